@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/haloyproxy"
 	"github.com/haloydev/haloy/internal/proxywire"
@@ -18,6 +19,7 @@ func main() {
 
 	switch cmd {
 	case "serve":
+		config.LoadHaloydEnvFiles()
 		debug := os.Getenv(constants.EnvVarDebug) == "true"
 		if err := haloyproxy.Run(debug); err != nil {
 			fmt.Fprintf(os.Stderr, "haloy-proxy: %v\n", err)
@@ -1,6 +1,10 @@
 package api
 
-import "time"
+import (
+	"time"
+
+	"github.com/haloydev/haloy/internal/config"
+)
 
 const (
 	defaultContextTimeout = 120 * time.Second
@@ -17,3 +21,13 @@ const (
 	// legitimate payload is an image config in an assemble request.
 	maxJSONBodyBytes = 32 << 20 // 32 MiB
 )
+
+// deployTimeout bounds a deployment or rollback of targetConfig: the usual
+// timeout, plus the time its startup probe gives the app to come up.
+func deployTimeout(targetConfig config.TargetConfig) time.Duration {
+	if targetConfig.Startup == nil {
+		return defaultContextTimeout
+	}
+	startup, _ := targetConfig.Startup.Durations()
+	return defaultContextTimeout + startup
+}
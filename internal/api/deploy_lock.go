@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+)
+
+// deployLockTTL bounds how long a lock survives if its deployment never
+// releases it. Deployments are themselves bounded by deployTimeout, at most
+// defaultContextTimeout plus config.MaxStartupTimeout, so an expired lock
+// always belongs to a deployment that is no longer running.
+const deployLockTTL = defaultContextTimeout + config.MaxStartupTimeout + time.Minute
+
+type deployLock struct {
+	info   apitypes.DeployLockInfo
+	cancel context.CancelFunc
+}
+
+// deployLocks serializes deployments and rollbacks per app. Locks live in
+// memory: haloyd waits for in-flight deployments when it shuts down, but
+// those still running when the grace period ends are aborted, and their locks
+// go with them.
+type deployLocks struct {
+	mu    sync.Mutex
+	locks map[string]*deployLock
+	now   func() time.Time
+	// idle is set by drain and closed once the last lock is released.
+	idle chan struct{}
+}
+
+func newDeployLocks() *deployLocks {
+	return &deployLocks{
+		locks: make(map[string]*deployLock),
+		now:   time.Now,
+	}
+}
+
+// acquire takes the lock for appName. When the app is already locked by a
+// live deployment, the current holder is returned and ok is false, unless
+// force is set, in which case the holder's deployment is canceled and the
+// lock is taken over. cancel is called if the lock is later stolen.
+func (l *deployLocks) acquire(appName, deploymentID, holder string, force bool, cancel context.CancelFunc) (current apitypes.DeployLockInfo, stolen *apitypes.DeployLockInfo, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Nothing is locked once draining starts; see draining.
+	if l.idle != nil {
+		return apitypes.DeployLockInfo{}, nil, false
+	}
+
+	now := l.now()
+	if existing, locked := l.locks[appName]; locked && now.Before(existing.info.ExpiresAt) {
+		if !force {
+			return existing.info, nil, false
+		}
+		existing.cancel()
+		previous := existing.info
+		stolen = &previous
+	}
+
+	info := apitypes.DeployLockInfo{
+		App:          appName,
+		DeploymentID: deploymentID,
+		Holder:       holder,
+		AcquiredAt:   now,
+		ExpiresAt:    now.Add(deployLockTTL),
+	}
+	l.locks[appName] = &deployLock{info: info, cancel: cancel}
+	return info, stolen, true
+}
+
+// release drops the lock for appName if deploymentID still holds it.
+func (l *deployLocks) release(appName, deploymentID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if existing, ok := l.locks[appName]; ok && existing.info.DeploymentID == deploymentID {
+		delete(l.locks, appName)
+		l.closeIdle()
+	}
+}
+
+// cancel cancels the deployment deploymentID if it holds a lock, and returns
+// its lock. The deployment releases the lock itself once it has stopped.
+func (l *deployLocks) cancel(deploymentID string) (apitypes.DeployLockInfo, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, lock := range l.locks {
+		if lock.info.DeploymentID == deploymentID && l.now().Before(lock.info.ExpiresAt) {
+			lock.cancel()
+			return lock.info, true
+		}
+	}
+	return apitypes.DeployLockInfo{}, false
+}
+
+// drain makes every later acquire fail and returns a channel that is closed
+// once the deployments holding locks have released them.
+func (l *deployLocks) drain() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.idle == nil {
+		l.idle = make(chan struct{})
+		l.closeIdle()
+	}
+	return l.idle
+}
+
+// draining reports whether drain was called, which is why acquire fails
+// after it.
+func (l *deployLocks) draining() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.idle != nil
+}
+
+// held returns the deployments holding a lock, as "app (deployment)".
+func (l *deployLocks) held() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var held []string
+	for _, app := range slices.Sorted(maps.Keys(l.locks)) {
+		held = append(held, fmt.Sprintf("%s (%s)", app, l.locks[app].info.DeploymentID))
+	}
+	return held
+}
+
+// closeIdle closes idle when draining and no lock is left. l.mu must be held.
+func (l *deployLocks) closeIdle() {
+	if l.idle == nil || len(l.locks) > 0 {
+		return
+	}
+	select {
+	case <-l.idle:
+	default:
+		close(l.idle)
+	}
+}
+
+// current returns the live lock for appName, if any.
+func (l *deployLocks) current(appName string) (apitypes.DeployLockInfo, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	existing, ok := l.locks[appName]
+	if !ok || !l.now().Before(existing.info.ExpiresAt) {
+		return apitypes.DeployLockInfo{}, false
+	}
+	return existing.info, true
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/logging"
+)
+
+func TestDeployLocks_ConflictAndRelease(t *testing.T) {
+	locks := newDeployLocks()
+
+	if _, _, ok := locks.acquire("app", "dep-1", "alice@laptop", false, func() {}); !ok {
+		t.Fatal("first acquire failed")
+	}
+
+	current, _, ok := locks.acquire("app", "dep-2", "bob@ci", false, func() {})
+	if ok {
+		t.Fatal("second acquire succeeded while lock was held")
+	}
+	if current.DeploymentID != "dep-1" || current.Holder != "alice@laptop" {
+		t.Fatalf("current = %+v, want holder of dep-1", current)
+	}
+
+	if _, _, ok := locks.acquire("other", "dep-3", "bob@ci", false, func() {}); !ok {
+		t.Fatal("lock on a different app should not conflict")
+	}
+
+	// A stale deployment must not release a lock it no longer holds.
+	locks.release("app", "dep-2")
+	if _, _, ok := locks.acquire("app", "dep-2", "bob@ci", false, func() {}); ok {
+		t.Fatal("release by non-holder dropped the lock")
+	}
+
+	locks.release("app", "dep-1")
+	if _, _, ok := locks.acquire("app", "dep-2", "bob@ci", false, func() {}); !ok {
+		t.Fatal("acquire after release failed")
+	}
+}
+
+func TestDeployLocks_ForceCancelsHolder(t *testing.T) {
+	locks := newDeployLocks()
+
+	canceled := false
+	locks.acquire("app", "dep-1", "alice@laptop", false, func() { canceled = true })
+
+	_, stolen, ok := locks.acquire("app", "dep-2", "bob@ci", true, func() {})
+	if !ok {
+		t.Fatal("forced acquire failed")
+	}
+	if stolen == nil || stolen.DeploymentID != "dep-1" {
+		t.Fatalf("stolen = %+v, want dep-1", stolen)
+	}
+	if !canceled {
+		t.Fatal("previous holder was not canceled")
+	}
+
+	// The canceled deployment finishing later must not drop the new lock.
+	locks.release("app", "dep-1")
+	if _, _, ok := locks.acquire("app", "dep-3", "carol", false, func() {}); ok {
+		t.Fatal("lock was released by the canceled deployment")
+	}
+}
+
+func TestDeployLocks_ExpiredLockIsReplaced(t *testing.T) {
+	locks := newDeployLocks()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	locks.now = func() time.Time { return now }
+
+	locks.acquire("app", "dep-1", "alice@laptop", false, func() {})
+
+	now = now.Add(deployLockTTL + time.Second)
+	_, stolen, ok := locks.acquire("app", "dep-2", "bob@ci", false, func() {})
+	if !ok {
+		t.Fatal("acquire over an expired lock failed")
+	}
+	if stolen != nil {
+		t.Fatalf("stolen = %+v, want nil for an expired lock", stolen)
+	}
+}
+
+func TestDeployTimeout_CoversStartup(t *testing.T) {
+	if got := deployTimeout(config.TargetConfig{}); got != defaultContextTimeout {
+		t.Errorf("deployTimeout() without startup = %s, want %s", got, defaultContextTimeout)
+	}
+
+	longest := config.TargetConfig{Startup: &config.StartupProbe{Timeout: config.MaxStartupTimeout.String()}}
+	if err := longest.Startup.Validate("yaml"); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	got := deployTimeout(longest)
+	if got <= config.MaxStartupTimeout {
+		t.Errorf("deployTimeout() = %s, want more than the startup timeout %s", got, config.MaxStartupTimeout)
+	}
+	// A lock must outlive the deployment holding it.
+	if deployLockTTL <= got {
+		t.Errorf("deployLockTTL = %s, want more than the longest deployment %s", deployLockTTL, got)
+	}
+}
+
+func TestHandleDeploy_ReturnsConflictWhenLocked(t *testing.T) {
+	s := newTestAPIServerForDeploy()
+	s.deployLocks.acquire("app", "dep-1", "alice@laptop", false, func() {})
+
+	body := `{"deploymentID":"dep-2","targetConfig":{"name":"app","server":"example.com","image":{"repository":"nginx"}}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/deploy", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	s.handleDeploy().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d (body %q)", rr.Code, http.StatusConflict, rr.Body.String())
+	}
+	var resp apitypes.DeployLockedResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Lock.DeploymentID != "dep-1" || resp.Lock.Holder != "alice@laptop" {
+		t.Fatalf("lock = %+v, want dep-1 held by alice@laptop", resp.Lock)
+	}
+}
+
+func TestDeployLocks_Drain(t *testing.T) {
+	locks := newDeployLocks()
+	locks.acquire("app", "dep-1", "alice@laptop", false, func() {})
+
+	idle := locks.drain()
+	if _, _, ok := locks.acquire("other", "dep-2", "bob@ci", false, func() {}); ok {
+		t.Fatal("acquire succeeded while draining")
+	}
+	if !locks.draining() {
+		t.Fatal("draining() = false after drain()")
+	}
+	select {
+	case <-idle:
+		t.Fatal("drain finished while a lock was held")
+	default:
+	}
+
+	locks.release("app", "dep-1")
+	select {
+	case <-idle:
+	default:
+		t.Fatal("drain did not finish once the last lock was released")
+	}
+}
+
+func TestHandleDeploy_ReturnsUnavailableWhileShuttingDown(t *testing.T) {
+	s := newTestAPIServerForDeploy()
+	s.deployLocks.drain()
+
+	body := `{"deploymentID":"dep-1","targetConfig":{"name":"app","server":"example.com","image":{"repository":"nginx"}}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/deploy", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	s.handleDeploy().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d (body %q)", rr.Code, http.StatusServiceUnavailable, rr.Body.String())
+	}
+}
+
+func TestShutdown_ReportsRunningDeployments(t *testing.T) {
+	s := NewServer("secret", nil, logging.NewLogBroker(), slog.LevelInfo)
+	s.deployLocks.acquire("app", "dep-1", "alice@laptop", false, func() {})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	err := s.Shutdown(ctx)
+	if err == nil || !strings.Contains(err.Error(), "app (dep-1)") {
+		t.Fatalf("Shutdown() error = %v, want the running deployment", err)
+	}
+	if s.streamsCtx.Err() == nil {
+		t.Error("streams were not canceled")
+	}
+	if err := s.ListenAndServe("127.0.0.1:0"); !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("ListenAndServe() after Shutdown = %v, want http.ErrServerClosed", err)
+	}
+}
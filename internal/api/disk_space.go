@@ -38,6 +38,7 @@ type layerPathResolver interface {
 
 type filesystemInfo struct {
 	Path           string
+	TotalBytes     uint64
 	AvailableBytes uint64
 	DeviceID       uint64
 }
@@ -100,6 +101,7 @@ func (osDiskSpaceProbe) FilesystemInfo(path string) (filesystemInfo, error) {
 
 	return filesystemInfo{
 		Path:           statPath,
+		TotalBytes:     statfs.Blocks * uint64(statfs.Bsize),
 		AvailableBytes: statfs.Bavail * uint64(statfs.Bsize),
 		DeviceID:       uint64(stat.Dev),
 	}, nil
@@ -254,10 +256,7 @@ func uploadDiskSpaceRequirements(ctx context.Context, cli dockerInfoProvider, pr
 		return nil, nil
 	}
 
-	tempDir, err := config.ImageTempDirPath()
-	if err != nil {
-		return nil, err
-	}
+	// Uploads stream into docker load, so only Docker's storage fills up.
 	dockerRootDir, err := dockerRootDir(ctx, cli)
 	if err != nil {
 		return nil, err
@@ -265,7 +264,6 @@ func uploadDiskSpaceRequirements(ctx context.Context, cli dockerInfoProvider, pr
 
 	return buildDiskSpaceRequirements(
 		probe, constants.DefaultImageDiskReserve,
-		diskSpaceContribution{Path: tempDir, Bytes: uploadSize},
 		diskSpaceContribution{Path: dockerRootDir, Bytes: uploadSize},
 	)
 }
@@ -275,10 +273,6 @@ func layeredUploadDiskSpaceRequirements(ctx context.Context, cli dockerInfoProvi
 		return nil, nil
 	}
 
-	tempDir, err := config.ImageTempDirPath()
-	if err != nil {
-		return nil, err
-	}
 	dockerRootDir, err := dockerRootDir(ctx, cli)
 	if err != nil {
 		return nil, err
@@ -292,7 +286,6 @@ func layeredUploadDiskSpaceRequirements(ctx context.Context, cli dockerInfoProvi
 	return buildDiskSpaceRequirements(
 		probe, constants.DefaultImageDiskReserve,
 		diskSpaceContribution{Path: layerStorageDir, Bytes: layerUploadBytes},
-		diskSpaceContribution{Path: tempDir, Bytes: assembledImageSizeBytes},
 		diskSpaceContribution{Path: dockerRootDir, Bytes: assembledImageSizeBytes},
 	)
 }
@@ -0,0 +1,428 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/errorpages"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/logging"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+// appsAPIHolder is shown as the holder of the deploy locks the apps API
+// takes.
+const appsAPIHolder = "apps API"
+
+// appSpecServer is the server of every spec. The app always runs on the
+// server the spec was sent to, so specs leave it out.
+const appSpecServer = "localhost"
+
+// handleGetApp returns the applied spec and status of an app managed through
+// the apps API.
+func (s *APIServer) handleGetApp() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		record, err := s.db.GetAppSpec(appName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if record == nil {
+			http.Error(w, fmt.Sprintf("app '%s' isn't managed through the apps API", appName), http.StatusNotFound)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), defaultContextTimeout)
+		defer cancel()
+		cli, err := docker.NewClient(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer cli.Close()
+
+		resource, err := s.appResource(ctx, cli, *record)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		encodeJSON(w, http.StatusOK, resource)
+	}
+}
+
+// handlePutApp makes an app match a spec. Applying the spec the app already
+// runs does nothing and returns 200; otherwise the app is deployed in the
+// background and 202 is returned. GET reports when the deployment is done.
+func (s *APIServer) handlePutApp() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		var req apitypes.AppSpecRequest
+		if err := decodeJSON(r.Body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		spec, hash, err := normalizeAppSpec(appName, req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid app spec: %v", err), http.StatusBadRequest)
+			return
+		}
+		record, err := s.db.GetAppSpec(appName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		cli, err := docker.NewClient(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer cli.Close()
+
+		if record != nil && record.SpecHash == hash {
+			resource, err := s.appResource(r.Context(), cli, *record)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !needsRedeploy(resource.Status) {
+				encodeJSON(w, http.StatusOK, resource)
+				return
+			}
+		}
+
+		targetConfig := spec
+		targetConfig.Server = appSpecServer
+		if err := s.applyServerRegistryAuth(&targetConfig); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to resolve server registry authentication: %v", err), http.StatusInternalServerError)
+			return
+		}
+		targetConfig, err = s.withDatabaseEnv(targetConfig)
+		if err != nil {
+			writeDatabaseEnvError(w, err)
+			return
+		}
+		targetConfig, err = s.withServerSecrets(targetConfig)
+		if err != nil {
+			writeServerSecretsError(w, err)
+			return
+		}
+		if s.deployDiskSpaceCheck != nil {
+			if err := s.deployDiskSpaceCheck(r.Context()); err != nil {
+				status := http.StatusInternalServerError
+				var spaceErr *insufficientDiskSpaceError
+				if errors.As(err, &spaceErr) {
+					status = http.StatusInsufficientStorage
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+		}
+		specJSON, err := json.Marshal(spec)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		deploymentID := helpers.NewDeploymentID()
+		logger := logging.NewDeploymentLogger(deploymentID, s.logLevel, s.logBroker)
+		ctx, cancel := context.WithTimeout(context.Background(), defaultContextTimeout)
+		if !s.acquireDeployLock(w, appName, deploymentID, appsAPIHolder, false, cancel, logger) {
+			cancel()
+			return
+		}
+
+		record = &storage.AppSpec{
+			AppName:      appName,
+			Spec:         specJSON,
+			SpecHash:     hash,
+			DeploymentID: deploymentID,
+			UpdatedAt:    time.Now().UTC(),
+		}
+		if err := s.db.SaveAppSpec(*record); err != nil {
+			s.deployLocks.release(appName, deploymentID)
+			cancel()
+			http.Error(w, fmt.Sprintf("Failed to save app spec: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		go func() {
+			defer cancel()
+			defer s.deployLocks.release(appName, deploymentID)
+
+			cli, err := docker.NewClient(ctx)
+			if err != nil {
+				logger.Error("Failed to create Docker client", "error", err)
+				s.db.SetAppSpecError(appName, deploymentID, err.Error())
+				return
+			}
+			defer cli.Close()
+
+			rollbackDeployConfig := config.DeployConfig{TargetConfig: spec}
+			rollbackDeployConfig.Server = appSpecServer
+			if err := s.runDeploy(ctx, cli, deploymentID, targetConfig, rollbackDeployConfig, req.ErrorPages, logger); err != nil {
+				if err := s.db.SetAppSpecError(appName, deploymentID, err.Error()); err != nil {
+					logger.Warn("Failed to record deployment failure", "app", appName, "error", err)
+				}
+			}
+		}()
+
+		encodeJSON(w, http.StatusAccepted, apitypes.AppResource{
+			Name:         appName,
+			Spec:         spec,
+			SpecHash:     hash,
+			DeploymentID: deploymentID,
+			Status:       apitypes.AppStatusDeploying,
+			UpdatedAt:    record.UpdatedAt,
+		})
+	}
+}
+
+// handlePlanApp reports what PUT would do with a spec, without changing
+// anything.
+func (s *APIServer) handlePlanApp() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		var req apitypes.AppSpecRequest
+		if err := decodeJSON(r.Body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		spec, hash, err := normalizeAppSpec(appName, req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid app spec: %v", err), http.StatusBadRequest)
+			return
+		}
+		record, err := s.db.GetAppSpec(appName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		status := ""
+		if record != nil && record.SpecHash == hash {
+			cli, err := docker.NewClient(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer cli.Close()
+			if status, err = s.appStatus(r.Context(), cli, *record); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		plan, err := planAppSpec(record, status, spec, hash)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		encodeJSON(w, http.StatusOK, plan)
+	}
+}
+
+// handleDeleteApp destroys an app like haloy destroy. Deleting an app that
+// doesn't exist succeeds, so retries are safe.
+func (s *APIServer) handleDeleteApp() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		query := r.URL.Query()
+		req := apitypes.DestroyAppRequest{
+			KeepVolumes:        query.Get("keepVolumes") == "true",
+			RemoveCertificates: query.Get("removeCertificates") == "true",
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), defaultContextTimeout)
+		defer cancel()
+		cli, err := docker.NewClient(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer cli.Close()
+
+		logger := logging.NewLogger(s.logLevel, s.logBroker)
+		response, err := s.DestroyApp(ctx, cli, appName, req, logger)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		encodeJSON(w, http.StatusOK, response)
+	}
+}
+
+func (s *APIServer) appResource(ctx context.Context, cli *client.Client, record storage.AppSpec) (apitypes.AppResource, error) {
+	resource := apitypes.AppResource{
+		Name:         record.AppName,
+		SpecHash:     record.SpecHash,
+		DeploymentID: record.DeploymentID,
+		Error:        record.Error,
+		UpdatedAt:    record.UpdatedAt,
+	}
+	if err := json.Unmarshal(record.Spec, &resource.Spec); err != nil {
+		return resource, fmt.Errorf("failed to read spec of %s: %w", record.AppName, err)
+	}
+	status, err := s.appStatus(ctx, cli, record)
+	if err != nil {
+		return resource, err
+	}
+	resource.Status = status
+	return resource, nil
+}
+
+// appStatus reports whether the app runs the deployment of its applied
+// spec.
+func (s *APIServer) appStatus(ctx context.Context, cli *client.Client, record storage.AppSpec) (string, error) {
+	if lock, ok := s.deployLocks.current(record.AppName); ok && lock.DeploymentID == record.DeploymentID {
+		return apitypes.AppStatusDeploying, nil
+	}
+	if record.Error != "" {
+		return apitypes.AppStatusFailed, nil
+	}
+	paused, err := s.db.GetPausedApp(record.AppName)
+	if err != nil {
+		return "", err
+	}
+	if paused != nil {
+		return apitypes.AppStatusStopped, nil
+	}
+	containers, err := docker.GetAppContainers(ctx, cli, false, record.AppName)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range containers {
+		if c.Labels[config.LabelDeploymentID] == record.DeploymentID {
+			return apitypes.AppStatusRunning, nil
+		}
+	}
+	return apitypes.AppStatusDrifted, nil
+}
+
+// needsRedeploy reports whether applying the spec an app was deployed with
+// deploys it again: when its deployment failed or isn't what's running.
+func needsRedeploy(status string) bool {
+	return status == apitypes.AppStatusFailed || status == apitypes.AppStatusDrifted
+}
+
+// normalizeAppSpec validates the spec of an app and fills in the defaults
+// the CLI would, returning it with its hash.
+func normalizeAppSpec(appName string, req apitypes.AppSpecRequest) (config.TargetConfig, string, error) {
+	spec := req.Spec
+	switch {
+	case spec.Name != "" && spec.Name != appName:
+		return spec, "", fmt.Errorf("name '%s' doesn't match the app '%s'", spec.Name, appName)
+	case spec.Server != "" || len(spec.Servers) > 0:
+		return spec, "", errors.New("server and servers can't be set; the app runs on the server the spec is sent to")
+	case spec.APIToken != nil:
+		return spec, "", errors.New("apiToken can't be set")
+	case len(spec.PreDeploy) > 0 || len(spec.PostDeploy) > 0:
+		return spec, "", errors.New("preDeploy and postDeploy hooks run in the CLI and can't be set")
+	case spec.ErrorPages != "":
+		return spec, "", errors.New("errorPages is a local directory; send the pages in the request's errorPages instead")
+	case spec.Preview != nil:
+		return spec, "", errors.New("previews are deployed with 'haloy preview create'")
+	case spec.ImageKey != "":
+		return spec, "", errors.New("imageKey refers to the images of a config file; set image instead")
+	case spec.Image == nil || spec.Image.Repository == "":
+		return spec, "", errors.New("image.repository is required")
+	case spec.Image.ShouldBuild():
+		return spec, "", errors.New("images can't be built through the apps API; push the image to a registry and set image.build to false")
+	}
+	if err := errorpages.ValidateBundle(req.ErrorPages); err != nil {
+		return spec, "", fmt.Errorf("invalid error pages: %w", err)
+	}
+
+	spec.Name = appName
+	spec.Server = appSpecServer
+	spec.Format = "json"
+	targets, err := configloader.ExtractTargets(config.DeployConfig{TargetConfig: spec}, "json")
+	if err != nil {
+		return spec, "", err
+	}
+	normalized, ok := targets[appName]
+	if !ok || len(targets) != 1 {
+		return spec, "", fmt.Errorf("spec of '%s' didn't resolve to a single target", appName)
+	}
+	normalized.Server = ""
+
+	data, err := json.Marshal(struct {
+		Spec       config.TargetConfig `json:"spec"`
+		ErrorPages map[string]string   `json:"errorPages"`
+	}{normalized, req.ErrorPages})
+	if err != nil {
+		return spec, "", err
+	}
+	sum := sha256.Sum256(data)
+	return normalized, hex.EncodeToString(sum[:]), nil
+}
+
+// planAppSpec returns what applying spec does, given the applied record and,
+// when the spec is unchanged, the app's status.
+func planAppSpec(record *storage.AppSpec, status string, spec config.TargetConfig, hash string) (apitypes.AppPlanResponse, error) {
+	plan := apitypes.AppPlanResponse{SpecHash: hash}
+	switch {
+	case record == nil:
+		plan.Action = apitypes.AppPlanCreate
+		return plan, nil
+	case record.SpecHash == hash:
+		plan.Action = apitypes.AppPlanNone
+		if needsRedeploy(status) {
+			plan.Action = apitypes.AppPlanRedeploy
+		}
+		return plan, nil
+	}
+
+	plan.Action = apitypes.AppPlanUpdate
+	var applied, desired map[string]json.RawMessage
+	if err := json.Unmarshal(record.Spec, &applied); err != nil {
+		return plan, fmt.Errorf("failed to read applied spec: %w", err)
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return plan, err
+	}
+	if err := json.Unmarshal(data, &desired); err != nil {
+		return plan, err
+	}
+	fields := make(map[string]bool)
+	for _, m := range []map[string]json.RawMessage{applied, desired} {
+		for field := range m {
+			if !jsonEqual(applied[field], desired[field]) {
+				fields[field] = true
+			}
+		}
+	}
+	// Only the error pages, which aren't part of the spec, changed.
+	if len(fields) == 0 {
+		fields["errorPages"] = true
+	}
+	plan.Fields = slices.Sorted(maps.Keys(fields))
+	return plan, nil
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return string(a) == string(b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+func TestNormalizeAppSpec(t *testing.T) {
+	image := func() *config.Image {
+		return &config.Image{Repository: "ghcr.io/acme/shop", Tag: "1.2.0"}
+	}
+
+	tests := []struct {
+		name    string
+		spec    config.TargetConfig
+		wantErr string
+	}{
+		{name: "valid", spec: config.TargetConfig{Image: image()}},
+		{name: "matching name", spec: config.TargetConfig{Name: "shop", Image: image()}},
+		{name: "other name", spec: config.TargetConfig{Name: "blog", Image: image()}, wantErr: "doesn't match"},
+		{name: "server", spec: config.TargetConfig{Server: "haloy.example.com", Image: image()}, wantErr: "server"},
+		{name: "hooks", spec: config.TargetConfig{PreDeploy: []string{"make"}, Image: image()}, wantErr: "hooks"},
+		{name: "no image", spec: config.TargetConfig{}, wantErr: "image.repository"},
+		{name: "build", spec: config.TargetConfig{Image: &config.Image{Repository: "shop", Build: new(true)}}, wantErr: "built"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, hash, err := normalizeAppSpec("shop", apitypes.AppSpecRequest{Spec: tt.spec})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("normalizeAppSpec() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeAppSpec() error = %v", err)
+			}
+			if spec.Name != "shop" || spec.Server != "" {
+				t.Errorf("normalizeAppSpec() name = %q, server = %q, want shop and no server", spec.Name, spec.Server)
+			}
+			if hash == "" {
+				t.Error("normalizeAppSpec() returned no hash")
+			}
+		})
+	}
+
+	_, withName, _ := normalizeAppSpec("shop", apitypes.AppSpecRequest{Spec: config.TargetConfig{Name: "shop", Image: image()}})
+	_, withoutName, _ := normalizeAppSpec("shop", apitypes.AppSpecRequest{Spec: config.TargetConfig{Image: image()}})
+	if withName != withoutName {
+		t.Error("normalizeAppSpec() hash depends on whether defaults were spelled out")
+	}
+	_, withPages, _ := normalizeAppSpec("shop", apitypes.AppSpecRequest{
+		Spec:       config.TargetConfig{Image: image()},
+		ErrorPages: map[string]string{"502.html": "<h1>Down</h1>"},
+	})
+	if withPages == withoutName {
+		t.Error("normalizeAppSpec() hash ignores error pages")
+	}
+}
+
+func TestPlanAppSpec(t *testing.T) {
+	applied := config.TargetConfig{Name: "shop", Image: &config.Image{Repository: "shop", Tag: "1"}, Port: "8080"}
+	appliedJSON, err := json.Marshal(applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := &storage.AppSpec{AppName: "shop", Spec: appliedJSON, SpecHash: "a"}
+
+	updated := applied
+	updated.Image = &config.Image{Repository: "shop", Tag: "2"}
+	updated.Replicas = new(2)
+
+	tests := []struct {
+		name       string
+		record     *storage.AppSpec
+		status     string
+		spec       config.TargetConfig
+		hash       string
+		wantAction string
+		wantFields []string
+	}{
+		{name: "new app", spec: applied, hash: "a", wantAction: apitypes.AppPlanCreate},
+		{name: "unchanged", record: record, status: apitypes.AppStatusRunning, spec: applied, hash: "a", wantAction: apitypes.AppPlanNone},
+		{name: "unchanged but failed", record: record, status: apitypes.AppStatusFailed, spec: applied, hash: "a", wantAction: apitypes.AppPlanRedeploy},
+		{name: "unchanged but drifted", record: record, status: apitypes.AppStatusDrifted, spec: applied, hash: "a", wantAction: apitypes.AppPlanRedeploy},
+		{name: "changed", record: record, spec: updated, hash: "b", wantAction: apitypes.AppPlanUpdate, wantFields: []string{"image", "replicas"}},
+		{name: "changed error pages", record: record, spec: applied, hash: "b", wantAction: apitypes.AppPlanUpdate, wantFields: []string{"errorPages"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planAppSpec(tt.record, tt.status, tt.spec, tt.hash)
+			if err != nil {
+				t.Fatalf("planAppSpec() error = %v", err)
+			}
+			if plan.Action != tt.wantAction {
+				t.Errorf("planAppSpec() action = %q, want %q", plan.Action, tt.wantAction)
+			}
+			if !slices.Equal(plan.Fields, tt.wantFields) {
+				t.Errorf("planAppSpec() fields = %v, want %v", plan.Fields, tt.wantFields)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+func (s *APIServer) handleCertificates() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := certificatesStatus(s.db)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read certificates: %v", err), http.StatusInternalServerError)
+			return
+		}
+		encodeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// certificatesStatus joins the current certificates with the issuance
+// failure records, listing domains that have either.
+func certificatesStatus(db *storage.DB) (apitypes.CertificatesResponse, error) {
+	resp := apitypes.CertificatesResponse{Certificates: []apitypes.CertificateStatus{}}
+
+	certs, err := db.ListCurrentCertificates()
+	if err != nil {
+		return resp, err
+	}
+	issuances, err := db.ListCertificateIssuance()
+	if err != nil {
+		return resp, err
+	}
+
+	byDomain := make(map[string]*apitypes.CertificateStatus)
+	status := func(domain string) *apitypes.CertificateStatus {
+		if s, ok := byDomain[domain]; ok {
+			return s
+		}
+		s := &apitypes.CertificateStatus{Domain: domain}
+		byDomain[domain] = s
+		return s
+	}
+	for _, cert := range certs {
+		s := status(cert.Domain)
+		s.Names = cert.Names
+		s.NotAfter = &cert.NotAfter
+		s.Staging = cert.Staging
+	}
+	for _, issuance := range issuances {
+		s := status(issuance.Domain)
+		s.Failures = issuance.Failures
+		s.LastError = issuance.LastError
+		s.RateLimited = issuance.RateLimited
+		s.NextAttemptAt = &issuance.NextAttemptAt
+	}
+
+	for _, s := range byDomain {
+		resp.Certificates = append(resp.Certificates, *s)
+	}
+	slices.SortFunc(resp.Certificates, func(a, b apitypes.CertificateStatus) int {
+		return strings.Compare(a.Domain, b.Domain)
+	})
+	return resp, nil
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+func TestCertificatesStatus(t *testing.T) {
+	db := newTestAPIServerWithDB(t).db
+
+	notAfter := time.Now().Add(60 * 24 * time.Hour).UTC().Truncate(time.Second)
+	if _, err := db.SaveCertificate(storage.Certificate{
+		Domain:   "example.com",
+		Names:    []string{"example.com", "www.example.com"},
+		CertPEM:  []byte("cert"),
+		KeyPEM:   []byte("key"),
+		NotAfter: notAfter,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	next := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	if err := db.SaveCertificateIssuance(storage.CertificateIssuance{
+		Domain:        "broken.example.com",
+		Failures:      3,
+		LastAttemptAt: time.Now(),
+		LastError:     "rate limited",
+		RateLimited:   true,
+		NextAttemptAt: next,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := certificatesStatus(db)
+	if err != nil {
+		t.Fatalf("certificatesStatus() error = %v", err)
+	}
+	if len(resp.Certificates) != 2 {
+		t.Fatalf("got %d certificates, want 2", len(resp.Certificates))
+	}
+
+	broken, ok := resp.Certificates[0], resp.Certificates[1]
+	if broken.Domain != "broken.example.com" || broken.Failures != 3 || !broken.RateLimited ||
+		broken.NextAttemptAt == nil || !broken.NextAttemptAt.Equal(next) || broken.NotAfter != nil {
+		t.Errorf("failing domain = %+v", broken)
+	}
+	if ok.Domain != "example.com" || ok.NotAfter == nil || !ok.NotAfter.Equal(notAfter) || ok.Failures != 0 || len(ok.Names) != 2 {
+		t.Errorf("issued domain = %+v", ok)
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+// Response headers describing a copy-from-container archive.
+const (
+	copyContainerHeader = "X-Haloy-Container"
+	copySizeHeader      = "X-Haloy-Copy-Size" // set when the source is a regular file
+)
+
+// copyRequestPath returns the container path from the query, which must be absolute.
+func copyRequestPath(r *http.Request) (string, error) {
+	p := r.URL.Query().Get("path")
+	if p == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	if !path.IsAbs(p) {
+		return "", fmt.Errorf("path must be absolute")
+	}
+	return path.Clean(p), nil
+}
+
+// handleCopyFromContainer streams a tar archive of a path in one of the app's
+// containers.
+func (s *APIServer) handleCopyFromContainer() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		srcPath, err := copyRequestPath(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		cli, containerList, err := getAppContainers(ctx, appName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		defer cli.Close()
+
+		targetIDs, err := selectContainers(containerList, r.URL.Query().Get("container"), false)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		containerID := targetIDs[0]
+
+		archive, stat, err := docker.CopyFromContainer(ctx, cli, containerID, srcPath)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if client.IsErrNotFound(err) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		defer archive.Close()
+
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Header().Set(copyContainerHeader, helpers.SafeIDPrefix(containerID))
+		if stat.Mode.IsRegular() {
+			w.Header().Set(copySizeHeader, strconv.FormatInt(stat.Size, 10))
+		}
+		w.WriteHeader(http.StatusOK)
+		io.Copy(w, archive)
+	}
+}
+
+// handleCopyToContainer extracts the tar archive in the request body into
+// one or all of the app's containers.
+func (s *APIServer) handleCopyToContainer() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		dstPath, err := copyRequestPath(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query := r.URL.Query()
+		allContainers := query.Get("all") == "true"
+
+		ctx := r.Context()
+		cli, containerList, err := getAppContainers(ctx, appName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		defer cli.Close()
+
+		targetIDs, err := selectContainers(containerList, query.Get("container"), allContainers)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The body can only be read once; spool it when several containers need it.
+		var content io.ReadSeeker
+		if len(targetIDs) > 1 {
+			spool, err := os.CreateTemp("", "haloy-cp-*.tar")
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to buffer upload: %v", err), http.StatusInternalServerError)
+				return
+			}
+			defer os.Remove(spool.Name())
+			defer spool.Close()
+			if _, err := io.Copy(spool, r.Body); err != nil {
+				http.Error(w, fmt.Sprintf("failed to buffer upload: %v", err), http.StatusBadRequest)
+				return
+			}
+			content = spool
+		}
+
+		results := make([]apitypes.CopyResult, 0, len(targetIDs))
+		for _, containerID := range targetIDs {
+			body := io.Reader(r.Body)
+			if content != nil {
+				if _, err := content.Seek(0, io.SeekStart); err != nil {
+					http.Error(w, fmt.Sprintf("failed to rewind upload: %v", err), http.StatusInternalServerError)
+					return
+				}
+				body = content
+			}
+
+			result := apitypes.CopyResult{ContainerID: helpers.SafeIDPrefix(containerID)}
+			if err := docker.CopyToContainer(ctx, cli, containerID, dstPath, body); err != nil {
+				result.Error = err.Error()
+			}
+			results = append(results, result)
+		}
+
+		encodeJSON(w, http.StatusOK, apitypes.CopyToContainerResponse{Results: results})
+	}
+}
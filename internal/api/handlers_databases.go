@@ -0,0 +1,247 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/dbbackup"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/logging"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+// databaseOperationTimeout bounds a backup or restore started through the API.
+const databaseOperationTimeout = 2 * time.Hour
+
+// errDatabaseNotDeployed is returned when a target names a database that
+// haloyd has no credentials for yet.
+var errDatabaseNotDeployed = errors.New("database not deployed")
+
+// withDatabaseEnv gives a managed database the credentials it's initialized
+// with, and a target using one its connection string. The credentials are
+// generated on the database's first deploy and kept in storage afterwards.
+func (s *APIServer) withDatabaseEnv(targetConfig config.TargetConfig) (config.TargetConfig, error) {
+	var env []config.EnvVar
+
+	if database := targetConfig.Database; database != nil {
+		password, err := generateDatabasePassword()
+		if err != nil {
+			return targetConfig, err
+		}
+		creds, err := s.db.EnsureDatabaseCredentials(storage.DatabaseCredentials{
+			AppName:   targetConfig.Name,
+			Engine:    string(database.Engine),
+			Database:  database.GetName(targetConfig.Name),
+			Username:  database.GetUser(targetConfig.Name),
+			Password:  password,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			return targetConfig, err
+		}
+		env = append(env,
+			config.EnvVar{Name: "POSTGRES_USER", ValueSource: config.ValueSource{Value: creds.Username}},
+			config.EnvVar{Name: "POSTGRES_PASSWORD", ValueSource: config.ValueSource{Value: creds.Password}},
+			config.EnvVar{Name: "POSTGRES_DB", ValueSource: config.ValueSource{Value: creds.Database}},
+		)
+	}
+
+	if from := targetConfig.DatabaseURLFrom; from != "" {
+		creds, err := s.db.GetDatabaseCredentials(from)
+		if err != nil {
+			return targetConfig, err
+		}
+		if creds == nil {
+			return targetConfig, fmt.Errorf("%w: deploy the database target '%s' before the targets using it", errDatabaseNotDeployed, from)
+		}
+		env = append(env, config.EnvVar{Name: "DATABASE_URL", ValueSource: config.ValueSource{Value: databaseURL(*creds)}})
+	}
+
+	if len(env) == 0 {
+		return targetConfig, nil
+	}
+	// Copied rather than appended to, as the slice is shared with the request.
+	targetConfig.Env = slices.Concat(targetConfig.Env, env)
+	return targetConfig, nil
+}
+
+// databaseURL returns the connection string of a managed database. Databases
+// use static naming, so the container name is its host on the haloy network.
+func databaseURL(creds storage.DatabaseCredentials) string {
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(creds.Username, creds.Password),
+		Host:   creds.AppName + ":5432",
+		Path:   "/" + creds.Database,
+	}
+	return u.String()
+}
+
+func generateDatabasePassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate database password: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// writeDatabaseEnvError answers a deploy whose database variables couldn't
+// be set.
+func writeDatabaseEnvError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, errDatabaseNotDeployed) {
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func (s *APIServer) handleDatabaseBackups() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		backups, err := s.databaseBackups()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		list, err := backups.List(r.Context(), appName)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to list backups: %v", err), http.StatusBadGateway)
+			return
+		}
+		if err := encodeJSON(w, http.StatusOK, apitypes.DatabaseBackupsResponse{Backups: list}); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+	}
+}
+
+// handleDatabaseBackup starts a backup of a managed database. Its progress is
+// streamed from the deployment logs endpoint under the operation ID.
+func (s *APIServer) handleDatabaseBackup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		var req apitypes.DatabaseBackupRequest
+		if err := decodeJSON(r.Body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.OperationID == "" {
+			http.Error(w, "Operation ID is required", http.StatusBadRequest)
+			return
+		}
+		backups, err := s.databaseBackups()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.runDatabaseOperation(w, r, appName, req.OperationID, func(ctx context.Context, cli *client.Client, containerID string, logger *slog.Logger) (string, error) {
+			logger.Info("Backing up database", "app", appName)
+			backup, err := backups.Backup(ctx, cli, appName, containerID)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Backup %s created (%d bytes)", backup.Name, backup.Size), nil
+		})
+	}
+}
+
+// handleDatabaseRestore starts a restore of a managed database from a backup,
+// its own or, with a source app, another database's.
+func (s *APIServer) handleDatabaseRestore() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		var req apitypes.DatabaseRestoreRequest
+		if err := decodeJSON(r.Body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.OperationID == "" || req.Backup == "" {
+			http.Error(w, "Operation ID and backup are required", http.StatusBadRequest)
+			return
+		}
+		if req.SourceApp == appName {
+			req.SourceApp = ""
+		}
+		if req.Anonymize != "" && req.SourceApp == "" {
+			http.Error(w, "Anonymization only applies when cloning another app's backup", http.StatusBadRequest)
+			return
+		}
+		backups, err := s.databaseBackups()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.runDatabaseOperation(w, r, appName, req.OperationID, func(ctx context.Context, cli *client.Client, containerID string, logger *slog.Logger) (string, error) {
+			if req.SourceApp != "" {
+				logger.Info("Cloning database", "app", appName, "source", req.SourceApp, "backup", req.Backup, "anonymize", req.Anonymize != "")
+				if err := backups.Clone(ctx, cli, req.SourceApp, containerID, req.Backup, req.Anonymize); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Restored %s from %s of %s", appName, req.Backup, req.SourceApp), nil
+			}
+			logger.Info("Restoring database", "app", appName, "backup", req.Backup)
+			if err := backups.Restore(ctx, cli, appName, containerID, req.Backup); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Restored %s from %s", appName, req.Backup), nil
+		})
+	}
+}
+
+// runDatabaseOperation finds the database container of appName and runs op
+// on it in the background, logging under operationID.
+func (s *APIServer) runDatabaseOperation(w http.ResponseWriter, r *http.Request, appName, operationID string, op func(ctx context.Context, cli *client.Client, containerID string, logger *slog.Logger) (string, error)) {
+	cli, err := docker.NewClient(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create Docker client: %v", err), http.StatusInternalServerError)
+		return
+	}
+	containers, err := dbbackup.Containers(r.Context(), cli)
+	if err != nil {
+		cli.Close()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	containerID, ok := containers[appName]
+	if !ok {
+		cli.Close()
+		http.Error(w, fmt.Sprintf("No running managed database found for app '%s'", appName), http.StatusNotFound)
+		return
+	}
+
+	logger := logging.NewDeploymentLogger(operationID, s.logLevel, s.logBroker)
+	go func() {
+		defer cli.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), databaseOperationTimeout)
+		defer cancel()
+
+		message, err := op(ctx, cli, containerID, logger)
+		if err != nil {
+			logging.LogDeploymentFailed(logger, operationID, appName, "Database operation failed", err)
+			return
+		}
+		logging.LogDeploymentComplete(logger, nil, operationID, appName, message)
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *APIServer) databaseBackups() (*dbbackup.Backups, error) {
+	haloydConfig, err := config.LoadDefaultHaloydConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load haloyd config: %w", err)
+	}
+	return dbbackup.New(haloydConfig.DatabaseBackups)
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestWithDatabaseEnv(t *testing.T) {
+	s := newTestAPIServerWithDB(t)
+
+	envOf := func(tc config.TargetConfig) map[string]string {
+		m := make(map[string]string)
+		for _, e := range tc.Env {
+			m[e.Name] = e.Value
+		}
+		return m
+	}
+
+	web := config.TargetConfig{Name: "shop", DatabaseURLFrom: "shop-db"}
+	if _, err := s.withDatabaseEnv(web); !errors.Is(err, errDatabaseNotDeployed) {
+		t.Fatalf("withDatabaseEnv() before the database is deployed error = %v, want errDatabaseNotDeployed", err)
+	}
+
+	db := config.TargetConfig{Name: "shop-db", Database: &config.DatabaseConfig{Engine: config.DatabaseEnginePostgres}}
+	got, err := s.withDatabaseEnv(db)
+	if err != nil {
+		t.Fatalf("withDatabaseEnv(database) error = %v", err)
+	}
+	dbEnv := envOf(got)
+	if dbEnv["POSTGRES_USER"] != "shop_db" || dbEnv["POSTGRES_DB"] != "shop_db" || len(dbEnv["POSTGRES_PASSWORD"]) != 48 {
+		t.Fatalf("database env = %v", dbEnv)
+	}
+
+	// Redeploying keeps the password the database was initialized with.
+	again, err := s.withDatabaseEnv(db)
+	if err != nil {
+		t.Fatalf("withDatabaseEnv(database) redeploy error = %v", err)
+	}
+	if envOf(again)["POSTGRES_PASSWORD"] != dbEnv["POSTGRES_PASSWORD"] {
+		t.Error("redeploy generated a new password")
+	}
+
+	got, err = s.withDatabaseEnv(web)
+	if err != nil {
+		t.Fatalf("withDatabaseEnv(app) error = %v", err)
+	}
+	u, err := url.Parse(envOf(got)["DATABASE_URL"])
+	if err != nil {
+		t.Fatalf("DATABASE_URL is not a URL: %v", err)
+	}
+	password, _ := u.User.Password()
+	if u.Scheme != "postgres" || u.Host != "shop-db:5432" || u.Path != "/shop_db" || u.User.Username() != "shop_db" || password != dbEnv["POSTGRES_PASSWORD"] {
+		t.Errorf("DATABASE_URL = %s", u)
+	}
+	if len(web.Env) != 0 {
+		t.Error("withDatabaseEnv modified the request's target config")
+	}
+}
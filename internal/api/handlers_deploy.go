@@ -2,12 +2,17 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 
+	"github.com/docker/docker/client"
 	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/deploy"
 	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/errorpages"
 	"github.com/haloydev/haloy/internal/logging"
 )
 
@@ -35,13 +40,54 @@ func (s *APIServer) handleDeploy() http.HandlerFunc {
 			http.Error(w, fmt.Sprintf("Invalid deploy configuration: %v", err), http.StatusBadRequest)
 			return
 		}
+		if err := checkClusterSSO(req.TargetConfig); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid deploy configuration: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := errorpages.ValidateBundle(req.ErrorPages); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid error pages: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		targetConfig, err := s.withDatabaseEnv(req.TargetConfig)
+		if err != nil {
+			writeDatabaseEnvError(w, err)
+			return
+		}
+		targetConfig, err = s.withServerSecrets(targetConfig)
+		if err != nil {
+			writeServerSecretsError(w, err)
+			return
+		}
+
+		// Checked up front: running out of space halfway through a pull or
+		// container start leaves errors that don't point at the disk.
+		if s.deployDiskSpaceCheck != nil {
+			if err := s.deployDiskSpaceCheck(r.Context()); err != nil {
+				status := http.StatusInternalServerError
+				var spaceErr *insufficientDiskSpaceError
+				if errors.As(err, &spaceErr) {
+					status = http.StatusInsufficientStorage
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+		}
 
 		deploymentLogger := logging.NewDeploymentLogger(req.DeploymentID, s.logLevel, s.logBroker)
 
-		ctx, cancel := context.WithTimeout(context.Background(), defaultContextTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), deployTimeout(targetConfig))
+
+		appName := req.TargetConfig.Name
+		if !s.acquireDeployLock(w, appName, req.DeploymentID, req.Holder, req.ForceUnlock, cancel, deploymentLogger) {
+			cancel()
+			return
+		}
 
 		go func() {
 			defer cancel()
+			defer s.deployLocks.release(appName, req.DeploymentID)
 
 			cli, err := docker.NewClient(ctx)
 			if err != nil {
@@ -50,16 +96,122 @@ func (s *APIServer) handleDeploy() http.HandlerFunc {
 			}
 			defer cli.Close()
 
-			if err := deploy.DeployApp(ctx, cli, s.db, req.DeploymentID, req.TargetConfig, req.RollbackDeployConfig, deploymentLogger); err != nil {
-				logging.LogDeploymentFailed(deploymentLogger, req.DeploymentID, req.TargetConfig.Name, "Deployment failed", err)
-				return
-			}
+			s.saveDeploymentSpec(req.DeploymentID, req.TargetConfig, req.RollbackDeployConfig, req.ErrorPages, deploymentLogger)
+			s.runDeploy(ctx, cli, req.DeploymentID, targetConfig, req.RollbackDeployConfig, req.ErrorPages, deploymentLogger)
 		}()
 
-		w.WriteHeader(http.StatusAccepted)
+		encodeJSON(w, http.StatusAccepted, apitypes.DeployResponse{DeploymentID: req.DeploymentID})
 	}
 }
 
+// DeployTarget deploys targetConfig like the deploy endpoint, for deploys
+// haloyd starts itself, and returns once the containers are started. It
+// fails instead of waiting when the app is already being deployed.
+func (s *APIServer) DeployTarget(ctx context.Context, cli *client.Client, deploymentID, holder string, targetConfig config.TargetConfig, rollbackDeployConfig config.DeployConfig, errorPages map[string]string) error {
+	if err := s.applyServerRegistryAuth(&targetConfig); err != nil {
+		return fmt.Errorf("failed to resolve server registry authentication: %w", err)
+	}
+	if err := targetConfig.Validate(targetConfig.Format); err != nil {
+		return fmt.Errorf("invalid deploy configuration: %w", err)
+	}
+	if err := checkClusterSSO(targetConfig); err != nil {
+		return fmt.Errorf("invalid deploy configuration: %w", err)
+	}
+	if err := errorpages.ValidateBundle(errorPages); err != nil {
+		return fmt.Errorf("invalid error pages: %w", err)
+	}
+	requested := targetConfig
+	targetConfig, err := s.withDatabaseEnv(targetConfig)
+	if err != nil {
+		return err
+	}
+	targetConfig, err = s.withServerSecrets(targetConfig)
+	if err != nil {
+		return err
+	}
+	if s.deployDiskSpaceCheck != nil {
+		if err := s.deployDiskSpaceCheck(ctx); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deployTimeout(targetConfig))
+	defer cancel()
+
+	appName := targetConfig.Name
+	current, _, ok := s.deployLocks.acquire(appName, deploymentID, holder, false, cancel)
+	if !ok && s.deployLocks.draining() {
+		return errShuttingDown
+	}
+	if !ok {
+		return fmt.Errorf("app '%s' is already being deployed (deployment %s)", appName, current.DeploymentID)
+	}
+	defer s.deployLocks.release(appName, deploymentID)
+
+	logger := logging.NewDeploymentLogger(deploymentID, s.logLevel, s.logBroker)
+	s.saveDeploymentSpec(deploymentID, requested, rollbackDeployConfig, errorPages, logger)
+	return s.runDeploy(ctx, cli, deploymentID, targetConfig, rollbackDeployConfig, errorPages, logger)
+}
+
+// checkClusterSSO refuses SSO on a server whose cluster peer listener talks
+// plain HTTP: the edge gets the route's client secret from it, and the
+// secret also signs session cookies.
+func checkClusterSSO(targetConfig config.TargetConfig) error {
+	if targetConfig.SSO == nil {
+		return nil
+	}
+	haloydConfig, err := config.LoadDefaultHaloydConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load haloyd config: %w", err)
+	}
+	if haloydConfig.Cluster.Listen != "" && !haloydConfig.Cluster.UsesMutualTLS() {
+		return errors.New("sso can't be used while cluster.listen serves plain HTTP; set cluster.ca_file, cluster.cert_file and cluster.key_file in haloyd.yaml")
+	}
+	return nil
+}
+
+// runDeploy deploys a validated target while holding its deploy lock.
+func (s *APIServer) runDeploy(ctx context.Context, cli *client.Client, deploymentID string, targetConfig config.TargetConfig, rollbackDeployConfig config.DeployConfig, errorPages map[string]string, logger *slog.Logger) error {
+	if err := deploy.DeployApp(ctx, cli, s.db, deploymentID, s.withMigrationLockEnv(targetConfig), rollbackDeployConfig, logger); err != nil {
+		// A deployment canceled or timed out halfway through starting its
+		// replicas leaves the ones it started behind.
+		if ctx.Err() != nil {
+			removeDeploymentContainers(cli, targetConfig.Name, deploymentID, logger)
+		}
+		logging.LogDeploymentFailed(logger, deploymentID, targetConfig.Name, "Deployment failed", err)
+		return err
+	}
+
+	// Pages are swapped only once the new version is live; the proxy
+	// picks them up on the next error it serves.
+	if err := s.writeErrorPages(targetConfig.Name, errorPages); err != nil {
+		logger.Warn("Failed to update custom error pages", "error", err)
+	}
+	return nil
+}
+
+// acquireDeployLock takes the app's deploy lock or writes a 409 describing
+// the deployment holding it. Returns false when the request was rejected.
+func (s *APIServer) acquireDeployLock(w http.ResponseWriter, appName, deploymentID, holder string, force bool, cancel context.CancelFunc, logger *slog.Logger) bool {
+	current, stolen, ok := s.deployLocks.acquire(appName, deploymentID, holder, force, cancel)
+	if !ok && s.deployLocks.draining() {
+		http.Error(w, errShuttingDown.Error(), http.StatusServiceUnavailable)
+		return false
+	}
+	if !ok {
+		encodeJSON(w, http.StatusConflict, apitypes.DeployLockedResponse{
+			Error: fmt.Sprintf("app '%s' is already being deployed (deployment %s)", appName, current.DeploymentID),
+			Lock:  current,
+		})
+		return false
+	}
+	if stolen != nil {
+		logger.Warn("Took over deploy lock, canceling previous deployment",
+			"app", appName, "previousDeploymentID", stolen.DeploymentID, "previousHolder", stolen.Holder)
+	}
+	return true
+}
+
 // handleDeploymentLogs handles SSE connections for deployment logs
 func (s *APIServer) handleDeploymentLogs() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -69,13 +221,19 @@ func (s *APIServer) handleDeploymentLogs() http.HandlerFunc {
 			return
 		}
 
+		after, err := streamCursor(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Subscribe to logs for this deployment ID
 		// Don't pass request context - use background context with manual cleanup
-		logChan := s.logBroker.SubscribeDeployment(deploymentID)
+		logChan, subscriberID := s.logBroker.SubscribeDeployment(deploymentID, after)
 
 		streamConfig := sseStreamConfig{
 			logChan: logChan,
-			cleanup: func() { s.logBroker.UnsubscribeDeployment(deploymentID) },
+			cleanup: func() { s.logBroker.Unsubscribe(subscriberID) },
 			shouldTerminate: func(logEntry logging.LogEntry) bool {
 				return logEntry.IsDeploymentComplete || logEntry.IsDeploymentFailed
 			},
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/deploystate"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/logging"
+)
+
+// errDeploymentCanceled is the failure reported for a deployment canceled
+// while its containers waited on their health checks.
+var errDeploymentCanceled = errors.New("deployment canceled")
+
+// handleCancelDeployment aborts a deployment that hasn't gone live. One that
+// is still preparing its image or starting its containers holds the app's
+// deploy lock; it is canceled through it and removes the containers it
+// started. One whose containers are waiting on their health checks is
+// marked failed and has them removed, so the app keeps serving the
+// deployment it had.
+func (s *APIServer) handleCancelDeployment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deploymentID := r.PathValue("deploymentID")
+		if deploymentID == "" {
+			http.Error(w, "Deployment ID is required", http.StatusBadRequest)
+			return
+		}
+
+		if lock, ok := s.deployLocks.cancel(deploymentID); ok {
+			logging.NewDeploymentLogger(deploymentID, s.logLevel, s.logBroker).
+				Warn("Canceling deployment", "app", lock.App)
+			encodeJSON(w, http.StatusAccepted, apitypes.CancelDeploymentResponse{DeploymentID: deploymentID, App: lock.App})
+			return
+		}
+
+		events, err := s.db.GetDeploymentEvents(deploymentID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(events) == 0 {
+			http.Error(w, "Deployment not found", http.StatusNotFound)
+			return
+		}
+		latest := events[len(events)-1]
+		appName := latest.AppName
+		if state := deploystate.State(latest.State); state != deploystate.Pending && state != deploystate.Starting {
+			http.Error(w, fmt.Sprintf("Deployment %s is %s and can no longer be canceled", deploymentID, state), http.StatusConflict)
+			return
+		}
+
+		// Failing it first keeps it from going live should its health
+		// checks pass while the containers are removed: the update that
+		// would route it has to promote it to live first, which a failed
+		// deployment can't be. If that update promoted it first, this
+		// transition is refused and the deployment stays live.
+		if err := deploystate.New(s.db).Transition(deploymentID, appName, deploystate.Failed, errDeploymentCanceled.Error()); err != nil {
+			http.Error(w, fmt.Sprintf("Deployment %s can no longer be canceled: %v", deploymentID, err), http.StatusConflict)
+			return
+		}
+
+		logger := logging.NewDeploymentLogger(deploymentID, s.logLevel, s.logBroker)
+		cli, err := docker.NewClient(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to create Docker client: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer cli.Close()
+		removeDeploymentContainers(cli, appName, deploymentID, logger)
+		logging.LogDeploymentFailedKind(logger, deploymentID, appName, logging.FailureKindCanceled, "Deployment failed", errDeploymentCanceled)
+
+		encodeJSON(w, http.StatusOK, apitypes.CancelDeploymentResponse{DeploymentID: deploymentID, App: appName})
+	}
+}
+
+// removeDeploymentContainers stops and removes the containers of a
+// deployment that won't go live. It doesn't take a context, as it runs once
+// the deployment's own was canceled.
+func removeDeploymentContainers(cli *client.Client, appName, deploymentID string, logger *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if _, err := docker.StopContainersByDeploymentID(ctx, cli, logger, appName, deploymentID); err != nil {
+		logger.Warn("Failed to stop containers of the deployment", "error", err)
+	}
+	if _, err := docker.RemoveContainersByDeploymentID(ctx, cli, logger, appName, deploymentID); err != nil {
+		logger.Warn("Failed to remove containers of the deployment", "error", err)
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+func TestHandleCancelDeployment(t *testing.T) {
+	s := newTestAPIServerWithDB(t)
+	s.deployLocks = newDeployLocks()
+	for _, state := range []string{"pending", "starting", "healthy", "live"} {
+		if err := s.db.AppendDeploymentEvent(storage.DeploymentEvent{
+			DeploymentID: "live", AppName: "app", State: state, CreatedAt: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.deployLocks.acquire("app", "running", "alice@laptop", false, cancel)
+
+	post := func(deploymentID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/deployments/"+deploymentID+"/cancel", nil)
+		req.SetPathValue("deploymentID", deploymentID)
+		w := httptest.NewRecorder()
+		s.handleCancelDeployment().ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("running")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("cancel of a deployment holding its lock status = %d, want %d: %s", w.Code, http.StatusAccepted, w.Body.String())
+	}
+	var response apitypes.CancelDeploymentResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.App != "app" {
+		t.Errorf("App = %q, want app", response.App)
+	}
+	if ctx.Err() == nil {
+		t.Error("the deployment's context was not canceled")
+	}
+
+	if w := post("live"); w.Code != http.StatusConflict {
+		t.Errorf("cancel of a live deployment status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if w := post("unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("cancel of an unknown deployment status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
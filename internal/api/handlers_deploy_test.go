@@ -1,20 +1,25 @@
 package api
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/logging"
 )
 
 func newTestAPIServerForDeploy() *APIServer {
 	return &APIServer{
-		logBroker: logging.NewLogBroker(),
-		logLevel:  slog.LevelInfo,
+		logBroker:   logging.NewLogBroker(),
+		logLevel:    slog.LevelInfo,
+		deployLocks: newDeployLocks(),
 	}
 }
 
@@ -165,3 +170,53 @@ func TestApplyServerRegistryAuth_DoesNotApplyToServerBuild(t *testing.T) {
 		t.Fatal("RegistryAuth was set for a server build, want nil")
 	}
 }
+
+func TestHandleDeploy_RejectsWhenDiskIsFull(t *testing.T) {
+	s := newTestAPIServerForDeploy()
+	s.deployDiskSpaceCheck = func(context.Context) error {
+		return checkMinFreeSpace(fakeDiskSpaceProbe{infos: map[string]filesystemInfo{
+			"/var/lib/docker": {Path: "/var/lib/docker", AvailableBytes: 100, DeviceID: 1},
+		}}, 1<<30, "/var/lib/docker")
+	}
+
+	body := `{"deploymentID":"dep-1","targetConfig":{"name":"app","server":"example.com","image":{"repository":"nginx"}}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/deploy", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	s.handleDeploy().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInsufficientStorage {
+		t.Fatalf("status = %d, want %d (body %q)", rr.Code, http.StatusInsufficientStorage, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "insufficient disk space on /var/lib/docker") {
+		t.Fatalf("body = %q, expected insufficient disk space error", rr.Body.String())
+	}
+	if _, held := s.deployLocks.locks["app"]; held {
+		t.Fatal("deploy lock should not be taken when the disk check fails")
+	}
+}
+
+func TestCheckClusterSSO(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(constants.EnvVarConfigDir, dir)
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, constants.HaloydConfigFileName), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := config.TargetConfig{Name: "admin", SSO: &config.SSO{}}
+
+	writeConfig("cluster:\n  listen: 10.0.0.2:8443\n")
+	if err := checkClusterSSO(target); err == nil || !strings.Contains(err.Error(), "cluster.listen serves plain HTTP") {
+		t.Errorf("checkClusterSSO() error = %v, want sso refused on a plain HTTP peer listener", err)
+	}
+	if err := checkClusterSSO(config.TargetConfig{Name: "app"}); err != nil {
+		t.Errorf("checkClusterSSO() without sso error = %v", err)
+	}
+
+	writeConfig("cluster:\n  listen: 10.0.0.2:8443\n  ca_file: /etc/haloy/ca.pem\n  cert_file: /etc/haloy/node.pem\n  key_file: /etc/haloy/node-key.pem\n")
+	if err := checkClusterSSO(target); err != nil {
+		t.Errorf("checkClusterSSO() with mutual TLS error = %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/logging"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+const (
+	defaultDeploymentLogPage = 500
+	maxDeploymentLogPage     = 5000
+)
+
+// RecordDeploymentLog keeps a deployment's log entry, so the log can be read
+// after the deployment ended and its stream is gone.
+func (s *APIServer) RecordDeploymentLog(entry logging.LogEntry) {
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = s.db.AppendDeploymentLog(storage.DeploymentLog{
+			DeploymentID: entry.DeploymentID,
+			AppName:      entry.AppName,
+			Entry:        data,
+			CreatedAt:    entry.Timestamp,
+		})
+	}
+	if err != nil {
+		// Logged to the console only: through the broker it would be
+		// recorded again.
+		logging.NewLogger(s.logLevel, nil).Warn("Failed to record deployment log", "deploymentID", entry.DeploymentID, "error", err)
+	}
+}
+
+// handleDeploymentLogHistory returns the recorded log of a deployment a page
+// at a time, including deployments that ended long ago.
+func (s *APIServer) handleDeploymentLogHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deploymentID := r.PathValue("deploymentID")
+		if deploymentID == "" {
+			http.Error(w, "Deployment ID is required", http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		var after int64
+		if v := query.Get("after"); v != "" {
+			var err error
+			if after, err = strconv.ParseInt(v, 10, 64); err != nil || after < 0 {
+				http.Error(w, "Invalid after cursor", http.StatusBadRequest)
+				return
+			}
+		}
+		limit := defaultDeploymentLogPage
+		if v := query.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, maxDeploymentLogPage)
+		}
+
+		// One more than the page tells whether another follows.
+		logs, err := s.db.ListDeploymentLogs(deploymentID, after, limit+1)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(logs) == 0 && after == 0 {
+			http.Error(w, "No logs recorded for deployment", http.StatusNotFound)
+			return
+		}
+
+		response := apitypes.DeploymentLogsResponse{
+			DeploymentID: deploymentID,
+			Entries:      make([]logging.LogEntry, 0, min(len(logs), limit)),
+		}
+		if len(logs) > limit {
+			logs = logs[:limit]
+			response.Next = logs[limit-1].ID
+		}
+		for _, l := range logs {
+			var entry logging.LogEntry
+			if err := json.Unmarshal(l.Entry, &entry); err != nil {
+				http.Error(w, "Failed to decode recorded log entry: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			response.Entries = append(response.Entries, entry)
+		}
+		encodeJSON(w, http.StatusOK, response)
+	}
+}
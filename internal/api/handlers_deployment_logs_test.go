@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/logging"
+)
+
+func TestHandleDeploymentLogHistory(t *testing.T) {
+	s := newTestAPIServerWithDB(t)
+	for i := range 3 {
+		s.RecordDeploymentLog(logging.LogEntry{
+			Level:        "INFO",
+			Message:      fmt.Sprintf("step %d", i),
+			Timestamp:    time.Now(),
+			DeploymentID: "d1",
+			AppName:      "web",
+			Fields:       map[string]any{"step": fmt.Sprint(i)},
+		})
+	}
+
+	get := func(deploymentID, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/deployments/"+deploymentID+"/logs?"+query, nil)
+		req.SetPathValue("deploymentID", deploymentID)
+		w := httptest.NewRecorder()
+		s.handleDeploymentLogHistory().ServeHTTP(w, req)
+		return w
+	}
+	page := func(query string) apitypes.DeploymentLogsResponse {
+		t.Helper()
+		w := get("d1", query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET ?%s = %d: %s", query, w.Code, w.Body.String())
+		}
+		var response apitypes.DeploymentLogsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	first := page("limit=2")
+	if len(first.Entries) != 2 || first.Entries[0].Message != "step 0" || first.Next == 0 {
+		t.Fatalf("first page = %+v, want two entries and a cursor", first)
+	}
+	if first.Entries[1].Fields["step"] != "1" {
+		t.Errorf("entry fields = %+v, want them recorded", first.Entries[1].Fields)
+	}
+	last := page(fmt.Sprintf("limit=2&after=%d", first.Next))
+	if len(last.Entries) != 1 || last.Entries[0].Message != "step 2" || last.Next != 0 {
+		t.Fatalf("last page = %+v, want the last entry and no cursor", last)
+	}
+
+	if w := get("unknown", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown deployment = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := get("d1", "limit=0"); w.Code != http.StatusBadRequest {
+		t.Errorf("limit=0 = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+func (s *APIServer) handleDeploymentStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deploymentID := r.PathValue("deploymentID")
+		if deploymentID == "" {
+			http.Error(w, "Deployment ID is required", http.StatusBadRequest)
+			return
+		}
+
+		events, err := s.db.GetDeploymentEvents(deploymentID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(events) == 0 {
+			http.Error(w, "Deployment not found", http.StatusNotFound)
+			return
+		}
+
+		encodeJSON(w, http.StatusOK, deploymentStatus(events))
+	}
+}
+
+// deploymentStatus summarizes the events of a deployment, oldest first.
+func deploymentStatus(events []storage.DeploymentEvent) apitypes.DeploymentStatusResponse {
+	latest := events[len(events)-1]
+	response := apitypes.DeploymentStatusResponse{
+		DeploymentID: latest.DeploymentID,
+		App:          latest.AppName,
+		State:        latest.State,
+		Message:      latest.Message,
+		UpdatedAt:    latest.CreatedAt,
+		History:      make([]apitypes.DeploymentStateEvent, 0, len(events)),
+	}
+	for _, e := range events {
+		response.History = append(response.History, apitypes.DeploymentStateEvent{
+			State:   e.State,
+			Message: e.Message,
+			At:      e.CreatedAt,
+		})
+	}
+	return response
+}
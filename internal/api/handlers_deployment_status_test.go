@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+func TestHandleDeploymentStatus(t *testing.T) {
+	s := newTestAPIServerWithDB(t)
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, state := range []string{"pending", "starting", "failed"} {
+		message := ""
+		if state == "failed" {
+			message = "health check failed"
+		}
+		if err := s.db.AppendDeploymentEvent(storage.DeploymentEvent{
+			DeploymentID: "d1",
+			AppName:      "app",
+			State:        state,
+			Message:      message,
+			CreatedAt:    start.Add(time.Duration(i) * time.Second),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(deploymentID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/deploy/"+deploymentID, nil)
+		req.SetPathValue("deploymentID", deploymentID)
+		w := httptest.NewRecorder()
+		s.handleDeploymentStatus().ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown deployment status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	w := get("d1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var response apitypes.DeploymentStatusResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.App != "app" || response.State != "failed" || response.Message != "health check failed" {
+		t.Errorf("response = %+v, want app failed with its health check", response)
+	}
+	if !response.UpdatedAt.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("UpdatedAt = %v, want the last event", response.UpdatedAt)
+	}
+	if len(response.History) != 3 || response.History[0].State != "pending" {
+		t.Errorf("History = %+v, want pending, starting and failed", response.History)
+	}
+}
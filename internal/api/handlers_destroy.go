@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/logging"
+)
+
+func (s *APIServer) handleDestroyApp() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		if appName == "" {
+			http.Error(w, "App name is required", http.StatusBadRequest)
+			return
+		}
+
+		var req apitypes.DestroyAppRequest
+		if err := decodeJSON(r.Body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), defaultContextTimeout)
+		defer cancel()
+
+		cli, err := docker.NewClient(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer cli.Close()
+
+		logger := logging.NewLogger(s.logLevel, s.logBroker)
+		response, err := s.DestroyApp(ctx, cli, appName, req, logger)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		encodeJSON(w, http.StatusOK, response)
+	}
+}
+
+// DestroyApp removes appName's containers, images, deployment history and,
+// unless req keeps them, volumes. Failing to remove anything but the
+// containers is only logged.
+func (s *APIServer) DestroyApp(ctx context.Context, cli *client.Client, appName string, req apitypes.DestroyAppRequest, logger *slog.Logger) (apitypes.DestroyAppResponse, error) {
+	allContainers, err := docker.GetAppContainers(ctx, cli, true, "")
+	if err != nil {
+		return apitypes.DestroyAppResponse{}, err
+	}
+	domains, otherDomains := appDomains(allContainers, appName)
+
+	logger.Info("Destroying app", "app", appName, "keep_volumes", req.KeepVolumes, "remove_certificates", req.RemoveCertificates)
+
+	var response apitypes.DestroyAppResponse
+	response.Domains = domains
+
+	if _, err := docker.StopContainers(ctx, cli, logger, appName, ""); err != nil {
+		return response, fmt.Errorf("failed to stop containers: %w", err)
+	}
+	response.Containers, err = docker.RemoveContainers(ctx, cli, logger, appName, "")
+	if err != nil {
+		return response, fmt.Errorf("failed to remove containers: %w", err)
+	}
+
+	// Everything below is best effort: the app is already gone, so a
+	// leftover image or row is reported in the logs instead of failing.
+	if response.Images, err = docker.RemoveAppImages(ctx, cli, logger, appName); err != nil {
+		logger.Warn("Failed to remove some images", "app", appName, "error", err)
+	}
+
+	if s.db != nil {
+		if response.Deployments, err = s.db.DeleteAppDeployments(appName); err != nil {
+			logger.Warn("Failed to delete deployment history", "app", appName, "error", err)
+		}
+		if err := s.db.DeleteAppDeploymentLogs(appName); err != nil {
+			logger.Warn("Failed to delete deployment logs", "app", appName, "error", err)
+		}
+		if err := s.db.DeleteAppDeploymentEvents(appName); err != nil {
+			logger.Warn("Failed to delete deployment events", "app", appName, "error", err)
+		}
+		if err := s.db.ResumeApp(appName); err != nil {
+			logger.Warn("Failed to clear paused state", "app", appName, "error", err)
+		}
+		if err := s.db.DeleteAppContainerRestarts(appName); err != nil {
+			logger.Warn("Failed to delete restart history", "app", appName, "error", err)
+		}
+		if err := s.db.DeleteAppSpec(appName); err != nil {
+			logger.Warn("Failed to delete app spec", "app", appName, "error", err)
+		}
+		if err := s.db.DeleteAppDeploymentSpecs(appName); err != nil {
+			logger.Warn("Failed to delete deployment specs", "app", appName, "error", err)
+		}
+		if err := s.db.DeleteAppUsage(appName); err != nil {
+			logger.Warn("Failed to delete usage history", "app", appName, "error", err)
+		}
+		if err := s.db.DeleteAppUptime(appName); err != nil {
+			logger.Warn("Failed to delete uptime history", "app", appName, "error", err)
+		}
+	}
+
+	if err := s.writeErrorPages(appName, nil); err != nil {
+		logger.Warn("Failed to remove custom error pages", "app", appName, "error", err)
+	}
+
+	if !req.KeepVolumes {
+		if response.Volumes, err = docker.RemoveVolumes(ctx, cli, logger, appName); err != nil {
+			logger.Warn("Failed to remove some volumes", "app", appName, "error", err)
+		}
+	}
+
+	if req.RemoveCertificates && s.removeCertificates != nil {
+		var owned []string
+		for _, domain := range domains {
+			if !slices.Contains(otherDomains, domain) {
+				owned = append(owned, domain)
+			}
+		}
+		response.Certificates = s.removeCertificates(owned)
+	}
+
+	logger.Info("Destroyed app", "app", appName,
+		"containers", len(response.Containers), "images", len(response.Images), "volumes", len(response.Volumes))
+	return response, nil
+}
+
+// appDomains returns the canonical domains appName's containers route and
+// those routed by other apps. A domain in both, e.g. split by path prefix,
+// keeps its certificate.
+func appDomains(containers []container.Summary, appName string) (domains, others []string) {
+	for _, c := range containers {
+		labels, err := config.ParseContainerLabels(c.Labels)
+		if err != nil {
+			continue
+		}
+		for _, domain := range labels.Domains {
+			if labels.AppName == appName {
+				if !slices.Contains(domains, domain.Canonical) {
+					domains = append(domains, domain.Canonical)
+				}
+			} else if !slices.Contains(others, domain.Canonical) {
+				others = append(others, domain.Canonical)
+			}
+		}
+	}
+	return domains, others
+}
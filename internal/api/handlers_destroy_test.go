@@ -0,0 +1,33 @@
+package api
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestAppDomains(t *testing.T) {
+	containerFor := func(appName string, domains ...string) container.Summary {
+		labels := config.ContainerLabels{AppName: appName, DeploymentID: "20260101000000", Port: "8080"}
+		for _, domain := range domains {
+			labels.Domains = append(labels.Domains, config.Domain{Canonical: domain})
+		}
+		return container.Summary{Labels: labels.ToLabels()}
+	}
+
+	containers := []container.Summary{
+		containerFor("app", "app.example.com", "shared.example.com"),
+		containerFor("app", "app.example.com", "shared.example.com"),
+		containerFor("other", "shared.example.com", "other.example.com"),
+	}
+
+	domains, others := appDomains(containers, "app")
+	if want := []string{"app.example.com", "shared.example.com"}; !slices.Equal(domains, want) {
+		t.Errorf("domains = %v, want %v", domains, want)
+	}
+	if want := []string{"shared.example.com", "other.example.com"}; !slices.Equal(others, want) {
+		t.Errorf("others = %v, want %v", others, want)
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+const gitOpsDisabledMessage = "GitOps is not enabled on this server; set gitops.repo in haloyd.yaml"
+
+// handleGitOpsStatus reports the last GitOps sync and the state of the apps
+// the repository declares.
+func (s *APIServer) handleGitOpsStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.gitOpsStatus == nil {
+			http.Error(w, gitOpsDisabledMessage, http.StatusConflict)
+			return
+		}
+		encodeJSON(w, http.StatusOK, s.gitOpsStatus())
+	}
+}
+
+// handleGitOpsPause stops GitOps from deploying or destroying anything until
+// it's resumed. The repository is still fetched, so status shows what would
+// be applied.
+func (s *APIServer) handleGitOpsPause() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.gitOpsStatus == nil {
+			http.Error(w, gitOpsDisabledMessage, http.StatusConflict)
+			return
+		}
+		var req apitypes.GitOpsPauseRequest
+		if err := decodeJSON(r.Body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.db.PauseGitOps(storage.GitOpsPause{Reason: req.Reason, PausedAt: time.Now()}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		encodeJSON(w, http.StatusOK, s.gitOpsStatus())
+	}
+}
+
+// handleGitOpsResume lifts the pause and syncs right away.
+func (s *APIServer) handleGitOpsResume() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.gitOpsStatus == nil {
+			http.Error(w, gitOpsDisabledMessage, http.StatusConflict)
+			return
+		}
+		if err := s.db.ResumeGitOps(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.gitOpsSync()
+		encodeJSON(w, http.StatusOK, s.gitOpsStatus())
+	}
+}
+
+// handleGitOpsSync starts a sync without waiting for the interval.
+func (s *APIServer) handleGitOpsSync() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.gitOpsStatus == nil {
+			http.Error(w, gitOpsDisabledMessage, http.StatusConflict)
+			return
+		}
+		s.gitOpsSync()
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/docker"
+)
+
+// handleImageCheck reports which of the images a client is about to upload
+// the server already has. An image present under another tag is tagged with
+// the requested ref, so it needn't be uploaded again.
+func (s *APIServer) handleImageCheck() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req apitypes.ImageCheckRequest
+		if err := decodeJSON(http.MaxBytesReader(w, r.Body, maxJSONBodyBytes), &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Images) == 0 {
+			http.Error(w, "images array cannot be empty", http.StatusBadRequest)
+			return
+		}
+		for _, image := range req.Images {
+			if image.ImageRef == "" || !strings.HasPrefix(image.ImageID, "sha256:") {
+				http.Error(w, "every image needs an imageRef and a sha256: imageId", http.StatusBadRequest)
+				return
+			}
+		}
+
+		imagePresent := s.imagePresent
+		if imagePresent == nil {
+			cli, err := docker.NewClient(r.Context())
+			if err != nil {
+				http.Error(w, "Failed to create Docker client", http.StatusInternalServerError)
+				return
+			}
+			defer cli.Close()
+			imagePresent = func(ctx context.Context, imageID, imageRef string) (bool, error) {
+				return docker.TagImageIfPresent(ctx, cli, imageID, imageRef)
+			}
+		}
+
+		resp := apitypes.ImageCheckResponse{Present: []string{}, Missing: []string{}}
+		for _, image := range req.Images {
+			present, err := imagePresent(r.Context(), image.ImageID, image.ImageRef)
+			if err != nil {
+				writeImageHandlerError(w, "Failed to check image", err)
+				return
+			}
+			if present {
+				resp.Present = append(resp.Present, image.ImageRef)
+			} else {
+				resp.Missing = append(resp.Missing, image.ImageRef)
+			}
+		}
+
+		if err := encodeJSON(w, http.StatusOK, resp); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+	}
+}
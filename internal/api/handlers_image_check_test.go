@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+)
+
+func TestHandleImageCheck_SplitsPresentAndMissing(t *testing.T) {
+	s := newTestAPIServerForImages()
+	s.imagePresent = func(_ context.Context, imageID, _ string) (bool, error) {
+		return imageID == "sha256:aaa", nil
+	}
+
+	body := `{"images":[{"imageRef":"web:1","imageId":"sha256:aaa"},{"imageRef":"api:1","imageId":"sha256:bbb"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/images/check", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	s.handleImageCheck().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var resp apitypes.ImageCheckResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !slices.Equal(resp.Present, []string{"web:1"}) || !slices.Equal(resp.Missing, []string{"api:1"}) {
+		t.Fatalf("present = %v, missing = %v, want [web:1], [api:1]", resp.Present, resp.Missing)
+	}
+}
+
+func TestHandleImageCheck_RejectsInvalidImages(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no images", `{"images":[]}`},
+		{"missing ref", `{"images":[{"imageId":"sha256:aaa"}]}`},
+		{"not an image id", `{"images":[{"imageRef":"web:1","imageId":"web:latest"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestAPIServerForImages()
+			s.imagePresent = func(context.Context, string, string) (bool, error) {
+				t.Fatal("imagePresent called for an invalid request")
+				return false, nil
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/images/check", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			s.handleImageCheck().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", rr.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
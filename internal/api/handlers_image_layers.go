@@ -5,7 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"strings"
 
 	"github.com/haloydev/haloy/internal/apitypes"
 	"github.com/haloydev/haloy/internal/docker"
@@ -152,15 +152,14 @@ func (s *APIServer) handleImageAssemble() http.HandlerFunc {
 			return
 		}
 
-		// Assemble the image tar from cached layers
-		tarPath, err := store.AssembleImageTar(req)
+		// Assemble the image tar from cached layers, streaming it into Docker
+		image, err := store.OpenImageTar(req)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Failed to assemble image: %v", err), http.StatusInternalServerError)
 			return
 		}
-		defer os.Remove(tarPath)
+		defer image.Close()
 
-		// Load the assembled tar into Docker
 		ctx, cancel := context.WithTimeout(r.Context(), imageLoadTimeout)
 		defer cancel()
 
@@ -171,7 +170,7 @@ func (s *APIServer) handleImageAssemble() http.HandlerFunc {
 		}
 		defer cli.Close()
 
-		if err := docker.LoadImageFromTar(ctx, cli, tarPath); err != nil {
+		if err := docker.LoadImage(ctx, cli, image); err != nil {
 			writeImageHandlerError(w, "Failed to load image", err)
 			return
 		}
@@ -187,3 +186,116 @@ func (s *APIServer) handleImageAssemble() http.HandlerFunc {
 		}
 	}
 }
+
+// handleLayerUploadStatus reports how much of a resumable layer upload the server holds
+func (s *APIServer) handleLayerUploadStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		digest := r.PathValue("digest")
+		if err := layerstore.ValidateDigest(digest); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		store, err := layerstore.New(s.db)
+		if err != nil {
+			http.Error(w, "Failed to initialize layer store", http.StatusInternalServerError)
+			return
+		}
+
+		resp := apitypes.LayerUploadStatusResponse{Digest: digest}
+		_, exists, err := store.HasLayers([]string{digest})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to check layer: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if len(exists) > 0 {
+			resp.Complete = true
+		} else if resp.Offset, err = store.PartialOffset(digest); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read upload offset: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		encodeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// handleLayerUploadChunk appends a Content-Range chunk to a resumable layer
+// upload and stores the layer once the final chunk arrives
+func (s *APIServer) handleLayerUploadChunk() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		digest := r.PathValue("digest")
+		if err := layerstore.ValidateDigest(digest); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		start, end, total, err := parseContentRange(r.Header.Get("Content-Range"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if total > maxLayerUploadBytes {
+			http.Error(w, fmt.Sprintf("Layer exceeds maximum size of %d bytes", int64(maxLayerUploadBytes)), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		store, err := layerstore.New(s.db)
+		if err != nil {
+			http.Error(w, "Failed to initialize layer store", http.StatusInternalServerError)
+			return
+		}
+
+		chunkSize := end - start + 1
+		if err := s.ensureDiskSpaceOrPruneLayers(r.Context(), func() error {
+			return s.ensureLayerUploadDiskSpace(r.Context(), chunkSize)
+		}); err != nil {
+			writeImageHandlerError(w, "Failed disk space preflight", err)
+			return
+		}
+
+		offset, err := store.AppendPartial(digest, start, http.MaxBytesReader(w, r.Body, chunkSize))
+		if err != nil {
+			if errors.Is(err, layerstore.ErrOffsetMismatch) {
+				encodeJSON(w, http.StatusConflict, apitypes.LayerUploadStatusResponse{Digest: digest, Offset: offset})
+				return
+			}
+			http.Error(w, fmt.Sprintf("Failed to store chunk: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if offset != end+1 {
+			http.Error(w, fmt.Sprintf("chunk ended at byte %d, Content-Range declared %d", offset-1, end), http.StatusBadRequest)
+			return
+		}
+
+		if offset < total {
+			encodeJSON(w, http.StatusAccepted, apitypes.LayerUploadStatusResponse{Digest: digest, Offset: offset})
+			return
+		}
+
+		if _, err := store.CommitPartial(digest); err != nil {
+			if errors.Is(err, layerstore.ErrDigestMismatch) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Failed to store layer: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		encodeJSON(w, http.StatusCreated, apitypes.LayerUploadStatusResponse{Digest: digest, Offset: offset, Complete: true})
+	}
+}
+
+// parseContentRange parses a "bytes <start>-<end>/<total>" header value.
+func parseContentRange(value string) (start, end, total int64, err error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("Content-Range header must be 'bytes <start>-<end>/<total>'")
+	}
+	if _, err := fmt.Sscanf(spec, "%d-%d/%d", &start, &end, &total); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", value)
+	}
+	if start < 0 || end < start || end >= total {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", value)
+	}
+	return start, end, total, nil
+}
@@ -190,3 +190,74 @@ func TestHandleLayerCheck_InvalidDigestReturns400(t *testing.T) {
 		t.Fatalf("status = %d, want %d", rr.Code, http.StatusBadRequest)
 	}
 }
+
+func newLayerChunkRequest(digest, contentRange, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPatch, "/v1/images/layers/uploads/"+digest, strings.NewReader(body))
+	req.SetPathValue("digest", digest)
+	req.Header.Set("Content-Range", contentRange)
+	return req
+}
+
+func TestHandleLayerUploadChunk_ResumesAndCommits(t *testing.T) {
+	s := newTestAPIServerWithDB(t)
+
+	content := "0123456789abcdef"
+	sum := sha256.Sum256([]byte(content))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	rr := httptest.NewRecorder()
+	s.handleLayerUploadChunk().ServeHTTP(rr, newLayerChunkRequest(digest, "bytes 0-7/16", content[:8]))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("first chunk status = %d, want %d: %s", rr.Code, http.StatusAccepted, rr.Body.String())
+	}
+
+	// A chunk that skips ahead is rejected with the offset the server holds.
+	rr = httptest.NewRecorder()
+	s.handleLayerUploadChunk().ServeHTTP(rr, newLayerChunkRequest(digest, "bytes 12-15/16", content[12:]))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("out-of-order chunk status = %d, want %d", rr.Code, http.StatusConflict)
+	}
+	var conflict apitypes.LayerUploadStatusResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &conflict); err != nil {
+		t.Fatalf("decode conflict response: %v", err)
+	}
+	if conflict.Offset != 8 {
+		t.Fatalf("conflict offset = %d, want 8", conflict.Offset)
+	}
+
+	statusReq := httptest.NewRequest(http.MethodGet, "/v1/images/layers/uploads/"+digest, nil)
+	statusReq.SetPathValue("digest", digest)
+	rr = httptest.NewRecorder()
+	s.handleLayerUploadStatus().ServeHTTP(rr, statusReq)
+	var status apitypes.LayerUploadStatusResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status response: %v", err)
+	}
+	if status.Offset != 8 || status.Complete {
+		t.Fatalf("status = %+v, want offset 8 and incomplete", status)
+	}
+
+	rr = httptest.NewRecorder()
+	s.handleLayerUploadChunk().ServeHTTP(rr, newLayerChunkRequest(digest, "bytes 8-15/16", content[8:]))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("final chunk status = %d, want %d: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+
+	exists, err := s.db.HasLayer(digest)
+	if err != nil || !exists {
+		t.Fatalf("HasLayer() = %v, %v; want true", exists, err)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	start, end, total, err := parseContentRange("bytes 10-19/100")
+	if err != nil || start != 10 || end != 19 || total != 100 {
+		t.Fatalf("parseContentRange() = %d, %d, %d, %v", start, end, total, err)
+	}
+
+	for _, value := range []string{"", "10-19/100", "bytes 19-10/100", "bytes 0-100/100", "bytes */100"} {
+		if _, _, _, err := parseContentRange(value); err == nil {
+			t.Errorf("parseContentRange(%q) expected error", value)
+		}
+	}
+}
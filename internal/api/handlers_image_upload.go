@@ -6,11 +6,9 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/haloydev/haloy/internal/apitypes"
-	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/docker"
 )
 
@@ -24,8 +22,8 @@ func (s *APIServer) handleImageUpload() http.HandlerFunc {
 			return
 		}
 
-		// Stream the multipart body directly to the temp file instead of letting
-		// ParseMultipartForm spool a second copy to the OS temp dir first.
+		// Read the multipart body as a stream instead of letting
+		// ParseMultipartForm spool it to the OS temp dir first.
 		reader, err := r.MultipartReader()
 		if err != nil {
 			http.Error(w, "Failed to parse multipart form", http.StatusBadRequest)
@@ -57,30 +55,6 @@ func (s *APIServer) handleImageUpload() http.HandlerFunc {
 			return
 		}
 
-		// Create temporary file, we defer delete it
-		tempDir, err := config.EnsureImageTempDir()
-		if err != nil {
-			http.Error(w, "Failed to prepare temporary directory", http.StatusInternalServerError)
-			return
-		}
-
-		tempFile, err := os.CreateTemp(tempDir, "haloy-image-*.tar")
-		if err != nil {
-			http.Error(w, "Failed to create temporary file", http.StatusInternalServerError)
-			return
-		}
-		defer func() {
-			os.Remove(tempFile.Name())
-		}()
-		defer tempFile.Close()
-
-		// Copy uploaded data to temp file
-		_, err = io.Copy(tempFile, part)
-		if err != nil {
-			http.Error(w, "Failed to save uploaded file", http.StatusInternalServerError)
-			return
-		}
-
 		ctx, cancel := context.WithTimeout(r.Context(), imageLoadTimeout)
 		defer cancel()
 
@@ -91,7 +65,8 @@ func (s *APIServer) handleImageUpload() http.HandlerFunc {
 		}
 		defer cli.Close()
 
-		if err := docker.LoadImageFromTar(ctx, cli, tempFile.Name()); err != nil {
+		// Stream the upload straight into Docker rather than spooling it to disk.
+		if err := docker.LoadImage(ctx, cli, part); err != nil {
 			writeImageHandlerError(w, "Failed to load image", err)
 			return
 		}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/docker"
+)
+
+// handleAppInspect reports the running state of an app's latest deployment.
+func (s *APIServer) handleAppInspect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		if appName == "" {
+			http.Error(w, "App name is required", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), defaultContextTimeout)
+		defer cancel()
+
+		cli, err := docker.NewClient(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer cli.Close()
+
+		containerList, err := docker.GetAppContainers(ctx, cli, false, appName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(containerList) == 0 {
+			http.Error(w, "No running containers found for the specified app", http.StatusNotFound)
+			return
+		}
+
+		deploymentID, replicas := latestDeployment(containerList)
+		var containerID string
+		for _, c := range containerList {
+			if c.Labels[config.LabelDeploymentID] == deploymentID {
+				containerID = c.ID
+				break
+			}
+		}
+
+		containerInfo, err := cli.ContainerInspect(ctx, containerID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Lets the client tell env vars set by the image from configured ones.
+		var imageEnv []string
+		if imageInfo, err := cli.ImageInspect(ctx, containerInfo.Image); err == nil && imageInfo.Config != nil {
+			imageEnv = imageInfo.Config.Env
+		}
+
+		response := inspectResponse(containerInfo, imageEnv, deploymentID, replicas)
+		if s.db != nil {
+			if deployment, err := s.db.GetDeployment(deploymentID); err == nil {
+				var deployConfig config.DeployConfig
+				if json.Unmarshal(deployment.RawDeployConfig, &deployConfig) == nil && deployConfig.Image != nil {
+					response.Image = deployConfig.Image.ImageRef()
+				}
+			}
+		}
+
+		encodeJSON(w, http.StatusOK, response)
+	}
+}
+
+// latestDeployment returns the newest deployment ID among the containers and
+// how many of them belong to it.
+func latestDeployment(containers []container.Summary) (string, int) {
+	var latest string
+	var count int
+	for _, c := range containers {
+		id := c.Labels[config.LabelDeploymentID]
+		switch {
+		case id > latest:
+			latest, count = id, 1
+		case id == latest:
+			count++
+		}
+	}
+	return latest, count
+}
+
+func inspectResponse(info container.InspectResponse, imageEnv []string, deploymentID string, replicas int) apitypes.AppInspectResponse {
+	response := apitypes.AppInspectResponse{
+		DeploymentID:  deploymentID,
+		Replicas:      replicas,
+		Labels:        make(map[string]string),
+		ImageEnvNames: envNames(imageEnv),
+	}
+
+	if info.Config != nil {
+		response.Image = info.Config.Image
+		for key, value := range info.Config.Labels {
+			if strings.HasPrefix(key, "dev.haloy.") {
+				response.Labels[key] = value
+			}
+		}
+		if labels, err := config.ParseContainerLabels(info.Config.Labels); err == nil {
+			response.Domains = labels.Domains
+		}
+		response.EnvNames = envNames(info.Config.Env)
+	}
+
+	if info.HostConfig != nil {
+		response.Volumes = info.HostConfig.Binds
+		response.Network = string(info.HostConfig.NetworkMode)
+		response.NanoCPUs = info.HostConfig.NanoCPUs
+		response.MemoryBytes = info.HostConfig.Memory
+	}
+
+	return response
+}
+
+// envNames returns the sorted, de-duplicated names of KEY=value pairs,
+// leaving out the ones haloy injects into every container.
+func envNames(env []string) []string {
+	names := make(map[string]struct{}, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		switch name {
+		case constants.EnvVarReplicaID, constants.EnvVarMigrationLock, constants.EnvVarMigrationLockURL, constants.EnvVarMigrationLockToken:
+			continue
+		}
+		names[name] = struct{}{}
+	}
+	return slices.Sorted(maps.Keys(names))
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestLatestDeployment(t *testing.T) {
+	containers := []container.Summary{
+		{ID: "a", Labels: map[string]string{config.LabelDeploymentID: "20250101120000"}},
+		{ID: "b", Labels: map[string]string{config.LabelDeploymentID: "20250102120000"}},
+		{ID: "c", Labels: map[string]string{config.LabelDeploymentID: "20250102120000"}},
+	}
+
+	id, replicas := latestDeployment(containers)
+	if id != "20250102120000" || replicas != 2 {
+		t.Fatalf("latestDeployment = (%q, %d), want (20250102120000, 2)", id, replicas)
+	}
+}
+
+func TestEnvNames(t *testing.T) {
+	got := envNames([]string{"PATH=/usr/bin", "DATABASE_URL=postgres://", "HALOY_REPLICA_ID=1", "EMPTY="})
+	want := []string{"DATABASE_URL", "EMPTY", "PATH"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("envNames = %v, want %v", got, want)
+	}
+}
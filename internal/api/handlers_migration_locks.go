@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// lockScopedContextKey marks requests authorized with a migration lock token
+// rather than the API token.
+type lockScopedContextKey struct{}
+
+// migrationLockAuthMiddleware accepts the API token, or the token of the
+// migration lock named in the path.
+func (s *APIServer) migrationLockAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.apiToken)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		lockToken := migrationLockToken(s.apiToken, r.PathValue("name"))
+		if subtle.ConstantTimeCompare([]byte(token), []byte(lockToken)) != 1 {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), lockScopedContextKey{}, true)))
+	})
+}
+
+// handleMigrationLockAcquire takes a migration lock. With wait set, it blocks
+// until the lock is free or wait passes.
+func (s *APIServer) handleMigrationLockAcquire() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		holder := r.URL.Query().Get("holder")
+		if holder == "" {
+			http.Error(w, "holder is required", http.StatusBadRequest)
+			return
+		}
+		ttl, err := durationParam(r, "ttl", defaultMigrationLockTTL, maxMigrationLockTTL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		wait, err := durationParam(r, "wait", 0, maxMigrationLockWait)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var info apitypes.MigrationLockInfo
+		var acquired bool
+		if wait > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), wait)
+			info, acquired = s.migrationLocks.wait(ctx, name, holder, ttl)
+			cancel()
+		} else {
+			info, acquired = s.migrationLocks.acquire(name, holder, ttl)
+		}
+
+		status := http.StatusOK
+		if !acquired {
+			status = http.StatusConflict
+		}
+		encodeJSON(w, status, apitypes.MigrationLockResponse{Acquired: acquired, Lock: info})
+	}
+}
+
+// handleMigrationLockRelease releases a migration lock held by holder. With
+// force, which needs the API token, it releases a stale lock whoever holds it.
+func (s *APIServer) handleMigrationLockRelease() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		holder := r.URL.Query().Get("holder")
+		force := r.URL.Query().Get("force") == "true"
+		if force {
+			if scoped, _ := r.Context().Value(lockScopedContextKey{}).(bool); scoped {
+				http.Error(w, "force requires the API token", http.StatusForbidden)
+				return
+			}
+		} else if holder == "" {
+			http.Error(w, "holder is required", http.StatusBadRequest)
+			return
+		}
+
+		if !s.migrationLocks.release(name, holder, force) {
+			http.Error(w, fmt.Sprintf("migration lock '%s' is not held by %s", name, holderOrAnyone(holder, force)), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *APIServer) handleMigrationLocks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := encodeJSON(w, http.StatusOK, apitypes.MigrationLocksResponse{Locks: s.migrationLocks.list()}); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+	}
+}
+
+func holderOrAnyone(holder string, force bool) string {
+	if force {
+		return "anyone"
+	}
+	return "'" + holder + "'"
+}
+
+// durationParam parses the query parameter key as a duration between 0 and
+// maxValue, returning fallback when it's absent.
+func durationParam(r *http.Request, key string, fallback, maxValue time.Duration) (time.Duration, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 || d > maxValue {
+		return 0, fmt.Errorf("invalid %s '%s': must be a duration of at most %s", key, value, maxValue)
+	}
+	return d, nil
+}
+
+// withMigrationLockEnv adds the variables the target's containers and
+// sidecars use to take its migration lock. The URL is only set when the API
+// has a domain, as containers can't reach haloyd's loopback listener.
+func (s *APIServer) withMigrationLockEnv(targetConfig config.TargetConfig) config.TargetConfig {
+	name := targetConfig.MigrationLock
+	if name == "" {
+		return targetConfig
+	}
+	lockEnv := []config.EnvVar{
+		{Name: constants.EnvVarMigrationLock, ValueSource: config.ValueSource{Value: name}},
+		{Name: constants.EnvVarMigrationLockToken, ValueSource: config.ValueSource{Value: migrationLockToken(s.apiToken, name)}},
+	}
+	if haloydConfig, err := config.LoadDefaultHaloydConfig(); err == nil && haloydConfig.API.Domain != "" {
+		url := fmt.Sprintf("https://%s/v1/migration-locks/%s", haloydConfig.API.Domain, name)
+		lockEnv = append(lockEnv, config.EnvVar{Name: constants.EnvVarMigrationLockURL, ValueSource: config.ValueSource{Value: url}})
+	}
+
+	// The slices are shared with the request, so they're copied, not appended to.
+	targetConfig.Env = slices.Concat(targetConfig.Env, lockEnv)
+	sidecars := slices.Clone(targetConfig.Sidecars)
+	for i := range sidecars {
+		sidecars[i].Env = slices.Concat(sidecars[i].Env, lockEnv)
+	}
+	targetConfig.Sidecars = sidecars
+	return targetConfig
+}
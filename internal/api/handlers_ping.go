@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/healthcheck"
+)
+
+const (
+	defaultPingCount = 3
+	maxPingCount     = 10
+	pingCheckTimeout = 5 * time.Second
+)
+
+// handleAppPing runs the health check of every running container of an app
+// count times and reports how long each took, so slow backends can be told
+// apart from a slow network between the client and haloyd.
+func (s *APIServer) handleAppPing() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		if appName == "" {
+			http.Error(w, "App name is required", http.StatusBadRequest)
+			return
+		}
+
+		count := defaultPingCount
+		if v := r.URL.Query().Get("count"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 1 || parsed > maxPingCount {
+				http.Error(w, fmt.Sprintf("count must be between 1 and %d", maxPingCount), http.StatusBadRequest)
+				return
+			}
+			count = parsed
+		}
+
+		ctx := r.Context()
+		cli, containerList, err := getAppContainers(ctx, appName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		defer cli.Close()
+
+		checker := healthcheck.NewChecker(pingCheckTimeout, docker.HealthCheckExec(cli))
+		response := apitypes.AppPingResponse{Containers: make([]apitypes.ContainerPing, len(containerList))}
+		var wg sync.WaitGroup
+		for i, c := range containerList {
+			wg.Go(func() {
+				response.Containers[i] = pingContainer(ctx, cli, checker, c, count)
+			})
+		}
+		wg.Wait()
+
+		encodeJSON(w, http.StatusOK, response)
+	}
+}
+
+func pingContainer(ctx context.Context, cli *client.Client, checker *healthcheck.Checker, c container.Summary, count int) apitypes.ContainerPing {
+	ping := apitypes.ContainerPing{ContainerID: c.ID, LatenciesMs: []float64{}}
+
+	info, err := cli.ContainerInspect(ctx, c.ID)
+	if err != nil {
+		ping.Failed, ping.Error = count, fmt.Sprintf("failed to inspect container: %v", err)
+		return ping
+	}
+	target, err := docker.HealthCheckTarget(info)
+	if err != nil {
+		ping.Failed, ping.Error = count, err.Error()
+		return ping
+	}
+	ping.Check = describeCheck(target)
+
+	for range count {
+		result := checker.Check(ctx, target)
+		if result.Healthy {
+			ping.LatenciesMs = append(ping.LatenciesMs, float64(result.Latency.Microseconds())/1000)
+			continue
+		}
+		ping.Failed++
+		if result.Err != nil {
+			ping.Error = result.Err.Error()
+		}
+	}
+	return ping
+}
+
+func describeCheck(target healthcheck.Target) string {
+	addr := net.JoinHostPort(target.IP, target.Port)
+	switch target.Type {
+	case healthcheck.CheckTCP:
+		return "tcp " + addr
+	case healthcheck.CheckCmd:
+		return "cmd " + strings.Join(target.Cmd, " ")
+	}
+	return "GET " + addr + target.HealthCheckPath
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+// handleListPreviews lists the preview deployments on the server.
+func (s *APIServer) handleListPreviews() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), defaultContextTimeout)
+		defer cancel()
+
+		cli, err := docker.NewClient(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer cli.Close()
+
+		containers, err := docker.GetAppContainers(ctx, cli, true, "")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		encodeJSON(w, http.StatusOK, apitypes.PreviewsResponse{Previews: Previews(containers)})
+	}
+}
+
+// Previews returns the previews the containers belong to, one per app,
+// described by its latest deployment and sorted by app name.
+func Previews(containers []container.Summary) []apitypes.Preview {
+	byApp := make(map[string]apitypes.Preview)
+	for _, c := range containers {
+		labels, err := config.ParseContainerLabels(c.Labels)
+		if err != nil || labels.PreviewBranch == "" {
+			continue
+		}
+		if existing, ok := byApp[labels.AppName]; ok && helpers.CompareDeploymentIDs(existing.DeploymentID, labels.DeploymentID) >= 0 {
+			continue
+		}
+		preview := apitypes.Preview{
+			App:          labels.AppName,
+			Branch:       labels.PreviewBranch,
+			DeploymentID: labels.DeploymentID,
+			ExpiresAt:    labels.PreviewExpiresAt,
+		}
+		for _, domain := range labels.Domains {
+			preview.Domains = append(preview.Domains, domain.Canonical)
+		}
+		byApp[labels.AppName] = preview
+	}
+
+	previews := slices.Collect(maps.Values(byApp))
+	slices.SortFunc(previews, func(a, b apitypes.Preview) int {
+		return strings.Compare(a.App, b.App)
+	})
+	return previews
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestPreviews(t *testing.T) {
+	expiresAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	containerFor := func(appName, deploymentID, branch string, expires time.Time) container.Summary {
+		cl := config.NewContainerLabels(config.TargetConfig{
+			Name:    appName,
+			Port:    "8080",
+			Domains: []config.Domain{{Canonical: branch + ".preview.example.com"}},
+			Preview: &config.PreviewConfig{Domain: "preview.example.com", Branch: branch, ExpiresAt: expires},
+		}, deploymentID)
+		return container.Summary{Labels: cl.ToLabels()}
+	}
+	prod := config.NewContainerLabels(config.TargetConfig{Name: "shop", Port: "8080"}, "01a")
+
+	previews := Previews([]container.Summary{
+		{Labels: prod.ToLabels()},
+		containerFor("shop-feature-y", "01a", "feature-y", expiresAt),
+		containerFor("shop-feature-x", "01a", "feature-x", expiresAt),
+		containerFor("shop-feature-x", "01b", "feature-x", expiresAt.Add(time.Hour)),
+	})
+
+	if len(previews) != 2 {
+		t.Fatalf("Previews() returned %d previews, want 2: %+v", len(previews), previews)
+	}
+	if previews[0].App != "shop-feature-x" || previews[1].App != "shop-feature-y" {
+		t.Errorf("Previews() apps = %s, %s, want shop-feature-x, shop-feature-y", previews[0].App, previews[1].App)
+	}
+	if previews[0].DeploymentID != "01b" || !previews[0].ExpiresAt.Equal(expiresAt.Add(time.Hour)) {
+		t.Errorf("Previews()[0] = %+v, want the latest deployment", previews[0])
+	}
+	if len(previews[0].Domains) != 1 || previews[0].Domains[0] != "feature-x.preview.example.com" {
+		t.Errorf("Previews()[0].Domains = %v", previews[0].Domains)
+	}
+}
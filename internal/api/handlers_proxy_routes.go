@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+// proxyConfigTimeout bounds the lookup of the proxy's live config over its
+// control socket.
+const proxyConfigTimeout = 5 * time.Second
+
+func (s *APIServer) handleProxyRoutes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		live, ok := s.liveProxyConfig(w, r)
+		if !ok {
+			return
+		}
+		var planned *proxywire.Snapshot
+		if s.proxyPlan != nil {
+			planned = s.proxyPlan()
+		}
+		var status *proxywire.Status
+		if s.proxyStatus != nil {
+			ctx, cancel := context.WithTimeout(r.Context(), proxyConfigTimeout)
+			defer cancel()
+			// Backend stats are extra detail; the routes are shown without them.
+			status, _ = s.proxyStatus(ctx)
+		}
+		encodeJSON(w, http.StatusOK, proxyRoutes(live, planned, status))
+	}
+}
+
+func (s *APIServer) handleProxyRoutesDryRun() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.proxyPlan == nil {
+			http.Error(w, "Route planning is not available", http.StatusServiceUnavailable)
+			return
+		}
+		live, ok := s.liveProxyConfig(w, r)
+		if !ok {
+			return
+		}
+		encodeJSON(w, http.StatusOK, proxyRoutesDryRun(live, s.proxyPlan()))
+	}
+}
+
+// liveProxyConfig fetches the proxy's current snapshot, writing the error
+// response if that fails.
+func (s *APIServer) liveProxyConfig(w http.ResponseWriter, r *http.Request) (*proxywire.Snapshot, bool) {
+	if s.proxyConfig == nil {
+		http.Error(w, "Proxy routes are not available", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), proxyConfigTimeout)
+	defer cancel()
+	live, err := s.proxyConfig(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get routes from haloy-proxy: %v", err), http.StatusBadGateway)
+		return nil, false
+	}
+	return live, true
+}
+
+// proxyRoutes lists the routes of live with the backend stats from status,
+// which may be nil. A route is degraded when one of its backends is ejected
+// or, with the planned snapshot, when it has fewer live backends than the
+// app has containers because the health monitor took the others out.
+func proxyRoutes(live, planned *proxywire.Snapshot, status *proxywire.Status) apitypes.ProxyRoutesResponse {
+	plannedBackends := make(map[string]int)
+	if planned != nil {
+		for _, r := range planned.Routes {
+			plannedBackends[r.Canonical+r.PathPrefix] = len(r.Backends)
+		}
+	}
+
+	resp := apitypes.ProxyRoutesResponse{ConfigHash: live.Hash(), Routes: []apitypes.ProxyRoute{}}
+	ejected := make(map[string]bool)
+	if status != nil {
+		for _, b := range status.Backends {
+			backend := apitypes.ProxyBackend{
+				Address:      b.Address,
+				InFlight:     b.InFlight,
+				Requests:     b.Requests,
+				Errors:       b.Errors,
+				ErrorRate:    b.ErrorRate,
+				P95LatencyMS: b.P95LatencyMS,
+				State:        apitypes.BackendOK,
+			}
+			switch {
+			case b.Ejected:
+				backend.State = apitypes.BackendEjected
+				ejected[b.Address] = true
+			case b.Flagged:
+				backend.State = apitypes.BackendFlagged
+			}
+			resp.Backends = append(resp.Backends, backend)
+		}
+	}
+
+	for _, r := range live.Routes {
+		route := proxyRoute(r)
+		switch {
+		case len(r.Backends) == 0:
+			route.Health = apitypes.RouteDown
+		case plannedBackends[r.Canonical+r.PathPrefix] > len(r.Backends),
+			slices.ContainsFunc(route.Backends, func(addr string) bool { return ejected[addr] }):
+			route.Health = apitypes.RouteDegraded
+		default:
+			route.Health = apitypes.RouteHealthy
+		}
+		resp.Routes = append(resp.Routes, route)
+	}
+	return resp
+}
+
+// proxyRoutesDryRun lists the route changes pushing planned would make to
+// the proxy's live config.
+func proxyRoutesDryRun(live, planned *proxywire.Snapshot) apitypes.ProxyRoutesDryRunResponse {
+	diff := proxywire.DiffRoutes(live.Routes, planned.Routes)
+	resp := apitypes.ProxyRoutesDryRunResponse{
+		LiveConfigHash:    live.Hash(),
+		PlannedConfigHash: planned.Hash(),
+		Added:             make([]apitypes.ProxyRoute, 0, len(diff.Added)),
+		Removed:           make([]apitypes.ProxyRoute, 0, len(diff.Removed)),
+		Changed:           make([]apitypes.ProxyRouteChange, 0, len(diff.Changed)),
+	}
+	for _, r := range diff.Added {
+		resp.Added = append(resp.Added, proxyRoute(r))
+	}
+	for _, r := range diff.Removed {
+		resp.Removed = append(resp.Removed, proxyRoute(r))
+	}
+	for _, c := range diff.Changed {
+		resp.Changed = append(resp.Changed, apitypes.ProxyRouteChange{
+			From:   proxyRoute(c.From),
+			To:     proxyRoute(c.To),
+			Fields: c.Fields,
+		})
+	}
+	return resp
+}
+
+func proxyRoute(r proxywire.Route) apitypes.ProxyRoute {
+	route := apitypes.ProxyRoute{
+		Domain:     r.Canonical,
+		Aliases:    r.Aliases,
+		PathPrefix: r.PathPrefix,
+		App:        r.App,
+		Backends:   make([]string, 0, len(r.Backends)),
+	}
+	for _, b := range r.Backends {
+		route.Backends = append(route.Backends, b.Addr())
+	}
+	return route
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+func testProxyRoutesServer(live, planned *proxywire.Snapshot) *APIServer {
+	s := &APIServer{}
+	s.SetProxyRoutesFuncs(
+		func(_ context.Context) (*proxywire.Snapshot, error) { return live, nil },
+		func() *proxywire.Snapshot { return planned },
+	)
+	return s
+}
+
+func TestHandleProxyRoutesReportsHealth(t *testing.T) {
+	backend := func(ip string) proxywire.Backend { return proxywire.Backend{IP: ip, Port: "8080"} }
+	live := &proxywire.Snapshot{Routes: []proxywire.Route{
+		{Canonical: "a.example.com", App: "a", Backends: []proxywire.Backend{backend("10.0.0.2")}},
+		{Canonical: "b.example.com", App: "b", Backends: []proxywire.Backend{backend("10.0.0.3")}},
+		{Canonical: "c.example.com", App: "c"},
+		{Canonical: "d.example.com", App: "d", Backends: []proxywire.Backend{backend("10.0.0.5"), backend("10.0.0.6")}},
+	}}
+	planned := &proxywire.Snapshot{Routes: []proxywire.Route{
+		{Canonical: "a.example.com", App: "a", Backends: []proxywire.Backend{backend("10.0.0.2")}},
+		{Canonical: "b.example.com", App: "b", Backends: []proxywire.Backend{backend("10.0.0.3"), backend("10.0.0.4")}},
+		{Canonical: "c.example.com", App: "c"},
+		{Canonical: "d.example.com", App: "d", Backends: []proxywire.Backend{backend("10.0.0.5"), backend("10.0.0.6")}},
+	}}
+	s := testProxyRoutesServer(live, planned)
+	s.SetProxyStatusFunc(func(_ context.Context) (*proxywire.Status, error) {
+		return &proxywire.Status{Backends: []proxywire.BackendStatus{
+			{Address: "10.0.0.2:8080", Requests: 10},
+			{Address: "10.0.0.6:8080", Requests: 40, Errors: 30, ErrorRate: 0.75, Flagged: true, Ejected: true},
+		}}, nil
+	})
+
+	rr := httptest.NewRecorder()
+	s.handleProxyRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/proxy/routes", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body)
+	}
+	var resp apitypes.ProxyRoutesResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"a.example.com": apitypes.RouteHealthy,
+		"b.example.com": apitypes.RouteDegraded,
+		"c.example.com": apitypes.RouteDown,
+		"d.example.com": apitypes.RouteDegraded,
+	}
+	if len(resp.Routes) != len(want) {
+		t.Fatalf("got %d routes, want %d", len(resp.Routes), len(want))
+	}
+	for _, r := range resp.Routes {
+		if r.Health != want[r.Domain] {
+			t.Errorf("%s health = %q, want %q", r.Domain, r.Health, want[r.Domain])
+		}
+	}
+	if resp.Routes[0].Backends[0] != "10.0.0.2:8080" {
+		t.Errorf("backend = %q, want 10.0.0.2:8080", resp.Routes[0].Backends[0])
+	}
+	if len(resp.Backends) != 2 || resp.Backends[0].State != apitypes.BackendOK || resp.Backends[1].State != apitypes.BackendEjected {
+		t.Errorf("backends = %+v, want 10.0.0.2 ok and 10.0.0.6 ejected", resp.Backends)
+	}
+}
+
+func TestHandleProxyRoutesDryRun(t *testing.T) {
+	live := &proxywire.Snapshot{Routes: []proxywire.Route{
+		{Canonical: "kept.example.com", App: "kept"},
+		{Canonical: "gone.example.com", App: "gone"},
+	}}
+	planned := &proxywire.Snapshot{Routes: []proxywire.Route{
+		{Canonical: "kept.example.com", App: "kept"},
+		{Canonical: "new.example.com", App: "new"},
+	}}
+	s := testProxyRoutesServer(live, planned)
+
+	rr := httptest.NewRecorder()
+	s.handleProxyRoutesDryRun().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/proxy/routes/dry-run", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body)
+	}
+	var resp apitypes.ProxyRoutesDryRunResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Added) != 1 || resp.Added[0].Domain != "new.example.com" {
+		t.Errorf("added = %+v, want new.example.com", resp.Added)
+	}
+	if len(resp.Removed) != 1 || resp.Removed[0].Domain != "gone.example.com" {
+		t.Errorf("removed = %+v, want gone.example.com", resp.Removed)
+	}
+	if len(resp.Changed) != 0 {
+		t.Errorf("changed = %+v, want none", resp.Changed)
+	}
+	if resp.LiveConfigHash == resp.PlannedConfigHash {
+		t.Error("live and planned config hashes are equal")
+	}
+}
+
+func TestHandleProxyRoutesProxyUnreachable(t *testing.T) {
+	s := &APIServer{}
+	s.SetProxyRoutesFuncs(
+		func(_ context.Context) (*proxywire.Snapshot, error) { return nil, errors.New("connection refused") },
+		nil,
+	)
+
+	rr := httptest.NewRecorder()
+	s.handleProxyRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/proxy/routes", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", rr.Code, http.StatusBadGateway)
+	}
+
+	rr = httptest.NewRecorder()
+	s.handleProxyRoutesDryRun().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/proxy/routes/dry-run", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("dry-run status = %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/deploystate"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+// reconcileHolder holds the deploy lock of apps recreated by reconcile.
+const reconcileHolder = "haloyd reconcile"
+
+// saveDeploymentSpec records what a deployment is started with, before
+// haloyd adds database env vars and resolves server secrets, so the app can
+// be deployed again from it if its containers are lost.
+func (s *APIServer) saveDeploymentSpec(deploymentID string, targetConfig config.TargetConfig, rollbackDeployConfig config.DeployConfig, errorPages map[string]string, logger *slog.Logger) {
+	spec := storage.DeploymentSpec{
+		DeploymentID: deploymentID,
+		AppName:      targetConfig.Name,
+		Replicas:     1,
+		CreatedAt:    time.Now(),
+	}
+	if targetConfig.Image != nil {
+		spec.ImageRef = targetConfig.Image.ImageRef()
+	}
+	if targetConfig.Replicas != nil {
+		spec.Replicas = *targetConfig.Replicas
+	}
+	for _, domain := range targetConfig.Domains {
+		spec.Domains = append(spec.Domains, domain.Canonical)
+	}
+	for _, env := range targetConfig.Env {
+		spec.EnvNames = append(spec.EnvNames, env.Name)
+	}
+
+	var err error
+	if spec.TargetConfig, err = json.Marshal(targetConfig); err == nil {
+		if spec.RollbackDeployConfig, err = json.Marshal(rollbackDeployConfig); err == nil {
+			spec.ErrorPages, err = json.Marshal(errorPages)
+		}
+	}
+	if err == nil {
+		err = s.db.SaveDeploymentSpec(spec)
+	}
+	if err != nil {
+		logger.Warn("Failed to save the deployment spec, the app can't be recreated from it", "error", err)
+	}
+}
+
+// Reconcile compares the live deployment of every app with the containers
+// Docker has, and deploys an app again from the spec of its live deployment
+// when some are gone, like after a 'docker system prune' or restoring the
+// server without Docker's data. Stopped apps are left alone. With dryRun it
+// only reports.
+func (s *APIServer) Reconcile(ctx context.Context, cli *client.Client, dryRun bool) ([]apitypes.ReconciledApp, error) {
+	specs, err := s.db.ListDeploymentSpecsInState(string(deploystate.Live))
+	if err != nil {
+		return nil, err
+	}
+
+	apps := make([]apitypes.ReconciledApp, 0, len(specs))
+	for _, spec := range specs {
+		app := apitypes.ReconciledApp{
+			App:          spec.AppName,
+			DeploymentID: spec.DeploymentID,
+			Image:        spec.ImageRef,
+			Replicas:     spec.Replicas,
+			Domains:      spec.Domains,
+			EnvNames:     spec.EnvNames,
+		}
+
+		paused, err := s.db.GetPausedApp(spec.AppName)
+		if err != nil {
+			return nil, err
+		}
+		if paused != nil {
+			app.Action = apitypes.ReconcilePaused
+			apps = append(apps, app)
+			continue
+		}
+
+		containers, err := docker.GetAppContainers(ctx, cli, true, spec.AppName)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			if c.Labels[config.LabelDeploymentID] == spec.DeploymentID {
+				app.Containers++
+			}
+		}
+
+		switch {
+		case app.Containers >= spec.Replicas:
+			app.Action = apitypes.ReconcileOK
+		case dryRun:
+			app.Action = apitypes.ReconcileMissing
+		default:
+			app.NewDeploymentID = helpers.NewDeploymentID()
+			if err := s.recreateApp(ctx, cli, app.NewDeploymentID, spec); err != nil {
+				app.Action = apitypes.ReconcileFailed
+				app.Error = err.Error()
+			} else {
+				app.Action = apitypes.ReconcileRecreated
+			}
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// recreateApp deploys an app again from the spec of its live deployment.
+func (s *APIServer) recreateApp(ctx context.Context, cli *client.Client, deploymentID string, spec storage.DeploymentSpec) error {
+	var targetConfig config.TargetConfig
+	if err := json.Unmarshal(spec.TargetConfig, &targetConfig); err != nil {
+		return fmt.Errorf("failed to decode the spec of deployment %s: %w", spec.DeploymentID, err)
+	}
+	var rollbackDeployConfig config.DeployConfig
+	if err := json.Unmarshal(spec.RollbackDeployConfig, &rollbackDeployConfig); err != nil {
+		return fmt.Errorf("failed to decode the spec of deployment %s: %w", spec.DeploymentID, err)
+	}
+	var errorPages map[string]string
+	if err := json.Unmarshal(spec.ErrorPages, &errorPages); err != nil {
+		return fmt.Errorf("failed to decode the spec of deployment %s: %w", spec.DeploymentID, err)
+	}
+	return s.DeployTarget(ctx, cli, deploymentID, reconcileHolder, targetConfig, rollbackDeployConfig, errorPages)
+}
+
+func (s *APIServer) handleReconcile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun := r.URL.Query().Get("dryRun") == "true"
+
+		// Each app recreated has the timeout of a deploy, so the request
+		// itself isn't given one.
+		ctx := r.Context()
+		cli, err := docker.NewClient(ctx)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to create Docker client: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer cli.Close()
+
+		apps, err := s.Reconcile(ctx, cli, dryRun)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		encodeJSON(w, http.StatusOK, apitypes.ReconcileResponse{Apps: apps})
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/deploystate"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+func TestSaveDeploymentSpec(t *testing.T) {
+	s := newTestAPIServerWithDB(t)
+	replicas := 3
+	targetConfig := config.TargetConfig{
+		Name:     "web",
+		Image:    &config.Image{Repository: "ghcr.io/acme/web", Tag: "v2"},
+		Replicas: &replicas,
+		Domains:  []config.Domain{{Canonical: "web.example.com"}},
+		Env:      []config.EnvVar{{Name: "SECRET_KEY", ValueSource: config.ValueSource{Value: "server://secret-key"}}},
+	}
+	s.saveDeploymentSpec("d1", targetConfig, config.DeployConfig{}, map[string]string{"502.html": "down"}, slog.New(slog.DiscardHandler))
+
+	if specs, _ := s.db.ListDeploymentSpecsInState(string(deploystate.Live)); len(specs) != 0 {
+		t.Fatalf("specs = %+v, want none before the deployment goes live", specs)
+	}
+	if err := deploystate.New(s.db).GoLive("d1", "web"); err != nil {
+		t.Fatal(err)
+	}
+	specs, err := s.db.ListDeploymentSpecsInState(string(deploystate.Live))
+	if err != nil || len(specs) != 1 {
+		t.Fatalf("ListDeploymentSpecsInState() = %+v, %v, want the spec of d1", specs, err)
+	}
+	spec := specs[0]
+	if spec.ImageRef != "ghcr.io/acme/web:v2" || spec.Replicas != 3 || spec.Domains[0] != "web.example.com" || spec.EnvNames[0] != "SECRET_KEY" {
+		t.Errorf("spec = %+v, want it to describe the target", spec)
+	}
+
+	// The secret stays a reference, resolved again when the app is recreated.
+	var saved config.TargetConfig
+	if err := json.Unmarshal(spec.TargetConfig, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Env[0].Value != "server://secret-key" {
+		t.Errorf("saved env = %+v, want the secret reference", saved.Env)
+	}
+}
+
+func TestReconcile_LeavesPausedAppsAlone(t *testing.T) {
+	s := newTestAPIServerWithDB(t)
+	s.saveDeploymentSpec("d1", config.TargetConfig{Name: "web", Image: &config.Image{Repository: "web"}}, config.DeployConfig{}, nil, slog.New(slog.DiscardHandler))
+	if err := deploystate.New(s.db).GoLive("d1", "web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.db.PauseApp(storage.PausedApp{AppName: "web", DeploymentID: "d1", PausedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A paused app is reported without asking Docker for its containers.
+	apps, err := s.Reconcile(context.Background(), nil, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(apps) != 1 || apps[0].Action != apitypes.ReconcilePaused {
+		t.Errorf("Reconcile() = %+v, want web left alone", apps)
+	}
+}
+
+func TestReconcile_LeavesAppsStoppedWithoutContainersAlone(t *testing.T) {
+	s := newTestAPIServerWithDB(t)
+	s.saveDeploymentSpec("d1", config.TargetConfig{Name: "web", Image: &config.Image{Repository: "web"}}, config.DeployConfig{}, nil, slog.New(slog.DiscardHandler))
+	if err := deploystate.New(s.db).GoLive("d1", "web"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 'haloy stop --remove-containers' finds no containers once they're gone,
+	// and pauses the live deployment.
+	s.pauseApp(slog.New(slog.DiscardHandler), "web", "")
+	paused, err := s.db.GetPausedApp("web")
+	if err != nil || paused == nil || paused.DeploymentID != "d1" {
+		t.Fatalf("GetPausedApp() = %+v, %v, want d1 paused", paused, err)
+	}
+
+	apps, err := s.Reconcile(context.Background(), nil, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(apps) != 1 || apps[0].Action != apitypes.ReconcilePaused {
+		t.Errorf("Reconcile() = %+v, want web left alone", apps)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/deploy"
 	"github.com/haloydev/haloy/internal/docker"
 	"github.com/haloydev/haloy/internal/logging"
@@ -39,13 +40,36 @@ func (s *APIServer) handleRollback() http.HandlerFunc {
 			http.Error(w, fmt.Sprintf("Invalid deploy configuration: %v", err), http.StatusBadRequest)
 			return
 		}
+		if err := checkClusterSSO(deployConfig); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid deploy configuration: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		requested := deployConfig
+		deployConfig, err := s.withDatabaseEnv(deployConfig)
+		if err != nil {
+			writeDatabaseEnvError(w, err)
+			return
+		}
+		deployConfig, err = s.withServerSecrets(deployConfig)
+		if err != nil {
+			writeServerSecretsError(w, err)
+			return
+		}
 
 		deploymentLogger := logging.NewDeploymentLogger(req.NewDeploymentID, s.logLevel, s.logBroker)
 
+		ctx, cancel := context.WithTimeout(context.Background(), deployTimeout(deployConfig))
+
+		appName := deployConfig.Name
+		if !s.acquireDeployLock(w, appName, req.NewDeploymentID, req.Holder, req.ForceUnlock, cancel, deploymentLogger) {
+			cancel()
+			return
+		}
+
 		go func() {
-			ctx := context.Background()
-			ctx, cancel := context.WithTimeout(ctx, defaultContextTimeout)
 			defer cancel()
+			defer s.deployLocks.release(appName, req.NewDeploymentID)
 
 			cli, err := docker.NewClient(ctx)
 			if err != nil {
@@ -54,7 +78,10 @@ func (s *APIServer) handleRollback() http.HandlerFunc {
 			}
 			defer cli.Close()
 
-			if err := deploy.RollbackApp(ctx, cli, s.db, deployConfig, req.TargetDeploymentID, req.NewDeploymentID, deploymentLogger); err != nil {
+			// A rollback deploys the config of an earlier deployment, so its
+			// spec carries no config of its own to roll back to.
+			s.saveDeploymentSpec(req.NewDeploymentID, requested, config.DeployConfig{}, nil, deploymentLogger)
+			if err := deploy.RollbackApp(ctx, cli, s.db, s.withMigrationLockEnv(deployConfig), req.TargetDeploymentID, req.NewDeploymentID, deploymentLogger); err != nil {
 				deploymentLogger.Error("Deployment failed", "app", deployConfig.Name, "error", err)
 				return
 			}
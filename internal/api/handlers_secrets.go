@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+var errSecretNotFound = errors.New("server secret not found")
+
+func (s *APIServer) handleSecretsList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secrets, err := s.db.ListSecrets()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		users, err := s.secretUsers()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		entries := make([]apitypes.SecretEntry, 0, len(secrets))
+		for _, secret := range secrets {
+			entries = append(entries, apitypes.SecretEntry{
+				Name:      secret.Name,
+				UpdatedAt: secret.UpdatedAt,
+				Apps:      users[secret.Name],
+			})
+		}
+		encodeJSON(w, http.StatusOK, apitypes.SecretsResponse{Secrets: entries})
+	}
+}
+
+func (s *APIServer) handleSecretSet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if err := config.ValidateSecretName(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var req apitypes.SecretSetRequest
+		if err := decodeJSON(r.Body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Value == "" {
+			http.Error(w, "secret value is required", http.StatusBadRequest)
+			return
+		}
+
+		existing, err := s.db.GetSecret(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		secret := storage.Secret{Name: name, Value: req.Value, UpdatedAt: time.Now().UTC()}
+		if err := s.db.SaveSecret(secret); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		users, err := s.secretUsers()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		encodeJSON(w, http.StatusOK, apitypes.SecretSetResponse{
+			SecretEntry: apitypes.SecretEntry{Name: name, UpdatedAt: secret.UpdatedAt, Apps: users[name]},
+			Created:     existing == nil,
+		})
+	}
+}
+
+func (s *APIServer) handleSecretDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		users, err := s.secretUsers()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Deleting a secret in use would fail the next deploy of the apps
+		// using it, not this request.
+		if apps := users[name]; len(apps) > 0 {
+			http.Error(w, fmt.Sprintf("secret '%s' is used by %s; remove the references and redeploy first", name, strings.Join(apps, ", ")), http.StatusConflict)
+			return
+		}
+		deleted, err := s.db.DeleteSecret(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.Error(w, fmt.Sprintf("secret '%s' not found", name), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// secretUsers maps the name of each server secret to the apps whose latest
+// deployment references it, sorted. Those are the apps running with its
+// value, which a rotation has to redeploy.
+func (s *APIServer) secretUsers() (map[string][]string, error) {
+	appNames, err := s.db.ListDistinctAppNames()
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(appNames)
+
+	users := make(map[string][]string)
+	for _, appName := range appNames {
+		history, err := s.db.GetDeploymentHistory(appName, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(history) == 0 {
+			continue
+		}
+		var deployConfig config.DeployConfig
+		if err := json.Unmarshal(history[0].RawDeployConfig, &deployConfig); err != nil {
+			continue
+		}
+		for _, name := range serverSecretReferences(deployConfig.TargetConfig) {
+			users[name] = append(users[name], appName)
+		}
+	}
+	return users, nil
+}
+
+// serverSecretReferences returns the names of the server secrets the env of
+// a target and its sidecars reference, without duplicates.
+func serverSecretReferences(targetConfig config.TargetConfig) []string {
+	var names []string
+	add := func(env []config.EnvVar) {
+		for _, e := range env {
+			if name, ok := e.From.ServerSecret(); ok && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	add(targetConfig.Env)
+	for _, sidecar := range targetConfig.Sidecars {
+		add(sidecar.Env)
+	}
+	return names
+}
+
+// withServerSecrets sets the env vars of a target and its sidecars that
+// reference server secrets to the secrets' values. Only the containers get
+// the values; deployments record the config with the references.
+func (s *APIServer) withServerSecrets(targetConfig config.TargetConfig) (config.TargetConfig, error) {
+	env, err := s.resolveServerSecrets(targetConfig.Env)
+	if err != nil {
+		return targetConfig, err
+	}
+	targetConfig.Env = env
+
+	if len(targetConfig.Sidecars) > 0 {
+		// Copied, as the slice is shared with the request.
+		sidecars := slices.Clone(targetConfig.Sidecars)
+		for i := range sidecars {
+			env, err := s.resolveServerSecrets(sidecars[i].Env)
+			if err != nil {
+				return targetConfig, fmt.Errorf("sidecar '%s': %w", sidecars[i].Name, err)
+			}
+			sidecars[i].Env = env
+		}
+		targetConfig.Sidecars = sidecars
+	}
+	return targetConfig, nil
+}
+
+func (s *APIServer) resolveServerSecrets(env []config.EnvVar) ([]config.EnvVar, error) {
+	resolved := env
+	cloned := false
+	for i, e := range env {
+		name, ok := e.From.ServerSecret()
+		if !ok {
+			continue
+		}
+		secret, err := s.db.GetSecret(name)
+		if err != nil {
+			return nil, err
+		}
+		if secret == nil {
+			return nil, fmt.Errorf("%w: '%s', used by env var '%s'. Set it with 'haloy secret set %s'", errSecretNotFound, name, e.Name, name)
+		}
+		if !cloned {
+			resolved = slices.Clone(env)
+			cloned = true
+		}
+		resolved[i].ValueSource = config.ValueSource{Value: secret.Value}
+	}
+	return resolved, nil
+}
+
+func writeServerSecretsError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, errSecretNotFound) {
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+func serverSecretEnv(name, secret string) config.EnvVar {
+	return config.EnvVar{Name: name, ValueSource: config.ValueSource{From: &config.SourceReference{Secret: config.ServerSecretPrefix + secret}}}
+}
+
+func saveTestDeployment(t *testing.T, s *APIServer, id string, targetConfig config.TargetConfig) {
+	t.Helper()
+	raw, err := json.Marshal(config.DeployConfig{TargetConfig: targetConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.db.SaveDeployment(storage.Deployment{ID: id, AppName: targetConfig.Name, RawDeployConfig: raw, DeployedImage: json.RawMessage(`{}`)}); err != nil {
+		t.Fatalf("SaveDeployment() error = %v", err)
+	}
+}
+
+func TestWithServerSecrets(t *testing.T) {
+	s := newTestAPIServerWithDB(t)
+	if err := s.db.SaveSecret(storage.Secret{Name: "stripe-key", Value: "sk_live"}); err != nil {
+		t.Fatal(err)
+	}
+
+	targetConfig := config.TargetConfig{
+		Name: "shop",
+		Env: []config.EnvVar{
+			{Name: "MODE", ValueSource: config.ValueSource{Value: "production"}},
+			serverSecretEnv("STRIPE_KEY", "stripe-key"),
+		},
+		Sidecars: []config.Sidecar{{Name: "worker", Env: []config.EnvVar{serverSecretEnv("STRIPE_KEY", "stripe-key")}}},
+	}
+	resolved, err := s.withServerSecrets(targetConfig)
+	if err != nil {
+		t.Fatalf("withServerSecrets() error = %v", err)
+	}
+	if resolved.Env[1].Value != "sk_live" || resolved.Env[1].From != nil || resolved.Sidecars[0].Env[0].Value != "sk_live" {
+		t.Errorf("withServerSecrets() = %+v, want the secret's value", resolved)
+	}
+	if targetConfig.Env[1].From == nil || targetConfig.Sidecars[0].Env[0].From == nil {
+		t.Error("withServerSecrets() modified the config it was given")
+	}
+
+	targetConfig.Env = append(targetConfig.Env, serverSecretEnv("DB_PASSWORD", "db-password"))
+	if _, err := s.withServerSecrets(targetConfig); !errors.Is(err, errSecretNotFound) {
+		t.Errorf("withServerSecrets() error = %v, want errSecretNotFound", err)
+	}
+}
+
+func TestHandleSecretSet(t *testing.T) {
+	s := newTestAPIServerWithDB(t)
+	saveTestDeployment(t, s, "01a", config.TargetConfig{Name: "shop", Env: []config.EnvVar{serverSecretEnv("STRIPE_KEY", "stripe-key")}})
+	saveTestDeployment(t, s, "01b", config.TargetConfig{Name: "blog"})
+	// Only the latest deployment of an app counts.
+	saveTestDeployment(t, s, "01c", config.TargetConfig{Name: "admin", Env: []config.EnvVar{serverSecretEnv("STRIPE_KEY", "stripe-key")}})
+	saveTestDeployment(t, s, "01d", config.TargetConfig{Name: "admin"})
+
+	set := func(name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/v1/secrets/"+name, strings.NewReader(body))
+		req.SetPathValue("name", name)
+		rec := httptest.NewRecorder()
+		s.handleSecretSet().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := set("stripe-key", `{"value":"sk_live"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var response apitypes.SecretSetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !response.Created || !slices.Equal(response.Apps, []string{"shop"}) {
+		t.Errorf("response = %+v, want a created secret used by shop", response)
+	}
+
+	rec = set("stripe-key", `{"value":"sk_rotated"}`)
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Created {
+		t.Error("rotating a secret reported it as created")
+	}
+	if secret, _ := s.db.GetSecret("stripe-key"); secret == nil || secret.Value != "sk_rotated" {
+		t.Errorf("stored secret = %+v, want the rotated value", secret)
+	}
+
+	if rec := set("bad/name", `{"value":"x"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid name status = %d, want 400", rec.Code)
+	}
+	if rec := set("empty", `{"value":""}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty value status = %d, want 400", rec.Code)
+	}
+}
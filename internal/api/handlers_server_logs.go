@@ -8,12 +8,17 @@ import (
 
 func (s *APIServer) handleServerLogs() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		logChan, subscriberID := s.logBroker.SubscribeGeneral()
+		after, err := streamCursor(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logChan, subscriberID := s.logBroker.SubscribeGeneral(after)
 		includeAccessLogs := r.URL.Query().Get("access-logs") == "true"
 
 		streamConfig := sseStreamConfig{
 			logChan: logChan,
-			cleanup: func() { s.logBroker.UnsubscribeGeneral(subscriberID) },
+			cleanup: func() { s.logBroker.Unsubscribe(subscriberID) },
 		}
 
 		if !includeAccessLogs {
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/cron"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+const (
+	// defaultUpgradeWindow is how long after the start of its window a
+	// scheduled upgrade may still begin, e.g. when haloyd was restarting.
+	defaultUpgradeWindow = time.Hour
+	// minUpgradeWindow leaves haloyd, which checks for due upgrades every
+	// minute, time to notice one.
+	minUpgradeWindow = 5 * time.Minute
+	// serverUpgradeNow is the schedule of an upgrade started right away.
+	serverUpgradeNow = "now"
+)
+
+// handleServerUpgrade returns the latest scheduled upgrade.
+func (s *APIServer) handleServerUpgrade() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		upgrade, err := s.db.GetServerUpgrade()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		encodeJSON(w, http.StatusOK, serverUpgradeResponse(upgrade))
+	}
+}
+
+// handleScheduleServerUpgrade schedules an upgrade for the next time its
+// window starts, replacing one that is scheduled but not running yet.
+func (s *APIServer) handleScheduleServerUpgrade() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req apitypes.ServerUpgradeRequest
+		if err := decodeJSON(r.Body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		upgrade, err := newServerUpgrade(req, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		existing, err := s.db.GetServerUpgrade()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if existing != nil && existing.State == storage.ServerUpgradeRunning {
+			http.Error(w, "an upgrade is running on this server", http.StatusConflict)
+			return
+		}
+
+		if err := s.db.SaveServerUpgrade(upgrade); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if req.Now && s.serverUpgradeWake != nil {
+			s.serverUpgradeWake()
+		}
+		encodeJSON(w, http.StatusOK, serverUpgradeResponse(&upgrade))
+	}
+}
+
+// handleCancelServerUpgrade cancels the scheduled upgrade. A running upgrade
+// can't be canceled.
+func (s *APIServer) handleCancelServerUpgrade() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		upgrade, err := s.db.GetServerUpgrade()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		switch {
+		case upgrade == nil || upgrade.Finished():
+			http.Error(w, "no upgrade is scheduled on this server", http.StatusNotFound)
+			return
+		case upgrade.State == storage.ServerUpgradeRunning:
+			http.Error(w, "the upgrade is already running", http.StatusConflict)
+			return
+		}
+
+		upgrade.State = storage.ServerUpgradeCanceled
+		upgrade.UpdatedAt = time.Now()
+		if err := s.db.SaveServerUpgrade(*upgrade); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		encodeJSON(w, http.StatusOK, serverUpgradeResponse(upgrade))
+	}
+}
+
+// newServerUpgrade validates req and schedules the upgrade for the first
+// start of its window after now, or for now itself with req.Now.
+func newServerUpgrade(req apitypes.ServerUpgradeRequest, now time.Time) (storage.ServerUpgrade, error) {
+	var schedule cron.Schedule
+	var err error
+	switch {
+	case req.Now && req.Schedule != "":
+		return storage.ServerUpgrade{}, errors.New("an upgrade can't both have a schedule and start now")
+	case !req.Now:
+		if schedule, err = cron.ParseWindow(req.Schedule); err != nil {
+			return storage.ServerUpgrade{}, err
+		}
+	}
+
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return storage.ServerUpgrade{}, fmt.Errorf("invalid timezone '%s': %w", timezone, err)
+	}
+
+	window := defaultUpgradeWindow
+	if req.Window != "" {
+		if window, err = time.ParseDuration(req.Window); err != nil {
+			return storage.ServerUpgrade{}, fmt.Errorf("invalid window '%s': %w", req.Window, err)
+		}
+		if window < minUpgradeWindow {
+			return storage.ServerUpgrade{}, fmt.Errorf("window must be at least %s", minUpgradeWindow)
+		}
+	}
+
+	if req.NotifyURL != "" {
+		if u, err := url.Parse(req.NotifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return storage.ServerUpgrade{}, fmt.Errorf("invalid notify URL '%s': must be an http or https URL", req.NotifyURL)
+		}
+	}
+
+	startAt, label := now.In(loc), serverUpgradeNow
+	if !req.Now {
+		startAt, label = schedule.Next(startAt), req.Schedule
+	}
+	return storage.ServerUpgrade{
+		Schedule:  label,
+		Timezone:  timezone,
+		StartAt:   startAt.UTC(),
+		Deadline:  startAt.Add(window).UTC(),
+		NotifyURL: req.NotifyURL,
+		State:     storage.ServerUpgradeScheduled,
+		UpdatedAt: now,
+	}, nil
+}
+
+func serverUpgradeResponse(u *storage.ServerUpgrade) apitypes.ServerUpgradeResponse {
+	if u == nil {
+		return apitypes.ServerUpgradeResponse{}
+	}
+	upgrade := ServerUpgradeInfo(*u)
+	return apitypes.ServerUpgradeResponse{Upgrade: &upgrade}
+}
+
+// ServerUpgradeInfo returns the API view of an upgrade, without its notify
+// URL, which may hold a secret.
+func ServerUpgradeInfo(u storage.ServerUpgrade) apitypes.ServerUpgrade {
+	return apitypes.ServerUpgrade{
+		Schedule:    u.Schedule,
+		Timezone:    u.Timezone,
+		StartAt:     u.StartAt,
+		Deadline:    u.Deadline,
+		State:       u.State,
+		FromVersion: u.FromVersion,
+		ToVersion:   u.ToVersion,
+		Message:     u.Message,
+		UpdatedAt:   u.UpdatedAt,
+	}
+}
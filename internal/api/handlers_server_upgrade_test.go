@@ -0,0 +1,55 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+)
+
+func TestNewServerUpgrade(t *testing.T) {
+	// 2026-03-02 is a Monday; Oslo is at UTC+1 until the end of March.
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	upgrade, err := newServerUpgrade(apitypes.ServerUpgradeRequest{Schedule: "Sat 03:00", Timezone: "Europe/Oslo", Window: "30m"}, now)
+	if err != nil {
+		t.Fatalf("newServerUpgrade() error = %v", err)
+	}
+	if want := time.Date(2026, 3, 7, 2, 0, 0, 0, time.UTC); !upgrade.StartAt.Equal(want) {
+		t.Errorf("StartAt = %v, want %v", upgrade.StartAt, want)
+	}
+	if want := upgrade.StartAt.Add(30 * time.Minute); !upgrade.Deadline.Equal(want) {
+		t.Errorf("Deadline = %v, want %v", upgrade.Deadline, want)
+	}
+
+	upgrade, err = newServerUpgrade(apitypes.ServerUpgradeRequest{Schedule: "03:00"}, now)
+	if err != nil {
+		t.Fatalf("newServerUpgrade() error = %v", err)
+	}
+	if upgrade.Timezone != "UTC" || !upgrade.StartAt.Equal(time.Date(2026, 3, 3, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("upgrade = %+v, want the next 03:00 UTC", upgrade)
+	}
+	if upgrade.Deadline.Sub(upgrade.StartAt) != defaultUpgradeWindow {
+		t.Errorf("window = %s, want %s", upgrade.Deadline.Sub(upgrade.StartAt), defaultUpgradeWindow)
+	}
+
+	upgrade, err = newServerUpgrade(apitypes.ServerUpgradeRequest{Now: true}, now)
+	if err != nil {
+		t.Fatalf("newServerUpgrade() error = %v", err)
+	}
+	if upgrade.Schedule != serverUpgradeNow || !upgrade.StartAt.Equal(now) {
+		t.Errorf("upgrade = %+v, want one starting now", upgrade)
+	}
+
+	for name, req := range map[string]apitypes.ServerUpgradeRequest{
+		"schedule":   {Schedule: "someday"},
+		"now":        {Schedule: "Sat 03:00", Now: true},
+		"timezone":   {Schedule: "Sat 03:00", Timezone: "Mars/Olympus"},
+		"window":     {Schedule: "Sat 03:00", Window: "1m"},
+		"notify URL": {Schedule: "Sat 03:00", NotifyURL: "ftp://example.com"},
+	} {
+		if _, err := newServerUpgrade(req, now); err == nil {
+			t.Errorf("newServerUpgrade() with invalid %s succeeded, want an error", name)
+		}
+	}
+}
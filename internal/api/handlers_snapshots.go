@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/logging"
+	"github.com/haloydev/haloy/internal/volumesnapshots"
+)
+
+const (
+	// snapshotChecksumHeader carries a downloaded snapshot's hex SHA-256.
+	snapshotChecksumHeader = "X-Haloy-Checksum"
+	// volumeRestoreTimeout bounds a snapshot restore started through the API.
+	volumeRestoreTimeout = 2 * time.Hour
+)
+
+// handleVolumeSnapshots lists an app's volume snapshots. With remote, an
+// s3:// URL from the target's config, the ones uploaded there are included.
+func (s *APIServer) handleVolumeSnapshots() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		remote, err := snapshotRemoteParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		store, err := volumeSnapshotStore()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		snapshots, err := store.List(r.Context(), r.PathValue("appName"), remote)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to list snapshots: %v", err), http.StatusBadGateway)
+			return
+		}
+		if err := encodeJSON(w, http.StatusOK, apitypes.VolumeSnapshotsResponse{Snapshots: snapshots}); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+	}
+}
+
+// handleVolumeSnapshotDownload streams a snapshot, from the server when it's
+// kept there and from the remote otherwise.
+func (s *APIServer) handleVolumeSnapshotDownload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		remote, err := snapshotRemoteParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		store, err := volumeSnapshotStore()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body, size, checksum, err := store.Open(r.Context(), r.PathValue("appName"), r.PathValue("name"), remote)
+		if errors.Is(err, volumesnapshots.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer body.Close()
+		sum, err := volumesnapshots.ParseChecksum(checksum)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set(snapshotChecksumHeader, sum)
+		if size >= 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+		w.WriteHeader(http.StatusOK)
+		io.Copy(w, body)
+	}
+}
+
+// handleVolumeSnapshotRestore restores a snapshot of another app into new
+// volumes of the app in the path, which is meant to be deployed afterwards.
+func (s *APIServer) handleVolumeSnapshotRestore() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		var req apitypes.VolumeSnapshotRestoreRequest
+		if err := decodeJSON(r.Body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.OperationID == "" || req.SourceApp == "" || req.Snapshot == "" || len(req.Volumes) == 0 {
+			http.Error(w, "Operation ID, source app, snapshot and volumes are required", http.StatusBadRequest)
+			return
+		}
+		if req.SourceApp == appName {
+			http.Error(w, "A snapshot can only be restored into another app", http.StatusBadRequest)
+			return
+		}
+		if req.Remote != "" {
+			if _, _, err := config.ParseS3URL(req.Remote); err != nil {
+				http.Error(w, fmt.Sprintf("invalid remote: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		store, err := volumeSnapshotStore()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cli, err := docker.NewClient(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to create Docker client: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		logger := logging.NewDeploymentLogger(req.OperationID, s.logLevel, s.logBroker)
+		go func() {
+			defer cli.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), volumeRestoreTimeout)
+			defer cancel()
+
+			logger.Info("Restoring snapshot", "app", appName, "source", req.SourceApp, "snapshot", req.Snapshot)
+			image, err := restoreHelperImage(ctx, cli, logger, req)
+			if err != nil {
+				logging.LogDeploymentFailed(logger, req.OperationID, appName, "Snapshot restore failed", err)
+				return
+			}
+			err = store.Restore(ctx, cli, appName, volumesnapshots.RestoreOptions{
+				SourceApp: req.SourceApp,
+				Snapshot:  req.Snapshot,
+				Remote:    req.Remote,
+				Volumes:   req.Volumes,
+				Image:     image,
+			}, logger)
+			if err != nil {
+				logging.LogDeploymentFailed(logger, req.OperationID, appName, "Snapshot restore failed", err)
+				return
+			}
+			logging.LogDeploymentComplete(logger, nil, req.OperationID, appName, fmt.Sprintf("Restored %s into the volumes of %s", req.Snapshot, appName))
+		}()
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// restoreHelperImage returns a local image to create the restore's helper
+// container from: the source app's when one of its containers is on the
+// server, the new target's otherwise.
+func restoreHelperImage(ctx context.Context, cli *client.Client, logger *slog.Logger, req apitypes.VolumeSnapshotRestoreRequest) (string, error) {
+	containers, err := docker.GetAppContainers(ctx, cli, true, req.SourceApp)
+	if err != nil {
+		return "", err
+	}
+	if len(containers) > 0 {
+		return containers[0].ImageID, nil
+	}
+	if err := docker.EnsureImageUpToDate(ctx, cli, logger, req.Image); err != nil {
+		return "", err
+	}
+	return req.Image.ImageRef(), nil
+}
+
+func snapshotRemoteParam(r *http.Request) (string, error) {
+	remote := r.URL.Query().Get("remote")
+	if remote == "" {
+		return "", nil
+	}
+	if _, _, err := config.ParseS3URL(remote); err != nil {
+		return "", fmt.Errorf("invalid remote: %w", err)
+	}
+	return remote, nil
+}
+
+func volumeSnapshotStore() (*volumesnapshots.Store, error) {
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	haloydConfig, err := config.LoadDefaultHaloydConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load haloyd config: %w", err)
+	}
+	return volumesnapshots.New(filepath.Join(dataDir, constants.SnapshotsDir), haloydConfig.VolumeSnapshots.S3), nil
+}
@@ -48,6 +48,17 @@ func (s *APIServer) handleAppStatus() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if s.containerRestarts != nil {
+			addRestarts(&response, s.containerRestarts)
+		}
+		if s.db != nil {
+			if paused, err := s.db.GetPausedApp(appName); err == nil && paused != nil && paused.DeploymentID == response.DeploymentID {
+				response.State = "paused"
+			}
+			if events, err := s.db.GetDeploymentEvents(response.DeploymentID); err == nil && len(events) > 0 {
+				response.DeploymentState = events[len(events)-1].State
+			}
+		}
 
 		encodeJSON(w, http.StatusOK, response)
 	}
@@ -89,7 +100,7 @@ func getResponse(containers []container.Summary) (apitypes.AppStatusResponse, er
 		deploymentMap[labels.DeploymentID].domains = append(deploymentMap[labels.DeploymentID].domains, labels.Domains...)
 
 		// Track latest deployment
-		if labels.DeploymentID > latestDeploymentID {
+		if helpers.CompareDeploymentIDs(labels.DeploymentID, latestDeploymentID) > 0 {
 			latestDeploymentID = labels.DeploymentID
 		}
 	}
@@ -112,6 +123,17 @@ func getResponse(containers []container.Summary) (apitypes.AppStatusResponse, er
 	}, nil
 }
 
+// addRestarts fills in the restarts and crash loops of the response's containers.
+func addRestarts(response *apitypes.AppStatusResponse, containerRestarts func(string) (int, bool)) {
+	for _, id := range response.ContainerIDs {
+		restarts, looping := containerRestarts(id)
+		response.Restarts += restarts
+		if looping {
+			response.CrashLooping = append(response.CrashLooping, id)
+		}
+	}
+}
+
 func determineOverallState(states []string) string {
 	if len(states) == 0 {
 		return "unknown"
@@ -2,10 +2,16 @@ package api
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/deploystate"
 	"github.com/haloydev/haloy/internal/docker"
 	"github.com/haloydev/haloy/internal/logging"
+	"github.com/haloydev/haloy/internal/storage"
 )
 
 func (s *APIServer) handleStopApp() http.HandlerFunc {
@@ -30,6 +36,16 @@ func (s *APIServer) handleStopApp() http.HandlerFunc {
 			}
 			defer cli.Close()
 
+			// Mark the app paused before its containers stop, so haloyd
+			// drops its routes instead of answering 502 until it's started,
+			// and reconcile doesn't bring back an app whose containers were
+			// removed.
+			deploymentID, err := docker.LatestDeploymentID(ctx, cli, appName)
+			if err != nil {
+				logger.Warn("Failed to find the latest deployment", "app", appName, "error", err)
+			}
+			s.pauseApp(logger, appName, deploymentID)
+
 			logger.Info("Stopping containers", "app", appName)
 			stoppedIDs, err := docker.StopContainers(ctx, cli, logger, appName, "")
 			if err != nil {
@@ -48,7 +64,7 @@ func (s *APIServer) handleStopApp() http.HandlerFunc {
 
 				if removeVolumes {
 					logger.Info("Removing volumes", "app", appName)
-					if err := docker.RemoveVolumes(ctx, cli, logger, appName); err != nil {
+					if _, err := docker.RemoveVolumes(ctx, cli, logger, appName); err != nil {
 						logger.Error("Failed to remove volumes", "app", appName, "error", err)
 						return
 					}
@@ -62,3 +78,81 @@ func (s *APIServer) handleStopApp() http.HandlerFunc {
 		w.WriteHeader(http.StatusAccepted)
 	}
 }
+
+func (s *APIServer) handleStartApp() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		if appName == "" {
+			http.Error(w, "App name is required", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), defaultContextTimeout)
+		defer cancel()
+
+		cli, err := docker.NewClient(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer cli.Close()
+
+		deploymentID, err := docker.LatestDeploymentID(ctx, cli, appName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if deploymentID == "" {
+			http.Error(w, fmt.Sprintf("app %s has no containers to start, deploy it instead", appName), http.StatusConflict)
+			return
+		}
+
+		if s.db != nil {
+			if err := s.db.ResumeApp(appName); err != nil {
+				http.Error(w, fmt.Sprintf("failed to unpause app: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		logger := logging.NewLogger(s.logLevel, s.logBroker)
+		startedIDs, err := docker.StartContainersByDeploymentID(ctx, cli, logger, appName, deploymentID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logger.Info("Started containers", "app", appName, "deployment_id", deploymentID, "started_count", len(startedIDs))
+
+		encodeJSON(w, http.StatusOK, apitypes.StartAppResponse{
+			DeploymentID: deploymentID,
+			ContainerIDs: startedIDs,
+		})
+	}
+}
+
+// pauseApp marks deploymentID of an app as paused. Without one, like when
+// Docker has no containers of the app left, it marks its live deployment.
+func (s *APIServer) pauseApp(logger *slog.Logger, appName, deploymentID string) {
+	if s.db == nil {
+		return
+	}
+	if deploymentID == "" {
+		specs, err := s.db.ListDeploymentSpecsInState(string(deploystate.Live))
+		if err != nil {
+			logger.Warn("Failed to mark app as paused", "app", appName, "error", err)
+			return
+		}
+		for _, spec := range specs {
+			if spec.AppName == appName {
+				deploymentID = spec.DeploymentID
+				break
+			}
+		}
+		if deploymentID == "" {
+			return
+		}
+	}
+	err := s.db.PauseApp(storage.PausedApp{AppName: appName, DeploymentID: deploymentID, PausedAt: time.Now()})
+	if err != nil {
+		logger.Warn("Failed to mark app as paused", "app", appName, "error", err)
+	}
+}
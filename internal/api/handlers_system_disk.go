@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/docker"
+)
+
+func (s *APIServer) handleSystemDisk() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.diskUsage(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to collect disk usage: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := encodeJSON(w, http.StatusOK, resp); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// haloyDataCategories are the data directory entries reported on their own;
+// everything else in the data directory is summed up as "haloy other".
+var haloyDataCategories = []struct {
+	name string
+	dir  string
+}{
+	{"haloy layers", constants.LayersDir},
+	{"haloy certificates", constants.CertStorageDir},
+	{"haloy database", constants.DBDir},
+	{"haloy temp", constants.TempDir},
+}
+
+func collectDiskUsage(ctx context.Context) (apitypes.DiskUsageResponse, error) {
+	var resp apitypes.DiskUsageResponse
+
+	haloydConfig, err := config.LoadDefaultHaloydConfig()
+	if err != nil {
+		return resp, fmt.Errorf("load haloyd config: %w", err)
+	}
+	resp.MinFreeBytes = haloydConfig.Disk.GetMinFreeSpaceBytes()
+
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return resp, fmt.Errorf("resolve data directory: %w", err)
+	}
+
+	cli, err := docker.NewClient(ctx)
+	if err != nil {
+		return resp, err
+	}
+	defer cli.Close()
+
+	rootDir, err := dockerRootDir(ctx, cli)
+	if err != nil {
+		return resp, err
+	}
+
+	du, err := cli.DiskUsage(ctx, types.DiskUsageOptions{})
+	if err != nil {
+		return resp, fmt.Errorf("read Docker disk usage: %w", err)
+	}
+	resp.Categories = append(resp.Categories, dockerDiskUsageCategories(du)...)
+	resp.Categories = append(resp.Categories, haloyDataDiskUsage(dataDir)...)
+
+	resp.Filesystems, err = filesystemUsage(osDiskSpaceProbe{}, []diskUser{
+		{name: "docker", path: rootDir},
+		{name: "haloy data", path: dataDir},
+	})
+	if err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// dockerDiskUsageCategories summarizes 'docker system df' data. Reclaimable
+// space follows the Docker CLI: images and volumes no container uses,
+// stopped containers and build cache not in use.
+func dockerDiskUsageCategories(du types.DiskUsage) []apitypes.DiskUsageCategory {
+	images := apitypes.DiskUsageCategory{Name: "docker images", Count: len(du.Images), SizeBytes: nonNegative(du.LayersSize)}
+	for _, img := range du.Images {
+		if img.Containers == 0 {
+			reclaimable := img.Size
+			if img.SharedSize > 0 {
+				reclaimable -= img.SharedSize
+			}
+			images.ReclaimableBytes += nonNegative(reclaimable)
+		}
+	}
+
+	containers := apitypes.DiskUsageCategory{Name: "docker containers", Count: len(du.Containers)}
+	for _, c := range du.Containers {
+		containers.SizeBytes += nonNegative(c.SizeRw)
+		if c.State != "running" {
+			containers.ReclaimableBytes += nonNegative(c.SizeRw)
+		}
+	}
+
+	volumes := apitypes.DiskUsageCategory{Name: "docker volumes", Count: len(du.Volumes)}
+	for _, v := range du.Volumes {
+		if v.UsageData == nil {
+			continue
+		}
+		volumes.SizeBytes += nonNegative(v.UsageData.Size)
+		if v.UsageData.RefCount == 0 {
+			volumes.ReclaimableBytes += nonNegative(v.UsageData.Size)
+		}
+	}
+
+	buildCache := apitypes.DiskUsageCategory{Name: "docker build cache", Count: len(du.BuildCache)}
+	for _, bc := range du.BuildCache {
+		if bc.Shared {
+			continue
+		}
+		buildCache.SizeBytes += nonNegative(bc.Size)
+		if !bc.InUse {
+			buildCache.ReclaimableBytes += nonNegative(bc.Size)
+		}
+	}
+
+	return []apitypes.DiskUsageCategory{images, containers, volumes, buildCache}
+}
+
+func haloyDataDiskUsage(dataDir string) []apitypes.DiskUsageCategory {
+	total, err := dirSize(dataDir)
+	if err != nil {
+		return []apitypes.DiskUsageCategory{{Name: "haloy data", Path: dataDir, Error: err.Error()}}
+	}
+
+	var categories []apitypes.DiskUsageCategory
+	var counted uint64
+	for _, c := range haloyDataCategories {
+		path := filepath.Join(dataDir, c.dir)
+		category := apitypes.DiskUsageCategory{Name: c.name, Path: path}
+		size, err := dirSize(path)
+		if err != nil {
+			category.Error = err.Error()
+		}
+		category.SizeBytes = size
+		counted += size
+		categories = append(categories, category)
+	}
+	if total > counted {
+		categories = append(categories, apitypes.DiskUsageCategory{Name: "haloy other", Path: dataDir, SizeBytes: total - counted})
+	}
+	return categories
+}
+
+// dirSize sums the sizes of regular files under path. A missing path is empty.
+func dirSize(path string) (uint64, error) {
+	var size uint64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		size += uint64(info.Size())
+		return nil
+	})
+	return size, err
+}
+
+type diskUser struct {
+	name string
+	path string
+}
+
+// filesystemUsage reports each filesystem the users live on once, listing
+// the users sharing it.
+func filesystemUsage(probe diskSpaceProbe, users []diskUser) ([]apitypes.FilesystemUsage, error) {
+	var filesystems []apitypes.FilesystemUsage
+	byDevice := make(map[uint64]int)
+
+	for _, user := range users {
+		info, err := probe.FilesystemInfo(user.path)
+		if err != nil {
+			return nil, fmt.Errorf("inspect filesystem %s: %w", user.path, err)
+		}
+		if i, ok := byDevice[info.DeviceID]; ok {
+			filesystems[i].UsedBy = append(filesystems[i].UsedBy, user.name)
+			continue
+		}
+		byDevice[info.DeviceID] = len(filesystems)
+		filesystems = append(filesystems, apitypes.FilesystemUsage{
+			Path:           info.Path,
+			UsedBy:         []string{user.name},
+			TotalBytes:     info.TotalBytes,
+			AvailableBytes: info.AvailableBytes,
+		})
+	}
+	return filesystems, nil
+}
+
+// checkDeployDiskSpace rejects a deploy when the Docker or haloy data
+// filesystem has less free space than disk.min_free_space.
+func checkDeployDiskSpace(ctx context.Context) error {
+	haloydConfig, err := config.LoadDefaultHaloydConfig()
+	if err != nil {
+		return fmt.Errorf("load haloyd config: %w", err)
+	}
+	minFree := haloydConfig.Disk.GetMinFreeSpaceBytes()
+	if minFree == 0 {
+		return nil
+	}
+
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return fmt.Errorf("resolve data directory: %w", err)
+	}
+
+	cli, err := docker.NewClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	rootDir, err := dockerRootDir(ctx, cli)
+	if err != nil {
+		return err
+	}
+
+	return checkMinFreeSpace(osDiskSpaceProbe{}, minFree, rootDir, dataDir)
+}
+
+func checkMinFreeSpace(probe diskSpaceProbe, minFree uint64, paths ...string) error {
+	for _, path := range paths {
+		info, err := probe.FilesystemInfo(path)
+		if err != nil {
+			return fmt.Errorf("inspect filesystem %s: %w", path, err)
+		}
+		if info.AvailableBytes < minFree {
+			return fmt.Errorf("deploy aborted: %w (free up space, see 'haloy server df', or lower disk.min_free_space in the haloyd config)",
+				&insufficientDiskSpaceError{Path: info.Path, AvailableBytes: info.AvailableBytes, RequiredBytes: minFree})
+		}
+	}
+	return nil
+}
+
+func nonNegative(n int64) uint64 {
+	if n < 0 {
+		return 0
+	}
+	return uint64(n)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/volume"
+)
+
+func TestDockerDiskUsageCategories(t *testing.T) {
+	du := types.DiskUsage{
+		LayersSize: 1000,
+		Images: []*image.Summary{
+			{Size: 600, SharedSize: 100, Containers: 1},
+			{Size: 400, SharedSize: 100, Containers: 0},
+		},
+		Containers: []*container.Summary{
+			{SizeRw: 10, State: "running"},
+			{SizeRw: 5, State: "exited"},
+		},
+		Volumes: []*volume.Volume{
+			{UsageData: &volume.UsageData{Size: 50, RefCount: 1}},
+			{UsageData: &volume.UsageData{Size: 20, RefCount: 0}},
+			{UsageData: &volume.UsageData{Size: -1, RefCount: 0}},
+			{},
+		},
+		BuildCache: []*types.BuildCache{
+			{Size: 30, InUse: true},
+			{Size: 7},
+			{Size: 99, Shared: true},
+		},
+	}
+
+	got := dockerDiskUsageCategories(du)
+	want := map[string][3]uint64{ // count, size, reclaimable
+		"docker images":      {2, 1000, 300},
+		"docker containers":  {2, 15, 5},
+		"docker volumes":     {4, 70, 20},
+		"docker build cache": {3, 37, 7},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d categories, want %d", len(got), len(want))
+	}
+	for _, c := range got {
+		w, ok := want[c.Name]
+		if !ok {
+			t.Fatalf("unexpected category %q", c.Name)
+		}
+		if uint64(c.Count) != w[0] || c.SizeBytes != w[1] || c.ReclaimableBytes != w[2] {
+			t.Errorf("%s = count %d size %d reclaimable %d, want %v", c.Name, c.Count, c.SizeBytes, c.ReclaimableBytes, w)
+		}
+	}
+}
+
+func TestHaloyDataDiskUsage(t *testing.T) {
+	dataDir := t.TempDir()
+	write := func(rel string, size int) {
+		path := filepath.Join(dataDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("layers/sha256/abc", 100)
+	write("cert-storage/example.com.crt", 10)
+	write("db/haloy.db", 40)
+	write("proxy/snapshot.json", 5)
+
+	sizes := make(map[string]uint64)
+	for _, c := range haloyDataDiskUsage(dataDir) {
+		if c.Error != "" {
+			t.Fatalf("%s: unexpected error %s", c.Name, c.Error)
+		}
+		sizes[c.Name] = c.SizeBytes
+	}
+
+	want := map[string]uint64{
+		"haloy layers":       100,
+		"haloy certificates": 10,
+		"haloy database":     40,
+		"haloy temp":         0,
+		"haloy other":        5,
+	}
+	for name, size := range want {
+		if sizes[name] != size {
+			t.Errorf("%s = %d, want %d", name, sizes[name], size)
+		}
+	}
+}
+
+func TestFilesystemUsageGroupsSharedDevices(t *testing.T) {
+	probe := fakeDiskSpaceProbe{infos: map[string]filesystemInfo{
+		"/var/lib/docker": {Path: "/var/lib/docker", TotalBytes: 1000, AvailableBytes: 400, DeviceID: 1},
+		"/var/lib/haloy":  {Path: "/var/lib/haloy", TotalBytes: 1000, AvailableBytes: 400, DeviceID: 1},
+		"/mnt/data":       {Path: "/mnt/data", TotalBytes: 500, AvailableBytes: 100, DeviceID: 2},
+	}}
+
+	got, err := filesystemUsage(probe, []diskUser{
+		{name: "docker", path: "/var/lib/docker"},
+		{name: "haloy data", path: "/var/lib/haloy"},
+		{name: "volumes", path: "/mnt/data"},
+	})
+	if err != nil {
+		t.Fatalf("filesystemUsage() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d filesystems, want 2: %+v", len(got), got)
+	}
+	if strings.Join(got[0].UsedBy, ",") != "docker,haloy data" {
+		t.Errorf("UsedBy = %v, want docker and haloy data", got[0].UsedBy)
+	}
+	if got[1].Path != "/mnt/data" || got[1].AvailableBytes != 100 {
+		t.Errorf("second filesystem = %+v", got[1])
+	}
+}
+
+func TestCheckMinFreeSpace(t *testing.T) {
+	probe := fakeDiskSpaceProbe{infos: map[string]filesystemInfo{
+		"/var/lib/docker": {Path: "/var/lib/docker", AvailableBytes: 2 << 30, DeviceID: 1},
+		"/var/lib/haloy":  {Path: "/var/lib/haloy", AvailableBytes: 512 << 20, DeviceID: 2},
+	}}
+
+	if err := checkMinFreeSpace(probe, 1<<30, "/var/lib/docker"); err != nil {
+		t.Fatalf("checkMinFreeSpace() error = %v, want nil", err)
+	}
+
+	err := checkMinFreeSpace(probe, 1<<30, "/var/lib/docker", "/var/lib/haloy")
+	if err == nil {
+		t.Fatal("checkMinFreeSpace() error = nil, want insufficient space")
+	}
+	if !strings.Contains(err.Error(), "/var/lib/haloy") || !strings.Contains(err.Error(), "min_free_space") {
+		t.Fatalf("error = %q, want path and config hint", err)
+	}
+}
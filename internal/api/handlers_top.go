@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+const (
+	defaultTopInterval = 3 * time.Second
+	minTopInterval     = time.Second
+)
+
+// handleAppTop streams resource usage of the app's containers as SSE, one
+// event per interval. Containers are re-listed each time, so replicas that
+// come and go during a rolling deploy show up.
+func (s *APIServer) handleAppTop() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		if appName == "" {
+			http.Error(w, "App name is required", http.StatusBadRequest)
+			return
+		}
+
+		interval := defaultTopInterval
+		if v := r.URL.Query().Get("interval"); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid interval: %v", err), http.StatusBadRequest)
+				return
+			}
+			interval = max(parsed, minTopInterval)
+		}
+		once := r.URL.Query().Get("once") == "true"
+
+		ctx := r.Context()
+		cli, _, err := getAppContainers(ctx, appName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		defer cli.Close()
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		if _, err := w.Write([]byte(": keepalive\n\n")); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			containerList, err := docker.GetAppContainers(ctx, cli, false, appName)
+			if err != nil {
+				return
+			}
+			event := apitypes.AppStatsEvent{
+				Timestamp:  time.Now().UTC(),
+				Containers: sampleContainerStats(ctx, cli, containerList),
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+
+			if once {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// sampleContainerStats reads stats of all containers concurrently, since each
+// read takes about a second.
+func sampleContainerStats(ctx context.Context, cli *client.Client, containers []container.Summary) []apitypes.ContainerStats {
+	results := make([]apitypes.ContainerStats, len(containers))
+	var wg sync.WaitGroup
+	for i, c := range containers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats, err := docker.ContainerStats(ctx, cli, c.ID)
+			if err != nil {
+				results[i] = apitypes.ContainerStats{ContainerID: helpers.SafeIDPrefix(c.ID), Error: err.Error()}
+				return
+			}
+			results[i] = containerStatsFromDocker(c.ID, stats)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// containerStatsFromDocker derives the figures 'docker stats' shows from a
+// raw stats sample.
+func containerStatsFromDocker(containerID string, s container.StatsResponse) apitypes.ContainerStats {
+	stats := apitypes.ContainerStats{
+		ContainerID: helpers.SafeIDPrefix(containerID),
+		CPUPercent:  docker.CPUPercent(s),
+		MemoryUsage: docker.MemoryUsage(s),
+		MemoryLimit: s.MemoryStats.Limit,
+		PIDs:        s.PidsStats.Current,
+	}
+	if stats.MemoryLimit > 0 {
+		stats.MemoryPercent = float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100
+	}
+
+	for _, n := range s.Networks {
+		stats.NetRx += n.RxBytes
+		stats.NetTx += n.TxBytes
+	}
+	for _, entry := range s.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.BlockRead += entry.Value
+		case "write":
+			stats.BlockWrite += entry.Value
+		}
+	}
+	return stats
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"math"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestContainerStatsFromDocker(t *testing.T) {
+	var s container.StatsResponse
+	s.CPUStats.CPUUsage.TotalUsage = 300
+	s.CPUStats.SystemUsage = 2000
+	s.CPUStats.OnlineCPUs = 2
+	s.PreCPUStats.CPUUsage.TotalUsage = 100
+	s.PreCPUStats.SystemUsage = 1000
+	s.MemoryStats.Usage = 600
+	s.MemoryStats.Limit = 1000
+	s.MemoryStats.Stats = map[string]uint64{"inactive_file": 100}
+	s.Networks = map[string]container.NetworkStats{
+		"eth0": {RxBytes: 10, TxBytes: 20},
+		"eth1": {RxBytes: 1, TxBytes: 2},
+	}
+	s.BlkioStats.IoServiceBytesRecursive = []container.BlkioStatEntry{
+		{Op: "Read", Value: 5},
+		{Op: "write", Value: 7},
+		{Op: "Total", Value: 12},
+	}
+	s.PidsStats.Current = 4
+
+	got := containerStatsFromDocker("0123456789abcdef0123", s)
+
+	if got.ContainerID != "0123456789ab" {
+		t.Errorf("ContainerID = %q, want %q", got.ContainerID, "0123456789ab")
+	}
+	if math.Abs(got.CPUPercent-40) > 0.001 {
+		t.Errorf("CPUPercent = %v, want 40", got.CPUPercent)
+	}
+	if got.MemoryUsage != 500 || got.MemoryLimit != 1000 {
+		t.Errorf("memory = %d/%d, want 500/1000", got.MemoryUsage, got.MemoryLimit)
+	}
+	if math.Abs(got.MemoryPercent-50) > 0.001 {
+		t.Errorf("MemoryPercent = %v, want 50", got.MemoryPercent)
+	}
+	if got.NetRx != 11 || got.NetTx != 22 {
+		t.Errorf("net = %d/%d, want 11/22", got.NetRx, got.NetTx)
+	}
+	if got.BlockRead != 5 || got.BlockWrite != 7 {
+		t.Errorf("block = %d/%d, want 5/7", got.BlockRead, got.BlockWrite)
+	}
+	if got.PIDs != 4 {
+		t.Errorf("PIDs = %d, want 4", got.PIDs)
+	}
+}
+
+func TestContainerStatsFromDockerFirstSample(t *testing.T) {
+	var s container.StatsResponse
+	s.CPUStats.CPUUsage.TotalUsage = 300
+	s.CPUStats.SystemUsage = 2000
+
+	got := containerStatsFromDocker("abc", s)
+	if got.CPUPercent != 0 {
+		t.Errorf("CPUPercent = %v, want 0 without a previous sample", got.CPUPercent)
+	}
+	if got.MemoryPercent != 0 {
+		t.Errorf("MemoryPercent = %v, want 0 without a memory limit", got.MemoryPercent)
+	}
+}
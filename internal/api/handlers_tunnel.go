@@ -9,7 +9,6 @@ import (
 	"sync"
 
 	"github.com/haloydev/haloy/internal/config"
-	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/docker"
 )
 
@@ -71,11 +70,11 @@ func (s *APIServer) handleTunnel() http.HandlerFunc {
 		}
 
 		// Get container IP - handle host network mode specially
-		var containerIP string
+		var containerIP, publishedPort string
 		if containerInfo.HostConfig != nil && containerInfo.HostConfig.NetworkMode == "host" {
 			containerIP = "127.0.0.1"
 		} else {
-			containerIP, err = docker.ContainerNetworkIP(containerInfo, constants.DockerNetwork)
+			containerIP, publishedPort, err = docker.ContainerAddress(containerInfo)
 			if err != nil {
 				http.Error(w, fmt.Sprintf("Failed to get container IP: %v", err), http.StatusInternalServerError)
 				return
@@ -90,6 +89,9 @@ func (s *APIServer) handleTunnel() http.HandlerFunc {
 		}
 
 		port := labels.Port.String()
+		if publishedPort != "" {
+			port = publishedPort
+		}
 		if portOverride != "" {
 			port = portOverride
 		}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+const (
+	defaultUptimeDays = 30
+	maxUptimeDays     = 90
+)
+
+// uptimeWindows are the days the uptime endpoint summarizes, today included.
+var uptimeWindows = []int{1, 7, 30}
+
+// handleAppUptime returns the daily uptime of an app and how much of its
+// error budget the last 1, 7 and 30 days used.
+func (s *APIServer) handleAppUptime() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		if appName == "" {
+			http.Error(w, "App name is required", http.StatusBadRequest)
+			return
+		}
+
+		days := defaultUptimeDays
+		if v := r.URL.Query().Get("days"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 1 || parsed > maxUptimeDays {
+				http.Error(w, fmt.Sprintf("days must be between 1 and %d", maxUptimeDays), http.StatusBadRequest)
+				return
+			}
+			days = parsed
+		}
+
+		if s.db == nil || s.uptimeObjective == 0 {
+			http.Error(w, "Uptime tracking is disabled on this server", http.StatusServiceUnavailable)
+			return
+		}
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		uptime, err := s.db.ListAppUptime(appName, today.AddDate(0, 0, -max(days, uptimeWindows[len(uptimeWindows)-1])+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		encodeJSON(w, http.StatusOK, appUptimeResponse(uptime, today, days, s.uptimeObjective))
+	}
+}
+
+func appUptimeResponse(uptime []storage.AppUptime, today time.Time, days int, objective float64) apitypes.AppUptimeResponse {
+	response := apitypes.AppUptimeResponse{Objective: objective, Days: []apitypes.UptimeDay{}}
+	for _, windowDays := range uptimeWindows {
+		since := today.AddDate(0, 0, -windowDays+1)
+		var total storage.AppUptime
+		for _, u := range uptime {
+			if !u.Day.Before(since) {
+				total = AddUptime(total, u)
+			}
+		}
+		up, success := uptimeRatios(total)
+		response.Windows = append(response.Windows, apitypes.UptimeWindow{
+			Days:            windowDays,
+			Minutes:         total.Minutes,
+			DownMinutes:     total.DownMinutes,
+			Requests:        total.Requests,
+			Errors:          total.Errors,
+			Uptime:          up * 100,
+			SuccessRate:     success * 100,
+			BudgetRemaining: (1 - UptimeBurnRate(total, objective)) * 100,
+		})
+	}
+
+	since := today.AddDate(0, 0, -days+1)
+	for _, u := range uptime {
+		if u.Day.Before(since) {
+			continue
+		}
+		up, success := uptimeRatios(u)
+		response.Days = append(response.Days, apitypes.UptimeDay{
+			Date:        u.Day.UTC().Format(time.DateOnly),
+			Minutes:     u.Minutes,
+			DownMinutes: u.DownMinutes,
+			Requests:    u.Requests,
+			Errors:      u.Errors,
+			Uptime:      up * 100,
+			SuccessRate: success * 100,
+		})
+	}
+	return response
+}
+
+// AddUptime returns the counts of a and b added up.
+func AddUptime(a, b storage.AppUptime) storage.AppUptime {
+	a.Minutes += b.Minutes
+	a.DownMinutes += b.DownMinutes
+	a.Requests += b.Requests
+	a.Errors += b.Errors
+	return a
+}
+
+// uptimeRatios returns the share of monitored minutes that were up and of
+// requests that succeeded, 1 for either when nothing was measured.
+func uptimeRatios(u storage.AppUptime) (uptime, success float64) {
+	uptime, success = 1, 1
+	if u.Minutes > 0 {
+		uptime = 1 - float64(u.DownMinutes)/float64(u.Minutes)
+	}
+	if u.Requests > 0 {
+		success = 1 - float64(u.Errors)/float64(u.Requests)
+	}
+	return uptime, success
+}
+
+// UptimeBurnRate returns how fast u used the error budget the objective, in
+// percent, leaves: 1 is exactly as fast as the objective allows over the
+// same time. Downtime and failed requests are measured separately and the
+// worse one counts.
+func UptimeBurnRate(u storage.AppUptime, objective float64) float64 {
+	uptime, success := uptimeRatios(u)
+	return (1 - min(uptime, success)) / (1 - objective/100)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+func TestAppUptimeResponse(t *testing.T) {
+	today := time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)
+	uptime := []storage.AppUptime{
+		{Day: today.AddDate(0, 0, -20), Minutes: 1440, DownMinutes: 144, Requests: 1000},
+		{Day: today.AddDate(0, 0, -3), Minutes: 1440, Requests: 1000, Errors: 10},
+		{Day: today, Minutes: 600},
+	}
+
+	response := appUptimeResponse(uptime, today, 7, 99)
+	if len(response.Windows) != 3 {
+		t.Fatalf("windows = %+v, want 1, 7 and 30 days", response.Windows)
+	}
+	day, week, month := response.Windows[0], response.Windows[1], response.Windows[2]
+	if day.Uptime != 100 || day.SuccessRate != 100 || day.BudgetRemaining != 100 {
+		t.Errorf("today = %+v, want the full budget left", day)
+	}
+	// 1% of requests failed, the whole budget of a 99% objective.
+	if week.SuccessRate != 99 || math.Abs(week.BudgetRemaining) > 1e-9 {
+		t.Errorf("7 days = %+v, want 99%% success and no budget left", week)
+	}
+	// 144 of 3480 minutes down is worse than the failed requests.
+	wantUptime := (1 - 144.0/3480) * 100
+	if math.Abs(month.Uptime-wantUptime) > 1e-9 || month.BudgetRemaining >= 0 {
+		t.Errorf("30 days = %+v, want %.2f%% uptime and an overspent budget", month, wantUptime)
+	}
+
+	if len(response.Days) != 2 || response.Days[0].Date != "2026-10-07" || response.Days[1].Date != "2026-10-10" {
+		t.Errorf("days = %+v, want the two days in the last 7", response.Days)
+	}
+}
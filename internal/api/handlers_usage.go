@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+)
+
+const defaultUsageHistorySince = 24 * time.Hour
+
+// handleAppUsageHistory returns the CPU and memory usage haloyd recorded for
+// an app over the duration given in since.
+func (s *APIServer) handleAppUsageHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appName := r.PathValue("appName")
+		if appName == "" {
+			http.Error(w, "App name is required", http.StatusBadRequest)
+			return
+		}
+
+		since := defaultUsageHistorySince
+		if v := r.URL.Query().Get("since"); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil || parsed <= 0 {
+				http.Error(w, fmt.Sprintf("invalid since: %q", v), http.StatusBadRequest)
+				return
+			}
+			since = parsed
+		}
+
+		if s.db == nil {
+			http.Error(w, "Usage history is not available", http.StatusServiceUnavailable)
+			return
+		}
+		usage, err := s.db.ListAppUsage(appName, time.Now().Add(-since))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := apitypes.AppUsageHistoryResponse{Points: make([]apitypes.AppUsagePoint, 0, len(usage))}
+		for _, u := range usage {
+			response.Points = append(response.Points, apitypes.AppUsagePoint{
+				Time:        u.Time,
+				PeriodSecs:  int64(u.Period / time.Second),
+				CPUPercent:  u.CPUPercent,
+				CPUMax:      u.CPUMax,
+				MemoryAvg:   u.MemoryBytes,
+				MemoryMax:   u.MemoryMax,
+				MemoryLimit: u.MemoryLimit,
+				Containers:  u.Containers,
+			})
+		}
+		encodeJSON(w, http.StatusOK, response)
+	}
+}
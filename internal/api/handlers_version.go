@@ -16,7 +16,7 @@ func (s *APIServer) handleVersion() http.HandlerFunc {
 			Version:                    constants.Version,
 			RequiredProxyGeneration:    proxywire.ProxyGeneration,
 			RequiredProxySchemaVersion: proxywire.SchemaVersion,
-			Capabilities:               []string{constants.CapabilityLayerUpload, constants.CapabilityImagePreflight},
+			Capabilities:               constants.Capabilities,
 		}
 
 		if s.proxyStatus != nil {
@@ -29,6 +29,7 @@ func (s *APIServer) handleVersion() http.HandlerFunc {
 				response.ProxySchemaVersion = status.SchemaVersion
 				response.ProxyCompatible = &compatible
 				response.ProxyConfigHash = status.ConfigHash
+				response.ProxyListenersDown = status.DownListeners()
 			}
 		}
 
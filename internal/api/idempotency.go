@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// idempotencyTTL is how long the response to a request with an idempotency
+// key is kept for repeats. Clients retry within seconds; the rest is margin
+// for a CLI that reconnects after a laptop wakes up.
+const idempotencyTTL = 24 * time.Hour
+
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	// done is closed once the first request has been answered.
+	done    chan struct{}
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// idempotencyKeys remembers the responses to requests sent with an
+// idempotency key, so a client retrying one whose response it never got
+// doesn't start the same deployment twice. Responses live in memory: a
+// retry reaching a haloyd that restarted meanwhile runs the request again,
+// which the deploy lock turns into a conflict rather than a second
+// deployment.
+type idempotencyKeys struct {
+	mu        sync.Mutex
+	responses map[string]*idempotentResponse
+	now       func() time.Time
+}
+
+func newIdempotencyKeys() *idempotencyKeys {
+	return &idempotencyKeys{
+		responses: make(map[string]*idempotentResponse),
+		now:       time.Now,
+	}
+}
+
+// claim returns the response recorded for key and whether one was, or
+// registers a new one for the caller to fill in.
+func (k *idempotencyKeys) claim(key string, fingerprint [sha256.Size]byte) (*idempotentResponse, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := k.now()
+	for key, response := range k.responses {
+		if now.After(response.expires) {
+			delete(k.responses, key)
+		}
+	}
+
+	if response, ok := k.responses[key]; ok {
+		return response, true
+	}
+	response := &idempotentResponse{
+		fingerprint: fingerprint,
+		done:        make(chan struct{}),
+		expires:     now.Add(idempotencyTTL),
+	}
+	k.responses[key] = response
+	return response, false
+}
+
+// forget drops the response of key, so the request can be retried.
+func (k *idempotencyKeys) forget(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.responses, key)
+}
+
+// idempotencyMiddleware answers a request carrying an idempotency key that
+// was already handled with the first response, waiting for it if the first
+// request is still being handled. Server errors aren't kept, so a request
+// that failed on haloyd's side runs again when retried.
+func (s *APIServer) idempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(constants.HeaderIdempotencyKey)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key = r.Method + " " + r.URL.Path + " " + key
+		fingerprint := sha256.Sum256(body)
+		response, replay := s.idempotency.claim(key, fingerprint)
+		if replay {
+			if response.fingerprint != fingerprint {
+				http.Error(w, constants.HeaderIdempotencyKey+" was already used for a different request", http.StatusUnprocessableEntity)
+				return
+			}
+			select {
+			case <-response.done:
+			case <-r.Context().Done():
+				return
+			}
+			for name, values := range response.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(response.status)
+			w.Write(response.body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			if recorder.status >= http.StatusInternalServerError {
+				s.idempotency.forget(key)
+			}
+			response.status = recorder.status
+			response.header = w.Header().Clone()
+			response.body = recorder.body.Bytes()
+			close(response.done)
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// responseRecorder copies what a handler writes, for replaying it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	s := &APIServer{idempotency: newIdempotencyKeys()}
+	calls := 0
+	failing := false
+	h := s.idempotencyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if failing {
+			http.Error(w, "haloyd is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		encodeJSON(w, http.StatusAccepted, map[string]int{"call": calls})
+	}))
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/deploy", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(constants.HeaderIdempotencyKey, key)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := send("d1", `{"deploymentID":"d1"}`)
+	repeat := send("d1", `{"deploymentID":"d1"}`)
+	if calls != 1 || repeat.Code != http.StatusAccepted || repeat.Body.String() != first.Body.String() {
+		t.Fatalf("repeat = %d %q after %d calls, want the first response %q", repeat.Code, repeat.Body, calls, first.Body)
+	}
+	if repeat.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("repeat isn't marked as replayed")
+	}
+
+	if rr := send("d1", `{"deploymentID":"d2"}`); rr.Code != http.StatusUnprocessableEntity || calls != 1 {
+		t.Errorf("reusing the key for another request = %d after %d calls, want %d", rr.Code, calls, http.StatusUnprocessableEntity)
+	}
+	send("", `{}`)
+	send("", `{}`)
+	if calls != 3 {
+		t.Errorf("calls = %d, want requests without a key handled every time", calls)
+	}
+
+	// Server errors aren't kept, so a retry is handled again.
+	failing = true
+	send("d3", `{}`)
+	failing = false
+	if rr := send("d3", `{}`); rr.Code != http.StatusAccepted || calls != 5 {
+		t.Errorf("retry after a server error = %d after %d calls, want it handled", rr.Code, calls)
+	}
+}
+
+func TestIdempotencyKeysExpire(t *testing.T) {
+	keys := newIdempotencyKeys()
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+
+	for i := range 3 {
+		keys.claim(fmt.Sprintf("key%d", i), [32]byte{})
+	}
+	now = now.Add(idempotencyTTL + time.Second)
+	if _, replay := keys.claim("key0", [32]byte{}); replay {
+		t.Error("claim() of an expired key replays, want it claimed anew")
+	}
+	if len(keys.responses) != 1 {
+		t.Errorf("responses = %d, want the expired ones pruned", len(keys.responses))
+	}
+}
@@ -1,16 +1,36 @@
 package api
 
 import (
+	"context"
 	"crypto/subtle"
 	"net/http"
+	"slices"
 	"strings"
 )
 
+// bearerTokenAuthMiddleware accepts the API token or a session token as a
+// bearer token. Without an Authorization header it accepts the session
+// cookie, which requests that change something must back with the
+// session's CSRF token.
 func (s *APIServer) bearerTokenAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			cookie, err := r.Cookie(sessionCookie)
+			if err != nil {
+				http.Error(w, "Authorization header required", http.StatusUnauthorized)
+				return
+			}
+			id, ok := s.sessions.verify(cookie.Value)
+			if !ok {
+				http.Error(w, "Invalid or expired session", http.StatusUnauthorized)
+				return
+			}
+			if !safeMethod(r.Method) && !s.validCSRFToken(r, id) {
+				http.Error(w, "Missing or invalid "+csrfHeader+" header", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, cookie.Value)))
 			return
 		}
 
@@ -25,6 +45,15 @@ func (s *APIServer) bearerTokenAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if strings.HasPrefix(token, sessionTokenPrefix) {
+			if _, ok := s.sessions.verify(token); !ok {
+				http.Error(w, "Invalid or expired session", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, token)))
+			return
+		}
+
 		if subtle.ConstantTimeCompare([]byte(token), []byte(s.apiToken)) != 1 {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
@@ -34,6 +63,30 @@ func (s *APIServer) bearerTokenAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// corsMiddleware lets the browser origins in api.allowed_origins call the
+// API with credentials, and answers their preflight requests. Requests from
+// other origins pass through without CORS headers, so browsers block them.
+func (s *APIServer) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !slices.Contains(s.allowedOrigins, strings.ToLower(origin)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Add("Vary", "Origin")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, "+csrfHeader)
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // standardHeadersMiddleware applies headers for regular HTTP endpoints
 func (s *APIServer) headersMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -61,12 +114,21 @@ func (s *APIServer) streamHeadersMiddleware(next http.Handler) http.Handler {
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Cache-Control", "no-cache")
 		w.Header().Set("Connection", "keep-alive")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if w.Header().Get("Access-Control-Allow-Origin") == "" {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
 		w.Header().Set("X-Accel-Buffering", "no")
 		w.Header().Set("X-Buffering", "no")
 		w.Header().Set("Transfer-Encoding", "chunked")
 
-		next.ServeHTTP(w, r)
+		// Streams last until the client leaves, so shutdown ends them
+		// rather than waiting for them to drain.
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stop := context.AfterFunc(s.streamsCtx, cancel)
+		defer stop()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+)
+
+const (
+	// defaultMigrationLockTTL is how long a lock is held when the holder
+	// asks for no TTL. A holder that crashes loses it after this long.
+	defaultMigrationLockTTL = 5 * time.Minute
+	maxMigrationLockTTL     = time.Hour
+	// maxMigrationLockWait bounds how long an acquire blocks, below the 60s
+	// the proxy waits for the API's response headers.
+	maxMigrationLockWait = 50 * time.Second
+)
+
+// migrationLocks are named locks that containers take so only one of them
+// runs migrations at a time. Like deploy locks they live in memory: after a
+// haloyd restart every lock is free.
+type migrationLocks struct {
+	mu    sync.Mutex
+	locks map[string]apitypes.MigrationLockInfo
+	// released is closed and replaced whenever a lock is released, waking
+	// waiting acquires.
+	released chan struct{}
+	now      func() time.Time
+}
+
+func newMigrationLocks() *migrationLocks {
+	return &migrationLocks{
+		locks:    make(map[string]apitypes.MigrationLockInfo),
+		released: make(chan struct{}),
+		now:      time.Now,
+	}
+}
+
+// acquire takes the lock name for holder. A holder acquiring a lock it holds
+// extends it. When someone else holds it, the holder is returned and ok is
+// false.
+func (l *migrationLocks) acquire(name, holder string, ttl time.Duration) (apitypes.MigrationLockInfo, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if existing, locked := l.locks[name]; locked && now.Before(existing.ExpiresAt) {
+		if existing.Holder != holder {
+			return existing, false
+		}
+		existing.ExpiresAt = now.Add(ttl)
+		l.locks[name] = existing
+		return existing, true
+	}
+
+	info := apitypes.MigrationLockInfo{
+		Name:       name,
+		Holder:     holder,
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	l.locks[name] = info
+	return info, true
+}
+
+// wait acquires the lock, waiting for it to be released or to expire until
+// ctx is done.
+func (l *migrationLocks) wait(ctx context.Context, name, holder string, ttl time.Duration) (apitypes.MigrationLockInfo, bool) {
+	for {
+		l.mu.Lock()
+		released := l.released
+		l.mu.Unlock()
+
+		info, ok := l.acquire(name, holder, ttl)
+		if ok {
+			return info, true
+		}
+		timer := time.NewTimer(info.ExpiresAt.Sub(l.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return info, false
+		case <-released:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// release drops the lock name if holder holds it, or whoever holds it when
+// force is set. It reports whether a lock was dropped.
+func (l *migrationLocks) release(name, holder string, force bool) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	existing, ok := l.locks[name]
+	if !ok || (!force && existing.Holder != holder) {
+		return false
+	}
+	delete(l.locks, name)
+	close(l.released)
+	l.released = make(chan struct{})
+	return true
+}
+
+// list returns the held locks, by name.
+func (l *migrationLocks) list() []apitypes.MigrationLockInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	locks := make([]apitypes.MigrationLockInfo, 0, len(l.locks))
+	for name, info := range l.locks {
+		if !now.Before(info.ExpiresAt) {
+			delete(l.locks, name)
+			continue
+		}
+		locks = append(locks, info)
+	}
+	slices.SortFunc(locks, func(a, b apitypes.MigrationLockInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return locks
+}
+
+// migrationLockToken returns the token that grants access to the lock name
+// only. Containers get it instead of the API token.
+func migrationLockToken(apiToken, name string) string {
+	mac := hmac.New(sha256.New, []byte(apiToken))
+	mac.Write([]byte("migration-lock:" + name))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+func TestMigrationLocks_ConflictExpiryAndRelease(t *testing.T) {
+	locks := newMigrationLocks()
+	now := time.Now()
+	locks.now = func() time.Time { return now }
+
+	if _, ok := locks.acquire("db", "web-1", time.Minute); !ok {
+		t.Fatal("first acquire failed")
+	}
+	current, ok := locks.acquire("db", "web-2", time.Minute)
+	if ok || current.Holder != "web-1" {
+		t.Fatalf("second acquire = %+v, %v, want a conflict with web-1", current, ok)
+	}
+	if _, ok := locks.acquire("other", "web-2", time.Minute); !ok {
+		t.Fatal("lock with a different name should not conflict")
+	}
+
+	// Acquiring again extends the holder's lock.
+	now = now.Add(50 * time.Second)
+	if info, ok := locks.acquire("db", "web-1", time.Minute); !ok || !info.ExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("re-acquire by the holder = %+v, %v, want the lock extended", info, ok)
+	}
+
+	if locks.release("db", "web-2", false) {
+		t.Fatal("release by a non-holder dropped the lock")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := locks.acquire("db", "web-2", time.Minute); !ok {
+		t.Fatal("acquire of an expired lock failed")
+	}
+	if len(locks.list()) != 1 {
+		t.Errorf("list() = %+v, want only db; the expired lock on other must be left out", locks.list())
+	}
+
+	if !locks.release("db", "", true) {
+		t.Fatal("forced release failed")
+	}
+	if len(locks.list()) != 0 {
+		t.Errorf("list() = %+v after release, want none", locks.list())
+	}
+}
+
+func TestMigrationLocks_WaitWakesOnRelease(t *testing.T) {
+	locks := newMigrationLocks()
+	locks.acquire("db", "web-1", time.Minute)
+
+	acquired := make(chan bool, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, ok := locks.wait(ctx, "db", "web-2", time.Minute)
+		acquired <- ok
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	locks.release("db", "web-1", false)
+	if ok := <-acquired; !ok {
+		t.Fatal("waiting acquire did not get the released lock")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if info, ok := locks.wait(ctx, "db", "web-3", time.Minute); ok || info.Holder != "web-2" {
+		t.Fatalf("wait = %+v, %v, want a timeout reporting web-2", info, ok)
+	}
+}
+
+func TestMigrationLockAuth(t *testing.T) {
+	s := &APIServer{apiToken: "secret", migrationLocks: newMigrationLocks()}
+	mux := http.NewServeMux()
+	mux.Handle("POST /v1/migration-locks/{name}/acquire", s.migrationLockAuthMiddleware(s.handleMigrationLockAcquire()))
+	mux.Handle("POST /v1/migration-locks/{name}/release", s.migrationLockAuthMiddleware(s.handleMigrationLockRelease()))
+
+	do := func(path, token string) int {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+	dbToken := migrationLockToken("secret", "db")
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"lock token", "/v1/migration-locks/db/acquire?holder=web-1", dbToken, http.StatusOK},
+		{"lock token of another lock", "/v1/migration-locks/other/acquire?holder=web-1", dbToken, http.StatusUnauthorized},
+		{"held by someone else", "/v1/migration-locks/db/acquire?holder=web-2", dbToken, http.StatusConflict},
+		{"missing holder", "/v1/migration-locks/db/acquire", dbToken, http.StatusBadRequest},
+		{"force with lock token", "/v1/migration-locks/db/release?force=true", dbToken, http.StatusForbidden},
+		{"force with API token", "/v1/migration-locks/db/release?force=true", "secret", http.StatusNoContent},
+		{"release of a free lock", "/v1/migration-locks/db/release?holder=web-1", dbToken, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if got := do(tt.path, tt.token); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWithMigrationLockEnv(t *testing.T) {
+	t.Setenv(constants.EnvVarConfigDir, t.TempDir())
+	s := &APIServer{apiToken: "secret"}
+
+	env := make([]config.EnvVar, 1, 4)
+	env[0] = config.EnvVar{Name: "APP_ENV"}
+	tc := config.TargetConfig{
+		MigrationLock: "db",
+		Env:           env,
+		Sidecars:      []config.Sidecar{{Name: "migrate"}},
+	}
+
+	got := s.withMigrationLockEnv(tc)
+	names := func(env []config.EnvVar) map[string]string {
+		m := make(map[string]string)
+		for _, e := range env {
+			m[e.Name] = e.Value
+		}
+		return m
+	}
+	appEnv := names(got.Env)
+	if appEnv[constants.EnvVarMigrationLock] != "db" || appEnv[constants.EnvVarMigrationLockToken] != migrationLockToken("secret", "db") {
+		t.Errorf("app env = %v, want the lock name and token", appEnv)
+	}
+	if _, ok := appEnv[constants.EnvVarMigrationLockURL]; ok {
+		t.Error("lock URL set without an API domain")
+	}
+	if sidecarEnv := names(got.Sidecars[0].Env); sidecarEnv[constants.EnvVarMigrationLock] != "db" {
+		t.Errorf("sidecar env = %v, want the lock variables", sidecarEnv)
+	}
+	if len(tc.Sidecars[0].Env) != 0 || len(env[:cap(env)][1].Name) != 0 {
+		t.Error("withMigrationLockEnv modified the request's target config")
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -100,10 +101,32 @@ func clientIP(r *http.Request) string {
 	return ip
 }
 
+// ipv6PrefixBits is the IPv6 prefix clients are rate limited by. Hosts get
+// at least a /64, so keying on the full address would let one client rotate
+// through billions of buckets.
+const ipv6PrefixBits = 64
+
+// rateLimitKey returns the bucket a client IP is rate limited in: the address
+// itself for IPv4, its /64 network for IPv6.
+func rateLimitKey(ip string) string {
+	addr, err := netip.ParseAddr(strings.Trim(ip, "[]"))
+	if err != nil {
+		return ip
+	}
+	addr = addr.WithZone("").Unmap()
+	if addr.Is4() {
+		return addr.String()
+	}
+	prefix, err := addr.Prefix(ipv6PrefixBits)
+	if err != nil {
+		return addr.String()
+	}
+	return prefix.String()
+}
+
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := clientIP(r)
-		limiter := rl.getVisitor(ip)
+		limiter := rl.getVisitor(rateLimitKey(clientIP(r)))
 		if !limiter.Allow() {
 			http.Error(w, "429 Too Many Requests", http.StatusTooManyRequests)
 			return
@@ -57,3 +57,23 @@ func TestClientIP_NonLoopbackIgnoresForwardedFor(t *testing.T) {
 		t.Fatalf("clientIP() = %q, want remote address %q", got, "203.0.113.9")
 	}
 }
+
+func TestRateLimitKey(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"198.51.100.7", "198.51.100.7"},
+		{"::ffff:198.51.100.7", "198.51.100.7"},
+		{"2001:db8:1:2:aaaa::1", "2001:db8:1:2::/64"},
+		{"2001:db8:1:2:bbbb::9", "2001:db8:1:2::/64"},
+		{"[2001:db8:1:3::1]", "2001:db8:1:3::/64"},
+		{"fe80::1%eth0", "fe80::/64"},
+		{"not-an-ip", "not-an-ip"},
+	}
+	for _, tt := range tests {
+		if got := rateLimitKey(tt.ip); got != tt.want {
+			t.Errorf("rateLimitKey(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -6,26 +6,77 @@ func (s *APIServer) setupRoutes() {
 	httpWithAuth := chain(s.headersMiddleware, s.rateLimiter.Middleware, s.bearerTokenAuthMiddleware)
 	httpWithAuthLayers := chain(s.headersMiddleware, s.layerRateLimiter.Middleware, s.bearerTokenAuthMiddleware)
 	streamWithAuth := chain(s.streamHeadersMiddleware, s.rateLimiter.Middleware, s.bearerTokenAuthMiddleware)
+	// Migration locks also take the lock's own token, which containers get.
+	httpWithLockAuth := chain(s.headersMiddleware, s.rateLimiter.Middleware, s.migrationLockAuthMiddleware)
+	// Starting a deployment may be retried with an idempotency key.
+	httpWithIdempotency := chain(s.headersMiddleware, s.rateLimiter.Middleware, s.bearerTokenAuthMiddleware, s.idempotencyMiddleware)
 
 	s.router.Handle("GET /health", httpWithRateLimit(s.handleHealth()))
-	s.router.Handle("POST /v1/deploy", httpWithAuth(s.handleDeploy()))
+	s.router.Handle("POST /v1/deploy", httpWithIdempotency(s.handleDeploy()))
+	s.router.Handle("GET /v1/deploy/{deploymentID}", httpWithAuth(s.handleDeploymentStatus()))
+	s.router.Handle("POST /v1/deployments/{deploymentID}/cancel", httpWithAuth(s.handleCancelDeployment()))
 	s.router.Handle("GET /v1/deploy/{deploymentID}/logs", streamWithAuth(s.handleDeploymentLogs()))
+	s.router.Handle("GET /v1/deployments/{deploymentID}/logs", httpWithAuth(s.handleDeploymentLogHistory()))
 	s.router.Handle("POST /v1/images/disk-space-check", httpWithAuth(s.handleImageDiskSpaceCheck()))
 	s.router.Handle("POST /v1/images/prune", httpWithAuth(s.handleImagePrune()))
 	s.router.Handle("POST /v1/images/upload", httpWithAuth(s.handleImageUpload()))
+	s.router.Handle("POST /v1/images/check", httpWithAuth(s.handleImageCheck()))
 	s.router.Handle("POST /v1/images/layers/check", httpWithAuthLayers(s.handleLayerCheck()))
 	s.router.Handle("POST /v1/images/layers", httpWithAuthLayers(s.handleLayerUpload()))
+	s.router.Handle("GET /v1/images/layers/uploads/{digest}", httpWithAuthLayers(s.handleLayerUploadStatus()))
+	s.router.Handle("PATCH /v1/images/layers/uploads/{digest}", httpWithAuthLayers(s.handleLayerUploadChunk()))
 	s.router.Handle("POST /v1/images/layers/assemble", httpWithAuthLayers(s.handleImageAssemble()))
 	s.router.Handle("GET /v1/registries", httpWithAuth(s.handleRegistriesList()))
 	s.router.Handle("POST /v1/registries/login", httpWithAuth(s.handleRegistryLogin()))
 	s.router.Handle("POST /v1/registries/logout", httpWithAuth(s.handleRegistryLogout()))
 	s.router.Handle("GET /v1/logs/{appName}", streamWithAuth(s.handleAppLogs()))
+	s.router.Handle("GET /v1/top/{appName}", streamWithAuth(s.handleAppTop()))
+	s.router.Handle("GET /v1/usage/{appName}", httpWithAuth(s.handleAppUsageHistory()))
+	s.router.Handle("GET /v1/uptime/{appName}", httpWithAuth(s.handleAppUptime()))
+	s.router.Handle("GET /v1/system/disk", httpWithAuth(s.handleSystemDisk()))
+	s.router.Handle("GET /v1/certificates", httpWithAuth(s.handleCertificates()))
+	s.router.Handle("GET /v1/proxy/routes", httpWithAuth(s.handleProxyRoutes()))
+	s.router.Handle("GET /v1/proxy/routes/dry-run", httpWithAuth(s.handleProxyRoutesDryRun()))
 	s.router.Handle("GET /v1/server-logs", streamWithAuth(s.handleServerLogs()))
 	s.router.Handle("GET /v1/rollback/{appName}", httpWithAuth(s.handleRollbackTargets()))
-	s.router.Handle("POST /v1/rollback", httpWithAuth(s.handleRollback()))
+	s.router.Handle("POST /v1/rollback", httpWithIdempotency(s.handleRollback()))
 	s.router.Handle("GET /v1/status/{appName}", httpWithAuth(s.handleAppStatus()))
+	s.router.Handle("GET /v1/ping/{appName}", httpWithAuth(s.handleAppPing()))
+	s.router.Handle("GET /v1/inspect/{appName}", httpWithAuth(s.handleAppInspect()))
 	s.router.Handle("POST /v1/stop/{appName}", httpWithAuth(s.handleStopApp()))
+	s.router.Handle("POST /v1/start/{appName}", httpWithAuth(s.handleStartApp()))
+	s.router.Handle("POST /v1/destroy/{appName}", httpWithAuth(s.handleDestroyApp()))
+	s.router.Handle("POST /v1/reconcile", httpWithAuth(s.handleReconcile()))
+	s.router.Handle("GET /v1/previews", httpWithAuth(s.handleListPreviews()))
 	s.router.Handle("POST /v1/exec/{appName}", httpWithAuth(s.handleExec()))
+	s.router.Handle("GET /v1/cp/{appName}", httpWithAuth(s.handleCopyFromContainer()))
+	s.router.Handle("PUT /v1/cp/{appName}", httpWithAuth(s.handleCopyToContainer()))
 	s.router.Handle("POST /v1/tunnel/{appName}", withAuth(s.handleTunnel()))
+	s.router.Handle("GET /v1/migration-locks", httpWithAuth(s.handleMigrationLocks()))
+	s.router.Handle("POST /v1/migration-locks/{name}/acquire", httpWithLockAuth(s.handleMigrationLockAcquire()))
+	s.router.Handle("POST /v1/migration-locks/{name}/release", httpWithLockAuth(s.handleMigrationLockRelease()))
+	s.router.Handle("GET /v1/db/{appName}/backups", httpWithAuth(s.handleDatabaseBackups()))
+	s.router.Handle("POST /v1/db/{appName}/backups", httpWithAuth(s.handleDatabaseBackup()))
+	s.router.Handle("POST /v1/db/{appName}/restore", httpWithAuth(s.handleDatabaseRestore()))
+	s.router.Handle("GET /v1/snapshots/{appName}", httpWithAuth(s.handleVolumeSnapshots()))
+	s.router.Handle("GET /v1/snapshots/{appName}/{name}", httpWithAuth(s.handleVolumeSnapshotDownload()))
+	s.router.Handle("POST /v1/snapshots/{appName}/restore", httpWithAuth(s.handleVolumeSnapshotRestore()))
+	s.router.Handle("GET /v1/gitops", httpWithAuth(s.handleGitOpsStatus()))
+	s.router.Handle("POST /v1/gitops/pause", httpWithAuth(s.handleGitOpsPause()))
+	s.router.Handle("POST /v1/gitops/resume", httpWithAuth(s.handleGitOpsResume()))
+	s.router.Handle("POST /v1/gitops/sync", httpWithAuth(s.handleGitOpsSync()))
+	s.router.Handle("GET /v1/apps/{appName}", httpWithAuth(s.handleGetApp()))
+	s.router.Handle("PUT /v1/apps/{appName}", httpWithAuth(s.handlePutApp()))
+	s.router.Handle("DELETE /v1/apps/{appName}", httpWithAuth(s.handleDeleteApp()))
+	s.router.Handle("POST /v1/apps/{appName}/plan", httpWithAuth(s.handlePlanApp()))
+	s.router.Handle("GET /v1/secrets", httpWithAuth(s.handleSecretsList()))
+	s.router.Handle("PUT /v1/secrets/{name}", httpWithAuth(s.handleSecretSet()))
+	s.router.Handle("DELETE /v1/secrets/{name}", httpWithAuth(s.handleSecretDelete()))
+	s.router.Handle("GET /v1/auth/whoami", httpWithAuth(s.handleWhoAmI()))
+	s.router.Handle("POST /v1/sessions", httpWithAuth(s.handleCreateSession()))
+	s.router.Handle("DELETE /v1/sessions/current", httpWithAuth(s.handleDeleteSession()))
 	s.router.Handle("GET /v1/version", httpWithAuth(s.handleVersion()))
+	s.router.Handle("GET /v1/server/upgrade", httpWithAuth(s.handleServerUpgrade()))
+	s.router.Handle("POST /v1/server/upgrade", httpWithAuth(s.handleScheduleServerUpgrade()))
+	s.router.Handle("DELETE /v1/server/upgrade", httpWithAuth(s.handleCancelServerUpgrade()))
 }
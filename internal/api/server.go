@@ -2,13 +2,20 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/haloydev/haloy/internal/apitypes"
 	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/errorpages"
 	"github.com/haloydev/haloy/internal/logging"
 	"github.com/haloydev/haloy/internal/proxywire"
 	"github.com/haloydev/haloy/internal/storage"
@@ -28,17 +35,95 @@ type APIServer struct {
 	assembleDiskSpaceCheck    func(context.Context, apitypes.ImageAssembleRequest) error
 	imageDiskSpaceCheck       func(context.Context, apitypes.ImageDiskSpaceCheckRequest) (diskSpaceCheckResult, error)
 	imagePrune                func(context.Context, apitypes.ImagePruneRequest) (apitypes.ImagePruneResponse, error)
+	imagePresent              func(ctx context.Context, imageID, imageRef string) (bool, error)
 	registryAuthProvider      func(config.Image) (*config.RegistryAuth, error)
 	registryLoginCheck        func(context.Context, config.RegistryAuth) error
 	proxyStatus               func(context.Context) (*proxywire.Status, error)
+	proxyConfig               func(context.Context) (*proxywire.Snapshot, error)
+	proxyPlan                 func() *proxywire.Snapshot
+	deployLocks               *deployLocks
+	idempotency               *idempotencyKeys
+	migrationLocks            *migrationLocks
+	writeErrorPages           func(appName string, pages map[string]string) error
+	deployDiskSpaceCheck      func(context.Context) error
+	diskUsage                 func(context.Context) (apitypes.DiskUsageResponse, error)
+	containerRestarts         func(containerID string) (restarts int, crashLooping bool)
+	removeCertificates        func(domains []string) []string
+	gitOpsStatus              func() apitypes.GitOpsStatusResponse
+	gitOpsSync                func()
+	serverUpgradeWake         func()
+	uptimeObjective           float64
+	sessions                  *sessions
+	allowedOrigins            []string
+
+	// streamsCtx is the parent of streaming requests, canceled on shutdown.
+	streamsCtx  context.Context
+	stopStreams context.CancelFunc
+	serverMu    sync.Mutex
+	httpServer  *http.Server // set by ListenAndServe
+	shutdown    bool
 }
 
+// errShuttingDown refuses deployments once haloyd is shutting down.
+var errShuttingDown = errors.New("haloyd is shutting down, retry the deployment once it is back")
+
 // SetProxyStatusFunc wires the haloy-proxy status lookup used by the version
 // endpoint. It is optional; when unset or failing, proxy fields are omitted.
 func (s *APIServer) SetProxyStatusFunc(fn func(context.Context) (*proxywire.Status, error)) {
 	s.proxyStatus = fn
 }
 
+// SetProxyRoutesFuncs wires the lookups behind the proxy routes endpoints:
+// config fetches the snapshot haloy-proxy is serving and plan builds the one
+// haloyd would push for the current deployment state.
+func (s *APIServer) SetProxyRoutesFuncs(config func(context.Context) (*proxywire.Snapshot, error), plan func() *proxywire.Snapshot) {
+	s.proxyConfig = config
+	s.proxyPlan = plan
+}
+
+// SetContainerRestartsFunc wires the crash loop lookup used by the status
+// endpoint. It is optional; when unset, restarts are not reported.
+func (s *APIServer) SetContainerRestartsFunc(fn func(containerID string) (restarts int, crashLooping bool)) {
+	s.containerRestarts = fn
+}
+
+// SetRemoveCertificatesFunc wires the certificate removal used by the destroy
+// endpoint. It is optional; when unset, certificates are left in place.
+func (s *APIServer) SetRemoveCertificatesFunc(fn func(domains []string) []string) {
+	s.removeCertificates = fn
+}
+
+// SetGitOpsFuncs wires the GitOps endpoints to the reconciler: status reports
+// its last sync and sync wakes it up. When unset, GitOps is disabled.
+func (s *APIServer) SetGitOpsFuncs(status func() apitypes.GitOpsStatusResponse, sync func()) {
+	s.gitOpsStatus = status
+	s.gitOpsSync = sync
+}
+
+// SetServerUpgradeWakeFunc wires the server upgrade endpoint to the
+// upgrader, so an upgrade requested to start now doesn't wait for its next
+// check.
+func (s *APIServer) SetServerUpgradeWakeFunc(wake func()) {
+	s.serverUpgradeWake = wake
+}
+
+// SetUptimeObjective enables the uptime endpoint, which measures the
+// recorded uptime against objective, in percent. When unset, uptime tracking
+// is disabled.
+func (s *APIServer) SetUptimeObjective(objective float64) {
+	s.uptimeObjective = objective
+}
+
+// SetAllowedOrigins lets browser apps on these origins, such as
+// https://dashboard.example.com, call the API. When unset, browsers only
+// reach the event streams.
+func (s *APIServer) SetAllowedOrigins(origins []string) {
+	s.allowedOrigins = make([]string, len(origins))
+	for i, origin := range origins {
+		s.allowedOrigins[i] = strings.TrimSuffix(strings.ToLower(origin), "/")
+	}
+}
+
 func NewServer(apiToken string, db *storage.DB, logBroker logging.StreamPublisher, logLevel slog.Level) *APIServer {
 	s := &APIServer{
 		router:           http.NewServeMux(),
@@ -48,13 +133,29 @@ func NewServer(apiToken string, db *storage.DB, logBroker logging.StreamPublishe
 		apiToken:         apiToken,
 		rateLimiter:      NewRateLimiter(rate.Limit(5), 10),   // 5 req/sec, burst of 10
 		layerRateLimiter: NewRateLimiter(rate.Limit(50), 100), // 50 req/sec, burst of 100 for layer uploads
+		deployLocks:      newDeployLocks(),
+		idempotency:      newIdempotencyKeys(),
+		migrationLocks:   newMigrationLocks(),
+		sessions:         newSessions(apiToken),
 	}
+	s.streamsCtx, s.stopStreams = context.WithCancel(context.Background())
 	s.registryAuthProvider = loadServerRegistryAuthForImage
 	s.registryLoginCheck = docker.VerifyRegistryLogin
+	s.writeErrorPages = writeErrorPagesToDataDir
+	s.deployDiskSpaceCheck = checkDeployDiskSpace
+	s.diskUsage = collectDiskUsage
 	s.setupRoutes()
 	return s
 }
 
+func writeErrorPagesToDataDir(appName string, pages map[string]string) error {
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return err
+	}
+	return errorpages.WriteBundle(filepath.Join(dataDir, constants.ErrorPagesDir), appName, pages)
+}
+
 func loadServerRegistryAuthForImage(image config.Image) (*config.RegistryAuth, error) {
 	registries, err := loadServerRegistries()
 	if err != nil {
@@ -93,12 +194,53 @@ func (s *APIServer) applyServerRegistryAuth(targetConfig *config.TargetConfig) e
 	return nil
 }
 
+// ListenAndServe serves the API on addr. After Shutdown it returns
+// http.ErrServerClosed.
 func (s *APIServer) ListenAndServe(addr string) error {
+	s.serverMu.Lock()
+	if s.shutdown {
+		s.serverMu.Unlock()
+		return http.ErrServerClosed
+	}
 	srv := &http.Server{
 		Addr:              addr,
-		Handler:           s.router,
+		Handler:           s.corsMiddleware(s.router),
 		ReadHeaderTimeout: 5 * time.Second,  // Prevent Slowloris
 		IdleTimeout:       60 * time.Second, // Keep-alive connections
 	}
+	s.httpServer = srv
+	s.serverMu.Unlock()
 	return srv.ListenAndServe()
 }
+
+// Shutdown stops the API without cutting off deployments. New deployments
+// and rollbacks are refused right away, while running ones get until ctx is
+// done to finish. Streams then end, log streams once they have sent what the
+// log broker buffered, and the remaining requests are drained. Whatever is
+// still running when ctx is done is cut off and reported in the error.
+func (s *APIServer) Shutdown(ctx context.Context) error {
+	var errs []error
+	select {
+	case <-s.deployLocks.drain():
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("deployments still running: %s", strings.Join(s.deployLocks.held(), ", ")))
+	}
+
+	if s.logBroker != nil {
+		s.logBroker.Close()
+	}
+	s.stopStreams()
+
+	s.serverMu.Lock()
+	s.shutdown = true
+	srv := s.httpServer
+	s.serverMu.Unlock()
+	if srv == nil {
+		return errors.Join(errs...)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+		errs = append(errs, fmt.Errorf("drain API requests: %w", err))
+	}
+	return errors.Join(errs...)
+}
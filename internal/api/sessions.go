@@ -0,0 +1,219 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+)
+
+const (
+	// sessionTokenPrefix tells session tokens apart from the API token.
+	sessionTokenPrefix = "hs_"
+	sessionCookie      = "haloy_session"
+	csrfHeader         = "X-CSRF-Token"
+	defaultSessionTTL  = time.Hour
+	maxSessionTTL      = 12 * time.Hour
+)
+
+// sessions are short-lived tokens exchanged for the API token, so browsers
+// never hold the token itself. They are signed with a key derived from the
+// API token and need no storage, except for the ones revoked before they
+// expire, which are remembered in memory until they would have.
+type sessions struct {
+	key []byte
+
+	mu      sync.Mutex
+	revoked map[string]time.Time
+	now     func() time.Time
+}
+
+func newSessions(apiToken string) *sessions {
+	mac := hmac.New(sha256.New, []byte(apiToken))
+	mac.Write([]byte("haloy-api-session"))
+	return &sessions{
+		key:     mac.Sum(nil),
+		revoked: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// issue returns a new session token valid for ttl.
+func (s *sessions) issue(ttl time.Duration) (token string, expires time.Time) {
+	expires = s.now().Add(ttl).Truncate(time.Second)
+	payload := rand.Text() + "." + strconv.FormatInt(expires.Unix(), 10)
+	return sessionTokenPrefix + payload + "." + s.sign("session:"+payload), expires
+}
+
+// verify returns the ID of the session token, and false if it is forged,
+// expired or revoked.
+func (s *sessions) verify(token string) (string, bool) {
+	id, _, ok := s.parse(token)
+	if !ok {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, revoked := s.revoked[id]; revoked {
+		return "", false
+	}
+	return id, true
+}
+
+// parse returns the ID and expiry of a session token this server signed
+// that hasn't expired.
+func (s *sessions) parse(token string) (id string, expires time.Time, ok bool) {
+	rest, ok := strings.CutPrefix(token, sessionTokenPrefix)
+	if !ok {
+		return "", time.Time{}, false
+	}
+	i := strings.LastIndexByte(rest, '.')
+	if i < 0 {
+		return "", time.Time{}, false
+	}
+	payload, signature := rest[:i], rest[i+1:]
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(s.sign("session:"+payload))) != 1 {
+		return "", time.Time{}, false
+	}
+	id, expiresAt, _ := strings.Cut(payload, ".")
+	unix, err := strconv.ParseInt(expiresAt, 10, 64)
+	if err != nil || !s.now().Before(time.Unix(unix, 0)) {
+		return "", time.Time{}, false
+	}
+	return id, time.Unix(unix, 0), true
+}
+
+// csrfToken is the token cookie-authenticated requests that change
+// something have to send in the X-CSRF-Token header. Other sites can make a
+// browser send the cookie, but can't read the token.
+func (s *sessions) csrfToken(id string) string {
+	return s.sign("csrf:" + id)
+}
+
+// revoke ends the session of token before it expires.
+func (s *sessions) revoke(token string) {
+	id, expires, ok := s.parse(token)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for revokedID, until := range s.revoked {
+		if !now.Before(until) {
+			delete(s.revoked, revokedID)
+		}
+	}
+	s.revoked[id] = expires
+}
+
+func (s *sessions) sign(value string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sessionContextKey marks requests authorized with a session token rather
+// than the API token; its value is the session token.
+type sessionContextKey struct{}
+
+// handleCreateSession exchanges the API token for a session token, returned
+// in the body and set as an HTTP-only cookie.
+func (s *APIServer) handleCreateSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(sessionContextKey{}).(string); ok {
+			http.Error(w, "Sessions can only be created with the API token", http.StatusForbidden)
+			return
+		}
+		ttl, err := durationParam(r, "ttl", defaultSessionTTL, maxSessionTTL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if ttl == 0 {
+			ttl = defaultSessionTTL
+		}
+
+		token, expires := s.sessions.issue(ttl)
+		id, _ := s.sessions.verify(token)
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    token,
+			Path:     "/",
+			Expires:  expires,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		encodeJSON(w, http.StatusCreated, apitypes.SessionResponse{
+			Token:     token,
+			CSRFToken: s.sessions.csrfToken(id),
+			ExpiresAt: expires,
+		})
+	}
+}
+
+// handleDeleteSession revokes the session the request was made with and
+// clears its cookie.
+func (s *APIServer) handleDeleteSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(sessionContextKey{}).(string)
+		if !ok {
+			http.Error(w, "Request was not made with a session", http.StatusBadRequest)
+			return
+		}
+		s.sessions.revoke(token)
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Path:     "/",
+			MaxAge:   -1,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleWhoAmI describes the credentials the request was made with, so
+// clients can check which ones are in effect.
+func (s *APIServer) handleWhoAmI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := apitypes.WhoAmIResponse{
+			Type:   apitypes.CredentialAPIToken,
+			Scopes: []string{apitypes.ScopeAll},
+		}
+		if token, ok := r.Context().Value(sessionContextKey{}).(string); ok {
+			_, expires, _ := s.sessions.parse(token)
+			response.Type = apitypes.CredentialSession
+			response.ExpiresAt = &expires
+		}
+		encodeJSON(w, http.StatusOK, response)
+	}
+}
+
+// validCSRFToken reports whether the request carries the CSRF token of the
+// session id.
+func (s *APIServer) validCSRFToken(r *http.Request, id string) bool {
+	got := r.Header.Get(csrfHeader)
+	want := s.sessions.csrfToken(id)
+	return got != "" && hmac.Equal([]byte(got), []byte(want))
+}
+
+// safeMethod reports whether method only reads, so a cookie-authenticated
+// request needs no CSRF token.
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
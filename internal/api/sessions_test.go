@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/logging"
+)
+
+func TestSessions_VerifyExpiryAndRevoke(t *testing.T) {
+	sessions := newSessions("secret")
+	now := time.Now()
+	sessions.now = func() time.Time { return now }
+
+	token, expires := sessions.issue(time.Hour)
+	if _, ok := sessions.verify(token); !ok {
+		t.Fatal("verify() rejected a new session")
+	}
+	if _, ok := newSessions("other").verify(token); ok {
+		t.Error("verify() accepted a session signed with another API token")
+	}
+	if _, ok := sessions.verify(token[:len(token)-1] + "x"); ok {
+		t.Error("verify() accepted a tampered session")
+	}
+
+	other, _ := sessions.issue(time.Hour)
+	sessions.revoke(other)
+	if _, ok := sessions.verify(other); ok {
+		t.Error("verify() accepted a revoked session")
+	}
+	if _, ok := sessions.verify(token); !ok {
+		t.Error("revoke() ended another session")
+	}
+
+	now = expires
+	if _, ok := sessions.verify(token); ok {
+		t.Error("verify() accepted an expired session")
+	}
+}
+
+func TestBearerTokenAuthMiddleware_Sessions(t *testing.T) {
+	s := NewServer("secret", nil, logging.NewLogBroker(), slog.LevelInfo)
+	handler := s.bearerTokenAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/sessions?ttl=30m", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create session status = %d, want %d (body %q)", w.Code, http.StatusCreated, w.Body.String())
+	}
+	var session apitypes.SessionResponse
+	if err := json.NewDecoder(w.Body).Decode(&session); err != nil {
+		t.Fatal(err)
+	}
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value != session.Token || !cookie.HttpOnly || !cookie.Secure {
+		t.Fatalf("session cookie = %+v, want a secure, HTTP-only cookie with the token", cookie)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		auth   string
+		cookie bool
+		csrf   string
+		want   int
+	}{
+		{"API token", http.MethodPost, "Bearer secret", false, "", http.StatusOK},
+		{"session token", http.MethodPost, "Bearer " + session.Token, false, "", http.StatusOK},
+		{"cookie read", http.MethodGet, "", true, "", http.StatusOK},
+		{"cookie write with CSRF token", http.MethodPost, "", true, session.CSRFToken, http.StatusOK},
+		{"cookie write without CSRF token", http.MethodPost, "", true, "", http.StatusForbidden},
+		{"cookie write with wrong CSRF token", http.MethodDelete, "", true, "guess", http.StatusForbidden},
+		{"forged session token", http.MethodGet, "Bearer " + sessionTokenPrefix + "abc.1.sig", false, "", http.StatusUnauthorized},
+		{"nothing", http.MethodGet, "", false, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/v1/apps/web", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			if tt.cookie {
+				r.AddCookie(cookie)
+			}
+			if tt.csrf != "" {
+				r.Header.Set(csrfHeader, tt.csrf)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %q)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	// A session can't create sessions, so it can't outlive itself.
+	r = httptest.NewRequest(http.MethodPost, "/v1/sessions", nil)
+	r.Header.Set("Authorization", "Bearer "+session.Token)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("create session with a session status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	r = httptest.NewRequest(http.MethodDelete, "/v1/sessions/current", nil)
+	r.AddCookie(cookie)
+	r.Header.Set(csrfHeader, session.CSRFToken)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("delete session status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/v1/apps/web", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status after delete = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	s := &APIServer{}
+	s.SetAllowedOrigins([]string{"https://Dashboard.example.com/"})
+	handler := s.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	r := httptest.NewRequest(http.MethodOptions, "/v1/apps/web", nil)
+	r.Header.Set("Origin", "https://dashboard.example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://dashboard.example.com" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatalf("preflight status = %d, headers = %v, want the origin allowed", w.Code, w.Header())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/v1/apps/web", nil)
+	r.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusTeapot || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("other origin status = %d, Access-Control-Allow-Origin = %q, want the request passed on without CORS headers",
+			w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestHandleWhoAmI(t *testing.T) {
+	s := NewServer("secret", nil, logging.NewLogBroker(), slog.LevelInfo)
+	token, expires := s.sessions.issue(time.Hour)
+
+	tests := []struct {
+		token       string
+		wantType    string
+		wantExpires bool
+	}{
+		{"secret", apitypes.CredentialAPIToken, false},
+		{token, apitypes.CredentialSession, true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/v1/auth/whoami", nil)
+		r.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+		var got apitypes.WhoAmIResponse
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Type != tt.wantType || (got.ExpiresAt != nil) != tt.wantExpires {
+			t.Errorf("whoami = %+v, want type %s", got, tt.wantType)
+		}
+		if tt.wantExpires && !got.ExpiresAt.Equal(expires) {
+			t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, expires)
+		}
+	}
+}
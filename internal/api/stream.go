@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/haloydev/haloy/internal/logging"
@@ -16,6 +17,20 @@ type sseStreamConfig struct {
 	shouldSkip      func(logging.LogEntry) bool
 }
 
+// streamCursor returns the Seq a log stream resumes after, from the after
+// query parameter, or zero for a new stream.
+func streamCursor(r *http.Request) (uint64, error) {
+	v := r.URL.Query().Get("after")
+	if v == "" {
+		return 0, nil
+	}
+	after, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid after cursor: %q", v)
+	}
+	return after, nil
+}
+
 // streamSSELogs handles the common SSE streaming logic
 func streamSSELogs(w http.ResponseWriter, r *http.Request, config sseStreamConfig) {
 	defer config.cleanup()
@@ -69,13 +84,19 @@ func streamSSELogs(w http.ResponseWriter, r *http.Request, config sseStreamConfi
 	}
 }
 
-// writeSSEMessage writes a log entry as Server-Sent Event
+// writeSSEMessage writes a log entry as Server-Sent Event, with its Seq as
+// the event ID clients resume after.
 func writeSSEMessage(w http.ResponseWriter, entry logging.LogEntry) error {
 	data, err := json.Marshal(entry)
 	if err != nil {
 		return fmt.Errorf("failed to marshal log entry: %w", err)
 	}
 
+	if entry.Seq != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", entry.Seq); err != nil {
+			return fmt.Errorf("failed to write SSE id: %w", err)
+		}
+	}
 	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
 	if err != nil {
 		return fmt.Errorf("failed to write SSE data: %w", err)
@@ -10,31 +10,86 @@ import (
 	"fmt"
 	"io"
 	"mime/multipart"
-	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/helpers"
+	"golang.org/x/time/rate"
 )
 
 var ErrNotFound = errors.New("resource not found")
 
+// ErrUnauthorized is wrapped by errors for requests the server rejected
+// because of a missing or wrong API token.
+var ErrUnauthorized = errors.New("authentication failed")
+
+// ErrUnreachable is wrapped by errors for servers that could not be reached.
+var ErrUnreachable = errors.New("server not reachable")
+
+// HTTPError is returned when the server answers with an error status, so
+// callers can react to specific statuses and decode structured error bodies.
+type HTTPError struct {
+	Method     string
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s request failed with status %d", e.Method, e.StatusCode)
+	}
+	return fmt.Sprintf("%s request failed with status %d: %s", e.Method, e.StatusCode, e.Body)
+}
+
+// statusError reports an error status with its own message while still
+// matching *HTTPError for callers that classify errors.
+type statusError struct {
+	msg string
+	err *HTTPError
+}
+
+func (e *statusError) Error() string { return e.msg }
+
+func (e *statusError) Unwrap() error { return e.err }
+
 // APIClient handles communication with the haloy API
 type APIClient struct {
-	client   *http.Client
-	baseURL  string
-	apiToken string
+	client        *http.Client
+	uploadClient  *http.Client
+	baseURL       string
+	apiToken      string
+	timeouts      Timeouts
+	retry         RetryPolicy
+	breaker       *breaker
+	uploadLimiter *rate.Limiter
 }
 
 func New(url, token string) (*APIClient, error) {
-	return NewWithTimeout(url, token, 30*time.Second)
+	timeouts, err := DefaultTimeouts()
+	if err != nil {
+		return nil, err
+	}
+	return NewWithTimeouts(url, token, timeouts)
 }
 
+// NewWithTimeout creates a client that waits up to timeout for haloyd to
+// answer regular requests, and at least that long for uploads.
 func NewWithTimeout(url, token string, timeout time.Duration) (*APIClient, error) {
+	timeouts, err := DefaultTimeouts()
+	if err != nil {
+		return nil, err
+	}
+	timeouts.API = timeout
+	timeouts.Upload = max(timeouts.Upload, timeout)
+	return NewWithTimeouts(url, token, timeouts)
+}
+
+func NewWithTimeouts(url, token string, timeouts Timeouts) (*APIClient, error) {
 	normalizedUrl, err := helpers.NormalizeServerURL(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to normalize url: %w", err)
@@ -42,26 +97,31 @@ func NewWithTimeout(url, token string, timeout time.Duration) (*APIClient, error
 	serverUrl := helpers.BuildServerURL(normalizedUrl)
 
 	cli := &APIClient{
-		client: &http.Client{
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   30 * time.Second,
-					KeepAlive: 30 * time.Second,
-				}).DialContext,
-				TLSHandshakeTimeout:   10 * time.Second,
-				ResponseHeaderTimeout: timeout,
-				ExpectContinueTimeout: 1 * time.Second,
-				MaxIdleConns:          10,
-				IdleConnTimeout:       90 * time.Second,
-			},
-		},
-		baseURL:  serverUrl,
-		apiToken: token,
+		client:       &http.Client{Transport: newTransport(timeouts.API, 0)},
+		uploadClient: &http.Client{Transport: newTransport(timeouts.Upload, uploadWriteBufferSize)},
+		baseURL:      serverUrl,
+		apiToken:     token,
+		timeouts:     timeouts,
+		retry:        DefaultRetryPolicy,
+		breaker:      breakerFor(serverUrl),
+	}
+
+	if retries := os.Getenv(constants.EnvVarAPIRetries); retries != "" {
+		n, err := strconv.Atoi(retries)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid %s %q: must be a number of retries", constants.EnvVarAPIRetries, retries)
+		}
+		cli.retry.MaxRetries = n
 	}
 
 	return cli, nil
 }
 
+// SetRetryPolicy sets how requests that failed on the network are retried.
+func (c *APIClient) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
 func (c *APIClient) setAuthHeader(req *http.Request) {
 	if c.apiToken != "" {
 		req.Header.Set("Authorization", "Bearer "+c.apiToken)
@@ -75,20 +135,32 @@ func (c *APIClient) HealthCheck(ctx context.Context) error {
 	}
 
 	// Health endpoint doesn't require auth
-	resp, err := c.client.Do(req)
+	resp, err := c.roundTrip(c.client, req)
 	if err != nil {
-		return fmt.Errorf("server not reachable: %w", err)
+		if errors.Is(err, ErrCircuitOpen) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("server returned status %d", resp.StatusCode)
+		return &statusError{
+			msg: fmt.Sprintf("server returned status %d", resp.StatusCode),
+			err: &HTTPError{Method: http.MethodGet, StatusCode: resp.StatusCode},
+		}
 	}
 
 	return nil
 }
 
+// Get fetches path and decodes the JSON response into v. Requests that
+// fail on the network are retried.
 func (c *APIClient) Get(ctx context.Context, path string, v any) error {
+	return c.withRetries(ctx, func() error { return c.get(ctx, path, v) })
+}
+
+func (c *APIClient) get(ctx context.Context, path string, v any) error {
 	if err := c.HealthCheck(ctx); err != nil {
 		return fmt.Errorf("server not available at %s: %w", c.baseURL, err)
 	}
@@ -100,7 +172,7 @@ func (c *APIClient) Get(ctx context.Context, path string, v any) error {
 	}
 	c.setAuthHeader(req)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.roundTrip(c.client, req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -108,14 +180,14 @@ func (c *APIClient) Get(ctx context.Context, path string, v any) error {
 
 	if resp.StatusCode >= 400 {
 		if resp.StatusCode == http.StatusUnauthorized {
-			return fmt.Errorf("authentication failed - check your %s", constants.EnvVarAPIToken)
+			return fmt.Errorf("%w - check your %s", ErrUnauthorized, constants.EnvVarAPIToken)
 		}
 
 		if resp.StatusCode == http.StatusNotFound {
 			return ErrNotFound
 		}
 
-		return fmt.Errorf("GET request failed with status %d", resp.StatusCode)
+		return &HTTPError{Method: http.MethodGet, StatusCode: resp.StatusCode}
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
@@ -125,11 +197,35 @@ func (c *APIClient) Get(ctx context.Context, path string, v any) error {
 	return nil
 }
 
+// Post sends request as JSON and decodes the response into response, if
+// it's not nil. It isn't retried, as the server might have acted on a
+// request whose response was lost; see PostIdempotent.
 func (c *APIClient) Post(ctx context.Context, path string, request, response any) error {
-	if err := c.HealthCheck(ctx); err != nil {
-		return fmt.Errorf("server not available at %s: %w", c.baseURL, err)
-	}
+	return c.send(ctx, http.MethodPost, path, "", request, response)
+}
+
+// PostIdempotent is Post for requests that are retried when they fail on
+// the network. The server answers a repeat of a request with the same key
+// with the response to the first one instead of acting on it again, so key
+// must be unique to the request, like the ID of the deployment it starts.
+func (c *APIClient) PostIdempotent(ctx context.Context, path, key string, request, response any) error {
+	return c.send(ctx, http.MethodPost, path, key, request, response)
+}
 
+// Put sends request as JSON with PUT and decodes the response into
+// response, if it's not nil.
+func (c *APIClient) Put(ctx context.Context, path string, request, response any) error {
+	return c.send(ctx, http.MethodPut, path, "", request, response)
+}
+
+// Delete sends a DELETE request.
+func (c *APIClient) Delete(ctx context.Context, path string) error {
+	return c.send(ctx, http.MethodDelete, path, "", nil, nil)
+}
+
+// send sends request with method, retrying network failures unless it's a
+// POST without an idempotency key.
+func (c *APIClient) send(ctx context.Context, method, path, idempotencyKey string, request, response any) error {
 	var jsonData []byte
 	var err error
 
@@ -141,19 +237,36 @@ func (c *APIClient) Post(ctx context.Context, path string, request, response any
 		}
 	}
 
+	attempt := func() error {
+		return c.sendOnce(ctx, method, path, idempotencyKey, jsonData, request != nil, response)
+	}
+	if method == http.MethodPost && idempotencyKey == "" {
+		return attempt()
+	}
+	return c.withRetries(ctx, attempt)
+}
+
+func (c *APIClient) sendOnce(ctx context.Context, method, path, idempotencyKey string, jsonData []byte, hasBody bool, response any) error {
+	if err := c.HealthCheck(ctx); err != nil {
+		return fmt.Errorf("server not available at %s: %w", c.baseURL, err)
+	}
+
 	url := fmt.Sprintf("%s/v1/%s", c.baseURL, path)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Only set Content-Type if we have a request body
-	if request != nil {
+	if hasBody {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	if idempotencyKey != "" {
+		req.Header.Set(constants.HeaderIdempotencyKey, idempotencyKey)
+	}
 	c.setAuthHeader(req)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.roundTrip(c.client, req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -162,7 +275,7 @@ func (c *APIClient) Post(ctx context.Context, path string, request, response any
 	if resp.StatusCode >= 400 {
 		bodyBytes, readErr := io.ReadAll(resp.Body)
 		if readErr != nil {
-			return fmt.Errorf("POST request failed with status %d (unable to read error details: %v)", resp.StatusCode, readErr)
+			return fmt.Errorf("%s request failed with status %d (unable to read error details: %v)", method, resp.StatusCode, readErr)
 		}
 
 		errorMessage := strings.TrimSpace(string(bodyBytes))
@@ -170,9 +283,9 @@ func (c *APIClient) Post(ctx context.Context, path string, request, response any
 			errorMessage = "no error details provided"
 		}
 		if resp.StatusCode == http.StatusUnauthorized {
-			return fmt.Errorf("authentication failed - check your %s", constants.EnvVarAPIToken)
+			return fmt.Errorf("%w - check your %s", ErrUnauthorized, constants.EnvVarAPIToken)
 		}
-		return fmt.Errorf("POST request failed with status %d: %s", resp.StatusCode, errorMessage)
+		return &HTTPError{Method: method, StatusCode: resp.StatusCode, Body: errorMessage}
 	}
 
 	if response != nil {
@@ -197,11 +310,25 @@ func (c *APIClient) PostFile(ctx context.Context, path, fieldName, filePath stri
 	}
 	defer file.Close()
 
+	return c.postReader(ctx, path, fieldName, filepath.Base(filePath), file)
+}
+
+// PostReader uploads what r yields as the file fileName using multipart form
+// data, streaming it as it's read.
+func (c *APIClient) PostReader(ctx context.Context, path, fieldName, fileName string, r io.Reader) error {
+	if err := c.HealthCheck(ctx); err != nil {
+		return fmt.Errorf("server not available at %s: %w", c.baseURL, err)
+	}
+
+	return c.postReader(ctx, path, fieldName, fileName, r)
+}
+
+func (c *APIClient) postReader(ctx context.Context, path, fieldName, fileName string, file io.Reader) error {
 	pipeReader, pipeWriter := io.Pipe()
 	writer := multipart.NewWriter(pipeWriter)
 
 	go func() {
-		part, err := writer.CreateFormFile(fieldName, filepath.Base(filePath))
+		part, err := writer.CreateFormFile(fieldName, fileName)
 		if err != nil {
 			pipeWriter.CloseWithError(fmt.Errorf("failed to create form file: %w", err))
 			return
@@ -230,7 +357,7 @@ func (c *APIClient) PostFile(ctx context.Context, path, fieldName, filePath stri
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 	c.setAuthHeader(req)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.DoUpload(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -247,9 +374,12 @@ func (c *APIClient) PostFile(ctx context.Context, path, fieldName, filePath stri
 			errorMessage = "no error details provided"
 		}
 		if resp.StatusCode == http.StatusUnauthorized {
-			return fmt.Errorf("authentication failed - check your %s", constants.EnvVarAPIToken)
+			return fmt.Errorf("%w - check your %s", ErrUnauthorized, constants.EnvVarAPIToken)
+		}
+		return &statusError{
+			msg: fmt.Sprintf("file upload failed with status %d: %s", resp.StatusCode, errorMessage),
+			err: &HTTPError{Method: http.MethodPost, StatusCode: resp.StatusCode, Body: errorMessage},
 		}
-		return fmt.Errorf("file upload failed with status %d: %s", resp.StatusCode, errorMessage)
 	}
 
 	return nil
@@ -257,6 +387,86 @@ func (c *APIClient) PostFile(ctx context.Context, path, fieldName, filePath stri
 
 // Generic streaming method that handles any SSE endpoint
 func (c *APIClient) Stream(ctx context.Context, path string, handler func(data string) bool) error {
+	_, err := c.streamEvents(ctx, path, func(_, data string) bool { return handler(data) })
+	return err
+}
+
+// Reconnect attempts of StreamResumable after the connection dropped, with
+// the delay doubling from streamRetryDelay up to streamMaxRetryDelay. The
+// count starts over whenever a connection delivers events. Variables so
+// tests can shorten them.
+var (
+	streamRetries       = 5
+	streamRetryDelay    = time.Second
+	streamMaxRetryDelay = 10 * time.Second
+)
+
+// StreamResumable is Stream for haloyd's log streams, which give each event
+// its Seq as ID. When the connection drops after an event was received it
+// reconnects and resumes after the last one, so handler sees each event once
+// and none are skipped while haloyd still has them.
+func (c *APIClient) StreamResumable(ctx context.Context, path string, handler func(data string) bool) error {
+	var lastID uint64
+	failures := 0
+	delay := streamRetryDelay
+	for {
+		resumePath := path
+		if lastID > 0 {
+			sep := "?"
+			if strings.Contains(path, "?") {
+				sep = "&"
+			}
+			resumePath += sep + "after=" + strconv.FormatUint(lastID, 10)
+		}
+
+		received := false
+		stopped, err := c.streamEvents(ctx, resumePath, func(id, data string) bool {
+			received = true
+			if seq, err := strconv.ParseUint(id, 10, 64); err == nil {
+				if seq <= lastID {
+					return false
+				}
+				lastID = seq
+			}
+			return handler(data)
+		})
+		if stopped || ctx.Err() != nil {
+			return err
+		}
+		var httpErr *HTTPError
+		if errors.Is(err, ErrUnauthorized) || (errors.As(err, &httpErr) && httpErr.StatusCode < http.StatusInternalServerError) {
+			return err
+		}
+		// Only a stream that got a numbered event can resume: failing to
+		// connect at all is reported right away, and a server that doesn't
+		// number events would replay what was already handled.
+		if lastID == 0 {
+			return err
+		}
+
+		if received {
+			failures = 0
+			delay = streamRetryDelay
+		}
+		failures++
+		if failures > streamRetries {
+			if err == nil {
+				err = errors.New("stream ended")
+			}
+			return fmt.Errorf("gave up reconnecting after %d attempts: %w", streamRetries, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, streamMaxRetryDelay)
+	}
+}
+
+// streamEvents reads an SSE endpoint, passing the ID and data of each event
+// to handler until it returns true, and reports whether it did.
+func (c *APIClient) streamEvents(ctx context.Context, path string, handler func(id, data string) bool) (stopped bool, err error) {
 	// Create transport that forces HTTP/1.1 to avoid HTTP/2 stream cancellation
 	streamingTransport := &http.Transport{
 		ForceAttemptHTTP2: false, // Force HTTP/1.1
@@ -269,7 +479,7 @@ func (c *APIClient) Stream(ctx context.Context, path string, handler func(data s
 	url := fmt.Sprintf("%s/v1/%s", c.baseURL, path)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create SSE request: %w", err)
+		return false, fmt.Errorf("failed to create SSE request: %w", err)
 	}
 
 	req.Header.Set("Accept", "text/event-stream")
@@ -279,37 +489,48 @@ func (c *APIClient) Stream(ctx context.Context, path string, handler func(data s
 
 	resp, err := streamingClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to connect to stream: %w", err)
+		return false, fmt.Errorf("failed to connect to stream: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		if resp.StatusCode == http.StatusUnauthorized {
-			return fmt.Errorf("authentication failed for stream - check your %s", constants.EnvVarAPIToken)
+			return false, fmt.Errorf("%w for stream - check your %s", ErrUnauthorized, constants.EnvVarAPIToken)
 		}
 		bodyBytes, readErr := io.ReadAll(resp.Body)
 		if readErr != nil {
-			return fmt.Errorf("stream returned status %d (unable to read error details: %v)", resp.StatusCode, readErr)
+			return false, fmt.Errorf("stream returned status %d (unable to read error details: %v)", resp.StatusCode, readErr)
 		}
 		errorMessage := strings.TrimSpace(string(bodyBytes))
+		msg := fmt.Sprintf("stream returned status %d", resp.StatusCode)
 		if errorMessage != "" {
-			return fmt.Errorf("stream returned status %d: %s", resp.StatusCode, errorMessage)
+			msg += ": " + errorMessage
 		}
-		return fmt.Errorf("stream returned status %d", resp.StatusCode)
+		return false, &statusError{msg: msg, err: &HTTPError{Method: http.MethodGet, StatusCode: resp.StatusCode, Body: errorMessage}}
 	}
 
+	var id string
 	scanner := bufio.NewScanner(resp.Body)
 	for scanner.Scan() {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return false, ctx.Err()
 		default:
 		}
 
 		line := scanner.Text()
 
-		// Skip empty lines and SSE comment lines
-		if line == "" || strings.HasPrefix(line, ":") {
+		// Skip SSE comment lines, an empty line ends the event
+		if line == "" {
+			id = ""
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		if v, ok := strings.CutPrefix(line, "id: "); ok {
+			id = v
 			continue
 		}
 
@@ -317,20 +538,20 @@ func (c *APIClient) Stream(ctx context.Context, path string, handler func(data s
 		if data, ok := strings.CutPrefix(line, "data: "); ok {
 
 			// Call the handler function to process the data
-			shouldStop := handler(data)
+			shouldStop := handler(id, data)
 
 			// If handler returns true, stop streaming
 			if shouldStop {
-				return nil
+				return true, nil
 			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading stream: %w", err)
+		return false, fmt.Errorf("error reading stream: %w", err)
 	}
 
-	return nil
+	return false, nil
 }
 
 // NewRequest creates an authenticated request with the base URL and auth header set.
@@ -348,5 +569,5 @@ func (c *APIClient) NewRequest(ctx context.Context, method, path string, body io
 // Do executes a request using the client's http.Client.
 // Use with NewRequest for custom requests that don't fit Get/Post patterns.
 func (c *APIClient) Do(req *http.Request) (*http.Response, error) {
-	return c.client.Do(req)
+	return c.roundTrip(c.client, req)
 }
@@ -3,12 +3,15 @@ package apiclient
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestPostFileStreamsMultipartUpload(t *testing.T) {
@@ -147,3 +150,40 @@ func TestStreamReturnsStatusForNonOKStatusWithoutBody(t *testing.T) {
 		t.Fatalf("Stream() error = %q, want %q", err.Error(), want)
 	}
 }
+
+func TestStreamResumableResumesAfterLastEvent(t *testing.T) {
+	streamRetryDelay = time.Millisecond
+	t.Cleanup(func() { streamRetryDelay = time.Second })
+
+	var cursors []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cursors = append(cursors, r.URL.Query().Get("after"))
+		if len(cursors) == 1 {
+			// The connection drops after two events.
+			fmt.Fprint(w, "id: 1\ndata: one\n\nid: 2\ndata: two\n\n")
+			return
+		}
+		fmt.Fprint(w, "id: 2\ndata: two\n\nid: 3\ndata: three\n\n")
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var got []string
+	err = client.StreamResumable(context.Background(), "server-logs?access-logs=true", func(data string) bool {
+		got = append(got, data)
+		return data == "three"
+	})
+	if err != nil {
+		t.Fatalf("StreamResumable() error = %v", err)
+	}
+	if strings.Join(got, ",") != "one,two,three" {
+		t.Errorf("events = %v, want each once", got)
+	}
+	if len(cursors) != 2 || cursors[1] != "2" {
+		t.Errorf("cursors = %q, want a reconnect after 2", cursors)
+	}
+}
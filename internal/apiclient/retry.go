@@ -0,0 +1,173 @@
+package apiclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is wrapped, along with ErrUnreachable, by errors for
+// requests that weren't sent because the last ones to the server all failed
+// on the network.
+var ErrCircuitOpen = errors.New("not sending requests until the server is back")
+
+// RetryPolicy is how requests that failed on the network, or were answered
+// by a proxy that couldn't reach haloyd, are retried. The delay between
+// attempts doubles from BaseDelay up to MaxDelay, with jitter.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultRetryPolicy rides out a haloyd restart or a brief network drop
+// without holding up a command against a server that is down for long.
+// HALOY_API_RETRIES overrides MaxRetries.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   5 * time.Second,
+}
+
+// withRetries runs attempt until it succeeds, fails for a reason retrying
+// doesn't fix, or the retries run out.
+func (c *APIClient) withRetries(ctx context.Context, attempt func() error) error {
+	delay := c.retry.BaseDelay
+	for retry := 0; ; retry++ {
+		err := attempt()
+		if err == nil || retry >= c.retry.MaxRetries || ctx.Err() != nil || !transient(err) {
+			return err
+		}
+
+		wait := delay
+		if wait > 0 {
+			wait = delay/2 + rand.N(delay/2+1)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, c.retry.MaxDelay)
+	}
+}
+
+// transient reports whether err is a failure a retry may not run into.
+func transient(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	var urlErr *url.Error
+	if errors.Is(err, ErrUnreachable) || errors.As(err, &urlErr) {
+		return true
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// Once breakerThreshold requests in a row to a server failed on the network,
+// requests to it fail right away for breakerCooldown. After that one request
+// is let through to check whether the server is back. Variables so tests can
+// change them.
+var (
+	breakerThreshold = 3
+	breakerCooldown  = 15 * time.Second
+)
+
+// breaker is the circuit breaker of one server, shared by all clients of it
+// so commands that create a client per request fail fast too.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*breaker)
+)
+
+func breakerFor(baseURL string) *breaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	b, ok := breakers[baseURL]
+	if !ok {
+		b = &breaker{now: time.Now}
+		breakers[baseURL] = b
+	}
+	return b
+}
+
+// allow returns an error wrapping ErrCircuitOpen if a request must not be
+// sent.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < breakerThreshold {
+		return nil
+	}
+	now := b.now()
+	if now.Before(b.openUntil) {
+		return fmt.Errorf("%w: %w: the last %d requests failed, trying again in %s",
+			ErrUnreachable, ErrCircuitOpen, b.failures, b.openUntil.Sub(now).Round(time.Second))
+	}
+	if b.probing {
+		return fmt.Errorf("%w: %w: waiting for another request to check whether it's back", ErrUnreachable, ErrCircuitOpen)
+	}
+	b.probing = true
+	return nil
+}
+
+// record counts the outcome of a request allow let through.
+func (b *breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= breakerThreshold {
+		b.openUntil = b.now().Add(breakerCooldown)
+	}
+}
+
+// abandon lets another request check the server when one allow let through
+// was given up.
+func (b *breaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// roundTrip sends req with client through the server's circuit breaker. Any response
+// counts as the server being up; requests given up by the caller don't
+// count either way.
+func (c *APIClient) roundTrip(client *http.Client, req *http.Request) (*http.Response, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil && req.Context().Err() != nil {
+		c.breaker.abandon()
+		return nil, err
+	}
+	c.breaker.record(err != nil)
+	return resp, err
+}
@@ -0,0 +1,107 @@
+package apiclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+func newRetryTestClient(t *testing.T, url string) *APIClient {
+	t.Helper()
+	client, err := New(url, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	client.SetRetryPolicy(RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	return client
+}
+
+func TestRetriesTransientFailures(t *testing.T) {
+	var gets, posts atomic.Int32
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+		case "/v1/version":
+			if gets.Add(1) == 1 {
+				http.Error(w, "haloyd is restarting", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"version":"v1"}`))
+		case "/v1/deploy":
+			keys = append(keys, r.Header.Get(constants.HeaderIdempotencyKey))
+			if posts.Add(1) <= 2 {
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	client := newRetryTestClient(t, srv.URL)
+
+	var version struct{ Version string }
+	if err := client.Get(context.Background(), "version", &version); err != nil || version.Version != "v1" {
+		t.Fatalf("Get() = %+v, %v, want it to succeed on the retry", version, err)
+	}
+
+	// A POST might have been acted on, so it's only retried with a key.
+	if err := client.Post(context.Background(), "deploy", map[string]string{}, nil); err == nil {
+		t.Fatal("Post() error = nil, want the bad gateway")
+	}
+	if err := client.PostIdempotent(context.Background(), "deploy", "d1", map[string]string{}, nil); err != nil {
+		t.Fatalf("PostIdempotent() error = %v, want it to succeed on the retry", err)
+	}
+	if len(keys) != 3 || keys[0] != "" || keys[1] != "d1" || keys[2] != "d1" {
+		t.Errorf("idempotency keys sent = %q, want none, then d1 twice", keys)
+	}
+
+	// Errors retrying doesn't fix are returned right away.
+	if err := client.Get(context.Background(), "missing", &version); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a missing resource error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	client := newRetryTestClient(t, url)
+	client.SetRetryPolicy(RetryPolicy{MaxRetries: 10})
+	now := time.Now()
+	client.breaker.now = func() time.Time { return now }
+
+	// The third failed attempt opens the circuit, which ends the retries.
+	err := client.Get(context.Background(), "version", nil)
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrUnreachable) {
+		t.Fatalf("Get() error = %v, want ErrCircuitOpen wrapped with ErrUnreachable", err)
+	}
+
+	// Other clients of the server fail fast too.
+	other := newRetryTestClient(t, url)
+	if err := other.HealthCheck(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("HealthCheck() error = %v, want ErrCircuitOpen", err)
+	}
+
+	// After the cooldown a request checks whether the server is back, and
+	// opens the circuit again when it isn't.
+	now = now.Add(breakerCooldown)
+	if err := other.HealthCheck(context.Background()); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("HealthCheck() after the cooldown error = %v, want a connection error", err)
+	}
+	if err := other.HealthCheck(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("HealthCheck() after a failed check error = %v, want ErrCircuitOpen", err)
+	}
+
+	client.breaker.record(false)
+	if err := client.breaker.allow(); err != nil {
+		t.Errorf("allow() after a success error = %v, want the circuit closed", err)
+	}
+}
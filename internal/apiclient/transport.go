@@ -0,0 +1,187 @@
+package apiclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
+	"golang.org/x/time/rate"
+)
+
+// Timeouts bound how long the client waits for haloyd to answer, per class
+// of request. API is for regular requests, which haloyd answers in seconds.
+// Upload is for image and layer uploads, which haloyd answers once it has
+// verified or loaded what was sent, and Stall fails an upload that sent
+// nothing for that long, like one held up by a middlebox that stopped
+// forwarding the connection.
+type Timeouts struct {
+	API    time.Duration
+	Upload time.Duration
+	Stall  time.Duration
+}
+
+// DefaultTimeouts returns the timeouts of clients created with New, with
+// HALOY_API_TIMEOUT and HALOY_UPLOAD_TIMEOUT applied.
+func DefaultTimeouts() (Timeouts, error) {
+	timeouts := Timeouts{
+		API:    30 * time.Second,
+		Upload: 10 * time.Minute,
+		Stall:  2 * time.Minute,
+	}
+	for envVar, timeout := range map[string]*time.Duration{
+		constants.EnvVarAPITimeout:    &timeouts.API,
+		constants.EnvVarUploadTimeout: &timeouts.Upload,
+	} {
+		value := os.Getenv(envVar)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return Timeouts{}, fmt.Errorf("invalid %s %q: must be a duration like 30s or 5m", envVar, value)
+		}
+		*timeout = d
+	}
+	return timeouts, nil
+}
+
+// errUploadStalled cancels an upload that stopped sending data.
+var errUploadStalled = errors.New("upload stalled")
+
+// newTransport returns a transport that negotiates HTTP/2 with servers
+// behind TLS, so concurrent requests share one connection, unless
+// HALOY_API_HTTP2 is false. Idle connections are kept for the layers of an
+// upload and probed with TCP keepalives or HTTP/2 pings, which also keeps
+// NAT and firewall state from expiring during long uploads.
+func newTransport(responseHeaderTimeout time.Duration, writeBufferSize int) *http.Transport {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	if v, err := strconv.ParseBool(os.Getenv(constants.EnvVarAPIHTTP2)); err != nil || v {
+		protocols.SetHTTP2(true)
+	}
+
+	return &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 30 * time.Second,
+			KeepAliveConfig: net.KeepAliveConfig{
+				Enable:   true,
+				Idle:     15 * time.Second,
+				Interval: 15 * time.Second,
+				Count:    4,
+			},
+		}).DialContext,
+		Protocols: protocols,
+		HTTP2: &http.HTTP2Config{
+			SendPingTimeout: 30 * time.Second,
+			PingTimeout:     15 * time.Second,
+		},
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConns:          10,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		WriteBufferSize:       writeBufferSize,
+	}
+}
+
+// uploadWriteBufferSize is the write buffer of upload connections, larger
+// than the default 4KiB to need fewer syscalls per layer.
+const uploadWriteBufferSize = 256 << 10
+
+// SetUploadRateLimit limits uploads of the client to bytesPerSecond
+// together, or lifts the limit when it's 0.
+func (c *APIClient) SetUploadRateLimit(bytesPerSecond int64) {
+	if bytesPerSecond <= 0 {
+		c.uploadLimiter = nil
+		return
+	}
+	// Reads are cut to the burst, so it also bounds how far an upload gets
+	// ahead of the limit.
+	burst := int(min(bytesPerSecond, 64<<10))
+	c.uploadLimiter = rate.NewLimiter(rate.Limit(bytesPerSecond), burst)
+}
+
+// DoUpload executes a request that uploads its body, like Do but with the
+// upload timeouts and rate limit.
+func (c *APIClient) DoUpload(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(req.Context())
+	watchdog := time.AfterFunc(c.timeouts.Stall, func() {
+		cancel(fmt.Errorf("%w: nothing sent for %s", errUploadStalled, c.timeouts.Stall))
+	})
+
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &uploadBody{
+			ReadCloser: req.Body,
+			ctx:        ctx,
+			limiter:    c.uploadLimiter,
+			watchdog:   watchdog,
+			stall:      c.timeouts.Stall,
+		}
+	} else {
+		watchdog.Stop()
+	}
+	resp, err := c.roundTrip(c.uploadClient, req.WithContext(ctx))
+	// Once haloyd answered the body was sent, or won't be read anymore.
+	watchdog.Stop()
+	if err != nil {
+		cancel(nil)
+		if cause := context.Cause(ctx); errors.Is(cause, errUploadStalled) {
+			return nil, cause
+		}
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: func() { cancel(nil) }}
+	return resp, nil
+}
+
+// uploadBody is the body of an upload, read at the client's rate limit.
+// Every read shows the upload is progressing and restarts the watchdog,
+// which stops once the whole body was read, leaving the wait for haloyd's
+// answer to the upload timeout.
+type uploadBody struct {
+	io.ReadCloser
+	ctx      context.Context
+	limiter  *rate.Limiter
+	watchdog *time.Timer
+	stall    time.Duration
+}
+
+func (b *uploadBody) Read(p []byte) (int, error) {
+	b.watchdog.Reset(b.stall)
+	if b.limiter != nil && len(p) > b.limiter.Burst() {
+		p = p[:b.limiter.Burst()]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.watchdog.Stop()
+	}
+	if b.limiter != nil && n > 0 {
+		if waitErr := b.limiter.WaitN(b.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// cancelOnClose releases the context of a request when its response body
+// is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+	once   sync.Once
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.once.Do(c.cancel)
+	return err
+}
@@ -0,0 +1,123 @@
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+func newUploadTestClient(t *testing.T, url string, stall time.Duration) *APIClient {
+	t.Helper()
+	client, err := NewWithTimeouts(url, "", Timeouts{API: time.Second, Upload: time.Second, Stall: stall})
+	if err != nil {
+		t.Fatalf("NewWithTimeouts() error = %v", err)
+	}
+	return client
+}
+
+func upload(t *testing.T, client *APIClient, body io.Reader) error {
+	t.Helper()
+	req, err := client.NewRequest(context.Background(), http.MethodPost, "images/layers", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.DoUpload(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestDoUploadRateLimit(t *testing.T) {
+	var received int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = len(b)
+	}))
+	defer srv.Close()
+
+	client := newUploadTestClient(t, srv.URL, time.Second)
+	client.SetUploadRateLimit(1 << 20)
+
+	// The first 64KiB go out as a burst, the rest at 1MiB/s.
+	start := time.Now()
+	if err := upload(t, client, bytes.NewReader(make([]byte, 192<<10))); err != nil {
+		t.Fatalf("DoUpload() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("upload took %s, want the rate limit to slow it down", elapsed)
+	}
+	if received != 192<<10 {
+		t.Errorf("server received %d bytes, want %d", received, 192<<10)
+	}
+}
+
+func TestDoUploadStalled(t *testing.T) {
+	// A server that stops reading is like a middlebox that stopped
+	// forwarding the connection: once the socket buffers are full, the
+	// upload can't send anything.
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := newUploadTestClient(t, srv.URL, 50*time.Millisecond)
+	if err := upload(t, client, endless{}); !errors.Is(err, errUploadStalled) {
+		t.Errorf("DoUpload() error = %v, want errUploadStalled", err)
+	}
+
+	// Waiting for haloyd to answer after the body was sent isn't a stall.
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		time.Sleep(150 * time.Millisecond)
+	}))
+	defer slow.Close()
+	client = newUploadTestClient(t, slow.URL, 50*time.Millisecond)
+	if err := upload(t, client, bytes.NewReader([]byte("layer"))); err != nil {
+		t.Errorf("DoUpload() to a slow server error = %v", err)
+	}
+}
+
+func TestHTTP2(t *testing.T) {
+	var proto string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.Proto
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	// Clients talk plain HTTP to localhost, which haloyd serves without TLS.
+	trustServer := func(client *APIClient) {
+		certs := srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+		client.client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: certs}
+		client.baseURL = srv.URL
+	}
+
+	client := newUploadTestClient(t, srv.URL, time.Second)
+	trustServer(client)
+	if err := client.HealthCheck(context.Background()); err != nil || proto != "HTTP/2.0" {
+		t.Errorf("HealthCheck() = %v over %s, want HTTP/2.0", err, proto)
+	}
+
+	t.Setenv(constants.EnvVarAPIHTTP2, "false")
+	client = newUploadTestClient(t, srv.URL, time.Second)
+	trustServer(client)
+	if err := client.HealthCheck(context.Background()); err != nil || proto != "HTTP/1.1" {
+		t.Errorf("HealthCheck() with HTTP/2 off = %v over %s, want HTTP/1.1", err, proto)
+	}
+}
+
+// endless is a body that never ends.
+type endless struct{}
+
+func (endless) Read(p []byte) (int, error) { return len(p), nil }
@@ -1,8 +1,11 @@
 package apitypes
 
 import (
+	"time"
+
 	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/deploytypes"
+	"github.com/haloydev/haloy/internal/logging"
 )
 
 type HealthResponse struct {
@@ -16,12 +19,137 @@ type DeployRequest struct {
 	TargetConfig config.TargetConfig `json:"targetConfig"`
 	// DeployConfig without resolved secrets and with target extracted. Saved on server for rollbacks
 	RollbackDeployConfig config.DeployConfig `json:"rollbackDeployConfig"`
+	// Holder identifies who started the deployment, shown to others blocked by its lock.
+	Holder string `json:"holder,omitempty"`
+	// ForceUnlock takes over the app's deploy lock, canceling the deployment holding it.
+	ForceUnlock bool `json:"forceUnlock,omitempty"`
+	// ErrorPages maps error page file names (e.g. "502.html") to their templates.
+	// Empty removes pages uploaded by an earlier deploy.
+	ErrorPages map[string]string `json:"errorPages,omitempty"`
 }
 
 type RollbackRequest struct {
 	TargetDeploymentID string              `json:"targetDeploymentID"`
 	NewDeploymentID    string              `json:"newDeploymentID"`
 	NewTargetConfig    config.TargetConfig `json:"newTargetConfig"`
+	Holder             string              `json:"holder,omitempty"`
+	ForceUnlock        bool                `json:"forceUnlock,omitempty"`
+}
+
+// DeployLockInfo describes the deployment currently holding an app's deploy lock.
+type DeployLockInfo struct {
+	App          string    `json:"app"`
+	DeploymentID string    `json:"deploymentId"`
+	Holder       string    `json:"holder,omitempty"`
+	AcquiredAt   time.Time `json:"acquiredAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// DeployLockedResponse is returned with 409 Conflict when an app is already being deployed.
+type DeployLockedResponse struct {
+	Error string         `json:"error"`
+	Lock  DeployLockInfo `json:"lock"`
+}
+
+// SessionResponse is a session token exchanged for the API token. Requests
+// authorized with the session cookie rather than the token must send
+// CSRFToken in the X-CSRF-Token header, unless they only read.
+type SessionResponse struct {
+	Token     string    `json:"token"`
+	CSRFToken string    `json:"csrfToken"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+const (
+	CredentialAPIToken = "api-token"
+	CredentialSession  = "session"
+	// ScopeAll is the scope of credentials that may use every endpoint.
+	ScopeAll = "*"
+)
+
+// WhoAmIResponse describes the credentials a request was made with.
+type WhoAmIResponse struct {
+	// Type is CredentialAPIToken or CredentialSession.
+	Type   string   `json:"type"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is unset for credentials that don't expire.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// MigrationLockInfo describes the holder of a migration lock.
+type MigrationLockInfo struct {
+	Name       string    `json:"name"`
+	Holder     string    `json:"holder"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// MigrationLockResponse is returned by an acquire: with 200 OK when the
+// caller holds the lock, with 409 Conflict when someone else does.
+type MigrationLockResponse struct {
+	Acquired bool              `json:"acquired"`
+	Lock     MigrationLockInfo `json:"lock"`
+}
+
+type MigrationLocksResponse struct {
+	Locks []MigrationLockInfo `json:"locks"`
+}
+
+// DatabaseBackup is a backup of a managed database in the backup store.
+type DatabaseBackup struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type DatabaseBackupsResponse struct {
+	Backups []DatabaseBackup `json:"backups"`
+}
+
+// DatabaseBackupRequest starts a backup. Its progress is streamed like a
+// deployment's, from /v1/deploy/{operationId}/logs.
+type DatabaseBackupRequest struct {
+	OperationID string `json:"operationId"`
+}
+
+// DatabaseRestoreRequest starts a restore of the named backup. Its progress
+// is streamed like a backup's.
+type DatabaseRestoreRequest struct {
+	OperationID string `json:"operationId"`
+	Backup      string `json:"backup"`
+	// SourceApp restores a backup of another app's database, cloning it.
+	SourceApp string `json:"sourceApp,omitempty"`
+	// Anonymize is SQL run against a clone after the restore.
+	Anonymize string `json:"anonymize,omitempty"`
+}
+
+// VolumeSnapshot is a snapshot of an app's named volumes, kept on the server,
+// in the target's snapshots.remote, or both.
+type VolumeSnapshot struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+	Local     bool      `json:"local"`
+	Remote    bool      `json:"remote"`
+}
+
+type VolumeSnapshotsResponse struct {
+	Snapshots []VolumeSnapshot `json:"snapshots"`
+}
+
+// VolumeSnapshotRestoreRequest restores a snapshot of SourceApp into new
+// volumes of the app in the path. Its progress is streamed like a deploy's.
+type VolumeSnapshotRestoreRequest struct {
+	OperationID string `json:"operationId"`
+	SourceApp   string `json:"sourceApp"`
+	Snapshot    string `json:"snapshot"`
+	// Remote is the source target's snapshots.remote.
+	Remote string `json:"remote,omitempty"`
+	// Volumes maps the snapshot's volume names to the ones to create.
+	Volumes map[string]string `json:"volumes"`
+	// Image of the new target, used for the helper container when no
+	// container of the source app is on the server.
+	Image config.Image `json:"image"`
 }
 
 type RollbackTargetsResponse struct {
@@ -52,6 +180,271 @@ type AppStatusResponse struct {
 	DeploymentID string          `json:"deploymentId"`
 	ContainerIDs []string        `json:"containerIds"`
 	Domains      []config.Domain `json:"domains"`
+	// Restarts counts the recent restarts of the containers by Docker's
+	// restart policy. CrashLooping lists the containers that restarted often
+	// enough to be taken out of rotation.
+	Restarts     int      `json:"restarts,omitempty"`
+	CrashLooping []string `json:"crashLooping,omitempty"`
+	// DeploymentState is where the running deployment is in its lifecycle,
+	// like live, or empty for deployments haloyd didn't track.
+	DeploymentState string `json:"deploymentState,omitempty"`
+}
+
+// DeployResponse is returned with 202 Accepted once a deployment started.
+type DeployResponse struct {
+	DeploymentID string `json:"deploymentId"`
+}
+
+// CancelDeploymentResponse is returned by a cancel: with 202 Accepted while
+// the deployment stops preparing or starting its containers, with 200 OK once
+// the containers it started were removed.
+type CancelDeploymentResponse struct {
+	DeploymentID string `json:"deploymentId"`
+	App          string `json:"app"`
+}
+
+// Reconcile actions: what POST /v1/reconcile did, or would do on a dry run,
+// for an app.
+const (
+	ReconcileOK        = "ok"        // the live deployment has all its containers
+	ReconcilePaused    = "paused"    // the app was stopped and is left alone
+	ReconcileMissing   = "missing"   // containers are missing, dry run
+	ReconcileRecreated = "recreated" // the app was deployed again from its spec
+	ReconcileFailed    = "failed"
+)
+
+// ReconcileResponse lists the apps with a live deployment and what reconcile
+// did for each.
+type ReconcileResponse struct {
+	Apps []ReconciledApp `json:"apps"`
+}
+
+// ReconciledApp is the desired state of an app, as its live deployment was
+// started, against the containers it has.
+type ReconciledApp struct {
+	App          string   `json:"app"`
+	DeploymentID string   `json:"deploymentId"`
+	Image        string   `json:"image"`
+	Replicas     int      `json:"replicas"`
+	Domains      []string `json:"domains,omitempty"`
+	EnvNames     []string `json:"envNames,omitempty"`
+	// Containers counts the containers of the live deployment, running or
+	// not.
+	Containers int    `json:"containers"`
+	Action     string `json:"action"`
+	// NewDeploymentID is the deployment that recreated the app.
+	NewDeploymentID string `json:"newDeploymentId,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// DeploymentLogsResponse is a page of the log a deployment streamed, oldest
+// first. Next is the cursor of the following page, passed back as the after
+// query parameter, and is zero on the last page.
+type DeploymentLogsResponse struct {
+	DeploymentID string             `json:"deploymentId"`
+	Entries      []logging.LogEntry `json:"entries"`
+	Next         int64              `json:"next,omitempty"`
+}
+
+// DeploymentStatusResponse is the lifecycle of a deployment: its current
+// state and the transitions that led there.
+type DeploymentStatusResponse struct {
+	DeploymentID string                 `json:"deploymentId"`
+	App          string                 `json:"app"`
+	State        string                 `json:"state"`
+	Message      string                 `json:"message,omitempty"`
+	UpdatedAt    time.Time              `json:"updatedAt"`
+	History      []DeploymentStateEvent `json:"history"`
+}
+
+// DeploymentStateEvent is a deployment entering State.
+type DeploymentStateEvent struct {
+	State   string    `json:"state"`
+	Message string    `json:"message,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// StartAppResponse lists the containers started to bring a stopped app back.
+type StartAppResponse struct {
+	DeploymentID string   `json:"deploymentId"`
+	ContainerIDs []string `json:"containerIds"`
+}
+
+// DestroyAppRequest controls what haloy destroy removes besides the app's
+// containers, images and deployment history.
+type DestroyAppRequest struct {
+	KeepVolumes        bool `json:"keepVolumes"`
+	RemoveCertificates bool `json:"removeCertificates"`
+}
+
+// Preview is a per-branch deployment made by haloy preview create.
+type Preview struct {
+	App          string    `json:"app"`
+	Branch       string    `json:"branch"`
+	DeploymentID string    `json:"deploymentId"`
+	Domains      []string  `json:"domains"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+type PreviewsResponse struct {
+	Previews []Preview `json:"previews"`
+}
+
+// GitOpsStatusResponse reports what haloyd last fetched from the GitOps
+// repository and how the apps it declares were applied.
+type GitOpsStatusResponse struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	// Commit is the commit of the last successful fetch.
+	Commit   string    `json:"commit,omitempty"`
+	LastSync time.Time `json:"lastSync"`
+	// Error is why the last sync couldn't read the repository, if it failed.
+	Error       string      `json:"error,omitempty"`
+	Paused      bool        `json:"paused"`
+	PausedAt    time.Time   `json:"pausedAt"`
+	PauseReason string      `json:"pauseReason,omitempty"`
+	Apps        []GitOpsApp `json:"apps"`
+}
+
+// GitOps app states reported in GitOpsApp.State.
+const (
+	GitOpsAppSynced  = "synced"
+	GitOpsAppPending = "pending" // declared but not applied yet, e.g. while paused
+	GitOpsAppFailed  = "failed"
+	// GitOpsAppRemoving is an app removed from the repository that's
+	// destroyed on the next sync.
+	GitOpsAppRemoving = "removing"
+)
+
+// GitOpsApp is an app the GitOps repository declares for the server.
+type GitOpsApp struct {
+	App    string `json:"app"`
+	Target string `json:"target"`
+	State  string `json:"state"`
+	// Commit and DeploymentID are of the last deploy from the repository.
+	Commit       string    `json:"commit,omitempty"`
+	DeploymentID string    `json:"deploymentId,omitempty"`
+	AppliedAt    time.Time `json:"appliedAt"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type GitOpsPauseRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ServerUpgradeRequest schedules an upgrade of haloyd and haloy-proxy to the
+// latest release.
+type ServerUpgradeRequest struct {
+	// Schedule is the start of the window, "[day] HH:MM" as in "Sat 03:00".
+	Schedule string `json:"schedule,omitempty"`
+	// Now starts the upgrade right away instead of at Schedule.
+	Now bool `json:"now,omitempty"`
+	// Timezone is the IANA time zone of Schedule, UTC when empty.
+	Timezone string `json:"timezone,omitempty"`
+	// Window is how long after its start the upgrade may still begin, as a
+	// Go duration. The server's default applies when empty.
+	Window string `json:"window,omitempty"`
+	// NotifyURL, when set, is sent a POST with the ServerUpgrade once the
+	// upgrade completed or failed.
+	NotifyURL string `json:"notifyUrl,omitempty"`
+}
+
+// ServerUpgradeResponse is the latest upgrade scheduled on a server, nil if
+// there was none.
+type ServerUpgradeResponse struct {
+	Upgrade *ServerUpgrade `json:"upgrade"`
+}
+
+// ServerUpgrade is a scheduled server upgrade. States are scheduled, running,
+// completed, failed, missed and canceled.
+type ServerUpgrade struct {
+	Schedule    string    `json:"schedule"`
+	Timezone    string    `json:"timezone"`
+	StartAt     time.Time `json:"startAt"`
+	Deadline    time.Time `json:"deadline"`
+	State       string    `json:"state"`
+	FromVersion string    `json:"fromVersion,omitempty"`
+	ToVersion   string    `json:"toVersion,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	Server      string    `json:"server,omitempty"` // Host name, set in notifications
+}
+
+// AppSpecRequest is the desired state of an app for PUT /v1/apps/{name} and
+// its plan. Spec is a target config without the fields only the CLI uses,
+// like server and hooks.
+type AppSpecRequest struct {
+	Spec config.TargetConfig `json:"spec"`
+	// ErrorPages maps error page file names (e.g. "502.html") to their templates.
+	ErrorPages map[string]string `json:"errorPages,omitempty"`
+}
+
+// App states reported in AppResource.Status.
+const (
+	AppStatusDeploying = "deploying"
+	AppStatusRunning   = "running"
+	AppStatusFailed    = "failed"
+	AppStatusStopped   = "stopped" // stopped with haloy stop
+	AppStatusDrifted   = "drifted" // the running containers aren't from the applied spec, or none run
+)
+
+// AppResource is the state of an app managed through the apps API.
+type AppResource struct {
+	Name string `json:"name"`
+	// Spec is the applied spec with defaults filled in.
+	Spec         config.TargetConfig `json:"spec"`
+	SpecHash     string              `json:"specHash"`
+	DeploymentID string              `json:"deploymentId"`
+	Status       string              `json:"status"`
+	Error        string              `json:"error,omitempty"`
+	UpdatedAt    time.Time           `json:"updatedAt"`
+}
+
+// App plan actions reported in AppPlanResponse.Action.
+const (
+	AppPlanCreate   = "create"
+	AppPlanUpdate   = "update"
+	AppPlanRedeploy = "redeploy" // the spec is unchanged, but the app isn't running it
+	AppPlanNone     = "none"
+)
+
+// AppPlanResponse describes what applying a spec would do. Fields lists the
+// spec fields that differ from the applied spec.
+type AppPlanResponse struct {
+	Action   string   `json:"action"`
+	Fields   []string `json:"fields,omitempty"`
+	SpecHash string   `json:"specHash"`
+}
+
+// DestroyAppResponse reports everything removed for a destroyed app.
+type DestroyAppResponse struct {
+	Containers   []string `json:"containers"`
+	Domains      []string `json:"domains"`
+	Certificates []string `json:"certificates"`
+	Images       []string `json:"images"`
+	Deployments  int64    `json:"deployments"`
+	Volumes      []string `json:"volumes"`
+}
+
+// AppInspectResponse describes how the latest deployment of an app is
+// actually running, for comparison against the local configuration.
+type AppInspectResponse struct {
+	DeploymentID string `json:"deploymentId"`
+	// Image is the image reference the deployment was configured with. Falls
+	// back to the container's image when no deployment record exists.
+	Image string `json:"image"`
+	// Replicas counts the running containers of the deployment.
+	Replicas int             `json:"replicas"`
+	Domains  []config.Domain `json:"domains"`
+	EnvNames []string        `json:"envNames"`
+	// ImageEnvNames are env vars defined by the image itself.
+	ImageEnvNames []string          `json:"imageEnvNames"`
+	Labels        map[string]string `json:"labels"`
+	Volumes       []string          `json:"volumes"`
+	Network       string            `json:"network"`
+	// NanoCPUs and MemoryBytes are the container resource limits; 0 means unlimited.
+	NanoCPUs    int64 `json:"nanoCpus,omitempty"`
+	MemoryBytes int64 `json:"memoryBytes,omitempty"`
 }
 
 type ImageUploadResponse struct {
@@ -60,15 +453,18 @@ type ImageUploadResponse struct {
 }
 
 type VersionResponse struct {
-	Version                    string   `json:"haloyd"`
-	ProxyVersion               string   `json:"haloy_proxy,omitempty"`
-	ProxyGeneration            int      `json:"haloy_proxy_generation,omitempty"`
-	RequiredProxyGeneration    int      `json:"haloy_proxy_required_generation,omitempty"`
-	ProxySchemaVersion         int      `json:"haloy_proxy_schema_version,omitempty"`
-	RequiredProxySchemaVersion int      `json:"haloy_proxy_required_schema_version,omitempty"`
-	ProxyCompatible            *bool    `json:"haloy_proxy_compatible,omitempty"`
-	ProxyConfigHash            string   `json:"haloy_proxy_config_hash,omitempty"`
-	Capabilities               []string `json:"capabilities,omitempty"`
+	Version                    string `json:"haloyd"`
+	ProxyVersion               string `json:"haloy_proxy,omitempty"`
+	ProxyGeneration            int    `json:"haloy_proxy_generation,omitempty"`
+	RequiredProxyGeneration    int    `json:"haloy_proxy_required_generation,omitempty"`
+	ProxySchemaVersion         int    `json:"haloy_proxy_schema_version,omitempty"`
+	RequiredProxySchemaVersion int    `json:"haloy_proxy_required_schema_version,omitempty"`
+	ProxyCompatible            *bool  `json:"haloy_proxy_compatible,omitempty"`
+	ProxyConfigHash            string `json:"haloy_proxy_config_hash,omitempty"`
+	// ProxyListenersDown are the proxy listeners that died and haven't been
+	// rebound yet, as "protocol address".
+	ProxyListenersDown []string `json:"haloy_proxy_listeners_down,omitempty"`
+	Capabilities       []string `json:"capabilities,omitempty"`
 }
 
 type RegistryLoginRequest struct {
@@ -90,6 +486,29 @@ type RegistriesResponse struct {
 	Registries []RegistryEntry `json:"registries"`
 }
 
+type SecretSetRequest struct {
+	Value string `json:"value"`
+}
+
+// SecretEntry describes a server secret. Values never leave the server.
+type SecretEntry struct {
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Apps whose latest deployment references the secret.
+	Apps []string `json:"apps"`
+}
+
+type SecretsResponse struct {
+	Secrets []SecretEntry `json:"secrets"`
+}
+
+// SecretSetResponse lists the apps that need a redeploy for the running
+// containers to get the new value.
+type SecretSetResponse struct {
+	SecretEntry
+	Created bool `json:"created"`
+}
+
 type ExecRequest struct {
 	Command       []string `json:"command"`                 // Required: command to execute
 	ContainerID   string   `json:"containerId,omitempty"`   // Optional: specific container ID
@@ -108,6 +527,165 @@ type ExecResponse struct {
 	Results []ExecResult `json:"results"`
 }
 
+// ContainerStats is a resource usage sample of a single app container.
+type ContainerStats struct {
+	ContainerID   string  `json:"containerId"`
+	CPUPercent    float64 `json:"cpuPercent"`
+	MemoryUsage   uint64  `json:"memoryUsage"`
+	MemoryLimit   uint64  `json:"memoryLimit"`
+	MemoryPercent float64 `json:"memoryPercent"`
+	NetRx         uint64  `json:"netRx"`
+	NetTx         uint64  `json:"netTx"`
+	BlockRead     uint64  `json:"blockRead"`
+	BlockWrite    uint64  `json:"blockWrite"`
+	PIDs          uint64  `json:"pids"`
+	Error         string  `json:"error,omitempty"` // Set if stats could not be read for this container
+}
+
+// AppStatsEvent is one refresh of the 'haloy top' stream.
+type AppStatsEvent struct {
+	Timestamp  time.Time        `json:"timestamp"`
+	Containers []ContainerStats `json:"containers"`
+}
+
+// AppUsagePoint is the CPU and memory usage of an app's running containers
+// together over the period starting at Time.
+type AppUsagePoint struct {
+	Time       time.Time `json:"time"`
+	PeriodSecs int64     `json:"periodSecs"`
+	CPUPercent float64   `json:"cpuPercent"` // Average, as a percentage of one CPU
+	CPUMax     float64   `json:"cpuMax"`
+	MemoryAvg  int64     `json:"memoryAvg"`
+	MemoryMax  int64     `json:"memoryMax"`
+	// MemoryLimit is the sum of the containers' limits.
+	MemoryLimit int64 `json:"memoryLimit"`
+	Containers  int   `json:"containers"`
+}
+
+// AppUsageHistoryResponse is the recorded usage of an app, oldest first.
+type AppUsageHistoryResponse struct {
+	Points []AppUsagePoint `json:"points"`
+}
+
+// UptimeWindow is the availability of an app over a number of days,
+// measured against the objective. Uptime is the share of monitored minutes
+// the app had a healthy container, and SuccessRate the share of requests the
+// proxy served without an error; both are percentages, 100 when nothing was
+// measured.
+type UptimeWindow struct {
+	Days        int     `json:"days"`
+	Minutes     int64   `json:"minutes"`
+	DownMinutes int64   `json:"downMinutes"`
+	Requests    int64   `json:"requests"`
+	Errors      int64   `json:"errors"`
+	Uptime      float64 `json:"uptime"`
+	SuccessRate float64 `json:"successRate"`
+	// BudgetRemaining is the percentage of the error budget left, negative
+	// once it's overspent.
+	BudgetRemaining float64 `json:"budgetRemaining"`
+}
+
+// UptimeDay is the availability of an app over one UTC day.
+type UptimeDay struct {
+	Date        string  `json:"date"` // YYYY-MM-DD
+	Minutes     int64   `json:"minutes"`
+	DownMinutes int64   `json:"downMinutes"`
+	Requests    int64   `json:"requests"`
+	Errors      int64   `json:"errors"`
+	Uptime      float64 `json:"uptime"`
+	SuccessRate float64 `json:"successRate"`
+}
+
+type AppUptimeResponse struct {
+	Objective float64        `json:"objective"` // In percent, like 99.9
+	Windows   []UptimeWindow `json:"windows"`
+	Days      []UptimeDay    `json:"days"` // Oldest first
+}
+
+// UptimeAlert is the notification haloyd POSTs when an app burns through
+// its error budget faster than the alert's burn rate, and again once it
+// doesn't anymore.
+type UptimeAlert struct {
+	Event     string    `json:"event"` // "error_budget_burn" or "error_budget_burn_resolved"
+	App       string    `json:"app"`
+	Server    string    `json:"server"`
+	Time      time.Time `json:"time"`
+	Objective float64   `json:"objective"`
+	// BurnRate is how many times faster than the objective allows the error
+	// budget was used over the last hour, and Threshold the alert's rate.
+	BurnRate    float64 `json:"burnRate"`
+	Threshold   float64 `json:"threshold"`
+	Minutes     int64   `json:"minutes"`
+	DownMinutes int64   `json:"downMinutes"`
+	Requests    int64   `json:"requests"`
+	Errors      int64   `json:"errors"`
+}
+
+// TrafficAlert is the notification haloyd POSTs when an app starts or stops
+// breaking an alert rule, in the webhook format.
+type TrafficAlert struct {
+	Event  string    `json:"event"` // "alert_firing" or "alert_resolved"
+	Rule   string    `json:"rule"`
+	App    string    `json:"app"`
+	Server string    `json:"server"`
+	Time   time.Time `json:"time"`
+	Window string    `json:"window"`
+	// What the app's requests over the window measured, and the rule's
+	// thresholds; a threshold the rule doesn't check is left out.
+	Requests              int64   `json:"requests"`
+	Errors                int64   `json:"errors"`
+	ErrorRate             float64 `json:"errorRate"` // Percent
+	P95LatencyMs          int64   `json:"p95LatencyMs"`
+	ErrorRateThreshold    float64 `json:"errorRateThreshold,omitempty"`
+	P95LatencyThresholdMs int64   `json:"p95LatencyThresholdMs,omitempty"`
+	// Backends breaks the requests down by container.
+	Backends []TrafficAlertBackend `json:"backends"`
+	// SampleRequests are some of the requests that failed in the window,
+	// newest first.
+	SampleRequests []TrafficAlertRequest `json:"sampleRequests"`
+}
+
+type TrafficAlertBackend struct {
+	Address      string `json:"address"`
+	Requests     int64  `json:"requests"`
+	Errors       int64  `json:"errors"`
+	P95LatencyMs int64  `json:"p95LatencyMs"`
+}
+
+type TrafficAlertRequest struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId,omitempty"`
+	Status    int       `json:"status"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Backend   string    `json:"backend"`
+}
+
+// ContainerPing reports how long haloyd took to reach the health check of a
+// single app container.
+type ContainerPing struct {
+	ContainerID string `json:"containerId"`
+	Check       string `json:"check"` // What was checked, like "GET 172.18.0.5:8080/health"
+	// LatenciesMs are the durations of the checks that succeeded.
+	LatenciesMs []float64 `json:"latenciesMs"`
+	Failed      int       `json:"failed"`
+	Error       string    `json:"error,omitempty"` // Why the last failed check failed
+}
+
+type AppPingResponse struct {
+	Containers []ContainerPing `json:"containers"`
+}
+
+// CopyResult reports the outcome of copying into a single container.
+type CopyResult struct {
+	ContainerID string `json:"containerId"`
+	Error       string `json:"error,omitempty"`
+}
+
+type CopyToContainerResponse struct {
+	Results []CopyResult `json:"results"`
+}
+
 // LayerCheckRequest is sent by client to query which layers already exist on server
 type LayerCheckRequest struct {
 	Digests []string `json:"digests"`
@@ -125,6 +703,15 @@ type LayerUploadResponse struct {
 	Size   int64  `json:"size"`
 }
 
+// LayerUploadStatusResponse reports the progress of a resumable layer upload.
+// Offset is the number of bytes the server holds; Complete is set once the
+// layer has been verified and stored.
+type LayerUploadStatusResponse struct {
+	Digest   string `json:"digest"`
+	Offset   int64  `json:"offset"`
+	Complete bool   `json:"complete"`
+}
+
 // ImageManifestEntry represents one entry from docker save manifest.json
 type ImageManifestEntry struct {
 	Config   string   `json:"Config"`
@@ -139,6 +726,24 @@ type ImageAssembleRequest struct {
 	Manifest ImageManifestEntry `json:"manifest"`
 }
 
+// ImageCheckRequest asks which images the server already has, by the ID
+// (config digest) they have on the client.
+type ImageCheckRequest struct {
+	Images []ImageCheckEntry `json:"images"`
+}
+
+type ImageCheckEntry struct {
+	ImageRef string `json:"imageRef"`
+	ImageID  string `json:"imageId"`
+}
+
+// ImageCheckResponse lists the refs of the images the server has, tagged as
+// requested, and those that must be uploaded.
+type ImageCheckResponse struct {
+	Present []string `json:"present"`
+	Missing []string `json:"missing"`
+}
+
 // ImageAssembleResponse confirms image was loaded
 type ImageAssembleResponse struct {
 	Success bool   `json:"success"`
@@ -151,9 +756,119 @@ type ImageDiskSpaceCheckRequest struct {
 	AssembledImageSizeBytes uint64 `json:"assembledImageSizeBytes,omitempty"`
 }
 
+// DiskUsageResponse reports what takes up disk space on the server and how
+// much is left on the filesystems Docker and haloyd write to.
+type DiskUsageResponse struct {
+	Categories  []DiskUsageCategory `json:"categories"`
+	Filesystems []FilesystemUsage   `json:"filesystems"`
+	// MinFreeBytes is the free space deploys require; 0 means the check is off.
+	MinFreeBytes uint64 `json:"minFreeBytes"`
+}
+
+type DiskUsageCategory struct {
+	Name             string `json:"name"`
+	Path             string `json:"path,omitempty"`
+	Count            int    `json:"count,omitempty"`
+	SizeBytes        uint64 `json:"sizeBytes"`
+	ReclaimableBytes uint64 `json:"reclaimableBytes,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+type FilesystemUsage struct {
+	Path           string   `json:"path"`
+	UsedBy         []string `json:"usedBy"`
+	TotalBytes     uint64   `json:"totalBytes"`
+	AvailableBytes uint64   `json:"availableBytes"`
+}
+
 type ImageDiskSpaceCheckResponse struct {
 	OK             bool   `json:"ok"`
 	Path           string `json:"path"`
 	RequiredBytes  uint64 `json:"requiredBytes"`
 	AvailableBytes uint64 `json:"availableBytes"`
 }
+
+// CertificatesResponse lists the server's certificates and the issuance
+// state of their domains.
+type CertificatesResponse struct {
+	Certificates []CertificateStatus `json:"certificates"`
+}
+
+// CertificateStatus is the certificate of one canonical domain. The
+// certificate fields are empty for domains that never got one, and the
+// issuance fields are empty unless the latest attempts failed.
+type CertificateStatus struct {
+	Domain   string     `json:"domain"`
+	Names    []string   `json:"names,omitempty"`
+	NotAfter *time.Time `json:"notAfter,omitempty"`
+	Staging  bool       `json:"staging,omitempty"`
+
+	Failures      int        `json:"failures,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	RateLimited   bool       `json:"rateLimited,omitempty"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+}
+
+// ProxyRoutesResponse is the routing table haloy-proxy is serving.
+type ProxyRoutesResponse struct {
+	ConfigHash string       `json:"configHash"`
+	Routes     []ProxyRoute `json:"routes"`
+	// Backends is the traffic the proxy sent to each backend, when the proxy
+	// reports it.
+	Backends []ProxyBackend `json:"backends,omitempty"`
+}
+
+// Backend states reported in ProxyBackend.State.
+const (
+	BackendOK      = "ok"
+	BackendFlagged = "flagged" // over the passive health thresholds
+	BackendEjected = "ejected" // taken out of rotation for a while
+)
+
+// ProxyBackend is the traffic the proxy sent to one backend. ErrorRate and
+// P95LatencyMS cover its most recent requests.
+type ProxyBackend struct {
+	Address      string  `json:"address"`
+	InFlight     int64   `json:"inFlight"`
+	Requests     uint64  `json:"requests"`
+	Errors       uint64  `json:"errors"`
+	ErrorRate    float64 `json:"errorRate"`
+	P95LatencyMS int64   `json:"p95LatencyMs"`
+	State        string  `json:"state"`
+}
+
+// Route health states reported in ProxyRoute.Health.
+const (
+	RouteHealthy  = "healthy"
+	RouteDegraded = "degraded" // some of the app's containers are unhealthy or ejected
+	RouteDown     = "down"     // no backends, the proxy answers 502
+)
+
+// ProxyRoute is one route of the proxy: requests for Domain or one of its
+// Aliases, under PathPrefix if set, go to the Backends of App.
+type ProxyRoute struct {
+	Domain     string   `json:"domain"`
+	Aliases    []string `json:"aliases,omitempty"`
+	PathPrefix string   `json:"pathPrefix,omitempty"`
+	App        string   `json:"app,omitempty"`
+	Backends   []string `json:"backends"`
+	Health     string   `json:"health,omitempty"`
+}
+
+// ProxyRoutesDryRunResponse lists how the proxy's routes would change if the
+// routes for the current deployment state were pushed to it.
+type ProxyRoutesDryRunResponse struct {
+	LiveConfigHash    string             `json:"liveConfigHash"`
+	PlannedConfigHash string             `json:"plannedConfigHash"`
+	Added             []ProxyRoute       `json:"added"`
+	Removed           []ProxyRoute       `json:"removed"`
+	Changed           []ProxyRouteChange `json:"changed"`
+}
+
+// ProxyRouteChange is a route whose settings would change. Fields names the
+// settings that differ.
+type ProxyRouteChange struct {
+	From   ProxyRoute `json:"from"`
+	To     ProxyRoute `json:"to"`
+	Fields []string   `json:"fields"`
+}
@@ -0,0 +1,168 @@
+// Package bundle reads and writes offline deployment bundles. A bundle is a
+// gzip-compressed tar holding everything haloyd needs to deploy a target
+// without reaching a registry: the deploy request with its resolved config
+// and a 'docker save' archive of the images it runs.
+//
+// Entries are written in a fixed order, metadata first and images last, so a
+// reader can validate the bundle and stream the images into Docker without
+// unpacking it to disk.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// FormatVersion is bumped when the bundle layout changes incompatibly.
+const FormatVersion = 1
+
+const (
+	manifestEntry = "manifest.json"
+	deployEntry   = "deploy.json"
+	imagesEntry   = "images.tar"
+)
+
+// Manifest describes a bundle.
+type Manifest struct {
+	Version      int       `json:"version"`
+	HaloyVersion string    `json:"haloyVersion"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Target       string    `json:"target"`
+	App          string    `json:"app"`
+	// Images are the references saved in the image archive.
+	Images     []string `json:"images"`
+	ImagesSize int64    `json:"imagesSize"`
+}
+
+// Write writes a bundle to w. images is a 'docker save' archive of
+// m.Images, m.ImagesSize bytes long. The request's DeploymentID is left for
+// the importer to assign.
+func Write(w io.Writer, m Manifest, req apitypes.DeployRequest, images io.Reader) error {
+	m.Version = FormatVersion
+	if m.HaloyVersion == "" {
+		m.HaloyVersion = constants.Version
+	}
+	req.DeploymentID = ""
+
+	manifestData, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle manifest: %w", err)
+	}
+	deployData, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal deploy request: %w", err)
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	modTime := m.CreatedAt
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	writeEntry := func(name string, size int64, r io.Reader) error {
+		hdr := &tar.Header{
+			Name:     name,
+			Mode:     0o600,
+			Size:     size,
+			ModTime:  modTime,
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		n, err := io.Copy(tw, r)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if n != size {
+			return fmt.Errorf("failed to write %s: got %d bytes, expected %d", name, n, size)
+		}
+		return nil
+	}
+
+	if err := writeEntry(manifestEntry, int64(len(manifestData)), bytes.NewReader(manifestData)); err != nil {
+		return err
+	}
+	if err := writeEntry(deployEntry, int64(len(deployData)), bytes.NewReader(deployData)); err != nil {
+		return err
+	}
+	if err := writeEntry(imagesEntry, m.ImagesSize, images); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	return nil
+}
+
+// Read reads a bundle from r and calls load with its manifest, deploy
+// request and image archive. load must consume the archive before returning.
+func Read(r io.Reader, load func(m Manifest, req apitypes.DeployRequest, images io.Reader) error) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("not a haloy bundle: %w", err)
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+
+	var m Manifest
+	if err := readJSONEntry(tr, manifestEntry, &m); err != nil {
+		return err
+	}
+	if m.Version != FormatVersion {
+		return fmt.Errorf("bundle format version %d is not supported (expected %d); create it with a matching haloy version", m.Version, FormatVersion)
+	}
+
+	var req apitypes.DeployRequest
+	if err := readJSONEntry(tr, deployEntry, &req); err != nil {
+		return err
+	}
+
+	if _, err := nextEntry(tr, imagesEntry); err != nil {
+		return err
+	}
+	return load(m, req, tr)
+}
+
+func readJSONEntry(tr *tar.Reader, name string, v any) error {
+	hdr, err := nextEntry(tr, name)
+	if err != nil {
+		return err
+	}
+	// Metadata entries are small; the limit guards against a crafted header.
+	if hdr.Size > 64<<20 {
+		return fmt.Errorf("bundle entry %s is too large", name)
+	}
+	if err := json.NewDecoder(tr).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse bundle entry %s: %w", name, err)
+	}
+	return nil
+}
+
+func nextEntry(tr *tar.Reader, name string) (*tar.Header, error) {
+	hdr, err := tr.Next()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("bundle is missing %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	if hdr.Name != name {
+		return nil, fmt.Errorf("unexpected bundle entry %q, expected %s", hdr.Name, name)
+	}
+	return hdr, nil
+}
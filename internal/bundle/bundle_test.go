@@ -0,0 +1,119 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestWriteRead(t *testing.T) {
+	images := []byte("docker save archive")
+	m := Manifest{Target: "prod", App: "web", Images: []string{"web:abc"}, ImagesSize: int64(len(images))}
+	req := apitypes.DeployRequest{
+		DeploymentID: "should-be-dropped",
+		TargetConfig: config.TargetConfig{Name: "web", Server: "example.com"},
+		ErrorPages:   map[string]string{"502.html": "<h1>down</h1>"},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, m, req, bytes.NewReader(images)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var called bool
+	err := Read(&buf, func(gotM Manifest, gotReq apitypes.DeployRequest, gotImages io.Reader) error {
+		called = true
+		if gotM.Version != FormatVersion || gotM.App != "web" || gotM.Target != "prod" || gotM.HaloyVersion == "" {
+			t.Errorf("manifest = %+v", gotM)
+		}
+		if gotReq.DeploymentID != "" {
+			t.Errorf("DeploymentID = %q, want empty", gotReq.DeploymentID)
+		}
+		if gotReq.TargetConfig.Name != "web" || gotReq.ErrorPages["502.html"] != "<h1>down</h1>" {
+			t.Errorf("request = %+v", gotReq)
+		}
+		data, err := io.ReadAll(gotImages)
+		if err != nil {
+			t.Fatalf("read images: %v", err)
+		}
+		if !bytes.Equal(data, images) {
+			t.Errorf("images = %q, want %q", data, images)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !called {
+		t.Fatal("load was not called")
+	}
+}
+
+func TestWriteRejectsShortImageArchive(t *testing.T) {
+	m := Manifest{App: "web", ImagesSize: 100}
+	err := Write(io.Discard, m, apitypes.DeployRequest{}, strings.NewReader("short"))
+	if err == nil {
+		t.Fatal("Write() error = nil, want size mismatch")
+	}
+}
+
+func TestReadRejectsInvalidBundles(t *testing.T) {
+	tarGz := func(entries map[string]string, order ...string) *bytes.Buffer {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gw)
+		for _, name := range order {
+			data := entries[name]
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), Typeflag: tar.TypeReg})
+			tw.Write([]byte(data))
+		}
+		tw.Close()
+		gw.Close()
+		return &buf
+	}
+
+	tests := []struct {
+		name    string
+		bundle  io.Reader
+		wantErr string
+	}{
+		{
+			name:    "not gzip",
+			bundle:  strings.NewReader("plain text"),
+			wantErr: "not a haloy bundle",
+		},
+		{
+			name:    "unsupported version",
+			bundle:  tarGz(map[string]string{manifestEntry: `{"version":99}`}, manifestEntry),
+			wantErr: "format version 99",
+		},
+		{
+			name:    "missing images",
+			bundle:  tarGz(map[string]string{manifestEntry: `{"version":1}`, deployEntry: `{}`}, manifestEntry, deployEntry),
+			wantErr: "missing images.tar",
+		},
+		{
+			name:    "wrong order",
+			bundle:  tarGz(map[string]string{manifestEntry: `{"version":1}`, imagesEntry: "x"}, manifestEntry, imagesEntry),
+			wantErr: "unexpected bundle entry",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Read(tt.bundle, func(Manifest, apitypes.DeployRequest, io.Reader) error {
+				t.Fatal("load should not be called")
+				return nil
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Read() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// ClientAuth makes the proxy ask clients of the target's domains for a TLS
+// certificate issued by a CA (mutual TLS). The subject of a verified
+// certificate is passed to the app in the X-Client-Cert-Subject header.
+type ClientAuth struct {
+	// CAFile is a PEM file with the CA certificates, relative to the config
+	// file. The CLI reads it into CAInline when loading the config.
+	CAFile string `json:"caFile,omitempty" yaml:"ca_file,omitempty" toml:"ca_file,omitempty"`
+	// CAInline holds the PEM encoded CA certificates.
+	CAInline string `json:"caInline,omitempty" yaml:"ca_inline,omitempty" toml:"ca_inline,omitempty"`
+	// Mode is "require" (the default) to reject clients without a valid
+	// certificate, or "verify_if_given" to let them through to the app.
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty" toml:"mode,omitempty"`
+}
+
+// GetMode returns the configured mode, or require when unset.
+func (ca *ClientAuth) GetMode() string {
+	if ca.Mode == "" {
+		return constants.ClientAuthRequire
+	}
+	return ca.Mode
+}
+
+func (ca *ClientAuth) Validate(domains []Domain, format string) error {
+	field := GetFieldNameForFormat(TargetConfig{}, "ClientAuth", format)
+	if len(domains) == 0 {
+		return fmt.Errorf("%s requires the target to have domains", field)
+	}
+	switch ca.GetMode() {
+	case constants.ClientAuthRequire, constants.ClientAuthVerifyIfGiven:
+	default:
+		return fmt.Errorf("invalid %s.%s '%s': must be '%s' or '%s'",
+			field, GetFieldNameForFormat(ClientAuth{}, "Mode", format),
+			ca.Mode, constants.ClientAuthRequire, constants.ClientAuthVerifyIfGiven)
+	}
+
+	caFile := GetFieldNameForFormat(ClientAuth{}, "CAFile", format)
+	caInline := GetFieldNameForFormat(ClientAuth{}, "CAInline", format)
+	switch {
+	case ca.CAFile != "" && ca.CAInline != "":
+		return fmt.Errorf("%s: set either '%s' or '%s', not both", field, caFile, caInline)
+	case ca.CAFile != "":
+		// Read into CAInline when the config is loaded.
+		return nil
+	case ca.CAInline == "":
+		return fmt.Errorf("%s needs '%s' or '%s'", field, caFile, caInline)
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM([]byte(ca.CAInline)) {
+		return fmt.Errorf("%s.%s has no PEM encoded certificate", field, caInline)
+	}
+	return nil
+}
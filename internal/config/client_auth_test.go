@@ -0,0 +1,74 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCAPEM(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestClientAuth_Validate(t *testing.T) {
+	ca := testCAPEM(t)
+	domains := []Domain{{Canonical: "internal.example.com"}}
+	tests := []struct {
+		name       string
+		clientAuth ClientAuth
+		domains    []Domain
+		wantErr    string
+	}{
+		{name: "inline", clientAuth: ClientAuth{CAInline: ca}},
+		{name: "verify if given", clientAuth: ClientAuth{CAInline: ca, Mode: "verify_if_given"}},
+		{name: "file not read yet", clientAuth: ClientAuth{CAFile: "ca.pem", Mode: "require"}},
+		{name: "no domains", clientAuth: ClientAuth{CAInline: ca}, domains: []Domain{}, wantErr: "requires the target to have domains"},
+		{name: "invalid mode", clientAuth: ClientAuth{CAInline: ca, Mode: "optional"}, wantErr: "invalid client_auth.mode 'optional'"},
+		{name: "no CA", clientAuth: ClientAuth{}, wantErr: "needs 'ca_file' or 'ca_inline'"},
+		{name: "both", clientAuth: ClientAuth{CAFile: "ca.pem", CAInline: ca}, wantErr: "not both"},
+		{name: "not PEM", clientAuth: ClientAuth{CAInline: "not a certificate"}, wantErr: "no PEM encoded certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := domains
+			if tt.domains != nil {
+				d = tt.domains
+			}
+			err := tt.clientAuth.Validate(d, "yaml")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
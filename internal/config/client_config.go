@@ -15,11 +15,25 @@ import (
 )
 
 type ClientConfig struct {
-	Servers map[string]ServerConfig `json:"servers" yaml:"servers" toml:"servers"`
+	// CurrentProfile is the profile used when a config doesn't set a server.
+	CurrentProfile string                  `json:"current_profile,omitempty" yaml:"current_profile,omitempty" toml:"current_profile,omitempty"`
+	Profiles       map[string]Profile      `json:"profiles,omitempty" yaml:"profiles,omitempty" toml:"profiles,omitempty"`
+	Servers        map[string]ServerConfig `json:"servers" yaml:"servers" toml:"servers"`
 }
 
 type ServerConfig struct {
 	TokenEnv string `json:"token_env" yaml:"token_env" toml:"token_env"`
+	// Keyring is set when the token is stored in the OS keyring, where it is
+	// looked up before TokenEnv.
+	Keyring bool `json:"keyring,omitempty" yaml:"keyring,omitempty" toml:"keyring,omitempty"`
+}
+
+// Profile is a named server, like a kubectl context. Deploy configs can refer
+// to it by name in 'server', and its token is stored under Servers like any
+// other server's.
+type Profile struct {
+	Server        string `json:"server" yaml:"server" toml:"server"`
+	DefaultTarget string `json:"default_target,omitempty" yaml:"default_target,omitempty" toml:"default_target,omitempty"`
 }
 
 func (cc *ClientConfig) AddServer(url, tokenEnv string, force bool) error {
@@ -42,6 +56,29 @@ func (cc *ClientConfig) AddServer(url, tokenEnv string, force bool) error {
 	return nil
 }
 
+// SetServerKeyring records whether url's token is in the OS keyring, adding
+// the server when needed. A server left with neither a keyring token nor a
+// token env var is removed.
+func (cc *ClientConfig) SetServerKeyring(url string, enabled bool) error {
+	normalizedURL, err := helpers.NormalizeServerURL(url)
+	if err != nil {
+		return err
+	}
+
+	if cc.Servers == nil {
+		cc.Servers = make(map[string]ServerConfig)
+	}
+
+	server := cc.Servers[normalizedURL]
+	server.Keyring = enabled
+	if !server.Keyring && server.TokenEnv == "" {
+		delete(cc.Servers, normalizedURL)
+		return nil
+	}
+	cc.Servers[normalizedURL] = server
+	return nil
+}
+
 func (cc *ClientConfig) DeleteServer(url string) error {
 	normalizedURL, err := helpers.NormalizeServerURL(url)
 	if err != nil {
@@ -64,6 +101,118 @@ func (cc *ClientConfig) ListServers() []string {
 	return urls
 }
 
+func (cc *ClientConfig) AddProfile(name, url string, force bool) error {
+	if !isValidAppName(name) {
+		return fmt.Errorf("invalid profile name '%s'; must contain only alphanumeric characters, hyphens, and underscores", name)
+	}
+	normalizedURL, err := helpers.NormalizeServerURL(url)
+	if err != nil {
+		return err
+	}
+
+	if cc.Profiles == nil {
+		cc.Profiles = make(map[string]Profile)
+	}
+
+	existing, exists := cc.Profiles[name]
+	if exists && !force {
+		return fmt.Errorf("profile %s already exists. Use --force to override", name)
+	}
+
+	// Overwriting a profile keeps its defaults unless it moved to another server.
+	profile := Profile{Server: normalizedURL}
+	if exists && existing.Server == normalizedURL {
+		profile.DefaultTarget = existing.DefaultTarget
+	}
+	cc.Profiles[name] = profile
+	return nil
+}
+
+// DeleteProfile removes a profile, and makes no profile current if it was.
+func (cc *ClientConfig) DeleteProfile(name string) error {
+	if _, exists := cc.Profiles[name]; !exists {
+		return fmt.Errorf("profile %s not found", name)
+	}
+	delete(cc.Profiles, name)
+	if cc.CurrentProfile == name {
+		cc.CurrentProfile = ""
+	}
+	return nil
+}
+
+func (cc *ClientConfig) UseProfile(name string) error {
+	if _, exists := cc.Profiles[name]; !exists {
+		return fmt.Errorf("profile %s not found", name)
+	}
+	cc.CurrentProfile = name
+	return nil
+}
+
+func (cc *ClientConfig) ListProfiles() []string {
+	names := make([]string, 0, len(cc.Profiles))
+	for name := range cc.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProfilesForServer returns the names of the profiles pointing at url.
+func (cc *ClientConfig) ProfilesForServer(url string) []string {
+	normalizedURL, err := helpers.NormalizeServerURL(url)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, name := range cc.ListProfiles() {
+		if cc.Profiles[name].Server == normalizedURL {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Current returns the current profile, if one is set and still exists.
+func (cc *ClientConfig) Current() (string, Profile, bool) {
+	if cc == nil || cc.CurrentProfile == "" {
+		return "", Profile{}, false
+	}
+	profile, exists := cc.Profiles[cc.CurrentProfile]
+	return cc.CurrentProfile, profile, exists
+}
+
+// ResolveServer returns the server URL of the profile named ref, or ref
+// itself when no profile has that name.
+func (cc *ClientConfig) ResolveServer(ref string) string {
+	if cc == nil {
+		return ref
+	}
+	if profile, exists := cc.Profiles[ref]; exists {
+		return profile.Server
+	}
+	return ref
+}
+
+// ClientConfigPath returns the path of the client config in the haloy config
+// directory.
+func ClientConfigPath() (string, error) {
+	configDir, err := HaloyConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, constants.ClientConfigFileName), nil
+}
+
+// LoadDefaultClientConfig loads the client config from the haloy config
+// directory. It returns nil without an error when there is none.
+func LoadDefaultClientConfig() (*ClientConfig, error) {
+	path, err := ClientConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	return LoadClientConfig(path)
+}
+
 func LoadClientConfig(path string) (*ClientConfig, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return nil, nil
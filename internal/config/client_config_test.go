@@ -110,3 +110,69 @@ func TestClientConfig_ListServers(t *testing.T) {
 		})
 	}
 }
+
+func TestClientConfig_Profiles(t *testing.T) {
+	cc := ClientConfig{}
+	if err := cc.AddProfile("prod", "https://api.example.com/", false); err != nil {
+		t.Fatalf("AddProfile() unexpected error = %v", err)
+	}
+	if err := cc.AddProfile("prod", "other.example.com", false); err == nil {
+		t.Fatalf("AddProfile() expected error for existing profile without force")
+	}
+	if err := cc.AddProfile("prod.eu", "api.example.com", false); err == nil {
+		t.Fatalf("AddProfile() expected error for invalid profile name")
+	}
+	if err := cc.AddProfile("staging", "staging.example.com", false); err != nil {
+		t.Fatalf("AddProfile() unexpected error = %v", err)
+	}
+
+	if got := cc.ResolveServer("prod"); got != "api.example.com" {
+		t.Errorf("ResolveServer(prod) = %s, expected api.example.com", got)
+	}
+	if got := cc.ResolveServer("api.example.com"); got != "api.example.com" {
+		t.Errorf("ResolveServer(url) = %s, expected it unchanged", got)
+	}
+	if got := cc.ProfilesForServer("https://api.example.com"); len(got) != 1 || got[0] != "prod" {
+		t.Errorf("ProfilesForServer() = %v, expected [prod]", got)
+	}
+
+	if _, _, ok := cc.Current(); ok {
+		t.Fatalf("Current() expected no current profile")
+	}
+	if err := cc.UseProfile("missing"); err == nil {
+		t.Fatalf("UseProfile() expected error for unknown profile")
+	}
+	if err := cc.UseProfile("prod"); err != nil {
+		t.Fatalf("UseProfile() unexpected error = %v", err)
+	}
+	if name, profile, ok := cc.Current(); !ok || name != "prod" || profile.Server != "api.example.com" {
+		t.Errorf("Current() = %s, %+v, %v, expected prod on api.example.com", name, profile, ok)
+	}
+
+	// Overwriting keeps defaults for the same server only.
+	p := cc.Profiles["prod"]
+	p.DefaultTarget = "production"
+	cc.Profiles["prod"] = p
+	if err := cc.AddProfile("prod", "api.example.com", true); err != nil {
+		t.Fatalf("AddProfile() unexpected error = %v", err)
+	}
+	if got := cc.Profiles["prod"].DefaultTarget; got != "production" {
+		t.Errorf("DefaultTarget = %q after overwrite with same server, expected production", got)
+	}
+	if err := cc.AddProfile("prod", "new.example.com", true); err != nil {
+		t.Fatalf("AddProfile() unexpected error = %v", err)
+	}
+	if got := cc.Profiles["prod"].DefaultTarget; got != "" {
+		t.Errorf("DefaultTarget = %q after moving server, expected it cleared", got)
+	}
+
+	if err := cc.DeleteProfile("prod"); err != nil {
+		t.Fatalf("DeleteProfile() unexpected error = %v", err)
+	}
+	if cc.CurrentProfile != "" {
+		t.Errorf("CurrentProfile = %q after deleting it, expected none", cc.CurrentProfile)
+	}
+	if got := cc.ListProfiles(); len(got) != 1 || got[0] != "staging" {
+		t.Errorf("ListProfiles() = %v, expected [staging]", got)
+	}
+}
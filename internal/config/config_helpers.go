@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"reflect"
@@ -78,56 +79,83 @@ func GetFieldNameForFormat(v any, fieldName, format string) string {
 func CheckUnknownFields(structType reflect.Type, configKeys []string, format string) error {
 	knownFields := getKnownFields(structType, format)
 
-	unknownFields := make([]string, 0)
+	var errs []error
+	reported := make(map[string]bool)
 	for _, key := range configKeys {
-		if !isValidConfigKey(key, knownFields) {
-			unknownFields = append(unknownFields, key)
+		if isValidConfigKey(key, knownFields) {
+			continue
 		}
-	}
+		// Report the first unknown part of the key, so a misspelled block
+		// like 'helth_check' is reported once, not for each field in it.
+		parts := strings.Split(key, ".")
+		unknown := key
+		for i := 1; i <= len(parts); i++ {
+			if prefix := strings.Join(parts[:i], "."); !isValidConfigKey(prefix, knownFields) {
+				unknown = prefix
+				break
+			}
+		}
+		if reported[unknown] {
+			continue
+		}
+		reported[unknown] = true
 
-	if len(unknownFields) > 0 {
-		return fmt.Errorf("unknown config fields found: %v", unknownFields)
+		msg := fmt.Sprintf("unknown field '%s'", unknown)
+		if suggestion := closestMatch(parts[strings.Count(unknown, ".")], siblingFields(unknown, knownFields)); suggestion != "" {
+			msg += fmt.Sprintf(", did you mean '%s'?", suggestion)
+		}
+		errs = append(errs, errors.New(msg))
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
-// isValidConfigKey checks if a config key is valid, handling map fields with dynamic keys
-func isValidConfigKey(key string, knownFields []string) bool {
-	// Direct match
-	if slices.Contains(knownFields, key) {
-		return true
-	}
+// freeFormSuffix marks a known field as a map whose keys are chosen by the
+// user, like labels.
+const freeFormSuffix = ".*"
 
-	// Check for map field patterns (e.g., "targets.somekey.field")
+// canonicalConfigKey replaces the user chosen keys of maps of structs in a
+// config key, e.g. targets.web.replicas becomes targets.replicas, as the
+// fields collected from the struct types are named.
+func canonicalConfigKey(key string) string {
 	parts := strings.Split(key, ".")
-
-	// Handle targets.{dynamic_key}.{field}
-	if len(parts) >= 3 && parts[0] == "targets" {
-		// For targets.{dynamic_key}.{field}, check if the field part is valid
-		// by looking for a pattern like "targets.{field}" in known fields
-		targetFieldKey := "targets." + strings.Join(parts[2:], ".")
-		return slices.Contains(knownFields, targetFieldKey)
+	switch {
+	case len(parts) >= 2 && (parts[0] == "targets" || parts[0] == "images"):
+		parts = slices.Delete(parts, 1, 2)
+	case len(parts) >= 3 && parts[0] == "secret_providers":
+		parts = slices.Delete(parts, 2, 3)
 	}
+	return strings.Join(parts, ".")
+}
 
-	// Handle images.{dynamic_key} or images.{dynamic_key}.{field}
-	if len(parts) >= 2 && parts[0] == "images" {
-		if len(parts) == 2 {
+// isValidConfigKey checks if a config key is valid, handling map fields with dynamic keys
+func isValidConfigKey(key string, knownFields []string) bool {
+	canonical := canonicalConfigKey(key)
+	if slices.Contains(knownFields, canonical) {
+		return true
+	}
+	for _, field := range knownFields {
+		if prefix, ok := strings.CutSuffix(field, "*"); ok && strings.HasPrefix(canonical, prefix) {
 			return true
 		}
-		imageFieldKey := "images." + strings.Join(parts[2:], ".")
-		return slices.Contains(knownFields, imageFieldKey)
 	}
+	return false
+}
 
-	// Handle secret_providers.{provider}.{source_name}.{field}
-	if len(parts) >= 4 && parts[0] == "secret_providers" {
-		// For secret_providers.{provider}.{source_name}.{field}
-		// check if secret_providers.{provider}.{field} exists in known fields
-		providerFieldKey := "secret_providers." + parts[1] + "." + strings.Join(parts[3:], ".")
-		return slices.Contains(knownFields, providerFieldKey)
+// siblingFields returns the names of the known fields next to key.
+func siblingFields(key string, knownFields []string) []string {
+	parent := ""
+	if i := strings.LastIndex(canonicalConfigKey(key), "."); i >= 0 {
+		parent = canonicalConfigKey(key)[:i+1]
 	}
-
-	return false
+	var siblings []string
+	for _, field := range knownFields {
+		name, ok := strings.CutPrefix(field, parent)
+		if ok && name != "" && !strings.Contains(name, ".") {
+			siblings = append(siblings, name)
+		}
+	}
+	return siblings
 }
 
 func getKnownFields(structType reflect.Type, format string) []string {
@@ -182,7 +210,10 @@ func collectFields(structType reflect.Type, format string, prefix string, fields
 			if valueType.Kind() == reflect.Pointer {
 				valueType = valueType.Elem()
 			}
-			if valueType.Kind() == reflect.Struct {
+			if valueType.Kind() != reflect.Struct && valueType.Kind() != reflect.Map {
+				// Any key is valid in maps of plain values, like labels.
+				*fields = append(*fields, fullFieldName+freeFormSuffix)
+			} else if valueType.Kind() == reflect.Struct {
 				// For maps, we need to accept any key, so we use a wildcard approach
 				// by collecting the possible fields that could appear under this map
 				collectFields(valueType, format, fullFieldName, fields)
@@ -2,6 +2,8 @@ package config
 
 import (
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -25,3 +27,30 @@ func TestCheckUnknownFields(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckUnknownFieldsSuggestions(t *testing.T) {
+	deployConfigType := reflect.TypeFor[DeployConfig]()
+	keys := []string{
+		"name",
+		"replcias",
+		"targets.web.domians",
+		"helth_check.type",
+		"helth_check.cmd",
+		"labels.com.example.team",
+		"targets.web.logging_driver.options.max-size",
+		"zzzzzz",
+	}
+	err := CheckUnknownFields(deployConfigType, keys, "yaml")
+	if err == nil {
+		t.Fatal("CheckUnknownFields() accepted unknown fields")
+	}
+	want := []string{
+		"unknown field 'replcias', did you mean 'replicas'?",
+		"unknown field 'targets.web.domians', did you mean 'domains'?",
+		"unknown field 'helth_check', did you mean 'health_check'?",
+		"unknown field 'zzzzzz'",
+	}
+	if got := strings.Split(err.Error(), "\n"); !slices.Equal(got, want) {
+		t.Errorf("CheckUnknownFields() error =\n%s\nwant\n%s", err, strings.Join(want, "\n"))
+	}
+}
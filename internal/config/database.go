@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type DatabaseEngine string
+
+const DatabaseEnginePostgres DatabaseEngine = "postgres"
+
+// DatabaseConfig makes a target a database haloyd manages: it generates the
+// credentials, hands a connection string to the targets using it and backs
+// it up when database_backups is set in haloyd.yaml.
+type DatabaseConfig struct {
+	Engine DatabaseEngine `json:"engine" yaml:"engine" toml:"engine"`
+	// Name of the database to create. Defaults to the app name with hyphens
+	// replaced by underscores.
+	Name string `json:"name,omitempty" yaml:"name,omitempty" toml:"name,omitempty"`
+	// User to create. Defaults to the database name.
+	User string `json:"user,omitempty" yaml:"user,omitempty" toml:"user,omitempty"`
+}
+
+var databaseIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// GetName returns the database name for the app appName.
+func (d *DatabaseConfig) GetName(appName string) string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return strings.ToLower(strings.ReplaceAll(appName, "-", "_"))
+}
+
+// GetUser returns the database user for the app appName.
+func (d *DatabaseConfig) GetUser(appName string) string {
+	if d.User != "" {
+		return d.User
+	}
+	return d.GetName(appName)
+}
+
+func (d *DatabaseConfig) Validate(appName string) error {
+	if d.Engine != DatabaseEnginePostgres {
+		return fmt.Errorf("database.engine must be 'postgres', got '%s'", d.Engine)
+	}
+	if name := d.GetName(appName); !databaseIdentifier.MatchString(name) {
+		return fmt.Errorf("invalid database.name '%s'; must be lowercase letters, digits and underscores, starting with a letter", name)
+	}
+	if user := d.GetUser(appName); !databaseIdentifier.MatchString(user) {
+		return fmt.Errorf("invalid database.user '%s'; must be lowercase letters, digits and underscores, starting with a letter", user)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDatabaseConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		database DatabaseConfig
+		appName  string
+		wantErr  string
+		wantDB   string
+	}{
+		{
+			name:     "defaults from the app name",
+			database: DatabaseConfig{Engine: DatabaseEnginePostgres},
+			appName:  "shop-db",
+			wantDB:   "shop_db",
+		},
+		{
+			name:     "explicit name",
+			database: DatabaseConfig{Engine: DatabaseEnginePostgres, Name: "shop", User: "shop_app"},
+			appName:  "shop-db",
+			wantDB:   "shop",
+		},
+		{
+			name:     "unsupported engine",
+			database: DatabaseConfig{Engine: "mysql"},
+			appName:  "shop-db",
+			wantErr:  "must be 'postgres'",
+		},
+		{
+			name:     "invalid name",
+			database: DatabaseConfig{Engine: DatabaseEnginePostgres, Name: "Shop; DROP"},
+			appName:  "shop-db",
+			wantErr:  "invalid database.name",
+		},
+		{
+			name:     "explicit name for an app name that isn't an identifier",
+			database: DatabaseConfig{Engine: DatabaseEnginePostgres, Name: "shop"},
+			appName:  "1db",
+			wantDB:   "shop",
+		},
+		{
+			name:     "name starting with a digit",
+			database: DatabaseConfig{Engine: DatabaseEnginePostgres},
+			appName:  "1db",
+			wantErr:  "invalid database.name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.database.Validate(tt.appName)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error = %v", err)
+				}
+				if got := tt.database.GetName(tt.appName); got != tt.wantDB {
+					t.Errorf("GetName() = %q, want %q", got, tt.wantDB)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,18 +5,41 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/go-viper/mapstructure/v2"
+	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/helpers"
 )
 
 type DeployConfig struct {
+	// Schema is the version of the config file layout. Files without it are
+	// version 1 and are migrated to SchemaVersion when loaded.
+	Schema           int               `json:"schema,omitempty" yaml:"schema,omitempty" toml:"schema,omitempty"`
 	Images           map[string]*Image `json:"images,omitempty" yaml:"images,omitempty" toml:"images,omitempty"`
 	TargetConfig     `mapstructure:",squash" json:",inline" yaml:",inline" toml:",inline"`
 	Targets          map[string]*TargetConfig `json:"targets,omitempty" yaml:"targets,omitempty" toml:"targets,omitempty"`
 	SecretProviders  *SecretProviders         `json:"secretProviders,omitempty" yaml:"secret_providers,omitempty" toml:"secret_providers,omitempty"`
 	GlobalPreDeploy  []string                 `json:"globalPreDeploy,omitempty" yaml:"global_pre_deploy,omitempty" toml:"global_pre_deploy,omitempty"`
 	GlobalPostDeploy []string                 `json:"globalPostDeploy,omitempty" yaml:"global_post_deploy,omitempty" toml:"global_post_deploy,omitempty"`
+	Deploy           *DeployOptions           `json:"deploy,omitempty" yaml:"deploy,omitempty" toml:"deploy,omitempty"`
+	// Plugins are executables run at points of the deploy lifecycle, after
+	// the ones in the plugins directory of the haloy config directory.
+	// Relative paths are relative to the config file.
+	Plugins []string `json:"plugins,omitempty" yaml:"plugins,omitempty" toml:"plugins,omitempty"`
+}
+
+// DeployOptions control how 'haloy deploy' schedules the targets of a
+// multi-target config. By default targets on different servers deploy in
+// parallel and targets on the same server one after another.
+type DeployOptions struct {
+	// Concurrency is the most targets deployed at the same time. Zero means
+	// no limit.
+	Concurrency int `json:"concurrency,omitempty" yaml:"concurrency,omitempty" toml:"concurrency,omitempty"`
+	// Order lists targets that are deployed one after another, in this
+	// order, before all other targets. Each waits for the previous one to
+	// finish; a target with several servers deploys them one at a time.
+	Order []string `json:"order,omitempty" yaml:"order,omitempty" toml:"order,omitempty"`
 }
 
 type TargetConfig struct {
@@ -29,9 +52,15 @@ type TargetConfig struct {
 	Preset Preset `json:"preset,omitempty" yaml:"preset,omitempty" toml:"preset,omitempty"`
 
 	// Image can be defined inline OR reference a named image (ImageKey) from the Images map
-	Image              *Image             `json:"image,omitempty" yaml:"image,omitempty" toml:"image,omitempty"`
-	ImageKey           string             `json:"imageKey,omitempty" yaml:"image_key,omitempty" toml:"image_key,omitempty"`
-	Server             string             `json:"server,omitempty" yaml:"server,omitempty" toml:"server,omitempty"`
+	Image    *Image `json:"image,omitempty" yaml:"image,omitempty" toml:"image,omitempty"`
+	ImageKey string `json:"imageKey,omitempty" yaml:"image_key,omitempty" toml:"image_key,omitempty"`
+	Server   string `json:"server,omitempty" yaml:"server,omitempty" toml:"server,omitempty"`
+	// Servers deploys identical containers to each of these servers instead
+	// of the single 'server'. Each becomes a target named <target>@<server>.
+	Servers []string `json:"servers,omitempty" yaml:"servers,omitempty" toml:"servers,omitempty"`
+	// Rollout is how a target with several servers is deployed: to all of
+	// them at once, or one server at a time.
+	Rollout            Rollout            `json:"rollout,omitempty" yaml:"rollout,omitempty" toml:"rollout,omitempty"`
 	APIToken           *ValueSource       `json:"apiToken,omitempty" yaml:"api_token,omitempty" toml:"api_token,omitempty"`
 	DeploymentStrategy DeploymentStrategy `json:"deploymentStrategy,omitempty" yaml:"deployment_strategy,omitempty" toml:"deployment_strategy,omitempty"`
 	NamingStrategy     NamingStrategy     `json:"namingStrategy,omitempty" yaml:"naming_strategy,omitempty" toml:"naming_strategy,omitempty"`
@@ -39,17 +68,68 @@ type TargetConfig struct {
 	Domains            []Domain           `json:"domains,omitempty" yaml:"domains,omitempty" toml:"domains,omitempty"`
 	Env                []EnvVar           `json:"env,omitempty" yaml:"env,omitempty" toml:"env,omitempty"`
 	HealthCheckPath    string             `json:"healthCheckPath,omitempty" yaml:"health_check_path,omitempty" toml:"health_check_path,omitempty"`
+	HealthCheck        *HealthCheck       `json:"healthCheck,omitempty" yaml:"docker_healthcheck,omitempty" toml:"docker_healthcheck,omitempty"`
+	HealthProbe        *HealthProbe       `json:"healthProbe,omitempty" yaml:"health_check,omitempty" toml:"health_check,omitempty"`
+	Startup            *StartupProbe      `json:"startup,omitempty" yaml:"startup,omitempty" toml:"startup,omitempty"`
 	MinReadySeconds    *int               `json:"minReadySeconds,omitempty" yaml:"min_ready_seconds,omitempty" toml:"min_ready_seconds,omitempty"`
 	Port               Port               `json:"port,omitempty" yaml:"port,omitempty" toml:"port,omitempty"`
+	Ports              []string           `json:"ports,omitempty" yaml:"ports,omitempty" toml:"ports,omitempty"` // Published on the server, see ParsePortMapping
 	Replicas           *int               `json:"replicas,omitempty" yaml:"replicas,omitempty" toml:"replicas,omitempty"`
 	Volumes            []string           `json:"volumes,omitempty" yaml:"volumes,omitempty" toml:"volumes,omitempty"`
 	Network            string             `json:"network,omitempty" yaml:"network,omitempty" toml:"network,omitempty"`
 	PreDeploy          []string           `json:"preDeploy,omitempty" yaml:"pre_deploy,omitempty" toml:"pre_deploy,omitempty"`
 	PostDeploy         []string           `json:"postDeploy,omitempty" yaml:"post_deploy,omitempty" toml:"post_deploy,omitempty"`
+	Sidecars           []Sidecar          `json:"sidecars,omitempty" yaml:"sidecars,omitempty" toml:"sidecars,omitempty"`
+	// User runs the app containers as user[:group], e.g. "1000:1000",
+	// instead of the image's user. GroupAdd adds supplementary groups.
+	User     string   `json:"user,omitempty" yaml:"user,omitempty" toml:"user,omitempty"`
+	GroupAdd []string `json:"groupAdd,omitempty" yaml:"group_add,omitempty" toml:"group_add,omitempty"`
+	// LoggingDriver sets the Docker logging driver of the target's
+	// containers, sidecars included.
+	LoggingDriver *LoggingDriver `json:"loggingDriver,omitempty" yaml:"logging_driver,omitempty" toml:"logging_driver,omitempty"`
+	// Labels are extra labels for the app containers, e.g. for log
+	// collectors or monitoring that select containers by label.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty" toml:"labels,omitempty"`
+	// Security hardens the app containers: a read-only root filesystem,
+	// dropped capabilities and so on.
+	Security *SecurityOptions `json:"security,omitempty" yaml:"security,omitempty" toml:"security,omitempty"`
+	// MigrationLock names a server-side lock the target's containers can
+	// take through the API, so only one of them runs migrations at a time.
+	MigrationLock string `json:"migrationLock,omitempty" yaml:"migration_lock,omitempty" toml:"migration_lock,omitempty"`
+	// Database makes the target a database haloyd manages.
+	Database *DatabaseConfig `json:"database,omitempty" yaml:"database,omitempty" toml:"database,omitempty"`
+	// DatabaseURLFrom names the managed database app whose connection string
+	// is passed to the target as DATABASE_URL.
+	DatabaseURLFrom string `json:"databaseUrlFrom,omitempty" yaml:"database_url_from,omitempty" toml:"database_url_from,omitempty"`
+	// Snapshots schedules snapshots of the target's named volumes.
+	Snapshots *SnapshotsConfig `json:"snapshots,omitempty" yaml:"snapshots,omitempty" toml:"snapshots,omitempty"`
+	// Preview lets 'haloy preview create' deploy per-branch copies of the target.
+	Preview *PreviewConfig `json:"preview,omitempty" yaml:"preview,omitempty" toml:"preview,omitempty"`
+
+	// Proxy limits for requests routed to this target. Unset values use the proxy defaults.
+	ClientMaxBodySize string `json:"clientMaxBodySize,omitempty" yaml:"client_max_body_size,omitempty" toml:"client_max_body_size,omitempty"`
+	ProxyReadTimeout  string `json:"proxyReadTimeout,omitempty" yaml:"proxy_read_timeout,omitempty" toml:"proxy_read_timeout,omitempty"`
+	ProxySendTimeout  string `json:"proxySendTimeout,omitempty" yaml:"proxy_send_timeout,omitempty" toml:"proxy_send_timeout,omitempty"`
+	// Redirects are answered by the proxy before requests reach the target,
+	// e.g. for moved pages or a domain the app moved away from.
+	Redirects []Redirect `json:"redirects,omitempty" yaml:"redirects,omitempty" toml:"redirects,omitempty"`
+	// URLNormalization redirects requests to one canonical form of their URL.
+	URLNormalization *URLNormalization `json:"urlNormalization,omitempty" yaml:"url_normalization,omitempty" toml:"url_normalization,omitempty"`
+	// ClientAuth requires clients to present a TLS certificate from a CA.
+	ClientAuth *ClientAuth `json:"clientAuth,omitempty" yaml:"client_auth,omitempty" toml:"client_auth,omitempty"`
+	// SSO signs users in with an identity provider before they reach the target.
+	SSO *SSO `json:"sso,omitempty" yaml:"sso,omitempty" toml:"sso,omitempty"`
+	// ErrorPages is a local directory of custom error pages (404.html, 5xx.html, ...)
+	// uploaded with each deploy and served by the proxy for this target's routes.
+	ErrorPages string `json:"errorPages,omitempty" yaml:"error_pages,omitempty" toml:"error_pages,omitempty"`
 
 	// Non config fields. Not read from the config file and populated on load.
 	TargetName string `json:"-" yaml:"-" toml:"-"`
 	Format     string `json:"-" yaml:"-" toml:"-"`
+	// ServerGroup is the target a per-server target was expanded from, and
+	// ServerIndex the position of its server in 'servers'.
+	ServerGroup string `json:"-" yaml:"-" toml:"-"`
+	ServerIndex int    `json:"-" yaml:"-" toml:"-"`
 }
 
 type Preset string
@@ -60,6 +140,13 @@ const (
 	PresetService  Preset = "service"
 )
 
+type Rollout string
+
+const (
+	RolloutParallel Rollout = "parallel" // Default: all servers at once
+	RolloutStaged   Rollout = "staged"   // One server at a time, in 'servers' order
+)
+
 type DeploymentStrategy string
 
 const (
@@ -77,6 +164,15 @@ const (
 type Domain struct {
 	Canonical string   `yaml:"domain" json:"domain" toml:"domain"`
 	Aliases   []string `yaml:"aliases,omitempty" json:"aliases,omitempty" toml:"aliases,omitempty"`
+	// PathPrefix routes only requests under this path to the app, so several
+	// apps can share a domain. Empty means the whole domain.
+	PathPrefix string `yaml:"path_prefix,omitempty" json:"pathPrefix,omitempty" toml:"path_prefix,omitempty"`
+	// StripPrefix removes PathPrefix from the request path before it reaches the app.
+	StripPrefix bool `yaml:"strip_prefix,omitempty" json:"stripPrefix,omitempty" toml:"strip_prefix,omitempty"`
+	// CDN names the CDN proxying the domain. "cloudflare" skips the DNS check
+	// against the server's IPs, issues certificates over DNS-01 when haloyd
+	// has a Cloudflare API token and restores client IPs from CF-Connecting-IP.
+	CDN string `yaml:"cdn,omitempty" json:"cdn,omitempty" toml:"cdn,omitempty"`
 }
 
 func (d *Domain) Validate() error {
@@ -89,9 +185,30 @@ func (d *Domain) Validate() error {
 			return fmt.Errorf("alias '%s': %w", alias, err)
 		}
 	}
+
+	if d.PathPrefix != "" {
+		if !strings.HasPrefix(d.PathPrefix, "/") {
+			return fmt.Errorf("path_prefix '%s' must start with '/'", d.PathPrefix)
+		}
+		if strings.ContainsAny(d.PathPrefix, "?# \t") || strings.Contains(d.PathPrefix, "//") {
+			return fmt.Errorf("path_prefix '%s' must be a plain URL path", d.PathPrefix)
+		}
+	}
+	if d.StripPrefix && d.NormalizedPathPrefix() == "" {
+		return fmt.Errorf("strip_prefix requires a path_prefix for domain '%s'", d.Canonical)
+	}
+	if d.CDN != "" && d.CDN != constants.CDNCloudflare {
+		return fmt.Errorf("cdn '%s' for domain '%s' is not supported, use '%s'", d.CDN, d.Canonical, constants.CDNCloudflare)
+	}
 	return nil
 }
 
+// NormalizedPathPrefix returns PathPrefix without a trailing slash, so "/api"
+// and "/api/" route the same. The root prefix "/" normalizes to "".
+func (d *Domain) NormalizedPathPrefix() string {
+	return strings.TrimRight(d.PathPrefix, "/")
+}
+
 type EnvVar struct {
 	Name        string `json:"name" yaml:"name" toml:"name"`
 	ValueSource `mapstructure:",squash" json:",inline" yaml:",inline" toml:",inline"`
@@ -107,6 +224,11 @@ func (ev *EnvVar) Validate(format string) error {
 	if err := ev.ValueSource.Validate(); err != nil {
 		return fmt.Errorf("environment variable '%s': %w", ev.Name, err)
 	}
+	if ev.BuildArg {
+		if err := ev.validateNotServerSecret(); err != nil {
+			return fmt.Errorf("environment variable '%s' is a build argument: %w", ev.Name, err)
+		}
+	}
 
 	return nil
 }
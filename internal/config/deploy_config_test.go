@@ -319,6 +319,80 @@ func TestTargetConfig_Validate_Comprehensive(t *testing.T) {
 			format:      "yaml",
 			expectError: false,
 		},
+		{
+			name: "valid proxy limits",
+			target: TargetConfig{
+				Name:              "haloy-test-app",
+				Server:            "haloy.dev",
+				Image:             &Image{Repository: "nginx", Tag: "latest"},
+				ClientMaxBodySize: "50MB",
+				ProxyReadTimeout:  "5m",
+				ProxySendTimeout:  "30s",
+			},
+			format:      "yaml",
+			expectError: false,
+		},
+		{
+			name: "invalid client_max_body_size",
+			target: TargetConfig{
+				Name:              "haloy-test-app",
+				Server:            "haloy.dev",
+				Image:             &Image{Repository: "nginx", Tag: "latest"},
+				ClientMaxBodySize: "lots",
+			},
+			format:      "yaml",
+			expectError: true,
+			errMsg:      "client_max_body_size",
+		},
+		{
+			name: "invalid proxy_read_timeout",
+			target: TargetConfig{
+				Name:             "haloy-test-app",
+				Server:           "haloy.dev",
+				Image:            &Image{Repository: "nginx", Tag: "latest"},
+				ProxyReadTimeout: "0s",
+			},
+			format:      "yaml",
+			expectError: true,
+			errMsg:      "proxy_read_timeout",
+		},
+		{
+			name: "managed database",
+			target: TargetConfig{
+				Name:               "shop-db",
+				Server:             "haloy.dev",
+				Image:              &Image{Repository: "postgres", Tag: "17"},
+				NamingStrategy:     NamingStrategyStatic,
+				DeploymentStrategy: DeploymentStrategyReplace,
+				Database:           &DatabaseConfig{Engine: DatabaseEnginePostgres},
+			},
+			format:      "yaml",
+			expectError: false,
+		},
+		{
+			name: "managed database with dynamic naming",
+			target: TargetConfig{
+				Name:     "shop-db",
+				Server:   "haloy.dev",
+				Image:    &Image{Repository: "postgres", Tag: "17"},
+				Database: &DatabaseConfig{Engine: DatabaseEnginePostgres},
+			},
+			format:      "yaml",
+			expectError: true,
+			errMsg:      "database requires naming_strategy 'static'",
+		},
+		{
+			name: "database url from itself",
+			target: TargetConfig{
+				Name:            "shop",
+				Server:          "haloy.dev",
+				Image:           &Image{Repository: "shop", Tag: "latest"},
+				DatabaseURLFrom: "shop",
+			},
+			format:      "yaml",
+			expectError: true,
+			errMsg:      "database_url_from cannot name the target itself",
+		},
 	}
 
 	for _, tt := range tests {
@@ -362,6 +436,40 @@ func TestDomain_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:    "valid path prefix with strip",
+			domain:  Domain{Canonical: "example.com", PathPrefix: "/api", StripPrefix: true},
+			wantErr: false,
+		},
+		{
+			name:    "path prefix without leading slash",
+			domain:  Domain{Canonical: "example.com", PathPrefix: "api"},
+			wantErr: true,
+			errMsg:  "must start with '/'",
+		},
+		{
+			name:    "path prefix with query",
+			domain:  Domain{Canonical: "example.com", PathPrefix: "/api?x=1"},
+			wantErr: true,
+			errMsg:  "plain URL path",
+		},
+		{
+			name:    "strip prefix without path prefix",
+			domain:  Domain{Canonical: "example.com", PathPrefix: "/", StripPrefix: true},
+			wantErr: true,
+			errMsg:  "strip_prefix requires a path_prefix",
+		},
+		{
+			name:    "cloudflare cdn",
+			domain:  Domain{Canonical: "example.com", CDN: "cloudflare"},
+			wantErr: false,
+		},
+		{
+			name:    "unsupported cdn",
+			domain:  Domain{Canonical: "example.com", CDN: "fastly"},
+			wantErr: true,
+			errMsg:  "cdn 'fastly' for domain 'example.com' is not supported",
+		},
 		{
 			name: "invalid canonical domain",
 			domain: Domain{
@@ -828,6 +936,58 @@ func TestDeployConfig_Validate(t *testing.T) {
 			expectError: true,
 			errMsg:      "duplicate name 'app-one'",
 		},
+		{
+			name: "valid deploy options",
+			config: DeployConfig{
+				Targets: map[string]*TargetConfig{
+					"db":  {Server: "example.com"},
+					"api": {Server: "example.com"},
+					"web": {Server: "example.com"},
+				},
+				Deploy: &DeployOptions{Concurrency: 2, Order: []string{"db", "api"}},
+			},
+			expectError: false,
+		},
+		{
+			name: "deploy order with unknown target",
+			config: DeployConfig{
+				Targets: map[string]*TargetConfig{
+					"api": {Server: "example.com"},
+				},
+				Deploy: &DeployOptions{Order: []string{"db"}},
+			},
+			expectError: true,
+			errMsg:      "order lists unknown target 'db'",
+		},
+		{
+			name: "deploy order with duplicate target",
+			config: DeployConfig{
+				Targets: map[string]*TargetConfig{
+					"api": {Server: "example.com"},
+				},
+				Deploy: &DeployOptions{Order: []string{"api", "api"}},
+			},
+			expectError: true,
+			errMsg:      "order lists target 'api' more than once",
+		},
+		{
+			name: "deploy order in single-target config",
+			config: DeployConfig{
+				TargetConfig: TargetConfig{Name: "my-app"},
+				Deploy:       &DeployOptions{Order: []string{"my-app"}},
+			},
+			expectError: true,
+			errMsg:      "order only applies to configs with targets",
+		},
+		{
+			name: "negative deploy concurrency",
+			config: DeployConfig{
+				TargetConfig: TargetConfig{Name: "my-app"},
+				Deploy:       &DeployOptions{Concurrency: -1},
+			},
+			expectError: true,
+			errMsg:      "concurrency must be 0 or more",
+		},
 		{
 			name: "valid multi-target config ignores global name",
 			config: DeployConfig{
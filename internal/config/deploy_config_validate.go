@@ -3,136 +3,318 @@ package config
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"regexp"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/haloydev/haloy/internal/helpers"
 )
 
 func (dc *DeployConfig) Validate() error {
+	var errs []error
 	if len(dc.Targets) > 0 {
 		// Multi-target config: check for duplicate names
 		names := make(map[string]string) // name -> targetKey
-		for targetKey, target := range dc.Targets {
-			name := target.Name
+		for _, targetKey := range slices.Sorted(maps.Keys(dc.Targets)) {
+			name := dc.Targets[targetKey].Name
 			if name == "" {
 				name = targetKey // Will use target key as name after merge
 			}
 			if existingKey, exists := names[name]; exists {
-				return fmt.Errorf("duplicate name '%s' found in targets '%s' and '%s'", name, existingKey, targetKey)
+				errs = append(errs, fmt.Errorf("duplicate name '%s' found in targets '%s' and '%s'", name, existingKey, targetKey))
 			}
 			names[name] = targetKey
 		}
 	} else {
 		// Single-target config: require global name
 		if dc.Name == "" {
-			return errors.New("'name' is required for single-target configurations")
+			errs = append(errs, errors.New("'name' is required for single-target configurations"))
 		}
 	}
+	if dc.Deploy != nil {
+		if err := dc.Deploy.validate(dc.Targets); err != nil {
+			errs = append(errs, fmt.Errorf("invalid deploy options: %w", err))
+		}
+	}
+	for i, plugin := range dc.Plugins {
+		if strings.TrimSpace(plugin) == "" {
+			errs = append(errs, fmt.Errorf("plugins[%d] is empty", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (o *DeployOptions) validate(targets map[string]*TargetConfig) error {
+	if o.Concurrency < 0 {
+		return fmt.Errorf("concurrency must be 0 or more, got %d", o.Concurrency)
+	}
+	if len(o.Order) > 0 && len(targets) == 0 {
+		return errors.New("order only applies to configs with targets")
+	}
+	seen := make(map[string]bool, len(o.Order))
+	for _, targetName := range o.Order {
+		if _, exists := targets[targetName]; !exists {
+			return fmt.Errorf("order lists unknown target '%s'", targetName)
+		}
+		if seen[targetName] {
+			return fmt.Errorf("order lists target '%s' more than once", targetName)
+		}
+		seen[targetName] = true
+	}
 	return nil
 }
 
 func (tc *TargetConfig) Validate(format string) error {
+	// Every problem is reported, so a config can be fixed in one go.
+	var errs []error
 	if tc.Name == "" {
-		return errors.New("app 'name' is required")
+		errs = append(errs, errors.New("app 'name' is required"))
+	} else if !isValidAppName(tc.Name) {
+		errs = append(errs, fmt.Errorf("invalid app name '%s'; must contain only alphanumeric characters, hyphens, and underscores", tc.Name))
 	}
 
 	if tc.Server == "" {
-		return errors.New("server is required")
+		errs = append(errs, errors.New("server is required"))
 	}
 
-	if !isValidAppName(tc.Name) {
-		return fmt.Errorf("invalid app name '%s'; must contain only alphanumeric characters, hyphens, and underscores", tc.Name)
+	if tc.APIToken != nil {
+		if err := tc.APIToken.validateNotServerSecret(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", GetFieldNameForFormat(TargetConfig{}, "APIToken", format), err))
+		}
 	}
 
-	if tc.Preset != "" {
-		validPresets := []Preset{PresetDatabase, PresetService}
-		if !slices.Contains(validPresets, tc.Preset) {
-			return fmt.Errorf("%s is an invalid preset", tc.Preset)
-		}
+	if err := validateEnum("preset", tc.Preset, PresetDatabase, PresetService); err != nil {
+		errs = append(errs, err)
 	}
 
 	if tc.Image != nil && tc.ImageKey != "" {
-		return fmt.Errorf("cannot specify both 'image' and 'imageRef' in target config")
+		errs = append(errs, fmt.Errorf("cannot specify both 'image' and 'imageRef' in target config"))
 	}
 
 	if tc.Image != nil {
 		if err := tc.Image.Validate(format); err != nil {
-			return fmt.Errorf("invalid image: %w", err)
+			errs = append(errs, fmt.Errorf("invalid image: %w", err))
 		}
 	}
 
-	if tc.NamingStrategy != "" {
-		validNamingStrategies := []NamingStrategy{NamingStrategyDynamic, NamingStrategyStatic}
-		if !slices.Contains(validNamingStrategies, tc.NamingStrategy) {
-			return fmt.Errorf("%s must be 'dynamic' or 'static', got %s", GetFieldNameForFormat(TargetConfig{}, "NamingStrategy", format), tc.NamingStrategy)
-		}
+	if err := validateEnum(GetFieldNameForFormat(TargetConfig{}, "NamingStrategy", format), tc.NamingStrategy, NamingStrategyDynamic, NamingStrategyStatic); err != nil {
+		errs = append(errs, err)
 	}
 
 	// We can't use default deployment strategy if we want to use static naming because we can't have two container running with the same name.
 	if tc.NamingStrategy == NamingStrategyStatic && tc.DeploymentStrategy != DeploymentStrategyReplace {
-		return fmt.Errorf("%s 'static' requires %s 'replace' (you cannot use rolling updates with fixed container names)i", GetFieldNameForFormat(TargetConfig{}, "NamingStrategy", format), GetFieldNameForFormat(TargetConfig{}, "DeploymentStrategy", format))
+		errs = append(errs, fmt.Errorf("%s 'static' requires %s 'replace' (you cannot use rolling updates with fixed container names)i", GetFieldNameForFormat(TargetConfig{}, "NamingStrategy", format), GetFieldNameForFormat(TargetConfig{}, "DeploymentStrategy", format)))
 	}
 
 	if tc.NamingStrategy == NamingStrategyStatic && tc.Replicas != nil && *tc.Replicas > 1 {
-		return fmt.Errorf("%s 'static' does not support multiple replicas", GetFieldNameForFormat(TargetConfig{}, "NamingStrategy", format))
+		errs = append(errs, fmt.Errorf("%s 'static' does not support multiple replicas", GetFieldNameForFormat(TargetConfig{}, "NamingStrategy", format)))
 	}
 
-	if tc.DeploymentStrategy != "" {
-		validDeploymentStrategies := []DeploymentStrategy{DeploymentStrategyRolling, DeploymentStrategyReplace}
-		if !slices.Contains(validDeploymentStrategies, tc.DeploymentStrategy) {
-			return fmt.Errorf("%s must be 'rolling' or 'replace', got '%s'", GetFieldNameForFormat(TargetConfig{}, "DeploymentStrategy", format), tc.DeploymentStrategy)
-		}
+	if err := validateEnum("rollout", tc.Rollout, RolloutParallel, RolloutStaged); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateEnum(GetFieldNameForFormat(TargetConfig{}, "DeploymentStrategy", format), tc.DeploymentStrategy, DeploymentStrategyRolling, DeploymentStrategyReplace); err != nil {
+		errs = append(errs, err)
 	}
 
 	if len(tc.Domains) > 0 {
+		routes := make(map[string]bool, len(tc.Domains))
 		for _, domain := range tc.Domains {
 			if err := domain.Validate(); err != nil {
-				return err
+				errs = append(errs, err)
+				continue
+			}
+			route := domain.Canonical + domain.NormalizedPathPrefix()
+			if routes[route] {
+				errs = append(errs, fmt.Errorf("domain '%s' is listed more than once with the same path_prefix", domain.Canonical))
 			}
+			routes[route] = true
+		}
+	}
+
+	if err := validateRedirects(tc.Redirects, tc.Domains, format); err != nil {
+		errs = append(errs, err)
+	}
+	if tc.URLNormalization != nil {
+		if err := tc.URLNormalization.Validate(format); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if tc.ClientAuth != nil {
+		if err := tc.ClientAuth.Validate(tc.Domains, format); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if tc.SSO != nil {
+		if err := tc.SSO.Validate(tc.Domains, format); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
 	for j, envVar := range tc.Env {
 		if err := envVar.Validate(format); err != nil {
-			return fmt.Errorf("env[%d]: %w", j, err)
+			errs = append(errs, fmt.Errorf("env[%d]: %w", j, err))
 		}
 	}
 
 	if tc.Port != "" {
 		if err := helpers.ValidatePort(tc.Port.String()); err != nil {
-			return fmt.Errorf("invalid %s: %w", GetFieldNameForFormat(TargetConfig{}, "Port", format), err)
+			errs = append(errs, fmt.Errorf("invalid %s: %w", GetFieldNameForFormat(TargetConfig{}, "Port", format), err))
 		}
 	}
 
 	for _, volume := range tc.Volumes {
 		if _, err := ParseVolumeSpec(volume); err != nil {
-			return err
+			errs = append(errs, err)
+		}
+	}
+
+	if tc.MigrationLock != "" && !isValidAppName(tc.MigrationLock) {
+		errs = append(errs, fmt.Errorf("invalid %s '%s'; must contain only alphanumeric characters, hyphens, and underscores",
+			GetFieldNameForFormat(TargetConfig{}, "MigrationLock", format), tc.MigrationLock))
+	}
+
+	if tc.Database != nil {
+		if err := tc.Database.Validate(tc.Name); err != nil {
+			errs = append(errs, err)
+		}
+		// Targets reach the database by its container name.
+		if tc.NamingStrategy != NamingStrategyStatic {
+			errs = append(errs, fmt.Errorf("database requires %s 'static'; use preset: database", GetFieldNameForFormat(TargetConfig{}, "NamingStrategy", format)))
+		}
+	}
+
+	if tc.Snapshots != nil {
+		if err := tc.Snapshots.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if tc.Preview != nil {
+		if err := tc.Preview.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if tc.DatabaseURLFrom != "" {
+		if !isValidAppName(tc.DatabaseURLFrom) {
+			errs = append(errs, fmt.Errorf("invalid %s '%s'; must be the name of a database app",
+				GetFieldNameForFormat(TargetConfig{}, "DatabaseURLFrom", format), tc.DatabaseURLFrom))
+		}
+		if tc.DatabaseURLFrom == tc.Name {
+			errs = append(errs, fmt.Errorf("%s cannot name the target itself", GetFieldNameForFormat(TargetConfig{}, "DatabaseURLFrom", format)))
 		}
 	}
 
 	if tc.HealthCheckPath != "" {
 		if tc.HealthCheckPath[0] != '/' {
-			return fmt.Errorf("%s must start with a slash", GetFieldNameForFormat(TargetConfig{}, "HealthCheckPath", format))
+			errs = append(errs, fmt.Errorf("%s must start with a slash", GetFieldNameForFormat(TargetConfig{}, "HealthCheckPath", format)))
+		}
+	}
+
+	if tc.ClientMaxBodySize != "" {
+		if size, err := helpers.ParseBytes(tc.ClientMaxBodySize); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", GetFieldNameForFormat(TargetConfig{}, "ClientMaxBodySize", format), err))
+		} else if size == 0 {
+			errs = append(errs, fmt.Errorf("%s must be greater than 0", GetFieldNameForFormat(TargetConfig{}, "ClientMaxBodySize", format)))
+		}
+	}
+
+	proxyTimeouts := []struct {
+		field string
+		value string
+	}{
+		{"ProxyReadTimeout", tc.ProxyReadTimeout},
+		{"ProxySendTimeout", tc.ProxySendTimeout},
+	}
+	for _, timeout := range proxyTimeouts {
+		if timeout.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(timeout.value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s '%s': %w", GetFieldNameForFormat(TargetConfig{}, timeout.field, format), timeout.value, err))
+		} else if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be greater than 0", GetFieldNameForFormat(TargetConfig{}, timeout.field, format)))
+		}
+	}
+
+	if tc.HealthCheck != nil {
+		if err := tc.HealthCheck.Validate(format); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if tc.HealthProbe != nil {
+		if err := tc.HealthProbe.Validate(format); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if tc.Startup != nil {
+		if err := tc.Startup.Validate(format); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := tc.validatePorts(format); err != nil {
+		errs = append(errs, err)
+	}
+
+	if tc.User != "" {
+		if err := validateUser(tc.User, format); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := validateGroupAdd(tc.GroupAdd, format); err != nil {
+		errs = append(errs, err)
+	}
+
+	if tc.LoggingDriver != nil {
+		if err := tc.LoggingDriver.Validate(format); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := validateLabels(tc.Labels, format); err != nil {
+		errs = append(errs, err)
+	}
+
+	if tc.Security != nil {
+		if err := tc.Security.Validate(format); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
 	if tc.Replicas != nil {
 		if int(*tc.Replicas) < 1 {
-			return errors.New("replicas must be at least 1")
+			errs = append(errs, errors.New("replicas must be at least 1"))
 		}
 	}
 
+	sidecarNames := make(map[string]bool, len(tc.Sidecars))
+	for i := range tc.Sidecars {
+		sidecar := &tc.Sidecars[i]
+		if err := sidecar.Validate(format); err != nil {
+			errs = append(errs, err)
+		}
+		if sidecarNames[sidecar.Name] {
+			errs = append(errs, fmt.Errorf("duplicate sidecar name '%s'", sidecar.Name))
+		}
+		sidecarNames[sidecar.Name] = true
+	}
+
 	if tc.MinReadySeconds != nil {
 		if *tc.MinReadySeconds < 0 {
-			return fmt.Errorf("%s must be >= 0", GetFieldNameForFormat(TargetConfig{}, "MinReadySeconds", format))
+			errs = append(errs, fmt.Errorf("%s must be >= 0", GetFieldNameForFormat(TargetConfig{}, "MinReadySeconds", format)))
 		}
 		if *tc.MinReadySeconds > 600 {
-			return fmt.Errorf("%s must not exceed 600 (10 minutes)", GetFieldNameForFormat(TargetConfig{}, "MinReadySeconds", format))
+			errs = append(errs, fmt.Errorf("%s must not exceed 600 (10 minutes)", GetFieldNameForFormat(TargetConfig{}, "MinReadySeconds", format)))
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 func isValidAppName(name string) bool {
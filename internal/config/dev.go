@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// DevDir returns the directory 'haloyd init --dev' creates the data and
+// config directories in.
+func DevDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, constants.DevDir), nil
+}
+
+// publishPorts is set by EnablePublishContainerPorts.
+var publishPorts atomic.Bool
+
+// PublishContainerPorts reports whether haloyd publishes app container ports
+// on the loopback interface and reaches containers through them instead of
+// their haloy network IP. Docker Desktop on macOS and Windows runs containers
+// in a VM whose network isn't routable from the host.
+func PublishContainerPorts() bool {
+	return publishPorts.Load() || os.Getenv(constants.EnvVarPublishPorts) == "true"
+}
+
+// EnablePublishContainerPorts turns on port publishing for the rest of the
+// process, for runtimes detected at startup to need it, like rootless Docker
+// and Podman.
+func EnablePublishContainerPorts() {
+	publishPorts.Store(true)
+}
+
+// ProxyListenAddrs returns the HTTP and HTTPS addresses haloy-proxy listens on.
+func ProxyListenAddrs() (httpAddr, httpsAddr string) {
+	httpAddr, httpsAddr = ":80", ":443"
+	if addr := os.Getenv(constants.EnvVarProxyHTTPAddr); addr != "" {
+		httpAddr = addr
+	}
+	if addr := os.Getenv(constants.EnvVarProxyHTTPSAddr); addr != "" {
+		httpsAddr = addr
+	}
+	return httpAddr, httpsAddr
+}
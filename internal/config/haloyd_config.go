@@ -2,9 +2,15 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/haloydev/haloy/internal/constants"
@@ -17,10 +23,39 @@ import (
 type HaloydConfig struct {
 	API           HaloydAPIConfig     `json:"api" yaml:"api" toml:"api"`
 	HealthMonitor HealthMonitorConfig `json:"health_monitor" yaml:"health_monitor" toml:"health_monitor"`
+	ImageCache    ImageCacheConfig    `json:"image_cache" yaml:"image_cache" toml:"image_cache"`
+	Disk          DiskConfig          `json:"disk" yaml:"disk" toml:"disk"`
+	Certificates  CertificatesConfig  `json:"certificates" yaml:"certificates" toml:"certificates"`
+	OnDemandTLS   OnDemandTLSConfig   `json:"on_demand_tls" yaml:"on_demand_tls" toml:"on_demand_tls"`
+	TLS           TLSConfig           `json:"tls" yaml:"tls" toml:"tls"`
+	GC            GCConfig            `json:"gc" yaml:"gc" toml:"gc"`
+	Cluster       ClusterConfig       `json:"cluster" yaml:"cluster" toml:"cluster"`
+	// DatabaseBackups backs up the targets with a database section.
+	DatabaseBackups DatabaseBackupsConfig `json:"database_backups" yaml:"database_backups" toml:"database_backups"`
+	// VolumeSnapshots holds the store targets with snapshots.remote upload to.
+	VolumeSnapshots VolumeSnapshotsConfig `json:"volume_snapshots" yaml:"volume_snapshots" toml:"volume_snapshots"`
+	// GitOps deploys what a git repository declares for this server.
+	GitOps GitOpsConfig `json:"gitops" yaml:"gitops" toml:"gitops"`
+	// Shutdown controls how haloyd stops on SIGTERM.
+	Shutdown ShutdownConfig `json:"shutdown" yaml:"shutdown" toml:"shutdown"`
+	// AccessLog controls the line haloy-proxy logs for every request.
+	AccessLog AccessLogConfig `json:"access_log" yaml:"access_log" toml:"access_log"`
+	// UsageHistory records the CPU and memory usage of apps over time.
+	UsageHistory UsageHistoryConfig `json:"usage_history" yaml:"usage_history" toml:"usage_history"`
+	// Uptime tracks the availability of apps against an objective.
+	Uptime UptimeConfig `json:"uptime" yaml:"uptime" toml:"uptime"`
+	// Alerts notifies about apps whose error rate or latency exceed rules.
+	Alerts AlertsConfig `json:"alerts" yaml:"alerts" toml:"alerts"`
 }
 
 type HaloydAPIConfig struct {
 	Domain string `json:"domain" yaml:"domain" toml:"domain"`
+	// AllowedNetworks limits the API domain to clients in these CIDRs, such
+	// as a WireGuard network; other clients get 404. Empty allows everyone.
+	AllowedNetworks []string `json:"allowed_networks,omitempty" yaml:"allowed_networks,omitempty" toml:"allowed_networks,omitempty"`
+	// AllowedOrigins are the browser origins, such as
+	// https://dashboard.example.com, that may call the API with CORS.
+	AllowedOrigins []string `json:"allowed_origins,omitempty" yaml:"allowed_origins,omitempty" toml:"allowed_origins,omitempty"`
 }
 
 // HealthMonitorConfig holds configuration for continuous health monitoring.
@@ -30,6 +65,83 @@ type HealthMonitorConfig struct {
 	Fall     int    `json:"fall" yaml:"fall" toml:"fall"`             // Mark unhealthy after N failures
 	Rise     int    `json:"rise" yaml:"rise" toml:"rise"`             // Mark healthy after N successes
 	Timeout  string `json:"timeout" yaml:"timeout" toml:"timeout"`    // Per-check timeout, e.g., "5s"
+
+	Passive   PassiveHealthConfig `json:"passive" yaml:"passive" toml:"passive"`
+	CrashLoop CrashLoopConfig     `json:"crash_loop" yaml:"crash_loop" toml:"crash_loop"`
+}
+
+// CrashLoopConfig sets when a container that Docker keeps restarting is
+// considered crash looping and taken out of rotation.
+type CrashLoopConfig struct {
+	Restarts int    `json:"restarts" yaml:"restarts" toml:"restarts"` // Restarts within the window (default 5)
+	Window   string `json:"window" yaml:"window" toml:"window"`       // e.g. "10m" (default)
+}
+
+// GetRestarts returns the restart threshold, defaulting to 5 if not set.
+func (c *CrashLoopConfig) GetRestarts() int {
+	if c.Restarts <= 0 {
+		return 5
+	}
+	return c.Restarts
+}
+
+// GetWindow returns the window restarts are counted in, defaulting to 10m
+// if not set or invalid.
+func (c *CrashLoopConfig) GetWindow() time.Duration {
+	d, err := time.ParseDuration(c.Window)
+	if err != nil || d <= 0 {
+		return 10 * time.Minute
+	}
+	return d
+}
+
+func (c *CrashLoopConfig) Validate() error {
+	if c.Restarts < 0 {
+		return fmt.Errorf("invalid health_monitor.crash_loop.restarts %d: must be at least 1", c.Restarts)
+	}
+	if c.Window != "" {
+		if d, err := time.ParseDuration(c.Window); err != nil || d < time.Minute {
+			return fmt.Errorf("invalid health_monitor.crash_loop.window '%s': must be a duration of at least 1m", c.Window)
+		}
+	}
+	return nil
+}
+
+// PassiveHealthConfig sets when haloy-proxy flags a backend based on the
+// traffic it serves, between the health monitor's active checks. Empty fields
+// keep the proxy's defaults.
+type PassiveHealthConfig struct {
+	MaxLatency   string  `json:"max_latency" yaml:"max_latency" toml:"max_latency"`          // p95 response time, e.g. "2s" (default 5s)
+	MaxErrorRate float64 `json:"max_error_rate" yaml:"max_error_rate" toml:"max_error_rate"` // Share of failed requests, 0-1 (default 0.5)
+	// Eject takes a flagged backend out of rotation for a while instead of
+	// only logging a warning. The last backend of a route is never ejected.
+	Eject bool `json:"eject" yaml:"eject" toml:"eject"`
+}
+
+// IsSet reports whether any passive health setting is configured.
+func (c *PassiveHealthConfig) IsSet() bool {
+	return c.MaxLatency != "" || c.MaxErrorRate != 0 || c.Eject
+}
+
+// GetMaxLatency returns the latency threshold, or 0 for the proxy's default.
+func (c *PassiveHealthConfig) GetMaxLatency() time.Duration {
+	d, err := time.ParseDuration(c.MaxLatency)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+func (c *PassiveHealthConfig) Validate() error {
+	if c.MaxLatency != "" {
+		if d, err := time.ParseDuration(c.MaxLatency); err != nil || d <= 0 {
+			return fmt.Errorf("invalid health_monitor.passive.max_latency '%s': must be a positive duration", c.MaxLatency)
+		}
+	}
+	if c.MaxErrorRate < 0 || c.MaxErrorRate > 1 {
+		return fmt.Errorf("invalid health_monitor.passive.max_error_rate %v: must be between 0 and 1", c.MaxErrorRate)
+	}
+	return nil
 }
 
 // IsEnabled returns whether health monitoring is enabled.
@@ -83,6 +195,851 @@ func (c *HealthMonitorConfig) GetRise() int {
 	return c.Rise
 }
 
+// DefaultImageCacheMaxSize bounds the pull-through image cache when max_size is not set.
+const DefaultImageCacheMaxSize = "10GiB"
+
+// ImageCacheConfig controls the pull-through cache for registry images.
+// Pulled images are stored in the layer store so later pulls of the same
+// digest can be served locally instead of hitting the registry.
+type ImageCacheConfig struct {
+	Enabled *bool  `json:"enabled" yaml:"enabled" toml:"enabled"`    // nil means disabled (default)
+	MaxSize string `json:"max_size" yaml:"max_size" toml:"max_size"` // e.g., "10GiB"
+}
+
+// IsEnabled returns whether the pull-through cache is enabled.
+// Defaults to false since the cache trades disk space for fewer registry pulls.
+func (c *ImageCacheConfig) IsEnabled() bool {
+	if c.Enabled == nil {
+		return false
+	}
+	return *c.Enabled
+}
+
+// GetMaxSizeBytes returns the cache size bound in bytes.
+// Returns the default of 10GiB if not set or invalid.
+func (c *ImageCacheConfig) GetMaxSizeBytes() uint64 {
+	size := c.MaxSize
+	if size == "" {
+		size = DefaultImageCacheMaxSize
+	}
+	n, err := helpers.ParseBytes(size)
+	if err != nil {
+		n, _ = helpers.ParseBytes(DefaultImageCacheMaxSize)
+	}
+	return n
+}
+
+// DefaultMinFreeSpace is the free space deploys require when min_free_space is not set.
+const DefaultMinFreeSpace = "1GiB"
+
+// DiskConfig controls the free-space check haloyd runs before accepting a deploy.
+type DiskConfig struct {
+	MinFreeSpace string `json:"min_free_space" yaml:"min_free_space" toml:"min_free_space"` // e.g., "5GiB", "0" disables the check
+}
+
+// GetMinFreeSpaceBytes returns the free space a deploy requires on the Docker
+// and haloy data filesystems. Returns the default of 1GiB if not set or invalid.
+func (c *DiskConfig) GetMinFreeSpaceBytes() uint64 {
+	size := c.MinFreeSpace
+	if size == "" {
+		size = DefaultMinFreeSpace
+	}
+	n, err := helpers.ParseBytes(size)
+	if err != nil {
+		n, _ = helpers.ParseBytes(DefaultMinFreeSpace)
+	}
+	return n
+}
+
+// DefaultGCOlderThan is how old an orphan must be before it's collected when
+// gc.older_than is not set.
+const DefaultGCOlderThan = 7 * 24 * time.Hour
+
+// GCConfig schedules collection of orphaned containers, images, volumes and
+// certificates in haloyd's periodic maintenance.
+type GCConfig struct {
+	Enabled   *bool  `json:"enabled" yaml:"enabled" toml:"enabled"`          // nil means disabled (default)
+	OlderThan string `json:"older_than" yaml:"older_than" toml:"older_than"` // e.g. "72h", default 168h
+}
+
+// IsEnabled returns whether orphans are collected during maintenance.
+func (c *GCConfig) IsEnabled() bool {
+	return c.Enabled != nil && *c.Enabled
+}
+
+// GetOlderThan returns the age threshold, defaulting to 7 days if not set or
+// invalid.
+func (c *GCConfig) GetOlderThan() time.Duration {
+	d, err := time.ParseDuration(c.OlderThan)
+	if err != nil || d <= 0 {
+		return DefaultGCOlderThan
+	}
+	return d
+}
+
+func (c *GCConfig) Validate() error {
+	if c.OlderThan != "" {
+		if d, err := time.ParseDuration(c.OlderThan); err != nil || d < time.Hour {
+			return fmt.Errorf("invalid gc.older_than '%s': must be a duration of at least 1h", c.OlderThan)
+		}
+	}
+	return nil
+}
+
+const (
+	// DefaultShutdownGracePeriod is how long haloyd waits for running
+	// deployments and API requests when shutdown.grace_period is not set.
+	DefaultShutdownGracePeriod = 30 * time.Second
+	// MaxShutdownGracePeriod stays below the stop timeout of the haloyd
+	// service, after which the service manager kills it.
+	MaxShutdownGracePeriod = 5 * time.Minute
+)
+
+// ShutdownConfig controls haloyd's shutdown. New deployments are refused
+// right away; running ones and API requests get the grace period to finish.
+type ShutdownConfig struct {
+	GracePeriod string `json:"grace_period" yaml:"grace_period" toml:"grace_period"` // e.g. "2m", default 30s
+}
+
+// GetGracePeriod returns the grace period, defaulting to 30s if not set or
+// invalid.
+func (c *ShutdownConfig) GetGracePeriod() time.Duration {
+	d, err := time.ParseDuration(c.GracePeriod)
+	if err != nil || d <= 0 || d > MaxShutdownGracePeriod {
+		return DefaultShutdownGracePeriod
+	}
+	return d
+}
+
+func (c *ShutdownConfig) Validate() error {
+	if c.GracePeriod != "" {
+		if d, err := time.ParseDuration(c.GracePeriod); err != nil || d <= 0 || d > MaxShutdownGracePeriod {
+			return fmt.Errorf("invalid shutdown.grace_period '%s': must be a positive duration of at most %s", c.GracePeriod, MaxShutdownGracePeriod)
+		}
+	}
+	return nil
+}
+
+// AccessLogConfig controls haloy-proxy's access log. Under heavy traffic,
+// sampling keeps the log and its cost down while still logging every
+// request that failed.
+type AccessLogConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled" toml:"enabled"` // nil means enabled (default)
+	// Sample logs one in every Sample requests answered with a status below
+	// 400; 0 or 1 logs them all. Requests with a 4xx or 5xx status are
+	// always logged.
+	Sample int `json:"sample" yaml:"sample" toml:"sample"`
+}
+
+// IsEnabled returns whether haloy-proxy logs requests. Defaults to true if
+// not explicitly set.
+func (c *AccessLogConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// IsSet reports whether any access log setting is configured.
+func (c *AccessLogConfig) IsSet() bool {
+	return c.Enabled != nil || c.Sample != 0
+}
+
+func (c *AccessLogConfig) Validate() error {
+	if c.Sample < 0 {
+		return fmt.Errorf("invalid access_log.sample %d: must be 0 or more", c.Sample)
+	}
+	return nil
+}
+
+const (
+	// DefaultUsageHistoryInterval is how often app usage is sampled when
+	// usage_history.interval is not set.
+	DefaultUsageHistoryInterval = time.Minute
+	// DefaultUsageHistoryRetention is how long app usage is kept when
+	// usage_history.retention is not set.
+	DefaultUsageHistoryRetention = 7 * 24 * time.Hour
+)
+
+// UsageHistoryConfig controls the CPU and memory usage haloyd records for
+// each app, which haloy top --history shows. Samples are kept as taken for a
+// day and as hourly averages after that.
+type UsageHistoryConfig struct {
+	Enabled   *bool  `json:"enabled" yaml:"enabled" toml:"enabled"`       // nil means enabled (default)
+	Interval  string `json:"interval" yaml:"interval" toml:"interval"`    // e.g. "30s", default 1m
+	Retention string `json:"retention" yaml:"retention" toml:"retention"` // e.g. "720h", default 168h
+}
+
+// IsEnabled returns whether app usage is recorded. Defaults to true if not
+// explicitly set.
+func (c *UsageHistoryConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// GetInterval returns the sampling interval, defaulting to 1m if not set or
+// invalid.
+func (c *UsageHistoryConfig) GetInterval() time.Duration {
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil || d < 10*time.Second {
+		return DefaultUsageHistoryInterval
+	}
+	return d
+}
+
+// GetRetention returns how long usage is kept, defaulting to 7 days if not
+// set or invalid.
+func (c *UsageHistoryConfig) GetRetention() time.Duration {
+	d, err := time.ParseDuration(c.Retention)
+	if err != nil || d < 24*time.Hour {
+		return DefaultUsageHistoryRetention
+	}
+	return d
+}
+
+func (c *UsageHistoryConfig) Validate() error {
+	if c.Interval != "" {
+		if d, err := time.ParseDuration(c.Interval); err != nil || d < 10*time.Second {
+			return fmt.Errorf("invalid usage_history.interval '%s': must be a duration of at least 10s", c.Interval)
+		}
+	}
+	if c.Retention != "" {
+		if d, err := time.ParseDuration(c.Retention); err != nil || d < 24*time.Hour {
+			return fmt.Errorf("invalid usage_history.retention '%s': must be a duration of at least 24h", c.Retention)
+		}
+	}
+	return nil
+}
+
+const (
+	// DefaultUptimeObjective is the availability objective, in percent, when
+	// uptime.objective is not set.
+	DefaultUptimeObjective = 99.9
+	// DefaultUptimeBurnRate is the rate the error budget is used up at over
+	// an hour that triggers an alert, when uptime.alert.burn_rate is not
+	// set. At 14.4 a 30-day budget is 2% gone in that hour.
+	DefaultUptimeBurnRate = 14.4
+)
+
+// UptimeConfig controls the uptime haloyd tracks for each app: the minutes
+// it had no healthy container and the requests the proxy failed to serve,
+// measured against an objective that leaves an error budget.
+type UptimeConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled" toml:"enabled"` // nil means enabled (default)
+	// Objective is the availability objective in percent, e.g. 99.9.
+	Objective float64           `json:"objective" yaml:"objective" toml:"objective"`
+	Alert     UptimeAlertConfig `json:"alert" yaml:"alert" toml:"alert"`
+}
+
+// UptimeAlertConfig sends a notification when an app uses up its error
+// budget too fast.
+type UptimeAlertConfig struct {
+	// NotifyURL is POSTed a JSON alert; no alerts are sent without it.
+	NotifyURL string `json:"notify_url" yaml:"notify_url" toml:"notify_url"`
+	// BurnRate is how many times faster than the objective allows the error
+	// budget may be used up over an hour before alerting, default 14.4.
+	BurnRate float64 `json:"burn_rate" yaml:"burn_rate" toml:"burn_rate"`
+}
+
+// IsEnabled returns whether uptime is tracked. Defaults to true if not
+// explicitly set.
+func (c *UptimeConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// GetObjective returns the availability objective in percent, defaulting to
+// 99.9 if not set.
+func (c *UptimeConfig) GetObjective() float64 {
+	if c.Objective <= 0 {
+		return DefaultUptimeObjective
+	}
+	return c.Objective
+}
+
+// GetBurnRate returns the burn rate that triggers an alert, defaulting to
+// 14.4 if not set.
+func (c *UptimeAlertConfig) GetBurnRate() float64 {
+	if c.BurnRate <= 0 {
+		return DefaultUptimeBurnRate
+	}
+	return c.BurnRate
+}
+
+func (c *UptimeConfig) Validate() error {
+	if c.Objective < 0 || c.Objective >= 100 {
+		return fmt.Errorf("invalid uptime.objective %v: must be a percentage between 0 and 100, like 99.9", c.Objective)
+	}
+	if c.Alert.BurnRate < 0 {
+		return fmt.Errorf("invalid uptime.alert.burn_rate %v: must be positive", c.Alert.BurnRate)
+	}
+	if c.Alert.NotifyURL != "" {
+		if u, err := url.Parse(c.Alert.NotifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid uptime.alert.notify_url '%s': must be an http or https URL", c.Alert.NotifyURL)
+		}
+	}
+	return nil
+}
+
+// Alert notification formats.
+const (
+	AlertFormatWebhook = "webhook" // the alert as JSON
+	AlertFormatSlack   = "slack"   // a Slack incoming webhook message
+)
+
+const (
+	// DefaultAlertWindow is the window a rule is evaluated over when its
+	// window is not set.
+	DefaultAlertWindow = 5 * time.Minute
+	// MaxAlertWindow bounds rule windows, which haloyd keeps the proxy's
+	// counters for.
+	MaxAlertWindow = time.Hour
+	// DefaultAlertMinRequests is how many requests an app needs within the
+	// window before a rule is evaluated, when min_requests is not set.
+	DefaultAlertMinRequests = 20
+	// MaxAlertP95Latency is the highest latency threshold the proxy's latency
+	// histogram can tell apart.
+	MaxAlertP95Latency = 10 * time.Second
+)
+
+// AlertsConfig holds the rules haloyd checks the traffic haloy-proxy sends
+// each app against, and where it notifies when one is broken.
+type AlertsConfig struct {
+	// NotifyURL is POSTed when an app starts and stops breaking a rule;
+	// without it, alerts are only logged.
+	NotifyURL string `json:"notify_url" yaml:"notify_url" toml:"notify_url"`
+	// Format is "slack" or "webhook". Defaults to slack for Slack webhook
+	// URLs and to webhook otherwise.
+	Format string      `json:"format" yaml:"format" toml:"format"`
+	Rules  []AlertRule `json:"rules" yaml:"rules" toml:"rules"`
+}
+
+// AlertRule is broken by an app whose requests over the window failed more
+// often than ErrorRate or had a p95 latency above P95Latency.
+type AlertRule struct {
+	Name string `json:"name" yaml:"name" toml:"name"`
+	// Apps limits the rule to these apps; empty means every app.
+	Apps   []string `json:"apps" yaml:"apps" toml:"apps"`
+	Window string   `json:"window" yaml:"window" toml:"window"` // e.g. "10m", default 5m
+	// ErrorRate is the highest acceptable percentage of requests answered
+	// with a 5xx or failing in the proxy; 0 doesn't check it.
+	ErrorRate float64 `json:"error_rate" yaml:"error_rate" toml:"error_rate"`
+	// P95Latency is the highest acceptable p95 time to response headers,
+	// e.g. "2s"; empty doesn't check it.
+	P95Latency  string `json:"p95_latency" yaml:"p95_latency" toml:"p95_latency"`
+	MinRequests int    `json:"min_requests" yaml:"min_requests" toml:"min_requests"` // default 20
+}
+
+// IsEnabled returns whether any alert rules are configured.
+func (c *AlertsConfig) IsEnabled() bool {
+	return len(c.Rules) > 0
+}
+
+// GetFormat returns the notification format, detecting Slack webhook URLs
+// if not set.
+func (c *AlertsConfig) GetFormat() string {
+	if c.Format != "" {
+		return c.Format
+	}
+	if u, err := url.Parse(c.NotifyURL); err == nil && u.Host == "hooks.slack.com" {
+		return AlertFormatSlack
+	}
+	return AlertFormatWebhook
+}
+
+// GetWindow returns the window the rule is evaluated over, defaulting to 5m
+// if not set or invalid.
+func (r *AlertRule) GetWindow() time.Duration {
+	d, err := time.ParseDuration(r.Window)
+	if err != nil || d <= 0 {
+		return DefaultAlertWindow
+	}
+	return d
+}
+
+// GetP95Latency returns the latency threshold, 0 if not set.
+func (r *AlertRule) GetP95Latency() time.Duration {
+	d, _ := time.ParseDuration(r.P95Latency)
+	return d
+}
+
+// GetMinRequests returns the requests needed to evaluate the rule,
+// defaulting to 20 if not set.
+func (r *AlertRule) GetMinRequests() int {
+	if r.MinRequests <= 0 {
+		return DefaultAlertMinRequests
+	}
+	return r.MinRequests
+}
+
+// AppliesTo reports whether the rule checks appName.
+func (r *AlertRule) AppliesTo(appName string) bool {
+	return len(r.Apps) == 0 || slices.Contains(r.Apps, appName)
+}
+
+func (c *AlertsConfig) Validate() error {
+	if c.NotifyURL != "" {
+		if u, err := url.Parse(c.NotifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid alerts.notify_url '%s': must be an http or https URL", c.NotifyURL)
+		}
+	}
+	switch c.Format {
+	case "", AlertFormatWebhook, AlertFormatSlack:
+	default:
+		return fmt.Errorf("invalid alerts.format '%s': must be '%s' or '%s'", c.Format, AlertFormatWebhook, AlertFormatSlack)
+	}
+
+	names := make(map[string]bool, len(c.Rules))
+	for i, r := range c.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("alerts.rules[%d]: name is required", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("alerts.rules[%d]: duplicate name '%s'", i, r.Name)
+		}
+		names[r.Name] = true
+
+		if r.ErrorRate == 0 && r.P95Latency == "" {
+			return fmt.Errorf("alert rule '%s': set error_rate, p95_latency or both", r.Name)
+		}
+		if r.ErrorRate < 0 || r.ErrorRate > 100 {
+			return fmt.Errorf("alert rule '%s': invalid error_rate %v, must be a percentage", r.Name, r.ErrorRate)
+		}
+		if r.P95Latency != "" {
+			if d, err := time.ParseDuration(r.P95Latency); err != nil || d <= 0 || d > MaxAlertP95Latency {
+				return fmt.Errorf("alert rule '%s': invalid p95_latency '%s', must be a duration up to %s", r.Name, r.P95Latency, MaxAlertP95Latency)
+			}
+		}
+		if r.Window != "" {
+			if d, err := time.ParseDuration(r.Window); err != nil || d < time.Minute || d > MaxAlertWindow {
+				return fmt.Errorf("alert rule '%s': invalid window '%s', must be a duration between 1m and %s", r.Name, r.Window, MaxAlertWindow)
+			}
+		}
+		if r.MinRequests < 0 {
+			return fmt.Errorf("alert rule '%s': min_requests must not be negative", r.Name)
+		}
+	}
+	return nil
+}
+
+// DefaultClusterPollInterval is how often an edge server asks its peers for
+// their routes when cluster.poll_interval is not set.
+const DefaultClusterPollInterval = 10 * time.Second
+
+// ClusterConfig lets one haloy server front several. Every server in the
+// cluster opens a peer listener serving its own apps; the edge server asks
+// its peers which domains they route and proxies those domains to them,
+// leaving out peers that stop answering.
+type ClusterConfig struct {
+	// Listen is the address of the peer listener, e.g. "10.0.0.2:8443" on a
+	// private network or WireGuard interface. Empty disables the listener.
+	Listen string `json:"listen" yaml:"listen" toml:"listen"`
+	// Edge makes this server route the domains of its peers.
+	Edge  *bool         `json:"edge" yaml:"edge" toml:"edge"` // nil means false (default)
+	Peers []ClusterPeer `json:"peers" yaml:"peers" toml:"peers"`
+	// CAFile, CertFile and KeyFile secure peer traffic with mutual TLS: each
+	// server presents CertFile and accepts peers whose certificate CAFile
+	// signed. Without them peers talk plain HTTP, for WireGuard and other
+	// encrypted private networks.
+	CAFile       string `json:"ca_file" yaml:"ca_file" toml:"ca_file"`
+	CertFile     string `json:"cert_file" yaml:"cert_file" toml:"cert_file"`
+	KeyFile      string `json:"key_file" yaml:"key_file" toml:"key_file"`
+	PollInterval string `json:"poll_interval" yaml:"poll_interval" toml:"poll_interval"` // e.g. "10s"
+}
+
+// ClusterPeer is a server an edge routes to.
+type ClusterPeer struct {
+	Name    string `json:"name" yaml:"name" toml:"name"`
+	Address string `json:"address" yaml:"address" toml:"address"` // host:port of its peer listener
+}
+
+// IsEnabled returns whether this server is part of a cluster.
+func (c *ClusterConfig) IsEnabled() bool {
+	return c.Listen != "" || c.IsEdge()
+}
+
+// IsEdge returns whether this server routes its peers' domains.
+func (c *ClusterConfig) IsEdge() bool {
+	return c.Edge != nil && *c.Edge
+}
+
+// UsesTLS returns whether peer traffic uses mutual TLS.
+func (c *ClusterConfig) UsesTLS() bool {
+	return c.CertFile != ""
+}
+
+// GetPollInterval returns the peer poll interval, defaulting to 10s if not
+// set or invalid.
+func (c *ClusterConfig) GetPollInterval() time.Duration {
+	d, err := time.ParseDuration(c.PollInterval)
+	if err != nil || d <= 0 {
+		return DefaultClusterPollInterval
+	}
+	return d
+}
+
+// privateHostPort reports whether the host of addr is a private or loopback
+// IP address. Unspecified addresses like 0.0.0.0 listen on every interface,
+// so they aren't.
+func privateHostPort(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && (ip.IsPrivate() || ip.IsLoopback())
+}
+
+// UsesMutualTLS reports whether the servers of the cluster talk mutual TLS.
+func (c *ClusterConfig) UsesMutualTLS() bool {
+	return c.CAFile != "" && c.CertFile != "" && c.KeyFile != ""
+}
+
+func (c *ClusterConfig) Validate() error {
+	if c.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+			return fmt.Errorf("invalid cluster.listen '%s': must be host:port", c.Listen)
+		}
+	}
+	if c.IsEdge() && len(c.Peers) == 0 {
+		return errors.New("cluster.edge requires cluster.peers")
+	}
+	if !c.IsEdge() && len(c.Peers) > 0 {
+		return errors.New("cluster.peers is only used with cluster.edge")
+	}
+	names := make(map[string]struct{}, len(c.Peers))
+	for i, peer := range c.Peers {
+		if peer.Name == "" {
+			return fmt.Errorf("cluster.peers[%d].name is required", i)
+		}
+		if _, exists := names[peer.Name]; exists {
+			return fmt.Errorf("cluster peer '%s' is listed more than once", peer.Name)
+		}
+		names[peer.Name] = struct{}{}
+		if _, _, err := net.SplitHostPort(peer.Address); err != nil {
+			return fmt.Errorf("invalid address '%s' for cluster peer '%s': must be host:port", peer.Address, peer.Name)
+		}
+	}
+	if (c.CAFile != "" || c.CertFile != "" || c.KeyFile != "") && (c.CAFile == "" || c.CertFile == "" || c.KeyFile == "") {
+		return errors.New("cluster.ca_file, cluster.cert_file and cluster.key_file must be set together")
+	}
+	// Without mutual TLS anyone reaching a peer listener could skip the
+	// edge's client certificate, SSO and IP checks and pass off any client
+	// address, so plain HTTP stays on private networks such as WireGuard.
+	if !c.UsesMutualTLS() {
+		if c.Listen != "" && !privateHostPort(c.Listen) {
+			return fmt.Errorf("cluster.listen '%s' needs cluster.ca_file, cluster.cert_file and cluster.key_file unless it's a private address", c.Listen)
+		}
+		for _, peer := range c.Peers {
+			if !privateHostPort(peer.Address) {
+				return fmt.Errorf("cluster peer '%s' at '%s' needs cluster.ca_file, cluster.cert_file and cluster.key_file unless it's a private address", peer.Name, peer.Address)
+			}
+		}
+	}
+	if c.PollInterval != "" {
+		if d, err := time.ParseDuration(c.PollInterval); err != nil || d < time.Second {
+			return fmt.Errorf("invalid cluster.poll_interval '%s': must be a duration of at least 1s", c.PollInterval)
+		}
+	}
+	return nil
+}
+
+const (
+	DefaultDatabaseBackupInterval  = 24 * time.Hour
+	DefaultDatabaseBackupRetention = 7
+)
+
+// DatabaseBackupsConfig schedules dumps of managed databases to S3 or an
+// S3-compatible store such as MinIO or R2.
+type DatabaseBackupsConfig struct {
+	Interval  string   `json:"interval" yaml:"interval" toml:"interval"`    // e.g. "6h", default 24h
+	Retention int      `json:"retention" yaml:"retention" toml:"retention"` // Backups kept per database (default 7)
+	S3        S3Config `json:"s3" yaml:"s3" toml:"s3"`
+}
+
+type S3Config struct {
+	// Endpoint is the base URL of an S3-compatible store. Empty uses AWS.
+	Endpoint string `json:"endpoint" yaml:"endpoint" toml:"endpoint"`
+	Region   string `json:"region" yaml:"region" toml:"region"` // default us-east-1
+	Bucket   string `json:"bucket" yaml:"bucket" toml:"bucket"`
+	Prefix   string `json:"prefix" yaml:"prefix" toml:"prefix"` // key prefix, e.g. "haloy/"
+	// AccessKeyID and SecretAccessKey default to AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY in haloyd's environment.
+	AccessKeyID     string `json:"access_key_id" yaml:"access_key_id" toml:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key" yaml:"secret_access_key" toml:"secret_access_key"`
+}
+
+// IsEnabled returns whether backups are configured.
+func (c *DatabaseBackupsConfig) IsEnabled() bool {
+	return c.S3.Bucket != ""
+}
+
+// GetInterval returns the time between backups, defaulting to 24h if not set
+// or invalid.
+func (c *DatabaseBackupsConfig) GetInterval() time.Duration {
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil || d <= 0 {
+		return DefaultDatabaseBackupInterval
+	}
+	return d
+}
+
+// GetRetention returns how many backups are kept per database, defaulting
+// to 7.
+func (c *DatabaseBackupsConfig) GetRetention() int {
+	if c.Retention <= 0 {
+		return DefaultDatabaseBackupRetention
+	}
+	return c.Retention
+}
+
+// GetRegion returns the S3 region, defaulting to us-east-1.
+func (c *S3Config) GetRegion() string {
+	if c.Region == "" {
+		return "us-east-1"
+	}
+	return c.Region
+}
+
+// GetCredentials returns the access key pair, falling back to the standard
+// AWS environment variables.
+func (c *S3Config) GetCredentials() (accessKeyID, secretAccessKey string) {
+	accessKeyID, secretAccessKey = c.AccessKeyID, c.SecretAccessKey
+	if accessKeyID == "" {
+		accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if secretAccessKey == "" {
+		secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	return accessKeyID, secretAccessKey
+}
+
+func (c *DatabaseBackupsConfig) Validate() error {
+	if !c.IsEnabled() {
+		if c.Interval != "" || c.Retention != 0 || c.S3.Endpoint != "" {
+			return errors.New("database_backups requires database_backups.s3.bucket")
+		}
+		return nil
+	}
+	if c.Interval != "" {
+		if d, err := time.ParseDuration(c.Interval); err != nil || d < 5*time.Minute {
+			return fmt.Errorf("invalid database_backups.interval '%s': must be a duration of at least 5m", c.Interval)
+		}
+	}
+	if c.Retention < 0 {
+		return fmt.Errorf("invalid database_backups.retention %d: must be at least 1", c.Retention)
+	}
+	if c.S3.Endpoint != "" {
+		if u, err := url.Parse(c.S3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid database_backups.s3.endpoint '%s': must be an http or https URL", c.S3.Endpoint)
+		}
+	}
+	if id, secret := c.S3.GetCredentials(); id == "" || secret == "" {
+		return errors.New("database_backups.s3 needs access_key_id and secret_access_key, or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY in haloyd's environment")
+	}
+	return nil
+}
+
+// VolumeSnapshotsConfig sets the S3 endpoint and credentials used for the
+// remote of a target's snapshots. The bucket and prefix come from the remote.
+type VolumeSnapshotsConfig struct {
+	S3 S3Config `json:"s3" yaml:"s3" toml:"s3"`
+}
+
+func (c *VolumeSnapshotsConfig) Validate() error {
+	if c.S3.Bucket != "" || c.S3.Prefix != "" {
+		return errors.New("volume_snapshots.s3 takes no bucket or prefix; set them in each target's snapshots.remote")
+	}
+	if c.S3.Endpoint != "" {
+		if u, err := url.Parse(c.S3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid volume_snapshots.s3.endpoint '%s': must be an http or https URL", c.S3.Endpoint)
+		}
+	}
+	return nil
+}
+
+const (
+	DefaultGitOpsBranch   = "main"
+	DefaultGitOpsInterval = time.Minute
+)
+
+// GitOpsConfig makes haloyd deploy the targets for this server that a git
+// repository declares, and destroy the ones removed from it.
+type GitOpsConfig struct {
+	Repo     string `json:"repo" yaml:"repo" toml:"repo"`             // URL git clones, empty disables GitOps
+	Branch   string `json:"branch" yaml:"branch" toml:"branch"`       // default main
+	Path     string `json:"path" yaml:"path" toml:"path"`             // Directory of the configs in the repo, default its root
+	Interval string `json:"interval" yaml:"interval" toml:"interval"` // e.g. "5m", default 1m
+	// SSHKeyFile is the private key git uses for ssh:// and git@ repos.
+	SSHKeyFile string `json:"ssh_key_file" yaml:"ssh_key_file" toml:"ssh_key_file"`
+}
+
+// IsEnabled returns whether a repository is configured.
+func (c *GitOpsConfig) IsEnabled() bool {
+	return c.Repo != ""
+}
+
+// GetBranch returns the branch to follow, defaulting to main.
+func (c *GitOpsConfig) GetBranch() string {
+	if c.Branch == "" {
+		return DefaultGitOpsBranch
+	}
+	return c.Branch
+}
+
+// GetInterval returns the time between syncs, defaulting to 1m if not set or
+// invalid.
+func (c *GitOpsConfig) GetInterval() time.Duration {
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil || d <= 0 {
+		return DefaultGitOpsInterval
+	}
+	return d
+}
+
+func (c *GitOpsConfig) Validate() error {
+	if !c.IsEnabled() {
+		if c.Branch != "" || c.Path != "" || c.Interval != "" || c.SSHKeyFile != "" {
+			return errors.New("gitops requires gitops.repo")
+		}
+		return nil
+	}
+	if strings.HasPrefix(c.Branch, "-") || strings.ContainsAny(c.Branch, " \t\n") {
+		return fmt.Errorf("invalid gitops.branch '%s'", c.Branch)
+	}
+	if c.Path != "" {
+		if filepath.IsAbs(c.Path) || !filepath.IsLocal(c.Path) {
+			return fmt.Errorf("invalid gitops.path '%s': must be a directory inside the repository", c.Path)
+		}
+	}
+	if c.Interval != "" {
+		if d, err := time.ParseDuration(c.Interval); err != nil || d < 10*time.Second {
+			return fmt.Errorf("invalid gitops.interval '%s': must be a duration of at least 10s", c.Interval)
+		}
+	}
+	if c.SSHKeyFile != "" && !filepath.IsAbs(c.SSHKeyFile) {
+		return fmt.Errorf("invalid gitops.ssh_key_file '%s': must be an absolute path", c.SSHKeyFile)
+	}
+	return nil
+}
+
+// DNSCheckMode controls the DNS preflight haloyd runs on a domain before
+// requesting a certificate for it.
+type DNSCheckMode string
+
+const (
+	// DNSCheckOff skips the preflight and leaves validation to the ACME
+	// server, for split-horizon DNS or servers behind NAT.
+	DNSCheckOff DNSCheckMode = "off"
+	// DNSCheckWarn requires the domain to resolve and logs a warning when
+	// it doesn't point at this server, as it may sit behind a CDN.
+	DNSCheckWarn DNSCheckMode = "warn"
+	// DNSCheckStrict also refuses domains that don't point at this server.
+	DNSCheckStrict DNSCheckMode = "strict"
+)
+
+// CertificatesConfig controls the DNS preflight for certificate requests.
+type CertificatesConfig struct {
+	DNSCheck DNSCheckMode `json:"dns_check" yaml:"dns_check" toml:"dns_check"` // off, warn (default) or strict
+	// Resolver is a DNS server, e.g. "1.1.1.1" or "[2606:4700:4700::1111]:53",
+	// used for the preflight instead of the system resolver and public
+	// DNS-over-HTTPS.
+	Resolver string `json:"resolver" yaml:"resolver" toml:"resolver"`
+}
+
+// GetDNSCheck returns the DNS preflight mode, defaulting to warn.
+func (c *CertificatesConfig) GetDNSCheck() DNSCheckMode {
+	if c.DNSCheck == "" {
+		return DNSCheckWarn
+	}
+	return c.DNSCheck
+}
+
+// OnDemandTLSConfig lets haloyd issue certificates for domains it doesn't
+// route yet, the first time a client connects to one. Approved domains are
+// routed to App. A domain is approved when it matches Allow or Ask approves
+// it, and it resolves to this server.
+type OnDemandTLSConfig struct {
+	App string `json:"app" yaml:"app" toml:"app"` // empty disables on-demand TLS
+	// Allow lists domains to approve; "*.example.com" matches any subdomain.
+	Allow []string `json:"allow" yaml:"allow" toml:"allow"`
+	// Ask is a URL haloyd sends GET <ask>?domain=<domain>; a 2xx response
+	// approves the domain.
+	Ask string `json:"ask" yaml:"ask" toml:"ask"`
+}
+
+// IsEnabled returns whether on-demand TLS is configured.
+func (c *OnDemandTLSConfig) IsEnabled() bool {
+	return c.App != ""
+}
+
+// Allows reports whether domain matches one of the Allow patterns.
+func (c *OnDemandTLSConfig) Allows(domain string) bool {
+	domain = strings.ToLower(domain)
+	for _, pattern := range c.Allow {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(domain, "."+suffix) {
+				return true
+			}
+		} else if domain == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *OnDemandTLSConfig) Validate() error {
+	if !c.IsEnabled() {
+		if len(c.Allow) > 0 || c.Ask != "" {
+			return errors.New("on_demand_tls.app is required when on_demand_tls.allow or on_demand_tls.ask is set")
+		}
+		return nil
+	}
+	if len(c.Allow) == 0 && c.Ask == "" {
+		// Without a gate anyone could point domains at the server and
+		// exhaust the ACME rate limits.
+		return errors.New("on_demand_tls requires allow or ask to approve domains")
+	}
+	for _, pattern := range c.Allow {
+		if err := helpers.IsValidDomain(strings.TrimPrefix(pattern, "*.")); err != nil {
+			return fmt.Errorf("invalid on_demand_tls.allow pattern '%s': %w", pattern, err)
+		}
+	}
+	if c.Ask != "" {
+		u, err := url.Parse(c.Ask)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid on_demand_tls.ask '%s': must be an http or https URL", c.Ask)
+		}
+	}
+	return nil
+}
+
+// TLSConfig holds the TLS handshake settings haloy-proxy uses for HTTPS.
+// Empty fields keep Go's defaults.
+type TLSConfig struct {
+	MinVersion string `json:"min_version" yaml:"min_version" toml:"min_version"` // "1.2" (default) or "1.3"
+	// CipherSuites limits the TLS 1.2 cipher suites, by name as in Go's
+	// crypto/tls; TLS 1.3 suites are not configurable.
+	CipherSuites     []string `json:"cipher_suites" yaml:"cipher_suites" toml:"cipher_suites"`
+	CurvePreferences []string `json:"curve_preferences" yaml:"curve_preferences" toml:"curve_preferences"` // e.g. X25519, P-256
+}
+
+// IsSet reports whether any TLS setting is configured.
+func (c *TLSConfig) IsSet() bool {
+	return c.MinVersion != "" || len(c.CipherSuites) > 0 || len(c.CurvePreferences) > 0
+}
+
+func (c *TLSConfig) Validate() error {
+	if c.MinVersion != "" {
+		if _, err := helpers.ParseTLSVersion(c.MinVersion); err != nil {
+			return fmt.Errorf("invalid tls.min_version: %w", err)
+		}
+	}
+	if _, err := helpers.ParseCipherSuites(c.CipherSuites); err != nil {
+		return fmt.Errorf("invalid tls.cipher_suites: %w", err)
+	}
+	if _, err := helpers.ParseCurvePreferences(c.CurvePreferences); err != nil {
+		return fmt.Errorf("invalid tls.curve_preferences: %w", err)
+	}
+	return nil
+}
+
 // Normalize sets default values for HaloydConfig
 func (mc *HaloydConfig) Normalize() *HaloydConfig {
 	// Add any defaults if needed in the future
@@ -95,6 +1052,91 @@ func (mc *HaloydConfig) Validate() error {
 			return fmt.Errorf("invalid domain format: %w", err)
 		}
 	}
+	for _, network := range mc.API.AllowedNetworks {
+		if _, err := netip.ParsePrefix(network); err != nil {
+			return fmt.Errorf("invalid api.allowed_networks entry '%s': must be a CIDR such as 10.99.0.0/24", network)
+		}
+	}
+	if len(mc.API.AllowedNetworks) > 0 && mc.API.Domain == "" {
+		return errors.New("api.allowed_networks requires api.domain")
+	}
+	for _, origin := range mc.API.AllowedOrigins {
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" ||
+			strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return fmt.Errorf("invalid api.allowed_origins entry '%s': must be an origin such as https://dashboard.example.com", origin)
+		}
+	}
+
+	if mc.ImageCache.MaxSize != "" {
+		if _, err := helpers.ParseBytes(mc.ImageCache.MaxSize); err != nil {
+			return fmt.Errorf("invalid image_cache.max_size: %w", err)
+		}
+	}
+
+	if mc.Disk.MinFreeSpace != "" {
+		if _, err := helpers.ParseBytes(mc.Disk.MinFreeSpace); err != nil {
+			return fmt.Errorf("invalid disk.min_free_space: %w", err)
+		}
+	}
+
+	switch mc.Certificates.DNSCheck {
+	case "", DNSCheckOff, DNSCheckWarn, DNSCheckStrict:
+	default:
+		return fmt.Errorf("invalid certificates.dns_check '%s': must be off, warn or strict", mc.Certificates.DNSCheck)
+	}
+	if mc.Certificates.Resolver != "" {
+		if _, err := helpers.NormalizeResolverAddr(mc.Certificates.Resolver); err != nil {
+			return fmt.Errorf("invalid certificates.resolver: %w", err)
+		}
+	}
+
+	if err := mc.HealthMonitor.Passive.Validate(); err != nil {
+		return err
+	}
+	if err := mc.HealthMonitor.CrashLoop.Validate(); err != nil {
+		return err
+	}
+	if err := mc.OnDemandTLS.Validate(); err != nil {
+		return err
+	}
+	if err := mc.TLS.Validate(); err != nil {
+		return err
+	}
+	if err := mc.GC.Validate(); err != nil {
+		return err
+	}
+	if err := mc.AccessLog.Validate(); err != nil {
+		return err
+	}
+	if err := mc.Shutdown.Validate(); err != nil {
+		return err
+	}
+	if err := mc.UsageHistory.Validate(); err != nil {
+		return err
+	}
+	if err := mc.Uptime.Validate(); err != nil {
+		return err
+	}
+	if err := mc.Alerts.Validate(); err != nil {
+		return err
+	}
+
+	if err := mc.Cluster.Validate(); err != nil {
+		return err
+	}
+	if err := mc.DatabaseBackups.Validate(); err != nil {
+		return err
+	}
+	if err := mc.VolumeSnapshots.Validate(); err != nil {
+		return err
+	}
+	if err := mc.GitOps.Validate(); err != nil {
+		return err
+	}
+	// The repository's targets are matched to this server by the API domain.
+	if mc.GitOps.IsEnabled() && mc.API.Domain == "" {
+		return errors.New("gitops requires api.domain")
+	}
 
 	return nil
 }
@@ -126,6 +1168,23 @@ func LoadHaloydConfig(path string) (*HaloydConfig, error) {
 	return &haloydConfig, nil
 }
 
+// LoadDefaultHaloydConfig loads the haloyd config file from the haloyd config directory.
+// A missing file yields an empty config so callers can rely on the Get* defaults.
+func LoadDefaultHaloydConfig() (*HaloydConfig, error) {
+	configDir, err := HaloydConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	haloydConfig, err := LoadHaloydConfig(filepath.Join(configDir, constants.HaloydConfigFileName))
+	if err != nil {
+		return nil, err
+	}
+	if haloydConfig == nil {
+		haloydConfig = &HaloydConfig{}
+	}
+	return haloydConfig, nil
+}
+
 func SaveHaloydConfig(config *HaloydConfig, path string) error {
 	ext := filepath.Ext(path)
 	var data []byte
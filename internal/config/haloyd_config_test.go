@@ -36,6 +36,294 @@ func TestHaloydConfig_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "invalid domain format",
 		},
+		{
+			name: "valid api allowed networks",
+			config: HaloydConfig{
+				API: HaloydAPIConfig{Domain: "api.example.com", AllowedNetworks: []string{"10.99.0.0/24", "fd00::/64"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid api allowed network",
+			config: HaloydConfig{
+				API: HaloydAPIConfig{Domain: "api.example.com", AllowedNetworks: []string{"10.99.0.1"}},
+			},
+			wantErr: true,
+			errMsg:  "invalid api.allowed_networks entry",
+		},
+		{
+			name: "api allowed networks without domain",
+			config: HaloydConfig{
+				API: HaloydAPIConfig{AllowedNetworks: []string{"10.99.0.0/24"}},
+			},
+			wantErr: true,
+			errMsg:  "api.allowed_networks requires api.domain",
+		},
+		{
+			name: "valid api allowed origins",
+			config: HaloydConfig{
+				API: HaloydAPIConfig{AllowedOrigins: []string{"https://dashboard.example.com", "http://localhost:5173/"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "api allowed origin with a path",
+			config: HaloydConfig{
+				API: HaloydAPIConfig{AllowedOrigins: []string{"https://dashboard.example.com/app"}},
+			},
+			wantErr: true,
+			errMsg:  "invalid api.allowed_origins entry",
+		},
+		{
+			name: "api allowed origin wildcard",
+			config: HaloydConfig{
+				API: HaloydAPIConfig{AllowedOrigins: []string{"*"}},
+			},
+			wantErr: true,
+			errMsg:  "invalid api.allowed_origins entry",
+		},
+		{
+			name: "valid passive health config",
+			config: HaloydConfig{
+				HealthMonitor: HealthMonitorConfig{Passive: PassiveHealthConfig{MaxLatency: "2s", MaxErrorRate: 0.25, Eject: true}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid passive max latency",
+			config: HaloydConfig{
+				HealthMonitor: HealthMonitorConfig{Passive: PassiveHealthConfig{MaxLatency: "-1s"}},
+			},
+			wantErr: true,
+			errMsg:  "invalid health_monitor.passive.max_latency",
+		},
+		{
+			name: "invalid passive max error rate",
+			config: HaloydConfig{
+				HealthMonitor: HealthMonitorConfig{Passive: PassiveHealthConfig{MaxErrorRate: 1.5}},
+			},
+			wantErr: true,
+			errMsg:  "invalid health_monitor.passive.max_error_rate",
+		},
+		{
+			name: "valid crash loop config",
+			config: HaloydConfig{
+				HealthMonitor: HealthMonitorConfig{CrashLoop: CrashLoopConfig{Restarts: 3, Window: "5m"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid crash loop window",
+			config: HaloydConfig{
+				HealthMonitor: HealthMonitorConfig{CrashLoop: CrashLoopConfig{Window: "10s"}},
+			},
+			wantErr: true,
+			errMsg:  "invalid health_monitor.crash_loop.window",
+		},
+		{
+			name: "valid cluster edge",
+			config: HaloydConfig{Cluster: ClusterConfig{
+				Listen: "10.0.0.1:8443",
+				Edge:   new(true),
+				Peers:  []ClusterPeer{{Name: "b", Address: "10.0.0.2:8443"}},
+				CAFile: "/etc/haloy/ca.pem", CertFile: "/etc/haloy/node.pem", KeyFile: "/etc/haloy/node-key.pem",
+			}},
+			wantErr: false,
+		},
+		{
+			name:    "cluster edge without peers",
+			config:  HaloydConfig{Cluster: ClusterConfig{Edge: new(true)}},
+			wantErr: true,
+			errMsg:  "cluster.edge requires cluster.peers",
+		},
+		{
+			name: "cluster duplicate peer",
+			config: HaloydConfig{Cluster: ClusterConfig{
+				Edge:  new(true),
+				Peers: []ClusterPeer{{Name: "b", Address: "10.0.0.2:8443"}, {Name: "b", Address: "10.0.0.3:8443"}},
+			}},
+			wantErr: true,
+			errMsg:  "listed more than once",
+		},
+		{
+			name: "cluster peer without port",
+			config: HaloydConfig{Cluster: ClusterConfig{
+				Edge:  new(true),
+				Peers: []ClusterPeer{{Name: "b", Address: "10.0.0.2"}},
+			}},
+			wantErr: true,
+			errMsg:  "invalid address '10.0.0.2' for cluster peer 'b'",
+		},
+		{
+			name: "cluster plain http on a private network",
+			config: HaloydConfig{Cluster: ClusterConfig{
+				Listen: "10.99.0.1:8443",
+				Edge:   new(true),
+				Peers:  []ClusterPeer{{Name: "b", Address: "10.99.0.2:8443"}},
+			}},
+			wantErr: false,
+		},
+		{
+			name:    "cluster plain http on every interface",
+			config:  HaloydConfig{Cluster: ClusterConfig{Listen: "0.0.0.0:8443"}},
+			wantErr: true,
+			errMsg:  "cluster.listen '0.0.0.0:8443' needs cluster.ca_file",
+		},
+		{
+			name: "cluster plain http to a public peer",
+			config: HaloydConfig{Cluster: ClusterConfig{
+				Edge:  new(true),
+				Peers: []ClusterPeer{{Name: "b", Address: "203.0.113.2:8443"}},
+			}},
+			wantErr: true,
+			errMsg:  "cluster peer 'b' at '203.0.113.2:8443' needs cluster.ca_file",
+		},
+		{
+			name: "cluster public listener with mutual tls",
+			config: HaloydConfig{Cluster: ClusterConfig{
+				Listen: "0.0.0.0:8443",
+				CAFile: "/etc/haloy/ca.pem", CertFile: "/etc/haloy/node.pem", KeyFile: "/etc/haloy/node-key.pem",
+			}},
+			wantErr: false,
+		},
+		{
+			name:    "cluster partial tls",
+			config:  HaloydConfig{Cluster: ClusterConfig{Listen: ":8443", CertFile: "/etc/haloy/node.pem"}},
+			wantErr: true,
+			errMsg:  "must be set together",
+		},
+		{
+			name: "valid database backups",
+			config: HaloydConfig{DatabaseBackups: DatabaseBackupsConfig{
+				Interval:  "6h",
+				Retention: 14,
+				S3:        S3Config{Endpoint: "https://s3.example.com", Bucket: "backups", AccessKeyID: "id", SecretAccessKey: "secret"},
+			}},
+			wantErr: false,
+		},
+		{
+			name:    "database backups without bucket",
+			config:  HaloydConfig{DatabaseBackups: DatabaseBackupsConfig{Interval: "6h"}},
+			wantErr: true,
+			errMsg:  "requires database_backups.s3.bucket",
+		},
+		{
+			name: "database backups interval too short",
+			config: HaloydConfig{DatabaseBackups: DatabaseBackupsConfig{
+				Interval: "1m",
+				S3:       S3Config{Bucket: "backups", AccessKeyID: "id", SecretAccessKey: "secret"},
+			}},
+			wantErr: true,
+			errMsg:  "invalid database_backups.interval",
+		},
+		{
+			name:    "volume snapshots with a bucket",
+			config:  HaloydConfig{VolumeSnapshots: VolumeSnapshotsConfig{S3: S3Config{Bucket: "backups"}}},
+			wantErr: true,
+			errMsg:  "takes no bucket or prefix",
+		},
+		{
+			name:    "valid gc config",
+			config:  HaloydConfig{GC: GCConfig{Enabled: new(true), OlderThan: "72h"}},
+			wantErr: false,
+		},
+		{
+			name:    "invalid gc older than",
+			config:  HaloydConfig{GC: GCConfig{OlderThan: "10m"}},
+			wantErr: true,
+			errMsg:  "invalid gc.older_than",
+		},
+		{
+			name:    "invalid shutdown grace period",
+			config:  HaloydConfig{Shutdown: ShutdownConfig{GracePeriod: "1h"}},
+			wantErr: true,
+			errMsg:  "invalid shutdown.grace_period",
+		},
+		{
+			name: "invalid min free space",
+			config: HaloydConfig{
+				Disk: DiskConfig{MinFreeSpace: "lots"},
+			},
+			wantErr: true,
+			errMsg:  "invalid disk.min_free_space",
+		},
+		{
+			name: "valid certificates config",
+			config: HaloydConfig{
+				Certificates: CertificatesConfig{DNSCheck: DNSCheckStrict, Resolver: "[2606:4700:4700::1111]:53"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid dns check mode",
+			config: HaloydConfig{
+				Certificates: CertificatesConfig{DNSCheck: "sometimes"},
+			},
+			wantErr: true,
+			errMsg:  "invalid certificates.dns_check",
+		},
+		{
+			name: "resolver must be an IP",
+			config: HaloydConfig{
+				Certificates: CertificatesConfig{Resolver: "one.one.one.one"},
+			},
+			wantErr: true,
+			errMsg:  "invalid certificates.resolver",
+		},
+		{
+			name: "valid on-demand tls",
+			config: HaloydConfig{
+				OnDemandTLS: OnDemandTLSConfig{App: "tenants", Allow: []string{"*.customers.example.com"}, Ask: "http://127.0.0.1:3000/allowed"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "on-demand tls without a gate",
+			config: HaloydConfig{
+				OnDemandTLS: OnDemandTLSConfig{App: "tenants"},
+			},
+			wantErr: true,
+			errMsg:  "requires allow or ask",
+		},
+		{
+			name: "on-demand tls without an app",
+			config: HaloydConfig{
+				OnDemandTLS: OnDemandTLSConfig{Allow: []string{"example.com"}},
+			},
+			wantErr: true,
+			errMsg:  "on_demand_tls.app is required",
+		},
+		{
+			name: "on-demand tls ask must be a URL",
+			config: HaloydConfig{
+				OnDemandTLS: OnDemandTLSConfig{App: "tenants", Ask: "127.0.0.1:3000"},
+			},
+			wantErr: true,
+			errMsg:  "invalid on_demand_tls.ask",
+		},
+		{
+			name: "valid tls settings",
+			config: HaloydConfig{
+				TLS: TLSConfig{MinVersion: "1.3", CurvePreferences: []string{"X25519", "P-256"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "tls min version too old",
+			config: HaloydConfig{
+				TLS: TLSConfig{MinVersion: "1.0"},
+			},
+			wantErr: true,
+			errMsg:  "invalid tls.min_version",
+		},
+		{
+			name: "unknown cipher suite",
+			config: HaloydConfig{
+				TLS: TLSConfig{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			},
+			wantErr: true,
+			errMsg:  "invalid tls.cipher_suites",
+		},
 	}
 
 	for _, tt := range tests {
@@ -437,3 +725,237 @@ func TestLoadHaloydConfig_HealthMonitor(t *testing.T) {
 		}
 	})
 }
+
+func TestDiskConfig_GetMinFreeSpaceBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		size string
+		want uint64
+	}{
+		{name: "default", size: "", want: 1 << 30},
+		{name: "explicit", size: "5GiB", want: 5 << 30},
+		{name: "disabled", size: "0", want: 0},
+		{name: "invalid falls back to default", size: "lots", want: 1 << 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := DiskConfig{MinFreeSpace: tt.size}
+			if got := c.GetMinFreeSpaceBytes(); got != tt.want {
+				t.Errorf("GetMinFreeSpaceBytes() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGCConfig(t *testing.T) {
+	var c GCConfig
+	if c.IsEnabled() {
+		t.Error("IsEnabled() = true, want disabled by default")
+	}
+	if got := c.GetOlderThan(); got != DefaultGCOlderThan {
+		t.Errorf("GetOlderThan() = %v, want %v", got, DefaultGCOlderThan)
+	}
+
+	c = GCConfig{Enabled: new(true), OlderThan: "48h"}
+	if !c.IsEnabled() {
+		t.Error("IsEnabled() = false, want true")
+	}
+	if got := c.GetOlderThan(); got != 48*time.Hour {
+		t.Errorf("GetOlderThan() = %v, want 48h", got)
+	}
+}
+
+func TestShutdownConfig(t *testing.T) {
+	var c ShutdownConfig
+	if got := c.GetGracePeriod(); got != DefaultShutdownGracePeriod {
+		t.Errorf("GetGracePeriod() = %v, want %v", got, DefaultShutdownGracePeriod)
+	}
+	c = ShutdownConfig{GracePeriod: "2m"}
+	if got := c.GetGracePeriod(); got != 2*time.Minute {
+		t.Errorf("GetGracePeriod() = %v, want 2m", got)
+	}
+}
+
+func TestUsageHistoryConfig(t *testing.T) {
+	var c UsageHistoryConfig
+	if !c.IsEnabled() {
+		t.Error("IsEnabled() = false, want enabled by default")
+	}
+	if c.GetInterval() != DefaultUsageHistoryInterval || c.GetRetention() != DefaultUsageHistoryRetention {
+		t.Errorf("interval %v, retention %v, want the defaults", c.GetInterval(), c.GetRetention())
+	}
+
+	c = UsageHistoryConfig{Enabled: new(false), Interval: "30s", Retention: "720h"}
+	if c.IsEnabled() {
+		t.Error("IsEnabled() = true with enabled: false")
+	}
+	if c.GetInterval() != 30*time.Second || c.GetRetention() != 720*time.Hour {
+		t.Errorf("interval %v, retention %v, want 30s and 720h", c.GetInterval(), c.GetRetention())
+	}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := (&UsageHistoryConfig{Interval: "1s"}).Validate(); err == nil {
+		t.Error("Validate() with a 1s interval succeeded, want error")
+	}
+	if err := (&UsageHistoryConfig{Retention: "1h"}).Validate(); err == nil {
+		t.Error("Validate() with a 1h retention succeeded, want error")
+	}
+}
+
+func TestAccessLogConfig(t *testing.T) {
+	var c AccessLogConfig
+	if !c.IsEnabled() || c.IsSet() {
+		t.Error("access log is disabled or set by default, want enabled and unset")
+	}
+	c = AccessLogConfig{Enabled: new(false)}
+	if c.IsEnabled() || !c.IsSet() {
+		t.Error("IsEnabled() = true with enabled: false")
+	}
+	if err := (&AccessLogConfig{Sample: 100}).Validate(); err != nil {
+		t.Errorf("Validate() with sample 100 error = %v", err)
+	}
+	if err := (&AccessLogConfig{Sample: -1}).Validate(); err == nil {
+		t.Error("Validate() with a negative sample succeeded, want error")
+	}
+}
+
+func TestClusterConfig(t *testing.T) {
+	var c ClusterConfig
+	if c.IsEnabled() || c.IsEdge() || c.UsesTLS() {
+		t.Error("cluster is enabled by default, want disabled")
+	}
+	if got := c.GetPollInterval(); got != DefaultClusterPollInterval {
+		t.Errorf("GetPollInterval() = %v, want %v", got, DefaultClusterPollInterval)
+	}
+
+	c = ClusterConfig{Listen: "10.0.0.2:8443", CertFile: "node.pem", PollInterval: "30s"}
+	if !c.IsEnabled() || c.IsEdge() || !c.UsesTLS() {
+		t.Errorf("IsEnabled, IsEdge, UsesTLS = %v, %v, %v; want true, false, true", c.IsEnabled(), c.IsEdge(), c.UsesTLS())
+	}
+	if got := c.GetPollInterval(); got != 30*time.Second {
+		t.Errorf("GetPollInterval() = %v, want 30s", got)
+	}
+}
+
+func TestOnDemandTLSConfig_Allows(t *testing.T) {
+	c := OnDemandTLSConfig{Allow: []string{"*.customers.example.com", "Shop.Example.org"}}
+	tests := map[string]bool{
+		"acme.customers.example.com":   true,
+		"a.b.customers.example.com":    true,
+		"customers.example.com":        false,
+		"evilcustomers.example.com":    false,
+		"shop.example.org":             true,
+		"www.shop.example.org":         false,
+		"ACME.CUSTOMERS.EXAMPLE.COM":   true,
+		"acme.customers.example.com.x": false,
+	}
+	for domain, want := range tests {
+		if got := c.Allows(domain); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", domain, got, want)
+		}
+	}
+}
+
+func TestGitOpsConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  GitOpsConfig
+		wantErr bool
+	}{
+		{"disabled", GitOpsConfig{}, false},
+		{"repo only", GitOpsConfig{Repo: "https://github.com/acme/deploy.git"}, false},
+		{"all fields", GitOpsConfig{Repo: "git@github.com:acme/deploy.git", Branch: "prod", Path: "apps/eu", Interval: "5m", SSHKeyFile: "/etc/haloy/deploy_key"}, false},
+		{"settings without repo", GitOpsConfig{Branch: "prod"}, true},
+		{"path outside repo", GitOpsConfig{Repo: "https://example.com/r.git", Path: "../etc"}, true},
+		{"absolute path", GitOpsConfig{Repo: "https://example.com/r.git", Path: "/srv"}, true},
+		{"branch like a flag", GitOpsConfig{Repo: "https://example.com/r.git", Branch: "--upload-pack=x"}, true},
+		{"interval too short", GitOpsConfig{Repo: "https://example.com/r.git", Interval: "1s"}, true},
+		{"relative key file", GitOpsConfig{Repo: "https://example.com/r.git", SSHKeyFile: "deploy_key"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	var c GitOpsConfig
+	if got := c.GetBranch(); got != DefaultGitOpsBranch {
+		t.Errorf("GetBranch() = %q, want %q", got, DefaultGitOpsBranch)
+	}
+	if got := c.GetInterval(); got != DefaultGitOpsInterval {
+		t.Errorf("GetInterval() = %v, want %v", got, DefaultGitOpsInterval)
+	}
+}
+
+func TestUptimeConfig(t *testing.T) {
+	var c UptimeConfig
+	if !c.IsEnabled() {
+		t.Error("IsEnabled() = false, want enabled by default")
+	}
+	if c.GetObjective() != DefaultUptimeObjective || c.Alert.GetBurnRate() != DefaultUptimeBurnRate {
+		t.Errorf("objective %v, burn rate %v, want the defaults", c.GetObjective(), c.Alert.GetBurnRate())
+	}
+
+	tests := []struct {
+		name    string
+		config  UptimeConfig
+		wantErr bool
+	}{
+		{"defaults", UptimeConfig{}, false},
+		{"objective and alert", UptimeConfig{Objective: 99.5, Alert: UptimeAlertConfig{NotifyURL: "https://hooks.example.com/x", BurnRate: 6}}, false},
+		{"objective of 100", UptimeConfig{Objective: 100}, true},
+		{"negative objective", UptimeConfig{Objective: -1}, true},
+		{"negative burn rate", UptimeConfig{Alert: UptimeAlertConfig{BurnRate: -1}}, true},
+		{"notify URL without scheme", UptimeConfig{Alert: UptimeAlertConfig{NotifyURL: "hooks.example.com"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAlertsConfig(t *testing.T) {
+	if c := (AlertsConfig{NotifyURL: "https://hooks.slack.com/services/T/B/x"}); c.GetFormat() != AlertFormatSlack {
+		t.Errorf("GetFormat() = %s for a Slack URL, want slack", c.GetFormat())
+	}
+	if c := (AlertsConfig{NotifyURL: "https://example.com/hook"}); c.GetFormat() != AlertFormatWebhook {
+		t.Errorf("GetFormat() = %s, want webhook", c.GetFormat())
+	}
+	rule := AlertRule{Name: "5xx", ErrorRate: 5}
+	if rule.GetWindow() != DefaultAlertWindow || rule.GetMinRequests() != DefaultAlertMinRequests || !rule.AppliesTo("shop") {
+		t.Errorf("rule defaults: window %v, min requests %d, want the defaults for every app", rule.GetWindow(), rule.GetMinRequests())
+	}
+	if (&AlertRule{Apps: []string{"blog"}}).AppliesTo("shop") {
+		t.Error("AppliesTo() = true for an app the rule doesn't list")
+	}
+
+	tests := []struct {
+		name    string
+		config  AlertsConfig
+		wantErr bool
+	}{
+		{"rules", AlertsConfig{Rules: []AlertRule{{Name: "5xx", ErrorRate: 5}, {Name: "slow", P95Latency: "2s", Window: "10m"}}}, false},
+		{"no name", AlertsConfig{Rules: []AlertRule{{ErrorRate: 5}}}, true},
+		{"duplicate name", AlertsConfig{Rules: []AlertRule{{Name: "a", ErrorRate: 5}, {Name: "a", ErrorRate: 1}}}, true},
+		{"no threshold", AlertsConfig{Rules: []AlertRule{{Name: "a"}}}, true},
+		{"latency above the histogram", AlertsConfig{Rules: []AlertRule{{Name: "a", P95Latency: "30s"}}}, true},
+		{"window too long", AlertsConfig{Rules: []AlertRule{{Name: "a", ErrorRate: 5, Window: "2h"}}}, true},
+		{"unknown format", AlertsConfig{Format: "email"}, true},
+		{"notify URL without scheme", AlertsConfig{NotifyURL: "example.com"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// HealthCheck overrides the Docker HEALTHCHECK defined by the image.
+type HealthCheck struct {
+	// Cmd is either a shell command string or an exec-form list. Use "NONE"
+	// to disable the image's healthcheck and fall back to the HTTP health check.
+	Cmd         HealthCheckCommand `json:"cmd" yaml:"cmd" toml:"cmd"`
+	Interval    string             `json:"interval,omitempty" yaml:"interval,omitempty" toml:"interval,omitempty"`
+	Timeout     string             `json:"timeout,omitempty" yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+	Retries     *int               `json:"retries,omitempty" yaml:"retries,omitempty" toml:"retries,omitempty"`
+	StartPeriod string             `json:"startPeriod,omitempty" yaml:"start_period,omitempty" toml:"start_period,omitempty"`
+}
+
+// HealthCheckCommand accepts a string or a list in the config file.
+type HealthCheckCommand []string
+
+func HealthCheckCommandDecodeHook() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data any) (any, error) {
+		if t != reflect.TypeFor[HealthCheckCommand]() {
+			return data, nil
+		}
+		if v, ok := data.(string); ok {
+			return []string{v}, nil
+		}
+		return data, nil
+	}
+}
+
+// DockerTest converts the command to Docker's healthcheck test format.
+func (c HealthCheckCommand) DockerTest() []string {
+	if len(c) == 0 {
+		return nil
+	}
+	switch c[0] {
+	case "NONE", "CMD", "CMD-SHELL":
+		return c
+	}
+	if len(c) == 1 {
+		return []string{"CMD-SHELL", c[0]}
+	}
+	return append([]string{"CMD"}, c...)
+}
+
+func (h *HealthCheck) Validate(format string) error {
+	name := GetFieldNameForFormat(TargetConfig{}, "HealthCheck", format)
+	if len(h.Cmd) == 0 {
+		return fmt.Errorf("%s.cmd is required", name)
+	}
+
+	durations := []struct {
+		field string
+		value string
+	}{
+		{"Interval", h.Interval},
+		{"Timeout", h.Timeout},
+		{"StartPeriod", h.StartPeriod},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("invalid %s.%s '%s': %w", name, GetFieldNameForFormat(HealthCheck{}, d.field, format), d.value, err)
+		}
+		// Docker rejects non-zero durations below one millisecond.
+		if parsed < time.Millisecond {
+			return fmt.Errorf("%s.%s must be at least 1ms", name, GetFieldNameForFormat(HealthCheck{}, d.field, format))
+		}
+	}
+
+	if h.Retries != nil && *h.Retries < 0 {
+		return fmt.Errorf("%s.retries must be >= 0", name)
+	}
+
+	return nil
+}
+
+// Durations returns the parsed interval, timeout and start period. Unset
+// values are zero, which Docker replaces with its defaults. Assumes Validate passed.
+func (h *HealthCheck) Durations() (interval, timeout, startPeriod time.Duration) {
+	interval, _ = time.ParseDuration(h.Interval)
+	timeout, _ = time.ParseDuration(h.Timeout)
+	startPeriod, _ = time.ParseDuration(h.StartPeriod)
+	return interval, timeout, startPeriod
+}
+
+// HealthCheckType is how haloy checks that a replica is healthy.
+type HealthCheckType string
+
+const (
+	HealthCheckHTTP HealthCheckType = "http" // GET health_check_path, the default
+	HealthCheckTCP  HealthCheckType = "tcp"  // connect to the port
+	HealthCheckCmd  HealthCheckType = "cmd"  // run a command in the container, healthy on exit code 0
+)
+
+// HealthProbe selects the check haloy runs against a target's replicas to
+// gate deploys and in the health monitor. Unlike HealthCheck it doesn't
+// touch the container's Docker HEALTHCHECK.
+type HealthProbe struct {
+	Type HealthCheckType `json:"type,omitempty" yaml:"type,omitempty" toml:"type,omitempty"`
+	// Cmd is the command run by cmd checks, a shell command string or an
+	// exec-form list.
+	Cmd HealthCheckCommand `json:"cmd,omitempty" yaml:"cmd,omitempty" toml:"cmd,omitempty"`
+}
+
+func (p *HealthProbe) Validate(format string) error {
+	name := GetFieldNameForFormat(TargetConfig{}, "HealthProbe", format)
+	switch p.Type {
+	case "", HealthCheckHTTP, HealthCheckTCP:
+		if len(p.Cmd) > 0 {
+			return fmt.Errorf("%s.cmd is only used with type %q", name, HealthCheckCmd)
+		}
+	case HealthCheckCmd:
+		if len(p.Cmd) == 0 || p.Cmd[0] == "" {
+			return fmt.Errorf("%s.cmd is required with type %q", name, HealthCheckCmd)
+		}
+	default:
+		return validateEnum(name+".type", p.Type, HealthCheckHTTP, HealthCheckTCP, HealthCheckCmd)
+	}
+	return nil
+}
+
+// ExecArgs returns the command as arguments for an exec in the container.
+// A single string runs through the shell.
+func (c HealthCheckCommand) ExecArgs() []string {
+	if len(c) == 1 {
+		return []string{"/bin/sh", "-c", c[0]}
+	}
+	return c
+}
+
+// DefaultStartupPeriod is how often a starting app is checked when the
+// startup probe doesn't set a period.
+const DefaultStartupPeriod = 5 * time.Second
+
+// MaxStartupTimeout keeps a startup probe within a single deploy.
+const MaxStartupTimeout = 10 * time.Minute
+
+// StartupProbe gives slow starting apps more time to pass their first health
+// check during a deploy. Once the app is up, the health monitor's fall and
+// rise thresholds apply as usual.
+type StartupProbe struct {
+	// Timeout is how long after the container starts it may take to pass.
+	Timeout string `json:"timeout" yaml:"timeout" toml:"timeout"`
+	// Period is the time between checks while it starts, 5s by default.
+	Period string `json:"period,omitempty" yaml:"period,omitempty" toml:"period,omitempty"`
+}
+
+func (s *StartupProbe) Validate(format string) error {
+	name := GetFieldNameForFormat(TargetConfig{}, "Startup", format)
+	if s.Timeout == "" {
+		return fmt.Errorf("%s.timeout is required", name)
+	}
+	timeout, err := time.ParseDuration(s.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid %s.timeout '%s': %w", name, s.Timeout, err)
+	}
+	if timeout <= 0 || timeout > MaxStartupTimeout {
+		return fmt.Errorf("%s.timeout must be greater than 0 and at most %s", name, MaxStartupTimeout)
+	}
+	if s.Period == "" {
+		return nil
+	}
+	period, err := time.ParseDuration(s.Period)
+	if err != nil {
+		return fmt.Errorf("invalid %s.period '%s': %w", name, s.Period, err)
+	}
+	if period < time.Second || period > timeout {
+		return fmt.Errorf("%s.period must be at least 1s and at most the timeout", name)
+	}
+	return nil
+}
+
+// Durations returns the parsed timeout and period, with the period
+// defaulted. Assumes Validate passed.
+func (s *StartupProbe) Durations() (timeout, period time.Duration) {
+	timeout, _ = time.ParseDuration(s.Timeout)
+	period = DefaultStartupPeriod
+	if s.Period != "" {
+		period, _ = time.ParseDuration(s.Period)
+	}
+	return timeout, period
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthCheck_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		hc      HealthCheck
+		wantErr string
+	}{
+		{"valid", HealthCheck{Cmd: HealthCheckCommand{"curl -f localhost"}, Interval: "10s", Timeout: "2s", StartPeriod: "1m", Retries: new(3)}, ""},
+		{"missing cmd", HealthCheck{Interval: "10s"}, "cmd is required"},
+		{"bad interval", HealthCheck{Cmd: HealthCheckCommand{"true"}, Interval: "ten"}, "invalid docker_healthcheck.interval"},
+		{"too short timeout", HealthCheck{Cmd: HealthCheckCommand{"true"}, Timeout: "10us"}, "at least 1ms"},
+		{"negative retries", HealthCheck{Cmd: HealthCheckCommand{"true"}, Retries: new(-1)}, "retries must be >= 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hc.Validate("yaml")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHealthCheck_Durations(t *testing.T) {
+	hc := HealthCheck{Cmd: HealthCheckCommand{"true"}, Interval: "5s", StartPeriod: "1m"}
+	interval, timeout, startPeriod := hc.Durations()
+	if interval != 5*time.Second || timeout != 0 || startPeriod != time.Minute {
+		t.Fatalf("Durations() = (%v, %v, %v), want (5s, 0s, 1m0s)", interval, timeout, startPeriod)
+	}
+}
+
+func TestHealthProbe_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		probe   HealthProbe
+		wantErr string
+	}{
+		{"default", HealthProbe{}, ""},
+		{"tcp", HealthProbe{Type: HealthCheckTCP}, ""},
+		{"cmd", HealthProbe{Type: HealthCheckCmd, Cmd: HealthCheckCommand{"pg_isready"}}, ""},
+		{"cmd without command", HealthProbe{Type: HealthCheckCmd}, "health_check.cmd is required"},
+		{"command without cmd type", HealthProbe{Type: HealthCheckTCP, Cmd: HealthCheckCommand{"true"}}, "only used with type"},
+		{"unknown type", HealthProbe{Type: "grpc"}, "invalid health_check.type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.probe.Validate("yaml")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHealthCheckCommand_ExecArgs(t *testing.T) {
+	if got := (HealthCheckCommand{"pg_isready -U app"}).ExecArgs(); !slices.Equal(got, []string{"/bin/sh", "-c", "pg_isready -U app"}) {
+		t.Errorf("shell form ExecArgs() = %v", got)
+	}
+	if got := (HealthCheckCommand{"redis-cli", "ping"}).ExecArgs(); !slices.Equal(got, []string{"redis-cli", "ping"}) {
+		t.Errorf("exec form ExecArgs() = %v", got)
+	}
+}
+
+func TestStartupProbe_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		probe   StartupProbe
+		wantErr string
+	}{
+		{"timeout only", StartupProbe{Timeout: "5m"}, ""},
+		{"timeout and period", StartupProbe{Timeout: "5m", Period: "10s"}, ""},
+		{"missing timeout", StartupProbe{Period: "5s"}, "startup.timeout is required"},
+		{"timeout at the limit", StartupProbe{Timeout: "10m"}, ""},
+		{"timeout too long", StartupProbe{Timeout: "10m1s"}, "at most 10m0s"},
+		{"bad period", StartupProbe{Timeout: "5m", Period: "soon"}, "invalid startup.period"},
+		{"period over timeout", StartupProbe{Timeout: "10s", Period: "1m"}, "at most the timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.probe.Validate("yaml")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	timeout, period := (&StartupProbe{Timeout: "5m"}).Durations()
+	if timeout != 5*time.Minute || period != DefaultStartupPeriod {
+		t.Errorf("Durations() = %s, %s, want 5m and the default period", timeout, period)
+	}
+}
@@ -75,10 +75,24 @@ func (i *Image) GetEffectivePushStrategy() BuildPushOption {
 	return BuildPushOptionServer
 }
 
+// EffectivePullPolicy returns the pull policy, defaulting by how the image
+// gets to the server: images haloy uploads, and the image IDs they're pinned
+// to, are never pulled, since no registry has them, and images pinned by
+// digest only when missing, since a digest can't move. Other images are
+// always checked for a moved tag.
 func (i *Image) EffectivePullPolicy() PullPolicy {
 	if i.PullPolicy != "" {
 		return i.PullPolicy
 	}
+	if i.ShouldBuild() && i.GetEffectivePushStrategy() == BuildPushOptionServer {
+		return PullPolicyNever
+	}
+	if strings.HasPrefix(i.Repository, "sha256:") {
+		return PullPolicyNever
+	}
+	if strings.Contains(i.Repository, "@sha256:") {
+		return PullPolicyIfMissing
+	}
 	return PullPolicyAlways
 }
 
@@ -132,11 +146,8 @@ func (i *Image) Validate(format string) error {
 		return fmt.Errorf("image.tag '%s' contains whitespace", i.Tag)
 	}
 
-	if i.PullPolicy != "" {
-		validPullPolicies := []PullPolicy{PullPolicyAlways, PullPolicyIfMissing, PullPolicyNever}
-		if !slices.Contains(validPullPolicies, i.PullPolicy) {
-			return fmt.Errorf("image.pull_policy '%s' is invalid (must be 'always', 'if_missing', or 'never')", i.PullPolicy)
-		}
+	if err := validateEnum("image.pull_policy", i.PullPolicy, PullPolicyAlways, PullPolicyIfMissing, PullPolicyNever); err != nil {
+		return err
 	}
 
 	if i.History != nil {
@@ -170,6 +181,9 @@ func (i *Image) Validate(format string) error {
 		if err := reg.Password.Validate(); err != nil {
 			return err
 		}
+		if err := errors.Join(reg.Username.validateNotServerSecret(), reg.Password.validateNotServerSecret()); err != nil {
+			return fmt.Errorf("image.registry: %w", err)
+		}
 	}
 
 	if i.ShouldBuild() {
@@ -208,11 +222,8 @@ type ImageHistory struct {
 }
 
 func (h *ImageHistory) Validate() error {
-	if h.Strategy != "" {
-		validStrategies := []HistoryStrategy{HistoryStrategyLocal, HistoryStrategyRegistry, HistoryStrategyNone}
-		if !slices.Contains(validStrategies, h.Strategy) {
-			return fmt.Errorf("image.history.strategy '%s' is invalid (must be 'local', 'registry', or 'none')", h.Strategy)
-		}
+	if err := validateEnum("image.history.strategy", h.Strategy, HistoryStrategyLocal, HistoryStrategyRegistry, HistoryStrategyNone); err != nil {
+		return err
 	}
 
 	// Count is required for both local and registry strategies
@@ -262,11 +273,8 @@ func (b *BuildConfig) Validate(format string) error {
 		}
 	}
 
-	if b.Push != "" {
-		validPushOptions := []BuildPushOption{BuildPushOptionServer, BuildPushOptionRegistry}
-		if !slices.Contains(validPushOptions, b.Push) {
-			return fmt.Errorf("builder.push must be 'server' or 'registry', got '%s'", b.Push)
-		}
+	if err := validateEnum("builder.push", b.Push, BuildPushOptionServer, BuildPushOptionRegistry); err != nil {
+		return err
 	}
 
 	return nil
@@ -300,6 +308,9 @@ func (ba *BuildArg) Validate(format string) error {
 	if err := ba.ValueSource.Validate(); err != nil {
 		return fmt.Errorf("build argument '%s': %w", ba.Name, err)
 	}
+	if err := ba.validateNotServerSecret(); err != nil {
+		return fmt.Errorf("build argument '%s': %w", ba.Name, err)
+	}
 
 	return nil
 }
@@ -140,7 +140,7 @@ func TestImage_Validate(t *testing.T) {
 				PullPolicy: "sometimes",
 			},
 			wantErr: true,
-			errMsg:  "image.pull_policy 'sometimes' is invalid",
+			errMsg:  "invalid image.pull_policy 'sometimes', must be one of: always, if_missing, never",
 		},
 		{
 			name: "registry strategy with latest tag",
@@ -230,6 +230,7 @@ func TestImage_Validate(t *testing.T) {
 }
 
 func TestImage_EffectivePullPolicy(t *testing.T) {
+	build := false
 	tests := []struct {
 		name string
 		img  Image
@@ -245,6 +246,36 @@ func TestImage_EffectivePullPolicy(t *testing.T) {
 			img:  Image{Repository: "nginx", PullPolicy: PullPolicyIfMissing},
 			want: PullPolicyIfMissing,
 		},
+		{
+			name: "uploaded images are never pulled",
+			img:  Image{Repository: "web", BuildConfig: &BuildConfig{}},
+			want: PullPolicyNever,
+		},
+		{
+			name: "images pinned by ID are never pulled",
+			img:  Image{Repository: "sha256:abc", Build: &build, BuildConfig: &BuildConfig{Push: BuildPushOptionServer}},
+			want: PullPolicyNever,
+		},
+		{
+			name: "images not built by haloy are pulled",
+			img:  Image{Repository: "web", Build: &build, BuildConfig: &BuildConfig{}},
+			want: PullPolicyAlways,
+		},
+		{
+			name: "pushed images are pulled",
+			img:  Image{Repository: "ghcr.io/acme/web", BuildConfig: &BuildConfig{Push: BuildPushOptionRegistry}},
+			want: PullPolicyAlways,
+		},
+		{
+			name: "images pinned by digest are pulled if missing",
+			img:  Image{Repository: "ghcr.io/acme/web@sha256:def", BuildConfig: &BuildConfig{Push: BuildPushOptionRegistry}},
+			want: PullPolicyIfMissing,
+		},
+		{
+			name: "explicit pull policy wins",
+			img:  Image{Repository: "web", BuildConfig: &BuildConfig{}, PullPolicy: PullPolicyAlways},
+			want: PullPolicyAlways,
+		},
 	}
 
 	for _, tt := range tests {
@@ -300,7 +331,7 @@ func TestImageHistory_Validate(t *testing.T) {
 				Strategy: "invalid-strategy",
 			},
 			wantErr: true,
-			errMsg:  "must be 'local', 'registry', or 'none'",
+			errMsg:  "must be one of: local, registry, none",
 		},
 		{
 			name: "local strategy missing count",
@@ -430,7 +461,7 @@ func TestBuildConfig_Validate(t *testing.T) {
 				Push:       "invalid-option",
 			},
 			wantErr: true,
-			errMsg:  "builder.push must be 'server' or 'registry'",
+			errMsg:  "invalid builder.push 'invalid-option', must be one of: server, registry",
 		},
 		{
 			name: "context with whitespace",
@@ -1,39 +1,218 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/helpers"
 )
 
+// LabelPrefix starts the container labels haloy manages.
+const LabelPrefix = "dev.haloy."
+
+// LabelSchemaVersion is the version of the label format this haloy writes.
+// Bump it whenever a label is renamed or changes meaning, and add a migration
+// to labelMigrations so containers labeled by an older haloy keep working.
+const LabelSchemaVersion = 1
+
 const (
-	LabelAppName         = "dev.haloy.appName"
-	LabelDeploymentID    = "dev.haloy.deployment-id"
-	LabelHealthCheckPath = "dev.haloy.health-check-path" // optional default to "/"
-	LabelPort            = "dev.haloy.port"              // optional
-	LabelMinReadySeconds = "dev.haloy.min-ready-seconds" // optional, default 0
+	// LabelSchema holds the LabelSchemaVersion a container was labeled with.
+	// Containers labeled before the format was versioned lack it.
+	LabelSchema = "dev.haloy.label-schema"
+
+	LabelAppName          = "dev.haloy.appName"
+	LabelDeploymentID     = "dev.haloy.deployment-id"
+	LabelHealthCheckPath  = "dev.haloy.health-check-path" // optional default to "/"
+	LabelPort             = "dev.haloy.port"              // optional
+	LabelMinReadySeconds  = "dev.haloy.min-ready-seconds" // optional, default 0
+	LabelDatabase         = "dev.haloy.database"          // engine of a managed database, optional
+	LabelSnapshotSchedule = "dev.haloy.snapshot-schedule" // cron expression, optional
+	LabelSnapshotRetain   = "dev.haloy.snapshot-retain"   // optional
+	LabelSnapshotRemote   = "dev.haloy.snapshot-remote"   // s3:// URL, optional
+	LabelPreviewBranch    = "dev.haloy.preview-branch"    // branch of a preview, optional
+	LabelPreviewExpires   = "dev.haloy.preview-expires"   // RFC 3339 time a preview is destroyed at
+
+	// Health check type, optional and http when unset. The command of cmd
+	// checks is stored as a JSON list of exec arguments.
+	LabelHealthCheckType = "dev.haloy.health-check-type"
+	LabelHealthCheckCmd  = "dev.haloy.health-check-cmd"
+
+	// Startup probe, optional. Both are Go durations.
+	LabelStartupTimeout = "dev.haloy.startup-timeout"
+	LabelStartupPeriod  = "dev.haloy.startup-period"
+
+	// Proxy limits, optional. Body size is in bytes, timeouts are Go durations.
+	LabelClientMaxBodySize = "dev.haloy.client-max-body-size"
+	LabelProxyReadTimeout  = "dev.haloy.proxy-read-timeout"
+	LabelProxySendTimeout  = "dev.haloy.proxy-send-timeout"
+
+	// Redirects the proxy answers for the app's domains, as a JSON list.
+	LabelRedirects = "dev.haloy.redirects"
+	// URL normalization, optional. The booleans are only set when "true".
+	LabelURLTrailingSlash = "dev.haloy.url-trailing-slash"
+	LabelURLLowercase     = "dev.haloy.url-lowercase"
+	LabelURLMergeSlashes  = "dev.haloy.url-merge-slashes"
+	// Client certificate authentication, optional: the PEM encoded CA and
+	// the mode, set together.
+	LabelClientAuthCA   = "dev.haloy.client-auth-ca"
+	LabelClientAuthMode = "dev.haloy.client-auth-mode"
+	// Single sign-on settings, as JSON with the resolved client secret.
+	LabelSSO = "dev.haloy.sso"
+
+	// Sidecar containers carry these instead of LabelAppName, so routing and
+	// health checks never mistake them for app replicas.
+	LabelSidecarOf         = "dev.haloy.sidecar-of"          // app name
+	LabelSidecarName       = "dev.haloy.sidecar"             // sidecar name from the config
+	LabelSidecarParent     = "dev.haloy.sidecar-parent"      // ID of the app container it belongs to
+	LabelSidecarHealthGate = "dev.haloy.sidecar-health-gate" // "true" when the app's health depends on it
 
 	// Format strings for indexed canonical domains and aliases.
 	// Use fmt.Sprintf(LabelDomainCanonical, index) to get "dev.haloy.domain.<index>"
 	LabelDomainCanonical = "dev.haloy.domain.%d"
 	// Use fmt.Sprintf(LabelDomainAlias, domainIndex, aliasIndex) to get "dev.haloy.domain.<domainIndex>.alias.<aliasIndex>"
 	LabelDomainAlias = "dev.haloy.domain.%d.alias.%d"
+	// Optional path routing for a domain, set only when a path_prefix is configured.
+	LabelDomainPathPrefix  = "dev.haloy.domain.%d.path-prefix"
+	LabelDomainStripPrefix = "dev.haloy.domain.%d.strip-prefix"
+	// Set only for domains behind a CDN.
+	LabelDomainCDN = "dev.haloy.domain.%d.cdn"
 )
 
 type ContainerLabels struct {
 	AppName         string
 	DeploymentID    string
 	HealthCheckPath string
+	HealthCheckType HealthCheckType
+	HealthCheckCmd  []string // exec arguments for cmd checks
+	StartupTimeout  time.Duration
+	StartupPeriod   time.Duration
 	Port            Port
 	MinReadySeconds int
 	Domains         []Domain
+	Database        DatabaseEngine
+	Snapshots       *SnapshotsConfig
+	// PreviewBranch and PreviewExpiresAt are set on preview deployments.
+	PreviewBranch    string
+	PreviewExpiresAt time.Time
+
+	ClientMaxBodySize int64
+	ProxyReadTimeout  time.Duration
+	ProxySendTimeout  time.Duration
+	Redirects         []Redirect
+	URLNormalization  *URLNormalization
+	ClientAuth        *ClientAuth
+	SSO               *SSO
+}
+
+// NewContainerLabels returns the labels a deployment of tc sets on its
+// containers. tc is expected to be validated and normalized.
+func NewContainerLabels(tc TargetConfig, deploymentID string) ContainerLabels {
+	cl := ContainerLabels{
+		AppName:         tc.Name,
+		DeploymentID:    deploymentID,
+		Port:            tc.Port,
+		HealthCheckPath: tc.HealthCheckPath,
+		Domains:         tc.Domains,
+		Redirects:       tc.Redirects,
+	}
+	if n := tc.URLNormalization; n != nil && *n != (URLNormalization{}) {
+		cl.URLNormalization = n
+	}
+	if ca := tc.ClientAuth; ca != nil {
+		cl.ClientAuth = &ClientAuth{CAInline: ca.CAInline, Mode: ca.GetMode()}
+	}
+	cl.SSO = tc.SSO
+	if tc.HealthProbe != nil {
+		cl.HealthCheckType = tc.HealthProbe.Type
+		if tc.HealthProbe.Type == HealthCheckCmd {
+			cl.HealthCheckCmd = tc.HealthProbe.Cmd.ExecArgs()
+		}
+	}
+	if tc.Startup != nil {
+		cl.StartupTimeout, cl.StartupPeriod = tc.Startup.Durations()
+	}
+	if tc.MinReadySeconds != nil {
+		cl.MinReadySeconds = *tc.MinReadySeconds
+	}
+	if tc.Database != nil {
+		cl.Database = tc.Database.Engine
+	}
+	if tc.Snapshots != nil {
+		snapshots := *tc.Snapshots
+		cl.Snapshots = &snapshots
+	}
+	if tc.Preview != nil && tc.Preview.Branch != "" && !tc.Preview.ExpiresAt.IsZero() {
+		cl.PreviewBranch = tc.Preview.Branch
+		cl.PreviewExpiresAt = tc.Preview.ExpiresAt
+	}
+	if size, err := helpers.ParseBytes(tc.ClientMaxBodySize); err == nil {
+		cl.ClientMaxBodySize = int64(size)
+	}
+	cl.ProxyReadTimeout, _ = time.ParseDuration(tc.ProxyReadTimeout)
+	cl.ProxySendTimeout, _ = time.ParseDuration(tc.ProxySendTimeout)
+	return cl
+}
+
+// labelMigrations[v] rewrites labels in schema version v to version v+1.
+var labelMigrations = []func(labels map[string]string){
+	// Unversioned labels are the same as version 1, which only adds the
+	// schema label.
+	0: func(map[string]string) {},
+}
+
+// LabelSchemaOf returns the schema version of a container's labels, 0 for
+// labels from before the format was versioned.
+func LabelSchemaOf(labels map[string]string) (int, error) {
+	v, ok := labels[LabelSchema]
+	if !ok {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid %s label %q", LabelSchema, v)
+	}
+	return version, nil
+}
+
+// UpgradeLabels returns a copy of labels migrated to LabelSchemaVersion.
+// Labels from a newer haloy are returned unchanged, as there is no way to
+// downgrade them; whatever this version understands of them still parses.
+func UpgradeLabels(labels map[string]string) (map[string]string, error) {
+	version, err := LabelSchemaOf(labels)
+	if err != nil {
+		return nil, err
+	}
+	upgraded := maps.Clone(labels)
+	if version >= LabelSchemaVersion {
+		return upgraded, nil
+	}
+	for v := version; v < LabelSchemaVersion; v++ {
+		labelMigrations[v](upgraded)
+	}
+	upgraded[LabelSchema] = strconv.Itoa(LabelSchemaVersion)
+	return upgraded, nil
 }
 
-// Parse from docker labels to ContainerLabels struct.
+// ParseContainerLabels parses the labels of a container. Labels in an older
+// schema are migrated first, and labels from a newer one parsed as far as
+// this version understands them.
 func ParseContainerLabels(labels map[string]string) (*ContainerLabels, error) {
+	version, err := LabelSchemaOf(labels)
+	if err != nil {
+		return nil, err
+	}
+	if version < LabelSchemaVersion {
+		if labels, err = UpgradeLabels(labels); err != nil {
+			return nil, err
+		}
+	}
+
 	cl := &ContainerLabels{
 		AppName:      labels[LabelAppName],
 		DeploymentID: labels[LabelDeploymentID],
@@ -51,6 +230,41 @@ func ParseContainerLabels(labels map[string]string) (*ContainerLabels, error) {
 	} else {
 		cl.HealthCheckPath = constants.DefaultHealthCheckPath
 	}
+	if v, ok := labels[LabelHealthCheckType]; ok {
+		cl.HealthCheckType = HealthCheckType(v)
+	}
+	if v, ok := labels[LabelHealthCheckCmd]; ok {
+		if err := json.Unmarshal([]byte(v), &cl.HealthCheckCmd); err != nil {
+			return nil, fmt.Errorf("invalid %s label: %w", LabelHealthCheckCmd, err)
+		}
+	}
+	if v, ok := labels[LabelStartupTimeout]; ok {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			cl.StartupTimeout = parsed
+			cl.StartupPeriod = DefaultStartupPeriod
+		}
+	}
+	if v, ok := labels[LabelStartupPeriod]; ok && cl.StartupTimeout > 0 {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			cl.StartupPeriod = parsed
+		}
+	}
+
+	cl.Database = DatabaseEngine(labels[LabelDatabase])
+
+	if schedule := labels[LabelSnapshotSchedule]; schedule != "" {
+		cl.Snapshots = &SnapshotsConfig{Schedule: schedule, Remote: labels[LabelSnapshotRemote]}
+		if retain, err := strconv.Atoi(labels[LabelSnapshotRetain]); err == nil {
+			cl.Snapshots.Retain = retain
+		}
+	}
+
+	if branch := labels[LabelPreviewBranch]; branch != "" {
+		if expiresAt, err := time.Parse(time.RFC3339, labels[LabelPreviewExpires]); err == nil {
+			cl.PreviewBranch = branch
+			cl.PreviewExpiresAt = expiresAt
+		}
+	}
 
 	if v, ok := labels[LabelMinReadySeconds]; ok {
 		if parsed, err := strconv.Atoi(v); err == nil {
@@ -58,6 +272,47 @@ func ParseContainerLabels(labels map[string]string) (*ContainerLabels, error) {
 		}
 	}
 
+	if v, ok := labels[LabelClientMaxBodySize]; ok {
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil && parsed > 0 {
+			cl.ClientMaxBodySize = parsed
+		}
+	}
+	if v, ok := labels[LabelProxyReadTimeout]; ok {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			cl.ProxyReadTimeout = parsed
+		}
+	}
+	if v, ok := labels[LabelProxySendTimeout]; ok {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			cl.ProxySendTimeout = parsed
+		}
+	}
+
+	if v, ok := labels[LabelRedirects]; ok {
+		if err := json.Unmarshal([]byte(v), &cl.Redirects); err != nil {
+			return nil, fmt.Errorf("invalid %s label: %w", LabelRedirects, err)
+		}
+	}
+
+	normalization := URLNormalization{
+		TrailingSlash: labels[LabelURLTrailingSlash],
+		Lowercase:     labels[LabelURLLowercase] == "true",
+		MergeSlashes:  labels[LabelURLMergeSlashes] == "true",
+	}
+	if normalization != (URLNormalization{}) {
+		cl.URLNormalization = &normalization
+	}
+
+	if ca, ok := labels[LabelClientAuthCA]; ok {
+		cl.ClientAuth = &ClientAuth{CAInline: ca, Mode: labels[LabelClientAuthMode]}
+	}
+
+	if v, ok := labels[LabelSSO]; ok {
+		if err := json.Unmarshal([]byte(v), &cl.SSO); err != nil {
+			return nil, fmt.Errorf("invalid %s label: %w", LabelSSO, err)
+		}
+	}
+
 	// Parse domains
 	domainMap := make(map[int]*Domain)
 
@@ -66,7 +321,26 @@ func ParseContainerLabels(labels map[string]string) (*ContainerLabels, error) {
 		if !strings.HasPrefix(key, "dev.haloy.domain.") {
 			continue
 		}
-		if strings.Contains(key, ".alias.") {
+		switch {
+		case strings.HasSuffix(key, ".path-prefix"):
+			var domainIdx int
+			if _, err := fmt.Sscanf(key, LabelDomainPathPrefix, &domainIdx); err != nil {
+				continue
+			}
+			getOrCreateDomain(domainMap, domainIdx).PathPrefix = value
+		case strings.HasSuffix(key, ".strip-prefix"):
+			var domainIdx int
+			if _, err := fmt.Sscanf(key, LabelDomainStripPrefix, &domainIdx); err != nil {
+				continue
+			}
+			getOrCreateDomain(domainMap, domainIdx).StripPrefix = value == "true"
+		case strings.HasSuffix(key, ".cdn"):
+			var domainIdx int
+			if _, err := fmt.Sscanf(key, LabelDomainCDN, &domainIdx); err != nil {
+				continue
+			}
+			getOrCreateDomain(domainMap, domainIdx).CDN = value
+		case strings.Contains(key, ".alias."):
 			// Parse alias key: "dev.haloy.domain.<domainIdx>.alias.<aliasIdx>"
 			var domainIdx, aliasIdx int
 			if _, err := fmt.Sscanf(key, LabelDomainAlias, &domainIdx, &aliasIdx); err != nil {
@@ -75,7 +349,7 @@ func ParseContainerLabels(labels map[string]string) (*ContainerLabels, error) {
 			}
 			domain := getOrCreateDomain(domainMap, domainIdx)
 			domain.Aliases = append(domain.Aliases, value)
-		} else {
+		default:
 			// Parse canonical domain key: "dev.haloy.domain.<domainIdx>"
 			var domainIdx int
 			if _, err := fmt.Sscanf(key, LabelDomainCanonical, &domainIdx); err != nil {
@@ -116,16 +390,91 @@ func getOrCreateDomain(domainMap map[int]*Domain, idx int) *Domain {
 // ToLabels converts the ContainerLabels struct back to a map[string]string.
 func (cl *ContainerLabels) ToLabels() map[string]string {
 	labels := map[string]string{
+		LabelSchema:          strconv.Itoa(LabelSchemaVersion),
 		LabelAppName:         cl.AppName,
 		LabelDeploymentID:    cl.DeploymentID,
 		LabelHealthCheckPath: cl.HealthCheckPath,
 		LabelPort:            cl.Port.String(),
 	}
 
+	if cl.HealthCheckType != "" {
+		labels[LabelHealthCheckType] = string(cl.HealthCheckType)
+	}
+	if len(cl.HealthCheckCmd) > 0 {
+		if cmd, err := json.Marshal(cl.HealthCheckCmd); err == nil {
+			labels[LabelHealthCheckCmd] = string(cmd)
+		}
+	}
+
+	if cl.StartupTimeout > 0 {
+		labels[LabelStartupTimeout] = cl.StartupTimeout.String()
+		if cl.StartupPeriod > 0 {
+			labels[LabelStartupPeriod] = cl.StartupPeriod.String()
+		}
+	}
+
 	if cl.MinReadySeconds > 0 {
 		labels[LabelMinReadySeconds] = strconv.Itoa(cl.MinReadySeconds)
 	}
 
+	if cl.Database != "" {
+		labels[LabelDatabase] = string(cl.Database)
+	}
+
+	if cl.Snapshots != nil {
+		labels[LabelSnapshotSchedule] = cl.Snapshots.Schedule
+		if cl.Snapshots.Retain > 0 {
+			labels[LabelSnapshotRetain] = strconv.Itoa(cl.Snapshots.Retain)
+		}
+		if cl.Snapshots.Remote != "" {
+			labels[LabelSnapshotRemote] = cl.Snapshots.Remote
+		}
+	}
+
+	if cl.PreviewBranch != "" {
+		labels[LabelPreviewBranch] = cl.PreviewBranch
+		labels[LabelPreviewExpires] = cl.PreviewExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	if cl.ClientMaxBodySize > 0 {
+		labels[LabelClientMaxBodySize] = strconv.FormatInt(cl.ClientMaxBodySize, 10)
+	}
+	if cl.ProxyReadTimeout > 0 {
+		labels[LabelProxyReadTimeout] = cl.ProxyReadTimeout.String()
+	}
+	if cl.ProxySendTimeout > 0 {
+		labels[LabelProxySendTimeout] = cl.ProxySendTimeout.String()
+	}
+
+	if len(cl.Redirects) > 0 {
+		if redirects, err := json.Marshal(cl.Redirects); err == nil {
+			labels[LabelRedirects] = string(redirects)
+		}
+	}
+
+	if n := cl.URLNormalization; n != nil {
+		if n.TrailingSlash != "" {
+			labels[LabelURLTrailingSlash] = n.TrailingSlash
+		}
+		if n.Lowercase {
+			labels[LabelURLLowercase] = "true"
+		}
+		if n.MergeSlashes {
+			labels[LabelURLMergeSlashes] = "true"
+		}
+	}
+
+	if ca := cl.ClientAuth; ca != nil {
+		labels[LabelClientAuthCA] = ca.CAInline
+		labels[LabelClientAuthMode] = ca.GetMode()
+	}
+
+	if cl.SSO != nil {
+		if sso, err := json.Marshal(cl.SSO); err == nil {
+			labels[LabelSSO] = string(sso)
+		}
+	}
+
 	// Iterate through the domains slice.
 	for i, domain := range cl.Domains {
 		// Set canonical domain.
@@ -137,6 +486,16 @@ func (cl *ContainerLabels) ToLabels() map[string]string {
 			aliasKey := fmt.Sprintf(LabelDomainAlias, i, j)
 			labels[aliasKey] = alias
 		}
+
+		if prefix := domain.NormalizedPathPrefix(); prefix != "" {
+			labels[fmt.Sprintf(LabelDomainPathPrefix, i)] = prefix
+			if domain.StripPrefix {
+				labels[fmt.Sprintf(LabelDomainStripPrefix, i)] = "true"
+			}
+		}
+		if domain.CDN != "" {
+			labels[fmt.Sprintf(LabelDomainCDN, i)] = domain.CDN
+		}
 	}
 
 	return labels
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"slices"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestContainerLabels_MinReadySeconds_RoundTrip(t *testing.T) {
@@ -63,3 +66,298 @@ func TestContainerLabels_MinReadySeconds_RoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestContainerLabels_ProxyLimits_RoundTrip(t *testing.T) {
+	cl := &ContainerLabels{
+		AppName:           "test-app",
+		DeploymentID:      "deploy-1",
+		Port:              "8080",
+		ClientMaxBodySize: 50 << 20,
+		ProxyReadTimeout:  5 * time.Minute,
+		ProxySendTimeout:  30 * time.Second,
+	}
+
+	parsed, err := ParseContainerLabels(cl.ToLabels())
+	if err != nil {
+		t.Fatalf("ParseContainerLabels() unexpected error = %v", err)
+	}
+	if parsed.ClientMaxBodySize != cl.ClientMaxBodySize {
+		t.Errorf("ClientMaxBodySize = %d, want %d", parsed.ClientMaxBodySize, cl.ClientMaxBodySize)
+	}
+	if parsed.ProxyReadTimeout != cl.ProxyReadTimeout {
+		t.Errorf("ProxyReadTimeout = %v, want %v", parsed.ProxyReadTimeout, cl.ProxyReadTimeout)
+	}
+	if parsed.ProxySendTimeout != cl.ProxySendTimeout {
+		t.Errorf("ProxySendTimeout = %v, want %v", parsed.ProxySendTimeout, cl.ProxySendTimeout)
+	}
+
+	unset := (&ContainerLabels{AppName: "test-app", DeploymentID: "deploy-1", Port: "8080"}).ToLabels()
+	for _, key := range []string{LabelClientMaxBodySize, LabelProxyReadTimeout, LabelProxySendTimeout} {
+		if _, ok := unset[key]; ok {
+			t.Errorf("expected label %s to be absent when unset", key)
+		}
+	}
+}
+
+func TestContainerLabels_PathPrefix_RoundTrip(t *testing.T) {
+	cl := &ContainerLabels{
+		AppName:      "test-app",
+		DeploymentID: "deploy-1",
+		Port:         "8080",
+		Domains: []Domain{
+			{Canonical: "example.com", Aliases: []string{"www.example.com"}, PathPrefix: "/api/", StripPrefix: true},
+			{Canonical: "other.com"},
+		},
+	}
+
+	parsed, err := ParseContainerLabels(cl.ToLabels())
+	if err != nil {
+		t.Fatalf("ParseContainerLabels() unexpected error = %v", err)
+	}
+	if len(parsed.Domains) != 2 {
+		t.Fatalf("got %d domains, want 2", len(parsed.Domains))
+	}
+	first := parsed.Domains[0]
+	if first.Canonical != "example.com" || first.PathPrefix != "/api" || !first.StripPrefix || len(first.Aliases) != 1 {
+		t.Errorf("first domain = %+v, want example.com /api with strip and one alias", first)
+	}
+	if second := parsed.Domains[1]; second.PathPrefix != "" || second.StripPrefix {
+		t.Errorf("second domain = %+v, want no path routing", second)
+	}
+}
+
+func TestContainerLabels_CDN_RoundTrip(t *testing.T) {
+	cl := &ContainerLabels{
+		AppName:      "test-app",
+		DeploymentID: "deploy-1",
+		Port:         "8080",
+		Domains: []Domain{
+			{Canonical: "example.com", Aliases: []string{"www.example.com"}, CDN: "cloudflare"},
+			{Canonical: "other.com"},
+		},
+	}
+
+	labels := cl.ToLabels()
+	if _, ok := labels["dev.haloy.domain.1.cdn"]; ok {
+		t.Errorf("domain without a CDN must not get a cdn label")
+	}
+	parsed, err := ParseContainerLabels(labels)
+	if err != nil {
+		t.Fatalf("ParseContainerLabels() unexpected error = %v", err)
+	}
+	if got := parsed.Domains[0]; got.CDN != "cloudflare" || len(got.Aliases) != 1 {
+		t.Errorf("first domain = %+v, want cloudflare with one alias", got)
+	}
+	if got := parsed.Domains[1].CDN; got != "" {
+		t.Errorf("second domain CDN = %q, want none", got)
+	}
+}
+
+func TestContainerLabels_Redirects_RoundTrip(t *testing.T) {
+	tc := TargetConfig{
+		Name:    "test-app",
+		Port:    "8080",
+		Domains: []Domain{{Canonical: "www.example.com", Aliases: []string{"example.com"}}},
+		Redirects: []Redirect{
+			{From: "/old-path", To: "/new-path", Status: 308},
+			{Host: "example.com", To: "https://www.example.com/*"},
+		},
+	}
+
+	cl := NewContainerLabels(tc, "deploy-1")
+	parsed, err := ParseContainerLabels(cl.ToLabels())
+	if err != nil {
+		t.Fatalf("ParseContainerLabels() unexpected error = %v", err)
+	}
+	if !slices.Equal(parsed.Redirects, tc.Redirects) {
+		t.Errorf("redirects = %+v, want %+v", parsed.Redirects, tc.Redirects)
+	}
+}
+
+func TestContainerLabels_URLNormalization_RoundTrip(t *testing.T) {
+	tc := TargetConfig{
+		Name:             "test-app",
+		Port:             "8080",
+		URLNormalization: &URLNormalization{TrailingSlash: "add", MergeSlashes: true},
+	}
+
+	cl := NewContainerLabels(tc, "deploy-1")
+	labels := cl.ToLabels()
+	if _, ok := labels[LabelURLLowercase]; ok {
+		t.Errorf("labels have %s, want it only when set", LabelURLLowercase)
+	}
+	parsed, err := ParseContainerLabels(labels)
+	if err != nil {
+		t.Fatalf("ParseContainerLabels() unexpected error = %v", err)
+	}
+	if parsed.URLNormalization == nil || *parsed.URLNormalization != *tc.URLNormalization {
+		t.Errorf("URL normalization = %+v, want %+v", parsed.URLNormalization, tc.URLNormalization)
+	}
+
+	cl = NewContainerLabels(TargetConfig{Name: "test-app", Port: "8080", URLNormalization: &URLNormalization{}}, "deploy-1")
+	if parsed, err = ParseContainerLabels(cl.ToLabels()); err != nil {
+		t.Fatalf("ParseContainerLabels() unexpected error = %v", err)
+	}
+	if parsed.URLNormalization != nil {
+		t.Errorf("URL normalization = %+v, want nil when nothing is normalized", parsed.URLNormalization)
+	}
+}
+
+func TestContainerLabels_HealthCheckType_RoundTrip(t *testing.T) {
+	tc := TargetConfig{
+		Name:        "test-app",
+		Port:        "8080",
+		HealthProbe: &HealthProbe{Type: HealthCheckCmd, Cmd: HealthCheckCommand{"pg_isready"}},
+	}
+	cl := NewContainerLabels(tc, "deploy-1")
+
+	parsed, err := ParseContainerLabels(cl.ToLabels())
+	if err != nil {
+		t.Fatalf("ParseContainerLabels() unexpected error = %v", err)
+	}
+	if parsed.HealthCheckType != HealthCheckCmd {
+		t.Errorf("HealthCheckType = %q, want %q", parsed.HealthCheckType, HealthCheckCmd)
+	}
+	if want := []string{"/bin/sh", "-c", "pg_isready"}; !slices.Equal(parsed.HealthCheckCmd, want) {
+		t.Errorf("HealthCheckCmd = %v, want %v", parsed.HealthCheckCmd, want)
+	}
+
+	unset := (&ContainerLabels{AppName: "test-app", DeploymentID: "deploy-1", Port: "8080"}).ToLabels()
+	for _, key := range []string{LabelHealthCheckType, LabelHealthCheckCmd, LabelStartupTimeout, LabelStartupPeriod} {
+		if _, ok := unset[key]; ok {
+			t.Errorf("expected label %s to be absent when unset", key)
+		}
+	}
+}
+
+func TestContainerLabels_Startup_RoundTrip(t *testing.T) {
+	tc := TargetConfig{Name: "test-app", Port: "8080", Startup: &StartupProbe{Timeout: "5m"}}
+	cl := NewContainerLabels(tc, "deploy-1")
+	parsed, err := ParseContainerLabels(cl.ToLabels())
+	if err != nil {
+		t.Fatalf("ParseContainerLabels() unexpected error = %v", err)
+	}
+	if parsed.StartupTimeout != 5*time.Minute || parsed.StartupPeriod != DefaultStartupPeriod {
+		t.Errorf("startup = %s every %s, want 5m every %s", parsed.StartupTimeout, parsed.StartupPeriod, DefaultStartupPeriod)
+	}
+}
+
+func TestContainerLabels_Snapshots_RoundTrip(t *testing.T) {
+	tc := TargetConfig{Name: "test-app", Port: "8080", Snapshots: &SnapshotsConfig{Schedule: "0 3 * * *", Retain: 14, Remote: "s3://backups/haloy"}}
+	cl := NewContainerLabels(tc, "deploy-1")
+	parsed, err := ParseContainerLabels(cl.ToLabels())
+	if err != nil {
+		t.Fatalf("ParseContainerLabels() unexpected error = %v", err)
+	}
+	if parsed.Snapshots == nil || *parsed.Snapshots != *tc.Snapshots {
+		t.Errorf("snapshots = %+v, want %+v", parsed.Snapshots, tc.Snapshots)
+	}
+
+	cl = NewContainerLabels(TargetConfig{Name: "test-app", Port: "8080"}, "deploy-1")
+	parsed, _ = ParseContainerLabels(cl.ToLabels())
+	if parsed.Snapshots != nil {
+		t.Errorf("snapshots = %+v without a snapshots section, want nil", parsed.Snapshots)
+	}
+}
+
+func TestContainerLabels_Preview_RoundTrip(t *testing.T) {
+	expiresAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tc := TargetConfig{Name: "shop-feature-x", Port: "8080", Preview: &PreviewConfig{Domain: "preview.example.com", Branch: "feature-x", ExpiresAt: expiresAt}}
+	cl := NewContainerLabels(tc, "deploy-1")
+	parsed, err := ParseContainerLabels(cl.ToLabels())
+	if err != nil {
+		t.Fatalf("ParseContainerLabels() unexpected error = %v", err)
+	}
+	if parsed.PreviewBranch != "feature-x" || !parsed.PreviewExpiresAt.Equal(expiresAt) {
+		t.Errorf("preview = %q until %v, want feature-x until %v", parsed.PreviewBranch, parsed.PreviewExpiresAt, expiresAt)
+	}
+
+	// A target with a preview section that isn't a preview itself.
+	tc.Preview = &PreviewConfig{Domain: "preview.example.com"}
+	cl = NewContainerLabels(tc, "deploy-1")
+	if labels := cl.ToLabels(); labels[LabelPreviewBranch] != "" || labels[LabelPreviewExpires] != "" {
+		t.Errorf("labels = %v, want no preview labels", labels)
+	}
+}
+
+func TestContainerLabels_SchemaVersion(t *testing.T) {
+	labels := (&ContainerLabels{AppName: "app", DeploymentID: "01h7vxpqzk0000000000000000", Port: "8080"}).ToLabels()
+	if got := labels[LabelSchema]; got != strconv.Itoa(LabelSchemaVersion) {
+		t.Errorf("%s = %q, want %d", LabelSchema, got, LabelSchemaVersion)
+	}
+
+	tests := []struct {
+		name    string
+		schema  string
+		want    int
+		wantErr bool
+	}{
+		{name: "unversioned", want: 0},
+		{name: "current", schema: strconv.Itoa(LabelSchemaVersion), want: LabelSchemaVersion},
+		{name: "newer", schema: strconv.Itoa(LabelSchemaVersion + 1), want: LabelSchemaVersion + 1},
+		{name: "invalid", schema: "v2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := map[string]string{
+				LabelAppName:      "app",
+				LabelDeploymentID: "01h7vxpqzk0000000000000000",
+				LabelPort:         "8080",
+			}
+			if tt.schema != "" {
+				labels[LabelSchema] = tt.schema
+			}
+
+			version, err := LabelSchemaOf(labels)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("LabelSchemaOf() error = nil, want an error")
+				}
+				if _, err := ParseContainerLabels(labels); err == nil {
+					t.Error("ParseContainerLabels() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil || version != tt.want {
+				t.Fatalf("LabelSchemaOf() = %d, %v, want %d", version, err, tt.want)
+			}
+
+			parsed, err := ParseContainerLabels(labels)
+			if err != nil {
+				t.Fatalf("ParseContainerLabels() error = %v", err)
+			}
+			if parsed.AppName != "app" || parsed.Port != "8080" {
+				t.Errorf("ParseContainerLabels() = %+v", parsed)
+			}
+
+			upgraded, err := UpgradeLabels(labels)
+			if err != nil {
+				t.Fatalf("UpgradeLabels() error = %v", err)
+			}
+			if want := strconv.Itoa(max(tt.want, LabelSchemaVersion)); upgraded[LabelSchema] != want {
+				t.Errorf("upgraded %s = %q, want %s", LabelSchema, upgraded[LabelSchema], want)
+			}
+			if labels[LabelSchema] != tt.schema {
+				t.Error("UpgradeLabels() modified its input")
+			}
+		})
+	}
+}
+
+func TestContainerLabels_ClientAuth_RoundTrip(t *testing.T) {
+	tc := TargetConfig{
+		Name:       "test-app",
+		Port:       "8080",
+		ClientAuth: &ClientAuth{CAInline: "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"},
+	}
+
+	cl := NewContainerLabels(tc, "deploy-1")
+	parsed, err := ParseContainerLabels(cl.ToLabels())
+	if err != nil {
+		t.Fatalf("ParseContainerLabels() unexpected error = %v", err)
+	}
+	want := ClientAuth{CAInline: tc.ClientAuth.CAInline, Mode: "require"}
+	if parsed.ClientAuth == nil || *parsed.ClientAuth != want {
+		t.Errorf("client auth = %+v, want %+v", parsed.ClientAuth, want)
+	}
+}
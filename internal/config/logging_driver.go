@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LoggingDriver is the Docker logging driver of the target's containers,
+// e.g. json-file with max-size and max-file options to cap the logs kept
+// on disk.
+type LoggingDriver struct {
+	Name    string            `json:"name" yaml:"name" toml:"name"`
+	Options map[string]string `json:"options,omitempty" yaml:"options,omitempty" toml:"options,omitempty"`
+}
+
+// loggingDriverNamePattern allows built-in drivers and plugins such as
+// grafana/loki-docker-driver:latest.
+var loggingDriverNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._/:-]*$`)
+
+func (l *LoggingDriver) Validate(format string) error {
+	name := GetFieldNameForFormat(TargetConfig{}, "LoggingDriver", format)
+	if l.Name == "" {
+		return fmt.Errorf("%s.name is required", name)
+	}
+	if !loggingDriverNamePattern.MatchString(l.Name) {
+		return fmt.Errorf("invalid %s.name '%s'", name, l.Name)
+	}
+	for key := range l.Options {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("%s.options cannot have an empty key", name)
+		}
+	}
+	return nil
+}
+
+// validateLabels checks the extra container labels of a target. The
+// dev.haloy. prefix is reserved for the labels haloy manages itself.
+func validateLabels(labels map[string]string, format string) error {
+	name := GetFieldNameForFormat(TargetConfig{}, "Labels", format)
+	for key := range labels {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("%s cannot have an empty key", name)
+		}
+		if strings.HasPrefix(key, LabelPrefix) {
+			return fmt.Errorf("invalid %s key '%s': the %s prefix is reserved for haloy", name, key, LabelPrefix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoggingDriver_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		driver  LoggingDriver
+		wantErr string
+	}{
+		{name: "json-file with limits", driver: LoggingDriver{Name: "json-file", Options: map[string]string{"max-size": "10m", "max-file": "3"}}},
+		{name: "plugin", driver: LoggingDriver{Name: "grafana/loki-docker-driver:latest"}},
+		{name: "missing name", driver: LoggingDriver{Options: map[string]string{"max-size": "10m"}}, wantErr: "logging_driver.name is required"},
+		{name: "invalid name", driver: LoggingDriver{Name: "json file"}, wantErr: "invalid logging_driver.name"},
+		{name: "empty option key", driver: LoggingDriver{Name: "local", Options: map[string]string{" ": "x"}}, wantErr: "empty key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.driver.Validate("yaml")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	if err := validateLabels(map[string]string{"com.example.team": "payments", "logging": "promtail"}, "yaml"); err != nil {
+		t.Errorf("validateLabels() error = %v", err)
+	}
+	if err := validateLabels(map[string]string{LabelAppName: "other"}, "yaml"); err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Errorf("validateLabels() error = %v, want the haloy prefix to be reserved", err)
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PortMapping publishes a container port on the server, for traffic that
+// doesn't go through the HTTP proxy, such as UDP.
+type PortMapping struct {
+	// HostIP is the address the port is published on, all addresses when
+	// empty.
+	HostIP        string
+	HostPort      int
+	ContainerPort int
+	// Protocol is "tcp" or "udp".
+	Protocol string
+}
+
+func (p PortMapping) String() string {
+	host := strconv.Itoa(p.HostPort)
+	if p.HostIP != "" {
+		host = net.JoinHostPort(p.HostIP, host)
+	}
+	return fmt.Sprintf("%s:%d/%s", host, p.ContainerPort, p.Protocol)
+}
+
+// Overlaps reports whether both mappings publish the same host port, which
+// only one container can bind.
+func (p PortMapping) Overlaps(other PortMapping) bool {
+	if p.HostPort != other.HostPort || p.Protocol != other.Protocol {
+		return false
+	}
+	return p.HostIP == "" || other.HostIP == "" || net.ParseIP(p.HostIP).Equal(net.ParseIP(other.HostIP))
+}
+
+// ParsePortMapping parses a port given as [ip:]hostPort:containerPort[/protocol],
+// e.g. "2222:22" or "127.0.0.1:514:514/udp". IPv6 addresses are written in
+// brackets. The protocol defaults to tcp.
+func ParsePortMapping(raw string) (PortMapping, error) {
+	invalid := func(reason string) (PortMapping, error) {
+		return PortMapping{}, fmt.Errorf("invalid port '%s': %s", raw, reason)
+	}
+
+	spec, protocol, hasProtocol := strings.Cut(raw, "/")
+	if !hasProtocol {
+		protocol = "tcp"
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return invalid("protocol must be tcp or udp")
+	}
+	mapping := PortMapping{Protocol: protocol}
+
+	if strings.HasPrefix(spec, "[") {
+		end := strings.Index(spec, "]:")
+		if end < 0 {
+			return invalid("expected [ipv6]:hostPort:containerPort")
+		}
+		mapping.HostIP = spec[1:end]
+		spec = spec[end+2:]
+	}
+	parts := strings.Split(spec, ":")
+	switch {
+	case len(parts) == 3 && mapping.HostIP == "":
+		mapping.HostIP = parts[0]
+		parts = parts[1:]
+	case len(parts) != 2:
+		return invalid("expected [ip:]hostPort:containerPort[/protocol]")
+	}
+	if mapping.HostIP != "" && net.ParseIP(mapping.HostIP) == nil {
+		return invalid(fmt.Sprintf("'%s' is not an IP address", mapping.HostIP))
+	}
+
+	var err error
+	if mapping.HostPort, err = parsePortNumber(parts[0]); err != nil {
+		return invalid("host " + err.Error())
+	}
+	if mapping.ContainerPort, err = parsePortNumber(parts[1]); err != nil {
+		return invalid("container " + err.Error())
+	}
+	return mapping, nil
+}
+
+func parsePortNumber(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port '%s' must be a number from 1 to 65535", s)
+	}
+	return port, nil
+}
+
+// PortMappings parses the target's published ports. Assumes Validate passed.
+func (tc *TargetConfig) PortMappings() []PortMapping {
+	mappings := make([]PortMapping, 0, len(tc.Ports))
+	for _, raw := range tc.Ports {
+		if mapping, err := ParsePortMapping(raw); err == nil {
+			mappings = append(mappings, mapping)
+		}
+	}
+	return mappings
+}
+
+func (tc *TargetConfig) validatePorts(format string) error {
+	if len(tc.Ports) == 0 {
+		return nil
+	}
+	name := GetFieldNameForFormat(TargetConfig{}, "Ports", format)
+
+	var mappings []PortMapping
+	for _, raw := range tc.Ports {
+		mapping, err := ParsePortMapping(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for _, other := range mappings {
+			if mapping.Overlaps(other) {
+				return fmt.Errorf("%s: host port %d/%s is published twice", name, mapping.HostPort, mapping.Protocol)
+			}
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	// Only one container can bind a host port, so the old containers must
+	// be gone before the new one starts.
+	if tc.Replicas != nil && *tc.Replicas > 1 {
+		return fmt.Errorf("%s can't be used with more than one replica", name)
+	}
+	if tc.DeploymentStrategy != DeploymentStrategyReplace {
+		return fmt.Errorf("%s requires %s: %s, since new containers can't bind the ports while the old ones run",
+			name, GetFieldNameForFormat(TargetConfig{}, "DeploymentStrategy", format), DeploymentStrategyReplace)
+	}
+	return nil
+}
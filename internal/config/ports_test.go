@@ -0,0 +1,68 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePortMapping(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    PortMapping
+		wantErr string
+	}{
+		{raw: "2222:22", want: PortMapping{HostPort: 2222, ContainerPort: 22, Protocol: "tcp"}},
+		{raw: "514:514/udp", want: PortMapping{HostPort: 514, ContainerPort: 514, Protocol: "udp"}},
+		{raw: "127.0.0.1:5353:53/udp", want: PortMapping{HostIP: "127.0.0.1", HostPort: 5353, ContainerPort: 53, Protocol: "udp"}},
+		{raw: "[::1]:8443:443", want: PortMapping{HostIP: "::1", HostPort: 8443, ContainerPort: 443, Protocol: "tcp"}},
+		{raw: "22", wantErr: "expected [ip:]hostPort:containerPort"},
+		{raw: "2222:22/sctp", wantErr: "protocol must be tcp or udp"},
+		{raw: "70000:22", wantErr: "host port"},
+		{raw: "myhost:2222:22", wantErr: "not an IP address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParsePortMapping(tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParsePortMapping() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePortMapping() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePortMapping() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetConfigValidatePorts(t *testing.T) {
+	one, two := 1, 2
+	tests := []struct {
+		name    string
+		target  TargetConfig
+		wantErr string
+	}{
+		{name: "replace", target: TargetConfig{Ports: []string{"2222:22", "2222:22/udp"}, DeploymentStrategy: DeploymentStrategyReplace, Replicas: &one}},
+		{name: "rolling", target: TargetConfig{Ports: []string{"2222:22"}}, wantErr: "requires deployment_strategy: replace"},
+		{name: "replicas", target: TargetConfig{Ports: []string{"2222:22"}, DeploymentStrategy: DeploymentStrategyReplace, Replicas: &two}, wantErr: "more than one replica"},
+		{name: "duplicate", target: TargetConfig{Ports: []string{"2222:22", "127.0.0.1:2222:23"}, DeploymentStrategy: DeploymentStrategyReplace}, wantErr: "published twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.target.validatePorts("yaml")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validatePorts() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validatePorts() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
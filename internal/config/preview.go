@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+const DefaultPreviewTTL = 72 * time.Hour
+
+// maxPreviewSlugLength keeps preview subdomains and app names well within
+// the DNS label limit of 63 characters.
+const maxPreviewSlugLength = 40
+
+// PreviewConfig lets 'haloy preview create' deploy a copy of the target per
+// branch, reachable at <branch>.<domain>.
+type PreviewConfig struct {
+	// Domain the previews get subdomains of, e.g. "preview.example.com".
+	// Needs a wildcard DNS record pointing at the server.
+	Domain string `json:"domain" yaml:"domain" toml:"domain"`
+	// TTL is how long a preview lives after its last deploy before haloyd
+	// destroys it, e.g. "48h". Defaults to 72h.
+	TTL string `json:"ttl,omitempty" yaml:"ttl,omitempty" toml:"ttl,omitempty"`
+
+	// Branch and ExpiresAt are set on the copies 'haloy preview create'
+	// deploys. Not read from the config file.
+	Branch    string    `json:"branch,omitempty" yaml:"-" toml:"-"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero" yaml:"-" toml:"-"`
+}
+
+// GetTTL returns how long a preview lives, defaulting to 72h.
+func (p *PreviewConfig) GetTTL() time.Duration {
+	ttl, err := time.ParseDuration(p.TTL)
+	if err != nil || ttl <= 0 {
+		return DefaultPreviewTTL
+	}
+	return ttl
+}
+
+func (p *PreviewConfig) Validate() error {
+	if p.Domain == "" {
+		return fmt.Errorf("preview.domain is required")
+	}
+	if err := helpers.IsValidDomain(p.Domain); err != nil {
+		return fmt.Errorf("invalid preview.domain '%s': %w", p.Domain, err)
+	}
+	if p.TTL != "" {
+		ttl, err := time.ParseDuration(p.TTL)
+		if err != nil {
+			return fmt.Errorf("invalid preview.ttl '%s': %w", p.TTL, err)
+		}
+		if ttl < time.Minute {
+			return fmt.Errorf("invalid preview.ttl '%s': must be at least 1m", p.TTL)
+		}
+	}
+	return nil
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// PreviewSlug turns a branch name into the label its previews are named
+// after, e.g. "feature/Checkout-v2" into "feature-checkout-v2".
+func PreviewSlug(branch string) (string, error) {
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(branch), "-"), "-")
+	if len(slug) > maxPreviewSlugLength {
+		slug = strings.TrimRight(slug[:maxPreviewSlugLength], "-")
+	}
+	if slug == "" {
+		return "", fmt.Errorf("branch '%s' has no letters or digits to name a preview after", branch)
+	}
+	return slug, nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreviewSlug(t *testing.T) {
+	tests := []struct {
+		branch  string
+		want    string
+		wantErr bool
+	}{
+		{branch: "feature-x", want: "feature-x"},
+		{branch: "feature/Checkout_v2", want: "feature-checkout-v2"},
+		{branch: "--fix--", want: "fix"},
+		{branch: strings.Repeat("a", 39) + "/b", want: strings.Repeat("a", 39)},
+		{branch: "///", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			got, err := PreviewSlug(tt.branch)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("PreviewSlug(%q) expected error, got %q", tt.branch, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PreviewSlug(%q) error = %v", tt.branch, err)
+			}
+			if got != tt.want {
+				t.Errorf("PreviewSlug(%q) = %q, want %q", tt.branch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  PreviewConfig
+		wantErr bool
+	}{
+		{name: "domain only", config: PreviewConfig{Domain: "preview.example.com"}},
+		{name: "with ttl", config: PreviewConfig{Domain: "preview.example.com", TTL: "48h"}},
+		{name: "missing domain", config: PreviewConfig{TTL: "48h"}, wantErr: true},
+		{name: "invalid ttl", config: PreviewConfig{Domain: "preview.example.com", TTL: "2 days"}, wantErr: true},
+		{name: "ttl too short", config: PreviewConfig{Domain: "preview.example.com", TTL: "10s"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := (&PreviewConfig{}).GetTTL(); got != DefaultPreviewTTL {
+		t.Errorf("GetTTL() = %v, want %v", got, DefaultPreviewTTL)
+	}
+	if got := (&PreviewConfig{TTL: "48h"}).GetTTL(); got != 48*time.Hour {
+		t.Errorf("GetTTL() = %v, want 48h", got)
+	}
+}
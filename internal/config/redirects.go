@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// DefaultRedirectStatus is the status of redirects that don't set one, the
+// same the proxy uses to send aliases to their canonical domain.
+const DefaultRedirectStatus = http.StatusMovedPermanently
+
+// Redirect sends requests for a path, or for a whole domain, elsewhere. The
+// proxy answers them itself, so they never reach the app.
+type Redirect struct {
+	// Host limits the redirect to one of the target's domains or aliases.
+	// Empty matches all of them.
+	Host string `json:"host,omitempty" yaml:"host,omitempty" toml:"host,omitempty"`
+	// From is the path to redirect, or every path below it when it ends in
+	// "/*". Empty matches every path and requires Host.
+	From string `json:"from,omitempty" yaml:"from,omitempty" toml:"from,omitempty"`
+	// To is a path on the same domain or an absolute http(s) URL. A trailing
+	// "*" is replaced with what the "*" in From matched, or with the whole
+	// path when From is empty.
+	To string `json:"to" yaml:"to" toml:"to"`
+	// Status is 301, 302, 307 or 308. Defaults to 301.
+	Status int `json:"status,omitempty" yaml:"status,omitempty" toml:"status,omitempty"`
+}
+
+var redirectStatuses = []int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect,
+}
+
+// GetStatus returns the redirect's status, DefaultRedirectStatus when unset.
+func (r *Redirect) GetStatus() int {
+	if r.Status == 0 {
+		return DefaultRedirectStatus
+	}
+	return r.Status
+}
+
+// Validate checks the redirect on its own; validateRedirects also checks its
+// host against the target's domains.
+func (r *Redirect) Validate() error {
+	if r.From == "" && r.Host == "" {
+		return fmt.Errorf("redirect to '%s' needs a 'from' path or a 'host'", r.To)
+	}
+	if r.From != "" {
+		if !strings.HasPrefix(r.From, "/") {
+			return fmt.Errorf("redirect from '%s' must start with '/'", r.From)
+		}
+		path := strings.TrimSuffix(r.From, "/*")
+		if strings.ContainsAny(path, "*?# \t") || strings.Contains(path, "//") {
+			return fmt.Errorf("redirect from '%s' must be a plain URL path, optionally ending in '/*'", r.From)
+		}
+	}
+
+	if r.To == "" {
+		return fmt.Errorf("redirect from '%s%s' needs a 'to'", r.Host, r.From)
+	}
+	if strings.Contains(strings.TrimSuffix(r.To, "*"), "*") {
+		return fmt.Errorf("redirect to '%s' can only have a '*' at the end", r.To)
+	}
+	if strings.HasSuffix(r.To, "*") && r.From != "" && !strings.HasSuffix(r.From, "/*") {
+		return fmt.Errorf("redirect to '%s' ends in '*', so its 'from' must end in '/*'", r.To)
+	}
+	if !strings.HasPrefix(r.To, "/") || strings.HasPrefix(r.To, "//") {
+		u, err := url.Parse(r.To)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("redirect to '%s' must be a path starting with '/' or an http(s) URL", r.To)
+		}
+	} else if r.To == r.From {
+		return fmt.Errorf("redirect from '%s' points to itself", r.From)
+	}
+
+	if r.Status != 0 && !slices.Contains(redirectStatuses, r.Status) {
+		return fmt.Errorf("redirect from '%s%s' has invalid status %d: must be 301, 302, 307 or 308", r.Host, r.From, r.Status)
+	}
+	return nil
+}
+
+// validateRedirects checks the redirects of a target, whose domains are the
+// only hosts the proxy answers for it.
+func validateRedirects(redirects []Redirect, domains []Domain, format string) error {
+	if len(redirects) == 0 {
+		return nil
+	}
+	name := GetFieldNameForFormat(TargetConfig{}, "Redirects", format)
+	if len(domains) == 0 {
+		return fmt.Errorf("%s requires the target to have domains", name)
+	}
+
+	var hosts []string
+	for _, d := range domains {
+		hosts = append(hosts, strings.ToLower(d.Canonical))
+		for _, alias := range d.Aliases {
+			hosts = append(hosts, strings.ToLower(alias))
+		}
+	}
+	for i, r := range redirects {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("%s[%d]: %w", name, i, err)
+		}
+		if r.Host != "" && !slices.Contains(hosts, strings.ToLower(r.Host)) {
+			return fmt.Errorf("%s[%d]: host '%s' is not one of the target's domains or aliases", name, i, r.Host)
+		}
+	}
+	return nil
+}
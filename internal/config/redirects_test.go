@@ -0,0 +1,52 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRedirects(t *testing.T) {
+	domains := []Domain{{Canonical: "www.example.com", Aliases: []string{"Example.com"}}}
+	tests := []struct {
+		name      string
+		redirects []Redirect
+		domains   []Domain
+		wantErr   string
+	}{
+		{name: "path", redirects: []Redirect{{From: "/old-path", To: "/new-path", Status: 308}}},
+		{name: "wildcard to URL", redirects: []Redirect{{From: "/blog/*", To: "https://blog.example.com/*"}}},
+		{name: "host", redirects: []Redirect{{Host: "example.com", To: "https://shop.example.com/*", Status: 302}}},
+		{name: "no domains", redirects: []Redirect{{From: "/a", To: "/b"}}, domains: []Domain{}, wantErr: "requires the target to have domains"},
+		{name: "no from or host", redirects: []Redirect{{To: "/b"}}, wantErr: "needs a 'from' path or a 'host'"},
+		{name: "relative from", redirects: []Redirect{{From: "old", To: "/b"}}, wantErr: "must start with '/'"},
+		{name: "wildcard inside from", redirects: []Redirect{{From: "/a/*/b", To: "/b"}}, wantErr: "plain URL path"},
+		{name: "query in from", redirects: []Redirect{{From: "/a?x=1", To: "/b"}}, wantErr: "plain URL path"},
+		{name: "missing to", redirects: []Redirect{{From: "/a"}}, wantErr: "needs a 'to'"},
+		{name: "wildcard to from exact path", redirects: []Redirect{{From: "/a", To: "/b/*"}}, wantErr: "must end in '/*'"},
+		{name: "wildcard inside to", redirects: []Redirect{{From: "/a/*", To: "/b/*/c"}}, wantErr: "only have a '*' at the end"},
+		{name: "relative to", redirects: []Redirect{{From: "/a", To: "b"}}, wantErr: "path starting with '/' or an http(s) URL"},
+		{name: "scheme relative to", redirects: []Redirect{{From: "/a", To: "//evil.example"}}, wantErr: "path starting with '/' or an http(s) URL"},
+		{name: "ftp to", redirects: []Redirect{{From: "/a", To: "ftp://example.com/"}}, wantErr: "path starting with '/' or an http(s) URL"},
+		{name: "loop", redirects: []Redirect{{From: "/a", To: "/a"}}, wantErr: "points to itself"},
+		{name: "invalid status", redirects: []Redirect{{From: "/a", To: "/b", Status: 200}}, wantErr: "invalid status 200"},
+		{name: "unknown host", redirects: []Redirect{{Host: "other.com", To: "https://www.example.com"}}, wantErr: "not one of the target's domains"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := domains
+			if tt.domains != nil {
+				d = tt.domains
+			}
+			err := validateRedirects(tt.redirects, d, "yaml")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateRedirects() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateRedirects() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package config
+
+import "fmt"
+
+// SchemaVersion is the config file layout this version of haloy writes and
+// expects. Older layouts still load, with deprecation warnings, until the
+// fields they use are removed.
+const SchemaVersion = 2
+
+// ValidateSchema checks a config file's schema version, where 0 means the
+// file doesn't set one.
+func ValidateSchema(schema int) error {
+	if schema < 0 {
+		return fmt.Errorf("invalid schema %d, must be a positive number", schema)
+	}
+	if schema > SchemaVersion {
+		return fmt.Errorf("config uses schema %d, but this version of haloy only supports up to schema %d; upgrade haloy to use it", schema, SchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// SecurityOptions harden the target's app containers.
+type SecurityOptions struct {
+	// ReadOnlyRootfs mounts the container's root filesystem read-only. Apps
+	// can still write to volumes and to the tmpfs mounts.
+	ReadOnlyRootfs bool `json:"readOnlyRootfs,omitempty" yaml:"read_only_rootfs,omitempty" toml:"read_only_rootfs,omitempty"`
+	// NoNewPrivileges stops processes from gaining privileges, e.g. through
+	// setuid binaries.
+	NoNewPrivileges bool `json:"noNewPrivileges,omitempty" yaml:"no_new_privileges,omitempty" toml:"no_new_privileges,omitempty"`
+	// CapDrop and CapAdd are Linux capabilities such as NET_BIND_SERVICE,
+	// with or without the CAP_ prefix. ALL drops every capability.
+	CapDrop []string `json:"capDrop,omitempty" yaml:"cap_drop,omitempty" toml:"cap_drop,omitempty"`
+	CapAdd  []string `json:"capAdd,omitempty" yaml:"cap_add,omitempty" toml:"cap_add,omitempty"`
+	// Tmpfs mounts in-memory filesystems, as "/path" or "/path:options",
+	// e.g. "/tmp:size=64m".
+	Tmpfs []string `json:"tmpfs,omitempty" yaml:"tmpfs,omitempty" toml:"tmpfs,omitempty"`
+}
+
+var capabilityPattern = regexp.MustCompile(`^(CAP_)?[A-Z][A-Z_]*$`)
+
+func (s *SecurityOptions) Validate(format string) error {
+	name := GetFieldNameForFormat(TargetConfig{}, "Security", format)
+	capLists := []struct {
+		field string
+		caps  []string
+	}{
+		{"CapDrop", s.CapDrop},
+		{"CapAdd", s.CapAdd},
+	}
+	for _, list := range capLists {
+		for _, capability := range list.caps {
+			if !capabilityPattern.MatchString(capability) {
+				return fmt.Errorf("invalid %s.%s '%s': must be a capability name like NET_BIND_SERVICE, or ALL", name, GetFieldNameForFormat(SecurityOptions{}, list.field, format), capability)
+			}
+		}
+	}
+
+	tmpfs := make(map[string]bool, len(s.Tmpfs))
+	for _, mount := range s.Tmpfs {
+		target, _, _ := strings.Cut(mount, ":")
+		if !path.IsAbs(target) || path.Clean(target) == "/" {
+			return fmt.Errorf("invalid %s.tmpfs '%s': must be an absolute path other than /", name, mount)
+		}
+		if tmpfs[path.Clean(target)] {
+			return fmt.Errorf("duplicate %s.tmpfs path '%s'", name, target)
+		}
+		tmpfs[path.Clean(target)] = true
+	}
+	return nil
+}
+
+// Capabilities returns the capabilities to drop and add without the CAP_
+// prefix, as Docker reports them.
+func (s *SecurityOptions) Capabilities() (drop, add []string) {
+	trim := func(caps []string) []string {
+		var trimmed []string
+		for _, capability := range caps {
+			trimmed = append(trimmed, strings.TrimPrefix(capability, "CAP_"))
+		}
+		return trimmed
+	}
+	return trim(s.CapDrop), trim(s.CapAdd)
+}
+
+// TmpfsMounts returns the tmpfs mounts as paths mapped to their mount
+// options.
+func (s *SecurityOptions) TmpfsMounts() map[string]string {
+	if len(s.Tmpfs) == 0 {
+		return nil
+	}
+	mounts := make(map[string]string, len(s.Tmpfs))
+	for _, mount := range s.Tmpfs {
+		target, options, _ := strings.Cut(mount, ":")
+		mounts[path.Clean(target)] = options
+	}
+	return mounts
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSecurityOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		security SecurityOptions
+		wantErr  string
+	}{
+		{name: "valid", security: SecurityOptions{
+			ReadOnlyRootfs:  true,
+			NoNewPrivileges: true,
+			CapDrop:         []string{"ALL"},
+			CapAdd:          []string{"NET_BIND_SERVICE", "CAP_CHOWN"},
+			Tmpfs:           []string{"/tmp", "/run:size=16m"},
+		}},
+		{name: "lowercase capability", security: SecurityOptions{CapAdd: []string{"net_bind_service"}}, wantErr: "invalid security.cap_add"},
+		{name: "relative tmpfs", security: SecurityOptions{Tmpfs: []string{"tmp"}}, wantErr: "invalid security.tmpfs"},
+		{name: "tmpfs on root", security: SecurityOptions{Tmpfs: []string{"/"}}, wantErr: "invalid security.tmpfs"},
+		{name: "duplicate tmpfs", security: SecurityOptions{Tmpfs: []string{"/tmp", "/tmp/:size=1m"}}, wantErr: "duplicate security.tmpfs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.security.Validate("yaml")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSecurityOptions_DockerValues(t *testing.T) {
+	security := SecurityOptions{
+		CapDrop: []string{"ALL"},
+		CapAdd:  []string{"CAP_NET_BIND_SERVICE"},
+		Tmpfs:   []string{"/tmp", "/run/:size=16m"},
+	}
+
+	drop, add := security.Capabilities()
+	if !slices.Equal(drop, []string{"ALL"}) || !slices.Equal(add, []string{"NET_BIND_SERVICE"}) {
+		t.Errorf("Capabilities() = %v, %v, want [ALL], [NET_BIND_SERVICE]", drop, add)
+	}
+	if got, want := security.TmpfsMounts(), map[string]string{"/tmp": "", "/run": "size=16m"}; !maps.Equal(got, want) {
+		t.Errorf("TmpfsMounts() = %v, want %v", got, want)
+	}
+}
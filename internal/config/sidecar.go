@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+type SidecarNetwork string
+
+const (
+	// SidecarNetworkShared joins the network namespace of the app container,
+	// so the two reach each other on localhost. This is the default.
+	SidecarNetworkShared SidecarNetwork = "shared"
+	// SidecarNetworkApp runs the sidecar as its own container on the app's
+	// network, reachable under the alias <app>-<sidecar>.
+	SidecarNetworkApp SidecarNetwork = "app"
+)
+
+// Sidecar is a companion container, such as a log shipper or a database
+// proxy, that is started and stopped together with each app replica.
+type Sidecar struct {
+	Name    string         `json:"name" yaml:"name" toml:"name"`
+	Image   *Image         `json:"image" yaml:"image" toml:"image"`
+	Command []string       `json:"command,omitempty" yaml:"command,omitempty" toml:"command,omitempty"`
+	Env     []EnvVar       `json:"env,omitempty" yaml:"env,omitempty" toml:"env,omitempty"`
+	Volumes []string       `json:"volumes,omitempty" yaml:"volumes,omitempty" toml:"volumes,omitempty"`
+	Network SidecarNetwork `json:"network,omitempty" yaml:"network,omitempty" toml:"network,omitempty"`
+	// HealthGate makes the app replica count as unhealthy until the sidecar
+	// is running, and healthy if its image defines a Docker healthcheck.
+	HealthGate *bool `json:"healthGate,omitempty" yaml:"health_gate,omitempty" toml:"health_gate,omitempty"`
+}
+
+func (s *Sidecar) EffectiveNetwork() SidecarNetwork {
+	if s.Network == "" {
+		return SidecarNetworkShared
+	}
+	return s.Network
+}
+
+func (s *Sidecar) IsHealthGated() bool {
+	return s.HealthGate != nil && *s.HealthGate
+}
+
+func (s *Sidecar) Validate(format string) error {
+	if s.Name == "" {
+		return errors.New("sidecar 'name' is required")
+	}
+	if !isValidAppName(s.Name) {
+		return fmt.Errorf("invalid sidecar name '%s'; must contain only alphanumeric characters, hyphens, and underscores", s.Name)
+	}
+
+	if s.Image == nil {
+		return fmt.Errorf("sidecar '%s': image is required", s.Name)
+	}
+	if err := s.Image.Validate(format); err != nil {
+		return fmt.Errorf("sidecar '%s': invalid image: %w", s.Name, err)
+	}
+	if s.Image.ShouldBuild() {
+		return fmt.Errorf("sidecar '%s': images cannot be built; use a prebuilt image from a registry", s.Name)
+	}
+
+	if err := validateEnum("network", s.Network, SidecarNetworkShared, SidecarNetworkApp); err != nil {
+		return fmt.Errorf("sidecar '%s': %w", s.Name, err)
+	}
+
+	for j, envVar := range s.Env {
+		if err := envVar.Validate(format); err != nil {
+			return fmt.Errorf("sidecar '%s': env[%d]: %w", s.Name, j, err)
+		}
+	}
+
+	for _, volume := range s.Volumes {
+		if _, err := ParseVolumeSpec(volume); err != nil {
+			return fmt.Errorf("sidecar '%s': %w", s.Name, err)
+		}
+	}
+
+	return nil
+}
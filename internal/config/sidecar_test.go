@@ -0,0 +1,85 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSidecar_Validate(t *testing.T) {
+	validImage := &Image{Repository: "gcr.io/cloud-sql-connectors/cloud-sql-proxy", Tag: "2.14.0"}
+
+	tests := []struct {
+		name    string
+		sidecar Sidecar
+		wantErr string
+	}{
+		{
+			name:    "valid shared sidecar",
+			sidecar: Sidecar{Name: "sql-proxy", Image: validImage},
+		},
+		{
+			name:    "valid app network sidecar",
+			sidecar: Sidecar{Name: "vector", Image: validImage, Network: SidecarNetworkApp, Volumes: []string{"logs:/var/log/app"}},
+		},
+		{
+			name:    "missing name",
+			sidecar: Sidecar{Image: validImage},
+			wantErr: "name' is required",
+		},
+		{
+			name:    "invalid name",
+			sidecar: Sidecar{Name: "sql proxy", Image: validImage},
+			wantErr: "invalid sidecar name",
+		},
+		{
+			name:    "missing image",
+			sidecar: Sidecar{Name: "sql-proxy"},
+			wantErr: "image is required",
+		},
+		{
+			name:    "built image",
+			sidecar: Sidecar{Name: "sql-proxy", Image: &Image{Repository: "proxy", Build: new(true)}},
+			wantErr: "cannot be built",
+		},
+		{
+			name:    "invalid network",
+			sidecar: Sidecar{Name: "sql-proxy", Image: validImage, Network: "host"},
+			wantErr: "invalid network 'host', must be one of: shared, app",
+		},
+		{
+			name:    "invalid volume",
+			sidecar: Sidecar{Name: "sql-proxy", Image: validImage, Volumes: []string{"data"}},
+			wantErr: "invalid volume mapping",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sidecar.Validate("yaml")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTargetConfig_Validate_DuplicateSidecars(t *testing.T) {
+	sidecar := Sidecar{Name: "proxy", Image: &Image{Repository: "proxy", Tag: "1"}}
+	tc := TargetConfig{
+		Name:     "app",
+		Server:   "example.com",
+		Image:    &Image{Repository: "app"},
+		Sidecars: []Sidecar{sidecar, sidecar},
+	}
+
+	err := tc.Validate("yaml")
+	if err == nil || !strings.Contains(err.Error(), "duplicate sidecar name") {
+		t.Fatalf("Validate() error = %v, want duplicate sidecar error", err)
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/cron"
+)
+
+const DefaultSnapshotRetain = 7
+
+// SnapshotsConfig has haloyd snapshot the target's named volumes on a
+// schedule, keeping them on the server and optionally in an S3 bucket.
+type SnapshotsConfig struct {
+	// Schedule is a cron expression in the server's time zone, e.g.
+	// "0 3 * * *".
+	Schedule string `json:"schedule" yaml:"schedule" toml:"schedule"`
+	Retain   int    `json:"retain,omitempty" yaml:"retain,omitempty" toml:"retain,omitempty"` // Snapshots kept (default 7)
+	// Remote is an s3://bucket/path URL snapshots are uploaded to. The
+	// endpoint and credentials come from volume_snapshots in haloyd.yaml.
+	Remote string `json:"remote,omitempty" yaml:"remote,omitempty" toml:"remote,omitempty"`
+}
+
+// GetRetain returns how many snapshots are kept, defaulting to 7.
+func (s *SnapshotsConfig) GetRetain() int {
+	if s.Retain <= 0 {
+		return DefaultSnapshotRetain
+	}
+	return s.Retain
+}
+
+func (s *SnapshotsConfig) Validate() error {
+	if s.Schedule == "" {
+		return fmt.Errorf("snapshots.schedule is required")
+	}
+	if _, err := cron.Parse(s.Schedule); err != nil {
+		return fmt.Errorf("invalid snapshots.schedule: %w", err)
+	}
+	if s.Retain < 0 {
+		return fmt.Errorf("invalid snapshots.retain %d: must be at least 1", s.Retain)
+	}
+	if s.Remote != "" {
+		if _, _, err := ParseS3URL(s.Remote); err != nil {
+			return fmt.Errorf("invalid snapshots.remote: %w", err)
+		}
+	}
+	return nil
+}
+
+// ParseS3URL splits an s3://bucket/path URL into the bucket and the key
+// prefix, which has no leading or trailing slash.
+func ParseS3URL(rawURL string) (bucket, prefix string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "s3" || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", "", fmt.Errorf("'%s' must be an s3://bucket/path URL", rawURL)
+	}
+	return u.Host, strings.Trim(u.Path, "/"), nil
+}
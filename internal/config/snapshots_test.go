@@ -0,0 +1,38 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSnapshotsConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshots SnapshotsConfig
+		wantErr   string
+	}{
+		{name: "valid", snapshots: SnapshotsConfig{Schedule: "0 3 * * *", Retain: 7, Remote: "s3://backups/haloy/"}},
+		{name: "missing schedule", snapshots: SnapshotsConfig{}, wantErr: "snapshots.schedule is required"},
+		{name: "invalid schedule", snapshots: SnapshotsConfig{Schedule: "daily"}, wantErr: "invalid snapshots.schedule"},
+		{name: "invalid remote", snapshots: SnapshotsConfig{Schedule: "@daily", Remote: "https://backups"}, wantErr: "invalid snapshots.remote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.snapshots.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	bucket, prefix, err := ParseS3URL("s3://backups/haloy/")
+	if err != nil || bucket != "backups" || prefix != "haloy" {
+		t.Errorf("ParseS3URL() = %q, %q, %v, want backups, haloy", bucket, prefix, err)
+	}
+}
@@ -4,12 +4,33 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
 )
 
+// ServerSecretPrefix starts a from.secret reference to a secret stored on
+// the haloy server with 'haloy secret set'. haloyd resolves these when it
+// deploys, so they only work in env.
+const ServerSecretPrefix = "server://"
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidateSecretName checks the name of a server secret.
+func ValidateSecretName(name string) error {
+	if !secretNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid secret name '%s'; must start with a letter or digit and contain only letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
 // SourceReference defines a reference to a value from an external source.
 // Only one of its fields should be set.
 type SourceReference struct {
-	Env    string `json:"env,omitempty" yaml:"env,omitempty" toml:"env,omitempty"`
+	Env string `json:"env,omitempty" yaml:"env,omitempty" toml:"env,omitempty"`
+	// Secret is "provider:source_name:key" for a source in the secret
+	// providers block, a direct "op://vault/item/field" 1Password or
+	// "bw://item/field" Bitwarden reference, or "server://name" for a
+	// secret stored on the server.
 	Secret string `json:"secret,omitempty" yaml:"secret,omitempty" toml:"secret,omitempty"`
 }
 
@@ -26,6 +47,31 @@ func (sr *SourceReference) Validate() error {
 		return errors.New("only one source reference ('env' or 'secret') can be specified at a time")
 	}
 
+	if name, ok := sr.ServerSecret(); ok {
+		return ValidateSecretName(name)
+	}
+
+	return nil
+}
+
+// ServerSecret returns the name of the server secret the reference points
+// to, if it points to one.
+func (sr *SourceReference) ServerSecret() (string, bool) {
+	if sr == nil {
+		return "", false
+	}
+	return strings.CutPrefix(sr.Secret, ServerSecretPrefix)
+}
+
+// errServerSecret is returned for server secrets used where the value is
+// needed before haloyd gets the config.
+var errServerSecret = errors.New("server secrets can only be used in env")
+
+// validateNotServerSecret rejects a server secret reference in vs.
+func (vs *ValueSource) validateNotServerSecret() error {
+	if _, ok := vs.From.ServerSecret(); ok {
+		return errServerSecret
+	}
 	return nil
 }
 
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// SSO makes the proxy sign users in with an identity provider before their
+// requests reach the target, and pass who they are to the app in the
+// X-Auth-Request-User and X-Auth-Request-Email headers.
+type SSO struct {
+	// Provider is "oidc" for any OpenID Connect provider, "google" or
+	// "github".
+	Provider string `json:"provider" yaml:"provider" toml:"provider"`
+	// Issuer is the OpenID Connect issuer URL; only used by "oidc".
+	Issuer       string      `json:"issuer,omitempty" yaml:"issuer,omitempty" toml:"issuer,omitempty"`
+	ClientID     string      `json:"clientId" yaml:"client_id" toml:"client_id"`
+	ClientSecret ValueSource `json:"clientSecret" yaml:"client_secret" toml:"client_secret"`
+	// AllowedEmails and AllowedDomains limit who may sign in, by verified
+	// email address or its domain. Without either, every user of the
+	// provider may; google and github need one of them.
+	AllowedEmails  []string `json:"allowedEmails,omitempty" yaml:"allowed_emails,omitempty" toml:"allowed_emails,omitempty"`
+	AllowedDomains []string `json:"allowedDomains,omitempty" yaml:"allowed_domains,omitempty" toml:"allowed_domains,omitempty"`
+}
+
+func (s *SSO) Validate(domains []Domain, format string) error {
+	field := GetFieldNameForFormat(TargetConfig{}, "SSO", format)
+	name := func(f string) string {
+		return field + "." + GetFieldNameForFormat(SSO{}, f, format)
+	}
+
+	var errs []error
+	if len(domains) == 0 {
+		errs = append(errs, fmt.Errorf("%s requires the target to have domains", field))
+	}
+	switch s.Provider {
+	case constants.SSOProviderOIDC:
+		if u, err := url.Parse(s.Issuer); s.Issuer == "" || err != nil || u.Scheme != "https" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s must be an https URL for provider '%s'", name("Issuer"), s.Provider))
+		}
+	case constants.SSOProviderGoogle, constants.SSOProviderGitHub:
+		if s.Issuer != "" {
+			errs = append(errs, fmt.Errorf("%s is only used with provider '%s'", name("Issuer"), constants.SSOProviderOIDC))
+		}
+		if len(s.AllowedEmails) == 0 && len(s.AllowedDomains) == 0 {
+			errs = append(errs, fmt.Errorf("%s '%s' needs '%s' or '%s', or anyone with an account could sign in",
+				name("Provider"), s.Provider,
+				GetFieldNameForFormat(SSO{}, "AllowedEmails", format),
+				GetFieldNameForFormat(SSO{}, "AllowedDomains", format)))
+		}
+	case "":
+		errs = append(errs, fmt.Errorf("%s is required", name("Provider")))
+	default:
+		errs = append(errs, validateEnum(name("Provider"), s.Provider,
+			constants.SSOProviderOIDC, constants.SSOProviderGoogle, constants.SSOProviderGitHub))
+	}
+
+	if s.ClientID == "" {
+		errs = append(errs, fmt.Errorf("%s is required", name("ClientID")))
+	}
+	if err := s.ClientSecret.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", name("ClientSecret"), err))
+	} else if err := s.ClientSecret.validateNotServerSecret(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", name("ClientSecret"), err))
+	}
+
+	for _, email := range s.AllowedEmails {
+		if local, domain, ok := strings.Cut(email, "@"); !ok || local == "" || domain == "" {
+			errs = append(errs, fmt.Errorf("invalid email '%s' in %s", email, name("AllowedEmails")))
+		}
+	}
+	for _, domain := range s.AllowedDomains {
+		if domain == "" || strings.ContainsAny(domain, "@/ ") {
+			errs = append(errs, fmt.Errorf("invalid domain '%s' in %s", domain, name("AllowedDomains")))
+		}
+	}
+	return errors.Join(errs...)
+}
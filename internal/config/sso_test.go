@@ -0,0 +1,51 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSSO_Validate(t *testing.T) {
+	domains := []Domain{{Canonical: "dashboard.example.com"}}
+	secret := ValueSource{From: &SourceReference{Env: "SSO_CLIENT_SECRET"}}
+	tests := []struct {
+		name    string
+		sso     SSO
+		domains []Domain
+		wantErr string
+	}{
+		{name: "oidc", sso: SSO{Provider: "oidc", Issuer: "https://auth.example.com/realms/staff", ClientID: "dashboard", ClientSecret: secret}},
+		{name: "google", sso: SSO{Provider: "google", ClientID: "id", ClientSecret: secret, AllowedDomains: []string{"example.com"}}},
+		{name: "github", sso: SSO{Provider: "github", ClientID: "id", ClientSecret: secret, AllowedEmails: []string{"alice@example.com"}}},
+		{name: "no domains", sso: SSO{Provider: "oidc", Issuer: "https://auth.example.com", ClientID: "id", ClientSecret: secret}, domains: []Domain{}, wantErr: "requires the target to have domains"},
+		{name: "no provider", sso: SSO{ClientID: "id", ClientSecret: secret}, wantErr: "sso.provider is required"},
+		{name: "unknown provider", sso: SSO{Provider: "gitlab", ClientID: "id", ClientSecret: secret}, wantErr: "invalid sso.provider 'gitlab'"},
+		{name: "oidc without issuer", sso: SSO{Provider: "oidc", ClientID: "id", ClientSecret: secret}, wantErr: "sso.issuer must be an https URL"},
+		{name: "http issuer", sso: SSO{Provider: "oidc", Issuer: "http://auth.example.com", ClientID: "id", ClientSecret: secret}, wantErr: "sso.issuer must be an https URL"},
+		{name: "issuer for google", sso: SSO{Provider: "google", Issuer: "https://accounts.google.com", ClientID: "id", ClientSecret: secret, AllowedDomains: []string{"example.com"}}, wantErr: "only used with provider 'oidc'"},
+		{name: "google open to everyone", sso: SSO{Provider: "google", ClientID: "id", ClientSecret: secret}, wantErr: "needs 'allowed_emails' or 'allowed_domains'"},
+		{name: "no client id", sso: SSO{Provider: "oidc", Issuer: "https://auth.example.com", ClientSecret: secret}, wantErr: "sso.client_id is required"},
+		{name: "no client secret", sso: SSO{Provider: "oidc", Issuer: "https://auth.example.com", ClientID: "id"}, wantErr: "sso.client_secret"},
+		{name: "server secret", sso: SSO{Provider: "oidc", Issuer: "https://auth.example.com", ClientID: "id", ClientSecret: ValueSource{From: &SourceReference{Secret: "server://sso"}}}, wantErr: "server secrets can only be used in env"},
+		{name: "invalid email", sso: SSO{Provider: "github", ClientID: "id", ClientSecret: secret, AllowedEmails: []string{"alice"}}, wantErr: "invalid email 'alice'"},
+		{name: "invalid domain", sso: SSO{Provider: "github", ClientID: "id", ClientSecret: secret, AllowedDomains: []string{"@example.com"}}, wantErr: "invalid domain '@example.com'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := domains
+			if tt.domains != nil {
+				d = tt.domains
+			}
+			err := tt.sso.Validate(d, "yaml")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// closestMatch returns the candidate closest to value when it is close
+// enough to be a likely typo of it, or "".
+func closestMatch(value string, candidates []string) string {
+	best, bestDistance := "", max(1, len(value)/3)+1
+	for _, candidate := range candidates {
+		if d := levenshtein(strings.ToLower(value), strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the number of single character insertions, deletions
+// and substitutions that turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// validateEnum checks that value, when set, is one of allowed, listing them
+// and the likely intended one otherwise.
+func validateEnum[T ~string](field string, value T, allowed ...T) error {
+	if value == "" || slices.Contains(allowed, value) {
+		return nil
+	}
+	names := make([]string, len(allowed))
+	for i, v := range allowed {
+		names[i] = string(v)
+	}
+	msg := fmt.Sprintf("invalid %s '%s', must be one of: %s", field, value, strings.Join(names, ", "))
+	if suggestion := closestMatch(string(value), names); suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClosestMatch(t *testing.T) {
+	candidates := []string{"rolling", "replace"}
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "replce", want: "replace"},
+		{value: "Rolling", want: "rolling"},
+		{value: "blue-green", want: ""},
+		{value: "r", want: ""},
+	}
+	for _, tt := range tests {
+		if got := closestMatch(tt.value, candidates); got != tt.want {
+			t.Errorf("closestMatch(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestTargetConfigValidateReportsAllErrors(t *testing.T) {
+	target := TargetConfig{
+		Name:               "shop",
+		DeploymentStrategy: "replcae",
+		Preset:             "databse",
+		Volumes:            []string{"data"},
+	}
+	err := target.Validate("yaml")
+	if err == nil {
+		t.Fatal("Validate() accepted an invalid target")
+	}
+	for _, want := range []string{
+		"server is required",
+		"invalid preset 'databse', must be one of: database, service (did you mean 'database'?)",
+		"invalid deployment_strategy 'replcae', must be one of: rolling, replace (did you mean 'replace'?)",
+		"invalid volume mapping 'data'",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error =\n%v\nwant it to contain %q", err, want)
+		}
+	}
+}
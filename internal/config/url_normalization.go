@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// URLNormalization makes the proxy redirect requests to one form of their
+// URL, for sites whose search ranking suffers from duplicate URLs. Only GET
+// and HEAD requests are redirected.
+type URLNormalization struct {
+	// TrailingSlash is "add" to end paths with a slash, except those of files
+	// like /logo.png, or "remove" to strip it. Empty leaves paths as they are.
+	TrailingSlash string `json:"trailingSlash,omitempty" yaml:"trailing_slash,omitempty" toml:"trailing_slash,omitempty"`
+	// Lowercase lowercases paths; query strings are kept as they are.
+	Lowercase bool `json:"lowercase,omitempty" yaml:"lowercase,omitempty" toml:"lowercase,omitempty"`
+	// MergeSlashes collapses repeated slashes, so /a//b becomes /a/b.
+	MergeSlashes bool `json:"mergeSlashes,omitempty" yaml:"merge_slashes,omitempty" toml:"merge_slashes,omitempty"`
+}
+
+func (n *URLNormalization) Validate(format string) error {
+	switch n.TrailingSlash {
+	case "", constants.TrailingSlashAdd, constants.TrailingSlashRemove:
+		return nil
+	default:
+		return fmt.Errorf("invalid %s.%s '%s': must be '%s' or '%s'",
+			GetFieldNameForFormat(TargetConfig{}, "URLNormalization", format),
+			GetFieldNameForFormat(URLNormalization{}, "TrailingSlash", format),
+			n.TrailingSlash, constants.TrailingSlashAdd, constants.TrailingSlashRemove)
+	}
+}
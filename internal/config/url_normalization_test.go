@@ -0,0 +1,19 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestURLNormalization_Validate(t *testing.T) {
+	for _, valid := range []string{"", "add", "remove"} {
+		n := URLNormalization{TrailingSlash: valid, Lowercase: true}
+		if err := n.Validate("yaml"); err != nil {
+			t.Errorf("Validate() with trailing_slash %q error = %v", valid, err)
+		}
+	}
+	n := URLNormalization{TrailingSlash: "strip"}
+	if err := n.Validate("yaml"); err == nil || !strings.Contains(err.Error(), "invalid url_normalization.trailing_slash 'strip'") {
+		t.Errorf("Validate() error = %v, want invalid trailing_slash", err)
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// userOrGroupPattern matches a user or group name or numeric id.
+var userOrGroupPattern = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+
+// validateUser checks a container user given as user[:group], where both
+// parts are names or numeric ids.
+func validateUser(user, format string) error {
+	name := GetFieldNameForFormat(TargetConfig{}, "User", format)
+	userPart, groupPart, hasGroup := strings.Cut(user, ":")
+	if !userOrGroupPattern.MatchString(userPart) || (hasGroup && !userOrGroupPattern.MatchString(groupPart)) {
+		return fmt.Errorf("invalid %s '%s': must be user or user:group, as names or numeric ids like 1000:1000", name, user)
+	}
+	return nil
+}
+
+func validateGroupAdd(groups []string, format string) error {
+	for _, group := range groups {
+		if !userOrGroupPattern.MatchString(group) {
+			return fmt.Errorf("invalid %s '%s': must be a group name or numeric id", GetFieldNameForFormat(TargetConfig{}, "GroupAdd", format), group)
+		}
+	}
+	return nil
+}
+
+// NumericUser returns the uid and gid of a user given as uid[:gid]. gid is
+// -1 when no group is given. ok is false for names, which only the image's
+// /etc/passwd and /etc/group can resolve.
+func NumericUser(user string) (uid, gid int, ok bool) {
+	userPart, groupPart, hasGroup := strings.Cut(user, ":")
+	uid, err := strconv.Atoi(userPart)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !hasGroup {
+		return uid, -1, true
+	}
+	gid, err = strconv.Atoi(groupPart)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uid, gid, true
+}
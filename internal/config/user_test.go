@@ -0,0 +1,33 @@
+package config
+
+import "testing"
+
+func TestNumericUser(t *testing.T) {
+	tests := []struct {
+		user     string
+		uid, gid int
+		ok       bool
+	}{
+		{user: "1000:1000", uid: 1000, gid: 1000, ok: true},
+		{user: "1000", uid: 1000, gid: -1, ok: true},
+		{user: "node"},
+		{user: "1000:staff"},
+	}
+	for _, tt := range tests {
+		uid, gid, ok := NumericUser(tt.user)
+		if uid != tt.uid || gid != tt.gid || ok != tt.ok {
+			t.Errorf("NumericUser(%q) = %d, %d, %v, want %d, %d, %v", tt.user, uid, gid, ok, tt.uid, tt.gid, tt.ok)
+		}
+	}
+
+	for _, user := range []string{"1000:1000", "node", "www-data:www-data"} {
+		if err := validateUser(user, "yaml"); err != nil {
+			t.Errorf("validateUser(%q) error = %v", user, err)
+		}
+	}
+	for _, user := range []string{":1000", "1000:", "a b", "1000:1000:1000"} {
+		if err := validateUser(user, "yaml"); err == nil {
+			t.Errorf("validateUser(%q) accepted an invalid user", user)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package configloader
+
+import "errors"
+
+// ErrConfig matches, with errors.Is, every error this package returns for a
+// deploy config that can't be loaded, merged, validated or have its secrets
+// resolved. The error message is left as is.
+var ErrConfig = errors.New("invalid deploy config")
+
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string { return e.err.Error() }
+
+func (e *configError) Unwrap() error { return e.err }
+
+func (e *configError) Is(target error) bool { return target == ErrConfig }
+
+// markConfigError is deferred by the exported loaders to classify the error
+// they return.
+func markConfigError(err *error) {
+	if *err != nil && !errors.Is(*err, ErrConfig) {
+		*err = &configError{err: *err}
+	}
+}
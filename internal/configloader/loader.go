@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -25,6 +27,8 @@ func Load(
 	targets []string,
 	allTargets bool,
 ) (deployConfig config.DeployConfig, format string, err error) {
+	defer markConfigError(&err)
+
 	rawDeployConfig, format, err := LoadRawDeployConfig(configPath)
 	if err != nil {
 		return config.DeployConfig{}, "", err
@@ -35,7 +39,14 @@ func Load(
 	if len(rawDeployConfig.Targets) > 0 { // is multi target
 
 		if len(targets) == 0 && !allTargets {
-			return config.DeployConfig{}, "", errors.New("multiple targets available, please specify targets with --targets or use --all")
+			defaultTarget, err := profileDefaultTarget(rawDeployConfig)
+			if err != nil {
+				return config.DeployConfig{}, "", err
+			}
+			if defaultTarget == "" {
+				return config.DeployConfig{}, "", errors.New("multiple targets available, please specify targets with --targets or use --all")
+			}
+			targets = []string{defaultTarget}
 		}
 
 		if len(targets) > 0 {
@@ -155,7 +166,9 @@ func mergeEnvArrays(deployConfigEnv, targetConfigEnv []config.EnvVar) []config.E
 // 1. Target Config (explicitly set in the 'targets' map)
 // 2. Preset Defaults (applied if fields are empty)
 // 3. Global DeployConfig (applied if fields are still empty)
-func MergeToTarget(deployConfig config.DeployConfig, targetConfig config.TargetConfig, targetName, format string) (config.TargetConfig, error) {
+func MergeToTarget(deployConfig config.DeployConfig, targetConfig config.TargetConfig, targetName, format string) (_ config.TargetConfig, err error) {
+	defer markConfigError(&err)
+
 	var tc config.TargetConfig
 	if err := copier.Copy(&tc, &targetConfig); err != nil {
 		return config.TargetConfig{}, fmt.Errorf("failed to deep copy target config for merging: %w", err)
@@ -178,8 +191,13 @@ func MergeToTarget(deployConfig config.DeployConfig, targetConfig config.TargetC
 	}
 	tc.Image = mergedImage
 
-	if tc.Server == "" {
+	if tc.Server == "" && len(tc.Servers) == 0 {
 		tc.Server = deployConfig.Server
+		tc.Servers = slices.Clone(deployConfig.Servers)
+	}
+
+	if tc.Rollout == "" {
+		tc.Rollout = deployConfig.Rollout
 	}
 
 	if tc.APIToken == nil {
@@ -218,6 +236,16 @@ func MergeToTarget(deployConfig config.DeployConfig, targetConfig config.TargetC
 		tc.HealthCheckPath = deployConfig.HealthCheckPath
 	}
 
+	if tc.HealthCheck == nil {
+		tc.HealthCheck = deployConfig.HealthCheck
+	}
+	if tc.HealthProbe == nil {
+		tc.HealthProbe = deployConfig.HealthProbe
+	}
+	if tc.Startup == nil {
+		tc.Startup = deployConfig.Startup
+	}
+
 	if tc.Port == "" {
 		tc.Port = deployConfig.Port
 	}
@@ -226,6 +254,10 @@ func MergeToTarget(deployConfig config.DeployConfig, targetConfig config.TargetC
 		tc.Replicas = deployConfig.Replicas
 	}
 
+	if tc.Ports == nil {
+		tc.Ports = deployConfig.Ports
+	}
+
 	if tc.MinReadySeconds == nil {
 		tc.MinReadySeconds = deployConfig.MinReadySeconds
 	}
@@ -246,10 +278,90 @@ func MergeToTarget(deployConfig config.DeployConfig, targetConfig config.TargetC
 		tc.PostDeploy = deployConfig.PostDeploy
 	}
 
+	if tc.Sidecars == nil {
+		tc.Sidecars = deployConfig.Sidecars
+	}
+
+	if tc.User == "" {
+		tc.User = deployConfig.User
+	}
+
+	if tc.GroupAdd == nil {
+		tc.GroupAdd = deployConfig.GroupAdd
+	}
+
+	if tc.LoggingDriver == nil {
+		tc.LoggingDriver = deployConfig.LoggingDriver
+	}
+
+	if tc.Labels == nil {
+		tc.Labels = deployConfig.Labels
+	}
+
+	if tc.Security == nil {
+		tc.Security = deployConfig.Security
+	}
+
+	if tc.MigrationLock == "" {
+		tc.MigrationLock = deployConfig.MigrationLock
+	}
+
+	if tc.Database == nil {
+		tc.Database = deployConfig.Database
+	}
+
+	if tc.DatabaseURLFrom == "" {
+		tc.DatabaseURLFrom = deployConfig.DatabaseURLFrom
+	}
+
+	if tc.Snapshots == nil {
+		tc.Snapshots = deployConfig.Snapshots
+	}
+
+	if tc.Preview == nil {
+		tc.Preview = deployConfig.Preview
+	}
+
+	if tc.ClientMaxBodySize == "" {
+		tc.ClientMaxBodySize = deployConfig.ClientMaxBodySize
+	}
+
+	if tc.ProxyReadTimeout == "" {
+		tc.ProxyReadTimeout = deployConfig.ProxyReadTimeout
+	}
+
+	if tc.ProxySendTimeout == "" {
+		tc.ProxySendTimeout = deployConfig.ProxySendTimeout
+	}
+
+	if tc.Redirects == nil {
+		tc.Redirects = deployConfig.Redirects
+	}
+
+	if tc.URLNormalization == nil {
+		tc.URLNormalization = deployConfig.URLNormalization
+	}
+
+	if tc.ClientAuth == nil {
+		tc.ClientAuth = deployConfig.ClientAuth
+	}
+
+	if tc.SSO == nil {
+		tc.SSO = deployConfig.SSO
+	}
+
+	if tc.ErrorPages == "" {
+		tc.ErrorPages = deployConfig.ErrorPages
+	}
+
 	if err := applyPreset(&tc); err != nil {
 		return config.TargetConfig{}, err
 	}
 
+	if err := resolveServerProfile(&tc); err != nil {
+		return config.TargetConfig{}, err
+	}
+
 	normalizeTargetConfig(&tc)
 
 	if err := mergeBuildArgsFromEnv(&tc); err != nil {
@@ -304,9 +416,54 @@ func applyPreset(tc *config.TargetConfig) error {
 	return nil
 }
 
+// profileDefaultTarget returns the current profile's default target when the
+// deploy config has a target by that name.
+func profileDefaultTarget(deployConfig config.DeployConfig) (string, error) {
+	clientConfig, err := config.LoadDefaultClientConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to load client config: %w", err)
+	}
+	name, profile, ok := clientConfig.Current()
+	if !ok || profile.DefaultTarget == "" {
+		return "", nil
+	}
+	if _, exists := deployConfig.Targets[profile.DefaultTarget]; !exists {
+		return "", nil
+	}
+	ui.Info("Using target '%s', the default of profile %s", profile.DefaultTarget, name)
+	return profile.DefaultTarget, nil
+}
+
+// resolveServerProfile replaces a profile name in 'server' with the profile's
+// URL. Targets without a server use the current profile, if one is set.
+func resolveServerProfile(tc *config.TargetConfig) error {
+	clientConfig, err := config.LoadDefaultClientConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load client config: %w", err)
+	}
+	if clientConfig == nil {
+		return nil
+	}
+
+	if len(tc.Servers) > 0 {
+		for i, server := range tc.Servers {
+			tc.Servers[i] = clientConfig.ResolveServer(server)
+		}
+		return nil
+	}
+	if tc.Server == "" {
+		if _, profile, ok := clientConfig.Current(); ok {
+			tc.Server = profile.Server
+		}
+		return nil
+	}
+	tc.Server = clientConfig.ResolveServer(tc.Server)
+	return nil
+}
+
 // normalizeTargetConfig applies default values to a target config
 func normalizeTargetConfig(tc *config.TargetConfig) {
-	if tc.Server == "" {
+	if tc.Server == "" && len(tc.Servers) == 0 {
 		tc.Server = "localhost"
 	}
 
@@ -412,6 +569,50 @@ func mergeBuildArgsFromEnv(tc *config.TargetConfig) error {
 	return nil
 }
 
+// expandServers turns a target with 'servers' into one target per server,
+// named <target>@<host>, all deploying the same app. Other targets are
+// returned as they are.
+func expandServers(targetName string, tc config.TargetConfig) (map[string]config.TargetConfig, error) {
+	if len(tc.Servers) == 0 {
+		return map[string]config.TargetConfig{targetName: tc}, nil
+	}
+	if tc.Server != "" {
+		return nil, errors.New("'server' and 'servers' can't both be set")
+	}
+
+	expanded := make(map[string]config.TargetConfig, len(tc.Servers))
+	for i, server := range tc.Servers {
+		if server == "" {
+			return nil, errors.New("servers can't contain an empty server")
+		}
+		name := targetName + "@" + serverHost(server)
+		if _, exists := expanded[name]; exists {
+			return nil, fmt.Errorf("server '%s' is listed more than once", server)
+		}
+
+		var member config.TargetConfig
+		if err := copier.CopyWithOption(&member, &tc, copier.Option{DeepCopy: true}); err != nil {
+			return nil, fmt.Errorf("failed to copy target config for server '%s': %w", server, err)
+		}
+		member.Server = server
+		member.Servers = nil
+		member.TargetName = name
+		member.ServerGroup = targetName
+		member.ServerIndex = i
+		expanded[name] = member
+	}
+	return expanded, nil
+}
+
+// serverHost returns the host of a server URL, or the server as written when
+// it has no scheme.
+func serverHost(server string) string {
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return strings.TrimSuffix(server, "/")
+}
+
 func TargetsByServer(targets map[string]config.TargetConfig) map[string][]string {
 	servers := make(map[string][]string)
 	for targetName, target := range targets {
@@ -420,7 +621,9 @@ func TargetsByServer(targets map[string]config.TargetConfig) map[string][]string
 	return servers
 }
 
-func ExtractTargets(deployConfig config.DeployConfig, format string) (map[string]config.TargetConfig, error) {
+func ExtractTargets(deployConfig config.DeployConfig, format string) (_ map[string]config.TargetConfig, err error) {
+	defer markConfigError(&err)
+
 	if err := deployConfig.Validate(); err != nil {
 		return nil, err
 	}
@@ -428,32 +631,55 @@ func ExtractTargets(deployConfig config.DeployConfig, format string) (map[string
 	extractedTargetConfigs := make(map[string]config.TargetConfig)
 
 	if len(deployConfig.Targets) > 0 {
-		for targetName, target := range deployConfig.Targets {
-			mergedTargetConfig, err := MergeToTarget(deployConfig, *target, targetName, format)
+		// All targets are validated, so their problems are reported together.
+		var errs []error
+		for _, targetName := range slices.Sorted(maps.Keys(deployConfig.Targets)) {
+			mergedTargetConfig, err := MergeToTarget(deployConfig, *deployConfig.Targets[targetName], targetName, format)
 			if err != nil {
-				return nil, fmt.Errorf("failed to resolve target '%s': %w", targetName, err)
+				errs = append(errs, fmt.Errorf("failed to resolve target '%s': %w", targetName, err))
+				continue
 			}
 
-			if err := mergedTargetConfig.Validate(deployConfig.Format); err != nil {
-				return nil, fmt.Errorf("validation failed for target '%s': %w", targetName, err)
+			expanded, err := expandServers(targetName, mergedTargetConfig)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("validation failed for target '%s': %w", targetName, err))
+				continue
+			}
+			for _, name := range slices.Sorted(maps.Keys(expanded)) {
+				tc := expanded[name]
+				if err := tc.Validate(deployConfig.Format); err != nil {
+					errs = append(errs, fmt.Errorf("validation failed for target '%s': %w", name, err))
+					continue
+				}
+				extractedTargetConfigs[name] = tc
 			}
-			extractedTargetConfigs[targetName] = mergedTargetConfig
+		}
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
 		}
 	} else {
 		mergedSingleTargetConfig, err := MergeToTarget(deployConfig, deployConfig.TargetConfig, deployConfig.Name, format)
 		if err != nil {
 			return nil, fmt.Errorf("failed to merge config: %w", err)
 		}
-		if err := mergedSingleTargetConfig.Validate(deployConfig.Format); err != nil {
+		expanded, err := expandServers(deployConfig.Name, mergedSingleTargetConfig)
+		if err != nil {
 			return nil, fmt.Errorf("config invalid: %w", err)
 		}
-		extractedTargetConfigs[deployConfig.Name] = mergedSingleTargetConfig
+		for name, tc := range expanded {
+			if err := tc.Validate(deployConfig.Format); err != nil {
+				return nil, fmt.Errorf("config invalid: %w", err)
+			}
+			extractedTargetConfigs[name] = tc
+		}
 	}
 
 	return extractedTargetConfigs, nil
 }
 
-func LoadRawDeployConfig(configPath string) (config.DeployConfig, string, error) {
+func LoadRawDeployConfig(configPath string) (_ config.DeployConfig, _ string, err error) {
+	defer markConfigError(&err)
+
 	configFile, err := FindConfigFile(configPath)
 	if err != nil {
 		return config.DeployConfig{}, "", err
@@ -474,6 +700,19 @@ func LoadRawDeployConfig(configPath string) (config.DeployConfig, string, error)
 		return config.DeployConfig{}, "", EnhanceConfigError(configFile, format, err)
 	}
 
+	schema := k.Int("schema")
+	if err := config.ValidateSchema(schema); err != nil {
+		return config.DeployConfig{}, "", err
+	}
+	deprecations, err := findDeprecations(k, schema, format)
+	if err != nil {
+		return config.DeployConfig{}, "", err
+	}
+	if err := migrateKeys(k, deprecations); err != nil {
+		return config.DeployConfig{}, "", err
+	}
+	warnDeprecations(configFile, deprecations)
+
 	configKeys := k.Keys()
 	deployConfigType := reflect.TypeFor[config.DeployConfig]()
 
@@ -490,6 +729,7 @@ func LoadRawDeployConfig(configPath string) (config.DeployConfig, string, error)
 		DecodeHook: mapstructure.ComposeDecodeHookFunc(
 			config.PortDecodeHook(),
 			config.ImageDecodeHook(),
+			config.HealthCheckCommandDecodeHook(),
 		),
 	}
 
@@ -502,9 +742,50 @@ func LoadRawDeployConfig(configPath string) (config.DeployConfig, string, error)
 		return config.DeployConfig{}, "", fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	if err := readClientAuthCAs(&deployConfig, filepath.Dir(configFile), format); err != nil {
+		return config.DeployConfig{}, "", err
+	}
+
 	return deployConfig, format, nil
 }
 
+// readClientAuthCAs replaces the client_auth ca_file of the config and its
+// targets with the file's contents, so the CA reaches the server with the
+// rest of the target. Relative paths are resolved against dir.
+func readClientAuthCAs(deployConfig *config.DeployConfig, dir, format string) error {
+	read := func(ca *config.ClientAuth) error {
+		if ca == nil || ca.CAFile == "" || ca.CAInline != "" {
+			return nil
+		}
+		path := ca.CAFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w",
+				config.GetFieldNameForFormat(config.TargetConfig{}, "ClientAuth", format),
+				config.GetFieldNameForFormat(config.ClientAuth{}, "CAFile", format), err)
+		}
+		ca.CAFile = ""
+		ca.CAInline = string(data)
+		return nil
+	}
+
+	if err := read(deployConfig.ClientAuth); err != nil {
+		return err
+	}
+	for _, target := range deployConfig.Targets {
+		if target == nil {
+			continue
+		}
+		if err := read(target.ClientAuth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var (
 	supportedExtensions  = []string{".json", ".yaml", ".yml", ".toml"}
 	supportedConfigNames = []string{"haloy.json", "haloy.yaml", "haloy.yml", "haloy.toml"}
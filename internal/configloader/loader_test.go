@@ -1,11 +1,15 @@
 package configloader
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/helpers"
 )
 
@@ -823,6 +827,93 @@ func TestExtractTargets(t *testing.T) {
 	}
 }
 
+func TestExtractTargetsExpandsServers(t *testing.T) {
+	deployConfig := config.DeployConfig{
+		TargetConfig: config.TargetConfig{
+			Name:  "myapp",
+			Image: &config.Image{Repository: "nginx", Tag: "latest"},
+		},
+		Targets: map[string]*config.TargetConfig{
+			"prod": {
+				Servers: []string{"https://a.haloy.dev", "b.haloy.dev"},
+				Rollout: config.RolloutStaged,
+			},
+			"staging": {Server: "staging.haloy.dev"},
+		},
+	}
+
+	targets, err := ExtractTargets(deployConfig, "yaml")
+	if err != nil {
+		t.Fatalf("ExtractTargets() error = %v", err)
+	}
+	if len(targets) != 3 {
+		t.Fatalf("ExtractTargets() returned %d targets, want 3", len(targets))
+	}
+
+	for name, want := range map[string]struct {
+		server string
+		index  int
+	}{
+		"prod@a.haloy.dev": {"https://a.haloy.dev", 0},
+		"prod@b.haloy.dev": {"b.haloy.dev", 1},
+	} {
+		target, ok := targets[name]
+		if !ok {
+			t.Fatalf("target %s missing", name)
+		}
+		if target.Server != want.server || target.ServerGroup != "prod" || target.ServerIndex != want.index {
+			t.Errorf("%s: server %q group %q index %d, want %q prod %d",
+				name, target.Server, target.ServerGroup, target.ServerIndex, want.server, want.index)
+		}
+		if target.Name != "prod" || target.TargetName != name || target.Rollout != config.RolloutStaged {
+			t.Errorf("%s: name %q target name %q rollout %q", name, target.Name, target.TargetName, target.Rollout)
+		}
+	}
+
+	// Targets don't share the image, which later steps change per target.
+	targets["prod@a.haloy.dev"].Image.Tag = "changed"
+	if targets["prod@b.haloy.dev"].Image.Tag != "latest" {
+		t.Error("expanded targets share their image")
+	}
+}
+
+func TestExtractTargetsServersErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		target config.TargetConfig
+		errMsg string
+	}{
+		{
+			name:   "server and servers",
+			target: config.TargetConfig{Server: "a.haloy.dev", Servers: []string{"b.haloy.dev"}},
+			errMsg: "'server' and 'servers' can't both be set",
+		},
+		{
+			name:   "duplicate server",
+			target: config.TargetConfig{Servers: []string{"a.haloy.dev", "https://a.haloy.dev"}},
+			errMsg: "listed more than once",
+		},
+		{
+			name:   "invalid rollout",
+			target: config.TargetConfig{Servers: []string{"a.haloy.dev"}, Rollout: "canary"},
+			errMsg: "invalid rollout 'canary', must be one of: parallel, staged",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			deployConfig := config.DeployConfig{
+				TargetConfig: config.TargetConfig{Name: "myapp", Image: &config.Image{Repository: "nginx", Tag: "latest"}},
+				Targets:      map[string]*config.TargetConfig{"prod": &target},
+			}
+			_, err := ExtractTargets(deployConfig, "yaml")
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Fatalf("ExtractTargets() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
 func TestExpandBuildArgsFromEnv(t *testing.T) {
 	tests := []struct {
 		name              string
@@ -1255,3 +1346,199 @@ func TestMergeToTarget_MinReadySeconds(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadRawDeployConfig_HealthCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected []string
+	}{
+		{
+			name: "shell string",
+			yaml: `
+name: myapp
+server: test.haloy.dev
+healthcheck:
+  cmd: "curl -f http://localhost:8080/ready"
+  interval: 10s
+  retries: 3
+`,
+			expected: []string{"CMD-SHELL", "curl -f http://localhost:8080/ready"},
+		},
+		{
+			name: "exec list",
+			yaml: `
+name: myapp
+server: test.haloy.dev
+healthcheck:
+  cmd: ["pg_isready", "-U", "postgres"]
+`,
+			expected: []string{"CMD", "pg_isready", "-U", "postgres"},
+		},
+		{
+			name: "disabled",
+			yaml: `
+name: myapp
+server: test.haloy.dev
+healthcheck:
+  cmd: NONE
+`,
+			expected: []string{"NONE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "haloy.yaml")
+			if err := os.WriteFile(configPath, []byte(tt.yaml), 0o644); err != nil {
+				t.Fatalf("failed to write config file: %v", err)
+			}
+
+			dc, _, err := LoadRawDeployConfig(configPath)
+			if err != nil {
+				t.Fatalf("LoadRawDeployConfig() unexpected error = %v", err)
+			}
+			if dc.HealthCheck == nil {
+				t.Fatal("HealthCheck should not be nil")
+			}
+			if got := dc.HealthCheck.Cmd.DockerTest(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("DockerTest() = %v, expected %v", got, tt.expected)
+			}
+			if err := dc.HealthCheck.Validate("yaml"); err != nil {
+				t.Errorf("Validate() unexpected error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadRawDeployConfig_ClientAuthCAFile(t *testing.T) {
+	dir := t.TempDir()
+	const ca = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	if err := os.WriteFile(filepath.Join(dir, "clients-ca.pem"), []byte(ca), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "haloy.yaml")
+	if err := os.WriteFile(configPath, []byte(`
+name: myapp
+server: test.haloy.dev
+client_auth:
+  ca_file: clients-ca.pem
+targets:
+  internal:
+    client_auth:
+      ca_file: missing.pem
+      mode: verify_if_given
+`), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	_, _, err := LoadRawDeployConfig(configPath)
+	if err == nil || !strings.Contains(err.Error(), "client_auth.ca_file") {
+		t.Fatalf("LoadRawDeployConfig() error = %v, want the missing ca_file reported", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "missing.pem"), []byte(ca), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dc, _, err := LoadRawDeployConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadRawDeployConfig() unexpected error = %v", err)
+	}
+	for _, got := range []*config.ClientAuth{dc.ClientAuth, dc.Targets["internal"].ClientAuth} {
+		if got.CAFile != "" || got.CAInline != ca {
+			t.Errorf("client_auth = %+v, want the file read into ca_inline", got)
+		}
+	}
+}
+
+func writeClientConfig(t *testing.T, content string) {
+	t.Helper()
+	configDir := t.TempDir()
+	t.Setenv(constants.EnvVarConfigDir, configDir)
+	if err := os.WriteFile(filepath.Join(configDir, constants.ClientConfigFileName), []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write client config: %v", err)
+	}
+}
+
+func TestMergeToTarget_ServerProfile(t *testing.T) {
+	writeClientConfig(t, `
+current_profile: staging
+profiles:
+  prod:
+    server: prod.haloy.dev
+  staging:
+    server: staging.haloy.dev
+servers: {}
+`)
+
+	tests := []struct {
+		name     string
+		server   string
+		expected string
+	}{
+		{"profile name resolves to its server", "prod", "prod.haloy.dev"},
+		{"url is kept", "other.haloy.dev", "other.haloy.dev"},
+		{"no server uses the current profile", "", "staging.haloy.dev"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployConfig := config.DeployConfig{
+				TargetConfig: config.TargetConfig{
+					Name:   "myapp",
+					Server: tt.server,
+					Image:  &config.Image{Repository: "nginx", Tag: "latest"},
+				},
+			}
+			tc, err := MergeToTarget(deployConfig, config.TargetConfig{}, "myapp", "yaml")
+			if err != nil {
+				t.Fatalf("MergeToTarget() unexpected error = %v", err)
+			}
+			if tc.Server != tt.expected {
+				t.Errorf("Server = %s, expected %s", tc.Server, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoad_ProfileDefaultTarget(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "haloy.yaml")
+	if err := os.WriteFile(configPath, []byte(`
+image: nginx
+targets:
+  production:
+    server: prod
+  staging:
+    server: staging.haloy.dev
+`), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	writeClientConfig(t, `
+current_profile: prod
+profiles:
+  prod:
+    server: prod.haloy.dev
+    default_target: production
+servers: {}
+`)
+	deployConfig, _, err := Load(context.Background(), configPath, nil, false)
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	if len(deployConfig.Targets) != 1 || deployConfig.Targets["production"] == nil {
+		t.Fatalf("Load() targets = %v, expected only production", deployConfig.Targets)
+	}
+
+	writeClientConfig(t, `
+current_profile: prod
+profiles:
+  prod:
+    server: prod.haloy.dev
+    default_target: missing
+servers: {}
+`)
+	if _, _, err := Load(context.Background(), configPath, nil, false); err == nil {
+		t.Fatalf("Load() expected error when the default target is not in the config")
+	}
+}
@@ -0,0 +1,263 @@
+package configloader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// fieldRename is a target field whose key changed in a schema version.
+type fieldRename struct {
+	schema int
+	// old is the previous key by format. Formats without one kept the key.
+	old   map[string]string
+	field string // TargetConfig field with the current key
+	// removedIn is the haloy version that stops migrating the old key.
+	removedIn string
+}
+
+// fieldRenames lists every rename, oldest schema first. Files with an older
+// schema are migrated on load; files with a newer one must use the new keys.
+var fieldRenames = []fieldRename{
+	// 'healthcheck' and 'health_check' were too easy to mix up.
+	{schema: 2, old: map[string]string{"yaml": "healthcheck", "toml": "healthcheck"}, field: "HealthCheck", removedIn: "v1.0.0"},
+}
+
+// Deprecation is a key of an older schema found in a config file.
+type Deprecation struct {
+	// Target is the target the key is set in, empty for the top level.
+	Target    string
+	Old, New  string
+	RemovedIn string
+}
+
+func (d Deprecation) path(key string) string {
+	if d.Target == "" {
+		return key
+	}
+	return "targets." + d.Target + "." + key
+}
+
+// Change describes the rename, e.g. "'healthcheck' to 'docker_healthcheck'".
+func (d Deprecation) Change() string {
+	return fmt.Sprintf("'%s' to '%s'", d.path(d.Old), d.path(d.New))
+}
+
+func (d Deprecation) String() string {
+	return fmt.Sprintf("'%s' is deprecated, use '%s' instead; it will be removed in haloy %s",
+		d.path(d.Old), d.path(d.New), d.RemovedIn)
+}
+
+// findDeprecations returns the renamed keys set in k, which holds a config
+// file with the given schema.
+func findDeprecations(k *koanf.Koanf, schema int, format string) ([]Deprecation, error) {
+	if schema == 0 {
+		schema = 1
+	}
+
+	prefixes := map[string]string{"": ""}
+	for _, target := range k.MapKeys("targets") {
+		prefixes[target] = "targets." + target + "."
+	}
+
+	var deprecations []Deprecation
+	for _, target := range slices.Sorted(maps.Keys(prefixes)) {
+		prefix := prefixes[target]
+		for _, rename := range fieldRenames {
+			old, ok := rename.old[format]
+			if !ok || !k.Exists(prefix+old) {
+				continue
+			}
+			d := Deprecation{
+				Target:    target,
+				Old:       old,
+				New:       config.GetFieldNameForFormat(config.TargetConfig{}, rename.field, format),
+				RemovedIn: rename.removedIn,
+			}
+			if schema >= rename.schema {
+				return nil, fmt.Errorf("'%s' was renamed to '%s' in schema %d", d.path(d.Old), d.path(d.New), rename.schema)
+			}
+			if k.Exists(prefix + d.New) {
+				return nil, fmt.Errorf("both '%s' and '%s' are set, remove '%s'", d.path(d.Old), d.path(d.New), d.path(d.Old))
+			}
+			deprecations = append(deprecations, d)
+		}
+	}
+	return deprecations, nil
+}
+
+// migrateKeys moves the deprecated keys in k to their current names.
+func migrateKeys(k *koanf.Koanf, deprecations []Deprecation) error {
+	for _, d := range deprecations {
+		value := k.Get(d.path(d.Old))
+		k.Delete(d.path(d.Old))
+		if err := k.Set(d.path(d.New), value); err != nil {
+			return fmt.Errorf("failed to migrate '%s': %w", d.path(d.Old), err)
+		}
+	}
+	return nil
+}
+
+// warnedDeprecations keeps a config file loaded several times by one command
+// from repeating its warnings.
+var warnedDeprecations sync.Map
+
+func warnDeprecations(configFile string, deprecations []Deprecation) {
+	if len(deprecations) == 0 {
+		return
+	}
+	if _, warned := warnedDeprecations.LoadOrStore(configFile, true); warned {
+		return
+	}
+	for _, d := range deprecations {
+		ui.Warn("%s", d)
+	}
+	ui.Warn("Run 'haloy config migrate' to update %s to schema %d", configFile, config.SchemaVersion)
+}
+
+// MigrateConfigFile returns the contents of a config file rewritten to the
+// current schema, with the deprecated keys it moved, or nil contents when the
+// file already uses it. YAML files keep their comments and key order; JSON
+// and TOML files are re-encoded, so their keys end up sorted.
+func MigrateConfigFile(configFile string) ([]byte, []Deprecation, error) {
+	format, err := config.GetConfigFormat(configFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	parser, err := config.GetConfigParser(format)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	k := koanf.New(".")
+	if err := k.Load(file.Provider(configFile), parser); err != nil {
+		return nil, nil, EnhanceConfigError(configFile, format, err)
+	}
+	schema := k.Int("schema")
+	if err := config.ValidateSchema(schema); err != nil {
+		return nil, nil, err
+	}
+	if schema == config.SchemaVersion {
+		return nil, nil, nil
+	}
+	deprecations, err := findDeprecations(k, schema, format)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var data []byte
+	switch format {
+	case "yaml":
+		raw, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err = migrateYAML(raw, deprecations)
+		if err != nil {
+			return nil, nil, err
+		}
+	case "json":
+		if err := migrateKeys(k, deprecations); err != nil {
+			return nil, nil, err
+		}
+		if err := k.Set("schema", config.SchemaVersion); err != nil {
+			return nil, nil, err
+		}
+		data, err = json.MarshalIndent(k.Raw(), "", "  ")
+		if err != nil {
+			return nil, nil, err
+		}
+		data = append(data, '\n')
+	default:
+		if err := migrateKeys(k, deprecations); err != nil {
+			return nil, nil, err
+		}
+		if err := k.Set("schema", config.SchemaVersion); err != nil {
+			return nil, nil, err
+		}
+		data, err = k.Marshal(parser)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return data, deprecations, nil
+}
+
+// migrateYAML renames the deprecated keys and sets the schema by editing the
+// document's nodes, so comments survive the rewrite.
+func migrateYAML(raw []byte, deprecations []Deprecation) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("config file must be a YAML mapping")
+	}
+	root := doc.Content[0]
+
+	for _, d := range deprecations {
+		mapping := root
+		if d.Target != "" {
+			mapping = yamlMapValue(yamlMapValue(root, "targets"), d.Target)
+		}
+		if i := yamlMapIndex(mapping, d.Old); i >= 0 {
+			mapping.Content[i].Value = d.New
+		}
+	}
+
+	schema := fmt.Sprint(config.SchemaVersion)
+	if i := yamlMapIndex(root, "schema"); i >= 0 {
+		root.Content[i+1].Value = schema
+	} else {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "schema"}
+		// A comment at the top of the file is its header, which stays above
+		// the new first key.
+		if len(root.Content) > 0 {
+			key.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+		}
+		root.Content = append([]*yaml.Node{key, {Kind: yaml.ScalarNode, Tag: "!!int", Value: schema}}, root.Content...)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlMapIndex returns the index of key's node in a mapping's content, with
+// its value at the next index, or -1.
+func yamlMapIndex(mapping *yaml.Node, key string) int {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func yamlMapValue(mapping *yaml.Node, key string) *yaml.Node {
+	if i := yamlMapIndex(mapping, key); i >= 0 {
+		return mapping.Content[i+1]
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package configloader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadRawDeployConfig_Schema(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "schema 1 is migrated",
+			file: "haloy.yaml",
+			content: `
+server: test.haloy.dev
+targets:
+  web:
+    healthcheck:
+      cmd: "true"
+`,
+		},
+		{
+			name: "schema 1 toml is migrated",
+			file: "haloy.toml",
+			content: `
+name = "myapp"
+server = "test.haloy.dev"
+
+[healthcheck]
+cmd = "true"
+`,
+		},
+		{
+			name: "current schema",
+			file: "haloy.yaml",
+			content: `
+schema: 2
+name: myapp
+server: test.haloy.dev
+docker_healthcheck:
+  cmd: "true"
+`,
+		},
+		{
+			name: "old key with current schema",
+			file: "haloy.yaml",
+			content: `
+schema: 2
+name: myapp
+server: test.haloy.dev
+healthcheck:
+  cmd: "true"
+`,
+			wantErr: "'healthcheck' was renamed to 'docker_healthcheck' in schema 2",
+		},
+		{
+			name: "old and new key",
+			file: "haloy.yaml",
+			content: `
+server: test.haloy.dev
+targets:
+  web:
+    healthcheck:
+      cmd: "true"
+    docker_healthcheck:
+      cmd: "true"
+`,
+			wantErr: "both 'targets.web.healthcheck' and 'targets.web.docker_healthcheck' are set",
+		},
+		{
+			name: "newer schema",
+			file: "haloy.yaml",
+			content: `
+schema: 3
+name: myapp
+server: test.haloy.dev
+`,
+			wantErr: "only supports up to schema 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dc, _, err := LoadRawDeployConfig(writeConfigFile(t, tt.file, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadRawDeployConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadRawDeployConfig() unexpected error = %v", err)
+			}
+			hc := dc.HealthCheck
+			if target, ok := dc.Targets["web"]; ok {
+				hc = target.HealthCheck
+			}
+			if hc == nil || len(hc.Cmd) == 0 {
+				t.Errorf("HealthCheck = %+v, want the migrated healthcheck", hc)
+			}
+		})
+	}
+}
+
+func TestMigrateConfigFile_YAML(t *testing.T) {
+	path := writeConfigFile(t, "haloy.yaml", `# Production app
+server: test.haloy.dev
+healthcheck:
+  cmd: "true" # keep this
+targets:
+  web:
+    # Docker healthcheck for the web target
+    healthcheck:
+      cmd: ["curl", "-f", "localhost"]
+`)
+
+	data, deprecations, err := MigrateConfigFile(path)
+	if err != nil {
+		t.Fatalf("MigrateConfigFile() unexpected error = %v", err)
+	}
+	if len(deprecations) != 2 {
+		t.Errorf("MigrateConfigFile() deprecations = %v, want 2", deprecations)
+	}
+
+	got := string(data)
+	for _, want := range []string{
+		"# Production app\nschema: 2\n",
+		"docker_healthcheck:\n  cmd: \"true\" # keep this",
+		"# Docker healthcheck for the web target\n    docker_healthcheck:",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("migrated config doesn't contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, " healthcheck:") || strings.HasPrefix(got, "healthcheck:") {
+		t.Errorf("migrated config still has 'healthcheck':\n%s", got)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("failed to write migrated config: %v", err)
+	}
+	data, _, err = MigrateConfigFile(path)
+	if err != nil || data != nil {
+		t.Errorf("MigrateConfigFile() on a migrated file = %q, %v, want nil contents", data, err)
+	}
+	if _, _, err := LoadRawDeployConfig(path); err != nil {
+		t.Errorf("LoadRawDeployConfig() on a migrated file: %v", err)
+	}
+}
+
+func TestMigrateConfigFile_TOML(t *testing.T) {
+	path := writeConfigFile(t, "haloy.toml", `name = "myapp"
+server = "test.haloy.dev"
+
+[healthcheck]
+cmd = "true"
+`)
+
+	data, _, err := MigrateConfigFile(path)
+	if err != nil {
+		t.Fatalf("MigrateConfigFile() unexpected error = %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("failed to write migrated config: %v", err)
+	}
+	dc, _, err := LoadRawDeployConfig(path)
+	if err != nil {
+		t.Fatalf("LoadRawDeployConfig() on a migrated file: %v\n%s", err, data)
+	}
+	if dc.Schema != 2 || dc.HealthCheck == nil {
+		t.Errorf("migrated config has schema %d and healthcheck %v, want 2 and the healthcheck:\n%s", dc.Schema, dc.HealthCheck, data)
+	}
+}
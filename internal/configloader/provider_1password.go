@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/haloydev/haloy/internal/cmdexec"
 	"github.com/haloydev/haloy/internal/config"
@@ -26,8 +27,12 @@ func fetchFrom1Password(ctx context.Context, config config.OnePasswordSourceConf
 	// This struct matches the JSON output of 'op item get'
 	type opItem struct {
 		Fields []struct {
-			Label string `json:"label"`
-			Value string `json:"value"`
+			ID      string `json:"id"`
+			Label   string `json:"label"`
+			Value   string `json:"value"`
+			Section *struct {
+				Label string `json:"label"`
+			} `json:"section"`
 		} `json:"fields"`
 	}
 
@@ -35,6 +40,9 @@ func fetchFrom1Password(ctx context.Context, config config.OnePasswordSourceConf
 		WaitMessage: onePasswordWaitMessage,
 	}, "op", args...)
 	if err != nil {
+		if isOnePasswordSignInError(err) {
+			return nil, fmt.Errorf("the 1Password CLI isn't signed in. Run 'eval $(op signin)' or turn on the 1Password app integration, then retry: %w", err)
+		}
 		return nil, err
 	}
 
@@ -48,6 +56,34 @@ func fetchFrom1Password(ctx context.Context, config config.OnePasswordSourceConf
 		// The key is the field label from 1Password (e.g., "username", "password", "api-key")
 		secrets[field.Label] = field.Value
 	}
+	// 1Password secret references may also name a field by section or ID,
+	// which never replaces a field of that label.
+	for _, field := range item.Fields {
+		if field.Section != nil && field.Section.Label != "" {
+			addMissing(secrets, field.Section.Label+"/"+field.Label, field.Value)
+		}
+		if field.ID != "" {
+			addMissing(secrets, field.ID, field.Value)
+		}
+	}
 
 	return secrets, nil
 }
+
+func addMissing(secrets map[string]string, key, value string) {
+	if _, ok := secrets[key]; !ok {
+		secrets[key] = value
+	}
+}
+
+// isOnePasswordSignInError reports whether op failed because no account is
+// signed in or the session expired.
+func isOnePasswordSignInError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, s := range []string{"not currently signed in", "not signed in", "session expired", "no accounts configured"} {
+		if strings.Contains(message, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package configloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/haloydev/haloy/internal/cmdexec"
+)
+
+const bitwardenWaitMessage = "Waiting for Bitwarden."
+
+var runBitwardenCLICommand = cmdexec.RunCLICommandWithOptions
+
+// checkBitwardenStatus fails with instructions when the Bitwarden CLI can't
+// read the vault, instead of letting 'bw get' prompt or fail opaquely.
+func checkBitwardenStatus(ctx context.Context) error {
+	output, err := runBitwardenCLICommand(ctx, cmdexec.CLICommandOptions{}, "bw", "status", "--nointeraction")
+	if err != nil {
+		return err
+	}
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(output), &status); err != nil {
+		return fmt.Errorf("failed to parse JSON output from Bitwarden CLI: %w", err)
+	}
+	switch status.Status {
+	case "unlocked":
+		return nil
+	case "unauthenticated":
+		return errors.New("the Bitwarden CLI isn't logged in. Run 'bw login', then 'export BW_SESSION=$(bw unlock --raw)'")
+	case "locked":
+		return errors.New("the Bitwarden vault is locked. Run 'export BW_SESSION=$(bw unlock --raw)'")
+	default:
+		return fmt.Errorf("unexpected Bitwarden CLI status '%s'", status.Status)
+	}
+}
+
+// fetchFromBitwarden returns the fields of a Bitwarden item: username,
+// password, totp, uri and notes, plus its custom fields by name.
+func fetchFromBitwarden(ctx context.Context, item string) (map[string]string, error) {
+	if err := checkBitwardenStatus(ctx); err != nil {
+		return nil, err
+	}
+
+	// This struct matches the JSON output of 'bw get item'
+	type bwItem struct {
+		Notes string `json:"notes"`
+		Login *struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			TOTP     string `json:"totp"`
+			URIs     []struct {
+				URI string `json:"uri"`
+			} `json:"uris"`
+		} `json:"login"`
+		Fields []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"fields"`
+	}
+
+	output, err := runBitwardenCLICommand(ctx, cmdexec.CLICommandOptions{
+		WaitMessage: bitwardenWaitMessage,
+	}, "bw", "get", "item", item, "--nointeraction")
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed bwItem
+	if err := json.Unmarshal([]byte(output), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON output from Bitwarden CLI: %w", err)
+	}
+
+	secrets := make(map[string]string)
+	for _, field := range parsed.Fields {
+		secrets[field.Name] = field.Value
+	}
+	set := func(key, value string) {
+		if value != "" {
+			secrets[key] = value
+		}
+	}
+	set("notes", parsed.Notes)
+	if parsed.Login != nil {
+		set("username", parsed.Login.Username)
+		set("password", parsed.Login.Password)
+		set("totp", parsed.Login.TOTP)
+		if len(parsed.Login.URIs) > 0 {
+			set("uri", parsed.Login.URIs[0].URI)
+		}
+	}
+	return secrets, nil
+}
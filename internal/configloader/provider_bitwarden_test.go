@@ -0,0 +1,82 @@
+package configloader
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/cmdexec"
+)
+
+func TestFetchFromBitwarden(t *testing.T) {
+	var calls [][]string
+	withBitwardenProviderTestDouble(t, func(_ context.Context, _ cmdexec.CLICommandOptions, name string, args ...string) (string, error) {
+		calls = append(calls, append([]string{name}, args...))
+		if args[0] == "status" {
+			return `{"serverUrl":null,"status":"unlocked"}`, nil
+		}
+		return `{
+			"notes": "rotate monthly",
+			"login": {"username": "deploy", "password": "hunter2", "uris": [{"uri": "https://db.example.com"}]},
+			"fields": [{"name": "api-key", "value": "k-123"}, {"name": "password", "value": "custom"}]
+		}`, nil
+	})
+
+	got, err := fetchFromBitwarden(context.Background(), "shop/production")
+	if err != nil {
+		t.Fatalf("fetchFromBitwarden() error = %v", err)
+	}
+	want := map[string]string{
+		"notes":    "rotate monthly",
+		"username": "deploy",
+		"password": "hunter2",
+		"uri":      "https://db.example.com",
+		"api-key":  "k-123",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fetchFromBitwarden() = %#v, want %#v", got, want)
+	}
+	wantCalls := [][]string{
+		{"bw", "status", "--nointeraction"},
+		{"bw", "get", "item", "shop/production", "--nointeraction"},
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("commands = %v, want %v", calls, wantCalls)
+	}
+}
+
+func TestFetchFromBitwardenNotUnlocked(t *testing.T) {
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{status: "unauthenticated", want: "bw login"},
+		{status: "locked", want: "bw unlock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			withBitwardenProviderTestDouble(t, func(_ context.Context, _ cmdexec.CLICommandOptions, _ string, args ...string) (string, error) {
+				if args[0] != "status" {
+					t.Fatalf("ran bw %v with a %s vault", args, tt.status)
+				}
+				return `{"status":"` + tt.status + `"}`, nil
+			})
+
+			_, err := fetchFromBitwarden(context.Background(), "shop")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("fetchFromBitwarden() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func withBitwardenProviderTestDouble(t *testing.T, runner func(context.Context, cmdexec.CLICommandOptions, string, ...string) (string, error)) {
+	t.Helper()
+
+	original := runBitwardenCLICommand
+	runBitwardenCLICommand = runner
+	t.Cleanup(func() {
+		runBitwardenCLICommand = original
+	})
+}
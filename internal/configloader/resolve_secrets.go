@@ -11,12 +11,20 @@ import (
 	"github.com/jinzhu/copier"
 )
 
-func ResolveSecrets(ctx context.Context, deployConfig config.DeployConfig, configPath string) (config.DeployConfig, error) {
+func ResolveSecrets(ctx context.Context, deployConfig config.DeployConfig, configPath string) (_ config.DeployConfig, err error) {
+	defer markConfigError(&err)
+
 	configFile, err := FindConfigFile(configPath)
 	if err != nil {
 		return config.DeployConfig{}, fmt.Errorf("failed to determine config file path: %w", err)
 	}
-	configDir := filepath.Dir(configFile)
+	return ResolveSecretsInDir(ctx, deployConfig, filepath.Dir(configFile))
+}
+
+// ResolveSecretsInDir is ResolveSecrets for a config that isn't read from a
+// file, resolving relative paths like SOPS files against configDir.
+func ResolveSecretsInDir(ctx context.Context, deployConfig config.DeployConfig, configDir string) (_ config.DeployConfig, err error) {
+	defer markConfigError(&err)
 
 	var resolvedConfig config.DeployConfig
 	if err := copier.Copy(&resolvedConfig, &deployConfig); err != nil {
@@ -90,6 +98,10 @@ func gatherValueSources(deployConfig *config.DeployConfig) []*config.ValueSource
 		sources = append(sources, deployConfig.APIToken)
 	}
 
+	if deployConfig.SSO != nil {
+		sources = append(sources, &deployConfig.SSO.ClientSecret)
+	}
+
 	for i := range deployConfig.Env {
 		sources = append(sources, &deployConfig.Env[i].ValueSource)
 	}
@@ -102,6 +114,10 @@ func gatherValueSources(deployConfig *config.DeployConfig) []*config.ValueSource
 		sources = append(sources, gatherImageValueSources(image)...)
 	}
 
+	for i := range deployConfig.Sidecars {
+		sources = append(sources, gatherSidecarValueSources(&deployConfig.Sidecars[i])...)
+	}
+
 	for _, targetConfig := range deployConfig.Targets {
 		sources = append(sources, gatherTargetValueSources(targetConfig)...)
 	}
@@ -133,6 +149,10 @@ func gatherTargetValueSources(tc *config.TargetConfig) []*config.ValueSource {
 		sources = append(sources, tc.APIToken)
 	}
 
+	if tc.SSO != nil {
+		sources = append(sources, &tc.SSO.ClientSecret)
+	}
+
 	for i := range tc.Env {
 		sources = append(sources, &tc.Env[i].ValueSource)
 	}
@@ -141,6 +161,24 @@ func gatherTargetValueSources(tc *config.TargetConfig) []*config.ValueSource {
 		sources = append(sources, gatherImageValueSources(tc.Image)...)
 	}
 
+	for i := range tc.Sidecars {
+		sources = append(sources, gatherSidecarValueSources(&tc.Sidecars[i])...)
+	}
+
+	return sources
+}
+
+func gatherSidecarValueSources(sidecar *config.Sidecar) []*config.ValueSource {
+	var sources []*config.ValueSource
+
+	for i := range sidecar.Env {
+		sources = append(sources, &sidecar.Env[i].ValueSource)
+	}
+
+	if sidecar.Image != nil {
+		sources = append(sources, gatherImageValueSources(sidecar.Image)...)
+	}
+
 	return sources
 }
 
@@ -161,44 +199,39 @@ type fetchGroup struct {
 func groupSources(sources []*config.ValueSource, providers *config.SecretProviders, configFormat string, configDir string) (map[groupKey]fetchGroup, error) {
 	groups := make(map[groupKey]fetchGroup)
 
-	// if there are no providers are defined we'll check if there are any from.secret in the config and return an error.
-	if providers == nil {
-		for _, vs := range sources {
-			if vs.From != nil && vs.From.Secret != "" {
-				return nil, fmt.Errorf("found 'from.secret' reference but no '%s' block is defined in the configuration", config.GetFieldNameForFormat(config.DeployConfig{}, "SecretProviders", configFormat))
-			}
-		}
-		return groups, nil // Only `env:` sources are possible, which don't need grouping.
-	}
-
 	for _, vs := range sources {
 		if vs.From == nil || vs.From.Secret == "" {
 			continue // Skip plaintext values and 'env:' sources
 		}
-
-		parts := strings.SplitN(vs.From.Secret, ":", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid secret reference format: '%s'. Expected 'provider:source_name:key'", vs.From.Secret)
+		if _, ok := vs.From.ServerSecret(); ok {
+			continue // Resolved by haloyd
 		}
-		provider, ref := parts[0], parts[1]
 
-		refParts := strings.SplitN(ref, ":", 2)
-		if len(refParts) != 2 {
-			return nil, fmt.Errorf("invalid secret reference format: '%s'. Expected 'source_name:key'", ref)
+		ref, err := parseSecretReference(vs.From.Secret)
+		if err != nil {
+			return nil, err
+		}
+		// Only direct references work without a secret providers block.
+		if providers == nil && !ref.direct() {
+			return nil, fmt.Errorf("found 'from.secret' reference but no '%s' block is defined in the configuration", config.GetFieldNameForFormat(config.DeployConfig{}, "SecretProviders", configFormat))
 		}
-		sourceName, extractKey := refParts[0], refParts[1]
 
-		key := groupKey(fmt.Sprintf("%s:%s", provider, sourceName))
+		key := ref.groupKey()
 		group, ok := groups[key]
 		if !ok {
 			var sourceConfig any
 			var found bool
-			switch provider {
+			switch ref.provider {
+			case providerOnePasswordRef:
+				vault, item, _ := strings.Cut(ref.sourceName, "/")
+				sourceConfig, found = config.OnePasswordSourceConfig{Vault: vault, Item: item}, true
+			case providerBitwardenRef:
+				sourceConfig, found = ref.sourceName, true
 			case "onepassword":
-				sourceConfig, found = providers.OnePassword[sourceName]
+				sourceConfig, found = providers.OnePassword[ref.sourceName]
 			case "sops":
 				var sopsConfig config.SOPSSourceConfig
-				sopsConfig, found = providers.SOPS[sourceName]
+				sopsConfig, found = providers.SOPS[ref.sourceName]
 				if found {
 					sopsConfig.File = resolveSOPSPath(sopsConfig.File, configDir)
 					sourceConfig = sopsConfig
@@ -209,18 +242,18 @@ func groupSources(sources []*config.ValueSource, providers *config.SecretProvide
 			}
 
 			if !found {
-				return nil, fmt.Errorf("secret source '%s' for provider '%s' not defined in 'secretProviders' block", sourceName, provider)
+				return nil, fmt.Errorf("secret source '%s' for provider '%s' not defined in 'secretProviders' block", ref.sourceName, ref.provider)
 			}
 
 			group = fetchGroup{
-				provider:      provider,
-				sourceName:    sourceName,
+				provider:      ref.provider,
+				sourceName:    ref.sourceName,
 				sourceConfig:  sourceConfig,
 				keysToExtract: make(map[string]bool),
 			}
 		}
 
-		group.keysToExtract[extractKey] = true
+		group.keysToExtract[ref.key] = true
 		groups[key] = group
 	}
 
@@ -242,6 +275,16 @@ func fetchGroupedSources(ctx context.Context, groups map[groupKey]fetchGroup) (m
 		case "sops":
 			config := group.sourceConfig.(config.SOPSSourceConfig)
 			fetchedSecrets, err = fetchFromSOPS(ctx, config)
+		case providerOnePasswordRef:
+			config := group.sourceConfig.(config.OnePasswordSourceConfig)
+			fetchedSecrets, err = fetchCached(ctx, key, func() (map[string]string, error) {
+				return fetchFrom1Password(ctx, config)
+			})
+		case providerBitwardenRef:
+			item := group.sourceConfig.(string)
+			fetchedSecrets, err = fetchCached(ctx, key, func() (map[string]string, error) {
+				return fetchFromBitwarden(ctx, item)
+			})
 		// Add cases for other providers here
 		default:
 			err = fmt.Errorf("unsupported secret provider: %s", group.provider)
@@ -262,6 +305,9 @@ func extractValues(sources []*config.ValueSource, cache map[groupKey]map[string]
 		if vs.From == nil {
 			continue
 		}
+		if _, ok := vs.From.ServerSecret(); ok {
+			continue
+		}
 
 		if vs.From.Env != "" {
 			envValue := os.Getenv(vs.From.Env)
@@ -270,12 +316,12 @@ func extractValues(sources []*config.ValueSource, cache map[groupKey]map[string]
 			}
 			vs.Value = envValue
 		} else if vs.From.Secret != "" {
-			parts := strings.SplitN(vs.From.Secret, ":", 2)
-			provider, ref := parts[0], parts[1]
-			refParts := strings.SplitN(ref, ":", 2)
-			sourceName, extractKey := refParts[0], refParts[1]
-
-			key := groupKey(fmt.Sprintf("%s:%s", provider, sourceName))
+			ref, err := parseSecretReference(vs.From.Secret)
+			if err != nil {
+				return err
+			}
+			sourceName, extractKey := ref.sourceName, ref.key
+			key := ref.groupKey()
 
 			fetchedGroup, ok := cache[key]
 			if !ok {
@@ -4,9 +4,11 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/haloydev/haloy/internal/cmdexec"
 	"github.com/haloydev/haloy/internal/config"
 )
 
@@ -72,3 +74,84 @@ func writeResolveValueSourceTestConfig(t *testing.T) string {
 	}
 	return configPath
 }
+
+func TestResolveValueSourceResolvesDirectReferencesOncePerItem(t *testing.T) {
+	ctx := WithSecretCache(context.Background())
+	opCalls := 0
+	with1PasswordProviderTestDouble(t, func(_ context.Context, _ cmdexec.CLICommandOptions, _ string, args ...string) (string, error) {
+		opCalls++
+		if !reflect.DeepEqual(args, []string{"item", "get", "shop", "--vault", "apps", "--format", "json"}) {
+			t.Errorf("op args = %v", args)
+		}
+		return `{"fields":[{"id":"password","label":"password","value":"secret"},{"label":"token","value":"abc","section":{"label":"api"}}]}`, nil
+	})
+	configPath := writeResolveValueSourceTestConfig(t)
+
+	for _, tt := range []struct {
+		reference string
+		want      string
+	}{
+		{reference: "op://apps/shop/password", want: "secret"},
+		{reference: "op://apps/shop/api/token", want: "abc"},
+	} {
+		source := &config.ValueSource{From: &config.SourceReference{Secret: tt.reference}}
+		resolved, err := ResolveValueSource(ctx, source, nil, "yaml", configPath)
+		if err != nil {
+			t.Fatalf("ResolveValueSource(%s) error = %v", tt.reference, err)
+		}
+		if resolved.Value != tt.want {
+			t.Errorf("ResolveValueSource(%s) = %q, want %q", tt.reference, resolved.Value, tt.want)
+		}
+	}
+	if opCalls != 1 {
+		t.Errorf("op ran %d times, want once", opCalls)
+	}
+}
+
+func TestResolveValueSourceWithoutSecretCacheSeesRotatedValues(t *testing.T) {
+	password := "old"
+	with1PasswordProviderTestDouble(t, func(_ context.Context, _ cmdexec.CLICommandOptions, _ string, _ ...string) (string, error) {
+		return `{"fields":[{"id":"password","label":"password","value":"` + password + `"}]}`, nil
+	})
+	configPath := writeResolveValueSourceTestConfig(t)
+
+	for _, want := range []string{"old", "new"} {
+		password = want
+		source := &config.ValueSource{From: &config.SourceReference{Secret: "op://apps/shop/password"}}
+		resolved, err := ResolveValueSource(context.Background(), source, nil, "yaml", configPath)
+		if err != nil {
+			t.Fatalf("ResolveValueSource() error = %v", err)
+		}
+		if resolved.Value != want {
+			t.Errorf("ResolveValueSource() = %q, want %q", resolved.Value, want)
+		}
+	}
+}
+
+func TestParseSecretReference(t *testing.T) {
+	tests := []struct {
+		secret  string
+		want    secretReference
+		wantErr bool
+	}{
+		{secret: "onepassword:prod:api-key", want: secretReference{provider: "onepassword", sourceName: "prod", key: "api-key"}},
+		{secret: "op://apps/shop/api/token", want: secretReference{provider: "op", sourceName: "apps/shop", key: "api/token"}},
+		{secret: "bw://team/shop/password", want: secretReference{provider: "bw", sourceName: "team/shop", key: "password"}},
+		{secret: "op://apps/shop", wantErr: true},
+		{secret: "bw://shop", wantErr: true},
+		{secret: "bw://shop/", wantErr: true},
+		{secret: "onepassword:prod", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSecretReference(tt.secret)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSecretReference(%q) = %+v, want error", tt.secret, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseSecretReference(%q) = %+v, %v, want %+v", tt.secret, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package configloader
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/haloydev/haloy/internal/config"
+)
+
+// Providers of direct secret references, which name an item in a password
+// manager instead of a source in the secret providers block.
+const (
+	providerOnePasswordRef = "op"
+	providerBitwardenRef   = "bw"
+)
+
+// secretReference is a parsed from.secret value.
+type secretReference struct {
+	provider   string
+	sourceName string
+	key        string
+}
+
+func (r secretReference) groupKey() groupKey {
+	return groupKey(r.provider + ":" + r.sourceName)
+}
+
+// direct reports whether the reference needs no secret providers block.
+func (r secretReference) direct() bool {
+	return r.provider == providerOnePasswordRef || r.provider == providerBitwardenRef
+}
+
+// parseSecretReference parses a from.secret value, one of:
+//
+//	provider:source_name:key   a source of the secret providers block
+//	op://vault/item/field      a 1Password secret reference; field may be section/field
+//	bw://item/field            a field of a Bitwarden item, by name or ID
+func parseSecretReference(secret string) (secretReference, error) {
+	if rest, ok := strings.CutPrefix(secret, "op://"); ok {
+		parts := strings.SplitN(rest, "/", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return secretReference{}, fmt.Errorf("invalid 1Password reference: '%s'. Expected 'op://vault/item/field'", secret)
+		}
+		return secretReference{provider: providerOnePasswordRef, sourceName: parts[0] + "/" + parts[1], key: parts[2]}, nil
+	}
+	if rest, ok := strings.CutPrefix(secret, "bw://"); ok {
+		// Item names may contain slashes, field names rarely do.
+		i := strings.LastIndex(rest, "/")
+		if i <= 0 || i == len(rest)-1 {
+			return secretReference{}, fmt.Errorf("invalid Bitwarden reference: '%s'. Expected 'bw://item/field'", secret)
+		}
+		return secretReference{provider: providerBitwardenRef, sourceName: rest[:i], key: rest[i+1:]}, nil
+	}
+
+	provider, ref, ok := strings.Cut(secret, ":")
+	if !ok {
+		return secretReference{}, fmt.Errorf("invalid secret reference format: '%s'. Expected 'provider:source_name:key'", secret)
+	}
+	sourceName, key, ok := strings.Cut(ref, ":")
+	if !ok {
+		return secretReference{}, fmt.Errorf("invalid secret reference format: '%s'. Expected 'source_name:key'", ref)
+	}
+	return secretReference{provider: provider, sourceName: sourceName, key: key}, nil
+}
+
+// secretCache holds the items fetched for direct references.
+type secretCache struct {
+	mu    sync.Mutex
+	items map[groupKey]map[string]string
+}
+
+type secretCacheKey struct{}
+
+// WithSecretCache returns a context under which each item a direct reference
+// names is fetched once. Commands resolve the secrets of each target on its
+// own, and sharing the cache for the command keeps a deploy of several
+// targets from asking the password manager, and the user, more than once per
+// item. Without it every resolve fetches the items again, so a long-running
+// caller picks up rotated values.
+func WithSecretCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, secretCacheKey{}, &secretCache{items: make(map[groupKey]map[string]string)})
+}
+
+func fetchCached(ctx context.Context, key groupKey, fetch func() (map[string]string, error)) (map[string]string, error) {
+	cache, ok := ctx.Value(secretCacheKey{}).(*secretCache)
+	if !ok {
+		return fetch()
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if secrets, ok := cache.items[key]; ok {
+		return secrets, nil
+	}
+	secrets, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	cache.items[key] = secrets
+	return secrets, nil
+}
+
+// ResolveSecretReference resolves a direct op:// or bw:// reference on its
+// own, without a config.
+func ResolveSecretReference(ctx context.Context, secret string) (string, error) {
+	ref, err := parseSecretReference(secret)
+	if err != nil {
+		return "", err
+	}
+	if !ref.direct() {
+		return "", fmt.Errorf("'%s' isn't an op:// or bw:// reference", secret)
+	}
+	vs := &config.ValueSource{From: &config.SourceReference{Secret: secret}}
+	if err := resolveValueSources(ctx, []*config.ValueSource{vs}, nil, "", ""); err != nil {
+		return "", err
+	}
+	return vs.Value, nil
+}
@@ -0,0 +1,118 @@
+package configloader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// Directories that never hold configs to deploy and are expensive to walk.
+var workspaceSkipDirs = []string{".git", "node_modules", "vendor"}
+
+// FindWorkspaceConfigs returns the haloy config files in root and the
+// directories below it, one per directory, sorted by path. Paths matching a
+// pattern in root's .haloyignore are skipped.
+func FindWorkspaceConfigs(root string) ([]string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	ignore, err := readIgnoreFile(filepath.Join(absRoot, constants.WorkspaceIgnoreFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []string
+	err = filepath.WalkDir(absRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(absRoot, p)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			if strings.HasPrefix(d.Name(), ".") || slices.Contains(workspaceSkipDirs, d.Name()) || ignore.matches(filepath.ToSlash(rel)) {
+				return filepath.SkipDir
+			}
+		}
+		for _, configName := range supportedConfigNames {
+			configPath := filepath.Join(p, configName)
+			if ignore.matches(filepath.ToSlash(filepath.Join(rel, configName))) {
+				continue
+			}
+			if _, err := os.Stat(configPath); err == nil {
+				configs = append(configs, configPath)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s for configs: %w", root, err)
+	}
+	return configs, nil
+}
+
+// ignorePatterns is a parsed .haloyignore. It supports the common subset of
+// .gitignore: comments, blank lines, glob patterns, a leading '/' to anchor a
+// pattern to the workspace root and a trailing '/' that is ignored. Patterns
+// without a '/' match a file or directory name at any depth.
+type ignorePatterns []string
+
+func readIgnoreFile(p string) (ignorePatterns, error) {
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p, err)
+	}
+	defer f.Close()
+
+	var patterns ignorePatterns
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSuffix(line, "/")
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q in %s: %w", line, p, err)
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p, err)
+	}
+	return patterns, nil
+}
+
+// matches reports whether the slash-separated path, relative to the
+// workspace root, is ignored.
+func (patterns ignorePatterns) matches(rel string) bool {
+	for _, pattern := range patterns {
+		if anchored, ok := strings.CutPrefix(pattern, "/"); ok || strings.Contains(pattern, "/") {
+			if matched, _ := path.Match(anchored, rel); matched {
+				return true
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern, path.Base(rel)); matched {
+			return true
+		}
+	}
+	return false
+}
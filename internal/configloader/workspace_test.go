@@ -0,0 +1,66 @@
+package configloader
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFindWorkspaceConfigs(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"haloy.yaml":                      "name: root\n",
+		"services/api/haloy.yaml":         "name: api\n",
+		"services/api/haloy.json":         "{}",
+		"services/web/haloy.toml":         "name = \"web\"\n",
+		"services/legacy/haloy.yml":       "name: legacy\n",
+		"examples/demo/haloy.yaml":        "name: demo\n",
+		"node_modules/pkg/haloy.yaml":     "name: pkg\n",
+		".github/haloy.yaml":              "name: hidden\n",
+		"services/web/testdata/haloy.yml": "name: fixture\n",
+		".haloyignore":                    "# not deployed\n/examples/\nlegacy\ntestdata\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	configs, err := FindWorkspaceConfigs(root)
+	if err != nil {
+		t.Fatalf("FindWorkspaceConfigs() unexpected error: %v", err)
+	}
+	var got []string
+	for _, config := range configs {
+		rel, _ := filepath.Rel(root, config)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{"haloy.yaml", "services/api/haloy.json", "services/web/haloy.toml"}
+	if !slices.Equal(got, want) {
+		t.Errorf("FindWorkspaceConfigs() = %v, want %v", got, want)
+	}
+}
+
+func TestIgnorePatternsMatches(t *testing.T) {
+	patterns := ignorePatterns{"/examples", "*.bak", "apps/*/old"}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"examples", true},
+		{"apps/examples", false},
+		{"apps/web/haloy.yaml.bak", true},
+		{"apps/web/old", true},
+		{"apps/web/new", false},
+	}
+	for _, tt := range tests {
+		if got := patterns.matches(tt.path); got != tt.want {
+			t.Errorf("matches(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -18,26 +18,101 @@ const (
 	DefaultImageDiskReserve  = 2 * 1024 * 1024 * 1024
 	CapabilityLayerUpload    = "layer-upload"
 	CapabilityImagePreflight = "image-disk-preflight"
+	CapabilityLayerResume    = "layer-upload-resume"
+	CapabilityImageCheck     = "image-check"
+
+	// CapabilityFeatureNegotiation marks a server that advertises every deploy
+	// config feature it supports below, so a missing one means unsupported.
+	CapabilityFeatureNegotiation = "feature-negotiation"
+	CapabilitySidecars           = "sidecars"
+	CapabilityHealthCheck        = "healthcheck-override"
+	CapabilityRouteLimits        = "proxy-route-limits"
+	CapabilityPathPrefix         = "path-prefix-routing"
+	CapabilityErrorPages         = "error-pages"
+	CapabilityCDN                = "cdn-aware-domains"
+	CapabilityHealthCheckTypes   = "health-check-types"
+	CapabilityStartupProbe       = "startup-probe"
+	CapabilitySecurityOptions    = "container-security-options"
+	CapabilityRunAsUser          = "run-as-user"
+	CapabilityContainerLogging   = "container-logging-and-labels"
+	CapabilityHostPorts          = "host-ports"
+	CapabilityRedirects          = "proxy-redirects"
+	CapabilityURLNormalization   = "url-normalization"
+	CapabilityClientAuth         = "client-auth"
+	CapabilitySSO                = "proxy-sso"
+
+	// CDNCloudflare marks a domain proxied by Cloudflare (orange cloud).
+	CDNCloudflare = "cloudflare"
+
+	// Trailing slash handling of URL normalization: add one to paths without
+	// a file extension, or remove it from every path but the root.
+	TrailingSlashAdd    = "add"
+	TrailingSlashRemove = "remove"
+
+	// Client certificate authentication modes: reject clients without a
+	// certificate from the CA, or only verify the certificates clients send.
+	ClientAuthRequire       = "require"
+	ClientAuthVerifyIfGiven = "verify_if_given"
+
+	// Identity providers the proxy can sign users in with.
+	SSOProviderOIDC   = "oidc"
+	SSOProviderGoogle = "google"
+	SSOProviderGitHub = "github"
 
 	CertificatesHTTPProviderPort = "8080"
+	// OnDemandTLSPath on the certificate HTTP provider takes reports of TLS
+	// handshakes for unknown domains from the proxy. The proxy only forwards
+	// /.well-known/acme-challenge/ paths there, so it isn't reachable from outside.
+	OnDemandTLSPath = "/haloy/on-demand-tls"
+	// BackendFailurePath on the certificate HTTP provider takes reports of
+	// failing backends from the proxy, for an immediate active health check.
+	BackendFailurePath = "/haloy/backend-failure"
 
 	// haloyd's loopback API listener; the proxy forwards API-domain and
 	// localhost API traffic here.
 	HaloydAPIHost = "127.0.0.1"
 	HaloydAPIPort = "9922"
 
+	// HeaderIdempotencyKey names a request the client may send again after a
+	// network error; haloyd answers repeats with the first response.
+	HeaderIdempotencyKey = "Idempotency-Key"
+
 	// Environment variables
 	EnvVarAPIToken  = "HALOY_API_TOKEN"
 	EnvVarReplicaID = "HALOY_REPLICA_ID" // available in all containers.
-	EnvVarDataDir   = "HALOY_DATA_DIR"   // used to override default data directory.
-	EnvVarConfigDir = "HALOY_CONFIG_DIR" // used to override default config directory.
-	EnvVarDebug     = "HALOY_DEBUG"
+	// Set in containers of targets with a migration_lock.
+	EnvVarMigrationLock      = "HALOY_MIGRATION_LOCK"
+	EnvVarMigrationLockURL   = "HALOY_MIGRATION_LOCK_URL"
+	EnvVarMigrationLockToken = "HALOY_MIGRATION_LOCK_TOKEN"
+	EnvVarDataDir            = "HALOY_DATA_DIR"   // used to override default data directory.
+	EnvVarConfigDir          = "HALOY_CONFIG_DIR" // used to override default config directory.
+	EnvVarDebug              = "HALOY_DEBUG"
+	EnvVarAPIRetries         = "HALOY_API_RETRIES"    // how often haloy retries API requests that failed on the network, 0 disables.
+	EnvVarAPITimeout         = "HALOY_API_TIMEOUT"    // how long haloy waits for haloyd to answer a request, default 30s.
+	EnvVarUploadTimeout      = "HALOY_UPLOAD_TIMEOUT" // how long haloy waits for haloyd to take an image upload, default 10m.
+	EnvVarAPIHTTP2           = "HALOY_API_HTTP2"      // "false" keeps haloy's API requests on HTTP/1.1.
+	EnvVarPluginEvent        = "HALOY_PLUGIN_EVENT"   // set for plugins to the lifecycle event they're run for.
+	// API token haloyd uses to answer DNS-01 challenges for 'cdn: cloudflare'
+	// domains. Needs Zone:Read and DNS:Edit permissions.
+	EnvVarCloudflareAPIToken = "HALOY_CLOUDFLARE_API_TOKEN"
+	// Local development mode, see 'haloyd init --dev'.
+	EnvVarPublishPorts   = "HALOY_PUBLISH_PORTS"    // "true" publishes app ports on the loopback interface and routes through them.
+	EnvVarProxyHTTPAddr  = "HALOY_PROXY_HTTP_ADDR"  // haloy-proxy HTTP listen addresses, comma-separated, default ":80" (dual-stack).
+	EnvVarProxyHTTPSAddr = "HALOY_PROXY_HTTPS_ADDR" // haloy-proxy HTTPS listen addresses, comma-separated, default ":443" (dual-stack).
 
 	// Default directories (system-wide installation)
 	SystemDataDir          = "/var/lib/haloy"
 	DefaultHaloydConfigDir = "/etc/haloy"
 	SystemBinDir           = "/usr/local/bin"
 
+	// DevDir holds the data and config directories created by
+	// 'haloyd init --dev', relative to the user's home directory.
+	DevDir = ".haloy-dev"
+	// Ports haloy-proxy listens on in local development mode. 8080 is taken
+	// by the certificate HTTP provider.
+	DevProxyHTTPPort  = "8088"
+	DevProxyHTTPSPort = "8443"
+
 	// Default config directory for haloy CLI
 	DefaultHaloyConfigDir = ".config/haloy"
 
@@ -49,20 +124,70 @@ const (
 	// ProxyDir holds the routing snapshot written by haloyd and the
 	// haloy-proxy control socket.
 	ProxyDir = "proxy"
+	// ErrorPagesDir holds per-app custom error page bundles read by haloy-proxy.
+	ErrorPagesDir = "error-pages"
+	// SnapshotsDir holds scheduled volume snapshots, one directory per app.
+	SnapshotsDir = "snapshots"
+	// GitOpsDir holds haloyd's checkout of the GitOps repository.
+	GitOpsDir = "gitops"
+	// PluginsDir, in the haloy config directory, holds plugin executables
+	// run on every deploy.
+	PluginsDir = "plugins"
 
 	// Files inside ProxyDir
 	ProxySnapshotFileName = "snapshot.json"
 	ProxySocketFileName   = "haloy-proxy.sock"
 
 	// File names
-	HaloydConfigFileName   = "haloyd.yaml"
-	RegistriesFileName     = "registries.yaml"
-	ClientConfigFileName   = "client.yaml"
-	ConfigEnvFileName      = ".env"
-	ConfigEnvLocalFileName = ".env.local"
-	DBFileName             = "haloy.db"
+	HaloydConfigFileName    = "haloyd.yaml"
+	RegistriesFileName      = "registries.yaml"
+	ClientConfigFileName    = "client.yaml"
+	ConfigEnvFileName       = ".env"
+	ConfigEnvLocalFileName  = ".env.local"
+	WorkspaceIgnoreFileName = ".haloyignore"
+	DBFileName              = "haloy.db"
+	// OnDemandDomainsFileName lists, in the data directory, the domains
+	// approved for on-demand TLS.
+	OnDemandDomainsFileName = "on-demand-domains.json"
+	// HaloydStatusFileName is where, in the data directory, haloyd records
+	// its own status for 'haloyd status'.
+	HaloydStatusFileName = "haloyd-status.json"
+	// ServerUpgradeLogFileName is where, in the data directory, the output of
+	// a scheduled server upgrade goes.
+	ServerUpgradeLogFileName = "server-upgrade.log"
+
+	// UpgradeServerScriptURL serves scripts/upgrade-server.sh, which haloyd
+	// runs for scheduled upgrades.
+	UpgradeServerScriptURL = "https://sh.haloy.dev/upgrade-server.sh"
 )
 
+// Capabilities is what haloyd advertises on /v1/version. Deploy config
+// features must be listed here for the CLI to send them, and 'haloy version
+// --check' compares a server's list with the one the CLI was built with.
+var Capabilities = []string{
+	CapabilityLayerUpload,
+	CapabilityImagePreflight,
+	CapabilityLayerResume,
+	CapabilityFeatureNegotiation,
+	CapabilitySidecars,
+	CapabilityHealthCheck,
+	CapabilityRouteLimits,
+	CapabilityPathPrefix,
+	CapabilityErrorPages,
+	CapabilityCDN,
+	CapabilityHealthCheckTypes,
+	CapabilityStartupProbe,
+	CapabilitySecurityOptions,
+	CapabilityRunAsUser,
+	CapabilityContainerLogging,
+	CapabilityHostPorts,
+	CapabilityRedirects,
+	CapabilityURLNormalization,
+	CapabilityClientAuth,
+	CapabilitySSO,
+	CapabilityImageCheck,
+}
+
 // File and directory permissions
 const (
 	ModeFileSecret  os.FileMode = 0o600 // secrets: .env, keys
@@ -0,0 +1,64 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestSchedule_Matches(t *testing.T) {
+	// 2026-03-02 is a Monday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		spec string
+		t    time.Time
+		want bool
+	}{
+		{"0 3 * * *", at(2, 3, 0), true},
+		{"0 3 * * *", at(2, 3, 1), false},
+		{"@daily", at(5, 0, 0), true},
+		{"*/15 * * * *", at(2, 10, 45), true},
+		{"*/15 * * * *", at(2, 10, 46), false},
+		{"30 1-5/2 * * *", at(2, 3, 30), true},
+		{"30 1-5/2 * * *", at(2, 4, 30), false},
+		{"0 0 * * 1-5", at(2, 0, 0), true},
+		{"0 0 * * 1-5", at(1, 0, 0), false},
+		{"0 0 * * 7", at(1, 0, 0), true},
+		// Both day fields restricted: either matches.
+		{"0 0 15 * 1", at(2, 0, 0), true},
+		{"0 0 15 * 1", at(15, 0, 0), true},
+		{"0 0 15 * 1", at(3, 0, 0), false},
+		{"0 12 1,15 3 *", at(15, 12, 0), true},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+		}
+		if got := s.Matches(tt.t); got != tt.want {
+			t.Errorf("Parse(%q).Matches(%s) = %v, want %v", tt.spec, tt.t.Format(time.DateTime), got, tt.want)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	s, _ := Parse("0 3 * * *")
+	from := time.Date(2026, 3, 2, 3, 0, 30, 0, time.UTC)
+	if got, want := s.Next(from), time.Date(2026, 3, 3, 3, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next() = %s, want %s", got, want)
+	}
+
+	never, _ := Parse("0 0 31 2 *")
+	if got := never.Next(from); !got.IsZero() {
+		t.Errorf("Next() of a schedule that never fires = %s, want zero", got)
+	}
+}
@@ -0,0 +1,47 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ParseWindow parses the start of a maintenance window, "[day] HH:MM", such
+// as "Sat 03:00" or "03:00" for every day. Days are English names or their
+// first three letters, in any case.
+func ParseWindow(spec string) (Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Schedule{}, fmt.Errorf("invalid window '%s': want \"[day] HH:MM\"", spec)
+	}
+
+	dow := "*"
+	if len(fields) == 2 {
+		day, ok := weekdays[strings.ToLower(fields[0])]
+		if !ok {
+			return Schedule{}, fmt.Errorf("invalid window '%s': unknown day '%s'", spec, fields[0])
+		}
+		dow = strconv.Itoa(int(day))
+	}
+
+	clock := fields[len(fields)-1]
+	hour, minute, ok := strings.Cut(clock, ":")
+	h, herr := strconv.Atoi(hour)
+	m, merr := strconv.Atoi(minute)
+	if !ok || herr != nil || merr != nil || h < 0 || h > 23 || m < 0 || m > 59 || len(minute) != 2 {
+		return Schedule{}, fmt.Errorf("invalid window '%s': invalid time '%s'", spec, clock)
+	}
+
+	return Parse(fmt.Sprintf("%d %d * * %s", m, h, dow))
+}
@@ -0,0 +1,39 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	// 2026-03-02 is a Monday.
+	after := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"Sat 03:00", time.Date(2026, 3, 7, 3, 0, 0, 0, time.UTC)},
+		{"saturday 3:00", time.Date(2026, 3, 7, 3, 0, 0, 0, time.UTC)},
+		{"MON 13:30", time.Date(2026, 3, 2, 13, 30, 0, 0, time.UTC)},
+		{"Mon 11:00", time.Date(2026, 3, 9, 11, 0, 0, 0, time.UTC)},
+		{"03:00", time.Date(2026, 3, 3, 3, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseWindow(tt.spec)
+		if err != nil {
+			t.Errorf("ParseWindow(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if got := schedule.Next(after); !got.Equal(tt.want) {
+			t.Errorf("ParseWindow(%q).Next() = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseWindow_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "Sat", "Someday 03:00", "Sat 24:00", "Sat 03:60", "Sat 03:0", "Sat 03", "Sat 03:00 UTC"} {
+		if _, err := ParseWindow(spec); err == nil {
+			t.Errorf("ParseWindow(%q) succeeded, want an error", spec)
+		}
+	}
+}
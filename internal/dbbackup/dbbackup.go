@@ -0,0 +1,256 @@
+// Package dbbackup dumps managed Postgres databases to an S3-compatible
+// store and restores them. Dumps run inside the database container with
+// pg_dump, so haloyd needs no Postgres client of its own.
+package dbbackup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/s3"
+)
+
+// ErrNotFound is returned when restoring a backup that doesn't exist.
+var ErrNotFound = errors.New("backup not found")
+
+const timestampFormat = "20060102T150405Z"
+
+// The commands run through sh so the credentials come from the variables
+// the container was started with instead of haloyd's exec arguments.
+var (
+	dumpCmd    = []string{"sh", "-c", `PGPASSWORD="$POSTGRES_PASSWORD" exec pg_dump --format=custom --username="$POSTGRES_USER" --dbname="$POSTGRES_DB"`}
+	verifyCmd  = []string{"pg_restore", "--list"}
+	restoreCmd = []string{"sh", "-c", `PGPASSWORD="$POSTGRES_PASSWORD" exec pg_restore --clean --if-exists --no-owner --exit-on-error --username="$POSTGRES_USER" --dbname="$POSTGRES_DB"`}
+	// Grants name the source database's roles, which a clone doesn't have.
+	cloneCmd     = []string{"sh", "-c", `PGPASSWORD="$POSTGRES_PASSWORD" exec pg_restore --clean --if-exists --no-owner --no-privileges --exit-on-error --username="$POSTGRES_USER" --dbname="$POSTGRES_DB"`}
+	anonymizeCmd = []string{"sh", "-c", `PGPASSWORD="$POSTGRES_PASSWORD" exec psql --no-psqlrc --quiet --set=ON_ERROR_STOP=1 --single-transaction --username="$POSTGRES_USER" --dbname="$POSTGRES_DB"`}
+)
+
+// Backups stores backups under <prefix><app>/<app>-<timestamp>.dump.
+type Backups struct {
+	store     *s3.Client
+	prefix    string
+	retention int
+	now       func() time.Time
+}
+
+func New(cfg config.DatabaseBackupsConfig) (*Backups, error) {
+	if !cfg.IsEnabled() {
+		return nil, errors.New("database backups are not configured; set database_backups in haloyd.yaml")
+	}
+	accessKeyID, secretAccessKey := cfg.S3.GetCredentials()
+	store, err := s3.New(s3.Config{
+		Endpoint:        cfg.S3.Endpoint,
+		Region:          cfg.S3.GetRegion(),
+		Bucket:          cfg.S3.Bucket,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	prefix := strings.Trim(cfg.S3.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &Backups{store: store, prefix: prefix, retention: cfg.GetRetention(), now: time.Now}, nil
+}
+
+// Backup dumps the database in containerID, checks that pg_restore can read
+// the dump, uploads it and drops the backups beyond the retention.
+func (b *Backups) Backup(ctx context.Context, cli *client.Client, appName, containerID string) (apitypes.DatabaseBackup, error) {
+	dump, err := os.CreateTemp("", "haloy-db-*.dump")
+	if err != nil {
+		return apitypes.DatabaseBackup{}, fmt.Errorf("failed to create dump file: %w", err)
+	}
+	defer os.Remove(dump.Name())
+	defer dump.Close()
+
+	if err := run(ctx, cli, containerID, dumpCmd, nil, dump); err != nil {
+		return apitypes.DatabaseBackup{}, fmt.Errorf("pg_dump failed: %w", err)
+	}
+	size, err := dump.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return apitypes.DatabaseBackup{}, fmt.Errorf("failed to read dump size: %w", err)
+	}
+	if size == 0 {
+		return apitypes.DatabaseBackup{}, errors.New("pg_dump produced an empty dump")
+	}
+
+	if _, err := dump.Seek(0, io.SeekStart); err != nil {
+		return apitypes.DatabaseBackup{}, err
+	}
+	if err := run(ctx, cli, containerID, verifyCmd, dump, io.Discard); err != nil {
+		return apitypes.DatabaseBackup{}, fmt.Errorf("backup verification failed: %w", err)
+	}
+
+	createdAt := b.now().UTC().Truncate(time.Second)
+	backup := apitypes.DatabaseBackup{
+		Name:      fmt.Sprintf("%s-%s.dump", appName, createdAt.Format(timestampFormat)),
+		Size:      size,
+		CreatedAt: createdAt,
+	}
+	if _, err := dump.Seek(0, io.SeekStart); err != nil {
+		return apitypes.DatabaseBackup{}, err
+	}
+	if err := b.store.Put(ctx, b.key(appName, backup.Name), dump, size); err != nil {
+		return apitypes.DatabaseBackup{}, fmt.Errorf("failed to upload backup: %w", err)
+	}
+
+	if err := b.prune(ctx, appName); err != nil {
+		return backup, fmt.Errorf("backup %s uploaded, but failed to remove old backups: %w", backup.Name, err)
+	}
+	return backup, nil
+}
+
+// List returns the backups of an app, newest first.
+func (b *Backups) List(ctx context.Context, appName string) ([]apitypes.DatabaseBackup, error) {
+	objects, err := b.store.List(ctx, b.key(appName, ""))
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]apitypes.DatabaseBackup, 0, len(objects))
+	for _, object := range objects {
+		name := object.Key[strings.LastIndex(object.Key, "/")+1:]
+		createdAt, ok := parseBackupName(appName, name)
+		if !ok {
+			continue
+		}
+		backups = append(backups, apitypes.DatabaseBackup{Name: name, Size: object.Size, CreatedAt: createdAt})
+	}
+	slices.SortFunc(backups, func(a, b apitypes.DatabaseBackup) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return backups, nil
+}
+
+// Restore replaces the content of the database in containerID with the
+// backup name.
+func (b *Backups) Restore(ctx context.Context, cli *client.Client, appName, containerID, name string) error {
+	body, err := b.open(ctx, appName, name)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := run(ctx, cli, containerID, restoreCmd, body, io.Discard); err != nil {
+		return fmt.Errorf("pg_restore failed: %w", err)
+	}
+	return nil
+}
+
+// Clone replaces the content of the database in containerID with the backup
+// name of another app's database, then runs the SQL in anonymize, if any,
+// against it in one transaction.
+func (b *Backups) Clone(ctx context.Context, cli *client.Client, sourceApp, containerID, name, anonymize string) error {
+	body, err := b.open(ctx, sourceApp, name)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := run(ctx, cli, containerID, cloneCmd, body, io.Discard); err != nil {
+		return fmt.Errorf("pg_restore failed: %w", err)
+	}
+	if anonymize == "" {
+		return nil
+	}
+	if err := run(ctx, cli, containerID, anonymizeCmd, strings.NewReader(anonymize), io.Discard); err != nil {
+		return fmt.Errorf("anonymization failed: %w", err)
+	}
+	return nil
+}
+
+func (b *Backups) open(ctx context.Context, appName, name string) (io.ReadCloser, error) {
+	if _, ok := parseBackupName(appName, name); !ok {
+		return nil, fmt.Errorf("'%s' is not a backup of %s", name, appName)
+	}
+	body, err := b.store.Get(ctx, b.key(appName, name))
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return body, err
+}
+
+func (b *Backups) prune(ctx context.Context, appName string) error {
+	backups, err := b.List(ctx, appName)
+	if err != nil {
+		return err
+	}
+	for _, backup := range expired(backups, b.retention) {
+		if err := b.store.Delete(ctx, b.key(appName, backup.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expired returns the backups beyond the newest retention ones. backups is
+// sorted newest first.
+func expired(backups []apitypes.DatabaseBackup, retention int) []apitypes.DatabaseBackup {
+	if len(backups) <= retention {
+		return nil
+	}
+	return backups[retention:]
+}
+
+func (b *Backups) key(appName, name string) string {
+	return b.prefix + appName + "/" + name
+}
+
+var backupNamePattern = regexp.MustCompile(`^(.+)-(\d{8}T\d{6}Z)\.dump$`)
+
+// parseBackupName returns the time a backup of appName named name was made,
+// and false if name isn't one.
+func parseBackupName(appName, name string) (time.Time, bool) {
+	m := backupNamePattern.FindStringSubmatch(name)
+	if m == nil || m[1] != appName {
+		return time.Time{}, false
+	}
+	createdAt, err := time.Parse(timestampFormat, m[2])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
+
+// Containers returns the running managed database containers by app name.
+func Containers(ctx context.Context, cli *client.Client) (map[string]string, error) {
+	containerList, err := cli.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", config.LabelDatabase)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list database containers: %w", err)
+	}
+	containers := make(map[string]string, len(containerList))
+	for _, c := range containerList {
+		if appName := c.Labels[config.LabelAppName]; appName != "" {
+			containers[appName] = c.ID
+		}
+	}
+	return containers, nil
+}
+
+func run(ctx context.Context, cli *client.Client, containerID string, cmd []string, stdin io.Reader, stdout io.Writer) error {
+	stderr, exitCode, err := docker.ExecStreamInContainer(ctx, cli, containerID, cmd, stdin, stdout)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("exit code %d: %s", exitCode, strings.TrimSpace(stderr))
+	}
+	return nil
+}
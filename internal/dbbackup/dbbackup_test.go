@@ -0,0 +1,40 @@
+package dbbackup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+)
+
+func TestParseBackupName(t *testing.T) {
+	tests := []struct {
+		appName string
+		name    string
+		want    time.Time
+		ok      bool
+	}{
+		{"shop-db", "shop-db-20260102T030405Z.dump", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), true},
+		{"shop", "shop-db-20260102T030405Z.dump", time.Time{}, false},
+		{"shop-db", "../other/shop-db-20260102T030405Z.dump", time.Time{}, false},
+		{"shop-db", "shop-db-latest.dump", time.Time{}, false},
+		{"shop-db", "shop-db-20261399T999999Z.dump", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseBackupName(tt.appName, tt.name)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("parseBackupName(%q, %q) = %v, %v, want %v, %v", tt.appName, tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExpired(t *testing.T) {
+	backups := []apitypes.DatabaseBackup{{Name: "c"}, {Name: "b"}, {Name: "a"}}
+	if got := expired(backups, 3); len(got) != 0 {
+		t.Errorf("expired(retention 3) = %v, want none", got)
+	}
+	got := expired(backups, 1)
+	if len(got) != 2 || got[0].Name != "b" || got[1].Name != "a" {
+		t.Errorf("expired(retention 1) = %v, want b and a", got)
+	}
+}
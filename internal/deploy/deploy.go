@@ -10,14 +10,54 @@ import (
 
 	"github.com/docker/docker/client"
 	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/deploystate"
 	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/layerstore"
+	"github.com/haloydev/haloy/internal/logging"
 	"github.com/haloydev/haloy/internal/storage"
 )
 
-func DeployApp(ctx context.Context, cli *client.Client, db *storage.DB, deploymentID string, targetConfig config.TargetConfig, rawDeployConfig config.DeployConfig, logger *slog.Logger) error {
+// pullThroughCache returns the image cache configured for this server, or nil
+// when the cache is disabled or cannot be opened.
+func pullThroughCache(cli *client.Client, db *storage.DB, logger *slog.Logger) docker.ImageCache {
+	haloydConfig, err := config.LoadDefaultHaloydConfig()
+	if err != nil {
+		logger.Debug("Failed to load haloyd config, image cache disabled", "error", err)
+		return nil
+	}
+	if !haloydConfig.ImageCache.IsEnabled() {
+		return nil
+	}
+	store, err := layerstore.New(db)
+	if err != nil {
+		logger.Warn("Failed to open layer store, image cache disabled", "error", err)
+		return nil
+	}
+	return layerstore.NewPullThroughCache(store, cli)
+}
+
+func DeployApp(ctx context.Context, cli *client.Client, db *storage.DB, deploymentID string, targetConfig config.TargetConfig, rawDeployConfig config.DeployConfig, logger *slog.Logger) (err error) {
+	// haloyd takes the deployment from starting to live once Docker reports
+	// its containers, so only the steps up to that point are recorded here.
+	states := deploystate.New(db)
+	transition := func(to deploystate.State, message string) {
+		if err := states.Transition(deploymentID, targetConfig.Name, to, message); err != nil {
+			logger.Warn("Failed to record deployment state", "state", to, "error", err)
+		}
+	}
+	transition(deploystate.Pending, "")
+	phase := logging.PhasePull
+	defer func() {
+		if err != nil {
+			transition(deploystate.Failed, err.Error())
+			err = &logging.PhaseError{Phase: phase, Err: err}
+		}
+	}()
+
 	imageRef := targetConfig.Image.ImageRef()
+	logging.LogDeploymentPhase(logger, deploymentID, targetConfig.Name, phase, fmt.Sprintf("Preparing image %s", imageRef))
 
-	err := docker.EnsureImageUpToDate(ctx, cli, logger, *targetConfig.Image)
+	err = docker.EnsureImageUpToDateWithCache(ctx, cli, logger, *targetConfig.Image, pullThroughCache(cli, db, logger))
 	if err != nil {
 		return err
 	}
@@ -34,6 +74,10 @@ func DeployApp(ctx context.Context, cli *client.Client, db *storage.DB, deployme
 		newImageRef = dstRef
 	}
 
+	if err := docker.CheckHostPorts(ctx, cli, targetConfig.Name, targetConfig.PortMappings()); err != nil {
+		return err
+	}
+
 	if targetConfig.DeploymentStrategy == config.DeploymentStrategyReplace {
 		_, err := docker.StopContainers(ctx, cli, logger, targetConfig.Name, "")
 		if err != nil {
@@ -46,11 +90,31 @@ func DeployApp(ctx context.Context, cli *client.Client, db *storage.DB, deployme
 	}
 
 	if len(targetConfig.Volumes) > 0 {
+		warnVolumeOwnership(ctx, cli, targetConfig, logger)
 		if err := docker.EnsureVolumes(ctx, cli, logger, targetConfig.Name, targetConfig.Volumes); err != nil {
 			return fmt.Errorf("failed to ensure volumes: %w", err)
 		}
 	}
 
+	for _, sidecar := range targetConfig.Sidecars {
+		if err := docker.EnsureImageUpToDate(ctx, cli, logger, *sidecar.Image); err != nil {
+			return fmt.Errorf("failed to prepare image for sidecar '%s': %w", sidecar.Name, err)
+		}
+		if len(sidecar.Volumes) > 0 {
+			if err := docker.EnsureVolumes(ctx, cli, logger, targetConfig.Name, sidecar.Volumes); err != nil {
+				return fmt.Errorf("failed to ensure volumes for sidecar '%s': %w", sidecar.Name, err)
+			}
+		}
+	}
+
+	// A new deployment brings a paused app back.
+	if err := db.ResumeApp(targetConfig.Name); err != nil {
+		logger.Warn("Failed to unpause app", "error", err)
+	}
+
+	transition(deploystate.Starting, "")
+	phase = logging.PhaseStart
+	logging.LogDeploymentPhase(logger, deploymentID, targetConfig.Name, phase, fmt.Sprintf("Starting containers for %s", targetConfig.Name))
 	runResult, err := docker.RunContainer(ctx, cli, deploymentID, newImageRef, targetConfig)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
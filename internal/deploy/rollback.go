@@ -5,12 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"sort"
 
 	"github.com/docker/docker/client"
 	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/deploytypes"
 	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/helpers"
 	"github.com/haloydev/haloy/internal/storage"
 )
 
@@ -149,9 +149,5 @@ func getRunningDeploymentID(ctx context.Context, cli *client.Client, appName str
 		return "", fmt.Errorf("no deployment IDs found in running containers for app %s", appName)
 	}
 
-	sort.Slice(deploymentIDs, func(i, j int) bool {
-		return deploymentIDs[i] > deploymentIDs[j]
-	})
-
-	return deploymentIDs[0], nil
+	return helpers.LatestDeploymentID(deploymentIDs...), nil
 }
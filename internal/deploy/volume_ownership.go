@@ -0,0 +1,84 @@
+package deploy
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/config"
+)
+
+// warnVolumeOwnership warns about volumes the target's user can't write to,
+// a common cause of apps failing once they stop running as root. Only
+// numeric users can be checked, and named volumes that don't exist yet are
+// skipped: Docker copies the ownership from the image when it first mounts
+// them.
+func warnVolumeOwnership(ctx context.Context, cli *client.Client, targetConfig config.TargetConfig, logger *slog.Logger) {
+	uid, gid, ok := config.NumericUser(targetConfig.User)
+	if !ok || uid == 0 {
+		return
+	}
+	groups := []int{gid}
+	for _, group := range targetConfig.GroupAdd {
+		if id, err := strconv.Atoi(group); err == nil {
+			groups = append(groups, id)
+		}
+	}
+
+	for _, raw := range targetConfig.Volumes {
+		spec, err := config.ParseVolumeSpec(raw)
+		if err != nil || isReadOnlyVolume(spec.Options) {
+			continue
+		}
+		hostPath := spec.Source
+		if spec.IsNamedVolume() {
+			vol, err := cli.VolumeInspect(ctx, spec.Source)
+			if err != nil {
+				continue
+			}
+			hostPath = vol.Mountpoint
+		}
+
+		// Rootless runtimes keep volumes where haloyd may not look.
+		info, err := os.Stat(hostPath)
+		if err != nil {
+			logger.Debug("Skipped volume ownership check", "volume", raw, "error", err)
+			continue
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok || writableBy(uid, groups, stat.Uid, stat.Gid, info.Mode()) {
+			continue
+		}
+		logger.Warn("Volume isn't writable by the app's user; change its owner, e.g. with chown on the server, if the app writes to it",
+			"volume", raw, "user", targetConfig.User, "owner", strconv.Itoa(int(stat.Uid))+":"+strconv.Itoa(int(stat.Gid)))
+	}
+}
+
+// writableBy reports whether a file with the given owner and mode can be
+// written by uid with the given groups.
+func writableBy(uid int, groups []int, fileUID, fileGID uint32, mode fs.FileMode) bool {
+	perm := mode.Perm()
+	switch {
+	case int(fileUID) == uid:
+		return perm&0o200 != 0
+	case slices.Contains(groups, int(fileGID)):
+		return perm&0o020 != 0
+	default:
+		return perm&0o002 != 0
+	}
+}
+
+func isReadOnlyVolume(options string) bool {
+	for option := range strings.SplitSeq(options, ",") {
+		if option == "ro" || option == "readonly" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package deploy
+
+import (
+	"io/fs"
+	"testing"
+)
+
+func TestWritableBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		fileUID uint32
+		fileGID uint32
+		mode    fs.FileMode
+		want    bool
+	}{
+		{name: "owned by user", fileUID: 1000, fileGID: 1000, mode: 0o755, want: true},
+		{name: "owned by root", fileUID: 0, fileGID: 0, mode: 0o755, want: false},
+		{name: "group writable", fileUID: 0, fileGID: 1000, mode: 0o775, want: true},
+		{name: "supplementary group writable", fileUID: 0, fileGID: 2000, mode: 0o770, want: true},
+		{name: "group read-only", fileUID: 0, fileGID: 1000, mode: 0o755, want: false},
+		{name: "world writable", fileUID: 0, fileGID: 0, mode: 0o777, want: true},
+		{name: "owner read-only", fileUID: 1000, fileGID: 0, mode: 0o557, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := writableBy(1000, []int{1000, 2000}, tt.fileUID, tt.fileGID, tt.mode); got != tt.want {
+				t.Errorf("writableBy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+// Package deploystate tracks the lifecycle of a deployment as a sequence of
+// state transitions persisted in storage. A deployment moves
+//
+//	pending → starting → healthy → live → superseded
+//
+// and can fail at any point before it goes live. Every transition is stored
+// as an event, so the current state of a deployment is its latest event and
+// its history survives haloyd restarts.
+package deploystate
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+type State string
+
+const (
+	// Pending: the deployment was accepted and its image is being prepared.
+	Pending State = "pending"
+	// Starting: the containers are being created and started.
+	Starting State = "starting"
+	// Healthy: the containers passed discovery and health checks.
+	Healthy State = "healthy"
+	// Live: the proxy routes traffic to the deployment.
+	Live State = "live"
+	// Superseded: a newer deployment of the app went live.
+	Superseded State = "superseded"
+	// Failed: the deployment never went live.
+	Failed State = "failed"
+)
+
+var transitions = map[State][]State{
+	Pending:  {Starting, Failed},
+	Starting: {Healthy, Failed},
+	Healthy:  {Live, Failed},
+	Live:     {Superseded},
+}
+
+// CanTransition reports whether a deployment in state from may move to
+// state to. A deployment without history may enter any state, which covers
+// deployments started before haloyd tracked them.
+func CanTransition(from, to State) bool {
+	if from == "" {
+		return true
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Terminal reports whether no transition leaves s.
+func (s State) Terminal() bool {
+	return s == Superseded || s == Failed
+}
+
+// Event is a transition of a deployment into State.
+type Event struct {
+	State   State     `json:"state"`
+	Message string    `json:"message,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// Machine records deployment transitions in storage, rejecting those the
+// state machine doesn't allow.
+type Machine struct {
+	db  *storage.DB
+	now func() time.Time
+}
+
+// mu serializes the transitions of every Machine, as the API and the
+// deployment flow each have one over the same database, and a transition
+// checked against a state another one just left would slip through.
+var mu sync.Mutex
+
+func New(db *storage.DB) *Machine {
+	return &Machine{db: db, now: time.Now}
+}
+
+// Transition moves a deployment to state to. Moving a deployment to the
+// state it is already in is a no-op, so callers reacting to repeated Docker
+// events don't have to deduplicate them.
+func (m *Machine) Transition(deploymentID, appName string, to State, message string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return m.transition(deploymentID, appName, to, message)
+}
+
+func (m *Machine) transition(deploymentID, appName string, to State, message string) error {
+	from, err := m.current(deploymentID)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return nil
+	}
+	if !CanTransition(from, to) {
+		return fmt.Errorf("deployment %s cannot move from %s to %s", deploymentID, from, to)
+	}
+	return m.db.AppendDeploymentEvent(storage.DeploymentEvent{
+		DeploymentID: deploymentID,
+		AppName:      appName,
+		State:        string(to),
+		Message:      message,
+		CreatedAt:    m.now(),
+	})
+}
+
+// Current returns the state of a deployment, or "" if it has no history.
+func (m *Machine) Current(deploymentID string) (State, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return m.current(deploymentID)
+}
+
+func (m *Machine) current(deploymentID string) (State, error) {
+	events, err := m.db.GetDeploymentEvents(deploymentID)
+	if err != nil {
+		return "", err
+	}
+	if len(events) == 0 {
+		return "", nil
+	}
+	return State(events[len(events)-1].State), nil
+}
+
+// History returns the transitions of a deployment, oldest first.
+func (m *Machine) History(deploymentID string) ([]Event, error) {
+	events, err := m.db.GetDeploymentEvents(deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]Event, 0, len(events))
+	for _, e := range events {
+		history = append(history, Event{State: State(e.State), Message: e.Message, At: e.CreatedAt})
+	}
+	return history, nil
+}
+
+// GoLive moves a healthy deployment live and supersedes the deployments of
+// the app that were live before it.
+func (m *Machine) GoLive(deploymentID, appName string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return m.goLive(deploymentID, appName)
+}
+
+func (m *Machine) goLive(deploymentID, appName string) error {
+	if err := m.transition(deploymentID, appName, Live, ""); err != nil {
+		return err
+	}
+	previous, err := m.db.ListDeploymentsInState(appName, string(Live))
+	if err != nil {
+		return err
+	}
+	for _, id := range previous {
+		if id == deploymentID {
+			continue
+		}
+		if err := m.transition(id, appName, Superseded, "replaced by "+deploymentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Promote moves a deployment whose containers passed their health checks
+// through healthy to live, before anything routes it. A live deployment
+// stays live. One that failed meanwhile, like one canceled while its health
+// checks ran, can't be promoted and must not be routed.
+func (m *Machine) Promote(deploymentID, appName string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	from, err := m.current(deploymentID)
+	if err != nil {
+		return err
+	}
+	if from == Live {
+		return nil
+	}
+	if err := m.transition(deploymentID, appName, Healthy, ""); err != nil {
+		return err
+	}
+	return m.goLive(deploymentID, appName)
+}
+
+// Routable reports whether the healthy containers of a deployment may get
+// traffic from an update the deployment didn't trigger: once it went live,
+// or when haloyd never tracked it. A deployment on its way is routed only
+// by promoting it, and one that failed never.
+func (m *Machine) Routable(deploymentID string) (bool, error) {
+	state, err := m.Current(deploymentID)
+	if err != nil {
+		return false, err
+	}
+	switch state {
+	case "", Live, Superseded:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// Prune removes the history of deployments that ended before cutoff, and
+// returns how many events it removed. The specs of deployments that ended
+// are removed whenever they did, as only live ones are recreated. Recorded
+// logs go with the history they belong to.
+func (m *Machine) Prune(cutoff time.Time) (int64, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, err := m.db.PruneDeploymentSpecs(string(Superseded), string(Failed)); err != nil {
+		return 0, err
+	}
+	pruned, err := m.db.PruneDeploymentEvents(cutoff, string(Superseded), string(Failed))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := m.db.PruneDeploymentLogs(cutoff); err != nil {
+		return pruned, err
+	}
+	return pruned, nil
+}
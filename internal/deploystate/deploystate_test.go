@@ -0,0 +1,168 @@
+package deploystate
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/storage"
+	_ "modernc.org/sqlite"
+)
+
+func newTestMachine(t *testing.T) *Machine {
+	t.Helper()
+
+	rawDB, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	// Every connection to :memory: is a separate database.
+	rawDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { rawDB.Close() })
+
+	db := &storage.DB{DB: rawDB}
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	return New(db)
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to State
+		want     bool
+	}{
+		{"", Live, true},
+		{Pending, Starting, true},
+		{Pending, Failed, true},
+		{Pending, Live, false},
+		{Starting, Healthy, true},
+		{Healthy, Live, true},
+		{Live, Superseded, true},
+		{Live, Failed, false},
+		{Superseded, Live, false},
+		{Failed, Starting, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestMachine_Lifecycle(t *testing.T) {
+	m := newTestMachine(t)
+
+	for _, state := range []State{Pending, Starting, Healthy} {
+		if err := m.Transition("d1", "app", state, ""); err != nil {
+			t.Fatalf("Transition(%s) error = %v", state, err)
+		}
+	}
+	// Repeated Docker events report the same state again.
+	if err := m.Transition("d1", "app", Healthy, ""); err != nil {
+		t.Fatalf("repeated Transition(healthy) error = %v", err)
+	}
+	if err := m.GoLive("d1", "app"); err != nil {
+		t.Fatalf("GoLive(d1) error = %v", err)
+	}
+
+	if err := m.Transition("d2", "app", Pending, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Transition("d2", "app", Live, ""); err == nil {
+		t.Fatal("Transition(pending → live) succeeded, want error")
+	}
+	for _, state := range []State{Starting, Healthy} {
+		if err := m.Transition("d2", "app", state, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.GoLive("d2", "app"); err != nil {
+		t.Fatalf("GoLive(d2) error = %v", err)
+	}
+
+	for id, want := range map[string]State{"d1": Superseded, "d2": Live} {
+		got, err := m.Current(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Current(%s) = %q, want %q", id, got, want)
+		}
+	}
+
+	history, err := m.History("d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 5 {
+		t.Fatalf("History(d1) has %d events, want 5: %+v", len(history), history)
+	}
+	if last := history[4]; last.Message != "replaced by d2" {
+		t.Errorf("superseded message = %q, want %q", last.Message, "replaced by d2")
+	}
+}
+
+func TestMachine_Prune(t *testing.T) {
+	m := newTestMachine(t)
+	m.now = func() time.Time { return time.Now().Add(-48 * time.Hour) }
+
+	if err := m.Transition("old-failed", "app", Failed, "boom"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Transition("old-live", "app", Live, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := m.Prune(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("Prune() = %d, want 1", pruned)
+	}
+	if state, _ := m.Current("old-live"); state != Live {
+		t.Errorf("live deployment pruned, state = %q", state)
+	}
+}
+
+func TestMachine_Promote(t *testing.T) {
+	m := newTestMachine(t)
+
+	for _, state := range []State{Pending, Starting} {
+		if err := m.Transition("d1", "app", state, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if routable, err := m.Routable("d1"); err != nil || routable {
+		t.Fatalf("Routable(starting) = %v, %v, want false", routable, err)
+	}
+	if err := m.Promote("d1", "app"); err != nil {
+		t.Fatalf("Promote(d1) error = %v", err)
+	}
+	// A replica restarting promotes the live deployment again.
+	if err := m.Promote("d1", "app"); err != nil {
+		t.Fatalf("repeated Promote(d1) error = %v", err)
+	}
+	if routable, err := m.Routable("d1"); err != nil || !routable {
+		t.Fatalf("Routable(live) = %v, %v, want true", routable, err)
+	}
+
+	for _, state := range []State{Pending, Starting, Failed} {
+		if err := m.Transition("d2", "app", state, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Promote("d2", "app"); err == nil {
+		t.Fatal("Promote(failed) succeeded, want error")
+	}
+	if routable, err := m.Routable("d2"); err != nil || routable {
+		t.Fatalf("Routable(failed) = %v, %v, want false", routable, err)
+	}
+	if state, _ := m.Current("d1"); state != Live {
+		t.Errorf("failed deployment superseded d1, state = %q", state)
+	}
+	if routable, err := m.Routable("untracked"); err != nil || !routable {
+		t.Fatalf("Routable(untracked) = %v, %v, want true", routable, err)
+	}
+}
@@ -4,19 +4,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/docker/docker/client"
 )
 
+// NewClient connects to the container runtime: the daemon in DOCKER_HOST,
+// or the first socket found of rootful Docker, rootless Docker and Podman.
 func NewClient(ctx context.Context) (*client.Client, error) {
 	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	cli, err := client.NewClientWithOpts(
+	opts := []client.Opt{
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
-	)
+	}
+	if host := defaultHost(os.Getenv, os.Getuid(), socketExists); host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %w", err)
 	}
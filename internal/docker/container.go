@@ -12,6 +12,7 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
 	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/healthcheck"
@@ -30,15 +31,13 @@ func RunContainer(ctx context.Context, cli *client.Client, deploymentID, imageRe
 	if err := checkImagePlatformCompatibility(ctx, cli, imageRef); err != nil {
 		return result, err
 	}
-	cl := config.ContainerLabels{
-		AppName:         targetConfig.Name,
-		DeploymentID:    deploymentID,
-		Port:            targetConfig.Port,
-		HealthCheckPath: targetConfig.HealthCheckPath,
-		MinReadySeconds: *targetConfig.MinReadySeconds,
-		Domains:         targetConfig.Domains,
-	}
+	cl := config.NewContainerLabels(targetConfig, deploymentID)
 	labels := cl.ToLabels()
+	for key, value := range targetConfig.Labels {
+		if _, managed := labels[key]; !managed {
+			labels[key] = value
+		}
+	}
 
 	var envVars []string
 
@@ -54,14 +53,28 @@ func RunContainer(ctx context.Context, cli *client.Client, deploymentID, imageRe
 		NetworkMode:   network,
 		RestartPolicy: container.RestartPolicy{Name: "unless-stopped"},
 		Binds:         targetConfig.Volumes,
+		GroupAdd:      targetConfig.GroupAdd,
+		LogConfig:     logConfig(targetConfig.LoggingDriver),
+	}
+	applySecurityOptions(hostConfig, targetConfig.Security)
+	var exposedPorts nat.PortSet
+	if config.PublishContainerPorts() {
+		// Docker picks a free host port for each replica.
+		port := nat.Port(targetConfig.Port.String() + "/tcp")
+		exposedPorts = nat.PortSet{port: struct{}{}}
+		hostConfig.PortBindings = nat.PortMap{port: {{HostIP: publishedHostIP}}}
 	}
+	exposedPorts = publishPorts(exposedPorts, hostConfig, targetConfig.PortMappings())
 
 	for i := range make([]struct{}, *targetConfig.Replicas) {
 		envVars := append(envVars, fmt.Sprintf("%s=%d", constants.EnvVarReplicaID, i+1))
 		containerConfig := &container.Config{
-			Image:  imageRef,
-			Labels: labels,
-			Env:    envVars,
+			Image:        imageRef,
+			Labels:       labels,
+			Env:          envVars,
+			Healthcheck:  healthConfig(targetConfig.HealthCheck),
+			ExposedPorts: exposedPorts,
+			User:         targetConfig.User,
 		}
 
 		var containerName string
@@ -89,10 +102,23 @@ func RunContainer(ctx context.Context, cli *client.Client, deploymentID, imageRe
 			}
 		}(createResponse.ID)
 
-		if err := cli.ContainerStart(ctx, createResponse.ID, container.StartOptions{}); err != nil {
+		appSidecarIDs, err := startSidecars(ctx, cli, targetConfig, deploymentID, createResponse.ID, containerName, i+1, config.SidecarNetworkApp)
+		if err != nil {
+			removeSidecarContainers(ctx, cli, appSidecarIDs)
+			return result, err
+		}
+
+		if err = cli.ContainerStart(ctx, createResponse.ID, container.StartOptions{}); err != nil {
+			removeSidecarContainers(ctx, cli, appSidecarIDs)
 			return result, fmt.Errorf("failed to start container: %w", err)
 		}
 
+		sharedSidecarIDs, err := startSidecars(ctx, cli, targetConfig, deploymentID, createResponse.ID, containerName, i+1, config.SidecarNetworkShared)
+		if err != nil {
+			removeSidecarContainers(ctx, cli, append(appSidecarIDs, sharedSidecarIDs...))
+			return result, err
+		}
+
 		result = append(result, ContainerRunResult{
 			ID:           createResponse.ID,
 			DeploymentID: deploymentID,
@@ -104,8 +130,31 @@ func RunContainer(ctx context.Context, cli *client.Client, deploymentID, imageRe
 	return result, nil
 }
 
+// logConfig returns the Docker log config for a logging driver, or the
+// daemon's default when driver is nil.
+func logConfig(driver *config.LoggingDriver) container.LogConfig {
+	if driver == nil {
+		return container.LogConfig{}
+	}
+	return container.LogConfig{Type: driver.Name, Config: driver.Options}
+}
+
+// applySecurityOptions translates the target's security options to the host
+// config of its app containers.
+func applySecurityOptions(hostConfig *container.HostConfig, security *config.SecurityOptions) {
+	if security == nil {
+		return
+	}
+	hostConfig.ReadonlyRootfs = security.ReadOnlyRootfs
+	if security.NoNewPrivileges {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "no-new-privileges:true")
+	}
+	hostConfig.CapDrop, hostConfig.CapAdd = security.Capabilities()
+	hostConfig.Tmpfs = security.TmpfsMounts()
+}
+
 func StopContainers(ctx context.Context, cli *client.Client, logger *slog.Logger, appName, ignoreDeploymentID string) (stoppedIDs []string, err error) {
-	containerList, err := GetAppContainers(ctx, cli, true, appName)
+	containerList, err := getAppAndSidecarContainers(ctx, cli, appName)
 	if err != nil {
 		return stoppedIDs, err
 	}
@@ -122,7 +171,7 @@ func StopContainers(ctx context.Context, cli *client.Client, logger *slog.Logger
 }
 
 func StopContainersByDeploymentID(ctx context.Context, cli *client.Client, logger *slog.Logger, appName, deploymentID string) (stoppedIDs []string, err error) {
-	containerList, err := GetAppContainers(ctx, cli, true, appName)
+	containerList, err := getAppAndSidecarContainers(ctx, cli, appName)
 	if err != nil {
 		return stoppedIDs, err
 	}
@@ -137,6 +186,50 @@ func StopContainersByDeploymentID(ctx context.Context, cli *client.Client, logge
 	return stopContainerList(ctx, cli, logger, containersToStop)
 }
 
+// LatestDeploymentID returns the newest deployment among an app's
+// containers, running or not, or "" if the app has none.
+func LatestDeploymentID(ctx context.Context, cli *client.Client, appName string) (string, error) {
+	containerList, err := GetAppContainers(ctx, cli, true, appName)
+	if err != nil {
+		return "", err
+	}
+	ids := make([]string, 0, len(containerList))
+	for _, containerInfo := range containerList {
+		ids = append(ids, containerInfo.Labels[config.LabelDeploymentID])
+	}
+	return helpers.LatestDeploymentID(ids...), nil
+}
+
+// StartContainersByDeploymentID starts the stopped containers of an app's
+// deployment, sidecars first so the app finds them running.
+func StartContainersByDeploymentID(ctx context.Context, cli *client.Client, logger *slog.Logger, appName, deploymentID string) (startedIDs []string, err error) {
+	containerList, err := getAppAndSidecarContainers(ctx, cli, appName)
+	if err != nil {
+		return startedIDs, err
+	}
+
+	var sidecars, apps []container.Summary
+	for _, containerInfo := range containerList {
+		if containerInfo.Labels[config.LabelDeploymentID] != deploymentID || containerInfo.State == "running" {
+			continue
+		}
+		if containerInfo.Labels[config.LabelSidecarOf] != "" {
+			sidecars = append(sidecars, containerInfo)
+		} else {
+			apps = append(apps, containerInfo)
+		}
+	}
+
+	for _, containerInfo := range append(sidecars, apps...) {
+		if err := cli.ContainerStart(ctx, containerInfo.ID, container.StartOptions{}); err != nil {
+			return startedIDs, fmt.Errorf("failed to start container %s: %w", helpers.SafeIDPrefix(containerInfo.ID), err)
+		}
+		logger.Debug("Started container", "container_id", helpers.SafeIDPrefix(containerInfo.ID))
+		startedIDs = append(startedIDs, containerInfo.ID)
+	}
+	return startedIDs, nil
+}
+
 func stopContainerList(ctx context.Context, cli *client.Client, logger *slog.Logger, containersToStop []container.Summary) (stoppedIDs []string, err error) {
 	if len(containersToStop) == 0 {
 		return stoppedIDs, nil
@@ -248,12 +341,15 @@ type RemoveContainersResult struct {
 
 // HealthCheckResult contains the result of a container health check.
 type HealthCheckResult struct {
-	IP  string // Container IP address on the haloy network (only set on success)
-	Err error  // nil if healthy
+	IP string // Container IP address on the haloy network, or the loopback IP when ports are published (only set on success)
+	// Port is the published host port when ports are published, empty when
+	// the container is reached on its own port.
+	Port string
+	Err  error // nil if healthy
 }
 
 func RemoveContainers(ctx context.Context, cli *client.Client, logger *slog.Logger, appName, ignoreDeploymentID string) (removedIDs []string, err error) {
-	containerList, err := GetAppContainers(ctx, cli, true, appName)
+	containerList, err := getAppAndSidecarContainers(ctx, cli, appName)
 	if err != nil {
 		return removedIDs, err
 	}
@@ -270,7 +366,7 @@ func RemoveContainers(ctx context.Context, cli *client.Client, logger *slog.Logg
 }
 
 func RemoveContainersByDeploymentID(ctx context.Context, cli *client.Client, logger *slog.Logger, appName, deploymentID string) (removedIDs []string, err error) {
-	containerList, err := GetAppContainers(ctx, cli, true, appName)
+	containerList, err := getAppAndSidecarContainers(ctx, cli, appName)
 	if err != nil {
 		return removedIDs, err
 	}
@@ -305,7 +401,7 @@ func removeContainerList(ctx context.Context, cli *client.Client, logger *slog.L
 // HealthCheckContainer performs health checks on a container and returns its IP address on success.
 // It accepts a pre-fetched container.InspectResponse but will re-inspect if needed.
 // The function checks:
-// 1. Container is running (and stable, not in restart loop)
+// 1. Container is running (and stable, not in restart loop), as are its health-gated sidecars
 // 2. Docker health status (if configured)
 // 3. HTTP health check endpoint (if no Docker healthcheck)
 // 4. Container has a valid IP on the haloy network
@@ -333,8 +429,12 @@ func HealthCheckContainer(ctx context.Context, cli *client.Client, logger *slog.
 		return HealthCheckResult{Err: fmt.Errorf("container is not running (status: %s, exit code: %d)", containerInfo.State.Status, exitCode)}
 	}
 
+	if err := checkSidecarHealthGate(ctx, cli, containerID); err != nil {
+		return HealthCheckResult{Err: err}
+	}
+
 	// Get the container's IP address early - we need it for health checks and as the result
-	targetIP, err := ContainerNetworkIP(containerInfo, constants.DockerNetwork)
+	targetIP, publishedPort, err := ContainerAddress(containerInfo)
 	if err != nil {
 		return HealthCheckResult{Err: fmt.Errorf("failed to get container IP address: %w", err)}
 	}
@@ -346,11 +446,15 @@ func HealthCheckContainer(ctx context.Context, cli *client.Client, logger *slog.
 			if err := waitMinReadySeconds(ctx, cli, logger, containerID, containerInfo); err != nil {
 				return HealthCheckResult{Err: err}
 			}
-			return HealthCheckResult{IP: targetIP}
+			return HealthCheckResult{IP: targetIP, Port: publishedPort}
 		}
 
 		if containerInfo.State.Health.Status == "starting" {
-			healthCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			startingTimeout := healthStartingTimeout(containerInfo.Config)
+			if labels, err := config.ParseContainerLabels(containerInfo.Config.Labels); err == nil {
+				startingTimeout = max(startingTimeout, labels.StartupTimeout)
+			}
+			healthCtx, cancel := context.WithTimeout(ctx, startingTimeout)
 			defer cancel()
 
 			var latestInfo container.InspectResponse
@@ -379,7 +483,7 @@ func HealthCheckContainer(ctx context.Context, cli *client.Client, logger *slog.
 			if err := waitMinReadySeconds(ctx, cli, logger, containerID, containerInfo); err != nil {
 				return HealthCheckResult{Err: err}
 			}
-			return HealthCheckResult{IP: targetIP}
+			return HealthCheckResult{IP: targetIP, Port: publishedPort}
 		case "starting":
 			logger.Info("Container is still starting, falling back to manual health check", "container_id", helpers.SafeIDPrefix(containerID))
 		case "unhealthy":
@@ -399,41 +503,134 @@ func HealthCheckContainer(ctx context.Context, cli *client.Client, logger *slog.
 		return HealthCheckResult{Err: fmt.Errorf("failed to parse container labels: %w", err)}
 	}
 
+	// Use the unified healthcheck package, checking the way the target asks for
+	target, err := healthCheckTarget(containerID, labels, targetIP, publishedPort)
+	if err != nil {
+		return HealthCheckResult{Err: err}
+	}
+
+	checker := healthcheck.NewChecker(5*time.Second, HealthCheckExec(cli))
+	var result healthcheck.Result
+	if deadline, ok := startupDeadline(labels, containerInfo); ok {
+		logger.Info("Waiting for app to start",
+			"container_id", helpers.SafeIDPrefix(containerID),
+			"timeout", time.Until(deadline).Round(time.Second))
+		result = checker.CheckUntil(ctx, target, deadline, labels.StartupPeriod, func(attempt int, remaining time.Duration) {
+			logger.Debug("App not ready yet, checking again...",
+				"attempt", attempt+1,
+				"remaining", remaining.Round(time.Second))
+		})
+	} else {
+		retryConfig := healthcheck.DefaultRetryConfig()
+		result = checker.CheckWithRetry(ctx, target, retryConfig, func(attempt int, backoff time.Duration) {
+			logger.Info("Retrying health check...",
+				"backoff", backoff,
+				"attempt", attempt+1,
+				"max_retries", retryConfig.MaxRetries+1)
+		})
+	}
+
+	if result.Healthy {
+		if err := waitMinReadySeconds(ctx, cli, logger, containerID, containerInfo); err != nil {
+			return HealthCheckResult{Err: err}
+		}
+		return HealthCheckResult{IP: targetIP, Port: publishedPort}
+	}
+
+	return HealthCheckResult{Err: result.Err}
+}
+
+// HealthCheckTarget returns the target that checks a running app container
+// the way its labels ask for, as deployments do.
+func HealthCheckTarget(containerInfo container.InspectResponse) (healthcheck.Target, error) {
+	ip, publishedPort, err := ContainerAddress(containerInfo)
+	if err != nil {
+		return healthcheck.Target{}, fmt.Errorf("failed to get container IP address: %w", err)
+	}
+	labels, err := config.ParseContainerLabels(containerInfo.Config.Labels)
+	if err != nil {
+		return healthcheck.Target{}, fmt.Errorf("failed to parse container labels: %w", err)
+	}
+	return healthCheckTarget(containerInfo.ID, labels, ip, publishedPort)
+}
+
+func healthCheckTarget(containerID string, labels *config.ContainerLabels, ip, publishedPort string) (healthcheck.Target, error) {
 	if labels.Port == "" {
-		return HealthCheckResult{Err: fmt.Errorf("container has no port label set")}
+		return healthcheck.Target{}, fmt.Errorf("container has no port label set")
 	}
 
-	if labels.HealthCheckPath == "" {
-		return HealthCheckResult{Err: fmt.Errorf("container has no health check path set")}
+	checkType := healthcheck.CheckType(labels.HealthCheckType)
+	if labels.HealthCheckPath == "" && (checkType == "" || checkType == healthcheck.CheckHTTP) {
+		return healthcheck.Target{}, fmt.Errorf("container has no health check path set")
 	}
 
-	// Use the unified healthcheck package for HTTP health checks
 	target := healthcheck.Target{
 		ID:              containerID,
 		AppName:         labels.AppName,
-		IP:              targetIP,
+		IP:              ip,
 		Port:            labels.Port.String(),
 		HealthCheckPath: labels.HealthCheckPath,
+		Type:            checkType,
+		Cmd:             labels.HealthCheckCmd,
 	}
+	if publishedPort != "" {
+		target.Port = publishedPort
+	}
+	return target, nil
+}
 
-	checker := healthcheck.NewHTTPChecker(5 * time.Second)
-	retryConfig := healthcheck.DefaultRetryConfig()
-
-	result := checker.CheckWithRetry(ctx, target, retryConfig, func(attempt int, backoff time.Duration) {
-		logger.Info("Retrying health check...",
-			"backoff", backoff,
-			"attempt", attempt+1,
-			"max_retries", retryConfig.MaxRetries+1)
-	})
+// healthConfig converts a configured healthcheck override to Docker's
+// format. A nil override keeps the image's HEALTHCHECK.
+func healthConfig(hc *config.HealthCheck) *container.HealthConfig {
+	if hc == nil {
+		return nil
+	}
+	interval, timeout, startPeriod := hc.Durations()
+	healthConfig := &container.HealthConfig{
+		Test:        hc.Cmd.DockerTest(),
+		Interval:    interval,
+		Timeout:     timeout,
+		StartPeriod: startPeriod,
+	}
+	if hc.Retries != nil {
+		healthConfig.Retries = *hc.Retries
+	}
+	return healthConfig
+}
 
-	if result.Healthy {
-		if err := waitMinReadySeconds(ctx, cli, logger, containerID, containerInfo); err != nil {
-			return HealthCheckResult{Err: err}
-		}
-		return HealthCheckResult{IP: targetIP}
+// healthStartingTimeout is how long to wait for a Docker healthcheck to leave
+// the "starting" state: at least 30s, extended to cover the start period and
+// the first probe of a configured healthcheck.
+func healthStartingTimeout(containerConfig *container.Config) time.Duration {
+	const minTimeout = 30 * time.Second
+	if containerConfig == nil || containerConfig.Healthcheck == nil {
+		return minTimeout
 	}
+	hc := containerConfig.Healthcheck
+	interval := hc.Interval
+	if interval == 0 {
+		interval = 30 * time.Second // Docker default
+	}
+	timeout := hc.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second // Docker default
+	}
+	return max(minTimeout, hc.StartPeriod+interval+timeout)
+}
 
-	return HealthCheckResult{Err: result.Err}
+// startupDeadline returns until when a container with a startup probe may
+// take to pass its health check. Containers without one, or started longer
+// than the startup timeout ago, get the regular retries.
+func startupDeadline(labels *config.ContainerLabels, containerInfo container.InspectResponse) (time.Time, bool) {
+	if labels.StartupTimeout <= 0 || labels.StartupPeriod <= 0 || containerInfo.State == nil {
+		return time.Time{}, false
+	}
+	startedAt, err := time.Parse(time.RFC3339Nano, containerInfo.State.StartedAt)
+	if err != nil {
+		return time.Time{}, false
+	}
+	deadline := startedAt.Add(labels.StartupTimeout)
+	return deadline, time.Now().Before(deadline)
 }
 
 // waitMinReadySeconds waits for the configured stabilization period after a container
@@ -534,6 +731,33 @@ func GetAppContainers(ctx context.Context, cli *client.Client, listAll bool, app
 	return containerList, nil
 }
 
+// publishedHostIP is the interface app ports are published on when
+// config.PublishContainerPorts is set.
+const publishedHostIP = "127.0.0.1"
+
+// ContainerAddress returns the address haloyd reaches a running container
+// at. Normally that's the container's IP on the haloy network and port is
+// empty, meaning the container's own port. When ports are published, it's the
+// loopback interface and the host port Docker published the app port on.
+func ContainerAddress(containerInfo container.InspectResponse) (ip, port string, err error) {
+	if !config.PublishContainerPorts() {
+		ip, err := ContainerNetworkIP(containerInfo, constants.DockerNetwork)
+		return ip, "", err
+	}
+	labels, err := config.ParseContainerLabels(containerInfo.Config.Labels)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse container labels: %w", err)
+	}
+	if containerInfo.NetworkSettings != nil {
+		for _, binding := range containerInfo.NetworkSettings.Ports[nat.Port(labels.Port.String()+"/tcp")] {
+			if binding.HostPort != "" {
+				return publishedHostIP, binding.HostPort, nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("port %s is not published; redeploy the app so haloyd publishes it", labels.Port)
+}
+
 // ContainerNetworkInfo extracts the container's IP address
 func ContainerNetworkIP(containerInfo container.InspectResponse, networkName string) (string, error) {
 	if containerInfo.State == nil {
@@ -603,6 +827,15 @@ func checkImagePlatformCompatibility(ctx context.Context, cli *client.Client, im
 	return nil
 }
 
+// HealthCheckExec returns a healthcheck.ExecFunc that runs cmd checks
+// through cli, reporting stdout and stderr together as the output.
+func HealthCheckExec(cli *client.Client) healthcheck.ExecFunc {
+	return func(ctx context.Context, containerID string, cmd []string) (int, string, error) {
+		stdout, stderr, exitCode, err := ExecInContainer(ctx, cli, containerID, cmd)
+		return exitCode, stdout + stderr, err
+	}
+}
+
 // ExecInContainer executes a command in a running container and returns the output.
 func ExecInContainer(ctx context.Context, cli *client.Client, containerID string, cmd []string) (stdout, stderr string, exitCode int, err error) {
 	execConfig := container.ExecOptions{
@@ -634,6 +867,55 @@ func ExecInContainer(ctx context.Context, cli *client.Client, containerID string
 	return stdoutBuf.String(), stderrBuf.String(), inspectResp.ExitCode, nil
 }
 
+// ExecStreamInContainer runs cmd in a container, feeding it stdin when set and
+// streaming its stdout to stdout. For commands such as pg_dump whose output is
+// too large to buffer.
+func ExecStreamInContainer(ctx context.Context, cli *client.Client, containerID string, cmd []string, stdin io.Reader, stdout io.Writer) (stderr string, exitCode int, err error) {
+	execConfig := container.ExecOptions{
+		AttachStdin:  stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	}
+	execID, err := cli.ContainerExecCreate(ctx, containerID, execConfig)
+	if err != nil {
+		return "", 1, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	resp, err := cli.ContainerExecAttach(ctx, execID.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return "", 1, fmt.Errorf("failed to attach to exec: %w", err)
+	}
+	defer resp.Close()
+
+	stdinErr := make(chan error, 1)
+	if stdin != nil {
+		go func() {
+			_, err := io.Copy(resp.Conn, stdin)
+			if closeErr := resp.CloseWrite(); err == nil {
+				err = closeErr
+			}
+			stdinErr <- err
+		}()
+	} else {
+		stdinErr <- nil
+	}
+
+	var stderrBuf bytes.Buffer
+	if _, err := stdcopy.StdCopy(stdout, &stderrBuf, resp.Reader); err != nil {
+		return stderrBuf.String(), 1, fmt.Errorf("failed to read exec output: %w", err)
+	}
+	if err := <-stdinErr; err != nil {
+		return stderrBuf.String(), 1, fmt.Errorf("failed to write exec input: %w", err)
+	}
+
+	inspectResp, err := cli.ContainerExecInspect(ctx, execID.ID)
+	if err != nil {
+		return stderrBuf.String(), 1, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	return stderrBuf.String(), inspectResp.ExitCode, nil
+}
+
 type LogLine struct {
 	ContainerID string `json:"containerId"`
 	Line        string `json:"line"`
@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+func TestContainerAddress(t *testing.T) {
+	info := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			State: &container.State{Running: true},
+		},
+		Config: &container.Config{Labels: map[string]string{
+			config.LabelAppName:      "web",
+			config.LabelDeploymentID: "01ABC",
+			config.LabelPort:         "3000",
+		}},
+		NetworkSettings: &container.NetworkSettings{
+			NetworkSettingsBase: container.NetworkSettingsBase{
+				Ports: nat.PortMap{"3000/tcp": {{HostIP: "127.0.0.1", HostPort: "49153"}}},
+			},
+			Networks: map[string]*network.EndpointSettings{
+				constants.DockerNetwork: {IPAddress: "172.18.0.5"},
+			},
+		},
+	}
+
+	t.Run("network IP", func(t *testing.T) {
+		t.Setenv(constants.EnvVarPublishPorts, "")
+		ip, port, err := ContainerAddress(info)
+		if err != nil {
+			t.Fatalf("ContainerAddress() unexpected error: %v", err)
+		}
+		if ip != "172.18.0.5" || port != "" {
+			t.Errorf("ContainerAddress() = %s, %q, want 172.18.0.5 and no port", ip, port)
+		}
+	})
+
+	t.Run("published port", func(t *testing.T) {
+		t.Setenv(constants.EnvVarPublishPorts, "true")
+		ip, port, err := ContainerAddress(info)
+		if err != nil {
+			t.Fatalf("ContainerAddress() unexpected error: %v", err)
+		}
+		if ip != "127.0.0.1" || port != "49153" {
+			t.Errorf("ContainerAddress() = %s:%s, want 127.0.0.1:49153", ip, port)
+		}
+	})
+
+	t.Run("port not published", func(t *testing.T) {
+		t.Setenv(constants.EnvVarPublishPorts, "true")
+		unpublished := info
+		unpublished.NetworkSettings = &container.NetworkSettings{Networks: info.NetworkSettings.Networks}
+		if _, _, err := ContainerAddress(unpublished); err == nil {
+			t.Fatal("expected an error for a container without published port")
+		}
+	})
+}
+
+func TestStartupDeadline(t *testing.T) {
+	startedAt := func(ago time.Duration) container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{
+				State: &container.State{StartedAt: time.Now().Add(-ago).Format(time.RFC3339Nano)},
+			},
+		}
+	}
+	withProbe := &config.ContainerLabels{StartupTimeout: 5 * time.Minute, StartupPeriod: 5 * time.Second}
+
+	tests := []struct {
+		name   string
+		labels *config.ContainerLabels
+		info   container.InspectResponse
+		want   bool
+	}{
+		{"just started", withProbe, startedAt(time.Minute), true},
+		{"started before the timeout", withProbe, startedAt(10 * time.Minute), false},
+		{"no startup probe", &config.ContainerLabels{}, startedAt(time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deadline, ok := startupDeadline(tt.labels, tt.info)
+			if ok != tt.want {
+				t.Fatalf("startupDeadline() ok = %v, want %v", ok, tt.want)
+			}
+			if ok && time.Until(deadline) > 4*time.Minute+time.Second {
+				t.Errorf("deadline in %s, want about 4m from the container start", time.Until(deadline))
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// CopyFromContainer returns a tar archive of srcPath in the container, rooted
+// at the path's base name, and its stat information.
+func CopyFromContainer(ctx context.Context, cli *client.Client, containerID, srcPath string) (io.ReadCloser, container.PathStat, error) {
+	rc, stat, err := cli.CopyFromContainer(ctx, containerID, srcPath)
+	if err != nil {
+		return nil, stat, fmt.Errorf("failed to copy %s from container: %w", srcPath, err)
+	}
+	return rc, stat, nil
+}
+
+// CopyToContainer extracts a tar archive with a single root entry into the
+// container. Like docker cp: when dstPath is an existing directory the root
+// is placed inside it; otherwise the root is renamed to dstPath's base name
+// and extracted into its parent, which must exist.
+func CopyToContainer(ctx context.Context, cli *client.Client, containerID, dstPath string, content io.Reader) error {
+	extractDir := dstPath
+	stat, err := cli.ContainerStatPath(ctx, containerID, dstPath)
+	switch {
+	case err == nil && stat.Mode.IsDir():
+	case err == nil || client.IsErrNotFound(err):
+		extractDir = path.Dir(dstPath)
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(RenameTarRoot(content, pw, path.Base(dstPath)))
+		}()
+		defer pr.Close()
+		content = pr
+	default:
+		return fmt.Errorf("failed to stat %s in container: %w", dstPath, err)
+	}
+
+	if err := cli.CopyToContainer(ctx, containerID, extractDir, content, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to copy to %s in container: %w", dstPath, err)
+	}
+	return nil
+}
+
+// RenameTarRoot copies a tar archive from r to w, replacing the first path
+// component of every entry (and of hard link targets) with newRoot.
+func RenameTarRoot(r io.Reader, w io.Writer, newRoot string) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		hdr.Name = replaceRoot(hdr.Name, newRoot)
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname = replaceRoot(hdr.Linkname, newRoot)
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+	return tw.Close()
+}
+
+func replaceRoot(name, newRoot string) string {
+	trimmed := strings.TrimPrefix(name, "./")
+	_, rest, hasRest := strings.Cut(trimmed, "/")
+	if !hasRest {
+		return newRoot
+	}
+	return newRoot + "/" + rest
+}
@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRenameTarRoot(t *testing.T) {
+	var src bytes.Buffer
+	tw := tar.NewWriter(&src)
+	entries := []*tar.Header{
+		{Name: "assets/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "assets/app.css", Typeflag: tar.TypeReg, Mode: 0o644, Size: 4},
+		{Name: "assets/copy.css", Typeflag: tar.TypeLink, Linkname: "assets/app.css"},
+	}
+	for _, hdr := range entries {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			tw.Write([]byte("body"))
+		}
+	}
+	tw.Close()
+
+	var out bytes.Buffer
+	if err := RenameTarRoot(&src, &out, "static"); err != nil {
+		t.Fatalf("RenameTarRoot() error = %v", err)
+	}
+
+	want := []struct{ name, link string }{
+		{"static/", ""},
+		{"static/app.css", ""},
+		{"static/copy.css", "static/app.css"},
+	}
+	tr := tar.NewReader(&out)
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			if i != len(want) {
+				t.Fatalf("got %d entries, want %d", i, len(want))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Name != want[i].name || hdr.Linkname != want[i].link {
+			t.Errorf("entry %d = %q -> %q, want %q -> %q", i, hdr.Name, hdr.Linkname, want[i].name, want[i].link)
+		}
+	}
+}
+
+func TestRenameTarRoot_SingleFile(t *testing.T) {
+	var src bytes.Buffer
+	tw := tar.NewWriter(&src)
+	tw.WriteHeader(&tar.Header{Name: "config.json", Typeflag: tar.TypeReg, Mode: 0o644, Size: 2})
+	tw.Write([]byte("{}"))
+	tw.Close()
+
+	var out bytes.Buffer
+	if err := RenameTarRoot(&src, &out, "settings.json"); err != nil {
+		t.Fatalf("RenameTarRoot() error = %v", err)
+	}
+	hdr, err := tar.NewReader(&out).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != "settings.json" {
+		t.Errorf("Name = %q, want settings.json", hdr.Name)
+	}
+}
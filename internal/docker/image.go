@@ -7,8 +7,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"os"
-	"sort"
+	"slices"
 	"strings"
 
 	"github.com/docker/docker/api/types/container"
@@ -17,6 +16,7 @@ import (
 	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/client"
 	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/helpers"
 )
 
 func getRegistryAuthString(imageConfig *config.Image) (string, error) {
@@ -145,7 +145,34 @@ func formatImagePullError(imageRef string, imageConfig config.Image, err error)
 	return fmt.Errorf("failed to pull %s: %w", imageRef, err)
 }
 
+// ImageCache is a pull-through cache consulted before pulling registry images.
+// Restore returns true when it made imageRef available locally; remoteDigest
+// is empty when the registry could not be queried. Store records an image
+// after it has been pulled.
+type ImageCache interface {
+	Restore(ctx context.Context, imageRef, remoteDigest string) (bool, error)
+	Store(ctx context.Context, imageRef, remoteDigest string) error
+}
+
+// missingImageError explains why an image that must not be pulled isn't on
+// the server, and how to get it there.
+func missingImageError(imageConfig config.Image) error {
+	imageRef := imageConfig.ImageRef()
+	uploaded := imageConfig.ShouldBuild() && imageConfig.GetEffectivePushStrategy() == config.BuildPushOptionServer
+	if uploaded || strings.HasPrefix(imageRef, "sha256:") {
+		return fmt.Errorf("uploaded image '%s' not found on the server; it may have been pruned, run 'haloy deploy' to build and upload it again", imageRef)
+	}
+	return fmt.Errorf("image '%s' not found on the server and image.pull_policy is 'never'; "+
+		"load it with 'docker pull' or 'docker load' on the server, or set image.pull_policy to 'if_missing' to pull it when it's missing", imageRef)
+}
+
 func EnsureImageUpToDate(ctx context.Context, cli *client.Client, logger *slog.Logger, imageConfig config.Image) error {
+	return EnsureImageUpToDateWithCache(ctx, cli, logger, imageConfig, nil)
+}
+
+// EnsureImageUpToDateWithCache behaves like EnsureImageUpToDate but serves
+// registry images from cache when it holds the current digest. cache may be nil.
+func EnsureImageUpToDateWithCache(ctx context.Context, cli *client.Client, logger *slog.Logger, imageConfig config.Image, cache ImageCache) error {
 	imageRef := imageConfig.ImageRef()
 	pullPolicy := imageConfig.EffectivePullPolicy()
 
@@ -155,7 +182,7 @@ func EnsureImageUpToDate(ctx context.Context, cli *client.Client, logger *slog.L
 	// If BuildConfig. is true the server should have a local copy that was uploaded.
 	if imageConfig.BuildConfig != nil && imageConfig.BuildConfig.Push == config.BuildPushOptionServer {
 		if !localExists {
-			return fmt.Errorf("uploaded image '%s' not found", imageRef)
+			return missingImageError(imageConfig)
 		}
 		logger.Debug("Using local image", "image", imageRef)
 		return nil
@@ -171,7 +198,7 @@ func EnsureImageUpToDate(ctx context.Context, cli *client.Client, logger *slog.L
 			logger.Info("Using local image", "image", normalizedPullRef(imageConfig), "pull_policy", pullPolicy)
 			return nil
 		}
-		return fmt.Errorf("image '%s' not found locally and image.pull_policy is 'never'", imageRef)
+		return missingImageError(imageConfig)
 	}
 
 	registryAuth, err := getRegistryAuthString(&imageConfig)
@@ -179,19 +206,24 @@ func EnsureImageUpToDate(ctx context.Context, cli *client.Client, logger *slog.L
 		return fmt.Errorf("failed to resolve registry auth for image %s: %w", imageRef, err)
 	}
 
-	if localExists {
+	var remoteDigest string
+	if localExists || cache != nil {
 		remote, err := cli.DistributionInspect(ctx, imageRef, registryAuth)
-		if err != nil {
+		switch {
+		case err != nil && localExists:
 			if isDockerHubImage(imageConfig) && isDockerHubPullRateLimitError(err) {
 				logger.Warn("Failed to check Docker Hub for image updates due to rate limits; using local image", "image", normalizedPullRef(imageConfig), "error", err)
 			} else {
 				logger.Debug("Failed to check remote registry, using local image", "image", imageRef, "error", err)
 			}
 			return nil
+		case err != nil:
+			logger.Debug("Failed to check remote registry, trying image cache", "image", imageRef, "error", err)
+		default:
+			remoteDigest = remote.Descriptor.Digest.String()
 		}
 
-		remoteDigest := remote.Descriptor.Digest.String()
-		if local.RepoDigests != nil {
+		if localExists {
 			for _, rd := range local.RepoDigests {
 				if strings.HasSuffix(rd, "@"+remoteDigest) {
 					logger.Debug("Registry image is up to date", "image", imageRef)
@@ -199,7 +231,20 @@ func EnsureImageUpToDate(ctx context.Context, cli *client.Client, logger *slog.L
 				}
 			}
 		}
-		logger.Debug("Local image outdated, pulling from registry", "image", imageRef)
+
+		if cache != nil {
+			restored, err := cache.Restore(ctx, imageRef, remoteDigest)
+			if err != nil {
+				logger.Warn("Failed to restore image from cache", "image", imageRef, "error", err)
+			} else if restored {
+				logger.Info("Using cached image", "image", normalizedPullRef(imageConfig), "digest", remoteDigest)
+				return nil
+			}
+		}
+
+		if localExists {
+			logger.Debug("Local image outdated, pulling from registry", "image", imageRef)
+		}
 	}
 
 	// If we reach here, either the image doesn't exist locally or the remote digest doesn't match
@@ -224,6 +269,12 @@ func EnsureImageUpToDate(ctx context.Context, cli *client.Client, logger *slog.L
 		return fmt.Errorf("error reading pull response: %w", err)
 	}
 	logger.Debug("Successfully pulled image", "image", imageRef)
+
+	if cache != nil {
+		if err := cache.Store(ctx, imageRef, remoteDigest); err != nil {
+			logger.Warn("Failed to add image to cache", "image", imageRef, "error", err)
+		}
+	}
 	return nil
 }
 
@@ -262,9 +313,7 @@ type ImagePrunePlan struct {
 }
 
 func selectImageTagsToRemove(candidates []removableImageTag, inUseImageIDs map[string]struct{}, deploymentsToKeep int, ignoreDeploymentID string) []removableImageTag {
-	sort.Slice(candidates, func(i, j int) bool {
-		return candidates[i].DeploymentID > candidates[j].DeploymentID
-	})
+	slices.SortStableFunc(candidates, newestDeploymentFirst)
 
 	keepFromCandidates := deploymentsToKeep
 	if ignoreDeploymentID != "" && keepFromCandidates > 0 {
@@ -298,6 +347,10 @@ func selectImageTagsToRemove(candidates []removableImageTag, inUseImageIDs map[s
 	return removals
 }
 
+func newestDeploymentFirst(a, b removableImageTag) int {
+	return helpers.CompareDeploymentIDs(b.DeploymentID, a.DeploymentID)
+}
+
 func runningDeploymentIDs(containers []container.Summary) []string {
 	seen := make(map[string]struct{})
 	var deploymentIDs []string
@@ -313,7 +366,7 @@ func runningDeploymentIDs(containers []container.Summary) []string {
 		deploymentIDs = append(deploymentIDs, deploymentID)
 	}
 
-	sort.Sort(sort.Reverse(sort.StringSlice(deploymentIDs)))
+	slices.SortFunc(deploymentIDs, func(a, b string) int { return helpers.CompareDeploymentIDs(b, a) })
 	return deploymentIDs
 }
 
@@ -326,9 +379,7 @@ func planImagePrune(
 	runningIDs []string,
 ) ImagePrunePlan {
 	removals := selectImageTagsToRemove(candidates, inUseImageIDs, deploymentsToKeep, ignoreDeploymentID)
-	sort.Slice(removals, func(i, j int) bool {
-		return removals[i].DeploymentID > removals[j].DeploymentID
-	})
+	slices.SortStableFunc(removals, newestDeploymentFirst)
 
 	plan := ImagePrunePlan{
 		AppName:              appName,
@@ -431,14 +482,40 @@ func RemoveImages(ctx context.Context, cli *client.Client, logger *slog.Logger,
 	return ExecuteImagePrunePlan(ctx, cli, logger, plan)
 }
 
-func LoadImageFromTar(ctx context.Context, cli *client.Client, tarPath string) error {
-	file, err := os.Open(tarPath)
+// RemoveAppImages removes every image tag of an app, latest included. It is
+// meant for apps whose containers are already gone.
+func RemoveAppImages(ctx context.Context, cli *client.Client, logger *slog.Logger, appName string) (removed []string, err error) {
+	images, err := cli.ImageList(ctx, image.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("reference", appName+":*")),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to open tar file: %w", err)
+		return nil, fmt.Errorf("failed to list images for %s: %w", appName, err)
 	}
-	defer file.Close()
 
-	response, err := cli.ImageLoad(ctx, file)
+	var errs []error
+	for _, img := range images {
+		for _, tag := range img.RepoTags {
+			if !strings.HasPrefix(tag, appName+":") {
+				continue
+			}
+			if _, err := cli.ImageRemove(ctx, tag, image.RemoveOptions{Force: true, PruneChildren: true}); err != nil {
+				if client.IsErrNotFound(err) {
+					continue
+				}
+				logger.Error("Failed to remove image tag", "tag", tag, "error", err)
+				errs = append(errs, fmt.Errorf("%s: %w", tag, err))
+				continue
+			}
+			removed = append(removed, tag)
+		}
+	}
+
+	return removed, errors.Join(errs...)
+}
+
+// LoadImage loads the images in a 'docker save' archive read from r.
+func LoadImage(ctx context.Context, cli *client.Client, r io.Reader) error {
+	response, err := cli.ImageLoad(ctx, r)
 	if err != nil {
 		return fmt.Errorf("failed to load image: %w", err)
 	}
@@ -522,3 +599,27 @@ func PushImage(ctx context.Context, cli *client.Client, imageRef string, imageCo
 
 	return nil
 }
+
+// TagImageIfPresent reports whether the image with ID imageID is present,
+// and tags it as imageRef when it is but doesn't carry that tag yet, like an
+// image built once and deployed by another app under another name.
+func TagImageIfPresent(ctx context.Context, cli *client.Client, imageID, imageRef string) (bool, error) {
+	inspect, err := cli.ImageInspect(ctx, imageID)
+	if client.IsErrNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect image %s: %w", imageID, err)
+	}
+	if inspect.ID != imageID {
+		// A short or partial ID matched another image.
+		return false, nil
+	}
+	if slices.Contains(inspect.RepoTags, imageRef) {
+		return true, nil
+	}
+	if err := cli.ImageTag(ctx, imageID, imageRef); err != nil {
+		return false, fmt.Errorf("failed to tag image %s as %s: %w", imageID, imageRef, err)
+	}
+	return true, nil
+}
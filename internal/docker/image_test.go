@@ -303,6 +303,33 @@ func TestFormatImagePullError(t *testing.T) {
 	}
 }
 
+func TestMissingImageError(t *testing.T) {
+	tests := []struct {
+		name  string
+		image config.Image
+		want  string
+	}{
+		{
+			name:  "uploaded image",
+			image: config.Image{Repository: "web", Tag: "v1", BuildConfig: &config.BuildConfig{Push: config.BuildPushOptionServer}},
+			want:  "run 'haloy deploy' to build and upload it again",
+		},
+		{
+			name:  "registry image",
+			image: config.Image{Repository: "ghcr.io/acme/web", Tag: "v1", PullPolicy: config.PullPolicyNever},
+			want:  "image.pull_policy is 'never'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := missingImageError(tt.image)
+			if !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), tt.image.ImageRef()) {
+				t.Fatalf("missingImageError() = %q, want it to name the image and contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
 func TestSelectImageTagsToRemove_IgnoreDeploymentCountsTowardKeepLimit(t *testing.T) {
 	candidates := []removableImageTag{
 		{Tag: "app:20260222010101", DeploymentID: "20260222010101", ImageID: "img-1"},
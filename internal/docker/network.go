@@ -0,0 +1,31 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+)
+
+// EnsureNetwork creates the attachable bridge network name if it doesn't
+// exist. It goes through the API rather than the docker CLI, which Podman
+// hosts may not have.
+func EnsureNetwork(ctx context.Context, cli *client.Client, name string) error {
+	_, err := cli.NetworkInspect(ctx, name, network.InspectOptions{})
+	if err == nil {
+		return nil
+	}
+	if !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to inspect network %s: %w", name, err)
+	}
+
+	_, err = cli.NetworkCreate(ctx, name, network.CreateOptions{
+		Driver:     "bridge",
+		Attachable: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create network %s: %w", name, err)
+	}
+	return nil
+}
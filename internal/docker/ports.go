@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/haloydev/haloy/internal/config"
+)
+
+// CheckHostPorts returns an error naming the containers, other than the
+// app's own, that already publish one of the host ports in mappings.
+// Checking before the app's containers are replaced keeps a deploy that
+// can't bind its ports from taking the app down.
+func CheckHostPorts(ctx context.Context, cli *client.Client, appName string, mappings []config.PortMapping) error {
+	if len(mappings) == 0 {
+		return nil
+	}
+	containers, err := cli.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+	if conflicts := hostPortConflicts(containers, appName, mappings); len(conflicts) > 0 {
+		return fmt.Errorf("host ports already in use: %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+func hostPortConflicts(containers []container.Summary, appName string, mappings []config.PortMapping) []string {
+	var conflicts []string
+	for _, c := range containers {
+		owner := c.Labels[config.LabelAppName]
+		if owner == appName {
+			continue
+		}
+		if owner == "" {
+			owner = "container " + strings.TrimPrefix(firstName(c.Names), "/")
+		} else {
+			owner = "app " + owner
+		}
+		for _, port := range c.Ports {
+			if port.PublicPort == 0 {
+				continue
+			}
+			published := config.PortMapping{HostIP: unspecifiedToEmpty(port.IP), HostPort: int(port.PublicPort), Protocol: port.Type}
+			for _, mapping := range mappings {
+				if mapping.Overlaps(published) {
+					conflicts = append(conflicts, fmt.Sprintf("%d/%s by %s", mapping.HostPort, mapping.Protocol, owner))
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+// unspecifiedToEmpty maps the addresses Docker reports for ports published
+// on every interface to "", as PortMapping has them.
+func unspecifiedToEmpty(ip string) string {
+	if ip == "0.0.0.0" || ip == "::" {
+		return ""
+	}
+	return ip
+}
+
+func firstName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// publishPorts adds the target's published ports to the container and host
+// config.
+func publishPorts(exposedPorts nat.PortSet, hostConfig *container.HostConfig, mappings []config.PortMapping) nat.PortSet {
+	if len(mappings) == 0 {
+		return exposedPorts
+	}
+	if exposedPorts == nil {
+		exposedPorts = nat.PortSet{}
+	}
+	if hostConfig.PortBindings == nil {
+		hostConfig.PortBindings = nat.PortMap{}
+	}
+	for _, mapping := range mappings {
+		port := nat.Port(strconv.Itoa(mapping.ContainerPort) + "/" + mapping.Protocol)
+		exposedPorts[port] = struct{}{}
+		hostConfig.PortBindings[port] = append(hostConfig.PortBindings[port], nat.PortBinding{
+			HostIP:   mapping.HostIP,
+			HostPort: strconv.Itoa(mapping.HostPort),
+		})
+	}
+	return exposedPorts
+}
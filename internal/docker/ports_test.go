@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestHostPortConflicts(t *testing.T) {
+	containers := []container.Summary{
+		{
+			Labels: map[string]string{config.LabelAppName: "dns"},
+			Ports:  []container.Port{{IP: "0.0.0.0", PrivatePort: 53, PublicPort: 53, Type: "udp"}},
+		},
+		{
+			Labels: map[string]string{config.LabelAppName: "syslog"},
+			Ports:  []container.Port{{IP: "0.0.0.0", PrivatePort: 514, PublicPort: 514, Type: "udp"}},
+		},
+		{
+			Names: []string{"/gitea"},
+			Ports: []container.Port{
+				{IP: "127.0.0.1", PrivatePort: 22, PublicPort: 2222, Type: "tcp"},
+				{PrivatePort: 3000, Type: "tcp"},
+			},
+		},
+	}
+	mappings := []config.PortMapping{
+		{HostPort: 53, ContainerPort: 53, Protocol: "tcp"},
+		{HostPort: 514, ContainerPort: 514, Protocol: "udp"},
+		{HostPort: 2222, ContainerPort: 22, Protocol: "tcp"},
+		{HostPort: 53, ContainerPort: 53, Protocol: "udp"},
+	}
+
+	got := hostPortConflicts(containers, "dns", mappings)
+	want := []string{"514/udp by app syslog", "2222/tcp by container gitea"}
+	if !slices.Equal(got, want) {
+		t.Errorf("hostPortConflicts() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,160 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+// ErrHasSidecars is returned by RecreateWithLabels for app containers with
+// sidecars, which refer to the app container by ID and would lose it.
+var ErrHasSidecars = errors.New("container has sidecars")
+
+// RecreateWithLabels replaces a container with one created from the same
+// config, but with labels, as Docker can't change the labels of an existing
+// container. The new container takes the old one's name and networks, and is
+// started and health checked within healthTimeout if the old one was running.
+// Running containers are down between the two, so this is meant for
+// maintenance windows.
+//
+// The old container is removed only once the new one is up. On any failure
+// before that, the new container is removed and the old one restored.
+// Returns the ID of the new container.
+func RecreateWithLabels(ctx context.Context, cli *client.Client, logger *slog.Logger, containerID string, labels map[string]string, healthTimeout time.Duration) (string, error) {
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if info.Config == nil || info.HostConfig == nil {
+		return "", fmt.Errorf("container %s has no config", helpers.SafeIDPrefix(containerID))
+	}
+
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("label", fmt.Sprintf("%s=%s", config.LabelSidecarParent, info.ID))
+	sidecars, err := cli.ContainerList(ctx, container.ListOptions{Filters: filterArgs, All: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to list sidecars: %w", err)
+	}
+	if len(sidecars) > 0 {
+		return "", ErrHasSidecars
+	}
+
+	name := strings.TrimPrefix(info.Name, "/")
+	wasRunning := info.State != nil && info.State.Running
+	if wasRunning {
+		if err := stopSingleContainer(ctx, cli, logger, info.ID); err != nil {
+			return "", fmt.Errorf("failed to stop container: %w", err)
+		}
+	}
+
+	oldName := name + "-relabel"
+	if err := cli.ContainerRename(ctx, info.ID, oldName); err != nil {
+		restoreContainer(ctx, cli, logger, info.ID, "", wasRunning)
+		return "", fmt.Errorf("failed to rename container: %w", err)
+	}
+
+	containerConfig := *info.Config
+	containerConfig.Labels = labels
+	if containerConfig.Hostname == helpers.SafeIDPrefix(info.ID) {
+		// Docker's default hostname, which the new container gets its own of.
+		containerConfig.Hostname = ""
+	}
+
+	createResponse, err := cli.ContainerCreate(ctx, &containerConfig, info.HostConfig, endpointsOf(info), nil, name)
+	if err != nil {
+		restoreContainer(ctx, cli, logger, info.ID, name, wasRunning)
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+	newID := createResponse.ID
+
+	if wasRunning {
+		if err := startHealthy(ctx, cli, logger, newID, healthTimeout); err != nil {
+			if removeErr := cli.ContainerRemove(context.WithoutCancel(ctx), newID, container.RemoveOptions{Force: true}); removeErr != nil {
+				logger.Warn("Failed to remove relabeled container", "container_id", helpers.SafeIDPrefix(newID), "error", removeErr)
+			}
+			restoreContainer(ctx, cli, logger, info.ID, name, wasRunning)
+			return "", err
+		}
+	}
+
+	if err := cli.ContainerRemove(ctx, info.ID, container.RemoveOptions{}); err != nil {
+		return newID, fmt.Errorf("relabeled container is up, but failed to remove the old one (%s): %w", oldName, err)
+	}
+	return newID, nil
+}
+
+// endpointsOf returns the network settings to attach a copy of a container to
+// the same networks, minus the aliases Docker derived from its ID.
+func endpointsOf(info container.InspectResponse) *network.NetworkingConfig {
+	if info.NetworkSettings == nil || len(info.NetworkSettings.Networks) == 0 {
+		return nil
+	}
+	shortID := helpers.SafeIDPrefix(info.ID)
+	endpoints := make(map[string]*network.EndpointSettings, len(info.NetworkSettings.Networks))
+	for _, name := range slices.Sorted(maps.Keys(info.NetworkSettings.Networks)) {
+		ep := info.NetworkSettings.Networks[name]
+		if ep == nil {
+			continue
+		}
+		endpoints[name] = &network.EndpointSettings{
+			IPAMConfig: ep.IPAMConfig,
+			Links:      ep.Links,
+			Aliases: slices.DeleteFunc(slices.Clone(ep.Aliases), func(alias string) bool {
+				return alias == shortID
+			}),
+			DriverOpts: ep.DriverOpts,
+		}
+	}
+	return &network.NetworkingConfig{EndpointsConfig: endpoints}
+}
+
+// startHealthy starts a container and waits for it to pass its health check.
+func startHealthy(ctx context.Context, cli *client.Client, logger *slog.Logger, containerID string, timeout time.Duration) error {
+	if err := cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		result := HealthCheckContainer(ctx, cli, logger, containerID, container.InspectResponse{})
+		if result.Err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("container did not become healthy: %w", result.Err)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// restoreContainer undoes a failed RecreateWithLabels, giving the old
+// container its name back and restarting it. An empty name leaves the name
+// as it is. It runs even when ctx is canceled, so an interrupted migration
+// doesn't leave the app down.
+func restoreContainer(ctx context.Context, cli *client.Client, logger *slog.Logger, containerID, name string, start bool) {
+	ctx = context.WithoutCancel(ctx)
+	if name != "" {
+		if err := cli.ContainerRename(ctx, containerID, name); err != nil {
+			logger.Error("Failed to restore container name", "container_id", helpers.SafeIDPrefix(containerID), "name", name, "error", err)
+		}
+	}
+	if start {
+		if err := cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+			logger.Error("Failed to restart container", "container_id", helpers.SafeIDPrefix(containerID), "error", err)
+		}
+	}
+}
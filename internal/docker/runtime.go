@@ -0,0 +1,133 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+)
+
+// Engine is the container engine serving the Docker API.
+type Engine string
+
+const (
+	EngineDocker Engine = "docker"
+	// EnginePodman is Podman through its Docker-compatible API.
+	EnginePodman Engine = "podman"
+)
+
+// Runtime describes the container runtime haloy talks to.
+type Runtime struct {
+	Engine  Engine
+	Version string
+	// Rootless is set when the engine runs as an unprivileged user. Its
+	// containers live in a network namespace the host can't route to.
+	Rootless bool
+}
+
+func (r Runtime) String() string {
+	name := "Docker"
+	if r.Engine == EnginePodman {
+		name = "Podman"
+	}
+	if r.Version != "" {
+		name += " " + r.Version
+	}
+	if r.Rootless {
+		name += " (rootless)"
+	}
+	return name
+}
+
+// Limitations lists what works differently on the runtime than on rootful
+// Docker, for haloyd to log at startup.
+func (r Runtime) Limitations() []string {
+	var limitations []string
+	if r.Rootless {
+		limitations = append(limitations,
+			"container IPs aren't reachable from the host, app ports are published on 127.0.0.1 instead",
+			"apps on the host network share the runtime's network namespace, not the host's",
+			"volume bind mounts need paths the runtime's user can access",
+		)
+	}
+	if r.Engine == EnginePodman {
+		limitations = append(limitations,
+			"health check status only updates when Podman can schedule checks with systemd timers",
+			"images are built and loaded through the Docker-compatible API, Podman-only features are unavailable",
+		)
+	}
+	return limitations
+}
+
+// DetectRuntime asks the daemon behind cli which runtime it is.
+func DetectRuntime(ctx context.Context, cli *client.Client) (Runtime, error) {
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		return Runtime{}, fmt.Errorf("failed to get container runtime version: %w", err)
+	}
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return Runtime{}, fmt.Errorf("failed to get container runtime info: %w", err)
+	}
+	return runtimeFromServer(version, info), nil
+}
+
+func runtimeFromServer(version types.Version, info system.Info) Runtime {
+	runtime := Runtime{Engine: EngineDocker, Version: version.Version}
+	for _, component := range version.Components {
+		if strings.Contains(strings.ToLower(component.Name), "podman") {
+			runtime.Engine = EnginePodman
+			runtime.Version = component.Version
+			break
+		}
+	}
+	for _, option := range info.SecurityOptions {
+		if option == "name=rootless" {
+			runtime.Rootless = true
+			break
+		}
+	}
+	return runtime
+}
+
+// defaultHost returns the daemon socket to connect to when DOCKER_HOST isn't
+// set, or "" to use the client's default. Rootful Docker's socket wins; the
+// sockets of rootless Docker and of Podman are tried after it, rootless ones
+// in the user's runtime directory first.
+func defaultHost(getenv func(string) string, uid int, exists func(string) bool) string {
+	if getenv(client.EnvOverrideHost) != "" {
+		return ""
+	}
+	// Podman's own variable, set by 'podman machine' and in rootless setups.
+	if host := getenv("CONTAINER_HOST"); strings.HasPrefix(host, "unix://") {
+		return host
+	}
+
+	runtimeDir := getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = filepath.Join("/run/user", strconv.Itoa(uid))
+	}
+	candidates := []string{
+		"/var/run/docker.sock",
+		filepath.Join(runtimeDir, "docker.sock"),
+		filepath.Join(runtimeDir, "podman", "podman.sock"),
+		"/run/podman/podman.sock",
+	}
+	for _, socket := range candidates {
+		if exists(socket) {
+			return "unix://" + socket
+		}
+	}
+	return ""
+}
+
+func socketExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
@@ -0,0 +1,112 @@
+package docker
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/system"
+)
+
+func TestRuntimeFromServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		version types.Version
+		info    system.Info
+		want    Runtime
+	}{
+		{
+			name:    "docker",
+			version: types.Version{Version: "28.0.4", Components: []types.ComponentVersion{{Name: "Engine", Version: "28.0.4"}}},
+			info:    system.Info{SecurityOptions: []string{"name=seccomp,profile=builtin"}},
+			want:    Runtime{Engine: EngineDocker, Version: "28.0.4"},
+		},
+		{
+			name:    "rootless docker",
+			version: types.Version{Version: "28.0.4"},
+			info:    system.Info{SecurityOptions: []string{"name=seccomp,profile=builtin", "name=rootless", "name=cgroupns"}},
+			want:    Runtime{Engine: EngineDocker, Version: "28.0.4", Rootless: true},
+		},
+		{
+			name:    "rootless podman",
+			version: types.Version{Version: "5.2.0", Components: []types.ComponentVersion{{Name: "Podman Engine", Version: "5.2.0"}}},
+			info:    system.Info{SecurityOptions: []string{"name=rootless"}},
+			want:    Runtime{Engine: EnginePodman, Version: "5.2.0", Rootless: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runtimeFromServer(tt.version, tt.info); got != tt.want {
+				t.Errorf("runtimeFromServer() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuntimeString(t *testing.T) {
+	if got := (Runtime{Engine: EnginePodman, Version: "5.2.0", Rootless: true}).String(); got != "Podman 5.2.0 (rootless)" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := (Runtime{Engine: EngineDocker}).String(); got != "Docker" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := (Runtime{Engine: EngineDocker}).Limitations(); len(got) != 0 {
+		t.Errorf("rootful Docker must have no limitations, got %q", got)
+	}
+}
+
+func TestDefaultHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		sockets []string
+		want    string
+	}{
+		{
+			name:    "DOCKER_HOST wins",
+			env:     map[string]string{"DOCKER_HOST": "tcp://10.0.0.1:2375"},
+			sockets: []string{"/var/run/docker.sock"},
+			want:    "",
+		},
+		{
+			name:    "rootful docker first",
+			env:     map[string]string{"XDG_RUNTIME_DIR": "/run/user/1000"},
+			sockets: []string{"/var/run/docker.sock", "/run/user/1000/docker.sock"},
+			want:    "unix:///var/run/docker.sock",
+		},
+		{
+			name:    "rootless docker in XDG_RUNTIME_DIR",
+			env:     map[string]string{"XDG_RUNTIME_DIR": "/run/user/1000"},
+			sockets: []string{"/run/user/1000/docker.sock", "/run/user/1000/podman/podman.sock"},
+			want:    "unix:///run/user/1000/docker.sock",
+		},
+		{
+			name:    "rootless podman without XDG_RUNTIME_DIR",
+			sockets: []string{"/run/user/1000/podman/podman.sock"},
+			want:    "unix:///run/user/1000/podman/podman.sock",
+		},
+		{
+			name:    "rootful podman",
+			sockets: []string{"/run/podman/podman.sock"},
+			want:    "unix:///run/podman/podman.sock",
+		},
+		{
+			name: "CONTAINER_HOST",
+			env:  map[string]string{"CONTAINER_HOST": "unix:///tmp/podman.sock"},
+			want: "unix:///tmp/podman.sock",
+		},
+		{
+			name: "nothing found",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			exists := func(path string) bool { return slices.Contains(tt.sockets, path) }
+			if got := defaultHost(getenv, 1000, exists); got != tt.want {
+				t.Errorf("defaultHost() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+// startSidecars creates and starts the sidecars of one app replica that use
+// the given network mode. Sidecars on the app network start before the app
+// container so they are reachable when it boots; shared-namespace sidecars
+// can only start once the app container is running.
+// Returns the IDs of the containers created, including on error.
+func startSidecars(ctx context.Context, cli *client.Client, targetConfig config.TargetConfig, deploymentID, parentID, parentName string, replicaID int, mode config.SidecarNetwork) ([]string, error) {
+	var created []string
+
+	for _, sidecar := range targetConfig.Sidecars {
+		if sidecar.EffectiveNetwork() != mode {
+			continue
+		}
+
+		labels := map[string]string{
+			config.LabelSidecarOf:     targetConfig.Name,
+			config.LabelSidecarName:   sidecar.Name,
+			config.LabelSidecarParent: parentID,
+			config.LabelDeploymentID:  deploymentID,
+		}
+		if sidecar.IsHealthGated() {
+			labels[config.LabelSidecarHealthGate] = "true"
+		}
+
+		envVars := make([]string, 0, len(sidecar.Env)+1)
+		for _, envVar := range sidecar.Env {
+			envVars = append(envVars, fmt.Sprintf("%s=%s", envVar.Name, envVar.Value))
+		}
+		envVars = append(envVars, fmt.Sprintf("%s=%d", constants.EnvVarReplicaID, replicaID))
+
+		containerConfig := &container.Config{
+			Image:  sidecar.Image.ImageRef(),
+			Labels: labels,
+			Env:    envVars,
+			Cmd:    sidecar.Command,
+		}
+		hostConfig := &container.HostConfig{
+			RestartPolicy: container.RestartPolicy{Name: "unless-stopped"},
+			Binds:         sidecar.Volumes,
+			LogConfig:     logConfig(targetConfig.LoggingDriver),
+		}
+
+		var networkingConfig *network.NetworkingConfig
+		if mode == config.SidecarNetworkShared {
+			hostConfig.NetworkMode = container.NetworkMode("container:" + parentID)
+		} else {
+			networkName := constants.DockerNetwork
+			if targetConfig.Network != "" {
+				networkName = targetConfig.Network
+			}
+			hostConfig.NetworkMode = container.NetworkMode(networkName)
+			networkingConfig = &network.NetworkingConfig{
+				EndpointsConfig: map[string]*network.EndpointSettings{
+					networkName: {Aliases: []string{SidecarAlias(targetConfig.Name, sidecar.Name)}},
+				},
+			}
+		}
+
+		createResponse, err := cli.ContainerCreate(ctx, containerConfig, hostConfig, networkingConfig, nil, parentName+"-"+sidecar.Name)
+		if err != nil {
+			return created, fmt.Errorf("failed to create sidecar '%s': %w", sidecar.Name, err)
+		}
+		created = append(created, createResponse.ID)
+
+		if err := cli.ContainerStart(ctx, createResponse.ID, container.StartOptions{}); err != nil {
+			return created, fmt.Errorf("failed to start sidecar '%s': %w", sidecar.Name, err)
+		}
+	}
+
+	return created, nil
+}
+
+// SidecarAlias is the network alias of a sidecar that runs on the app network.
+func SidecarAlias(appName, sidecarName string) string {
+	return appName + "-" + sidecarName
+}
+
+// removeSidecarContainers force-removes sidecars created for a replica that failed to start.
+func removeSidecarContainers(ctx context.Context, cli *client.Client, ids []string) {
+	for _, id := range ids {
+		if err := cli.ContainerRemove(ctx, id, container.RemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) {
+			fmt.Printf("Failed to clean up sidecar container %s after error: %v\n", helpers.SafeIDPrefix(id), err)
+		}
+	}
+}
+
+// getSidecarContainers lists the sidecars of an app, including stopped ones.
+func getSidecarContainers(ctx context.Context, cli *client.Client, appName string) ([]container.Summary, error) {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("label", fmt.Sprintf("%s=%s", config.LabelSidecarOf, appName))
+	containerList, err := cli.ContainerList(ctx, container.ListOptions{Filters: filterArgs, All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sidecars for app %s: %w", appName, err)
+	}
+	return containerList, nil
+}
+
+// getAppAndSidecarContainers lists an app's containers followed by its
+// sidecars, so lifecycle operations stop app replicas before their sidecars.
+func getAppAndSidecarContainers(ctx context.Context, cli *client.Client, appName string) ([]container.Summary, error) {
+	containerList, err := GetAppContainers(ctx, cli, true, appName)
+	if err != nil {
+		return nil, err
+	}
+	sidecars, err := getSidecarContainers(ctx, cli, appName)
+	if err != nil {
+		return nil, err
+	}
+	return append(containerList, sidecars...), nil
+}
+
+// checkSidecarHealthGate verifies that every health-gated sidecar of an app
+// container is running and, if it has a Docker healthcheck, healthy.
+func checkSidecarHealthGate(ctx context.Context, cli *client.Client, parentID string) error {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("label", fmt.Sprintf("%s=%s", config.LabelSidecarParent, parentID))
+	filterArgs.Add("label", config.LabelSidecarHealthGate+"=true")
+	sidecars, err := cli.ContainerList(ctx, container.ListOptions{Filters: filterArgs, All: true})
+	if err != nil {
+		return fmt.Errorf("failed to list sidecars: %w", err)
+	}
+
+	for _, sidecar := range sidecars {
+		name := sidecar.Labels[config.LabelSidecarName]
+		if err := waitSidecarHealthy(ctx, cli, sidecar.ID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func waitSidecarHealthy(ctx context.Context, cli *client.Client, containerID, name string) error {
+	healthCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	for {
+		info, err := cli.ContainerInspect(healthCtx, containerID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect sidecar '%s': %w", name, err)
+		}
+		if info.State == nil {
+			return fmt.Errorf("sidecar '%s' state is nil", name)
+		}
+		if info.State.Restarting || !info.State.Running {
+			return fmt.Errorf("sidecar '%s' is not running (status: %s, exit code: %d)", name, info.State.Status, info.State.ExitCode)
+		}
+		if info.State.Health == nil || info.State.Health.Status == "healthy" {
+			return nil
+		}
+		if info.State.Health.Status == "unhealthy" {
+			return fmt.Errorf("sidecar '%s' is unhealthy according to Docker healthcheck", name)
+		}
+
+		select {
+		case <-healthCtx.Done():
+			return fmt.Errorf("timed out waiting for sidecar '%s' to become healthy", name)
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// ContainerStats returns a single resource usage sample for the container.
+// Docker fills in the previous CPU sample too, so CPU usage can be derived;
+// the call blocks for about a second while Docker takes the second reading.
+func ContainerStats(ctx context.Context, cli *client.Client, containerID string) (container.StatsResponse, error) {
+	var stats container.StatsResponse
+
+	resp, err := cli.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get stats for container %s: %w", containerID, err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return stats, fmt.Errorf("failed to decode stats for container %s: %w", containerID, err)
+	}
+	return stats, nil
+}
+
+// CPUPercent derives the CPU usage 'docker stats' shows from a sample, as a
+// percentage of one CPU.
+func CPUPercent(s container.StatsResponse) float64 {
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(s.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		return cpuDelta / systemDelta * onlineCPUs * 100
+	}
+	return 0
+}
+
+// MemoryUsage returns the memory usage 'docker stats' shows, which excludes
+// the page cache: cgroup v2 reports it as inactive_file, cgroup v1 as
+// total_inactive_file.
+func MemoryUsage(s container.StatsResponse) uint64 {
+	usage := s.MemoryStats.Usage
+	for _, key := range []string{"inactive_file", "total_inactive_file"} {
+		if cache, ok := s.MemoryStats.Stats[key]; ok && cache < usage {
+			return usage - cache
+		}
+	}
+	return usage
+}
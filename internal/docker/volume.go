@@ -56,7 +56,7 @@ func EnsureVolumes(ctx context.Context, cli *client.Client, logger *slog.Logger,
 	return nil
 }
 
-func RemoveVolumes(ctx context.Context, cli *client.Client, logger *slog.Logger, appName string) error {
+func RemoveVolumes(ctx context.Context, cli *client.Client, logger *slog.Logger, appName string) (removed []string, err error) {
 	filterArgs := filters.NewArgs()
 	filterArgs.Add("label", fmt.Sprintf("%s=%s", config.LabelAppName, appName))
 
@@ -64,7 +64,7 @@ func RemoveVolumes(ctx context.Context, cli *client.Client, logger *slog.Logger,
 		Filters: filterArgs,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to list volumes for app %s: %w", appName, err)
+		return nil, fmt.Errorf("failed to list volumes for app %s: %w", appName, err)
 	}
 
 	for _, vol := range volumeList.Volumes {
@@ -74,9 +74,10 @@ func RemoveVolumes(ctx context.Context, cli *client.Client, logger *slog.Logger,
 			if client.IsErrNotFound(err) {
 				continue
 			}
-			return fmt.Errorf("failed to remove volume %s: %w", vol.Name, err)
+			return removed, fmt.Errorf("failed to remove volume %s: %w", vol.Name, err)
 		}
+		removed = append(removed, vol.Name)
 	}
 
-	return nil
+	return removed, nil
 }
@@ -0,0 +1,161 @@
+// Package errorpages manages per-app custom error pages: the bundle the CLI
+// reads from the project, the copy haloyd keeps in the data directory, and
+// the lookup haloy-proxy does when it serves an error for an app's route.
+//
+// A bundle is a flat directory of HTML templates named after the status they
+// serve: an exact code ("502.html") or a class ("5xx.html"). Templates are
+// rendered with html/template and can use PageData fields, e.g.
+// {{.Status}} and {{.RequestID}}.
+package errorpages
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+const (
+	// MaxPageSize and MaxBundleSize bound what a deploy request may carry.
+	MaxPageSize   = 256 << 10
+	MaxBundleSize = 1 << 20
+
+	// uploadedMarker marks a bundle written by a deploy, as opposed to one an
+	// operator placed in the data directory by hand. Only uploaded bundles
+	// are removed when a deploy no longer configures error pages.
+	uploadedMarker = ".uploaded"
+)
+
+var pageNamePattern = regexp.MustCompile(`^([45][0-9][0-9]|[45]xx)\.html$`)
+
+// ValidName reports whether name is a recognized error page file name.
+func ValidName(name string) bool {
+	return pageNamePattern.MatchString(name)
+}
+
+// Candidates returns the page names that can serve status, most specific first.
+func Candidates(status int) []string {
+	code := strconv.Itoa(status)
+	return []string{code + ".html", code[:1] + "xx.html"}
+}
+
+// AppDir returns the directory holding appName's bundle under baseDir.
+func AppDir(baseDir, appName string) string {
+	return filepath.Join(baseDir, appName)
+}
+
+// ReadBundle reads the error pages in dir. Files that are not named like an
+// error page are ignored; oversized pages and bundles are rejected.
+func ReadBundle(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read error pages directory: %w", err)
+	}
+
+	pages := make(map[string]string)
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || !ValidName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat error page %s: %w", entry.Name(), err)
+		}
+		if info.Size() > MaxPageSize {
+			return nil, fmt.Errorf("error page %s is %d bytes, limit is %d", entry.Name(), info.Size(), MaxPageSize)
+		}
+		total += info.Size()
+		if total > MaxBundleSize {
+			return nil, fmt.Errorf("error pages in %s exceed %d bytes in total", dir, MaxBundleSize)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read error page %s: %w", entry.Name(), err)
+		}
+		pages[entry.Name()] = string(data)
+	}
+
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no error pages found in %s (expected files like 404.html or 5xx.html)", dir)
+	}
+	return pages, nil
+}
+
+// ValidateBundle checks page names and sizes of a bundle received over the API.
+func ValidateBundle(pages map[string]string) error {
+	var total int
+	for name, content := range pages {
+		if !ValidName(name) {
+			return fmt.Errorf("invalid error page name %q", name)
+		}
+		if len(content) > MaxPageSize {
+			return fmt.Errorf("error page %s exceeds %d bytes", name, MaxPageSize)
+		}
+		total += len(content)
+	}
+	if total > MaxBundleSize {
+		return fmt.Errorf("error pages exceed %d bytes in total", MaxBundleSize)
+	}
+	return nil
+}
+
+// WriteBundle replaces appName's bundle under baseDir with pages. The new
+// bundle is staged next to the old one and swapped in with a rename, so the
+// proxy never sees a half-written bundle. An empty pages map removes a bundle
+// a previous deploy uploaded and leaves a hand-placed one alone.
+func WriteBundle(baseDir, appName string, pages map[string]string) error {
+	if err := ValidateBundle(pages); err != nil {
+		return err
+	}
+	appDir := AppDir(baseDir, appName)
+
+	if len(pages) == 0 {
+		if _, err := os.Stat(filepath.Join(appDir, uploadedMarker)); err != nil {
+			return nil
+		}
+		if err := os.RemoveAll(appDir); err != nil {
+			return fmt.Errorf("failed to remove error pages: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(baseDir, constants.ModeDirPrivate); err != nil {
+		return fmt.Errorf("failed to create error pages directory: %w", err)
+	}
+	stageDir, err := os.MkdirTemp(baseDir, "."+appName+"-")
+	if err != nil {
+		return fmt.Errorf("failed to stage error pages: %w", err)
+	}
+	defer os.RemoveAll(stageDir)
+
+	for name, content := range pages {
+		if err := os.WriteFile(filepath.Join(stageDir, name), []byte(content), constants.ModeFileDefault); err != nil {
+			return fmt.Errorf("failed to write error page %s: %w", name, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(stageDir, uploadedMarker), nil, constants.ModeFileDefault); err != nil {
+		return fmt.Errorf("failed to write error pages marker: %w", err)
+	}
+
+	oldDir := stageDir + ".old"
+	if err := os.Rename(appDir, oldDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to replace error pages: %w", err)
+	}
+	if err := os.Rename(stageDir, appDir); err != nil {
+		os.Rename(oldDir, appDir)
+		return fmt.Errorf("failed to replace error pages: %w", err)
+	}
+	os.RemoveAll(oldDir)
+	return nil
+}
+
+// validAppDirName guards lookups against path traversal through route data.
+func validAppDirName(appName string) bool {
+	return appName != "" && !strings.ContainsAny(appName, `/\`) && appName != "." && appName != ".."
+}
@@ -0,0 +1,149 @@
+package errorpages
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidName(t *testing.T) {
+	tests := map[string]bool{
+		"404.html":     true,
+		"502.html":     true,
+		"5xx.html":     true,
+		"4xx.html":     true,
+		"200.html":     false,
+		"6xx.html":     false,
+		"404.htm":      false,
+		"../404.html":  false,
+		"error.html":   false,
+		"404.html.bak": false,
+	}
+	for name, want := range tests {
+		if got := ValidName(name); got != want {
+			t.Errorf("ValidName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestWriteBundle_ReplacesAndRemovesUploaded(t *testing.T) {
+	baseDir := t.TempDir()
+
+	if err := WriteBundle(baseDir, "app", map[string]string{"404.html": "v1", "5xx.html": "down"}); err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+	if err := WriteBundle(baseDir, "app", map[string]string{"404.html": "v2"}); err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+
+	pages, err := ReadBundle(AppDir(baseDir, "app"))
+	if err != nil {
+		t.Fatalf("ReadBundle() error = %v", err)
+	}
+	if len(pages) != 1 || pages["404.html"] != "v2" {
+		t.Fatalf("pages = %v, want only the new 404.html", pages)
+	}
+
+	if err := WriteBundle(baseDir, "app", nil); err != nil {
+		t.Fatalf("WriteBundle(nil) error = %v", err)
+	}
+	if _, err := os.Stat(AppDir(baseDir, "app")); !os.IsNotExist(err) {
+		t.Fatalf("uploaded bundle still exists after deploy without pages: %v", err)
+	}
+
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("staging leftovers in base dir: %v", entries)
+	}
+}
+
+func TestWriteBundle_KeepsHandPlacedBundle(t *testing.T) {
+	baseDir := t.TempDir()
+	appDir := AppDir(baseDir, "app")
+	if err := os.MkdirAll(appDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(appDir, "404.html"), []byte("manual"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteBundle(baseDir, "app", nil); err != nil {
+		t.Fatalf("WriteBundle(nil) error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(appDir, "404.html")); err != nil {
+		t.Fatalf("hand-placed page was removed: %v", err)
+	}
+}
+
+func TestValidateBundle(t *testing.T) {
+	if err := ValidateBundle(map[string]string{"../../etc/passwd": "x"}); err == nil {
+		t.Error("expected error for invalid page name")
+	}
+	if err := ValidateBundle(map[string]string{"404.html": strings.Repeat("x", MaxPageSize+1)}); err == nil {
+		t.Error("expected error for oversized page")
+	}
+	if err := ValidateBundle(map[string]string{"404.html": "ok"}); err != nil {
+		t.Errorf("ValidateBundle() unexpected error = %v", err)
+	}
+}
+
+func TestStore_RenderAndReload(t *testing.T) {
+	baseDir := t.TempDir()
+	appDir := AppDir(baseDir, "app")
+	if err := os.MkdirAll(appDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	pagePath := filepath.Join(appDir, "5xx.html")
+	if err := os.WriteFile(pagePath, []byte("<p>{{.Status}} {{.RequestID}}</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(baseDir)
+	data := PageData{Status: 502, RequestID: "<abc>"}
+
+	body, ok, err := store.Render("app", data)
+	if err != nil || !ok {
+		t.Fatalf("Render() = ok %v, err %v; want rendered page", ok, err)
+	}
+	if string(body) != "<p>502 &lt;abc&gt;</p>" {
+		t.Errorf("body = %q, want escaped template output", body)
+	}
+
+	if _, ok, _ := store.Render("app", PageData{Status: 404}); ok {
+		t.Error("Render() served a 5xx page for a 404")
+	}
+	if _, ok, _ := store.Render("other", data); ok {
+		t.Error("Render() served a page for an app without a bundle")
+	}
+	if _, ok, _ := store.Render("..", data); ok {
+		t.Error("Render() accepted a path-traversing app name")
+	}
+
+	if err := os.WriteFile(pagePath, []byte("<p>updated {{.Status}}</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(pagePath, future, future); err != nil {
+		t.Fatal(err)
+	}
+	body, ok, err = store.Render("app", data)
+	if err != nil || !ok || string(body) != "<p>updated 502</p>" {
+		t.Errorf("Render() after change = %q, %v, %v; want reloaded page", body, ok, err)
+	}
+
+	if err := os.WriteFile(pagePath, []byte("{{.Broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := future.Add(time.Minute)
+	if err := os.Chtimes(pagePath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := store.Render("app", data); ok || err == nil {
+		t.Errorf("Render() with broken template = ok %v, err %v; want fallback with error", ok, err)
+	}
+}
@@ -0,0 +1,95 @@
+package errorpages
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// PageData is the data error page templates are rendered with.
+type PageData struct {
+	Status     int
+	StatusText string
+	Message    string
+	RequestID  string
+	Host       string
+	Path       string
+}
+
+type cachedPage struct {
+	modTime time.Time
+	size    int64
+	tmpl    *template.Template
+	err     error
+}
+
+// Store renders app error pages from a bundle directory. Pages are parsed on
+// first use and re-parsed when their file changes, so a new bundle takes
+// effect without restarting the proxy.
+type Store struct {
+	baseDir string
+
+	mu    sync.Mutex
+	pages map[string]*cachedPage // file path -> parsed page
+}
+
+// NewStore returns a Store reading bundles from baseDir.
+func NewStore(baseDir string) *Store {
+	return &Store{
+		baseDir: baseDir,
+		pages:   make(map[string]*cachedPage),
+	}
+}
+
+// Render renders appName's page for data.Status. ok is false when the app has
+// no page for the status or its template fails, and the caller should fall
+// back to the default page; err carries the template failure, if any.
+func (s *Store) Render(appName string, data PageData) (body []byte, ok bool, err error) {
+	if s == nil || !validAppDirName(appName) {
+		return nil, false, nil
+	}
+
+	for _, name := range Candidates(data.Status) {
+		tmpl, err := s.load(filepath.Join(AppDir(s.baseDir, appName), name))
+		if err != nil {
+			return nil, false, err
+		}
+		if tmpl == nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, false, err
+		}
+		return buf.Bytes(), true, nil
+	}
+	return nil, false, nil
+}
+
+// load returns the parsed template at path, or nil if the file does not exist.
+func (s *Store) load(path string) (*template.Template, error) {
+	info, statErr := os.Stat(path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if statErr != nil {
+		delete(s.pages, path)
+		return nil, nil
+	}
+	if cached, ok := s.pages[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.tmpl, cached.err
+	}
+
+	page := &cachedPage{modTime: info.ModTime(), size: info.Size()}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		page.tmpl, err = template.New(filepath.Base(path)).Parse(string(data))
+	}
+	page.err = err
+	s.pages[path] = page
+	return page.tmpl, page.err
+}
@@ -0,0 +1,77 @@
+package github
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// Annotation levels for workflow commands.
+const (
+	AnnotationNotice  = "notice"
+	AnnotationWarning = "warning"
+	AnnotationError   = "error"
+)
+
+// InActions reports whether the process runs as a GitHub Actions step.
+func InActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// AppendStepSummary appends markdown to the job summary shown on the
+// workflow run page. It does nothing outside GitHub Actions.
+func AppendStepSummary(markdown string) error {
+	return appendToEnvFile("GITHUB_STEP_SUMMARY", markdown)
+}
+
+// SetOutput sets a step output that later steps read as
+// steps.<id>.outputs.<name>. It does nothing outside GitHub Actions.
+func SetOutput(name, value string) error {
+	if !strings.ContainsAny(value, "\r\n") {
+		return appendToEnvFile("GITHUB_OUTPUT", fmt.Sprintf("%s=%s\n", name, value))
+	}
+
+	// Multiline values use a heredoc with a delimiter that can't occur in
+	// the value.
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	delimiter := "ghadelimiter_" + hex.EncodeToString(buf)
+	return appendToEnvFile("GITHUB_OUTPUT", fmt.Sprintf("%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter))
+}
+
+// Annotate writes a workflow command that GitHub shows as an annotation on the
+// run and, for errors, the checks summary.
+func Annotate(w io.Writer, level, title, message string) {
+	fmt.Fprintf(w, "::%s title=%s::%s\n", level, escapeProperty(title), escapeData(message))
+}
+
+func appendToEnvFile(env, content string) error {
+	path := os.Getenv(env)
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, constants.ModeFileDefault)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", env, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", env, err)
+	}
+	return nil
+}
+
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,53 @@
+package github
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output")
+	t.Setenv("GITHUB_OUTPUT", path)
+
+	if err := SetOutput("deployment_id", "01ABC"); err != nil {
+		t.Fatalf("SetOutput() unexpected error: %v", err)
+	}
+	if err := SetOutput("deployments", "line1\nline2"); err != nil {
+		t.Fatalf("SetOutput() unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if lines[0] != "deployment_id=01ABC" {
+		t.Errorf("first line = %q, want deployment_id=01ABC", lines[0])
+	}
+	if len(lines) != 5 || !strings.HasPrefix(lines[1], "deployments<<") {
+		t.Fatalf("expected a heredoc for the multiline value, got %q", lines[1:])
+	}
+	delimiter := strings.TrimPrefix(lines[1], "deployments<<")
+	if lines[2] != "line1" || lines[3] != "line2" || lines[4] != delimiter {
+		t.Errorf("unexpected heredoc: %q", lines[1:])
+	}
+}
+
+func TestSetOutputOutsideActions(t *testing.T) {
+	t.Setenv("GITHUB_OUTPUT", "")
+	if err := SetOutput("deployment_id", "01ABC"); err != nil {
+		t.Fatalf("SetOutput() unexpected error: %v", err)
+	}
+}
+
+func TestAnnotateEscapes(t *testing.T) {
+	var buf bytes.Buffer
+	Annotate(&buf, AnnotationError, "Deploy failed: web, api", "50% done\nthen failed")
+	want := "::error title=Deploy failed%3A web%2C api::50%25 done%0Athen failed\n"
+	if buf.String() != want {
+		t.Errorf("Annotate() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,280 @@
+package haloy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/cmdexec"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+const (
+	defaultArtifactLockPath = "haloy.lock.json"
+	artifactLockVersion     = 1
+)
+
+// Artifact delivery modes recorded in the lockfile.
+const (
+	artifactDeliveryRegistry = "registry" // pushed to a registry, pinned by repo digest
+	artifactDeliveryServer   = "server"   // uploaded to the target server, pinned by image ID
+	artifactDeliveryLocal    = "local"    // built on the same Docker daemon the target uses
+)
+
+var runCLICommandOutput = cmdexec.RunCLICommand
+
+// ArtifactLock records the images produced by 'haloy build' so a later
+// 'haloy deploy --from-artifacts' deploys exactly those images.
+type ArtifactLock struct {
+	Version      int                       `json:"version"`
+	HaloyVersion string                    `json:"haloyVersion"`
+	CreatedAt    time.Time                 `json:"createdAt"`
+	Targets      map[string]ArtifactTarget `json:"targets"`
+}
+
+// ArtifactTarget is the image built for a single target.
+type ArtifactTarget struct {
+	App        string `json:"app"`
+	Server     string `json:"server"`
+	ImageRef   string `json:"imageRef"`
+	ImageID    string `json:"imageId"`
+	RepoDigest string `json:"repoDigest,omitempty"`
+	Delivery   string `json:"delivery"`
+}
+
+func readArtifactLock(path string) (*ArtifactLock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact lockfile: %w", err)
+	}
+
+	var lock ArtifactLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse artifact lockfile %s: %w", path, err)
+	}
+	if lock.Version != artifactLockVersion {
+		return nil, fmt.Errorf("artifact lockfile %s has unsupported version %d (expected %d)", path, lock.Version, artifactLockVersion)
+	}
+	return &lock, nil
+}
+
+func writeArtifactLock(path string, lock *ArtifactLock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal artifact lockfile: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), constants.ModeFileDefault); err != nil {
+		return fmt.Errorf("failed to write artifact lockfile: %w", err)
+	}
+	return nil
+}
+
+// buildAndDeliverImages builds every image that needs building and, when
+// deliver is set, uploads or pushes it to where the targets will pull it from.
+// Uploads are limited to limitRate bytes per second, unless it's 0. The
+// returned lock describes the result for each built target.
+func buildAndDeliverImages(ctx context.Context, targets map[string]config.TargetConfig, configPath string, deliver bool, limitRate int64) (*ArtifactLock, error) {
+	builds, pushes, uploads, localBuilds := ResolveImageBuilds(targets)
+
+	// Check Docker availability before building
+	if len(builds) > 0 {
+		imageRefs := make([]string, 0, len(builds))
+		for imageRef := range builds {
+			imageRefs = append(imageRefs, imageRef)
+		}
+		if err := checkDockerAvailable(ctx, imageRefs); err != nil {
+			return nil, err
+		}
+	}
+
+	for imageRef, image := range builds {
+		if err := BuildImage(ctx, imageRef, image, configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	pushedDigests := make(map[string]string)
+	if deliver {
+		// Upload images only to remote servers (skip localhost - image already in shared daemon)
+		if err := UploadImages(ctx, uploads, limitRate); err != nil {
+			return nil, err
+		}
+
+		// Log skipped localhost uploads for visibility
+		for imageRef := range localBuilds {
+			ui.Info("Skipping upload for %s (localhost shares Docker daemon)", imageRef)
+		}
+
+		// The ref names the registry, so one push serves every target.
+		for imageRef, images := range pushes {
+			image := images[0]
+			ui.Info("Pushing image '%s' to %s", imageRef, image.GetRegistryServer())
+			repoDigest, err := pushImageToRegistry(ctx, imageRef, image)
+			if err != nil {
+				return nil, err
+			}
+			pushedDigests[imageRef] = repoDigest
+		}
+	}
+
+	lock := &ArtifactLock{
+		Version:      artifactLockVersion,
+		HaloyVersion: constants.Version,
+		CreatedAt:    time.Now().UTC(),
+		Targets:      make(map[string]ArtifactTarget),
+	}
+	for targetName, target := range targets {
+		if target.Image == nil || !target.Image.ShouldBuild() {
+			continue
+		}
+		imageRef := target.Image.ImageRef()
+		imageID, repoDigests, err := inspectLocalImage(ctx, imageRef)
+		if err != nil {
+			return nil, err
+		}
+
+		artifact := ArtifactTarget{
+			App:      target.Name,
+			Server:   target.Server,
+			ImageRef: imageRef,
+			ImageID:  imageID,
+		}
+		switch {
+		case target.Image.GetEffectivePushStrategy() == config.BuildPushOptionRegistry:
+			artifact.Delivery = artifactDeliveryRegistry
+			artifact.RepoDigest = pushedDigests[imageRef]
+			if artifact.RepoDigest == "" {
+				artifact.RepoDigest = matchRepoDigest(imageRef, repoDigests)
+			}
+		case helpers.IsLocalhost(target.Server):
+			artifact.Delivery = artifactDeliveryLocal
+		default:
+			artifact.Delivery = artifactDeliveryServer
+		}
+		lock.Targets[targetName] = artifact
+	}
+
+	return lock, nil
+}
+
+// applyArtifactLock pins the image of every building target to the artifact
+// recorded for it. Targets that pull a prebuilt image are left unchanged.
+func applyArtifactLock(lock *ArtifactLock, rawTargets, resolvedTargets map[string]config.TargetConfig) error {
+	var errs []error
+	for _, targetName := range slices.Sorted(maps.Keys(resolvedTargets)) {
+		target := resolvedTargets[targetName]
+		if target.Image == nil || !target.Image.ShouldBuild() {
+			continue
+		}
+
+		artifact, ok := lock.Targets[targetName]
+		if !ok {
+			errs = append(errs, fmt.Errorf("target '%s' builds an image but has no entry in the artifact lockfile; run 'haloy build' first", targetName))
+			continue
+		}
+		if artifact.Delivery == artifactDeliveryServer && artifact.Server != target.Server {
+			errs = append(errs, fmt.Errorf("target '%s': image was uploaded to %s, but the target deploys to %s", targetName, artifact.Server, target.Server))
+			continue
+		}
+		if artifact.Delivery == artifactDeliveryRegistry && artifact.RepoDigest == "" {
+			errs = append(errs, fmt.Errorf("target '%s': artifact lockfile has no registry digest; was the image pushed?", targetName))
+			continue
+		}
+
+		target.Image = pinArtifactImage(target.Image, artifact)
+		resolvedTargets[targetName] = target
+		if raw, ok := rawTargets[targetName]; ok && raw.Image != nil {
+			raw.Image = pinArtifactImage(raw.Image, artifact)
+			rawTargets[targetName] = raw
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pinPushedImages pins the image of every target whose image was just
+// pushed to a registry to the digest the push stored, so the server pulls
+// exactly that image even if someone pushes the same tag meanwhile.
+func pinPushedImages(lock *ArtifactLock, rawTargets, resolvedTargets map[string]config.TargetConfig) {
+	for targetName, target := range resolvedTargets {
+		if target.Image == nil || !target.Image.ShouldBuild() || target.Image.RegistryAuth == nil {
+			continue
+		}
+		artifact, ok := lock.Targets[targetName]
+		if !ok || artifact.Delivery != artifactDeliveryRegistry || artifact.RepoDigest == "" {
+			continue
+		}
+
+		target.Image = pinArtifactImage(target.Image, artifact)
+		resolvedTargets[targetName] = target
+		if raw, ok := rawTargets[targetName]; ok && raw.Image != nil {
+			raw.Image = pinArtifactImage(raw.Image, artifact)
+			rawTargets[targetName] = raw
+		}
+	}
+}
+
+// pinArtifactImage returns a copy of image that references the artifact by
+// content: a repo digest for registry images, the image ID for uploaded ones.
+// The registry history strategy keys rollbacks on the tag, so those images
+// keep their (immutable) tag.
+func pinArtifactImage(image *config.Image, artifact ArtifactTarget) *config.Image {
+	pinned := *image
+	if image.History != nil && image.History.Strategy == config.HistoryStrategyRegistry {
+		return &pinned
+	}
+
+	ref := artifact.ImageID
+	if artifact.Delivery == artifactDeliveryRegistry {
+		ref = artifact.RepoDigest
+	}
+	if ref != "" {
+		pinned.Repository = ref
+		pinned.Tag = ""
+	}
+	// The build already happened; never rebuild a pinned image.
+	build := false
+	pinned.Build = &build
+	return &pinned
+}
+
+func inspectLocalImage(ctx context.Context, imageRef string) (string, []string, error) {
+	output, err := runCLICommandOutput(ctx, "docker", "image", "inspect", "--format", `{{.Id}} {{join .RepoDigests ","}}`, imageRef)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to inspect image %s: %w", imageRef, err)
+	}
+
+	id, digests, _ := strings.Cut(strings.TrimSpace(output), " ")
+	var repoDigests []string
+	if digests != "" {
+		repoDigests = strings.Split(digests, ",")
+	}
+	return id, repoDigests, nil
+}
+
+// repositoryOf returns imageRef without its tag.
+func repositoryOf(imageRef string) string {
+	if i := strings.LastIndex(imageRef, ":"); i > strings.LastIndex(imageRef, "/") {
+		return imageRef[:i]
+	}
+	return imageRef
+}
+
+// matchRepoDigest returns the repo digest belonging to imageRef's repository.
+func matchRepoDigest(imageRef string, repoDigests []string) string {
+	repository := repositoryOf(imageRef)
+	for _, digest := range repoDigests {
+		if strings.HasPrefix(digest, repository+"@") {
+			return digest
+		}
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+package haloy
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func buildTarget(server string, push config.BuildPushOption) config.TargetConfig {
+	build := true
+	return config.TargetConfig{
+		Name:   "web",
+		Server: server,
+		Image: &config.Image{
+			Repository:  "ghcr.io/acme/web",
+			Tag:         "latest",
+			Build:       &build,
+			BuildConfig: &config.BuildConfig{Push: push},
+		},
+	}
+}
+
+func TestApplyArtifactLock_PinsImages(t *testing.T) {
+	resolved := map[string]config.TargetConfig{
+		"uploaded": buildTarget("prod.example.com", config.BuildPushOptionServer),
+		"pushed":   buildTarget("prod.example.com", config.BuildPushOptionRegistry),
+		"pulled":   {Name: "db", Image: &config.Image{Repository: "postgres", Tag: "17"}},
+	}
+	raw := map[string]config.TargetConfig{
+		"uploaded": buildTarget("prod.example.com", config.BuildPushOptionServer),
+	}
+	lock := &ArtifactLock{Version: artifactLockVersion, Targets: map[string]ArtifactTarget{
+		"uploaded": {Server: "prod.example.com", ImageID: "sha256:abc", Delivery: artifactDeliveryServer},
+		"pushed":   {Server: "prod.example.com", RepoDigest: "ghcr.io/acme/web@sha256:def", Delivery: artifactDeliveryRegistry},
+	}}
+
+	if err := applyArtifactLock(lock, raw, resolved); err != nil {
+		t.Fatalf("applyArtifactLock() error = %v", err)
+	}
+
+	if got := resolved["uploaded"].Image.ImageRef(); got != "sha256:abc" {
+		t.Errorf("uploaded image ref = %q, want image ID", got)
+	}
+	if got := raw["uploaded"].Image.ImageRef(); got != "sha256:abc" {
+		t.Errorf("raw uploaded image ref = %q, want image ID", got)
+	}
+	if got := resolved["pushed"].Image.ImageRef(); got != "ghcr.io/acme/web@sha256:def" {
+		t.Errorf("pushed image ref = %q, want repo digest", got)
+	}
+	if resolved["pushed"].Image.ShouldBuild() {
+		t.Errorf("pinned image should not be rebuilt")
+	}
+	if got := resolved["pulled"].Image.ImageRef(); got != "postgres:17" {
+		t.Errorf("pulled image ref = %q, want unchanged", got)
+	}
+}
+
+func TestApplyArtifactLock_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		lock    map[string]ArtifactTarget
+		wantErr string
+	}{
+		{
+			name:    "missing entry",
+			lock:    map[string]ArtifactTarget{},
+			wantErr: "no entry in the artifact lockfile",
+		},
+		{
+			name:    "uploaded to another server",
+			lock:    map[string]ArtifactTarget{"web": {Server: "staging.example.com", ImageID: "sha256:abc", Delivery: artifactDeliveryServer}},
+			wantErr: "was uploaded to staging.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved := map[string]config.TargetConfig{"web": buildTarget("prod.example.com", config.BuildPushOptionServer)}
+			err := applyArtifactLock(&ArtifactLock{Version: artifactLockVersion, Targets: tt.lock}, nil, resolved)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("applyArtifactLock() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestArtifactLock_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "haloy.lock.json")
+	lock := &ArtifactLock{Version: artifactLockVersion, Targets: map[string]ArtifactTarget{
+		"web": {App: "web", ImageID: "sha256:abc", Delivery: artifactDeliveryLocal},
+	}}
+	if err := writeArtifactLock(path, lock); err != nil {
+		t.Fatalf("writeArtifactLock() error = %v", err)
+	}
+
+	got, err := readArtifactLock(path)
+	if err != nil {
+		t.Fatalf("readArtifactLock() error = %v", err)
+	}
+	if got.Targets["web"].ImageID != "sha256:abc" {
+		t.Fatalf("ImageID = %q, want sha256:abc", got.Targets["web"].ImageID)
+	}
+}
+
+func TestMatchRepoDigest(t *testing.T) {
+	digests := []string{"other/app@sha256:111", "localhost:5000/acme/web@sha256:222"}
+	if got := matchRepoDigest("localhost:5000/acme/web:v1", digests); got != "localhost:5000/acme/web@sha256:222" {
+		t.Fatalf("matchRepoDigest() = %q", got)
+	}
+	if got := matchRepoDigest("acme/none:v1", digests); got != "" {
+		t.Fatalf("matchRepoDigest() = %q, want empty", got)
+	}
+}
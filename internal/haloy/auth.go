@@ -0,0 +1,233 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/keyring"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+)
+
+// Replaced in tests so they never touch the real keyring.
+var (
+	keyringGet    = keyring.Get
+	keyringSet    = keyring.Set
+	keyringDelete = keyring.Delete
+)
+
+func AuthCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Store API tokens in the OS keyring",
+		Long: `Store Haloy server API tokens in the operating system's keyring (macOS
+Keychain, Secret Service on Linux, Windows Credential Manager) instead of the
+plaintext env file 'haloy server add' writes. Tokens in the keyring are used
+before any other stored token; an api_token set in the deploy config still
+takes precedence.`,
+	}
+
+	cmd.AddCommand(AuthLoginCmd())
+	cmd.AddCommand(AuthLogoutCmd())
+
+	return cmd
+}
+
+func AuthLoginCmd() *cobra.Command {
+	var noVerify bool
+
+	cmd := &cobra.Command{
+		Use:   "login <server>",
+		Short: "Store a server's API token in the OS keyring",
+		Long: `Prompt for a server's API token and store it in the OS keyring. The token
+is read from stdin when it isn't a terminal, so it never has to appear on the
+command line or in shell history.
+
+The server can be a URL or a profile name. A token for the server stored in
+the haloy env file is removed once it is in the keyring.
+
+Examples:
+  haloy auth login haloy.example.com
+  haloy auth login prod
+  pass show haloy/prod | haloy auth login prod`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := readToken(cmd.InOrStdin(), args[0])
+			if err != nil {
+				return err
+			}
+			return authLogin(cmd.Context(), resolveServerRef(args[0]), token, !noVerify)
+		},
+	}
+
+	cmd.Flags().BoolVar(&noVerify, "no-verify", false, "Store the token without checking it against the server")
+	cmd.ValidArgsFunction = completeProfileNames
+
+	return cmd
+}
+
+func readToken(stdin io.Reader, server string) (string, error) {
+	if file, ok := stdin.(*os.File); ok && isTerminal(file.Fd()) {
+		if ui.NonInteractive() {
+			return "", fmt.Errorf("%w: pipe the token for %s to stdin", ui.ErrInputRequired, server)
+		}
+		fmt.Fprintf(os.Stderr, "API token for %s: ", server)
+		data, err := term.ReadPassword(file.Fd())
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token from stdin: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New("no token given on stdin")
+	}
+	return token, nil
+}
+
+func authLogin(ctx context.Context, server, token string, verify bool) error {
+	if token == "" {
+		return errors.New("token is required")
+	}
+	normalizedURL, err := helpers.NormalizeServerURL(server)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := helpers.ValidateServerHost(normalizedURL); err != nil {
+		return fmt.Errorf("invalid domain: %w", err)
+	}
+
+	if verify {
+		api, err := apiclient.New(normalizedURL, token)
+		if err != nil {
+			return fmt.Errorf("unable to create API client: %w", err)
+		}
+		var version apitypes.VersionResponse
+		if err := api.Get(ctx, "version", &version); err != nil {
+			return fmt.Errorf("token check against %s failed (use --no-verify to store it anyway): %w", normalizedURL, err)
+		}
+	}
+
+	if err := keyringSet(normalizedURL, token); err != nil {
+		if errors.Is(err, keyring.ErrUnsupported) {
+			return fmt.Errorf("%w; store the token with 'haloy server add %s <token>' instead", err, normalizedURL)
+		}
+		return fmt.Errorf("failed to store token in keyring: %w", err)
+	}
+
+	configDir, err := config.HaloyConfigDir()
+	if err != nil {
+		return fmt.Errorf("failed to get config dir: %w", err)
+	}
+	if err := helpers.EnsureDir(configDir); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+	clientConfigPath := filepath.Join(configDir, constants.ClientConfigFileName)
+	clientConfig, err := config.LoadClientConfig(clientConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load client config: %w", err)
+	}
+	if clientConfig == nil {
+		clientConfig = &config.ClientConfig{}
+	}
+
+	// The keyring replaces the plaintext copy, if there is one.
+	if serverConfig, exists := clientConfig.Servers[normalizedURL]; exists && serverConfig.TokenEnv != "" {
+		removeEnvFileToken(configDir, serverConfig.TokenEnv)
+		serverConfig.TokenEnv = ""
+		clientConfig.Servers[normalizedURL] = serverConfig
+	}
+	if err := clientConfig.SetServerKeyring(normalizedURL, true); err != nil {
+		return err
+	}
+	if err := config.SaveClientConfig(clientConfig, clientConfigPath); err != nil {
+		return fmt.Errorf("failed to save client config: %w", err)
+	}
+
+	ui.Success("Token for %s stored in the OS keyring", normalizedURL)
+	return nil
+}
+
+func AuthLogoutCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logout <server>",
+		Short: "Remove a server's API token from the OS keyring",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			normalizedURL, err := helpers.NormalizeServerURL(resolveServerRef(args[0]))
+			if err != nil {
+				return fmt.Errorf("invalid URL: %w", err)
+			}
+
+			if err := keyringDelete(normalizedURL); err != nil {
+				if errors.Is(err, keyring.ErrNotFound) {
+					return fmt.Errorf("no token for %s in the keyring", normalizedURL)
+				}
+				return fmt.Errorf("failed to remove token from keyring: %w", err)
+			}
+
+			clientConfig, path, err := loadClientConfigForUpdate()
+			if err == nil {
+				if err := clientConfig.SetServerKeyring(normalizedURL, false); err != nil {
+					return err
+				}
+				if err := config.SaveClientConfig(clientConfig, path); err != nil {
+					return fmt.Errorf("failed to save client config: %w", err)
+				}
+			}
+
+			ui.Success("Token for %s removed from the OS keyring", normalizedURL)
+			return nil
+		},
+	}
+
+	cmd.ValidArgsFunction = completeProfileNames
+
+	return cmd
+}
+
+// tokenSource describes where the token for a client config server comes
+// from, for listings.
+func tokenSource(server config.ServerConfig) string {
+	switch {
+	case server.Keyring:
+		return "🔑 keyring"
+	case server.TokenEnv != "" && os.Getenv(server.TokenEnv) != "":
+		return "✅ env"
+	default:
+		return "⚠️ missing"
+	}
+}
+
+func removeEnvFileToken(configDir, tokenEnv string) {
+	envFile := filepath.Join(configDir, constants.ConfigEnvFileName)
+	env, err := godotenv.Read(envFile)
+	if err != nil {
+		return
+	}
+	if _, exists := env[tokenEnv]; !exists {
+		return
+	}
+	delete(env, tokenEnv)
+	if err := godotenv.Write(env, envFile); err != nil {
+		ui.Warn("Failed to write env file: %v", err)
+		ui.Info("Please remove the token %s from %s manually", tokenEnv, envFile)
+	}
+}
@@ -0,0 +1,121 @@
+package haloy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/keyring"
+	"github.com/joho/godotenv"
+)
+
+func useFakeKeyring(t *testing.T) map[string]string {
+	t.Helper()
+	secrets := make(map[string]string)
+	origGet, origSet, origDelete := keyringGet, keyringSet, keyringDelete
+	keyringGet = func(account string) (string, error) {
+		secret, ok := secrets[account]
+		if !ok {
+			return "", keyring.ErrNotFound
+		}
+		return secret, nil
+	}
+	keyringSet = func(account, secret string) error {
+		secrets[account] = secret
+		return nil
+	}
+	keyringDelete = func(account string) error {
+		if _, ok := secrets[account]; !ok {
+			return keyring.ErrNotFound
+		}
+		delete(secrets, account)
+		return nil
+	}
+	t.Cleanup(func() {
+		keyringGet, keyringSet, keyringDelete = origGet, origSet, origDelete
+	})
+	return secrets
+}
+
+func TestAuthLogin_MovesTokenToKeyring(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv(constants.EnvVarConfigDir, configDir)
+	t.Setenv(constants.EnvVarAPIToken, "")
+	secrets := useFakeKeyring(t)
+
+	if err := runRootCommand(t, "server", "add", "haloy.example.com", "plain-token"); err != nil {
+		t.Fatalf("server add failed: %v", err)
+	}
+	tokenEnv := generateTokenEnvName("haloy.example.com")
+
+	if err := authLogin(context.Background(), "https://haloy.example.com", "keyring-token", false); err != nil {
+		t.Fatalf("authLogin() unexpected error: %v", err)
+	}
+	if secrets["haloy.example.com"] != "keyring-token" {
+		t.Fatalf("expected token in keyring, got %v", secrets)
+	}
+
+	env, err := godotenv.Read(filepath.Join(configDir, constants.ConfigEnvFileName))
+	if err != nil {
+		t.Fatalf("failed to read env file: %v", err)
+	}
+	if _, exists := env[tokenEnv]; exists {
+		t.Errorf("expected plaintext token to be removed from the env file")
+	}
+
+	// A stale value in the environment must not win over the keyring.
+	t.Setenv(tokenEnv, "plain-token")
+	token, err := getToken(nil, "haloy.example.com")
+	if err != nil {
+		t.Fatalf("getToken() unexpected error: %v", err)
+	}
+	if token != "keyring-token" {
+		t.Errorf("getToken() = %s, expected keyring-token", token)
+	}
+
+	if err := runRootCommand(t, "auth", "logout", "haloy.example.com"); err != nil {
+		t.Fatalf("auth logout failed: %v", err)
+	}
+	if len(secrets) != 0 {
+		t.Errorf("expected keyring to be empty after logout, got %v", secrets)
+	}
+	clientConfig, err := config.LoadDefaultClientConfig()
+	if err != nil {
+		t.Fatalf("failed to load client config: %v", err)
+	}
+	if _, exists := clientConfig.Servers["haloy.example.com"]; exists {
+		t.Errorf("expected server without any token to be removed from client config")
+	}
+}
+
+func TestAuthLogin_KeyringUnsupported(t *testing.T) {
+	t.Setenv(constants.EnvVarConfigDir, t.TempDir())
+	useFakeKeyring(t)
+	keyringSet = func(string, string) error { return keyring.ErrUnsupported }
+
+	err := authLogin(context.Background(), "haloy.example.com", "token", false)
+	if err == nil || !strings.Contains(err.Error(), "haloy server add") {
+		t.Fatalf("expected error pointing to 'haloy server add', got: %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(os.Getenv(constants.EnvVarConfigDir), constants.ClientConfigFileName)); !os.IsNotExist(statErr) {
+		t.Errorf("expected no client config to be written when the keyring is unavailable")
+	}
+}
+
+func TestReadToken_FromPipe(t *testing.T) {
+	token, err := readToken(strings.NewReader("  secret-token\n"), "haloy.example.com")
+	if err != nil {
+		t.Fatalf("readToken() unexpected error: %v", err)
+	}
+	if token != "secret-token" {
+		t.Errorf("readToken() = %q, expected secret-token", token)
+	}
+
+	if _, err := readToken(strings.NewReader("\n"), "haloy.example.com"); err == nil {
+		t.Errorf("readToken() expected error for empty input")
+	}
+}
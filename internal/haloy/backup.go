@@ -0,0 +1,244 @@
+package haloy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+func BackupCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "List and download volume snapshots",
+		Long: `List and download the volume snapshots haloyd takes of the targets with a
+snapshots section, from the server or the target's snapshots.remote.`,
+	}
+
+	cmd.AddCommand(
+		backupListCmd(configPath, flags),
+		backupPullCmd(configPath, flags),
+	)
+
+	return cmd
+}
+
+func backupListCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List volume snapshots",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			targets, err := snapshotTargets(cmd.Context(), *configPath, flags)
+			if err != nil {
+				return err
+			}
+			g, ctx := errgroup.WithContext(cmd.Context())
+			for _, target := range targets {
+				g.Go(func() error {
+					prefix := ""
+					if len(targets) > 1 {
+						prefix = target.TargetName
+					}
+					api, err := newTargetAPIClient(target)
+					if err != nil {
+						return &PrefixedError{Err: err, Prefix: prefix}
+					}
+					var response apitypes.VolumeSnapshotsResponse
+					if err := api.Get(ctx, snapshotsPath(target, ""), &response); err != nil {
+						return &PrefixedError{Err: fmt.Errorf("failed to list snapshots: %w", explainSnapshotError(err)), Prefix: prefix}
+					}
+					printVolumeSnapshots(target.Name, response.Snapshots, prefix)
+					return nil
+				})
+			}
+			return g.Wait()
+		},
+	}
+
+	addBackupFlags(cmd, flags)
+	return cmd
+}
+
+func backupPullCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var outputDir string
+
+	cmd := &cobra.Command{
+		Use:   "pull <snapshot>",
+		Short: "Download a volume snapshot",
+		Long: `Download a snapshot listed by 'haloy backup list' and check it against the
+checksum taken when it was created. The checksum is written next to it, so
+'sha256sum -c <snapshot>.sha256' verifies the file later.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if filepath.Base(name) != name {
+				return fmt.Errorf("invalid snapshot name '%s'", name)
+			}
+			targets, err := snapshotTargets(cmd.Context(), *configPath, flags)
+			if err != nil {
+				return err
+			}
+			var matching []config.TargetConfig
+			for _, target := range targets {
+				if strings.HasPrefix(name, target.Name+"-") {
+					matching = append(matching, target)
+				}
+			}
+			if len(matching) != 1 {
+				return fmt.Errorf("snapshot '%s' doesn't belong to exactly one selected target; select it with --targets", name)
+			}
+
+			api, err := newTargetAPIClient(matching[0])
+			if err != nil {
+				return err
+			}
+			return pullVolumeSnapshot(cmd.Context(), api, matching[0], name, filepath.Join(outputDir, name))
+		},
+	}
+
+	addBackupFlags(cmd, flags)
+	cmd.Flags().StringVarP(&outputDir, "output", "o", ".", "Directory to download the snapshot to")
+	return cmd
+}
+
+func addBackupFlags(cmd *cobra.Command, flags *appCmdFlags) {
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Use specific targets with snapshots (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Use all targets with snapshots")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+}
+
+// snapshotTargets returns the selected targets that have volume snapshots.
+func snapshotTargets(ctx context.Context, configPath string, flags *appCmdFlags) ([]config.TargetConfig, error) {
+	targets, err := selectedTargets(ctx, configPath, flags, func(target config.TargetConfig) bool {
+		return target.Snapshots != nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("none of the selected targets has a snapshots section")
+	}
+	return targets, nil
+}
+
+// snapshotsPath returns the API path of a target's snapshots, or of the
+// snapshot name when set. The remote is passed along so haloyd can reach the
+// snapshots only kept there.
+func snapshotsPath(target config.TargetConfig, name string) string {
+	path := "snapshots/" + url.PathEscape(target.Name)
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	if target.Snapshots.Remote != "" {
+		path += "?" + url.Values{"remote": {target.Snapshots.Remote}}.Encode()
+	}
+	return path
+}
+
+// pullVolumeSnapshot downloads a snapshot to path and writes its checksum
+// file. A download that doesn't match the checksum is removed.
+func pullVolumeSnapshot(ctx context.Context, api *apiclient.APIClient, target config.TargetConfig, name, path string) error {
+	req, err := api.NewRequest(ctx, http.MethodGet, snapshotsPath(target, name), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := api.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download snapshot: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := copyResponseError(resp); err != nil {
+		return explainSnapshotError(err)
+	}
+	want := resp.Header.Get("X-Haloy-Checksum")
+	if want == "" {
+		return errors.New("the server sent no checksum for the snapshot")
+	}
+
+	var body io.Reader = resp.Body
+	var progress *ui.ProgressBar
+	if resp.ContentLength > 0 {
+		progress = ui.NewProgressBar(ui.ProgressBarConfig{
+			Description: "Downloading",
+			TotalBytes:  resp.ContentLength,
+			ShowBytes:   true,
+		})
+		body = &progressReader{reader: resp.Body, progress: progress}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(f, hash), body)
+	if progress != nil {
+		progress.Finish()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to download snapshot: %w", err)
+	}
+
+	got := hex.EncodeToString(hash.Sum(nil))
+	if got != want {
+		os.Remove(path)
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, want, got)
+	}
+	if err := os.WriteFile(path+".sha256", []byte(got+"  "+name+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write checksum file: %w", err)
+	}
+	ui.Success("Downloaded %s (%s) to %s, checksum verified", name, ui.FormatBytes(written), path)
+	return nil
+}
+
+// explainSnapshotError points at an outdated haloyd when the endpoint is
+// missing.
+func explainSnapshotError(err error) error {
+	var httpErr *apiclient.HTTPError
+	if errors.Is(err, apiclient.ErrNotFound) || (errors.As(err, &httpErr) && httpErr.Body == "404 page not found") {
+		return errors.New("the server doesn't support volume snapshots, upgrade haloyd to use this command")
+	}
+	return err
+}
+
+func printVolumeSnapshots(appName string, snapshots []apitypes.VolumeSnapshot, prefix string) {
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	if len(snapshots) == 0 {
+		pui.Info("No snapshots of %s", appName)
+		return
+	}
+	rows := make([][]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		var where []string
+		if snapshot.Local {
+			where = append(where, "server")
+		}
+		if snapshot.Remote {
+			where = append(where, "remote")
+		}
+		rows = append(rows, []string{snapshot.Name, ui.FormatBytes(snapshot.Size), helpers.FormatTime(snapshot.CreatedAt), strings.Join(where, ", ")})
+	}
+	ui.Table([]string{"SNAPSHOT", "SIZE", "CREATED", "STORED"}, rows)
+}
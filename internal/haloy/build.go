@@ -0,0 +1,103 @@
+package haloy
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func BuildCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var (
+		outputPath string
+		noPush     bool
+		limitRate  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build images without deploying",
+		Long: `Build images for the selected targets without deploying them.
+
+Built images are pushed to their registry or uploaded to their server, and a
+lockfile recording the exact image digests is written. Deploy those images
+later, without rebuilding, with:
+
+  haloy deploy --from-artifacts haloy.lock.json`,
+		Example: `  # Build and deliver images for all targets
+  haloy build --all
+
+  # Build locally only, e.g. to run tests against the image first
+  haloy build --no-push -o build.lock.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+
+			uploadRate, err := parseLimitRate(limitRate)
+			if err != nil {
+				return err
+			}
+
+			rawDeployConfig, _, resolvedTargets, err := loadDeployTargets(ctx, *configPath, flags)
+			if err != nil {
+				return err
+			}
+
+			if !noPush {
+				if err := checkServersAuth(ctx, resolvedTargets); err != nil {
+					return err
+				}
+			}
+
+			plugins, err := loadPlugins(rawDeployConfig.Plugins, *configPath)
+			if err != nil {
+				return err
+			}
+
+			lock, err := buildWithPlugins(ctx, plugins, resolvedTargets, *configPath, !noPush, uploadRate)
+			if err != nil {
+				return err
+			}
+
+			if len(lock.Targets) == 0 {
+				ui.Warn("No targets build an image; nothing to record")
+				return nil
+			}
+
+			if err := writeArtifactLock(outputPath, lock); err != nil {
+				return err
+			}
+
+			rows := make([][]string, 0, len(lock.Targets))
+			for _, targetName := range slices.Sorted(maps.Keys(lock.Targets)) {
+				artifact := lock.Targets[targetName]
+				ref := artifact.ImageID
+				if artifact.RepoDigest != "" {
+					ref = artifact.RepoDigest
+				}
+				rows = append(rows, []string{targetName, artifact.Delivery, ref})
+			}
+			ui.Table([]string{"TARGET", "DELIVERY", "IMAGE"}, rows)
+			ui.Success("Wrote %s", outputPath)
+			if noPush {
+				ui.Info("Images were not delivered; run 'haloy build' without --no-push before deploying with --from-artifacts")
+			} else {
+				ui.Info("Deploy with: haloy deploy --from-artifacts %s", outputPath)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Build for specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Build for all targets")
+	cmd.Flags().BoolVar(&flags.includeProtected, "include-protected", false, "Include protected targets when using --all")
+	cmd.Flags().StringVarP(&outputPath, "output", "o", defaultArtifactLockPath, "Path of the artifact lockfile to write")
+	cmd.Flags().BoolVar(&noPush, "no-push", false, "Build images locally without pushing or uploading them")
+	cmd.Flags().StringVar(&limitRate, "limit-rate", "", "Limit image uploads to this many bytes per second per server, e.g. 500K or 2M")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+
+	return cmd
+}
@@ -0,0 +1,255 @@
+package haloy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/bundle"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func BundleCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Create offline deployment bundles",
+		Long: `Create a single file holding everything needed to deploy a target on a
+server without access to registries or the internet. Import it on the server
+with 'haloyd bundle import'.`,
+	}
+
+	cmd.AddCommand(BundleCreateCmd(configPath, flags))
+
+	return cmd
+}
+
+func BundleCreateCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "create [target]",
+		Short: "Bundle a target's images and config into one file",
+		Long: `Build or pull the images a target runs and write them, together with the
+target's resolved config and custom error pages, to a single bundle file.
+
+The target is deployed with the bundled images only: haloyd never pulls them
+from a registry. Pre- and post-deploy hooks are not part of the bundle.
+
+The bundle contains resolved secrets. Treat it like a credential.
+
+Examples:
+  # Bundle the only target in the config
+  haloy bundle create
+
+  # Bundle the prod target
+  haloy bundle create prod -o app.haloy
+
+  # Then, on the server
+  haloyd bundle import app.haloy`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if len(args) == 1 {
+				flags.targets = []string{args[0]}
+			}
+
+			rawDeployConfig, rawTargets, resolvedTargets, err := loadDeployTargets(ctx, *configPath, flags)
+			if err != nil {
+				return err
+			}
+			if len(resolvedTargets) != 1 {
+				return fmt.Errorf("a bundle holds one target, select it with 'haloy bundle create <target>'")
+			}
+			var targetName string
+			for name := range resolvedTargets {
+				targetName = name
+			}
+			resolvedTarget := resolvedTargets[targetName]
+			rawTarget := rawTargets[targetName]
+
+			if output == "" {
+				output = resolvedTarget.Name + ".haloy"
+			}
+			return createBundle(ctx, *configPath, targetName, resolvedTarget, config.DeployConfig{
+				TargetConfig:    rawTarget,
+				SecretProviders: rawDeployConfig.SecretProviders,
+			}, output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Bundle file to write (default: <app>.haloy)")
+
+	cmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeTargetNames(cmd, args, toComplete)
+	}
+
+	return cmd
+}
+
+func createBundle(ctx context.Context, configPath, targetName string, target config.TargetConfig, rollbackDeployConfig config.DeployConfig, output string) error {
+	images := bundleImages(target)
+	imageRefs := make([]string, 0, len(images))
+	for _, image := range images {
+		imageRefs = append(imageRefs, image.ImageRef())
+	}
+	if len(imageRefs) == 0 {
+		return fmt.Errorf("target '%s' has no image to bundle", targetName)
+	}
+	if err := checkDockerAvailable(ctx, imageRefs); err != nil {
+		return err
+	}
+
+	for _, image := range images {
+		imageRef := image.ImageRef()
+		if image.ShouldBuild() {
+			if err := BuildImage(ctx, imageRef, image, configPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := runCLICommandOutput(ctx, "docker", "image", "inspect", imageRef); err == nil {
+			continue
+		}
+		ui.Info("Pulling image %s", imageRef)
+		if err := runCLICommandInDir(ctx, ".", "docker", "pull", imageRef); err != nil {
+			return fmt.Errorf("failed to pull image %s: %w", imageRef, err)
+		}
+	}
+
+	errorPages, err := readErrorPages(target, configPath)
+	if err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp("", "haloy-bundle-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	imagesPath := filepath.Join(tempDir, "images.tar")
+	ui.Info("Saving %d image(s)", len(imageRefs))
+	if err := runCLICommandInDir(ctx, ".", "docker", append([]string{"save", "-o", imagesPath}, imageRefs...)...); err != nil {
+		return fmt.Errorf("failed to save images: %w", err)
+	}
+	imagesFile, err := os.Open(imagesPath)
+	if err != nil {
+		return fmt.Errorf("failed to open saved images: %w", err)
+	}
+	defer imagesFile.Close()
+	info, err := imagesFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat saved images: %w", err)
+	}
+
+	rollbackDeployConfig.TargetConfig = offlineTarget(rollbackDeployConfig.TargetConfig)
+	request := apitypes.DeployRequest{
+		TargetConfig:         offlineTarget(target),
+		RollbackDeployConfig: rollbackDeployConfig,
+		ErrorPages:           errorPages,
+	}
+	manifest := bundle.Manifest{
+		CreatedAt:  time.Now().UTC(),
+		Target:     targetName,
+		App:        target.Name,
+		Images:     imageRefs,
+		ImagesSize: info.Size(),
+	}
+
+	// Written next to the destination and renamed, so an interrupted run
+	// never leaves a truncated bundle under the final name.
+	out, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer os.Remove(out.Name())
+	if err := out.Chmod(constants.ModeFileSecret); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+
+	progress := ui.NewProgressBar(ui.ProgressBarConfig{
+		Description: "Writing bundle",
+		TotalBytes:  info.Size(),
+		ShowBytes:   true,
+	})
+	err = bundle.Write(out, manifest, request, &progressReader{reader: imagesFile, progress: progress})
+	progress.Finish()
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(out.Name(), output); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	bundleSize := info.Size()
+	if stat, err := os.Stat(output); err == nil {
+		bundleSize = stat.Size()
+	}
+	ui.Success("Created bundle %s (%s) for %s", output, ui.FormatBytes(bundleSize), targetName)
+	ui.Warn("The bundle contains resolved secrets, keep it private")
+	ui.Info("Deploy it on the server with: haloyd bundle import %s", filepath.Base(output))
+	return nil
+}
+
+// bundleImages returns the distinct images the target runs: its own and its
+// sidecars'.
+func bundleImages(target config.TargetConfig) []*config.Image {
+	var images []*config.Image
+	add := func(image *config.Image) {
+		if image == nil {
+			return
+		}
+		if slices.ContainsFunc(images, func(i *config.Image) bool { return i.ImageRef() == image.ImageRef() }) {
+			return
+		}
+		images = append(images, image)
+	}
+	add(target.Image)
+	for _, sidecar := range target.Sidecars {
+		add(sidecar.Image)
+	}
+	return images
+}
+
+// offlineTarget returns target with every image set to run from haloyd's
+// local Docker store, where importing the bundle puts it.
+func offlineTarget(target config.TargetConfig) config.TargetConfig {
+	target.Image = offlineImage(target.Image)
+	if len(target.Sidecars) > 0 {
+		sidecars := slices.Clone(target.Sidecars)
+		for i := range sidecars {
+			sidecars[i].Image = offlineImage(sidecars[i].Image)
+		}
+		target.Sidecars = sidecars
+	}
+	return target
+}
+
+func offlineImage(image *config.Image) *config.Image {
+	if image == nil {
+		return nil
+	}
+	offline := *image
+	build := false
+	offline.Build = &build
+	offline.BuildConfig = nil
+	offline.RegistryAuth = nil
+	offline.PullPolicy = config.PullPolicyNever
+	return &offline
+}
@@ -0,0 +1,64 @@
+package haloy
+
+import (
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestOfflineTarget(t *testing.T) {
+	build := true
+	target := config.TargetConfig{
+		Name: "web",
+		Image: &config.Image{
+			Repository:   "ghcr.io/acme/web",
+			Tag:          "v1",
+			Build:        &build,
+			BuildConfig:  &config.BuildConfig{Push: config.BuildPushOptionRegistry},
+			RegistryAuth: &config.RegistryAuth{Username: config.ValueSource{Value: "u"}},
+		},
+		Sidecars: []config.Sidecar{{Name: "worker", Image: &config.Image{Repository: "redis", Tag: "7"}}},
+	}
+
+	got := offlineTarget(target)
+
+	for _, image := range []*config.Image{got.Image, got.Sidecars[0].Image} {
+		if image.ShouldBuild() {
+			t.Errorf("%s should not build", image.ImageRef())
+		}
+		if image.EffectivePullPolicy() != config.PullPolicyNever {
+			t.Errorf("%s pull policy = %s, want never", image.ImageRef(), image.EffectivePullPolicy())
+		}
+		if image.RegistryAuth != nil {
+			t.Errorf("%s keeps registry auth", image.ImageRef())
+		}
+		if err := image.Validate("yaml"); err != nil {
+			t.Errorf("%s: Validate() error = %v", image.ImageRef(), err)
+		}
+	}
+	if got.Image.ImageRef() != "ghcr.io/acme/web:v1" {
+		t.Errorf("ImageRef() = %s, want ghcr.io/acme/web:v1", got.Image.ImageRef())
+	}
+
+	if !target.Image.ShouldBuild() || target.Image.RegistryAuth == nil || target.Sidecars[0].Image.PullPolicy != "" {
+		t.Error("offlineTarget modified the original target")
+	}
+}
+
+func TestBundleImagesDeduplicates(t *testing.T) {
+	target := config.TargetConfig{
+		Image: &config.Image{Repository: "web", Tag: "v1"},
+		Sidecars: []config.Sidecar{
+			{Name: "worker", Image: &config.Image{Repository: "web", Tag: "v1"}},
+			{Name: "cache", Image: &config.Image{Repository: "redis", Tag: "7"}},
+		},
+	}
+
+	images := bundleImages(target)
+	if len(images) != 2 {
+		t.Fatalf("got %d images, want 2", len(images))
+	}
+	if images[0].ImageRef() != "web:v1" || images[1].ImageRef() != "redis:7" {
+		t.Fatalf("images = %s, %s", images[0].ImageRef(), images[1].ImageRef())
+	}
+}
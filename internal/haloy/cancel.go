@@ -0,0 +1,125 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// errDeploymentNotFound is returned by cancelDeployment for servers that
+// don't know the deployment.
+var errDeploymentNotFound = errors.New("deployment not found")
+
+func CancelCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "cancel <deployment-id>",
+		Short: "Cancel a deployment that hasn't gone live",
+		Long: `Cancel a deployment on the server. A deployment still pulling its image or
+starting its containers is stopped; one whose containers are waiting on their
+health checks has them removed. Either way the containers it started are
+removed and the app keeps serving the deployment it had. A deployment that
+already went live can't be canceled; roll back instead.
+
+The deployment ID is shown when a deploy starts. Without --server, the
+deployment is canceled on every server of the targets in the deploy config
+that knows it.
+
+Examples:
+  haloy cancel 01JQ4Z8N5X3M1T2V7B9C0D6E4F
+  haloy cancel 01JQ4Z8N5X3M1T2V7B9C0D6E4F --server prod`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			deploymentID := args[0]
+			if serverFlag != "" {
+				return cancelDeployment(ctx, nil, resolveServerRef(serverFlag), deploymentID, "")
+			}
+
+			servers, err := resolveServerTargets(ctx, cmd, *configPath, flags)
+			if err != nil {
+				return err
+			}
+
+			found := make([]bool, len(servers))
+			g, ctx := errgroup.WithContext(ctx)
+			for i, serverTarget := range servers {
+				g.Go(func() error {
+					prefix := ""
+					if len(servers) > 1 {
+						prefix = serverTarget.Server
+					}
+					err := cancelDeployment(ctx, serverTarget.TargetConfig, serverTarget.Server, deploymentID, prefix)
+					if errors.Is(err, errDeploymentNotFound) {
+						return nil
+					}
+					found[i] = err == nil
+					return err
+				})
+			}
+			if err := g.Wait(); err != nil {
+				return err
+			}
+			for _, ok := range found {
+				if ok {
+					return nil
+				}
+			}
+			names := make([]string, len(servers))
+			for i, serverTarget := range servers {
+				names[i] = serverTarget.Server
+			}
+			return fmt.Errorf("deployment %s not found on %s", deploymentID, strings.Join(names, ", "))
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringVarP(&serverFlag, "server", "s", "", "Server URL or profile name (overrides config file)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Cancel on the servers of specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Cancel on the servers of all targets")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+
+	return cmd
+}
+
+func cancelDeployment(ctx context.Context, targetConfig *config.TargetConfig, targetServer, deploymentID, prefix string) error {
+	token, err := getToken(targetConfig, targetServer)
+	if err != nil {
+		return &PrefixedError{Err: fmt.Errorf("unable to get token: %w", err), Prefix: prefix}
+	}
+
+	api, err := apiclient.New(targetServer, token)
+	if err != nil {
+		return &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
+	}
+
+	var response apitypes.CancelDeploymentResponse
+	path := fmt.Sprintf("deployments/%s/cancel", url.PathEscape(deploymentID))
+	if err := api.Post(ctx, path, nil, &response); err != nil {
+		var httpErr *apiclient.HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+			if httpErr.Body == "404 page not found" {
+				err = errors.New("the server doesn't support canceling deployments, upgrade haloyd to use this command")
+			} else {
+				return errDeploymentNotFound
+			}
+		}
+		return &PrefixedError{Err: fmt.Errorf("failed to cancel deployment %s: %w", deploymentID, err), Prefix: prefix}
+	}
+
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	pui.Success("Canceled deployment %s of %s", deploymentID, response.App)
+	return nil
+}
@@ -0,0 +1,41 @@
+package haloy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+)
+
+func TestCancelDeployment(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+		case "/v1/deployments/d1/cancel":
+			json.NewEncoder(w).Encode(apitypes.CancelDeploymentResponse{DeploymentID: "d1", App: "app"})
+		case "/v1/deployments/d2/cancel":
+			http.Error(w, "Deployment not found", http.StatusNotFound)
+		case "/v1/deployments/d3/cancel":
+			http.Error(w, "Deployment d3 is live and can no longer be canceled", http.StatusConflict)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	target := targetWithServer(srv.URL)
+
+	if err := cancelDeployment(context.Background(), &target, srv.URL, "d1", ""); err != nil {
+		t.Fatalf("cancelDeployment() unexpected error: %v", err)
+	}
+	if err := cancelDeployment(context.Background(), &target, srv.URL, "d2", ""); !errors.Is(err, errDeploymentNotFound) {
+		t.Errorf("cancelDeployment() of an unknown deployment error = %v, want errDeploymentNotFound", err)
+	}
+	if err := cancelDeployment(context.Background(), &target, srv.URL, "d3", ""); err == nil || !strings.Contains(err.Error(), "can no longer be canceled") {
+		t.Errorf("cancelDeployment() of a live deployment error = %v, want the server's reason", err)
+	}
+}
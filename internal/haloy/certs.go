@@ -0,0 +1,168 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+func CertsCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "certs",
+		Short: "Inspect TLS certificates on the server",
+	}
+
+	cmd.AddCommand(CertsStatusCmd(configPath, flags))
+
+	return cmd
+}
+
+func CertsStatusCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show certificates and failing certificate requests",
+		Long: `Show the certificates a Haloy server manages with their expiry, and
+the domains whose certificate requests are failing. Failing domains are
+retried with increasing delays so they don't run into the certificate
+authority's rate limits; the output shows when the next attempt is due.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			if serverFlag != "" {
+				status, err := getCertificatesStatus(ctx, nil, resolveServerRef(serverFlag), "")
+				if err != nil {
+					return err
+				}
+				printCertificatesStatus(status, "")
+				return nil
+			}
+
+			servers, err := resolveServerTargets(ctx, cmd, *configPath, flags)
+			if err != nil {
+				return err
+			}
+
+			statuses := make([]*apitypes.CertificatesResponse, len(servers))
+			g, ctx := errgroup.WithContext(ctx)
+			for i, serverTarget := range servers {
+				g.Go(func() error {
+					prefix := ""
+					if len(servers) > 1 {
+						prefix = serverTarget.Server
+					}
+					status, err := getCertificatesStatus(ctx, serverTarget.TargetConfig, serverTarget.Server, prefix)
+					if err != nil {
+						return err
+					}
+					statuses[i] = status
+					return nil
+				})
+			}
+			if err := g.Wait(); err != nil {
+				return err
+			}
+
+			for i, status := range statuses {
+				prefix := ""
+				if len(servers) > 1 {
+					prefix = servers[i].Server
+				}
+				printCertificatesStatus(status, prefix)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringVarP(&serverFlag, "server", "s", "", "Server URL or profile name (overrides config file)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Show certificates for specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Show certificates for all targets")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+
+	return cmd
+}
+
+func getCertificatesStatus(ctx context.Context, targetConfig *config.TargetConfig, targetServer, prefix string) (*apitypes.CertificatesResponse, error) {
+	token, err := getToken(targetConfig, targetServer)
+	if err != nil {
+		return nil, &PrefixedError{Err: fmt.Errorf("unable to get token: %w", err), Prefix: prefix}
+	}
+
+	api, err := apiclient.New(targetServer, token)
+	if err != nil {
+		return nil, &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
+	}
+
+	var response apitypes.CertificatesResponse
+	if err := api.Get(ctx, "certificates", &response); err != nil {
+		if errors.Is(err, apiclient.ErrNotFound) {
+			err = errors.New("the server doesn't report certificate status, upgrade haloyd to use this command")
+		}
+		return nil, &PrefixedError{Err: fmt.Errorf("failed to get certificates from API: %w", err), Prefix: prefix}
+	}
+	return &response, nil
+}
+
+func printCertificatesStatus(status *apitypes.CertificatesResponse, prefix string) {
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	if len(status.Certificates) == 0 {
+		pui.Info("No certificates")
+		return
+	}
+
+	rows := make([][]string, 0, len(status.Certificates))
+	for _, c := range status.Certificates {
+		expires := "-"
+		if c.NotAfter != nil {
+			expires = helpers.FormatTime(*c.NotAfter)
+			if c.Staging {
+				expires += " (staging)"
+			}
+		}
+		rows = append(rows, []string{c.Domain, strings.Join(c.Names, ", "), expires, certificateIssuanceState(c)})
+	}
+	ui.Table([]string{"DOMAIN", "NAMES", "EXPIRES", "ISSUANCE"}, rows)
+
+	for _, c := range status.Certificates {
+		if c.Failures > 0 {
+			pui.Warn("%s: %s", c.Domain, c.LastError)
+		}
+	}
+}
+
+// certificateIssuanceState describes the issuance of c, e.g. "failed 3 times,
+// retrying 2 hours from now due to rate limit".
+func certificateIssuanceState(c apitypes.CertificateStatus) string {
+	if c.Failures == 0 {
+		return "ok"
+	}
+	times := "times"
+	if c.Failures == 1 {
+		times = "time"
+	}
+	state := fmt.Sprintf("failed %d %s", c.Failures, times)
+	if c.NextAttemptAt != nil {
+		if time.Until(*c.NextAttemptAt) > 0 {
+			state += ", retrying " + helpers.FormatTime(*c.NextAttemptAt)
+		} else {
+			state += ", retrying on the next check"
+		}
+	}
+	if c.RateLimited {
+		state += " due to rate limit"
+	}
+	return state
+}
@@ -0,0 +1,34 @@
+package haloy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+)
+
+func TestCertificateIssuanceState(t *testing.T) {
+	later := time.Now().Add(2*time.Hour + time.Minute)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name   string
+		status apitypes.CertificateStatus
+		want   []string
+	}{
+		{"issued", apitypes.CertificateStatus{Domain: "example.com"}, []string{"ok"}},
+		{"rate limited", apitypes.CertificateStatus{Failures: 3, RateLimited: true, NextAttemptAt: &later}, []string{"failed 3 times", "retrying 2 hours from now", "due to rate limit"}},
+		{"due", apitypes.CertificateStatus{Failures: 1, NextAttemptAt: &past}, []string{"failed 1 time,", "retrying on the next check"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := certificateIssuanceState(tt.status)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("certificateIssuanceState() = %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package haloy
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+// changedFiles returns the absolute paths of the files in the git repository
+// containing dir that differ from ref, including uncommitted and untracked
+// ones.
+func changedFiles(ctx context.Context, dir, ref string) ([]string, error) {
+	topLevel, err := runCLICommandOutput(ctx, "git", "-C", dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository: %w", dir, err)
+	}
+	topLevel = strings.TrimSpace(topLevel)
+
+	diff, err := runCLICommandOutput(ctx, "git", "-C", topLevel, "diff", "--name-only", ref, "--")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files changed since %s: %w", ref, err)
+	}
+	untracked, err := runCLICommandOutput(ctx, "git", "-C", topLevel, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files: %w", err)
+	}
+
+	var files []string
+	for _, line := range strings.Split(diff+"\n"+untracked, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, filepath.Join(topLevel, filepath.FromSlash(line)))
+		}
+	}
+	return files, nil
+}
+
+// targetChange is why a target is deployed or skipped by 'deploy --since'.
+type targetChange struct {
+	changed bool
+	reason  string
+}
+
+// planChangedTargets decides which targets have inputs changed since ref.
+// The inputs of a target are its config file and, for images built locally,
+// the build context and dockerfile. Targets running a prebuilt image only
+// change with the config.
+func planChangedTargets(ctx context.Context, configPath string, targets map[string]config.TargetConfig, ref string) (map[string]targetChange, error) {
+	configFile, err := configloader.FindConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := changedFiles(ctx, filepath.Dir(configFile), ref)
+	if err != nil {
+		return nil, err
+	}
+	// git reports paths below the real repository root, so compare against
+	// symlink-free paths.
+	configFile = evalSymlinks(configFile)
+	configChanged := slices.Contains(changed, configFile)
+
+	plan := make(map[string]targetChange, len(targets))
+	for targetName, target := range targets {
+		switch {
+		case configChanged:
+			plan[targetName] = targetChange{changed: true, reason: "config changed"}
+		case target.Image == nil || !target.Image.ShouldBuild():
+			plan[targetName] = targetChange{reason: "config unchanged, image is not built"}
+		default:
+			paths, err := resolveBuildPaths(configPath, target.Image.BuildConfig)
+			if err != nil {
+				return nil, fmt.Errorf("target '%s': %w", targetName, err)
+			}
+			contextDir := evalSymlinks(paths.ContextDir)
+			dockerfile := evalSymlinks(paths.Dockerfile)
+			switch {
+			case slices.Contains(changed, dockerfile):
+				plan[targetName] = targetChange{changed: true, reason: "dockerfile changed"}
+			case slices.ContainsFunc(changed, func(file string) bool { return isBelow(file, contextDir) }):
+				plan[targetName] = targetChange{changed: true, reason: "build context changed"}
+			default:
+				plan[targetName] = targetChange{reason: "no changes in config or build inputs"}
+			}
+		}
+	}
+	return plan, nil
+}
+
+// filterChangedTargets prints the plan and drops unchanged targets from
+// rawTargets and resolvedTargets.
+func filterChangedTargets(ctx context.Context, configPath, ref string, rawTargets, resolvedTargets map[string]config.TargetConfig) error {
+	plan, err := planChangedTargets(ctx, configPath, resolvedTargets, ref)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(plan))
+	for _, targetName := range slices.Sorted(maps.Keys(plan)) {
+		change := plan[targetName]
+		action := "deploy"
+		if !change.changed {
+			action = "skip"
+			delete(rawTargets, targetName)
+			delete(resolvedTargets, targetName)
+		}
+		rows = append(rows, []string{targetName, action, change.reason})
+	}
+	ui.Info("Changes since %s:", ref)
+	ui.Table([]string{"TARGET", "ACTION", "REASON"}, rows)
+	return nil
+}
+
+func isBelow(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+func evalSymlinks(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
@@ -0,0 +1,97 @@
+package haloy
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func gitTestRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for name, content := range files {
+		writeRepoFile(t, dir, name, content)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func writeRepoFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanChangedTargets(t *testing.T) {
+	dir := gitTestRepo(t, map[string]string{
+		"haloy.yaml":        "name: app\n",
+		"api/Dockerfile":    "FROM scratch\n",
+		"api/main.go":       "package main\n",
+		"web/Dockerfile":    "FROM scratch\n",
+		"web/index.html":    "<html></html>\n",
+		"docker/web.Docker": "FROM scratch\n",
+	})
+
+	build := true
+	targets := map[string]config.TargetConfig{
+		"api": {Image: &config.Image{Repository: "api", Build: &build, BuildConfig: &config.BuildConfig{Context: "api"}}},
+		"web": {Image: &config.Image{Repository: "web", Build: &build, BuildConfig: &config.BuildConfig{Context: "web", Dockerfile: "../docker/web.Docker"}}},
+		"db":  {Image: &config.Image{Repository: "postgres"}},
+	}
+
+	plan := func() map[string]targetChange {
+		t.Helper()
+		plan, err := planChangedTargets(context.Background(), dir, targets, "HEAD")
+		if err != nil {
+			t.Fatalf("planChangedTargets() unexpected error: %v", err)
+		}
+		return plan
+	}
+
+	for name, change := range plan() {
+		if change.changed {
+			t.Errorf("target %s changed without changes: %s", name, change.reason)
+		}
+	}
+
+	writeRepoFile(t, dir, "api/main.go", "package main\n\nfunc main() {}\n")
+	writeRepoFile(t, dir, "docker/web.Docker", "FROM alpine\n")
+	got := plan()
+	if c := got["api"]; !c.changed || c.reason != "build context changed" {
+		t.Errorf("api = %+v, want changed build context", c)
+	}
+	if c := got["web"]; !c.changed || c.reason != "dockerfile changed" {
+		t.Errorf("web = %+v, want changed dockerfile", c)
+	}
+	if got["db"].changed {
+		t.Errorf("db changed, but its image isn't built and the config is unchanged")
+	}
+
+	writeRepoFile(t, dir, "haloy.yaml", "name: app2\n")
+	for name, change := range plan() {
+		if !change.changed || change.reason != "config changed" {
+			t.Errorf("target %s = %+v, want changed config", name, change)
+		}
+	}
+}
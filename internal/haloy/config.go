@@ -0,0 +1,77 @@
+package haloy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func ConfigCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the haloy config file",
+	}
+
+	cmd.AddCommand(ConfigMigrateCmd(configPath, flags))
+
+	return cmd
+}
+
+func ConfigMigrateCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Update the config file to the current schema",
+		Long: fmt.Sprintf(`Update the config file to schema %d, renaming deprecated fields and
+setting 'schema'.
+
+The file is rewritten in place. YAML files keep their comments; JSON and TOML
+files are re-encoded, which sorts their keys and drops TOML comments.`, config.SchemaVersion),
+		Example: `  haloy config migrate
+  haloy config migrate --dry-run`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			configFile, err := configloader.FindConfigFile(*configPath)
+			if err != nil {
+				return err
+			}
+			info, err := os.Stat(configFile)
+			if err != nil {
+				return err
+			}
+
+			data, deprecations, err := configloader.MigrateConfigFile(configFile)
+			if err != nil {
+				return fmt.Errorf("unable to migrate %s: %w", filepath.Base(configFile), err)
+			}
+			if data == nil {
+				ui.Success("%s already uses schema %d", filepath.Base(configFile), config.SchemaVersion)
+				return nil
+			}
+
+			if dryRun {
+				_, err := cmd.OutOrStdout().Write(data)
+				return err
+			}
+			if err := os.WriteFile(configFile, data, info.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to write %s: %w", configFile, err)
+			}
+			for _, d := range deprecations {
+				ui.Info("Renamed %s", d.Change())
+			}
+			ui.Success("Migrated %s to schema %d", filepath.Base(configFile), config.SchemaVersion)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the migrated config instead of writing it")
+
+	return cmd
+}
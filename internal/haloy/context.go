@@ -0,0 +1,211 @@
+package haloy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func ContextCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "context",
+		Short: "Manage server profiles",
+		Long: `Manage server profiles, named servers added with
+'haloy server add <name> <url> <token>'.
+
+The current profile is the server for deploy configs that don't set 'server',
+and its default target is used when a command gets no --targets or --all.`,
+	}
+
+	cmd.AddCommand(ContextListCmd())
+	cmd.AddCommand(UseProfileCmd())
+	cmd.AddCommand(ContextCurrentCmd())
+	cmd.AddCommand(ContextSetCmd())
+
+	return cmd
+}
+
+func ContextListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List server profiles",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			clientConfig, err := config.LoadDefaultClientConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load client config: %w", err)
+			}
+			if clientConfig == nil || len(clientConfig.Profiles) == 0 {
+				return errors.New("no profiles found, add one with 'haloy server add <name> <url> <token>'")
+			}
+
+			ui.Table([]string{"CURRENT", "NAME", "SERVER", "DEFAULT TARGET", "TOKEN"}, contextRows(clientConfig))
+			return nil
+		},
+	}
+	return cmd
+}
+
+func contextRows(clientConfig *config.ClientConfig) [][]string {
+	rows := make([][]string, 0, len(clientConfig.Profiles))
+	for _, name := range clientConfig.ListProfiles() {
+		profile := clientConfig.Profiles[name]
+		current := ""
+		if name == clientConfig.CurrentProfile {
+			current = "*"
+		}
+		rows = append(rows, []string{current, name, profile.Server, profile.DefaultTarget, tokenSource(clientConfig.Servers[profile.Server])})
+	}
+	return rows
+}
+
+// UseProfileCmd is both 'haloy context use' and 'haloy server use', next to
+// 'server add' which creates the profiles.
+func UseProfileCmd() *cobra.Command {
+	var unset bool
+
+	cmd := &cobra.Command{
+		Use:   "use <name>",
+		Short: "Make a server profile the current one",
+		Long: `Make a server profile the current one. Deploy configs without 'server'
+deploy to the current profile's server.
+
+Examples:
+  haloy server use prod
+
+  # Go back to no current profile
+  haloy server use --unset`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if unset {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clientConfig, path, err := loadClientConfigForUpdate()
+			if err != nil {
+				return err
+			}
+
+			if unset {
+				clientConfig.CurrentProfile = ""
+			} else if err := clientConfig.UseProfile(args[0]); err != nil {
+				return err
+			}
+
+			if err := config.SaveClientConfig(clientConfig, path); err != nil {
+				return fmt.Errorf("failed to save client config: %w", err)
+			}
+
+			if unset {
+				ui.Success("No profile is current")
+				return nil
+			}
+			ui.Success("Using profile %s (%s)", args[0], clientConfig.Profiles[args[0]].Server)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&unset, "unset", false, "Clear the current profile")
+	cmd.ValidArgsFunction = completeProfileNames
+
+	return cmd
+}
+
+func ContextCurrentCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "current",
+		Short: "Show the current server profile",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			clientConfig, err := config.LoadDefaultClientConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load client config: %w", err)
+			}
+			name, profile, ok := clientConfig.Current()
+			if !ok {
+				return errors.New("no current profile, set one with 'haloy server use <name>'")
+			}
+			fmt.Printf("%s\t%s\n", name, profile.Server)
+			return nil
+		},
+	}
+	return cmd
+}
+
+func ContextSetCmd() *cobra.Command {
+	var defaultTarget string
+
+	cmd := &cobra.Command{
+		Use:   "set <name>",
+		Short: "Change the defaults of a server profile",
+		Long: `Change the defaults of a server profile.
+
+Examples:
+  # Deploy the production target when prod is current and no target is selected
+  haloy context set prod --default-target production
+
+  # Remove the default target
+  haloy context set prod --default-target ""`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("default-target") {
+				return errors.New("nothing to set, use --default-target")
+			}
+
+			clientConfig, path, err := loadClientConfigForUpdate()
+			if err != nil {
+				return err
+			}
+			profile, exists := clientConfig.Profiles[args[0]]
+			if !exists {
+				return fmt.Errorf("profile %s not found", args[0])
+			}
+			profile.DefaultTarget = defaultTarget
+			clientConfig.Profiles[args[0]] = profile
+
+			if err := config.SaveClientConfig(clientConfig, path); err != nil {
+				return fmt.Errorf("failed to save client config: %w", err)
+			}
+			ui.Success("Profile %s updated", args[0])
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&defaultTarget, "default-target", "", "Target to use when the profile is current and no target is selected")
+	cmd.ValidArgsFunction = completeProfileNames
+
+	return cmd
+}
+
+// loadClientConfigForUpdate loads the client config for a command that
+// changes profiles, which only exist once a server was added.
+func loadClientConfigForUpdate() (*config.ClientConfig, string, error) {
+	path, err := config.ClientConfigPath()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get config dir: %w", err)
+	}
+	clientConfig, err := config.LoadClientConfig(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load client config: %w", err)
+	}
+	if clientConfig == nil {
+		return nil, "", fmt.Errorf("no config file found in %s", path)
+	}
+	return clientConfig, path, nil
+}
+
+func completeProfileNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	clientConfig, err := config.LoadDefaultClientConfig()
+	if err != nil || clientConfig == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return clientConfig.ListProfiles(), cobra.ShellCompDirectiveNoFileComp
+}
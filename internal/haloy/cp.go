@@ -0,0 +1,428 @@
+package haloy
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// copyTimeout bounds how long the server may take to answer once a copy
+// request has been sent; extracting a large archive can take a while.
+const copyTimeout = 5 * time.Minute
+
+// copyLocation is one side of a 'haloy cp' argument.
+type copyLocation struct {
+	remote bool
+	target string // target name, empty for the only/default target
+	path   string
+}
+
+// parseCopyLocation splits "[target]:/path" from a local path. Container
+// paths must be absolute, which keeps local paths containing ':' usable.
+func parseCopyLocation(arg string) copyLocation {
+	target, p, found := strings.Cut(arg, ":")
+	if found && !strings.ContainsAny(target, `/\`) && strings.HasPrefix(p, "/") {
+		return copyLocation{remote: true, target: target, path: p}
+	}
+	return copyLocation{path: arg}
+}
+
+func CpCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var (
+		allContainers bool
+		containerID   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "cp <src> <dest>",
+		Short: "Copy files between an application container and the local machine",
+		Long: `Copy files or directories between a running application container and the
+local machine. Container paths are written as [target]:/absolute/path; the
+target can be omitted for single-target configs.
+
+Copying from a container reads from the first container unless --container
+is given. Copying into a container writes to the first container, a specific
+one, or all of them with --all-containers.
+
+Use '-' as the local destination to write the tar archive to stdout.`,
+		Example: `  # Grab a heap dump from the app
+  haloy cp :/tmp/heap.pprof ./heap.pprof
+
+  # Download a directory from the prod target
+  haloy cp prod:/var/log/app ./logs
+
+  # Push a hotfix asset to every replica
+  haloy cp ./dist/app.js prod:/app/public/app.js --all-containers`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			src, dst := parseCopyLocation(args[0]), parseCopyLocation(args[1])
+			if src.remote == dst.remote {
+				return fmt.Errorf("exactly one of <src> and <dest> must be a container path ([target]:/path)")
+			}
+			if allContainers && containerID != "" {
+				return fmt.Errorf("cannot specify both --all-containers and --container")
+			}
+			if src.remote && allContainers {
+				return fmt.Errorf("--all-containers can only be used when copying into containers")
+			}
+
+			remote := src
+			if dst.remote {
+				remote = dst
+			}
+			target, err := loadCopyTarget(ctx, *configPath, remote.target)
+			if err != nil {
+				return err
+			}
+
+			token, err := getToken(&target, target.Server)
+			if err != nil {
+				return fmt.Errorf("unable to get token: %w", err)
+			}
+			api, err := apiclient.NewWithTimeout(target.Server, token, copyTimeout)
+			if err != nil {
+				return fmt.Errorf("unable to create API client: %w", err)
+			}
+
+			query := url.Values{"path": {remote.path}}
+			if containerID != "" {
+				query.Set("container", containerID)
+			}
+			if src.remote {
+				return copyFromContainer(ctx, api, target.Name, query, dst.path)
+			}
+			if allContainers {
+				query.Set("all", "true")
+			}
+			return copyToContainer(ctx, api, target.Name, query, src.path)
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().BoolVar(&allContainers, "all-containers", false, "Copy into all containers")
+	cmd.Flags().StringVar(&containerID, "container", "", "Copy from or to a specific container ID")
+
+	return cmd
+}
+
+// loadCopyTarget resolves the target named in a container path, or the only
+// target when none is named.
+func loadCopyTarget(ctx context.Context, configPath, targetName string) (config.TargetConfig, error) {
+	var targetNames []string
+	if targetName != "" {
+		targetNames = []string{targetName}
+	}
+
+	rawDeployConfig, format, err := configloader.Load(ctx, configPath, targetNames, false)
+	if err != nil {
+		return config.TargetConfig{}, fmt.Errorf("unable to load config: %w", err)
+	}
+	resolvedDeployConfig, err := configloader.ResolveSecrets(ctx, rawDeployConfig, configPath)
+	if err != nil {
+		return config.TargetConfig{}, fmt.Errorf("unable to resolve secrets: %w", err)
+	}
+	targets, err := configloader.ExtractTargets(resolvedDeployConfig, format)
+	if err != nil {
+		return config.TargetConfig{}, err
+	}
+	if len(targets) != 1 {
+		return config.TargetConfig{}, fmt.Errorf("config has %d targets; name one in the container path, e.g. <target>:/path", len(targets))
+	}
+	for _, target := range targets {
+		return target, nil
+	}
+	return config.TargetConfig{}, nil
+}
+
+func copyFromContainer(ctx context.Context, api *apiclient.APIClient, appName string, query url.Values, localPath string) error {
+	req, err := api.NewRequest(ctx, http.MethodGet, "cp/"+appName+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := api.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to copy from container: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := copyResponseError(resp); err != nil {
+		return err
+	}
+
+	if localPath == "-" {
+		_, err := io.Copy(os.Stdout, resp.Body)
+		return err
+	}
+
+	var body io.Reader = resp.Body
+	var progress *ui.ProgressBar
+	if size, err := strconv.ParseInt(resp.Header.Get("X-Haloy-Copy-Size"), 10, 64); err == nil && size > 0 {
+		progress = ui.NewProgressBar(ui.ProgressBarConfig{
+			Description: "Downloading",
+			TotalBytes:  size,
+			ShowBytes:   true,
+		})
+		body = &progressReader{reader: resp.Body, progress: progress}
+	}
+
+	written, err := extractCopyArchive(body, localPath)
+	if progress != nil {
+		progress.Finish()
+	}
+	if err != nil {
+		return err
+	}
+	ui.Success("Copied %s from container %s to %s", ui.FormatBytes(written), resp.Header.Get("X-Haloy-Container"), localPath)
+	return nil
+}
+
+func copyToContainer(ctx context.Context, api *apiclient.APIClient, appName string, query url.Values, localPath string) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", localPath, err)
+	}
+	total, err := localCopySize(localPath, info)
+	if err != nil {
+		return err
+	}
+
+	progress := ui.NewProgressBar(ui.ProgressBarConfig{
+		Description: "Uploading",
+		TotalBytes:  total,
+		ShowBytes:   true,
+	})
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeCopyArchive(pw, localPath, progress))
+	}()
+	defer pr.Close()
+
+	req, err := api.NewRequest(ctx, http.MethodPut, "cp/"+appName+"?"+query.Encode(), pr)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+
+	resp, err := api.Do(req)
+	progress.Finish()
+	if err != nil {
+		return fmt.Errorf("failed to copy to container: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := copyResponseError(resp); err != nil {
+		return err
+	}
+
+	var response apitypes.CopyToContainerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var failed int
+	for _, result := range response.Results {
+		if result.Error != "" {
+			ui.Error("Container %s: %s", result.ContainerID, result.Error)
+			failed++
+			continue
+		}
+		ui.Success("Copied %s to container %s:%s", localPath, result.ContainerID, query.Get("path"))
+	}
+	if failed > 0 {
+		return fmt.Errorf("copy failed for %d of %d containers", failed, len(response.Results))
+	}
+	return nil
+}
+
+func copyResponseError(resp *http.Response) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return &apiclient.HTTPError{Method: resp.Request.Method, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+}
+
+// localCopySize returns the number of file bytes an upload of root will send.
+func localCopySize(root string, info fs.FileInfo) (int64, error) {
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+	var total int64
+	err := filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += fi.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", root, err)
+	}
+	return total, nil
+}
+
+// writeCopyArchive writes root as a tar archive whose single root entry is
+// root's base name. Symlinks are archived as links, not followed.
+func writeCopyArchive(w io.Writer, root string, progress *ui.ProgressBar) error {
+	tw := tar.NewWriter(w)
+	base := filepath.Base(filepath.Clean(root))
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(base, filepath.ToSlash(rel))
+
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		var content io.Reader = f
+		if progress != nil {
+			content = &progressReader{reader: f, progress: progress}
+		}
+		_, err = io.Copy(tw, content)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", root, err)
+	}
+	return tw.Close()
+}
+
+// extractCopyArchive extracts an archive from the server to localPath, with
+// docker cp semantics: into localPath when it is an existing directory,
+// otherwise as localPath itself. Returns the number of file bytes written.
+func extractCopyArchive(r io.Reader, localPath string) (int64, error) {
+	destDir := localPath
+	var rename string
+	if info, err := os.Stat(localPath); err != nil || !info.IsDir() {
+		destDir = filepath.Dir(localPath)
+		rename = filepath.Base(localPath)
+	}
+
+	var written int64
+	symlinks := make(map[string]bool)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return written, nil
+		}
+		if err != nil {
+			return written, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if rename != "" {
+			if _, rest, ok := strings.Cut(name, "/"); ok {
+				name = rename + "/" + rest
+			} else {
+				name = rename
+			}
+		}
+		if !filepath.IsLocal(name) || underSymlink(name, symlinks) {
+			return written, fmt.Errorf("archive entry %q escapes the destination", hdr.Name)
+		}
+		target := filepath.Join(destDir, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, hdr.FileInfo().Mode().Perm()|0o700); err != nil {
+				return written, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return written, err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return written, err
+			}
+			n, err := io.Copy(f, tr)
+			written += n
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return written, fmt.Errorf("failed to write %s: %w", target, err)
+			}
+		case tar.TypeSymlink:
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return written, err
+			}
+			symlinks[name] = true
+		default:
+			// Devices, FIFOs and hard links are not useful outside the container.
+			ui.Warn("Skipping %s (unsupported file type)", hdr.Name)
+		}
+	}
+}
+
+// underSymlink reports whether name lies below a symlink extracted earlier,
+// which could otherwise redirect writes outside the destination.
+func underSymlink(name string, symlinks map[string]bool) bool {
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if symlinks[dir] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package haloy
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCopyLocation(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want copyLocation
+	}{
+		{"prod:/var/log/app", copyLocation{remote: true, target: "prod", path: "/var/log/app"}},
+		{":/tmp/heap.pprof", copyLocation{remote: true, path: "/tmp/heap.pprof"}},
+		{"./heap.pprof", copyLocation{path: "./heap.pprof"}},
+		{"notes:draft.txt", copyLocation{path: "notes:draft.txt"}},
+		{"./dir:x/file", copyLocation{path: "./dir:x/file"}},
+	}
+	for _, tt := range tests {
+		if got := parseCopyLocation(tt.arg); got != tt.want {
+			t.Errorf("parseCopyLocation(%q) = %+v, want %+v", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestCopyArchiveRoundTrip(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "assets")
+	if err := os.MkdirAll(filepath.Join(src, "css"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "css", "app.css"), []byte("body{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if err := writeCopyArchive(&archive, src, nil); err != nil {
+		t.Fatalf("writeCopyArchive() error = %v", err)
+	}
+	data := archive.Bytes()
+
+	// Into an existing directory: the root keeps its name.
+	into := t.TempDir()
+	if _, err := extractCopyArchive(bytes.NewReader(data), into); err != nil {
+		t.Fatalf("extractCopyArchive() error = %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(into, "assets", "css", "app.css")); err != nil || string(got) != "body{}" {
+		t.Fatalf("extracted file = %q, %v", got, err)
+	}
+
+	// To a new path: the root is renamed.
+	renamed := filepath.Join(t.TempDir(), "static")
+	written, err := extractCopyArchive(bytes.NewReader(data), renamed)
+	if err != nil {
+		t.Fatalf("extractCopyArchive() error = %v", err)
+	}
+	if written != 6 {
+		t.Errorf("written = %d, want 6", written)
+	}
+	if _, err := os.Stat(filepath.Join(renamed, "css", "app.css")); err != nil {
+		t.Fatalf("renamed extraction missing file: %v", err)
+	}
+}
+
+func TestExtractCopyArchive_RejectsEscapes(t *testing.T) {
+	tests := map[string][]*tar.Header{
+		"parent traversal": {
+			{Name: "app/../../evil", Typeflag: tar.TypeReg, Mode: 0o644},
+		},
+		"write through symlink": {
+			{Name: "app/", Typeflag: tar.TypeDir, Mode: 0o755},
+			{Name: "app/link", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+			{Name: "app/link/passwd", Typeflag: tar.TypeReg, Mode: 0o644},
+		},
+	}
+	for name, entries := range tests {
+		t.Run(name, func(t *testing.T) {
+			var archive bytes.Buffer
+			tw := tar.NewWriter(&archive)
+			for _, hdr := range entries {
+				if err := tw.WriteHeader(hdr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tw.Close()
+
+			if _, err := extractCopyArchive(&archive, t.TempDir()); err == nil {
+				t.Fatal("extractCopyArchive() succeeded, want escape error")
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package haloy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/logging"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+func DBCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Back up and restore managed databases",
+		Long: `Back up and restore the targets with a database section. Backups go to the
+S3 bucket set in database_backups in the server's haloyd.yaml.`,
+	}
+
+	cmd.AddCommand(
+		dbBackupCmd(configPath, flags),
+		dbListCmd(configPath, flags),
+		dbRestoreCmd(configPath, flags),
+	)
+
+	return cmd
+}
+
+func dbBackupCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Back up databases now",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return forEachDatabaseTarget(cmd.Context(), *configPath, flags, func(ctx context.Context, api *apiclient.APIClient, target config.TargetConfig, prefix string) error {
+				pui := &ui.PrefixedUI{Prefix: prefix}
+				pui.Info("Backing up %s", target.Name)
+				request := apitypes.DatabaseBackupRequest{OperationID: createDeploymentID()}
+				return runServerOperation(ctx, api, fmt.Sprintf("db/%s/backups", target.Name), request.OperationID, request, prefix, explainDBError)
+			})
+		},
+	}
+
+	addDBFlags(cmd, flags)
+	return cmd
+}
+
+func dbListCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List database backups",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return forEachDatabaseTarget(cmd.Context(), *configPath, flags, func(ctx context.Context, api *apiclient.APIClient, target config.TargetConfig, prefix string) error {
+				var response apitypes.DatabaseBackupsResponse
+				if err := api.Get(ctx, fmt.Sprintf("db/%s/backups", target.Name), &response); err != nil {
+					return &PrefixedError{Err: fmt.Errorf("failed to list backups: %w", explainDBError(err)), Prefix: prefix}
+				}
+				printDatabaseBackups(target.Name, response.Backups, prefix)
+				return nil
+			})
+		},
+	}
+
+	addDBFlags(cmd, flags)
+	return cmd
+}
+
+func dbRestoreCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var yesFlag bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <backup>",
+		Short: "Restore a database from a backup",
+		Long: `Restore a database from a backup listed by 'haloy db list'. Objects in the
+backup replace the ones in the database; stop the apps using it first.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			backup := args[0]
+			targets, err := databaseTargets(cmd.Context(), *configPath, flags)
+			if err != nil {
+				return err
+			}
+			var matching []config.TargetConfig
+			for _, target := range targets {
+				if strings.HasPrefix(backup, target.Name+"-") {
+					matching = append(matching, target)
+				}
+			}
+			if len(matching) != 1 {
+				return fmt.Errorf("backup '%s' doesn't belong to exactly one selected database target; select it with --targets", backup)
+			}
+			target := matching[0]
+
+			if !yesFlag {
+				answer, err := ui.Prompt(fmt.Sprintf("Restore %s on %s from %s? This replaces its current data. [y/N]", target.Name, target.Server, backup))
+				if err != nil {
+					return err
+				}
+				if !slices.Contains([]string{"y", "yes"}, strings.ToLower(answer)) {
+					return fmt.Errorf("restore of %s cancelled", target.Name)
+				}
+			}
+
+			api, err := newTargetAPIClient(target)
+			if err != nil {
+				return err
+			}
+			ui.Info("Restoring %s from %s", target.Name, backup)
+			request := apitypes.DatabaseRestoreRequest{OperationID: createDeploymentID(), Backup: backup}
+			return runServerOperation(cmd.Context(), api, fmt.Sprintf("db/%s/restore", target.Name), request.OperationID, request, "", explainDBError)
+		},
+	}
+
+	addDBFlags(cmd, flags)
+	cmd.Flags().BoolVarP(&yesFlag, "yes", "y", false, "Skip the confirmation")
+	return cmd
+}
+
+func addDBFlags(cmd *cobra.Command, flags *appCmdFlags) {
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Use specific database targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Use all database targets")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+}
+
+// databaseTargets returns the selected targets that are managed databases.
+func databaseTargets(ctx context.Context, configPath string, flags *appCmdFlags) ([]config.TargetConfig, error) {
+	targets, err := selectedTargets(ctx, configPath, flags, func(target config.TargetConfig) bool {
+		return target.Database != nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("none of the selected targets has a database section")
+	}
+	return targets, nil
+}
+
+// selectedTargets loads the targets picked by flags and returns the ones keep
+// accepts, sorted by name.
+func selectedTargets(ctx context.Context, configPath string, flags *appCmdFlags, keep func(config.TargetConfig) bool) ([]config.TargetConfig, error) {
+	rawDeployConfig, format, err := configloader.Load(ctx, configPath, flags.targets, flags.all)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+	resolvedDeployConfig, err := configloader.ResolveSecrets(ctx, rawDeployConfig, configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+	targets, err := configloader.ExtractTargets(resolvedDeployConfig, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []config.TargetConfig
+	for _, name := range slices.Sorted(maps.Keys(targets)) {
+		if keep(targets[name]) {
+			kept = append(kept, targets[name])
+		}
+	}
+	return kept, nil
+}
+
+func forEachDatabaseTarget(ctx context.Context, configPath string, flags *appCmdFlags, fn func(ctx context.Context, api *apiclient.APIClient, target config.TargetConfig, prefix string) error) error {
+	targets, err := databaseTargets(ctx, configPath, flags)
+	if err != nil {
+		return err
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	for _, target := range targets {
+		g.Go(func() error {
+			prefix := ""
+			if len(targets) > 1 {
+				prefix = target.TargetName
+			}
+			api, err := newTargetAPIClient(target)
+			if err != nil {
+				return &PrefixedError{Err: err, Prefix: prefix}
+			}
+			return fn(ctx, api, target, prefix)
+		})
+	}
+	return g.Wait()
+}
+
+func newTargetAPIClient(target config.TargetConfig) (*apiclient.APIClient, error) {
+	token, err := getToken(&target, target.Server)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get token: %w", err)
+	}
+	api, err := apiclient.New(target.Server, token)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create API client: %w", err)
+	}
+	return api, nil
+}
+
+// runServerOperation starts a long-running operation, like a backup or a
+// restore, and streams its logs until haloyd reports it done. explain turns
+// the error of starting it into one for the user.
+func runServerOperation(ctx context.Context, api *apiclient.APIClient, path, operationID string, request any, prefix string, explain func(error) error) error {
+	if err := api.Post(ctx, path, request, nil); err != nil {
+		return &PrefixedError{Err: explain(err), Prefix: prefix}
+	}
+
+	var failure *logging.LogEntry
+	api.StreamResumable(ctx, fmt.Sprintf("deploy/%s/logs", url.PathEscape(operationID)), func(data string) bool {
+		var logEntry logging.LogEntry
+		if err := json.Unmarshal([]byte(data), &logEntry); err != nil {
+			return false
+		}
+		ui.DisplayLogEntry(logEntry, prefix)
+		if logEntry.IsDeploymentFailed {
+			failure = &logEntry
+		}
+		return logEntry.IsDeploymentComplete
+	})
+	if failure != nil {
+		return &PrefixedError{Err: withExitCode(ExitServer, errors.New("operation failed")), Prefix: prefix}
+	}
+	return nil
+}
+
+// explainDBError points at an outdated haloyd when the endpoint is missing.
+func explainDBError(err error) error {
+	var httpErr *apiclient.HTTPError
+	if errors.Is(err, apiclient.ErrNotFound) || (errors.As(err, &httpErr) && httpErr.Body == "404 page not found") {
+		return errors.New("the server doesn't support managed databases, upgrade haloyd to use this command")
+	}
+	return err
+}
+
+func printDatabaseBackups(appName string, backups []apitypes.DatabaseBackup, prefix string) {
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	if len(backups) == 0 {
+		pui.Info("No backups of %s", appName)
+		return
+	}
+	rows := make([][]string, 0, len(backups))
+	for _, backup := range backups {
+		rows = append(rows, []string{backup.Name, ui.FormatBytes(backup.Size), helpers.FormatTime(backup.CreatedAt)})
+	}
+	ui.Table([]string{"BACKUP", "SIZE", "CREATED"}, rows)
+}
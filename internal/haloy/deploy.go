@@ -3,17 +3,23 @@ package haloy
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/haloydev/haloy/internal/apiclient"
 	"github.com/haloydev/haloy/internal/apitypes"
 	"github.com/haloydev/haloy/internal/cmdexec"
 	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/errorpages"
+	"github.com/haloydev/haloy/internal/github"
 	"github.com/haloydev/haloy/internal/logging"
 	"github.com/haloydev/haloy/internal/ui"
 	"github.com/spf13/cobra"
@@ -21,206 +27,365 @@ import (
 )
 
 func DeployAppCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
-	var noLogsFlag bool
+	var opts deployOptions
+	var workspace bool
+	var changedSince string
+	var limitRate string
 
 	cmd := &cobra.Command{
 		Use:   "deploy",
 		Short: "Deploy an application",
-		Long:  "Deploy an application using a haloy configuration file.",
-		Args:  cobra.NoArgs,
+		Long: `Deploy an application using a haloy configuration file.
+
+With --workspace, every haloy config in the config directory and the
+directories below it is deployed, one app after the other, with all its
+targets. Paths listed in a .haloyignore file at the workspace root are
+skipped. Add --changed-since <ref> to deploy only the apps with files changed
+since a git ref; a changed file belongs to the app whose config is in the
+closest directory above it.
+
+With --since <ref>, targets are only built and deployed when their config
+file, build context or dockerfile changed since a git ref. Targets that run a
+prebuilt image are deployed when the config file changed. A plan of deployed
+and skipped targets is printed first.
+
+When run in GitHub Actions, deploy writes a job summary with the deployment
+ID, image, URLs and duration of every target, and sets these step outputs:
+
+  deployments     JSON array with the result of every target
+  deployment_id   deployment ID, when one target was deployed
+  image           image reference, when one target was deployed
+  image_digest    image digest, when one target was deployed and it is known
+  url             first URL of the target, when one target was deployed`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			ctx := cmd.Context()
-
-			rawDeployConfig, format, err := configloader.Load(ctx, *configPath, flags.targets, flags.all)
-			if err != nil {
-				return fmt.Errorf("unable to load config: %w", err)
+			var err error
+			if opts.limitRate, err = parseLimitRate(limitRate); err != nil {
+				return err
 			}
-
-			resolvedDeployConfig, err := configloader.ResolveSecrets(ctx, rawDeployConfig, *configPath)
-			if err != nil {
-				return fmt.Errorf("failed to resolve secrets: %w", err)
+			if workspace {
+				return deployWorkspace(cmd.Context(), *configPath, flags, changedSince, opts)
 			}
-
-			rawTargets, err := configloader.ExtractTargets(rawDeployConfig, format)
-			if err != nil {
-				return err
+			if changedSince != "" {
+				return errors.New("--changed-since only works with --workspace")
 			}
+			return deployApp(cmd.Context(), *configPath, flags, opts)
+		},
+	}
 
-			resolvedTargets, err := configloader.ExtractTargets(resolvedDeployConfig, format)
-			if err != nil {
-				return err
-			}
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Deploy to a specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Deploy to all targets")
+	cmd.Flags().BoolVar(&opts.noLogs, "no-logs", false, "Don't stream haloyd deployment logs")
+	cmd.Flags().BoolVar(&flags.includeProtected, "include-protected", false, "Include protected targets when using --all")
+	cmd.Flags().BoolVar(&opts.forceUnlock, "force-unlock", false, "Cancel any deployment of the app already in progress and take over its lock")
+	cmd.Flags().StringVar(&opts.fromArtifacts, "from-artifacts", "", "Deploy images recorded in a lockfile from 'haloy build' instead of building")
+	cmd.Flags().BoolVar(&opts.githubAnnotations, "github-annotations", false, "In GitHub Actions, also annotate the workflow run with the result of every target")
+	cmd.Flags().BoolVar(&workspace, "workspace", false, "Deploy every app whose config is in the config directory or below it")
+	cmd.Flags().StringVar(&opts.since, "since", "", "Only deploy targets whose config, build context or dockerfile changed since this git ref")
+	cmd.Flags().StringVar(&changedSince, "changed-since", "", "With --workspace, only deploy apps with files changed since this git ref")
+	cmd.Flags().StringVar(&limitRate, "limit-rate", "", "Limit image uploads to this many bytes per second per server, e.g. 500K or 2M")
 
-			for targetName := range resolvedTargets {
-				target := resolvedTargets[targetName]
-				if err := configloader.InterpolateEnvVars(target.Env); err != nil {
-					return fmt.Errorf("target '%s': %w", targetName, err)
-				}
-				resolvedTargets[targetName] = target
-			}
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
 
-			if len(rawTargets) != len(resolvedTargets) {
-				return fmt.Errorf("mismatch between raw targets (%d) and resolved targets (%d). This indicates a configuration processing error.", len(rawTargets), len(resolvedTargets))
-			}
+	return cmd
+}
 
-			// Filter out protected targets when using --all without --include-protected
-			if flags.all && !flags.includeProtected {
-				var skippedTargets []string
-				for targetName, target := range rawTargets {
-					if target.Protected != nil && *target.Protected {
-						skippedTargets = append(skippedTargets, targetName)
-						delete(rawTargets, targetName)
-						delete(resolvedTargets, targetName)
-					}
-				}
-				if len(skippedTargets) > 0 {
-					ui.Warn("Skipping protected targets: %s", strings.Join(skippedTargets, ", "))
-					ui.Warn("Use --include-protected to deploy these, or --targets to deploy explicitly")
-				}
-				if len(rawTargets) == 0 {
-					return fmt.Errorf("no targets to deploy (all targets are protected)")
-				}
-			}
+// deployWaves splits targets into waves deployed one after the other. Each
+// target in order gets a wave of its own, in that order; a target with
+// several servers gets one wave per server. All other targets make up the
+// last waves: staged targets deploy their first server in the first of these
+// and advance one server per wave, everything else deploys in the first.
+// Within a wave, targets are grouped by server so that deployments to the
+// same server are serialized. This prevents too many containers starting at
+// the same time and avoids races and conflicts, while targets on different
+// servers run in parallel to speed things up.
+func deployWaves(targets map[string]config.TargetConfig, order []string) []map[string][]string {
+	var waves []map[string][]string
+	remaining := maps.Clone(targets)
+	for _, targetName := range order {
+		for _, name := range serverGroupMembers(remaining, targetName) {
+			waves = append(waves, map[string][]string{remaining[name].Server: {name}})
+			delete(remaining, name)
+		}
+	}
 
-			if err := checkServersAuth(ctx, resolvedTargets); err != nil {
-				return err
-			}
+	var stages []map[string]config.TargetConfig
+	for targetName, target := range remaining {
+		stage := 0
+		if target.Rollout == config.RolloutStaged {
+			stage = target.ServerIndex
+		}
+		for len(stages) <= stage {
+			stages = append(stages, make(map[string]config.TargetConfig))
+		}
+		stages[stage][targetName] = target
+	}
+	for _, stage := range stages {
+		if len(stage) > 0 {
+			waves = append(waves, configloader.TargetsByServer(stage))
+		}
+	}
+	return waves
+}
 
-			builds, pushes, uploads, localBuilds := ResolveImageBuilds(resolvedTargets)
-
-			// Check Docker availability before building
-			if len(builds) > 0 {
-				imageRefs := make([]string, 0, len(builds))
-				for imageRef := range builds {
-					imageRefs = append(imageRefs, imageRef)
-				}
-				if err := checkDockerAvailable(ctx, imageRefs); err != nil {
-					return err
-				}
-			}
+// serverGroupMembers returns the targets named targetName: the target
+// itself, or the per-server targets it was expanded into, in server order.
+// Targets not selected for this deploy are left out.
+func serverGroupMembers(targets map[string]config.TargetConfig, targetName string) []string {
+	if _, ok := targets[targetName]; ok {
+		return []string{targetName}
+	}
+	var members []string
+	for name, target := range targets {
+		if target.ServerGroup == targetName {
+			members = append(members, name)
+		}
+	}
+	slices.SortFunc(members, func(a, b string) int {
+		return targets[a].ServerIndex - targets[b].ServerIndex
+	})
+	return members
+}
 
-			for imageRef, image := range builds {
-				if err := BuildImage(ctx, imageRef, image, *configPath); err != nil {
-					return err
-				}
-			}
+// deployOptions are the deploy flags that aren't shared with other commands.
+type deployOptions struct {
+	noLogs            bool
+	fromArtifacts     string
+	forceUnlock       bool
+	githubAnnotations bool
+	since             string
+	limitRate         int64 // bytes per second image uploads are limited to, 0 for no limit
+	// preview deploys the targets as the preview of a branch.
+	preview *previewOptions
+}
 
-			// Upload images only to remote servers (skip localhost - image already in shared daemon)
-			for imageRef, targetConfigs := range uploads {
-				if err := UploadImage(ctx, imageRef, targetConfigs); err != nil {
-					return err
-				}
-			}
+// deployApp deploys the targets of the config at configPath selected by flags.
+func deployApp(ctx context.Context, configPath string, flags *appCmdFlags, opts deployOptions) error {
+	rawDeployConfig, rawTargets, resolvedTargets, err := loadDeployTargets(ctx, configPath, flags)
+	if err != nil {
+		return err
+	}
 
-			// Log skipped localhost uploads for visibility
-			for imageRef := range localBuilds {
-				ui.Info("Skipping upload for %s (localhost shares Docker daemon)", imageRef)
+	if opts.preview != nil {
+		if err := applyPreview(*opts.preview, time.Now(), rawTargets, resolvedTargets); err != nil {
+			return err
+		}
+	}
+
+	if opts.since != "" {
+		if err := filterChangedTargets(ctx, configPath, opts.since, rawTargets, resolvedTargets); err != nil {
+			return err
+		}
+		if len(resolvedTargets) == 0 {
+			ui.Info("No target changed since %s, nothing to deploy", opts.since)
+			return nil
+		}
+	}
+
+	if err := checkServersCompatibility(ctx, resolvedTargets); err != nil {
+		return err
+	}
+
+	plugins, err := loadPlugins(rawDeployConfig.Plugins, configPath)
+	if err != nil {
+		return err
+	}
+
+	var schedule config.DeployOptions
+	if rawDeployConfig.Deploy != nil {
+		schedule = *rawDeployConfig.Deploy
+	}
+
+	var lock *ArtifactLock
+	if opts.fromArtifacts != "" {
+		lock, err = readArtifactLock(opts.fromArtifacts)
+		if err != nil {
+			return err
+		}
+		if err := applyArtifactLock(lock, rawTargets, resolvedTargets); err != nil {
+			return err
+		}
+		ui.Info("Deploying prebuilt images from %s", opts.fromArtifacts)
+	} else {
+		if lock, err = buildWithPlugins(ctx, plugins, resolvedTargets, configPath, true, opts.limitRate); err != nil {
+			return err
+		}
+		pinPushedImages(lock, rawTargets, resolvedTargets)
+	}
+
+	if len(rawDeployConfig.GlobalPreDeploy) > 0 {
+		for _, hookCmd := range rawDeployConfig.GlobalPreDeploy {
+			if err := cmdexec.RunCommand(ctx, hookCmd, getHooksWorkDir(configPath)); err != nil {
+				return fmt.Errorf("%s hook failed: %v", config.GetFieldNameForFormat(config.DeployConfig{}, "GlobalPreDeploy", rawDeployConfig.Format), err)
 			}
+		}
+	}
+
+	// Create deployment IDs per app name
+	deploymentIDs := make(map[string]string)
+	for _, target := range resolvedTargets {
+		if _, exists := deploymentIDs[target.Name]; !exists {
+			deploymentIDs[target.Name] = createDeploymentID()
+		}
+	}
+
+	summary := newDeploySummary(resolvedTargets, deploymentIDs, lock)
 
-			if len(pushes) > 0 {
-				for imageRef, images := range pushes {
-					for _, image := range images {
-						registryServer := image.GetRegistryServer()
-						ui.Info("Pushing image '%s' to %s", imageRef, registryServer)
-						if err := pushImageToRegistry(ctx, imageRef, image); err != nil {
-							return err
-						}
-					}
-				}
+	// deployTargets deploys targetNames one after another.
+	deployTargets := func(ctx context.Context, targetNames []string) error {
+		for _, targetName := range targetNames {
+			rawTargetConfig, rawTargetExists := rawTargets[targetName]
+			if !rawTargetExists {
+				return fmt.Errorf("could not find raw target for %s", targetName)
+			}
+			resolvedTargetConfig, resolvedTargetExists := resolvedTargets[targetName]
+			if !resolvedTargetExists {
+				return fmt.Errorf("could not find resolved target for %s", targetName)
 			}
 
-			if len(rawDeployConfig.GlobalPreDeploy) > 0 {
-				for _, hookCmd := range rawDeployConfig.GlobalPreDeploy {
-					if err := cmdexec.RunCommand(ctx, hookCmd, getHooksWorkDir(*configPath)); err != nil {
-						return fmt.Errorf("%s hook failed: %v", config.GetFieldNameForFormat(config.DeployConfig{}, "GlobalPreDeploy", rawDeployConfig.Format), err)
-					}
-				}
+			deploymentID, deploymentIDExists := deploymentIDs[resolvedTargetConfig.Name]
+			if !deploymentIDExists {
+				return fmt.Errorf("could not find deployment ID for app '%s'", resolvedTargetConfig.Name)
 			}
 
-			// Group targets by server so that deployments to the same server are serialized.
-			// This will prevent too many containers starting at the same time, avoids race conditions and conflicts.
-			// Targets that are on different server are run in paralell to speed things up.
-			servers := configloader.TargetsByServer(rawTargets)
-
-			// Create deployment IDs per app name
-			deploymentIDs := make(map[string]string)
-			for _, target := range resolvedTargets {
-				if _, exists := deploymentIDs[target.Name]; !exists {
-					deploymentIDs[target.Name] = createDeploymentID()
-				}
+			// Recreate the DeployConfig with just the target for rollbacks
+			rollbackDeployConfig := config.DeployConfig{
+				TargetConfig:    rawTargetConfig,
+				SecretProviders: rawDeployConfig.SecretProviders,
 			}
 
-			g, ctx := errgroup.WithContext(ctx)
-			for _, targetNames := range servers {
-				g.Go(func() error {
-					for _, targetName := range targetNames {
-
-						rawTargetConfig, rawTargetExists := rawTargets[targetName]
-						if !rawTargetExists {
-							return fmt.Errorf("could not find raw target for %s", targetName)
-						}
-						resolvedTargetConfig, resolvedTargetExists := resolvedTargets[targetName]
-						if !resolvedTargetExists {
-							return fmt.Errorf("could not find resolved target for %s", targetName)
-						}
-
-						deploymentID, deploymentIDExists := deploymentIDs[resolvedTargetConfig.Name]
-						if !deploymentIDExists {
-							return fmt.Errorf("could not find deployment ID for app '%s'", resolvedTargetConfig.Name)
-						}
-
-						// Recreate the DeployConfig with just the target for rollbacks
-						rollbackDeployConfig := config.DeployConfig{
-							TargetConfig:    rawTargetConfig,
-							SecretProviders: rawDeployConfig.SecretProviders,
-						}
-
-						prefix := ""
-						if len(rawTargets) > 1 {
-							prefix = targetName
-						}
-
-						if err := deployTarget(
-							ctx,
-							resolvedTargetConfig,
-							rollbackDeployConfig,
-							*configPath,
-							deploymentID,
-							prefix,
-							noLogsFlag,
-						); err != nil {
-							return err
-						}
-
-					}
-					return nil
-				})
+			prefix := ""
+			if len(rawTargets) > 1 {
+				prefix = targetName
 			}
 
-			if err := g.Wait(); err != nil {
+			pluginTargets := []pluginTarget{newPluginTarget(targetName, resolvedTargetConfig, deploymentID)}
+
+			start := time.Now()
+			err := plugins.run(ctx, pluginRequest{Event: pluginEventPreDeploy, Targets: pluginTargets}, prefix)
+			if err != nil {
+				err = &PrefixedError{Err: err, Prefix: prefix}
+			} else {
+				err = deployTarget(
+					ctx,
+					resolvedTargetConfig,
+					rollbackDeployConfig,
+					configPath,
+					deploymentID,
+					prefix,
+					opts.noLogs,
+					opts.forceUnlock,
+				)
+			}
+			summary.record(targetName, time.Since(start), err)
+			if err != nil {
+				plugins.failure(ctx, "deploy", pluginTargets, err, prefix)
 				return err
 			}
+			plugins.run(ctx, pluginRequest{Event: pluginEventPostDeploy, Targets: pluginTargets}, prefix)
+		}
+		return nil
+	}
+
+	for _, servers := range deployWaves(rawTargets, schedule.Order) {
+		g, gctx := errgroup.WithContext(ctx)
+		if schedule.Concurrency > 0 {
+			g.SetLimit(schedule.Concurrency)
+		}
+		for _, targetNames := range servers {
+			g.Go(func() error {
+				return deployTargets(gctx, targetNames)
+			})
+		}
+		if err = g.Wait(); err != nil {
+			break
+		}
+	}
 
-			if len(rawDeployConfig.GlobalPostDeploy) > 0 {
-				for _, hookCmd := range rawDeployConfig.GlobalPostDeploy {
-					if err := cmdexec.RunCommand(ctx, hookCmd, getHooksWorkDir(*configPath)); err != nil {
-						return fmt.Errorf("%s hook failed: %v", config.GetFieldNameForFormat(config.DeployConfig{}, "GlobalPostDeploy", rawDeployConfig.Format), err)
-					}
-				}
+	if github.InActions() {
+		summary.reportToGitHub(os.Stdout, opts.githubAnnotations)
+	}
+	if err != nil {
+		versions := make(map[string]string, len(rawTargets))
+		for _, result := range summary.sorted() {
+			versions[result.Target] = "not updated"
+			if result.Status == deployStatusSucceeded {
+				versions[result.Target] = "updated"
 			}
-			return nil
-		},
+		}
+		for _, line := range serverGroupDivergence(rawTargets, versions) {
+			ui.Warn("Servers left on different versions: %s", line)
+		}
+		return err
 	}
 
-	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
-	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Deploy to a specific targets (comma-separated)")
-	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Deploy to all targets")
-	cmd.Flags().BoolVar(&noLogsFlag, "no-logs", false, "Don't stream haloyd deployment logs")
-	cmd.Flags().BoolVar(&flags.includeProtected, "include-protected", false, "Include protected targets when using --all")
+	if len(rawDeployConfig.GlobalPostDeploy) > 0 {
+		for _, hookCmd := range rawDeployConfig.GlobalPostDeploy {
+			if err := cmdexec.RunCommand(ctx, hookCmd, getHooksWorkDir(configPath)); err != nil {
+				return fmt.Errorf("%s hook failed: %v", config.GetFieldNameForFormat(config.DeployConfig{}, "GlobalPostDeploy", rawDeployConfig.Format), err)
+			}
+		}
+	}
+	return nil
+}
 
-	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+// loadDeployTargets loads the config and returns the raw and secret-resolved
+// targets selected by flags. Protected targets are dropped when --all is used
+// without --include-protected.
+func loadDeployTargets(ctx context.Context, configPath string, flags *appCmdFlags) (config.DeployConfig, map[string]config.TargetConfig, map[string]config.TargetConfig, error) {
+	rawDeployConfig, format, err := configloader.Load(ctx, configPath, flags.targets, flags.all)
+	if err != nil {
+		return config.DeployConfig{}, nil, nil, fmt.Errorf("unable to load config: %w", err)
+	}
 
-	return cmd
+	resolvedDeployConfig, err := configloader.ResolveSecrets(ctx, rawDeployConfig, configPath)
+	if err != nil {
+		return config.DeployConfig{}, nil, nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
+	rawTargets, err := configloader.ExtractTargets(rawDeployConfig, format)
+	if err != nil {
+		return config.DeployConfig{}, nil, nil, err
+	}
+
+	resolvedTargets, err := configloader.ExtractTargets(resolvedDeployConfig, format)
+	if err != nil {
+		return config.DeployConfig{}, nil, nil, err
+	}
+
+	for targetName := range resolvedTargets {
+		target := resolvedTargets[targetName]
+		if err := configloader.InterpolateEnvVars(target.Env); err != nil {
+			return config.DeployConfig{}, nil, nil, fmt.Errorf("target '%s': %w", targetName, err)
+		}
+		resolvedTargets[targetName] = target
+	}
+
+	if len(rawTargets) != len(resolvedTargets) {
+		return config.DeployConfig{}, nil, nil, fmt.Errorf("mismatch between raw targets (%d) and resolved targets (%d). This indicates a configuration processing error.", len(rawTargets), len(resolvedTargets))
+	}
+
+	// Filter out protected targets when using --all without --include-protected
+	if flags.all && !flags.includeProtected {
+		var skippedTargets []string
+		for targetName, target := range rawTargets {
+			if target.Protected != nil && *target.Protected {
+				skippedTargets = append(skippedTargets, targetName)
+				delete(rawTargets, targetName)
+				delete(resolvedTargets, targetName)
+			}
+		}
+		if len(skippedTargets) > 0 {
+			ui.Warn("Skipping protected targets: %s", strings.Join(skippedTargets, ", "))
+			ui.Warn("Use --include-protected to deploy these, or --targets to deploy explicitly")
+		}
+		if len(rawTargets) == 0 {
+			return config.DeployConfig{}, nil, nil, fmt.Errorf("no targets to deploy (all targets are protected)")
+		}
+	}
+
+	return rawDeployConfig, rawTargets, resolvedTargets, nil
 }
 
 func deployTarget(
@@ -228,7 +393,7 @@ func deployTarget(
 	targetConfig config.TargetConfig,
 	rollbackDeployConfig config.DeployConfig,
 	configPath, deploymentID, prefix string,
-	noLogs bool,
+	noLogs, forceUnlock bool,
 ) error {
 	format := targetConfig.Format
 	server := targetConfig.Server
@@ -256,22 +421,31 @@ func deployTarget(
 		return &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
 	}
 
+	errorPages, err := readErrorPages(targetConfig, configPath)
+	if err != nil {
+		return &PrefixedError{Err: err, Prefix: prefix}
+	}
+
 	request := apitypes.DeployRequest{
 		TargetConfig:         targetConfig,
 		RollbackDeployConfig: rollbackDeployConfig,
 		DeploymentID:         deploymentID,
+		Holder:               deployLockHolder(),
+		ForceUnlock:          forceUnlock,
+		ErrorPages:           errorPages,
 	}
 
-	pui.Info("Deployment started for %s", targetConfig.Name)
+	pui.Info("Deployment %s started for %s", deploymentID, targetConfig.Name)
 
-	err = api.Post(ctx, "deploy", request, nil)
+	err = api.PostIdempotent(ctx, "deploy", deploymentID, request, nil)
 	if err != nil {
-		return &PrefixedError{Err: err, Prefix: prefix}
+		return &PrefixedError{Err: explainDeployLockError(err), Prefix: prefix}
 	}
 
 	if !noLogs {
 		streamPath := fmt.Sprintf("deploy/%s/logs", deploymentID)
 
+		var failure *logging.LogEntry
 		streamHandler := func(data string) bool {
 			var logEntry logging.LogEntry
 			if err := json.Unmarshal([]byte(data), &logEntry); err != nil {
@@ -281,11 +455,19 @@ func deployTarget(
 
 			ui.DisplayLogEntry(logEntry, prefix)
 
+			if logEntry.IsDeploymentFailed {
+				failure = &logEntry
+			}
+
 			// If deployment is complete we'll return true to signal stream should stop
 			return logEntry.IsDeploymentComplete
 		}
 
-		api.Stream(ctx, streamPath, streamHandler)
+		api.StreamResumable(ctx, streamPath, streamHandler)
+
+		if failure != nil {
+			return &PrefixedError{Err: deploymentFailedError(deploymentID, *failure), Prefix: prefix}
+		}
 	}
 
 	if len(postDeploy) > 0 {
@@ -298,6 +480,33 @@ func deployTarget(
 	return nil
 }
 
+// deploymentFailedError is returned for a deployment haloyd reported as
+// failed. The failure itself was already shown from the log stream.
+func deploymentFailedError(deploymentID string, failure logging.LogEntry) error {
+	err := fmt.Errorf("deployment %s failed", deploymentID)
+	if failure.FailureKind() == logging.FailureKindHealthCheck {
+		return withExitCode(ExitHealthCheck, fmt.Errorf("%w: health check failed", err))
+	}
+	return withExitCode(ExitServer, err)
+}
+
+// readErrorPages loads the target's custom error pages, resolving a relative
+// directory against the config file's directory.
+func readErrorPages(targetConfig config.TargetConfig, configPath string) (map[string]string, error) {
+	if targetConfig.ErrorPages == "" {
+		return nil, nil
+	}
+	dir := targetConfig.ErrorPages
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(getHooksWorkDir(configPath), dir)
+	}
+	pages, err := errorpages.ReadBundle(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", config.GetFieldNameForFormat(config.TargetConfig{}, "ErrorPages", targetConfig.Format), err)
+	}
+	return pages, nil
+}
+
 func getHooksWorkDir(configPath string) string {
 	workDir := "."
 	if configPath != "." {
@@ -324,78 +533,3 @@ func checkDockerAvailable(ctx context.Context, imageRefs []string) error {
 	}
 	return nil
 }
-
-// pushImageToRegistry pushes an image to a container registry using shell commands.
-// This avoids importing the Docker client library which adds significant binary bloat.
-func pushImageToRegistry(ctx context.Context, imageRef string, image *config.Image) error {
-	if image.RegistryAuth == nil {
-		return fmt.Errorf("no registry authentication configured for image %s", imageRef)
-	}
-
-	server := image.GetRegistryServer()
-
-	// Check for empty credentials before attempting login
-	if image.RegistryAuth.Password.Value == "" {
-		return fmt.Errorf("registry password is empty for image %s - check that the environment variable is set and the .env file is being loaded", imageRef)
-	}
-
-	loginCmd := exec.CommandContext(ctx, "docker", "login", server, "-u", image.RegistryAuth.Username.Value, "-p", image.RegistryAuth.Password.Value)
-	if output, err := loginCmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("docker login to %s failed: %w\n%s", server, err, string(output))
-	}
-
-	pushCmd := exec.CommandContext(ctx, "docker", "push", imageRef)
-	var stdoutBuf, stderrBuf strings.Builder
-	pushCmd.Stdout = &stdoutBuf
-	pushCmd.Stderr = &stderrBuf
-
-	err := pushCmd.Run()
-
-	var cached, pushed, mounted int
-	for line := range strings.SplitSeq(stdoutBuf.String(), "\n") {
-		switch {
-		case strings.Contains(line, "Layer already exists"):
-			cached++
-		case strings.Contains(line, ": Pushed"):
-			pushed++
-		case strings.Contains(line, "Mounted from"):
-			mounted++
-		}
-	}
-
-	if err != nil {
-		stderrStr := strings.TrimSpace(stderrBuf.String())
-		errMsg := fmt.Sprintf("failed to push image %s", imageRef)
-		if stderrStr != "" {
-			errMsg = fmt.Sprintf("failed to push image %s: %s", imageRef, stderrStr)
-		}
-
-		stderrLower := strings.ToLower(stderrStr)
-		if strings.Contains(stderrLower, "denied") || strings.Contains(stderrLower, "unauthorized") {
-			errMsg += "\n\nHint: This usually means your registry credentials are incorrect or expired.\nCheck your registryAuth username and password configuration."
-		} else if strings.Contains(stderrLower, "not found") {
-			errMsg += "\n\nHint: The repository may not exist. Ensure the image name is correct and the repository exists on the registry."
-		}
-
-		return fmt.Errorf("%s", errMsg)
-	}
-
-	total := cached + pushed + mounted
-	if total > 0 {
-		parts := []string{}
-		if cached > 0 {
-			parts = append(parts, fmt.Sprintf("%d cached", cached))
-		}
-		if pushed > 0 {
-			parts = append(parts, fmt.Sprintf("%d pushed", pushed))
-		}
-		if mounted > 0 {
-			parts = append(parts, fmt.Sprintf("%d mounted", mounted))
-		}
-		ui.Success("Pushed image (%s)", strings.Join(parts, ", "))
-	} else {
-		ui.Success("Pushed image")
-	}
-
-	return nil
-}
@@ -1,12 +1,12 @@
 package haloy
 
 import (
-	"archive/tar"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path"
 	"path/filepath"
@@ -21,7 +21,6 @@ import (
 	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/helpers"
 	"github.com/haloydev/haloy/internal/ui"
-	"golang.org/x/sync/errgroup"
 )
 
 var runCLICommandInDir = cmdexec.RunCLICommandInDir
@@ -78,7 +77,7 @@ func BuildImage(ctx context.Context, imageRef string, image *config.Image, confi
 	// before invoking docker, so a bad path fails with a clear error.
 	paths, err := resolveBuildPaths(configPath, buildConfig)
 	if err != nil {
-		return err
+		return withExitCode(ExitBuild, err)
 	}
 
 	workDir := getBuilderWorkDir(configPath)
@@ -106,7 +105,7 @@ func BuildImage(ctx context.Context, imageRef string, image *config.Image, confi
 	args = append(args, paths.ContextDir)
 
 	if err := runCLICommandInDir(ctx, workDir, "docker", args...); err != nil {
-		return withLocalDockerDiskFullHint(fmt.Errorf("failed to build image %s: %w", imageRef, err))
+		return withExitCode(ExitBuild, withLocalDockerDiskFullHint(fmt.Errorf("failed to build image %s: %w", imageRef, err)))
 	}
 
 	ui.Success("Built image %s", imageRef)
@@ -125,73 +124,38 @@ func getBuilderWorkDir(configPath string) string {
 	return filepath.Dir(configPath)
 }
 
-// UploadImage uploads a Docker image to the specified server
-// It tries layer-based upload first (efficient), falls back to full tar upload
-func UploadImage(ctx context.Context, imageRef string, resolvedTargetConfigs []*config.TargetConfig) error {
-	sanitized := strings.NewReplacer("/", "-", ":", "-").Replace(imageRef)
-	tempFile, err := os.CreateTemp("", fmt.Sprintf("haloy-upload-%s-*.tar", sanitized))
-	if err != nil {
-		return fmt.Errorf("failed to create temporary file: %w", err)
+// uploadImage uploads a Docker image to the server of api. It tries
+// layer-based upload first (efficient), falls back to full tar upload.
+// The image is streamed from 'docker save' and never written to a temp file.
+func uploadImage(ctx context.Context, api *apiclient.APIClient, capabilities map[string]struct{}, imageRef string, archive imageArchive) error {
+	supportsLayerUpload := hasCapability(capabilities, constants.CapabilityLayerUpload)
+	supportsImagePreflight := hasCapability(capabilities, constants.CapabilityImagePreflight)
+	supportsLayerResume := hasCapability(capabilities, constants.CapabilityLayerResume)
+
+	if supportsLayerUpload {
+		err := uploadImageLayered(ctx, api, imageRef, archive, supportsImagePreflight, supportsLayerResume)
+		if err == nil {
+			return nil
+		}
+		ui.Warn("Layer-based push failed, falling back to full push: %v", err)
 	}
-	tempPath := tempFile.Name()
-	tempFile.Close()
-	defer os.Remove(tempPath)
+	return uploadImageFull(ctx, api, imageRef, archive.size, supportsImagePreflight)
+}
 
-	if err := saveImageTar(ctx, imageRef, tempPath); err != nil {
-		return withLocalDockerDiskFullHint(fmt.Errorf("failed to save image to tar: %w", err))
+// parseLimitRate parses the --limit-rate of uploads, a size per second like
+// 500K or 2M, where K and M are binary like in curl's option of that name.
+func parseLimitRate(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
 	}
-
-	tempInfo, err := os.Stat(tempPath)
+	n, err := helpers.ParseBytes(s)
 	if err != nil {
-		return fmt.Errorf("failed to stat image tar: %w", err)
+		return 0, fmt.Errorf("invalid --limit-rate: %w", err)
 	}
-
-	for _, resolvedDeployConfig := range resolvedTargetConfigs {
-		token, err := getToken(resolvedDeployConfig, resolvedDeployConfig.Server)
-		if err != nil {
-			return fmt.Errorf("failed to get authentication token: %w", err)
-		}
-
-		api, err := apiclient.NewWithTimeout(resolvedDeployConfig.Server, token, 5*time.Minute)
-		if err != nil {
-			return fmt.Errorf("failed to create API client: %w", err)
-		}
-
-		capabilities := getServerCapabilities(ctx, api)
-		supportsLayerUpload := hasCapability(capabilities, constants.CapabilityLayerUpload)
-		supportsImagePreflight := hasCapability(capabilities, constants.CapabilityImagePreflight)
-
-		if supportsLayerUpload {
-			ui.Info("Pushing image %s to %s", imageRef, resolvedDeployConfig.Server)
-			if err := uploadImageLayered(ctx, api, imageRef, tempPath, supportsImagePreflight); err != nil {
-				ui.Warn("Layer-based push failed, falling back to full push: %v", err)
-				if supportsImagePreflight {
-					if err := reportFullUploadDiskSpace(ctx, api, uint64(tempInfo.Size())); err != nil {
-						return withImagePruneHint(err, *resolvedDeployConfig)
-					}
-				}
-				if err := api.PostFile(ctx, "images/upload", "image", tempPath); err != nil {
-					return withImagePruneHint(fmt.Errorf("failed to upload image: %w", err), *resolvedDeployConfig)
-				}
-			}
-		} else {
-			ui.Info("Pushing image %s to %s", imageRef, resolvedDeployConfig.Server)
-			if supportsImagePreflight {
-				if err := reportFullUploadDiskSpace(ctx, api, uint64(tempInfo.Size())); err != nil {
-					return withImagePruneHint(err, *resolvedDeployConfig)
-				}
-			}
-			if err := api.PostFile(ctx, "images/upload", "image", tempPath); err != nil {
-				return withImagePruneHint(fmt.Errorf("failed to upload image: %w", err), *resolvedDeployConfig)
-			}
-		}
+	if n == 0 || n > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid --limit-rate %q: must be more than 0 bytes per second", s)
 	}
-
-	return nil
-}
-
-func saveImageTar(ctx context.Context, imageRef, tarPath string) error {
-	return runCLICommandInDir(ctx, ".", "docker", "save", "-o", tarPath, imageRef)
+	return int64(n), nil
 }
 
 // getServerCapabilities returns the server capability set. It falls back to no capabilities on error.
@@ -214,11 +178,10 @@ func hasCapability(capabilities map[string]struct{}, capability string) bool {
 }
 
 // uploadImageLayered uploads an image using layer-based transfer
-func uploadImageLayered(ctx context.Context, api *apiclient.APIClient, imageRef, tarPath string, supportsImagePreflight bool) error {
-	// Parse the image tar to extract manifest, config, and layers
-	manifest, configData, layers, err := parseImageTar(tarPath)
+func uploadImageLayered(ctx context.Context, api *apiclient.APIClient, imageRef string, archive imageArchive, supportsImagePreflight, supportsLayerResume bool) error {
+	manifest, configData, layers, err := archive.layout()
 	if err != nil {
-		return fmt.Errorf("failed to parse image tar: %w", err)
+		return fmt.Errorf("failed to parse image archive: %w", err)
 	}
 
 	// Legacy docker save layouts name layer directories by chain ID, not content
@@ -292,27 +255,21 @@ func uploadImageLayered(ctx context.Context, api *apiclient.APIClient, imageRef,
 			ShowBytes:   true,
 		})
 
-		// Upload missing layers in parallel
-		g, gctx := errgroup.WithContext(ctx)
-		g.SetLimit(4) // Max 4 concurrent uploads
-
+		missing := make([]layerInfo, 0, missingCount)
 		for _, digest := range checkResp.Missing {
-			layerInfo, ok := layers[digest]
+			info, ok := layers[digest]
 			if !ok {
 				progress.Finish()
-				return fmt.Errorf("layer %s not found in tar", digest)
+				return fmt.Errorf("layer %s not found in image archive", digest)
 			}
-
-			g.Go(func() error {
-				if err := uploadLayerWithRetry(gctx, api, tarPath, layerInfo, digest, progress); err != nil {
-					return err
-				}
-				progress.CompleteItem()
-				return nil
-			})
+			missing = append(missing, info)
 		}
 
-		if err := g.Wait(); err != nil {
+		upload := uploadLayerWithRetry
+		if supportsLayerResume {
+			upload = uploadLayerResumable
+		}
+		if err := uploadExportedLayers(ctx, api, imageRef, missing, upload, progress); err != nil {
 			progress.Finish()
 			return err
 		}
@@ -384,7 +341,7 @@ func describeDiskSpaceEstimate(req apitypes.ImageDiskSpaceCheckRequest) string {
 	reserve := helpers.FormatBinaryBytes(constants.DefaultImageDiskReserve)
 
 	if req.UploadSizeBytes > 0 {
-		return fmt.Sprintf("includes temporary image tar, Docker load, and %s reserve", reserve)
+		return fmt.Sprintf("includes Docker load and %s reserve", reserve)
 	}
 
 	parts := make([]string, 0, 4)
@@ -392,7 +349,7 @@ func describeDiskSpaceEstimate(req apitypes.ImageDiskSpaceCheckRequest) string {
 		parts = append(parts, "missing layer upload")
 	}
 
-	parts = append(parts, "assembled temp image tar", "Docker load", fmt.Sprintf("%s reserve", reserve))
+	parts = append(parts, "Docker load", fmt.Sprintf("%s reserve", reserve))
 	return "includes " + strings.Join(parts, ", ")
 }
 
@@ -437,7 +394,7 @@ func (e *layerUploadStatusError) Error() string {
 	return fmt.Sprintf("failed to upload layer %s: server returned %d: %s", e.digest, e.statusCode, e.body)
 }
 
-func uploadLayerWithRetry(ctx context.Context, api *apiclient.APIClient, tarPath string, info layerInfo, digest string, progress *ui.ProgressBar) error {
+func uploadLayerWithRetry(ctx context.Context, api *apiclient.APIClient, src *layerSource, info layerInfo, digest string, progress *ui.ProgressBar) error {
 	var lastErr error
 	backoff := layerUploadInitialBackoff
 
@@ -451,7 +408,7 @@ func uploadLayerWithRetry(ctx context.Context, api *apiclient.APIClient, tarPath
 			backoff *= 2
 		}
 
-		lastErr = uploadSingleLayer(ctx, api, tarPath, info, digest, progress)
+		lastErr = uploadSingleLayer(ctx, api, src, info, digest, progress)
 		if lastErr == nil {
 			return nil
 		}
@@ -471,8 +428,8 @@ func uploadLayerWithRetry(ctx context.Context, api *apiclient.APIClient, tarPath
 	return lastErr
 }
 
-func uploadSingleLayer(ctx context.Context, api *apiclient.APIClient, tarPath string, info layerInfo, digest string, progress *ui.ProgressBar) error {
-	layerReader, err := openLayerFromTar(tarPath, info.tarPath)
+func uploadSingleLayer(ctx context.Context, api *apiclient.APIClient, src *layerSource, info layerInfo, digest string, progress *ui.ProgressBar) error {
+	layerReader, err := src.open(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to open layer %s: %w", digest, err)
 	}
@@ -496,7 +453,7 @@ func uploadSingleLayer(ctx context.Context, api *apiclient.APIClient, tarPath st
 	req.Header.Set("Content-Type", "application/octet-stream")
 	req.Header.Set("X-Layer-Digest", digest)
 
-	resp, err := api.Do(req)
+	resp, err := api.DoUpload(req)
 	layerReader.Close()
 	if err != nil {
 		return fail(fmt.Errorf("failed to upload layer %s: %w", digest, err))
@@ -522,101 +479,6 @@ type layerInfo struct {
 	size    int64
 }
 
-// parseImageTar extracts manifest, config, and layer info from a docker save tar
-func parseImageTar(tarPath string) (apitypes.ImageManifestEntry, []byte, map[string]layerInfo, error) {
-	file, err := os.Open(tarPath)
-	if err != nil {
-		return apitypes.ImageManifestEntry{}, nil, nil, err
-	}
-	defer file.Close()
-
-	tr := tar.NewReader(file)
-
-	var manifestData []byte
-	var configData []byte
-	var manifest apitypes.ImageManifestEntry
-	configName := ""
-	layers := make(map[string]layerInfo)
-
-	// First pass: read manifest to know what to look for
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return apitypes.ImageManifestEntry{}, nil, nil, err
-		}
-
-		if header.Name == "manifest.json" {
-			manifestData, err = io.ReadAll(tr)
-			if err != nil {
-				return apitypes.ImageManifestEntry{}, nil, nil, err
-			}
-
-			var manifests []apitypes.ImageManifestEntry
-			if err := json.Unmarshal(manifestData, &manifests); err != nil {
-				return apitypes.ImageManifestEntry{}, nil, nil, err
-			}
-			if len(manifests) == 0 {
-				return apitypes.ImageManifestEntry{}, nil, nil, fmt.Errorf("empty manifest")
-			}
-			manifest = manifests[0]
-			configName = manifest.Config
-
-			// Map layer paths to their digests
-			for _, layerPath := range manifest.Layers {
-				digest := extractDigestFromPath(layerPath)
-				layers[digest] = layerInfo{
-					digest:  digest,
-					tarPath: layerPath,
-				}
-			}
-			break
-		}
-	}
-
-	if manifestData == nil {
-		return apitypes.ImageManifestEntry{}, nil, nil, fmt.Errorf("manifest.json not found in tar")
-	}
-
-	// Second pass: read config and get layer sizes
-	file.Seek(0, 0)
-	tr = tar.NewReader(file)
-
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return apitypes.ImageManifestEntry{}, nil, nil, err
-		}
-
-		// Read config
-		if header.Name == configName {
-			configData, err = io.ReadAll(tr)
-			if err != nil {
-				return apitypes.ImageManifestEntry{}, nil, nil, err
-			}
-		}
-
-		// Update layer sizes
-		for digest, info := range layers {
-			if header.Name == info.tarPath {
-				info.size = header.Size
-				layers[digest] = info
-			}
-		}
-	}
-
-	if configData == nil {
-		return apitypes.ImageManifestEntry{}, nil, nil, fmt.Errorf("config %s not found in tar", configName)
-	}
-
-	return manifest, configData, layers, nil
-}
-
 // hasContentAddressedLayers reports whether every manifest layer path encodes a
 // content digest (buildkit OCI blobs or sha256:-prefixed directories). Legacy
 // docker save layouts use chain IDs as directory names, which are not content
@@ -660,33 +522,7 @@ func extractDigestFromPath(layerPath string) string {
 	return "sha256:" + dir
 }
 
-// openLayerFromTar opens a specific layer from the tar file for streaming
-func openLayerFromTar(tarPath, layerPath string) (io.ReadCloser, error) {
-	file, err := os.Open(tarPath)
-	if err != nil {
-		return nil, err
-	}
-
-	tr := tar.NewReader(file)
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			file.Close()
-			return nil, fmt.Errorf("layer %s not found in tar", layerPath)
-		}
-		if err != nil {
-			file.Close()
-			return nil, err
-		}
-
-		if header.Name == layerPath {
-			// Return a reader that closes the underlying file when done
-			return &layerReader{Reader: tr, closer: file}, nil
-		}
-	}
-}
-
-// layerReader wraps a tar reader and closes the underlying file when closed
+// layerReader wraps a tar reader and closes the export it reads from when closed
 type layerReader struct {
 	io.Reader
 	closer io.Closer
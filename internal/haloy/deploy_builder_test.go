@@ -2,9 +2,9 @@ package haloy
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -90,32 +90,6 @@ func TestGetBuilderWorkDir(t *testing.T) {
 	}
 }
 
-func TestUploadImage_TempFilePattern(t *testing.T) {
-	tests := []struct {
-		name     string
-		imageRef string
-	}{
-		{"simple image", "nginx:latest"},
-		{"image with org slash", "myorg/myapp:latest"},
-		{"deeply nested ref", "registry.io/org/app:v1"},
-		{"no tag", "myapp"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			sanitized := strings.NewReplacer("/", "-", ":", "-").Replace(tt.imageRef)
-			pattern := fmt.Sprintf("haloy-upload-%s-*.tar", sanitized)
-			f, err := os.CreateTemp("", pattern)
-			if err != nil {
-				t.Errorf("os.CreateTemp failed for image ref %q: %v", tt.imageRef, err)
-				return
-			}
-			os.Remove(f.Name())
-			f.Close()
-		})
-	}
-}
-
 func TestExtractDigestFromPath(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -296,7 +270,7 @@ func TestFormatDiskSpaceEstimateMessage_FullUpload(t *testing.T) {
 		},
 	)
 
-	want := "Server disk space estimate: need 3.1 GiB, have 10.0 GiB free (includes temporary image tar, Docker load, and 2.0 GiB reserve)"
+	want := "Server disk space estimate: need 3.1 GiB, have 10.0 GiB free (includes Docker load and 2.0 GiB reserve)"
 	if msg != want {
 		t.Fatalf("message = %q, want %q", msg, want)
 	}
@@ -313,7 +287,7 @@ func TestFormatDiskSpaceEstimateMessage_LayeredUploadAllLayersCached(t *testing.
 		},
 	)
 
-	want := "Server disk space estimate: need 3.1 GiB, have 10.0 GiB free (includes assembled temp image tar, Docker load, 2.0 GiB reserve)"
+	want := "Server disk space estimate: need 3.1 GiB, have 10.0 GiB free (includes Docker load, 2.0 GiB reserve)"
 	if msg != want {
 		t.Fatalf("message = %q, want %q", msg, want)
 	}
@@ -331,8 +305,59 @@ func TestFormatDiskSpaceEstimateMessage_LayeredUploadWithMissingLayers(t *testin
 		},
 	)
 
-	want := "Server disk space estimate: need 3.2 GiB, have 10.0 GiB free (includes missing layer upload, assembled temp image tar, Docker load, 2.0 GiB reserve)"
+	want := "Server disk space estimate: need 3.2 GiB, have 10.0 GiB free (includes missing layer upload, Docker load, 2.0 GiB reserve)"
 	if msg != want {
 		t.Fatalf("message = %q, want %q", msg, want)
 	}
 }
+
+func TestParseLimitRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"1000", 1000, false},
+		{"500K", 500 << 10, false},
+		{"2M", 2 << 20, false},
+		{"1MB", 1e6, false},
+		{"0", 0, true},
+		{"fast", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseLimitRate(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseLimitRate(%q) = %d, %v, want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPlanImageUploads(t *testing.T) {
+	web := &config.TargetConfig{Name: "web", Server: "a.example.com"}
+	worker := &config.TargetConfig{Name: "worker", Server: "https://a.example.com"}
+	other := &config.TargetConfig{Name: "web-eu", Server: "b.example.com"}
+	api := &config.TargetConfig{Name: "api", Server: "a.example.com"}
+
+	transfers := planImageUploads(map[string][]*config.TargetConfig{
+		"shop:1": {worker, other, web},
+		"api:1":  {api},
+	})
+
+	var got []string
+	for _, transfer := range transfers {
+		var names []string
+		for _, target := range transfer.targets {
+			names = append(names, target.Name)
+		}
+		got = append(got, transfer.server+" "+transfer.imageRef+" "+strings.Join(names, ","))
+	}
+	want := []string{
+		"a.example.com api:1 api",
+		"a.example.com shop:1 web,worker",
+		"b.example.com shop:1 web-eu",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("planImageUploads() = %q, want %q", got, want)
+	}
+}
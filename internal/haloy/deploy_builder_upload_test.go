@@ -1,46 +1,182 @@
 package haloy
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
-	"reflect"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/ui"
 )
 
-func TestSaveImageTar_UsesDirectArgsForWindowsStyleTempPath(t *testing.T) {
-	previous := runCLICommandInDir
-	t.Cleanup(func() {
-		runCLICommandInDir = previous
+// testImageArchive builds a docker save archive in the OCI layout, with the
+// blobs ahead of manifest.json as docker writes them.
+func testImageArchive(t *testing.T, config []byte, layers map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	write := func(name string, data []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("WriteHeader(%s) error = %v", name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatalf("Write(%s) error = %v", name, err)
+		}
+	}
+
+	manifest := apitypes.ImageManifestEntry{Config: "blobs/sha256/config"}
+	write(manifest.Config, config)
+	for _, hash := range slices.Sorted(maps.Keys(layers)) {
+		layerPath := "blobs/sha256/" + hash
+		write(layerPath, layers[hash])
+		manifest.Layers = append(manifest.Layers, layerPath)
+	}
+	manifestJSON, err := json.Marshal([]apitypes.ImageManifestEntry{manifest})
+	if err != nil {
+		t.Fatalf("marshal manifest: %v", err)
+	}
+	write("manifest.json", manifestJSON)
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+// stubImageExport makes openImageExport return archive and counts the exports.
+func stubImageExport(t *testing.T, archive []byte) *int {
+	t.Helper()
+	previous := openImageExport
+	t.Cleanup(func() { openImageExport = previous })
+
+	var mu sync.Mutex
+	exports := new(int)
+	openImageExport = func(context.Context, string) (io.ReadCloser, error) {
+		mu.Lock()
+		*exports++
+		mu.Unlock()
+		return io.NopCloser(bytes.NewReader(archive)), nil
+	}
+	return exports
+}
+
+func TestScanImageArchive(t *testing.T) {
+	config := []byte(`{"rootfs":{"diff_ids":["sha256:a","sha256:b"]}}`)
+	archiveData := testImageArchive(t, config, map[string][]byte{
+		"aaa": bytes.Repeat([]byte("a"), 3000),
+		"bbb": bytes.Repeat([]byte("b"), 10),
 	})
 
-	var (
-		gotWorkDir string
-		gotName    string
-		gotArgs    []string
-	)
+	archive, err := scanImageArchive(bytes.NewReader(archiveData))
+	if err != nil {
+		t.Fatalf("scanImageArchive() error = %v", err)
+	}
+	if archive.size != int64(len(archiveData)) {
+		t.Errorf("size = %d, want %d", archive.size, len(archiveData))
+	}
 
-	runCLICommandInDir = func(_ context.Context, workDir, name string, args ...string) error {
-		gotWorkDir = workDir
-		gotName = name
-		gotArgs = append([]string(nil), args...)
-		return nil
+	manifest, configData, layers, err := archive.layout()
+	if err != nil {
+		t.Fatalf("layout() error = %v", err)
+	}
+	if manifest.Config != "blobs/sha256/config" {
+		t.Errorf("manifest.Config = %q", manifest.Config)
+	}
+	if !bytes.Equal(configData, config) {
+		t.Errorf("config = %s, want %s", configData, config)
 	}
+	want := map[string]layerInfo{
+		"sha256:aaa": {digest: "sha256:aaa", tarPath: "blobs/sha256/aaa", size: 3000},
+		"sha256:bbb": {digest: "sha256:bbb", tarPath: "blobs/sha256/bbb", size: 10},
+	}
+	if len(layers) != len(want) {
+		t.Fatalf("layers = %+v, want %+v", layers, want)
+	}
+	for digest, info := range want {
+		if layers[digest] != info {
+			t.Errorf("layers[%s] = %+v, want %+v", digest, layers[digest], info)
+		}
+	}
+}
 
-	tarPath := `C:\Users\Familia\AppData\Local\Temp\haloy-upload-normaagro-dev-latest-123.tar`
-	imageRef := "normaagro-dev:latest"
+func TestScanImageArchive_SkipsLargeEntries(t *testing.T) {
+	archiveData := testImageArchive(t, []byte("{}"), map[string][]byte{
+		"big": make([]byte, maxExportMetadataEntry+1),
+	})
 
-	if err := saveImageTar(context.Background(), imageRef, tarPath); err != nil {
-		t.Fatalf("saveImageTar() unexpected error: %v", err)
+	archive, err := scanImageArchive(bytes.NewReader(archiveData))
+	if err != nil {
+		t.Fatalf("scanImageArchive() error = %v", err)
 	}
+	if _, ok := archive.metadata["blobs/sha256/big"]; ok {
+		t.Error("scan kept a layer larger than maxExportMetadataEntry in memory")
+	}
+	if got := archive.entries["blobs/sha256/big"]; got != maxExportMetadataEntry+1 {
+		t.Errorf("entries[big] = %d, want %d", got, maxExportMetadataEntry+1)
+	}
+}
 
-	if gotWorkDir != "." {
-		t.Fatalf("workDir = %q, want %q", gotWorkDir, ".")
+func TestUploadExportedLayers_UploadsMissingLayersInOneExport(t *testing.T) {
+	layers := map[string][]byte{
+		"aaa": []byte("layer a"),
+		"bbb": []byte("layer b"),
+		"ccc": []byte("layer c"),
 	}
-	if gotName != "docker" {
-		t.Fatalf("name = %q, want %q", gotName, "docker")
+	archiveData := testImageArchive(t, []byte("{}"), layers)
+	exports := stubImageExport(t, archiveData)
+
+	var mu sync.Mutex
+	uploaded := make(map[string][]byte)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/images/layers" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		uploaded[r.Header.Get("X-Layer-Digest")] = body
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+
+	api, err := apiclient.NewWithTimeout(srv.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewWithTimeout() error = %v", err)
+	}
+
+	archive, err := scanImageArchive(bytes.NewReader(archiveData))
+	if err != nil {
+		t.Fatalf("scanImageArchive() error = %v", err)
+	}
+	_, _, infos, err := archive.layout()
+	if err != nil {
+		t.Fatalf("layout() error = %v", err)
 	}
+	missing := []layerInfo{infos["sha256:ccc"], infos["sha256:aaa"]}
 
-	wantArgs := []string{"save", "-o", tarPath, imageRef}
-	if !reflect.DeepEqual(gotArgs, wantArgs) {
-		t.Fatalf("args = %#v, want %#v", gotArgs, wantArgs)
+	ui.SetNonInteractive(true)
+	t.Cleanup(func() { ui.SetNonInteractive(false) })
+	progress := ui.NewProgressBar(ui.ProgressBarConfig{Description: "Uploading layers", TotalItems: len(missing)})
+
+	if err := uploadExportedLayers(context.Background(), api, "app:latest", missing, uploadLayerWithRetry, progress); err != nil {
+		t.Fatalf("uploadExportedLayers() error = %v", err)
+	}
+
+	if *exports != 1 {
+		t.Errorf("exported the image %d times, want once", *exports)
+	}
+	if len(uploaded) != 2 || string(uploaded["sha256:aaa"]) != "layer a" || string(uploaded["sha256:ccc"]) != "layer c" {
+		t.Errorf("uploaded = %q, want layers aaa and ccc", uploaded)
 	}
 }
@@ -0,0 +1,50 @@
+package haloy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+// deployLockHolder identifies this invocation to other users who find the
+// app locked, e.g. "alice@laptop".
+func deployLockHolder() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		name += "@" + host
+	}
+	return name
+}
+
+// explainDeployLockError turns a 409 from the deploy or rollback API into a
+// message naming the deployment that holds the lock. Other errors are
+// returned unchanged.
+func explainDeployLockError(err error) error {
+	var httpErr *apiclient.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusConflict {
+		return err
+	}
+
+	var locked apitypes.DeployLockedResponse
+	if json.Unmarshal([]byte(httpErr.Body), &locked) != nil || locked.Lock.DeploymentID == "" {
+		return err
+	}
+
+	lock := locked.Lock
+	holder := lock.Holder
+	if holder == "" {
+		holder = "another client"
+	}
+	return fmt.Errorf("app '%s' is locked by %s (deployment %s, started %s, lock expires %s); wait for it to finish or retry with --force-unlock to cancel it",
+		lock.App, holder, lock.DeploymentID, helpers.FormatTime(lock.AcquiredAt), helpers.FormatTime(lock.ExpiresAt))
+}
@@ -0,0 +1,214 @@
+package haloy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/github"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+// deployResult is the outcome of deploying one target, reported to CI.
+type deployResult struct {
+	Target       string        `json:"target"`
+	App          string        `json:"app"`
+	Server       string        `json:"server"`
+	DeploymentID string        `json:"deploymentId"`
+	Image        string        `json:"image,omitempty"`
+	ImageDigest  string        `json:"imageDigest,omitempty"`
+	URLs         []string      `json:"urls,omitempty"`
+	Status       string        `json:"status"`
+	Error        string        `json:"error,omitempty"`
+	Duration     time.Duration `json:"-"`
+	DurationSecs float64       `json:"durationSeconds"`
+}
+
+const (
+	deployStatusSucceeded = "succeeded"
+	deployStatusFailed    = "failed"
+	deployStatusSkipped   = "skipped"
+)
+
+// deploySummary collects deployResults from the per-server deploy goroutines.
+type deploySummary struct {
+	mu      sync.Mutex
+	results map[string]*deployResult
+}
+
+// newDeploySummary starts every target as skipped, so targets never reached
+// because another one failed still show up.
+func newDeploySummary(targets map[string]config.TargetConfig, deploymentIDs map[string]string, lock *ArtifactLock) *deploySummary {
+	s := &deploySummary{results: make(map[string]*deployResult, len(targets))}
+	for targetName, target := range targets {
+		result := &deployResult{
+			Target:       targetName,
+			App:          target.Name,
+			Server:       target.Server,
+			DeploymentID: deploymentIDs[target.Name],
+			URLs:         targetURLs(target),
+			Status:       deployStatusSkipped,
+		}
+		if target.Image != nil {
+			result.Image = target.Image.ImageRef()
+		}
+		if lock != nil {
+			if artifact, ok := lock.Targets[targetName]; ok {
+				result.Image = artifact.ImageRef
+				result.ImageDigest = artifact.RepoDigest
+				if result.ImageDigest == "" {
+					result.ImageDigest = artifact.ImageID
+				}
+			}
+		}
+		if result.ImageDigest == "" {
+			if _, digest, found := strings.Cut(result.Image, "@"); found {
+				result.ImageDigest = digest
+			}
+		}
+		s.results[targetName] = result
+	}
+	return s
+}
+
+func (s *deploySummary) record(targetName string, duration time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, ok := s.results[targetName]
+	if !ok {
+		return
+	}
+	result.Duration = duration
+	result.DurationSecs = duration.Round(time.Millisecond).Seconds()
+	if err != nil {
+		result.Status = deployStatusFailed
+		result.Error = err.Error()
+		return
+	}
+	result.Status = deployStatusSucceeded
+}
+
+// sorted returns the results ordered by target name.
+func (s *deploySummary) sorted() []deployResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]deployResult, 0, len(s.results))
+	for _, result := range s.results {
+		results = append(results, *result)
+	}
+	slices.SortFunc(results, func(a, b deployResult) int { return strings.Compare(a.Target, b.Target) })
+	return results
+}
+
+func targetURLs(target config.TargetConfig) []string {
+	urls := make([]string, 0, len(target.Domains))
+	for _, domain := range target.Domains {
+		urls = append(urls, "https://"+domain.Canonical+domain.PathPrefix)
+	}
+	return urls
+}
+
+// reportToGitHub writes the job summary, step outputs and, when annotations
+// is set, one annotation per target. Reporting problems are only warned
+// about: they must not fail a deploy that went through.
+func (s *deploySummary) reportToGitHub(w io.Writer, annotations bool) {
+	results := s.sorted()
+
+	if err := github.AppendStepSummary(deploySummaryMarkdown(results)); err != nil {
+		ui.Warn("Failed to write GitHub job summary: %v", err)
+	}
+
+	outputs := map[string]string{}
+	if data, err := json.Marshal(results); err == nil {
+		outputs["deployments"] = string(data)
+	}
+	// Single values only make sense when one target was deployed.
+	if len(results) == 1 {
+		outputs["deployment_id"] = results[0].DeploymentID
+		outputs["image"] = results[0].Image
+		outputs["image_digest"] = results[0].ImageDigest
+		if len(results[0].URLs) > 0 {
+			outputs["url"] = results[0].URLs[0]
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(outputs)) {
+		if err := github.SetOutput(name, outputs[name]); err != nil {
+			ui.Warn("Failed to set GitHub output %s: %v", name, err)
+			break
+		}
+	}
+
+	if !annotations {
+		return
+	}
+	for _, result := range results {
+		switch result.Status {
+		case deployStatusSucceeded:
+			message := fmt.Sprintf("Deployment %s of %s succeeded in %s", result.DeploymentID, result.App, formatDeployDuration(result.Duration))
+			if len(result.URLs) > 0 {
+				message += ": " + strings.Join(result.URLs, ", ")
+			}
+			github.Annotate(w, github.AnnotationNotice, "Deployed "+result.Target, message)
+		case deployStatusFailed:
+			github.Annotate(w, github.AnnotationError, "Deploy of "+result.Target+" failed", result.Error)
+		}
+	}
+}
+
+func deploySummaryMarkdown(results []deployResult) string {
+	var b strings.Builder
+	b.WriteString("### Haloy deploy\n\n")
+	b.WriteString("| Target | Status | Deployment | Image | URLs | Duration |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, result := range results {
+		status := map[string]string{
+			deployStatusSucceeded: "✅ succeeded",
+			deployStatusFailed:    "❌ failed",
+			deployStatusSkipped:   "⏭️ skipped",
+		}[result.Status]
+
+		image := markdownCode(result.Image)
+		if result.ImageDigest != "" {
+			image += "<br>" + markdownCode(result.ImageDigest)
+		}
+		urls := make([]string, 0, len(result.URLs))
+		for _, url := range result.URLs {
+			urls = append(urls, fmt.Sprintf("[%s](%s)", strings.TrimPrefix(url, "https://"), url))
+		}
+		duration := ""
+		if result.Status != deployStatusSkipped {
+			duration = formatDeployDuration(result.Duration)
+		}
+
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n",
+			markdownEscape(result.Target), status, markdownCode(result.DeploymentID), image, strings.Join(urls, "<br>"), duration)
+	}
+
+	for _, result := range results {
+		if result.Status == deployStatusFailed {
+			fmt.Fprintf(&b, "\n**%s:** %s\n", markdownEscape(result.Target), markdownEscape(result.Error))
+		}
+	}
+	return b.String()
+}
+
+func formatDeployDuration(d time.Duration) string {
+	return d.Round(100 * time.Millisecond).String()
+}
+
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + strings.ReplaceAll(s, "`", "") + "`"
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ", "\r", "").Replace(s)
+}
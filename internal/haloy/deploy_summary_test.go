@@ -0,0 +1,120 @@
+package haloy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestDeploySummaryReportToGitHub(t *testing.T) {
+	dir := t.TempDir()
+	summaryPath := filepath.Join(dir, "summary.md")
+	outputPath := filepath.Join(dir, "output")
+	t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+	t.Setenv("GITHUB_OUTPUT", outputPath)
+
+	targets := map[string]config.TargetConfig{
+		"prod": {
+			Name:    "web",
+			Server:  "haloy.example.com",
+			Image:   &config.Image{Repository: "ghcr.io/acme/web", Tag: "v1"},
+			Domains: []config.Domain{{Canonical: "example.com"}},
+		},
+		"staging": {
+			Name:   "web-staging",
+			Server: "haloy.example.com",
+			Image:  &config.Image{Repository: "ghcr.io/acme/web", Tag: "v1"},
+		},
+		"edge": {
+			Name:   "web-edge",
+			Server: "edge.example.com",
+			Image:  &config.Image{Repository: "ghcr.io/acme/web", Tag: "v1"},
+		},
+	}
+	deploymentIDs := map[string]string{"web": "01PROD", "web-staging": "01STAGING", "web-edge": "01EDGE"}
+	lock := &ArtifactLock{Targets: map[string]ArtifactTarget{
+		"prod": {ImageRef: "ghcr.io/acme/web:v1", RepoDigest: "ghcr.io/acme/web@sha256:abc"},
+	}}
+
+	summary := newDeploySummary(targets, deploymentIDs, lock)
+	summary.record("prod", 2*time.Second, nil)
+	summary.record("staging", time.Second, errors.New("health check timed out"))
+
+	var annotations bytes.Buffer
+	summary.reportToGitHub(&annotations, true)
+
+	markdown, err := os.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatalf("failed to read summary: %v", err)
+	}
+	for _, want := range []string{
+		"| prod | ✅ succeeded | `01PROD` | `ghcr.io/acme/web:v1`<br>`ghcr.io/acme/web@sha256:abc` | [example.com](https://example.com) | 2s |",
+		"| staging | ❌ failed | `01STAGING` |",
+		"| edge | ⏭️ skipped | `01EDGE` |",
+		"**staging:** health check timed out",
+	} {
+		if !strings.Contains(string(markdown), want) {
+			t.Errorf("summary missing %q:\n%s", want, markdown)
+		}
+	}
+
+	output, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("failed to read outputs: %v", err)
+	}
+	if strings.Contains(string(output), "deployment_id=") {
+		t.Errorf("deployment_id should only be set for a single target:\n%s", output)
+	}
+	_, value, found := strings.Cut(string(output), "deployments=")
+	if !found {
+		t.Fatalf("deployments output missing:\n%s", output)
+	}
+	var results []deployResult
+	if err := json.Unmarshal([]byte(strings.SplitN(value, "\n", 2)[0]), &results); err != nil {
+		t.Fatalf("deployments output is not JSON: %v", err)
+	}
+	if len(results) != 3 || results[0].Target != "edge" || results[1].Status != deployStatusSucceeded {
+		t.Errorf("unexpected deployments output: %+v", results)
+	}
+
+	wantAnnotations := "::notice title=Deployed prod::Deployment 01PROD of web succeeded in 2s: https://example.com\n" +
+		"::error title=Deploy of staging failed::health check timed out\n"
+	if annotations.String() != wantAnnotations {
+		t.Errorf("annotations = %q, want %q", annotations.String(), wantAnnotations)
+	}
+}
+
+func TestDeploySummarySingleTargetOutputs(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "output")
+	t.Setenv("GITHUB_STEP_SUMMARY", "")
+	t.Setenv("GITHUB_OUTPUT", outputPath)
+
+	targets := map[string]config.TargetConfig{
+		"web": {Name: "web", Server: "haloy.example.com", Image: &config.Image{Repository: "acme/web@sha256:def"}},
+	}
+	summary := newDeploySummary(targets, map[string]string{"web": "01WEB"}, nil)
+	summary.record("web", time.Second, nil)
+
+	var annotations bytes.Buffer
+	summary.reportToGitHub(&annotations, false)
+
+	output, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("failed to read outputs: %v", err)
+	}
+	for _, want := range []string{"deployment_id=01WEB\n", "image=acme/web@sha256:def\n", "image_digest=sha256:def\n"} {
+		if !strings.Contains(string(output), want) {
+			t.Errorf("outputs missing %q:\n%s", want, output)
+		}
+	}
+	if annotations.Len() != 0 {
+		t.Errorf("expected no annotations without --github-annotations, got %q", annotations.String())
+	}
+}
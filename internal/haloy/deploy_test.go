@@ -0,0 +1,153 @@
+package haloy
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestDeployWaves(t *testing.T) {
+	targets := map[string]config.TargetConfig{
+		"db":     {Server: "a.example.com"},
+		"api":    {Server: "a.example.com"},
+		"web":    {Server: "b.example.com"},
+		"worker": {Server: "a.example.com"},
+	}
+
+	tests := []struct {
+		name  string
+		order []string
+		want  []map[string][]string
+	}{
+		{
+			name: "no order groups by server",
+			want: []map[string][]string{{
+				"a.example.com": {"api", "db", "worker"},
+				"b.example.com": {"web"},
+			}},
+		},
+		{
+			name:  "ordered targets come first, one per wave",
+			order: []string{"db", "api"},
+			want: []map[string][]string{
+				{"a.example.com": {"db"}},
+				{"a.example.com": {"api"}},
+				{"a.example.com": {"worker"}, "b.example.com": {"web"}},
+			},
+		},
+		{
+			name:  "every target ordered",
+			order: []string{"web", "db", "api", "worker"},
+			want: []map[string][]string{
+				{"b.example.com": {"web"}},
+				{"a.example.com": {"db"}},
+				{"a.example.com": {"api"}},
+				{"a.example.com": {"worker"}},
+			},
+		},
+		{
+			name:  "unselected ordered targets are skipped",
+			order: []string{"cache", "db"},
+			want: []map[string][]string{
+				{"a.example.com": {"db"}},
+				{"a.example.com": {"api", "worker"}, "b.example.com": {"web"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := deployWaves(targets, tt.order)
+			for _, wave := range got {
+				for _, targetNames := range wave {
+					slices.Sort(targetNames)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("deployWaves() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeployWavesServerGroups(t *testing.T) {
+	targets := map[string]config.TargetConfig{
+		"web@a": {Server: "a", ServerGroup: "web", ServerIndex: 0, Rollout: config.RolloutStaged},
+		"web@b": {Server: "b", ServerGroup: "web", ServerIndex: 1, Rollout: config.RolloutStaged},
+		"web@c": {Server: "c", ServerGroup: "web", ServerIndex: 2, Rollout: config.RolloutStaged},
+		"api@a": {Server: "a", ServerGroup: "api", ServerIndex: 0},
+		"api@b": {Server: "b", ServerGroup: "api", ServerIndex: 1},
+		"db":    {Server: "a"},
+	}
+
+	tests := []struct {
+		name  string
+		order []string
+		want  []map[string][]string
+	}{
+		{
+			name: "staged targets advance one server per wave",
+			want: []map[string][]string{
+				{"a": {"api@a", "db", "web@a"}, "b": {"api@b"}},
+				{"b": {"web@b"}},
+				{"c": {"web@c"}},
+			},
+		},
+		{
+			name:  "ordered server group deploys one server per wave",
+			order: []string{"db", "api"},
+			want: []map[string][]string{
+				{"a": {"db"}},
+				{"a": {"api@a"}},
+				{"b": {"api@b"}},
+				{"a": {"web@a"}},
+				{"b": {"web@b"}},
+				{"c": {"web@c"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := deployWaves(targets, tt.order)
+			for _, wave := range got {
+				for _, targetNames := range wave {
+					slices.Sort(targetNames)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("deployWaves() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerGroupDivergence(t *testing.T) {
+	targets := map[string]config.TargetConfig{
+		"web@a": {Server: "a", ServerGroup: "web", ServerIndex: 0},
+		"web@b": {Server: "b", ServerGroup: "web", ServerIndex: 1},
+		"api@a": {Server: "a", ServerGroup: "api", ServerIndex: 0},
+		"api@b": {Server: "b", ServerGroup: "api", ServerIndex: 1},
+		"db":    {Server: "a"},
+	}
+	versions := map[string]string{
+		"web@a": "v2",
+		"web@b": "v1",
+		"api@a": "v1",
+		"api@b": "v1",
+		"db":    "v3",
+	}
+
+	got := serverGroupDivergence(targets, versions)
+	want := []string{"web: a v2, b v1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("serverGroupDivergence() = %v, want %v", got, want)
+	}
+
+	versions["web@b"] = "v2"
+	if got := serverGroupDivergence(targets, versions); len(got) != 0 {
+		t.Fatalf("serverGroupDivergence() = %v, want none", got)
+	}
+}
@@ -0,0 +1,185 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// destroyTimeout covers stopping and removing every container of an app
+// before the server answers.
+const destroyTimeout = 3 * time.Minute
+
+func DestroyAppCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var keepVolumesFlag bool
+	var removeCertsFlag bool
+	var yesFlag bool
+
+	cmd := &cobra.Command{
+		Use:   "destroy",
+		Short: "Remove an application and everything haloy stored for it",
+		Long: `Remove an application from its server: its containers with their logs, its routes, its images,
+its deployment history and its custom error pages. Volumes are removed too unless
+--keep-volumes is set, and certificates only with --remove-certs.
+
+Protected targets must be confirmed twice, interactively, even with --yes.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			rawDeployConfig, format, err := configloader.Load(ctx, *configPath, flags.targets, flags.all)
+			if err != nil {
+				return fmt.Errorf("unable to load config: %w", err)
+			}
+
+			resolvedDeployConfig, err := configloader.ResolveSecrets(ctx, rawDeployConfig, *configPath)
+			if err != nil {
+				return fmt.Errorf("failed to resolve secrets: %w", err)
+			}
+
+			targets, err := configloader.ExtractTargets(resolvedDeployConfig, format)
+			if err != nil {
+				return err
+			}
+
+			// Confirm every target before destroying any of them.
+			for _, name := range slices.Sorted(maps.Keys(targets)) {
+				if err := confirmDestroy(targets[name], keepVolumesFlag, yesFlag, ui.Prompt); err != nil {
+					return err
+				}
+			}
+
+			req := apitypes.DestroyAppRequest{KeepVolumes: keepVolumesFlag, RemoveCertificates: removeCertsFlag}
+			g, ctx := errgroup.WithContext(ctx)
+			for _, target := range targets {
+				g.Go(func() error {
+					prefix := ""
+					if len(targets) > 1 {
+						prefix = target.TargetName
+					}
+					return destroyApp(ctx, &target, req, prefix)
+				})
+			}
+			return g.Wait()
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Destroy app on specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Destroy app on all targets")
+	cmd.Flags().BoolVar(&keepVolumesFlag, "keep-volumes", false, "Keep the app's volumes")
+	cmd.Flags().BoolVar(&removeCertsFlag, "remove-certs", false, "Also remove the TLS certificates of the app's domains")
+	cmd.Flags().BoolVarP(&yesFlag, "yes", "y", false, "Skip the confirmation for targets that are not protected")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+
+	return cmd
+}
+
+// confirmDestroy asks before destroying target's app. Unprotected targets
+// are confirmed once unless yes is set; protected ones are confirmed and then
+// the app name must be typed, whatever yes says.
+func confirmDestroy(target config.TargetConfig, keepVolumes, yes bool, prompt func(string) (string, error)) error {
+	protected := target.Protected != nil && *target.Protected
+	if yes && !protected {
+		return nil
+	}
+
+	what := "containers, images and deployment history"
+	if !keepVolumes {
+		what = "containers, images, deployment history and volumes"
+	}
+	question := fmt.Sprintf("Destroy %s on %s? This removes its %s. [y/N]", target.Name, target.Server, what)
+	if protected {
+		question = fmt.Sprintf("Target %s is protected. %s", target.TargetName, question)
+	}
+
+	answer, err := prompt(question)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains([]string{"y", "yes"}, strings.ToLower(answer)) {
+		return fmt.Errorf("destroy of %s cancelled", target.Name)
+	}
+	if !protected {
+		return nil
+	}
+
+	answer, err = prompt(fmt.Sprintf("Type the app name (%s) to confirm:", target.Name))
+	if err != nil {
+		return err
+	}
+	if answer != target.Name {
+		return fmt.Errorf("destroy of %s cancelled: app name did not match", target.Name)
+	}
+	return nil
+}
+
+func destroyApp(ctx context.Context, targetConfig *config.TargetConfig, req apitypes.DestroyAppRequest, prefix string) error {
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	pui.Info("Destroying application: %s using server %s", targetConfig.Name, targetConfig.Server)
+
+	token, err := getToken(targetConfig, targetConfig.Server)
+	if err != nil {
+		return &PrefixedError{Err: fmt.Errorf("unable to get token: %w", err), Prefix: prefix}
+	}
+
+	api, err := apiclient.NewWithTimeout(targetConfig.Server, token, destroyTimeout)
+	if err != nil {
+		return &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
+	}
+
+	var response apitypes.DestroyAppResponse
+	if err := api.Post(ctx, fmt.Sprintf("destroy/%s", targetConfig.Name), req, &response); err != nil {
+		if errors.Is(err, apiclient.ErrNotFound) {
+			return &PrefixedError{Err: errors.New("server does not support destroying apps, upgrade haloyd to use haloy destroy"), Prefix: prefix}
+		}
+		return &PrefixedError{Err: fmt.Errorf("failed to destroy app: %w", err), Prefix: prefix}
+	}
+
+	title := fmt.Sprintf("Destroyed %s", targetConfig.Name)
+	if prefix != "" {
+		title = fmt.Sprintf("%s (%s)", title, prefix)
+	}
+	ui.Section(title, destroySummary(response, req))
+	return nil
+}
+
+func destroySummary(response apitypes.DestroyAppResponse, req apitypes.DestroyAppRequest) []string {
+	lines := []string{
+		fmt.Sprintf("Containers removed: %d", len(response.Containers)),
+		fmt.Sprintf("Routes removed: %s", listOrNone(response.Domains)),
+		fmt.Sprintf("Images removed: %d", len(response.Images)),
+		fmt.Sprintf("Deployments removed from history: %d", response.Deployments),
+	}
+	if req.KeepVolumes {
+		lines = append(lines, "Volumes: kept")
+	} else {
+		lines = append(lines, fmt.Sprintf("Volumes removed: %s", listOrNone(response.Volumes)))
+	}
+	if req.RemoveCertificates {
+		lines = append(lines, fmt.Sprintf("Certificates removed: %s", listOrNone(response.Certificates)))
+	} else {
+		lines = append(lines, "Certificates: kept")
+	}
+	return lines
+}
+
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,64 @@
+package haloy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+func TestConfirmDestroy(t *testing.T) {
+	unprotected := config.TargetConfig{Name: "blog", TargetName: "prod", Server: "haloy.example.com"}
+	protected := unprotected
+	protected.Protected = new(true)
+
+	tests := []struct {
+		name    string
+		target  config.TargetConfig
+		yes     bool
+		answers []string
+		wantErr bool
+		prompts int
+	}{
+		{name: "yes skips unprotected", target: unprotected, yes: true},
+		{name: "unprotected confirmed", target: unprotected, answers: []string{"y"}, prompts: 1},
+		{name: "unprotected declined", target: unprotected, answers: []string{""}, prompts: 1, wantErr: true},
+		{name: "protected confirmed twice", target: protected, answers: []string{"yes", "blog"}, prompts: 2},
+		{name: "protected needs both despite yes", target: protected, yes: true, answers: []string{"y", "blog"}, prompts: 2},
+		{name: "protected wrong name", target: protected, answers: []string{"y", "blgo"}, prompts: 2, wantErr: true},
+		{name: "protected declined first", target: protected, answers: []string{"n"}, prompts: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompts := 0
+			prompt := func(string) (string, error) {
+				if prompts >= len(tt.answers) {
+					t.Fatalf("unexpected prompt %d", prompts+1)
+				}
+				prompts++
+				return tt.answers[prompts-1], nil
+			}
+
+			err := confirmDestroy(tt.target, false, tt.yes, prompt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("confirmDestroy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if prompts != tt.prompts {
+				t.Errorf("prompted %d times, want %d", prompts, tt.prompts)
+			}
+		})
+	}
+}
+
+func TestConfirmDestroyNonInteractive(t *testing.T) {
+	target := config.TargetConfig{Name: "blog", Protected: new(true)}
+	prompt := func(message string) (string, error) {
+		return "", ui.ErrInputRequired
+	}
+
+	if err := confirmDestroy(target, false, true, prompt); !errors.Is(err, ui.ErrInputRequired) {
+		t.Fatalf("confirmDestroy() error = %v, want ErrInputRequired", err)
+	}
+}
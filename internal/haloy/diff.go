@@ -0,0 +1,295 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// errDriftDetected is returned by 'haloy diff --exit-code' when any target differs.
+var errDriftDetected = errors.New("running state differs from local configuration")
+
+// driftEntry is one field whose running value differs from the local config.
+type driftEntry struct {
+	Field   string
+	Local   string
+	Running string
+}
+
+type targetDiff struct {
+	target     config.TargetConfig
+	notRunning bool
+	drift      []driftEntry
+}
+
+func DiffCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var exitCode bool
+
+	cmd := &cobra.Command{
+		Use:   "diff [target]",
+		Short: "Show drift between the running deployment and the local config",
+		Long: `Compare the running deployment of each target against the locally resolved
+configuration and show what differs: image, replicas, domains, env var names,
+haloy labels, volumes, network and resource limits.
+
+Use it before deploying to spot changes made on the server, such as manual
+scaling or containers started outside of haloy.`,
+		Example: `  # Compare a single target
+  haloy diff production
+
+  # Fail in CI when any target has drifted
+  haloy diff --all --exit-code`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if len(args) == 1 {
+				if flags.all {
+					return errors.New("cannot specify both a target argument and --all")
+				}
+				flags.targets = append(flags.targets, args[0])
+			}
+
+			rawDeployConfig, format, err := configloader.Load(ctx, *configPath, flags.targets, flags.all)
+			if err != nil {
+				return fmt.Errorf("unable to load config: %w", err)
+			}
+
+			resolvedDeployConfig, err := configloader.ResolveSecrets(ctx, rawDeployConfig, *configPath)
+			if err != nil {
+				return fmt.Errorf("failed to resolve secrets: %w", err)
+			}
+
+			targets, err := configloader.ExtractTargets(resolvedDeployConfig, format)
+			if err != nil {
+				return err
+			}
+
+			targetNames := slices.Sorted(maps.Keys(targets))
+			diffs := make([]targetDiff, len(targetNames))
+
+			g, ctx := errgroup.WithContext(ctx)
+			for i, targetName := range targetNames {
+				g.Go(func() error {
+					target := targets[targetName]
+					prefix := ""
+					if len(targets) > 1 {
+						prefix = targetName
+					}
+
+					running, err := getAppInspect(ctx, &target)
+					if errors.Is(err, apiclient.ErrNotFound) {
+						diffs[i] = targetDiff{target: target, notRunning: true}
+						return nil
+					}
+					if err != nil {
+						return &PrefixedError{Err: err, Prefix: prefix}
+					}
+					diffs[i] = targetDiff{target: target, drift: diffTarget(target, running)}
+					return nil
+				})
+			}
+			if err := g.Wait(); err != nil {
+				return err
+			}
+
+			drifted := false
+			for i, diff := range diffs {
+				name := diff.target.Name
+				if len(diffs) > 1 {
+					name = fmt.Sprintf("%s (%s)", targetNames[i], diff.target.Name)
+				}
+
+				switch {
+				case diff.notRunning:
+					drifted = true
+					ui.Warn("%s: not running on %s; a deploy would create it", name, diff.target.Server)
+				case len(diff.drift) == 0:
+					ui.Success("%s: running state matches the local configuration", name)
+				default:
+					drifted = true
+					ui.Warn("%s: %d difference(s) on %s", name, len(diff.drift), diff.target.Server)
+					rows := make([][]string, 0, len(diff.drift))
+					for _, entry := range diff.drift {
+						rows = append(rows, []string{entry.Field, entry.Local, entry.Running})
+					}
+					ui.Table([]string{"FIELD", "LOCAL", "RUNNING"}, rows)
+				}
+			}
+
+			if drifted && exitCode {
+				return errDriftDetected
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Compare specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Compare all targets")
+	cmd.Flags().BoolVar(&exitCode, "exit-code", false, "Exit with an error when any target has drifted")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+
+	return cmd
+}
+
+func getAppInspect(ctx context.Context, target *config.TargetConfig) (apitypes.AppInspectResponse, error) {
+	var response apitypes.AppInspectResponse
+
+	token, err := getToken(target, target.Server)
+	if err != nil {
+		return response, fmt.Errorf("unable to get token: %w", err)
+	}
+
+	api, err := apiclient.New(target.Server, token)
+	if err != nil {
+		return response, fmt.Errorf("unable to create API client: %w", err)
+	}
+
+	if err := api.Get(ctx, "inspect/"+target.Name, &response); err != nil {
+		if errors.Is(err, apiclient.ErrNotFound) {
+			return response, err
+		}
+		return response, fmt.Errorf("failed to inspect app: %w", err)
+	}
+	return response, nil
+}
+
+// diffTarget compares a resolved target config against what is running.
+func diffTarget(target config.TargetConfig, running apitypes.AppInspectResponse) []driftEntry {
+	var drift []driftEntry
+	add := func(field, local, running string) {
+		if local != running {
+			drift = append(drift, driftEntry{Field: field, Local: local, Running: running})
+		}
+	}
+
+	if target.Image != nil {
+		add("image", target.Image.ImageRef(), running.Image)
+	}
+	if target.Replicas != nil {
+		add("replicas", strconv.Itoa(*target.Replicas), strconv.Itoa(running.Replicas))
+	}
+	add("domains", formatDomains(target.Domains), formatDomains(running.Domains))
+
+	localEnv := make(map[string]bool, len(target.Env))
+	for _, env := range target.Env {
+		localEnv[env.Name] = true
+	}
+	runningEnv := make(map[string]bool, len(running.EnvNames))
+	for _, name := range running.EnvNames {
+		runningEnv[name] = true
+	}
+	imageEnv := make(map[string]bool, len(running.ImageEnvNames))
+	for _, name := range running.ImageEnvNames {
+		imageEnv[name] = true
+	}
+	for _, name := range slices.Sorted(maps.Keys(localEnv)) {
+		if !runningEnv[name] {
+			add("env "+name, "set", "missing")
+		}
+	}
+	for _, name := range running.EnvNames {
+		if !localEnv[name] && !imageEnv[name] {
+			add("env "+name, "missing", "set")
+		}
+	}
+
+	// Domains are compared above, with their path prefix and CDN; the
+	// deployment ID always differs.
+	expectedLabels := expectedHaloyLabels(target)
+	isComparedLabel := func(key string) bool {
+		return key != config.LabelDeploymentID && !strings.HasPrefix(key, "dev.haloy.domain.")
+	}
+	labelKeys := make(map[string]struct{})
+	for key := range expectedLabels {
+		labelKeys[key] = struct{}{}
+	}
+	for key := range running.Labels {
+		labelKeys[key] = struct{}{}
+	}
+	for _, key := range slices.Sorted(maps.Keys(labelKeys)) {
+		if isComparedLabel(key) {
+			add("label "+key, labelValue(expectedLabels, key), labelValue(running.Labels, key))
+		}
+	}
+
+	localVolumes := slices.Clone(target.Volumes)
+	slices.Sort(localVolumes)
+	runningVolumes := slices.Clone(running.Volumes)
+	slices.Sort(runningVolumes)
+	add("volumes", strings.Join(localVolumes, ", "), strings.Join(runningVolumes, ", "))
+
+	network := target.Network
+	if network == "" {
+		network = constants.DockerNetwork
+	}
+	add("network", network, running.Network)
+
+	// haloy does not set resource limits, so any limit was applied by hand.
+	if running.NanoCPUs != 0 {
+		add("cpus", "unlimited", strconv.FormatFloat(float64(running.NanoCPUs)/1e9, 'f', -1, 64))
+	}
+	if running.MemoryBytes != 0 {
+		add("memory", "unlimited", helpers.FormatBinaryBytes(uint64(running.MemoryBytes)))
+	}
+
+	return drift
+}
+
+// expectedHaloyLabels returns the labels a deploy of target would set.
+func expectedHaloyLabels(target config.TargetConfig) map[string]string {
+	cl := config.NewContainerLabels(target, "")
+	return cl.ToLabels()
+}
+
+func labelValue(labels map[string]string, key string) string {
+	if value, ok := labels[key]; ok {
+		return value
+	}
+	return "(unset)"
+}
+
+// formatDomains describes domains with the options that change how they are
+// routed, e.g. "example.com/api (www.example.com) [strip prefix, cdn cloudflare]".
+func formatDomains(domains []config.Domain) string {
+	formatted := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		prefix := domain.NormalizedPathPrefix()
+		entry := domain.Canonical + prefix
+		if len(domain.Aliases) > 0 {
+			entry += fmt.Sprintf(" (%s)", strings.Join(domain.Aliases, ", "))
+		}
+		var options []string
+		// The prefix is only stripped when there is one.
+		if prefix != "" && domain.StripPrefix {
+			options = append(options, "strip prefix")
+		}
+		if domain.CDN != "" {
+			options = append(options, "cdn "+domain.CDN)
+		}
+		if len(options) > 0 {
+			entry += fmt.Sprintf(" [%s]", strings.Join(options, ", "))
+		}
+		formatted = append(formatted, entry)
+	}
+	if len(formatted) == 0 {
+		return "(none)"
+	}
+	return strings.Join(formatted, "; ")
+}
@@ -0,0 +1,114 @@
+package haloy
+
+import (
+	"testing"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func diffTestTarget() config.TargetConfig {
+	return config.TargetConfig{
+		Name:            "web",
+		Server:          "example.com",
+		Image:           &config.Image{Repository: "ghcr.io/acme/web", Tag: "1.2.0"},
+		Replicas:        new(2),
+		MinReadySeconds: new(0),
+		Port:            "8080",
+		HealthCheckPath: "/",
+		Domains:         []config.Domain{{Canonical: "example.com", Aliases: []string{"www.example.com"}}},
+		Env: []config.EnvVar{
+			{Name: "DATABASE_URL", ValueSource: config.ValueSource{Value: "postgres://"}},
+		},
+	}
+}
+
+func matchingRunning(target config.TargetConfig) apitypes.AppInspectResponse {
+	labels := expectedHaloyLabels(target)
+	labels[config.LabelDeploymentID] = "20250101120000"
+	return apitypes.AppInspectResponse{
+		DeploymentID:  "20250101120000",
+		Image:         target.Image.ImageRef(),
+		Replicas:      *target.Replicas,
+		Domains:       target.Domains,
+		EnvNames:      []string{"DATABASE_URL", "PATH"},
+		ImageEnvNames: []string{"PATH"},
+		Labels:        labels,
+		Network:       "haloy",
+	}
+}
+
+func TestDiffTarget_NoDrift(t *testing.T) {
+	target := diffTestTarget()
+	if drift := diffTarget(target, matchingRunning(target)); len(drift) != 0 {
+		t.Fatalf("drift = %+v, want none", drift)
+	}
+}
+
+func TestDiffTarget_DetectsDrift(t *testing.T) {
+	target := diffTestTarget()
+
+	tests := []struct {
+		name   string
+		mutate func(*apitypes.AppInspectResponse)
+		field  string
+	}{
+		{"manual scale", func(r *apitypes.AppInspectResponse) { r.Replicas = 5 }, "replicas"},
+		{"image", func(r *apitypes.AppInspectResponse) { r.Image = "ghcr.io/acme/web:1.1.0" }, "image"},
+		{"domains", func(r *apitypes.AppInspectResponse) { r.Domains = nil }, "domains"},
+		{"missing env", func(r *apitypes.AppInspectResponse) { r.EnvNames = []string{"PATH"} }, "env DATABASE_URL"},
+		{"extra env", func(r *apitypes.AppInspectResponse) { r.EnvNames = append(r.EnvNames, "DEBUG") }, "env DEBUG"},
+		{"label", func(r *apitypes.AppInspectResponse) { r.Labels[config.LabelPort] = "3000" }, "label " + config.LabelPort},
+		{"network", func(r *apitypes.AppInspectResponse) { r.Network = "bridge" }, "network"},
+		{"memory limit", func(r *apitypes.AppInspectResponse) { r.MemoryBytes = 512 << 20 }, "memory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			running := matchingRunning(target)
+			tt.mutate(&running)
+
+			drift := diffTarget(target, running)
+			if len(drift) != 1 || drift[0].Field != tt.field {
+				t.Fatalf("drift = %+v, want a single %q entry", drift, tt.field)
+			}
+		})
+	}
+}
+
+func TestDiffTarget_DomainOptions(t *testing.T) {
+	target := diffTestTarget()
+	target.Domains = []config.Domain{{Canonical: "example.com", PathPrefix: "/api/", StripPrefix: true, CDN: "cloudflare"}}
+
+	tests := []struct {
+		name    string
+		running config.Domain
+		want    string
+	}{
+		{"same options", config.Domain{Canonical: "example.com", PathPrefix: "/api", StripPrefix: true, CDN: "cloudflare"}, ""},
+		{"path prefix", config.Domain{Canonical: "example.com", PathPrefix: "/v2", StripPrefix: true, CDN: "cloudflare"}, "example.com/v2 [strip prefix, cdn cloudflare]"},
+		{"strip prefix", config.Domain{Canonical: "example.com", PathPrefix: "/api", CDN: "cloudflare"}, "example.com/api [cdn cloudflare]"},
+		{"cdn", config.Domain{Canonical: "example.com", PathPrefix: "/api", StripPrefix: true}, "example.com/api [strip prefix]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			running := matchingRunning(target)
+			running.Domains = []config.Domain{tt.running}
+
+			drift := diffTarget(target, running)
+			if tt.want == "" {
+				if len(drift) != 0 {
+					t.Fatalf("drift = %+v, want none", drift)
+				}
+				return
+			}
+			if len(drift) != 1 || drift[0].Field != "domains" {
+				t.Fatalf("drift = %+v, want a single domains entry", drift)
+			}
+			if drift[0].Local != "example.com/api [strip prefix, cdn cloudflare]" || drift[0].Running != tt.want {
+				t.Errorf("domains drift = %q -> %q, want %q", drift[0].Local, drift[0].Running, tt.want)
+			}
+		})
+	}
+}
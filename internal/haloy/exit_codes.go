@@ -0,0 +1,108 @@
+package haloy
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes returned by the haloy CLI, so scripts and CI pipelines can branch
+// on the kind of failure. The values are part of the CLI's interface: never
+// renumber them, only add new ones.
+const (
+	ExitOK            = 0
+	ExitError         = 1
+	ExitConfig        = 2
+	ExitAuth          = 3
+	ExitBuild         = 4
+	ExitHealthCheck   = 5
+	ExitServer        = 6
+	ExitInputRequired = 7
+)
+
+// exitCodes documents every exit code, in order, for 'haloy help exit-codes'.
+var exitCodes = []struct {
+	code        int
+	name        string
+	description string
+}{
+	{ExitOK, "success", "The command completed."},
+	{ExitError, "error", "A failure not covered by a more specific code."},
+	{ExitConfig, "config", "The deploy config could not be loaded, is invalid, or a secret in it could not be resolved."},
+	{ExitAuth, "auth", "No API token was found, or the server rejected it."},
+	{ExitBuild, "build", "Building an image failed."},
+	{ExitHealthCheck, "health-check", "The deployment failed because the new containers never passed their health check."},
+	{ExitServer, "server", "The server could not be reached, answered with an error, or the deployment failed on it."},
+	{ExitInputRequired, "input-required", "The command needed input that can't be asked for in non-interactive mode."},
+}
+
+// exitCodeError attaches an exit code to an error without changing its
+// message.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string { return e.err.Error() }
+
+func (e *exitCodeError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitCodeError{code: code, err: err}
+}
+
+// exitCodeFor returns the exit code for an error returned by a command. An
+// explicit code set with withExitCode wins over the error classes packages
+// expose.
+func exitCodeFor(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var codeErr *exitCodeError
+	if errors.As(err, &codeErr) {
+		return codeErr.code
+	}
+
+	var httpErr *apiclient.HTTPError
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, ui.ErrInputRequired):
+		return ExitInputRequired
+	case errors.Is(err, configloader.ErrConfig):
+		return ExitConfig
+	case errors.Is(err, apiclient.ErrUnauthorized), errors.Is(err, errNoAPIToken):
+		return ExitAuth
+	case errors.Is(err, apiclient.ErrUnreachable), errors.As(err, &httpErr), errors.As(err, &urlErr):
+		return ExitServer
+	default:
+		return ExitError
+	}
+}
+
+func ExitCodesHelpCmd() *cobra.Command {
+	var b strings.Builder
+	b.WriteString("Exit codes returned by haloy commands:\n\n")
+	for _, c := range exitCodes {
+		fmt.Fprintf(&b, "  %d  %-15s %s\n", c.code, c.name, c.description)
+	}
+	b.WriteString(`
+When several targets fail in different ways, the code of the first error
+reported is returned.`)
+
+	// A command without Run is listed by cobra as a help topic.
+	return &cobra.Command{
+		Use:   "exit-codes",
+		Short: "Exit codes and what they mean",
+		Long:  b.String(),
+	}
+}
@@ -0,0 +1,90 @@
+package haloy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/logging"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	_, configErr := configloader.ExtractTargets(config.DeployConfig{}, "yaml")
+	if configErr == nil {
+		t.Fatal("expected ExtractTargets to fail for an empty config")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, ExitOK},
+		{"plain error", errors.New("boom"), ExitError},
+		{"config error", fmt.Errorf("unable to load config: %w", configErr), ExitConfig},
+		{"unauthorized", fmt.Errorf("server x: %w", fmt.Errorf("%w - check your token", apiclient.ErrUnauthorized)), ExitAuth},
+		{"no token", &PrefixedError{Err: fmt.Errorf("unable to get token: %w", errNoAPIToken)}, ExitAuth},
+		{"unreachable", fmt.Errorf("server not available: %w", apiclient.ErrUnreachable), ExitServer},
+		{"http error", &apiclient.HTTPError{Method: "POST", StatusCode: 500, Body: "boom"}, ExitServer},
+		{"input required", fmt.Errorf("%w: token", ui.ErrInputRequired), ExitInputRequired},
+		{"explicit code wins", withExitCode(ExitBuild, fmt.Errorf("failed: %w", apiclient.ErrUnreachable)), ExitBuild},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeFor(tt.err); got != tt.want {
+				t.Errorf("exitCodeFor(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeploymentFailedErrorExitCode(t *testing.T) {
+	healthCheck := logging.LogEntry{
+		IsDeploymentFailed: true,
+		Fields:             map[string]any{logging.AttrFailureKind: logging.FailureKindHealthCheck},
+	}
+	if got := exitCodeFor(&PrefixedError{Err: deploymentFailedError("01ABC", healthCheck)}); got != ExitHealthCheck {
+		t.Errorf("health check failure exit code = %d, want %d", got, ExitHealthCheck)
+	}
+
+	other := logging.LogEntry{IsDeploymentFailed: true}
+	if got := exitCodeFor(deploymentFailedError("01ABC", other)); got != ExitServer {
+		t.Errorf("other deployment failure exit code = %d, want %d", got, ExitServer)
+	}
+}
+
+func TestExitCodesHelpListsEveryCode(t *testing.T) {
+	help := ExitCodesHelpCmd().Long
+	for _, c := range exitCodes {
+		if !strings.Contains(help, fmt.Sprintf("%d  %s", c.code, c.name)) {
+			t.Errorf("help does not list exit code %d (%s):\n%s", c.code, c.name, help)
+		}
+	}
+}
+
+func TestNonInteractiveFlagOverridesCIDetection(t *testing.T) {
+	t.Setenv("CI", "true")
+	t.Cleanup(func() { ui.SetNonInteractive(false) })
+
+	if err := runRootCommand(t, "help", "exit-codes"); err != nil {
+		t.Fatalf("help exit-codes failed: %v", err)
+	}
+	if err := runRootCommand(t, "targets", "--non-interactive=false", "-c", writeTestConfig(t, "name: app\nserver: localhost")); err != nil {
+		t.Fatalf("targets failed: %v", err)
+	}
+	if ui.NonInteractive() {
+		t.Errorf("expected --non-interactive=false to override CI detection")
+	}
+	if err := runRootCommand(t, "targets", "-c", writeTestConfig(t, "name: app\nserver: localhost")); err != nil {
+		t.Fatalf("targets failed: %v", err)
+	}
+	if !ui.NonInteractive() {
+		t.Errorf("expected CI=true to enable non-interactive mode")
+	}
+}
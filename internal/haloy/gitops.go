@@ -0,0 +1,239 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+func GitOpsCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gitops",
+		Short: "Inspect and control GitOps on the server",
+		Long: `Servers with a gitops section in haloyd.yaml fetch a git repository on an
+interval and deploy the targets it declares for them. A target is redeployed
+when its config changes or it stops running, and an app deployed from the
+repository is destroyed, keeping its volumes, once its target is removed.`,
+	}
+
+	cmd.AddCommand(
+		gitOpsStatusCmd(configPath, flags),
+		gitOpsPauseCmd(configPath, flags),
+		gitOpsResumeCmd(configPath, flags),
+		gitOpsSyncCmd(configPath, flags),
+	)
+
+	return cmd
+}
+
+func gitOpsStatusCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the synced commit and the state of each app",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return forEachGitOpsServer(cmd, *configPath, flags, serverFlag, func(ctx context.Context, api *apiclient.APIClient, prefix string) error {
+				var status apitypes.GitOpsStatusResponse
+				if err := api.Get(ctx, "gitops", &status); err != nil {
+					return fmt.Errorf("failed to get GitOps status: %w", explainGitOpsError(err))
+				}
+				printGitOpsStatus(status, prefix)
+				return nil
+			})
+		},
+	}
+
+	addGitOpsFlags(cmd, flags, &serverFlag)
+	return cmd
+}
+
+func gitOpsPauseCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var (
+		serverFlag string
+		reason     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "pause",
+		Short: "Stop applying changes from the repository",
+		Long: `Stop deploying and destroying apps from the repository, e.g. while
+deploying a fix by hand during an incident. The repository is still fetched,
+so 'haloy gitops status' shows what would be applied. The pause lasts until
+'haloy gitops resume', also across restarts of haloyd.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return forEachGitOpsServer(cmd, *configPath, flags, serverFlag, func(ctx context.Context, api *apiclient.APIClient, prefix string) error {
+				if err := api.Post(ctx, "gitops/pause", apitypes.GitOpsPauseRequest{Reason: reason}, nil); err != nil {
+					return fmt.Errorf("failed to pause GitOps: %w", explainGitOpsError(err))
+				}
+				(&ui.PrefixedUI{Prefix: prefix}).Success("GitOps paused")
+				return nil
+			})
+		},
+	}
+
+	addGitOpsFlags(cmd, flags, &serverFlag)
+	cmd.Flags().StringVar(&reason, "reason", "", "Why GitOps is paused, shown by status")
+	return cmd
+}
+
+func gitOpsResumeCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "resume",
+		Short: "Apply changes from the repository again",
+		Long:  "Lift a pause and sync right away, applying what changed in the repository meanwhile.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return forEachGitOpsServer(cmd, *configPath, flags, serverFlag, func(ctx context.Context, api *apiclient.APIClient, prefix string) error {
+				if err := api.Post(ctx, "gitops/resume", nil, nil); err != nil {
+					return fmt.Errorf("failed to resume GitOps: %w", explainGitOpsError(err))
+				}
+				(&ui.PrefixedUI{Prefix: prefix}).Success("GitOps resumed")
+				return nil
+			})
+		},
+	}
+
+	addGitOpsFlags(cmd, flags, &serverFlag)
+	return cmd
+}
+
+func gitOpsSyncCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Sync with the repository now",
+		Long: `Fetch the repository and apply it without waiting for the interval. Apps
+whose deploy failed are retried, which otherwise only happens once their
+config changes.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return forEachGitOpsServer(cmd, *configPath, flags, serverFlag, func(ctx context.Context, api *apiclient.APIClient, prefix string) error {
+				if err := api.Post(ctx, "gitops/sync", nil, nil); err != nil {
+					return fmt.Errorf("failed to start GitOps sync: %w", explainGitOpsError(err))
+				}
+				(&ui.PrefixedUI{Prefix: prefix}).Info("GitOps sync started, follow it with 'haloy gitops status'")
+				return nil
+			})
+		},
+	}
+
+	addGitOpsFlags(cmd, flags, &serverFlag)
+	return cmd
+}
+
+func addGitOpsFlags(cmd *cobra.Command, flags *appCmdFlags, serverFlag *string) {
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringVarP(serverFlag, "server", "s", "", "Server URL or profile name (overrides config file)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Use the servers of specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Use the servers of all targets")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+}
+
+// forEachGitOpsServer runs fn against the server named with --server, or
+// else the servers of the selected targets.
+func forEachGitOpsServer(cmd *cobra.Command, configPath string, flags *appCmdFlags, serverFlag string, fn func(ctx context.Context, api *apiclient.APIClient, prefix string) error) error {
+	var servers []serverTarget
+	if serverFlag != "" {
+		servers = []serverTarget{{Server: resolveServerRef(serverFlag)}}
+	} else {
+		var err error
+		if servers, err = resolveServerTargets(cmd.Context(), cmd, configPath, flags); err != nil {
+			return err
+		}
+	}
+
+	g, ctx := errgroup.WithContext(cmd.Context())
+	for _, server := range servers {
+		g.Go(func() error {
+			prefix := ""
+			if len(servers) > 1 {
+				prefix = server.Server
+			}
+			token, err := getToken(server.TargetConfig, server.Server)
+			if err != nil {
+				return &PrefixedError{Err: fmt.Errorf("unable to get token: %w", err), Prefix: prefix}
+			}
+			api, err := apiclient.New(server.Server, token)
+			if err != nil {
+				return &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
+			}
+			if err := fn(ctx, api, prefix); err != nil {
+				return &PrefixedError{Err: err, Prefix: prefix}
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// explainGitOpsError points at an outdated haloyd when the endpoints are
+// missing.
+func explainGitOpsError(err error) error {
+	var httpErr *apiclient.HTTPError
+	if errors.Is(err, apiclient.ErrNotFound) || (errors.As(err, &httpErr) && httpErr.Body == "404 page not found") {
+		return errors.New("the server doesn't support GitOps, upgrade haloyd to use this command")
+	}
+	return err
+}
+
+func printGitOpsStatus(status apitypes.GitOpsStatusResponse, prefix string) {
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	pui.Info("Repository: %s (%s)", status.Repo, status.Branch)
+	switch {
+	case status.LastSync.IsZero():
+		pui.Info("Not synced yet")
+	case status.Commit != "":
+		pui.Info("Commit: %s, last sync %s", shortCommit(status.Commit), helpers.FormatTime(status.LastSync))
+	}
+	if status.Error != "" {
+		pui.Error("Last sync failed: %s", status.Error)
+	}
+	if status.Paused {
+		paused := "Paused since " + helpers.FormatTime(status.PausedAt)
+		if status.PauseReason != "" {
+			paused += ": " + status.PauseReason
+		}
+		pui.Warn("%s", paused)
+	}
+
+	if len(status.Apps) == 0 {
+		pui.Info("No apps declared for this server")
+		return
+	}
+	rows := make([][]string, 0, len(status.Apps))
+	for _, app := range status.Apps {
+		commit, applied := "-", "-"
+		if app.Commit != "" {
+			commit = shortCommit(app.Commit)
+			applied = helpers.FormatTime(app.AppliedAt)
+		}
+		rows = append(rows, []string{app.App, app.Target, app.State, commit, applied})
+	}
+	ui.Table([]string{"APP", "TARGET", "STATE", "COMMIT", "APPLIED"}, rows)
+	for _, app := range status.Apps {
+		if app.Error != "" {
+			pui.Warn("%s: %s", app.App, app.Error)
+		}
+	}
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
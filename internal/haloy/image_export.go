@@ -0,0 +1,303 @@
+package haloy
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+const (
+	// maxExportMetadataEntry is the largest archive entry kept in memory
+	// while scanning an export. Manifests and image configs are far smaller;
+	// layers are only counted.
+	maxExportMetadataEntry = 8 << 20 // 8 MiB
+	// maxExportMetadata bounds the memory a scan holds in all.
+	maxExportMetadata = 64 << 20 // 64 MiB
+)
+
+// openImageExport starts 'docker save' for imageRef and returns its output,
+// so the image never has to be written to the local disk. Closing the reader
+// before the end stops the export.
+var openImageExport = func(ctx context.Context, imageRef string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := exec.CommandContext(ctx, "docker", "save", imageRef)
+	export := &exportReader{cmd: cmd, cancel: cancel}
+	cmd.Stderr = &export.stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to start docker save: %w", err)
+	}
+	export.stdout = stdout
+	return export, nil
+}
+
+// exportReader reads the output of 'docker save'. When the output ends it
+// waits for the command, so a failed export surfaces as a read error instead
+// of a truncated archive.
+type exportReader struct {
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	stdout io.Reader
+	stderr bytes.Buffer
+
+	waitOnce sync.Once
+	waitErr  error
+}
+
+func (r *exportReader) Read(p []byte) (int, error) {
+	n, err := r.stdout.Read(p)
+	if err == io.EOF {
+		if werr := r.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (r *exportReader) Close() error {
+	r.cancel()
+	r.wait()
+	return nil
+}
+
+func (r *exportReader) wait() error {
+	r.waitOnce.Do(func() {
+		if err := r.cmd.Wait(); err != nil {
+			r.waitErr = fmt.Errorf("docker save failed: %w: %s", err, strings.TrimSpace(r.stderr.String()))
+		}
+	})
+	return r.waitErr
+}
+
+// imageArchive describes a docker save archive without holding its layers.
+type imageArchive struct {
+	size     int64             // bytes in the archive
+	entries  map[string]int64  // size of every file, by name
+	metadata map[string][]byte // contents of the files small enough to keep
+}
+
+// scanImage exports imageRef once and records what an upload needs to know
+// about it.
+func scanImage(ctx context.Context, imageRef string) (imageArchive, error) {
+	export, err := openImageExport(ctx, imageRef)
+	if err != nil {
+		return imageArchive{}, err
+	}
+	defer export.Close()
+	return scanImageArchive(export)
+}
+
+// scanImageArchive reads a docker save archive in a single pass. Files up to
+// maxExportMetadataEntry are kept until maxExportMetadata is used up; the
+// rest are skipped.
+func scanImageArchive(r io.Reader) (imageArchive, error) {
+	counter := &countingReader{reader: r}
+	tr := tar.NewReader(counter)
+	archive := imageArchive{
+		entries:  make(map[string]int64),
+		metadata: make(map[string][]byte),
+	}
+
+	var kept int64
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return imageArchive{}, fmt.Errorf("failed to read image archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		archive.entries[header.Name] = header.Size
+		if header.Size > maxExportMetadataEntry || kept+header.Size > maxExportMetadata {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return imageArchive{}, fmt.Errorf("failed to read %s from image archive: %w", header.Name, err)
+		}
+		archive.metadata[header.Name] = data
+		kept += header.Size
+	}
+
+	// Read the end-of-archive padding too, so size matches what an upload sends.
+	if _, err := io.Copy(io.Discard, counter); err != nil {
+		return imageArchive{}, fmt.Errorf("failed to read image archive: %w", err)
+	}
+	archive.size = counter.n
+	return archive, nil
+}
+
+// layout returns the manifest, config, and layers of the archive.
+func (a imageArchive) layout() (apitypes.ImageManifestEntry, []byte, map[string]layerInfo, error) {
+	manifestData, ok := a.metadata["manifest.json"]
+	if !ok {
+		return apitypes.ImageManifestEntry{}, nil, nil, fmt.Errorf("manifest.json not found in image archive")
+	}
+
+	var manifests []apitypes.ImageManifestEntry
+	if err := json.Unmarshal(manifestData, &manifests); err != nil {
+		return apitypes.ImageManifestEntry{}, nil, nil, fmt.Errorf("failed to parse manifest.json: %w", err)
+	}
+	if len(manifests) == 0 {
+		return apitypes.ImageManifestEntry{}, nil, nil, fmt.Errorf("empty manifest")
+	}
+	manifest := manifests[0]
+
+	configData, ok := a.metadata[manifest.Config]
+	if !ok {
+		return apitypes.ImageManifestEntry{}, nil, nil, fmt.Errorf("config %s not found in image archive", manifest.Config)
+	}
+
+	layers := make(map[string]layerInfo, len(manifest.Layers))
+	for _, layerPath := range manifest.Layers {
+		size, ok := a.entries[layerPath]
+		if !ok {
+			return apitypes.ImageManifestEntry{}, nil, nil, fmt.Errorf("layer %s not found in image archive", layerPath)
+		}
+		digest := extractDigestFromPath(layerPath)
+		layers[digest] = layerInfo{
+			digest:  digest,
+			tarPath: layerPath,
+			size:    size,
+		}
+	}
+
+	return manifest, configData, layers, nil
+}
+
+// layerUploadFunc uploads the layer read from src.
+type layerUploadFunc func(ctx context.Context, api *apiclient.APIClient, src *layerSource, info layerInfo, digest string, progress *ui.ProgressBar) error
+
+// uploadExportedLayers exports imageRef again and uploads the missing layers
+// as the export reaches them. Layers go up one after another straight from
+// the export, so none is held in memory or on disk.
+func uploadExportedLayers(ctx context.Context, api *apiclient.APIClient, imageRef string, missing []layerInfo, upload layerUploadFunc, progress *ui.ProgressBar) error {
+	pending := make(map[string]layerInfo, len(missing))
+	for _, info := range missing {
+		pending[info.tarPath] = info
+	}
+
+	export, err := openImageExport(ctx, imageRef)
+	if err != nil {
+		return err
+	}
+	defer export.Close()
+
+	tr := tar.NewReader(export)
+	for len(pending) > 0 {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read image archive: %w", err)
+		}
+		info, ok := pending[header.Name]
+		if !ok || header.Typeflag != tar.TypeReg {
+			continue
+		}
+		delete(pending, header.Name)
+
+		src := &layerSource{imageRef: imageRef, tarPath: info.tarPath, current: tr}
+		if err := upload(ctx, api, src, info, info.digest, progress); err != nil {
+			return err
+		}
+		progress.CompleteItem()
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("%d layers not found in image archive", len(pending))
+	}
+	return nil
+}
+
+// layerSource reads a layer of an image export. The first open returns the
+// layer from the export being uploaded; any later one, made to retry a failed
+// upload, starts a new export and skips ahead to the layer.
+type layerSource struct {
+	imageRef string
+	tarPath  string
+	current  io.Reader
+}
+
+func (s *layerSource) open(ctx context.Context) (io.ReadCloser, error) {
+	if s.current != nil {
+		r := s.current
+		s.current = nil
+		return io.NopCloser(r), nil
+	}
+
+	export, err := openImageExport(ctx, s.imageRef)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(export)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			export.Close()
+			return nil, fmt.Errorf("layer %s not found in image archive", s.tarPath)
+		}
+		if err != nil {
+			export.Close()
+			return nil, err
+		}
+		if header.Name == s.tarPath && header.Typeflag == tar.TypeReg {
+			return &layerReader{Reader: tr, closer: export}, nil
+		}
+	}
+}
+
+// uploadImageFull uploads the whole image as a single archive, streamed from
+// a new export.
+func uploadImageFull(ctx context.Context, api *apiclient.APIClient, imageRef string, archiveSize int64, supportsImagePreflight bool) error {
+	if supportsImagePreflight {
+		if err := reportFullUploadDiskSpace(ctx, api, uint64(archiveSize)); err != nil {
+			return err
+		}
+	}
+
+	export, err := openImageExport(ctx, imageRef)
+	if err != nil {
+		return err
+	}
+	defer export.Close()
+
+	fileName := strings.NewReplacer("/", "-", ":", "-").Replace(imageRef) + ".tar"
+	if err := api.PostReader(ctx, "images/upload", "image", fileName, export); err != nil {
+		return fmt.Errorf("failed to upload image: %w", err)
+	}
+	return nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
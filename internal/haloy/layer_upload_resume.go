@@ -0,0 +1,158 @@
+package haloy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+const (
+	// layerUploadChunkSize bounds how much a dropped connection can cost.
+	layerUploadChunkSize = 32 << 20 // 32 MiB
+
+	// layerResumeMaxStalls is how many consecutive attempts may fail without
+	// the server offset advancing before the upload is abandoned.
+	layerResumeMaxStalls  = 5
+	layerResumeMaxBackoff = 30 * time.Second
+)
+
+// uploadLayerResumable uploads a layer in Content-Range chunks. After a
+// failure it asks the server how many bytes it holds and continues from there
+// instead of re-sending the whole layer.
+func uploadLayerResumable(ctx context.Context, api *apiclient.APIClient, src *layerSource, info layerInfo, digest string, progress *ui.ProgressBar) error {
+	var reported int64 // bytes of this layer currently counted in progress
+	stalls := 0
+	backoff := layerUploadInitialBackoff
+
+	for {
+		lastOffset := reported
+		status, err := getLayerUploadStatus(ctx, api, digest)
+		if err == nil {
+			if status.Complete {
+				progress.Add(info.size - reported)
+				return nil
+			}
+			progress.Add(status.Offset - reported)
+			reported = status.Offset
+			lastOffset = status.Offset
+
+			err = sendLayerChunks(ctx, api, src, info, digest, status.Offset, progress, &reported)
+			if err == nil {
+				return nil
+			}
+		}
+
+		if ctx.Err() != nil {
+			progress.Add(-reported)
+			return ctx.Err()
+		}
+
+		// Rejections other than an offset conflict are deterministic.
+		var statusErr *layerUploadStatusError
+		if errors.As(err, &statusErr) && statusErr.statusCode >= 400 && statusErr.statusCode < 500 && statusErr.statusCode != http.StatusConflict {
+			progress.Add(-reported)
+			return err
+		}
+
+		if reported > lastOffset {
+			stalls = 0
+			backoff = layerUploadInitialBackoff
+		} else {
+			stalls++
+			if stalls >= layerResumeMaxStalls {
+				progress.Add(-reported)
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			progress.Add(-reported)
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, layerResumeMaxBackoff)
+	}
+}
+
+func getLayerUploadStatus(ctx context.Context, api *apiclient.APIClient, digest string) (apitypes.LayerUploadStatusResponse, error) {
+	var status apitypes.LayerUploadStatusResponse
+	if err := api.Get(ctx, "images/layers/uploads/"+digest, &status); err != nil {
+		return status, fmt.Errorf("failed to get upload status for layer %s: %w", digest, err)
+	}
+	return status, nil
+}
+
+// sendLayerChunks streams the layer from offset to the end, one request per
+// chunk. reported is advanced by every byte handed to the transport.
+func sendLayerChunks(ctx context.Context, api *apiclient.APIClient, src *layerSource, info layerInfo, digest string, offset int64, progress *ui.ProgressBar, reported *int64) error {
+	layerReader, err := src.open(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open layer %s: %w", digest, err)
+	}
+	defer layerReader.Close()
+
+	if _, err := io.CopyN(io.Discard, layerReader, offset); err != nil {
+		return fmt.Errorf("failed to seek layer %s to offset %d: %w", digest, offset, err)
+	}
+
+	for offset < info.size {
+		chunkLen := min(int64(layerUploadChunkSize), info.size-offset)
+		chunk := &progressReader{
+			reader:   io.LimitReader(layerReader, chunkLen),
+			progress: progress,
+		}
+
+		status, err := sendLayerChunk(ctx, api, digest, chunk, offset, chunkLen, info.size)
+		*reported += chunk.count.Load()
+		if err != nil {
+			return err
+		}
+		if status.Complete {
+			return nil
+		}
+		offset = status.Offset
+	}
+
+	return nil
+}
+
+func sendLayerChunk(ctx context.Context, api *apiclient.APIClient, digest string, body io.Reader, offset, length, total int64) (apitypes.LayerUploadStatusResponse, error) {
+	var status apitypes.LayerUploadStatusResponse
+
+	req, err := api.NewRequest(ctx, http.MethodPatch, "images/layers/uploads/"+digest, body)
+	if err != nil {
+		return status, fmt.Errorf("failed to create request for layer %s: %w", digest, err)
+	}
+	req.ContentLength = length
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, total))
+
+	resp, err := api.DoUpload(req)
+	if err != nil {
+		return status, fmt.Errorf("failed to upload layer %s: %w", digest, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return status, &layerUploadStatusError{
+			digest:     digest,
+			statusCode: resp.StatusCode,
+			body:       string(body),
+		}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return status, fmt.Errorf("failed to decode upload response for layer %s: %w", digest, err)
+	}
+	return status, nil
+}
@@ -3,11 +3,13 @@ package haloy
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"strconv"
 
 	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
 	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/configloader"
 	"github.com/haloydev/haloy/internal/docker"
@@ -23,6 +25,7 @@ func LogsCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
 		tail          int
 		noFollow      bool
 		raw           bool
+		deploymentID  string
 	)
 
 	cmd := &cobra.Command{
@@ -33,6 +36,9 @@ func LogsCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
 By default, streams logs from the first container. Use flags to target
 specific containers or all containers.
 
+With --deployment, prints the log haloyd recorded for a deployment instead,
+the one 'haloy deploy' streams, so it can be read after the deployment ended.
+
 The logs are streamed in real-time and will continue until interrupted (Ctrl+C).
 
 Examples:
@@ -55,7 +61,10 @@ Examples:
   haloy logs --no-follow
 
   # Raw logs, no container names, no extra formatting
-  haloy logs --raw`,
+  haloy logs --raw
+
+  # Print the log of a deployment, also after it ended
+  haloy logs --deployment 01JZ4K9X7B2N5Q8R3T6V1W0YAB`,
 		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			ctx := cmd.Context()
@@ -63,6 +72,9 @@ Examples:
 			if allContainers && containerID != "" {
 				return fmt.Errorf("cannot specify both --all-containers and --container")
 			}
+			if deploymentID != "" && (allContainers || containerID != "") {
+				return fmt.Errorf("cannot specify --deployment with --all-containers or --container")
+			}
 
 			rawDeployConfig, format, err := configloader.Load(ctx, *configPath, flags.targets, flags.all)
 			if err != nil {
@@ -79,6 +91,10 @@ Examples:
 				return err
 			}
 
+			if deploymentID != "" {
+				return printDeploymentLogs(ctx, targets, deploymentID, raw)
+			}
+
 			g, ctx := errgroup.WithContext(ctx)
 			for _, target := range targets {
 				g.Go(func() error {
@@ -102,6 +118,7 @@ Examples:
 	cmd.Flags().BoolVar(&allContainers, "all-containers", false, "Stream logs from all containers")
 	cmd.Flags().BoolVar(&noFollow, "no-follow", false, "Print existing logs and exit without following")
 	cmd.Flags().BoolVar(&raw, "raw", false, "Raw logs with no extra formatting")
+	cmd.Flags().StringVar(&deploymentID, "deployment", "", "Print the recorded log of a deployment")
 
 	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
 
@@ -175,3 +192,51 @@ func streamAppLogs(ctx context.Context, targetConfig *config.TargetConfig, targe
 	}
 	return nil
 }
+
+// printDeploymentLogs prints the recorded log of a deployment from the
+// server of whichever target has it.
+func printDeploymentLogs(ctx context.Context, targets map[string]config.TargetConfig, deploymentID string, raw bool) error {
+	servers := make(map[string]bool)
+	for _, target := range targets {
+		if servers[target.Server] {
+			continue
+		}
+		servers[target.Server] = true
+
+		token, err := getToken(&target, target.Server)
+		if err != nil {
+			return fmt.Errorf("unable to get token: %w", err)
+		}
+		api, err := apiclient.New(target.Server, token)
+		if err != nil {
+			return fmt.Errorf("failed to create API client: %w", err)
+		}
+
+		var after int64
+		for {
+			path := fmt.Sprintf("deployments/%s/logs", url.PathEscape(deploymentID))
+			if after > 0 {
+				path += "?after=" + strconv.FormatInt(after, 10)
+			}
+			var response apitypes.DeploymentLogsResponse
+			if err := api.Get(ctx, path, &response); err != nil {
+				if errors.Is(err, apiclient.ErrNotFound) && after == 0 {
+					break
+				}
+				return fmt.Errorf("failed to get logs of deployment %s: %w", deploymentID, err)
+			}
+			for _, entry := range response.Entries {
+				if raw {
+					fmt.Println(entry.Message)
+				} else {
+					ui.DisplayLogEntry(entry, "")
+				}
+			}
+			if response.Next == 0 {
+				return nil
+			}
+			after = response.Next
+		}
+	}
+	return fmt.Errorf("no logs recorded for deployment %s", deploymentID)
+}
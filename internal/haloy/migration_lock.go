@@ -0,0 +1,148 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+func MigrationLockCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migration-lock",
+		Short: "Inspect and release migration locks on the server",
+		Long: `Inspect and release the migration locks that containers of targets with
+migration_lock take, so only one replica runs migrations at a time.`,
+	}
+
+	cmd.AddCommand(
+		migrationLockStatusCmd(configPath, flags),
+		migrationLockReleaseCmd(configPath, flags),
+	)
+
+	return cmd
+}
+
+func migrationLockStatusCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show held migration locks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return forEachLockServer(cmd, *configPath, flags, serverFlag, func(ctx context.Context, api *apiclient.APIClient, prefix string) error {
+				var response apitypes.MigrationLocksResponse
+				if err := api.Get(ctx, "migration-locks", &response); err != nil {
+					if errors.Is(err, apiclient.ErrNotFound) {
+						err = errors.New("the server doesn't support migration locks, upgrade haloyd to use this command")
+					}
+					return &PrefixedError{Err: fmt.Errorf("failed to get migration locks: %w", err), Prefix: prefix}
+				}
+				printMigrationLocks(response.Locks, prefix)
+				return nil
+			})
+		},
+	}
+
+	addMigrationLockFlags(cmd, flags, &serverFlag)
+	return cmd
+}
+
+func migrationLockReleaseCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "release <name>",
+		Short: "Release a stale migration lock",
+		Long: `Release a migration lock whoever holds it, for a container that crashed
+while holding it. Locks also expire on their own once their TTL passes.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			return forEachLockServer(cmd, *configPath, flags, serverFlag, func(ctx context.Context, api *apiclient.APIClient, prefix string) error {
+				pui := &ui.PrefixedUI{Prefix: prefix}
+				path := fmt.Sprintf("migration-locks/%s/release?force=true", url.PathEscape(name))
+				if err := api.Post(ctx, path, nil, nil); err != nil {
+					if errors.Is(err, apiclient.ErrNotFound) {
+						pui.Info("Migration lock '%s' is not held", name)
+						return nil
+					}
+					return &PrefixedError{Err: fmt.Errorf("failed to release migration lock: %w", err), Prefix: prefix}
+				}
+				pui.Success("Released migration lock '%s'", name)
+				return nil
+			})
+		},
+	}
+
+	addMigrationLockFlags(cmd, flags, &serverFlag)
+	return cmd
+}
+
+func addMigrationLockFlags(cmd *cobra.Command, flags *appCmdFlags, serverFlag *string) {
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringVarP(serverFlag, "server", "s", "", "Server URL or profile name (overrides config file)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Use the servers of specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Use the servers of all targets")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+}
+
+// forEachLockServer runs fn against --server, or each server of the selected
+// targets.
+func forEachLockServer(cmd *cobra.Command, configPath string, flags *appCmdFlags, serverFlag string, fn func(ctx context.Context, api *apiclient.APIClient, prefix string) error) error {
+	ctx := cmd.Context()
+	run := func(ctx context.Context, targetConfig *config.TargetConfig, server, prefix string) error {
+		token, err := getToken(targetConfig, server)
+		if err != nil {
+			return &PrefixedError{Err: fmt.Errorf("unable to get token: %w", err), Prefix: prefix}
+		}
+		api, err := apiclient.New(server, token)
+		if err != nil {
+			return &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
+		}
+		return fn(ctx, api, prefix)
+	}
+
+	if serverFlag != "" {
+		return run(ctx, nil, resolveServerRef(serverFlag), "")
+	}
+
+	servers, err := resolveServerTargets(ctx, cmd, configPath, flags)
+	if err != nil {
+		return err
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	for _, serverTarget := range servers {
+		g.Go(func() error {
+			prefix := ""
+			if len(servers) > 1 {
+				prefix = serverTarget.Server
+			}
+			return run(ctx, serverTarget.TargetConfig, serverTarget.Server, prefix)
+		})
+	}
+	return g.Wait()
+}
+
+func printMigrationLocks(locks []apitypes.MigrationLockInfo, prefix string) {
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	if len(locks) == 0 {
+		pui.Info("No migration locks held")
+		return
+	}
+	rows := make([][]string, 0, len(locks))
+	for _, lock := range locks {
+		rows = append(rows, []string{lock.Name, lock.Holder, helpers.FormatTime(lock.AcquiredAt), helpers.FormatTime(lock.ExpiresAt)})
+	}
+	ui.Table([]string{"NAME", "HOLDER", "ACQUIRED", "EXPIRES"}, rows)
+}
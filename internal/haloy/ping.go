@@ -0,0 +1,262 @@
+package haloy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+const pingDialTimeout = 5 * time.Second
+
+func PingCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var count int
+
+	cmd := &cobra.Command{
+		Use:   "ping [target]",
+		Short: "Measure latency between the CLI, the server and the app",
+		Long: `Measure each hop a request to the app takes, to tell network problems apart
+from a slow app:
+
+  cli → haloyd        requests from this machine to the haloyd API
+  haloyd → container  the app's health check, run by haloyd against each container
+  dns, tcp, tls       resolving each app domain, connecting to it on port 443
+                      and the TLS handshake, from this machine
+
+Each is measured --count times and reported as min/avg/max.`,
+		Example: `  haloy ping
+  haloy ping production --count 10`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if len(args) == 1 {
+				if flags.all {
+					return errors.New("cannot specify both a target argument and --all")
+				}
+				flags.targets = append(flags.targets, args[0])
+			}
+			if count < 1 || count > 10 {
+				return errors.New("--count must be between 1 and 10")
+			}
+
+			rawDeployConfig, format, err := configloader.Load(ctx, *configPath, flags.targets, flags.all)
+			if err != nil {
+				return fmt.Errorf("unable to load config: %w", err)
+			}
+
+			resolvedDeployConfig, err := configloader.ResolveSecrets(ctx, rawDeployConfig, *configPath)
+			if err != nil {
+				return fmt.Errorf("failed to resolve secrets: %w", err)
+			}
+
+			targets, err := configloader.ExtractTargets(resolvedDeployConfig, format)
+			if err != nil {
+				return err
+			}
+
+			// Targets run one after another, so they don't skew each other's
+			// timings.
+			var errs []error
+			for _, targetName := range slices.Sorted(maps.Keys(targets)) {
+				target := targets[targetName]
+				prefix := ""
+				if len(targets) > 1 {
+					prefix = targetName
+				}
+				ui.Info("Pinging %s on %s", target.Name, target.Server)
+				rows, err := pingTarget(ctx, target, count)
+				if err != nil {
+					errs = append(errs, &PrefixedError{Err: err, Prefix: prefix})
+					continue
+				}
+				printPingRows(rows, prefix)
+			}
+			return errors.Join(errs...)
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Ping specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Ping all targets")
+	cmd.Flags().IntVarP(&count, "count", "n", 3, "Number of measurements of each hop (1-10)")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+
+	return cmd
+}
+
+// pingRow is the measurements of one hop.
+type pingRow struct {
+	hop      string
+	target   string
+	samples  []time.Duration
+	attempts int
+	err      error // of the last failed attempt
+}
+
+func (r *pingRow) add(d time.Duration, err error) {
+	r.attempts++
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.samples = append(r.samples, d)
+}
+
+func pingTarget(ctx context.Context, target config.TargetConfig, count int) ([]pingRow, error) {
+	token, err := getToken(&target, target.Server)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get token: %w", err)
+	}
+	api, err := apiclient.New(target.Server, token)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create API client: %w", err)
+	}
+
+	apiRow := pingRow{hop: "cli → haloyd", target: target.Server}
+	for range count {
+		start := time.Now()
+		err := api.HealthCheck(ctx)
+		apiRow.add(time.Since(start), err)
+	}
+	rows := []pingRow{apiRow}
+	if len(apiRow.samples) == 0 {
+		return nil, fmt.Errorf("server not available at %s: %w", target.Server, apiRow.err)
+	}
+
+	var response apitypes.AppPingResponse
+	path := fmt.Sprintf("ping/%s?count=%d", target.Name, count)
+	if err := api.Get(ctx, path, &response); err != nil {
+		var httpErr *apiclient.HTTPError
+		switch {
+		case errors.As(err, &httpErr) && httpErr.Body == "404 page not found":
+			return nil, errors.New("the server doesn't support ping, upgrade haloyd to use this command")
+		case errors.Is(err, apiclient.ErrNotFound):
+			return nil, fmt.Errorf("application '%s' is not currently deployed or running", target.Name)
+		}
+		return nil, fmt.Errorf("failed to ping containers: %w", err)
+	}
+	for _, c := range response.Containers {
+		row := pingRow{hop: "haloyd → container", target: helpers.SafeIDPrefix(c.ContainerID) + " " + c.Check, attempts: len(c.LatenciesMs) + c.Failed}
+		for _, ms := range c.LatenciesMs {
+			row.samples = append(row.samples, time.Duration(ms*float64(time.Millisecond)))
+		}
+		if c.Error != "" {
+			row.err = errors.New(c.Error)
+		}
+		rows = append(rows, row)
+	}
+
+	var wg sync.WaitGroup
+	domainRows := make([][]pingRow, len(target.Domains))
+	for i, domain := range target.Domains {
+		wg.Go(func() {
+			domainRows[i] = pingDomain(ctx, domain.Canonical, count)
+		})
+	}
+	wg.Wait()
+	for _, r := range domainRows {
+		rows = append(rows, r...)
+	}
+	return rows, nil
+}
+
+// pingDomain times resolving domain, connecting to it on port 443 and the
+// TLS handshake, count times over new connections.
+func pingDomain(ctx context.Context, domain string, count int) []pingRow {
+	dns := pingRow{hop: "dns", target: domain}
+	connect := pingRow{hop: "tcp", target: domain}
+	handshake := pingRow{hop: "tls", target: domain}
+	dialer := &net.Dialer{Timeout: pingDialTimeout}
+
+	for range count {
+		start := time.Now()
+		addrs, err := net.DefaultResolver.LookupHost(ctx, domain)
+		dns.add(time.Since(start), err)
+		if err != nil {
+			continue
+		}
+
+		start = time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addrs[0], "443"))
+		connect.add(time.Since(start), err)
+		if err != nil {
+			continue
+		}
+
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: domain})
+		hsCtx, cancel := context.WithTimeout(ctx, pingDialTimeout)
+		start = time.Now()
+		err = tlsConn.HandshakeContext(hsCtx)
+		handshake.add(time.Since(start), err)
+		cancel()
+		tlsConn.Close()
+	}
+	return []pingRow{dns, connect, handshake}
+}
+
+func printPingRows(rows []pingRow, prefix string) {
+	tableRows := make([][]string, 0, len(rows))
+	var slowest *pingRow
+	var slowestAvg time.Duration
+	for i, row := range rows {
+		minLatency, avgLatency, maxLatency := "-", "-", "-"
+		if len(row.samples) > 0 {
+			lo, avg, hi := latencyStats(row.samples)
+			minLatency, avgLatency, maxLatency = formatLatency(lo), formatLatency(avg), formatLatency(hi)
+			if avg > slowestAvg {
+				slowest, slowestAvg = &rows[i], avg
+			}
+		}
+		failed := "-"
+		if row.attempts > 0 {
+			failed = fmt.Sprintf("%d/%d", row.attempts-len(row.samples), row.attempts)
+		}
+		tableRows = append(tableRows, []string{row.hop, row.target, minLatency, avgLatency, maxLatency, failed})
+	}
+
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	ui.Table([]string{"HOP", "TARGET", "MIN", "AVG", "MAX", "FAILED"}, tableRows)
+	for _, row := range rows {
+		if row.err != nil {
+			pui.Warn("%s %s: %v", row.hop, row.target, row.err)
+		}
+	}
+	if slowest != nil {
+		pui.Info("Slowest hop: %s (%s avg)", slowest.hop, formatLatency(slowestAvg))
+	}
+}
+
+func latencyStats(samples []time.Duration) (lo, avg, hi time.Duration) {
+	var total time.Duration
+	lo, hi = samples[0], samples[0]
+	for _, d := range samples {
+		lo, hi = min(lo, d), max(hi, d)
+		total += d
+	}
+	return lo, total / time.Duration(len(samples)), hi
+}
+
+func formatLatency(d time.Duration) string {
+	switch {
+	case d < 10*time.Millisecond:
+		return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+	case d < time.Second:
+		return fmt.Sprintf("%.0fms", float64(d)/float64(time.Millisecond))
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
@@ -0,0 +1,44 @@
+package haloy
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLatencyStats(t *testing.T) {
+	lo, avg, hi := latencyStats([]time.Duration{30 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond})
+	if lo != 10*time.Millisecond || avg != 20*time.Millisecond || hi != 30*time.Millisecond {
+		t.Errorf("latencyStats() = %s, %s, %s, want 10ms, 20ms, 30ms", lo, avg, hi)
+	}
+}
+
+func TestFormatLatency(t *testing.T) {
+	tests := []struct {
+		latency time.Duration
+		want    string
+	}{
+		{1234 * time.Microsecond, "1.23ms"},
+		{42400 * time.Microsecond, "42ms"},
+		{1234 * time.Millisecond, "1.23s"},
+	}
+	for _, tt := range tests {
+		if got := formatLatency(tt.latency); got != tt.want {
+			t.Errorf("formatLatency(%s) = %q, want %q", tt.latency, got, tt.want)
+		}
+	}
+}
+
+func TestPingRowAdd(t *testing.T) {
+	var row pingRow
+	row.add(time.Millisecond, nil)
+	row.add(time.Second, errors.New("connection refused"))
+	row.add(2*time.Millisecond, nil)
+
+	if row.attempts != 3 || len(row.samples) != 2 {
+		t.Errorf("attempts = %d, samples = %v, want 3 attempts and 2 samples", row.attempts, row.samples)
+	}
+	if row.err == nil {
+		t.Error("err = nil, want the failed attempt's error")
+	}
+}
@@ -0,0 +1,275 @@
+package haloy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+// Lifecycle events plugins are run for. A plugin stops the build or deploy
+// of a pre- event by failing or asking to abort; failures at other events
+// are only reported.
+const (
+	pluginEventPreBuild   = "pre-build"
+	pluginEventPostBuild  = "post-build"
+	pluginEventPreDeploy  = "pre-deploy"
+	pluginEventPostDeploy = "post-deploy"
+	pluginEventOnFailure  = "on-failure"
+)
+
+const (
+	// pluginProtocolVersion is sent to plugins so they can reject requests
+	// they don't understand once the contract changes.
+	pluginProtocolVersion = 1
+	pluginTimeout         = 5 * time.Minute
+	// pluginOutputLimit bounds the response read from a plugin's stdout.
+	pluginOutputLimit = 64 * 1024
+)
+
+// pluginRequest is written as JSON to a plugin's stdin.
+type pluginRequest struct {
+	Version int            `json:"version"`
+	Event   string         `json:"event"`
+	Targets []pluginTarget `json:"targets"`
+	// Stage is what failed for on-failure: "build" or "deploy".
+	Stage string `json:"stage,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type pluginTarget struct {
+	Target       string `json:"target"`
+	App          string `json:"app"`
+	Server       string `json:"server"`
+	Image        string `json:"image,omitempty"`
+	DeploymentID string `json:"deploymentId,omitempty"`
+}
+
+// pluginResponse is read as JSON from a plugin's stdout. Plugins with
+// nothing to say may print nothing.
+type pluginResponse struct {
+	Abort   bool   `json:"abort"`
+	Message string `json:"message"`
+}
+
+// pluginSet runs the plugins of a deploy in order.
+type pluginSet struct {
+	paths   []string
+	workDir string
+}
+
+// loadPlugins returns the executables in the haloy config directory's
+// plugins directory, sorted by name, followed by the ones the config
+// declares.
+func loadPlugins(declared []string, configPath string) (*pluginSet, error) {
+	set := &pluginSet{workDir: getHooksWorkDir(configPath)}
+
+	configDir, err := config.HaloyConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	discovered, err := discoverPlugins(filepath.Join(configDir, constants.PluginsDir))
+	if err != nil {
+		return nil, err
+	}
+	set.paths = discovered
+
+	for _, path := range declared {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(set.workDir, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", path, err)
+		}
+		if info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+			return nil, fmt.Errorf("plugin %s isn't an executable file", path)
+		}
+		if !slices.Contains(set.paths, path) {
+			set.paths = append(set.paths, path)
+		}
+	}
+	return set, nil
+}
+
+// discoverPlugins lists the executable files in dir. Hidden files are
+// skipped, so a plugin can be disabled by renaming it.
+func discoverPlugins(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins directory: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// Stat follows symlinks, so linked plugins work.
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// run runs every plugin for req.Event. For pre- events it returns an error
+// from the first plugin that fails or aborts; for other events failures are
+// shown as warnings and run returns nil.
+func (p *pluginSet) run(ctx context.Context, req pluginRequest, prefix string) error {
+	if p == nil || len(p.paths) == 0 {
+		return nil
+	}
+	req.Version = pluginProtocolVersion
+	input, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	abortable := strings.HasPrefix(req.Event, "pre-")
+	for _, path := range p.paths {
+		name := filepath.Base(path)
+		response, err := p.runOne(ctx, path, req.Event, input)
+		if err == nil && response.Abort && abortable {
+			err = errors.New("aborted")
+			if response.Message != "" {
+				err = errors.New(response.Message)
+			}
+		} else if response.Message != "" {
+			pui.Info("%s: %s", name, response.Message)
+		}
+		if err == nil {
+			continue
+		}
+		if abortable {
+			return fmt.Errorf("plugin %s stopped %s: %w", name, req.Event, err)
+		}
+		pui.Warn("Plugin %s failed at %s: %v", name, req.Event, err)
+	}
+	return nil
+}
+
+func (p *pluginSet) runOne(ctx context.Context, path, event string, input []byte) (pluginResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, pluginTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Dir = p.workDir
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &limitedWriter{w: &stdout, n: pluginOutputLimit}
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), constants.EnvVarPluginEvent+"="+event)
+
+	var response pluginResponse
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return response, fmt.Errorf("timed out after %s", pluginTimeout)
+		}
+		// A failing plugin may still explain itself on stdout.
+		json.Unmarshal(stdout.Bytes(), &response)
+		if response.Message != "" {
+			return pluginResponse{}, fmt.Errorf("%w: %s", err, response.Message)
+		}
+		return pluginResponse{}, err
+	}
+
+	out := bytes.TrimSpace(stdout.Bytes())
+	if len(out) == 0 {
+		return response, nil
+	}
+	if err := json.Unmarshal(out, &response); err != nil {
+		return response, fmt.Errorf("invalid response on stdout: %w", err)
+	}
+	return response, nil
+}
+
+// limitedWriter keeps the first n bytes written to it and drops the rest,
+// so a chatty plugin can't exhaust memory.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(b []byte) (int, error) {
+	size := len(b)
+	if l.n <= 0 {
+		return size, nil
+	}
+	if len(b) > l.n {
+		b = b[:l.n]
+	}
+	n, err := l.w.Write(b)
+	l.n -= n
+	if err != nil {
+		return n, err
+	}
+	return size, nil
+}
+
+// failure runs the on-failure plugins for a failed stage.
+func (p *pluginSet) failure(ctx context.Context, stage string, targets []pluginTarget, cause error, prefix string) {
+	// The deploy may have failed because ctx was canceled; the plugins
+	// should still hear about it.
+	ctx = context.WithoutCancel(ctx)
+	p.run(ctx, pluginRequest{Event: pluginEventOnFailure, Stage: stage, Targets: targets, Error: cause.Error()}, prefix)
+}
+
+func newPluginTarget(targetName string, targetConfig config.TargetConfig, deploymentID string) pluginTarget {
+	target := pluginTarget{
+		Target:       targetName,
+		App:          targetConfig.Name,
+		Server:       targetConfig.Server,
+		DeploymentID: deploymentID,
+	}
+	if targetConfig.Image != nil {
+		target.Image = targetConfig.Image.ImageRef()
+	}
+	return target
+}
+
+// buildWithPlugins builds and delivers images like buildAndDeliverImages,
+// running the build plugins around it when a target builds an image.
+func buildWithPlugins(ctx context.Context, plugins *pluginSet, targets map[string]config.TargetConfig, configPath string, deliver bool, limitRate int64) (*ArtifactLock, error) {
+	var built []pluginTarget
+	for _, targetName := range slices.Sorted(maps.Keys(targets)) {
+		targetConfig := targets[targetName]
+		if targetConfig.Image != nil && targetConfig.Image.ShouldBuild() {
+			built = append(built, newPluginTarget(targetName, targetConfig, ""))
+		}
+	}
+	if len(built) == 0 {
+		return buildAndDeliverImages(ctx, targets, configPath, deliver, limitRate)
+	}
+
+	if err := plugins.run(ctx, pluginRequest{Event: pluginEventPreBuild, Targets: built}, ""); err != nil {
+		return nil, err
+	}
+	lock, err := buildAndDeliverImages(ctx, targets, configPath, deliver, limitRate)
+	if err != nil {
+		plugins.failure(ctx, "build", built, err, "")
+		return nil, err
+	}
+	plugins.run(ctx, pluginRequest{Event: pluginEventPostBuild, Targets: built}, "")
+	return lock, nil
+}
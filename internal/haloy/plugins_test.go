@@ -0,0 +1,123 @@
+package haloy
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+func writePlugin(t *testing.T, dir, name, script string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPlugins(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv(constants.EnvVarConfigDir, configDir)
+	pluginsDir := filepath.Join(configDir, constants.PluginsDir)
+	if err := os.Mkdir(pluginsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writePlugin(t, pluginsDir, "20-notify", "", 0o755)
+	writePlugin(t, pluginsDir, "10-audit", "", 0o755)
+	writePlugin(t, pluginsDir, ".disabled", "", 0o755)
+	writePlugin(t, pluginsDir, "README", "", 0o644)
+
+	projectDir := t.TempDir()
+	writePlugin(t, projectDir, "check", "", 0o755)
+	writePlugin(t, projectDir, "notes", "", 0o644)
+
+	set, err := loadPlugins([]string{"check"}, projectDir)
+	if err != nil {
+		t.Fatalf("loadPlugins() error = %v", err)
+	}
+	var names []string
+	for _, path := range set.paths {
+		names = append(names, filepath.Base(path))
+	}
+	if want := []string{"10-audit", "20-notify", "check"}; !slices.Equal(names, want) {
+		t.Errorf("loadPlugins() = %v, want %v", names, want)
+	}
+
+	if _, err := loadPlugins([]string{"notes"}, projectDir); err == nil {
+		t.Error("loadPlugins() accepted a plugin that isn't executable")
+	}
+	if _, err := loadPlugins([]string{"missing"}, projectDir); err == nil {
+		t.Error("loadPlugins() accepted a missing plugin")
+	}
+}
+
+func TestPluginSetRun(t *testing.T) {
+	dir := t.TempDir()
+	requestPath := filepath.Join(dir, "request.json")
+	record := writePlugin(t, dir, "record", `cat > `+requestPath+`
+echo "{\"message\": \"event $HALOY_PLUGIN_EVENT\"}"
+`, 0o755)
+	abort := writePlugin(t, dir, "abort", `echo '{"abort": true, "message": "change freeze"}'`, 0o755)
+	fail := writePlugin(t, dir, "fail", "exit 3", 0o755)
+	garbage := writePlugin(t, dir, "garbage", "echo not json", 0o755)
+
+	ctx := context.Background()
+	req := pluginRequest{
+		Event:   pluginEventPreDeploy,
+		Targets: []pluginTarget{{Target: "web", App: "shop", Server: "haloy.example.com", DeploymentID: "01abc"}},
+	}
+
+	set := &pluginSet{paths: []string{record}, workDir: dir}
+	if err := set.run(ctx, req, ""); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	data, err := os.ReadFile(requestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got pluginRequest
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("plugin got invalid JSON %q: %v", data, err)
+	}
+	if got.Version != pluginProtocolVersion || got.Event != pluginEventPreDeploy || len(got.Targets) != 1 || got.Targets[0].DeploymentID != "01abc" {
+		t.Errorf("plugin got %+v", got)
+	}
+
+	tests := []struct {
+		name    string
+		paths   []string
+		event   string
+		wantErr string
+	}{
+		{name: "abort before deploy", paths: []string{abort, record}, event: pluginEventPreDeploy, wantErr: "change freeze"},
+		{name: "failure before build", paths: []string{fail}, event: pluginEventPreBuild, wantErr: "exit status 3"},
+		{name: "invalid response", paths: []string{garbage}, event: pluginEventPreDeploy, wantErr: "invalid response"},
+		{name: "abort after deploy is ignored", paths: []string{abort}, event: pluginEventPostDeploy},
+		{name: "failure on failure is reported only", paths: []string{fail}, event: pluginEventOnFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(requestPath)
+			set := &pluginSet{paths: tt.paths, workDir: dir}
+			err := set.run(ctx, pluginRequest{Event: tt.event}, "")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("run() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("run() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(requestPath); err == nil {
+				t.Error("run() kept running plugins after one stopped the event")
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+func PreviewCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "preview",
+		Short: "Manage per-branch preview deployments",
+		Long: `Deploy the targets with a preview section once per branch, as separate apps
+named <app>-<branch> at <branch>.<preview domain>. haloyd destroys a preview
+when its TTL runs out after its last deploy.`,
+	}
+
+	cmd.AddCommand(
+		previewCreateCmd(configPath, flags),
+		previewListCmd(configPath, flags),
+		previewDestroyCmd(configPath, flags),
+	)
+
+	return cmd
+}
+
+// previewOptions turn a deploy into the deploy of a branch's preview.
+type previewOptions struct {
+	branch string
+	// ttl overrides the TTL in the preview sections when set.
+	ttl time.Duration
+}
+
+func previewCreateCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var (
+		opts   deployOptions
+		branch string
+		ttl    time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Deploy a preview of a branch",
+		Long: `Build and deploy a preview of a branch, or redeploy it and restart its TTL.
+The branch defaults to the current git branch, or GITHUB_HEAD_REF in GitHub
+Actions pull request workflows.
+
+A preview gets its own named volumes, <volume>-<branch>, and a target whose
+database_url_from names another previewed target uses that target's preview.
+Snapshots aren't taken of previews.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if branch == "" {
+				detected, err := currentBranch(cmd.Context(), *configPath)
+				if err != nil {
+					return err
+				}
+				branch = detected
+			}
+			opts.preview = &previewOptions{branch: branch, ttl: ttl}
+			// Previews are copies, so protected targets can have them too.
+			flags.includeProtected = true
+			return deployApp(cmd.Context(), *configPath, flags, opts)
+		},
+	}
+
+	addPreviewFlags(cmd, flags)
+	cmd.Flags().StringVar(&branch, "branch", "", "Branch to deploy a preview of (default: the current git branch)")
+	cmd.Flags().DurationVar(&ttl, "ttl", 0, "How long the preview lives after this deploy (default: preview.ttl)")
+	cmd.Flags().BoolVar(&opts.noLogs, "no-logs", false, "Don't stream haloyd deployment logs")
+	cmd.Flags().BoolVar(&opts.forceUnlock, "force-unlock", false, "Cancel any deployment of the preview already in progress and take over its lock")
+	return cmd
+}
+
+func previewListCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the previews on the servers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			targets, err := previewTargets(cmd.Context(), *configPath, flags)
+			if err != nil {
+				return err
+			}
+
+			byServer := make(map[string][]config.TargetConfig)
+			for _, target := range targets {
+				byServer[target.Server] = append(byServer[target.Server], target)
+			}
+			servers := slices.Sorted(maps.Keys(byServer))
+
+			results := make([][]apitypes.Preview, len(servers))
+			g, ctx := errgroup.WithContext(cmd.Context())
+			for i, server := range servers {
+				g.Go(func() error {
+					api, err := newTargetAPIClient(byServer[server][0])
+					if err != nil {
+						return &PrefixedError{Err: err, Prefix: server}
+					}
+					var response apitypes.PreviewsResponse
+					if err := api.Get(ctx, "previews", &response); err != nil {
+						if errors.Is(err, apiclient.ErrNotFound) {
+							err = errors.New("the server doesn't support previews, upgrade haloyd to use this command")
+						}
+						return &PrefixedError{Err: fmt.Errorf("failed to list previews: %w", err), Prefix: server}
+					}
+					results[i] = previewsOf(response.Previews, byServer[server])
+					return nil
+				})
+			}
+			if err := g.Wait(); err != nil {
+				return err
+			}
+
+			previews := slices.Concat(results...)
+			if len(previews) == 0 {
+				ui.Info("No previews deployed")
+				return nil
+			}
+			rows := make([][]string, 0, len(previews))
+			for _, preview := range previews {
+				url := "-"
+				if len(preview.Domains) > 0 {
+					url = "https://" + preview.Domains[0]
+				}
+				rows = append(rows, []string{preview.App, preview.Branch, url, helpers.FormatTime(preview.ExpiresAt)})
+			}
+			ui.Table([]string{"APP", "BRANCH", "URL", "EXPIRES"}, rows)
+			return nil
+		},
+	}
+
+	addPreviewFlags(cmd, flags)
+	return cmd
+}
+
+func previewDestroyCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var branch string
+
+	cmd := &cobra.Command{
+		Use:   "destroy",
+		Short: "Destroy the preview of a branch",
+		Long: `Destroy the preview of a branch with its volumes and certificates, before
+its TTL runs out. The branch defaults to the current git branch.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if branch == "" {
+				detected, err := currentBranch(cmd.Context(), *configPath)
+				if err != nil {
+					return err
+				}
+				branch = detected
+			}
+			slug, err := config.PreviewSlug(branch)
+			if err != nil {
+				return err
+			}
+			targets, err := previewTargets(cmd.Context(), *configPath, flags)
+			if err != nil {
+				return err
+			}
+
+			g, ctx := errgroup.WithContext(cmd.Context())
+			for _, target := range targets {
+				g.Go(func() error {
+					prefix := ""
+					if len(targets) > 1 {
+						prefix = target.TargetName
+					}
+					target.Name = previewAppName(target.Name, slug)
+					return destroyApp(ctx, &target, apitypes.DestroyAppRequest{RemoveCertificates: true}, prefix)
+				})
+			}
+			return g.Wait()
+		},
+	}
+
+	addPreviewFlags(cmd, flags)
+	cmd.Flags().StringVar(&branch, "branch", "", "Branch whose preview to destroy (default: the current git branch)")
+	return cmd
+}
+
+func addPreviewFlags(cmd *cobra.Command, flags *appCmdFlags) {
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Use specific targets with a preview section (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Use all targets with a preview section")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+}
+
+// previewTargets returns the selected targets that have a preview section.
+func previewTargets(ctx context.Context, configPath string, flags *appCmdFlags) ([]config.TargetConfig, error) {
+	targets, err := selectedTargets(ctx, configPath, flags, func(target config.TargetConfig) bool {
+		return target.Preview != nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("none of the selected targets has a preview section")
+	}
+	return targets, nil
+}
+
+// previewsOf returns the previews of targets among previews.
+func previewsOf(previews []apitypes.Preview, targets []config.TargetConfig) []apitypes.Preview {
+	var matching []apitypes.Preview
+	for _, preview := range previews {
+		for _, target := range targets {
+			if slug, err := config.PreviewSlug(preview.Branch); err == nil && preview.App == previewAppName(target.Name, slug) {
+				matching = append(matching, preview)
+				break
+			}
+		}
+	}
+	return matching
+}
+
+// currentBranch returns the branch checked out in the repository of the
+// config, or the pull request's branch in GitHub Actions.
+func currentBranch(ctx context.Context, configPath string) (string, error) {
+	if ref := os.Getenv("GITHUB_HEAD_REF"); ref != "" {
+		return ref, nil
+	}
+	configFile, err := configloader.FindConfigFile(configPath)
+	if err != nil {
+		return "", err
+	}
+	branch, err := runCLICommandOutput(ctx, "git", "-C", filepath.Dir(configFile), "rev-parse", "--abbrev-ref", "HEAD")
+	branch = strings.TrimSpace(branch)
+	if err != nil || branch == "" || branch == "HEAD" {
+		return "", errors.New("unable to detect the git branch; set it with --branch")
+	}
+	return branch, nil
+}
+
+func previewAppName(appName, slug string) string {
+	return appName + "-" + slug
+}
+
+// applyPreview turns the targets into the preview of opts.branch, dropping
+// the ones without a preview section. rawTargets and resolvedTargets are
+// changed alike.
+func applyPreview(opts previewOptions, now time.Time, rawTargets, resolvedTargets map[string]config.TargetConfig) error {
+	slug, err := config.PreviewSlug(opts.branch)
+	if err != nil {
+		return err
+	}
+
+	previewed := make(map[string]bool)
+	for targetName, target := range resolvedTargets {
+		if target.Preview == nil {
+			delete(rawTargets, targetName)
+			delete(resolvedTargets, targetName)
+			continue
+		}
+		previewed[target.Name] = true
+	}
+	if len(resolvedTargets) == 0 {
+		return errors.New("none of the selected targets has a preview section")
+	}
+
+	for targetName := range resolvedTargets {
+		rawTargets[targetName] = previewTarget(rawTargets[targetName], opts, slug, now, previewed)
+		resolvedTargets[targetName] = previewTarget(resolvedTargets[targetName], opts, slug, now, previewed)
+	}
+	return nil
+}
+
+// previewTarget returns the copy of target deployed as a preview. Named
+// volumes get the slug appended so previews never share data with the apps
+// they copy, and database_url_from follows the database to its preview when
+// it's previewed too.
+func previewTarget(target config.TargetConfig, opts previewOptions, slug string, now time.Time, previewed map[string]bool) config.TargetConfig {
+	preview := *target.Preview
+	ttl := opts.ttl
+	if ttl <= 0 {
+		ttl = preview.GetTTL()
+	}
+	preview.Branch = opts.branch
+	preview.ExpiresAt = now.Add(ttl).UTC().Truncate(time.Second)
+	target.Preview = &preview
+
+	target.Name = previewAppName(target.Name, slug)
+	if len(target.Domains) > 0 {
+		target.Domains = []config.Domain{{Canonical: slug + "." + preview.Domain}}
+	}
+	if target.DatabaseURLFrom != "" && previewed[target.DatabaseURLFrom] {
+		target.DatabaseURLFrom = previewAppName(target.DatabaseURLFrom, slug)
+	}
+	target.Volumes = previewVolumes(target.Volumes, slug)
+	if len(target.Sidecars) > 0 {
+		sidecars := slices.Clone(target.Sidecars)
+		for i := range sidecars {
+			sidecars[i].Volumes = previewVolumes(sidecars[i].Volumes, slug)
+		}
+		target.Sidecars = sidecars
+	}
+	if target.Image != nil && target.Image.ShouldBuild() {
+		// Keep the preview's build from replacing the image of the app.
+		image := *target.Image
+		image.Tag = "preview-" + slug
+		target.Image = &image
+	}
+	target.Snapshots = nil
+	target.Protected = nil
+	return target
+}
+
+func previewVolumes(volumes []string, slug string) []string {
+	if len(volumes) == 0 {
+		return volumes
+	}
+	renamed := make([]string, len(volumes))
+	for i, spec := range volumes {
+		spec = strings.TrimSpace(spec)
+		renamed[i] = spec
+		if parsed, err := config.ParseVolumeSpec(spec); err == nil && parsed.IsNamedVolume() {
+			renamed[i] = parsed.Source + "-" + slug + strings.TrimPrefix(spec, parsed.Source)
+		}
+	}
+	return renamed
+}
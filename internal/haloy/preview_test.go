@@ -0,0 +1,100 @@
+package haloy
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestApplyPreview(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	preview := &config.PreviewConfig{Domain: "preview.example.com", TTL: "48h"}
+	newTargets := func() map[string]config.TargetConfig {
+		return map[string]config.TargetConfig{
+			"web": {
+				Name:            "shop",
+				Image:           &config.Image{Repository: "shop", BuildConfig: &config.BuildConfig{}},
+				Domains:         []config.Domain{{Canonical: "shop.example.com", Aliases: []string{"www.shop.example.com"}}},
+				Volumes:         []string{"shop-uploads:/app/uploads", "/srv/static:/app/static:ro"},
+				DatabaseURLFrom: "shop-db",
+				Snapshots:       &config.SnapshotsConfig{Schedule: "@daily"},
+				Protected:       new(true),
+				Preview:         preview,
+			},
+			"db": {
+				Name:    "shop-db",
+				Image:   &config.Image{Repository: "postgres", Tag: "17"},
+				Volumes: []string{"shop-db-data:/var/lib/postgresql/data"},
+				Preview: preview,
+			},
+			"worker": {Name: "shop-worker"},
+		}
+	}
+	rawTargets, resolvedTargets := newTargets(), newTargets()
+
+	if err := applyPreview(previewOptions{branch: "Feature/X"}, now, rawTargets, resolvedTargets); err != nil {
+		t.Fatalf("applyPreview() error = %v", err)
+	}
+	if _, ok := resolvedTargets["worker"]; ok {
+		t.Error("target without a preview section should be dropped")
+	}
+	if len(rawTargets) != len(resolvedTargets) {
+		t.Errorf("raw targets = %d, resolved = %d, want the same", len(rawTargets), len(resolvedTargets))
+	}
+
+	web := resolvedTargets["web"]
+	if web.Name != "shop-feature-x" {
+		t.Errorf("Name = %q, want shop-feature-x", web.Name)
+	}
+	if len(web.Domains) != 1 || web.Domains[0].Canonical != "feature-x.preview.example.com" || len(web.Domains[0].Aliases) > 0 {
+		t.Errorf("Domains = %+v, want only feature-x.preview.example.com", web.Domains)
+	}
+	if want := []string{"shop-uploads-feature-x:/app/uploads", "/srv/static:/app/static:ro"}; !slices.Equal(web.Volumes, want) {
+		t.Errorf("Volumes = %v, want %v", web.Volumes, want)
+	}
+	if web.DatabaseURLFrom != "shop-db-feature-x" {
+		t.Errorf("DatabaseURLFrom = %q, want shop-db-feature-x", web.DatabaseURLFrom)
+	}
+	if web.Image.Tag != "preview-feature-x" {
+		t.Errorf("Image.Tag = %q, want preview-feature-x", web.Image.Tag)
+	}
+	if web.Snapshots != nil || web.Protected != nil {
+		t.Error("previews should have no snapshots and not be protected")
+	}
+	if web.Preview.Branch != "Feature/X" || !web.Preview.ExpiresAt.Equal(now.Add(48*time.Hour)) {
+		t.Errorf("Preview = %+v, want branch Feature/X expiring in 48h", web.Preview)
+	}
+	if preview.Branch != "" {
+		t.Error("applyPreview() changed the shared preview section")
+	}
+
+	db := resolvedTargets["db"]
+	if db.Image.Tag != "17" {
+		t.Errorf("prebuilt image tag = %q, want it kept", db.Image.Tag)
+	}
+	if len(db.Domains) != 0 {
+		t.Errorf("target without domains got %+v", db.Domains)
+	}
+}
+
+func TestApplyPreview_TTLOverride(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	targets := func() map[string]config.TargetConfig {
+		return map[string]config.TargetConfig{"web": {Name: "shop", Preview: &config.PreviewConfig{Domain: "preview.example.com"}}}
+	}
+	raw, resolved := targets(), targets()
+	if err := applyPreview(previewOptions{branch: "fix", ttl: 2 * time.Hour}, now, raw, resolved); err != nil {
+		t.Fatalf("applyPreview() error = %v", err)
+	}
+	if got := resolved["web"].Preview.ExpiresAt; !got.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("ExpiresAt = %v, want %v", got, now.Add(2*time.Hour))
+	}
+
+	raw = map[string]config.TargetConfig{"web": {Name: "shop"}}
+	resolved = map[string]config.TargetConfig{"web": {Name: "shop"}}
+	if err := applyPreview(previewOptions{branch: "fix"}, now, raw, resolved); err == nil {
+		t.Error("applyPreview() without preview sections should fail")
+	}
+}
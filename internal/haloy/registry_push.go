@@ -0,0 +1,230 @@
+package haloy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+// A push failing on the network or on an overloaded registry is tried
+// pushAttempts times in all, waiting pushRetryDelay before the first retry
+// and twice as long before each next one. Variables so tests can change them.
+var (
+	pushAttempts   = 3
+	pushRetryDelay = 2 * time.Second
+)
+
+// dockerPush runs 'docker push imageRef' with its output written to stdout,
+// and returns what it wrote to stderr. A variable so tests can replace it.
+var dockerPush = func(ctx context.Context, imageRef string, stdout io.Writer) (string, error) {
+	cmd := exec.CommandContext(ctx, "docker", "push", imageRef)
+	var stderr strings.Builder
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return strings.TrimSpace(stderr.String()), err
+}
+
+// pushImageToRegistry pushes an image to a container registry using shell commands.
+// This avoids importing the Docker client library which adds significant binary bloat.
+// It returns the repo digest the registry stored the image under, so the
+// deployment pulls exactly what was pushed even if the tag moves meanwhile.
+func pushImageToRegistry(ctx context.Context, imageRef string, image *config.Image) (string, error) {
+	if image.RegistryAuth == nil {
+		return "", fmt.Errorf("no registry authentication configured for image %s", imageRef)
+	}
+
+	server := image.GetRegistryServer()
+
+	// Check for empty credentials before attempting login
+	if image.RegistryAuth.Password.Value == "" {
+		return "", fmt.Errorf("registry password is empty for image %s - check that the environment variable is set and the .env file is being loaded", imageRef)
+	}
+
+	loginCmd := exec.CommandContext(ctx, "docker", "login", server, "-u", image.RegistryAuth.Username.Value, "-p", image.RegistryAuth.Password.Value)
+	if output, err := loginCmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("docker login to %s failed: %w\n%s", server, err, string(output))
+	}
+
+	return pushWithRetries(ctx, imageRef)
+}
+
+// pushWithRetries pushes imageRef, retrying pushes that failed on the
+// network or on the registry being overloaded, and returns its repo digest.
+func pushWithRetries(ctx context.Context, imageRef string) (string, error) {
+	layers := countImageLayers(ctx, imageRef)
+	delay := pushRetryDelay
+	for attempt := 1; ; attempt++ {
+		output := &pushOutput{}
+		if layers > 0 {
+			output.progress = ui.NewProgressBar(ui.ProgressBarConfig{
+				Description: "Pushing layers",
+				TotalItems:  layers,
+			})
+		}
+		stderr, err := dockerPush(ctx, imageRef, output)
+		if output.progress != nil {
+			output.progress.Finish()
+		}
+		if err == nil {
+			ui.Success("%s", output.summary())
+			if output.digest != "" {
+				return repositoryOf(imageRef) + "@" + output.digest, nil
+			}
+			// Older Docker versions don't print the digest; the push recorded it
+			// on the local image.
+			_, repoDigests, err := inspectLocalImage(ctx, imageRef)
+			if err != nil {
+				return "", err
+			}
+			return matchRepoDigest(imageRef, repoDigests), nil
+		}
+
+		if attempt >= pushAttempts || ctx.Err() != nil || !transientPushError(stderr) {
+			return "", pushError(imageRef, stderr)
+		}
+		ui.Warn("Push of %s failed, retrying in %s (attempt %d of %d): %s", imageRef, delay, attempt+1, pushAttempts, stderr)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// countImageLayers returns the number of layers of the local image imageRef,
+// or 0 if it can't tell.
+func countImageLayers(ctx context.Context, imageRef string) int {
+	output, err := runCLICommandOutput(ctx, "docker", "image", "inspect", "--format", "{{len .RootFS.Layers}}", imageRef)
+	if err != nil {
+		return 0
+	}
+	layers, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		return 0
+	}
+	return layers
+}
+
+// pushOutput follows the output of 'docker push', counting the layers that
+// are done by how they got to the registry and picking up the digest it
+// stored the image under.
+type pushOutput struct {
+	progress *ui.ProgressBar // nil when not shown
+	partial  []byte
+	cached   int
+	pushed   int
+	mounted  int
+	digest   string
+}
+
+func (o *pushOutput) Write(p []byte) (int, error) {
+	o.partial = append(o.partial, p...)
+	for {
+		i := bytes.IndexByte(o.partial, '\n')
+		if i < 0 {
+			break
+		}
+		o.line(string(o.partial[:i]))
+		o.partial = o.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// line handles one line of output, like "5f70bf18a086: Pushed" for a layer
+// or "v1: digest: sha256:... size: 1570" once the image is pushed.
+func (o *pushOutput) line(line string) {
+	_, status, ok := strings.Cut(strings.TrimSpace(line), ": ")
+	if !ok {
+		return
+	}
+	switch {
+	case status == "Pushed":
+		o.pushed++
+	case status == "Layer already exists":
+		o.cached++
+	case strings.HasPrefix(status, "Mounted from"):
+		o.mounted++
+	case strings.HasPrefix(status, "digest: sha256:"):
+		o.digest, _, _ = strings.Cut(strings.TrimPrefix(status, "digest: "), " ")
+		return
+	default:
+		return
+	}
+	if o.progress != nil {
+		o.progress.CompleteItem()
+	}
+}
+
+func (o *pushOutput) summary() string {
+	var parts []string
+	if o.cached > 0 {
+		parts = append(parts, fmt.Sprintf("%d cached", o.cached))
+	}
+	if o.pushed > 0 {
+		parts = append(parts, fmt.Sprintf("%d pushed", o.pushed))
+	}
+	if o.mounted > 0 {
+		parts = append(parts, fmt.Sprintf("%d mounted", o.mounted))
+	}
+	if len(parts) == 0 {
+		return "Pushed image"
+	}
+	return fmt.Sprintf("Pushed image (%s)", strings.Join(parts, ", "))
+}
+
+// transientPushError reports whether a push that failed with stderr may
+// succeed when tried again.
+func transientPushError(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	for _, marker := range []string{"denied", "unauthorized", "not found"} {
+		if strings.Contains(stderr, marker) {
+			return false
+		}
+	}
+	for _, marker := range []string{
+		"connection reset",
+		"connection refused",
+		"broken pipe",
+		"unexpected eof",
+		"i/o timeout",
+		"tls handshake timeout",
+		"timeout exceeded",
+		"toomanyrequests",
+		"429 too many requests",
+		"502 bad gateway",
+		"503 service unavailable",
+		"504 gateway timeout",
+	} {
+		if strings.Contains(stderr, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// pushError explains a failed push, with a hint for the usual causes.
+func pushError(imageRef, stderr string) error {
+	errMsg := fmt.Sprintf("failed to push image %s", imageRef)
+	if stderr != "" {
+		errMsg = fmt.Sprintf("failed to push image %s: %s", imageRef, stderr)
+	}
+
+	stderrLower := strings.ToLower(stderr)
+	if strings.Contains(stderrLower, "denied") || strings.Contains(stderrLower, "unauthorized") {
+		errMsg += "\n\nHint: This usually means your registry credentials are incorrect or expired.\nCheck your registryAuth username and password configuration."
+	} else if strings.Contains(stderrLower, "not found") {
+		errMsg += "\n\nHint: The repository may not exist. Ensure the image name is correct and the repository exists on the registry."
+	}
+
+	return fmt.Errorf("%s", errMsg)
+}
@@ -0,0 +1,134 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestPushOutput(t *testing.T) {
+	output := &pushOutput{}
+	lines := "The push refers to repository [ghcr.io/acme/web]\n" +
+		"5f70bf18a086: Preparing\n" +
+		"5f70bf18a086: Layer already exists\n" +
+		"a1b2c3d4e5f6: Pushed\n" +
+		"0badc0ffee00: Mounted from acme/api\n" +
+		"v1: digest: sha256:abc123 size: 1570"
+	// Split a line across writes, as pipes do.
+	io.WriteString(output, lines[:60])
+	io.WriteString(output, lines[60:]+"\n")
+
+	if output.cached != 1 || output.pushed != 1 || output.mounted != 1 {
+		t.Errorf("cached, pushed, mounted = %d, %d, %d, want 1, 1, 1", output.cached, output.pushed, output.mounted)
+	}
+	if output.digest != "sha256:abc123" {
+		t.Errorf("digest = %q, want sha256:abc123", output.digest)
+	}
+	if got, want := output.summary(), "Pushed image (1 cached, 1 pushed, 1 mounted)"; got != want {
+		t.Errorf("summary() = %q, want %q", got, want)
+	}
+}
+
+func TestTransientPushError(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   bool
+	}{
+		{"write tcp 10.0.0.2:51234->140.82.112.33:443: write: connection reset by peer", true},
+		{"received unexpected HTTP status: 503 Service Unavailable", true},
+		{"toomanyrequests: retry-after: 1.2s", true},
+		{"denied: permission_denied: write_package", false},
+		{"unauthorized: authentication required", false},
+		{"name unknown: repository not found", false},
+		{"invalid reference format", false},
+	}
+	for _, tt := range tests {
+		if got := transientPushError(tt.stderr); got != tt.want {
+			t.Errorf("transientPushError(%q) = %v, want %v", tt.stderr, got, tt.want)
+		}
+	}
+}
+
+func stubDockerPush(t *testing.T, push func(ctx context.Context, imageRef string, stdout io.Writer) (string, error)) {
+	t.Helper()
+	previousPush, previousDelay := dockerPush, pushRetryDelay
+	previousOutput := runCLICommandOutput
+	t.Cleanup(func() {
+		dockerPush, pushRetryDelay = previousPush, previousDelay
+		runCLICommandOutput = previousOutput
+	})
+	dockerPush = push
+	pushRetryDelay = 0
+	runCLICommandOutput = func(context.Context, string, ...string) (string, error) {
+		return "", errors.New("no docker")
+	}
+}
+
+func TestPushWithRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures []string
+		wantErr  bool
+		wantRuns int
+	}{
+		{"first attempt", nil, false, 1},
+		{"transient failures", []string{"connection reset by peer", "503 Service Unavailable"}, false, 3},
+		{"out of attempts", []string{"i/o timeout", "i/o timeout", "i/o timeout"}, true, 3},
+		{"permanent failure", []string{"denied: requested access to the resource is denied"}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+			stubDockerPush(t, func(_ context.Context, _ string, stdout io.Writer) (string, error) {
+				runs++
+				if runs <= len(tt.failures) {
+					return tt.failures[runs-1], errors.New("exit status 1")
+				}
+				io.WriteString(stdout, "v1: digest: sha256:abc123 size: 1570\n")
+				return "", nil
+			})
+
+			digest, err := pushWithRetries(context.Background(), "ghcr.io/acme/web:v1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pushWithRetries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if runs != tt.wantRuns {
+				t.Errorf("docker push ran %d times, want %d", runs, tt.wantRuns)
+			}
+			if !tt.wantErr && digest != "ghcr.io/acme/web@sha256:abc123" {
+				t.Errorf("digest = %q, want ghcr.io/acme/web@sha256:abc123", digest)
+			}
+		})
+	}
+}
+
+func TestPinPushedImages(t *testing.T) {
+	pushed := buildTarget("prod.example.com", config.BuildPushOptionRegistry)
+	pushed.Image.RegistryAuth = &config.RegistryAuth{}
+	unauthenticated := buildTarget("prod.example.com", config.BuildPushOptionRegistry)
+	resolved := map[string]config.TargetConfig{
+		"pushed":          pushed,
+		"unauthenticated": unauthenticated,
+		"uploaded":        buildTarget("prod.example.com", config.BuildPushOptionServer),
+	}
+	lock := &ArtifactLock{Version: artifactLockVersion, Targets: map[string]ArtifactTarget{
+		"pushed":          {RepoDigest: "ghcr.io/acme/web@sha256:def", Delivery: artifactDeliveryRegistry},
+		"unauthenticated": {RepoDigest: "ghcr.io/acme/web@sha256:old", Delivery: artifactDeliveryRegistry},
+		"uploaded":        {ImageID: "sha256:abc", Delivery: artifactDeliveryServer},
+	}}
+
+	pinPushedImages(lock, map[string]config.TargetConfig{}, resolved)
+
+	if got := resolved["pushed"].Image.ImageRef(); got != "ghcr.io/acme/web@sha256:def" {
+		t.Errorf("pushed image ref = %q, want repo digest", got)
+	}
+	if got := resolved["unauthenticated"].Image.ImageRef(); got != "ghcr.io/acme/web:latest" {
+		t.Errorf("image not pushed by haloy = %q, want unchanged", got)
+	}
+	if got := resolved["uploaded"].Image.ImageRef(); got != "ghcr.io/acme/web:latest" {
+		t.Errorf("uploaded image ref = %q, want unchanged", got)
+	}
+}
@@ -0,0 +1,220 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// restoreNamePattern matches the names of database backups and volume
+// snapshots, capturing the app they were taken of and the kind.
+var restoreNamePattern = regexp.MustCompile(`^(.+)-\d{8}T\d{6}Z\.(dump|tar\.gz)$`)
+
+func RestoreCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var (
+		toNewTarget   string
+		anonymizePath string
+		yesFlag       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "restore <backup|snapshot> --to-new-target <target>",
+		Short: "Clone a backup or snapshot into another target",
+		Long: `Restore a database backup ('haloy db list') or volume snapshot ('haloy
+backup list') of one target into another, like a staging copy of production.
+
+A volume snapshot is restored into new volumes of the target, matched to the
+source's volumes by mount path; deploy the target afterwards to start it on
+them. A database backup is restored into the target's managed database, which
+must be deployed first. --anonymize runs a SQL file against the copy in the
+same operation, before anything can connect to it with the production data.
+
+To restore a database backup into its own database, use 'haloy db restore'.`,
+		Example: `  haloy restore shop-db-20260101T030000Z.dump --to-new-target staging-db --anonymize scrub.sql
+  haloy restore shop-20260101T030000Z.tar.gz --to-new-target staging`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			m := restoreNamePattern.FindStringSubmatch(name)
+			if m == nil {
+				return fmt.Errorf("'%s' is neither a database backup nor a volume snapshot", name)
+			}
+			sourceApp, isDatabase := m[1], m[2] == "dump"
+
+			targets, err := selectedTargets(cmd.Context(), *configPath, &appCmdFlags{all: true}, func(config.TargetConfig) bool { return true })
+			if err != nil {
+				return err
+			}
+			source, dest, err := restoreTargets(targets, sourceApp, toNewTarget)
+			if err != nil {
+				return err
+			}
+
+			var anonymize string
+			if anonymizePath != "" {
+				if !isDatabase {
+					return errors.New("--anonymize runs SQL and only applies to database backups")
+				}
+				content, err := os.ReadFile(anonymizePath)
+				if err != nil {
+					return fmt.Errorf("failed to read anonymization script: %w", err)
+				}
+				anonymize = string(content)
+			}
+
+			if isDatabase {
+				return cloneDatabase(cmd.Context(), source, dest, name, anonymize, yesFlag)
+			}
+			return restoreSnapshotToTarget(cmd.Context(), source, dest, name)
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringVar(&toNewTarget, "to-new-target", "", "Target to restore into")
+	cmd.Flags().StringVar(&anonymizePath, "anonymize", "", "SQL file to run against a cloned database")
+	cmd.Flags().BoolVarP(&yesFlag, "yes", "y", false, "Skip the confirmation")
+	cmd.MarkFlagRequired("to-new-target")
+
+	cmd.RegisterFlagCompletionFunc("to-new-target", completeTargetNames)
+	return cmd
+}
+
+// restoreTargets returns the target of the app sourceApp and the target
+// named dest, which must be a different app.
+func restoreTargets(targets []config.TargetConfig, sourceApp, dest string) (source, target config.TargetConfig, err error) {
+	var sources, dests []config.TargetConfig
+	for _, t := range targets {
+		if t.Name == sourceApp {
+			sources = append(sources, t)
+		}
+		if t.TargetName == dest || t.Name == dest {
+			dests = append(dests, t)
+		}
+	}
+	if len(sources) == 0 {
+		return source, target, fmt.Errorf("no target in the config deploys the app '%s'", sourceApp)
+	}
+	switch len(dests) {
+	case 0:
+		return source, target, fmt.Errorf("target '%s' not found in configuration", dest)
+	case 1:
+	default:
+		return source, target, fmt.Errorf("'%s' matches %d targets; name one of them, e.g. %s", dest, len(dests), dests[0].TargetName)
+	}
+	if dests[0].Name == sourceApp {
+		return source, target, fmt.Errorf("target '%s' is the app the backup was taken of; use 'haloy db restore' to restore it in place", dest)
+	}
+	return sources[0], dests[0], nil
+}
+
+func cloneDatabase(ctx context.Context, source, dest config.TargetConfig, backup, anonymize string, yes bool) error {
+	if dest.Database == nil {
+		return fmt.Errorf("target '%s' has no database section to restore %s into", dest.TargetName, backup)
+	}
+	if !yes {
+		answer, err := ui.Prompt(fmt.Sprintf("Replace the data of %s on %s with %s? [y/N]", dest.Name, dest.Server, backup))
+		if err != nil {
+			return err
+		}
+		if !slices.Contains([]string{"y", "yes"}, strings.ToLower(answer)) {
+			return fmt.Errorf("restore of %s cancelled", dest.Name)
+		}
+	}
+
+	api, err := newTargetAPIClient(dest)
+	if err != nil {
+		return err
+	}
+	ui.Info("Cloning %s from %s into %s", source.Name, backup, dest.Name)
+	request := apitypes.DatabaseRestoreRequest{
+		OperationID: createDeploymentID(),
+		Backup:      backup,
+		SourceApp:   source.Name,
+		Anonymize:   anonymize,
+	}
+	return runServerOperation(ctx, api, fmt.Sprintf("db/%s/restore", dest.Name), request.OperationID, request, "", explainDBError)
+}
+
+func restoreSnapshotToTarget(ctx context.Context, source, dest config.TargetConfig, snapshot string) error {
+	volumes, skipped, err := snapshotVolumeMapping(source, dest)
+	if err != nil {
+		return err
+	}
+	for _, name := range skipped {
+		ui.Warn("Skipping volume %s, %s mounts nothing at its path", name, dest.TargetName)
+	}
+
+	var remote string
+	if source.Snapshots != nil {
+		remote = source.Snapshots.Remote
+	}
+	api, err := newTargetAPIClient(dest)
+	if err != nil {
+		return err
+	}
+	ui.Info("Restoring %s into %s", snapshot, dest.Name)
+	request := apitypes.VolumeSnapshotRestoreRequest{
+		OperationID: createDeploymentID(),
+		SourceApp:   source.Name,
+		Snapshot:    snapshot,
+		Remote:      remote,
+		Volumes:     volumes,
+	}
+	if dest.Image != nil {
+		request.Image = *dest.Image
+	}
+	if err := runServerOperation(ctx, api, fmt.Sprintf("snapshots/%s/restore", dest.Name), request.OperationID, request, "", explainSnapshotError); err != nil {
+		return err
+	}
+	ui.Info("Deploy %s to start it on the restored volumes", dest.TargetName)
+	return nil
+}
+
+// snapshotVolumeMapping maps the named volumes of source to the ones dest
+// mounts at the same paths. skipped lists the source volumes dest has no
+// volume for.
+func snapshotVolumeMapping(source, dest config.TargetConfig) (volumes map[string]string, skipped []string, err error) {
+	destByPath := make(map[string]string)
+	for _, spec := range dest.Volumes {
+		parsed, err := config.ParseVolumeSpec(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		if parsed.IsNamedVolume() {
+			destByPath[parsed.Target] = parsed.Source
+		}
+	}
+
+	volumes = make(map[string]string)
+	for _, spec := range source.Volumes {
+		parsed, err := config.ParseVolumeSpec(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !parsed.IsNamedVolume() {
+			continue
+		}
+		target, ok := destByPath[parsed.Target]
+		if !ok {
+			skipped = append(skipped, parsed.Source)
+			continue
+		}
+		if target == parsed.Source {
+			return nil, nil, fmt.Errorf("%s uses the volume %s of %s; give it a volume of its own", dest.TargetName, target, source.Name)
+		}
+		volumes[parsed.Source] = target
+	}
+	if len(volumes) == 0 {
+		return nil, nil, fmt.Errorf("%s has no named volumes at the paths of %s's", dest.TargetName, source.Name)
+	}
+	return volumes, skipped, nil
+}
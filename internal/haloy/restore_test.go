@@ -0,0 +1,92 @@
+package haloy
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestSnapshotVolumeMapping(t *testing.T) {
+	source := config.TargetConfig{Name: "shop", Volumes: []string{
+		"shop-uploads:/app/uploads",
+		"shop-cache:/app/cache",
+		"/srv/shop:/app/static",
+	}}
+
+	tests := []struct {
+		name        string
+		destVolumes []string
+		want        map[string]string
+		wantSkipped []string
+		wantErr     string
+	}{
+		{
+			name:        "matched by path",
+			destVolumes: []string{"staging-uploads:/app/uploads", "staging-cache:/app/cache"},
+			want:        map[string]string{"shop-uploads": "staging-uploads", "shop-cache": "staging-cache"},
+		},
+		{
+			name:        "unmatched volume skipped",
+			destVolumes: []string{"staging-uploads:/app/uploads"},
+			want:        map[string]string{"shop-uploads": "staging-uploads"},
+			wantSkipped: []string{"shop-cache"},
+		},
+		{
+			name:        "shared volume rejected",
+			destVolumes: []string{"shop-uploads:/app/uploads"},
+			wantErr:     "volume of its own",
+		},
+		{
+			name:        "nothing to restore",
+			destVolumes: []string{"staging-data:/data"},
+			wantErr:     "no named volumes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := config.TargetConfig{Name: "shop-staging", TargetName: "staging", Volumes: tt.destVolumes}
+			got, skipped, err := snapshotVolumeMapping(source, dest)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("snapshotVolumeMapping() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("snapshotVolumeMapping() error = %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("volumes = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(skipped, tt.wantSkipped) {
+				t.Errorf("skipped = %v, want %v", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestRestoreTargets(t *testing.T) {
+	targets := []config.TargetConfig{
+		{Name: "shop-db", TargetName: "db"},
+		{Name: "shop-db-staging", TargetName: "staging-db"},
+	}
+
+	source, dest, err := restoreTargets(targets, "shop-db", "staging-db")
+	if err != nil {
+		t.Fatalf("restoreTargets() error = %v", err)
+	}
+	if source.TargetName != "db" || dest.TargetName != "staging-db" {
+		t.Errorf("restoreTargets() = %s, %s, want db, staging-db", source.TargetName, dest.TargetName)
+	}
+
+	if _, _, err := restoreTargets(targets, "shop-db", "db"); err == nil {
+		t.Error("restoreTargets() into the source app should fail")
+	}
+	if _, _, err := restoreTargets(targets, "shop", "staging-db"); err == nil {
+		t.Error("restoreTargets() of an unknown app should fail")
+	}
+}
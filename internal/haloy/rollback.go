@@ -20,6 +20,7 @@ import (
 
 func RollbackAppCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
 	var noLogsFlag bool
+	var forceUnlock bool
 
 	cmd := &cobra.Command{
 		Use:   "rollback <deployment-id>",
@@ -111,12 +112,14 @@ Use 'haloy rollback-targets' to list available deployment IDs.`,
 							TargetDeploymentID: targetDeploymentID,
 							NewDeploymentID:    newDeploymentID,
 							NewTargetConfig:    newResolvedTargetConfig,
+							Holder:             deployLockHolder(),
+							ForceUnlock:        forceUnlock,
 						}
 
 						ui.Info("Starting rollback for application: %s using server %s", targetConfig.Name, server)
 
-						if err := api.Post(ctx, "rollback", request, nil); err != nil {
-							return &PrefixedError{Err: fmt.Errorf("rollback failed: %w", err), Prefix: prefix}
+						if err := api.PostIdempotent(ctx, "rollback", newDeploymentID, request, nil); err != nil {
+							return &PrefixedError{Err: fmt.Errorf("rollback failed: %w", explainDeployLockError(err)), Prefix: prefix}
 						}
 
 						if !noLogsFlag {
@@ -135,7 +138,7 @@ Use 'haloy rollback-targets' to list available deployment IDs.`,
 								return logEntry.IsDeploymentComplete
 							}
 
-							api.Stream(ctx, streamPath, streamHandler)
+							api.StreamResumable(ctx, streamPath, streamHandler)
 						}
 
 					}
@@ -152,6 +155,7 @@ Use 'haloy rollback-targets' to list available deployment IDs.`,
 	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Deploy to specific targets (comma-separated)")
 	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Deploy to all targets")
 	cmd.Flags().BoolVar(&noLogsFlag, "no-logs", false, "Don't stream deployment logs")
+	cmd.Flags().BoolVar(&forceUnlock, "force-unlock", false, "Cancel any deployment of the app already in progress and take over its lock")
 
 	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
 
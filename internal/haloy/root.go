@@ -1,11 +1,13 @@
 package haloy
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
 
 	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
 	"github.com/haloydev/haloy/internal/ui"
 	"github.com/spf13/cobra"
 )
@@ -33,11 +35,18 @@ func isDirectSubcommand(cmd *cobra.Command) bool {
 func NewRootCmd() *cobra.Command {
 	appFlags := &appCmdFlags{}
 	resolvedConfigPath := "."
+	var nonInteractive bool
 
 	cmd := &cobra.Command{
 		Use:   "haloy",
 		Short: "haloy builds and runs Docker containers based on a YAML config",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("non-interactive") {
+				ui.SetNonInteractive(nonInteractive)
+			} else {
+				ui.SetNonInteractive(ui.DetectCI())
+			}
+
 			// Skip commands that don't need any config or validation
 			if isDirectSubcommand(cmd) && (cmd.Name() == "completion" || cmd.Name() == "version" || cmd.Name() == "__progress-demo") {
 				return nil
@@ -46,8 +55,12 @@ func NewRootCmd() *cobra.Command {
 			// Load environment files from default locations
 			config.LoadHaloyEnvFiles()
 
-			// Skip server subcommands that don't use deploy config (add, delete, list)
-			if cmd.Parent() != nil && cmd.Parent().Name() == "server" && cmd.Name() != "version" && cmd.Name() != "logs" {
+			// Skip server subcommands that don't use deploy config (add, delete, list, use)
+			if cmd.Parent() != nil && cmd.Parent().Name() == "server" && cmd.Name() != "version" && cmd.Name() != "logs" && cmd.Name() != "df" {
+				return nil
+			}
+
+			if cmd.Parent() != nil && (cmd.Parent().Name() == "context" || cmd.Parent().Name() == "auth") {
 				return nil
 			}
 
@@ -74,27 +87,53 @@ func NewRootCmd() *cobra.Command {
 		SilenceUsage:  true,
 	}
 
+	cmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "Never prompt and print plain output (default true when a CI environment is detected)")
+
 	validateCmd := ValidateDeployConfigCmd(&resolvedConfigPath)
 	validateCmd.Flags().StringVarP(&appFlags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
 
 	cmd.AddCommand(
 		DeployAppCmd(&resolvedConfigPath, appFlags),
+		BuildCmd(&resolvedConfigPath, appFlags),
 		PruneImagesCmd(&resolvedConfigPath, appFlags),
 		RollbackTargetsCmd(&resolvedConfigPath, appFlags),
 		RollbackAppCmd(&resolvedConfigPath, appFlags),
 		LogsCmd(&resolvedConfigPath, appFlags),
 		StatusAppCmd(&resolvedConfigPath, appFlags),
+		DiffCmd(&resolvedConfigPath, appFlags),
+		PingCmd(&resolvedConfigPath, appFlags),
+		UptimeCmd(&resolvedConfigPath, appFlags),
 		StopAppCmd(&resolvedConfigPath, appFlags),
+		StartAppCmd(&resolvedConfigPath, appFlags),
+		DestroyAppCmd(&resolvedConfigPath, appFlags),
+		CancelCmd(&resolvedConfigPath, appFlags),
 		ExecCmd(&resolvedConfigPath, appFlags),
+		CpCmd(&resolvedConfigPath, appFlags),
+		TopCmd(&resolvedConfigPath, appFlags),
+		BundleCmd(&resolvedConfigPath, appFlags),
 		TargetsCmd(&resolvedConfigPath, appFlags),
 		TunnelCmd(&resolvedConfigPath, appFlags),
 		ServerCmd(&resolvedConfigPath, appFlags),
+		SecretCmd(&resolvedConfigPath, appFlags),
+		ConfigCmd(&resolvedConfigPath, appFlags),
+		CertsCmd(&resolvedConfigPath, appFlags),
+		RoutesCmd(&resolvedConfigPath, appFlags),
+		MigrationLockCmd(&resolvedConfigPath, appFlags),
+		DBCmd(&resolvedConfigPath, appFlags),
+		BackupCmd(&resolvedConfigPath, appFlags),
+		RestoreCmd(&resolvedConfigPath, appFlags),
+		PreviewCmd(&resolvedConfigPath, appFlags),
+		GitOpsCmd(&resolvedConfigPath, appFlags),
+		WhoAmICmd(&resolvedConfigPath, appFlags),
+		ContextCmd(),
+		AuthCmd(),
 
 		validateCmd,
 
 		CompletionCmd(),
 		ProgressDemoCmd(),
 		VersionCmd(),
+		ExitCodesHelpCmd(),
 	)
 
 	return cmd
@@ -102,7 +141,8 @@ func NewRootCmd() *cobra.Command {
 
 func Execute() int {
 	rootCmd := NewRootCmd()
-	if err := rootCmd.Execute(); err != nil {
+	// Secrets from op:// and bw:// references are fetched once per command.
+	if err := rootCmd.ExecuteContext(configloader.WithSecretCache(context.Background())); err != nil {
 		var prefixedErr *PrefixedError
 		if errors.As(err, &prefixedErr) {
 			pui := &ui.PrefixedUI{Prefix: prefixedErr.GetPrefix()}
@@ -110,7 +150,7 @@ func Execute() int {
 		} else {
 			ui.Error("%v", err)
 		}
-		return 1
+		return exitCodeFor(err)
 	}
-	return 0
+	return ExitOK
 }
@@ -0,0 +1,242 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+func RoutesCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "routes",
+		Short: "Inspect the proxy's routing table on the server",
+	}
+
+	cmd.AddCommand(RoutesListCmd(configPath, flags))
+	cmd.AddCommand(RoutesDiffCmd(configPath, flags))
+
+	return cmd
+}
+
+func RoutesListCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the routes the proxy is serving",
+		Long: `List the routes haloy-proxy is serving: each domain with its aliases,
+the app it routes to and the app's backends. The health column shows
+whether all of the app's containers receive traffic ("healthy"), some
+are unhealthy or ejected ("degraded"), or none are left and
+the proxy answers 502 ("down"). Domains missing from the list get a 404.
+
+The backends table shows each backend's requests in flight and, over its
+recent requests, its error rate and p95 latency. Backends over the
+passive health thresholds are "flagged", and "ejected" while they are
+taken out of rotation.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return forEachRoutesServer(cmd, *configPath, flags, serverFlag, "proxy/routes", printProxyRoutes)
+		},
+	}
+
+	addRoutesFlags(cmd, flags, &serverFlag)
+	return cmd
+}
+
+func RoutesDiffCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show how the proxy's routes differ from the deployment state",
+		Long: `Show the routes that would change if the server pushed the routes for
+its current deployments to the proxy now. Nothing is changed. An empty
+diff means the proxy routes exactly what is deployed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return forEachRoutesServer(cmd, *configPath, flags, serverFlag, "proxy/routes/dry-run", printProxyRoutesDiff)
+		},
+	}
+
+	addRoutesFlags(cmd, flags, &serverFlag)
+	return cmd
+}
+
+func addRoutesFlags(cmd *cobra.Command, flags *appCmdFlags, serverFlag *string) {
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringVarP(serverFlag, "server", "s", "", "Server URL or profile name (overrides config file)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Show routes for specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Show routes for all targets")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+}
+
+// forEachRoutesServer fetches path from each target server and prints the
+// responses in server order once all of them arrived.
+func forEachRoutesServer[T any](cmd *cobra.Command, configPath string, flags *appCmdFlags, serverFlag, path string, print func(*T, string)) error {
+	ctx := cmd.Context()
+	if serverFlag != "" {
+		resp, err := getProxyRoutes[T](ctx, nil, resolveServerRef(serverFlag), "", path)
+		if err != nil {
+			return err
+		}
+		print(resp, "")
+		return nil
+	}
+
+	servers, err := resolveServerTargets(ctx, cmd, configPath, flags)
+	if err != nil {
+		return err
+	}
+
+	responses := make([]*T, len(servers))
+	g, ctx := errgroup.WithContext(ctx)
+	for i, serverTarget := range servers {
+		g.Go(func() error {
+			prefix := ""
+			if len(servers) > 1 {
+				prefix = serverTarget.Server
+			}
+			resp, err := getProxyRoutes[T](ctx, serverTarget.TargetConfig, serverTarget.Server, prefix, path)
+			if err != nil {
+				return err
+			}
+			responses[i] = resp
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	for i, resp := range responses {
+		prefix := ""
+		if len(servers) > 1 {
+			prefix = servers[i].Server
+		}
+		print(resp, prefix)
+	}
+	return nil
+}
+
+func getProxyRoutes[T any](ctx context.Context, targetConfig *config.TargetConfig, targetServer, prefix, path string) (*T, error) {
+	token, err := getToken(targetConfig, targetServer)
+	if err != nil {
+		return nil, &PrefixedError{Err: fmt.Errorf("unable to get token: %w", err), Prefix: prefix}
+	}
+
+	api, err := apiclient.New(targetServer, token)
+	if err != nil {
+		return nil, &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
+	}
+
+	var response T
+	if err := api.Get(ctx, path, &response); err != nil {
+		if errors.Is(err, apiclient.ErrNotFound) {
+			err = errors.New("the server doesn't report proxy routes, upgrade haloyd to use this command")
+		}
+		return nil, &PrefixedError{Err: fmt.Errorf("failed to get routes from API: %w", err), Prefix: prefix}
+	}
+	return &response, nil
+}
+
+func printProxyRoutes(resp *apitypes.ProxyRoutesResponse, prefix string) {
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	if len(resp.Routes) == 0 {
+		pui.Info("No routes")
+		return
+	}
+
+	rows := make([][]string, 0, len(resp.Routes))
+	for _, r := range resp.Routes {
+		rows = append(rows, []string{routeName(r), joinOrDash(r.Aliases), valueOrDash(r.App), joinOrDash(r.Backends), r.Health})
+	}
+	ui.Table([]string{"DOMAIN", "ALIASES", "APP", "BACKENDS", "HEALTH"}, rows)
+
+	if len(resp.Backends) == 0 {
+		return
+	}
+	rows = make([][]string, 0, len(resp.Backends))
+	for _, b := range resp.Backends {
+		rows = append(rows, []string{
+			b.Address,
+			strconv.FormatInt(b.InFlight, 10),
+			strconv.FormatUint(b.Requests, 10),
+			fmt.Sprintf("%.0f%%", b.ErrorRate*100),
+			fmt.Sprintf("%dms", b.P95LatencyMS),
+			b.State,
+		})
+	}
+	ui.Table([]string{"BACKEND", "IN FLIGHT", "REQUESTS", "ERRORS", "P95", "STATE"}, rows)
+}
+
+func printProxyRoutesDiff(resp *apitypes.ProxyRoutesDryRunResponse, prefix string) {
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	if len(resp.Added) == 0 && len(resp.Removed) == 0 && len(resp.Changed) == 0 {
+		pui.Success("Proxy routes match the deployment state")
+		return
+	}
+
+	for _, r := range resp.Added {
+		pui.Info("+ %s -> %s %s", routeName(r), valueOrDash(r.App), joinOrDash(r.Backends))
+	}
+	for _, r := range resp.Removed {
+		pui.Info("- %s -> %s %s", routeName(r), valueOrDash(r.App), joinOrDash(r.Backends))
+	}
+	for _, c := range resp.Changed {
+		pui.Info("~ %s", routeName(c.To))
+		for _, field := range c.Fields {
+			from, ok := routeField(c.From, field)
+			if !ok {
+				pui.Info("    %s changed", field)
+				continue
+			}
+			to, _ := routeField(c.To, field)
+			pui.Info("    %s: %s -> %s", field, from, to)
+		}
+	}
+}
+
+// routeName is the route's domain with its path prefix, if any.
+func routeName(r apitypes.ProxyRoute) string {
+	return r.Domain + r.PathPrefix
+}
+
+// routeField formats the value of a field named in ProxyRouteChange.Fields,
+// reporting false for settings ProxyRoute doesn't carry.
+func routeField(r apitypes.ProxyRoute, field string) (string, bool) {
+	switch field {
+	case "aliases":
+		return joinOrDash(r.Aliases), true
+	case "app":
+		return valueOrDash(r.App), true
+	case "backends":
+		return joinOrDash(r.Backends), true
+	default:
+		return "", false
+	}
+}
+
+func joinOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ", ")
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,364 @@
+package haloy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func SecretCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secret",
+		Short: "Manage secrets stored on Haloy servers",
+		Long: `Manage secrets stored on Haloy servers.
+
+Env vars reference a server secret with:
+
+  env:
+    - name: STRIPE_KEY
+      from:
+        secret: server://stripe-key
+
+haloyd resolves the reference when it deploys, so the value never has to be
+available where haloy deploy runs.`,
+	}
+
+	cmd.AddCommand(
+		SecretSetCmd(configPath, flags),
+		SecretRotateCmd(configPath, flags),
+		SecretListCmd(configPath, flags),
+		SecretDeleteCmd(configPath, flags),
+	)
+
+	return cmd
+}
+
+const valueFromHelp = "Where to read the value: env:NAME, file:PATH, - for piped stdin, or an op:// or bw:// reference"
+
+func SecretSetCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag, valueFrom string
+
+	cmd := &cobra.Command{
+		Use:   "set <name>",
+		Short: "Store a secret on a Haloy server",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := config.ValidateSecretName(name); err != nil {
+				return err
+			}
+			value, err := readSecretValue(cmd.Context(), cmd.InOrStdin(), valueFrom)
+			if err != nil {
+				return err
+			}
+
+			targets, err := resolveRegistryTargets(cmd.Context(), cmd, registryConfigPath(configPath), flags, serverFlag)
+			if err != nil {
+				return err
+			}
+			for _, target := range targets {
+				response, err := secretSet(cmd.Context(), target.TargetConfig, target.Server, name, value)
+				if err != nil {
+					return err
+				}
+				ui.Success("Secret %s stored on %s", name, target.Server)
+				if len(response.Apps) > 0 {
+					ui.Info("Used by %s. Redeploy them, or use 'haloy secret rotate', for the running containers to get the new value", strings.Join(response.Apps, ", "))
+				}
+			}
+			return nil
+		},
+	}
+
+	addRegistryTargetFlags(cmd, flags, &serverFlag)
+	cmd.Flags().StringVar(&valueFrom, "value-from", "", valueFromHelp)
+	cmd.MarkFlagRequired("value-from")
+
+	return cmd
+}
+
+func SecretRotateCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag, valueFrom string
+	var yes, noRedeploy bool
+
+	cmd := &cobra.Command{
+		Use:   "rotate <name>",
+		Short: "Change a secret and redeploy the targets using it",
+		Long: `Change a secret stored on a Haloy server and redeploy the targets using it,
+so the running containers get the new value.
+
+The server reports the apps whose latest deployment references the secret.
+Targets of the config that deploy one of these apps are redeployed after
+confirmation, which --yes skips. Apps deployed from other configs are listed
+for you to redeploy.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			name := args[0]
+			if err := config.ValidateSecretName(name); err != nil {
+				return err
+			}
+			value, err := readSecretValue(ctx, cmd.InOrStdin(), valueFrom)
+			if err != nil {
+				return err
+			}
+
+			path := registryConfigPath(configPath)
+			targets, err := resolveRegistryTargets(ctx, cmd, path, flags, serverFlag)
+			if err != nil {
+				return err
+			}
+
+			affected := make(map[string][]string)
+			for _, target := range targets {
+				response, err := secretSet(ctx, target.TargetConfig, target.Server, name, value)
+				if err != nil {
+					return err
+				}
+				if response.Created {
+					ui.Success("Secret %s created on %s", name, target.Server)
+				} else {
+					ui.Success("Secret %s rotated on %s", name, target.Server)
+				}
+				affected[target.Server] = response.Apps
+			}
+
+			if noRedeploy {
+				for server, apps := range affected {
+					if len(apps) > 0 {
+						ui.Info("Redeploy %s on %s for the running containers to get the new value", strings.Join(apps, ", "), server)
+					}
+				}
+				return nil
+			}
+			return redeploySecretUsers(ctx, path, serverFlag != "", affected, yes)
+		},
+	}
+
+	addRegistryTargetFlags(cmd, flags, &serverFlag)
+	cmd.Flags().StringVar(&valueFrom, "value-from", "", valueFromHelp)
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Redeploy without asking for confirmation")
+	cmd.Flags().BoolVar(&noRedeploy, "no-redeploy", false, "Only change the secret")
+	cmd.MarkFlagRequired("value-from")
+
+	return cmd
+}
+
+// redeploySecretUsers redeploys the targets of the config at configPath that
+// deploy the apps affected lists per server. Without a config, as with
+// --server, the apps are only listed.
+func redeploySecretUsers(ctx context.Context, configPath string, serverOverride bool, affected map[string][]string, yes bool) error {
+	var targets map[string]config.TargetConfig
+	if !serverOverride {
+		rawDeployConfig, format, err := configloader.LoadRawDeployConfig(configPath)
+		if err != nil {
+			return fmt.Errorf("unable to load config: %w", err)
+		}
+		targets, err = configloader.ExtractTargets(rawDeployConfig, format)
+		if err != nil {
+			return err
+		}
+	}
+
+	redeploy, others := secretUserTargets(targets, affected)
+	for _, other := range others {
+		ui.Warn("%s isn't deployed by this config; redeploy it from its own config for it to get the new value", other)
+	}
+	if len(redeploy) == 0 {
+		if len(others) == 0 {
+			ui.Info("No deployed app uses the secret")
+		}
+		return nil
+	}
+
+	if !yes {
+		answer, err := ui.Prompt(fmt.Sprintf("Redeploy %s to apply the new value? [y/N]", strings.Join(redeploy, ", ")))
+		if err != nil {
+			return err
+		}
+		if !slices.Contains([]string{"y", "yes"}, strings.ToLower(answer)) {
+			ui.Info("Skipped the redeploy; the running containers keep the old value until the next deploy")
+			return nil
+		}
+	}
+	return deployApp(ctx, configPath, &appCmdFlags{targets: redeploy}, deployOptions{})
+}
+
+// secretUserTargets returns the names of the targets that deploy one of the
+// affected apps to its server, and the affected apps no target deploys, as
+// "app on server".
+func secretUserTargets(targets map[string]config.TargetConfig, affected map[string][]string) (redeploy, others []string) {
+	covered := make(map[string]bool)
+	for targetName, target := range targets {
+		server, err := helpers.NormalizeServerURL(target.Server)
+		if err != nil {
+			continue
+		}
+		if slices.Contains(affected[server], target.Name) {
+			redeploy = append(redeploy, targetName)
+			covered[server+"\x00"+target.Name] = true
+		}
+	}
+	for server, apps := range affected {
+		for _, app := range apps {
+			if !covered[server+"\x00"+app] {
+				others = append(others, fmt.Sprintf("%s on %s", app, server))
+			}
+		}
+	}
+	sort.Strings(redeploy)
+	sort.Strings(others)
+	return redeploy, others
+}
+
+func SecretListCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the secrets stored on a Haloy server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := resolveRegistryTargets(cmd.Context(), cmd, registryConfigPath(configPath), flags, serverFlag)
+			if err != nil {
+				return err
+			}
+
+			multipleServers := len(targets) > 1
+			var rows [][]string
+			for _, target := range targets {
+				api, err := registryAPI(cmd.Context(), target.TargetConfig, target.Server)
+				if err != nil {
+					return err
+				}
+				var response apitypes.SecretsResponse
+				if err := api.Get(cmd.Context(), "secrets", &response); err != nil {
+					return fmt.Errorf("failed to list secrets: %w", err)
+				}
+				if len(response.Secrets) == 0 {
+					ui.Info("No secrets stored on %s", target.Server)
+					continue
+				}
+				for _, secret := range response.Secrets {
+					row := []string{secret.Name, helpers.FormatTime(secret.UpdatedAt), strings.Join(secret.Apps, ", ")}
+					if multipleServers {
+						row = append([]string{target.Server}, row...)
+					}
+					rows = append(rows, row)
+				}
+			}
+
+			if len(rows) == 0 {
+				return nil
+			}
+			headers := []string{"Name", "Updated", "Used by"}
+			if multipleServers {
+				headers = append([]string{"Server"}, headers...)
+			}
+			ui.Table(headers, rows)
+			return nil
+		},
+	}
+
+	addRegistryTargetFlags(cmd, flags, &serverFlag)
+
+	return cmd
+}
+
+func SecretDeleteCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a secret no deployed app uses",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			targets, err := resolveRegistryTargets(cmd.Context(), cmd, registryConfigPath(configPath), flags, serverFlag)
+			if err != nil {
+				return err
+			}
+			for _, target := range targets {
+				api, err := registryAPI(cmd.Context(), target.TargetConfig, target.Server)
+				if err != nil {
+					return err
+				}
+				if err := api.Delete(cmd.Context(), "secrets/"+url.PathEscape(name)); err != nil {
+					return fmt.Errorf("failed to delete secret %s: %w", name, err)
+				}
+				ui.Success("Secret %s deleted from %s", name, target.Server)
+			}
+			return nil
+		},
+	}
+
+	addRegistryTargetFlags(cmd, flags, &serverFlag)
+
+	return cmd
+}
+
+func secretSet(ctx context.Context, targetConfig *config.TargetConfig, serverURL, name, value string) (*apitypes.SecretSetResponse, error) {
+	api, err := registryAPI(ctx, targetConfig, serverURL)
+	if err != nil {
+		return nil, err
+	}
+	var response apitypes.SecretSetResponse
+	if err := api.Put(ctx, "secrets/"+url.PathEscape(name), apitypes.SecretSetRequest{Value: value}, &response); err != nil {
+		return nil, fmt.Errorf("failed to store secret %s: %w", name, err)
+	}
+	return &response, nil
+}
+
+// readSecretValue reads the value of a secret from where valueFrom points.
+// Values are never taken as arguments, which would leave them in shell
+// history.
+func readSecretValue(ctx context.Context, stdin io.Reader, valueFrom string) (string, error) {
+	var value string
+	switch {
+	case valueFrom == "-":
+		if file, ok := stdin.(*os.File); ok && isTerminal(file.Fd()) {
+			return "", fmt.Errorf("--value-from - requires piped input. Try: echo \"$VALUE\" | haloy secret set <name> --value-from -")
+		}
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret from stdin: %w", err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	case strings.HasPrefix(valueFrom, "env:"):
+		name := strings.TrimPrefix(valueFrom, "env:")
+		value = os.Getenv(name)
+		if value == "" {
+			return "", fmt.Errorf("environment variable '%s' is not set or empty", name)
+		}
+	case strings.HasPrefix(valueFrom, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(valueFrom, "file:"))
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret: %w", err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	case strings.HasPrefix(valueFrom, "op://"), strings.HasPrefix(valueFrom, "bw://"):
+		resolved, err := configloader.ResolveSecretReference(ctx, valueFrom)
+		if err != nil {
+			return "", err
+		}
+		value = resolved
+	default:
+		return "", fmt.Errorf("invalid --value-from '%s': use env:NAME, file:PATH, - or an op:// or bw:// reference", valueFrom)
+	}
+	if value == "" {
+		return "", fmt.Errorf("secret value from '%s' is empty", valueFrom)
+	}
+	return value, nil
+}
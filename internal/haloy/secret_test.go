@@ -0,0 +1,73 @@
+package haloy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestReadSecretValue(t *testing.T) {
+	t.Setenv("HALOY_TEST_SECRET", "from-env")
+	t.Setenv("HALOY_TEST_EMPTY", "")
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		valueFrom string
+		stdin     string
+		want      string
+		wantErr   bool
+	}{
+		{name: "stdin", valueFrom: "-", stdin: "from-stdin\n", want: "from-stdin"},
+		{name: "empty stdin", valueFrom: "-", stdin: "\n", wantErr: true},
+		{name: "env", valueFrom: "env:HALOY_TEST_SECRET", want: "from-env"},
+		{name: "empty env", valueFrom: "env:HALOY_TEST_EMPTY", wantErr: true},
+		{name: "file", valueFrom: "file:" + path, want: "from-file"},
+		{name: "missing file", valueFrom: "file:" + path + ".missing", wantErr: true},
+		{name: "literal value", valueFrom: "hunter2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readSecretValue(context.Background(), strings.NewReader(tt.stdin), tt.valueFrom)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readSecretValue() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readSecretValue() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("readSecretValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretUserTargets(t *testing.T) {
+	targets := map[string]config.TargetConfig{
+		"web":     {Name: "shop", Server: "https://one.example.com"},
+		"worker":  {Name: "shop-worker", Server: "one.example.com"},
+		"staging": {Name: "shop", Server: "two.example.com"},
+	}
+	affected := map[string][]string{
+		"one.example.com": {"shop", "shop-worker", "blog"},
+	}
+
+	redeploy, others := secretUserTargets(targets, affected)
+	if want := []string{"web", "worker"}; !slices.Equal(redeploy, want) {
+		t.Errorf("secretUserTargets() redeploy = %v, want %v", redeploy, want)
+	}
+	if want := []string{"blog on one.example.com"}; !slices.Equal(others, want) {
+		t.Errorf("secretUserTargets() others = %v, want %v", others, want)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/keyring"
 	"github.com/haloydev/haloy/internal/ui"
 	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
@@ -29,40 +30,66 @@ func ServerCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
 	cmd.AddCommand(ServerAddCmd())
 	cmd.AddCommand(ServerDeleteCmd())
 	cmd.AddCommand(ServerListCmd())
+	cmd.AddCommand(UseProfileCmd())
 	cmd.AddCommand(ServerRegistryCmd(configPath, flags))
 	cmd.AddCommand(ServerLogsCmd(configPath, flags))
 	cmd.AddCommand(ServerVersionCmd(configPath, flags))
+	cmd.AddCommand(ServerDfCmd(configPath, flags))
+	cmd.AddCommand(ServerUpgradeCmd(configPath, flags))
 
 	return cmd
 }
 
 func ServerAddCmd() *cobra.Command {
 	var force bool
+	var defaultTarget string
 
 	cmd := &cobra.Command{
-		Use:   "add <url> <token>",
+		Use:   "add [name] <url> <token>",
 		Short: "Add a new Haloy server",
+		Long: `Add a Haloy server and store its API token.
+
+Give the server a name to create a profile for it. Deploy configs can then
+refer to the server by name ('server: prod'), and 'haloy server use prod'
+makes it the server for configs that don't set one.
+
+Examples:
+  # Add a server
+  haloy server add haloy.example.com <token>
+
+  # Add a server as the prod profile
+  haloy server add prod haloy.example.com <token>
+
+  # Deploy the production target by default while prod is in use
+  haloy server add prod haloy.example.com <token> --default-target production`,
 		Args: func(cmd *cobra.Command, args []string) error {
-			if len(args) < 2 {
-				ui.Error("Error: You must provide a <url> and a <token> to add a server.\n")
+			if len(args) < 2 || len(args) > 3 {
+				ui.Error("Error: You must provide a <url> and a <token> to add a server, optionally preceded by a profile name.\n")
 				ui.Info("%s", cmd.UsageString())
-				return fmt.Errorf("requires at least 2 arg(s), only received %d", len(args))
+				return fmt.Errorf("accepts 2 or 3 arg(s), received %d", len(args))
 			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			url := args[0]
-			token := strings.Join(args[1:], " ")
-			return addServerURL(url, token, force)
+			if len(args) == 3 {
+				return addServer(args[0], args[1], args[2], defaultTarget, force)
+			}
+			if defaultTarget != "" {
+				return errors.New("--default-target needs a profile name: haloy server add <name> <url> <token>")
+			}
+			return addServer("", args[0], args[1], "", force)
 		},
 	}
 
-	cmd.Flags().BoolVarP(&force, "force", "f", false, "Force overwrite if server already exists")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Force overwrite if server or profile already exists")
+	cmd.Flags().StringVar(&defaultTarget, "default-target", "", "Target to use when the profile is current and no target is selected")
 
 	return cmd
 }
 
-func addServerURL(url, token string, force bool) error {
+// addServer stores the token for url and, when profile is set, a profile
+// pointing at it.
+func addServer(profile, url, token, defaultTarget string, force bool) error {
 	if url == "" {
 		return errors.New("URL is required")
 	}
@@ -76,7 +103,7 @@ func addServerURL(url, token string, force bool) error {
 		return fmt.Errorf("invalid URL: %w", err)
 	}
 
-	if err := helpers.IsValidDomain(normalizedURL); err != nil {
+	if err := helpers.ValidateServerHost(normalizedURL); err != nil {
 		return fmt.Errorf("invalid domain: %w", err)
 	}
 
@@ -89,10 +116,21 @@ func addServerURL(url, token string, force bool) error {
 		return fmt.Errorf("failed to create config dir: %w", err)
 	}
 
-	envFile := filepath.Join(configDir, constants.ConfigEnvFileName)
+	clientConfigPath := filepath.Join(configDir, constants.ClientConfigFileName)
+	clientConfig, err := config.LoadClientConfig(clientConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load client config: %w", err)
+	}
+
+	if clientConfig == nil {
+		clientConfig = &config.ClientConfig{}
+	}
 
 	tokenEnv := generateTokenEnvName(normalizedURL)
 
+	// A profile for a server that is already added only needs --force when it
+	// would replace the stored token.
+	envFile := filepath.Join(configDir, constants.ConfigEnvFileName)
 	env, err := godotenv.Read(envFile)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -101,23 +139,26 @@ func addServerURL(url, token string, force bool) error {
 			return fmt.Errorf("failed to read env file: %w", err)
 		}
 	}
-	env[tokenEnv] = token
-	if err := godotenv.Write(env, envFile); err != nil {
-		return fmt.Errorf("failed to write env file: %w", err)
-	}
+	forceServer := force || (profile != "" && env[tokenEnv] == token)
 
-	clientConfigPath := filepath.Join(configDir, constants.ClientConfigFileName)
-	clientConfig, err := config.LoadClientConfig(clientConfigPath)
-	if err != nil {
-		return fmt.Errorf("failed to load client config: %w", err)
+	if err := clientConfig.AddServer(normalizedURL, tokenEnv, forceServer); err != nil {
+		return fmt.Errorf("failed to add server: %w", err)
 	}
 
-	if clientConfig == nil {
-		clientConfig = &config.ClientConfig{}
+	if profile != "" {
+		if err := clientConfig.AddProfile(profile, normalizedURL, force); err != nil {
+			return fmt.Errorf("failed to add profile: %w", err)
+		}
+		if defaultTarget != "" {
+			p := clientConfig.Profiles[profile]
+			p.DefaultTarget = defaultTarget
+			clientConfig.Profiles[profile] = p
+		}
 	}
 
-	if err := clientConfig.AddServer(normalizedURL, tokenEnv, force); err != nil {
-		return fmt.Errorf("failed to add server: %w", err)
+	env[tokenEnv] = token
+	if err := godotenv.Write(env, envFile); err != nil {
+		return fmt.Errorf("failed to write env file: %w", err)
 	}
 
 	if err := config.SaveClientConfig(clientConfig, clientConfigPath); err != nil {
@@ -126,6 +167,9 @@ func addServerURL(url, token string, force bool) error {
 
 	ui.Success("Server %s added successfully", normalizedURL)
 	ui.Info("API token stored as: %s", tokenEnv)
+	if profile != "" {
+		ui.Info("Use it with 'server: %s' in haloy.yaml, or make it the default with 'haloy server use %s'", profile, profile)
+	}
 
 	return nil
 }
@@ -136,21 +180,21 @@ func generateTokenEnvName(url string) string {
 
 func ServerDeleteCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "delete <url>",
-		Short: "Delete a Haloy server",
-		Args:  cobra.ExactArgs(1),
+		Use:     "delete <url|name>",
+		Aliases: []string{"remove", "rm"},
+		Short:   "Delete a Haloy server or profile",
+		Long: `Delete a Haloy server or profile.
+
+Deleting a server by URL also deletes the profiles pointing at it. Deleting a
+profile also deletes its server, unless another profile still uses it.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			url := args[0]
+			ref := args[0]
 
-			if url == "" {
+			if ref == "" {
 				return errors.New("URL is required")
 			}
 
-			normalizedURL, err := helpers.NormalizeServerURL(url)
-			if err != nil {
-				return fmt.Errorf("invalid URL: %w", err)
-			}
-
 			configDir, err := config.HaloyConfigDir()
 			if err != nil {
 				return fmt.Errorf("failed to get config dir: %w", err)
@@ -166,34 +210,57 @@ func ServerDeleteCmd() *cobra.Command {
 				return fmt.Errorf("no config file found in %s", clientConfigPath)
 			}
 
-			if len(clientConfig.Servers) == 0 {
-				return errors.New("no servers found in client config")
+			var profiles []string
+			deleteServer := true
+			if profile, exists := clientConfig.Profiles[ref]; exists {
+				profiles = []string{ref}
+				ref = profile.Server
+				deleteServer = len(clientConfig.ProfilesForServer(ref)) == 1
+			} else {
+				profiles = clientConfig.ProfilesForServer(ref)
 			}
 
-			serverConfig, exists := clientConfig.Servers[normalizedURL]
-			if !exists {
-				return fmt.Errorf("server %s not found in config", normalizedURL)
+			normalizedURL, err := helpers.NormalizeServerURL(ref)
+			if err != nil {
+				return fmt.Errorf("invalid URL: %w", err)
 			}
 
-			envFile := filepath.Join(configDir, constants.ConfigEnvFileName)
-			env, _ := godotenv.Read(envFile)
-			if _, exists := env[serverConfig.TokenEnv]; exists {
-				delete(env, serverConfig.TokenEnv)
-				if err := godotenv.Write(env, envFile); err != nil {
-					ui.Warn("Failed to write env file: %v", err)
-					ui.Info("Please remove the token %s from %s manually", serverConfig.TokenEnv, envFile)
+			for _, name := range profiles {
+				if err := clientConfig.DeleteProfile(name); err != nil {
+					return fmt.Errorf("failed to delete profile: %w", err)
 				}
+				ui.Success("Profile %s deleted successfully", name)
 			}
 
-			if err := clientConfig.DeleteServer(normalizedURL); err != nil {
-				return fmt.Errorf("failed to delete server: %w", err)
+			if deleteServer {
+				serverConfig, exists := clientConfig.Servers[normalizedURL]
+				if !exists {
+					if len(profiles) == 0 {
+						return fmt.Errorf("server %s not found in config", normalizedURL)
+					}
+				} else {
+					if serverConfig.TokenEnv != "" {
+						removeEnvFileToken(configDir, serverConfig.TokenEnv)
+					}
+					if serverConfig.Keyring {
+						if err := keyringDelete(normalizedURL); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+							ui.Warn("Failed to remove token from keyring: %v", err)
+						}
+					}
+
+					if err := clientConfig.DeleteServer(normalizedURL); err != nil {
+						return fmt.Errorf("failed to delete server: %w", err)
+					}
+				}
 			}
 
 			if err := config.SaveClientConfig(clientConfig, clientConfigPath); err != nil {
 				return fmt.Errorf("failed to save client config: %w", err)
 			}
 
-			ui.Success("Server %s deleted successfully", normalizedURL)
+			if deleteServer {
+				ui.Success("Server %s deleted successfully", normalizedURL)
+			}
 
 			return nil
 		},
@@ -227,15 +294,11 @@ func ServerListCmd() *cobra.Command {
 			}
 
 			ui.Info("List of servers:")
-			headers := []string{"URL", "ENV VAR", "ENV VAR EXISTS"}
+			headers := []string{"URL", "PROFILES", "ENV VAR", "TOKEN"}
 			rows := make([][]string, 0, len(servers))
-			for url, config := range servers {
-				tokenExists := "⚠️ no"
-				token := os.Getenv(config.TokenEnv)
-				if token != "" {
-					tokenExists = "✅ yes"
-				}
-				rows = append(rows, []string{url, config.TokenEnv, tokenExists})
+			for _, url := range clientConfig.ListServers() {
+				config := servers[url]
+				rows = append(rows, []string{url, strings.Join(clientConfig.ProfilesForServer(url), ", "), config.TokenEnv, tokenSource(config)})
 			}
 
 			ui.Table(headers, rows)
@@ -258,7 +321,7 @@ func ServerVersionCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			if serverFlag != "" {
-				version, err := getServerVersion(ctx, nil, serverFlag, "")
+				version, err := getServerVersion(ctx, nil, resolveServerRef(serverFlag), "")
 				if err != nil {
 					return err
 				}
@@ -295,7 +358,7 @@ func ServerVersionCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
-	cmd.Flags().StringVarP(&serverFlag, "server", "s", "", "Server URL (overrides config file)")
+	cmd.Flags().StringVarP(&serverFlag, "server", "s", "", "Server URL or profile name (overrides config file)")
 	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Get version for specific targets (comma-separated)")
 	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Get version for all targets")
 	cmd.Flags().BoolVar(&components, "components", false, "Show component build and compatibility metadata")
@@ -319,6 +382,9 @@ func printServerVersion(version *apitypes.VersionResponse, prefix string, compon
 	default:
 		pui.Warn("haloy-proxy: incompatible; run the server upgrade script")
 	}
+	for _, listener := range version.ProxyListenersDown {
+		pui.Warn("haloy-proxy: %s listener is down and being rebound; check 'journalctl -u haloy-proxy'", listener)
+	}
 
 	if !components {
 		return
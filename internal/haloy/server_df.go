@@ -0,0 +1,153 @@
+package haloy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+func ServerDfCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "df",
+		Short: "Show disk usage on the server",
+		Long: `Show what takes up disk space on a Haloy server: Docker images,
+containers, volumes and build cache, and the haloy data directory (layer
+store, certificates, database). Also shows the free space left on each
+filesystem and the minimum free space deploys require.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			if serverFlag != "" {
+				usage, err := getServerDiskUsage(ctx, nil, resolveServerRef(serverFlag), "")
+				if err != nil {
+					return err
+				}
+				printServerDiskUsage(usage, "")
+				return nil
+			}
+
+			servers, err := resolveServerTargets(ctx, cmd, *configPath, flags)
+			if err != nil {
+				return err
+			}
+
+			usages := make([]*apitypes.DiskUsageResponse, len(servers))
+			g, ctx := errgroup.WithContext(ctx)
+			for i, serverTarget := range servers {
+				g.Go(func() error {
+					prefix := ""
+					if len(servers) > 1 {
+						prefix = serverTarget.Server
+					}
+					usage, err := getServerDiskUsage(ctx, serverTarget.TargetConfig, serverTarget.Server, prefix)
+					if err != nil {
+						return err
+					}
+					usages[i] = usage
+					return nil
+				})
+			}
+			if err := g.Wait(); err != nil {
+				return err
+			}
+
+			// Printed in order once all servers answered, so tables don't interleave.
+			for i, usage := range usages {
+				prefix := ""
+				if len(servers) > 1 {
+					prefix = servers[i].Server
+				}
+				printServerDiskUsage(usage, prefix)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringVarP(&serverFlag, "server", "s", "", "Server URL or profile name (overrides config file)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Show disk usage for specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Show disk usage for all targets")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+
+	return cmd
+}
+
+func getServerDiskUsage(ctx context.Context, targetConfig *config.TargetConfig, targetServer, prefix string) (*apitypes.DiskUsageResponse, error) {
+	token, err := getToken(targetConfig, targetServer)
+	if err != nil {
+		return nil, &PrefixedError{Err: fmt.Errorf("unable to get token: %w", err), Prefix: prefix}
+	}
+
+	api, err := apiclient.New(targetServer, token)
+	if err != nil {
+		return nil, &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
+	}
+
+	var response apitypes.DiskUsageResponse
+	if err := api.Get(ctx, "system/disk", &response); err != nil {
+		return nil, &PrefixedError{Err: fmt.Errorf("failed to get disk usage from API: %w", err), Prefix: prefix}
+	}
+	return &response, nil
+}
+
+func printServerDiskUsage(usage *apitypes.DiskUsageResponse, prefix string) {
+	pui := &ui.PrefixedUI{Prefix: prefix}
+
+	rows := make([][]string, 0, len(usage.Categories))
+	for _, c := range usage.Categories {
+		count := ""
+		if c.Count > 0 {
+			count = fmt.Sprintf("%d", c.Count)
+		}
+		reclaimable := ""
+		if c.ReclaimableBytes > 0 {
+			reclaimable = helpers.FormatBinaryBytes(c.ReclaimableBytes)
+		}
+		size := helpers.FormatBinaryBytes(c.SizeBytes)
+		if c.Error != "" {
+			size = "error: " + c.Error
+		}
+		rows = append(rows, []string{c.Name, count, size, reclaimable})
+	}
+	ui.Table([]string{"TYPE", "COUNT", "SIZE", "RECLAIMABLE"}, rows)
+
+	rows = make([][]string, 0, len(usage.Filesystems))
+	for _, fs := range usage.Filesystems {
+		used := fs.TotalBytes - min(fs.AvailableBytes, fs.TotalBytes)
+		usePercent := ""
+		if fs.TotalBytes > 0 {
+			usePercent = fmt.Sprintf("%.0f%%", float64(used)/float64(fs.TotalBytes)*100)
+		}
+		rows = append(rows, []string{
+			fs.Path,
+			strings.Join(fs.UsedBy, ", "),
+			helpers.FormatBinaryBytes(fs.TotalBytes),
+			helpers.FormatBinaryBytes(fs.AvailableBytes),
+			usePercent,
+		})
+	}
+	ui.Table([]string{"FILESYSTEM", "USED BY", "SIZE", "AVAIL", "USE%"}, rows)
+
+	if usage.MinFreeBytes == 0 {
+		pui.Info("Pre-deploy free space check: disabled")
+		return
+	}
+	pui.Info("Pre-deploy free space check: %s", helpers.FormatBinaryBytes(usage.MinFreeBytes))
+	for _, fs := range usage.Filesystems {
+		if fs.AvailableBytes < usage.MinFreeBytes {
+			pui.Warn("%s has %s free, deploys will be rejected until at least %s is free",
+				fs.Path, helpers.FormatBinaryBytes(fs.AvailableBytes), helpers.FormatBinaryBytes(usage.MinFreeBytes))
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package haloy
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+// serverFeature is a deploy config setting that only works on servers
+// advertising capability.
+type serverFeature struct {
+	setting    string
+	capability string
+}
+
+// requiredServerFeatures lists the settings in target that need server support
+// beyond a plain deploy, named as they are written in the config file.
+func requiredServerFeatures(target config.TargetConfig) []serverFeature {
+	format := target.Format
+	field := func(v any, name string) string {
+		return config.GetFieldNameForFormat(v, name, format)
+	}
+
+	var features []serverFeature
+	if len(target.Sidecars) > 0 {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "Sidecars"), constants.CapabilitySidecars})
+	}
+	if target.HealthCheck != nil {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "HealthCheck"), constants.CapabilityHealthCheck})
+	}
+	routeLimits := []struct {
+		name string
+		set  bool
+	}{
+		{"ClientMaxBodySize", target.ClientMaxBodySize != ""},
+		{"ProxyReadTimeout", target.ProxyReadTimeout != ""},
+		{"ProxySendTimeout", target.ProxySendTimeout != ""},
+	}
+	for _, limit := range routeLimits {
+		if limit.set {
+			features = append(features, serverFeature{field(config.TargetConfig{}, limit.name), constants.CapabilityRouteLimits})
+		}
+	}
+	if slices.ContainsFunc(target.Domains, func(d config.Domain) bool { return d.PathPrefix != "" }) {
+		features = append(features, serverFeature{field(config.Domain{}, "PathPrefix"), constants.CapabilityPathPrefix})
+	}
+	if slices.ContainsFunc(target.Domains, func(d config.Domain) bool { return d.CDN != "" }) {
+		features = append(features, serverFeature{field(config.Domain{}, "CDN"), constants.CapabilityCDN})
+	}
+	if len(target.Redirects) > 0 {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "Redirects"), constants.CapabilityRedirects})
+	}
+	if target.URLNormalization != nil {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "URLNormalization"), constants.CapabilityURLNormalization})
+	}
+	if target.ClientAuth != nil {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "ClientAuth"), constants.CapabilityClientAuth})
+	}
+	if target.SSO != nil {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "SSO"), constants.CapabilitySSO})
+	}
+	if target.ErrorPages != "" {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "ErrorPages"), constants.CapabilityErrorPages})
+	}
+	if target.HealthProbe != nil && target.HealthProbe.Type != "" && target.HealthProbe.Type != config.HealthCheckHTTP {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "HealthProbe"), constants.CapabilityHealthCheckTypes})
+	}
+	if target.Startup != nil {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "Startup"), constants.CapabilityStartupProbe})
+	}
+	if len(target.Ports) > 0 {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "Ports"), constants.CapabilityHostPorts})
+	}
+	if target.User != "" {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "User"), constants.CapabilityRunAsUser})
+	}
+	if len(target.GroupAdd) > 0 {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "GroupAdd"), constants.CapabilityRunAsUser})
+	}
+	if target.LoggingDriver != nil {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "LoggingDriver"), constants.CapabilityContainerLogging})
+	}
+	if len(target.Labels) > 0 {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "Labels"), constants.CapabilityContainerLogging})
+	}
+	if target.Security != nil {
+		features = append(features, serverFeature{field(config.TargetConfig{}, "Security"), constants.CapabilitySecurityOptions})
+	}
+	return features
+}
+
+// missingServerFeatures returns the settings of features the server with
+// capabilities does not support. known is false when the server predates
+// feature negotiation, in which case support can't be told either way.
+func missingServerFeatures(features []serverFeature, capabilities []string) (missing []string, known bool) {
+	if !slices.Contains(capabilities, constants.CapabilityFeatureNegotiation) {
+		for _, f := range features {
+			missing = append(missing, f.setting)
+		}
+		return missing, false
+	}
+	for _, f := range features {
+		if !slices.Contains(capabilities, f.capability) {
+			missing = append(missing, f.setting)
+		}
+	}
+	return missing, true
+}
+
+// checkServersCompatibility checks auth like checkServersAuth and, using the
+// same version response, that every server supports the config features its
+// targets use. All unsupported settings are reported together, before
+// anything is built or uploaded. Servers too old to advertise features only
+// produce a warning.
+func checkServersCompatibility(ctx context.Context, targets map[string]config.TargetConfig) error {
+	byServer := make(map[string][]config.TargetConfig)
+	serverURLs := make(map[string]string)
+	for _, target := range targets {
+		normalized, err := helpers.NormalizeServerURL(target.Server)
+		if err != nil {
+			return fmt.Errorf("invalid server URL %q: %w", target.Server, err)
+		}
+		byServer[normalized] = append(byServer[normalized], target)
+		serverURLs[normalized] = target.Server
+	}
+
+	var problems []string
+	for _, normalized := range slices.Sorted(maps.Keys(byServer)) {
+		serverTargets := byServer[normalized]
+		slices.SortFunc(serverTargets, func(a, b config.TargetConfig) int {
+			return strings.Compare(a.TargetName, b.TargetName)
+		})
+
+		version, err := fetchServerVersion(ctx, serverURLs[normalized], &serverTargets[0])
+		if err != nil {
+			return err
+		}
+
+		for _, target := range serverTargets {
+			missing, known := missingServerFeatures(requiredServerFeatures(target), version.Capabilities)
+			if len(missing) == 0 {
+				continue
+			}
+			if !known {
+				ui.Warn("Server %s (haloyd %s) does not report which config features it supports; %s uses %s, which may need a server upgrade",
+					normalized, version.Version, targetLabel(target), strings.Join(missing, ", "))
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("  %s on %s (haloyd %s): %s",
+				targetLabel(target), normalized, version.Version, strings.Join(missing, ", ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("the config uses settings the server does not support:\n%s\nupgrade haloyd on the server to match this CLI (%s) or remove these settings",
+			strings.Join(problems, "\n"), constants.Version)
+	}
+	return nil
+}
+
+func targetLabel(target config.TargetConfig) string {
+	if target.TargetName != "" && target.TargetName != target.Name {
+		return fmt.Sprintf("target %q", target.TargetName)
+	}
+	return fmt.Sprintf("app %q", target.Name)
+}
@@ -0,0 +1,129 @@
+package haloy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+func TestRequiredServerFeatures(t *testing.T) {
+	target := config.TargetConfig{
+		Format:           "yaml",
+		Sidecars:         []config.Sidecar{{Name: "worker"}},
+		ProxyReadTimeout: "120s",
+		Domains:          []config.Domain{{Canonical: "example.com"}, {Canonical: "example.com", PathPrefix: "/api"}},
+		ErrorPages:       "./errors",
+		HealthProbe:      &config.HealthProbe{Type: config.HealthCheckTCP},
+	}
+
+	var settings []string
+	for _, f := range requiredServerFeatures(target) {
+		settings = append(settings, f.setting)
+	}
+	want := []string{"sidecars", "proxy_read_timeout", "path_prefix", "error_pages", "health_check"}
+	if !slices.Equal(settings, want) {
+		t.Fatalf("settings = %v, want %v", settings, want)
+	}
+
+	if got := requiredServerFeatures(config.TargetConfig{Format: "yaml"}); len(got) != 0 {
+		t.Fatalf("plain target requires %v, want none", got)
+	}
+}
+
+func TestMissingServerFeatures(t *testing.T) {
+	features := []serverFeature{
+		{setting: "sidecars", capability: constants.CapabilitySidecars},
+		{setting: "error_pages", capability: constants.CapabilityErrorPages},
+	}
+
+	tests := []struct {
+		name         string
+		capabilities []string
+		wantMissing  []string
+		wantKnown    bool
+	}{
+		{
+			name:         "all supported",
+			capabilities: []string{constants.CapabilityFeatureNegotiation, constants.CapabilitySidecars, constants.CapabilityErrorPages},
+			wantKnown:    true,
+		},
+		{
+			name:         "one missing",
+			capabilities: []string{constants.CapabilityFeatureNegotiation, constants.CapabilitySidecars},
+			wantMissing:  []string{"error_pages"},
+			wantKnown:    true,
+		},
+		{
+			name:         "server predates negotiation",
+			capabilities: []string{constants.CapabilityLayerUpload},
+			wantMissing:  []string{"sidecars", "error_pages"},
+			wantKnown:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, known := missingServerFeatures(features, tt.capabilities)
+			if !slices.Equal(missing, tt.wantMissing) || known != tt.wantKnown {
+				t.Fatalf("missingServerFeatures() = %v, %v, want %v, %v", missing, known, tt.wantMissing, tt.wantKnown)
+			}
+		})
+	}
+}
+
+func newCapabilitiesServer(capabilities ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.URL.Path == "/v1/version" {
+			json.NewEncoder(w).Encode(apitypes.VersionResponse{Version: "1.0.0", Capabilities: capabilities})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+func TestCheckServersCompatibility_ReportsUnsupportedSettings(t *testing.T) {
+	srv := newCapabilitiesServer(constants.CapabilityFeatureNegotiation, constants.CapabilitySidecars)
+	defer srv.Close()
+
+	target := targetWithServer(srv.URL)
+	target.Name = "web"
+	target.Format = "yaml"
+	target.Sidecars = []config.Sidecar{{Name: "worker"}}
+	target.ErrorPages = "./errors"
+
+	err := checkServersCompatibility(context.Background(), map[string]config.TargetConfig{"web": target})
+	if err == nil {
+		t.Fatal("expected an error for unsupported error_pages")
+	}
+	if !strings.Contains(err.Error(), `app "web"`) || !strings.Contains(err.Error(), "error_pages") {
+		t.Fatalf("error = %q, want it to name the app and error_pages", err)
+	}
+	if strings.Contains(err.Error(), "sidecars") {
+		t.Fatalf("error = %q, sidecars are supported and should not be listed", err)
+	}
+}
+
+func TestCheckServersCompatibility_OldServerOnlyWarns(t *testing.T) {
+	srv := newCapabilitiesServer(constants.CapabilityLayerUpload)
+	defer srv.Close()
+
+	target := targetWithServer(srv.URL)
+	target.Format = "yaml"
+	target.Sidecars = []config.Sidecar{{Name: "worker"}}
+
+	if err := checkServersCompatibility(context.Background(), map[string]config.TargetConfig{"web": target}); err != nil {
+		t.Fatalf("expected no error for a server without feature negotiation, got %v", err)
+	}
+}
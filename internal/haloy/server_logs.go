@@ -29,7 +29,7 @@ The logs are streamed in real-time and will continue until interrupted (Ctrl+C).
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			ctx := cmd.Context()
 			if serverFlag != "" {
-				return streamServerLogs(ctx, nil, serverFlag, accessLogs)
+				return streamServerLogs(ctx, nil, resolveServerRef(serverFlag), accessLogs)
 			}
 
 			servers, err := resolveServerTargets(ctx, cmd, *configPath, flags)
@@ -48,7 +48,7 @@ The logs are streamed in real-time and will continue until interrupted (Ctrl+C).
 	}
 
 	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
-	cmd.Flags().StringVarP(&serverFlag, "server", "s", "", "Haloy server URL")
+	cmd.Flags().StringVarP(&serverFlag, "server", "s", "", "Haloy server URL or profile name")
 	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Show logs for specific targets (comma-separated)")
 	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Show all target logs")
 	cmd.Flags().BoolVar(&accessLogs, "access-logs", false, "Include proxy access logs in output")
@@ -94,5 +94,5 @@ func streamServerLogs(ctx context.Context, targetConfig *config.TargetConfig, ta
 	if accessLogs {
 		path += "?access-logs=true"
 	}
-	return api.Stream(ctx, path, streamHandler)
+	return api.StreamResumable(ctx, path, streamHandler)
 }
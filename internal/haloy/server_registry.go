@@ -181,7 +181,7 @@ func ServerRegistryListCmd(configPath *string, flags *appCmdFlags) *cobra.Comman
 }
 
 func addRegistryTargetFlags(cmd *cobra.Command, flags *appCmdFlags, serverFlag *string) {
-	cmd.Flags().StringVarP(serverFlag, "server", "s", "", "Server URL (overrides config file)")
+	cmd.Flags().StringVarP(serverFlag, "server", "s", "", "Server URL or profile name (overrides config file)")
 	if flags != nil {
 		cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
 		cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Apply to specific targets (comma-separated)")
@@ -227,7 +227,7 @@ func registryConfigPath(configPath *string) string {
 
 func resolveRegistryTargets(ctx context.Context, cmd *cobra.Command, configPath string, flags *appCmdFlags, serverOverride string) ([]registryTarget, error) {
 	if serverOverride != "" {
-		normalized, err := helpers.NormalizeServerURL(serverOverride)
+		normalized, err := helpers.NormalizeServerURL(resolveServerRef(serverOverride))
 		if err != nil {
 			return nil, fmt.Errorf("invalid server URL: %w", err)
 		}
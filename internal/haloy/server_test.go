@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
 )
 
@@ -157,3 +158,57 @@ func TestPrintServerVersionWarnsWhenProxyIsIncompatible(t *testing.T) {
 		t.Fatalf("expected incompatibility warning, got:\n%s", stderr)
 	}
 }
+
+func TestServerProfileAddUseDelete(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv(constants.EnvVarConfigDir, configDir)
+
+	if err := runRootCommand(t, "server", "add", "prod", "https://haloy.example.com", "secret", "--default-target", "production"); err != nil {
+		t.Fatalf("server add with profile failed: %v", err)
+	}
+	if err := runRootCommand(t, "server", "use", "prod"); err != nil {
+		t.Fatalf("server use failed: %v", err)
+	}
+
+	clientConfig, err := config.LoadDefaultClientConfig()
+	if err != nil || clientConfig == nil {
+		t.Fatalf("failed to load client config: %v", err)
+	}
+	name, profile, ok := clientConfig.Current()
+	if !ok || name != "prod" || profile.Server != "haloy.example.com" || profile.DefaultTarget != "production" {
+		t.Fatalf("Current() = %s, %+v, %v, expected prod on haloy.example.com", name, profile, ok)
+	}
+	if _, exists := clientConfig.Servers["haloy.example.com"]; !exists {
+		t.Fatalf("expected server token to be stored for the profile")
+	}
+
+	// Adding a second profile for the same server reuses its token.
+	if err := runRootCommand(t, "server", "add", "prod-eu", "haloy.example.com", "secret"); err != nil {
+		t.Fatalf("second profile for the same server failed: %v", err)
+	}
+	if err := runRootCommand(t, "server", "remove", "prod"); err != nil {
+		t.Fatalf("server remove failed: %v", err)
+	}
+
+	clientConfig, err = config.LoadDefaultClientConfig()
+	if err != nil {
+		t.Fatalf("failed to load client config: %v", err)
+	}
+	if _, _, ok := clientConfig.Current(); ok {
+		t.Errorf("expected no current profile after removing it")
+	}
+	if _, exists := clientConfig.Servers["haloy.example.com"]; !exists {
+		t.Errorf("expected server to be kept while prod-eu still uses it")
+	}
+
+	if err := runRootCommand(t, "server", "delete", "haloy.example.com"); err != nil {
+		t.Fatalf("server delete by URL failed: %v", err)
+	}
+	clientConfig, err = config.LoadDefaultClientConfig()
+	if err != nil {
+		t.Fatalf("failed to load client config: %v", err)
+	}
+	if len(clientConfig.Profiles) != 0 || len(clientConfig.Servers) != 0 {
+		t.Errorf("expected no profiles or servers left, got %+v", clientConfig)
+	}
+}
@@ -0,0 +1,183 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+func ServerUpgradeCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var (
+		serverFlag string
+		req        apitypes.ServerUpgradeRequest
+		cancel     bool
+		parallel   int
+		timeout    time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Schedule an upgrade of the server",
+		Long: `Schedule haloyd to upgrade itself and haloy-proxy to the latest release during a
+maintenance window, instead of running the upgrade script by hand. haloyd runs
+the upgrade the next time the window starts, and reports the result here and to
+--notify, which is sent a POST with the outcome as JSON.
+
+With --now, upgrades right away and waits for the result. Several servers are
+upgraded in stages: the first one alone, then the rest, --parallel at a time.
+Each server is verified after its upgrade, and the first one that fails halts
+the rollout, so servers that haven't started yet keep their version.
+
+Without flags, shows the scheduled upgrade and how the last one went.`,
+		Example: `  haloy server upgrade --schedule "Sat 03:00" --timezone Europe/Oslo
+  haloy server upgrade --schedule 03:00 --notify https://hooks.example.com/haloy
+  haloy server upgrade --now --all --parallel 5
+  haloy server upgrade --cancel`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			switch {
+			case cancel && req.Schedule != "":
+				return errors.New("--cancel and --schedule can't be used together")
+			case req.Now && (cancel || req.Schedule != ""):
+				return errors.New("--now can't be used with --schedule or --cancel")
+			}
+
+			servers, err := resolveUpgradeServers(cmd, *configPath, flags, serverFlag)
+			if err != nil {
+				return err
+			}
+			if req.Now {
+				return newUpgradeRollout(servers, parallel, timeout, req.NotifyURL).run(cmd.Context())
+			}
+			return forEachUpgradeServer(cmd.Context(), servers, func(ctx context.Context, api *apiclient.APIClient, prefix string) error {
+				var response apitypes.ServerUpgradeResponse
+				var err error
+				switch {
+				case cancel:
+					if err = api.Delete(ctx, "server/upgrade"); err == nil {
+						(&ui.PrefixedUI{Prefix: prefix}).Success("Scheduled upgrade canceled")
+						return nil
+					}
+				case req.Schedule != "":
+					err = api.Post(ctx, "server/upgrade", req, &response)
+				default:
+					err = api.Get(ctx, "server/upgrade", &response)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to %s: %w", upgradeAction(cancel, req.Schedule), explainUpgradeError(err))
+				}
+				printServerUpgrade(response.Upgrade, prefix)
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringVarP(&serverFlag, "server", "s", "", "Server URL or profile name (overrides config file)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Use the servers of specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Use the servers of all targets")
+	cmd.Flags().StringVar(&req.Schedule, "schedule", "", `Start of the upgrade window, "[day] HH:MM" like "Sat 03:00", or "HH:MM" for the next day`)
+	cmd.Flags().StringVar(&req.Timezone, "timezone", "", "IANA time zone of --schedule, like Europe/Oslo (default UTC)")
+	cmd.Flags().StringVar(&req.Window, "window", "", "How long after its start the upgrade may still begin (default 1h)")
+	cmd.Flags().StringVar(&req.NotifyURL, "notify", "", "URL to POST the outcome of the upgrade to")
+	cmd.Flags().BoolVar(&cancel, "cancel", false, "Cancel the scheduled upgrade")
+	cmd.Flags().BoolVar(&req.Now, "now", false, "Upgrade right away, one server first and then the rest")
+	cmd.Flags().IntVar(&parallel, "parallel", 3, "With --now, how many servers to upgrade at a time after the first")
+	cmd.Flags().DurationVar(&timeout, "timeout", 15*time.Minute, "With --now, how long to wait for the upgrade of each server")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+
+	return cmd
+}
+
+func upgradeAction(cancel bool, schedule string) string {
+	switch {
+	case cancel:
+		return "cancel upgrade"
+	case schedule != "":
+		return "schedule upgrade"
+	}
+	return "get upgrade status"
+}
+
+func resolveUpgradeServers(cmd *cobra.Command, configPath string, flags *appCmdFlags, serverFlag string) ([]serverTarget, error) {
+	if serverFlag != "" {
+		return []serverTarget{{Server: resolveServerRef(serverFlag)}}, nil
+	}
+	return resolveServerTargets(cmd.Context(), cmd, configPath, flags)
+}
+
+func forEachUpgradeServer(ctx context.Context, servers []serverTarget, fn func(ctx context.Context, api *apiclient.APIClient, prefix string) error) error {
+	g, ctx := errgroup.WithContext(ctx)
+	for _, server := range servers {
+		g.Go(func() error {
+			prefix := ""
+			if len(servers) > 1 {
+				prefix = server.Server
+			}
+			token, err := getToken(server.TargetConfig, server.Server)
+			if err != nil {
+				return &PrefixedError{Err: fmt.Errorf("unable to get token: %w", err), Prefix: prefix}
+			}
+			api, err := apiclient.New(server.Server, token)
+			if err != nil {
+				return &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
+			}
+			if err := fn(ctx, api, prefix); err != nil {
+				return &PrefixedError{Err: err, Prefix: prefix}
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// explainUpgradeError points at an outdated haloyd when the endpoints are
+// missing.
+func explainUpgradeError(err error) error {
+	var httpErr *apiclient.HTTPError
+	if errors.Is(err, apiclient.ErrNotFound) || (errors.As(err, &httpErr) && httpErr.Body == "404 page not found") {
+		return errors.New("the server doesn't support scheduled upgrades, run the upgrade script on it instead")
+	}
+	return err
+}
+
+func printServerUpgrade(upgrade *apitypes.ServerUpgrade, prefix string) {
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	if upgrade == nil {
+		pui.Info("No upgrade scheduled")
+		return
+	}
+
+	loc, err := time.LoadLocation(upgrade.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	const layout = "Mon 2 Jan 15:04"
+	window := fmt.Sprintf("%s %s", upgrade.StartAt.In(loc).Format(layout), upgrade.Timezone)
+	if local := upgrade.StartAt.Local(); local.Format(layout) != upgrade.StartAt.In(loc).Format(layout) {
+		window += fmt.Sprintf(", %s local time", local.Format(layout))
+	}
+
+	switch upgrade.State {
+	case "scheduled":
+		pui.Success("Upgrade scheduled for %s (%s), may start until %s",
+			window, helpers.FormatTime(upgrade.StartAt), upgrade.Deadline.In(loc).Format("15:04"))
+	case "running":
+		pui.Info("Upgrade from %s running since %s", upgrade.FromVersion, helpers.FormatTime(upgrade.UpdatedAt))
+	case "completed":
+		pui.Success("Upgrade from %s to %s completed %s", upgrade.FromVersion, upgrade.ToVersion, helpers.FormatTime(upgrade.UpdatedAt))
+	case "canceled":
+		pui.Info("Upgrade for %s canceled", window)
+	default:
+		pui.Warn("Upgrade for %s %s: %s", window, upgrade.State, upgrade.Message)
+	}
+}
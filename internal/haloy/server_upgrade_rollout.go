@@ -0,0 +1,301 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/ui"
+	"golang.org/x/sync/errgroup"
+)
+
+// Phases of a server in an upgrade rollout.
+const (
+	rolloutPending   = "pending"
+	rolloutUpgrading = "upgrading"
+	rolloutVerifying = "verifying"
+	rolloutDone      = "done"
+	rolloutFailed    = "failed"
+	rolloutSkipped   = "skipped"
+)
+
+const (
+	upgradePollInterval = 2 * time.Second
+	// upgradeVerifyTimeout is how long an upgraded server gets to report a
+	// healthy haloy-proxy, which restarts along with haloyd.
+	upgradeVerifyTimeout = time.Minute
+)
+
+// upgradeAPI is the part of the API client a rollout uses.
+type upgradeAPI interface {
+	Get(ctx context.Context, path string, v any) error
+	Post(ctx context.Context, path string, request, response any) error
+}
+
+type rolloutServer struct {
+	target     serverTarget
+	phase      string
+	before     string
+	after      string
+	startedAt  time.Time
+	finishedAt time.Time
+	err        error
+}
+
+// upgradeRollout upgrades servers in stages: the first one alone, then the
+// rest, parallel at a time. A server that fails its upgrade or verification
+// halts the rollout; servers that haven't started by then are skipped.
+type upgradeRollout struct {
+	servers      []*rolloutServer
+	parallel     int
+	timeout      time.Duration // per server
+	notifyURL    string
+	pollInterval time.Duration
+	newAPI       func(serverTarget) (upgradeAPI, error)
+
+	mu     sync.Mutex
+	table  *ui.LiveTable
+	halted atomic.Bool
+}
+
+func newUpgradeRollout(targets []serverTarget, parallel int, timeout time.Duration, notifyURL string) *upgradeRollout {
+	r := &upgradeRollout{
+		parallel:     max(parallel, 1),
+		timeout:      timeout,
+		notifyURL:    notifyURL,
+		pollInterval: upgradePollInterval,
+		newAPI: func(target serverTarget) (upgradeAPI, error) {
+			token, err := getToken(target.TargetConfig, target.Server)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get token: %w", err)
+			}
+			return apiclient.New(target.Server, token)
+		},
+		table: ui.NewLiveTable([]string{"SERVER", "PHASE", "BEFORE", "AFTER", "DURATION"}),
+	}
+	for _, target := range targets {
+		r.servers = append(r.servers, &rolloutServer{target: target, phase: rolloutPending})
+	}
+	return r
+}
+
+func (r *upgradeRollout) run(ctx context.Context) error {
+	done := make(chan struct{})
+	var redraws sync.WaitGroup
+	redraws.Go(func() {
+		// Keeps the durations of running upgrades current.
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			r.redraw()
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+
+	canary, rest := r.servers[0], r.servers[1:]
+	r.upgrade(ctx, canary)
+
+	g := new(errgroup.Group)
+	g.SetLimit(r.parallel)
+	for _, server := range rest {
+		g.Go(func() error {
+			if r.halted.Load() || ctx.Err() != nil {
+				r.update(func() { server.phase = rolloutSkipped })
+				return nil
+			}
+			r.upgrade(ctx, server)
+			return nil
+		})
+	}
+	g.Wait()
+
+	close(done)
+	redraws.Wait()
+	r.table.Finish(r.rows())
+	return r.result()
+}
+
+// upgrade runs the upgrade of one server and halts the rollout when it fails.
+func (r *upgradeRollout) upgrade(ctx context.Context, server *rolloutServer) {
+	r.update(func() {
+		server.phase = rolloutUpgrading
+		server.startedAt = time.Now()
+	})
+	// Without redraws, the table only shows once the rollout ended.
+	pui := &ui.PrefixedUI{Prefix: server.target.Server}
+	if ui.NonInteractive() {
+		pui.Info("Upgrading")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	err := r.upgradeServer(ctx, server)
+	if err != nil {
+		r.halted.Store(true)
+	}
+	if ui.NonInteractive() {
+		if err != nil {
+			pui.Warn("Upgrade failed")
+		} else {
+			pui.Info("Upgraded")
+		}
+	}
+	r.update(func() {
+		server.finishedAt = time.Now()
+		server.err = err
+		server.phase = rolloutDone
+		if err != nil {
+			server.phase = rolloutFailed
+		}
+	})
+}
+
+func (r *upgradeRollout) upgradeServer(ctx context.Context, server *rolloutServer) error {
+	api, err := r.newAPI(server.target)
+	if err != nil {
+		return err
+	}
+
+	var version apitypes.VersionResponse
+	if err := api.Get(ctx, "version", &version); err != nil {
+		return fmt.Errorf("failed to get version: %w", err)
+	}
+	r.update(func() { server.before = version.Version })
+
+	req := apitypes.ServerUpgradeRequest{Now: true, NotifyURL: r.notifyURL}
+	if err := api.Post(ctx, "server/upgrade", req, nil); err != nil {
+		return fmt.Errorf("failed to start upgrade: %w", explainUpgradeError(err))
+	}
+	if err := r.waitForUpgrade(ctx, api); err != nil {
+		return err
+	}
+
+	r.update(func() { server.phase = rolloutVerifying })
+	return r.verify(ctx, api, server)
+}
+
+// waitForUpgrade polls the upgrade until it ends. Requests fail while the
+// upgrade restarts haloyd, so only authentication errors end the wait early.
+func (r *upgradeRollout) waitForUpgrade(ctx context.Context, api upgradeAPI) error {
+	var lastErr error
+	for {
+		var response apitypes.ServerUpgradeResponse
+		err := api.Get(ctx, "server/upgrade", &response)
+		switch {
+		case errors.Is(err, apiclient.ErrUnauthorized):
+			return err
+		case err != nil:
+			lastErr = err
+		case response.Upgrade != nil:
+			switch upgrade := response.Upgrade; upgrade.State {
+			case "completed":
+				return nil
+			case "failed", "missed", "canceled":
+				return fmt.Errorf("upgrade %s: %s", upgrade.State, upgrade.Message)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("timed out waiting for the upgrade, last error: %w", lastErr)
+			}
+			return errors.New("timed out waiting for the upgrade")
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// verify checks that haloyd is back with a compatible haloy-proxy, giving
+// the proxy upgradeVerifyTimeout to come up.
+func (r *upgradeRollout) verify(ctx context.Context, api upgradeAPI, server *rolloutServer) error {
+	ctx, cancel := context.WithTimeout(ctx, upgradeVerifyTimeout)
+	defer cancel()
+
+	for {
+		var version apitypes.VersionResponse
+		err := api.Get(ctx, "version", &version)
+		if err == nil {
+			r.update(func() { server.after = version.Version })
+			if err = checkUpgradedVersion(version); err == nil {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("verification failed: %w", err)
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+func checkUpgradedVersion(version apitypes.VersionResponse) error {
+	switch {
+	case version.ProxyCompatible == nil && version.ProxyVersion == "":
+		return errors.New("haloy-proxy is unavailable")
+	case version.ProxyCompatible != nil && !*version.ProxyCompatible:
+		return errors.New("haloy-proxy is incompatible with haloyd")
+	case len(version.ProxyListenersDown) > 0:
+		return fmt.Errorf("haloy-proxy %s listener is down", version.ProxyListenersDown[0])
+	}
+	return nil
+}
+
+func (r *upgradeRollout) update(fn func()) {
+	r.mu.Lock()
+	fn()
+	r.mu.Unlock()
+	r.redraw()
+}
+
+func (r *upgradeRollout) redraw() {
+	r.table.Update(r.rows())
+}
+
+func (r *upgradeRollout) rows() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rows := make([][]string, 0, len(r.servers))
+	for _, server := range r.servers {
+		duration := ""
+		switch {
+		case !server.finishedAt.IsZero():
+			duration = server.finishedAt.Sub(server.startedAt).Round(time.Second).String()
+		case !server.startedAt.IsZero():
+			duration = time.Since(server.startedAt).Round(time.Second).String()
+		}
+		rows = append(rows, []string{server.target.Server, server.phase, server.before, server.after, duration})
+	}
+	return rows
+}
+
+// result prints why servers failed and summarizes the rollout as an error
+// when it didn't upgrade every server.
+func (r *upgradeRollout) result() error {
+	var failed, skipped int
+	for _, server := range r.servers {
+		switch server.phase {
+		case rolloutFailed:
+			failed++
+			(&ui.PrefixedUI{Prefix: server.target.Server}).Error("%v", server.err)
+		case rolloutSkipped, rolloutPending:
+			skipped++
+		}
+	}
+	if failed == 0 && skipped == 0 {
+		ui.Success("Upgraded %d server(s)", len(r.servers))
+		return nil
+	}
+	return fmt.Errorf("upgrade rollout halted: %d of %d server(s) failed, %d skipped", failed, len(r.servers), skipped)
+}
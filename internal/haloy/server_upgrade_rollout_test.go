@@ -0,0 +1,129 @@
+package haloy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+// fakeUpgradeServer answers like a haloyd that upgrades from v1 to v2 when
+// asked, optionally failing the upgrade or coming back with a broken proxy.
+type fakeUpgradeServer struct {
+	mu            sync.Mutex
+	failUpgrade   bool
+	brokenProxy   bool
+	upgradeCalled bool
+}
+
+func (f *fakeUpgradeServer) Get(_ context.Context, path string, v any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch path {
+	case "version":
+		version := v.(*apitypes.VersionResponse)
+		compatible := !f.brokenProxy
+		version.Version, version.ProxyVersion, version.ProxyCompatible = "v1", "v1", &compatible
+		if f.upgradeCalled {
+			version.Version, version.ProxyVersion = "v2", "v2"
+		}
+	case "server/upgrade":
+		state := "completed"
+		if f.failUpgrade {
+			state = "failed"
+		}
+		v.(*apitypes.ServerUpgradeResponse).Upgrade = &apitypes.ServerUpgrade{State: state, Message: "script exited"}
+	default:
+		return fmt.Errorf("unexpected GET %s", path)
+	}
+	return nil
+}
+
+func (f *fakeUpgradeServer) Post(_ context.Context, path string, request, _ any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if req, ok := request.(apitypes.ServerUpgradeRequest); path != "server/upgrade" || !ok || !req.Now {
+		return fmt.Errorf("unexpected POST %s %+v", path, request)
+	}
+	f.upgradeCalled = true
+	return nil
+}
+
+func newTestRollout(t *testing.T, parallel int, servers map[string]*fakeUpgradeServer, order ...string) *upgradeRollout {
+	t.Helper()
+	ui.SetNonInteractive(true)
+	t.Cleanup(func() { ui.SetNonInteractive(false) })
+
+	targets := make([]serverTarget, 0, len(order))
+	for _, server := range order {
+		targets = append(targets, serverTarget{Server: server})
+	}
+	r := newUpgradeRollout(targets, parallel, 200*time.Millisecond, "")
+	r.pollInterval = 10 * time.Millisecond
+	r.newAPI = func(target serverTarget) (upgradeAPI, error) {
+		return servers[target.Server], nil
+	}
+	return r
+}
+
+func rolloutPhases(r *upgradeRollout) string {
+	phases := make([]string, 0, len(r.servers))
+	for _, server := range r.servers {
+		phases = append(phases, server.phase)
+	}
+	return strings.Join(phases, ",")
+}
+
+func TestUpgradeRollout_UpgradesAllServers(t *testing.T) {
+	servers := map[string]*fakeUpgradeServer{"a": {}, "b": {}, "c": {}}
+	r := newTestRollout(t, 2, servers, "a", "b", "c")
+
+	if err := r.run(context.Background()); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if got := rolloutPhases(r); got != "done,done,done" {
+		t.Errorf("phases = %s, want all done", got)
+	}
+	for _, server := range r.servers {
+		if server.before != "v1" || server.after != "v2" {
+			t.Errorf("%s: before %q, after %q, want v1 and v2", server.target.Server, server.before, server.after)
+		}
+	}
+}
+
+func TestUpgradeRollout_CanaryFailureHaltsRollout(t *testing.T) {
+	servers := map[string]*fakeUpgradeServer{"a": {failUpgrade: true}, "b": {}, "c": {}}
+	r := newTestRollout(t, 2, servers, "a", "b", "c")
+
+	err := r.run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 server(s) failed, 2 skipped") {
+		t.Fatalf("run() error = %v, want the rollout halted after the first server", err)
+	}
+	if got := rolloutPhases(r); got != "failed,skipped,skipped" {
+		t.Errorf("phases = %s, want the rest skipped", got)
+	}
+	if servers["b"].upgradeCalled || servers["c"].upgradeCalled {
+		t.Error("upgraded servers after the first one failed")
+	}
+}
+
+func TestUpgradeRollout_VerificationFailureHaltsRest(t *testing.T) {
+	servers := map[string]*fakeUpgradeServer{"a": {}, "b": {brokenProxy: true}, "c": {}}
+	r := newTestRollout(t, 1, servers, "a", "b", "c")
+
+	if err := r.run(context.Background()); err == nil {
+		t.Fatal("run() succeeded, want the broken proxy to halt the rollout")
+	}
+	if got := rolloutPhases(r); got != "done,failed,skipped" {
+		t.Errorf("phases = %s, want done,failed,skipped", got)
+	}
+	if err := r.servers[1].err; err == nil || !strings.Contains(err.Error(), "incompatible") {
+		t.Errorf("error = %v, want the incompatible proxy", err)
+	}
+}
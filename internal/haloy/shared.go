@@ -2,44 +2,45 @@ package haloy
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand"
 	"os"
 	"path/filepath"
-	"strings"
-	"time"
 
 	"github.com/haloydev/haloy/internal/apiclient"
 	"github.com/haloydev/haloy/internal/apitypes"
 	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/helpers"
-	"github.com/oklog/ulid"
 )
 
+var errNoAPIToken = errors.New("no API token found")
+
 func createDeploymentID() string {
-	entropy := ulid.Monotonic(rand.New(rand.NewSource(time.Now().UnixNano())), 0)
-	id := ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
-	return strings.ToLower(id)
+	return helpers.NewDeploymentID()
 }
 
 func checkServerAuth(ctx context.Context, server string, targetConfig *config.TargetConfig) error {
+	_, err := fetchServerVersion(ctx, server, targetConfig)
+	return err
+}
+
+func fetchServerVersion(ctx context.Context, server string, targetConfig *config.TargetConfig) (*apitypes.VersionResponse, error) {
 	token, err := getToken(targetConfig, server)
 	if err != nil {
-		return fmt.Errorf("server %s: %w", server, err)
+		return nil, fmt.Errorf("server %s: %w", server, err)
 	}
 
 	api, err := apiclient.New(server, token)
 	if err != nil {
-		return fmt.Errorf("server %s: unable to create API client: %w", server, err)
+		return nil, fmt.Errorf("server %s: unable to create API client: %w", server, err)
 	}
 
 	var version apitypes.VersionResponse
 	if err := api.Get(ctx, "version", &version); err != nil {
-		return fmt.Errorf("server %s: authentication check failed: %w", server, err)
+		return nil, fmt.Errorf("server %s: authentication check failed: %w", server, err)
 	}
-
-	return nil
+	return &version, nil
 }
 
 func checkServersAuth(ctx context.Context, targets map[string]config.TargetConfig) error {
@@ -60,38 +61,60 @@ func checkServersAuth(ctx context.Context, targets map[string]config.TargetConfi
 	return nil
 }
 
+// resolveServerRef returns the URL of the profile named ref, so --server
+// flags accept profile names like 'server' in the deploy config does.
+func resolveServerRef(ref string) string {
+	clientConfig, err := config.LoadDefaultClientConfig()
+	if err != nil {
+		return ref
+	}
+	return clientConfig.ResolveServer(ref)
+}
+
 func getToken(targetConfig *config.TargetConfig, url string) (string, error) {
+	token, _, err := getTokenWithSource(targetConfig, url)
+	return token, err
+}
+
+// getTokenWithSource returns the API token for url like getToken, and where
+// it was found.
+func getTokenWithSource(targetConfig *config.TargetConfig, url string) (token, source string, err error) {
 	if targetConfig != nil && targetConfig.APIToken != nil && targetConfig.APIToken.Value != "" {
-		return targetConfig.APIToken.Value, nil
+		return targetConfig.APIToken.Value, "api_token in the deploy config", nil
 	}
 
 	configDir, err := config.HaloyConfigDir()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	clientConfigPath := filepath.Join(configDir, constants.ClientConfigFileName)
 	clientConfig, err := config.LoadClientConfig(clientConfigPath)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	if clientConfig != nil {
 		normalizedURL, err := helpers.NormalizeServerURL(url)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 
 		if serverConfig, exists := clientConfig.Servers[normalizedURL]; exists {
+			if serverConfig.Keyring {
+				if token, err := keyringGet(normalizedURL); err == nil && token != "" {
+					return token, "OS keyring", nil
+				}
+			}
 			token := os.Getenv(serverConfig.TokenEnv)
 			if token != "" {
-				return token, nil
+				return token, serverConfig.TokenEnv + " environment variable", nil
 			}
 		}
 	}
 
 	if token := os.Getenv(constants.EnvVarAPIToken); token != "" {
-		return token, nil
+		return token, constants.EnvVarAPIToken + " environment variable", nil
 	}
 
-	return "", fmt.Errorf("no API token found. Either run 'haloy server add <url> <token>' or set the %s environment variable", constants.EnvVarAPIToken)
+	return "", "", fmt.Errorf("%w. Either run 'haloy auth login <url>' or 'haloy server add <url> <token>', or set the %s environment variable", errNoAPIToken, constants.EnvVarAPIToken)
 }
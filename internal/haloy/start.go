@@ -0,0 +1,100 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+func StartAppCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var serverFlag string
+
+	cmd := &cobra.Command{
+		Use:   "start",
+		Short: "Start an application stopped with haloy stop",
+		Long: `Start the containers of an application's current deployment after it was stopped with 'haloy stop'.
+Its routes come back once the containers pass their health checks.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if serverFlag != "" {
+				return startApp(ctx, nil, resolveServerRef(serverFlag), "", "")
+			}
+
+			rawDeployConfig, format, err := configloader.Load(ctx, *configPath, flags.targets, flags.all)
+			if err != nil {
+				return fmt.Errorf("unable to load config: %w", err)
+			}
+
+			resolvedDeployConfig, err := configloader.ResolveSecrets(ctx, rawDeployConfig, *configPath)
+			if err != nil {
+				return fmt.Errorf("failed to resolve secrets: %w", err)
+			}
+
+			targets, err := configloader.ExtractTargets(resolvedDeployConfig, format)
+			if err != nil {
+				return err
+			}
+
+			g, ctx := errgroup.WithContext(ctx)
+			for _, target := range targets {
+				g.Go(func() error {
+					prefix := ""
+					if len(targets) > 1 {
+						prefix = target.TargetName
+					}
+					return startApp(ctx, &target, target.Server, target.Name, prefix)
+				})
+			}
+			return g.Wait()
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringVarP(&serverFlag, "server", "s", "", "Haloy server URL or profile name (overrides config)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Start app on specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Start app on all targets")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+
+	return cmd
+}
+
+func startApp(ctx context.Context, targetConfig *config.TargetConfig, targetServer, appName, prefix string) error {
+	ui.Info("Starting application: %s using server %s", appName, targetServer)
+
+	token, err := getToken(targetConfig, targetServer)
+	if err != nil {
+		return &PrefixedError{Err: fmt.Errorf("unable to get token: %w", err), Prefix: prefix}
+	}
+
+	api, err := apiclient.New(targetServer, token)
+	if err != nil {
+		return &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
+	}
+
+	var response apitypes.StartAppResponse
+	if err := api.Post(ctx, fmt.Sprintf("start/%s", appName), nil, &response); err != nil {
+		if errors.Is(err, apiclient.ErrNotFound) {
+			return &PrefixedError{Err: errors.New("server does not support starting apps, upgrade haloyd to use haloy start"), Prefix: prefix}
+		}
+		return &PrefixedError{Err: fmt.Errorf("failed to start app: %w", err), Prefix: prefix}
+	}
+
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	if len(response.ContainerIDs) == 0 {
+		pui.Info("%s is already running (deployment %s)", appName, response.DeploymentID)
+		return nil
+	}
+	pui.Success("Started %d container(s) of %s (deployment %s), routes return once they pass health checks",
+		len(response.ContainerIDs), appName, response.DeploymentID)
+	return nil
+}
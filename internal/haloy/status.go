@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/haloydev/haloy/internal/apiclient"
@@ -41,6 +44,8 @@ func StatusAppCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
 				return err
 			}
 
+			var mu sync.Mutex
+			versions := make(map[string]string, len(targets))
 			g, ctx := errgroup.WithContext(ctx)
 			for _, target := range targets {
 				g.Go(func() error {
@@ -48,11 +53,22 @@ func StatusAppCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
 					if len(targets) > 1 {
 						prefix = target.TargetName
 					}
-					return getAppStatus(ctx, &target, target.Server, target.Name, prefix)
+					deploymentID, err := getAppStatus(ctx, &target, target.Server, target.Name, prefix)
+					if deploymentID == "" {
+						deploymentID = "not deployed"
+					}
+					mu.Lock()
+					versions[target.TargetName] = deploymentID
+					mu.Unlock()
+					return err
 				})
 			}
 
-			return g.Wait()
+			err = g.Wait()
+			for _, line := range serverGroupDivergence(targets, versions) {
+				ui.Warn("Servers out of sync: %s", line)
+			}
+			return err
 		},
 	}
 
@@ -65,17 +81,19 @@ func StatusAppCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
 	return cmd
 }
 
-func getAppStatus(ctx context.Context, targetConfig *config.TargetConfig, targetServer, appName, prefix string) error {
+// getAppStatus prints the status of appName and returns the ID of the
+// deployment it runs.
+func getAppStatus(ctx context.Context, targetConfig *config.TargetConfig, targetServer, appName, prefix string) (string, error) {
 	ui.Info("Getting status for application: %s using server %s", appName, targetServer)
 
 	token, err := getToken(targetConfig, targetServer)
 	if err != nil {
-		return &PrefixedError{Err: fmt.Errorf("unable to get token: %w", err), Prefix: prefix}
+		return "", &PrefixedError{Err: fmt.Errorf("unable to get token: %w", err), Prefix: prefix}
 	}
 
 	api, err := apiclient.New(targetServer, token)
 	if err != nil {
-		return &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
+		return "", &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
 	}
 	path := fmt.Sprintf("status/%s", appName)
 	var response apitypes.AppStatusResponse
@@ -83,13 +101,13 @@ func getAppStatus(ctx context.Context, targetConfig *config.TargetConfig, target
 
 		// Handle 404 specifically - app not deployed/running
 		if errors.Is(err, apiclient.ErrNotFound) {
-			return &PrefixedError{
+			return "", &PrefixedError{
 				Err:    fmt.Errorf("application '%s' is not currently deployed or running", appName),
 				Prefix: prefix,
 			}
 		}
 
-		return &PrefixedError{Err: fmt.Errorf("failed to get status: %w", err), Prefix: prefix}
+		return "", &PrefixedError{Err: fmt.Errorf("failed to get status: %w", err), Prefix: prefix}
 	}
 
 	containerIDs := make([]string, 0, len(response.ContainerIDs))
@@ -106,13 +124,61 @@ func getAppStatus(ctx context.Context, targetConfig *config.TargetConfig, target
 	formattedOutput := []string{
 		fmt.Sprintf("State: %s", state),
 		fmt.Sprintf("Deployment ID: %s", response.DeploymentID),
+	}
+	if response.DeploymentState != "" {
+		formattedOutput = append(formattedOutput, fmt.Sprintf("Deployment state: %s", response.DeploymentState))
+	}
+	formattedOutput = append(formattedOutput,
 		fmt.Sprintf("Running container(s): %s", strings.Join(containerIDs, ", ")),
 		fmt.Sprintf("Domain(s): %s", strings.Join(canonicalDomains, ", ")),
+	)
+
+	if response.Restarts > 0 {
+		formattedOutput = append(formattedOutput, fmt.Sprintf("Recent restarts: %d", response.Restarts))
+	}
+	if len(response.CrashLooping) > 0 {
+		crashLooping := make([]string, 0, len(response.CrashLooping))
+		for _, id := range response.CrashLooping {
+			crashLooping = append(crashLooping, helpers.SafeIDPrefix(id))
+		}
+		formattedOutput = append(formattedOutput, fmt.Sprintf("Crash looping (not receiving traffic): %s",
+			lipgloss.NewStyle().Foreground(ui.Red).Render(strings.Join(crashLooping, ", "))))
 	}
 
 	ui.Section(fmt.Sprintf("Status for %s", appName), formattedOutput)
 
-	return nil
+	return response.DeploymentID, nil
+}
+
+// serverGroupDivergence describes, one line per target with several
+// servers, the targets whose servers don't all report the same version.
+// versions maps target names to what their server runs.
+func serverGroupDivergence(targets map[string]config.TargetConfig, versions map[string]string) []string {
+	groups := make(map[string][]string)
+	for targetName, target := range targets {
+		if target.ServerGroup != "" {
+			groups[target.ServerGroup] = append(groups[target.ServerGroup], targetName)
+		}
+	}
+
+	var lines []string
+	for _, group := range slices.Sorted(maps.Keys(groups)) {
+		members := groups[group]
+		slices.SortFunc(members, func(a, b string) int { return targets[a].ServerIndex - targets[b].ServerIndex })
+
+		diverged := false
+		parts := make([]string, 0, len(members))
+		for _, member := range members {
+			if versions[member] != versions[members[0]] {
+				diverged = true
+			}
+			parts = append(parts, fmt.Sprintf("%s %s", targets[member].Server, versions[member]))
+		}
+		if diverged {
+			lines = append(lines, fmt.Sprintf("%s: %s", group, strings.Join(parts, ", ")))
+		}
+	}
+	return lines
 }
 
 func displayState(state string) string {
@@ -21,12 +21,15 @@ func StopAppCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "stop",
 		Short: "Stop an application's running containers",
-		Long:  "Stop all running containers for an application using a haloy configuration file.",
-		Args:  cobra.NoArgs,
+		Long: `Stop all running containers for an application using a haloy configuration file.
+The app is marked paused: its routes are removed, volumes and config are kept, and
+'haloy start' brings it back. With --remove-containers it stays down until it's
+deployed again.`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			if serverFlag != "" {
-				return stopApp(ctx, nil, serverFlag, "", removeContainersFlag, removeVolumesFlag, "")
+				return stopApp(ctx, nil, resolveServerRef(serverFlag), "", removeContainersFlag, removeVolumesFlag, "")
 			}
 
 			rawDeployConfig, format, err := configloader.Load(ctx, *configPath, flags.targets, flags.all)
@@ -66,7 +69,7 @@ func StopAppCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
-	cmd.Flags().StringVarP(&serverFlag, "server", "s", "", "Haloy server URL (overrides config)")
+	cmd.Flags().StringVarP(&serverFlag, "server", "s", "", "Haloy server URL or profile name (overrides config)")
 	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Stop app on specific targets (comma-separated)")
 	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Stop app on all targets")
 	cmd.Flags().BoolVarP(&removeContainersFlag, "remove-containers", "r", false, "Remove containers after stopping them")
@@ -0,0 +1,159 @@
+package haloy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func TopCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var (
+		interval time.Duration
+		noStream bool
+		history  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "Show live resource usage of application containers",
+		Long: `Show CPU, memory, network and block I/O usage for each running
+container of an app, refreshed until interrupted (Ctrl+C).
+
+Examples:
+  # Live usage for the app in the current directory
+  haloy top
+
+  # Refresh every 10 seconds
+  haloy top --interval 10s
+
+  # Print a single sample and exit
+  haloy top --no-stream
+
+  # CPU and memory usage charted over the last day
+  haloy top --history 24h
+
+  # Usage for a specific target (multi-target config)
+  haloy top --targets prod`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+
+			if history < 0 {
+				return fmt.Errorf("--history must be a positive duration")
+			}
+			if interval < time.Second {
+				return fmt.Errorf("--interval must be at least 1s")
+			}
+
+			rawDeployConfig, format, err := configloader.Load(ctx, *configPath, flags.targets, false)
+			if err != nil {
+				return fmt.Errorf("unable to load config: %w", err)
+			}
+
+			resolvedDeployConfig, err := configloader.ResolveSecrets(ctx, rawDeployConfig, *configPath)
+			if err != nil {
+				return fmt.Errorf("failed to resolve secrets: %w", err)
+			}
+
+			targets, err := configloader.ExtractTargets(resolvedDeployConfig, format)
+			if err != nil {
+				return err
+			}
+			if len(targets) != 1 {
+				return fmt.Errorf("haloy top shows one target at a time, select it with --targets")
+			}
+			var target config.TargetConfig
+			for _, t := range targets {
+				target = t
+			}
+
+			token, err := getToken(&target, target.Server)
+			if err != nil {
+				return fmt.Errorf("unable to get token: %w", err)
+			}
+
+			api, err := apiclient.New(target.Server, token)
+			if err != nil {
+				return fmt.Errorf("failed to create API client: %w", err)
+			}
+
+			if history > 0 {
+				return showUsageHistory(ctx, api, target.Name, history)
+			}
+
+			params := url.Values{}
+			params.Set("interval", interval.String())
+			if noStream {
+				params.Set("once", "true")
+			}
+			path := fmt.Sprintf("top/%s?%s", target.Name, params.Encode())
+
+			clearScreen := !noStream && isTerminal(os.Stdout.Fd()) && !ui.NonInteractive()
+			var handlerErr error
+			err = api.Stream(ctx, path, func(data string) bool {
+				var event apitypes.AppStatsEvent
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					handlerErr = fmt.Errorf("failed to parse stats: %w", err)
+					return true
+				}
+				if clearScreen {
+					fmt.Print("\033[H\033[2J")
+				}
+				ui.Basic("%s  %s", target.Name, event.Timestamp.Local().Format(time.TimeOnly))
+				ui.Table(topHeaders, topRows(event.Containers))
+				return noStream
+			})
+			if handlerErr != nil {
+				return handlerErr
+			}
+			if err != nil && ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("stats stream error: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Show usage for a specific target")
+	cmd.Flags().DurationVar(&interval, "interval", 3*time.Second, "Refresh interval")
+	cmd.Flags().BoolVar(&noStream, "no-stream", false, "Print a single sample and exit")
+	cmd.Flags().DurationVar(&history, "history", 0, "Chart the usage recorded over this duration, like 24h, instead of live usage")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+
+	return cmd
+}
+
+var topHeaders = []string{"CONTAINER", "CPU %", "MEM USAGE / LIMIT", "MEM %", "NET I/O", "BLOCK I/O", "PIDS"}
+
+func topRows(containers []apitypes.ContainerStats) [][]string {
+	rows := make([][]string, 0, len(containers))
+	for _, c := range containers {
+		if c.Error != "" {
+			rows = append(rows, []string{c.ContainerID, "-", c.Error, "-", "-", "-", "-"})
+			continue
+		}
+		rows = append(rows, []string{
+			c.ContainerID,
+			fmt.Sprintf("%.2f%%", c.CPUPercent),
+			fmt.Sprintf("%s / %s", ui.FormatBytes(int64(c.MemoryUsage)), ui.FormatBytes(int64(c.MemoryLimit))),
+			fmt.Sprintf("%.2f%%", c.MemoryPercent),
+			fmt.Sprintf("%s / %s", ui.FormatBytes(int64(c.NetRx)), ui.FormatBytes(int64(c.NetTx))),
+			fmt.Sprintf("%s / %s", ui.FormatBytes(int64(c.BlockRead)), ui.FormatBytes(int64(c.BlockWrite))),
+			fmt.Sprintf("%d", c.PIDs),
+		})
+	}
+	return rows
+}
@@ -0,0 +1,118 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+// usageChartWidth is the most columns a usage chart takes; longer histories
+// are averaged into this many.
+const usageChartWidth = 60
+
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+func showUsageHistory(ctx context.Context, api *apiclient.APIClient, appName string, since time.Duration) error {
+	var response apitypes.AppUsageHistoryResponse
+	path := fmt.Sprintf("usage/%s?%s", appName, url.Values{"since": {since.String()}}.Encode())
+	if err := api.Get(ctx, path, &response); err != nil {
+		var httpErr *apiclient.HTTPError
+		if errors.As(err, &httpErr) && httpErr.Body == "404 page not found" {
+			return errors.New("the server doesn't record usage history, upgrade haloyd to use --history")
+		}
+		return fmt.Errorf("failed to get usage history: %w", err)
+	}
+
+	points := response.Points
+	if len(points) == 0 {
+		ui.Info("No usage recorded for %s in the last %s", appName, since)
+		return nil
+	}
+
+	first, last := points[0].Time.Local(), points[len(points)-1].Time.Local()
+	ui.Basic("%s  %s – %s", appName, first.Format(time.DateTime), last.Format(time.DateTime))
+
+	cpu := make([]float64, len(points))
+	memory := make([]float64, len(points))
+	var cpuMax float64
+	var memoryMax, memoryLimit int64
+	for i, p := range points {
+		cpu[i], memory[i] = p.CPUPercent, float64(p.MemoryAvg)
+		cpuMax = max(cpuMax, p.CPUMax)
+		memoryMax = max(memoryMax, p.MemoryMax)
+		memoryLimit = max(memoryLimit, p.MemoryLimit)
+	}
+	cpuLow, cpuAvg, _ := usageStats(cpu)
+	memoryLow, memoryAvg, _ := usageStats(memory)
+
+	memoryPeak := ui.FormatBytes(memoryMax)
+	if memoryLimit > 0 {
+		memoryPeak += fmt.Sprintf(" (%.0f%% of limit)", float64(memoryMax)/float64(memoryLimit)*100)
+	}
+	ui.Table([]string{"", "USAGE", "MIN", "AVG", "MAX"}, [][]string{
+		{"CPU", sparkline(cpu, usageChartWidth), fmt.Sprintf("%.1f%%", cpuLow), fmt.Sprintf("%.1f%%", cpuAvg), fmt.Sprintf("%.1f%%", cpuMax)},
+		{"MEM", sparkline(memory, usageChartWidth), ui.FormatBytes(int64(memoryLow)), ui.FormatBytes(int64(memoryAvg)), memoryPeak},
+	})
+
+	if growth := memoryGrowth(memory); growth >= 0.5 {
+		ui.Warn("Memory usage grew %.0f%% over this period without coming back down, which may be a leak", growth*100)
+	}
+	return nil
+}
+
+// usageStats returns the lowest, average and highest value.
+func usageStats(values []float64) (lo, avg, hi float64) {
+	lo, hi = values[0], values[0]
+	var total float64
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+		total += v
+	}
+	return lo, total / float64(len(values)), hi
+}
+
+// sparkline charts values in at most width columns, averaging neighbouring
+// values when there are more of them, scaled from zero to the highest value.
+func sparkline(values []float64, width int) string {
+	n := min(len(values), width)
+	columns := make([]float64, n)
+	for i := range n {
+		_, columns[i], _ = usageStats(values[i*len(values)/n : (i+1)*len(values)/n])
+	}
+
+	_, _, hi := usageStats(columns)
+	var b strings.Builder
+	for _, v := range columns {
+		level := 0
+		if hi > 0 {
+			level = int(v / hi * float64(len(sparkLevels)-1))
+		}
+		b.WriteRune(sparkLevels[max(level, 0)])
+	}
+	return b.String()
+}
+
+// memoryGrowth returns how much memory usage in the last quarter of the
+// history exceeds the first quarter, as a fraction of the first, when it
+// never dropped back to where it started. Steady growth like that points to
+// a leak rather than load.
+func memoryGrowth(memory []float64) float64 {
+	if len(memory) < 8 {
+		return 0
+	}
+	quarter := len(memory) / 4
+	_, start, _ := usageStats(memory[:quarter])
+	_, end, _ := usageStats(memory[len(memory)-quarter:])
+	lowAfterStart, _, _ := usageStats(memory[quarter:])
+	if start <= 0 || lowAfterStart < start {
+		return 0
+	}
+	return (end - start) / start
+}
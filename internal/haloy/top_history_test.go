@@ -0,0 +1,51 @@
+package haloy
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		width  int
+		want   string
+	}{
+		{"scaled to the highest value", []float64{0, 7, 14}, 10, "▁▄█"},
+		{"all zero", []float64{0, 0}, 10, "▁▁"},
+		{"averaged into width", []float64{0, 0, 8, 8}, 2, "▁█"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sparkline(tt.values, tt.width); got != tt.want {
+				t.Errorf("sparkline(%v, %d) = %q, want %q", tt.values, tt.width, got, tt.want)
+			}
+		})
+	}
+
+	long := make([]float64, 1000)
+	if got := utf8.RuneCountInString(sparkline(long, usageChartWidth)); got != usageChartWidth {
+		t.Errorf("sparkline of 1000 values is %d columns, want %d", got, usageChartWidth)
+	}
+}
+
+func TestMemoryGrowth(t *testing.T) {
+	tests := []struct {
+		name   string
+		memory []float64
+		leak   bool
+	}{
+		{"steady growth", []float64{100, 110, 120, 130, 140, 150, 160, 170}, true},
+		{"flat", []float64{100, 100, 100, 100, 100, 100, 100, 100}, false},
+		{"drops back after a spike", []float64{100, 100, 200, 50, 100, 150, 200, 200}, false},
+		{"too short", []float64{100, 300}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := memoryGrowth(tt.memory) >= 0.5; got != tt.leak {
+				t.Errorf("memoryGrowth(%v) = %v, want leak %v", tt.memory, memoryGrowth(tt.memory), tt.leak)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package haloy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+// imageTransfer is an image to get onto a server, for the targets deployed
+// from it there.
+type imageTransfer struct {
+	server   string
+	imageRef string
+	targets  []*config.TargetConfig
+	// present is set when the server already has the image.
+	present bool
+}
+
+// planImageUploads turns the targets each image is uploaded for into one
+// transfer per server and image, so an image deployed by several targets on
+// the same server is sent once. Transfers are sorted by server and image.
+func planImageUploads(uploads map[string][]*config.TargetConfig) []*imageTransfer {
+	type key struct{ server, imageRef string }
+	byKey := make(map[key]*imageTransfer)
+	var transfers []*imageTransfer
+	for imageRef, targets := range uploads {
+		for _, target := range targets {
+			server, err := helpers.NormalizeServerURL(target.Server)
+			if err != nil {
+				server = target.Server
+			}
+			k := key{server, imageRef}
+			transfer, ok := byKey[k]
+			if !ok {
+				transfer = &imageTransfer{server: server, imageRef: imageRef}
+				byKey[k] = transfer
+				transfers = append(transfers, transfer)
+			}
+			transfer.targets = append(transfer.targets, target)
+		}
+	}
+
+	slices.SortFunc(transfers, func(a, b *imageTransfer) int {
+		return cmp.Or(cmp.Compare(a.server, b.server), cmp.Compare(a.imageRef, b.imageRef))
+	})
+	for _, transfer := range transfers {
+		slices.SortFunc(transfer.targets, func(a, b *config.TargetConfig) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+	}
+	return transfers
+}
+
+// UploadImages uploads the built images to the servers of the targets
+// deployed from them. Each image is sent to a server once however many
+// targets use it there, and not at all when the server already has it.
+// The transfers are shown before the first one starts. Uploads to each
+// server are limited to limitRate bytes per second, unless it's 0.
+func UploadImages(ctx context.Context, uploads map[string][]*config.TargetConfig, limitRate int64) error {
+	transfers := planImageUploads(uploads)
+	if len(transfers) == 0 {
+		return nil
+	}
+
+	type serverClient struct {
+		api          *apiclient.APIClient
+		capabilities map[string]struct{}
+	}
+	clients := make(map[string]serverClient)
+	for _, transfer := range transfers {
+		if _, ok := clients[transfer.server]; ok {
+			continue
+		}
+		target := transfer.targets[0]
+		token, err := getToken(target, target.Server)
+		if err != nil {
+			return fmt.Errorf("failed to get authentication token: %w", err)
+		}
+		api, err := apiclient.NewWithTimeout(target.Server, token, 5*time.Minute)
+		if err != nil {
+			return fmt.Errorf("failed to create API client: %w", err)
+		}
+		api.SetUploadRateLimit(limitRate)
+		clients[transfer.server] = serverClient{api: api, capabilities: getServerCapabilities(ctx, api)}
+	}
+
+	imageIDs := make(map[string]string)
+	for server, client := range clients {
+		if !hasCapability(client.capabilities, constants.CapabilityImageCheck) {
+			continue
+		}
+		var serverTransfers []*imageTransfer
+		for _, transfer := range transfers {
+			if transfer.server == server {
+				serverTransfers = append(serverTransfers, transfer)
+			}
+		}
+		if err := checkImagesPresent(ctx, client.api, serverTransfers, imageIDs); err != nil {
+			ui.Warn("Could not check which images %s already has, uploading all of them: %v", server, err)
+		}
+	}
+
+	printTransferPlan(transfers)
+
+	archives := make(map[string]imageArchive)
+	for _, transfer := range transfers {
+		if transfer.present {
+			continue
+		}
+		archive, ok := archives[transfer.imageRef]
+		if !ok {
+			var err error
+			archive, err = scanImage(ctx, transfer.imageRef)
+			if err != nil {
+				return fmt.Errorf("failed to export image: %w", err)
+			}
+			archives[transfer.imageRef] = archive
+		}
+
+		client := clients[transfer.server]
+		ui.Info("Pushing image %s to %s", transfer.imageRef, transfer.server)
+		if err := uploadImage(ctx, client.api, client.capabilities, transfer.imageRef, archive); err != nil {
+			return withImagePruneHint(err, *transfer.targets[0])
+		}
+	}
+
+	return nil
+}
+
+// checkImagesPresent asks the server which of the images of transfers it
+// already has, comparing image IDs, and marks those transfers present.
+// imageIDs caches the IDs of the local images by ref.
+func checkImagesPresent(ctx context.Context, api *apiclient.APIClient, transfers []*imageTransfer, imageIDs map[string]string) error {
+	req := apitypes.ImageCheckRequest{Images: make([]apitypes.ImageCheckEntry, 0, len(transfers))}
+	for _, transfer := range transfers {
+		imageID, ok := imageIDs[transfer.imageRef]
+		if !ok {
+			id, _, err := inspectLocalImage(ctx, transfer.imageRef)
+			if err != nil {
+				return err
+			}
+			imageID = id
+			imageIDs[transfer.imageRef] = imageID
+		}
+		req.Images = append(req.Images, apitypes.ImageCheckEntry{ImageRef: transfer.imageRef, ImageID: imageID})
+	}
+
+	var resp apitypes.ImageCheckResponse
+	if err := api.Post(ctx, "images/check", req, &resp); err != nil {
+		return err
+	}
+	for _, transfer := range transfers {
+		transfer.present = slices.Contains(resp.Present, transfer.imageRef)
+	}
+	return nil
+}
+
+// printTransferPlan shows what UploadImages is about to send where.
+func printTransferPlan(transfers []*imageTransfer) {
+	rows := make([][]string, 0, len(transfers))
+	uploading := 0
+	for _, transfer := range transfers {
+		names := make([]string, 0, len(transfer.targets))
+		for _, target := range transfer.targets {
+			names = append(names, target.Name)
+		}
+		action := "upload"
+		if transfer.present {
+			action = "skip, already on server"
+		} else {
+			uploading++
+		}
+		rows = append(rows, []string{transfer.server, transfer.imageRef, strings.Join(names, ", "), action})
+	}
+
+	ui.Info("Image transfer plan: %d of %d to upload", uploading, len(transfers))
+	ui.Table([]string{"SERVER", "IMAGE", "TARGETS", "ACTION"}, rows)
+}
@@ -0,0 +1,178 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func UptimeCmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	var days int
+
+	cmd := &cobra.Command{
+		Use:   "uptime [target]",
+		Short: "Show an app's uptime and error budget",
+		Long: `Show the uptime haloyd recorded for an app and how much of its error budget
+is left. Two things are measured, each against the server's objective (99.9%
+unless uptime.objective is set in haloyd.yaml):
+
+  uptime   the share of minutes the app had at least one healthy container
+  success  the share of requests haloy-proxy served without an error
+
+The worse of the two uses up the error budget. Totals are shown for today,
+the last 7 and the last 30 days, followed by each of the last --days days.`,
+		Example: `  haloy uptime
+  haloy uptime production --days 30`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if len(args) == 1 {
+				if flags.all {
+					return errors.New("cannot specify both a target argument and --all")
+				}
+				flags.targets = append(flags.targets, args[0])
+			}
+			if days < 1 || days > 90 {
+				return errors.New("--days must be between 1 and 90")
+			}
+
+			rawDeployConfig, format, err := configloader.Load(ctx, *configPath, flags.targets, flags.all)
+			if err != nil {
+				return fmt.Errorf("unable to load config: %w", err)
+			}
+
+			resolvedDeployConfig, err := configloader.ResolveSecrets(ctx, rawDeployConfig, *configPath)
+			if err != nil {
+				return fmt.Errorf("failed to resolve secrets: %w", err)
+			}
+
+			targets, err := configloader.ExtractTargets(resolvedDeployConfig, format)
+			if err != nil {
+				return err
+			}
+
+			var errs []error
+			for _, targetName := range slices.Sorted(maps.Keys(targets)) {
+				target := targets[targetName]
+				prefix := ""
+				if len(targets) > 1 {
+					prefix = targetName
+				}
+				response, err := getUptime(ctx, target, days)
+				if err != nil {
+					errs = append(errs, &PrefixedError{Err: err, Prefix: prefix})
+					continue
+				}
+				printUptime(target, response, prefix)
+			}
+			return errors.Join(errs...)
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Show uptime of specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Show uptime of all targets")
+	cmd.Flags().IntVar(&days, "days", 7, "Number of days to list (1-90)")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+
+	return cmd
+}
+
+func getUptime(ctx context.Context, target config.TargetConfig, days int) (apitypes.AppUptimeResponse, error) {
+	var response apitypes.AppUptimeResponse
+
+	token, err := getToken(&target, target.Server)
+	if err != nil {
+		return response, fmt.Errorf("unable to get token: %w", err)
+	}
+	api, err := apiclient.New(target.Server, token)
+	if err != nil {
+		return response, fmt.Errorf("unable to create API client: %w", err)
+	}
+
+	if err := api.Get(ctx, fmt.Sprintf("uptime/%s?days=%d", target.Name, days), &response); err != nil {
+		var httpErr *apiclient.HTTPError
+		if errors.As(err, &httpErr) {
+			switch {
+			case httpErr.Body == "404 page not found":
+				return response, errors.New("the server doesn't track uptime, upgrade haloyd to use this command")
+			case httpErr.StatusCode == http.StatusServiceUnavailable:
+				return response, errors.New("uptime tracking is disabled on the server, see uptime in haloyd.yaml")
+			}
+		}
+		return response, fmt.Errorf("failed to get uptime: %w", err)
+	}
+	return response, nil
+}
+
+func printUptime(target config.TargetConfig, response apitypes.AppUptimeResponse, prefix string) {
+	pui := &ui.PrefixedUI{Prefix: prefix}
+	pui.Info("Uptime of %s on %s, objective %s", target.Name, target.Server, formatPercent(response.Objective))
+
+	windowRows := make([][]string, 0, len(response.Windows))
+	for _, w := range response.Windows {
+		name := fmt.Sprintf("%d days", w.Days)
+		if w.Days == 1 {
+			name = "today"
+		}
+		windowRows = append(windowRows, []string{
+			name,
+			formatPercent(w.Uptime),
+			formatDowntime(w.DownMinutes),
+			fmt.Sprintf("%d", w.Requests),
+			formatPercent(w.SuccessRate),
+			fmt.Sprintf("%.1f%%", w.BudgetRemaining),
+		})
+	}
+	ui.Table([]string{"WINDOW", "UPTIME", "DOWNTIME", "REQUESTS", "SUCCESS", "BUDGET LEFT"}, windowRows)
+
+	if len(response.Days) > 0 {
+		dayRows := make([][]string, 0, len(response.Days))
+		for _, d := range response.Days {
+			dayRows = append(dayRows, []string{
+				d.Date,
+				formatPercent(d.Uptime),
+				formatDowntime(d.DownMinutes),
+				fmt.Sprintf("%d", d.Requests),
+				fmt.Sprintf("%d", d.Errors),
+				formatPercent(d.SuccessRate),
+			})
+		}
+		ui.Table([]string{"DATE", "UPTIME", "DOWNTIME", "REQUESTS", "ERRORS", "SUCCESS"}, dayRows)
+	}
+
+	for _, w := range response.Windows {
+		if w.BudgetRemaining < 0 {
+			pui.Warn("The error budget of the last %d days is used up", w.Days)
+			break
+		}
+	}
+}
+
+// formatPercent shows enough decimals to tell nines apart.
+func formatPercent(p float64) string {
+	if p == 100 {
+		return "100%"
+	}
+	return fmt.Sprintf("%.3f%%", p)
+}
+
+func formatDowntime(minutes int64) string {
+	if minutes == 0 {
+		return "-"
+	}
+	return (time.Duration(minutes) * time.Minute).String()
+}
@@ -1,8 +1,18 @@
 package haloy
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/github"
 	"github.com/haloydev/haloy/internal/helpers"
@@ -10,13 +20,23 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// versionCheckTimeout bounds asking one server for its version.
+const versionCheckTimeout = 10 * time.Second
+
 func VersionCmd() *cobra.Command {
 	var check bool
 
 	cmd := &cobra.Command{
 		Use:   "version",
 		Short: "Show the current version",
-		Args:  cobra.NoArgs,
+		Long: `Show the version of haloy.
+
+With --check, also look up the latest release and ask every server added with
+'haloy server add' for its haloyd version and capabilities. Servers missing
+capabilities this CLI uses, or offering ones it doesn't know, are flagged as
+incompatible with the command that upgrades the side that is behind, and the
+command fails so scripts can catch a mismatch before deploying.`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			if !check {
 				fmt.Println(constants.Version)
@@ -29,25 +49,207 @@ func VersionCmd() *cobra.Command {
 			ui.Info("Checking for updates...")
 			latestVersion, err := github.FetchLatestVersion(cmd.Context())
 			if err != nil {
-				return fmt.Errorf("failed to check for updates: %w", err)
-			}
-
-			ui.Info("Latest version: %s", latestVersion)
+				// The servers are still worth checking without it.
+				ui.Warn("Failed to check for updates: %v", err)
+			} else {
+				ui.Info("Latest version: %s", latestVersion)
 
-			normalizedCurrent := helpers.NormalizeVersion(currentVersion)
-			normalizedLatest := helpers.NormalizeVersion(latestVersion)
+				normalizedCurrent := helpers.NormalizeVersion(currentVersion)
+				normalizedLatest := helpers.NormalizeVersion(latestVersion)
 
-			if normalizedCurrent == normalizedLatest {
-				ui.Success("You are running the latest version!")
-			} else {
-				ui.Info("Update available: %s -> %s", currentVersion, latestVersion)
+				if normalizedCurrent == normalizedLatest {
+					ui.Success("You are running the latest version!")
+				} else {
+					ui.Info("Update available: %s -> %s", currentVersion, latestVersion)
+				}
 			}
 
-			return nil
+			clientConfig, err := config.LoadDefaultClientConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load client config: %w", err)
+			}
+			if clientConfig == nil || len(clientConfig.Servers) == 0 {
+				return nil
+			}
+			return checkServerVersions(cmd.Context(), clientConfig.ListServers())
 		},
 	}
 
-	cmd.Flags().BoolVar(&check, "check", false, "Check if a newer version is available")
+	cmd.Flags().BoolVar(&check, "check", false, "Check for a newer version and compare with the configured servers")
 
 	return cmd
 }
+
+// serverVersionCheck is how a server compares with this CLI.
+type serverVersionCheck struct {
+	server  string
+	version string
+	err     error
+	// serverMissing are capabilities of this CLI the server lacks, and
+	// cliMissing those of the server this CLI lacks.
+	serverMissing []string
+	cliMissing    []string
+	// negotiates is false for servers that predate advertising their
+	// capabilities, which are too old to tell what they lack.
+	negotiates bool
+}
+
+func (c serverVersionCheck) upgradeServer() bool {
+	return c.err == nil && (len(c.serverMissing) > 0 || !c.negotiates)
+}
+
+func (c serverVersionCheck) upgradeCLI() bool {
+	return c.err == nil && len(c.cliMissing) > 0
+}
+
+func (c serverVersionCheck) status() string {
+	switch {
+	case c.err != nil:
+		return "unreachable: " + c.err.Error()
+	case c.upgradeServer() && c.upgradeCLI():
+		return "incompatible, upgrade both"
+	case !c.negotiates:
+		return "incompatible, haloyd predates capability checks"
+	case c.upgradeServer():
+		return "incompatible, haloyd lacks " + strings.Join(c.serverMissing, ", ")
+	case c.upgradeCLI():
+		return "incompatible, haloy lacks " + strings.Join(c.cliMissing, ", ")
+	case helpers.NormalizeVersion(c.version) != helpers.NormalizeVersion(constants.Version):
+		return "compatible, versions differ"
+	}
+	return "compatible"
+}
+
+// compareServerVersion diffs a server's capabilities with those this CLI was
+// built with.
+func compareServerVersion(server string, version apitypes.VersionResponse) serverVersionCheck {
+	check := serverVersionCheck{
+		server:     server,
+		version:    version.Version,
+		negotiates: slices.Contains(version.Capabilities, constants.CapabilityFeatureNegotiation),
+	}
+	for _, capability := range constants.Capabilities {
+		if !slices.Contains(version.Capabilities, capability) {
+			check.serverMissing = append(check.serverMissing, capability)
+		}
+	}
+	for _, capability := range version.Capabilities {
+		if !slices.Contains(constants.Capabilities, capability) {
+			check.cliMissing = append(check.cliMissing, capability)
+		}
+	}
+	return check
+}
+
+// checkServerVersions prints how each server compares with this CLI and how
+// to upgrade the side that is behind. It fails when a server is
+// incompatible.
+func checkServerVersions(ctx context.Context, servers []string) error {
+	ui.Info("Checking %d servers...", len(servers))
+
+	checks := make([]serverVersionCheck, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(ctx, versionCheckTimeout)
+			defer cancel()
+			version, err := fetchVersion(ctx, server)
+			if err != nil {
+				checks[i] = serverVersionCheck{server: server, err: err}
+				return
+			}
+			checks[i] = compareServerVersion(server, *version)
+		})
+	}
+	wg.Wait()
+
+	rows := make([][]string, 0, len(checks))
+	var incompatible, olderServers []string
+	var olderCLI bool
+	for _, c := range checks {
+		version := c.version
+		if c.err != nil {
+			version = "-"
+		}
+		rows = append(rows, []string{c.server, constants.Version, version, c.status()})
+
+		if c.upgradeServer() || c.upgradeCLI() {
+			incompatible = append(incompatible, c.server)
+		}
+		if c.upgradeServer() {
+			olderServers = append(olderServers, c.server)
+		}
+		olderCLI = olderCLI || c.upgradeCLI()
+
+		// Compatible servers on another release are only worth a hint.
+		if c.err == nil && !c.upgradeServer() && !c.upgradeCLI() {
+			if cmp, ok := helpers.CompareVersions(c.version, constants.Version); ok && cmp < 0 {
+				ui.Info("%s runs an older haloyd; upgrade it with: haloy server upgrade --now --server %s", c.server, c.server)
+			} else if ok && cmp > 0 {
+				ui.Info("%s runs a newer haloyd; upgrade haloy with: %s", c.server, cliUpgradeCommand())
+			}
+		}
+	}
+	ui.Table([]string{"SERVER", "HALOY", "HALOYD", "STATUS"}, rows)
+
+	for _, server := range olderServers {
+		ui.Warn("Upgrade haloyd on %s: haloy server upgrade --now --server %s", server, server)
+	}
+	if olderCLI {
+		ui.Warn("Upgrade haloy: %s", cliUpgradeCommand())
+	}
+	if len(incompatible) > 0 {
+		return fmt.Errorf("%d of %d servers are incompatible with haloy %s: %s",
+			len(incompatible), len(checks), constants.Version, strings.Join(incompatible, ", "))
+	}
+	return nil
+}
+
+// fetchVersion asks a server from the client config for its version.
+func fetchVersion(ctx context.Context, server string) (*apitypes.VersionResponse, error) {
+	token, err := getToken(nil, server)
+	if err != nil {
+		return nil, err
+	}
+	api, err := apiclient.New(server, token)
+	if err != nil {
+		return nil, err
+	}
+	var version apitypes.VersionResponse
+	if err := api.Get(ctx, "version", &version); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// cliUpgradeCommand returns the command that upgrades this haloy the way it
+// was installed, told from where its executable is.
+func cliUpgradeCommand() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return upgradeCommandFor("", "")
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			gopath = filepath.Join(home, "go")
+		}
+	}
+	return upgradeCommandFor(exe, gopath)
+}
+
+func upgradeCommandFor(exe, gopath string) string {
+	slashed := filepath.ToSlash(exe)
+	switch {
+	case strings.Contains(slashed, "/Cellar/") || strings.Contains(slashed, "/homebrew/") || strings.Contains(slashed, "/linuxbrew/"):
+		return "brew upgrade haloydev/tap/haloy"
+	case strings.Contains(slashed, "/node_modules/"):
+		return "npm i -g haloy@latest"
+	case gopath != "" && filepath.Dir(exe) == filepath.Join(gopath, "bin"):
+		return "go install github.com/haloydev/haloy/cmd/haloy@latest"
+	}
+	return "curl -fsSL https://sh.haloy.dev/install-haloy.sh | sh"
+}
@@ -0,0 +1,64 @@
+package haloy
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+func TestCompareServerVersion(t *testing.T) {
+	without := func(capability string) []string {
+		return slices.DeleteFunc(slices.Clone(constants.Capabilities), func(c string) bool { return c == capability })
+	}
+
+	tests := []struct {
+		name          string
+		capabilities  []string
+		upgradeServer bool
+		upgradeCLI    bool
+		status        string
+	}{
+		{"same capabilities", constants.Capabilities, false, false, "compatible"},
+		{"server lacks one", without(constants.CapabilitySSO), true, false, "haloyd lacks " + constants.CapabilitySSO},
+		{"server has a new one", append(slices.Clone(constants.Capabilities), "future"), false, true, "haloy lacks future"},
+		{"server predates negotiation", nil, true, false, "predates capability checks"},
+		{"both sides behind", append(without(constants.CapabilitySSO), "future"), true, true, "upgrade both"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := compareServerVersion("https://example.com", apitypes.VersionResponse{
+				Version:      constants.Version,
+				Capabilities: tt.capabilities,
+			})
+			if check.upgradeServer() != tt.upgradeServer || check.upgradeCLI() != tt.upgradeCLI {
+				t.Errorf("upgradeServer() = %v, upgradeCLI() = %v, want %v, %v",
+					check.upgradeServer(), check.upgradeCLI(), tt.upgradeServer, tt.upgradeCLI)
+			}
+			if status := check.status(); !strings.Contains(status, tt.status) {
+				t.Errorf("status() = %q, want it to contain %q", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestUpgradeCommandFor(t *testing.T) {
+	tests := []struct {
+		exe  string
+		want string
+	}{
+		{"/opt/homebrew/Cellar/haloy/0.1.0/bin/haloy", "brew upgrade"},
+		{"/home/linuxbrew/.linuxbrew/bin/haloy", "brew upgrade"},
+		{"/usr/lib/node_modules/haloy/bin/haloy", "npm i -g"},
+		{"/home/dev/go/bin/haloy", "go install"},
+		{"/usr/local/bin/haloy", "install-haloy.sh"},
+		{"", "install-haloy.sh"},
+	}
+	for _, tt := range tests {
+		if got := upgradeCommandFor(tt.exe, "/home/dev/go"); !strings.Contains(got, tt.want) {
+			t.Errorf("upgradeCommandFor(%q) = %q, want it to contain %q", tt.exe, got, tt.want)
+		}
+	}
+}
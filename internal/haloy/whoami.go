@@ -0,0 +1,127 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// whoAmI is what a server says about the credentials haloy uses for it.
+type whoAmI struct {
+	server   string
+	source   string
+	response apitypes.WhoAmIResponse
+}
+
+func WhoAmICmd(configPath *string, flags *appCmdFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "whoami [server]",
+		Short: "Show which credentials are used for a server",
+		Long: `Show the credentials haloy uses for a server: where the token was found,
+what the server accepts it as, its scopes and when it expires. Run it before
+destructive commands, or in CI, to check the right credentials are in effect.
+
+Without a server, the servers of the targets in the deploy config are shown.
+The server can be a URL or a profile name.
+
+Examples:
+  haloy whoami
+  haloy whoami prod
+  haloy whoami --all`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) == 1 {
+				server := resolveServerRef(args[0])
+				result, err := getWhoAmI(ctx, nil, server, "")
+				if err != nil {
+					return err
+				}
+				printWhoAmI([]whoAmI{result})
+				return nil
+			}
+
+			servers, err := resolveServerTargets(ctx, cmd, *configPath, flags)
+			if err != nil {
+				return err
+			}
+
+			results := make([]whoAmI, len(servers))
+			g, ctx := errgroup.WithContext(ctx)
+			for i, serverTarget := range servers {
+				g.Go(func() error {
+					prefix := ""
+					if len(servers) > 1 {
+						prefix = serverTarget.Server
+					}
+					result, err := getWhoAmI(ctx, serverTarget.TargetConfig, serverTarget.Server, prefix)
+					if err != nil {
+						return err
+					}
+					results[i] = result
+					return nil
+				})
+			}
+			if err := g.Wait(); err != nil {
+				return err
+			}
+			printWhoAmI(results)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.configPath, "config", "c", "", "Path to config file or directory (default: .)")
+	cmd.Flags().StringSliceVarP(&flags.targets, "targets", "t", nil, "Show credentials for specific targets (comma-separated)")
+	cmd.Flags().BoolVarP(&flags.all, "all", "a", false, "Show credentials for all targets")
+
+	cmd.RegisterFlagCompletionFunc("targets", completeTargetNames)
+	cmd.ValidArgsFunction = completeProfileNames
+
+	return cmd
+}
+
+func getWhoAmI(ctx context.Context, targetConfig *config.TargetConfig, targetServer, prefix string) (whoAmI, error) {
+	token, source, err := getTokenWithSource(targetConfig, targetServer)
+	if err != nil {
+		return whoAmI{}, &PrefixedError{Err: fmt.Errorf("unable to get token: %w", err), Prefix: prefix}
+	}
+
+	api, err := apiclient.New(targetServer, token)
+	if err != nil {
+		return whoAmI{}, &PrefixedError{Err: fmt.Errorf("unable to create API client: %w", err), Prefix: prefix}
+	}
+
+	result := whoAmI{server: targetServer, source: source}
+	if err := api.Get(ctx, "auth/whoami", &result.response); err != nil {
+		if errors.Is(err, apiclient.ErrNotFound) {
+			err = errors.New("the server doesn't support whoami, upgrade haloyd to use this command")
+		}
+		return whoAmI{}, &PrefixedError{Err: fmt.Errorf("failed to check credentials with the API (token from %s): %w", source, err), Prefix: prefix}
+	}
+	return result, nil
+}
+
+func printWhoAmI(results []whoAmI) {
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		scopes := strings.Join(r.response.Scopes, ", ")
+		if len(r.response.Scopes) == 1 && r.response.Scopes[0] == apitypes.ScopeAll {
+			scopes = "all"
+		}
+		expires := "never"
+		if r.response.ExpiresAt != nil {
+			expires = helpers.FormatTime(*r.response.ExpiresAt)
+		}
+		rows = append(rows, []string{r.server, r.response.Type, r.source, scopes, expires})
+	}
+	ui.Table([]string{"SERVER", "CREDENTIAL", "TOKEN FROM", "SCOPES", "EXPIRES"}, rows)
+}
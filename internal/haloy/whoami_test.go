@@ -0,0 +1,48 @@
+package haloy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+func TestGetWhoAmI_ReportsTokenSource(t *testing.T) {
+	t.Setenv(constants.EnvVarConfigDir, t.TempDir())
+	t.Setenv(constants.EnvVarAPIToken, "env-token")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		if r.URL.Path != "/v1/auth/whoami" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer env-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(apitypes.WhoAmIResponse{Type: apitypes.CredentialAPIToken, Scopes: []string{apitypes.ScopeAll}})
+	}))
+	defer srv.Close()
+
+	result, err := getWhoAmI(context.Background(), nil, srv.URL, "")
+	if err != nil {
+		t.Fatalf("getWhoAmI() unexpected error: %v", err)
+	}
+	if result.source != constants.EnvVarAPIToken+" environment variable" || result.response.Type != apitypes.CredentialAPIToken {
+		t.Errorf("getWhoAmI() = %+v, want the API token from %s", result, constants.EnvVarAPIToken)
+	}
+
+	target := targetWithServer(srv.URL)
+	_, err = getWhoAmI(context.Background(), &target, srv.URL, "")
+	if err == nil || !strings.Contains(err.Error(), "api_token in the deploy config") {
+		t.Errorf("getWhoAmI() with a rejected token error = %v, want it to name where the token came from", err)
+	}
+}
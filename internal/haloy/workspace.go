@@ -0,0 +1,172 @@
+package haloy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/ui"
+)
+
+// workspaceApp is a config found by 'haloy deploy --workspace'.
+type workspaceApp struct {
+	configFile string
+	// dir is the config's directory relative to the workspace root, with
+	// forward slashes.
+	dir         string
+	name        string
+	targets     []string
+	multiTarget bool
+}
+
+// deployWorkspace deploys every app in the workspace at root, one after the
+// other. A failing app doesn't stop the others; all failures are returned.
+func deployWorkspace(ctx context.Context, root string, flags *appCmdFlags, changedSince string, opts deployOptions) error {
+	if len(flags.targets) > 0 {
+		return errors.New("--targets can't be used with --workspace, every target of each app is deployed")
+	}
+	if opts.fromArtifacts != "" {
+		return errors.New("--from-artifacts can't be used with --workspace")
+	}
+
+	apps, err := findWorkspaceApps(root)
+	if err != nil {
+		return err
+	}
+	if len(apps) == 0 {
+		return fmt.Errorf("no haloy config files found in %s", root)
+	}
+
+	if changedSince != "" {
+		changed, err := changedFiles(ctx, root, changedSince)
+		if err != nil {
+			return err
+		}
+		apps = filterChangedApps(apps, workspaceRelPaths(root, changed))
+		if len(apps) == 0 {
+			ui.Info("No apps changed since %s", changedSince)
+			return nil
+		}
+	}
+
+	rows := make([][]string, 0, len(apps))
+	for _, app := range apps {
+		rows = append(rows, []string{app.dir, app.name, strings.Join(app.targets, ", ")})
+	}
+	ui.Info("Deploying %d app(s):", len(apps))
+	ui.Table([]string{"DIRECTORY", "APP", "TARGETS"}, rows)
+
+	var errs []error
+	for _, app := range apps {
+		appFlags := *flags
+		appFlags.all = app.multiTarget
+
+		config.LoadEnvFilesFromDir(filepath.Dir(app.configFile))
+		ui.Info("Deploying %s (%s)", app.name, app.dir)
+		if err := deployApp(ctx, app.configFile, &appFlags, opts); err != nil {
+			ui.Error("Deploying %s failed: %v", app.name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", app.dir, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d apps failed to deploy: %w", len(errs), len(apps), errors.Join(errs...))
+	}
+	return nil
+}
+
+func findWorkspaceApps(root string) ([]workspaceApp, error) {
+	configFiles, err := configloader.FindWorkspaceConfigs(root)
+	if err != nil {
+		return nil, err
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	apps := make([]workspaceApp, 0, len(configFiles))
+	for _, configFile := range configFiles {
+		rawDeployConfig, _, err := configloader.LoadRawDeployConfig(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", configFile, err)
+		}
+		rel, err := filepath.Rel(absRoot, filepath.Dir(configFile))
+		if err != nil {
+			return nil, err
+		}
+		app := workspaceApp{
+			configFile: configFile,
+			dir:        filepath.ToSlash(rel),
+			name:       rawDeployConfig.Name,
+		}
+		if len(rawDeployConfig.Targets) > 0 {
+			for name := range rawDeployConfig.Targets {
+				app.targets = append(app.targets, name)
+			}
+			slices.Sort(app.targets)
+			app.multiTarget = true
+		} else {
+			app.targets = []string{rawDeployConfig.Name}
+		}
+		if app.name == "" {
+			app.name = filepath.Base(filepath.Dir(configFile))
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// workspaceRelPaths returns the paths below root relative to it, with
+// forward slashes like workspaceApp.dir.
+func workspaceRelPaths(root string, paths []string) []string {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil
+	}
+	absRoot = evalSymlinks(absRoot)
+	var rel []string
+	for _, p := range paths {
+		r, err := filepath.Rel(absRoot, p)
+		if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	return rel
+}
+
+// filterChangedApps keeps the apps with a changed file in their directory or
+// below it. Apps in nested directories don't count as changes of the app
+// above them.
+func filterChangedApps(apps []workspaceApp, changed []string) []workspaceApp {
+	var filtered []workspaceApp
+	for _, app := range apps {
+		if slices.ContainsFunc(changed, func(file string) bool { return owningApp(apps, file) == app.dir }) {
+			filtered = append(filtered, app)
+		}
+	}
+	return filtered
+}
+
+// owningApp returns the directory of the app closest to file.
+func owningApp(apps []workspaceApp, file string) string {
+	owner, ownerDepth := "", -1
+	for _, app := range apps {
+		depth := 0
+		if app.dir != "." {
+			if file != app.dir && !strings.HasPrefix(file, app.dir+"/") {
+				continue
+			}
+			depth = len(app.dir)
+		}
+		if depth > ownerDepth {
+			owner, ownerDepth = app.dir, depth
+		}
+	}
+	return owner
+}
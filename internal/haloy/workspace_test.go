@@ -0,0 +1,67 @@
+package haloy
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFindWorkspaceApps(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"api/haloy.yaml": "name: api\nserver: haloy.example.com\nimage:\n  repository: acme/api\n",
+		"web/haloy.yaml": "server: haloy.example.com\nimage:\n  repository: acme/web\ntargets:\n  staging:\n    name: web-staging\n  prod:\n    name: web\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apps, err := findWorkspaceApps(root)
+	if err != nil {
+		t.Fatalf("findWorkspaceApps() unexpected error: %v", err)
+	}
+	if len(apps) != 2 {
+		t.Fatalf("expected 2 apps, got %d", len(apps))
+	}
+	if apps[0].dir != "api" || apps[0].name != "api" || apps[0].multiTarget {
+		t.Errorf("unexpected api app: %+v", apps[0])
+	}
+	if apps[1].dir != "web" || apps[1].name != "web" || !apps[1].multiTarget || len(apps[1].targets) != 2 || apps[1].targets[0] != "prod" {
+		t.Errorf("unexpected web app: %+v", apps[1])
+	}
+}
+
+func TestFilterChangedApps(t *testing.T) {
+	apps := []workspaceApp{{dir: "."}, {dir: "services/api"}, {dir: "services/api/worker"}, {dir: "services/web"}}
+
+	tests := []struct {
+		name    string
+		changed []string
+		want    []string
+	}{
+		{"no changes", nil, nil},
+		{"app file", []string{"services/api/main.go"}, []string{"services/api"}},
+		{"nested app only", []string{"services/api/worker/main.go"}, []string{"services/api/worker"}},
+		{"root file", []string{"README.md"}, []string{"."}},
+		{"prefix is not a parent", []string{"services/api2/main.go"}, []string{"."}},
+		{"several", []string{"services/web/index.html", "services/api/haloy.yaml"}, []string{"services/api", "services/web"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, app := range filterChangedApps(apps, tt.changed) {
+				got = append(got, app.dir)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("filterChangedApps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package haloyd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+const (
+	alertEvalInterval = 15 * time.Second
+	// alertSampleRequests is how many failed requests an alert includes.
+	alertSampleRequests = 5
+	alertNotifyTimeout  = 10 * time.Second
+)
+
+// trafficSample is the proxy's backend counters at one point in time.
+type trafficSample struct {
+	at       time.Time
+	backends map[string]proxywire.BackendStatus
+}
+
+// appTraffic is what an app's requests over a rule's window measured.
+type appTraffic struct {
+	requests   int64
+	errors     int64
+	buckets    []uint64
+	backends   []apitypes.TrafficAlertBackend
+	failures   []apitypes.TrafficAlertRequest
+	errorRate  float64 // percent
+	p95Latency time.Duration
+	p95Over    bool // above every latency bucket
+}
+
+// alertEngine checks the traffic haloy-proxy sends each app against the
+// configured rules. It samples the proxy's cumulative backend counters and
+// measures a rule over the difference between the latest sample and the one
+// a window earlier, so no request data has to be kept in haloyd.
+type alertEngine struct {
+	config      config.AlertsConfig
+	proxyStatus func(context.Context) (*proxywire.Status, error)
+	// routes returns the routes haloyd pushes, which map backends to apps.
+	routes func() *proxywire.Snapshot
+	logger *slog.Logger
+	notify func(ctx context.Context, url string, body []byte) error
+
+	samples   []trafficSample
+	maxWindow time.Duration
+	// firing holds the rule and app pairs that are alerting, as "rule/app".
+	firing map[string]bool
+}
+
+func newAlertEngine(cfg config.AlertsConfig, proxyStatus func(context.Context) (*proxywire.Status, error), routes func() *proxywire.Snapshot, logger *slog.Logger) *alertEngine {
+	e := &alertEngine{
+		config:      cfg,
+		proxyStatus: proxyStatus,
+		routes:      routes,
+		logger:      logger,
+		notify:      postJSONNotification,
+		firing:      make(map[string]bool),
+	}
+	for _, rule := range cfg.Rules {
+		e.maxWindow = max(e.maxWindow, rule.GetWindow())
+	}
+	return e
+}
+
+// Run evaluates the rules every alertEvalInterval until ctx is done.
+func (e *alertEngine) Run(ctx context.Context) {
+	ticker := time.NewTicker(alertEvalInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		statusCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		status, err := e.proxyStatus(statusCtx)
+		cancel()
+		if err != nil {
+			e.logger.Debug("Skipping alert evaluation", "error", err)
+			continue
+		}
+		e.evaluate(ctx, time.Now(), status.Backends)
+	}
+}
+
+func (e *alertEngine) evaluate(ctx context.Context, now time.Time, backends []proxywire.BackendStatus) {
+	sample := trafficSample{at: now, backends: make(map[string]proxywire.BackendStatus, len(backends))}
+	for _, b := range backends {
+		sample.backends[b.Address] = b
+	}
+	e.samples = append(e.samples, sample)
+	// Keep the newest sample at least maxWindow old as the base of the
+	// longest window.
+	for len(e.samples) > 1 && now.Sub(e.samples[1].at) >= e.maxWindow {
+		e.samples = e.samples[1:]
+	}
+
+	addrApps := make(map[string]string)
+	if snapshot := e.routes(); snapshot != nil {
+		for _, route := range snapshot.Routes {
+			for _, b := range route.Backends {
+				if route.App != "" && b.Peer == "" {
+					addrApps[b.Addr()] = route.App
+				}
+			}
+		}
+	}
+	apps := slices.Sorted(maps.Values(addrApps))
+	apps = slices.Compact(apps)
+
+	for _, rule := range e.config.Rules {
+		base, ok := e.baseSample(now, rule.GetWindow())
+		if !ok {
+			continue
+		}
+		for _, appName := range apps {
+			if !rule.AppliesTo(appName) {
+				continue
+			}
+			traffic := measureTraffic(base, sample, addrApps, appName)
+			broken, measured := ruleBroken(rule, traffic)
+			if !measured {
+				// Too few requests to say; an alert stays as it is.
+				continue
+			}
+			e.transition(ctx, now, rule, appName, traffic, broken)
+		}
+	}
+}
+
+// baseSample returns the newest sample at least window older than now.
+func (e *alertEngine) baseSample(now time.Time, window time.Duration) (trafficSample, bool) {
+	for i := len(e.samples) - 1; i >= 0; i-- {
+		if now.Sub(e.samples[i].at) >= window {
+			return e.samples[i], true
+		}
+	}
+	return trafficSample{}, false
+}
+
+// measureTraffic returns the requests the backends of appName served between
+// base and latest. A backend whose counters went down was reset by a proxy
+// restart and counts from zero.
+func measureTraffic(base, latest trafficSample, addrApps map[string]string, appName string) appTraffic {
+	t := appTraffic{buckets: make([]uint64, len(proxywire.LatencyBucketBounds)+1)}
+	for _, addr := range slices.Sorted(maps.Keys(latest.backends)) {
+		if addrApps[addr] != appName {
+			continue
+		}
+		current := latest.backends[addr]
+		previous := base.backends[addr]
+		if current.Requests < previous.Requests {
+			previous = proxywire.BackendStatus{}
+		}
+
+		requests := int64(current.Requests - previous.Requests)
+		errors := int64(current.Errors - previous.Errors)
+		buckets := make([]uint64, len(t.buckets))
+		for i := range buckets {
+			if i < len(current.LatencyBuckets) {
+				buckets[i] = current.LatencyBuckets[i]
+			}
+			if i < len(previous.LatencyBuckets) && previous.LatencyBuckets[i] <= buckets[i] {
+				buckets[i] -= previous.LatencyBuckets[i]
+			}
+			t.buckets[i] += buckets[i]
+		}
+		if requests == 0 {
+			continue
+		}
+		t.requests += requests
+		t.errors += errors
+		p95, _ := histogramP95(buckets)
+		t.backends = append(t.backends, apitypes.TrafficAlertBackend{
+			Address:      addr,
+			Requests:     requests,
+			Errors:       errors,
+			P95LatencyMs: p95.Milliseconds(),
+		})
+		for _, f := range current.RecentFailures {
+			if f.Time.After(base.at) {
+				t.failures = append(t.failures, apitypes.TrafficAlertRequest{
+					Time:      f.Time,
+					RequestID: f.RequestID,
+					Status:    f.Status,
+					Method:    f.Method,
+					Path:      f.Path,
+					Backend:   addr,
+				})
+			}
+		}
+	}
+
+	slices.SortFunc(t.failures, func(a, b apitypes.TrafficAlertRequest) int { return b.Time.Compare(a.Time) })
+	t.failures = t.failures[:min(len(t.failures), alertSampleRequests)]
+	if t.requests > 0 {
+		t.errorRate = float64(t.errors) / float64(t.requests) * 100
+	}
+	t.p95Latency, t.p95Over = histogramP95(t.buckets)
+	return t
+}
+
+// histogramP95 estimates the p95 latency from latency buckets, interpolating
+// within the bucket it falls in. over is set when it's above every bound.
+func histogramP95(buckets []uint64) (p95 time.Duration, over bool) {
+	var total uint64
+	for _, n := range buckets {
+		total += n
+	}
+	if total == 0 {
+		return 0, false
+	}
+
+	bounds := proxywire.LatencyBucketBounds
+	rank := float64(total) * 0.95
+	var cumulative uint64
+	for i, n := range buckets {
+		if float64(cumulative+n) < rank {
+			cumulative += n
+			continue
+		}
+		if i >= len(bounds) {
+			return bounds[len(bounds)-1], true
+		}
+		var lower time.Duration
+		if i > 0 {
+			lower = bounds[i-1]
+		}
+		fraction := (rank - float64(cumulative)) / float64(n)
+		return lower + time.Duration(fraction*float64(bounds[i]-lower)), false
+	}
+	return bounds[len(bounds)-1], true
+}
+
+// ruleBroken reports whether traffic breaks rule, and whether there were
+// enough requests to tell.
+func ruleBroken(rule config.AlertRule, traffic appTraffic) (broken, measured bool) {
+	if traffic.requests < int64(rule.GetMinRequests()) {
+		return false, false
+	}
+	if rule.ErrorRate > 0 && traffic.errorRate > rule.ErrorRate {
+		return true, true
+	}
+	if threshold := rule.GetP95Latency(); threshold > 0 && (traffic.p95Over || traffic.p95Latency > threshold) {
+		return true, true
+	}
+	return false, true
+}
+
+// transition notifies when the app starts or stops breaking the rule.
+func (e *alertEngine) transition(ctx context.Context, now time.Time, rule config.AlertRule, appName string, traffic appTraffic, broken bool) {
+	key := rule.Name + "/" + appName
+	if e.firing[key] == broken {
+		return
+	}
+	e.firing[key] = broken
+
+	alert := apitypes.TrafficAlert{
+		Event:          "alert_resolved",
+		Rule:           rule.Name,
+		App:            appName,
+		Time:           now.UTC(),
+		Window:         rule.GetWindow().String(),
+		Requests:       traffic.requests,
+		Errors:         traffic.errors,
+		ErrorRate:      traffic.errorRate,
+		P95LatencyMs:   traffic.p95Latency.Milliseconds(),
+		Backends:       traffic.backends,
+		SampleRequests: traffic.failures,
+	}
+	if rule.ErrorRate > 0 {
+		alert.ErrorRateThreshold = rule.ErrorRate
+	}
+	alert.P95LatencyThresholdMs = rule.GetP95Latency().Milliseconds()
+	alert.Server, _ = os.Hostname()
+	if broken {
+		alert.Event = "alert_firing"
+		e.logger.Warn("Alert firing", "rule", rule.Name, "app", appName, "error_rate", traffic.errorRate, "p95_latency_ms", alert.P95LatencyMs)
+	} else {
+		e.logger.Info("Alert resolved", "rule", rule.Name, "app", appName)
+	}
+
+	if e.config.NotifyURL == "" {
+		return
+	}
+	var body []byte
+	var err error
+	if e.config.GetFormat() == config.AlertFormatSlack {
+		body, err = json.Marshal(map[string]string{"text": slackAlertText(alert)})
+	} else {
+		body, err = json.Marshal(alert)
+	}
+	if err != nil {
+		return
+	}
+	notifyCtx, cancel := context.WithTimeout(ctx, alertNotifyTimeout)
+	defer cancel()
+	if err := e.notify(notifyCtx, e.config.NotifyURL, body); err != nil {
+		e.logger.Warn("Failed to send alert", "rule", rule.Name, "app", appName, "error", err)
+	}
+}
+
+// slackAlertText formats an alert as a Slack message.
+func slackAlertText(alert apitypes.TrafficAlert) string {
+	var b strings.Builder
+	if alert.Event == "alert_firing" {
+		fmt.Fprintf(&b, ":rotating_light: *%s* is breaking alert rule *%s* on %s\n", alert.App, alert.Rule, alert.Server)
+	} else {
+		fmt.Fprintf(&b, ":white_check_mark: *%s* is back within alert rule *%s* on %s\n", alert.App, alert.Rule, alert.Server)
+	}
+	fmt.Fprintf(&b, "Last %s: %d requests, %.1f%% failed, p95 %dms\n", alert.Window, alert.Requests, alert.ErrorRate, alert.P95LatencyMs)
+	for _, backend := range alert.Backends {
+		fmt.Fprintf(&b, "• `%s` %d requests, %d failed, p95 %dms\n", backend.Address, backend.Requests, backend.Errors, backend.P95LatencyMs)
+	}
+	for _, r := range alert.SampleRequests {
+		id := r.RequestID
+		if id == "" {
+			id = "no request ID"
+		}
+		fmt.Fprintf(&b, "• %d %s %s (%s)\n", r.Status, r.Method, r.Path, id)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,183 @@
+package haloyd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+func TestAlertEngine(t *testing.T) {
+	snapshot := &proxywire.Snapshot{Routes: []proxywire.Route{
+		{Canonical: "shop.example.com", App: "shop", Backends: []proxywire.Backend{{IP: "10.0.0.2", Port: "8080"}, {IP: "10.0.0.3", Port: "8080"}}},
+		{Canonical: "blog.example.com", App: "blog", Backends: []proxywire.Backend{{IP: "10.0.0.4", Port: "8080"}}},
+	}}
+	cfg := config.AlertsConfig{
+		NotifyURL: "https://hooks.example.com/alerts",
+		Rules:     []config.AlertRule{{Name: "errors", Apps: []string{"shop"}, ErrorRate: 5}},
+	}
+
+	var alerts []apitypes.TrafficAlert
+	e := newAlertEngine(cfg, nil, func() *proxywire.Snapshot { return snapshot }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	e.notify = func(_ context.Context, url string, body []byte) error {
+		if url != cfg.NotifyURL {
+			t.Errorf("notify url = %q, want %q", url, cfg.NotifyURL)
+		}
+		var alert apitypes.TrafficAlert
+		if err := json.Unmarshal(body, &alert); err != nil {
+			t.Errorf("alert body %s: %v", body, err)
+		}
+		alerts = append(alerts, alert)
+		return nil
+	}
+
+	buckets := func(fast uint64) []uint64 {
+		b := make([]uint64, len(proxywire.LatencyBucketBounds)+1)
+		b[1] = fast
+		return b
+	}
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	good := proxywire.BackendStatus{Address: "10.0.0.2:8080", Requests: 100, LatencyBuckets: buckets(100)}
+	bad := proxywire.BackendStatus{Address: "10.0.0.3:8080", Requests: 100, LatencyBuckets: buckets(100)}
+	blog := proxywire.BackendStatus{Address: "10.0.0.4:8080", Requests: 100, Errors: 100, LatencyBuckets: buckets(100)}
+	e.evaluate(context.Background(), now, []proxywire.BackendStatus{good, bad, blog})
+	if len(alerts) != 0 {
+		t.Fatalf("alerts = %+v, want none before a full window", alerts)
+	}
+
+	// Over the next five minutes one of shop's backends fails a fifth of its
+	// requests; blog fails all of its own but isn't covered by the rule.
+	at := now.Add(config.DefaultAlertWindow)
+	good.Requests, good.LatencyBuckets = 200, buckets(200)
+	bad.Requests, bad.Errors, bad.LatencyBuckets = 200, 20, buckets(200)
+	bad.RecentFailures = []proxywire.FailedRequest{
+		{Time: now.Add(-time.Minute), RequestID: "before-window", Status: 502},
+		{Time: at.Add(-time.Second), RequestID: "req-1", Status: 502, Method: "GET", Path: "/cart"},
+	}
+	blog.Requests, blog.Errors = 200, 200
+	e.evaluate(context.Background(), at, []proxywire.BackendStatus{good, bad, blog})
+	if len(alerts) != 1 {
+		t.Fatalf("alerts = %+v, want one", alerts)
+	}
+	alert := alerts[0]
+	if alert.Event != "alert_firing" || alert.Rule != "errors" || alert.App != "shop" {
+		t.Errorf("alert = %+v, want errors firing for shop", alert)
+	}
+	if alert.Requests != 200 || alert.Errors != 20 || alert.ErrorRate != 10 || alert.ErrorRateThreshold != 5 {
+		t.Errorf("alert counts = %d requests, %d errors, %.1f%% (threshold %.1f%%), want 200, 20, 10%% (5%%)", alert.Requests, alert.Errors, alert.ErrorRate, alert.ErrorRateThreshold)
+	}
+	if len(alert.Backends) != 2 || alert.Backends[1].Address != "10.0.0.3:8080" || alert.Backends[1].Errors != 20 {
+		t.Errorf("alert backends = %+v, want both shop backends with the errors on 10.0.0.3", alert.Backends)
+	}
+	if len(alert.SampleRequests) != 1 || alert.SampleRequests[0].RequestID != "req-1" || alert.SampleRequests[0].Backend != "10.0.0.3:8080" {
+		t.Errorf("alert sample requests = %+v, want req-1 only", alert.SampleRequests)
+	}
+
+	// Still failing doesn't alert again.
+	at = at.Add(alertEvalInterval)
+	bad.Requests, bad.Errors, bad.LatencyBuckets = 210, 22, buckets(210)
+	e.evaluate(context.Background(), at, []proxywire.BackendStatus{good, bad, blog})
+	if len(alerts) != 1 {
+		t.Fatalf("alerts = %+v, want no repeat while firing", alerts)
+	}
+
+	// A healthy window resolves it.
+	at = at.Add(config.DefaultAlertWindow)
+	good.Requests, good.LatencyBuckets = 300, buckets(300)
+	bad.Requests, bad.LatencyBuckets = 310, buckets(310)
+	e.evaluate(context.Background(), at, []proxywire.BackendStatus{good, bad, blog})
+	if len(alerts) != 2 || alerts[1].Event != "alert_resolved" || alerts[1].Errors != 0 {
+		t.Errorf("alerts = %+v, want the alert resolved", alerts)
+	}
+}
+
+func TestAlertEngineMinRequests(t *testing.T) {
+	snapshot := &proxywire.Snapshot{Routes: []proxywire.Route{
+		{Canonical: "shop.example.com", App: "shop", Backends: []proxywire.Backend{{IP: "10.0.0.2", Port: "8080"}}},
+	}}
+	cfg := config.AlertsConfig{
+		NotifyURL: "https://hooks.example.com/alerts",
+		Rules:     []config.AlertRule{{Name: "errors", ErrorRate: 5}},
+	}
+	notified := 0
+	e := newAlertEngine(cfg, nil, func() *proxywire.Snapshot { return snapshot }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	e.notify = func(context.Context, string, []byte) error {
+		notified++
+		return nil
+	}
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	e.evaluate(context.Background(), now, []proxywire.BackendStatus{{Address: "10.0.0.2:8080"}})
+	e.evaluate(context.Background(), now.Add(config.DefaultAlertWindow), []proxywire.BackendStatus{{Address: "10.0.0.2:8080", Requests: 5, Errors: 5}})
+	if notified != 0 {
+		t.Errorf("notified %d times, want none below %d requests", notified, config.DefaultAlertMinRequests)
+	}
+}
+
+func TestHistogramP95(t *testing.T) {
+	bucketsOf := func(counts map[int]uint64) []uint64 {
+		b := make([]uint64, len(proxywire.LatencyBucketBounds)+1)
+		for i, n := range counts {
+			b[i] = n
+		}
+		return b
+	}
+	tests := []struct {
+		name     string
+		buckets  []uint64
+		wantP95  time.Duration
+		wantOver bool
+	}{
+		{"empty", bucketsOf(nil), 0, false},
+		// 95 of 100 requests in the first bucket puts p95 at its bound.
+		{"first bucket", bucketsOf(map[int]uint64{0: 95, 3: 5}), 10 * time.Millisecond, false},
+		// Rank 95 falls halfway into the 50ms-100ms bucket.
+		{"interpolated", bucketsOf(map[int]uint64{0: 90, 3: 10}), 75 * time.Millisecond, false},
+		{"over every bound", bucketsOf(map[int]uint64{0: 10, len(proxywire.LatencyBucketBounds): 90}), 10 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p95, over := histogramP95(tt.buckets)
+			if p95 != tt.wantP95 || over != tt.wantOver {
+				t.Errorf("histogramP95() = %v, %v, want %v, %v", p95, over, tt.wantP95, tt.wantOver)
+			}
+		})
+	}
+}
+
+func TestSlackAlertText(t *testing.T) {
+	text := slackAlertText(apitypes.TrafficAlert{
+		Event:        "alert_firing",
+		Rule:         "errors",
+		App:          "shop",
+		Server:       "web-1",
+		Window:       "5m0s",
+		Requests:     200,
+		Errors:       20,
+		ErrorRate:    10,
+		P95LatencyMs: 42,
+		Backends:     []apitypes.TrafficAlertBackend{{Address: "10.0.0.3:8080", Requests: 100, Errors: 20, P95LatencyMs: 80}},
+		SampleRequests: []apitypes.TrafficAlertRequest{
+			{RequestID: "req-1", Status: 502, Method: "GET", Path: "/cart"},
+			{Status: 504, Method: "POST", Path: "/checkout"},
+		},
+	})
+	for _, want := range []string{
+		"*shop* is breaking alert rule *errors* on web-1",
+		"Last 5m0s: 200 requests, 10.0% failed, p95 42ms",
+		"`10.0.0.3:8080` 100 requests, 20 failed, p95 80ms",
+		"502 GET /cart (req-1)",
+		"504 POST /checkout (no request ID)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("slackAlertText() = %q, want it to contain %q", text, want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/docker/docker/api/types/events"
 	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/helpers"
 )
 
 type debouncedAppEvent struct {
@@ -86,7 +87,7 @@ func (d *appDebouncer) signalDone(appName string) {
 	var capturedStartEvent bool
 	for _, event := range capturedEvents {
 
-		if event.Labels.DeploymentID > latestEvent.Labels.DeploymentID {
+		if helpers.CompareDeploymentIDs(event.Labels.DeploymentID, latestEvent.Labels.DeploymentID) > 0 {
 			latestEvent = event
 		}
 
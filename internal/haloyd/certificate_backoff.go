@@ -0,0 +1,118 @@
+package haloyd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/storage"
+	"golang.org/x/crypto/acme"
+)
+
+const (
+	// issuanceBackoffBase is the wait after the first failed issuance; it
+	// doubles with every further failure up to issuanceBackoffMax.
+	issuanceBackoffBase = 5 * time.Minute
+	issuanceBackoffMax  = 24 * time.Hour
+	// rateLimitBackoffMin is the least a rate-limited domain waits when the CA
+	// doesn't say how long.
+	rateLimitBackoffMin = time.Hour
+)
+
+// issuanceBackoff returns how long to wait after the given number of
+// consecutive failures, with up to 20% jitter either way so domains that
+// failed together don't retry together.
+func issuanceBackoff(failures int) time.Duration {
+	backoff := issuanceBackoffMax
+	if failures < 20 {
+		backoff = min(issuanceBackoffBase<<max(failures-1, 0), issuanceBackoffMax)
+	}
+	jitter := 0.8 + 0.4*rand.Float64()
+	return time.Duration(float64(backoff) * jitter)
+}
+
+// nextIssuanceAttempt returns when issuance may be retried after err, the
+// failures-th consecutive failure, and whether err is a CA rate limit. A
+// Retry-After sent with a rate limit error is always respected.
+func nextIssuanceAttempt(now time.Time, failures int, err error) (time.Time, bool) {
+	wait := issuanceBackoff(failures)
+	var acmeErr *acme.Error
+	if !errors.As(err, &acmeErr) {
+		return now.Add(wait), false
+	}
+	retryAfter, rateLimited := acme.RateLimit(acmeErr)
+	if !rateLimited {
+		return now.Add(wait), false
+	}
+	return now.Add(max(wait, retryAfter, rateLimitBackoffMin)), true
+}
+
+// checkIssuanceBackoff returns an error while domain is waiting out the
+// backoff from its failed issuance attempts.
+func (m *CertificatesManager) checkIssuanceBackoff(domain string) error {
+	if m.config.DB == nil {
+		return nil
+	}
+	issuance, err := m.config.DB.GetCertificateIssuance(domain)
+	if err != nil || issuance == nil || !time.Now().Before(issuance.NextAttemptAt) {
+		return err
+	}
+	reason := ""
+	if issuance.RateLimited {
+		reason = " due to rate limit"
+	}
+	return fmt.Errorf("not requesting a certificate for %s after %d failed attempts, retrying in %s%s (last error: %s)",
+		domain, issuance.Failures, shortDuration(time.Until(issuance.NextAttemptAt)), reason, issuance.LastError)
+}
+
+// recordIssuance updates domain's failure record with the outcome of an
+// issuance attempt. Attempts cut short by haloyd stopping aren't counted.
+func (m *CertificatesManager) recordIssuance(logger *slog.Logger, domain string, issueErr error) {
+	if m.config.DB == nil || errors.Is(issueErr, context.Canceled) {
+		return
+	}
+	if issueErr == nil {
+		if err := m.config.DB.DeleteCertificateIssuance(domain); err != nil {
+			logger.Warn("Failed to clear certificate issuance failures", "domain", domain, "error", err)
+		}
+		return
+	}
+
+	failures := 1
+	previous, err := m.config.DB.GetCertificateIssuance(domain)
+	if err != nil {
+		logger.Warn("Failed to read certificate issuance failures", "domain", domain, "error", err)
+	} else if previous != nil {
+		failures = previous.Failures + 1
+	}
+	now := time.Now()
+	next, rateLimited := nextIssuanceAttempt(now, failures, issueErr)
+	err = m.config.DB.SaveCertificateIssuance(storage.CertificateIssuance{
+		Domain:        domain,
+		Failures:      failures,
+		LastAttemptAt: now,
+		LastError:     issueErr.Error(),
+		RateLimited:   rateLimited,
+		NextAttemptAt: next,
+	})
+	if err != nil {
+		logger.Warn("Failed to record certificate issuance failure", "domain", domain, "error", err)
+		return
+	}
+	logger.Warn(fmt.Sprintf("Certificate issuance for %s failed %d time(s), retrying in %s", domain, failures, shortDuration(next.Sub(now))),
+		"domain", domain, "failures", failures, "rate_limited", rateLimited, "next_attempt", next)
+}
+
+// shortDuration formats d rounded to the minute, e.g. "2h" or "1h30m".
+func shortDuration(d time.Duration) string {
+	d = max(d.Round(time.Minute), time.Minute)
+	s := strings.TrimSuffix(d.String(), "0s")
+	if hours, ok := strings.CutSuffix(s, "h0m"); ok {
+		return hours + "h"
+	}
+	return s
+}
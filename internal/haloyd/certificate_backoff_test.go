@@ -0,0 +1,105 @@
+package haloyd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+func TestIssuanceBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, issuanceBackoffBase},
+		{2, 2 * issuanceBackoffBase},
+		{4, 8 * issuanceBackoffBase},
+		{12, issuanceBackoffMax},
+		{100, issuanceBackoffMax},
+	}
+	for _, tt := range tests {
+		got := issuanceBackoff(tt.failures)
+		low, high := time.Duration(float64(tt.want)*0.8), time.Duration(float64(tt.want)*1.2)
+		if got < low || got > high {
+			t.Errorf("issuanceBackoff(%d) = %v, want within 20%% of %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestNextIssuanceAttemptRespectsRetryAfter(t *testing.T) {
+	now := time.Now()
+	rateLimited := &acme.Error{
+		StatusCode:  http.StatusTooManyRequests,
+		ProblemType: "urn:ietf:params:acme:error:rateLimited",
+		Header:      http.Header{"Retry-After": []string{"10800"}},
+	}
+
+	next, limited := nextIssuanceAttempt(now, 1, fmt.Errorf("failed to create order: %w", rateLimited))
+	if !limited {
+		t.Fatal("rate limit error not recognized")
+	}
+	if next.Before(now.Add(3 * time.Hour)) {
+		t.Errorf("next attempt %v is before Retry-After", next.Sub(now))
+	}
+
+	next, limited = nextIssuanceAttempt(now, 1, &acme.Error{ProblemType: "urn:ietf:params:acme:error:rateLimited"})
+	if !limited || next.Before(now.Add(rateLimitBackoffMin)) {
+		t.Errorf("rate limit without Retry-After: next in %v, limited %v, want at least %v", next.Sub(now), limited, rateLimitBackoffMin)
+	}
+
+	next, limited = nextIssuanceAttempt(now, 1, fmt.Errorf("connection refused"))
+	if limited || next.After(now.Add(2*issuanceBackoffBase)) {
+		t.Errorf("plain failure: next in %v, limited %v", next.Sub(now), limited)
+	}
+}
+
+func TestIssuanceBackoffLedger(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m := newTestCertificatesManager(t)
+	m.config.DB = newTestCertificatesDB(t)
+
+	const domain = "example.com"
+	if err := m.checkIssuanceBackoff(domain); err != nil {
+		t.Fatalf("checkIssuanceBackoff() before any attempt = %v", err)
+	}
+
+	m.recordIssuance(logger, domain, &acme.Error{ProblemType: "urn:ietf:params:acme:error:rateLimited", Detail: "too many certificates"})
+	m.recordIssuance(logger, domain, fmt.Errorf("authorization failed"))
+	issuance, err := m.config.DB.GetCertificateIssuance(domain)
+	if err != nil || issuance == nil {
+		t.Fatalf("GetCertificateIssuance() = %v, %v", issuance, err)
+	}
+	if issuance.Failures != 2 {
+		t.Errorf("Failures = %d, want 2", issuance.Failures)
+	}
+
+	err = m.checkIssuanceBackoff(domain)
+	if err == nil || !strings.Contains(err.Error(), "retrying in") || !strings.Contains(err.Error(), "authorization failed") {
+		t.Errorf("checkIssuanceBackoff() = %v, want the retry time and last error", err)
+	}
+
+	m.recordIssuance(logger, domain, nil)
+	if err := m.checkIssuanceBackoff(domain); err != nil {
+		t.Errorf("checkIssuanceBackoff() after success = %v", err)
+	}
+}
+
+func TestShortDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		2 * time.Hour:                  "2h",
+		90 * time.Minute:               "1h30m",
+		5*time.Minute + 10*time.Second: "5m",
+		10 * time.Second:               "1m",
+	}
+	for d, want := range tests {
+		if got := shortDuration(d); got != want {
+			t.Errorf("shortDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package haloyd
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+// recordCertificate stores a newly issued certificate as the current version
+// for domain, keeping earlier versions as history.
+func (m *CertificatesManager) recordCertificate(domain string, keyPEM, certPEM []byte) error {
+	leaf, err := parseCertificate(certPEM)
+	if err != nil {
+		return fmt.Errorf("failed to parse issued certificate for %s: %w", domain, err)
+	}
+	_, err = m.config.DB.SaveCertificate(storage.Certificate{
+		Domain:     domain,
+		Names:      leaf.DNSNames,
+		CertPEM:    certPEM,
+		KeyPEM:     keyPEM,
+		AccountURL: m.clientManager.accountURL(),
+		Staging:    m.config.TlsStaging,
+		IssuedAt:   leaf.NotBefore,
+		NotAfter:   leaf.NotAfter,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record certificate for %s: %w", domain, err)
+	}
+	return nil
+}
+
+// retireCertificate marks domain's certificate as no longer served after its
+// file was removed, so SyncCertificateFiles doesn't bring it back.
+func (m *CertificatesManager) retireCertificate(logger *slog.Logger, domain string) {
+	if m.config.DB == nil {
+		return
+	}
+	if err := m.config.DB.RetireCertificate(domain); err != nil {
+		logger.Warn("Failed to retire certificate in history", "domain", domain, "error", err)
+	}
+}
+
+// SyncCertificateFiles makes the certificate directory match the history.
+// Files missing or left stale by an interrupted save are rewritten from the
+// current version, and certificate files without history, e.g. from before
+// the history existed, are imported.
+func (m *CertificatesManager) SyncCertificateFiles(logger *slog.Logger) error {
+	if m.config.DB == nil {
+		return nil
+	}
+	current, err := m.config.DB.ListCurrentCertificates()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]struct{}, len(current))
+	restored := 0
+	var errs []error
+	for _, cert := range current {
+		known[cert.Domain] = struct{}{}
+		want := combinedPEM(cert.KeyPEM, cert.CertPEM)
+		path := filepath.Join(m.config.CertDir, cert.Domain+combinedCertExt)
+		if have, err := os.ReadFile(path); err == nil && bytes.Equal(have, want) {
+			continue
+		}
+		if err := m.writeCertificateFile(cert.Domain, want); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore certificate for %s: %w", cert.Domain, err))
+			continue
+		}
+		logger.Info("Restored certificate file from history", "domain", cert.Domain)
+		restored++
+	}
+	if restored > 0 && m.updateSignal != nil {
+		m.updateSignal <- "certificates_restored"
+	}
+
+	entries, err := os.ReadDir(m.config.CertDir)
+	if err != nil {
+		return errors.Join(append(errs, fmt.Errorf("failed to read certificates directory: %w", err))...)
+	}
+	for _, entry := range entries {
+		domain, ok := strings.CutSuffix(entry.Name(), combinedCertExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if _, ok := known[domain]; ok {
+			continue
+		}
+		if err := m.importCertificateFile(domain); err != nil {
+			logger.Warn("Failed to import certificate into history", "domain", domain, "error", err)
+			continue
+		}
+		logger.Debug("Imported certificate into history", "domain", domain)
+	}
+	return errors.Join(errs...)
+}
+
+func (m *CertificatesManager) importCertificateFile(domain string) error {
+	data, err := os.ReadFile(filepath.Join(m.config.CertDir, domain+combinedCertExt))
+	if err != nil {
+		return err
+	}
+	keyPEM, certPEM := splitCombinedPEM(data)
+	if len(keyPEM) == 0 || len(certPEM) == 0 {
+		return errors.New("file doesn't contain a private key and a certificate")
+	}
+	leaf, err := parseCertificate(certPEM)
+	if err != nil {
+		return err
+	}
+	_, err = m.config.DB.SaveCertificate(storage.Certificate{
+		Domain:   domain,
+		Names:    leaf.DNSNames,
+		CertPEM:  certPEM,
+		KeyPEM:   keyPEM,
+		Staging:  strings.Contains(leaf.Issuer.String(), "(STAGING)"),
+		IssuedAt: leaf.NotBefore,
+		NotAfter: leaf.NotAfter,
+	})
+	return err
+}
+
+// combinedPEM joins a private key and certificate chain into the single file
+// format the proxy loads.
+func combinedPEM(keyPEM, certPEM []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(keyPEM)
+	if len(keyPEM) > 0 && keyPEM[len(keyPEM)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	buf.Write(certPEM)
+	return buf.Bytes()
+}
+
+// splitCombinedPEM separates a combined file into its private key and its
+// certificate chain.
+func splitCombinedPEM(data []byte) (keyPEM, certPEM []byte) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return keyPEM, certPEM
+		}
+		switch {
+		case block.Type == "CERTIFICATE":
+			certPEM = append(certPEM, pem.EncodeToMemory(block)...)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			keyPEM = append(keyPEM, pem.EncodeToMemory(block)...)
+		}
+	}
+}
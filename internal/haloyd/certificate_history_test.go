@@ -0,0 +1,139 @@
+package haloyd
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+func newTestCertificatesDB(t *testing.T) *storage.DB {
+	t.Helper()
+
+	rawDB, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	// Every connection to :memory: is a separate database.
+	rawDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { rawDB.Close() })
+
+	db := &storage.DB{DB: rawDB}
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	return db
+}
+
+func TestSaveCertificateRecordsHistory(t *testing.T) {
+	m := newTestCertificatesManager(t)
+	m.config.DB = newTestCertificatesDB(t)
+
+	const domain = "example.com"
+	first := writeCombinedTestCert(t, t.TempDir(), domain)
+	second := writeCombinedTestCert(t, t.TempDir(), domain)
+	for _, path := range []string{first, second} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyPEM, certPEM := splitCombinedPEM(data)
+		if err := m.saveCertificate(domain, keyPEM, certPEM); err != nil {
+			t.Fatalf("saveCertificate() error = %v", err)
+		}
+	}
+
+	versions, err := m.config.DB.ListCertificateVersions(domain)
+	if err != nil {
+		t.Fatalf("ListCertificateVersions() error = %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("history has %d versions, want 2", len(versions))
+	}
+	served, err := os.ReadFile(filepath.Join(m.config.CertDir, domain+combinedCertExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(served, combinedPEM(versions[0].KeyPEM, versions[0].CertPEM)) {
+		t.Error("served file doesn't match the current version")
+	}
+	if len(versions[0].Names) != 1 || versions[0].Names[0] != domain {
+		t.Errorf("Names = %v, want [%s]", versions[0].Names, domain)
+	}
+}
+
+func TestSyncCertificateFiles(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m := newTestCertificatesManager(t)
+	m.config.DB = newTestCertificatesDB(t)
+
+	// A file from before the history existed is imported.
+	legacyPath := writeCombinedTestCert(t, m.config.CertDir, "legacy.example.com")
+	if err := m.SyncCertificateFiles(logger); err != nil {
+		t.Fatalf("SyncCertificateFiles() error = %v", err)
+	}
+	legacy, err := m.config.DB.GetCurrentCertificate("legacy.example.com")
+	if err != nil || legacy == nil {
+		t.Fatalf("GetCurrentCertificate() = %v, %v, want the imported certificate", legacy, err)
+	}
+
+	// A file lost, e.g. by a crash between recording and writing it, is
+	// restored from the current version.
+	if err := os.Remove(legacyPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SyncCertificateFiles(logger); err != nil {
+		t.Fatalf("SyncCertificateFiles() error = %v", err)
+	}
+	restored, err := os.ReadFile(legacyPath)
+	if err != nil {
+		t.Fatalf("certificate file was not restored: %v", err)
+	}
+	if !bytes.Equal(restored, combinedPEM(legacy.KeyPEM, legacy.CertPEM)) {
+		t.Error("restored file doesn't match the recorded certificate")
+	}
+
+	// Retired certificates stay removed.
+	m.retireCertificate(logger, "legacy.example.com")
+	if err := os.Remove(legacyPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SyncCertificateFiles(logger); err != nil {
+		t.Fatalf("SyncCertificateFiles() error = %v", err)
+	}
+	if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+		t.Errorf("retired certificate was restored, stat error = %v", err)
+	}
+}
+
+func TestRemoveCertificates(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m := newTestCertificatesManager(t)
+	m.config.DB = newTestCertificatesDB(t)
+
+	writeCombinedTestCert(t, m.config.CertDir, "app.example.com")
+	if err := m.SyncCertificateFiles(logger); err != nil {
+		t.Fatalf("SyncCertificateFiles() error = %v", err)
+	}
+
+	removed := m.RemoveCertificates(logger, []string{"app.example.com", "missing.example.com"})
+	if len(removed) != 1 || removed[0] != "app.example.com" {
+		t.Fatalf("RemoveCertificates() = %v, want [app.example.com]", removed)
+	}
+	if _, err := os.Stat(filepath.Join(m.config.CertDir, "app.example.com"+combinedCertExt)); !os.IsNotExist(err) {
+		t.Fatalf("certificate file still exists, stat error = %v", err)
+	}
+
+	// A removed certificate is not restored from the history.
+	if err := m.SyncCertificateFiles(logger); err != nil {
+		t.Fatalf("SyncCertificateFiles() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.config.CertDir, "app.example.com"+combinedCertExt)); !os.IsNotExist(err) {
+		t.Fatalf("certificate file restored after sync, stat error = %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package haloyd
+
+import (
+	"context"
+	"sync"
+)
+
+// maxConcurrentIssuance bounds how many domains are checked and issued at
+// once. An ACME order spends most of its time waiting on the CA, so a few
+// in parallel speed up servers with many domains without hammering it.
+const maxConcurrentIssuance = 4
+
+// CertificateResult is the outcome of checking the certificate of a domain.
+type CertificateResult struct {
+	Domain CertificatesDomain
+	// Renewed is set when a new certificate was obtained.
+	Renewed bool
+	Err     error
+}
+
+// issuanceQueue checks domains in parallel, up to a limit. Checks of the same
+// domain run one after the other, so two refreshes never request the same
+// certificate at once, but a slow or failing domain holds up no other.
+type issuanceQueue struct {
+	slots   chan struct{}
+	mu      sync.Mutex
+	domains map[string]*domainQueue
+}
+
+// domainQueue serializes the checks of a domain. It is dropped once no check
+// holds or waits for it.
+type domainQueue struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func newIssuanceQueue(concurrency int) *issuanceQueue {
+	return &issuanceQueue{
+		slots:   make(chan struct{}, max(concurrency, 1)),
+		domains: make(map[string]*domainQueue),
+	}
+}
+
+// run calls check for every domain and returns the results in the order of
+// domains. Domains still waiting for a slot when ctx is done fail with its
+// error.
+func (q *issuanceQueue) run(ctx context.Context, domains []CertificatesDomain, check func(CertificatesDomain) CertificateResult) []CertificateResult {
+	results := make([]CertificateResult, len(domains))
+	var wg sync.WaitGroup
+	for i, domain := range domains {
+		wg.Go(func() {
+			unlock := q.lock(domain.Canonical)
+			defer unlock()
+
+			select {
+			case q.slots <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = CertificateResult{Domain: domain, Err: ctx.Err()}
+				return
+			}
+			defer func() { <-q.slots }()
+
+			results[i] = check(domain)
+		})
+	}
+	wg.Wait()
+	return results
+}
+
+// lock waits until no other check of domain runs and returns the function
+// that lets the next one go.
+func (q *issuanceQueue) lock(domain string) (unlock func()) {
+	q.mu.Lock()
+	dq, ok := q.domains[domain]
+	if !ok {
+		dq = &domainQueue{}
+		q.domains[domain] = dq
+	}
+	dq.refs++
+	q.mu.Unlock()
+
+	dq.mu.Lock()
+	return func() {
+		dq.mu.Unlock()
+		q.mu.Lock()
+		dq.refs--
+		if dq.refs == 0 {
+			delete(q.domains, domain)
+		}
+		q.mu.Unlock()
+	}
+}
@@ -0,0 +1,121 @@
+package haloyd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIssuanceQueue_BoundsConcurrency(t *testing.T) {
+	q := newIssuanceQueue(2)
+
+	var running, peak atomic.Int32
+	var domains []CertificatesDomain
+	for i := range 6 {
+		domains = append(domains, CertificatesDomain{Canonical: fmt.Sprintf("d%d.example.com", i)})
+	}
+	results := q.run(context.Background(), domains, func(d CertificatesDomain) CertificateResult {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return CertificateResult{Domain: d, Renewed: true}
+	})
+
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak concurrency = %d, want 2", got)
+	}
+	for i, r := range results {
+		if r.Domain.Canonical != domains[i].Canonical || !r.Renewed {
+			t.Errorf("results[%d] = %+v, want %s renewed", i, r, domains[i].Canonical)
+		}
+	}
+}
+
+func TestIssuanceQueue_SlowDomainDoesNotBlockOthers(t *testing.T) {
+	q := newIssuanceQueue(2)
+	release := make(chan struct{})
+	slowStarted := make(chan struct{})
+
+	var slow sync.WaitGroup
+	slow.Go(func() {
+		q.run(context.Background(), []CertificatesDomain{{Canonical: "slow.example.com"}}, func(d CertificatesDomain) CertificateResult {
+			close(slowStarted)
+			<-release
+			return CertificateResult{Domain: d}
+		})
+	})
+	<-slowStarted
+
+	done := make(chan []CertificateResult)
+	go func() {
+		done <- q.run(context.Background(), []CertificatesDomain{{Canonical: "fast.example.com"}}, func(d CertificatesDomain) CertificateResult {
+			return CertificateResult{Domain: d, Err: errors.New("boom")}
+		})
+	}()
+	select {
+	case results := <-done:
+		if len(results) != 1 || results[0].Err == nil {
+			t.Errorf("results = %+v, want the failure of fast.example.com", results)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow domain held up another domain")
+	}
+
+	close(release)
+	slow.Wait()
+}
+
+func TestIssuanceQueue_SerializesSameDomain(t *testing.T) {
+	q := newIssuanceQueue(4)
+
+	var running atomic.Int32
+	var overlapped atomic.Bool
+	check := func(d CertificatesDomain) CertificateResult {
+		if running.Add(1) > 1 {
+			overlapped.Store(true)
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return CertificateResult{Domain: d}
+	}
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Go(func() {
+			q.run(context.Background(), []CertificatesDomain{{Canonical: "example.com"}}, check)
+		})
+	}
+	wg.Wait()
+
+	if overlapped.Load() {
+		t.Error("checks of the same domain overlapped")
+	}
+	if len(q.domains) != 0 {
+		t.Errorf("queue kept %d domains after the checks finished", len(q.domains))
+	}
+}
+
+func TestIssuanceQueue_Canceled(t *testing.T) {
+	q := newIssuanceQueue(1)
+	q.slots <- struct{}{} // every slot taken
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := q.run(ctx, []CertificatesDomain{{Canonical: "example.com"}}, func(d CertificatesDomain) CertificateResult {
+		t.Error("check ran after the context was canceled")
+		return CertificateResult{Domain: d}
+	})
+	if len(results) != 1 || !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("results = %+v, want context.Canceled", results)
+	}
+}
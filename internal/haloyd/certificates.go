@@ -14,19 +14,23 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/helpers"
 	"github.com/haloydev/haloy/internal/logging"
+	"github.com/haloydev/haloy/internal/storage"
 	"golang.org/x/crypto/acme"
 )
 
@@ -48,6 +52,10 @@ type ChallengeServer struct {
 	challenges map[string]string // token -> keyAuth
 	server     *http.Server
 	port       string
+	// onDemand receives the proxy's reports of unknown TLS server names.
+	onDemand func(domain string)
+	// backendFailure receives the proxy's reports of failing backends.
+	backendFailure func(addr string)
 }
 
 // NewChallengeServer creates a new HTTP-01 challenge server
@@ -94,8 +102,65 @@ func (cs *ChallengeServer) ClearChallenge(token string) {
 	delete(cs.challenges, token)
 }
 
+// SetOnDemandHandler sets the function called for the proxy's on-demand TLS
+// reports.
+func (cs *ChallengeServer) SetOnDemandHandler(fn func(domain string)) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.onDemand = fn
+}
+
+func (cs *ChallengeServer) handleOnDemand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cs.mu.RLock()
+	onDemand := cs.onDemand
+	cs.mu.RUnlock()
+	if onDemand == nil {
+		http.NotFound(w, r)
+		return
+	}
+	onDemand(r.FormValue("domain"))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// SetBackendFailureHandler sets the function called for the proxy's reports
+// of failing backends.
+func (cs *ChallengeServer) SetBackendFailureHandler(fn func(addr string)) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.backendFailure = fn
+}
+
+func (cs *ChallengeServer) handleBackendFailure(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cs.mu.RLock()
+	backendFailure := cs.backendFailure
+	cs.mu.RUnlock()
+	if backendFailure == nil {
+		http.NotFound(w, r)
+		return
+	}
+	backendFailure(r.FormValue("backend"))
+	w.WriteHeader(http.StatusAccepted)
+}
+
 // ServeHTTP handles HTTP-01 challenge requests
 func (cs *ChallengeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case constants.OnDemandTLSPath:
+		cs.handleOnDemand(w, r)
+		return
+	case constants.BackendFailurePath:
+		cs.handleBackendFailure(w, r)
+		return
+	}
+
 	// Expected path: /.well-known/acme-challenge/{token}
 	prefix := "/.well-known/acme-challenge/"
 	if !strings.HasPrefix(r.URL.Path, prefix) {
@@ -154,6 +219,16 @@ func NewACMEClientManager(certDir string, staging bool) (*ACMEClientManager, err
 	}, nil
 }
 
+// accountURL returns the URL of the ACME account, or "" before it is loaded.
+func (m *ACMEClientManager) accountURL() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.account == nil {
+		return ""
+	}
+	return m.account.URI
+}
+
 // GetClient returns the ACME client, initializing it if necessary
 func (m *ACMEClientManager) GetClient(ctx context.Context) (*acme.Client, error) {
 	m.mu.Lock()
@@ -246,8 +321,21 @@ func (m *ACMEClientManager) loadOrCreateAccount(ctx context.Context) error {
 	return nil
 }
 
-// ObtainCertificate obtains a certificate for the given domains using HTTP-01 challenge
-func (m *ACMEClientManager) ObtainCertificate(ctx context.Context, domains []string, challengeServer *ChallengeServer) (certPEM, keyPEM []byte, err error) {
+// dnsProvider publishes DNS-01 challenge records.
+type dnsProvider interface {
+	// Present creates the TXT record for domain's challenge. cleanup removes
+	// it and must be called even when err is set.
+	Present(ctx context.Context, domain, value string) (cleanup func(), err error)
+}
+
+// dnsPropagationDelay gives the DNS provider's nameservers time to serve a new
+// challenge record before the CA is asked to look it up.
+var dnsPropagationDelay = 10 * time.Second
+
+// ObtainCertificate obtains a certificate for the given domains. It answers
+// DNS-01 challenges through dns when it is set and HTTP-01 challenges through
+// challengeServer otherwise.
+func (m *ACMEClientManager) ObtainCertificate(ctx context.Context, domains []string, challengeServer *ChallengeServer, dns dnsProvider) (certPEM, keyPEM []byte, err error) {
 	client, err := m.GetClient(ctx)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get ACME client: %w", err)
@@ -270,28 +358,45 @@ func (m *ACMEClientManager) ObtainCertificate(ctx context.Context, domains []str
 			continue // Already authorized
 		}
 
-		// Find HTTP-01 challenge
+		challengeType := "http-01"
+		if dns != nil {
+			challengeType = "dns-01"
+		}
 		var challenge *acme.Challenge
 		for _, c := range auth.Challenges {
-			if c.Type == "http-01" {
+			if c.Type == challengeType {
 				challenge = c
 				break
 			}
 		}
 		if challenge == nil {
-			return nil, nil, fmt.Errorf("no HTTP-01 challenge found for %s", auth.Identifier.Value)
+			return nil, nil, fmt.Errorf("no %s challenge found for %s", strings.ToUpper(challengeType), auth.Identifier.Value)
 		}
 
-		// Get key authorization
-		keyAuth, err := client.HTTP01ChallengeResponse(challenge.Token)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to get challenge response: %w", err)
+		if dns != nil {
+			record, err := client.DNS01ChallengeRecord(challenge.Token)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to get challenge record: %w", err)
+			}
+			cleanup, err := dns.Present(ctx, auth.Identifier.Value, record)
+			defer cleanup()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to publish DNS challenge for %s: %w", auth.Identifier.Value, err)
+			}
+			select {
+			case <-time.After(dnsPropagationDelay):
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+		} else {
+			keyAuth, err := client.HTTP01ChallengeResponse(challenge.Token)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to get challenge response: %w", err)
+			}
+			challengeServer.SetChallenge(challenge.Token, keyAuth)
+			defer challengeServer.ClearChallenge(challenge.Token)
 		}
 
-		// Set up challenge response
-		challengeServer.SetChallenge(challenge.Token, keyAuth)
-		defer challengeServer.ClearChallenge(challenge.Token)
-
 		// Accept the challenge
 		if _, err := client.Accept(ctx, challenge); err != nil {
 			return nil, nil, fmt.Errorf("failed to accept challenge: %w", err)
@@ -393,11 +498,22 @@ type CertificatesManagerConfig struct {
 	CertDir          string
 	HTTPProviderPort string
 	TlsStaging       bool
+	// DNSCheck and Resolver configure the DNS preflight run before
+	// requesting a certificate, see config.CertificatesConfig.
+	DNSCheck config.DNSCheckMode
+	Resolver string
+	// CloudflareAPIToken enables DNS-01 issuance for domains behind Cloudflare.
+	CloudflareAPIToken string
+	// DB keeps the history of issued certificates. Without it only the
+	// certificate files are written.
+	DB *storage.DB
 }
 
 type CertificatesDomain struct {
 	Canonical string
 	Aliases   []string
+	// CDN is the CDN proxying the domain, see config.Domain.CDN.
+	CDN string
 }
 
 func (cm *CertificatesDomain) Validate() error {
@@ -422,13 +538,18 @@ func (cm *CertificatesDomain) Validate() error {
 
 type CertificatesManager struct {
 	config          CertificatesManagerConfig
-	checkMutex      sync.Mutex
+	queue           *issuanceQueue
 	ctx             context.Context
 	cancel          context.CancelFunc
 	clientManager   *ACMEClientManager
 	challengeServer *ChallengeServer
 	updateSignal    chan<- string // signal successful updates
 	debouncer       *helpers.Debouncer
+	// resolver is used for the DNS preflight when one is configured.
+	resolver *net.Resolver
+	// cloudflare answers DNS-01 challenges for Cloudflare domains; nil
+	// without an API token.
+	cloudflare dnsProvider
 }
 
 func NewCertificatesManager(config CertificatesManagerConfig, updateSignal chan<- string) (*CertificatesManager, error) {
@@ -436,6 +557,15 @@ func NewCertificatesManager(config CertificatesManagerConfig, updateSignal chan<
 		return nil, fmt.Errorf("failed to create certificate directory: %w", err)
 	}
 
+	var resolver *net.Resolver
+	if config.Resolver != "" {
+		var err error
+		resolver, err = helpers.NewDNSResolver(config.Resolver)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DNS resolver: %w", err)
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	clientManager, err := NewACMEClientManager(config.CertDir, config.TlsStaging)
@@ -452,12 +582,17 @@ func NewCertificatesManager(config CertificatesManagerConfig, updateSignal chan<
 
 	m := &CertificatesManager{
 		config:          config,
+		queue:           newIssuanceQueue(maxConcurrentIssuance),
 		ctx:             ctx,
 		cancel:          cancel,
 		clientManager:   clientManager,
 		challengeServer: challengeServer,
 		updateSignal:    updateSignal,
 		debouncer:       helpers.NewDebouncer(refreshDebounceDelay),
+		resolver:        resolver,
+	}
+	if config.CloudflareAPIToken != "" {
+		m.cloudflare = newCloudflareDNS(config.CloudflareAPIToken)
 	}
 
 	return m, nil
@@ -469,14 +604,18 @@ func (m *CertificatesManager) Stop() {
 	m.challengeServer.Stop()
 }
 
-func (cm *CertificatesManager) RefreshSync(logger *slog.Logger, domains []CertificatesDomain) error {
-	renewedDomains, err := cm.checkRenewals(logger, domains)
+// RefreshSync checks the certificates of domains and obtains those that are
+// missing, due for renewal or changed, and returns the outcome for each
+// domain. Domains are checked in parallel, and it waits only for other
+// refreshes of the same domains. The error joins those of failed domains.
+func (cm *CertificatesManager) RefreshSync(logger *slog.Logger, domains []CertificatesDomain) ([]CertificateResult, error) {
+	results := cm.checkRenewals(logger, domains)
 	// Signal even on partial failure so the proxy reloads the certificates
-	// that were renewed before the error.
-	if len(renewedDomains) > 0 && cm.updateSignal != nil {
+	// that were renewed.
+	if anyRenewed(results) && cm.updateSignal != nil {
 		cm.updateSignal <- "certificates_renewed"
 	}
-	return err
+	return results, resultsError(results)
 }
 
 // Refresh is used for periodic refreshes of certificates.
@@ -484,13 +623,13 @@ func (cm *CertificatesManager) Refresh(logger *slog.Logger, domains []Certificat
 	logger.Debug("Refresh requested for certificate manager, using debouncer.")
 
 	refreshAction := func() {
-		renewedDomains, err := cm.checkRenewals(logger, domains)
-		if err != nil {
+		results := cm.checkRenewals(logger, domains)
+		if err := resultsError(results); err != nil {
 			logger.Error("Certificate refresh failed", "error", err)
 		}
 		// Signal the update channel to reload certificates if any were renewed,
 		// even on partial failure.
-		if len(renewedDomains) > 0 {
+		if anyRenewed(results) {
 			if cm.updateSignal != nil {
 				cm.updateSignal <- "certificates_renewed"
 			}
@@ -500,14 +639,30 @@ func (cm *CertificatesManager) Refresh(logger *slog.Logger, domains []Certificat
 	cm.debouncer.Debounce(refreshDebounceKey, refreshAction)
 }
 
-func (cm *CertificatesManager) checkRenewals(logger *slog.Logger, domains []CertificatesDomain) (renewedDomains []CertificatesDomain, err error) {
-	cm.checkMutex.Lock()
-	defer func() {
-		cm.checkMutex.Unlock()
-	}()
+func anyRenewed(results []CertificateResult) bool {
+	for _, r := range results {
+		if r.Renewed {
+			return true
+		}
+	}
+	return false
+}
+
+func resultsError(results []CertificateResult) error {
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
 
+// checkRenewals checks every domain through the issuance queue, so one
+// misconfigured or slow domain neither fails nor delays the others.
+func (cm *CertificatesManager) checkRenewals(logger *slog.Logger, domains []CertificatesDomain) []CertificateResult {
 	if len(domains) == 0 {
-		return renewedDomains, nil
+		return nil
 	}
 
 	uniqueDomains := deduplicateDomains(domains)
@@ -537,58 +692,69 @@ func (cm *CertificatesManager) checkRenewals(logger *slog.Logger, domains []Cert
 		}
 	}
 
-	var errs []error
-	for canonical, domain := range currentState {
-		configChanged, err := cm.hasConfigurationChanged(logger, domain)
-		if err != nil {
-			logger.Error("Failed to check configuration", "domain", canonical, "error", err)
-			continue
-		}
+	desired := make([]CertificatesDomain, 0, len(currentState))
+	for _, canonical := range slices.Sorted(maps.Keys(currentState)) {
+		desired = append(desired, currentState[canonical])
+	}
+	return cm.queue.run(cm.ctx, desired, func(domain CertificatesDomain) CertificateResult {
+		return cm.checkDomain(logger, domain)
+	})
+}
 
-		// Check if certificate needs renewal due to expiry
-		needsRenewal, err := cm.needsRenewalDueToExpiry(logger, domain)
-		if err != nil {
-			logger.Error("Failed to check expiry", "domain", canonical, "error", err)
-			// Treat error as needing renewal to be safe
-			needsRenewal = true
-		}
-
-		// Obtain certificate if needed. Any existing certificate is kept on
-		// disk until saveCertificate atomically replaces it, so a failed
-		// obtain never leaves a domain without its previous certificate.
-		allDomains := []string{domain.Canonical}
-		allDomains = append(allDomains, domain.Aliases...)
-		if configChanged || needsRenewal {
-			requestMessage := "Requesting new certificate"
-			if len(allDomains) > 1 {
-				requestMessage = "Requesting new certificates"
-			}
-			logger.Info(requestMessage,
-				logging.AttrDomains, allDomains,
-				"domain", canonical,
-				"aliases", domain.Aliases)
-			obtainedDomain, err := cm.obtainCertificate(logger, domain)
-			if err != nil {
-				// Continue with the remaining domains; one misconfigured
-				// domain must not block renewals for the others.
-				logger.Error("Failed to obtain certificate", "domain", canonical, "error", err)
-				errs = append(errs, err)
-				continue
-			}
+// checkDomain obtains a certificate for domain if it has none, or its
+// certificate expires soon or no longer covers its aliases.
+func (cm *CertificatesManager) checkDomain(logger *slog.Logger, domain CertificatesDomain) CertificateResult {
+	canonical := domain.Canonical
+	result := CertificateResult{Domain: domain}
 
-			renewedDomains = append(renewedDomains, obtainedDomain)
-			logger.Info("Obtained new certificate",
-				logging.AttrDomains, allDomains,
-				"domain", canonical,
-				"aliases", domain.Aliases)
-		} else {
-			logger.Info(fmt.Sprintf("Certificate valid for %s", strings.Join(allDomains, ", ")),
-				"domain", canonical,
-				"aliases", domain.Aliases)
-		}
+	configChanged, err := cm.hasConfigurationChanged(logger, domain)
+	if err != nil {
+		logger.Error("Failed to check configuration", "domain", canonical, "error", err)
+		return result
 	}
 
-	return renewedDomains, errors.Join(errs...)
+	// Check if certificate needs renewal due to expiry
+	needsRenewal, err := cm.needsRenewalDueToExpiry(logger, domain)
+	if err != nil {
+		logger.Error("Failed to check expiry", "domain", canonical, "error", err)
+		// Treat error as needing renewal to be safe
+		needsRenewal = true
+	}
+
+	allDomains := []string{domain.Canonical}
+	allDomains = append(allDomains, domain.Aliases...)
+	if !configChanged && !needsRenewal {
+		logger.Info(fmt.Sprintf("Certificate valid for %s", strings.Join(allDomains, ", ")),
+			"domain", canonical,
+			"aliases", domain.Aliases)
+		return result
+	}
+
+	// Any existing certificate is kept on disk until saveCertificate
+	// atomically replaces it, so a failed obtain never leaves a domain
+	// without its previous certificate.
+	requestMessage := "Requesting new certificate"
+	if len(allDomains) > 1 {
+		requestMessage = "Requesting new certificates"
+	}
+	logger.Info(requestMessage,
+		logging.AttrDomains, allDomains,
+		"domain", canonical,
+		"aliases", domain.Aliases)
+	obtainedDomain, err := cm.obtainCertificate(logger, domain)
+	if err != nil {
+		logger.Error("Failed to obtain certificate", "domain", canonical, "error", err)
+		result.Err = err
+		return result
+	}
+
+	logger.Info("Obtained new certificate",
+		logging.AttrDomains, allDomains,
+		"domain", canonical,
+		"aliases", domain.Aliases)
+	result.Domain = obtainedDomain
+	result.Renewed = true
+	return result
 }
 
 // hasConfigurationChanged checks if the domain configuration has changed compared to existing certificate
@@ -662,9 +828,33 @@ func (cm *CertificatesManager) needsRenewalDueToExpiry(logger *slog.Logger, doma
 	return false, nil
 }
 
-func (cm *CertificatesManager) validateDomain(logger *slog.Logger, domain string) error {
+// validateDomain checks that domain resolves and points at this server. For
+// domains behind a CDN only resolution is checked, the records point at the
+// CDN.
+func (cm *CertificatesManager) validateDomain(logger *slog.Logger, domain, cdn string) error {
+	mode := cm.config.DNSCheck
+	if mode == config.DNSCheckOff {
+		return nil
+	}
+	// report fails the check in strict mode and only logs otherwise, for
+	// problems that a CDN or split-horizon DNS can explain.
+	report := func(msg string, args ...any) error {
+		if mode == config.DNSCheckStrict {
+			return fmt.Errorf("%s\n\nSet certificates.dns_check to warn or off in haloyd.yaml to request the certificate anyway", msg)
+		}
+		logger.Warn(msg, append([]any{"domain", domain}, args...)...)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
 	// Check if domain resolves
-	ips, err := net.LookupIP(domain)
+	resolver := net.DefaultResolver
+	if cm.resolver != nil {
+		resolver = cm.resolver
+	}
+	ips, err := resolver.LookupIP(ctx, "ip", domain)
 	if err != nil {
 		// Try to determine the specific issue
 		errorMessage := cm.buildDomainErrorMessage(domain, err)
@@ -676,43 +866,52 @@ func (cm *CertificatesManager) validateDomain(logger *slog.Logger, domain string
 		return fmt.Errorf(`domain %s has no IP addresses assigned
 
 Please add DNS records:
-- A record: %s → YOUR_SERVER_IP
-- Test with: dig A %s`, domain, domain, domain)
+- A record: %s → YOUR_SERVER_IPV4
+- AAAA record: %s → YOUR_SERVER_IPV6 (if the server has one)
+- Test with: dig A %s && dig AAAA %s`, domain, domain, domain, domain, domain)
 	}
 
-	// Check if domain points to this server. Resolve via public DNS-over-HTTPS
-	// so /etc/hosts entries and local resolver caches don't skew the result.
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-	domainIPs, err := helpers.ResolveDomainDoH(ctx, domain)
-	if err != nil {
+	if cdn != "" {
+		logger.Debug("Skipping server IP check for domain behind a CDN", "domain", domain, "cdn", cdn)
+		return nil
+	}
+
+	// Check if domain points to this server. Without a configured resolver,
+	// resolve via public DNS-over-HTTPS so /etc/hosts entries and local
+	// resolver caches don't skew the result.
+	var domainIPs []net.IP
+	if cm.resolver != nil {
+		domainIPs = helpers.FilterGlobalUnicastIPs(ips)
+	} else if domainIPs, err = helpers.ResolveDomainDoH(ctx, domain); err != nil {
 		// DoH unavailable; fall back to the system resolver result, ignoring
 		// loopback/link-local addresses that come from /etc/hosts.
 		domainIPs = helpers.FilterGlobalUnicastIPs(ips)
 		if len(domainIPs) == 0 {
-			logger.Warn("Domain only resolves to a local address on this server, likely from an /etc/hosts entry. Verify the public DNS A record points to this server.",
-				"domain", domain)
-			return nil
+			return report("Domain only resolves to a local address on this server, likely from an /etc/hosts entry. Verify the public DNS A/AAAA records point to this server.")
 		}
 	}
 	if len(domainIPs) == 0 {
-		// No A records in public DNS (e.g. IPv6-only domain); nothing to compare.
+		// No address records in public DNS; nothing to compare.
 		return nil
 	}
 
 	serverIPs, _ := helpers.GetLocalIPs()
-	if externalIP, err := helpers.GetExternalIP(); err == nil {
-		serverIPs = append(serverIPs, externalIP)
+	if externalIPs, err := helpers.GetExternalIPs(); err == nil {
+		serverIPs = append(serverIPs, externalIPs...)
 	}
 	if len(serverIPs) == 0 {
 		// Can't determine any server IP, skip this check
 		return nil
 	}
 
+	if onlyIPv6(domainIPs) && !slices.ContainsFunc(serverIPs, isIPv6) {
+		return report(fmt.Sprintf("Domain %s only has AAAA records but this server has no IPv6 address. Certificate validation and clients will fail to connect; add an A record or enable IPv6 on the server.", domain),
+			"domain_ips", formatIPList(domainIPs))
+	}
+
 	if !helpers.AnyIPMatch(domainIPs, serverIPs) {
-		logger.Warn(fmt.Sprintf("Domain %s resolves to %s but this server's addresses are %s. This is expected if using a CDN or proxy (e.g. Cloudflare). If not, update your DNS A record.",
+		return report(fmt.Sprintf("Domain %s resolves to %s but this server's addresses are %s. This is expected if using a CDN or proxy (e.g. Cloudflare). If not, update your DNS A/AAAA records.",
 			domain, formatIPList(domainIPs), formatIPList(serverIPs)),
-			"domain", domain,
 			"domain_ips", formatIPList(domainIPs),
 			"server_ips", formatIPList(serverIPs))
 	}
@@ -720,6 +919,14 @@ Please add DNS records:
 	return nil
 }
 
+func isIPv6(ip net.IP) bool {
+	return ip.To4() == nil
+}
+
+func onlyIPv6(ips []net.IP) bool {
+	return len(ips) > 0 && !slices.ContainsFunc(ips, func(ip net.IP) bool { return !isIPv6(ip) })
+}
+
 func formatIPList(ips []net.IP) string {
 	strs := make([]string, len(ips))
 	for i, ip := range ips {
@@ -732,7 +939,7 @@ func (cm *CertificatesManager) buildDomainErrorMessage(domain string, originalEr
 	errorStr := originalErr.Error()
 
 	if strings.Contains(errorStr, "NXDOMAIN") || strings.Contains(errorStr, "no such host") {
-		return fmt.Sprintf("Domain %s not found. Check if domain exists and DNS A record is configured.", domain)
+		return fmt.Sprintf("Domain %s not found. Check if domain exists and DNS A or AAAA records are configured.", domain)
 	}
 
 	if strings.Contains(errorStr, "timeout") {
@@ -750,7 +957,7 @@ func wrapAuthorizationError(domain string, err error) error {
 
 This error means Let's Encrypt could not reach your server.
 If using Cloudflare proxy (orange cloud), the origin IP may be incorrect.
-Otherwise, ensure your DNS A record points to this server's IP.
+Otherwise, ensure your DNS A/AAAA records point to this server's IPs.
 
 Original error: %w`, domain, err)
 	}
@@ -773,12 +980,26 @@ func (m *CertificatesManager) obtainCertificate(logger *slog.Logger, managedDoma
 	allDomains := append([]string{canonicalDomain}, aliases...)
 
 	for _, domain := range allDomains {
-		if err := m.validateDomain(logger, domain); err != nil {
+		if err := m.validateDomain(logger, domain, managedDomain.CDN); err != nil {
 			return obtainedDomain, fmt.Errorf("domain validation failed for %s: %w", domain, err)
 		}
 	}
 
-	certPEM, keyPEM, err := m.clientManager.ObtainCertificate(m.ctx, allDomains, m.challengeServer)
+	var dns dnsProvider
+	if managedDomain.CDN == constants.CDNCloudflare {
+		if m.cloudflare != nil {
+			dns = m.cloudflare
+		} else {
+			logger.Warn(fmt.Sprintf("No Cloudflare API token set, using HTTP-01 for %s. This fails if Cloudflare redirects HTTP to HTTPS; set %s for haloyd to use DNS-01 instead.",
+				canonicalDomain, constants.EnvVarCloudflareAPIToken), "domain", canonicalDomain)
+		}
+	}
+
+	if err := m.checkIssuanceBackoff(canonicalDomain); err != nil {
+		return obtainedDomain, err
+	}
+	certPEM, keyPEM, err := m.clientManager.ObtainCertificate(m.ctx, allDomains, m.challengeServer, dns)
+	m.recordIssuance(logger, canonicalDomain, err)
 	if err != nil {
 		return obtainedDomain, fmt.Errorf("failed to obtain certificate for %s: %w", canonicalDomain, err)
 	}
@@ -790,24 +1011,29 @@ func (m *CertificatesManager) obtainCertificate(logger *slog.Logger, managedDoma
 	obtainedDomain = CertificatesDomain{
 		Canonical: canonicalDomain,
 		Aliases:   aliases,
+		CDN:       managedDomain.CDN,
 	}
 
 	return obtainedDomain, nil
 }
 
+// saveCertificate records the certificate in the history, then atomically
+// replaces the file the proxy serves. If haloyd stops in between,
+// SyncCertificateFiles writes the file on the next start.
 func (m *CertificatesManager) saveCertificate(domain string, keyPEM, certPEM []byte) error {
+	if m.config.DB != nil {
+		if err := m.recordCertificate(domain, keyPEM, certPEM); err != nil {
+			return err
+		}
+	}
+	return m.writeCertificateFile(domain, combinedPEM(keyPEM, certPEM))
+}
+
+func (m *CertificatesManager) writeCertificateFile(domain string, data []byte) error {
 	combinedPath := filepath.Join(m.config.CertDir, domain+combinedCertExt)
 	tmpPath := combinedPath + ".tmp"
 
-	pemContent := bytes.Buffer{}
-
-	pemContent.Write(keyPEM)
-	if len(keyPEM) > 0 && keyPEM[len(keyPEM)-1] != '\n' {
-		pemContent.WriteByte('\n')
-	}
-
-	pemContent.Write(certPEM)
-	if err := os.WriteFile(tmpPath, pemContent.Bytes(), constants.ModeFileSecret); err != nil {
+	if err := os.WriteFile(tmpPath, data, constants.ModeFileSecret); err != nil {
 		return fmt.Errorf("failed to save temporary combined certificate/key: %w", err)
 	}
 
@@ -863,6 +1089,7 @@ func (m *CertificatesManager) CleanupExpiredCertificates(logger *slog.Logger, do
 			if time.Now().After(parsedCert.NotAfter) && !isManaged {
 				logger.Debug("Deleting expired certificate files for unmanaged domain", "domain", domain)
 				os.Remove(combinedCertPath)
+				m.retireCertificate(logger, domain)
 				deleted++
 			}
 		}
@@ -871,6 +1098,25 @@ func (m *CertificatesManager) CleanupExpiredCertificates(logger *slog.Logger, do
 	logger.Debug("Certificate cleanup complete. Deleted expired/orphaned certificate sets for unmanaged domains")
 }
 
+// RemoveCertificates deletes the certificate files of the given canonical
+// domains and retires them in the history. It returns the domains that had a
+// certificate.
+func (m *CertificatesManager) RemoveCertificates(logger *slog.Logger, domains []string) []string {
+	var removed []string
+	for _, domain := range domains {
+		err := os.Remove(filepath.Join(m.config.CertDir, domain+combinedCertExt))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logger.Warn("Failed to remove certificate", "domain", domain, "error", err)
+			}
+			continue
+		}
+		m.retireCertificate(logger, domain)
+		removed = append(removed, domain)
+	}
+	return removed
+}
+
 // parseCertificate takes PEM encoded certificate data and returns the parsed x509.Certificate
 func parseCertificate(certData []byte) (*x509.Certificate, error) {
 	var block *pem.Block
@@ -891,15 +1137,38 @@ func parseCertificate(certData []byte) (*x509.Certificate, error) {
 	return nil, fmt.Errorf("no CERTIFICATE PEM block found")
 }
 
+// deduplicateDomains merges entries that share a canonical domain. Apps that
+// split a domain by path prefix each list it, possibly with different
+// aliases; one certificate covering every alias serves all of them.
 func deduplicateDomains(domains []CertificatesDomain) []CertificatesDomain {
-	seen := make(map[string]bool)
+	index := make(map[string]int)
 	var unique []CertificatesDomain
 
 	for _, domain := range domains {
-		if !seen[domain.Canonical] {
-			seen[domain.Canonical] = true
-			unique = append(unique, domain)
+		i, seen := index[domain.Canonical]
+		if !seen {
+			index[domain.Canonical] = len(unique)
+			unique = append(unique, CertificatesDomain{
+				Canonical: domain.Canonical,
+				Aliases:   slices.Clone(domain.Aliases),
+				CDN:       domain.CDN,
+			})
+			continue
+		}
+		if unique[i].CDN == "" {
+			unique[i].CDN = domain.CDN
 		}
+		for _, alias := range domain.Aliases {
+			if !slices.Contains(unique[i].Aliases, alias) {
+				unique[i].Aliases = append(unique[i].Aliases, alias)
+			}
+		}
+	}
+
+	// Deployments are collected from a map; sorted aliases keep the
+	// certificate's name set stable so it isn't reissued on every pass.
+	for i := range unique {
+		sort.Strings(unique[i].Aliases)
 	}
 
 	return unique
@@ -15,6 +15,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/haloydev/haloy/internal/config"
 )
 
 // newTestCertificatesManager creates a manager backed by a temp cert dir. The
@@ -47,7 +49,8 @@ func TestCheckRenewalsContinuesPastFailedDomain(t *testing.T) {
 		{Canonical: "haloy-test-b.invalid"},
 	}
 
-	renewed, err := m.checkRenewals(logger, domains)
+	results := m.checkRenewals(logger, domains)
+	renewed, err := renewedDomains(results), resultsError(results)
 	if err == nil {
 		t.Fatal("checkRenewals() expected error for unresolvable domains, got nil")
 	}
@@ -73,9 +76,10 @@ func TestCheckRenewalsKeepsCertOnFailedObtain(t *testing.T) {
 	certPath := writeCombinedTestCert(t, m.config.CertDir, canonical)
 
 	// Adding an alias changes the required SAN set, forcing a re-obtain.
-	renewed, err := m.checkRenewals(logger, []CertificatesDomain{
+	results := m.checkRenewals(logger, []CertificatesDomain{
 		{Canonical: canonical, Aliases: []string{"www." + canonical}},
 	})
+	renewed, err := renewedDomains(results), resultsError(results)
 	if err == nil {
 		t.Fatal("checkRenewals() expected error for unresolvable domain, got nil")
 	}
@@ -87,6 +91,16 @@ func TestCheckRenewalsKeepsCertOnFailedObtain(t *testing.T) {
 	}
 }
 
+func renewedDomains(results []CertificateResult) []CertificatesDomain {
+	var renewed []CertificatesDomain
+	for _, r := range results {
+		if r.Renewed {
+			renewed = append(renewed, r.Domain)
+		}
+	}
+	return renewed
+}
+
 // writeCombinedTestCert writes a combined key+certificate PEM file for domain
 // in the layout the certificate manager uses, and returns its path.
 func writeCombinedTestCert(t *testing.T, dir, domain string) string {
@@ -135,3 +149,69 @@ func writeCombinedTestCert(t *testing.T, dir, domain string) string {
 	}
 	return certPath
 }
+
+func TestDeduplicateDomainsMergesAliases(t *testing.T) {
+	domains := []CertificatesDomain{
+		{Canonical: "example.com", Aliases: []string{"www.example.com"}},
+		{Canonical: "other.com"},
+		{Canonical: "example.com", Aliases: []string{"api.example.com", "www.example.com"}},
+	}
+
+	got := deduplicateDomains(domains)
+	if len(got) != 2 {
+		t.Fatalf("got %d domains, want 2: %+v", len(got), got)
+	}
+	if got[0].Canonical != "example.com" || strings.Join(got[0].Aliases, ",") != "api.example.com,www.example.com" {
+		t.Errorf("merged domain = %+v, want example.com with sorted union of aliases", got[0])
+	}
+	if len(domains[0].Aliases) != 1 {
+		t.Errorf("input aliases were modified: %v", domains[0].Aliases)
+	}
+}
+
+func TestDeduplicateDomainsKeepsCDN(t *testing.T) {
+	got := deduplicateDomains([]CertificatesDomain{
+		{Canonical: "example.com"},
+		{Canonical: "example.com", CDN: "cloudflare"},
+	})
+	if len(got) != 1 || got[0].CDN != "cloudflare" {
+		t.Errorf("deduplicateDomains() = %+v, want one cloudflare domain", got)
+	}
+}
+
+func TestValidateDomainDNSCheckOff(t *testing.T) {
+	m := newTestCertificatesManager(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// The .invalid TLD never resolves, so only a skipped check passes.
+	if err := m.validateDomain(logger, "app.invalid", ""); err == nil {
+		t.Fatal("validateDomain() with the default check should fail for an unresolvable domain")
+	}
+	m.config.DNSCheck = config.DNSCheckOff
+	if err := m.validateDomain(logger, "app.invalid", ""); err != nil {
+		t.Fatalf("validateDomain() with dns_check off error = %v", err)
+	}
+}
+
+func TestNewCertificatesManagerResolver(t *testing.T) {
+	m, err := NewCertificatesManager(CertificatesManagerConfig{
+		CertDir:          t.TempDir(),
+		HTTPProviderPort: "0",
+		Resolver:         "1.1.1.1",
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewCertificatesManager() error = %v", err)
+	}
+	t.Cleanup(m.Stop)
+	if m.resolver == nil {
+		t.Error("expected a resolver when one is configured")
+	}
+
+	if _, err := NewCertificatesManager(CertificatesManagerConfig{
+		CertDir:          t.TempDir(),
+		HTTPProviderPort: "0",
+		Resolver:         "dns.example.com",
+	}, nil); err == nil {
+		t.Error("expected an error for a resolver that isn't an IP address")
+	}
+}
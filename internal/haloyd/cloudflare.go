@@ -0,0 +1,139 @@
+package haloyd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const cloudflareAPIBaseURL = "https://api.cloudflare.com/client/v4"
+
+// cloudflareDNS creates and removes the TXT records of DNS-01 challenges
+// through the Cloudflare API.
+type cloudflareDNS struct {
+	token   string
+	baseURL string
+	client  *http.Client
+}
+
+func newCloudflareDNS(token string) *cloudflareDNS {
+	return &cloudflareDNS{
+		token:   token,
+		baseURL: cloudflareAPIBaseURL,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+func (c *cloudflareDNS) do(ctx context.Context, method, path string, body, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var parsed cloudflareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("cloudflare API returned %s with an unreadable body: %w", resp.Status, err)
+	}
+	if !parsed.Success {
+		messages := make([]string, len(parsed.Errors))
+		for i, e := range parsed.Errors {
+			messages[i] = fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+		}
+		return fmt.Errorf("cloudflare API returned %s: %s", resp.Status, strings.Join(messages, "; "))
+	}
+	if result != nil {
+		return json.Unmarshal(parsed.Result, result)
+	}
+	return nil
+}
+
+// zoneID finds the zone holding domain, trying each parent domain in turn
+// so subdomains resolve to the zone they are managed in.
+func (c *cloudflareDNS) zoneID(ctx context.Context, domain string) (string, error) {
+	labels := strings.Split(strings.TrimSuffix(domain, "."), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		name := strings.Join(labels[i:], ".")
+		var zones []struct {
+			ID string `json:"id"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/zones?name="+url.QueryEscape(name), nil, &zones); err != nil {
+			return "", err
+		}
+		if len(zones) > 0 {
+			return zones[0].ID, nil
+		}
+	}
+	return "", fmt.Errorf("no Cloudflare zone found for %s, check that the API token can read it", domain)
+}
+
+// Present creates the challenge TXT record for domain. The returned cleanup
+// removes it and is safe to call when Present failed.
+func (c *cloudflareDNS) Present(ctx context.Context, domain, value string) (cleanup func(), err error) {
+	cleanup = func() {}
+	zoneID, err := c.zoneID(ctx, domain)
+	if err != nil {
+		return cleanup, err
+	}
+
+	record := cloudflareRecord{
+		Type:    "TXT",
+		Name:    "_acme-challenge." + domain,
+		Content: value,
+		TTL:     120,
+	}
+	var created cloudflareRecord
+	if err := c.do(ctx, http.MethodPost, "/zones/"+zoneID+"/dns_records", record, &created); err != nil {
+		return cleanup, fmt.Errorf("failed to create TXT record %s: %w", record.Name, err)
+	}
+	if created.ID == "" {
+		return cleanup, errors.New("cloudflare API did not return the created record")
+	}
+
+	cleanup = func() {
+		// The challenge context may be done; removal gets its own deadline.
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		c.do(ctx, http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+created.ID, nil, nil)
+	}
+	return cleanup, nil
+}
@@ -0,0 +1,74 @@
+package haloyd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCloudflareDNSPresent(t *testing.T) {
+	var created cloudflareRecord
+	deleted := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("Authorization = %q, want bearer token", got)
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/zones":
+			// Only the registrable domain is a zone.
+			if r.URL.Query().Get("name") == "example.com" {
+				w.Write([]byte(`{"success":true,"result":[{"id":"zone-1"}]}`))
+				return
+			}
+			w.Write([]byte(`{"success":true,"result":[]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/zones/zone-1/dns_records":
+			if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+				t.Errorf("decode record: %v", err)
+			}
+			w.Write([]byte(`{"success":true,"result":{"id":"record-1"}}`))
+		case r.Method == http.MethodDelete:
+			deleted = r.URL.Path
+			w.Write([]byte(`{"success":true,"result":{"id":"record-1"}}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := newCloudflareDNS("test-token")
+	c.baseURL = server.URL
+
+	cleanup, err := c.Present(context.Background(), "app.example.com", "challenge-value")
+	if err != nil {
+		t.Fatalf("Present() error = %v", err)
+	}
+	if created.Type != "TXT" || created.Name != "_acme-challenge.app.example.com" || created.Content != "challenge-value" {
+		t.Errorf("created record = %+v", created)
+	}
+
+	cleanup()
+	if deleted != "/zones/zone-1/dns_records/record-1" {
+		t.Errorf("deleted = %q, want the created record", deleted)
+	}
+}
+
+func TestCloudflareDNSErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"success":false,"errors":[{"code":10000,"message":"Authentication error"}]}`))
+	}))
+	defer server.Close()
+
+	c := newCloudflareDNS("bad-token")
+	c.baseURL = server.URL
+
+	cleanup, err := c.Present(context.Background(), "example.com", "value")
+	if err == nil || !strings.Contains(err.Error(), "Authentication error (code 10000)") {
+		t.Fatalf("Present() error = %v, want the API error", err)
+	}
+	cleanup()
+}
@@ -0,0 +1,273 @@
+package haloyd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/proxy"
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+const (
+	// clusterPeerTimeout bounds one request for a peer's routes.
+	clusterPeerTimeout = 5 * time.Second
+	// clusterPeerFall is how many polls in a row a peer must fail before its
+	// routes are dropped, so a single slow answer doesn't move traffic.
+	clusterPeerFall = 2
+)
+
+// ClusterEdge polls the peers of an edge server for the routes they serve.
+// A peer that stops answering is left out until it answers again, so its
+// domains fail over to the other servers serving them.
+type ClusterEdge struct {
+	peers    []config.ClusterPeer
+	client   *http.Client
+	scheme   string
+	interval time.Duration
+	logger   *slog.Logger
+	onChange func()
+
+	mu       sync.Mutex
+	routes   map[string][]proxywire.Route // by peer name, answering peers only
+	failures map[string]int
+}
+
+// peerRoutes are the routes one peer serves and the backend reaching it.
+type peerRoutes struct {
+	Backend proxywire.Backend
+	Routes  []proxywire.Route
+}
+
+// NewClusterEdge returns an edge polling the peers in clusterConfig. onChange
+// is called when the routes of the peers change.
+func NewClusterEdge(clusterConfig config.ClusterConfig, logger *slog.Logger, onChange func()) (*ClusterEdge, error) {
+	settings, err := proxy.LoadClusterSettings("", clusterConfig.CAFile, clusterConfig.CertFile, clusterConfig.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "http"
+	tlsConfig := settings.ClientTLS()
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	return &ClusterEdge{
+		peers: clusterConfig.Peers,
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+			Timeout:   clusterPeerTimeout,
+		},
+		scheme:   scheme,
+		interval: clusterConfig.GetPollInterval(),
+		logger:   logger,
+		onChange: onChange,
+		routes:   make(map[string][]proxywire.Route),
+		failures: make(map[string]int),
+	}, nil
+}
+
+// Run polls the peers until ctx is cancelled.
+func (e *ClusterEdge) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	e.poll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.poll(ctx)
+		}
+	}
+}
+
+// poll asks every peer for its routes once.
+func (e *ClusterEdge) poll(ctx context.Context) {
+	type result struct {
+		routes []proxywire.Route
+		err    error
+	}
+	results := make([]result, len(e.peers))
+	var wg sync.WaitGroup
+	for i, peer := range e.peers {
+		wg.Go(func() {
+			routes, err := e.fetch(ctx, peer)
+			results[i] = result{routes: routes, err: err}
+		})
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	e.mu.Lock()
+	changed := false
+	for i, peer := range e.peers {
+		old, wasUp := e.routes[peer.Name]
+		if err := results[i].err; err != nil {
+			e.failures[peer.Name]++
+			if wasUp && e.failures[peer.Name] >= clusterPeerFall {
+				e.logger.Warn("Cluster peer is down, routing around it", "peer", peer.Name, "error", err)
+				delete(e.routes, peer.Name)
+				changed = true
+			} else if !wasUp && e.failures[peer.Name] == 1 {
+				e.logger.Warn("Cluster peer is unreachable", "peer", peer.Name, "error", err)
+			}
+			continue
+		}
+		e.failures[peer.Name] = 0
+		if !wasUp {
+			e.logger.Info("Cluster peer is up", "peer", peer.Name, "routes", len(results[i].routes))
+		}
+		if !wasUp || !reflect.DeepEqual(old, results[i].routes) {
+			e.routes[peer.Name] = results[i].routes
+			changed = true
+		}
+	}
+	e.mu.Unlock()
+
+	if changed && e.onChange != nil {
+		e.onChange()
+	}
+}
+
+func (e *ClusterEdge) fetch(ctx context.Context, peer config.ClusterPeer) ([]proxywire.Route, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.scheme+"://"+peer.Address+proxywire.PeerRoutesPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var peerRoutes proxywire.PeerRoutes
+	if err := json.NewDecoder(resp.Body).Decode(&peerRoutes); err != nil {
+		return nil, fmt.Errorf("failed to decode routes: %w", err)
+	}
+	return peerRoutes.Routes, nil
+}
+
+// routesByPeer returns the routes of the answering peers, in the order the
+// peers are configured.
+func (e *ClusterEdge) routesByPeer() []peerRoutes {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var peers []peerRoutes
+	for _, peer := range e.peers {
+		routes, ok := e.routes[peer.Name]
+		if !ok {
+			continue
+		}
+		host, port, _ := net.SplitHostPort(peer.Address)
+		peers = append(peers, peerRoutes{
+			Backend: proxywire.Backend{IP: host, Port: port, Peer: peer.Name},
+			Routes:  routes,
+		})
+	}
+	return peers
+}
+
+// certificateDomains returns the domains of the peers' routes. The edge
+// terminates TLS for them, so it holds their certificates.
+func (e *ClusterEdge) certificateDomains() []CertificatesDomain {
+	var domains []CertificatesDomain
+	seen := make(map[string]struct{})
+	for _, peer := range e.routesByPeer() {
+		for _, route := range peer.Routes {
+			canonical := strings.ToLower(route.Canonical)
+			if _, ok := seen[canonical]; ok {
+				continue
+			}
+			seen[canonical] = struct{}{}
+			domains = append(domains, CertificatesDomain{Canonical: canonical, Aliases: route.Aliases, CDN: route.CDN})
+		}
+	}
+	return domains
+}
+
+// addPeerRoutes adds the routes of cluster peers to routes. A route this
+// server serves too gets the peer as another backend; a route only peers
+// serve is added with them as its backends. A peer route claiming a domain
+// another route owns is left out, as the proxy would reject the snapshot.
+func addPeerRoutes(routes []proxywire.Route, peers []peerRoutes, routed map[string]struct{}) []proxywire.Route {
+	index := make(map[string]int, len(routes))
+	owner := make(map[string]string, len(routes))
+	for i, route := range routes {
+		index[route.Canonical+route.PathPrefix] = i
+		owner[route.Canonical] = route.Canonical
+		for _, alias := range route.Aliases {
+			owner[alias] = route.Canonical
+		}
+	}
+
+	for _, peer := range peers {
+		for _, route := range peer.Routes {
+			route.Canonical = strings.ToLower(route.Canonical)
+			if !canOwn(owner, route) {
+				continue
+			}
+			if i, ok := index[route.Canonical+route.PathPrefix]; ok {
+				// The routes of one deployment share their backends slice.
+				routes[i].Backends = append(slices.Clip(routes[i].Backends), peer.Backend)
+				continue
+			}
+			route.Backends = []proxywire.Backend{peer.Backend}
+			index[route.Canonical+route.PathPrefix] = len(routes)
+			routes = append(routes, route)
+			owner[route.Canonical] = route.Canonical
+			routed[route.Canonical] = struct{}{}
+			for _, alias := range route.Aliases {
+				owner[alias] = route.Canonical
+				routed[alias] = struct{}{}
+			}
+		}
+	}
+	return routes
+}
+
+// canOwn reports whether route's domains are free or already belong to its
+// canonical domain.
+func canOwn(owner map[string]string, route proxywire.Route) bool {
+	if o, ok := owner[route.Canonical]; ok && o != route.Canonical {
+		return false
+	}
+	for _, alias := range route.Aliases {
+		if o, ok := owner[alias]; ok && o != route.Canonical {
+			return false
+		}
+	}
+	return true
+}
+
+// appendNewCertificateDomains appends the domains of extra whose canonical
+// domain isn't in domains yet, so a domain served both here and by a peer
+// gets one certificate.
+func appendNewCertificateDomains(domains, extra []CertificatesDomain) []CertificatesDomain {
+	have := make(map[string]struct{}, len(domains))
+	for _, domain := range domains {
+		have[strings.ToLower(domain.Canonical)] = struct{}{}
+	}
+	for _, domain := range extra {
+		if _, ok := have[domain.Canonical]; !ok {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
@@ -0,0 +1,108 @@
+package haloyd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/proxy"
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+func TestBuildSnapshotPeerRoutes(t *testing.T) {
+	deployments := map[string]Deployment{
+		"web": {
+			Labels: &config.ContainerLabels{AppName: "web", Domains: []config.Domain{
+				{Canonical: "web.example.com"},
+				{Canonical: "www.example.com"},
+			}},
+			Instances: []DeploymentInstance{{IP: "10.0.0.3", Port: "8080"}},
+		},
+	}
+	edge := &ClusterEdge{
+		peers: []config.ClusterPeer{{Name: "b", Address: "10.1.0.2:8443"}},
+		routes: map[string][]proxywire.Route{"b": {
+			{Canonical: "web.example.com"},
+			{Canonical: "api.example.com", Aliases: []string{"api2.example.com"}},
+			// Claims an alias of a route this server owns.
+			{Canonical: "other.example.com", Aliases: []string{"www.example.com"}},
+		}},
+	}
+	snap := buildSnapshot(deployments, nil, snapshotSettings{Edge: edge}, nil)
+
+	byDomain := make(map[string]proxywire.Route)
+	for _, route := range snap.Routes {
+		byDomain[route.Canonical] = route
+	}
+	peer := proxywire.Backend{IP: "10.1.0.2", Port: "8443", Peer: "b"}
+
+	if got := byDomain["web.example.com"].Backends; len(got) != 2 || got[1] != peer {
+		t.Errorf("web.example.com backends = %v, want the local instance and the peer", got)
+	}
+	if got := byDomain["www.example.com"].Backends; len(got) != 1 {
+		t.Errorf("www.example.com backends = %v, want only the local instance", got)
+	}
+	if got := byDomain["api.example.com"]; len(got.Backends) != 1 || got.Backends[0] != peer || len(got.Aliases) != 1 {
+		t.Errorf("api.example.com = %+v, want the peer's route", got)
+	}
+	if _, ok := byDomain["other.example.com"]; ok {
+		t.Error("peer route claiming a local alias must be left out")
+	}
+	if _, err := proxy.ConfigFromSnapshot(snap); err != nil {
+		t.Errorf("ConfigFromSnapshot() error = %v, want a valid snapshot", err)
+	}
+}
+
+func TestClusterEdgePollFailover(t *testing.T) {
+	var failing atomic.Bool
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(proxywire.PeerRoutes{Routes: []proxywire.Route{{Canonical: "app.example.com"}}})
+	}))
+	defer peer.Close()
+
+	changes := 0
+	edge, err := NewClusterEdge(config.ClusterConfig{
+		Edge:  new(true),
+		Peers: []config.ClusterPeer{{Name: "b", Address: strings.TrimPrefix(peer.URL, "http://")}},
+	}, discardLogger(), func() { changes++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edge.poll(t.Context())
+	if changes != 1 || len(edge.routesByPeer()) != 1 {
+		t.Fatalf("after first poll changes = %d peers = %v, want the peer's routes", changes, edge.routesByPeer())
+	}
+	if domains := edge.certificateDomains(); len(domains) != 1 || domains[0].Canonical != "app.example.com" {
+		t.Errorf("certificateDomains() = %v, want app.example.com", domains)
+	}
+
+	edge.poll(t.Context())
+	if changes != 1 {
+		t.Errorf("changes = %d after an unchanged poll, want 1", changes)
+	}
+
+	failing.Store(true)
+	edge.poll(t.Context())
+	if len(edge.routesByPeer()) != 1 {
+		t.Error("peer dropped after one failed poll, want it kept until it fails again")
+	}
+	edge.poll(t.Context())
+	if changes != 2 || len(edge.routesByPeer()) != 0 {
+		t.Errorf("after two failed polls changes = %d peers = %v, want the peer dropped", changes, edge.routesByPeer())
+	}
+
+	failing.Store(false)
+	edge.poll(t.Context())
+	if changes != 3 || len(edge.routesByPeer()) != 1 {
+		t.Errorf("after recovery changes = %d peers = %v, want the peer back", changes, edge.routesByPeer())
+	}
+}
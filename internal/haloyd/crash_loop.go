@@ -0,0 +1,169 @@
+package haloyd
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/logging"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+// failureReasonCrashLoop is the FailedContainer reason for containers taken
+// out of rotation because Docker keeps restarting them.
+const failureReasonCrashLoop = "crash looping"
+
+// CrashLoopDetector records the restarts Docker's restart policy makes and
+// flags containers restarting too often as crash looping, so they stop
+// getting traffic instead of flapping in and out of rotation.
+type CrashLoopDetector struct {
+	db       *storage.DB
+	restarts int
+	window   time.Duration
+	logger   *slog.Logger
+	// recheck asks for a deployment update once a crash loop may have ended.
+	recheck chan<- struct{}
+
+	mu sync.Mutex
+	// exitCodes holds the exit code of each container's last run, from the
+	// die event that precedes a restart.
+	exitCodes map[string]int
+	// looping holds the containers reported as crash looping.
+	looping map[string]struct{}
+	now     func() time.Time
+}
+
+func NewCrashLoopDetector(db *storage.DB, cfg config.CrashLoopConfig, recheck chan<- struct{}, logger *slog.Logger) *CrashLoopDetector {
+	return &CrashLoopDetector{
+		db:        db,
+		restarts:  cfg.GetRestarts(),
+		window:    cfg.GetWindow(),
+		logger:    logger,
+		recheck:   recheck,
+		exitCodes: make(map[string]int),
+		looping:   make(map[string]struct{}),
+		now:       time.Now,
+	}
+}
+
+// ObserveDie remembers the exit code reported by a die event.
+func (d *CrashLoopDetector) ObserveDie(containerID string, attributes map[string]string) {
+	exitCode, err := strconv.Atoi(attributes["exitCode"])
+	if err != nil {
+		return
+	}
+	d.mu.Lock()
+	d.exitCodes[containerID] = exitCode
+	d.mu.Unlock()
+}
+
+// ObserveStart records a restart when Docker started info's container again
+// after it exited, and reports the crash loop event the first time the
+// container crosses the restart threshold.
+func (d *CrashLoopDetector) ObserveStart(info container.InspectResponse, labels *config.ContainerLabels) {
+	if info.ContainerJSONBase == nil || info.RestartCount == 0 {
+		return
+	}
+	containerID := info.ID
+	d.mu.Lock()
+	exitCode := d.exitCodes[containerID]
+	delete(d.exitCodes, containerID)
+	d.mu.Unlock()
+
+	now := d.now()
+	recorded, err := d.db.RecordContainerRestart(storage.ContainerRestart{
+		ContainerID:  containerID,
+		RestartCount: info.RestartCount,
+		AppName:      labels.AppName,
+		DeploymentID: labels.DeploymentID,
+		ExitCode:     exitCode,
+		RestartedAt:  now,
+	})
+	if err != nil {
+		d.logger.Warn("Failed to record container restart", "container_id", helpers.SafeIDPrefix(containerID), "error", err)
+		return
+	}
+	if !recorded {
+		return
+	}
+
+	restarts, looping := d.Restarts(containerID)
+	if !looping {
+		return
+	}
+	// Check again once this restart falls out of the window, so a container
+	// that stopped crashing gets traffic back without waiting for an event.
+	time.AfterFunc(d.window, d.requestRecheck)
+
+	d.mu.Lock()
+	_, reported := d.looping[containerID]
+	d.looping[containerID] = struct{}{}
+	d.mu.Unlock()
+	if !reported {
+		logging.LogCrashLoop(d.logger, labels.AppName, labels.DeploymentID, containerID, restarts, d.window,
+			fmt.Sprintf("Container %s of %s is crash looping: restarted %d times in %s (last exit code %d), no longer routing traffic to it",
+				helpers.SafeIDPrefix(containerID), labels.AppName, restarts, shortDuration(d.window), exitCode))
+	}
+}
+
+// IsCrashLooping is Restarts for deciding whether the container gets
+// traffic, noting when a reported crash loop has ended.
+func (d *CrashLoopDetector) IsCrashLooping(containerID string) (restarts int, looping bool) {
+	restarts, looping = d.Restarts(containerID)
+	if looping {
+		return restarts, true
+	}
+	d.mu.Lock()
+	_, reported := d.looping[containerID]
+	delete(d.looping, containerID)
+	d.mu.Unlock()
+	if reported {
+		d.logger.Info("Container stopped crash looping, routing traffic to it again",
+			"container_id", helpers.SafeIDPrefix(containerID), "restarts", restarts)
+	}
+	return restarts, false
+}
+
+// Restarts returns how often a container restarted within the window, and
+// whether that makes it crash looping.
+func (d *CrashLoopDetector) Restarts(containerID string) (int, bool) {
+	restarts, err := d.db.CountContainerRestarts(containerID, d.now().Add(-d.window))
+	if err != nil {
+		d.logger.Warn("Failed to count container restarts", "container_id", helpers.SafeIDPrefix(containerID), "error", err)
+		return 0, false
+	}
+	return restarts, restarts >= d.restarts
+}
+
+// Prune forgets restarts that no longer count towards a crash loop, and
+// crash loops of containers that are gone.
+func (d *CrashLoopDetector) Prune() {
+	if _, err := d.db.PruneContainerRestarts(d.now().Add(-d.window)); err != nil {
+		d.logger.Warn("Failed to prune container restarts", "error", err)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for containerID := range d.looping {
+		if restarts, _ := d.db.CountContainerRestarts(containerID, time.Time{}); restarts == 0 {
+			delete(d.looping, containerID)
+		}
+	}
+}
+
+// Window is the time restarts are counted in.
+func (d *CrashLoopDetector) Window() time.Duration {
+	return d.window
+}
+
+func (d *CrashLoopDetector) requestRecheck() {
+	select {
+	case d.recheck <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,58 @@
+package haloyd
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/haloydev/haloy/internal/config"
+)
+
+func TestCrashLoopDetector(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	detector := NewCrashLoopDetector(newTestCertificatesDB(t), config.CrashLoopConfig{Restarts: 3, Window: "10m"}, make(chan struct{}, 1), logger)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	detector.now = func() time.Time { return now }
+
+	labels := &config.ContainerLabels{AppName: "app", DeploymentID: "dep-1"}
+	restart := func(count int) {
+		detector.ObserveDie("abc123", map[string]string{"exitCode": "1"})
+		detector.ObserveStart(container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: "abc123", RestartCount: count},
+		}, labels)
+	}
+
+	// The first start isn't a restart.
+	restart(0)
+	restart(1)
+	restart(2)
+	// Repeated events for the same restart count once.
+	restart(2)
+	if restarts, looping := detector.IsCrashLooping("abc123"); looping || restarts != 2 {
+		t.Fatalf("IsCrashLooping() = %d, %v, want 2 restarts and no crash loop", restarts, looping)
+	}
+
+	restart(3)
+	if restarts, looping := detector.IsCrashLooping("abc123"); !looping || restarts != 3 {
+		t.Fatalf("IsCrashLooping() = %d, %v, want a crash loop after 3 restarts", restarts, looping)
+	}
+	if !strings.Contains(logs.String(), "crashLoop=true") || !strings.Contains(logs.String(), "last exit code 1") {
+		t.Errorf("crash loop event not logged:\n%s", logs.String())
+	}
+	if _, looping := detector.IsCrashLooping("other"); looping {
+		t.Error("container without restarts reported as crash looping")
+	}
+
+	now = now.Add(11 * time.Minute)
+	if _, looping := detector.IsCrashLooping("abc123"); looping {
+		t.Error("crash loop didn't end once the restarts left the window")
+	}
+	detector.Prune()
+	if restarts, _ := detector.Restarts("abc123"); restarts != 0 {
+		t.Errorf("Restarts() = %d after prune, want 0", restarts)
+	}
+}
@@ -0,0 +1,43 @@
+package haloyd
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/dbbackup"
+)
+
+// databaseBackupTimeout bounds one scheduled backup of one database.
+const databaseBackupTimeout = time.Hour
+
+// runDatabaseBackups backs up every running managed database each interval
+// until ctx is done.
+func runDatabaseBackups(ctx context.Context, cli *client.Client, backups *dbbackup.Backups, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		containers, err := dbbackup.Containers(ctx, cli)
+		if err != nil {
+			logger.Warn("Skipping database backups", "error", err)
+			continue
+		}
+		for appName, containerID := range containers {
+			backupCtx, cancel := context.WithTimeout(ctx, databaseBackupTimeout)
+			backup, err := backups.Backup(backupCtx, cli, appName, containerID)
+			cancel()
+			if err != nil {
+				logger.Error("Database backup failed", "app", appName, "error", err)
+				continue
+			}
+			logger.Info("Database backed up", "app", appName, "backup", backup.Name, "bytes", backup.Size)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package haloyd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/deploystate"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/logging"
+)
+
+// deploymentFlow takes a deployment from starting to live or failed. The API
+// records a deployment as pending and starting while it pulls the image and
+// starts the containers; the Docker events those containers raise arrive here
+// debounced. The update they trigger promotes the deployment to live before
+// routing it, which a deployment failed meanwhile can't be, and the flow
+// records failures and cleans up after them.
+type deploymentFlow struct {
+	updater   *Updater
+	queue     *updateQueue
+	cli       *client.Client
+	states    *deploystate.Machine
+	logLevel  slog.Level
+	logBroker logging.StreamPublisher
+}
+
+// deploymentOutcome is what an update means for the deployment that
+// triggered it.
+type deploymentOutcome struct {
+	// state is where the deployment moves, or "" when the event doesn't
+	// move it, like a replica restarting after the deployment went live.
+	state    deploystate.State
+	failures []FailedContainer
+	// domains are the canonical domains a live deployment serves.
+	domains []string
+	// unavailable is set when a container of a deployment that already went
+	// live died and the app has nothing left to serve it.
+	unavailable bool
+}
+
+// classifyDeployment decides the outcome of an update from its failures and
+// the deployments that are routed after it.
+func classifyDeployment(de debouncedAppEvent, result UpdateResult, deployments map[string]Deployment) deploymentOutcome {
+	outcome := deploymentOutcome{failures: result.GetAppFailures(de.AppName)}
+	current, hasHealthy := deployments[de.AppName]
+
+	// Only a start event means a new deployment. Any other event is a
+	// container of a deployment that already went live changing state.
+	if !de.CapturedStartEvent {
+		outcome.unavailable = len(outcome.failures) > 0 && !hasHealthy
+		return outcome
+	}
+
+	// Healthy instances from an older deployment mean the new one failed.
+	succeeded := hasHealthy && current.Labels.DeploymentID == de.DeploymentID
+	if len(outcome.failures) > 0 && !succeeded {
+		outcome.state = deploystate.Failed
+		return outcome
+	}
+
+	outcome.state = deploystate.Live
+	domains := de.Domains
+	if succeeded {
+		domains = current.Labels.Domains
+	}
+	for _, domain := range domains {
+		outcome.domains = append(outcome.domains, domain.Canonical)
+	}
+	return outcome
+}
+
+// failureSummary joins the reasons containers failed. The kind is
+// health check when every container failed its health check, so the CLI
+// can point at the health check configuration.
+func failureSummary(failures []FailedContainer) (kind string, err error) {
+	reasons := make([]string, 0, len(failures))
+	kind = logging.FailureKindHealthCheck
+	for _, f := range failures {
+		reasons = append(reasons, fmt.Sprintf("%s: %v", f.Reason, f.Err))
+		if f.Reason != failureReasonHealthCheck {
+			kind = ""
+		}
+	}
+	return kind, fmt.Errorf("%s", strings.Join(reasons, "; "))
+}
+
+func (f *deploymentFlow) handle(ctx context.Context, de debouncedAppEvent) {
+	logger := logging.NewDeploymentLogger(de.DeploymentID, f.logLevel, f.logBroker)
+
+	app := &TriggeredByApp{
+		appName:           de.AppName,
+		domains:           de.Domains,
+		deploymentID:      de.DeploymentID,
+		dockerEventAction: de.EventAction,
+	}
+
+	if err := app.Validate(); err != nil {
+		err = fmt.Errorf("app data not valid: %w", err)
+		f.fail(logger, de, "", err)
+		return
+	}
+
+	// A replica of a live deployment restarting starts no phase.
+	if de.CapturedStartEvent {
+		if current, err := f.states.Current(de.DeploymentID); err == nil && current == deploystate.Starting {
+			logging.LogDeploymentPhase(logger, de.DeploymentID, de.AppName, logging.PhaseHealthCheck,
+				fmt.Sprintf("Checking the new containers of %s", de.AppName))
+		}
+	}
+	result, err := f.queue.Update(ctx, logger, TriggerReasonAppUpdated, app)
+	if err != nil {
+		f.fail(logger, de, "", err)
+		return
+	}
+	if de.CapturedStartEvent {
+		// Failed before the update could promote it, like when canceled while
+		// its health checks ran: it wasn't routed, and whatever failed it
+		// removed its containers and reported why.
+		if current, err := f.states.Current(de.DeploymentID); err == nil && current == deploystate.Failed {
+			logger.Debug("Deployment failed while its containers were checked", "app", de.AppName)
+			return
+		}
+	}
+
+	outcome := classifyDeployment(de, result, f.updater.deploymentManager.Deployments())
+	switch outcome.state {
+	case deploystate.Failed:
+		cleanupCtx, cleanupCancel := context.WithTimeout(ctx, 2*time.Minute)
+		defer cleanupCancel()
+
+		// Extract logs from failed containers before removing them to allow debugging
+		logContainerFailureLogs(cleanupCtx, f.cli, logger, outcome.failures)
+
+		if _, err := docker.StopContainersByDeploymentID(cleanupCtx, f.cli, logger, de.AppName, de.DeploymentID); err != nil {
+			logger.Warn("Failed to stop containers during cleanup", "error", err)
+		}
+		if _, err := docker.RemoveContainersByDeploymentID(cleanupCtx, f.cli, logger, de.AppName, de.DeploymentID); err != nil {
+			logger.Warn("Failed to remove containers during cleanup", "error", err)
+		}
+		kind, err := failureSummary(outcome.failures)
+		f.fail(logger, de, kind, err)
+
+	case deploystate.Live:
+		// The update promoted the deployment if it routed it. One it didn't
+		// had no containers to route.
+		if current, err := f.states.Current(de.DeploymentID); err != nil {
+			logger.Warn("Failed to read deployment state", "error", err)
+		} else if current != deploystate.Live && current != deploystate.Superseded {
+			f.fail(logger, de, "", errors.New("none of its containers were found running"))
+			return
+		}
+		logging.LogDeploymentComplete(logger, outcome.domains, de.DeploymentID, de.AppName,
+			fmt.Sprintf("Deployed %s", de.AppName))
+
+	default:
+		if outcome.unavailable {
+			logCtx, logCancel := context.WithTimeout(ctx, 30*time.Second)
+			defer logCancel()
+			logContainerFailureLogs(logCtx, f.cli, logger, outcome.failures)
+			logger.Error(fmt.Sprintf("Container died after deployment for %s, app has no healthy instances", de.AppName))
+		}
+	}
+}
+
+// fail records a new deployment as failed and tells a CLI streaming it to
+// stop waiting.
+func (f *deploymentFlow) fail(logger *slog.Logger, de debouncedAppEvent, kind string, err error) {
+	if de.CapturedStartEvent {
+		f.transition(logger, de, deploystate.Failed, err.Error())
+	}
+	logging.LogDeploymentFailedKind(logger, de.DeploymentID, de.AppName, kind, "Deployment failed", err)
+}
+
+func (f *deploymentFlow) transition(logger *slog.Logger, de debouncedAppEvent, to deploystate.State, message string) {
+	if err := f.states.Transition(de.DeploymentID, de.AppName, to, message); err != nil {
+		logger.Warn("Failed to record deployment state", "state", to, "error", err)
+	}
+}
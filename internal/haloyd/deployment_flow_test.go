@@ -0,0 +1,261 @@
+package haloyd
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/deploystate"
+	"github.com/haloydev/haloy/internal/logging"
+)
+
+func TestClassifyDeployment(t *testing.T) {
+	event := debouncedAppEvent{
+		AppName:            "app",
+		DeploymentID:       "new",
+		Domains:            []config.Domain{{Canonical: "event.example.com"}},
+		CapturedStartEvent: true,
+	}
+	routed := func(deploymentID string) map[string]Deployment {
+		return map[string]Deployment{"app": {Labels: &config.ContainerLabels{
+			AppName:      "app",
+			DeploymentID: deploymentID,
+			Domains:      []config.Domain{{Canonical: "labels.example.com"}},
+		}}}
+	}
+	failed := UpdateResult{FailedContainers: []FailedContainer{{
+		ContainerID: "c1",
+		Labels:      &config.ContainerLabels{AppName: "app"},
+		Reason:      failureReasonHealthCheck,
+	}}}
+	restart := event
+	restart.CapturedStartEvent = false
+
+	tests := []struct {
+		name            string
+		event           debouncedAppEvent
+		result          UpdateResult
+		deployments     map[string]Deployment
+		wantState       deploystate.State
+		wantDomains     []string
+		wantUnavailable bool
+	}{
+		{
+			name:        "new deployment routed",
+			event:       event,
+			deployments: routed("new"),
+			wantState:   deploystate.Live,
+			wantDomains: []string{"labels.example.com"},
+		},
+		{
+			name:        "no failures reported",
+			event:       event,
+			deployments: routed("old"),
+			wantState:   deploystate.Live,
+			wantDomains: []string{"event.example.com"},
+		},
+		{
+			name:        "failed while old deployment serves",
+			event:       event,
+			result:      failed,
+			deployments: routed("old"),
+			wantState:   deploystate.Failed,
+		},
+		{
+			name:        "replica failed but deployment routed",
+			event:       event,
+			result:      failed,
+			deployments: routed("new"),
+			wantState:   deploystate.Live,
+			wantDomains: []string{"labels.example.com"},
+		},
+		{
+			name:            "live container died",
+			event:           restart,
+			result:          failed,
+			wantUnavailable: true,
+		},
+		{
+			name:        "live container died with others serving",
+			event:       restart,
+			result:      failed,
+			deployments: routed("new"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyDeployment(tt.event, tt.result, tt.deployments)
+			if got.state != tt.wantState {
+				t.Errorf("state = %q, want %q", got.state, tt.wantState)
+			}
+			if !slices.Equal(got.domains, tt.wantDomains) {
+				t.Errorf("domains = %v, want %v", got.domains, tt.wantDomains)
+			}
+			if got.unavailable != tt.wantUnavailable {
+				t.Errorf("unavailable = %v, want %v", got.unavailable, tt.wantUnavailable)
+			}
+		})
+	}
+}
+
+func TestFailureSummary(t *testing.T) {
+	healthCheck := FailedContainer{Reason: failureReasonHealthCheck, Err: errors.New("timeout")}
+	crashLoop := FailedContainer{Reason: failureReasonCrashLoop, Err: errors.New("restarting")}
+
+	kind, err := failureSummary([]FailedContainer{healthCheck, healthCheck})
+	if kind != logging.FailureKindHealthCheck {
+		t.Errorf("kind = %q, want %q", kind, logging.FailureKindHealthCheck)
+	}
+	if want := "health check failed: timeout; health check failed: timeout"; err.Error() != want {
+		t.Errorf("err = %q, want %q", err, want)
+	}
+
+	if kind, _ := failureSummary([]FailedContainer{healthCheck, crashLoop}); kind != "" {
+		t.Errorf("kind with mixed failures = %q, want none", kind)
+	}
+}
+
+// fakeGate routes the deployments in routable and promotes all but those in
+// failed.
+type fakeGate struct {
+	routable map[string]bool
+	failed   map[string]bool
+	promoted []string
+}
+
+func (g *fakeGate) Routable(deploymentID string) (bool, error) {
+	return g.routable[deploymentID], nil
+}
+
+func (g *fakeGate) Promote(deploymentID, _ string) error {
+	if g.failed[deploymentID] {
+		return errors.New("deployment " + deploymentID + " cannot move from failed to healthy")
+	}
+	g.promoted = append(g.promoted, deploymentID)
+	return nil
+}
+
+func TestGateDeployments(t *testing.T) {
+	container := func(appName, deploymentID string) HealthyContainer {
+		return HealthyContainer{
+			ContainerID: appName + "-" + deploymentID,
+			Labels:      &config.ContainerLabels{AppName: appName, DeploymentID: deploymentID},
+		}
+	}
+	healthy := []HealthyContainer{
+		container("app", "20260101000000"),
+		container("app", "20260102000000"),
+		container("other", "20260101000000"),
+	}
+	newApp := &TriggeredByApp{appName: "app", deploymentID: "20260102000000"}
+	oldApp := &TriggeredByApp{appName: "app", deploymentID: "20260101000000"}
+
+	tests := []struct {
+		name         string
+		gate         *fakeGate
+		app          *TriggeredByApp
+		wantRouted   []string
+		wantApp      bool
+		wantPromoted []string
+	}{
+		{
+			name:       "periodic update routes live deployments only",
+			gate:       &fakeGate{routable: map[string]bool{"20260101000000": true}},
+			wantRouted: []string{"app-20260101000000", "other-20260101000000"},
+		},
+		{
+			name:         "new deployment is promoted before it's routed",
+			gate:         &fakeGate{routable: map[string]bool{"20260101000000": true}},
+			app:          newApp,
+			wantRouted:   []string{"app-20260101000000", "app-20260102000000", "other-20260101000000"},
+			wantApp:      true,
+			wantPromoted: []string{"20260102000000"},
+		},
+		{
+			name:       "failed deployment isn't routed",
+			gate:       &fakeGate{routable: map[string]bool{"20260101000000": true}, failed: map[string]bool{"20260102000000": true}},
+			app:        newApp,
+			wantRouted: []string{"app-20260101000000", "other-20260101000000"},
+		},
+		{
+			name:       "older deployment isn't promoted over a newer one",
+			gate:       &fakeGate{routable: map[string]bool{"20260102000000": true}},
+			app:        oldApp,
+			wantRouted: []string{"app-20260101000000", "app-20260102000000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routed, appRoutable := gateDeployments(slog.New(slog.DiscardHandler), tt.gate, slices.Clone(healthy), tt.app)
+			var got []string
+			for _, c := range routed {
+				got = append(got, c.ContainerID)
+			}
+			if !slices.Equal(got, tt.wantRouted) {
+				t.Errorf("routed = %v, want %v", got, tt.wantRouted)
+			}
+			if appRoutable != tt.wantApp {
+				t.Errorf("app routable = %v, want %v", appRoutable, tt.wantApp)
+			}
+			if !slices.Equal(tt.gate.promoted, tt.wantPromoted) {
+				t.Errorf("promoted = %v, want %v", tt.gate.promoted, tt.wantPromoted)
+			}
+		})
+	}
+
+	if routed, appRoutable := gateDeployments(slog.New(slog.DiscardHandler), nil, healthy, newApp); len(routed) != len(healthy) || !appRoutable {
+		t.Errorf("without a gate routed %d of %d containers, app routable = %v", len(routed), len(healthy), appRoutable)
+	}
+}
+
+func TestGateDeployments_CancelWhileGoingLive(t *testing.T) {
+	oldContainer := HealthyContainer{ContainerID: "old", Labels: &config.ContainerLabels{AppName: "app", DeploymentID: "20260101000000"}}
+	newContainer := HealthyContainer{ContainerID: "new", Labels: &config.ContainerLabels{AppName: "app", DeploymentID: "20260102000000"}}
+	app := &TriggeredByApp{appName: "app", deploymentID: "20260102000000"}
+	logger := slog.New(slog.DiscardHandler)
+
+	for i := range 20 {
+		db := newTestCertificatesDB(t)
+		// The flow and the cancel handler each have their own machine.
+		flowStates, apiStates := deploystate.New(db), deploystate.New(db)
+		if err := flowStates.Transition(oldContainer.Labels.DeploymentID, "app", deploystate.Live, ""); err != nil {
+			t.Fatal(err)
+		}
+		for _, state := range []deploystate.State{deploystate.Pending, deploystate.Starting} {
+			if err := flowStates.Transition(app.deploymentID, "app", state, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The update checking the new containers and the cancel race.
+		var cancelErr error
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			cancelErr = apiStates.Transition(app.deploymentID, "app", deploystate.Failed, "deployment canceled")
+		}()
+		routed, appRoutable := gateDeployments(logger, flowStates, []HealthyContainer{oldContainer, newContainer}, app)
+		<-done
+
+		routedNew := slices.ContainsFunc(routed, func(c HealthyContainer) bool { return c.ContainerID == "new" })
+		state, err := flowStates.Current(app.deploymentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cancelErr == nil {
+			// Canceled first: the new containers, which the cancel removes,
+			// must not be routed, and the old ones must stay.
+			if routedNew || appRoutable || state != deploystate.Failed {
+				t.Fatalf("run %d: canceled deployment routed = %v, app routable = %v, state = %s", i, routedNew, appRoutable, state)
+			}
+			if !slices.ContainsFunc(routed, func(c HealthyContainer) bool { return c.ContainerID == "old" }) {
+				t.Fatalf("run %d: old deployment not routed after the new one was canceled", i)
+			}
+		} else if !routedNew || !appRoutable || state != deploystate.Live {
+			// Promoted first: the cancel is refused and the deployment is live.
+			t.Fatalf("run %d: cancel refused but deployment routed = %v, app routable = %v, state = %s", i, routedNew, appRoutable, state)
+		}
+	}
+}
@@ -46,6 +46,10 @@ type HealthyContainer struct {
 	Port        string
 }
 
+// failureReasonHealthCheck is the FailedContainer reason for containers that
+// are running but never passed their health check.
+const failureReasonHealthCheck = "health check failed"
+
 // FailedContainer represents a container that failed discovery or health check.
 type FailedContainer struct {
 	ContainerID string
@@ -63,6 +67,10 @@ type DeploymentManager struct {
 	failedDeployments map[string]Deployment
 	deploymentsMutex  sync.RWMutex
 	haloydConfig      *config.HaloydConfig
+	// crashLoops keeps crash looping containers out of the deployments. Optional.
+	crashLoops *CrashLoopDetector
+	// isPaused reports apps stopped on purpose, which keep no routes. Optional.
+	isPaused func(appName string) bool
 }
 
 func NewDeploymentManager(cli *client.Client, haloydConfig *config.HaloydConfig) *DeploymentManager {
@@ -74,6 +82,18 @@ func NewDeploymentManager(cli *client.Client, haloydConfig *config.HaloydConfig)
 	}
 }
 
+// SetCrashLoopDetector makes health checks fail for containers the detector
+// reports as crash looping.
+func (dm *DeploymentManager) SetCrashLoopDetector(detector *CrashLoopDetector) {
+	dm.crashLoops = detector
+}
+
+// SetPausedCheck makes apps reported as paused lose their routes once their
+// containers are stopped, instead of being kept as failed deployments.
+func (dm *DeploymentManager) SetPausedCheck(isPaused func(appName string) bool) {
+	dm.isPaused = isPaused
+}
+
 // DiscoverContainers finds all containers with haloy labels and validates their basic configuration.
 // It returns containers that are eligible for health checking, and containers that failed validation.
 func (dm *DeploymentManager) DiscoverContainers(ctx context.Context, logger *slog.Logger) (discovered []DiscoveredContainer, failed []FailedContainer, err error) {
@@ -160,6 +180,18 @@ func (dm *DeploymentManager) DiscoverContainers(ctx context.Context, logger *slo
 // Returns healthy containers (with IPs) and failed containers with detailed error information.
 func (dm *DeploymentManager) HealthCheckContainers(ctx context.Context, logger *slog.Logger, discovered []DiscoveredContainer) (healthy []HealthyContainer, failed []FailedContainer) {
 	for _, container := range discovered {
+		if dm.crashLoops != nil {
+			if restarts, looping := dm.crashLoops.IsCrashLooping(container.ContainerID); looping {
+				failed = append(failed, FailedContainer{
+					ContainerID: container.ContainerID,
+					Labels:      container.Labels,
+					Reason:      failureReasonCrashLoop,
+					Err:         fmt.Errorf("restarted %d times in %s", restarts, shortDuration(dm.crashLoops.Window())),
+				})
+				continue
+			}
+		}
+
 		result := docker.HealthCheckContainer(ctx, dm.cli, logger, container.ContainerID, container.ContainerInfo)
 		if result.Err != nil {
 			logger.Debug("Container failed health check",
@@ -169,17 +201,21 @@ func (dm *DeploymentManager) HealthCheckContainers(ctx context.Context, logger *
 			failed = append(failed, FailedContainer{
 				ContainerID: container.ContainerID,
 				Labels:      container.Labels,
-				Reason:      "health check failed",
+				Reason:      failureReasonHealthCheck,
 				Err:         result.Err,
 			})
 			continue
 		}
 
+		port := container.Port
+		if result.Port != "" {
+			port = result.Port
+		}
 		healthy = append(healthy, HealthyContainer{
 			ContainerID: container.ContainerID,
 			Labels:      container.Labels,
 			IP:          result.IP,
-			Port:        container.Port,
+			Port:        port,
 		})
 	}
 
@@ -204,8 +240,8 @@ func (dm *DeploymentManager) UpdateDeployments(healthy []HealthyContainer) (hasC
 				deployment.Instances = append(deployment.Instances, instance)
 				newDeployments[container.Labels.AppName] = deployment
 			} else {
-				// Replace the deployment if the new one has a higher deployment ID
-				if deployment.Labels.DeploymentID < container.Labels.DeploymentID {
+				// Replace the deployment if the new one is newer
+				if helpers.CompareDeploymentIDs(container.Labels.DeploymentID, deployment.Labels.DeploymentID) > 0 {
 					newDeployments[container.Labels.AppName] = Deployment{
 						Labels:    container.Labels,
 						Instances: []DeploymentInstance{instance},
@@ -241,6 +277,13 @@ func (dm *DeploymentManager) updateFailedDeployments(compareResult compareResult
 	activeDomains := deploymentDomainSet(activeDeployments)
 
 	for appName, deployment := range compareResult.RemovedDeployments {
+		if dm.isPaused != nil && dm.isPaused(appName) {
+			if _, exists := dm.failedDeployments[appName]; exists {
+				delete(dm.failedDeployments, appName)
+				hasChanged = true
+			}
+			continue
+		}
 		if deploymentOverlapsDomains(deployment, activeDomains) {
 			if _, exists := dm.failedDeployments[appName]; exists {
 				delete(dm.failedDeployments, appName)
@@ -253,7 +296,8 @@ func (dm *DeploymentManager) updateFailedDeployments(compareResult compareResult
 	}
 
 	for appName, deployment := range dm.failedDeployments {
-		if _, exists := activeDeployments[appName]; exists || deploymentOverlapsDomains(deployment, activeDomains) {
+		paused := dm.isPaused != nil && dm.isPaused(appName)
+		if _, exists := activeDeployments[appName]; exists || paused || deploymentOverlapsDomains(deployment, activeDomains) {
 			delete(dm.failedDeployments, appName)
 			hasChanged = true
 		}
@@ -301,6 +345,8 @@ func (dm *DeploymentManager) GetHealthCheckTargets() []healthcheck.Target {
 				IP:              instance.IP,
 				Port:            instance.Port,
 				HealthCheckPath: healthCheckPath,
+				Type:            healthcheck.CheckType(deployment.Labels.HealthCheckType),
+				Cmd:             deployment.Labels.HealthCheckCmd,
 			})
 		}
 	}
@@ -323,6 +369,7 @@ func (dm *DeploymentManager) GetCertificateDomains() ([]CertificatesDomain, erro
 				newDomain := CertificatesDomain{
 					Canonical: domain.Canonical,
 					Aliases:   domain.Aliases,
+					CDN:       domain.CDN,
 				}
 
 				if err := newDomain.Validate(); err != nil {
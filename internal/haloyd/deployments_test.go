@@ -49,6 +49,38 @@ func TestUpdateDeploymentsTracksFailedDeployments(t *testing.T) {
 	}
 }
 
+func TestPausedAppNotTrackedAsFailed(t *testing.T) {
+	dm := NewDeploymentManager(nil, nil)
+	paused := false
+	dm.SetPausedCheck(func(appName string) bool { return paused && appName == "myapp" })
+
+	labels := &config.ContainerLabels{
+		AppName:      "myapp",
+		DeploymentID: "deploy-1",
+		Port:         config.Port(constants.DefaultContainerPort),
+		Domains:      []config.Domain{{Canonical: "myapp.example.com"}},
+	}
+	healthy := []HealthyContainer{{ContainerID: "c1", Labels: labels, IP: "10.0.0.1", Port: "8080"}}
+
+	// A crashed app keeps its routes as a failed deployment...
+	dm.UpdateDeployments(healthy)
+	dm.UpdateDeployments(nil)
+	if len(dm.FailedDeployments()) != 1 {
+		t.Fatal("expected the crashed app in failed deployments")
+	}
+
+	// ...until it's paused, and a paused app never becomes one.
+	paused = true
+	if !dm.UpdateDeployments(nil) {
+		t.Error("UpdateDeployments() reported no change when the failed app was paused")
+	}
+	dm.UpdateDeployments(healthy)
+	dm.UpdateDeployments(nil)
+	if failed := dm.FailedDeployments(); len(failed) != 0 {
+		t.Fatalf("expected no failed deployments for a paused app, got %v", failed)
+	}
+}
+
 func TestFailedDeploymentsClearedOnRedeploy(t *testing.T) {
 	dm := NewDeploymentManager(nil, nil)
 
@@ -0,0 +1,287 @@
+package haloyd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+// OrphanKind is the kind of resource an Orphan is.
+type OrphanKind string
+
+const (
+	OrphanContainer   OrphanKind = "container"
+	OrphanImage       OrphanKind = "image"
+	OrphanVolume      OrphanKind = "volume"
+	OrphanCertificate OrphanKind = "certificate"
+)
+
+// Orphan is a resource left behind by an app haloyd has no deployments of,
+// or a certificate for a domain no container routes.
+type Orphan struct {
+	Kind OrphanKind
+	// Name is the container name, image tag, volume name or domain.
+	Name    string
+	AppName string
+	Age     time.Duration
+	// Eligible is set when the orphan is older than the age threshold.
+	Eligible bool
+	Removed  bool
+	Err      error
+}
+
+// GCOptions controls a garbage collection run.
+type GCOptions struct {
+	OlderThan time.Duration
+	DryRun    bool
+	CertDir   string
+	// KeepDomains lists domains whose certificates are in use without any
+	// container routing them, like the API domain.
+	KeepDomains []string
+}
+
+// gcInventory is what garbage collection looks at, gathered up front so
+// finding orphans doesn't depend on Docker.
+type gcInventory struct {
+	apps         map[string]struct{}
+	containers   []container.Summary
+	images       []image.Summary
+	volumes      []*volume.Volume
+	certificates map[string]time.Time
+}
+
+// CollectOrphans finds the orphans on this server and, unless opts.DryRun is
+// set, removes the ones older than opts.OlderThan. Running containers, and
+// images and volumes they use, are never orphans.
+func CollectOrphans(ctx context.Context, cli *client.Client, db *storage.DB, logger *slog.Logger, opts GCOptions) ([]Orphan, error) {
+	inventory, err := loadGCInventory(ctx, cli, db, opts.CertDir)
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := findOrphans(inventory, opts, time.Now())
+	if opts.DryRun {
+		return orphans, nil
+	}
+
+	// Containers go first so the images and volumes they held can go too.
+	var errs []error
+	for i := range orphans {
+		orphan := &orphans[i]
+		if !orphan.Eligible {
+			continue
+		}
+		orphan.Err = removeOrphan(ctx, cli, db, opts.CertDir, *orphan)
+		if orphan.Err != nil {
+			logger.Warn("Failed to remove orphaned resource", "kind", orphan.Kind, "name", orphan.Name, "error", orphan.Err)
+			errs = append(errs, fmt.Errorf("%s %s: %w", orphan.Kind, orphan.Name, orphan.Err))
+			continue
+		}
+		orphan.Removed = true
+		logger.Debug("Removed orphaned resource", "kind", orphan.Kind, "name", orphan.Name, "app", orphan.AppName)
+	}
+	return orphans, errors.Join(errs...)
+}
+
+func loadGCInventory(ctx context.Context, cli *client.Client, db *storage.DB, certDir string) (gcInventory, error) {
+	inventory := gcInventory{apps: make(map[string]struct{})}
+
+	appNames, err := db.ListDistinctAppNames()
+	if err != nil {
+		return inventory, err
+	}
+	for _, appName := range appNames {
+		inventory.apps[appName] = struct{}{}
+	}
+
+	// All containers, not only haloy's, since any of them may use an image.
+	inventory.containers, err = cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return inventory, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	inventory.images, err = cli.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return inventory, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	volumes, err := cli.VolumeList(ctx, volume.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", config.LabelAppName)),
+	})
+	if err != nil {
+		return inventory, fmt.Errorf("failed to list volumes: %w", err)
+	}
+	inventory.volumes = volumes.Volumes
+
+	inventory.certificates, err = listCertificateFiles(certDir)
+	if err != nil {
+		return inventory, err
+	}
+	return inventory, nil
+}
+
+// listCertificateFiles returns the domains with a certificate in certDir and
+// when each was last written.
+func listCertificateFiles(certDir string) (map[string]time.Time, error) {
+	certificates := make(map[string]time.Time)
+	if certDir == "" {
+		return certificates, nil
+	}
+	entries, err := os.ReadDir(certDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return certificates, nil
+		}
+		return nil, fmt.Errorf("failed to read certificates directory: %w", err)
+	}
+	for _, entry := range entries {
+		domain, ok := strings.CutSuffix(entry.Name(), combinedCertExt)
+		if entry.IsDir() || !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		certificates[domain] = info.ModTime()
+	}
+	return certificates, nil
+}
+
+// findOrphans lists orphans by kind, containers first.
+func findOrphans(inv gcInventory, opts GCOptions, now time.Time) []Orphan {
+	var orphans []Orphan
+	add := func(kind OrphanKind, name, appName string, created time.Time) bool {
+		age := now.Sub(created)
+		eligible := age >= opts.OlderThan
+		orphans = append(orphans, Orphan{Kind: kind, Name: name, AppName: appName, Age: age, Eligible: eligible})
+		return eligible
+	}
+	registered := func(appName string) bool {
+		_, ok := inv.apps[appName]
+		return ok
+	}
+
+	// Images, volumes and domains used by containers that stay.
+	usedImages := make(map[string]struct{})
+	usedVolumes := make(map[string]struct{})
+	usedDomains := make(map[string]struct{})
+	for _, c := range inv.containers {
+		appName := containerAppName(c)
+		if appName != "" && !registered(appName) && c.State != "running" {
+			if add(OrphanContainer, containerName(c), appName, time.Unix(c.Created, 0)) {
+				continue
+			}
+		}
+		usedImages[c.ImageID] = struct{}{}
+		for _, mount := range c.Mounts {
+			if mount.Type == "volume" {
+				usedVolumes[mount.Name] = struct{}{}
+			}
+		}
+		if labels, err := config.ParseContainerLabels(c.Labels); err == nil {
+			for _, domain := range labels.Domains {
+				usedDomains[domain.Canonical] = struct{}{}
+			}
+		}
+	}
+
+	for _, img := range inv.images {
+		if _, ok := usedImages[img.ID]; ok {
+			continue
+		}
+		for _, tag := range img.RepoTags {
+			// Deployments tag their image <app>:<deployment ID>.
+			i := strings.LastIndex(tag, ":")
+			if i < 0 || registered(tag[:i]) {
+				continue
+			}
+			appName, deploymentID := tag[:i], tag[i+1:]
+			if _, err := helpers.GetTimestampFromDeploymentID(deploymentID); err != nil {
+				continue
+			}
+			add(OrphanImage, tag, appName, time.Unix(img.Created, 0))
+		}
+	}
+
+	for _, vol := range inv.volumes {
+		appName := vol.Labels[config.LabelAppName]
+		if _, ok := usedVolumes[vol.Name]; ok || registered(appName) {
+			continue
+		}
+		created, _ := time.Parse(time.RFC3339, vol.CreatedAt)
+		add(OrphanVolume, vol.Name, appName, created)
+	}
+
+	for _, domain := range slices.Sorted(maps.Keys(inv.certificates)) {
+		if _, ok := usedDomains[domain]; ok || slices.Contains(opts.KeepDomains, domain) {
+			continue
+		}
+		add(OrphanCertificate, domain, "", inv.certificates[domain])
+	}
+	return orphans
+}
+
+func removeOrphan(ctx context.Context, cli *client.Client, db *storage.DB, certDir string, orphan Orphan) error {
+	switch orphan.Kind {
+	case OrphanContainer:
+		return cli.ContainerRemove(ctx, orphan.Name, container.RemoveOptions{})
+	case OrphanImage:
+		_, err := cli.ImageRemove(ctx, orphan.Name, image.RemoveOptions{PruneChildren: true})
+		return err
+	case OrphanVolume:
+		return cli.VolumeRemove(ctx, orphan.Name, false)
+	case OrphanCertificate:
+		if err := os.Remove(filepath.Join(certDir, orphan.Name+combinedCertExt)); err != nil {
+			return err
+		}
+		return db.RetireCertificate(orphan.Name)
+	}
+	return fmt.Errorf("unknown orphan kind %q", orphan.Kind)
+}
+
+// GCKeepDomains returns the domains with certificates haloyd uses without a
+// container routing them: the API domain and domains approved for on-demand
+// TLS.
+func GCKeepDomains(dataDir string, haloydConfig *config.HaloydConfig) ([]string, error) {
+	var domains []string
+	if haloydConfig != nil && haloydConfig.API.Domain != "" {
+		domains = append(domains, haloydConfig.API.Domain)
+	}
+	onDemand, err := readOnDemandDomains(filepath.Join(dataDir, constants.OnDemandDomainsFileName))
+	if err != nil {
+		return nil, err
+	}
+	return append(domains, onDemand...), nil
+}
+
+func containerAppName(c container.Summary) string {
+	if appName := c.Labels[config.LabelAppName]; appName != "" {
+		return appName
+	}
+	return c.Labels[config.LabelSidecarOf]
+}
+
+func containerName(c container.Summary) string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return helpers.SafeIDPrefix(c.ID)
+}
@@ -0,0 +1,89 @@
+package haloyd
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+func TestFindOrphans(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-30 * 24 * time.Hour)
+	recent := now.Add(-time.Hour)
+
+	appLabels := func(appName, domain string) map[string]string {
+		labels := config.ContainerLabels{AppName: appName, DeploymentID: helpers.NewDeploymentID(), Port: "8080"}
+		if domain != "" {
+			labels.Domains = []config.Domain{{Canonical: domain}}
+		}
+		return labels.ToLabels()
+	}
+	deploymentID := helpers.NewDeploymentID()
+
+	inv := gcInventory{
+		apps: map[string]struct{}{"live": {}},
+		containers: []container.Summary{
+			{ID: "c1", Names: []string{"/live-1"}, State: "running", Labels: appLabels("live", "live.example.com"), ImageID: "img-live", Created: old.Unix()},
+			// A stopped container of an app without deployments.
+			{
+				ID: "c2", Names: []string{"/gone-1"}, State: "exited", Labels: appLabels("gone", "gone.example.com"), ImageID: "img-gone", Created: old.Unix(),
+				Mounts: []container.MountPoint{{Type: "volume", Name: "gone-data"}},
+			},
+			// Running containers are never collected, deployments or not.
+			{
+				ID: "c3", Names: []string{"/legacy-1"}, State: "running", Labels: appLabels("legacy", ""), ImageID: "img-legacy", Created: old.Unix(),
+				Mounts: []container.MountPoint{{Type: "volume", Name: "legacy-data"}},
+			},
+			{ID: "c4", Names: []string{"/fresh-1"}, State: "exited", Labels: appLabels("fresh", ""), ImageID: "img-fresh", Created: recent.Unix()},
+		},
+		images: []image.Summary{
+			{ID: "img-live", RepoTags: []string{"live:" + deploymentID}, Created: old.Unix()},
+			{ID: "img-gone", RepoTags: []string{"gone:" + deploymentID, "gone:latest"}, Created: old.Unix()},
+			{ID: "img-legacy", RepoTags: []string{"legacy:" + deploymentID}, Created: old.Unix()},
+			{ID: "img-fresh", RepoTags: []string{"fresh:" + deploymentID}, Created: old.Unix()},
+			{ID: "img-postgres", RepoTags: []string{"postgres:16"}, Created: old.Unix()},
+		},
+		volumes: []*volume.Volume{
+			{Name: "gone-data", Labels: map[string]string{config.LabelAppName: "gone"}, CreatedAt: old.Format(time.RFC3339)},
+			{Name: "legacy-data", Labels: map[string]string{config.LabelAppName: "legacy"}, CreatedAt: old.Format(time.RFC3339)},
+			{Name: "live-data", Labels: map[string]string{config.LabelAppName: "live"}, CreatedAt: old.Format(time.RFC3339)},
+		},
+		certificates: map[string]time.Time{
+			"live.example.com":   old,
+			"gone.example.com":   old,
+			"api.example.com":    old,
+			"stale.example.com":  old,
+			"recent.example.com": recent,
+		},
+	}
+
+	orphans := findOrphans(inv, GCOptions{OlderThan: 24 * time.Hour, KeepDomains: []string{"api.example.com"}}, now)
+
+	type result struct {
+		kind     OrphanKind
+		name     string
+		eligible bool
+	}
+	var got []result
+	for _, o := range orphans {
+		got = append(got, result{o.Kind, o.Name, o.Eligible})
+	}
+	want := []result{
+		{OrphanContainer, "gone-1", true},
+		{OrphanContainer, "fresh-1", false},
+		{OrphanImage, "gone:" + deploymentID, true},
+		{OrphanVolume, "gone-data", true},
+		{OrphanCertificate, "gone.example.com", true},
+		{OrphanCertificate, "recent.example.com", false},
+		{OrphanCertificate, "stale.example.com", true},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("findOrphans() =\n%v\nwant\n%v", got, want)
+	}
+}
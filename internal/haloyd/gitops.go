@@ -0,0 +1,548 @@
+package haloyd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/api"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/errorpages"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+const (
+	// gitOpsFetchTimeout bounds cloning or fetching the repository.
+	gitOpsFetchTimeout = 2 * time.Minute
+	// gitOpsDestroyTimeout bounds the destroy of an app removed from the
+	// repository.
+	gitOpsDestroyTimeout = 5 * time.Minute
+	// gitOpsHolder is shown as the holder of the deploy locks GitOps takes.
+	gitOpsHolder = "gitops"
+)
+
+// GitOps keeps the apps a git repository declares for this server deployed.
+// Every interval it fetches the repository, deploys the targets whose config
+// changed or that aren't running, and destroys the apps it deployed earlier
+// that the repository no longer declares. Volumes of destroyed apps are kept.
+type GitOps struct {
+	config    config.GitOpsConfig
+	apiDomain string
+	dir       string
+	db        *storage.DB
+	cli       *client.Client
+	apiServer *api.APIServer
+	logger    *slog.Logger
+	wake      chan struct{}
+
+	mu       sync.Mutex
+	commit   string
+	lastSync time.Time
+	syncErr  string
+	declared []declaredApp
+	// failed holds the error of the last deploy of an app, keyed by app
+	// name, until its config changes.
+	failed map[string]gitOpsFailure
+}
+
+// declaredApp is a target of the repository to run on this server.
+type declaredApp struct {
+	target     config.TargetConfig
+	rollback   config.DeployConfig
+	errorPages map[string]string
+	hash       string
+	// notDeployable is why the target can't be deployed by GitOps, if so.
+	notDeployable string
+}
+
+type gitOpsFailure struct {
+	hash string
+	err  string
+}
+
+// NewGitOps returns a reconciler that checks the repository out in dir.
+func NewGitOps(cfg config.GitOpsConfig, apiDomain, dir string, db *storage.DB, cli *client.Client, apiServer *api.APIServer, logger *slog.Logger) *GitOps {
+	return &GitOps{
+		config:    cfg,
+		apiDomain: apiDomain,
+		dir:       dir,
+		db:        db,
+		cli:       cli,
+		apiServer: apiServer,
+		logger:    logger,
+		wake:      make(chan struct{}, 1),
+		failed:    make(map[string]gitOpsFailure),
+	}
+}
+
+// Run syncs right away and then every interval, or when Sync is called,
+// until ctx is done.
+func (g *GitOps) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		manual := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-g.wake:
+			manual = true
+		}
+		g.sync(ctx, manual)
+		timer.Reset(g.config.GetInterval())
+	}
+}
+
+// Sync starts a sync without waiting for the interval. Apps whose last
+// deploy failed are retried.
+func (g *GitOps) Sync() {
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Status reports the last sync and the state of the declared apps.
+func (g *GitOps) Status() apitypes.GitOpsStatusResponse {
+	status := apitypes.GitOpsStatusResponse{
+		Repo:   g.config.Repo,
+		Branch: g.config.GetBranch(),
+		Apps:   []apitypes.GitOpsApp{},
+	}
+	pause, err := g.db.GetGitOpsPause()
+	if err != nil {
+		g.logger.Warn("Failed to read GitOps pause", "error", err)
+	}
+	if pause != nil {
+		status.Paused = true
+		status.PausedAt = pause.PausedAt
+		status.PauseReason = pause.Reason
+	}
+	applied, err := g.appliedApps()
+	if err != nil {
+		g.logger.Warn("Failed to read GitOps apps", "error", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	status.Commit = g.commit
+	status.LastSync = g.lastSync
+	status.Error = g.syncErr
+
+	declared := make(map[string]bool, len(g.declared))
+	for _, app := range g.declared {
+		declared[app.target.Name] = true
+		entry := apitypes.GitOpsApp{App: app.target.Name, Target: app.target.TargetName, State: apitypes.GitOpsAppPending}
+		if record, ok := applied[app.target.Name]; ok {
+			entry.Commit = record.Commit
+			entry.DeploymentID = record.DeploymentID
+			entry.AppliedAt = record.AppliedAt
+			if record.ConfigHash == app.hash {
+				entry.State = apitypes.GitOpsAppSynced
+			}
+		}
+		if failure, ok := g.failed[app.target.Name]; ok && failure.hash == app.hash {
+			entry.State = apitypes.GitOpsAppFailed
+			entry.Error = failure.err
+		}
+		status.Apps = append(status.Apps, entry)
+	}
+	// Before the first sync nothing is known to be removed.
+	if !g.lastSync.IsZero() && g.syncErr == "" {
+		for _, record := range applied {
+			if declared[record.AppName] {
+				continue
+			}
+			status.Apps = append(status.Apps, apitypes.GitOpsApp{
+				App:          record.AppName,
+				Target:       record.TargetName,
+				State:        apitypes.GitOpsAppRemoving,
+				Commit:       record.Commit,
+				DeploymentID: record.DeploymentID,
+				AppliedAt:    record.AppliedAt,
+			})
+		}
+	}
+	slices.SortFunc(status.Apps, func(a, b apitypes.GitOpsApp) int { return strings.Compare(a.App, b.App) })
+	return status
+}
+
+func (g *GitOps) sync(ctx context.Context, manual bool) {
+	commit, declared, err := g.load(ctx)
+
+	g.mu.Lock()
+	g.lastSync = time.Now()
+	if err != nil {
+		g.syncErr = err.Error()
+		g.mu.Unlock()
+		g.logger.Error("GitOps sync failed", "repo", g.config.Repo, "error", err)
+		return
+	}
+	if commit != g.commit {
+		g.logger.Info("GitOps fetched new commit", "repo", g.config.Repo, "commit", commit)
+	}
+	g.commit = commit
+	g.syncErr = ""
+	g.declared = declared
+	failed := maps.Clone(g.failed)
+	g.mu.Unlock()
+
+	pause, err := g.db.GetGitOpsPause()
+	if err != nil {
+		g.logger.Error("GitOps sync failed", "error", err)
+		return
+	}
+	if pause != nil {
+		return
+	}
+
+	applied, err := g.appliedApps()
+	if err != nil {
+		g.logger.Error("GitOps sync failed", "error", err)
+		return
+	}
+	running, err := g.runningApps(ctx)
+	if err != nil {
+		g.logger.Error("GitOps sync failed", "error", err)
+		return
+	}
+	stopped := make(map[string]bool)
+	for _, app := range declared {
+		if paused, err := g.db.GetPausedApp(app.target.Name); err == nil && paused != nil {
+			stopped[app.target.Name] = true
+		}
+	}
+	if manual {
+		failed = nil
+	}
+
+	plan := planGitOps(declared, applied, running, stopped, failed)
+	for _, app := range plan.deploy {
+		g.deploy(ctx, commit, app)
+	}
+	for _, record := range plan.destroy {
+		g.destroy(ctx, record)
+	}
+}
+
+func (g *GitOps) deploy(ctx context.Context, commit string, app declaredApp) {
+	appName := app.target.Name
+	var err error
+	deploymentID := ""
+	if app.notDeployable != "" {
+		err = errors.New(app.notDeployable)
+	} else {
+		deploymentID = helpers.NewDeploymentID()
+		g.logger.Info("GitOps deploying app", "app", appName, "commit", commit, "deployment_id", deploymentID)
+		err = g.apiServer.DeployTarget(ctx, g.cli, deploymentID, gitOpsHolder, app.target, app.rollback, app.errorPages)
+	}
+	if err == nil {
+		err = g.db.SaveGitOpsApp(storage.GitOpsApp{
+			AppName:      appName,
+			TargetName:   app.target.TargetName,
+			ConfigHash:   app.hash,
+			Commit:       commit,
+			DeploymentID: deploymentID,
+			AppliedAt:    time.Now(),
+		})
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err != nil {
+		g.logger.Error("GitOps deploy failed", "app", appName, "commit", commit, "error", err)
+		g.failed[appName] = gitOpsFailure{hash: app.hash, err: err.Error()}
+		return
+	}
+	delete(g.failed, appName)
+}
+
+func (g *GitOps) destroy(ctx context.Context, record storage.GitOpsApp) {
+	g.logger.Info("GitOps destroying app removed from the repository", "app", record.AppName)
+	destroyCtx, cancel := context.WithTimeout(ctx, gitOpsDestroyTimeout)
+	defer cancel()
+	req := apitypes.DestroyAppRequest{KeepVolumes: true, RemoveCertificates: true}
+	if _, err := g.apiServer.DestroyApp(destroyCtx, g.cli, record.AppName, req, g.logger); err != nil {
+		g.logger.Error("GitOps destroy failed", "app", record.AppName, "error", err)
+		return
+	}
+	if err := g.db.DeleteGitOpsApp(record.AppName); err != nil {
+		g.logger.Error("Failed to forget GitOps app", "app", record.AppName, "error", err)
+	}
+}
+
+func (g *GitOps) appliedApps() (map[string]storage.GitOpsApp, error) {
+	records, err := g.db.ListGitOpsApps()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]storage.GitOpsApp, len(records))
+	for _, record := range records {
+		applied[record.AppName] = record
+	}
+	return applied, nil
+}
+
+func (g *GitOps) runningApps(ctx context.Context) (map[string]bool, error) {
+	containers, err := docker.GetAppContainers(ctx, g.cli, false, "")
+	if err != nil {
+		return nil, err
+	}
+	running := make(map[string]bool)
+	for _, c := range containers {
+		if appName := c.Labels[config.LabelAppName]; appName != "" {
+			running[appName] = true
+		}
+	}
+	return running, nil
+}
+
+// gitOpsPlan lists what a sync changes.
+type gitOpsPlan struct {
+	deploy  []declaredApp
+	destroy []storage.GitOpsApp
+}
+
+// planGitOps returns the declared apps to deploy, in order, and the apps
+// deployed from the repository that it no longer declares. An app is
+// deployed when its config changed since it was applied, or when it isn't
+// running and wasn't stopped with haloy stop. A config whose deploy failed
+// isn't retried until it changes.
+func planGitOps(declared []declaredApp, applied map[string]storage.GitOpsApp, running, stopped map[string]bool, failed map[string]gitOpsFailure) gitOpsPlan {
+	var plan gitOpsPlan
+	names := make(map[string]bool, len(declared))
+	for _, app := range declared {
+		appName := app.target.Name
+		names[appName] = true
+		if failure, ok := failed[appName]; ok && failure.hash == app.hash {
+			continue
+		}
+		record, ok := applied[appName]
+		if !ok || record.ConfigHash != app.hash || (!running[appName] && !stopped[appName]) {
+			plan.deploy = append(plan.deploy, app)
+		}
+	}
+	for _, record := range applied {
+		if !names[record.AppName] {
+			plan.destroy = append(plan.destroy, record)
+		}
+	}
+	slices.SortFunc(plan.destroy, func(a, b storage.GitOpsApp) int { return strings.Compare(a.AppName, b.AppName) })
+	return plan
+}
+
+// load fetches the repository and returns its commit and the apps it
+// declares for this server. Nothing is applied when it fails, so a broken
+// config never destroys the apps it no longer parses into.
+func (g *GitOps) load(ctx context.Context) (string, []declaredApp, error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, gitOpsFetchTimeout)
+	defer cancel()
+	commit, err := g.fetch(fetchCtx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	root := filepath.Join(g.dir, filepath.FromSlash(g.config.Path))
+	configPaths, err := configloader.FindWorkspaceConfigs(root)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(configPaths) == 0 {
+		return "", nil, fmt.Errorf("no haloy config found in %s at %s", g.config.Path, commit)
+	}
+
+	var declared []declaredApp
+	declaredBy := make(map[string]string)
+	for _, configPath := range configPaths {
+		apps, err := loadDeclaredApps(ctx, configPath, g.apiDomain)
+		rel, _ := filepath.Rel(g.dir, configPath)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", rel, err)
+		}
+		for _, app := range apps {
+			if other, exists := declaredBy[app.target.Name]; exists {
+				return "", nil, fmt.Errorf("app '%s' is declared in both %s and %s", app.target.Name, other, rel)
+			}
+			declaredBy[app.target.Name] = rel
+			declared = append(declared, app)
+		}
+	}
+	sortDeclaredApps(declared)
+	return commit, declared, nil
+}
+
+// loadDeclaredApps loads the targets of a config that run on the server with
+// the API domain apiDomain, with their secrets resolved.
+func loadDeclaredApps(ctx context.Context, configPath, apiDomain string) ([]declaredApp, error) {
+	rawDeployConfig, format, err := configloader.LoadRawDeployConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	rawDeployConfig.Format = format
+
+	resolvedDeployConfig, err := configloader.ResolveSecrets(ctx, rawDeployConfig, configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+	rawTargets, err := configloader.ExtractTargets(rawDeployConfig, format)
+	if err != nil {
+		return nil, err
+	}
+	resolvedTargets, err := configloader.ExtractTargets(resolvedDeployConfig, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var apps []declaredApp
+	for targetName, target := range resolvedTargets {
+		if !servesTarget(target.Server, apiDomain) {
+			continue
+		}
+		if err := configloader.InterpolateEnvVars(target.Env); err != nil {
+			return nil, fmt.Errorf("target '%s': %w", targetName, err)
+		}
+		app := declaredApp{
+			target: target,
+			rollback: config.DeployConfig{
+				TargetConfig:    rawTargets[targetName],
+				SecretProviders: rawDeployConfig.SecretProviders,
+			},
+		}
+		if target.Image != nil && target.Image.ShouldBuild() {
+			app.notDeployable = "GitOps deploys images from a registry and can't build; push the image and set image.build to false"
+		}
+		if target.ErrorPages != "" {
+			dir := target.ErrorPages
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(filepath.Dir(configPath), dir)
+			}
+			if app.errorPages, err = errorpages.ReadBundle(dir); err != nil {
+				return nil, fmt.Errorf("target '%s': %w", targetName, err)
+			}
+		}
+		if app.hash, err = configHash(app.target, app.errorPages); err != nil {
+			return nil, fmt.Errorf("target '%s': %w", targetName, err)
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// sortDeclaredApps orders apps so databases are deployed before the apps
+// that take their URL with database_url_from, then by target name.
+func sortDeclaredApps(apps []declaredApp) {
+	slices.SortFunc(apps, func(a, b declaredApp) int {
+		aUses, bUses := a.target.DatabaseURLFrom != "", b.target.DatabaseURLFrom != ""
+		if aUses != bUses {
+			if aUses {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a.target.TargetName, b.target.TargetName)
+	})
+}
+
+// configHash identifies a resolved target config, so a rotated secret
+// redeploys the app too.
+func configHash(target config.TargetConfig, errorPages map[string]string) (string, error) {
+	data, err := json.Marshal(struct {
+		Target     config.TargetConfig `json:"target"`
+		ErrorPages map[string]string   `json:"errorPages"`
+	}{target, errorPages})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// servesTarget reports whether a target's server is this server, whose API
+// domain is apiDomain. Servers may be written with a scheme and port.
+func servesTarget(server, apiDomain string) bool {
+	host := server
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	host = strings.TrimSuffix(host, "/")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return apiDomain != "" && strings.EqualFold(host, apiDomain)
+}
+
+// fetch brings the checkout to the head of the branch and returns its
+// commit. A checkout that isn't a clone of the repository is replaced.
+func (g *GitOps) fetch(ctx context.Context) (string, error) {
+	branch := g.config.GetBranch()
+	origin, err := g.git(ctx, g.dir, "remote", "get-url", "origin")
+	if err != nil || strings.TrimSpace(origin) != g.config.Repo {
+		if err := os.RemoveAll(g.dir); err != nil {
+			return "", fmt.Errorf("failed to remove old checkout: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(g.dir), 0o700); err != nil {
+			return "", fmt.Errorf("failed to create GitOps directory: %w", err)
+		}
+		if _, err := g.git(ctx, "", "clone", "--quiet", "--depth", "1", "--single-branch", "--branch", branch, "--", g.config.Repo, g.dir); err != nil {
+			return "", err
+		}
+	} else {
+		if _, err := g.git(ctx, g.dir, "fetch", "--quiet", "--depth", "1", "origin", branch); err != nil {
+			return "", err
+		}
+		if _, err := g.git(ctx, g.dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+		if _, err := g.git(ctx, g.dir, "clean", "-qfdx"); err != nil {
+			return "", err
+		}
+	}
+	commit, err := g.git(ctx, g.dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(commit), nil
+}
+
+func (g *GitOps) git(ctx context.Context, dir string, args ...string) (string, error) {
+	name := args[0]
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if g.config.SSHKeyFile != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %s -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new", g.config.SSHKeyFile))
+	}
+	var stdout, stderr strings.Builder
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s failed: %s", name, msg)
+		}
+		return "", fmt.Errorf("git %s failed: %w", name, err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,163 @@
+package haloyd
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+func TestPlanGitOps(t *testing.T) {
+	app := func(name, hash string) declaredApp {
+		return declaredApp{target: config.TargetConfig{Name: name, TargetName: name}, hash: hash}
+	}
+	declared := []declaredApp{app("unchanged", "h1"), app("changed", "h2"), app("new", "h3"), app("crashed", "h4"), app("stopped", "h5"), app("failing", "h6")}
+	applied := map[string]storage.GitOpsApp{
+		"unchanged": {AppName: "unchanged", ConfigHash: "h1"},
+		"changed":   {AppName: "changed", ConfigHash: "old"},
+		"crashed":   {AppName: "crashed", ConfigHash: "h4"},
+		"stopped":   {AppName: "stopped", ConfigHash: "h5"},
+		"removed":   {AppName: "removed", ConfigHash: "h7"},
+	}
+	running := map[string]bool{"unchanged": true, "changed": true}
+	stopped := map[string]bool{"stopped": true}
+	failed := map[string]gitOpsFailure{"failing": {hash: "h6", err: "image not found"}, "changed": {hash: "old"}}
+
+	plan := planGitOps(declared, applied, running, stopped, failed)
+
+	var deployed []string
+	for _, app := range plan.deploy {
+		deployed = append(deployed, app.target.Name)
+	}
+	want := []string{"changed", "new", "crashed"}
+	if len(deployed) != len(want) {
+		t.Fatalf("deploy = %v, want %v", deployed, want)
+	}
+	for i := range want {
+		if deployed[i] != want[i] {
+			t.Fatalf("deploy = %v, want %v", deployed, want)
+		}
+	}
+	if len(plan.destroy) != 1 || plan.destroy[0].AppName != "removed" {
+		t.Errorf("destroy = %+v, want only removed", plan.destroy)
+	}
+}
+
+func TestSortDeclaredApps(t *testing.T) {
+	apps := []declaredApp{
+		{target: config.TargetConfig{TargetName: "web", DatabaseURLFrom: "db"}},
+		{target: config.TargetConfig{TargetName: "worker", DatabaseURLFrom: "db"}},
+		{target: config.TargetConfig{TargetName: "db"}},
+		{target: config.TargetConfig{TargetName: "cache"}},
+	}
+	sortDeclaredApps(apps)
+	want := []string{"cache", "db", "web", "worker"}
+	for i, app := range apps {
+		if app.target.TargetName != want[i] {
+			t.Fatalf("order[%d] = %s, want %v", i, app.target.TargetName, want)
+		}
+	}
+}
+
+func TestServesTarget(t *testing.T) {
+	tests := []struct {
+		server string
+		want   bool
+	}{
+		{"haloy.example.com", true},
+		{"HALOY.example.com", true},
+		{"https://haloy.example.com", true},
+		{"https://haloy.example.com:8443/", true},
+		{"haloy.example.com:443", true},
+		{"other.example.com", false},
+		{"localhost", false},
+	}
+	for _, tt := range tests {
+		if got := servesTarget(tt.server, "haloy.example.com"); got != tt.want {
+			t.Errorf("servesTarget(%q) = %v, want %v", tt.server, got, tt.want)
+		}
+	}
+	if servesTarget("", "") {
+		t.Error("servesTarget() matched an empty API domain")
+	}
+}
+
+func TestGitOpsLoad(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("HALOY_CONFIG_DIR", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	repo := t.TempDir()
+	runGit := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(repo, "apps"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, "apps", "haloy.yaml"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		runGit("add", "-A")
+		runGit("commit", "-q", "-m", "update")
+	}
+
+	runGit("init", "-q", "-b", "main")
+	writeConfig(`
+targets:
+  web:
+    server: haloy.example.com
+    image:
+      repository: nginx
+      tag: "1.27"
+  elsewhere:
+    server: other.example.com
+    image:
+      repository: nginx
+`)
+
+	g := NewGitOps(config.GitOpsConfig{Repo: repo, Path: "apps"}, "haloy.example.com", filepath.Join(t.TempDir(), "gitops"), nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	commit, declared, err := g.load(context.Background())
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if len(commit) != 40 {
+		t.Errorf("commit = %q, want a SHA", commit)
+	}
+	if len(declared) != 1 || declared[0].target.Name != "web" {
+		t.Fatalf("declared = %+v, want only web", declared)
+	}
+	firstHash := declared[0].hash
+
+	writeConfig(`
+targets:
+  web:
+    server: haloy.example.com
+    image:
+      repository: nginx
+      tag: "1.28"
+`)
+	newCommit, declared, err := g.load(context.Background())
+	if err != nil {
+		t.Fatalf("load() after commit error = %v", err)
+	}
+	if newCommit == commit {
+		t.Error("load() didn't fetch the new commit")
+	}
+	if len(declared) != 1 || declared[0].hash == firstHash {
+		t.Errorf("hash of changed web = %s, want it to differ from %s", declared[0].hash, firstHash)
+	}
+}
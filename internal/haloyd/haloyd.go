@@ -2,13 +2,16 @@ package haloyd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -16,23 +19,30 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/haloydev/haloy/internal/api"
+	"github.com/haloydev/haloy/internal/apitypes"
 	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/dbbackup"
+	"github.com/haloydev/haloy/internal/deploystate"
 	"github.com/haloydev/haloy/internal/docker"
 	"github.com/haloydev/haloy/internal/healthcheck"
 	"github.com/haloydev/haloy/internal/helpers"
 	"github.com/haloydev/haloy/internal/layerstore"
 	"github.com/haloydev/haloy/internal/logging"
 	"github.com/haloydev/haloy/internal/proxyclient"
+	"github.com/haloydev/haloy/internal/sdnotify"
 	"github.com/haloydev/haloy/internal/storage"
+	"github.com/haloydev/haloy/internal/volumesnapshots"
 )
 
 const (
-	maintenanceInterval  = 12 * time.Hour   // Interval for periodic maintenance tasks
-	eventDebounceDelay   = 5 * time.Second  // Delay for debouncing container events
-	eventDebounceMaxWait = 30 * time.Second // Max debounce postponement while events keep arriving
-	eventsReconnectDelay = 5 * time.Second  // Delay before re-subscribing to Docker events after a stream error
-	updateTimeout        = 15 * time.Minute // Max time for a single update operation
+	maintenanceInterval      = 12 * time.Hour      // Interval for periodic maintenance tasks
+	eventDebounceDelay       = 5 * time.Second     // Delay for debouncing container events
+	eventDebounceMaxWait     = 30 * time.Second    // Max debounce postponement while events keep arriving
+	eventsReconnectDelay     = 5 * time.Second     // Delay before re-subscribing to Docker events after a stream error
+	updateTimeout            = 15 * time.Minute    // Max time for a single update operation
+	statusInterval           = time.Minute         // Interval haloyd refreshes its status file
+	deploymentEventRetention = 30 * 24 * time.Hour // How long the state history of ended deployments is kept
 )
 
 type ContainerEvent struct {
@@ -62,16 +72,6 @@ func Run(debug bool) {
 		logger.Info("Debug mode enabled: Staging certificates will be used for all domains.")
 	}
 
-	db, err := storage.New()
-	if err != nil {
-		logging.LogFatal(logger, "Failed to initialize database", "error", err)
-	}
-	defer db.Close()
-	if err := db.Migrate(); err != nil {
-		logging.LogFatal(logger, "Failed to run database migrations", "error", err)
-	}
-	logger.Info("Database initialized successfully")
-
 	dataDir, err := config.DataDir()
 	if err != nil {
 		logging.LogFatal(logger, "Failed to get data directory", "error", err)
@@ -80,30 +80,59 @@ func Run(debug bool) {
 	if err != nil {
 		logging.LogFatal(logger, "Failed to get haloyd config directory", "error", err)
 	}
+	status := newStatusRecorder(dataDir, logger)
+	status.write()
 	configFilePath := filepath.Join(configDir, constants.HaloydConfigFileName)
 	haloydConfig, err := config.LoadHaloydConfig(configFilePath)
 	if err != nil {
 		logging.LogFatal(logger, "Failed to load configuration file", "error", err)
 	}
 
+	db, err := storage.New()
+	if err != nil {
+		logging.LogFatal(logger, "Failed to initialize database", "error", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		logging.LogFatal(logger, "Failed to run database migrations", "error", err)
+	}
+	logger.Info("Database initialized successfully")
+
 	cli, err := docker.NewClient(ctx)
 	if err != nil {
 		logging.LogFatal(logger, "Failed to create Docker client", "error", err)
 	}
 	defer cli.Close()
 
+	containerRuntime, err := docker.DetectRuntime(ctx, cli)
+	if err != nil {
+		logging.LogFatal(logger, "Failed to detect container runtime", "error", err)
+	}
+	logger.Info("Container runtime detected", "runtime", containerRuntime.String())
+	for _, limitation := range containerRuntime.Limitations() {
+		logger.Warn("Container runtime limitation", "runtime", containerRuntime.String(), "limitation", limitation)
+	}
+	if containerRuntime.Rootless {
+		config.EnablePublishContainerPorts()
+	}
+
 	apiToken := os.Getenv(constants.EnvVarAPIToken)
 	if apiToken == "" {
 		logging.LogFatal(logger, "%s environment variable not set", constants.EnvVarAPIToken)
 	}
 
 	apiServer := api.NewServer(apiToken, db, logBroker, logLevel)
+	// Deployment logs are kept so they can be read after the stream ends.
+	logBroker.RecordDeployments(apiServer.RecordDeploymentLog)
+	if haloydConfig != nil {
+		apiServer.SetAllowedOrigins(haloydConfig.API.AllowedOrigins)
+	}
 
 	// The API is served on a loopback listener; the proxy forwards API-domain
 	// and localhost API traffic to it.
 	apiListenAddr := net.JoinHostPort(constants.HaloydAPIHost, constants.HaloydAPIPort)
 	go func() {
-		if err := apiServer.ListenAndServe(apiListenAddr); err != nil {
+		if err := apiServer.ListenAndServe(apiListenAddr); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logging.LogFatal(logger, "API listener failed", "addr", apiListenAddr, "error", err)
 		}
 	}()
@@ -134,7 +163,7 @@ func Run(debug bool) {
 	// Seed the proxy with an API-domain-only snapshot before the initial
 	// deployment discovery so the control plane stays reachable even if
 	// discovery or certificate renewal fails.
-	if err := proxyClient.Push(ctx, buildSnapshot(nil, nil, apiDomain, nil)); err != nil {
+	if err := proxyClient.Push(ctx, buildSnapshot(nil, nil, newSnapshotSettings(apiDomain, nil, nil, haloydConfig), nil)); err != nil {
 		logger.Warn("Failed to push initial proxy config", "error", err)
 	}
 
@@ -145,25 +174,132 @@ func Run(debug bool) {
 	certUpdateSignal := make(chan string, 5)
 
 	deploymentManager := NewDeploymentManager(cli, haloydConfig)
+
+	// Crash loops end without an event when the container stops restarting,
+	// so the detector asks for an update once they may be over.
+	crashLoopRecheck := make(chan struct{}, 1)
+	var crashLoopConfig config.CrashLoopConfig
+	if haloydConfig != nil {
+		crashLoopConfig = haloydConfig.HealthMonitor.CrashLoop
+	}
+	crashLoops := NewCrashLoopDetector(db, crashLoopConfig, crashLoopRecheck, logger)
+	deploymentManager.SetCrashLoopDetector(crashLoops)
+	deploymentManager.SetPausedCheck(func(appName string) bool {
+		paused, err := db.GetPausedApp(appName)
+		return err == nil && paused != nil
+	})
+	apiServer.SetContainerRestartsFunc(crashLoops.Restarts)
+
 	certManagerConfig := CertificatesManagerConfig{
 		CertDir:          filepath.Join(dataDir, constants.CertStorageDir),
 		HTTPProviderPort: constants.CertificatesHTTPProviderPort,
 		TlsStaging:       debug,
+
+		CloudflareAPIToken: os.Getenv(constants.EnvVarCloudflareAPIToken),
+		DB:                 db,
+	}
+	if haloydConfig != nil {
+		certManagerConfig.DNSCheck = haloydConfig.Certificates.GetDNSCheck()
+		certManagerConfig.Resolver = haloydConfig.Certificates.Resolver
 	}
 	certManager, err := NewCertificatesManager(certManagerConfig, certUpdateSignal)
 	if err != nil {
 		logging.LogFatal(logger, "Failed to create certificate manager", "error", err)
 	}
+	if err := certManager.SyncCertificateFiles(logger); err != nil {
+		logger.Error("Failed to sync certificate files with their history", "error", err)
+	}
+	apiServer.SetRemoveCertificatesFunc(func(domains []string) []string {
+		return certManager.RemoveCertificates(logger, domains)
+	})
+
+	// On-demand TLS needs the updater to route approved domains and the
+	// updater needs it to build snapshots, so the updater is bound late.
+	var updater *Updater
+	var queue *updateQueue
+	var onDemand *OnDemandTLS
+	if haloydConfig != nil && haloydConfig.OnDemandTLS.IsEnabled() {
+		onDemand, err = NewOnDemandTLS(haloydConfig.OnDemandTLS, dataDir, certManager, logger, func() {
+			if _, err := queue.Update(ctx, logger, TriggerReasonOnDemandTLS, nil); err != nil {
+				logger.Error("Failed to route on-demand TLS domain", "error", err)
+			}
+		})
+		if err != nil {
+			logging.LogFatal(logger, "Failed to set up on-demand TLS", "error", err)
+		}
+		certManager.challengeServer.SetOnDemandHandler(onDemand.Request)
+		go onDemand.Run(ctx)
+		logger.Info("On-demand TLS enabled", "app", haloydConfig.OnDemandTLS.App)
+	}
 
+	// An edge server routes the domains its cluster peers serve, and its
+	// updater is bound late like on-demand TLS.
+	var edge *ClusterEdge
+	if haloydConfig != nil && haloydConfig.Cluster.IsEdge() {
+		edge, err = NewClusterEdge(haloydConfig.Cluster, logger, func() {
+			if _, err := queue.Update(ctx, logger, TriggerReasonClusterPeers, nil); err != nil {
+				logger.Error("Failed to route cluster peers", "error", err)
+			}
+		})
+		if err != nil {
+			logging.LogFatal(logger, "Failed to set up cluster edge", "error", err)
+		}
+	}
+
+	states := deploystate.New(db)
+	snapshotSettings := newSnapshotSettings(apiDomain, onDemand, edge, haloydConfig)
 	updaterConfig := UpdaterConfig{
 		Cli:               cli,
 		DeploymentManager: deploymentManager,
 		CertManager:       certManager,
 		ProxyPusher:       proxyClient,
-		APIDomain:         apiDomain,
+		Snapshot:          snapshotSettings,
+		Gate:              states,
 	}
 
-	updater := NewUpdater(updaterConfig)
+	updater = NewUpdater(updaterConfig)
+	queue = newUpdateQueue(updater.Update)
+	status.setUpdateQueue(queue)
+	apiServer.SetProxyRoutesFuncs(proxyClient.Config, updater.PlannedSnapshot)
+	if edge != nil {
+		go edge.Run(ctx)
+		logger.Info("Cluster edge enabled", "peers", len(haloydConfig.Cluster.Peers))
+	}
+
+	if haloydConfig != nil && haloydConfig.DatabaseBackups.IsEnabled() {
+		backups, err := dbbackup.New(haloydConfig.DatabaseBackups)
+		if err != nil {
+			logging.LogFatal(logger, "Failed to set up database backups", "error", err)
+		}
+		go runDatabaseBackups(ctx, cli, backups, haloydConfig.DatabaseBackups.GetInterval(), logger)
+		logger.Info("Database backups enabled", "interval", haloydConfig.DatabaseBackups.GetInterval())
+	}
+
+	var volumeSnapshotsS3 config.S3Config
+	if haloydConfig != nil {
+		volumeSnapshotsS3 = haloydConfig.VolumeSnapshots.S3
+	}
+	snapshotStore := volumesnapshots.New(filepath.Join(dataDir, constants.SnapshotsDir), volumeSnapshotsS3)
+	go runVolumeSnapshots(ctx, cli, snapshotStore, logger)
+	go runPreviewExpiry(ctx, cli, apiServer, logger)
+	serverUpgrader := newServerUpgrader(db, dataDir, logger)
+	apiServer.SetServerUpgradeWakeFunc(serverUpgrader.Wake)
+	go serverUpgrader.Run(ctx)
+
+	if haloydConfig == nil || haloydConfig.UsageHistory.IsEnabled() {
+		var usageConfig config.UsageHistoryConfig
+		if haloydConfig != nil {
+			usageConfig = haloydConfig.UsageHistory
+		}
+		go newUsageRecorder(db, cli, usageConfig, logger).Run(ctx)
+	}
+
+	if haloydConfig != nil && haloydConfig.GitOps.IsEnabled() {
+		gitOps := NewGitOps(haloydConfig.GitOps, apiDomain, filepath.Join(dataDir, constants.GitOpsDir), db, cli, apiServer, logger)
+		apiServer.SetGitOpsFuncs(gitOps.Status, gitOps.Sync)
+		go gitOps.Run(ctx)
+		logger.Info("GitOps enabled", "repo", haloydConfig.GitOps.Repo, "branch", haloydConfig.GitOps.GetBranch(), "interval", haloydConfig.GitOps.GetInterval())
+	}
 
 	// Start Docker event listener BEFORE initial update so events aren't lost
 	// during long-running health check retries. Buffer allows events to queue.
@@ -183,6 +319,12 @@ func Run(debug bool) {
 	if _, err := updater.Update(ctx, logger, TriggerReasonInitial, nil); err != nil {
 		logger.Error("Initial update failed", "error", err)
 	}
+	// Updates requested meanwhile wait for the initial one to finish.
+	go queue.Run(ctx)
+
+	// Apps that lost their containers while haloyd was down, like after a
+	// restore of the server without Docker's data, are deployed again.
+	go reconcileApps(ctx, apiServer, cli, logger)
 
 	logger.Info(
 		"haloyd successfully initialized",
@@ -204,14 +346,58 @@ func Run(debug bool) {
 		} else {
 			healthConfig = healthcheck.DefaultConfig()
 		}
+		healthConfig.Exec = docker.HealthCheckExec(cli)
 
-		healthUpdater := NewHealthConfigUpdater(deploymentManager, proxyClient, apiDomain, logger)
+		healthUpdater := NewHealthConfigUpdater(deploymentManager, proxyClient, snapshotSettings, logger)
 		healthMonitor = healthcheck.NewHealthMonitor(healthConfig, deploymentManager, healthUpdater, logger)
 		healthMonitor.Start()
+		certManager.challengeServer.SetBackendFailureHandler(healthMonitor.ReportPassiveFailure)
+		status.setHealthMonitor(healthMonitor)
+	}
+	status.write()
+
+	if haloydConfig == nil || haloydConfig.Uptime.IsEnabled() {
+		var uptimeConfig config.UptimeConfig
+		if haloydConfig != nil {
+			uptimeConfig = haloydConfig.Uptime
+		}
+		var health uptimeHealth
+		if healthMonitor != nil {
+			health = healthMonitor
+		}
+		apiServer.SetUptimeObjective(uptimeConfig.GetObjective())
+		go newUptimeRecorder(db, health, proxyClient.Status, uptimeConfig, logger).Run(ctx)
+	}
+
+	if haloydConfig != nil && haloydConfig.Alerts.IsEnabled() {
+		go newAlertEngine(haloydConfig.Alerts, proxyClient.Status, updater.PlannedSnapshot, logger).Run(ctx)
+		logger.Info("Alerts enabled", "rules", len(haloydConfig.Alerts.Rules))
 	}
 
 	maintenanceTicker := time.NewTicker(maintenanceInterval)
 	defer maintenanceTicker.Stop()
+	statusTicker := time.NewTicker(statusInterval)
+	defer statusTicker.Stop()
+
+	// Under systemd, haloyd is ready once the initial update ran, and the
+	// watchdog restarts it when this loop stops turning.
+	watchdog, stopWatchdog := sdnotify.WatchdogTicks()
+	defer stopWatchdog()
+	if _, err := sdnotify.Notify(sdnotify.Ready); err != nil {
+		logger.Warn("Failed to notify systemd", "error", err)
+	}
+
+	flow := &deploymentFlow{
+		updater:   updater,
+		queue:     queue,
+		cli:       cli,
+		states:    states,
+		logLevel:  logLevel,
+		logBroker: logBroker,
+	}
+
+	// Updates started by the main loop, which shutdown waits for.
+	var updates sync.WaitGroup
 
 	// Main event loop
 	for {
@@ -219,100 +405,28 @@ func Run(debug bool) {
 
 		// All docker events are piped to debouncer
 		case e := <-eventsChan:
+			switch e.Event.Action {
+			case events.ActionDie:
+				crashLoops.ObserveDie(e.Event.Actor.ID, e.Event.Actor.Attributes)
+			case events.ActionStart:
+				go func() {
+					if info, err := cli.ContainerInspect(ctx, e.Event.Actor.ID); err == nil {
+						crashLoops.ObserveStart(info, e.Labels)
+					}
+				}()
+			}
 			appDebouncer.captureEvent(e.Labels.AppName, e)
 
-		// Debounced docker events
-		case de := <-debouncedEventsChan:
-			go func() {
-				deploymentLogger := logging.NewDeploymentLogger(de.DeploymentID, logLevel, logBroker)
-
-				updateCtx, cancelUpdate := context.WithTimeout(ctx, updateTimeout)
-				defer cancelUpdate()
-
-				app := &TriggeredByApp{
-					appName:           de.AppName,
-					domains:           de.Domains,
-					deploymentID:      de.DeploymentID,
-					dockerEventAction: de.EventAction,
-				}
-
-				if err := app.Validate(); err != nil {
-					// Signal failure so a CLI streaming this deployment stops waiting.
-					logging.LogDeploymentFailed(deploymentLogger, de.DeploymentID, de.AppName,
-						"Deployment failed", fmt.Errorf("app data not valid: %w", err))
-					return
-				}
-
-				result, err := updater.Update(updateCtx, deploymentLogger, TriggerReasonAppUpdated, app)
-				if err != nil {
-					logging.LogDeploymentFailed(deploymentLogger, de.DeploymentID, de.AppName,
-						"Deployment failed", err)
-					return
+		case <-crashLoopRecheck:
+			updates.Go(func() {
+				if _, err := queue.Update(ctx, logger, TriggerPeriodicRefresh, nil); err != nil {
+					logger.Error("Crash loop recheck update failed", "error", err)
 				}
+			})
 
-				// Start event indicates that this is a new deployment and we'll signal the logger that the deployment is done.
-				if de.CapturedStartEvent {
-					// Check if the triggering app had any failures
-					appFailures := result.GetAppFailures(de.AppName)
-
-					// Check if there are any healthy instances for this app
-					deployments := updater.deploymentManager.Deployments()
-					appDeployment, appHasHealthyInstances := deployments[de.AppName]
-
-					// Determine if the new deployment succeeded:
-					// - If there are no healthy instances at all, the deployment failed
-					// - If there are healthy instances but they're from an OLD deployment (different ID), the new deployment failed
-					newDeploymentSucceeded := appHasHealthyInstances && appDeployment.Labels.DeploymentID == de.DeploymentID
-
-					if len(appFailures) > 0 && !newDeploymentSucceeded {
-						// New deployment failed - clean up failed containers and report deployment failure
-						cleanupCtx, cleanupCancel := context.WithTimeout(ctx, 2*time.Minute)
-						defer cleanupCancel()
-
-						// Extract logs from failed containers before removing them to allow debugging
-						logContainerFailureLogs(cleanupCtx, cli, deploymentLogger, appFailures)
-
-						if _, err := docker.StopContainersByDeploymentID(cleanupCtx, cli, deploymentLogger, de.AppName, de.DeploymentID); err != nil {
-							deploymentLogger.Warn("Failed to stop containers during cleanup", "error", err)
-						}
-						if _, err := docker.RemoveContainersByDeploymentID(cleanupCtx, cli, deploymentLogger, de.AppName, de.DeploymentID); err != nil {
-							deploymentLogger.Warn("Failed to remove containers during cleanup", "error", err)
-						}
-						var failureReasons []string
-						for _, f := range appFailures {
-							failureReasons = append(failureReasons, fmt.Sprintf("%s: %v", f.Reason, f.Err))
-						}
-						logging.LogDeploymentFailed(deploymentLogger, de.DeploymentID, de.AppName,
-							"Deployment failed", fmt.Errorf("%s", strings.Join(failureReasons, "; ")))
-						return
-					}
-
-					canonicalDomains := make([]string, 0, len(de.Domains))
-					if newDeploymentSucceeded {
-						for _, domain := range appDeployment.Labels.Domains {
-							canonicalDomains = append(canonicalDomains, domain.Canonical)
-						}
-					} else {
-						// Fallback to domains from the event
-						for _, domain := range de.Domains {
-							canonicalDomains = append(canonicalDomains, domain.Canonical)
-						}
-					}
-					logging.LogDeploymentComplete(deploymentLogger, canonicalDomains, de.DeploymentID, de.AppName,
-						fmt.Sprintf("Deployed %s", de.AppName))
-				} else {
-					appFailures := result.GetAppFailures(de.AppName)
-					deployments := updater.deploymentManager.Deployments()
-					_, hasHealthy := deployments[de.AppName]
-
-					if len(appFailures) > 0 && !hasHealthy {
-						logCtx, logCancel := context.WithTimeout(ctx, 30*time.Second)
-						defer logCancel()
-						logContainerFailureLogs(logCtx, cli, deploymentLogger, appFailures)
-						deploymentLogger.Error(fmt.Sprintf("Container died after deployment for %s, app has no healthy instances", de.AppName))
-					}
-				}
-			}()
+		// Debounced docker events
+		case de := <-debouncedEventsChan:
+			updates.Go(func() { flow.handle(ctx, de) })
 
 		case domainUpdated := <-certUpdateSignal:
 			logger.Info("Received cert update signal", "domain", domainUpdated)
@@ -327,14 +441,11 @@ func Run(debug bool) {
 
 		case <-resyncChan:
 			logger.Info("Docker event stream re-established, resyncing deployments")
-			go func() {
-				resyncCtx, cancelResync := context.WithTimeout(ctx, updateTimeout)
-				defer cancelResync()
-
-				if _, err := updater.Update(resyncCtx, logger, TriggerPeriodicRefresh, nil); err != nil {
+			updates.Go(func() {
+				if _, err := queue.Update(ctx, logger, TriggerPeriodicRefresh, nil); err != nil {
 					logger.Error("Resync update failed", "error", err)
 				}
-			}()
+			})
 
 		case <-maintenanceTicker.C:
 			logger.Info("Performing periodic maintenance...")
@@ -347,32 +458,123 @@ func Run(debug bool) {
 			} else if pruned > 0 {
 				logger.Info("Pruned unused layers", "count", pruned, "bytes_freed", freed)
 			}
-			go func() {
-				deploymentCtx, cancelDeployment := context.WithTimeout(ctx, updateTimeout)
-				defer cancelDeployment()
-
-				if _, err := updater.Update(deploymentCtx, logger, TriggerPeriodicRefresh, nil); err != nil {
+			maintainImageCache(db, haloydConfig, logger)
+			crashLoops.Prune()
+			if pruned, err := flow.states.Prune(time.Now().Add(-deploymentEventRetention)); err != nil {
+				logger.Warn("Failed to prune deployment history", "error", err)
+			} else if pruned > 0 {
+				logger.Info("Pruned deployment history", "events", pruned)
+			}
+			if haloydConfig != nil && haloydConfig.GC.IsEnabled() {
+				collectOrphans(ctx, cli, db, dataDir, haloydConfig, logger)
+			}
+			status.maintenanceDone()
+			updates.Go(func() {
+				if _, err := queue.Update(ctx, logger, TriggerPeriodicRefresh, nil); err != nil {
 					logger.Error("Background update failed", "error", err)
 				}
-			}()
+			})
 
 		case err := <-errorsChan:
 			logger.Error("Error from docker events", "error", err)
 
+		case <-statusTicker.C:
+			status.write()
+
+		case <-watchdog:
+			sdnotify.Notify(sdnotify.Watchdog)
+
 		case <-sigChan:
-			logger.Info("Received shutdown signal, stopping haloyd...")
+			gracePeriod := shutdownGracePeriod(haloydConfig)
+			logger.Info("Received shutdown signal, stopping haloyd...", "grace_period", gracePeriod)
+			sdnotify.Notify(sdnotify.Stopping)
+			drainForShutdown(apiServer, &updates, gracePeriod, logger)
 			if healthMonitor != nil {
 				healthMonitor.Stop()
 			}
 			if certManager != nil {
 				certManager.Stop()
 			}
+			status.write()
 			cancel()
+			logger.Info("haloyd stopped")
 			return
 		}
 	}
 }
 
+// reconcileApps deploys apps whose live deployment is missing containers
+// again from the spec it was started with.
+func reconcileApps(ctx context.Context, apiServer *api.APIServer, cli *client.Client, logger *slog.Logger) {
+	apps, err := apiServer.Reconcile(ctx, cli, false)
+	if err != nil {
+		logger.Error("Failed to check apps for missing containers", "error", err)
+		return
+	}
+	for _, app := range apps {
+		switch app.Action {
+		case apitypes.ReconcileRecreated:
+			logger.Warn(fmt.Sprintf("Containers of %s were missing, deployed it again", app.App),
+				"deployment_id", app.DeploymentID, "new_deployment_id", app.NewDeploymentID)
+		case apitypes.ReconcileFailed:
+			logger.Error(fmt.Sprintf("Containers of %s are missing and it couldn't be deployed again", app.App),
+				"deployment_id", app.DeploymentID, "error", app.Error)
+		}
+	}
+}
+
+// collectOrphans removes orphaned resources older than gc.older_than.
+func collectOrphans(ctx context.Context, cli *client.Client, db *storage.DB, dataDir string, haloydConfig *config.HaloydConfig, logger *slog.Logger) {
+	keepDomains, err := GCKeepDomains(dataDir, haloydConfig)
+	if err != nil {
+		logger.Warn("Skipping orphan collection", "error", err)
+		return
+	}
+	orphans, err := CollectOrphans(ctx, cli, db, logger, GCOptions{
+		OlderThan:   haloydConfig.GC.GetOlderThan(),
+		CertDir:     filepath.Join(dataDir, constants.CertStorageDir),
+		KeepDomains: keepDomains,
+	})
+	if err != nil {
+		logger.Warn("Orphan collection incomplete", "error", err)
+	}
+	removed := 0
+	for _, orphan := range orphans {
+		if orphan.Removed {
+			removed++
+		}
+	}
+	if removed > 0 {
+		logger.Info("Removed orphaned resources", "count", removed)
+	}
+}
+
+// maintainImageCache keeps the pull-through image cache within its configured
+// size, or forgets cached images when the cache has been disabled.
+func maintainImageCache(db *storage.DB, haloydConfig *config.HaloydConfig, logger *slog.Logger) {
+	store, err := layerstore.New(db)
+	if err != nil {
+		logger.Warn("Failed to open layer store", "error", err)
+		return
+	}
+
+	if haloydConfig == nil || !haloydConfig.ImageCache.IsEnabled() {
+		if cleared, err := store.ClearImageCache(); err != nil {
+			logger.Warn("Failed to clear image cache", "error", err)
+		} else if cleared > 0 {
+			logger.Info("Image cache disabled, forgot cached images", "count", cleared)
+		}
+		return
+	}
+
+	pruned, freed, err := store.PruneCache(haloydConfig.ImageCache.GetMaxSizeBytes())
+	if err != nil {
+		logger.Warn("Failed to prune image cache", "error", err)
+	} else if pruned > 0 {
+		logger.Info("Pruned image cache", "count", pruned, "bytes_freed", freed)
+	}
+}
+
 // listenForDockerEvents sets up a listener for Docker events. It keeps
 // re-subscribing until ctx is cancelled: after any stream error the Docker
 // client closes the stream, and haloyd must never run without an event source.
@@ -13,7 +13,7 @@ import (
 type HealthConfigUpdater struct {
 	deploymentManager *DeploymentManager
 	proxyPusher       ProxyPusher
-	apiDomain         string
+	snapshot          snapshotSettings
 	logger            *slog.Logger
 }
 
@@ -21,13 +21,13 @@ type HealthConfigUpdater struct {
 func NewHealthConfigUpdater(
 	deploymentManager *DeploymentManager,
 	proxyPusher ProxyPusher,
-	apiDomain string,
+	snapshot snapshotSettings,
 	logger *slog.Logger,
 ) *HealthConfigUpdater {
 	return &HealthConfigUpdater{
 		deploymentManager: deploymentManager,
 		proxyPusher:       proxyPusher,
-		apiDomain:         apiDomain,
+		snapshot:          snapshot,
 		logger:            logger,
 	}
 }
@@ -57,7 +57,7 @@ func (u *HealthConfigUpdater) OnHealthChange(healthyTargets []healthcheck.Target
 		}
 	}
 
-	snapshot := buildSnapshot(deployments, u.deploymentManager.FailedDeployments(), u.apiDomain,
+	snapshot := buildSnapshot(deployments, u.deploymentManager.FailedDeployments(), u.snapshot,
 		func(inst DeploymentInstance) bool {
 			_, isHealthy := healthyIDs[inst.ContainerID]
 			return isHealthy
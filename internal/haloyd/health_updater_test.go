@@ -64,7 +64,7 @@ func TestHealthUpdaterKeepsRoutesWithoutHealthyBackends(t *testing.T) {
 
 	deploymentManager.UpdateDeployments(healthy)
 
-	updater := NewHealthConfigUpdater(deploymentManager, newInProcessPusher(proxyServer), "api.example.com", logger)
+	updater := NewHealthConfigUpdater(deploymentManager, newInProcessPusher(proxyServer), snapshotSettings{APIDomain: "api.example.com"}, logger)
 	updater.OnHealthChange(nil)
 
 	config := proxyServer.GetConfig()
@@ -116,7 +116,7 @@ func TestHealthUpdaterKeepsRouteAfterDeploymentRemoved(t *testing.T) {
 		t.Fatal("expected app to be in FailedDeployments after removal")
 	}
 
-	updater := NewHealthConfigUpdater(deploymentManager, newInProcessPusher(proxyServer), "api.example.com", logger)
+	updater := NewHealthConfigUpdater(deploymentManager, newInProcessPusher(proxyServer), snapshotSettings{APIDomain: "api.example.com"}, logger)
 	updater.OnHealthChange(nil)
 
 	cfg := proxyServer.GetConfig()
@@ -0,0 +1,266 @@
+package haloyd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+const (
+	// onDemandRetryAfter is how long a domain that failed approval or
+	// issuance is ignored, so repeated handshakes don't hammer the ask
+	// endpoint or the ACME rate limits.
+	onDemandRetryAfter = time.Hour
+	onDemandQueueSize  = 100
+)
+
+// onDemandRoutes routes the domains approved for on-demand TLS to App.
+type onDemandRoutes struct {
+	App     string
+	Domains []string
+}
+
+// OnDemandTLS issues certificates for domains haloyd doesn't route yet. The
+// proxy reports TLS handshakes for unknown domains; approved ones get a
+// certificate over HTTP-01 and are routed to the configured app from then on.
+type OnDemandTLS struct {
+	config config.OnDemandTLSConfig
+	path   string
+	logger *slog.Logger
+	client *http.Client
+
+	// issue obtains the certificate for domain; resolvesToServer checks
+	// that domain points at this server. Both are replaced in tests.
+	issue            func(domain string) error
+	resolvesToServer func(ctx context.Context, domain string) error
+	onIssued         func()
+
+	queue chan string
+
+	mu      sync.Mutex
+	domains map[string]struct{}
+	pending map[string]struct{}
+	failed  map[string]time.Time
+}
+
+// NewOnDemandTLS loads the domains approved so far from dataDir. onIssued is
+// called after a new domain got its certificate, to push its route.
+func NewOnDemandTLS(cfg config.OnDemandTLSConfig, dataDir string, certManager *CertificatesManager, logger *slog.Logger, onIssued func()) (*OnDemandTLS, error) {
+	o := &OnDemandTLS{
+		config:           cfg,
+		path:             filepath.Join(dataDir, constants.OnDemandDomainsFileName),
+		logger:           logger,
+		client:           &http.Client{Timeout: 10 * time.Second},
+		resolvesToServer: resolvesToServer,
+		onIssued:         onIssued,
+		queue:            make(chan string, onDemandQueueSize),
+		domains:          make(map[string]struct{}),
+		pending:          make(map[string]struct{}),
+		failed:           make(map[string]time.Time),
+	}
+	o.issue = func(domain string) error {
+		_, err := certManager.RefreshSync(logger, []CertificatesDomain{{Canonical: domain}})
+		return err
+	}
+
+	domains, err := readOnDemandDomains(o.path)
+	if err != nil {
+		return nil, err
+	}
+	for _, domain := range domains {
+		o.domains[domain] = struct{}{}
+	}
+	return o, nil
+}
+
+// readOnDemandDomains reads the approved domains saved at path.
+func readOnDemandDomains(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read on-demand TLS domains: %w", err)
+	}
+	var domains []string
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &domains); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	return domains, nil
+}
+
+// Run processes reported domains one at a time until ctx is done.
+func (o *OnDemandTLS) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case domain := <-o.queue:
+			o.process(ctx, domain)
+		}
+	}
+}
+
+// Request queues domain for approval and issuance unless it was handled
+// recently. It never blocks; reports are dropped while the queue is full.
+func (o *OnDemandTLS) Request(domain string) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if helpers.IsValidDomain(domain) != nil {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.domains[domain]; ok {
+		return
+	}
+	if _, ok := o.pending[domain]; ok {
+		return
+	}
+	if failedAt, ok := o.failed[domain]; ok && time.Since(failedAt) < onDemandRetryAfter {
+		return
+	}
+
+	select {
+	case o.queue <- domain:
+		o.pending[domain] = struct{}{}
+	default:
+		o.logger.Warn("On-demand TLS queue is full, ignoring domain", "domain", domain)
+	}
+}
+
+func (o *OnDemandTLS) process(ctx context.Context, domain string) {
+	err := o.obtain(ctx, domain)
+
+	o.mu.Lock()
+	delete(o.pending, domain)
+	if err != nil {
+		o.failed[domain] = time.Now()
+	} else {
+		delete(o.failed, domain)
+		o.domains[domain] = struct{}{}
+	}
+	domains := slices.Sorted(maps.Keys(o.domains))
+	o.mu.Unlock()
+
+	if err != nil {
+		o.logger.Warn("On-demand TLS refused domain", "domain", domain, "error", err)
+		return
+	}
+	if err := o.save(domains); err != nil {
+		o.logger.Error("Failed to save on-demand TLS domains", "error", err)
+	}
+	o.logger.Info(fmt.Sprintf("Issued on-demand certificate for %s, routing it to %s", domain, o.config.App),
+		"domain", domain, "app", o.config.App)
+	if o.onIssued != nil {
+		o.onIssued()
+	}
+}
+
+func (o *OnDemandTLS) obtain(ctx context.Context, domain string) error {
+	if err := o.approve(ctx, domain); err != nil {
+		return err
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	if err := o.resolvesToServer(checkCtx, domain); err != nil {
+		return err
+	}
+	return o.issue(domain)
+}
+
+// approve checks domain against the allow list, then the ask endpoint.
+func (o *OnDemandTLS) approve(ctx context.Context, domain string) error {
+	if o.config.Allows(domain) {
+		return nil
+	}
+	if o.config.Ask == "" {
+		return errors.New("domain is not in on_demand_tls.allow")
+	}
+
+	askURL, err := url.Parse(o.config.Ask)
+	if err != nil {
+		return fmt.Errorf("invalid ask URL: %w", err)
+	}
+	query := askURL.Query()
+	query.Set("domain", domain)
+	askURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, askURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ask endpoint failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("ask endpoint declined the domain with %s", resp.Status)
+	}
+	return nil
+}
+
+func (o *OnDemandTLS) save(domains []string) error {
+	data, err := json.MarshalIndent(domains, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := o.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, constants.ModeFileDefault); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, o.path)
+}
+
+// routes returns the approved domains and the app serving them. It is safe
+// to call on a nil receiver, which routes nothing.
+func (o *OnDemandTLS) routes() onDemandRoutes {
+	if o == nil {
+		return onDemandRoutes{}
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return onDemandRoutes{App: o.config.App, Domains: slices.Sorted(maps.Keys(o.domains))}
+}
+
+// certificateDomains returns the approved domains for certificate renewal.
+func (o *OnDemandTLS) certificateDomains() []CertificatesDomain {
+	var domains []CertificatesDomain
+	for _, domain := range o.routes().Domains {
+		domains = append(domains, CertificatesDomain{Canonical: domain})
+	}
+	return domains
+}
+
+// resolvesToServer returns an error unless domain's public DNS records
+// include one of this server's addresses. Certificates are only requested
+// for domains that point here, as anyone can send an arbitrary SNI.
+func resolvesToServer(ctx context.Context, domain string) error {
+	domainIPs, err := helpers.ResolveDomainDoH(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("failed to resolve domain: %w", err)
+	}
+	serverIPs, _ := helpers.GetLocalIPs()
+	if externalIPs, err := helpers.GetExternalIPs(); err == nil {
+		serverIPs = append(serverIPs, externalIPs...)
+	}
+	if !helpers.AnyIPMatch(domainIPs, serverIPs) {
+		return fmt.Errorf("domain resolves to %s, not to this server", formatIPList(domainIPs))
+	}
+	return nil
+}
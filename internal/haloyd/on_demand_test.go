@@ -0,0 +1,167 @@
+package haloyd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+func newTestOnDemandTLS(t *testing.T, cfg config.OnDemandTLSConfig, dataDir string) (*OnDemandTLS, *[]string) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var issued []string
+	o, err := NewOnDemandTLS(cfg, dataDir, nil, logger, nil)
+	if err != nil {
+		t.Fatalf("NewOnDemandTLS() error = %v", err)
+	}
+	o.issue = func(domain string) error {
+		issued = append(issued, domain)
+		return nil
+	}
+	o.resolvesToServer = func(context.Context, string) error { return nil }
+	return o, &issued
+}
+
+// drain processes every queued domain.
+func drain(o *OnDemandTLS) {
+	for {
+		select {
+		case domain := <-o.queue:
+			o.process(context.Background(), domain)
+		default:
+			return
+		}
+	}
+}
+
+func TestOnDemandTLSAllowList(t *testing.T) {
+	dataDir := t.TempDir()
+	o, issued := newTestOnDemandTLS(t, config.OnDemandTLSConfig{App: "tenants", Allow: []string{"*.customers.example.com"}}, dataDir)
+
+	o.Request("Acme.Customers.Example.com.")
+	o.Request("acme.customers.example.com")
+	o.Request("evil.example.org")
+	o.Request("../../etc")
+	drain(o)
+
+	if !slices.Equal(*issued, []string{"acme.customers.example.com"}) {
+		t.Fatalf("issued = %v, want only the allowed domain once", *issued)
+	}
+	routes := o.routes()
+	if routes.App != "tenants" || !slices.Equal(routes.Domains, []string{"acme.customers.example.com"}) {
+		t.Errorf("routes() = %+v", routes)
+	}
+
+	// Refused domains are not retried right away.
+	o.Request("evil.example.org")
+	if len(o.queue) != 0 {
+		t.Error("a refused domain must not be queued again within the retry window")
+	}
+
+	// Approved domains survive a restart.
+	reloaded, _ := newTestOnDemandTLS(t, config.OnDemandTLSConfig{App: "tenants", Allow: []string{"*.customers.example.com"}}, dataDir)
+	if got := reloaded.routes().Domains; !slices.Equal(got, []string{"acme.customers.example.com"}) {
+		t.Errorf("reloaded domains = %v", got)
+	}
+}
+
+func TestOnDemandTLSAsk(t *testing.T) {
+	ask := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("domain") != "shop.example.org" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ask.Close()
+
+	o, issued := newTestOnDemandTLS(t, config.OnDemandTLSConfig{App: "tenants", Ask: ask.URL + "/allowed?token=x"}, t.TempDir())
+	o.Request("shop.example.org")
+	o.Request("other.example.org")
+	drain(o)
+
+	if !slices.Equal(*issued, []string{"shop.example.org"}) {
+		t.Errorf("issued = %v, want only the domain the ask endpoint approved", *issued)
+	}
+}
+
+func TestOnDemandTLSRequiresDNS(t *testing.T) {
+	o, issued := newTestOnDemandTLS(t, config.OnDemandTLSConfig{App: "tenants", Allow: []string{"*.example.com"}}, t.TempDir())
+	o.resolvesToServer = func(context.Context, string) error { return errors.New("points elsewhere") }
+
+	o.Request("app.example.com")
+	drain(o)
+
+	if len(*issued) != 0 || len(o.routes().Domains) != 0 {
+		t.Errorf("domain not pointing at the server must not be issued, issued = %v", *issued)
+	}
+}
+
+func TestBuildSnapshotOnDemandRoutes(t *testing.T) {
+	deployments := map[string]Deployment{
+		"tenants": {
+			Labels:    &config.ContainerLabels{AppName: "tenants", ClientMaxBodySize: 1024},
+			Instances: []DeploymentInstance{{IP: "10.0.0.2", Port: "8080"}},
+		},
+		"web": {
+			Labels:    &config.ContainerLabels{AppName: "web", Domains: []config.Domain{{Canonical: "taken.example.com"}}},
+			Instances: []DeploymentInstance{{IP: "10.0.0.3", Port: "8080"}},
+		},
+	}
+	o, _ := newTestOnDemandTLS(t, config.OnDemandTLSConfig{App: "tenants", Allow: []string{"*"}}, t.TempDir())
+	o.domains["shop.example.org"] = struct{}{}
+	o.domains["taken.example.com"] = struct{}{}
+	snap := buildSnapshot(deployments, nil, snapshotSettings{APIDomain: "api.example.com", OnDemand: o}, nil)
+
+	if !snap.OnDemandTLS {
+		t.Error("snapshot should enable on-demand TLS")
+	}
+	var shop, taken int
+	for _, route := range snap.Routes {
+		switch route.Canonical {
+		case "shop.example.org":
+			shop++
+			if route.App != "tenants" || len(route.Backends) != 1 || route.Backends[0].IP != "10.0.0.2" || route.MaxBodyBytes != 1024 {
+				t.Errorf("on-demand route = %+v, want the tenants app's backends and limits", route)
+			}
+		case "taken.example.com":
+			taken++
+			if route.App != "web" {
+				t.Errorf("deployed domain routed to %q, want web", route.App)
+			}
+		}
+	}
+	if shop != 1 || taken != 1 {
+		t.Errorf("got %d shop and %d taken routes, want one each", shop, taken)
+	}
+}
+
+func TestChallengeServerOnDemandReports(t *testing.T) {
+	cs := NewChallengeServer("0")
+	var reported []string
+	cs.SetOnDemandHandler(func(domain string) { reported = append(reported, domain) })
+
+	r := httptest.NewRequest(http.MethodPost, constants.OnDemandTLSPath, strings.NewReader("domain=shop.example.org"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	cs.ServeHTTP(w, r)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+
+	// The proxy forwards challenge paths from the internet; they must not
+	// reach the on-demand handler.
+	r = httptest.NewRequest(http.MethodPost, "/.well-known/acme-challenge/.."+constants.OnDemandTLSPath+"?domain=evil.example.org", nil)
+	cs.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !slices.Equal(reported, []string{"shop.example.org"}) {
+		t.Errorf("reported = %v", reported)
+	}
+}
@@ -0,0 +1,60 @@
+package haloyd
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/api"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/docker"
+)
+
+const (
+	// previewExpiryInterval is how often haloyd looks for expired previews.
+	previewExpiryInterval = 5 * time.Minute
+	// previewDestroyTimeout bounds the destroy of one expired preview.
+	previewDestroyTimeout = 5 * time.Minute
+)
+
+// runPreviewExpiry destroys the previews on this server whose TTL has run
+// out, with their volumes and certificates, until ctx is done. Every server
+// expires the previews it runs.
+func runPreviewExpiry(ctx context.Context, cli *client.Client, apiServer *api.APIServer, logger *slog.Logger) {
+	ticker := time.NewTicker(previewExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		containers, err := docker.GetAppContainers(ctx, cli, true, "")
+		if err != nil {
+			logger.Warn("Skipping preview expiry", "error", err)
+			continue
+		}
+		for _, preview := range expiredPreviews(api.Previews(containers), time.Now()) {
+			logger.Info("Preview expired", "app", preview.App, "branch", preview.Branch, "expired_at", preview.ExpiresAt)
+			destroyCtx, cancel := context.WithTimeout(ctx, previewDestroyTimeout)
+			req := apitypes.DestroyAppRequest{RemoveCertificates: true}
+			if _, err := apiServer.DestroyApp(destroyCtx, cli, preview.App, req, logger); err != nil {
+				logger.Error("Failed to destroy expired preview", "app", preview.App, "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+func expiredPreviews(previews []apitypes.Preview, now time.Time) []apitypes.Preview {
+	var expired []apitypes.Preview
+	for _, preview := range previews {
+		if !preview.ExpiresAt.IsZero() && preview.ExpiresAt.Before(now) {
+			expired = append(expired, preview)
+		}
+	}
+	return expired
+}
@@ -5,22 +5,89 @@ import (
 	"strings"
 	"time"
 
+	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
 	"github.com/haloydev/haloy/internal/proxywire"
 )
 
+// snapshotSettings are the server-wide parts of a routing snapshot.
+type snapshotSettings struct {
+	APIDomain string
+	// APIAllowedNetworks limits the API domain to clients in these CIDRs.
+	APIAllowedNetworks []string
+	// OnDemand is nil unless on-demand TLS is enabled.
+	OnDemand *OnDemandTLS
+	TLS      *proxywire.TLSSettings
+	// PassiveHealth is nil unless passive health thresholds are configured.
+	PassiveHealth *proxywire.PassiveHealthSettings
+	// AccessLog is nil unless the access log is configured.
+	AccessLog *proxywire.AccessLogSettings
+	// Cluster is nil unless the server is part of a cluster.
+	Cluster *proxywire.ClusterSettings
+	// Edge is nil unless the server routes the domains of cluster peers.
+	Edge *ClusterEdge
+}
+
+// newSnapshotSettings returns the snapshot settings from haloyd's config.
+// haloydConfig and edge may be nil.
+func newSnapshotSettings(apiDomain string, onDemand *OnDemandTLS, edge *ClusterEdge, haloydConfig *config.HaloydConfig) snapshotSettings {
+	settings := snapshotSettings{APIDomain: apiDomain, OnDemand: onDemand, Edge: edge}
+	if haloydConfig != nil {
+		settings.APIAllowedNetworks = haloydConfig.API.AllowedNetworks
+	}
+	if haloydConfig != nil && haloydConfig.TLS.IsSet() {
+		settings.TLS = &proxywire.TLSSettings{
+			MinVersion:       haloydConfig.TLS.MinVersion,
+			CipherSuites:     haloydConfig.TLS.CipherSuites,
+			CurvePreferences: haloydConfig.TLS.CurvePreferences,
+		}
+	}
+	if haloydConfig != nil && haloydConfig.HealthMonitor.Passive.IsSet() {
+		passive := haloydConfig.HealthMonitor.Passive
+		settings.PassiveHealth = &proxywire.PassiveHealthSettings{
+			MaxLatencyMS: passive.GetMaxLatency().Milliseconds(),
+			MaxErrorRate: passive.MaxErrorRate,
+			Eject:        passive.Eject,
+		}
+	}
+	if haloydConfig != nil && haloydConfig.AccessLog.IsSet() {
+		settings.AccessLog = &proxywire.AccessLogSettings{
+			Disabled: !haloydConfig.AccessLog.IsEnabled(),
+			Sample:   haloydConfig.AccessLog.Sample,
+		}
+	}
+	if haloydConfig != nil && haloydConfig.Cluster.IsEnabled() {
+		cluster := haloydConfig.Cluster
+		settings.Cluster = &proxywire.ClusterSettings{
+			Listen:   cluster.Listen,
+			CAFile:   cluster.CAFile,
+			CertFile: cluster.CertFile,
+			KeyFile:  cluster.KeyFile,
+		}
+	}
+	return settings
+}
+
 // buildSnapshot converts deployments into a proxy routing snapshot.
 // includeInstance filters which instances become backends; nil includes all.
 // Apps in failedDeployments that are no longer deployed keep their routes with
-// no backends, so the proxy serves 502 instead of 404 for them. Validation of
-// domain collisions happens when the snapshot is converted to a proxy config.
+// no backends, so the proxy serves 502 instead of 404 for them. Domains
+// approved for on-demand TLS are routed to their app unless a deployment
+// routes them itself. On an edge server, the routes of answering cluster
+// peers are added with the peers as backends. Validation of domain collisions happens when the
+// snapshot is converted to a proxy config.
 func buildSnapshot(
 	deployments map[string]Deployment,
 	failedDeployments map[string]Deployment,
-	apiDomain string,
+	settings snapshotSettings,
 	includeInstance func(DeploymentInstance) bool,
 ) *proxywire.Snapshot {
 	var routes []proxywire.Route
+	onDemand := settings.OnDemand.routes()
+	// routed collects every host a deployment routes; onDemandRoute carries
+	// the on-demand app's backends and limits.
+	routed := make(map[string]struct{})
+	onDemandRoute := proxywire.Route{App: onDemand.App}
 
 	for _, d := range deployments {
 		var backends []proxywire.Backend
@@ -30,15 +97,38 @@ func buildSnapshot(
 			}
 			backends = append(backends, proxywire.Backend{IP: inst.IP, Port: inst.Port})
 		}
+		if d.Labels.AppName == onDemand.App {
+			onDemandRoute.Backends = backends
+			onDemandRoute.MaxBodyBytes = d.Labels.ClientMaxBodySize
+			onDemandRoute.ReadTimeoutMS = d.Labels.ProxyReadTimeout.Milliseconds()
+			onDemandRoute.SendTimeoutMS = d.Labels.ProxySendTimeout.Milliseconds()
+		}
 
 		for _, domain := range d.Labels.Domains {
 			if domain.Canonical == "" {
 				continue
 			}
+			routed[domain.Canonical] = struct{}{}
+			for _, alias := range domain.Aliases {
+				routed[alias] = struct{}{}
+			}
 			routes = append(routes, proxywire.Route{
-				Canonical: domain.Canonical,
-				Aliases:   domain.Aliases,
-				Backends:  backends,
+				Canonical:   domain.Canonical,
+				Aliases:     domain.Aliases,
+				App:         d.Labels.AppName,
+				PathPrefix:  domain.NormalizedPathPrefix(),
+				StripPrefix: domain.StripPrefix,
+				CDN:         domain.CDN,
+				Backends:    backends,
+
+				MaxBodyBytes:  d.Labels.ClientMaxBodySize,
+				ReadTimeoutMS: d.Labels.ProxyReadTimeout.Milliseconds(),
+				SendTimeoutMS: d.Labels.ProxySendTimeout.Milliseconds(),
+				Redirects:     routeRedirects(domain, d.Labels.Redirects),
+
+				URLNormalization: routeURLNormalization(d.Labels.URLNormalization),
+				ClientAuth:       routeClientAuth(d.Labels.ClientAuth),
+				SSO:              routeSSO(d.Labels.SSO),
 			})
 		}
 	}
@@ -52,23 +142,105 @@ func buildSnapshot(
 			if domain.Canonical == "" {
 				continue
 			}
+			routed[domain.Canonical] = struct{}{}
+			for _, alias := range domain.Aliases {
+				routed[alias] = struct{}{}
+			}
 			routes = append(routes, proxywire.Route{
-				Canonical: domain.Canonical,
-				Aliases:   domain.Aliases,
+				Canonical:   domain.Canonical,
+				Aliases:     domain.Aliases,
+				App:         appName,
+				PathPrefix:  domain.NormalizedPathPrefix(),
+				StripPrefix: domain.StripPrefix,
+				CDN:         domain.CDN,
+				Redirects:   routeRedirects(domain, d.Labels.Redirects),
+
+				URLNormalization: routeURLNormalization(d.Labels.URLNormalization),
+				ClientAuth:       routeClientAuth(d.Labels.ClientAuth),
+				SSO:              routeSSO(d.Labels.SSO),
 			})
 		}
 	}
 
+	routes = addPeerRoutes(routes, settings.Edge.routesByPeer(), routed)
+
+	for _, domain := range onDemand.Domains {
+		if _, ok := routed[domain]; ok {
+			continue
+		}
+		route := onDemandRoute
+		route.Canonical = domain
+		routes = append(routes, route)
+	}
+
 	// Deterministic order keeps the snapshot file diff-friendly.
-	slices.SortFunc(routes, func(a, b proxywire.Route) int {
-		return strings.Compare(a.Canonical, b.Canonical)
-	})
+	proxywire.SortRoutes(routes)
 
 	return &proxywire.Snapshot{
-		SchemaVersion: proxywire.SchemaVersion,
-		GeneratedAt:   time.Now().UTC(),
-		APIDomain:     apiDomain,
-		APIBackend:    &proxywire.Backend{IP: constants.HaloydAPIHost, Port: constants.HaloydAPIPort},
-		Routes:        routes,
+		SchemaVersion:      proxywire.SchemaVersion,
+		GeneratedAt:        time.Now().UTC(),
+		APIDomain:          settings.APIDomain,
+		APIBackend:         &proxywire.Backend{IP: constants.HaloydAPIHost, Port: constants.HaloydAPIPort},
+		APIAllowedNetworks: settings.APIAllowedNetworks,
+		Routes:             routes,
+		OnDemandTLS:        onDemand.App != "",
+		TLS:                settings.TLS,
+		PassiveHealth:      settings.PassiveHealth,
+		AccessLog:          settings.AccessLog,
+		Cluster:            settings.Cluster,
+	}
+}
+
+// routeRedirects returns the redirects that apply to the route of domain:
+// those without a host and those for its canonical domain or an alias.
+func routeRedirects(domain config.Domain, redirects []config.Redirect) []proxywire.Redirect {
+	var matched []proxywire.Redirect
+	for _, r := range redirects {
+		if r.Host != "" && !strings.EqualFold(r.Host, domain.Canonical) &&
+			!slices.ContainsFunc(domain.Aliases, func(alias string) bool { return strings.EqualFold(r.Host, alias) }) {
+			continue
+		}
+		matched = append(matched, proxywire.Redirect{
+			Host:   strings.ToLower(r.Host),
+			From:   r.From,
+			To:     r.To,
+			Status: r.GetStatus(),
+		})
+	}
+	return matched
+}
+
+// routeURLNormalization returns the wire form of n, which may be nil.
+func routeURLNormalization(n *config.URLNormalization) *proxywire.URLNormalization {
+	if n == nil {
+		return nil
+	}
+	return &proxywire.URLNormalization{
+		TrailingSlash: n.TrailingSlash,
+		Lowercase:     n.Lowercase,
+		MergeSlashes:  n.MergeSlashes,
+	}
+}
+
+// routeClientAuth returns the wire form of ca, which may be nil.
+func routeClientAuth(ca *config.ClientAuth) *proxywire.ClientAuth {
+	if ca == nil {
+		return nil
+	}
+	return &proxywire.ClientAuth{CA: ca.CAInline, Mode: ca.GetMode()}
+}
+
+// routeSSO returns the wire form of sso, which may be nil.
+func routeSSO(sso *config.SSO) *proxywire.SSO {
+	if sso == nil {
+		return nil
+	}
+	return &proxywire.SSO{
+		Provider:       sso.Provider,
+		Issuer:         sso.Issuer,
+		ClientID:       sso.ClientID,
+		ClientSecret:   sso.ClientSecret.Value,
+		AllowedEmails:  sso.AllowedEmails,
+		AllowedDomains: sso.AllowedDomains,
 	}
 }
@@ -0,0 +1,307 @@
+package haloyd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/haloydev/haloy/internal/api"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+const (
+	// serverUpgradeUnit is the transient systemd unit a scheduled upgrade runs
+	// in. Outside haloyd's cgroup, the upgrade survives the script stopping
+	// haloyd.
+	serverUpgradeUnit = "haloy-upgrade"
+	// serverUpgradeNotifyTimeout bounds the notification of a finished upgrade.
+	serverUpgradeNotifyTimeout = 10 * time.Second
+)
+
+// serverUpgrader runs the upgrade scheduled with haloy server upgrade once its
+// window starts. The upgrade script restarts haloyd, so an upgrade that
+// succeeded is usually recorded by the new haloyd, which finds it running
+// with a different version than it started from.
+type serverUpgrader struct {
+	db      *storage.DB
+	dataDir string
+	logger  *slog.Logger
+	now     func() time.Time
+	// download fetches the upgrade script to path.
+	download func(ctx context.Context, path string) error
+	// start runs the upgrade script with its output in logPath, and returns
+	// a func waiting for it to exit.
+	start func(scriptPath, logPath string) (wait func() error, err error)
+	// inProgress reports whether an upgrade started by an earlier haloyd
+	// still runs.
+	inProgress func() bool
+	notify     func(ctx context.Context, url string, body []byte) error
+
+	// waiting is set while this haloyd waits for the upgrade it started.
+	waiting atomic.Bool
+	wake    chan struct{}
+}
+
+func newServerUpgrader(db *storage.DB, dataDir string, logger *slog.Logger) *serverUpgrader {
+	return &serverUpgrader{
+		db:         db,
+		dataDir:    dataDir,
+		logger:     logger,
+		now:        time.Now,
+		download:   downloadUpgradeScript,
+		start:      startUpgradeScript,
+		inProgress: upgradeUnitActive,
+		notify:     postJSONNotification,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Wake checks for a due upgrade without waiting for the next minute.
+func (u *serverUpgrader) Wake() {
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run checks for a due upgrade now, at the start of every minute and when
+// woken, until ctx is done.
+func (u *serverUpgrader) Run(ctx context.Context) {
+	for {
+		u.check(ctx)
+
+		now := time.Now()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(now.Truncate(time.Minute).Add(time.Minute).Sub(now)):
+		case <-u.wake:
+		}
+	}
+}
+
+func (u *serverUpgrader) check(ctx context.Context) {
+	upgrade, err := u.db.GetServerUpgrade()
+	if err != nil {
+		u.logger.Warn("Failed to check for a scheduled server upgrade", "error", err)
+		return
+	}
+	if upgrade == nil {
+		return
+	}
+
+	switch upgrade.State {
+	case storage.ServerUpgradeScheduled:
+		now := u.now()
+		switch {
+		case now.Before(upgrade.StartAt):
+		case now.After(upgrade.Deadline):
+			u.finish(ctx, *upgrade, storage.ServerUpgradeMissed, "haloyd wasn't running during the upgrade window")
+		default:
+			u.run(ctx, *upgrade)
+		}
+
+	case storage.ServerUpgradeRunning:
+		if u.waiting.Load() || u.inProgress() {
+			return
+		}
+		// The script restarts haloyd with the new version, or with the old
+		// one after rolling back a failed upgrade.
+		if upgrade.FromVersion != constants.Version {
+			upgrade.ToVersion = constants.Version
+			u.finish(ctx, *upgrade, storage.ServerUpgradeCompleted, "")
+		} else {
+			u.finish(ctx, *upgrade, storage.ServerUpgradeFailed, fmt.Sprintf("haloyd is still at %s: %s", constants.Version, u.lastLogLine()))
+		}
+	}
+}
+
+// run downloads the upgrade script and starts it.
+func (u *serverUpgrader) run(ctx context.Context, upgrade storage.ServerUpgrade) {
+	upgrade.State = storage.ServerUpgradeRunning
+	upgrade.FromVersion = constants.Version
+	upgrade.UpdatedAt = u.now()
+	if err := u.db.SaveServerUpgrade(upgrade); err != nil {
+		u.logger.Error("Failed to start server upgrade", "error", err)
+		return
+	}
+	u.logger.Info("Starting scheduled server upgrade", "schedule", upgrade.Schedule, "version", constants.Version)
+
+	logPath := filepath.Join(u.dataDir, constants.ServerUpgradeLogFileName)
+	scriptPath := filepath.Join(u.dataDir, constants.TempDir, "upgrade-server.sh")
+	if err := u.download(ctx, scriptPath); err != nil {
+		u.finish(ctx, upgrade, storage.ServerUpgradeFailed, err.Error())
+		return
+	}
+	wait, err := u.start(scriptPath, logPath)
+	if err != nil {
+		u.finish(ctx, upgrade, storage.ServerUpgradeFailed, err.Error())
+		return
+	}
+
+	u.waiting.Store(true)
+	go func() {
+		defer u.waiting.Store(false)
+		if err := wait(); err != nil {
+			if u.inProgress() {
+				// Stopping haloyd stopped the wait, not the upgrade.
+				return
+			}
+			u.finish(ctx, upgrade, storage.ServerUpgradeFailed, fmt.Sprintf("%v: %s", err, u.lastLogLine()))
+			return
+		}
+		// A script that upgraded haloyd restarts it, so this one is still
+		// running only when it was up to date.
+		upgrade.ToVersion = constants.Version
+		u.finish(ctx, upgrade, storage.ServerUpgradeCompleted, u.lastLogLine())
+	}()
+}
+
+// finish records the outcome of an upgrade and sends its notification.
+func (u *serverUpgrader) finish(ctx context.Context, upgrade storage.ServerUpgrade, state, message string) {
+	upgrade.State = state
+	upgrade.Message = message
+	upgrade.UpdatedAt = u.now()
+	if err := u.db.SaveServerUpgrade(upgrade); err != nil {
+		u.logger.Error("Failed to record server upgrade result", "state", state, "error", err)
+	}
+
+	if state == storage.ServerUpgradeCompleted {
+		u.logger.Info("Server upgrade completed", "from", upgrade.FromVersion, "to", upgrade.ToVersion)
+	} else {
+		u.logger.Error("Server upgrade "+state, "message", message)
+	}
+
+	if upgrade.NotifyURL == "" {
+		return
+	}
+	info := api.ServerUpgradeInfo(upgrade)
+	info.Server, _ = os.Hostname()
+	body, err := json.Marshal(info)
+	if err != nil {
+		return
+	}
+	notifyCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), serverUpgradeNotifyTimeout)
+	defer cancel()
+	if err := u.notify(notifyCtx, upgrade.NotifyURL, body); err != nil {
+		u.logger.Warn("Failed to send server upgrade notification", "error", err)
+	}
+}
+
+// lastLogLine returns the last line the upgrade script printed, which
+// explains why it stopped.
+func (u *serverUpgrader) lastLogLine() string {
+	f, err := os.Open(filepath.Join(u.dataDir, constants.ServerUpgradeLogFileName))
+	if err != nil {
+		return "no upgrade log"
+	}
+	defer f.Close()
+
+	last := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			last = line
+		}
+	}
+	return last
+}
+
+func downloadUpgradeScript(ctx context.Context, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, constants.UpgradeServerScriptURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download the upgrade script: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download the upgrade script: %s", resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), constants.ModeDirPrivate); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, constants.ModeFileExec)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to download the upgrade script: %w", err)
+	}
+	return f.Close()
+}
+
+// startUpgradeScript runs the script detached from haloyd, which it stops
+// and restarts. Under systemd it runs in its own unit, elsewhere in its own
+// session.
+func startUpgradeScript(scriptPath, logPath string) (func() error, error) {
+	if err := os.WriteFile(logPath, fmt.Appendf(nil, "Scheduled upgrade from %s started at %s\n", constants.Version, time.Now().Format(time.RFC3339)), constants.ModeFileDefault); err != nil {
+		return nil, err
+	}
+
+	var cmd *exec.Cmd
+	if helpers.DetectInitSystem() == helpers.InitSystemd {
+		cmd = exec.Command("systemd-run", "--unit="+serverUpgradeUnit, "--collect", "--wait", "--quiet",
+			"--property=StandardOutput=append:"+logPath, "--property=StandardError=append:"+logPath,
+			"sh", scriptPath)
+	} else {
+		logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, constants.ModeFileDefault)
+		if err != nil {
+			return nil, err
+		}
+		defer logFile.Close()
+		cmd = exec.Command("setsid", "sh", scriptPath)
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the upgrade script: %w", err)
+	}
+	return cmd.Wait, nil
+}
+
+// upgradeUnitActive reports whether the systemd unit of an upgrade is still
+// running. Without systemd there is no way to tell, and it reports false.
+func upgradeUnitActive() bool {
+	if helpers.DetectInitSystem() != helpers.InitSystemd {
+		return false
+	}
+	return exec.Command("systemctl", "is-active", "--quiet", serverUpgradeUnit).Run() == nil
+}
+
+func postJSONNotification(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notify URL returned %s", resp.Status)
+	}
+	return nil
+}
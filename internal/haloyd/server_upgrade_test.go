@@ -0,0 +1,174 @@
+package haloyd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+type testUpgrader struct {
+	*serverUpgrader
+	started  int
+	exit     chan error
+	notified []apitypes.ServerUpgrade
+}
+
+func newTestUpgrader(t *testing.T, now time.Time, upgrade *storage.ServerUpgrade) *testUpgrader {
+	t.Helper()
+
+	db := newTestCertificatesDB(t)
+	if upgrade != nil {
+		if err := db.SaveServerUpgrade(*upgrade); err != nil {
+			t.Fatalf("SaveServerUpgrade() error = %v", err)
+		}
+	}
+
+	tu := &testUpgrader{exit: make(chan error, 1)}
+	tu.serverUpgrader = &serverUpgrader{
+		db:       db,
+		dataDir:  t.TempDir(),
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		now:      func() time.Time { return now },
+		download: func(context.Context, string) error { return nil },
+		start: func(string, string) (func() error, error) {
+			tu.started++
+			return func() error { return <-tu.exit }, nil
+		},
+		inProgress: func() bool { return false },
+		notify: func(_ context.Context, _ string, body []byte) error {
+			var info apitypes.ServerUpgrade
+			if err := json.Unmarshal(body, &info); err != nil {
+				t.Errorf("notification body %s: %v", body, err)
+			}
+			tu.notified = append(tu.notified, info)
+			return nil
+		},
+	}
+	return tu
+}
+
+func (tu *testUpgrader) upgrade(t *testing.T) storage.ServerUpgrade {
+	t.Helper()
+	upgrade, err := tu.db.GetServerUpgrade()
+	if err != nil || upgrade == nil {
+		t.Fatalf("GetServerUpgrade() = %v, %v", upgrade, err)
+	}
+	return *upgrade
+}
+
+func scheduledUpgrade(startAt time.Time) *storage.ServerUpgrade {
+	return &storage.ServerUpgrade{
+		Schedule:  "Sat 03:00",
+		Timezone:  "UTC",
+		StartAt:   startAt,
+		Deadline:  startAt.Add(time.Hour),
+		NotifyURL: "https://hooks.example.com/upgrade",
+		State:     storage.ServerUpgradeScheduled,
+	}
+}
+
+func TestServerUpgrader_WaitsForWindow(t *testing.T) {
+	startAt := time.Date(2026, 3, 7, 3, 0, 0, 0, time.UTC)
+	tu := newTestUpgrader(t, startAt.Add(-time.Minute), scheduledUpgrade(startAt))
+
+	tu.check(context.Background())
+
+	if tu.started != 0 {
+		t.Error("upgrade started before its window")
+	}
+	if got := tu.upgrade(t).State; got != storage.ServerUpgradeScheduled {
+		t.Errorf("state = %s, want scheduled", got)
+	}
+}
+
+func TestServerUpgrader_MissedWindow(t *testing.T) {
+	startAt := time.Date(2026, 3, 7, 3, 0, 0, 0, time.UTC)
+	tu := newTestUpgrader(t, startAt.Add(2*time.Hour), scheduledUpgrade(startAt))
+
+	tu.check(context.Background())
+
+	if tu.started != 0 {
+		t.Error("upgrade started after its window")
+	}
+	if got := tu.upgrade(t).State; got != storage.ServerUpgradeMissed {
+		t.Errorf("state = %s, want missed", got)
+	}
+	if len(tu.notified) != 1 || tu.notified[0].State != storage.ServerUpgradeMissed {
+		t.Errorf("notified = %+v, want one missed notification", tu.notified)
+	}
+}
+
+func TestServerUpgrader_ScriptFails(t *testing.T) {
+	startAt := time.Date(2026, 3, 7, 3, 0, 0, 0, time.UTC)
+	tu := newTestUpgrader(t, startAt.Add(time.Minute), scheduledUpgrade(startAt))
+
+	tu.check(context.Background())
+	if tu.started != 1 {
+		t.Fatalf("started the upgrade %d times, want once", tu.started)
+	}
+	if upgrade := tu.upgrade(t); upgrade.State != storage.ServerUpgradeRunning || upgrade.FromVersion != constants.Version {
+		t.Fatalf("upgrade = %+v, want running from %s", upgrade, constants.Version)
+	}
+
+	// Checks while the script runs leave it alone.
+	tu.check(context.Background())
+
+	tu.exit <- errors.New("exit status 1")
+	deadline := time.Now().Add(5 * time.Second)
+	for tu.waiting.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("still waiting for the upgrade after the script failed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := tu.upgrade(t).State; got != storage.ServerUpgradeFailed {
+		t.Errorf("state = %s, want failed", got)
+	}
+	if tu.started != 1 {
+		t.Errorf("started the upgrade %d times, want once", tu.started)
+	}
+	if len(tu.notified) != 1 || tu.notified[0].State != storage.ServerUpgradeFailed {
+		t.Errorf("notified = %+v, want one failed notification", tu.notified)
+	}
+}
+
+func TestServerUpgrader_FinishesAfterRestart(t *testing.T) {
+	tests := []struct {
+		name        string
+		fromVersion string
+		want        string
+	}{
+		{name: "new version", fromVersion: "v0.0.1-old", want: storage.ServerUpgradeCompleted},
+		{name: "same version", fromVersion: constants.Version, want: storage.ServerUpgradeFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startAt := time.Date(2026, 3, 7, 3, 0, 0, 0, time.UTC)
+			upgrade := scheduledUpgrade(startAt)
+			upgrade.State = storage.ServerUpgradeRunning
+			upgrade.FromVersion = tt.fromVersion
+			tu := newTestUpgrader(t, startAt.Add(5*time.Minute), upgrade)
+
+			tu.check(context.Background())
+
+			got := tu.upgrade(t)
+			if got.State != tt.want {
+				t.Errorf("state = %s, want %s", got.State, tt.want)
+			}
+			if tt.want == storage.ServerUpgradeCompleted && got.ToVersion != constants.Version {
+				t.Errorf("ToVersion = %q, want %q", got.ToVersion, constants.Version)
+			}
+			if len(tu.notified) != 1 || tu.notified[0].State != tt.want {
+				t.Errorf("notified = %+v, want one %s notification", tu.notified, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package haloyd
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/api"
+	"github.com/haloydev/haloy/internal/config"
+)
+
+// shutdownGracePeriod returns how long haloyd waits for work in progress
+// when it stops.
+func shutdownGracePeriod(haloydConfig *config.HaloydConfig) time.Duration {
+	if haloydConfig == nil {
+		return config.DefaultShutdownGracePeriod
+	}
+	return haloydConfig.Shutdown.GetGracePeriod()
+}
+
+// drainForShutdown lets the work in progress finish within gracePeriod, in
+// order: the API refuses new deployments and waits for running ones, ends its
+// streams and drains its requests, then the updates started by the main loop
+// finish routing deployments and stopping the containers they replace. Work
+// cut off at the end of the grace period is picked up again when haloyd
+// restarts, and haloy-proxy keeps serving its current routes meanwhile.
+func drainForShutdown(apiServer *api.APIServer, updates *sync.WaitGroup, gracePeriod time.Duration, logger *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+
+	if err := apiServer.Shutdown(ctx); err != nil {
+		logger.Warn("Stopped the API before its work was done", "error", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		updates.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Warn("Stopping with proxy updates still running; they are redone after haloyd restarts")
+	}
+}
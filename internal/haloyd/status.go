@@ -0,0 +1,152 @@
+package haloyd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/healthcheck"
+)
+
+// RuntimeStatus is what a running haloyd records about itself in the data
+// directory. 'haloyd status' reads it directly, so it works when the API
+// doesn't.
+type RuntimeStatus struct {
+	PID       int       `json:"pid"`
+	Version   string    `json:"version"`
+	StartedAt time.Time `json:"started_at"`
+	// UpdatedAt is when the file was last written, at least every
+	// statusInterval while haloyd runs.
+	UpdatedAt         time.Time `json:"updated_at"`
+	LastMaintenanceAt time.Time `json:"last_maintenance_at,omitzero"`
+	// HealthMonitor is nil when the health monitor is disabled.
+	HealthMonitor *HealthSummary `json:"health_monitor,omitempty"`
+	// UpdateQueue is nil until haloyd set up its updater.
+	UpdateQueue *UpdateQueueSummary `json:"update_queue,omitempty"`
+}
+
+// UpdateQueueSummary is the state of the queue of proxy and deployment
+// updates.
+type UpdateQueueSummary struct {
+	// Waiting counts the updates waiting for the one running to finish.
+	Waiting int  `json:"waiting"`
+	Running bool `json:"running"`
+}
+
+// HealthSummary counts the containers the health monitor checks.
+type HealthSummary struct {
+	Targets   int `json:"targets"`
+	Healthy   int `json:"healthy"`
+	Unhealthy int `json:"unhealthy"`
+	// UnhealthyApps are the apps with at least one unhealthy container.
+	UnhealthyApps []string `json:"unhealthy_apps,omitempty"`
+}
+
+// ReadRuntimeStatus reads the status haloyd last recorded in dataDir. A
+// missing file is returned as is, for haloyd never having run.
+func ReadRuntimeStatus(dataDir string) (*RuntimeStatus, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, constants.HaloydStatusFileName))
+	if err != nil {
+		return nil, err
+	}
+	var status RuntimeStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", constants.HaloydStatusFileName, err)
+	}
+	return &status, nil
+}
+
+// Fresh reports whether the status was written recently enough to come from
+// a haloyd that is still running.
+func (s *RuntimeStatus) Fresh(now time.Time) bool {
+	return now.Sub(s.UpdatedAt) < 3*statusInterval
+}
+
+// statusRecorder keeps haloyd's status file current.
+type statusRecorder struct {
+	path   string
+	logger *slog.Logger
+
+	mu            sync.Mutex
+	status        RuntimeStatus
+	healthMonitor *healthcheck.HealthMonitor
+	updateQueue   *updateQueue
+}
+
+func newStatusRecorder(dataDir string, logger *slog.Logger) *statusRecorder {
+	return &statusRecorder{
+		path:   filepath.Join(dataDir, constants.HaloydStatusFileName),
+		logger: logger,
+		status: RuntimeStatus{
+			PID:       os.Getpid(),
+			Version:   constants.Version,
+			StartedAt: time.Now().UTC(),
+		},
+	}
+}
+
+func (r *statusRecorder) setHealthMonitor(m *healthcheck.HealthMonitor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.healthMonitor = m
+}
+
+func (r *statusRecorder) setUpdateQueue(q *updateQueue) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updateQueue = q
+}
+
+func (r *statusRecorder) maintenanceDone() {
+	r.mu.Lock()
+	r.status.LastMaintenanceAt = time.Now().UTC()
+	r.mu.Unlock()
+	r.write()
+}
+
+// write records the current status. It is written to a temporary file and
+// renamed, so readers never see a partial file.
+func (r *statusRecorder) write() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.UpdatedAt = time.Now().UTC()
+	r.status.HealthMonitor = nil
+	if r.healthMonitor != nil {
+		total, healthy, unhealthy := r.healthMonitor.GetStats()
+		summary := &HealthSummary{Targets: total, Healthy: healthy, Unhealthy: unhealthy}
+		for _, target := range r.healthMonitor.GetUnhealthyTargets() {
+			if !slices.Contains(summary.UnhealthyApps, target.AppName) {
+				summary.UnhealthyApps = append(summary.UnhealthyApps, target.AppName)
+			}
+		}
+		slices.Sort(summary.UnhealthyApps)
+		r.status.HealthMonitor = summary
+	}
+	r.status.UpdateQueue = nil
+	if r.updateQueue != nil {
+		waiting, running := r.updateQueue.Depth()
+		r.status.UpdateQueue = &UpdateQueueSummary{Waiting: waiting, Running: running}
+	}
+
+	data, err := json.MarshalIndent(r.status, "", "  ")
+	if err != nil {
+		r.logger.Warn("Failed to encode status file", "error", err)
+		return
+	}
+	tmpPath := r.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, constants.ModeFileDefault); err != nil {
+		r.logger.Warn("Failed to write status file", "error", err)
+		return
+	}
+	if err := os.Rename(tmpPath, r.path); err != nil {
+		os.Remove(tmpPath)
+		r.logger.Warn("Failed to write status file", "error", err)
+	}
+}
@@ -0,0 +1,33 @@
+package haloyd
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStatusRecorder(t *testing.T) {
+	dataDir := t.TempDir()
+	recorder := newStatusRecorder(dataDir, slog.New(slog.DiscardHandler))
+	recorder.write()
+	recorder.maintenanceDone()
+
+	status, err := ReadRuntimeStatus(dataDir)
+	if err != nil {
+		t.Fatalf("ReadRuntimeStatus() error = %v", err)
+	}
+	if status.PID != os.Getpid() || status.StartedAt.IsZero() || status.LastMaintenanceAt.IsZero() {
+		t.Errorf("ReadRuntimeStatus() = %+v, want this process with a maintenance run", status)
+	}
+	if status.HealthMonitor != nil {
+		t.Errorf("HealthMonitor = %+v, want nil without a health monitor", status.HealthMonitor)
+	}
+	if !status.Fresh(time.Now()) || status.Fresh(time.Now().Add(time.Hour)) {
+		t.Error("Fresh() should only hold shortly after the status was written")
+	}
+
+	if _, err := ReadRuntimeStatus(t.TempDir()); !os.IsNotExist(err) {
+		t.Errorf("ReadRuntimeStatus() on an empty data directory error = %v, want not exist", err)
+	}
+}
@@ -0,0 +1,151 @@
+package haloyd
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// updateFunc runs an update; Updater.Update in haloyd.
+type updateFunc func(ctx context.Context, logger *slog.Logger, reason TriggerReason, app *TriggeredByApp) (UpdateResult, error)
+
+// updateQueue runs the updates haloyd triggers one at a time. Updates
+// triggered by an app's containers, approved on-demand TLS domains or a
+// change of cluster peers run before periodic refreshes, so a deployment
+// doesn't wait on a refresh that was requested first. A request joins an
+// update of the same kind that is still waiting: the same app or, without an
+// app, the same reason. Every update discovers all containers, so the joined
+// update sees what the request would have.
+type updateQueue struct {
+	update updateFunc
+
+	mu      sync.Mutex
+	waiting []*queuedUpdate
+	running bool
+	wake    chan struct{}
+}
+
+// queuedUpdate is an update waiting for its turn, and its result once done.
+type queuedUpdate struct {
+	key    string
+	urgent bool
+	reason TriggerReason
+	app    *TriggeredByApp
+	logger *slog.Logger
+
+	done   chan struct{}
+	result UpdateResult
+	err    error
+}
+
+func newUpdateQueue(update updateFunc) *updateQueue {
+	return &updateQueue{
+		update: update,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Update queues an update and waits for its result or for ctx to be done.
+// An update that was started runs to completion even when no one waits for
+// it anymore.
+func (q *updateQueue) Update(ctx context.Context, logger *slog.Logger, reason TriggerReason, app *TriggeredByApp) (UpdateResult, error) {
+	u := q.enqueue(logger, reason, app)
+	select {
+	case <-u.done:
+		return u.result, u.err
+	case <-ctx.Done():
+		return UpdateResult{}, ctx.Err()
+	}
+}
+
+func (q *updateQueue) enqueue(logger *slog.Logger, reason TriggerReason, app *TriggeredByApp) *queuedUpdate {
+	key := "reason:" + reason.String()
+	if app != nil {
+		key = "app:" + app.appName
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, u := range q.waiting {
+		if u.key == key {
+			// The latest deployment of the app is the one to keep, and its
+			// logger the one its deployment is followed on.
+			if app != nil {
+				u.app = app
+				u.logger = logger
+			}
+			return u
+		}
+	}
+
+	u := &queuedUpdate{
+		key:    key,
+		urgent: app != nil || reason != TriggerPeriodicRefresh,
+		reason: reason,
+		app:    app,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+	q.waiting = append(q.waiting, u)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return u
+}
+
+// next removes and returns the first urgent update waiting, or the first
+// refresh when none is urgent.
+func (q *updateQueue) next() *queuedUpdate {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.waiting) == 0 {
+		return nil
+	}
+	i := 0
+	for j, u := range q.waiting {
+		if u.urgent {
+			i = j
+			break
+		}
+	}
+	u := q.waiting[i]
+	q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+	q.running = true
+	return u
+}
+
+func (q *updateQueue) finished() {
+	q.mu.Lock()
+	q.running = false
+	q.mu.Unlock()
+}
+
+// Depth returns how many updates wait for their turn and whether one runs.
+func (q *updateQueue) Depth() (waiting int, running bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiting), q.running
+}
+
+// Run works through the queue until ctx is done. Each update gets
+// updateTimeout from when it starts.
+func (q *updateQueue) Run(ctx context.Context) {
+	for {
+		for u := q.next(); u != nil; u = q.next() {
+			updateCtx, cancel := context.WithTimeout(ctx, updateTimeout)
+			u.result, u.err = q.update(updateCtx, u.logger, u.reason, u.app)
+			cancel()
+			q.finished()
+			close(u.done)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		}
+	}
+}
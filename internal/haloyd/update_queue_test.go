@@ -0,0 +1,89 @@
+package haloyd
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateQueue_PrioritizesAndCoalesces(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var mu sync.Mutex
+	var ran []string
+	var deploymentIDs []string
+
+	q := newUpdateQueue(func(ctx context.Context, logger *slog.Logger, reason TriggerReason, app *TriggeredByApp) (UpdateResult, error) {
+		mu.Lock()
+		first := len(ran) == 0
+		if app != nil {
+			ran = append(ran, app.appName)
+			deploymentIDs = append(deploymentIDs, app.deploymentID)
+		} else {
+			ran = append(ran, reason.String())
+		}
+		mu.Unlock()
+		if first {
+			close(started)
+			<-release
+		}
+		return UpdateResult{}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	logger := slog.New(slog.DiscardHandler)
+	var wg sync.WaitGroup
+	update := func(reason TriggerReason, app *TriggeredByApp) {
+		u := q.enqueue(logger, reason, app)
+		wg.Go(func() { <-u.done })
+	}
+
+	// The first refresh holds the queue while the others line up.
+	update(TriggerPeriodicRefresh, nil)
+	<-started
+	update(TriggerPeriodicRefresh, nil)
+	update(TriggerPeriodicRefresh, nil)
+	update(TriggerReasonAppUpdated, &TriggeredByApp{appName: "web", deploymentID: "1"})
+	update(TriggerReasonAppUpdated, &TriggeredByApp{appName: "web", deploymentID: "2"})
+	update(TriggerReasonClusterPeers, nil)
+
+	if waiting, running := q.Depth(); waiting != 3 || !running {
+		t.Errorf("Depth() = %d, %v, want 3 waiting and one running", waiting, running)
+	}
+	close(release)
+	wg.Wait()
+
+	want := []string{TriggerPeriodicRefresh.String(), "web", TriggerReasonClusterPeers.String(), TriggerPeriodicRefresh.String()}
+	if len(ran) != len(want) {
+		t.Fatalf("ran %v, want %v", ran, want)
+	}
+	for i := range want {
+		if ran[i] != want[i] {
+			t.Fatalf("ran %v, want %v", ran, want)
+		}
+	}
+	if deploymentIDs[0] != "2" {
+		t.Errorf("coalesced app update ran deployment %s, want the latest", deploymentIDs[0])
+	}
+	if waiting, running := q.Depth(); waiting != 0 || running {
+		t.Errorf("Depth() after the queue drained = %d, %v, want it empty", waiting, running)
+	}
+}
+
+func TestUpdateQueue_CallerContext(t *testing.T) {
+	q := newUpdateQueue(func(ctx context.Context, logger *slog.Logger, reason TriggerReason, app *TriggeredByApp) (UpdateResult, error) {
+		return UpdateResult{}, nil
+	})
+
+	// Without a worker the update never runs, so only the caller's context
+	// ends the wait.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Update(ctx, slog.New(slog.DiscardHandler), TriggerPeriodicRefresh, nil); err != context.DeadlineExceeded {
+		t.Errorf("Update() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
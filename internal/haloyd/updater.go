@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/haloydev/haloy/internal/docker"
 	"github.com/haloydev/haloy/internal/helpers"
 	"github.com/haloydev/haloy/internal/proxyclient"
+	"github.com/haloydev/haloy/internal/proxywire"
 )
 
 type Updater struct {
@@ -21,7 +23,8 @@ type Updater struct {
 	deploymentManager *DeploymentManager
 	certManager       *CertificatesManager
 	proxyPusher       ProxyPusher
-	apiDomain         string
+	snapshot          snapshotSettings
+	gate              DeploymentGate
 	// mu serializes Update calls. Concurrent updates would race on the
 	// deployments map: the slower one would overwrite newer state with its
 	// stale discovery snapshot and push a stale proxy config.
@@ -33,7 +36,21 @@ type UpdaterConfig struct {
 	DeploymentManager *DeploymentManager
 	CertManager       *CertificatesManager
 	ProxyPusher       ProxyPusher
-	APIDomain         string
+	Snapshot          snapshotSettings
+	// Gate ties routing to the recorded state of deployments. Optional;
+	// without it every healthy container is routed.
+	Gate DeploymentGate
+}
+
+// DeploymentGate decides from the state deployments are recorded in which
+// of them an update may route. *deploystate.Machine implements it.
+type DeploymentGate interface {
+	// Routable reports whether a deployment that didn't trigger the update
+	// may be routed.
+	Routable(deploymentID string) (bool, error)
+	// Promote records the deployment that triggered the update as live,
+	// before it is routed. An error keeps it from being routed.
+	Promote(deploymentID, appName string) error
 }
 
 func NewUpdater(config UpdaterConfig) *Updater {
@@ -42,10 +59,17 @@ func NewUpdater(config UpdaterConfig) *Updater {
 		deploymentManager: config.DeploymentManager,
 		certManager:       config.CertManager,
 		proxyPusher:       config.ProxyPusher,
-		apiDomain:         config.APIDomain,
+		snapshot:          config.Snapshot,
+		gate:              config.Gate,
 	}
 }
 
+// PlannedSnapshot returns the snapshot an update would push for the current
+// deployment state, without pushing it.
+func (u *Updater) PlannedSnapshot() *proxywire.Snapshot {
+	return buildSnapshot(u.deploymentManager.Deployments(), u.deploymentManager.FailedDeployments(), u.snapshot, nil)
+}
+
 type TriggeredByApp struct {
 	appName           string
 	domains           []config.Domain
@@ -78,9 +102,11 @@ func (tba *TriggeredByApp) Validate() error {
 type TriggerReason int
 
 const (
-	TriggerReasonInitial    TriggerReason = iota // Initial update at startup
-	TriggerReasonAppUpdated                      // An app container was stopped, killed or removed
-	TriggerPeriodicRefresh                       // Periodic refresh (e.g., every 5 minutes)
+	TriggerReasonInitial      TriggerReason = iota // Initial update at startup
+	TriggerReasonAppUpdated                        // An app container was stopped, killed or removed
+	TriggerPeriodicRefresh                         // Periodic refresh (e.g., every 5 minutes)
+	TriggerReasonOnDemandTLS                       // A domain was approved for on-demand TLS
+	TriggerReasonClusterPeers                      // The routes of cluster peers changed
 )
 
 func (r TriggerReason) String() string {
@@ -91,6 +117,10 @@ func (r TriggerReason) String() string {
 		return "app updated"
 	case TriggerPeriodicRefresh:
 		return "periodic refresh"
+	case TriggerReasonOnDemandTLS:
+		return "on-demand TLS domain"
+	case TriggerReasonClusterPeers:
+		return "cluster peers changed"
 	default:
 		return "unknown"
 	}
@@ -123,6 +153,11 @@ func (u *Updater) Update(ctx context.Context, logger *slog.Logger, reason Trigge
 	result.FailedContainers = append(result.FailedContainers, discoveryFailed...)
 	result.FailedContainers = append(result.FailedContainers, healthCheckFailed...)
 
+	// Routing, certificates and removing the containers a deployment
+	// replaces all follow from it going live, so nothing happens for a
+	// deployment that can't.
+	healthy, appRoutable := gateDeployments(logger, u.gate, healthy, app)
+
 	// Log warnings for partial replica failures (some healthy, some failed for same app)
 	logPartialReplicaFailures(healthy, healthCheckFailed, logger)
 
@@ -136,10 +171,13 @@ func (u *Updater) Update(ctx context.Context, logger *slog.Logger, reason Trigge
 	if err != nil {
 		return result, fmt.Errorf("failed to get certificate domains: %w", err)
 	}
+	certDomains = append(certDomains, u.snapshot.OnDemand.certificateDomains()...)
+	certDomains = appendNewCertificateDomains(certDomains, u.snapshot.Edge.certificateDomains())
 
 	// Skip proxy and container work if no changes were detected and the reason is not an initial update.
-	// We'll still want to continue on the initial update to ensure the API domain is set up correctly.
-	if !deploymentsHasChanged && reason != TriggerReasonInitial {
+	// We'll still want to continue on the initial update to ensure the API domain is set up correctly,
+	// and on an on-demand TLS approval or a change of cluster peers to route the new domains.
+	if !deploymentsHasChanged && reason != TriggerReasonInitial && reason != TriggerReasonOnDemandTLS && reason != TriggerReasonClusterPeers {
 		logger.Debug("Updater: No changes detected in deployments, running certificate maintenance only")
 		u.certManager.Refresh(logger, certDomains)
 		if reason == TriggerPeriodicRefresh {
@@ -169,7 +207,7 @@ func (u *Updater) Update(ctx context.Context, logger *slog.Logger, reason Trigge
 	// challenges are forwarded to haloyd regardless of the route table, and a
 	// transient ACME failure should not leave the proxy config stale or the
 	// route table empty on startup.
-	snapshot := buildSnapshot(deployments, u.deploymentManager.FailedDeployments(), u.apiDomain, nil)
+	snapshot := buildSnapshot(deployments, u.deploymentManager.FailedDeployments(), u.snapshot, nil)
 	if err := u.proxyPusher.Push(ctx, snapshot); err != nil {
 		if !errors.Is(err, proxyclient.ErrUnreachable) {
 			return result, fmt.Errorf("failed to push proxy config: %w", err)
@@ -184,7 +222,7 @@ func (u *Updater) Update(ctx context.Context, logger *slog.Logger, reason Trigge
 	// If an app is provided we refresh the certs synchronously so we can log the result.
 	// Otherwise, we refresh them asynchronously to avoid blocking the main update process.
 	// We also refresh the certs for that app only.
-	if app != nil && len(app.domains) > 0 {
+	if appRoutable && len(app.domains) > 0 {
 		appCanonicalDomains := make(map[string]struct{}, len(app.domains))
 		for _, domain := range app.domains {
 			appCanonicalDomains[domain.Canonical] = struct{}{}
@@ -196,12 +234,12 @@ func (u *Updater) Update(ctx context.Context, logger *slog.Logger, reason Trigge
 				appCertDomains = append(appCertDomains, certDomain)
 			}
 		}
-		if err := u.certManager.RefreshSync(logger, appCertDomains); err != nil {
+		if _, err := u.certManager.RefreshSync(logger, appCertDomains); err != nil {
 			return result, fmt.Errorf("failed to refresh certificates for app %s: %w", app.appName, err)
 		}
 	} else if reason == TriggerReasonInitial {
 		// Refresh synchronously on initial update so we can log api domain setup.
-		if err := u.certManager.RefreshSync(logger, certDomains); err != nil {
+		if _, err := u.certManager.RefreshSync(logger, certDomains); err != nil {
 			return result, err
 		}
 	} else {
@@ -212,10 +250,9 @@ func (u *Updater) Update(ctx context.Context, logger *slog.Logger, reason Trigge
 		u.certManager.CleanupExpiredCertificates(logger, certDomains)
 	}
 
-	// If an app is provided:
-	// - stop old containers, remove and log the result.
-	// - log successful deployment for app.
-	if app != nil {
+	// If the app's deployment went live, stop and remove the containers it
+	// replaced.
+	if appRoutable {
 		stopCtx, cancelStop := context.WithTimeout(ctx, 10*time.Minute)
 		defer cancelStop()
 		_, err := docker.StopContainers(stopCtx, u.cli, logger, app.appName, app.deploymentID)
@@ -231,6 +268,61 @@ func (u *Updater) Update(ctx context.Context, logger *slog.Logger, reason Trigge
 	return result, nil
 }
 
+// gateDeployments drops the healthy containers of deployments the gate
+// keeps from traffic, and promotes the deployment that triggered the update
+// when it's the newest of its app with healthy containers. It reports
+// whether that deployment is to be routed. Without a gate every container is
+// routed, and the deployment of any triggering app.
+func gateDeployments(logger *slog.Logger, gate DeploymentGate, healthy []HealthyContainer, app *TriggeredByApp) ([]HealthyContainer, bool) {
+	if gate == nil {
+		return healthy, app != nil
+	}
+
+	triggered := func(c HealthyContainer) bool {
+		return app != nil && c.Labels.AppName == app.appName && c.Labels.DeploymentID == app.deploymentID
+	}
+	routable := make(map[string]bool)
+	var admitted []HealthyContainer
+	for _, c := range healthy {
+		id := c.Labels.DeploymentID
+		if !triggered(c) {
+			ok, checked := routable[id]
+			if !checked {
+				var err error
+				if ok, err = gate.Routable(id); err != nil {
+					// Keep serving what's running rather than dropping routes
+					// over a storage error.
+					logger.Warn("Failed to read deployment state, routing it", "deployment_id", id, "error", err)
+					ok = true
+				}
+				routable[id] = ok
+			}
+			if !ok {
+				continue
+			}
+		}
+		admitted = append(admitted, c)
+	}
+	if app == nil {
+		return admitted, false
+	}
+
+	newest := ""
+	for _, c := range admitted {
+		if c.Labels.AppName == app.appName && (newest == "" || helpers.CompareDeploymentIDs(c.Labels.DeploymentID, newest) > 0) {
+			newest = c.Labels.DeploymentID
+		}
+	}
+	if newest != app.deploymentID {
+		return admitted, false
+	}
+	if err := gate.Promote(app.deploymentID, app.appName); err != nil {
+		logger.Warn("Not routing deployment", "app", app.appName, "deployment_id", app.deploymentID, "error", err)
+		return slices.DeleteFunc(admitted, triggered), false
+	}
+	return admitted, true
+}
+
 // logFailedContainers logs warnings about containers that failed during a specific phase.
 // The final deployment success/failure is logged by the caller (haloyd.go).
 func logFailedContainers(failed []FailedContainer, logger *slog.Logger, phase string) {
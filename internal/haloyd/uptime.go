@@ -0,0 +1,235 @@
+package haloyd
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"github.com/haloydev/haloy/internal/api"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/healthcheck"
+	"github.com/haloydev/haloy/internal/proxywire"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+const (
+	uptimeInterval  = time.Minute
+	uptimeRetention = 90 * 24 * time.Hour
+	// uptimeAlertWindow is the time the burn rate that triggers alerts is
+	// measured over.
+	uptimeAlertWindow = time.Hour
+	// uptimeMinAlertRequests is how many requests an app needs within the
+	// alert window before failed ones count towards its burn rate, so a
+	// handful of requests can't trigger an alert.
+	uptimeMinAlertRequests = 100
+	uptimeNotifyTimeout    = 10 * time.Second
+)
+
+// uptimeHealth is the part of the health monitor the uptime recorder reads.
+type uptimeHealth interface {
+	GetHealthyTargets() []healthcheck.Target
+	GetUnhealthyTargets() []healthcheck.Target
+}
+
+type backendCount struct {
+	requests uint64
+	errors   uint64
+}
+
+type uptimeMinute struct {
+	at     time.Time
+	uptime storage.AppUptime
+}
+
+// uptimeRecorder records every minute which apps had no healthy container,
+// from the health monitor, and how many requests the proxy sent them and
+// failed, from its backend counters. It alerts when an app uses up its error
+// budget faster than the configured burn rate over the last hour.
+type uptimeRecorder struct {
+	db          *storage.DB
+	health      uptimeHealth // nil when the health monitor is disabled
+	proxyStatus func(context.Context) (*proxywire.Status, error)
+	config      config.UptimeConfig
+	logger      *slog.Logger
+	notify      func(ctx context.Context, url string, body []byte) error
+
+	// counts are the proxy's counters at the last sample, by backend address.
+	counts   map[string]backendCount
+	recent   map[string][]uptimeMinute
+	alerting map[string]bool
+}
+
+func newUptimeRecorder(db *storage.DB, health uptimeHealth, proxyStatus func(context.Context) (*proxywire.Status, error), cfg config.UptimeConfig, logger *slog.Logger) *uptimeRecorder {
+	return &uptimeRecorder{
+		db:          db,
+		health:      health,
+		proxyStatus: proxyStatus,
+		config:      cfg,
+		logger:      logger,
+		notify:      postJSONNotification,
+		counts:      make(map[string]backendCount),
+		recent:      make(map[string][]uptimeMinute),
+		alerting:    make(map[string]bool),
+	}
+}
+
+// Run records uptime every minute until ctx is done.
+func (r *uptimeRecorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(uptimeInterval)
+	defer ticker.Stop()
+	pruneTicker := time.NewTicker(24 * time.Hour)
+	defer pruneTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.sample(ctx, time.Now())
+		case <-pruneTicker.C:
+			if err := r.db.PruneAppUptime(time.Now().Add(-uptimeRetention)); err != nil {
+				r.logger.Warn("Failed to prune uptime history", "error", err)
+			}
+		}
+	}
+}
+
+func (r *uptimeRecorder) sample(ctx context.Context, now time.Time) {
+	minutes := make(map[string]*storage.AppUptime)
+	appMinute := func(appName string) *storage.AppUptime {
+		if minutes[appName] == nil {
+			minutes[appName] = &storage.AppUptime{AppName: appName, Day: now}
+		}
+		return minutes[appName]
+	}
+
+	addrApps := make(map[string]string)
+	if r.health != nil {
+		healthy := r.health.GetHealthyTargets()
+		unhealthy := r.health.GetUnhealthyTargets()
+		for _, t := range unhealthy {
+			addrApps[net.JoinHostPort(t.IP, t.Port)] = t.AppName
+			appMinute(t.AppName).DownMinutes = 1
+		}
+		for _, t := range healthy {
+			addrApps[net.JoinHostPort(t.IP, t.Port)] = t.AppName
+			appMinute(t.AppName).DownMinutes = 0
+		}
+		for _, m := range minutes {
+			m.Minutes = 1
+		}
+	}
+
+	statusCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	status, err := r.proxyStatus(statusCtx)
+	cancel()
+	if err != nil {
+		r.logger.Debug("Skipping request counts in uptime", "error", err)
+	} else {
+		r.countRequests(status.Backends, addrApps, appMinute)
+	}
+
+	for appName, m := range minutes {
+		if err := r.db.AddAppUptime(*m); err != nil {
+			r.logger.Warn("Failed to record uptime", "app", appName, "error", err)
+		}
+		r.recent[appName] = append(r.recent[appName], uptimeMinute{at: now, uptime: *m})
+	}
+	for appName, recent := range r.recent {
+		for len(recent) > 0 && now.Sub(recent[0].at) >= uptimeAlertWindow {
+			recent = recent[1:]
+		}
+		if len(recent) == 0 {
+			delete(r.recent, appName)
+			delete(r.alerting, appName)
+			continue
+		}
+		r.recent[appName] = recent
+		r.checkBurnRate(ctx, appName, now)
+	}
+}
+
+// countRequests adds the requests each backend served since the last sample
+// to its app. Counters start over when the proxy restarts.
+func (r *uptimeRecorder) countRequests(backends []proxywire.BackendStatus, addrApps map[string]string, appMinute func(string) *storage.AppUptime) {
+	seen := make(map[string]bool, len(backends))
+	for _, b := range backends {
+		seen[b.Address] = true
+		current := backendCount{requests: b.Requests, errors: b.Errors}
+		last, ok := r.counts[b.Address]
+		r.counts[b.Address] = current
+		appName := addrApps[b.Address]
+		if !ok || appName == "" {
+			continue
+		}
+		if current.requests < last.requests || current.errors < last.errors {
+			last = backendCount{}
+		}
+		m := appMinute(appName)
+		m.Requests += int64(current.requests - last.requests)
+		m.Errors += int64(current.errors - last.errors)
+	}
+	for addr := range r.counts {
+		if !seen[addr] {
+			delete(r.counts, addr)
+		}
+	}
+}
+
+// checkBurnRate alerts when the burn rate of an app over the last hour
+// reaches the alert's, and again once it drops below it.
+func (r *uptimeRecorder) checkBurnRate(ctx context.Context, appName string, now time.Time) {
+	var total storage.AppUptime
+	for _, m := range r.recent[appName] {
+		total = api.AddUptime(total, m.uptime)
+	}
+	if total.Requests < uptimeMinAlertRequests {
+		total.Requests, total.Errors = 0, 0
+	}
+
+	objective, threshold := r.config.GetObjective(), r.config.Alert.GetBurnRate()
+	burnRate := api.UptimeBurnRate(total, objective)
+	burning := burnRate >= threshold
+	if burning == r.alerting[appName] {
+		return
+	}
+	r.alerting[appName] = burning
+
+	event := "error_budget_burn"
+	if burning {
+		r.logger.Warn("App is burning its error budget", "app", appName, "burn_rate", burnRate, "threshold", threshold)
+	} else {
+		event = "error_budget_burn_resolved"
+		r.logger.Info("App is no longer burning its error budget", "app", appName, "burn_rate", burnRate)
+	}
+	if r.config.Alert.NotifyURL == "" {
+		return
+	}
+
+	alert := apitypes.UptimeAlert{
+		Event:       event,
+		App:         appName,
+		Time:        now.UTC(),
+		Objective:   objective,
+		BurnRate:    burnRate,
+		Threshold:   threshold,
+		Minutes:     total.Minutes,
+		DownMinutes: total.DownMinutes,
+		Requests:    total.Requests,
+		Errors:      total.Errors,
+	}
+	alert.Server, _ = os.Hostname()
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return
+	}
+	notifyCtx, cancel := context.WithTimeout(ctx, uptimeNotifyTimeout)
+	defer cancel()
+	if err := r.notify(notifyCtx, r.config.Alert.NotifyURL, body); err != nil {
+		r.logger.Warn("Failed to send uptime alert", "app", appName, "error", err)
+	}
+}
@@ -0,0 +1,78 @@
+package haloyd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/healthcheck"
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+type fakeUptimeHealth struct {
+	healthy, unhealthy []healthcheck.Target
+}
+
+func (f *fakeUptimeHealth) GetHealthyTargets() []healthcheck.Target   { return f.healthy }
+func (f *fakeUptimeHealth) GetUnhealthyTargets() []healthcheck.Target { return f.unhealthy }
+
+func TestUptimeRecorder(t *testing.T) {
+	db := newTestCertificatesDB(t)
+	shop := healthcheck.Target{ID: "c1", AppName: "shop", IP: "10.0.0.2", Port: "8080"}
+	health := &fakeUptimeHealth{healthy: []healthcheck.Target{shop}}
+	backend := proxywire.BackendStatus{Address: "10.0.0.2:8080", Requests: 1000, Errors: 5}
+
+	var alerts []apitypes.UptimeAlert
+	r := newUptimeRecorder(db, health, func(context.Context) (*proxywire.Status, error) {
+		return &proxywire.Status{Backends: []proxywire.BackendStatus{backend}}, nil
+	}, config.UptimeConfig{Alert: config.UptimeAlertConfig{NotifyURL: "https://hooks.example.com"}}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r.notify = func(_ context.Context, _ string, body []byte) error {
+		var alert apitypes.UptimeAlert
+		if err := json.Unmarshal(body, &alert); err != nil {
+			t.Errorf("alert body %s: %v", body, err)
+		}
+		alerts = append(alerts, alert)
+		return nil
+	}
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	// The first sample only sets the baseline of the proxy's counters.
+	r.sample(context.Background(), now)
+	backend.Requests, backend.Errors = 1200, 5
+	r.sample(context.Background(), now.Add(time.Minute))
+	if len(alerts) != 0 {
+		t.Fatalf("alerts = %+v, want none while healthy", alerts)
+	}
+
+	// Down for a minute with every request failing burns a 99.9% budget
+	// far faster than 14.4 times.
+	health.healthy, health.unhealthy = nil, []healthcheck.Target{shop}
+	backend.Requests, backend.Errors = 1300, 105
+	r.sample(context.Background(), now.Add(2*time.Minute))
+	if len(alerts) != 1 || alerts[0].Event != "error_budget_burn" || alerts[0].App != "shop" {
+		t.Fatalf("alerts = %+v, want a burn alert for shop", alerts)
+	}
+
+	uptime, err := db.ListAppUptime("shop", now)
+	if err != nil {
+		t.Fatalf("ListAppUptime() error = %v", err)
+	}
+	if len(uptime) != 1 {
+		t.Fatalf("ListAppUptime() = %+v, want one day", uptime)
+	}
+	if u := uptime[0]; u.Minutes != 3 || u.DownMinutes != 1 || u.Requests != 300 || u.Errors != 100 {
+		t.Errorf("uptime = %+v, want 3 minutes, 1 down, 300 requests and 100 errors", u)
+	}
+
+	// An hour later the down minute is out of the alert window.
+	health.healthy, health.unhealthy = []healthcheck.Target{shop}, nil
+	r.sample(context.Background(), now.Add(time.Hour+3*time.Minute))
+	if len(alerts) != 2 || alerts[1].Event != "error_budget_burn_resolved" {
+		t.Errorf("alerts = %+v, want the burn resolved", alerts)
+	}
+}
@@ -0,0 +1,124 @@
+package haloyd
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+const (
+	// usageRawRetention is how long usage is kept at the sampling interval
+	// before it's downsampled to usageDownsamplePeriod.
+	usageRawRetention     = 24 * time.Hour
+	usageDownsamplePeriod = time.Hour
+	usageSampleTimeout    = 30 * time.Second
+)
+
+// usageRecorder samples the CPU and memory usage of every app's running
+// containers for haloy top --history.
+type usageRecorder struct {
+	db        *storage.DB
+	cli       *client.Client
+	interval  time.Duration
+	retention time.Duration
+	logger    *slog.Logger
+}
+
+func newUsageRecorder(db *storage.DB, cli *client.Client, cfg config.UsageHistoryConfig, logger *slog.Logger) *usageRecorder {
+	return &usageRecorder{
+		db:        db,
+		cli:       cli,
+		interval:  cfg.GetInterval(),
+		retention: cfg.GetRetention(),
+		logger:    logger,
+	}
+}
+
+// Run samples usage every interval and compacts the history every hour
+// until ctx is done.
+func (r *usageRecorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	compactTicker := time.NewTicker(usageDownsamplePeriod)
+	defer compactTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sampleCtx, cancel := context.WithTimeout(ctx, usageSampleTimeout)
+			r.sample(sampleCtx, time.Now())
+			cancel()
+		case <-compactTicker.C:
+			r.compact(time.Now())
+		}
+	}
+}
+
+func (r *usageRecorder) sample(ctx context.Context, now time.Time) {
+	containers, err := docker.GetAppContainers(ctx, r.cli, false, "")
+	if err != nil {
+		r.logger.Warn("Skipping usage sample", "error", err)
+		return
+	}
+
+	var mu sync.Mutex
+	statsByApp := make(map[string][]container.StatsResponse)
+	var wg sync.WaitGroup
+	for _, c := range containers {
+		appName := c.Labels[config.LabelAppName]
+		if appName == "" {
+			continue
+		}
+		wg.Go(func() {
+			stats, err := docker.ContainerStats(ctx, r.cli, c.ID)
+			if err != nil {
+				r.logger.Debug("Failed to sample container usage", "app", appName, "container", c.ID, "error", err)
+				return
+			}
+			mu.Lock()
+			statsByApp[appName] = append(statsByApp[appName], stats)
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	at := now.UTC().Truncate(time.Second)
+	for appName, stats := range statsByApp {
+		usage := appUsageFromStats(appName, stats, at, r.interval)
+		if err := r.db.RecordAppUsage(usage); err != nil {
+			r.logger.Warn("Failed to record usage", "app", appName, "error", err)
+		}
+	}
+}
+
+// appUsageFromStats sums a sample of the containers of an app.
+func appUsageFromStats(appName string, stats []container.StatsResponse, at time.Time, interval time.Duration) storage.AppUsage {
+	usage := storage.AppUsage{AppName: appName, Time: at, Period: interval, Samples: 1, Containers: len(stats)}
+	for _, s := range stats {
+		usage.CPUPercent += docker.CPUPercent(s)
+		usage.MemoryBytes += int64(docker.MemoryUsage(s))
+		usage.MemoryLimit += int64(s.MemoryStats.Limit)
+	}
+	usage.CPUMax, usage.MemoryMax = usage.CPUPercent, usage.MemoryBytes
+	return usage
+}
+
+// compact downsamples usage older than usageRawRetention and removes usage
+// past the retention.
+func (r *usageRecorder) compact(now time.Time) {
+	if _, err := r.db.DownsampleAppUsage(now.Add(-usageRawRetention), usageDownsamplePeriod); err != nil {
+		r.logger.Warn("Failed to downsample usage history", "error", err)
+	}
+	if _, err := r.db.PruneAppUsage(now.Add(-r.retention)); err != nil {
+		r.logger.Warn("Failed to prune usage history", "error", err)
+	}
+}
@@ -0,0 +1,31 @@
+package haloyd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestAppUsageFromStats(t *testing.T) {
+	sample := func(cpu, memory uint64) container.StatsResponse {
+		var s container.StatsResponse
+		s.CPUStats.CPUUsage.TotalUsage, s.PreCPUStats.CPUUsage.TotalUsage = cpu, 0
+		s.CPUStats.SystemUsage, s.CPUStats.OnlineCPUs = 100, 1
+		s.MemoryStats.Usage, s.MemoryStats.Limit = memory, 1000
+		return s
+	}
+
+	at := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	usage := appUsageFromStats("shop", []container.StatsResponse{sample(10, 100), sample(30, 200)}, at, time.Minute)
+
+	if usage.CPUPercent != 40 || usage.CPUMax != 40 {
+		t.Errorf("CPU = %v, max %v, want 40 for both", usage.CPUPercent, usage.CPUMax)
+	}
+	if usage.MemoryBytes != 300 || usage.MemoryMax != 300 || usage.MemoryLimit != 2000 {
+		t.Errorf("memory = %d, max %d, limit %d, want 300, 300 and 2000", usage.MemoryBytes, usage.MemoryMax, usage.MemoryLimit)
+	}
+	if usage.Containers != 2 || usage.Samples != 1 || usage.Period != time.Minute || !usage.Time.Equal(at) {
+		t.Errorf("usage = %+v, want one sample of 2 containers", usage)
+	}
+}
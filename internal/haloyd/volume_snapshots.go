@@ -0,0 +1,102 @@
+package haloyd
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/cron"
+	"github.com/haloydev/haloy/internal/volumesnapshots"
+)
+
+// volumeSnapshotTimeout bounds one scheduled snapshot of one app.
+const volumeSnapshotTimeout = time.Hour
+
+// runVolumeSnapshots snapshots the volumes of apps whose snapshot schedule
+// fires, checking at the start of every minute until ctx is done. An app's
+// snapshot is skipped while its previous one still runs.
+func runVolumeSnapshots(ctx context.Context, cli *client.Client, store *volumesnapshots.Store, logger *slog.Logger) {
+	var mu sync.Mutex
+	running := make(map[string]bool)
+
+	for {
+		now := time.Now()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(now.Truncate(time.Minute).Add(time.Minute).Sub(now)):
+		}
+		due, err := dueSnapshots(ctx, cli, time.Now(), logger)
+		if err != nil {
+			logger.Warn("Skipping volume snapshots", "error", err)
+			continue
+		}
+		for appName, job := range due {
+			mu.Lock()
+			if running[appName] {
+				mu.Unlock()
+				logger.Warn("Skipping volume snapshot, the previous one is still running", "app", appName)
+				continue
+			}
+			running[appName] = true
+			mu.Unlock()
+
+			go func() {
+				defer func() {
+					mu.Lock()
+					delete(running, appName)
+					mu.Unlock()
+				}()
+				snapshotCtx, cancel := context.WithTimeout(ctx, volumeSnapshotTimeout)
+				defer cancel()
+				snapshot, err := store.Create(snapshotCtx, cli, appName, job.containerID, job.config)
+				if err != nil {
+					logger.Error("Volume snapshot failed", "app", appName, "error", err)
+					return
+				}
+				logger.Info("Volumes snapshotted", "app", appName, "snapshot", snapshot.Name, "bytes", snapshot.Size, "uploaded", snapshot.Remote)
+			}()
+		}
+	}
+}
+
+type snapshotJob struct {
+	containerID string
+	config      config.SnapshotsConfig
+}
+
+// dueSnapshots returns the apps whose snapshot schedule fires at now, with a
+// running container of each. Replicas share their named volumes, so one is
+// enough.
+func dueSnapshots(ctx context.Context, cli *client.Client, now time.Time, logger *slog.Logger) (map[string]snapshotJob, error) {
+	containerList, err := cli.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", config.LabelSnapshotSchedule)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	due := make(map[string]snapshotJob)
+	for _, c := range containerList {
+		labels, err := config.ParseContainerLabels(c.Labels)
+		if err != nil || labels.Snapshots == nil {
+			continue
+		}
+		if _, exists := due[labels.AppName]; exists {
+			continue
+		}
+		schedule, err := cron.Parse(labels.Snapshots.Schedule)
+		if err != nil {
+			logger.Warn("Invalid snapshot schedule", "app", labels.AppName, "error", err)
+			continue
+		}
+		if schedule.Matches(now) {
+			due[labels.AppName] = snapshotJob{containerID: c.ID, config: *labels.Snapshots}
+		}
+	}
+	return due, nil
+}
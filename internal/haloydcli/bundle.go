@@ -0,0 +1,123 @@
+package haloydcli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/bundle"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/logging"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func bundleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Deploy offline bundles created with 'haloy bundle create'",
+	}
+
+	cmd.AddCommand(bundleImportCmd())
+
+	return cmd
+}
+
+func bundleImportCmd() *cobra.Command {
+	var noLogs bool
+	var forceUnlock bool
+
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Load a bundle's images and deploy it",
+		Long: `Load the images in a bundle into Docker and deploy the bundled target
+through the running haloyd, exactly like a deploy from the haloy CLI. No
+registry or internet access is needed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return importBundle(cmd.Context(), args[0], noLogs, forceUnlock)
+		},
+	}
+
+	cmd.Flags().BoolVar(&noLogs, "no-logs", false, "Don't stream deployment logs")
+	cmd.Flags().BoolVar(&forceUnlock, "force-unlock", false, "Cancel any deployment of the app already in progress and take over its lock")
+
+	return cmd
+}
+
+func importBundle(ctx context.Context, path string, noLogs, forceUnlock bool) error {
+	api, version, err := localAPI(ctx, 30*time.Second)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+
+	var request apitypes.DeployRequest
+	err = bundle.Read(f, func(m bundle.Manifest, req apitypes.DeployRequest, images io.Reader) error {
+		ui.Info("Bundle for %s (target %s), created %s with haloy %s",
+			m.App, m.Target, helpers.FormatTime(m.CreatedAt), m.HaloyVersion)
+		if m.HaloyVersion != version.Version {
+			ui.Warn("Bundle was created with haloy %s, haloyd is %s", m.HaloyVersion, version.Version)
+		}
+
+		cli, err := docker.NewClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+
+		ui.Info("Loading %d image(s) (%s)", len(m.Images), helpers.FormatBinaryBytes(uint64(max(m.ImagesSize, 0))))
+		if err := docker.LoadImage(ctx, cli, images); err != nil {
+			return err
+		}
+		request = req
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	request.DeploymentID = helpers.NewDeploymentID()
+	request.Holder = "haloyd bundle import"
+	if host, err := os.Hostname(); err == nil && host != "" {
+		request.Holder += "@" + host
+	}
+	request.ForceUnlock = forceUnlock
+
+	if err := api.PostIdempotent(ctx, "deploy", request.DeploymentID, request, nil); err != nil {
+		return fmt.Errorf("failed to start deployment: %w", err)
+	}
+	ui.Info("Deployment %s started for %s", request.DeploymentID, request.TargetConfig.Name)
+	if noLogs {
+		return nil
+	}
+
+	var failed bool
+	streamErr := api.StreamResumable(ctx, fmt.Sprintf("deploy/%s/logs", request.DeploymentID), func(data string) bool {
+		var logEntry logging.LogEntry
+		if err := json.Unmarshal([]byte(data), &logEntry); err != nil {
+			ui.Warn("failed to unmarshal json: %v", err)
+			return false
+		}
+		ui.DisplayLogEntry(logEntry, "")
+		failed = logEntry.IsDeploymentFailed
+		return logEntry.IsDeploymentComplete || logEntry.IsDeploymentFailed
+	})
+	if streamErr != nil {
+		return fmt.Errorf("deployment log stream error: %w", streamErr)
+	}
+	if failed {
+		return fmt.Errorf("deployment %s failed", request.DeploymentID)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package haloydcli
+
+import (
+	"fmt"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/layerstore"
+	"github.com/haloydev/haloy/internal/storage"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func cacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and prune the image layer cache",
+		Long: `Commands to inspect and prune the image layer cache.
+
+The cache holds layers from layered uploads and, when image_cache.enabled is
+set in haloyd.yaml, registry images pulled through the pull-through cache.`,
+	}
+
+	cmd.AddCommand(
+		cacheStatsCmd(),
+		cachePruneCmd(),
+	)
+
+	return cmd
+}
+
+func cacheStatsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Show image cache usage",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, db, haloydConfig, err := openLayerStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			stats, err := store.Stats()
+			if err != nil {
+				return fmt.Errorf("failed to read cache stats: %w", err)
+			}
+
+			enabled := "disabled"
+			if haloydConfig.ImageCache.IsEnabled() {
+				enabled = "enabled"
+			}
+			ui.Section("Image cache", []string{
+				fmt.Sprintf("Pull-through cache: %s", enabled),
+				fmt.Sprintf("Max size: %s", helpers.FormatBinaryBytes(haloydConfig.ImageCache.GetMaxSizeBytes())),
+				fmt.Sprintf("Layers: %d (%s)", stats.Layers, helpers.FormatBinaryBytes(uint64(stats.LayerBytes))),
+				fmt.Sprintf("Cached images: %d", len(stats.Images)),
+			})
+
+			if len(stats.Images) > 0 {
+				rows := make([][]string, 0, len(stats.Images))
+				for _, img := range stats.Images {
+					rows = append(rows, []string{
+						img.ImageRef,
+						helpers.FormatBinaryBytes(uint64(img.Size)),
+						img.LastUsedAt.Local().Format("2006-01-02 15:04"),
+					})
+				}
+				ui.Table([]string{"IMAGE", "SIZE", "LAST USED"}, rows)
+			}
+			return nil
+		},
+	}
+}
+
+func cachePruneCmd() *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Evict least recently used layers beyond the cache size limit",
+		Long: `Evict least recently used layers until the cache fits within image_cache.max_size.
+
+With --all, every cached image is forgotten and all layers not used in the
+last hour are removed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, db, haloydConfig, err := openLayerStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			maxBytes := haloydConfig.ImageCache.GetMaxSizeBytes()
+			if all {
+				if _, err := store.ClearImageCache(); err != nil {
+					return fmt.Errorf("failed to clear image cache: %w", err)
+				}
+				maxBytes = 0
+			}
+
+			pruned, freed, err := store.PruneCache(maxBytes)
+			if err != nil {
+				return fmt.Errorf("failed to prune cache: %w", err)
+			}
+			ui.Success("Removed %d layers, freed %s", pruned, helpers.FormatBinaryBytes(uint64(freed)))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Remove all cached images and layers")
+	return cmd
+}
+
+func openLayerStore() (*layerstore.LayerStore, *storage.DB, *config.HaloydConfig, error) {
+	haloydConfig, err := config.LoadDefaultHaloydConfig()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load haloyd config: %w", err)
+	}
+
+	db, err := storage.New()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Migrate(); err != nil {
+		db.Close()
+		return nil, nil, nil, fmt.Errorf("failed to run database migrations: %w", err)
+	}
+
+	store, err := layerstore.New(db)
+	if err != nil {
+		db.Close()
+		return nil, nil, nil, err
+	}
+	return store, db, haloydConfig, nil
+}
@@ -49,8 +49,10 @@ func configGetCmd() *cobra.Command {
 		Long: `Get a configuration value.
 
 Available keys:
-  api-token   - The API authentication token
-  api-domain  - The configured API domain`,
+  api-token     - The API authentication token
+  api-domain    - The configured API domain
+  dns-check     - The DNS check before requesting certificates (off, warn or strict)
+  dns-resolver  - The DNS server used for that check`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			key := args[0]
@@ -90,6 +92,32 @@ Available keys:
 					}
 				}
 
+			case "dns-check":
+				haloydConfig, err := loadHaloydConfig(configDir)
+				if err != nil {
+					return err
+				}
+				mode := haloydConfig.Certificates.GetDNSCheck()
+				if raw {
+					fmt.Print(mode)
+				} else {
+					ui.Info("DNS check: %s", mode)
+				}
+
+			case "dns-resolver":
+				haloydConfig, err := loadHaloydConfig(configDir)
+				if err != nil {
+					return err
+				}
+				resolver := haloydConfig.Certificates.Resolver
+				if raw {
+					fmt.Print(resolver)
+				} else if resolver == "" {
+					ui.Info("DNS resolver is not configured, the system resolver and public DNS-over-HTTPS are used")
+				} else {
+					ui.Info("DNS resolver: %s", resolver)
+				}
+
 			default:
 				return fmt.Errorf("unknown config key: %s", key)
 			}
@@ -110,7 +138,12 @@ func configSetCmd() *cobra.Command {
 		Long: `Set a configuration value.
 
 Available keys:
-  api-domain  - The domain for the haloyd API
+  api-domain    - The domain for the haloyd API
+  dns-check     - off, warn or strict: how haloyd checks a domain's DNS before
+                  requesting a certificate. Use off for split-horizon DNS or
+                  servers behind NAT, to leave validation to Let's Encrypt.
+  dns-resolver  - DNS server for that check, e.g. 1.1.1.1 ("" for the system
+                  resolver)
 
 Note: After changing configuration, restart haloyd for changes to take effect.`,
 		Args: cobra.ExactArgs(2),
@@ -142,6 +175,24 @@ Note: After changing configuration, restart haloyd for changes to take effect.`,
 				ui.Info("API domain set to: %s", value)
 				postSaveErr = cleanupOldAPIDomainCertificate(oldDomain, value)
 
+			case "dns-check":
+				haloydConfig.Certificates.DNSCheck = config.DNSCheckMode(strings.ToLower(value))
+				if err := saveHaloydConfig(configDir, haloydConfig); err != nil {
+					return err
+				}
+				ui.Info("DNS check set to: %s", haloydConfig.Certificates.GetDNSCheck())
+
+			case "dns-resolver":
+				haloydConfig.Certificates.Resolver = value
+				if err := saveHaloydConfig(configDir, haloydConfig); err != nil {
+					return err
+				}
+				if value == "" {
+					ui.Info("DNS resolver cleared")
+				} else {
+					ui.Info("DNS resolver set to: %s", value)
+				}
+
 			default:
 				return fmt.Errorf("unknown config key: %s", key)
 			}
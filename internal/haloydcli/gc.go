@@ -0,0 +1,129 @@
+package haloydcli
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/haloyd"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/logging"
+	"github.com/haloydev/haloy/internal/storage"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func gcCmd() *cobra.Command {
+	var dryRun bool
+	var olderThan time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove containers, images, volumes and certificates of apps that are gone",
+		Long: `Find resources left behind by apps haloyd has no deployments of: stopped
+containers, images tagged for a deployment, and volumes labeled for the app. Certificates
+for domains no container routes are collected too. Running containers, and images and
+volumes they use, are never touched.
+
+Orphans younger than --older-than are reported but kept. Use --dry-run to only report.
+Set gc.enabled in haloyd.yaml to collect orphans during haloyd's periodic maintenance.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			haloydConfig, err := config.LoadDefaultHaloydConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load haloyd config: %w", err)
+			}
+			if !cmd.Flags().Changed("older-than") {
+				olderThan = haloydConfig.GC.GetOlderThan()
+			}
+
+			dataDir, err := config.DataDir()
+			if err != nil {
+				return err
+			}
+			keepDomains, err := haloyd.GCKeepDomains(dataDir, haloydConfig)
+			if err != nil {
+				return err
+			}
+
+			db, err := storage.New()
+			if err != nil {
+				return fmt.Errorf("failed to open database: %w", err)
+			}
+			defer db.Close()
+			if err := db.Migrate(); err != nil {
+				return fmt.Errorf("failed to run database migrations: %w", err)
+			}
+
+			cli, err := docker.NewClient(ctx)
+			if err != nil {
+				return err
+			}
+			defer cli.Close()
+
+			// Failures show up in the table, so only errors are logged.
+			logger := logging.NewLogger(slog.LevelError, nil)
+			orphans, gcErr := haloyd.CollectOrphans(ctx, cli, db, logger, haloyd.GCOptions{
+				OlderThan:   olderThan,
+				DryRun:      dryRun,
+				CertDir:     filepath.Join(dataDir, constants.CertStorageDir),
+				KeepDomains: keepDomains,
+			})
+			if orphans == nil && gcErr != nil {
+				return gcErr
+			}
+
+			if len(orphans) == 0 {
+				ui.Success("No orphaned resources found")
+				return nil
+			}
+
+			rows := make([][]string, 0, len(orphans))
+			collected := 0
+			for _, orphan := range orphans {
+				if orphan.Removed {
+					collected++
+				}
+				rows = append(rows, []string{
+					string(orphan.Kind),
+					orphan.Name,
+					orphan.AppName,
+					helpers.FormatTime(time.Now().Add(-orphan.Age)),
+					orphanStatus(orphan, dryRun),
+				})
+			}
+			ui.Table([]string{"KIND", "NAME", "APP", "CREATED", "STATUS"}, rows)
+
+			if dryRun {
+				ui.Info("Dry run: nothing was removed")
+			} else {
+				ui.Success("Removed %d of %d orphaned resources", collected, len(orphans))
+			}
+			return gcErr
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report orphans, remove nothing")
+	cmd.Flags().DurationVar(&olderThan, "older-than", config.DefaultGCOlderThan, "Only remove orphans older than this (default from gc.older_than)")
+	return cmd
+}
+
+func orphanStatus(orphan haloyd.Orphan, dryRun bool) string {
+	switch {
+	case !orphan.Eligible:
+		return "kept, too recent"
+	case orphan.Removed:
+		return "removed"
+	case orphan.Err != nil:
+		return fmt.Sprintf("failed: %v", orphan.Err)
+	case dryRun:
+		return "would be removed"
+	}
+	return ""
+}
@@ -1,16 +1,24 @@
 package haloydcli
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"io/fs"
+	"maps"
+	"net"
 	"os"
-	"os/exec"
+	"os/user"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/service"
 	"github.com/haloydev/haloy/internal/ui"
 	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
@@ -18,6 +26,7 @@ import (
 
 const (
 	apiTokenLength = 32 // bytes, results in 64 character hex string
+	serviceUser    = "haloy"
 )
 
 func initCmd() *cobra.Command {
@@ -25,6 +34,8 @@ func initCmd() *cobra.Command {
 	var apiDomain string
 	var dataDirFlag string
 	var configDirFlag string
+	var dev bool
+	var installService bool
 
 	cmd := &cobra.Command{
 		Use:   "init",
@@ -38,10 +49,38 @@ This command will:
   - Create the Docker network for containers
 
 Use --data-dir and --config-dir to specify custom directories.
-Environment variables HALOY_DATA_DIR and HALOY_CONFIG_DIR can also be used.`,
+Environment variables HALOY_DATA_DIR and HALOY_CONFIG_DIR can also be used.
+
+With --dev, Haloy is set up for local development on Linux, macOS or Windows
+with Docker Desktop: the directories go in ~/.haloy-dev, owned by the current
+user, haloy-proxy listens on 127.0.0.1:8088 and 127.0.0.1:8443 instead of
+80/443, and, outside Linux, app ports are published on the loopback interface
+because Docker Desktop's container network isn't reachable from the host.
+Run haloy-proxy and haloyd yourself, or add --install-service on macOS to run
+them as launchd agents of the current user.
+
+With --install-service, haloy-proxy and haloyd are installed as services of
+the host's service manager and started: systemd or OpenRC on Linux, launchd on
+macOS. They run as the 'haloy' user when it exists.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 
+			if dev {
+				devDir, err := config.DevDir()
+				if err != nil {
+					return fmt.Errorf("failed to determine dev directory: %w", err)
+				}
+				if dataDirFlag == "" {
+					dataDirFlag = filepath.Join(devDir, "data")
+				}
+				if configDirFlag == "" {
+					configDirFlag = filepath.Join(devDir, "config")
+				}
+				if apiDomain == "" {
+					apiDomain = "localhost"
+				}
+			}
+
 			// Determine directories - flags take priority, then env vars, then defaults
 			dataDir := dataDirFlag
 			if dataDir == "" {
@@ -70,16 +109,18 @@ Environment variables HALOY_DATA_DIR and HALOY_CONFIG_DIR can also be used.`,
 				}
 			}()
 
-			// Check if Docker is installed and available
-			if _, err := exec.LookPath("docker"); err != nil {
-				return fmt.Errorf("docker executable not found: %w\nPlease ensure Docker is installed and in your PATH.\nDownload from: https://www.docker.com/get-started", err)
+			// Talk to the runtime's API directly, so hosts running Podman or
+			// rootless Docker without the docker CLI work too.
+			cli, err := docker.NewClient(ctx)
+			if err != nil {
+				return fmt.Errorf("%w\nPlease ensure Docker or Podman is installed and running.\nDownload from: https://www.docker.com/get-started", err)
 			}
-
-			// Check if Docker daemon is running
-			dockerCheck := exec.CommandContext(ctx, "docker", "info")
-			if err := dockerCheck.Run(); err != nil {
-				return fmt.Errorf("Docker daemon is not running. Please start Docker and try again.")
+			defer cli.Close()
+			containerRuntime, err := docker.DetectRuntime(ctx, cli)
+			if err != nil {
+				return err
 			}
+			ui.Info("Container runtime: %s", containerRuntime)
 
 			if err := validateAndPrepareDirectory(configDir, "Config", override); err != nil {
 				return err
@@ -96,7 +137,11 @@ Environment variables HALOY_DATA_DIR and HALOY_CONFIG_DIR can also be used.`,
 				return fmt.Errorf("failed to generate API token: %w", err)
 			}
 
-			if err := createConfigFiles(apiToken, apiDomain, configDir); err != nil {
+			var extraEnv map[string]string
+			if dev {
+				extraEnv = devEnv(dataDir, runtime.GOOS)
+			}
+			if err := createConfigFiles(apiToken, apiDomain, configDir, extraEnv); err != nil {
 				return fmt.Errorf("failed to create config files: %w", err)
 			}
 
@@ -110,10 +155,10 @@ Environment variables HALOY_DATA_DIR and HALOY_CONFIG_DIR can also be used.`,
 			}
 
 			// Create Docker network
-			if err := ensureDockerNetwork(ctx); err != nil {
+			if err := docker.EnsureNetwork(ctx, cli, constants.DockerNetwork); err != nil {
 				ui.Info("You can manually create it with:")
 				ui.Info("docker network create --driver bridge --attachable %s", constants.DockerNetwork)
-				return fmt.Errorf("failed to create Docker network: %w", err)
+				return err
 			}
 
 			cleanupOnFailure = false
@@ -125,6 +170,20 @@ Environment variables HALOY_DATA_DIR and HALOY_CONFIG_DIR can also be used.`,
 				ui.Info("API domain: %s", apiDomain)
 			}
 
+			var serviceManager string
+			if installService {
+				serviceManager, err = installServices(ctx, dataDir, configDir, dev)
+				if err != nil {
+					return fmt.Errorf("failed to install services: %w", err)
+				}
+				ui.Success("Installed and started %s services (haloy-proxy, haloyd)", serviceManager)
+			}
+
+			if dev {
+				printDevInstructions(configDir, apiToken, serviceManager != "")
+				return nil
+			}
+
 			ui.Info("\nAPI Token: %s", apiToken)
 			ui.Info("\nAdd this server to the haloy CLI with:")
 			apiDomainMsg := "<server-url>"
@@ -141,6 +200,8 @@ Environment variables HALOY_DATA_DIR and HALOY_CONFIG_DIR can also be used.`,
 	cmd.Flags().StringVar(&apiDomain, "api-domain", "", "Domain for the haloyd API (e.g., api.yourserver.com)")
 	cmd.Flags().StringVar(&dataDirFlag, "data-dir", "", "Data directory path (default: /var/lib/haloy)")
 	cmd.Flags().StringVar(&configDirFlag, "config-dir", "", "Config directory path (default: /etc/haloy)")
+	cmd.Flags().BoolVar(&dev, "dev", false, "Set up a local development install on high ports, without system services")
+	cmd.Flags().BoolVar(&installService, "install-service", false, "Install and start haloy-proxy and haloyd as services (systemd, OpenRC or launchd)")
 
 	return cmd
 }
@@ -153,7 +214,131 @@ func generateAPIToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
-func createConfigFiles(apiToken, domain, configDir string) error {
+// devEnv returns the settings a local development install keeps in its env
+// file, which haloyd and haloy-proxy load from the config directory.
+func devEnv(dataDir, goos string) map[string]string {
+	env := map[string]string{
+		constants.EnvVarDataDir:        dataDir,
+		constants.EnvVarProxyHTTPAddr:  net.JoinHostPort("127.0.0.1", constants.DevProxyHTTPPort),
+		constants.EnvVarProxyHTTPSAddr: net.JoinHostPort("127.0.0.1", constants.DevProxyHTTPSPort),
+	}
+	// Only on Linux do containers run on the host's network stack.
+	if goos != "linux" {
+		env[constants.EnvVarPublishPorts] = "true"
+	}
+	return env
+}
+
+func printDevInstructions(configDir, apiToken string, servicesInstalled bool) {
+	setEnv := fmt.Sprintf("export %s=%s", constants.EnvVarConfigDir, configDir)
+	if runtime.GOOS == "windows" {
+		setEnv = fmt.Sprintf("$env:%s = \"%s\"", constants.EnvVarConfigDir, configDir)
+	}
+	server := net.JoinHostPort("localhost", constants.DevProxyHTTPPort)
+
+	if !servicesInstalled {
+		ui.Info("\nStart the proxy and haloyd, each in its own terminal:")
+		ui.Info("  %s", setEnv)
+		ui.Info("  haloy-proxy serve")
+		ui.Info("")
+		ui.Info("  %s", setEnv)
+		ui.Info("  haloyd serve")
+	}
+	ui.Info("\nThen add the local server to the haloy CLI and deploy as usual:")
+	ui.Info("  haloy server add local %s %s", server, apiToken)
+	ui.Info("\nApps are served over HTTP on port %s; use domains like myapp.localhost.", constants.DevProxyHTTPPort)
+}
+
+// installServices installs haloy-proxy and haloyd, from the directory this
+// binary is in, as services of the host's service manager and starts them.
+// A dev install gets services of the current user. It returns the service
+// manager's name.
+func installServices(ctx context.Context, dataDir, configDir string, dev bool) (string, error) {
+	manager, err := service.Detect(dev)
+	if err != nil {
+		return "", err
+	}
+	if !dev && os.Geteuid() != 0 {
+		return "", fmt.Errorf("installing %s services requires root, run with sudo", manager.Name())
+	}
+
+	opts, serviceAccount, err := serviceOptions(manager, dataDir, configDir, dev)
+	if err != nil {
+		return "", err
+	}
+	if serviceAccount != nil {
+		if err := chownTree(serviceAccount, dataDir, configDir); err != nil {
+			return "", err
+		}
+	} else if !dev && manager.Name() != "launchd" {
+		ui.Warn("User '%s' not found, the services run as root", serviceUser)
+	}
+
+	services := service.HaloyServices(opts)
+	if err := manager.Install(ctx, services); err != nil {
+		return "", err
+	}
+	if err := manager.Start(ctx, services); err != nil {
+		return "", err
+	}
+	return manager.Name(), nil
+}
+
+// serviceOptions returns the options the services of manager are installed
+// with, and the user they run as when it isn't root.
+func serviceOptions(manager service.Manager, dataDir, configDir string, dev bool) (service.Options, *user.User, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return service.Options{}, nil, fmt.Errorf("failed to locate haloyd binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	binDir := filepath.Dir(exe)
+	if _, err := os.Stat(filepath.Join(binDir, "haloy-proxy")); err != nil {
+		return service.Options{}, nil, fmt.Errorf("haloy-proxy must be installed next to haloyd in %s: %w", binDir, err)
+	}
+
+	opts := service.Options{BinDir: binDir, DataDir: dataDir, ConfigDir: configDir}
+	// launchd daemons run as root: macOS has no service user, and haloy-proxy
+	// needs ports 80/443.
+	if dev || manager.Name() == "launchd" {
+		return opts, nil, nil
+	}
+	u, err := user.Lookup(serviceUser)
+	if err != nil {
+		return opts, nil, nil
+	}
+	opts.User = u.Username
+	return opts, u, nil
+}
+
+// chownTree gives u everything in dirs, so services running as u can use
+// the directories init created as root.
+func chownTree(u *user.User, dirs ...string) error {
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("invalid uid for %s: %w", u.Username, err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("invalid gid for %s: %w", u.Username, err)
+	}
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, _ fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			return os.Lchown(path, uid, gid)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to change owner of %s to %s: %w", dir, u.Username, err)
+		}
+	}
+	return nil
+}
+
+func createConfigFiles(apiToken, domain, configDir string, extraEnv map[string]string) error {
 	if apiToken == "" {
 		return fmt.Errorf("apiToken cannot be empty")
 	}
@@ -163,6 +348,7 @@ func createConfigFiles(apiToken, domain, configDir string) error {
 	env := map[string]string{
 		constants.EnvVarAPIToken: apiToken,
 	}
+	maps.Copy(env, extraEnv)
 	if err := godotenv.Write(env, envPath); err != nil {
 		return fmt.Errorf("failed to write %s: %w", constants.ConfigEnvFileName, err)
 	}
@@ -203,26 +389,6 @@ func createSubdirectories(dataDir string, subdirs []string) error {
 	return nil
 }
 
-func ensureDockerNetwork(ctx interface{ Done() <-chan struct{} }) error {
-	// Check if network exists
-	checkCmd := exec.Command("docker", "network", "inspect", constants.DockerNetwork)
-	if err := checkCmd.Run(); err == nil {
-		return nil // Network exists
-	}
-
-	// Create network
-	createCmd := exec.Command("docker", "network", "create",
-		"--driver", "bridge",
-		"--attachable",
-		constants.DockerNetwork)
-
-	if output, err := createCmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to create network: %w\nOutput: %s", err, output)
-	}
-
-	return nil
-}
-
 func validateAndPrepareDirectory(dirPath, dirType string, override bool) error {
 	info, err := os.Stat(dirPath)
 	if err == nil {
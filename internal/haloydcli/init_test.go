@@ -0,0 +1,42 @@
+package haloydcli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/joho/godotenv"
+)
+
+func TestCreateConfigFilesDev(t *testing.T) {
+	configDir := t.TempDir()
+	dataDir := filepath.Join(t.TempDir(), "data")
+
+	if err := createConfigFiles("token", "localhost", configDir, devEnv(dataDir, "darwin")); err != nil {
+		t.Fatalf("createConfigFiles() unexpected error: %v", err)
+	}
+
+	env, err := godotenv.Read(filepath.Join(configDir, constants.ConfigEnvFileName))
+	if err != nil {
+		t.Fatalf("failed to read env file: %v", err)
+	}
+	want := map[string]string{
+		constants.EnvVarAPIToken:       "token",
+		constants.EnvVarDataDir:        dataDir,
+		constants.EnvVarProxyHTTPAddr:  "127.0.0.1:" + constants.DevProxyHTTPPort,
+		constants.EnvVarProxyHTTPSAddr: "127.0.0.1:" + constants.DevProxyHTTPSPort,
+		constants.EnvVarPublishPorts:   "true",
+	}
+	for key, value := range want {
+		if env[key] != value {
+			t.Errorf("%s = %q, want %q", key, env[key], value)
+		}
+	}
+}
+
+func TestDevEnvLinuxDoesNotPublishPorts(t *testing.T) {
+	env := devEnv("/data", "linux")
+	if _, ok := env[constants.EnvVarPublishPorts]; ok {
+		t.Errorf("ports should not be published on Linux, got %v", env)
+	}
+}
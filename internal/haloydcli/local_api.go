@@ -0,0 +1,33 @@
+package haloydcli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// localAPI returns a client of the API of the haloyd running on this server
+// and its version. It talks to the local API listener directly, so it works
+// without the proxy or DNS for the API domain.
+func localAPI(ctx context.Context, timeout time.Duration) (*apiclient.APIClient, apitypes.VersionResponse, error) {
+	var version apitypes.VersionResponse
+	token := os.Getenv(constants.EnvVarAPIToken)
+	if token == "" {
+		return nil, version, fmt.Errorf("%s is not set; it is read from the haloyd env file, run this as a user that can read it", constants.EnvVarAPIToken)
+	}
+
+	api, err := apiclient.NewWithTimeout(net.JoinHostPort(constants.HaloydAPIHost, constants.HaloydAPIPort), token, timeout)
+	if err != nil {
+		return nil, version, fmt.Errorf("failed to create API client: %w", err)
+	}
+	if err := api.Get(ctx, "version", &version); err != nil {
+		return nil, version, fmt.Errorf("haloyd API is not reachable, is haloyd running? %w", err)
+	}
+	return api, version, nil
+}
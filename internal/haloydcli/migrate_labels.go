@@ -0,0 +1,130 @@
+package haloydcli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/logging"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func migrateLabelsCmd() *cobra.Command {
+	var dryRun bool
+	var appName string
+	var healthTimeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "migrate-labels",
+		Short: "Relabel app containers with the current label format",
+		Long: fmt.Sprintf(`Find app containers labeled by an older haloy and recreate them with their
+labels migrated to the current format (version %d). Docker can't change the labels
+of an existing container, so each one is replaced by a copy with the same config,
+name and networks.
+
+Running containers are stopped while they are replaced, and the copy has to pass
+its health check within --health-timeout, or it is removed and the original
+restored. Run this during a maintenance window. Containers with sidecars are
+skipped; redeploy those apps to relabel them.
+
+haloyd migrates older labels in memory as it reads them, so this is only needed before
+upgrading to a haloy that drops support for them. Use --dry-run to only report.`, config.LabelSchemaVersion),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			cli, err := docker.NewClient(ctx)
+			if err != nil {
+				return err
+			}
+			defer cli.Close()
+
+			containers, err := docker.GetAppContainers(ctx, cli, true, appName)
+			if err != nil {
+				return err
+			}
+
+			logger := logging.NewLogger(slog.LevelError, nil)
+			var rows [][]string
+			migrated, failed := 0, 0
+			for _, c := range containers {
+				version, err := config.LabelSchemaOf(c.Labels)
+				if err == nil && version >= config.LabelSchemaVersion {
+					continue
+				}
+
+				status := "would be relabeled"
+				if err != nil {
+					status = fmt.Sprintf("failed: %v", err)
+					failed++
+				} else if !dryRun {
+					var relabeled bool
+					relabeled, status, err = migrateContainerLabels(ctx, cli, logger, c.ID, c.Labels, healthTimeout)
+					if relabeled {
+						migrated++
+					}
+					if err != nil {
+						failed++
+					}
+				}
+				rows = append(rows, []string{
+					helpers.SafeIDPrefix(c.ID),
+					c.Labels[config.LabelAppName],
+					c.Labels[config.LabelDeploymentID],
+					strconv.Itoa(version),
+					status,
+				})
+			}
+
+			if len(rows) == 0 {
+				ui.Success("All app containers use label format version %d", config.LabelSchemaVersion)
+				return nil
+			}
+			ui.Table([]string{"CONTAINER", "APP", "DEPLOYMENT ID", "LABEL VERSION", "STATUS"}, rows)
+
+			if dryRun {
+				ui.Info("Dry run: nothing was relabeled")
+				return nil
+			}
+			ui.Success("Relabeled %d of %d containers", migrated, len(rows))
+			if failed > 0 {
+				return fmt.Errorf("failed to relabel %d containers", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report containers with old labels, change nothing")
+	cmd.Flags().StringVar(&appName, "app", "", "Only relabel containers of this app")
+	cmd.Flags().DurationVar(&healthTimeout, "health-timeout", time.Minute, "How long a relabeled container has to become healthy")
+	return cmd
+}
+
+// migrateContainerLabels recreates a container with its labels upgraded. It
+// reports whether the container was replaced and its status for the table.
+// Skipped containers aren't an error.
+func migrateContainerLabels(ctx context.Context, cli *client.Client, logger *slog.Logger, containerID string, labels map[string]string, healthTimeout time.Duration) (bool, string, error) {
+	upgraded, err := config.UpgradeLabels(labels)
+	if err != nil {
+		return false, fmt.Sprintf("failed: %v", err), err
+	}
+
+	newID, err := docker.RecreateWithLabels(ctx, cli, logger, containerID, upgraded, healthTimeout)
+	switch {
+	case errors.Is(err, docker.ErrHasSidecars):
+		return false, "skipped, has sidecars", nil
+	case err != nil && newID == "":
+		return false, fmt.Sprintf("failed, kept as is: %v", err), err
+	case err != nil:
+		return true, fmt.Sprintf("relabeled as %s, but: %v", helpers.SafeIDPrefix(newID), err), err
+	}
+	return true, "relabeled as " + helpers.SafeIDPrefix(newID), nil
+}
@@ -0,0 +1,87 @@
+package haloydcli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// reconcileTimeout bounds a reconcile that deploys apps again, which pulls
+// their images when Docker lost them too.
+const reconcileTimeout = 30 * time.Minute
+
+func reconcileCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Recreate apps whose containers are missing",
+		Long: `Compare the live deployment of every app with the containers Docker has,
+and deploy the apps that are missing containers again from the spec their live
+deployment was started with: the same image, env vars, replicas and domains.
+Containers go missing when Docker's data is lost, like after a 'docker system
+prune' or restoring the server from a backup without it. Apps stopped with
+'haloy stop' are left alone.
+
+haloyd runs this check itself when it starts. Use --dry-run to only report.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			api, _, err := localAPI(ctx, reconcileTimeout)
+			if err != nil {
+				return err
+			}
+
+			path := "reconcile"
+			if dryRun {
+				path += "?dryRun=true"
+			}
+			var response apitypes.ReconcileResponse
+			if err := api.Post(ctx, path, nil, &response); err != nil {
+				return fmt.Errorf("failed to reconcile apps: %w", err)
+			}
+
+			if len(response.Apps) == 0 {
+				ui.Info("No apps with a live deployment")
+				return nil
+			}
+
+			rows := make([][]string, 0, len(response.Apps))
+			var failed []string
+			for _, app := range response.Apps {
+				status := app.Action
+				switch app.Action {
+				case apitypes.ReconcileRecreated:
+					status = "recreated as " + app.NewDeploymentID
+				case apitypes.ReconcileFailed:
+					status = "failed: " + app.Error
+					failed = append(failed, app.App)
+				}
+				rows = append(rows, []string{
+					app.App,
+					app.DeploymentID,
+					app.Image,
+					fmt.Sprintf("%d/%d", app.Containers, app.Replicas),
+					strings.Join(app.Domains, ", "),
+					status,
+				})
+			}
+			ui.Table([]string{"APP", "DEPLOYMENT", "IMAGE", "CONTAINERS", "DOMAINS", "STATUS"}, rows)
+
+			if dryRun {
+				ui.Info("Dry run: nothing was recreated")
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("failed to recreate %s", strings.Join(failed, ", "))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report apps with missing containers, recreate nothing")
+	return cmd
+}
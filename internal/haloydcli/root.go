@@ -30,6 +30,13 @@ It manages:
 		configCmd(),
 		versionCmd(),
 		verifyCmd(),
+		statusCmd(),
+		cacheCmd(),
+		bundleCmd(),
+		gcCmd(),
+		reconcileCmd(),
+		migrateLabelsCmd(),
+		wgCmd(),
 	)
 
 	return cmd
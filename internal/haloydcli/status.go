@@ -0,0 +1,486 @@
+package haloydcli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/haloyd"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/proxyclient"
+	"github.com/haloydev/haloy/internal/proxywire"
+	"github.com/haloydev/haloy/internal/service"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// certificateExpiryWarning is how close to expiry a certificate is shown as
+// a warning.
+const certificateExpiryWarning = 14 * 24 * time.Hour
+
+func statusCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of haloyd and haloy-proxy on this server",
+		Long: `Show the status of haloyd and haloy-proxy on this server: uptime and
+version, the proxy's listeners and routes, certificate expiry, the health
+monitor, the update queue, the last maintenance run, Docker and disk usage,
+and restarts needed to pick up upgrades or config changes.
+
+Everything is read from local state, the proxy's control socket and Docker,
+so it works when the API doesn't.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			dataDir, err := config.DataDir()
+			if err != nil {
+				return fmt.Errorf("failed to determine data directory: %w", err)
+			}
+			configDir, err := config.HaloydConfigDir()
+			if err != nil {
+				return fmt.Errorf("failed to determine config directory: %w", err)
+			}
+
+			status := collectServerStatus(cmd.Context(), dataDir, configDir)
+			if jsonOutput {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(status)
+			}
+			printServerStatus(status)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the status as JSON")
+	return cmd
+}
+
+type serverStatus struct {
+	Daemon            daemonStatus               `json:"daemon"`
+	Proxy             proxyStatus                `json:"proxy"`
+	Certificates      []certificateStatus        `json:"certificates"`
+	HealthMonitor     *haloyd.HealthSummary      `json:"health_monitor,omitempty"`
+	UpdateQueue       *haloyd.UpdateQueueSummary `json:"update_queue,omitempty"`
+	LastMaintenanceAt time.Time                  `json:"last_maintenance_at,omitzero"`
+	Docker            dockerStatus               `json:"docker"`
+	Disk              []diskUsage                `json:"disk"`
+	PendingRestarts   []string                   `json:"pending_restarts"`
+}
+
+type daemonStatus struct {
+	Running          bool      `json:"running"`
+	PID              int       `json:"pid,omitempty"`
+	Version          string    `json:"version,omitempty"`
+	InstalledVersion string    `json:"installed_version"`
+	StartedAt        time.Time `json:"started_at,omitzero"`
+	UptimeSeconds    int64     `json:"uptime_seconds,omitempty"`
+	Error            string    `json:"error,omitempty"`
+}
+
+type proxyStatus struct {
+	Running   bool             `json:"running"`
+	Version   string           `json:"version,omitempty"`
+	Listeners []listenerStatus `json:"listeners"`
+	// ListenersDown are the listeners the proxy reports as dead and is
+	// rebinding.
+	ListenersDown []string  `json:"listeners_down,omitempty"`
+	Routes        int       `json:"routes"`
+	CertsLoaded   int       `json:"certs_loaded"`
+	LastUpdateAt  time.Time `json:"last_update_at,omitzero"`
+	Error         string    `json:"error,omitempty"`
+
+	generation int
+}
+
+type listenerStatus struct {
+	Address   string `json:"address"`
+	Listening bool   `json:"listening"`
+}
+
+type certificateStatus struct {
+	Domain   string    `json:"domain"`
+	NotAfter time.Time `json:"not_after"`
+	Staging  bool      `json:"staging,omitempty"`
+}
+
+type dockerStatus struct {
+	Running           bool   `json:"running"`
+	Version           string `json:"version,omitempty"`
+	ContainersRunning int    `json:"containers_running"`
+	Containers        int    `json:"containers"`
+	Images            int    `json:"images"`
+	RootDir           string `json:"root_dir,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+type diskUsage struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	TotalBytes uint64 `json:"total_bytes"`
+	FreeBytes  uint64 `json:"free_bytes"`
+}
+
+func (d diskUsage) usedPercent() float64 {
+	if d.TotalBytes == 0 {
+		return 0
+	}
+	return float64(d.TotalBytes-d.FreeBytes) / float64(d.TotalBytes) * 100
+}
+
+func collectServerStatus(ctx context.Context, dataDir, configDir string) serverStatus {
+	now := time.Now()
+	status := serverStatus{Certificates: []certificateStatus{}, Disk: []diskUsage{}}
+
+	runtime, err := haloyd.ReadRuntimeStatus(dataDir)
+	status.Daemon = daemonStatusFrom(runtime, err, now)
+	if runtime != nil {
+		status.HealthMonitor = runtime.HealthMonitor
+		status.UpdateQueue = runtime.UpdateQueue
+		status.LastMaintenanceAt = runtime.LastMaintenanceAt
+	}
+
+	status.Proxy = collectProxyStatus(ctx, dataDir)
+
+	certs, err := certificateExpiries(filepath.Join(dataDir, constants.CertStorageDir))
+	if err != nil {
+		ui.Warn("Failed to read certificates: %v", err)
+	}
+	if certs != nil {
+		status.Certificates = certs
+	}
+
+	status.Docker = collectDockerStatus(ctx)
+
+	status.Disk = append(status.Disk, diskUsageOf("data", dataDir)...)
+	if status.Docker.RootDir != "" {
+		status.Disk = append(status.Disk, diskUsageOf("docker", status.Docker.RootDir)...)
+	}
+
+	var configModified time.Time
+	if info, err := os.Stat(filepath.Join(configDir, constants.HaloydConfigFileName)); err == nil {
+		configModified = info.ModTime()
+	}
+	status.PendingRestarts = pendingRestarts(runtime, status.Daemon.Running, configModified, status.Proxy, outdatedServices(dataDir, configDir))
+
+	return status
+}
+
+// daemonStatusFrom reports haloyd as running when the process that wrote
+// the status file is alive and still updating it.
+func daemonStatusFrom(runtime *haloyd.RuntimeStatus, readErr error, now time.Time) daemonStatus {
+	status := daemonStatus{InstalledVersion: constants.Version}
+	switch {
+	case errors.Is(readErr, os.ErrNotExist):
+		status.Error = "haloyd hasn't run on this server yet"
+		return status
+	case readErr != nil:
+		status.Error = readErr.Error()
+		return status
+	}
+
+	status.Version = runtime.Version
+	if !runtime.Fresh(now) || !processAlive(runtime.PID) {
+		status.Error = fmt.Sprintf("not running, last seen %s", helpers.FormatTime(runtime.UpdatedAt))
+		return status
+	}
+	status.Running = true
+	status.PID = runtime.PID
+	status.StartedAt = runtime.StartedAt
+	status.UptimeSeconds = int64(now.Sub(runtime.StartedAt).Seconds())
+	return status
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// EPERM means the process exists but runs as another user.
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func collectProxyStatus(ctx context.Context, dataDir string) proxyStatus {
+	var status proxyStatus
+	for _, addr := range proxyListenAddrs() {
+		status.Listeners = append(status.Listeners, listenerStatus{Address: addr, Listening: listening(addr)})
+	}
+
+	statusCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	client := proxyclient.New(dataDir, slog.New(slog.DiscardHandler))
+	proxy, err := client.Status(statusCtx)
+	if err == nil {
+		status.Running = true
+		status.Version = proxy.Version
+		status.Routes = proxy.Routes
+		status.CertsLoaded = proxy.CertsLoaded
+		status.LastUpdateAt = proxy.LastUpdateAt
+		status.ListenersDown = proxy.DownListeners()
+		status.generation = proxy.Generation
+		return status
+	}
+	status.Error = err.Error()
+
+	// The proxy boots from the snapshot file, so it has these routes once
+	// it runs again.
+	snap, snapErr := proxywire.ReadSnapshotFile(filepath.Join(dataDir, constants.ProxyDir, constants.ProxySnapshotFileName))
+	if snapErr == nil {
+		status.Routes = len(snap.Routes)
+	}
+	return status
+}
+
+// proxyListenAddrs returns the addresses haloy-proxy listens on, as set for
+// local development or the defaults.
+func proxyListenAddrs() []string {
+	var addrs []string
+	for _, env := range []struct{ name, fallback string }{
+		{constants.EnvVarProxyHTTPAddr, ":80"},
+		{constants.EnvVarProxyHTTPSAddr, ":443"},
+	} {
+		value := os.Getenv(env.name)
+		if value == "" {
+			value = env.fallback
+		}
+		for addr := range strings.SplitSeq(value, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+func listening(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "" || net.ParseIP(host).IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// certificateExpiries returns the certificates in certDir, the ones expiring
+// first first.
+func certificateExpiries(certDir string) ([]certificateStatus, error) {
+	entries, err := os.ReadDir(certDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", certDir, err)
+	}
+
+	var certs []certificateStatus
+	for _, entry := range entries {
+		domain, ok := strings.CutSuffix(entry.Name(), ".pem")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(certDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		leaf := leafCertificate(data)
+		if leaf == nil {
+			continue
+		}
+		certs = append(certs, certificateStatus{
+			Domain:   domain,
+			NotAfter: leaf.NotAfter,
+			Staging:  strings.Contains(leaf.Issuer.String(), "(STAGING)"),
+		})
+	}
+	slices.SortFunc(certs, func(a, b certificateStatus) int { return a.NotAfter.Compare(b.NotAfter) })
+	return certs, nil
+}
+
+func collectDockerStatus(ctx context.Context) dockerStatus {
+	var status dockerStatus
+	cli, err := docker.NewClient(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer cli.Close()
+
+	info, err := cli.Info(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Running = true
+	status.Version = info.ServerVersion
+	status.ContainersRunning = info.ContainersRunning
+	status.Containers = info.Containers
+	status.Images = info.Images
+	status.RootDir = info.DockerRootDir
+	return status
+}
+
+func diskUsageOf(name, path string) []diskUsage {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return nil
+	}
+	return []diskUsage{{
+		Name:       name,
+		Path:       path,
+		TotalBytes: stat.Blocks * uint64(stat.Bsize),
+		FreeBytes:  stat.Bavail * uint64(stat.Bsize),
+	}}
+}
+
+func outdatedServices(dataDir, configDir string) []string {
+	manager, err := service.Detect(false)
+	if err != nil {
+		return nil
+	}
+	opts, _, err := serviceOptions(manager, dataDir, configDir, false)
+	if err != nil {
+		return nil
+	}
+	var outdated []string
+	for _, s := range service.HaloyServices(opts) {
+		if manager.State(s) == service.StateOutdated {
+			outdated = append(outdated, s.Name)
+		}
+	}
+	return outdated
+}
+
+// pendingRestarts lists what haloyd or haloy-proxy only pick up once
+// restarted.
+func pendingRestarts(runtime *haloyd.RuntimeStatus, running bool, configModified time.Time, proxy proxyStatus, outdatedServices []string) []string {
+	pending := []string{}
+	if runtime != nil && running {
+		if runtime.Version != constants.Version {
+			pending = append(pending, fmt.Sprintf("haloyd runs %s, but %s is installed", runtime.Version, constants.Version))
+		}
+		if configModified.After(runtime.StartedAt) {
+			pending = append(pending, fmt.Sprintf("haloyd: %s changed after haloyd started", constants.HaloydConfigFileName))
+		}
+	}
+	if proxy.Running && proxy.generation < proxywire.ProxyGeneration {
+		pending = append(pending, fmt.Sprintf("haloy-proxy %s is older than haloyd %s requires", proxy.Version, constants.Version))
+	}
+	for _, name := range outdatedServices {
+		pending = append(pending, fmt.Sprintf("%s: service definition is outdated, run 'haloyd verify --fix'", name))
+	}
+	return pending
+}
+
+func printServerStatus(status serverStatus) {
+	daemon := status.Daemon
+	if daemon.Running {
+		ui.Success("haloyd %s: running (pid %d), started %s", daemon.Version, daemon.PID, helpers.FormatTime(daemon.StartedAt))
+	} else {
+		ui.Error("haloyd: %s", daemon.Error)
+	}
+
+	proxy := status.Proxy
+	var listeners []string
+	for _, l := range proxy.Listeners {
+		state := "listening"
+		if !l.Listening {
+			state = "not listening"
+		}
+		listeners = append(listeners, fmt.Sprintf("%s %s", l.Address, state))
+	}
+	if proxy.Running {
+		ui.Success("haloy-proxy %s: running, %d routes, %d certificates loaded, %s",
+			proxy.Version, proxy.Routes, proxy.CertsLoaded, strings.Join(listeners, ", "))
+	} else {
+		ui.Error("haloy-proxy: %s (%d routes in its snapshot file), %s", proxy.Error, proxy.Routes, strings.Join(listeners, ", "))
+	}
+	for _, listener := range proxy.ListenersDown {
+		ui.Warn("haloy-proxy: %s listener died and is being rebound", listener)
+	}
+
+	if health := status.HealthMonitor; health != nil {
+		if health.Unhealthy > 0 {
+			ui.Warn("Health monitor: %d of %d containers unhealthy (%s)", health.Unhealthy, health.Targets, strings.Join(health.UnhealthyApps, ", "))
+		} else {
+			ui.Success("Health monitor: %d containers healthy", health.Healthy)
+		}
+	} else if daemon.Running {
+		ui.Info("Health monitor: disabled")
+	}
+
+	if queue := status.UpdateQueue; queue != nil && daemon.Running {
+		switch {
+		case queue.Running:
+			ui.Info("Updates: one running, %d waiting", queue.Waiting)
+		case queue.Waiting > 0:
+			ui.Info("Updates: %d waiting", queue.Waiting)
+		default:
+			ui.Info("Updates: none queued")
+		}
+	}
+
+	switch {
+	case !status.LastMaintenanceAt.IsZero():
+		ui.Info("Maintenance: last run %s", helpers.FormatTime(status.LastMaintenanceAt))
+	case daemon.Running:
+		ui.Info("Maintenance: not run since haloyd started")
+	}
+
+	docker := status.Docker
+	if docker.Running {
+		ui.Success("Docker %s: %d of %d containers running, %d images", docker.Version, docker.ContainersRunning, docker.Containers, docker.Images)
+	} else {
+		ui.Error("Docker: %s", docker.Error)
+	}
+
+	for _, disk := range status.Disk {
+		line := fmt.Sprintf("Disk (%s, %s): %.0f%% used, %s free of %s", disk.Name, disk.Path, disk.usedPercent(),
+			helpers.FormatBinaryBytes(disk.FreeBytes), helpers.FormatBinaryBytes(disk.TotalBytes))
+		if disk.usedPercent() >= 90 {
+			ui.Warn("%s", line)
+		} else {
+			ui.Info("%s", line)
+		}
+	}
+
+	for _, pending := range status.PendingRestarts {
+		ui.Warn("Restart pending: %s", pending)
+	}
+
+	if len(status.Certificates) > 0 {
+		rows := make([][]string, 0, len(status.Certificates))
+		for _, cert := range status.Certificates {
+			var notes []string
+			switch remaining := time.Until(cert.NotAfter); {
+			case remaining <= 0:
+				notes = append(notes, "expired")
+			case remaining < certificateExpiryWarning:
+				notes = append(notes, "expires soon")
+			}
+			if cert.Staging {
+				notes = append(notes, "staging")
+			}
+			rows = append(rows, []string{cert.Domain, cert.NotAfter.Format(time.DateOnly), helpers.FormatTime(cert.NotAfter), strings.Join(notes, ", ")})
+		}
+		ui.Table([]string{"DOMAIN", "EXPIRES", "", "NOTES"}, rows)
+	}
+}
@@ -0,0 +1,89 @@
+package haloydcli
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/haloyd"
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+func TestDaemonStatusFrom(t *testing.T) {
+	now := time.Now()
+	running := &haloyd.RuntimeStatus{PID: os.Getpid(), Version: "v1.2.0", StartedAt: now.Add(-time.Hour), UpdatedAt: now.Add(-time.Second)}
+	stale := &haloyd.RuntimeStatus{PID: os.Getpid(), Version: "v1.2.0", StartedAt: now.Add(-time.Hour), UpdatedAt: now.Add(-time.Hour)}
+
+	if got := daemonStatusFrom(running, nil, now); !got.Running || got.UptimeSeconds != 3600 || got.PID != os.Getpid() {
+		t.Errorf("daemonStatusFrom(running) = %+v, want running for an hour", got)
+	}
+	if got := daemonStatusFrom(stale, nil, now); got.Running || !strings.Contains(got.Error, "not running") {
+		t.Errorf("daemonStatusFrom(stale) = %+v, want not running", got)
+	}
+	if got := daemonStatusFrom(nil, os.ErrNotExist, now); got.Running || !strings.Contains(got.Error, "hasn't run") {
+		t.Errorf("daemonStatusFrom(missing) = %+v, want never run", got)
+	}
+}
+
+func TestPendingRestarts(t *testing.T) {
+	started := time.Now().Add(-time.Hour)
+	runtime := &haloyd.RuntimeStatus{Version: constants.Version, StartedAt: started}
+	currentProxy := proxyStatus{Running: true, generation: proxywire.ProxyGeneration}
+
+	tests := []struct {
+		name           string
+		runtime        *haloyd.RuntimeStatus
+		running        bool
+		configModified time.Time
+		proxy          proxyStatus
+		services       []string
+		want           []string
+	}{
+		{name: "nothing pending", runtime: runtime, running: true, configModified: started.Add(-time.Hour), proxy: currentProxy, want: []string{}},
+		{
+			name:    "upgraded haloyd",
+			runtime: &haloyd.RuntimeStatus{Version: "v0.0.1", StartedAt: started}, running: true, proxy: currentProxy,
+			want: []string{"haloyd runs v0.0.1, but " + constants.Version + " is installed"},
+		},
+		{
+			name: "config changed", runtime: runtime, running: true, configModified: started.Add(time.Minute), proxy: currentProxy,
+			want: []string{"haloyd: haloyd.yaml changed after haloyd started"},
+		},
+		{name: "stopped haloyd", runtime: &haloyd.RuntimeStatus{Version: "v0.0.1", StartedAt: started}, proxy: currentProxy, want: []string{}},
+		{
+			name: "old proxy and outdated service", runtime: runtime, running: true,
+			proxy: proxyStatus{Running: true, Version: "v0.0.1", generation: proxywire.ProxyGeneration - 1}, services: []string{"haloy-proxy"},
+			want: []string{
+				"haloy-proxy v0.0.1 is older than haloyd " + constants.Version + " requires",
+				"haloy-proxy: service definition is outdated, run 'haloyd verify --fix'",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pendingRestarts(tt.runtime, tt.running, tt.configModified, tt.proxy, tt.services)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("pendingRestarts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCertificateExpiries(t *testing.T) {
+	certDir := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	writeTestCertificate(t, filepath.Join(certDir, "late.example.com.pem"), "R10", now.Add(60*24*time.Hour))
+	writeTestCertificate(t, filepath.Join(certDir, "soon.example.com.pem"), "(STAGING) Counterfeit Cashew R10", now.Add(time.Hour))
+
+	certs, err := certificateExpiries(certDir)
+	if err != nil {
+		t.Fatalf("certificateExpiries() error = %v", err)
+	}
+	if len(certs) != 2 || certs[0].Domain != "soon.example.com" || !certs[0].Staging || certs[1].Domain != "late.example.com" || certs[1].Staging {
+		t.Errorf("certificateExpiries() = %+v, want soon.example.com (staging) then late.example.com", certs)
+	}
+}
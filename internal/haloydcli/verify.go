@@ -3,39 +3,63 @@ package haloydcli
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
 	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/docker"
 	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/service"
+	"github.com/haloydev/haloy/internal/storage"
 	"github.com/haloydev/haloy/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 func verifyCmd() *cobra.Command {
+	var fix, yes bool
 	cmd := &cobra.Command{
-		Use:   "verify",
-		Short: "Verify Haloy installation and configuration",
+		Use:     "verify",
+		Aliases: []string{"doctor"},
+		Short:   "Verify Haloy installation and configuration",
 		Long: `Run diagnostic checks to verify Haloy is properly installed and configured.
 
 Checks performed:
   - Configuration files exist and are valid
   - Data directories have correct permissions
+  - Certificate files have correct permissions and no staging certificate has expired
   - Docker daemon is accessible
-  - Docker network exists
-  - API is responding (if service is running)`,
+  - Docker network exists and app containers are attached to it
+  - App containers run with a read-only root filesystem, no-new-privileges
+    and dropped capabilities (a warning only)
+  - Services are installed with current definitions
+  - API is responding (if service is running)
+
+With --fix, failed checks that can be repaired are fixed after asking for
+confirmation, which --yes skips.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runVerify()
+			return runVerify(cmd.Context(), fix, yes)
 		},
 	}
+	cmd.Flags().BoolVar(&fix, "fix", false, "Repair failed checks")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Apply fixes without asking for confirmation")
 	return cmd
 }
 
@@ -43,35 +67,75 @@ type checkResult struct {
 	name    string
 	passed  bool
 	message string
+	// fix repairs a failed check, when it can be repaired.
+	fix *checkFix
+	// advisory checks are reported as warnings and don't fail verify.
+	advisory bool
 }
 
-func runVerify() error {
+type checkFix struct {
+	// description completes "Fix: ..." and the confirmation question.
+	description string
+	apply       func(ctx context.Context) error
+}
+
+func runVerify(ctx context.Context, fix, yes bool) error {
 	ui.Info("Running Haloy verification checks...\n")
 
-	checks := []func() checkResult{
+	checks := []func(context.Context) checkResult{
 		checkConfigDir,
 		checkDataDir,
+		checkCertStorage,
+		checkStagingCertificates,
 		checkConfigFiles,
 		checkDocker,
 		checkDockerNetwork,
+		checkContainerNetworks,
+		checkContainerSecurity,
+		checkServices,
 		checkAPIHealth,
 	}
 
 	passed := 0
+	fixed := 0
 	failed := 0
+	fixable := 0
+	warnings := 0
 
 	for _, check := range checks {
-		result := check()
+		result := check(ctx)
 		if result.passed {
 			ui.Success("%s: %s", result.name, result.message)
 			passed++
-		} else {
-			ui.Error("%s: %s", result.name, result.message)
-			failed++
+			continue
+		}
+		if result.advisory {
+			ui.Warn("%s: %s", result.name, result.message)
+			warnings++
+			continue
+		}
+		ui.Error("%s: %s", result.name, result.message)
+		if result.fix != nil && !fix {
+			fixable++
 		}
+		if result.fix != nil && fix && applyFix(ctx, result.fix, yes) {
+			fixed++
+			continue
+		}
+		failed++
 	}
 
-	ui.Info("\nResults: %d passed, %d failed", passed, failed)
+	summary := fmt.Sprintf("%d passed, %d failed", passed, failed)
+	if fix {
+		summary = fmt.Sprintf("%d passed, %d fixed, %d failed", passed, fixed, failed)
+	}
+	if warnings > 0 {
+		summary += fmt.Sprintf(", %d warnings", warnings)
+	}
+	ui.Info("\nResults: %s", summary)
+	if fixable > 0 {
+		ui.Info("Run 'haloyd verify --fix' to repair %d of the failed checks", fixable)
+	}
 
 	if failed > 0 {
 		return fmt.Errorf("%d checks failed", failed)
@@ -79,7 +143,28 @@ func runVerify() error {
 	return nil
 }
 
-func checkConfigDir() checkResult {
+// applyFix asks for confirmation unless yes is set, applies fix and reports
+// whether the check is repaired.
+func applyFix(ctx context.Context, fix *checkFix, yes bool) bool {
+	if !yes {
+		answer, err := ui.Prompt(fmt.Sprintf("Fix: %s? [y/N]", fix.description))
+		if err != nil {
+			ui.Warn("Skipped fix: %v", err)
+			return false
+		}
+		if !slices.Contains([]string{"y", "yes"}, strings.ToLower(answer)) {
+			return false
+		}
+	}
+	if err := fix.apply(ctx); err != nil {
+		ui.Error("Fix failed: %s: %v", fix.description, err)
+		return false
+	}
+	ui.Success("Fixed: %s", fix.description)
+	return true
+}
+
+func checkConfigDir(ctx context.Context) checkResult {
 	configDir, err := config.HaloydConfigDir()
 	if err != nil {
 		return checkResult{
@@ -119,7 +204,7 @@ func checkConfigDir() checkResult {
 	}
 }
 
-func checkDataDir() checkResult {
+func checkDataDir(ctx context.Context) checkResult {
 	dataDir, err := config.DataDir()
 	if err != nil {
 		return checkResult{
@@ -161,6 +246,12 @@ func checkDataDir() checkResult {
 				name:    "Data directory",
 				passed:  false,
 				message: fmt.Sprintf("incorrect permissions %o (expected 700): %s", mode, dataDir),
+				fix: &checkFix{
+					description: fmt.Sprintf("set the permissions of %s to 700", dataDir),
+					apply: func(context.Context) error {
+						return os.Chmod(dataDir, constants.ModeDirPrivate)
+					},
+				},
 			}
 		}
 		// Check owner is haloy user or root
@@ -180,7 +271,7 @@ func checkDataDir() checkResult {
 	}
 }
 
-func checkConfigFiles() checkResult {
+func checkConfigFiles(ctx context.Context) checkResult {
 	configDir, err := config.HaloydConfigDir()
 	if err != nil {
 		return checkResult{
@@ -243,7 +334,7 @@ func checkConfigFiles() checkResult {
 	}
 }
 
-func checkDocker() checkResult {
+func checkDocker(ctx context.Context) checkResult {
 	// Check if docker command exists
 	if _, err := exec.LookPath("docker"); err != nil {
 		return checkResult{
@@ -254,7 +345,7 @@ func checkDocker() checkResult {
 	}
 
 	// Check if Docker daemon is running
-	cmd := exec.Command("docker", "info")
+	cmd := exec.CommandContext(ctx, "docker", "info")
 	if err := cmd.Run(); err != nil {
 		return checkResult{
 			name:    "Docker",
@@ -264,7 +355,7 @@ func checkDocker() checkResult {
 	}
 
 	// Get Docker version
-	versionCmd := exec.Command("docker", "--version")
+	versionCmd := exec.CommandContext(ctx, "docker", "--version")
 	output, err := versionCmd.Output()
 	if err != nil {
 		return checkResult{
@@ -281,13 +372,24 @@ func checkDocker() checkResult {
 	}
 }
 
-func checkDockerNetwork() checkResult {
-	cmd := exec.Command("docker", "network", "inspect", constants.DockerNetwork)
+func checkDockerNetwork(ctx context.Context) checkResult {
+	cmd := exec.CommandContext(ctx, "docker", "network", "inspect", constants.DockerNetwork)
 	if err := cmd.Run(); err != nil {
 		return checkResult{
 			name:    "Docker network",
 			passed:  false,
 			message: fmt.Sprintf("'%s' network does not exist", constants.DockerNetwork),
+			fix: &checkFix{
+				description: fmt.Sprintf("create the '%s' Docker network", constants.DockerNetwork),
+				apply: func(ctx context.Context) error {
+					cli, err := docker.NewClient(ctx)
+					if err != nil {
+						return err
+					}
+					defer cli.Close()
+					return docker.EnsureNetwork(ctx, cli, constants.DockerNetwork)
+				},
+			},
 		}
 	}
 
@@ -298,9 +400,499 @@ func checkDockerNetwork() checkResult {
 	}
 }
 
+func checkContainerNetworks(ctx context.Context) checkResult {
+	cli, err := docker.NewClient(ctx)
+	if err != nil {
+		return checkResult{
+			name:    "Container networks",
+			passed:  false,
+			message: fmt.Sprintf("cannot connect to Docker: %v", err),
+		}
+	}
+	defer cli.Close()
+
+	stray, err := detachedContainers(ctx, cli)
+	if err != nil {
+		return checkResult{
+			name:    "Container networks",
+			passed:  false,
+			message: err.Error(),
+		}
+	}
+	if len(stray) == 0 {
+		return checkResult{
+			name:    "Container networks",
+			passed:  true,
+			message: fmt.Sprintf("app containers are attached to '%s'", constants.DockerNetwork),
+		}
+	}
+
+	names := make([]string, 0, len(stray))
+	for _, c := range stray {
+		names = append(names, containerName(c))
+	}
+	return checkResult{
+		name:    "Container networks",
+		passed:  false,
+		message: fmt.Sprintf("not attached to '%s': %s", constants.DockerNetwork, strings.Join(names, ", ")),
+		fix: &checkFix{
+			description: fmt.Sprintf("attach %d app containers to the '%s' network", len(stray), constants.DockerNetwork),
+			apply: func(ctx context.Context) error {
+				cli, err := docker.NewClient(ctx)
+				if err != nil {
+					return err
+				}
+				defer cli.Close()
+				for _, c := range stray {
+					if err := cli.NetworkConnect(ctx, constants.DockerNetwork, c.ID, nil); err != nil {
+						return fmt.Errorf("failed to attach %s: %w", containerName(c), err)
+					}
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// detachedContainers returns the running app containers created on the
+// haloy network that are no longer attached to it, e.g. after the network
+// was recreated. The proxy can't reach them. Apps with a network of their
+// own are left alone.
+func detachedContainers(ctx context.Context, cli *client.Client) ([]container.Summary, error) {
+	containers, err := docker.GetAppContainers(ctx, cli, false, "")
+	if err != nil {
+		return nil, err
+	}
+	var detached []container.Summary
+	for _, c := range containers {
+		if c.HostConfig.NetworkMode != constants.DockerNetwork {
+			continue
+		}
+		if c.NetworkSettings != nil {
+			if _, ok := c.NetworkSettings.Networks[constants.DockerNetwork]; ok {
+				continue
+			}
+		}
+		detached = append(detached, c)
+	}
+	return detached, nil
+}
+
+func checkContainerSecurity(ctx context.Context) checkResult {
+	cli, err := docker.NewClient(ctx)
+	if err != nil {
+		return checkResult{
+			name:     "Container security",
+			passed:   false,
+			message:  fmt.Sprintf("cannot connect to Docker: %v", err),
+			advisory: true,
+		}
+	}
+	defer cli.Close()
+
+	containers, err := docker.GetAppContainers(ctx, cli, false, "")
+	if err != nil {
+		return checkResult{
+			name:     "Container security",
+			passed:   false,
+			message:  err.Error(),
+			advisory: true,
+		}
+	}
+
+	// Replicas of an app share their host config, so one is inspected per app.
+	gapsByApp := make(map[string][]string)
+	for _, c := range containers {
+		app := c.Labels[config.LabelAppName]
+		if _, seen := gapsByApp[app]; seen {
+			continue
+		}
+		info, err := cli.ContainerInspect(ctx, c.ID)
+		if err != nil || info.ContainerJSONBase == nil {
+			continue
+		}
+		gapsByApp[app] = hardeningGaps(info.HostConfig)
+	}
+
+	var problems []string
+	for _, app := range slices.Sorted(maps.Keys(gapsByApp)) {
+		if gaps := gapsByApp[app]; len(gaps) > 0 {
+			problems = append(problems, fmt.Sprintf("%s (%s)", app, strings.Join(gaps, ", ")))
+		}
+	}
+	if len(problems) == 0 {
+		return checkResult{
+			name:    "Container security",
+			passed:  true,
+			message: "app containers run with security options",
+		}
+	}
+	return checkResult{
+		name:     "Container security",
+		passed:   false,
+		message:  fmt.Sprintf("set 'security' in the deploy config to harden %s", strings.Join(problems, "; ")),
+		advisory: true,
+	}
+}
+
+// hardeningGaps lists the security options a container runs without.
+func hardeningGaps(hostConfig *container.HostConfig) []string {
+	if hostConfig == nil {
+		return nil
+	}
+	var gaps []string
+	if hostConfig.Privileged {
+		gaps = append(gaps, "privileged")
+	}
+	if !hostConfig.ReadonlyRootfs {
+		gaps = append(gaps, "writable root filesystem")
+	}
+	if !slices.ContainsFunc(hostConfig.SecurityOpt, func(opt string) bool {
+		return opt == "no-new-privileges" || opt == "no-new-privileges:true"
+	}) {
+		gaps = append(gaps, "no no-new-privileges")
+	}
+	if !slices.Contains(hostConfig.CapDrop, "ALL") {
+		gaps = append(gaps, "capabilities not dropped")
+	}
+	return gaps
+}
+
+func containerName(c container.Summary) string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return helpers.SafeIDPrefix(c.ID)
+}
+
+func checkCertStorage(ctx context.Context) checkResult {
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return checkResult{
+			name:    "Certificate storage",
+			passed:  false,
+			message: fmt.Sprintf("failed to determine path: %v", err),
+		}
+	}
+	certDir := filepath.Join(dataDir, constants.CertStorageDir)
+	if _, err := os.Stat(certDir); os.IsNotExist(err) {
+		return checkResult{
+			name:    "Certificate storage",
+			passed:  true,
+			message: fmt.Sprintf("not created yet: %s", certDir),
+		}
+	}
+
+	// Services running as the haloy user must own the certificates to
+	// read them.
+	owner := -1
+	serviceAccount, err := user.Lookup(serviceUser)
+	if err == nil {
+		if uid, err := strconv.Atoi(serviceAccount.Uid); err == nil {
+			owner = uid
+		}
+	} else {
+		serviceAccount = nil
+	}
+
+	problems, err := certStorageProblems(certDir, owner)
+	if err != nil {
+		return checkResult{
+			name:    "Certificate storage",
+			passed:  false,
+			message: fmt.Sprintf("cannot access: %v", err),
+		}
+	}
+	if len(problems) == 0 {
+		return checkResult{
+			name:    "Certificate storage",
+			passed:  true,
+			message: certDir,
+		}
+	}
+
+	description := fmt.Sprintf("set the permissions in %s to 700 for directories and 600 for files", certDir)
+	if serviceAccount != nil {
+		description += fmt.Sprintf(", owned by %s", serviceAccount.Username)
+	}
+	return checkResult{
+		name:    "Certificate storage",
+		passed:  false,
+		message: fmt.Sprintf("%d problems, first %s", len(problems), problems[0]),
+		fix: &checkFix{
+			description: description,
+			apply: func(context.Context) error {
+				if err := repairCertStorage(certDir); err != nil {
+					return err
+				}
+				if serviceAccount != nil {
+					return chownTree(serviceAccount, certDir)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// certStorageProblems lists the entries of the certificate directory that
+// are readable by others, or not owned by owner when it isn't -1.
+func certStorageProblems(certDir string, owner int) ([]string, error) {
+	var problems []string
+	err := filepath.WalkDir(certDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		want := constants.ModeFileSecret
+		if entry.IsDir() {
+			want = constants.ModeDirPrivate
+		}
+		if mode := info.Mode().Perm(); mode != want {
+			problems = append(problems, fmt.Sprintf("%s has permissions %o (expected %o)", path, mode, want))
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok && owner >= 0 && int(stat.Uid) != owner {
+			problems = append(problems, fmt.Sprintf("%s is owned by uid %d (expected %d)", path, stat.Uid, owner))
+		}
+		return nil
+	})
+	return problems, err
+}
+
+// repairCertStorage gives the directories of certDir mode 700 and its files
+// mode 600.
+func repairCertStorage(certDir string) error {
+	return filepath.WalkDir(certDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+			return os.Chmod(path, constants.ModeDirPrivate)
+		case entry.Type().IsRegular():
+			return os.Chmod(path, constants.ModeFileSecret)
+		}
+		return nil
+	})
+}
+
+func checkStagingCertificates(ctx context.Context) checkResult {
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return checkResult{
+			name:    "Staging certificates",
+			passed:  false,
+			message: fmt.Sprintf("failed to determine path: %v", err),
+		}
+	}
+	certDir := filepath.Join(dataDir, constants.CertStorageDir)
+
+	expired, err := expiredStagingCertificates(certDir, time.Now())
+	if err != nil {
+		return checkResult{
+			name:    "Staging certificates",
+			passed:  false,
+			message: err.Error(),
+		}
+	}
+	if len(expired) == 0 {
+		return checkResult{
+			name:    "Staging certificates",
+			passed:  true,
+			message: "none expired",
+		}
+	}
+
+	return checkResult{
+		name:    "Staging certificates",
+		passed:  false,
+		message: fmt.Sprintf("expired for %s", strings.Join(expired, ", ")),
+		fix: &checkFix{
+			description: "remove the expired staging certificates, so haloyd requests production ones",
+			apply: func(context.Context) error {
+				if err := removeCertificates(certDir, expired); err != nil {
+					return err
+				}
+				ui.Info("Restart haloyd to request the production certificates now")
+				return nil
+			},
+		},
+	}
+}
+
+// expiredStagingCertificates returns the domains whose certificate in
+// certDir is a Let's Encrypt staging certificate expired at now. These are
+// left behind by running haloyd in debug mode, and browsers reject them.
+func expiredStagingCertificates(certDir string, now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(certDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", certDir, err)
+	}
+
+	var expired []string
+	for _, entry := range entries {
+		domain, ok := strings.CutSuffix(entry.Name(), ".pem")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(certDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		leaf := leafCertificate(data)
+		if leaf == nil {
+			continue
+		}
+		if strings.Contains(leaf.Issuer.String(), "(STAGING)") && now.After(leaf.NotAfter) {
+			expired = append(expired, domain)
+		}
+	}
+	return expired, nil
+}
+
+// leafCertificate returns the first certificate in a combined key and
+// certificate file, or nil when there is none.
+func leafCertificate(data []byte) *x509.Certificate {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil
+		}
+		return cert
+	}
+}
+
+// removeCertificates retires the certificates of domains in the certificate
+// history, so haloyd doesn't restore them, and removes their files.
+func removeCertificates(certDir string, domains []string) error {
+	db, err := storage.New()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		return fmt.Errorf("failed to run database migrations: %w", err)
+	}
+
+	for _, domain := range domains {
+		if err := db.RetireCertificate(domain); err != nil {
+			return fmt.Errorf("failed to retire certificate for %s: %w", domain, err)
+		}
+		if err := os.Remove(filepath.Join(certDir, domain+".pem")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkServices(ctx context.Context) checkResult {
+	manager, err := service.Detect(false)
+	if errors.Is(err, service.ErrUnsupported) {
+		return checkResult{
+			name:    "Services",
+			passed:  true,
+			message: "no supported service manager",
+		}
+	}
+	if err != nil {
+		return checkResult{
+			name:    "Services",
+			passed:  false,
+			message: err.Error(),
+		}
+	}
+
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return checkResult{
+			name:    "Services",
+			passed:  false,
+			message: fmt.Sprintf("failed to determine data directory: %v", err),
+		}
+	}
+	configDir, err := config.HaloydConfigDir()
+	if err != nil {
+		return checkResult{
+			name:    "Services",
+			passed:  false,
+			message: fmt.Sprintf("failed to determine config directory: %v", err),
+		}
+	}
+	opts, _, err := serviceOptions(manager, dataDir, configDir, false)
+	if err != nil {
+		return checkResult{
+			name:    "Services",
+			passed:  false,
+			message: err.Error(),
+		}
+	}
+
+	services := service.HaloyServices(opts)
+	var missing, outdated []string
+	for _, s := range services {
+		switch manager.State(s) {
+		case service.StateMissing:
+			missing = append(missing, s.Name)
+		case service.StateOutdated:
+			outdated = append(outdated, s.Name)
+		}
+	}
+	if len(missing) == len(services) {
+		// Installed without a service manager, e.g. in a container.
+		return checkResult{
+			name:    "Services",
+			passed:  true,
+			message: fmt.Sprintf("not installed as %s services", manager.Name()),
+		}
+	}
+	if len(missing) == 0 && len(outdated) == 0 {
+		return checkResult{
+			name:    "Services",
+			passed:  true,
+			message: fmt.Sprintf("%s services are current", manager.Name()),
+		}
+	}
+
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "missing "+strings.Join(missing, ", "))
+	}
+	if len(outdated) > 0 {
+		problems = append(problems, "outdated "+strings.Join(outdated, ", "))
+	}
+	return checkResult{
+		name:    "Services",
+		passed:  false,
+		message: fmt.Sprintf("%s services %s", manager.Name(), strings.Join(problems, "; ")),
+		fix: &checkFix{
+			description: fmt.Sprintf("reinstall and restart the %s services", manager.Name()),
+			apply: func(ctx context.Context) error {
+				_, err := installServices(ctx, dataDir, configDir, false)
+				return err
+			},
+		},
+	}
+}
+
 const apiHealthCheckTimeout = 2 * time.Second
 
-func checkAPIHealth() checkResult {
+func checkAPIHealth(ctx context.Context) checkResult {
 	client, req, err := apiHealthCheckRequest()
 	if err != nil {
 		return checkResult{
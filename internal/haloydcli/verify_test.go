@@ -1,10 +1,21 @@
 package haloydcli
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
 	"net/http"
+	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/docker/docker/api/types/container"
 	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
 )
@@ -103,3 +114,97 @@ func TestAPIHealthCheckRequestTreatsLocalDomainsAsLocalhost(t *testing.T) {
 		})
 	}
 }
+
+func writeTestCertificate(t *testing.T, path, issuer string, notAfter time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: issuer},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	if err := os.WriteFile(path, data, constants.ModeFileSecret); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpiredStagingCertificates(t *testing.T) {
+	certDir := t.TempDir()
+	now := time.Now()
+	writeTestCertificate(t, filepath.Join(certDir, "old.example.com.pem"), "(STAGING) Counterfeit Cashew R10", now.Add(-time.Hour))
+	writeTestCertificate(t, filepath.Join(certDir, "new.example.com.pem"), "(STAGING) Counterfeit Cashew R10", now.Add(time.Hour))
+	writeTestCertificate(t, filepath.Join(certDir, "prod.example.com.pem"), "R10", now.Add(-time.Hour))
+
+	expired, err := expiredStagingCertificates(certDir, now)
+	if err != nil {
+		t.Fatalf("expiredStagingCertificates() error = %v", err)
+	}
+	if !slices.Equal(expired, []string{"old.example.com"}) {
+		t.Errorf("expiredStagingCertificates() = %v, want [old.example.com]", expired)
+	}
+
+	expired, err = expiredStagingCertificates(filepath.Join(certDir, "missing"), now)
+	if err != nil || len(expired) != 0 {
+		t.Errorf("expiredStagingCertificates() on a missing directory = %v, %v", expired, err)
+	}
+}
+
+func TestRepairCertStorage(t *testing.T) {
+	certDir := filepath.Join(t.TempDir(), constants.CertStorageDir)
+	if err := os.Mkdir(certDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(certDir, "example.com.pem"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := certStorageProblems(certDir, -1)
+	if err != nil {
+		t.Fatalf("certStorageProblems() error = %v", err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("certStorageProblems() = %v, want the directory and the file", problems)
+	}
+
+	if err := repairCertStorage(certDir); err != nil {
+		t.Fatalf("repairCertStorage() error = %v", err)
+	}
+	problems, err = certStorageProblems(certDir, os.Getuid())
+	if err != nil {
+		t.Fatalf("certStorageProblems() error = %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("certStorageProblems() after repair = %v", problems)
+	}
+}
+
+func TestHardeningGaps(t *testing.T) {
+	hardened := &container.HostConfig{
+		ReadonlyRootfs: true,
+		SecurityOpt:    []string{"no-new-privileges:true"},
+		CapDrop:        []string{"ALL"},
+		CapAdd:         []string{"NET_BIND_SERVICE"},
+	}
+	if gaps := hardeningGaps(hardened); len(gaps) != 0 {
+		t.Errorf("hardeningGaps() = %v for a hardened container, want none", gaps)
+	}
+
+	want := []string{"writable root filesystem", "no no-new-privileges", "capabilities not dropped"}
+	if gaps := hardeningGaps(&container.HostConfig{CapDrop: []string{"NET_RAW"}}); !slices.Equal(gaps, want) {
+		t.Errorf("hardeningGaps() = %v, want %v", gaps, want)
+	}
+}
@@ -0,0 +1,574 @@
+package haloydcli
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+const (
+	wgDefaultInterface = "haloy0"
+	wgDefaultAddress   = "10.99.0.1/24"
+	wgDefaultPort      = 51820
+	wgDefaultDir       = "/etc/wireguard"
+	wgClientKeepalive  = 25
+)
+
+func wgCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "wg",
+		Short: "Manage a WireGuard network between servers and operators",
+		Long: `Commands to set up a private WireGuard network for haloy.
+
+Run "haloyd wg init" on every server, then add the other servers and your own
+machine with "haloyd wg peer add". Traffic that shouldn't be on a public
+interface can then use the tunnel addresses:
+  - api.allowed_networks in haloyd.yaml limits the API domain to the network
+  - cluster.listen and cluster.peers use tunnel addresses for routing between servers
+  - haloy deploys, uploads and tunnels use the network when the API domain
+    resolves to the server's tunnel address on the operator's machine
+
+The interface is managed with wg-quick, which must be installed.`,
+	}
+
+	cmd.PersistentFlags().String("interface", wgDefaultInterface, "WireGuard interface name")
+	cmd.PersistentFlags().String("dir", wgDefaultDir, "Directory holding the WireGuard config")
+
+	cmd.AddCommand(
+		wgInitCmd(),
+		wgPeerCmd(),
+	)
+
+	return cmd
+}
+
+func wgInitCmd() *cobra.Command {
+	var address string
+	var listenPort int
+	var force bool
+	var noStart bool
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create this server's WireGuard interface",
+		Long: `Generate a key pair and write the WireGuard config for this server.
+
+Give every server its own --address in the same network, such as 10.99.0.1/24,
+10.99.0.2/24 and so on. Unless --no-start is set, the interface is brought up
+and enabled at boot.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			iface, path := wgConfigPath(cmd)
+
+			prefix, err := netip.ParsePrefix(address)
+			if err != nil {
+				return fmt.Errorf("invalid --address '%s': must be a CIDR such as %s", address, wgDefaultAddress)
+			}
+			if prefix.Addr() == prefix.Masked().Addr() {
+				return fmt.Errorf("invalid --address '%s': must be this server's address in the network, not the network itself", address)
+			}
+			if _, err := os.Stat(path); err == nil && !force {
+				return fmt.Errorf("%s already exists, use --force to replace it", path)
+			}
+
+			privateKey, publicKey, err := generateWGKeyPair()
+			if err != nil {
+				return err
+			}
+			wg := wgConfig{Address: prefix.String(), ListenPort: listenPort, PrivateKey: privateKey}
+
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+			}
+			if err := os.WriteFile(path, []byte(wg.render()), 0o600); err != nil {
+				return fmt.Errorf("failed to write WireGuard config: %w", err)
+			}
+			ui.Success("Wrote %s", path)
+
+			if !noStart {
+				if err := startWGInterface(iface); err != nil {
+					ui.Warn("Failed to start %s: %v", iface, err)
+					ui.Info("Start it with: wg-quick up %s", iface)
+				} else {
+					ui.Success("Interface %s is up", iface)
+				}
+			}
+
+			ui.Basic("")
+			ui.Info("Public key: %s", publicKey)
+			ui.Info("Add this server to the others with:")
+			ui.Basic("  haloyd wg peer add <name> --public-key %s --address %s --endpoint <public-ip>:%d",
+				publicKey, prefix.Addr(), listenPort)
+			ui.Info("To only serve the API over the network, set in haloyd.yaml:")
+			ui.Basic("  api:\n    allowed_networks: [%s]", prefix.Masked())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&address, "address", wgDefaultAddress, "This server's address and network, in CIDR notation")
+	cmd.Flags().IntVar(&listenPort, "listen-port", wgDefaultPort, "UDP port WireGuard listens on")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace an existing config, generating a new key pair")
+	cmd.Flags().BoolVar(&noStart, "no-start", false, "Only write the config, don't bring the interface up")
+	return cmd
+}
+
+func wgPeerCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "peer",
+		Short: "Manage WireGuard peers",
+	}
+
+	cmd.AddCommand(
+		wgPeerAddCmd(),
+		wgPeerListCmd(),
+	)
+
+	return cmd
+}
+
+func wgPeerAddCmd() *cobra.Command {
+	var publicKey string
+	var address string
+	var endpoint string
+	var serverEndpoint string
+	var keepalive int
+
+	cmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Add a server or operator to the network",
+		Long: `Add a peer to this server's WireGuard interface.
+
+For another server, pass its --public-key, --address and --endpoint as printed
+by "haloyd wg init" on it. For an operator's machine, leave out --public-key:
+a key pair is generated and a config for the machine is printed, to import
+into the WireGuard client. Pass --server-endpoint so it knows how to reach
+this server.
+
+Without --address, the next free address in the network is used.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			iface, path := wgConfigPath(cmd)
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return fmt.Errorf("%s not found, run 'haloyd wg init' first", path)
+				}
+				return fmt.Errorf("failed to read WireGuard config: %w", err)
+			}
+			wg, err := parseWGConfig(data)
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			if endpoint != "" {
+				if _, _, err := net.SplitHostPort(endpoint); err != nil {
+					return fmt.Errorf("invalid --endpoint '%s': must be host:port", endpoint)
+				}
+			}
+
+			var peerAddr netip.Addr
+			if address != "" {
+				if peerAddr, err = netip.ParseAddr(address); err != nil {
+					return fmt.Errorf("invalid --address '%s': must be an IP address", address)
+				}
+			} else if peerAddr, err = wg.nextFreeAddr(); err != nil {
+				return err
+			}
+
+			var clientPrivateKey string
+			if publicKey == "" {
+				if clientPrivateKey, publicKey, err = generateWGKeyPair(); err != nil {
+					return err
+				}
+			} else if err := validateWGKey(publicKey); err != nil {
+				return fmt.Errorf("invalid --public-key: %w", err)
+			}
+
+			peer := wgPeer{
+				Name:       name,
+				PublicKey:  publicKey,
+				AllowedIPs: netip.PrefixFrom(peerAddr, peerAddr.BitLen()).String(),
+				Endpoint:   endpoint,
+				Keepalive:  keepalive,
+			}
+			if err := wg.canAdd(peer, peerAddr); err != nil {
+				return err
+			}
+
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+			if err != nil {
+				return fmt.Errorf("failed to open WireGuard config: %w", err)
+			}
+			_, err = f.WriteString("\n" + peer.render())
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write WireGuard config: %w", err)
+			}
+			ui.Success("Added peer '%s' with address %s", name, peerAddr)
+
+			if err := applyWGPeer(iface, peer); err != nil {
+				ui.Warn("Failed to add the peer to the running interface: %v", err)
+				ui.Info("Restart the interface with: wg-quick down %s && wg-quick up %s", iface, iface)
+			}
+
+			if clientPrivateKey != "" {
+				client, err := wg.clientConfig(clientPrivateKey, peerAddr, serverEndpoint)
+				if err != nil {
+					return err
+				}
+				ui.Basic("")
+				ui.Info("WireGuard config for '%s' (holds its private key, keep it safe):", name)
+				ui.Basic("%s", client)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&publicKey, "public-key", "", "The peer's public key (default: generate a key pair for it)")
+	cmd.Flags().StringVar(&address, "address", "", "The peer's address in the network (default: next free address)")
+	cmd.Flags().StringVar(&endpoint, "endpoint", "", "The peer's public host:port, for servers")
+	cmd.Flags().StringVar(&serverEndpoint, "server-endpoint", "", "This server's public host:port, written to a generated config")
+	cmd.Flags().IntVar(&keepalive, "keepalive", 0, "Persistent keepalive interval in seconds (0 disables)")
+	return cmd
+}
+
+func wgPeerListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the peers of this server's network",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, path := wgConfigPath(cmd)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read WireGuard config: %w", err)
+			}
+			wg, err := parseWGConfig(data)
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			if len(wg.Peers) == 0 {
+				ui.Info("No peers, add one with 'haloyd wg peer add'")
+				return nil
+			}
+			rows := make([][]string, 0, len(wg.Peers))
+			for _, peer := range wg.Peers {
+				rows = append(rows, []string{peer.Name, peer.AllowedIPs, peer.Endpoint, peer.PublicKey})
+			}
+			ui.Table([]string{"NAME", "ADDRESS", "ENDPOINT", "PUBLIC KEY"}, rows)
+			return nil
+		},
+	}
+}
+
+// wgConfigPath returns the interface name and the path of its wg-quick config.
+func wgConfigPath(cmd *cobra.Command) (string, string) {
+	iface, _ := cmd.Flags().GetString("interface")
+	dir, _ := cmd.Flags().GetString("dir")
+	return iface, filepath.Join(dir, iface+".conf")
+}
+
+// wgConfig is the part of a wg-quick config haloyd manages.
+type wgConfig struct {
+	Address    string
+	ListenPort int
+	PrivateKey string
+	Peers      []wgPeer
+}
+
+// wgPeer is a [Peer] section. Name is kept in a comment, as WireGuard has no
+// field for it.
+type wgPeer struct {
+	Name       string
+	PublicKey  string
+	AllowedIPs string
+	Endpoint   string
+	Keepalive  int
+}
+
+func (c wgConfig) render() string {
+	var b strings.Builder
+	b.WriteString("# Managed by haloyd wg\n[Interface]\n")
+	fmt.Fprintf(&b, "Address = %s\n", c.Address)
+	if c.ListenPort > 0 {
+		fmt.Fprintf(&b, "ListenPort = %d\n", c.ListenPort)
+	}
+	fmt.Fprintf(&b, "PrivateKey = %s\n", c.PrivateKey)
+	for _, peer := range c.Peers {
+		b.WriteString("\n" + peer.render())
+	}
+	return b.String()
+}
+
+func (p wgPeer) render() string {
+	var b strings.Builder
+	b.WriteString("[Peer]\n")
+	if p.Name != "" {
+		fmt.Fprintf(&b, "# Name = %s\n", p.Name)
+	}
+	fmt.Fprintf(&b, "PublicKey = %s\n", p.PublicKey)
+	fmt.Fprintf(&b, "AllowedIPs = %s\n", p.AllowedIPs)
+	if p.Endpoint != "" {
+		fmt.Fprintf(&b, "Endpoint = %s\n", p.Endpoint)
+	}
+	if p.Keepalive > 0 {
+		fmt.Fprintf(&b, "PersistentKeepalive = %d\n", p.Keepalive)
+	}
+	return b.String()
+}
+
+// parseWGConfig reads the interface and peers of a wg-quick config. Keys
+// haloyd doesn't manage are skipped, and left in the file as they are.
+func parseWGConfig(data []byte) (wgConfig, error) {
+	var c wgConfig
+	var peer *wgPeer
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			if section == "peer" {
+				c.Peers = append(c.Peers, wgPeer{})
+				peer = &c.Peers[len(c.Peers)-1]
+			}
+			continue
+		}
+		comment := strings.HasPrefix(line, "#")
+		key, value, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "#")), "=")
+		if !ok {
+			if comment {
+				continue
+			}
+			return wgConfig{}, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if comment {
+			if section == "peer" && key == "Name" {
+				peer.Name = value
+			}
+			continue
+		}
+
+		switch section {
+		case "interface":
+			switch key {
+			case "Address":
+				c.Address = value
+			case "ListenPort":
+				port, err := strconv.Atoi(value)
+				if err != nil {
+					return wgConfig{}, fmt.Errorf("line %d: invalid ListenPort '%s'", n, value)
+				}
+				c.ListenPort = port
+			case "PrivateKey":
+				c.PrivateKey = value
+			}
+		case "peer":
+			switch key {
+			case "PublicKey":
+				peer.PublicKey = value
+			case "AllowedIPs":
+				peer.AllowedIPs = value
+			case "Endpoint":
+				peer.Endpoint = value
+			case "PersistentKeepalive":
+				keepalive, err := strconv.Atoi(value)
+				if err != nil {
+					return wgConfig{}, fmt.Errorf("line %d: invalid PersistentKeepalive '%s'", n, value)
+				}
+				peer.Keepalive = keepalive
+			}
+		default:
+			return wgConfig{}, fmt.Errorf("line %d: key outside of a section", n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return wgConfig{}, err
+	}
+	if c.Address == "" || c.PrivateKey == "" {
+		return wgConfig{}, errors.New("missing Address or PrivateKey in [Interface]")
+	}
+	return c, nil
+}
+
+// network returns the interface's address and network.
+func (c wgConfig) network() (netip.Prefix, error) {
+	// wg-quick allows a comma-separated list; the first address is ours.
+	first, _, _ := strings.Cut(c.Address, ",")
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(first))
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid interface Address '%s'", c.Address)
+	}
+	return prefix, nil
+}
+
+// usedAddrs returns the addresses of the interface and its peers.
+func (c wgConfig) usedAddrs() map[netip.Addr]string {
+	used := make(map[netip.Addr]string)
+	if prefix, err := c.network(); err == nil {
+		used[prefix.Addr()] = "this server"
+	}
+	for _, peer := range c.Peers {
+		for allowed := range strings.SplitSeq(peer.AllowedIPs, ",") {
+			if prefix, err := netip.ParsePrefix(strings.TrimSpace(allowed)); err == nil {
+				used[prefix.Addr()] = peer.Name
+			}
+		}
+	}
+	return used
+}
+
+// nextFreeAddr returns the lowest address in the network no one uses.
+func (c wgConfig) nextFreeAddr() (netip.Addr, error) {
+	prefix, err := c.network()
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	used := c.usedAddrs()
+	network := prefix.Masked()
+	for addr := network.Addr().Next(); network.Contains(addr); addr = addr.Next() {
+		if _, taken := used[addr]; taken {
+			continue
+		}
+		// Skip the IPv4 broadcast address.
+		if next := addr.Next(); addr.Is4() && !network.Contains(next) {
+			break
+		}
+		return addr, nil
+	}
+	return netip.Addr{}, fmt.Errorf("no free address left in %s", network)
+}
+
+// canAdd reports why peer can't join the network, if it can't.
+func (c wgConfig) canAdd(peer wgPeer, addr netip.Addr) error {
+	prefix, err := c.network()
+	if err != nil {
+		return err
+	}
+	if !prefix.Masked().Contains(addr) {
+		return fmt.Errorf("address %s is outside the network %s", addr, prefix.Masked())
+	}
+	if owner, taken := c.usedAddrs()[addr]; taken {
+		return fmt.Errorf("address %s is already used by %s", addr, owner)
+	}
+	for _, existing := range c.Peers {
+		if existing.Name == peer.Name {
+			return fmt.Errorf("a peer named '%s' already exists", peer.Name)
+		}
+		if existing.PublicKey == peer.PublicKey {
+			return fmt.Errorf("public key is already used by peer '%s'", existing.Name)
+		}
+	}
+	return nil
+}
+
+// clientConfig returns a wg-quick config for a peer with privateKey that
+// reaches the whole network through this server.
+func (c wgConfig) clientConfig(privateKey string, addr netip.Addr, serverEndpoint string) (string, error) {
+	prefix, err := c.network()
+	if err != nil {
+		return "", err
+	}
+	serverKey, err := wgPublicKey(c.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid interface PrivateKey: %w", err)
+	}
+	if serverEndpoint == "" {
+		serverEndpoint = fmt.Sprintf("<this-server>:%d", c.ListenPort)
+	}
+	client := wgConfig{Address: netip.PrefixFrom(addr, prefix.Bits()).String(), PrivateKey: privateKey}
+	client.Peers = []wgPeer{{
+		PublicKey:  serverKey,
+		AllowedIPs: prefix.Masked().String(),
+		Endpoint:   serverEndpoint,
+		Keepalive:  wgClientKeepalive,
+	}}
+	return strings.TrimPrefix(client.render(), "# Managed by haloyd wg\n"), nil
+}
+
+// generateWGKeyPair returns a new base64 encoded WireGuard key pair.
+func generateWGKeyPair() (privateKey, publicKey string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key pair: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key.Bytes()),
+		base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// wgPublicKey derives the public key of a base64 encoded private key.
+func wgPublicKey(privateKey string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil {
+		return "", err
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// validateWGKey checks that key is a base64 encoded 32 byte key.
+func validateWGKey(key string) error {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return errors.New("must be a base64 encoded 32 byte key")
+	}
+	return nil
+}
+
+// startWGInterface brings the interface up and, under systemd, enables it
+// at boot.
+func startWGInterface(iface string) error {
+	if out, err := exec.Command("wg-quick", "up", iface).CombinedOutput(); err != nil {
+		return fmt.Errorf("wg-quick up: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	if helpers.DetectInitSystem() == helpers.InitSystemd {
+		if out, err := exec.Command("systemctl", "enable", "wg-quick@"+iface).CombinedOutput(); err != nil {
+			return fmt.Errorf("systemctl enable: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// applyWGPeer adds peer to the running interface, so it needn't restart.
+// An interface that isn't up is left alone; it reads the peer when started.
+func applyWGPeer(iface string, peer wgPeer) error {
+	if _, err := net.InterfaceByName(iface); err != nil {
+		return nil
+	}
+	args := []string{"set", iface, "peer", peer.PublicKey, "allowed-ips", peer.AllowedIPs}
+	if peer.Endpoint != "" {
+		args = append(args, "endpoint", peer.Endpoint)
+	}
+	if peer.Keepalive > 0 {
+		args = append(args, "persistent-keepalive", strconv.Itoa(peer.Keepalive))
+	}
+	if out, err := exec.Command("wg", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("wg set: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package haloydcli
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestWGConfigRoundTrip(t *testing.T) {
+	privateKey, _, err := generateWGKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, peerKey, err := generateWGKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := wgConfig{
+		Address:    "10.99.0.1/24",
+		ListenPort: 51820,
+		PrivateKey: privateKey,
+		Peers: []wgPeer{
+			{Name: "web-2", PublicKey: peerKey, AllowedIPs: "10.99.0.2/32", Endpoint: "203.0.113.2:51820", Keepalive: 25},
+		},
+	}
+
+	// Keys haloyd doesn't manage are skipped.
+	data := want.render() + "PresharedKey = abc\n"
+	got, err := parseWGConfig([]byte(data))
+	if err != nil {
+		t.Fatalf("parseWGConfig() error = %v", err)
+	}
+	if got.Address != want.Address || got.ListenPort != want.ListenPort || got.PrivateKey != want.PrivateKey {
+		t.Errorf("interface = %+v, want %+v", got, want)
+	}
+	if len(got.Peers) != 1 || got.Peers[0] != want.Peers[0] {
+		t.Errorf("peers = %+v, want %+v", got.Peers, want.Peers)
+	}
+
+	if _, err := parseWGConfig([]byte("[Interface]\nListenPort = 51820\n")); err == nil {
+		t.Error("parseWGConfig() accepted a config without Address and PrivateKey")
+	}
+}
+
+func TestWGConfigNextFreeAddr(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  wgConfig
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "first address after the server",
+			config: wgConfig{Address: "10.99.0.1/24"},
+			want:   "10.99.0.2",
+		},
+		{
+			name: "skips used addresses",
+			config: wgConfig{Address: "10.99.0.1/24", Peers: []wgPeer{
+				{AllowedIPs: "10.99.0.2/32"},
+				{AllowedIPs: "10.99.0.3/32, 192.168.1.0/24"},
+			}},
+			want: "10.99.0.4",
+		},
+		{
+			name:   "server not on the first address",
+			config: wgConfig{Address: "10.99.0.5/24"},
+			want:   "10.99.0.1",
+		},
+		{
+			name:    "full network",
+			config:  wgConfig{Address: "10.99.0.1/30", Peers: []wgPeer{{AllowedIPs: "10.99.0.2/32"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.nextFreeAddr()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("nextFreeAddr() = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("nextFreeAddr() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("nextFreeAddr() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWGConfigCanAdd(t *testing.T) {
+	c := wgConfig{Address: "10.99.0.1/24", Peers: []wgPeer{
+		{Name: "laptop", PublicKey: "key-a", AllowedIPs: "10.99.0.2/32"},
+	}}
+	tests := []struct {
+		name    string
+		peer    wgPeer
+		addr    string
+		wantErr string
+	}{
+		{"new peer", wgPeer{Name: "web-2", PublicKey: "key-b"}, "10.99.0.3", ""},
+		{"outside the network", wgPeer{Name: "web-2", PublicKey: "key-b"}, "10.98.0.3", "outside the network"},
+		{"address of the server", wgPeer{Name: "web-2", PublicKey: "key-b"}, "10.99.0.1", "already used by this server"},
+		{"address of a peer", wgPeer{Name: "web-2", PublicKey: "key-b"}, "10.99.0.2", "already used by laptop"},
+		{"duplicate name", wgPeer{Name: "laptop", PublicKey: "key-b"}, "10.99.0.3", "already exists"},
+		{"duplicate key", wgPeer{Name: "web-2", PublicKey: "key-a"}, "10.99.0.3", "already used by peer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.canAdd(tt.peer, netip.MustParseAddr(tt.addr))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("canAdd() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("canAdd() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWGClientConfig(t *testing.T) {
+	serverKey, serverPublic, err := generateWGKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKey, _, err := generateWGKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := wgConfig{Address: "10.99.0.1/24", ListenPort: 51820, PrivateKey: serverKey}
+
+	out, err := server.clientConfig(clientKey, netip.MustParseAddr("10.99.0.7"), "203.0.113.1:51820")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := parseWGConfig([]byte(out))
+	if err != nil {
+		t.Fatalf("client config doesn't parse: %v\n%s", err, out)
+	}
+	if client.Address != "10.99.0.7/24" || client.PrivateKey != clientKey {
+		t.Errorf("client interface = %+v, want 10.99.0.7/24 with the client's key", client)
+	}
+	want := wgPeer{PublicKey: serverPublic, AllowedIPs: "10.99.0.0/24", Endpoint: "203.0.113.1:51820", Keepalive: wgClientKeepalive}
+	if len(client.Peers) != 1 || client.Peers[0] != want {
+		t.Errorf("client peers = %+v, want %+v", client.Peers, want)
+	}
+}
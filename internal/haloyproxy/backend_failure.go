@@ -0,0 +1,29 @@
+package haloyproxy
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// newBackendFailureReporter returns the proxy's backend failure handler. It
+// reports each failing backend to haloyd's certificate HTTP provider in the
+// background, where the health monitor checks it right away.
+func newBackendFailureReporter(logger *slog.Logger) func(addr string) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	endpoint := "http://127.0.0.1:" + constants.CertificatesHTTPProviderPort + constants.BackendFailurePath
+
+	return func(addr string) {
+		go func() {
+			resp, err := client.PostForm(endpoint, url.Values{"backend": {addr}})
+			if err != nil {
+				logger.Debug("Failed to report failing backend", "backend", addr, "error", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+}
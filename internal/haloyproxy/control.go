@@ -36,6 +36,7 @@ type controlServer struct {
 	loadedFrom   string // "socket" | "snapshot-file" | "empty"
 	lastUpdateAt time.Time
 	routeCount   int
+	snapshot     *proxywire.Snapshot
 }
 
 func newControlServer(p *proxy.Proxy, certManager *proxy.CertManager, logger *slog.Logger) *controlServer {
@@ -48,6 +49,7 @@ func newControlServer(p *proxy.Proxy, certManager *proxy.CertManager, logger *sl
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("PUT /v1/config", c.handleConfig)
+	mux.HandleFunc("GET /v1/config", c.handleGetConfig)
 	mux.HandleFunc("POST /v1/certs/reload", c.handleCertsReload)
 	mux.HandleFunc("GET /v1/status", c.handleStatus)
 
@@ -68,6 +70,7 @@ func (c *controlServer) recordApplied(snap *proxywire.Snapshot, loadedFrom strin
 	c.loadedFrom = loadedFrom
 	c.lastUpdateAt = time.Now()
 	c.routeCount = len(snap.Routes)
+	c.snapshot = snap
 }
 
 // Start binds the unix socket and serves the control API. A stale socket file
@@ -132,12 +135,26 @@ func (c *controlServer) handleConfig(w http.ResponseWriter, r *http.Request) {
 	c.loadedFrom = "socket"
 	c.lastUpdateAt = time.Now()
 	c.routeCount = len(snap.Routes)
+	c.snapshot = &snap
 	hash := c.configHash
 	c.mu.Unlock()
 
 	writeJSON(w, http.StatusOK, map[string]string{"config_hash": hash})
 }
 
+// handleGetConfig returns the snapshot the proxy currently routes by, or an
+// empty one before any config was applied.
+func (c *controlServer) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	snap := c.snapshot
+	c.mu.Unlock()
+
+	if snap == nil {
+		snap = &proxywire.Snapshot{SchemaVersion: proxywire.SchemaVersion, Routes: []proxywire.Route{}}
+	}
+	writeJSON(w, http.StatusOK, snap)
+}
+
 func (c *controlServer) handleCertsReload(w http.ResponseWriter, r *http.Request) {
 	if err := c.certManager.ReloadCertificates(); err != nil {
 		writeJSONError(w, http.StatusInternalServerError, err.Error())
@@ -160,6 +177,32 @@ func (c *controlServer) handleStatus(w http.ResponseWriter, r *http.Request) {
 	}
 	c.mu.Unlock()
 
+	for _, b := range c.proxy.BackendStats() {
+		status.Backends = append(status.Backends, proxywire.BackendStatus{
+			Address:      b.Address,
+			InFlight:     b.InFlight,
+			Requests:     b.Requests,
+			Errors:       b.Errors,
+			ErrorRate:    b.ErrorRate,
+			P95LatencyMS: b.P95Latency.Milliseconds(),
+			Flagged:      b.Flagged,
+			Ejected:      b.Ejected,
+
+			LatencyBuckets: b.LatencyBuckets,
+			RecentFailures: b.RecentFailures,
+		})
+	}
+	for _, l := range c.proxy.ListenerStates() {
+		status.Listeners = append(status.Listeners, proxywire.ListenerStatus{
+			Address:   l.Addr,
+			Protocol:  l.Protocol,
+			Serving:   l.Serving,
+			Restarts:  l.Restarts,
+			LastError: l.LastError,
+			DownSince: l.DownSince,
+		})
+	}
+
 	writeJSON(w, http.StatusOK, status)
 }
 
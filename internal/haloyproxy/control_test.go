@@ -137,6 +137,48 @@ func TestControlAPI_ConfigPushAndStatus(t *testing.T) {
 	}
 }
 
+func getConfig(t *testing.T, httpc *http.Client) proxywire.Snapshot {
+	t.Helper()
+	resp, err := httpc.Get("http://proxy/v1/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("config endpoint returned %d", resp.StatusCode)
+	}
+	var snap proxywire.Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		t.Fatal(err)
+	}
+	return snap
+}
+
+func TestControlAPI_GetConfig(t *testing.T) {
+	_, _, httpc := newTestControl(t)
+
+	if snap := getConfig(t, httpc); len(snap.Routes) != 0 {
+		t.Fatalf("initial config has %d routes, want none", len(snap.Routes))
+	}
+
+	snap := &proxywire.Snapshot{
+		SchemaVersion: proxywire.SchemaVersion,
+		Routes: []proxywire.Route{
+			{Canonical: "app.example.com", App: "app", Backends: []proxywire.Backend{{IP: "10.0.0.2", Port: "8080"}}},
+		},
+	}
+	resp := putConfig(t, httpc, snap)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("config push returned %d", resp.StatusCode)
+	}
+
+	got := getConfig(t, httpc)
+	if got.Hash() != snap.Hash() {
+		t.Fatalf("GET /v1/config = %+v, want the pushed snapshot", got)
+	}
+}
+
 func TestControlAPI_RejectsNewerSchema(t *testing.T) {
 	_, proxyServer, httpc := newTestControl(t)
 
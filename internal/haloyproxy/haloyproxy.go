@@ -17,9 +17,11 @@ import (
 
 	"github.com/haloydev/haloy/internal/config"
 	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/errorpages"
 	"github.com/haloydev/haloy/internal/logging"
 	"github.com/haloydev/haloy/internal/proxy"
 	"github.com/haloydev/haloy/internal/proxywire"
+	"github.com/haloydev/haloy/internal/sdnotify"
 )
 
 const shutdownTimeout = 30 * time.Second
@@ -49,7 +51,15 @@ func Run(debug bool) error {
 		return fmt.Errorf("create certificate manager: %w", err)
 	}
 
+	certManager.SetOnDemandHandler(newOnDemandReporter(logger))
+
+	ocspCtx, stopOCSP := context.WithCancel(context.Background())
+	defer stopOCSP()
+	certManager.StartOCSPStapling(ocspCtx)
+
 	proxyServer := proxy.New(logger, certManager)
+	proxyServer.SetErrorPages(errorpages.NewStore(filepath.Join(dataDir, constants.ErrorPagesDir)))
+	proxyServer.SetBackendFailureHandler(newBackendFailureReporter(logger))
 	control := newControlServer(proxyServer, certManager, logger)
 
 	// Boot from the last snapshot haloyd wrote, if any. A missing or broken
@@ -75,10 +85,11 @@ func Run(debug bool) error {
 			"age", time.Since(snap.GeneratedAt).Round(time.Second).String())
 	}
 
-	if err := proxyServer.Start(":80", ":443"); err != nil {
+	httpAddr, httpsAddr := config.ProxyListenAddrs()
+	if err := proxyServer.Start(httpAddr, httpsAddr); err != nil {
 		return fmt.Errorf("start proxy: %w", err)
 	}
-	logger.Info("Proxy started", "http", ":80", "https", ":443")
+	logger.Info("Proxy started", "http", httpAddr, "https", httpsAddr)
 
 	socketPath := filepath.Join(proxyDir, constants.ProxySocketFileName)
 	if err := control.Start(socketPath); err != nil {
@@ -91,14 +102,29 @@ func Run(debug bool) error {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	watchdog, stopWatchdog := sdnotify.WatchdogTicks()
+	defer stopWatchdog()
+	if _, err := sdnotify.Notify(sdnotify.Ready); err != nil {
+		logger.Warn("Failed to notify systemd", "error", err)
+	}
+
 	var runErr error
-	select {
-	case sig := <-sigChan:
-		logger.Info("Received shutdown signal", "signal", sig.String())
-	case err := <-proxyServer.Err():
-		logger.Error("Proxy listener failed", "error", err)
-		runErr = err
+wait:
+	for {
+		select {
+		case sig := <-sigChan:
+			logger.Info("Received shutdown signal", "signal", sig.String())
+			break wait
+		case err := <-proxyServer.Err():
+			// Dead listeners are rebound first; this is one that couldn't be.
+			logger.Error("Proxy listener failed", "error", err)
+			runErr = err
+			break wait
+		case <-watchdog:
+			sdnotify.Notify(sdnotify.Watchdog)
+		}
 	}
+	sdnotify.Notify(sdnotify.Stopping)
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
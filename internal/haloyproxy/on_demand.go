@@ -0,0 +1,29 @@
+package haloyproxy
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// newOnDemandReporter returns the proxy's on-demand TLS handler. It reports
+// each unknown domain to haloyd's certificate HTTP provider in the
+// background; haloyd decides whether to issue a certificate for it.
+func newOnDemandReporter(logger *slog.Logger) func(domain string) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	endpoint := "http://127.0.0.1:" + constants.CertificatesHTTPProviderPort + constants.OnDemandTLSPath
+
+	return func(domain string) {
+		go func() {
+			resp, err := client.PostForm(endpoint, url.Values{"domain": {domain}})
+			if err != nil {
+				logger.Debug("Failed to report unknown domain for on-demand TLS", "domain", domain, "error", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+}
@@ -2,21 +2,33 @@ package healthcheck
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
 
-// HTTPChecker performs HTTP health checks on targets.
-type HTTPChecker struct {
-	client *http.Client
+// ExecFunc runs cmd in a container and returns its exit code.
+type ExecFunc func(ctx context.Context, containerID string, cmd []string) (exitCode int, output string, err error)
+
+// Checker performs health checks on targets, of the type each target asks for.
+type Checker struct {
+	client  *http.Client
+	dialer  *net.Dialer
+	exec    ExecFunc
+	timeout time.Duration
 }
 
-// NewHTTPChecker creates a new HTTP health checker with the given timeout.
-func NewHTTPChecker(timeout time.Duration) *HTTPChecker {
-	return &HTTPChecker{
+// NewChecker creates a new health checker with the given per-check timeout.
+// exec runs the commands of cmd checks; without it they always fail.
+func NewChecker(timeout time.Duration, exec ExecFunc) *Checker {
+	return &Checker{
+		dialer:  &net.Dialer{Timeout: timeout},
+		exec:    exec,
+		timeout: timeout,
 		client: &http.Client{
 			Timeout: timeout,
 			Transport: &http.Transport{
@@ -38,8 +50,20 @@ func NewHTTPChecker(timeout time.Duration) *HTTPChecker {
 }
 
 // Check performs a health check on the given target.
-// A target is considered healthy if the HTTP request succeeds with a 2xx or 3xx status code.
-func (c *HTTPChecker) Check(ctx context.Context, target Target) Result {
+func (c *Checker) Check(ctx context.Context, target Target) Result {
+	switch target.Type {
+	case CheckTCP:
+		return c.checkTCP(ctx, target)
+	case CheckCmd:
+		return c.checkCmd(ctx, target)
+	default:
+		return c.checkHTTP(ctx, target)
+	}
+}
+
+// checkHTTP considers a target healthy if the HTTP request succeeds with a
+// 2xx or 3xx status code.
+func (c *Checker) checkHTTP(ctx context.Context, target Target) Result {
 	start := time.Now()
 
 	url := fmt.Sprintf("http://%s:%s%s", target.IP, target.Port, target.HealthCheckPath)
@@ -82,6 +106,56 @@ func (c *HTTPChecker) Check(ctx context.Context, target Target) Result {
 	}
 }
 
+// checkTCP considers a target healthy if its port accepts a connection.
+func (c *Checker) checkTCP(ctx context.Context, target Target) Result {
+	start := time.Now()
+	conn, err := c.dialer.DialContext(ctx, "tcp", net.JoinHostPort(target.IP, target.Port))
+	latency := time.Since(start)
+	if err != nil {
+		return Result{Target: target, Healthy: false, Err: fmt.Errorf("connect failed: %w", err), Latency: latency}
+	}
+	conn.Close()
+	return Result{Target: target, Healthy: true, Latency: latency}
+}
+
+// checkCmd considers a target healthy if its command exits with code 0
+// within the timeout.
+func (c *Checker) checkCmd(ctx context.Context, target Target) Result {
+	start := time.Now()
+	if c.exec == nil {
+		return Result{Target: target, Healthy: false, Err: errors.New("command health checks are not supported")}
+	}
+	if len(target.Cmd) == 0 {
+		return Result{Target: target, Healthy: false, Err: errors.New("no health check command set")}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	exitCode, output, err := c.exec(ctx, target.ID, target.Cmd)
+	latency := time.Since(start)
+	if err != nil {
+		return Result{Target: target, Healthy: false, Err: fmt.Errorf("command failed: %w", err), Latency: latency}
+	}
+	if exitCode != 0 {
+		err := fmt.Errorf("command exited with code %d", exitCode)
+		if output = strings.TrimSpace(output); output != "" {
+			err = fmt.Errorf("command exited with code %d: %s", exitCode, truncateOutput(output))
+		}
+		return Result{Target: target, Healthy: false, Err: err, Latency: latency}
+	}
+	return Result{Target: target, Healthy: true, Latency: latency}
+}
+
+// maxCmdOutput bounds the command output kept in a failed check's error.
+const maxCmdOutput = 200
+
+func truncateOutput(output string) string {
+	if len(output) <= maxCmdOutput {
+		return output
+	}
+	return output[:maxCmdOutput] + "..."
+}
+
 // RetryConfig holds configuration for retry behavior.
 type RetryConfig struct {
 	MaxRetries     int           // Maximum number of retry attempts
@@ -101,7 +175,7 @@ func DefaultRetryConfig() RetryConfig {
 // CheckWithRetry performs a health check with exponential backoff retries.
 // This is used during initial deployment when containers may take time to start.
 // The onRetry callback is called before each retry attempt (can be nil).
-func (c *HTTPChecker) CheckWithRetry(ctx context.Context, target Target, config RetryConfig, onRetry func(attempt int, backoff time.Duration)) Result {
+func (c *Checker) CheckWithRetry(ctx context.Context, target Target, config RetryConfig, onRetry func(attempt int, backoff time.Duration)) Result {
 	var lastResult Result
 	backoff := config.InitialBackoff
 
@@ -144,9 +218,42 @@ func (c *HTTPChecker) CheckWithRetry(ctx context.Context, target Target, config
 	return lastResult
 }
 
+// CheckUntil checks target every period until it passes or deadline has
+// passed. It gives a slow starting app time to come up before its first
+// check must pass. The onRetry callback is called before each retry (can be nil).
+func (c *Checker) CheckUntil(ctx context.Context, target Target, deadline time.Time, period time.Duration, onRetry func(attempt int, remaining time.Duration)) Result {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	var lastResult Result
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if onRetry != nil {
+				onRetry(attempt, time.Until(deadline))
+			}
+			select {
+			case <-ctx.Done():
+				if lastResult.Err == nil {
+					lastResult.Err = ctx.Err()
+				}
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					lastResult.Err = fmt.Errorf("not healthy before the startup timeout after %d attempts: %w", attempt, lastResult.Err)
+				}
+				return lastResult
+			case <-time.After(period):
+			}
+		}
+
+		lastResult = c.Check(ctx, target)
+		if lastResult.Healthy {
+			return lastResult
+		}
+	}
+}
+
 // CheckAll performs health checks on all targets concurrently.
 // It limits concurrency to maxConcurrent to avoid overwhelming the system.
-func (c *HTTPChecker) CheckAll(ctx context.Context, targets []Target, maxConcurrent int) []Result {
+func (c *Checker) CheckAll(ctx context.Context, targets []Target, maxConcurrent int) []Result {
 	if len(targets) == 0 {
 		return nil
 	}
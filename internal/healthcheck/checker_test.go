@@ -3,6 +3,7 @@ package healthcheck
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -11,13 +12,13 @@ import (
 	"time"
 )
 
-func TestNewHTTPChecker(t *testing.T) {
-	checker := NewHTTPChecker(5 * time.Second)
+func TestNewChecker(t *testing.T) {
+	checker := NewChecker(5*time.Second, nil)
 	if checker == nil {
-		t.Fatal("NewHTTPChecker returned nil")
+		t.Fatal("NewChecker returned nil")
 	}
 	if checker.client == nil {
-		t.Error("HTTPChecker.client is nil")
+		t.Error("Checker.client is nil")
 	}
 }
 
@@ -35,7 +36,7 @@ func TestHTTPChecker_Check_Healthy(t *testing.T) {
 	parts := strings.Split(addr, ":")
 	host, port := parts[0], parts[1]
 
-	checker := NewHTTPChecker(5 * time.Second)
+	checker := NewChecker(5*time.Second, nil)
 	target := Target{
 		ID:              "test-container",
 		AppName:         "testapp",
@@ -82,7 +83,7 @@ func TestHTTPChecker_Check_Unhealthy_StatusCode(t *testing.T) {
 			addr := strings.TrimPrefix(server.URL, "http://")
 			parts := strings.Split(addr, ":")
 
-			checker := NewHTTPChecker(5 * time.Second)
+			checker := NewChecker(5*time.Second, nil)
 			target := Target{
 				ID:              "test",
 				IP:              parts[0],
@@ -100,7 +101,7 @@ func TestHTTPChecker_Check_Unhealthy_StatusCode(t *testing.T) {
 }
 
 func TestHTTPChecker_Check_ConnectionRefused(t *testing.T) {
-	checker := NewHTTPChecker(1 * time.Second)
+	checker := NewChecker(1*time.Second, nil)
 	target := Target{
 		ID:              "test",
 		IP:              "127.0.0.1",
@@ -118,6 +119,58 @@ func TestHTTPChecker_Check_ConnectionRefused(t *testing.T) {
 	}
 }
 
+func TestChecker_Check_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	checker := NewChecker(time.Second, nil)
+	target := Target{ID: "test", IP: host, Port: port, Type: CheckTCP}
+
+	if result := checker.Check(context.Background(), target); !result.Healthy {
+		t.Fatalf("Check() unhealthy with a listening port: %v", result.Err)
+	}
+
+	ln.Close()
+	if result := checker.Check(context.Background(), target); result.Healthy || result.Err == nil {
+		t.Fatal("Check() healthy after the listener closed")
+	}
+}
+
+func TestChecker_Check_Cmd(t *testing.T) {
+	var gotID string
+	var gotCmd []string
+	exitCode := 0
+	exec := func(ctx context.Context, containerID string, cmd []string) (int, string, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("exec called without a deadline")
+		}
+		gotID, gotCmd = containerID, cmd
+		return exitCode, "not ready\n", nil
+	}
+	checker := NewChecker(time.Second, exec)
+	target := Target{ID: "abc123", Type: CheckCmd, Cmd: []string{"pg_isready"}}
+
+	result := checker.Check(context.Background(), target)
+	if !result.Healthy {
+		t.Fatalf("Check() unhealthy on exit code 0: %v", result.Err)
+	}
+	if gotID != "abc123" || len(gotCmd) != 1 || gotCmd[0] != "pg_isready" {
+		t.Errorf("exec called with %q %v", gotID, gotCmd)
+	}
+
+	exitCode = 2
+	result = checker.Check(context.Background(), target)
+	if result.Healthy || result.Err == nil || !strings.Contains(result.Err.Error(), "code 2: not ready") {
+		t.Errorf("Check() on exit code 2 = %v, %v", result.Healthy, result.Err)
+	}
+
+	if result := NewChecker(time.Second, nil).Check(context.Background(), target); result.Healthy {
+		t.Error("Check() healthy without a way to run commands")
+	}
+}
+
 func TestHTTPChecker_Check_Timeout(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(300 * time.Millisecond)
@@ -128,7 +181,7 @@ func TestHTTPChecker_Check_Timeout(t *testing.T) {
 	addr := strings.TrimPrefix(server.URL, "http://")
 	parts := strings.Split(addr, ":")
 
-	checker := NewHTTPChecker(100 * time.Millisecond)
+	checker := NewChecker(100*time.Millisecond, nil)
 	target := Target{
 		ID:              "test",
 		IP:              parts[0],
@@ -159,7 +212,7 @@ func TestHTTPChecker_Check_ContextCanceled(t *testing.T) {
 	addr := strings.TrimPrefix(server.URL, "http://")
 	parts := strings.Split(addr, ":")
 
-	checker := NewHTTPChecker(10 * time.Second)
+	checker := NewChecker(10*time.Second, nil)
 	target := Target{
 		ID:              "test",
 		IP:              parts[0],
@@ -208,7 +261,7 @@ func TestHTTPChecker_CheckWithRetry_ImmediateSuccess(t *testing.T) {
 	addr := strings.TrimPrefix(server.URL, "http://")
 	parts := strings.Split(addr, ":")
 
-	checker := NewHTTPChecker(5 * time.Second)
+	checker := NewChecker(5*time.Second, nil)
 	target := Target{
 		ID:              "test",
 		IP:              parts[0],
@@ -247,7 +300,7 @@ func TestHTTPChecker_CheckWithRetry_SuccessAfterRetries(t *testing.T) {
 	addr := strings.TrimPrefix(server.URL, "http://")
 	parts := strings.Split(addr, ":")
 
-	checker := NewHTTPChecker(5 * time.Second)
+	checker := NewChecker(5*time.Second, nil)
 	target := Target{
 		ID:              "test",
 		IP:              parts[0],
@@ -288,7 +341,7 @@ func TestHTTPChecker_CheckWithRetry_AllRetriesFail(t *testing.T) {
 	addr := strings.TrimPrefix(server.URL, "http://")
 	parts := strings.Split(addr, ":")
 
-	checker := NewHTTPChecker(5 * time.Second)
+	checker := NewChecker(5*time.Second, nil)
 	target := Target{
 		ID:              "test",
 		IP:              parts[0],
@@ -325,7 +378,7 @@ func TestHTTPChecker_CheckWithRetry_ContextCanceled(t *testing.T) {
 	addr := strings.TrimPrefix(server.URL, "http://")
 	parts := strings.Split(addr, ":")
 
-	checker := NewHTTPChecker(5 * time.Second)
+	checker := NewChecker(5*time.Second, nil)
 	target := Target{
 		ID:              "test",
 		IP:              parts[0],
@@ -366,7 +419,7 @@ func TestHTTPChecker_CheckAll(t *testing.T) {
 	addr := strings.TrimPrefix(server.URL, "http://")
 	parts := strings.Split(addr, ":")
 
-	checker := NewHTTPChecker(5 * time.Second)
+	checker := NewChecker(5*time.Second, nil)
 	targets := []Target{
 		{ID: "1", IP: parts[0], Port: parts[1], HealthCheckPath: "/health"},
 		{ID: "2", IP: parts[0], Port: parts[1], HealthCheckPath: "/health"},
@@ -389,7 +442,7 @@ func TestHTTPChecker_CheckAll(t *testing.T) {
 }
 
 func TestHTTPChecker_CheckAll_EmptyTargets(t *testing.T) {
-	checker := NewHTTPChecker(5 * time.Second)
+	checker := NewChecker(5*time.Second, nil)
 	results := checker.CheckAll(context.Background(), nil, 10)
 
 	if results != nil {
@@ -418,7 +471,7 @@ func TestHTTPChecker_CheckAll_ConcurrencyLimit(t *testing.T) {
 	addr := strings.TrimPrefix(server.URL, "http://")
 	parts := strings.Split(addr, ":")
 
-	checker := NewHTTPChecker(5 * time.Second)
+	checker := NewChecker(5*time.Second, nil)
 	targets := make([]Target, 20)
 	for i := range targets {
 		targets[i] = Target{
@@ -436,3 +489,38 @@ func TestHTTPChecker_CheckAll_ConcurrencyLimit(t *testing.T) {
 		t.Errorf("Max concurrent requests = %d, want <= %d", maxConcurrent, maxAllowed)
 	}
 }
+
+func TestChecker_CheckUntil(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Booting for the first three checks.
+		if calls.Add(1) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	target := Target{ID: "test", IP: host, Port: port, HealthCheckPath: "/"}
+	checker := NewChecker(time.Second, nil)
+
+	result := checker.CheckUntil(context.Background(), target, time.Now().Add(5*time.Second), 10*time.Millisecond, nil)
+	if !result.Healthy {
+		t.Fatalf("CheckUntil() unhealthy: %v", result.Err)
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("checked %d times, want 4", got)
+	}
+
+	calls.Store(-1000)
+	start := time.Now()
+	result = checker.CheckUntil(context.Background(), target, time.Now().Add(100*time.Millisecond), 10*time.Millisecond, nil)
+	if result.Healthy || result.Err == nil || !strings.Contains(result.Err.Error(), "startup timeout") {
+		t.Fatalf("CheckUntil() = %v, %v, want a startup timeout", result.Healthy, result.Err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CheckUntil() took %s past a 100ms deadline", elapsed)
+	}
+}
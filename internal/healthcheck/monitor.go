@@ -2,13 +2,19 @@ package healthcheck
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"net"
 	"sync"
 	"time"
 )
 
 const (
 	maxConcurrentChecks = 10 // Maximum concurrent health checks
+	// passiveRecheckInterval spaces the active checks that confirm a failure
+	// the proxy reported, so a dead replica is marked unhealthy within a
+	// fraction of a second instead of fall check intervals.
+	passiveRecheckInterval = 200 * time.Millisecond
 )
 
 // HealthMonitor runs continuous health checks in the background.
@@ -16,7 +22,7 @@ type HealthMonitor struct {
 	config         Config
 	targetProvider TargetProvider
 	configUpdater  ConfigUpdater
-	checker        *HTTPChecker
+	checker        *Checker
 	stateTracker   *StateTracker
 	logger         *slog.Logger
 
@@ -24,6 +30,12 @@ type HealthMonitor struct {
 	running   bool
 	stopCh    chan struct{}
 	stoppedCh chan struct{}
+	// rechecking holds the IDs of targets with out-of-band checks running.
+	rechecking map[string]struct{}
+
+	// notifyMu orders health change notifications, so the config updater
+	// always ends with the latest healthy targets.
+	notifyMu sync.Mutex
 }
 
 // NewHealthMonitor creates a new health monitor.
@@ -37,9 +49,10 @@ func NewHealthMonitor(
 		config:         config,
 		targetProvider: targetProvider,
 		configUpdater:  configUpdater,
-		checker:        NewHTTPChecker(config.Timeout),
+		checker:        NewChecker(config.Timeout, config.Exec),
 		stateTracker:   NewStateTracker(config.Fall, config.Rise),
 		logger:         logger,
+		rechecking:     make(map[string]struct{}),
 	}
 }
 
@@ -140,11 +153,17 @@ func (m *HealthMonitor) runCheck() {
 
 	// If any state changed, notify the config updater
 	if stateChanged {
-		healthyTargets := m.stateTracker.GetHealthyTargets()
-		m.configUpdater.OnHealthChange(healthyTargets)
+		m.notifyHealthChange()
 	}
 }
 
+// notifyHealthChange passes the current healthy targets to the config updater.
+func (m *HealthMonitor) notifyHealthChange() {
+	m.notifyMu.Lock()
+	defer m.notifyMu.Unlock()
+	m.configUpdater.OnHealthChange(m.stateTracker.GetHealthyTargets())
+}
+
 // logStateChange logs a health state transition.
 func (m *HealthMonitor) logStateChange(result Result) {
 	state := m.stateTracker.GetState(result.Target.ID)
@@ -179,11 +198,86 @@ func (m *HealthMonitor) ForceCheck() {
 	}
 }
 
+// ReportPassiveFailure takes a report from the proxy that the backend at addr
+// (ip:port) failed live traffic. The report counts as a failed check, and
+// the target is checked again right away, so fall is reached in well under
+// a second when the target is really down. A passing check clears it.
+func (m *HealthMonitor) ReportPassiveFailure(addr string) {
+	ip, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return
+	}
+	target, ok := m.stateTracker.FindTarget(ip, port)
+	if !ok {
+		m.logger.Debug("Passive failure reported for unknown backend", "backend", addr)
+		return
+	}
+
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return
+	}
+	if _, busy := m.rechecking[target.ID]; busy {
+		m.mu.Unlock()
+		return
+	}
+	m.rechecking[target.ID] = struct{}{}
+	stopCh := m.stopCh
+	m.mu.Unlock()
+
+	m.logger.Debug("Proxy reported failing backend, checking it now",
+		"app", target.AppName, "backend", addr)
+	if m.stateTracker.RecordPassiveFailure(target.ID) {
+		m.logStateChange(Result{Target: target, Err: errors.New("failing live traffic")})
+		m.notifyHealthChange()
+	}
+
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			delete(m.rechecking, target.ID)
+			m.mu.Unlock()
+		}()
+		m.recheck(target, stopCh)
+	}()
+}
+
+// recheck checks a single target out of band, repeating the check up to
+// fall times while it keeps failing, until it passes or is marked unhealthy.
+// It returns early when stopCh is closed.
+func (m *HealthMonitor) recheck(target Target, stopCh <-chan struct{}) {
+	for attempt := range m.config.Fall {
+		if attempt > 0 {
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(passiveRecheckInterval):
+			}
+		}
+
+		// The checker enforces the check timeout.
+		result := m.checker.Check(context.Background(), target)
+		if m.stateTracker.RecordResult(result) {
+			m.logStateChange(result)
+			m.notifyHealthChange()
+		}
+		if result.Healthy || m.stateTracker.GetState(target.ID) == StateUnhealthy {
+			return
+		}
+	}
+}
+
 // GetHealthyTargets returns the current list of healthy targets.
 func (m *HealthMonitor) GetHealthyTargets() []Target {
 	return m.stateTracker.GetHealthyTargets()
 }
 
+// GetUnhealthyTargets returns the targets currently marked unhealthy.
+func (m *HealthMonitor) GetUnhealthyTargets() []Target {
+	return m.stateTracker.GetUnhealthyTargets()
+}
+
 // GetStats returns current health statistics.
 func (m *HealthMonitor) GetStats() (total, healthy, unhealthy int) {
 	return m.stateTracker.GetStats()
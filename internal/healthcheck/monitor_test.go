@@ -518,3 +518,66 @@ func TestHealthMonitor_DynamicTargets(t *testing.T) {
 		t.Errorf("GetHealthyTargets returned %d targets after adding, want 2", len(healthy))
 	}
 }
+
+func TestHealthMonitor_ReportPassiveFailure(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	var checkCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&checkCount, 1)
+		if healthy.Load() {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	addr := strings.TrimPrefix(server.URL, "http://")
+	parts := strings.Split(addr, ":")
+
+	config := Config{
+		Enabled:  true,
+		Interval: time.Hour, // Only the initial round runs on the ticker
+		Fall:     3,
+		Rise:     1,
+		Timeout:  time.Second,
+	}
+	provider := &mockTargetProvider{
+		targets: []Target{{ID: "a", IP: parts[0], Port: parts[1], HealthCheckPath: "/health"}},
+	}
+	updater := &mockConfigUpdater{}
+	monitor := NewHealthMonitor(config, provider, updater, newTestLogger())
+	monitor.Start()
+	defer monitor.Stop()
+
+	waitForCondition(t, 2*time.Second, func() bool {
+		return atomic.LoadInt32(&checkCount) >= 1
+	}, "initial health check did not run within timeout")
+
+	// A report for a backend that passes its check doesn't take it out.
+	monitor.ReportPassiveFailure(addr)
+	waitForCondition(t, 2*time.Second, func() bool {
+		return atomic.LoadInt32(&checkCount) >= 2
+	}, "passive failure report did not trigger a check")
+	if state := monitor.stateTracker.GetState("a"); state != StateHealthy {
+		t.Fatalf("state after a passing recheck = %v, want healthy", state)
+	}
+
+	// Once the backend is down, the report and two rechecks reach fall.
+	healthy.Store(false)
+	start := time.Now()
+	monitor.ReportPassiveFailure(addr)
+	waitForCondition(t, 2*time.Second, func() bool {
+		return monitor.stateTracker.GetState("a") == StateUnhealthy
+	}, "backend was not marked unhealthy after a passive failure report")
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("marking the backend unhealthy took %v, want under a second", elapsed)
+	}
+	if len(updater.GetLastHealthy()) != 0 {
+		t.Errorf("config updater still has healthy targets %v", updater.GetLastHealthy())
+	}
+
+	// Reports for unknown backends are ignored.
+	monitor.ReportPassiveFailure("10.255.255.1:9999")
+}
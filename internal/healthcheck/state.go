@@ -86,9 +86,28 @@ func (st *StateTracker) RecordResult(result Result) (stateChanged bool) {
 		return false
 	}
 
+	return st.recordLocked(entry, result.Healthy)
+}
+
+// RecordPassiveFailure counts a failure the proxy saw on the target's live
+// traffic like a failed check, so passive and active failures add up towards
+// the fall threshold. Returns true if the target's state changed.
+func (st *StateTracker) RecordPassiveFailure(targetID string) (stateChanged bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	entry, exists := st.entries[targetID]
+	if !exists {
+		return false
+	}
+	return st.recordLocked(entry, false)
+}
+
+// recordLocked applies a check outcome to entry. Callers hold st.mu.
+func (st *StateTracker) recordLocked(entry *targetEntry, healthy bool) (stateChanged bool) {
 	oldState := entry.State
 
-	if result.Healthy {
+	if healthy {
 		// Reset failure count, increment success count
 		entry.ConsecutiveFailures = 0
 		entry.ConsecutiveSuccesses++
@@ -111,6 +130,19 @@ func (st *StateTracker) RecordResult(result Result) (stateChanged bool) {
 	return entry.State != oldState
 }
 
+// FindTarget returns the tracked target listening on ip and port.
+func (st *StateTracker) FindTarget(ip, port string) (Target, bool) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	for _, entry := range st.entries {
+		if entry.Target.IP == ip && entry.Target.Port == port {
+			return entry.Target, true
+		}
+	}
+	return Target{}, false
+}
+
 // GetHealthyTargets returns all targets currently in healthy state.
 func (st *StateTracker) GetHealthyTargets() []Target {
 	st.mu.RLock()
@@ -338,3 +338,27 @@ func TestStateTracker_ConcurrentAccess(t *testing.T) {
 		<-done
 	}
 }
+
+func TestStateTracker_PassiveFailuresCountTowardsFall(t *testing.T) {
+	st := NewStateTracker(3, 1)
+	target := Target{ID: "a", IP: "10.0.0.2", Port: "8080"}
+	st.SyncTargets([]Target{target})
+
+	if found, ok := st.FindTarget("10.0.0.2", "8080"); !ok || found.ID != "a" {
+		t.Fatalf("FindTarget() = %v, %v; want target a", found, ok)
+	}
+	if _, ok := st.FindTarget("10.0.0.2", "9090"); ok {
+		t.Fatal("FindTarget() found a target on the wrong port")
+	}
+
+	if st.RecordPassiveFailure("a") {
+		t.Fatal("one passive failure changed the state")
+	}
+	st.RecordResult(Result{Target: target, Healthy: false})
+	if !st.RecordPassiveFailure("a") || st.GetState("a") != StateUnhealthy {
+		t.Fatal("passive and active failures did not add up to fall")
+	}
+	if st.RecordPassiveFailure("unknown") {
+		t.Error("RecordPassiveFailure() changed the state of an unknown target")
+	}
+}
@@ -2,6 +2,15 @@ package healthcheck
 
 import "time"
 
+// CheckType is how a target is checked.
+type CheckType string
+
+const (
+	CheckHTTP CheckType = "http" // GET HealthCheckPath, also used when unset
+	CheckTCP  CheckType = "tcp"  // connect to the port
+	CheckCmd  CheckType = "cmd"  // run Cmd in the container
+)
+
 // Target represents a backend to health check.
 type Target struct {
 	ID              string // Container ID
@@ -9,6 +18,8 @@ type Target struct {
 	IP              string
 	Port            string
 	HealthCheckPath string // e.g., "/health"
+	Type            CheckType
+	Cmd             []string // exec arguments for cmd checks
 }
 
 // Result represents the outcome of a single health check.
@@ -26,6 +37,7 @@ type Config struct {
 	Fall     int           // Mark unhealthy after N consecutive failures
 	Rise     int           // Mark healthy after N consecutive successes
 	Timeout  time.Duration // Per-check timeout
+	Exec     ExecFunc      // Runs the commands of cmd checks
 }
 
 // DefaultConfig returns the default health monitor configuration.
@@ -1,6 +1,10 @@
 package helpers
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 func FormatBinaryBytes(bytes uint64) string {
 	const unit = 1024
@@ -18,3 +22,42 @@ func FormatBinaryBytes(bytes uint64) string {
 	value := float64(bytes) / float64(div)
 	return fmt.Sprintf("%.1f %s", value, suffixes[exp])
 }
+
+// ParseBytes parses a human readable size such as "512MB", "10GiB" or "1048576".
+// Decimal (KB, MB, GB, TB) and binary (KiB, MiB, GiB, TiB) suffixes are accepted,
+// as is the single-letter shorthand (K, M, G, T) which is treated as binary.
+func ParseBytes(s string) (uint64, error) {
+	value := strings.TrimSpace(s)
+	if value == "" {
+		return 0, fmt.Errorf("size is empty")
+	}
+
+	i := 0
+	for i < len(value) && (value[i] >= '0' && value[i] <= '9' || value[i] == '.') {
+		i++
+	}
+	number, unit := value[:i], strings.ToUpper(strings.TrimSpace(value[i:]))
+	if number == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	multipliers := map[string]float64{
+		"": 1, "B": 1,
+		"KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+		"K": 1 << 10, "KIB": 1 << 10,
+		"M": 1 << 20, "MIB": 1 << 20,
+		"G": 1 << 30, "GIB": 1 << 30,
+		"T": 1 << 40, "TIB": 1 << 40,
+	}
+	multiplier, ok := multipliers[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit %q in %q", value[i:], s)
+	}
+
+	return uint64(n * multiplier), nil
+}
@@ -0,0 +1,37 @@
+package helpers
+
+import "testing"
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    uint64
+		wantErr bool
+	}{
+		{input: "1024", want: 1024},
+		{input: "512MB", want: 512_000_000},
+		{input: "10GiB", want: 10 << 30},
+		{input: "2g", want: 2 << 30},
+		{input: " 1.5 KiB ", want: 1536},
+		{input: "", wantErr: true},
+		{input: "GB", wantErr: true},
+		{input: "10XB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseBytes(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseBytes(%q) expected error, got %d", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseBytes(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseBytes(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid"
+)
+
+var deploymentIDs = newDeploymentIDSource(time.Now)
+
+// NewDeploymentID returns a new lowercase ULID. Deployment IDs sort by
+// creation time, and the IDs made by one process always increase, even when
+// several are made in the same millisecond or the clock steps back.
+func NewDeploymentID() string {
+	return deploymentIDs.next()
+}
+
+// deploymentIDSource makes ULIDs that increase monotonically. When the clock
+// hasn't moved past the last ID it falls back to a sequence: the timestamp of
+// the last ID is kept and its random part incremented.
+type deploymentIDSource struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entropy io.Reader
+	last    uint64 // timestamp of the last ID, in Unix milliseconds
+}
+
+func newDeploymentIDSource(now func() time.Time) *deploymentIDSource {
+	return &deploymentIDSource{
+		now:     now,
+		entropy: ulid.Monotonic(rand.Reader, 0),
+	}
+}
+
+func (s *deploymentIDSource) next() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms := max(ulid.Timestamp(s.now()), s.last)
+	id, err := ulid.New(ms, s.entropy)
+	if err != nil {
+		// The random part overflowed within this millisecond, so move on to
+		// the next one.
+		ms++
+		id = ulid.MustNew(ms, s.entropy)
+	}
+	s.last = ms
+	return strings.ToLower(id.String())
+}
+
+// CompareDeploymentIDs orders deployment IDs by when they were created and
+// returns -1, 0 or +1 like strings.Compare. Use it wherever deployments are
+// ordered instead of comparing the IDs as strings.
+//
+// ULIDs are compared by their timestamp, then by their random part, ignoring
+// case. IDs from before ULIDs, timestamps like "20250812150405", are compared
+// by the time they hold. IDs in neither format sort before all others.
+func CompareDeploymentIDs(a, b string) int {
+	ta, okA := deploymentIDTime(a)
+	tb, okB := deploymentIDTime(b)
+	switch {
+	case okA && okB:
+		if c := ta.Compare(tb); c != 0 {
+			return c
+		}
+	case okA:
+		return 1
+	case okB:
+		return -1
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// LatestDeploymentID returns the newest of ids, or "" if there are none.
+func LatestDeploymentID(ids ...string) string {
+	latest := ""
+	for _, id := range ids {
+		if latest == "" || CompareDeploymentIDs(id, latest) > 0 {
+			latest = id
+		}
+	}
+	return latest
+}
+
+func deploymentIDTime(id string) (time.Time, bool) {
+	if len(id) == ulid.EncodedSize {
+		if parsed, err := ulid.ParseStrict(strings.ToUpper(id)); err == nil {
+			return ulid.Time(parsed.Time()), true
+		}
+		return time.Time{}, false
+	}
+
+	// Legacy IDs are a timestamp, optionally followed by centiseconds.
+	if len(id) != 14 && len(id) != 16 {
+		return time.Time{}, false
+	}
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return time.Time{}, false
+		}
+	}
+	t, err := time.Parse("20060102150405", id[:14])
+	if err != nil {
+		return time.Time{}, false
+	}
+	if len(id) == 16 {
+		cs := int(id[14]-'0')*10 + int(id[15]-'0')
+		t = t.Add(time.Duration(cs) * 10 * time.Millisecond)
+	}
+	return t, true
+}
@@ -0,0 +1,120 @@
+package helpers
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeploymentID_Format(t *testing.T) {
+	id := NewDeploymentID()
+
+	assert.Len(t, id, 26)
+	assert.Equal(t, strings.ToLower(id), id)
+	_, err := GetTimestampFromDeploymentID(id)
+	assert.NoError(t, err)
+}
+
+func TestDeploymentIDSource_Monotonic(t *testing.T) {
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	source := newDeploymentIDSource(func() time.Time { return clock })
+
+	var ids []string
+	for range 100 {
+		ids = append(ids, source.next())
+	}
+	// The clock steps back, as it can after an NTP correction.
+	clock = clock.Add(-time.Minute)
+	for range 100 {
+		ids = append(ids, source.next())
+	}
+
+	for i := 1; i < len(ids); i++ {
+		require.Less(t, ids[i-1], ids[i], "ID %d does not sort after the one before it", i)
+		require.Equal(t, 1, CompareDeploymentIDs(ids[i], ids[i-1]))
+	}
+}
+
+func TestCompareDeploymentIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{
+			name: "ulid_by_time",
+			a:    "01h7vxpqzk0000000000000000",
+			b:    "01h7vxpqzm0000000000000000",
+			want: -1,
+		},
+		{
+			name: "ulid_ignores_case",
+			a:    "01H7VXPQZK0000000000000001",
+			b:    "01h7vxpqzk0000000000000001",
+			want: 0,
+		},
+		{
+			name: "ulid_same_millisecond_by_random_part",
+			a:    "01h7vxpqzk0000000000000002",
+			b:    "01H7VXPQZK0000000000000001",
+			want: 1,
+		},
+		{
+			name: "legacy_by_time",
+			a:    "20250812150405",
+			b:    "20250812150404",
+			want: 1,
+		},
+		{
+			name: "legacy_with_centiseconds",
+			a:    "2025081215040501",
+			b:    "20250812150405",
+			want: 1,
+		},
+		{
+			name: "legacy_before_newer_ulid",
+			a:    "20250812150405",
+			b:    "01k2gn00000000000000000000",
+			want: -1,
+		},
+		{
+			// 01h7vxpqzk is August 2023.
+			name: "ulid_before_newer_legacy",
+			a:    "01h7vxpqzk0000000000000000",
+			b:    "20250812150405",
+			want: -1,
+		},
+		{
+			name: "unknown_before_known",
+			a:    "my-deployment",
+			b:    "20250812150405",
+			want: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CompareDeploymentIDs(tt.a, tt.b))
+			assert.Equal(t, -tt.want, CompareDeploymentIDs(tt.b, tt.a))
+		})
+	}
+}
+
+func TestLatestDeploymentID(t *testing.T) {
+	assert.Empty(t, LatestDeploymentID())
+
+	ids := []string{
+		"20250812150405",
+		"01h7vxpqzk0000000000000000",
+		"01K2GN0000000000000000000A",
+		"unknown",
+	}
+	assert.Equal(t, "01K2GN0000000000000000000A", LatestDeploymentID(ids...))
+
+	sorted := slices.SortedFunc(slices.Values(ids), CompareDeploymentIDs)
+	assert.Equal(t, []string{"unknown", "01h7vxpqzk0000000000000000", "20250812150405", "01K2GN0000000000000000000A"}, sorted)
+}
@@ -3,6 +3,7 @@ package helpers
 import (
 	"os"
 	"os/exec"
+	"runtime"
 )
 
 // InitSystem represents the detected init system type
@@ -12,11 +13,15 @@ const (
 	InitSystemd  InitSystem = "systemd"
 	InitOpenRC   InitSystem = "openrc"
 	InitSysVInit InitSystem = "sysvinit"
+	InitLaunchd  InitSystem = "launchd"
 	InitUnknown  InitSystem = "unknown"
 )
 
 // DetectInitSystem returns the init system used on the current machine
 func DetectInitSystem() InitSystem {
+	if runtime.GOOS == "darwin" {
+		return InitLaunchd
+	}
 	// Check for systemd: directory must exist AND systemctl must be available
 	if _, err := os.Stat("/run/systemd/system"); err == nil {
 		if _, err := exec.LookPath("systemctl"); err == nil {
@@ -41,6 +46,8 @@ func RestartCommand() string {
 		return "systemctl restart haloyd"
 	case InitOpenRC:
 		return "rc-service haloyd restart"
+	case InitLaunchd:
+		return "launchctl kickstart -k system/" + LaunchdLabel("haloyd")
 	default:
 		return "/etc/init.d/haloyd restart"
 	}
@@ -53,6 +60,8 @@ func RestartServiceArgs() (string, []string) {
 		return "systemctl", []string{"restart", "haloyd"}
 	case InitOpenRC:
 		return "rc-service", []string{"haloyd", "restart"}
+	case InitLaunchd:
+		return "launchctl", []string{"kickstart", "-k", "system/" + LaunchdLabel("haloyd")}
 	default:
 		return "/etc/init.d/haloyd", []string{"restart"}
 	}
@@ -63,3 +72,8 @@ func RestartService() error {
 	cmd, args := RestartServiceArgs()
 	return exec.Command(cmd, args...).Run()
 }
+
+// LaunchdLabel returns the launchd job label of a haloy service
+func LaunchdLabel(service string) string {
+	return "dev.haloy." + service
+}
@@ -3,12 +3,15 @@ package helpers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -22,7 +25,11 @@ var dohProviders = []string{
 	"https://dns.google/resolve",
 }
 
-const dnsTypeA = 1
+// DNS record types queried over DoH.
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+)
 
 type dohAnswer struct {
 	Type int    `json:"type"`
@@ -33,10 +40,10 @@ type dohResponse struct {
 	Answer []dohAnswer `json:"Answer"`
 }
 
-// ResolveDomainDoH looks up the domain's A records via public DNS-over-HTTPS
-// providers, bypassing /etc/hosts and local resolver caches so the result
-// reflects what public DNS (and ACME validators) see. An empty, non-error
-// result means public DNS has no A records for the domain.
+// ResolveDomainDoH looks up the domain's A and AAAA records via public
+// DNS-over-HTTPS providers, bypassing /etc/hosts and local resolver caches so
+// the result reflects what public DNS (and ACME validators) see. An empty,
+// non-error result means public DNS has no address records for the domain.
 func ResolveDomainDoH(ctx context.Context, domain string) ([]net.IP, error) {
 	return resolveDomainDoH(ctx, domain, dohProviders)
 }
@@ -44,18 +51,27 @@ func ResolveDomainDoH(ctx context.Context, domain string) ([]net.IP, error) {
 func resolveDomainDoH(ctx context.Context, domain string, providers []string) ([]net.IP, error) {
 	var lastErr error
 	for _, provider := range providers {
-		ips, err := queryDoH(ctx, provider, domain)
+		ips, err := queryDoH(ctx, provider, domain, dnsTypeA)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ipv6, err := queryDoH(ctx, provider, domain, dnsTypeAAAA)
 		if err != nil {
 			lastErr = err
 			continue
 		}
-		return ips, nil
+		return append(ips, ipv6...), nil
 	}
 	return nil, fmt.Errorf("all DoH providers failed: %w", lastErr)
 }
 
-func queryDoH(ctx context.Context, provider, domain string) ([]net.IP, error) {
-	reqURL := fmt.Sprintf("%s?name=%s&type=A", provider, url.QueryEscape(domain))
+func queryDoH(ctx context.Context, provider, domain string, recordType int) ([]net.IP, error) {
+	typeName := "A"
+	if recordType == dnsTypeAAAA {
+		typeName = "AAAA"
+	}
+	reqURL := fmt.Sprintf("%s?name=%s&type=%s", provider, url.QueryEscape(domain), typeName)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
 		return nil, err
@@ -79,7 +95,7 @@ func queryDoH(ctx context.Context, provider, domain string) ([]net.IP, error) {
 
 	var ips []net.IP
 	for _, answer := range parsed.Answer {
-		if answer.Type != dnsTypeA {
+		if answer.Type != recordType {
 			continue
 		}
 		if ip := net.ParseIP(answer.Data); ip != nil {
@@ -89,6 +105,38 @@ func queryDoH(ctx context.Context, provider, domain string) ([]net.IP, error) {
 	return ips, nil
 }
 
+// NormalizeResolverAddr returns a DNS server address as host:port, adding the
+// default port 53. The host must be an IP address.
+func NormalizeResolverAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = strings.Trim(addr, "[]"), "53"
+	}
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("'%s' is not an IP address", host)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid port '%s'", port)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// NewDNSResolver returns a resolver that sends DNS queries to the server at
+// addr instead of the ones in the system's resolver configuration.
+func NewDNSResolver(addr string) (*net.Resolver, error) {
+	server, err := NormalizeResolverAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, server)
+		},
+	}, nil
+}
+
 // GetLocalIPs returns the global unicast IP addresses (IPv4 and IPv6)
 // assigned to this machine's network interfaces.
 func GetLocalIPs() ([]net.IP, error) {
@@ -130,10 +178,44 @@ func AnyIPMatch(a, b []net.IP) bool {
 	return false
 }
 
-// GetExternalIP queries a public service for this machine's external IPv4.
-// It returns the IP or an error.
-func GetExternalIP() (net.IP, error) {
-	resp, err := networkHTTPClient.Get("https://api.ipify.org?format=text")
+// externalIPServices return the caller's public address as plain text, one
+// per address family.
+var externalIPServices = []struct {
+	url  string
+	ipv6 bool
+}{
+	{"https://api.ipify.org?format=text", false},
+	{"https://api6.ipify.org?format=text", true},
+}
+
+// GetExternalIPs queries public services for this machine's external IPv4
+// and IPv6 addresses. A family the machine has no route for is left out, so
+// the result is empty only when neither could be determined.
+func GetExternalIPs() ([]net.IP, error) {
+	results := make([]net.IP, len(externalIPServices))
+	errs := make([]error, len(externalIPServices))
+	var wg sync.WaitGroup
+	for i, service := range externalIPServices {
+		wg.Go(func() {
+			results[i], errs[i] = queryExternalIP(service.url, service.ipv6)
+		})
+	}
+	wg.Wait()
+
+	var ips []net.IP
+	for _, ip := range results {
+		if ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return ips, nil
+}
+
+func queryExternalIP(serviceURL string, ipv6 bool) (net.IP, error) {
+	resp, err := networkHTTPClient.Get(serviceURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query external IP service: %w", err)
 	}
@@ -145,9 +227,16 @@ func GetExternalIP() (net.IP, error) {
 	}
 
 	ipStr := strings.TrimSpace(string(body))
-	ip := net.ParseIP(ipStr).To4()
-	if ip == nil {
-		return nil, fmt.Errorf("invalid IPv4 address returned: %s", ipStr)
+	ip := net.ParseIP(ipStr)
+	if ip == nil || (ip.To4() != nil) == ipv6 {
+		family := "IPv4"
+		if ipv6 {
+			family = "IPv6"
+		}
+		return nil, fmt.Errorf("invalid %s address returned: %s", family, ipStr)
+	}
+	if !ipv6 {
+		ip = ip.To4()
 	}
 	return ip, nil
 }
@@ -91,6 +91,28 @@ func TestResolveDomainDoH(t *testing.T) {
 		}
 	})
 
+	t.Run("returns A and AAAA records", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Query().Get("type") {
+			case "A":
+				_, _ = w.Write([]byte(`{"Status":0,"Answer":[{"name":"app.example.com","type":1,"data":"185.248.146.236"}]}`))
+			case "AAAA":
+				_, _ = w.Write([]byte(`{"Status":0,"Answer":[{"name":"app.example.com","type":28,"data":"2a12:6bc0:1337::1"}]}`))
+			default:
+				t.Errorf("unexpected query type %q", r.URL.Query().Get("type"))
+			}
+		}))
+		defer srv.Close()
+
+		ips, err := resolveDomainDoH(ctx, "app.example.com", []string{srv.URL})
+		if err != nil {
+			t.Fatalf("resolveDomainDoH() error: %v", err)
+		}
+		if len(ips) != 2 || ips[0].String() != "185.248.146.236" || ips[1].String() != "2a12:6bc0:1337::1" {
+			t.Errorf("resolveDomainDoH() = %v, want [185.248.146.236 2a12:6bc0:1337::1]", ips)
+		}
+	})
+
 	t.Run("empty answer returns no IPs without error", func(t *testing.T) {
 		srv := dohTestServer(t, http.StatusOK, `{"Status":0}`)
 		defer srv.Close()
@@ -142,3 +164,90 @@ func dohTestServer(t *testing.T, status int, body string) *httptest.Server {
 		_, _ = w.Write([]byte(body))
 	}))
 }
+
+func TestGetExternalIPs(t *testing.T) {
+	serve := func(status int, body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}))
+	}
+	setServices := func(t *testing.T, v4, v6 string) {
+		t.Helper()
+		orig := externalIPServices
+		externalIPServices = []struct {
+			url  string
+			ipv6 bool
+		}{{v4, false}, {v6, true}}
+		t.Cleanup(func() { externalIPServices = orig })
+	}
+
+	t.Run("dual-stack", func(t *testing.T) {
+		v4 := serve(http.StatusOK, "185.248.146.236\n")
+		defer v4.Close()
+		v6 := serve(http.StatusOK, "2a12:6bc0:1337::1")
+		defer v6.Close()
+		setServices(t, v4.URL, v6.URL)
+
+		ips, err := GetExternalIPs()
+		if err != nil {
+			t.Fatalf("GetExternalIPs() error: %v", err)
+		}
+		if len(ips) != 2 || ips[0].String() != "185.248.146.236" || ips[1].String() != "2a12:6bc0:1337::1" {
+			t.Errorf("GetExternalIPs() = %v, want [185.248.146.236 2a12:6bc0:1337::1]", ips)
+		}
+	})
+
+	t.Run("IPv6-only", func(t *testing.T) {
+		v4 := serve(http.StatusBadGateway, "")
+		defer v4.Close()
+		v6 := serve(http.StatusOK, "2a12:6bc0:1337::1")
+		defer v6.Close()
+		setServices(t, v4.URL, v6.URL)
+
+		ips, err := GetExternalIPs()
+		if err != nil {
+			t.Fatalf("GetExternalIPs() error: %v", err)
+		}
+		if len(ips) != 1 || ips[0].String() != "2a12:6bc0:1337::1" {
+			t.Errorf("GetExternalIPs() = %v, want [2a12:6bc0:1337::1]", ips)
+		}
+	})
+
+	t.Run("wrong family is rejected", func(t *testing.T) {
+		v4 := serve(http.StatusOK, "2a12:6bc0:1337::1")
+		defer v4.Close()
+		v6 := serve(http.StatusOK, "185.248.146.236")
+		defer v6.Close()
+		setServices(t, v4.URL, v6.URL)
+
+		if ips, err := GetExternalIPs(); err == nil {
+			t.Errorf("GetExternalIPs() = %v, want error", ips)
+		}
+	})
+}
+
+func TestNormalizeResolverAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    string
+		wantErr bool
+	}{
+		{addr: "1.1.1.1", want: "1.1.1.1:53"},
+		{addr: "1.1.1.1:5353", want: "1.1.1.1:5353"},
+		{addr: "2606:4700:4700::1111", want: "[2606:4700:4700::1111]:53"},
+		{addr: "[2606:4700:4700::1111]:53", want: "[2606:4700:4700::1111]:53"},
+		{addr: "dns.example.com", wantErr: true},
+		{addr: "1.1.1.1:0", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeResolverAddr(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeResolverAddr(%q) error = %v, wantErr %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeResolverAddr(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
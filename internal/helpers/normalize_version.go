@@ -1,5 +1,10 @@
 package helpers
 
+import (
+	"strconv"
+	"strings"
+)
+
 // NormalizeVersion strips the 'v' prefix from version strings for comparison
 func NormalizeVersion(version string) string {
 	if len(version) > 0 && version[0] == 'v' {
@@ -7,3 +12,46 @@ func NormalizeVersion(version string) string {
 	}
 	return version
 }
+
+// CompareVersions compares two release versions, like "v1.4.0" and
+// "1.5.0-rc.1", by their numeric parts; pre-release suffixes are ignored. ok
+// is false when either isn't a release version, like a "dev" build.
+func CompareVersions(a, b string) (cmp int, ok bool) {
+	pa, okA := versionParts(a)
+	pb, okB := versionParts(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	for i := range max(len(pa), len(pb)) {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+func versionParts(version string) ([]int, bool) {
+	version, _, _ = strings.Cut(NormalizeVersion(version), "-")
+	if version == "" {
+		return nil, false
+	}
+	var parts []int
+	for field := range strings.SplitSeq(version, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
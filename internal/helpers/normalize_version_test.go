@@ -0,0 +1,24 @@
+package helpers
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		want   int
+		wantOK bool
+	}{
+		{"v1.4.0", "1.4.0", 0, true},
+		{"v1.4.0", "v1.10.0", -1, true},
+		{"v2.0", "v1.9.9", 1, true},
+		{"v1.5.0-rc.1", "v1.5.0", 0, true},
+		{"dev", "v1.4.0", 0, false},
+		{"v1.4.0", "", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := CompareVersions(tt.a, tt.b)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("CompareVersions(%q, %q) = %d, %v, want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
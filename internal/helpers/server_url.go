@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -50,3 +51,19 @@ func IsLocalhost(serverURL string) bool {
 
 	return host == "localhost" || host == "127.0.0.1" || host == "::1"
 }
+
+// ValidateServerHost checks a normalized server URL: a domain with an
+// optional port. Localhost addresses are accepted for local development.
+func ValidateServerHost(normalizedURL string) error {
+	host := normalizedURL
+	if h, port, err := net.SplitHostPort(normalizedURL); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid port '%s'", port)
+		}
+		host = h
+	}
+	if IsLocalhost(host) {
+		return nil
+	}
+	return IsValidDomain(host)
+}
@@ -0,0 +1,25 @@
+package helpers
+
+import "testing"
+
+func TestValidateServerHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		wantErr bool
+	}{
+		{"haloy.example.com", false},
+		{"haloy.example.com:8443", false},
+		{"localhost", false},
+		{"localhost:8088", false},
+		{"127.0.0.1:8088", false},
+		{"haloy", true},
+		{"haloy.example.com:http", true},
+		{"haloy.example.com:70000", true},
+	}
+	for _, tt := range tests {
+		err := ValidateServerHost(tt.host)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateServerHost(%q) error = %v, wantErr %v", tt.host, err, tt.wantErr)
+		}
+	}
+}
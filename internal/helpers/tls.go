@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// ParseTLSVersion parses a minimum TLS version, "1.2" or "1.3". Older
+// versions are rejected.
+func ParseTLSVersion(version string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(version), "tls") {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version '%s', use 1.2 or 1.3", version)
+	}
+}
+
+// ParseCipherSuites parses cipher suite names as listed by tls.CipherSuites,
+// e.g. "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256". Suites Go considers
+// insecure are rejected.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite '%s'", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if strings.EqualFold(suite.Name, name) {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// curves maps curve names accepted in configs to their IDs.
+var curves = map[string]tls.CurveID{
+	"x25519":         tls.X25519,
+	"x25519mlkem768": tls.X25519MLKEM768,
+	"p-256":          tls.CurveP256,
+	"p-384":          tls.CurveP384,
+	"p-521":          tls.CurveP521,
+}
+
+// ParseCurvePreferences parses key exchange names, e.g. "X25519", "P-256" or
+// "X25519MLKEM768".
+func ParseCurvePreferences(names []string) ([]tls.CurveID, error) {
+	ids := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		id, ok := curves[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown curve '%s'", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	"crypto/tls"
+	"slices"
+	"testing"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    uint16
+		wantErr bool
+	}{
+		{"1.2", tls.VersionTLS12, false},
+		{"1.3", tls.VersionTLS13, false},
+		{"TLS1.3", tls.VersionTLS13, false},
+		{"1.1", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTLSVersion(tt.version)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTLSVersion(%q) = %v, %v, want %v, error %v", tt.version, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	got, err := ParseCipherSuites([]string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "tls_ecdhe_rsa_with_chacha20_poly1305_sha256"})
+	if err != nil {
+		t.Fatalf("ParseCipherSuites() error = %v", err)
+	}
+	want := []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseCipherSuites() = %v, want %v", got, want)
+	}
+
+	if _, err := ParseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"}); err == nil {
+		t.Error("ParseCipherSuites() should reject insecure suites")
+	}
+}
+
+func TestParseCurvePreferences(t *testing.T) {
+	got, err := ParseCurvePreferences([]string{"X25519", "p-256"})
+	if err != nil {
+		t.Fatalf("ParseCurvePreferences() error = %v", err)
+	}
+	if !slices.Equal(got, []tls.CurveID{tls.X25519, tls.CurveP256}) {
+		t.Errorf("ParseCurvePreferences() = %v", got)
+	}
+	if _, err := ParseCurvePreferences([]string{"secp256k1"}); err == nil {
+		t.Error("ParseCurvePreferences() should reject unknown curves")
+	}
+}
@@ -0,0 +1,35 @@
+// Package keyring stores secrets in the operating system's credential store:
+// the macOS Keychain, the Secret Service on Linux (through secret-tool) and
+// the Windows Credential Manager.
+//
+// Secrets are kept under the haloy service, one per account. Callers that can
+// fall back to another source should treat ErrNotFound and ErrUnsupported
+// alike: on headless machines there is often no keyring at all.
+package keyring
+
+import "errors"
+
+const service = "haloy"
+
+var (
+	ErrNotFound    = errors.New("secret not found in keyring")
+	ErrUnsupported = errors.New("no keyring available on this system")
+)
+
+// Get returns the secret stored for account.
+func Get(account string) (string, error) {
+	return get(account)
+}
+
+// Set stores secret for account, replacing any previous one.
+func Set(account, secret string) error {
+	if account == "" {
+		return errors.New("keyring account is required")
+	}
+	return set(account, secret)
+}
+
+// Delete removes the secret stored for account.
+func Delete(account string) error {
+	return del(account)
+}
@@ -0,0 +1,50 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// errItemNotFound is the exit code of 'security' when no item matches.
+const errItemNotFound = 44
+
+func get(account string) (string, error) {
+	out, err := security("find-generic-password", "-s", service, "-a", account, "-w")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+// set passes the secret as an argument: 'security' has no way to read it from
+// stdin outside its interactive mode.
+func set(account, secret string) error {
+	_, err := security("add-generic-password", "-U", "-s", service, "-a", account, "-l", "haloy: "+account, "-w", secret)
+	return err
+}
+
+func del(account string) error {
+	_, err := security("delete-generic-password", "-s", service, "-a", account)
+	return err
+}
+
+func security(args ...string) (string, error) {
+	path, err := exec.LookPath("security")
+	if err != nil {
+		return "", ErrUnsupported
+	}
+	var stderr strings.Builder
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == errItemNotFound {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("keychain: %s", strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,65 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secret-tool talks to the Secret Service (GNOME Keyring, KWallet) over
+// D-Bus. Without a session bus, as on most servers, it fails and the keyring
+// is reported as unsupported.
+
+func get(account string) (string, error) {
+	out, err := secretTool("", "lookup", "service", service, "account", account)
+	if err != nil {
+		var exitErr *exec.ExitError
+		// lookup exits 1 without output when nothing matches.
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(exitErr.Stderr) == 0 {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+func set(account, secret string) error {
+	_, err := secretTool(secret, "store", "--label=haloy: "+account, "service", service, "account", account)
+	return err
+}
+
+func del(account string) error {
+	if _, err := get(account); err != nil {
+		return err
+	}
+	_, err := secretTool("", "clear", "service", service, "account", account)
+	return err
+}
+
+// secretTool runs secret-tool with stdin as input, which keeps secrets out of
+// the process list.
+func secretTool(stdin string, args ...string) (string, error) {
+	path, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return "", ErrUnsupported
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			stderr := strings.TrimSpace(string(exitErr.Stderr))
+			if stderr == "" {
+				return "", err
+			}
+			if strings.Contains(stderr, "D-Bus") || strings.Contains(stderr, "dbus") {
+				return "", ErrUnsupported
+			}
+			return "", fmt.Errorf("secret-tool: %s: %w", stderr, err)
+		}
+		return "", fmt.Errorf("secret-tool: %w", err)
+	}
+	return string(out), nil
+}
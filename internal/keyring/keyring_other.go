@@ -0,0 +1,9 @@
+//go:build !darwin && !linux && !windows
+
+package keyring
+
+func get(string) (string, error) { return "", ErrUnsupported }
+
+func set(string, string) error { return ErrUnsupported }
+
+func del(string) error { return ErrUnsupported }
@@ -10,70 +10,81 @@ import (
 	"strings"
 
 	"github.com/haloydev/haloy/internal/apitypes"
-	"github.com/haloydev/haloy/internal/config"
 )
 
-// AssembleImageTar creates a docker-loadable tar from cached layers
-// Returns the path to the temporary tar file (caller must clean up)
-func (s *LayerStore) AssembleImageTar(req apitypes.ImageAssembleRequest) (string, error) {
-	tempDir, err := config.EnsureImageTempDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to prepare temporary directory: %w", err)
-	}
-
-	// Create temporary file for the assembled tar
-	tempFile, err := os.CreateTemp(tempDir, "haloy-assembled-*.tar")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temporary file: %w", err)
-	}
-	tempPath := tempFile.Name()
-
-	// If we fail, clean up
-	success := false
-	defer func() {
-		tempFile.Close()
-		if !success {
-			os.Remove(tempPath)
-		}
-	}()
-
-	tw := tar.NewWriter(tempFile)
-	defer tw.Close()
-
+// OpenImageTar streams a docker-loadable tar of the cached layers of req.
+// Every layer is resolved before the stream starts, so a missing layer fails
+// here rather than halfway through a docker load. The caller must close the
+// returned reader.
+func (s *LayerStore) OpenImageTar(req apitypes.ImageAssembleRequest) (io.ReadCloser, error) {
 	// Wrap the single manifest entry in an array (docker save format)
 	manifestJSON, err := json.Marshal([]apitypes.ImageManifestEntry{req.Manifest})
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal manifest: %w", err)
-	}
-	if err := writeToTar(tw, "manifest.json", manifestJSON); err != nil {
-		return "", fmt.Errorf("failed to write manifest.json: %w", err)
-	}
-
-	// The config path is specified in the manifest (e.g., "sha256:abc123.json" or "abc123.json")
-	configPath := req.Manifest.Config
-	if err := writeToTar(tw, configPath, req.Config); err != nil {
-		return "", fmt.Errorf("failed to write config: %w", err)
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
 	}
 
 	// The manifest.Layers contains paths like "<digest>/layer.tar"
-	for _, layerPath := range req.Manifest.Layers {
+	digests := make([]string, len(req.Manifest.Layers))
+	storedLayerPaths := make([]string, len(req.Manifest.Layers))
+	for i, layerPath := range req.Manifest.Layers {
 		// Extract the digest from the layer path
 		// Format is typically "sha256:abc123/layer.tar" or just "abc123/layer.tar"
 		digest, err := extractDigestFromLayerPath(layerPath)
 		if err != nil {
-			return "", fmt.Errorf("failed to parse layer path %s: %w", layerPath, err)
+			return nil, fmt.Errorf("failed to parse layer path %s: %w", layerPath, err)
 		}
 		if err := ValidateDigest(digest); err != nil {
-			return "", fmt.Errorf("invalid layer path %s: %w", layerPath, err)
+			return nil, fmt.Errorf("invalid layer path %s: %w", layerPath, err)
 		}
 
 		storedLayerPath, err := s.GetLayerPath(digest)
 		if err != nil {
-			return "", fmt.Errorf("layer not found: %s: %w", digest, err)
+			return nil, fmt.Errorf("layer not found: %s: %w", digest, err)
+		}
+		digests[i] = digest
+		storedLayerPaths[i] = storedLayerPath
+	}
+
+	// Touch all layers to update last_used_at
+	if err := s.TouchLayers(digests); err != nil {
+		// Non-fatal, just log
+		fmt.Printf("Warning: failed to touch layers: %v\n", err)
+	}
+
+	// Record diff IDs so prune can match stored blobs against live images even
+	// when blob digests differ from diff IDs (compressed docker save output).
+	if diffIDs := diffIDsByDigest(req.Config, req.Manifest.Layers); len(diffIDs) > 0 {
+		if err := s.db.SetLayerDiffIDs(diffIDs); err != nil {
+			// Non-fatal, just log
+			fmt.Printf("Warning: failed to record layer diff IDs: %v\n", err)
 		}
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeImageTar(pw, manifestJSON, req, digests, storedLayerPaths))
+	}()
+	return pr, nil
+}
+
+// writeImageTar writes the tar OpenImageTar streams to w.
+func writeImageTar(w io.Writer, manifestJSON []byte, req apitypes.ImageAssembleRequest, digests, storedLayerPaths []string) error {
+	tw := tar.NewWriter(w)
+
+	if err := writeToTar(tw, "manifest.json", manifestJSON); err != nil {
+		return fmt.Errorf("failed to write manifest.json: %w", err)
+	}
 
-		if err := copyFileToTar(tw, layerPath, storedLayerPath); err != nil {
-			return "", fmt.Errorf("failed to copy layer %s: %w", digest, err)
+	// The config path is specified in the manifest (e.g., "sha256:abc123.json" or "abc123.json")
+	configPath := req.Manifest.Config
+	if err := writeToTar(tw, configPath, req.Config); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	for i, layerPath := range req.Manifest.Layers {
+		digest := digests[i]
+		if err := copyFileToTar(tw, layerPath, storedLayerPaths[i]); err != nil {
+			return fmt.Errorf("failed to copy layer %s: %w", digest, err)
 		}
 
 		// Legacy-format layer directories need VERSION and json companion files.
@@ -83,7 +94,7 @@ func (s *LayerStore) AssembleImageTar(req apitypes.ImageAssembleRequest) (string
 			layerDir := filepath.Dir(layerPath)
 			versionPath := filepath.Join(layerDir, "VERSION")
 			if err := writeToTar(tw, versionPath, []byte("1.0")); err != nil {
-				return "", fmt.Errorf("failed to write VERSION for layer %s: %w", digest, err)
+				return fmt.Errorf("failed to write VERSION for layer %s: %w", digest, err)
 			}
 
 			// Write minimal json file for this layer directory
@@ -91,41 +102,15 @@ func (s *LayerStore) AssembleImageTar(req apitypes.ImageAssembleRequest) (string
 			layerJSONPath := filepath.Join(layerDir, "json")
 			layerJSON := fmt.Sprintf(`{"id":"%s"}`, strings.TrimSuffix(filepath.Base(layerDir), "/"))
 			if err := writeToTar(tw, layerJSONPath, []byte(layerJSON)); err != nil {
-				return "", fmt.Errorf("failed to write json for layer %s: %w", digest, err)
+				return fmt.Errorf("failed to write json for layer %s: %w", digest, err)
 			}
 		}
 	}
 
-	// Touch all layers to update last_used_at
-	var digests []string
-	for _, layerPath := range req.Manifest.Layers {
-		digest, _ := extractDigestFromLayerPath(layerPath)
-		digests = append(digests, digest)
-	}
-	if err := s.TouchLayers(digests); err != nil {
-		// Non-fatal, just log
-		fmt.Printf("Warning: failed to touch layers: %v\n", err)
-	}
-
-	// Record diff IDs so prune can match stored blobs against live images even
-	// when blob digests differ from diff IDs (compressed docker save output).
-	if diffIDs := diffIDsByDigest(req.Config, req.Manifest.Layers); len(diffIDs) > 0 {
-		if err := s.db.SetLayerDiffIDs(diffIDs); err != nil {
-			// Non-fatal, just log
-			fmt.Printf("Warning: failed to record layer diff IDs: %v\n", err)
-		}
-	}
-
 	if err := tw.Close(); err != nil {
-		return "", fmt.Errorf("failed to close tar writer: %w", err)
-	}
-
-	if err := tempFile.Close(); err != nil {
-		return "", fmt.Errorf("failed to close temp file: %w", err)
+		return fmt.Errorf("failed to close tar writer: %w", err)
 	}
-
-	success = true
-	return tempPath, nil
+	return nil
 }
 
 // diffIDsByDigest maps each layer's blob digest to its diff ID using the image
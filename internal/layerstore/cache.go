@@ -0,0 +1,423 @@
+package layerstore
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+// recentLayerWindow protects recently written layers from eviction so that
+// in-flight layered uploads never lose blobs between check and assemble.
+const recentLayerWindow = time.Hour
+
+// CacheStats summarizes what the layer store and pull-through cache hold.
+type CacheStats struct {
+	Layers     int
+	LayerBytes int64
+	Images     []storage.CachedImage
+}
+
+// Stats returns the current layer store usage and the cached registry images.
+func (s *LayerStore) Stats() (CacheStats, error) {
+	layers, err := s.db.ListAllLayers()
+	if err != nil {
+		return CacheStats{}, err
+	}
+	images, err := s.db.ListCachedImages()
+	if err != nil {
+		return CacheStats{}, err
+	}
+
+	stats := CacheStats{Layers: len(layers), Images: images}
+	for _, layer := range layers {
+		stats.LayerBytes += layer.Size
+	}
+	return stats, nil
+}
+
+// PruneCache evicts least recently used layers until the store fits within
+// maxBytes, then drops cached images that can no longer be reassembled.
+// Returns the number of layers removed and the bytes freed.
+func (s *LayerStore) PruneCache(maxBytes uint64) (int, int64, error) {
+	layers, err := s.db.ListAllLayers()
+	if err != nil {
+		return 0, 0, err
+	}
+	sort.Slice(layers, func(i, j int) bool {
+		return layers[i].LastUsedAt.Before(layers[j].LastUsedAt)
+	})
+
+	var total int64
+	for _, layer := range layers {
+		total += layer.Size
+	}
+
+	cutoff := time.Now().Add(-recentLayerWindow)
+	var pruned int
+	var freed int64
+	for _, layer := range layers {
+		if total <= int64(maxBytes) {
+			break
+		}
+		if layer.LastUsedAt.After(cutoff) {
+			continue
+		}
+		if err := s.DeleteLayer(layer.Digest); err != nil {
+			return pruned, freed, err
+		}
+		total -= layer.Size
+		pruned++
+		freed += layer.Size
+	}
+
+	if err := s.dropIncompleteCachedImages(); err != nil {
+		return pruned, freed, err
+	}
+	return pruned, freed, nil
+}
+
+// ClearImageCache forgets all cached registry images. Their layers are left
+// in place and are removed by regular layer pruning once unused.
+func (s *LayerStore) ClearImageCache() (int, error) {
+	images, err := s.db.ListCachedImages()
+	if err != nil {
+		return 0, err
+	}
+	for _, img := range images {
+		if err := s.db.DeleteCachedImage(img.ImageRef); err != nil {
+			return 0, fmt.Errorf("failed to delete cached image %s: %w", img.ImageRef, err)
+		}
+	}
+	return len(images), nil
+}
+
+// cachedLayerDigests returns the layer digests referenced by cached images.
+func (s *LayerStore) cachedLayerDigests() (map[string]struct{}, error) {
+	images, err := s.db.ListCachedImages()
+	if err != nil {
+		return nil, err
+	}
+	digests := make(map[string]struct{})
+	for _, img := range images {
+		entry, err := decodeCachedManifest(img)
+		if err != nil {
+			continue
+		}
+		for _, layerPath := range entry.Layers {
+			if digest, err := extractDigestFromLayerPath(layerPath); err == nil {
+				digests[digest] = struct{}{}
+			}
+		}
+	}
+	return digests, nil
+}
+
+func (s *LayerStore) dropIncompleteCachedImages() error {
+	images, err := s.db.ListCachedImages()
+	if err != nil {
+		return err
+	}
+	for _, img := range images {
+		complete, err := s.hasCachedLayers(img)
+		if err != nil {
+			return err
+		}
+		if complete {
+			continue
+		}
+		if err := s.db.DeleteCachedImage(img.ImageRef); err != nil {
+			return fmt.Errorf("failed to delete cached image %s: %w", img.ImageRef, err)
+		}
+	}
+	return nil
+}
+
+func (s *LayerStore) hasCachedLayers(img storage.CachedImage) (bool, error) {
+	entry, err := decodeCachedManifest(img)
+	if err != nil {
+		return false, nil
+	}
+	digests := make([]string, 0, len(entry.Layers))
+	for _, layerPath := range entry.Layers {
+		digest, err := extractDigestFromLayerPath(layerPath)
+		if err != nil {
+			return false, nil
+		}
+		digests = append(digests, digest)
+	}
+	missing, _, err := s.db.HasLayers(digests)
+	if err != nil {
+		return false, err
+	}
+	return len(missing) == 0, nil
+}
+
+func decodeCachedManifest(img storage.CachedImage) (apitypes.ImageManifestEntry, error) {
+	var entry apitypes.ImageManifestEntry
+	if err := json.Unmarshal(img.Manifest, &entry); err != nil {
+		return entry, fmt.Errorf("invalid cached manifest for %s: %w", img.ImageRef, err)
+	}
+	return entry, nil
+}
+
+// PullThroughCache serves registry images from the layer store and records
+// freshly pulled images into it. It implements docker.ImageCache.
+type PullThroughCache struct {
+	store *LayerStore
+	cli   *client.Client
+}
+
+// NewPullThroughCache creates a pull-through cache backed by the layer store.
+func NewPullThroughCache(store *LayerStore, cli *client.Client) *PullThroughCache {
+	return &PullThroughCache{store: store, cli: cli}
+}
+
+var _ docker.ImageCache = (*PullThroughCache)(nil)
+
+// Restore makes imageRef available locally from the cache. An empty
+// remoteDigest means the registry could not be reached, in which case any
+// cached copy is accepted. Returns false when the cache cannot serve the image.
+func (c *PullThroughCache) Restore(ctx context.Context, imageRef, remoteDigest string) (bool, error) {
+	img, err := c.store.db.GetCachedImage(imageRef)
+	if err != nil || img == nil {
+		return false, err
+	}
+	if remoteDigest != "" && img.RemoteDigest != remoteDigest {
+		return false, nil
+	}
+
+	if local, err := c.cli.ImageInspect(ctx, imageRef); err == nil && local.ID == img.ImageID {
+		return true, c.store.db.TouchCachedImage(imageRef)
+	}
+
+	complete, err := c.store.hasCachedLayers(*img)
+	if err != nil || !complete {
+		return false, err
+	}
+
+	entry, err := decodeCachedManifest(*img)
+	if err != nil {
+		return false, err
+	}
+	entry.RepoTags = []string{imageRef}
+
+	image, err := c.store.OpenImageTar(apitypes.ImageAssembleRequest{
+		ImageRef: imageRef,
+		Config:   img.Config,
+		Manifest: entry,
+	})
+	if err != nil {
+		return false, err
+	}
+	defer image.Close()
+
+	if err := docker.LoadImage(ctx, c.cli, image); err != nil {
+		return false, err
+	}
+	return true, c.store.db.TouchCachedImage(imageRef)
+}
+
+// Store saves a freshly pulled image into the layer store.
+func (c *PullThroughCache) Store(ctx context.Context, imageRef, remoteDigest string) error {
+	inspect, err := c.cli.ImageInspect(ctx, imageRef)
+	if err != nil {
+		return fmt.Errorf("failed to inspect image: %w", err)
+	}
+
+	tempDir, err := config.EnsureImageTempDir()
+	if err != nil {
+		return fmt.Errorf("failed to prepare temporary directory: %w", err)
+	}
+	tempFile, err := os.CreateTemp(tempDir, "haloy-cache-*.tar")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	reader, err := c.cli.ImageSave(ctx, []string{imageRef})
+	if err != nil {
+		return fmt.Errorf("failed to save image: %w", err)
+	}
+	_, err = io.Copy(tempFile, reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write saved image: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to close saved image: %w", err)
+	}
+
+	entry, configJSON, size, err := c.store.ingestImageTar(tempFile.Name())
+	if err != nil {
+		return err
+	}
+	entry.RepoTags = []string{imageRef}
+
+	manifestJSON, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	now := time.Now()
+	return c.store.db.SaveCachedImage(storage.CachedImage{
+		ImageRef:     imageRef,
+		RemoteDigest: remoteDigest,
+		ImageID:      inspect.ID,
+		Manifest:     manifestJSON,
+		Config:       configJSON,
+		Size:         size,
+		CreatedAt:    now,
+		LastUsedAt:   now,
+	})
+}
+
+// ingestImageTar stores every layer of a docker save archive in the layer
+// store and returns a manifest entry that references them by content digest.
+func (s *LayerStore) ingestImageTar(tarPath string) (apitypes.ImageManifestEntry, []byte, int64, error) {
+	var manifests []apitypes.ImageManifestEntry
+	if err := walkTar(tarPath, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Name != "manifest.json" {
+			return nil
+		}
+		return json.NewDecoder(r).Decode(&manifests)
+	}); err != nil {
+		return apitypes.ImageManifestEntry{}, nil, 0, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if len(manifests) == 0 {
+		return apitypes.ImageManifestEntry{}, nil, 0, errors.New("saved image has no manifest")
+	}
+	manifest := manifests[0]
+
+	wanted := make(map[string]struct{}, len(manifest.Layers))
+	for _, layerPath := range manifest.Layers {
+		wanted[layerPath] = struct{}{}
+	}
+
+	digests := make(map[string]string, len(manifest.Layers))
+	links := make(map[string]string)
+	var configJSON []byte
+	var size int64
+	err := walkTar(tarPath, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Name == manifest.Config {
+			data, err := io.ReadAll(r)
+			configJSON = data
+			return err
+		}
+		if _, ok := wanted[hdr.Name]; !ok {
+			return nil
+		}
+		if hdr.Typeflag == tar.TypeSymlink {
+			links[hdr.Name] = path.Join(path.Dir(hdr.Name), hdr.Linkname)
+			return nil
+		}
+		digest, n, err := s.storeLayerFromReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to store layer %s: %w", hdr.Name, err)
+		}
+		digests[hdr.Name] = digest
+		size += n
+		return nil
+	})
+	if err != nil {
+		return apitypes.ImageManifestEntry{}, nil, 0, err
+	}
+	if configJSON == nil {
+		return apitypes.ImageManifestEntry{}, nil, 0, fmt.Errorf("saved image config %s not found", manifest.Config)
+	}
+
+	layers := make([]string, 0, len(manifest.Layers))
+	for _, layerPath := range manifest.Layers {
+		digest, ok := digests[layerPath]
+		if !ok {
+			digest, ok = digests[links[layerPath]]
+		}
+		if !ok {
+			return apitypes.ImageManifestEntry{}, nil, 0, fmt.Errorf("layer %s not found in saved image", layerPath)
+		}
+		layers = append(layers, "blobs/sha256/"+digest[len("sha256:"):])
+	}
+
+	if diffIDs := diffIDsByDigest(configJSON, layers); len(diffIDs) > 0 {
+		if err := s.db.SetLayerDiffIDs(diffIDs); err != nil {
+			return apitypes.ImageManifestEntry{}, nil, 0, err
+		}
+	}
+
+	return apitypes.ImageManifestEntry{Config: manifest.Config, Layers: layers}, configJSON, size, nil
+}
+
+// storeLayerFromReader spools a layer to disk to learn its digest before
+// handing it to StoreLayer. Layers already in the store are only touched.
+func (s *LayerStore) storeLayerFromReader(r io.Reader) (string, int64, error) {
+	tempDir, err := config.EnsureImageTempDir()
+	if err != nil {
+		return "", 0, err
+	}
+	tempFile, err := os.CreateTemp(tempDir, "haloy-layer-*.tar")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	hasher := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tempFile, hasher), r)
+	if err != nil {
+		return "", 0, err
+	}
+	digest := "sha256:" + hex.EncodeToString(hasher.Sum(nil))
+
+	exists, err := s.db.HasLayer(digest)
+	if err != nil {
+		return "", 0, err
+	}
+	if exists {
+		return digest, n, s.TouchLayers([]string{digest})
+	}
+
+	if _, err := tempFile.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	if _, err := s.StoreLayer(digest, tempFile); err != nil {
+		return "", 0, err
+	}
+	return digest, n, nil
+}
+
+func walkTar(tarPath string, fn func(*tar.Header, io.Reader) error) error {
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	tr := tar.NewReader(file)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package layerstore
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+type tarEntry struct {
+	name     string
+	data     []byte
+	linkname string
+}
+
+func writeTestTar(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+
+	tarPath := filepath.Join(t.TempDir(), "image.tar")
+	file, err := os.Create(tarPath)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer file.Close()
+
+	tw := tar.NewWriter(file)
+	for _, entry := range entries {
+		hdr := &tar.Header{Name: entry.name, Mode: 0o644, Size: int64(len(entry.data))}
+		if entry.linkname != "" {
+			hdr = &tar.Header{Name: entry.name, Typeflag: tar.TypeSymlink, Linkname: entry.linkname}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader() error = %v", err)
+		}
+		if _, err := tw.Write(entry.data); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return tarPath
+}
+
+func TestIngestImageTar_LegacyFormatWithSymlinkedLayer(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	layer := []byte("layer-content")
+	configJSON := []byte(`{"rootfs":{"diff_ids":["sha256:aa","sha256:aa"]}}`)
+	manifest, _ := json.Marshal([]apitypes.ImageManifestEntry{{
+		Config: "cfg.json",
+		Layers: []string{"one/layer.tar", "two/layer.tar"},
+	}})
+
+	// Entries are written before manifest.json to match docker save ordering.
+	tarPath := writeTestTar(t, []tarEntry{
+		{name: "one/layer.tar", data: layer},
+		{name: "two/layer.tar", linkname: "../one/layer.tar"},
+		{name: "cfg.json", data: configJSON},
+		{name: "manifest.json", data: manifest},
+	})
+
+	entry, gotConfig, size, err := store.ingestImageTar(tarPath)
+	if err != nil {
+		t.Fatalf("ingestImageTar() error = %v", err)
+	}
+	if string(gotConfig) != string(configJSON) {
+		t.Fatalf("config = %s, want %s", gotConfig, configJSON)
+	}
+	if size != int64(len(layer)) {
+		t.Fatalf("size = %d, want %d", size, len(layer))
+	}
+
+	digest := digestFor(layer)
+	want := "blobs/sha256/" + digest[len("sha256:"):]
+	if len(entry.Layers) != 2 || entry.Layers[0] != want || entry.Layers[1] != want {
+		t.Fatalf("layers = %v, want both %s", entry.Layers, want)
+	}
+	if _, err := store.GetLayerPath(digest); err != nil {
+		t.Fatalf("GetLayerPath() error = %v", err)
+	}
+}
+
+func TestPruneCache_EvictsLeastRecentlyUsedAndDropsIncompleteImages(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	old := []byte("old-layer-content")
+	newer := []byte("newer-layer")
+	oldDigest, newerDigest := digestFor(old), digestFor(newer)
+	for _, content := range [][]byte{old, newer} {
+		if _, err := store.StoreLayer(digestFor(content), bytes.NewReader(content)); err != nil {
+			t.Fatalf("StoreLayer() error = %v", err)
+		}
+	}
+
+	setLastUsed(t, store.db, oldDigest, time.Now().Add(-48*time.Hour))
+	setLastUsed(t, store.db, newerDigest, time.Now().Add(-2*time.Hour))
+
+	manifest, _ := json.Marshal(apitypes.ImageManifestEntry{
+		Config: "cfg.json",
+		Layers: []string{"blobs/sha256/" + oldDigest[len("sha256:"):]},
+	})
+	now := time.Now()
+	if err := store.db.SaveCachedImage(storage.CachedImage{
+		ImageRef:   "nginx:latest",
+		Manifest:   manifest,
+		Config:     []byte("{}"),
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}); err != nil {
+		t.Fatalf("SaveCachedImage() error = %v", err)
+	}
+
+	pruned, freed, err := store.PruneCache(uint64(len(newer)))
+	if err != nil {
+		t.Fatalf("PruneCache() error = %v", err)
+	}
+	if pruned != 1 || freed != int64(len(old)) {
+		t.Fatalf("PruneCache() = (%d, %d), want (1, %d)", pruned, freed, len(old))
+	}
+	if _, err := store.GetLayerPath(newerDigest); err != nil {
+		t.Fatalf("newer layer was evicted: %v", err)
+	}
+
+	img, err := store.db.GetCachedImage("nginx:latest")
+	if err != nil {
+		t.Fatalf("GetCachedImage() error = %v", err)
+	}
+	if img != nil {
+		t.Fatalf("cached image referencing evicted layer was kept")
+	}
+}
+
+func TestPruneCache_KeepsRecentLayers(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	content := []byte("fresh-layer")
+	if _, err := store.StoreLayer(digestFor(content), bytes.NewReader(content)); err != nil {
+		t.Fatalf("StoreLayer() error = %v", err)
+	}
+
+	pruned, _, err := store.PruneCache(0)
+	if err != nil {
+		t.Fatalf("PruneCache() error = %v", err)
+	}
+	if pruned != 0 {
+		t.Fatalf("PruneCache() pruned %d recent layers, want 0", pruned)
+	}
+}
+
+func setLastUsed(t *testing.T, db *storage.DB, digest string, at time.Time) {
+	t.Helper()
+	if _, err := db.Exec(`UPDATE layers SET last_used_at = ? WHERE digest = ?`, at, digest); err != nil {
+		t.Fatalf("update last_used_at: %v", err)
+	}
+}
@@ -1,12 +1,16 @@
 package layerstore
 
 import (
+	"archive/tar"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
 	"errors"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -196,7 +200,7 @@ func TestDeleteLayerRejectsTraversalDigest(t *testing.T) {
 	}
 }
 
-func TestAssembleImageTarRejectsTraversalLayerPath(t *testing.T) {
+func TestOpenImageTarRejectsTraversalLayerPath(t *testing.T) {
 	store, dataDir := newTestStore(t)
 
 	outsideDir := filepath.Join(filepath.Dir(dataDir), "loot")
@@ -216,7 +220,59 @@ func TestAssembleImageTarRejectsTraversalLayerPath(t *testing.T) {
 		},
 	}
 
-	if _, err := store.AssembleImageTar(req); err == nil {
-		t.Error("AssembleImageTar() with traversal layer path = nil, want error")
+	if image, err := store.OpenImageTar(req); err == nil {
+		image.Close()
+		t.Error("OpenImageTar() with traversal layer path = nil, want error")
+	}
+}
+
+func TestOpenImageTarStreamsStoredLayers(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	content := []byte("layer content")
+	digest := digestFor(content)
+	if _, err := store.StoreLayer(digest, strings.NewReader(string(content))); err != nil {
+		t.Fatalf("StoreLayer() error = %v", err)
+	}
+	layerPath := "blobs/sha256/" + strings.TrimPrefix(digest, "sha256:")
+
+	image, err := store.OpenImageTar(apitypes.ImageAssembleRequest{
+		ImageRef: "app:latest",
+		Config:   []byte(`{}`),
+		Manifest: apitypes.ImageManifestEntry{
+			Config: "blobs/sha256/config",
+			Layers: []string{layerPath},
+		},
+	})
+	if err != nil {
+		t.Fatalf("OpenImageTar() error = %v", err)
+	}
+	defer image.Close()
+
+	files := make(map[string]string)
+	tr := tar.NewReader(image)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading image tar: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("reading %s: %v", header.Name, err)
+		}
+		files[header.Name] = string(data)
+	}
+
+	if files[layerPath] != string(content) {
+		t.Errorf("layer = %q, want %q", files[layerPath], content)
+	}
+	if files["blobs/sha256/config"] != "{}" {
+		t.Errorf("config = %q, want {}", files["blobs/sha256/config"])
+	}
+	if _, ok := files["manifest.json"]; !ok || len(files) != 3 {
+		t.Errorf("image tar holds %v, want manifest.json, config, and the layer", slices.Sorted(maps.Keys(files)))
 	}
 }
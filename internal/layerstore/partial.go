@@ -0,0 +1,168 @@
+package layerstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/storage"
+)
+
+// ErrOffsetMismatch is returned when a chunk does not start where the partial upload ends.
+var ErrOffsetMismatch = errors.New("upload offset mismatch")
+
+// partialUploadsDir holds in-progress resumable uploads, one file per digest.
+const partialUploadsDir = "uploads"
+
+// partialLocks serializes chunk writes per digest across handler goroutines.
+// Digests share a fixed set of locks, so it doesn't grow with every upload.
+var partialLocks [64]sync.Mutex
+
+func lockPartial(digest string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(digest))
+	mu := &partialLocks[h.Sum32()%uint32(len(partialLocks))]
+	mu.Lock()
+	return mu.Unlock
+}
+
+func (s *LayerStore) partialPath(digest string) string {
+	return filepath.Join(s.basePath, partialUploadsDir, strings.TrimPrefix(digest, "sha256:")+".partial")
+}
+
+// PartialOffset returns how many bytes of a resumable upload the server already has.
+func (s *LayerStore) PartialOffset(digest string) (int64, error) {
+	if err := ValidateDigest(digest); err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(s.partialPath(digest))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat partial upload: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// AppendPartial writes a chunk to a resumable upload. offset must equal the
+// bytes already received, otherwise ErrOffsetMismatch is returned so the
+// client can re-sync. Returns the new offset.
+func (s *LayerStore) AppendPartial(digest string, offset int64, r io.Reader) (int64, error) {
+	if err := ValidateDigest(digest); err != nil {
+		return 0, err
+	}
+	unlock := lockPartial(digest)
+	defer unlock()
+
+	partialPath := s.partialPath(digest)
+	if err := os.MkdirAll(filepath.Dir(partialPath), constants.ModeDirPrivate); err != nil {
+		return 0, fmt.Errorf("failed to create uploads directory: %w", err)
+	}
+
+	file, err := os.OpenFile(partialPath, os.O_CREATE|os.O_WRONLY, constants.ModeFileSecret)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open partial upload: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat partial upload: %w", err)
+	}
+	if info.Size() != offset {
+		return info.Size(), fmt.Errorf("%w: server has %d bytes, chunk starts at %d", ErrOffsetMismatch, info.Size(), offset)
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, fmt.Errorf("failed to seek partial upload: %w", err)
+	}
+	n, copyErr := io.Copy(file, r)
+	if copyErr != nil {
+		// Keep whatever landed on disk; the client resumes from the reported offset.
+		return offset + n, fmt.Errorf("failed to write chunk: %w", copyErr)
+	}
+	return offset + n, nil
+}
+
+// CommitPartial verifies a completed resumable upload against its digest and
+// moves it into the layer store. The partial file is discarded on mismatch.
+func (s *LayerStore) CommitPartial(digest string) (int64, error) {
+	if err := ValidateDigest(digest); err != nil {
+		return 0, err
+	}
+	unlock := lockPartial(digest)
+	defer unlock()
+
+	partialPath := s.partialPath(digest)
+	file, err := os.Open(partialPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open partial upload: %w", err)
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	file.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to hash partial upload: %w", err)
+	}
+
+	expectedHash := strings.TrimPrefix(digest, "sha256:")
+	if actualHash := hex.EncodeToString(hasher.Sum(nil)); actualHash != expectedHash {
+		os.Remove(partialPath)
+		return 0, fmt.Errorf("%w: expected %s, got %s", ErrDigestMismatch, expectedHash, actualHash)
+	}
+
+	layerDir := filepath.Join(s.basePath, expectedHash)
+	if err := os.MkdirAll(layerDir, constants.ModeDirPrivate); err != nil {
+		return 0, fmt.Errorf("failed to create layer directory: %w", err)
+	}
+	layerPath := filepath.Join(layerDir, "layer.tar")
+	if err := os.Rename(partialPath, layerPath); err != nil {
+		return 0, fmt.Errorf("failed to move partial upload: %w", err)
+	}
+
+	now := time.Now()
+	if err := s.db.SaveLayer(storage.Layer{Digest: digest, Size: size, CreatedAt: now, LastUsedAt: now}); err != nil {
+		os.Remove(layerPath)
+		return 0, fmt.Errorf("failed to save layer to database: %w", err)
+	}
+	return size, nil
+}
+
+// PruneStalePartials removes resumable uploads that have not received data
+// within maxAge. Returns the number of files removed and the bytes freed.
+func (s *LayerStore) PruneStalePartials(maxAge time.Duration) (int, int64, error) {
+	entries, err := os.ReadDir(filepath.Join(s.basePath, partialUploadsDir))
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list partial uploads: %w", err)
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	var removed int
+	var freed int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.basePath, partialUploadsDir, entry.Name())); err != nil {
+			continue
+		}
+		removed++
+		freed += info.Size()
+	}
+	return removed, freed, nil
+}
@@ -50,6 +50,16 @@ func PruneUnusedLayers(ctx context.Context, db *storage.DB, logger *slog.Logger)
 		}
 	}
 
+	// Layers of images held by the pull-through cache are bounded by
+	// PruneCache instead, so the cache survives Docker removing the image.
+	cachedDigests, err := store.cachedLayerDigests()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list cached image layers: %w", err)
+	}
+	for digest := range cachedDigests {
+		neededDigests[digest] = struct{}{}
+	}
+
 	allLayers, err := db.ListAllLayers()
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to list layers: %w", err)
@@ -79,5 +89,12 @@ func PruneUnusedLayers(ctx context.Context, db *storage.DB, logger *slog.Logger)
 		freed += layer.Size
 	}
 
+	// Resumable uploads abandoned for a day are not coming back.
+	if removed, bytes, err := store.PruneStalePartials(24 * time.Hour); err != nil {
+		logger.Warn("Failed to prune stale partial uploads", "error", err)
+	} else if removed > 0 {
+		logger.Info("Pruned stale partial uploads", "count", removed, "bytes_freed", bytes)
+	}
+
 	return pruned, freed, nil
 }
@@ -1,8 +1,11 @@
 package logging
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"os"
+	"time"
 )
 
 // Log attribute keys used for structured logging and streaming
@@ -23,8 +26,97 @@ const (
 
 	// General attributes
 	AttrError = "error"
+
+	// AttrCrashLoop marks the event logged when an app container is found
+	// crash looping, for clients watching the log stream to alert on.
+	AttrCrashLoop   = "crashLoop"
+	AttrContainerID = "containerID"
+	AttrRestarts    = "restarts"
+
+	// AttrFailureKind classifies a failed deployment for clients, e.g. to pick
+	// an exit code. Absent for failures without a more specific kind.
+	AttrFailureKind = "failureKind"
+)
+
+// Deployment failure kinds sent in AttrFailureKind.
+const (
+	FailureKindHealthCheck = "health_check"
+	FailureKindCanceled    = "canceled"
+	FailureKindTimeout     = "timeout"
+)
+
+// AttrPhase marks a deployment's log entry as the start of a phase, which
+// the entry's Event carries with the progress it stands for. AttrErrorClass
+// is the Event's error class when the phase is PhaseFailed.
+const (
+	AttrPhase      = "phase"
+	AttrErrorClass = "errorClass"
+)
+
+// Deployment phases, in the order a deployment goes through them.
+const (
+	PhasePull        = "pull"
+	PhaseStart       = "start"
+	PhaseHealthCheck = "health_check"
+	PhaseLive        = "live"
+	PhaseFailed      = "failed"
 )
 
+// phasePercent is how far along a deployment is once a phase starts.
+var phasePercent = map[string]int{
+	PhasePull:        10,
+	PhaseStart:       50,
+	PhaseHealthCheck: 75,
+	PhaseLive:        100,
+	PhaseFailed:      100,
+}
+
+// ErrorClassUnknown is the error class of a failure that neither has a kind
+// nor happened in a known phase.
+const ErrorClassUnknown = "unknown"
+
+// DeploymentEvent is the machine-readable record of a deployment's log
+// entry, for clients to show progress and read the result without parsing
+// messages.
+type DeploymentEvent struct {
+	Phase        string `json:"phase"`
+	App          string `json:"app,omitempty"`
+	DeploymentID string `json:"deploymentId"`
+	Percent      int    `json:"percent"`
+	// ErrorClass is set in PhaseFailed: the failure kind, or else the phase
+	// the deployment failed in.
+	ErrorClass string `json:"errorClass,omitempty"`
+}
+
+// PhaseError is an error a deployment failed with in Phase.
+type PhaseError struct {
+	Phase string
+	Err   error
+}
+
+func (e *PhaseError) Error() string { return e.Err.Error() }
+
+func (e *PhaseError) Unwrap() error { return e.Err }
+
+// ErrorClass classifies the error a deployment failed with: the failure
+// kind when there is one, then cancellation and timeouts, then the phase
+// from a *PhaseError.
+func ErrorClass(kind string, err error) string {
+	switch {
+	case kind != "":
+		return kind
+	case errors.Is(err, context.Canceled):
+		return FailureKindCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return FailureKindTimeout
+	}
+	var phaseErr *PhaseError
+	if errors.As(err, &phaseErr) {
+		return phaseErr.Phase
+	}
+	return ErrorClassUnknown
+}
+
 // NewLogger creates a new slog.Logger with optional streaming
 func NewLogger(level slog.Level, publisher StreamPublisher) *slog.Logger {
 	// Create base handler (console output)
@@ -54,6 +146,12 @@ func LogFatal(logger *slog.Logger, message string, args ...any) {
 	os.Exit(1)
 }
 
+// LogDeploymentPhase logs that a deployment started phase, one of the Phase
+// constants.
+func LogDeploymentPhase(logger *slog.Logger, deploymentID, appName, phase, message string, args ...any) {
+	logger.Info(message, append([]any{AttrApp, appName, AttrDeploymentID, deploymentID, AttrPhase, phase}, args...)...)
+}
+
 // LogDeploymentComplete marks a deployment as successfully completed
 // This sends the completion signal that tells CLI clients to stop streaming
 func LogDeploymentComplete(logger *slog.Logger, domains []string, deploymentID, appName, message string) {
@@ -62,6 +160,7 @@ func LogDeploymentComplete(logger *slog.Logger, domains []string, deploymentID,
 		AttrApp, appName,
 		AttrDeploymentID, deploymentID,
 		AttrDomains, domains,
+		AttrPhase, PhaseLive,
 		AttrDeploymentComplete, true,
 		AttrDeploymentSuccess, true,
 	)
@@ -70,12 +169,43 @@ func LogDeploymentComplete(logger *slog.Logger, domains []string, deploymentID,
 // LogDeploymentFailed marks a deployment as failed
 // This sends the failure signal that tells CLI clients to stop streaming with error
 func LogDeploymentFailed(logger *slog.Logger, deploymentID, appName, message string, err error) {
-	logger.Error(
-		message,
+	LogDeploymentFailedKind(logger, deploymentID, appName, "", message, err)
+}
+
+// LogDeploymentFailedKind is LogDeploymentFailed with a failure kind, one of
+// the FailureKind constants.
+func LogDeploymentFailedKind(logger *slog.Logger, deploymentID, appName, kind, message string, err error) {
+	args := []any{
 		AttrApp, appName,
 		AttrDeploymentID, deploymentID,
 		AttrError, err,
+		AttrPhase, PhaseFailed,
+		AttrErrorClass, ErrorClass(kind, err),
 		AttrDeploymentComplete, true, // Also end stream on failure
 		AttrDeploymentFailed, true,
+	}
+	if kind != "" {
+		args = append(args, AttrFailureKind, kind)
+	}
+	logger.Error(message, args...)
+}
+
+// LogCrashLoop emits the crash loop event for a container Docker restarted
+// restarts times within window.
+func LogCrashLoop(logger *slog.Logger, appName, deploymentID, containerID string, restarts int, window time.Duration, message string) {
+	logger.Error(
+		message,
+		AttrApp, appName,
+		AttrDeploymentID, deploymentID,
+		AttrContainerID, containerID,
+		AttrRestarts, restarts,
+		"window", window,
+		AttrCrashLoop, true,
 	)
 }
+
+// FailureKind returns the failure kind of a failed deployment's log entry.
+func (e LogEntry) FailureKind() string {
+	kind, _ := e.Fields[AttrFailureKind].(string)
+	return kind
+}
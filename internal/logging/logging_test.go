@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+)
+
+func TestErrorClass(t *testing.T) {
+	pullErr := &PhaseError{Phase: PhasePull, Err: errors.New("manifest unknown")}
+	tests := []struct {
+		name string
+		kind string
+		err  error
+		want string
+	}{
+		{"kind wins", FailureKindHealthCheck, pullErr, FailureKindHealthCheck},
+		{"canceled", "", &PhaseError{Phase: PhaseStart, Err: fmt.Errorf("deployment canceled: %w", context.Canceled)}, FailureKindCanceled},
+		{"timeout", "", fmt.Errorf("container startup timed out: %w", context.DeadlineExceeded), FailureKindTimeout},
+		{"phase", "", fmt.Errorf("deploy: %w", pullErr), PhasePull},
+		{"unknown", "", errors.New("boom"), ErrorClassUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorClass(tt.kind, tt.err); got != tt.want {
+				t.Errorf("ErrorClass() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeploymentEvents(t *testing.T) {
+	lb := NewLogBroker()
+	defer lb.Close()
+	ch, id := lb.SubscribeDeployment("d1", 0)
+	defer lb.Unsubscribe(id)
+
+	logger := slog.New(NewStreamHandler(lb, nil)).With(AttrDeploymentID, "d1")
+	logger.Info("Pulling image")
+	LogDeploymentPhase(logger, "d1", "web", PhaseStart, "Starting containers for web")
+	LogDeploymentFailedKind(logger, "d1", "web", "", "Deployment failed", &PhaseError{Phase: PhaseStart, Err: errors.New("port in use")})
+
+	if got := receive(t, ch); got.Event != nil {
+		t.Errorf("plain entry event = %+v, want none", got.Event)
+	}
+	start := receive(t, ch)
+	if start.Event == nil || *start.Event != (DeploymentEvent{Phase: PhaseStart, App: "web", DeploymentID: "d1", Percent: 50}) {
+		t.Errorf("start event = %+v", start.Event)
+	}
+	if _, ok := start.Fields[AttrPhase]; ok {
+		t.Errorf("fields = %+v, want the phase only in the event", start.Fields)
+	}
+	failed := receive(t, ch)
+	if failed.Event == nil || failed.Event.Phase != PhaseFailed || failed.Event.ErrorClass != PhaseStart || failed.Event.Percent != 100 {
+		t.Errorf("failed event = %+v, want failed in the start phase", failed.Event)
+	}
+	if failed.Fields[AttrError] != "port in use" {
+		t.Errorf("failed fields = %+v, want the error message unchanged", failed.Fields)
+	}
+}
@@ -21,258 +21,314 @@ type LogEntry struct {
 	IsDeploymentFailed   bool           `json:"isDeploymentFailed,omitempty"`
 	IsDeploymentSuccess  bool           `json:"isDeploymentSuccess,omitempty"`
 	IsHaloydInitComplete bool           `json:"isHaloydInitComplete,omitempty"`
+	// Event is set on the entries that start a phase of a deployment.
+	Event *DeploymentEvent `json:"event,omitempty"`
+
+	// Seq orders the entries the broker published. A client that lost its
+	// stream subscribes again after the last Seq it got.
+	Seq uint64 `json:"seq,omitempty"`
+	// Dropped is how many entries the stream skipped right before this one,
+	// because its client read too slowly or resumed after they were gone.
+	Dropped uint64 `json:"dropped,omitempty"`
 }
 
 // StreamPublisher defines the interface for publishing log entries to streams
 type StreamPublisher interface {
 	Publish(entry LogEntry)
 
-	SubscribeGeneral() (<-chan LogEntry, string)
-	UnsubscribeGeneral(subscriberID string)
+	// SubscribeGeneral and SubscribeDeployment return a stream and its
+	// subscriber ID. With after zero the stream starts with the latest
+	// entries kept, otherwise with the kept entries whose Seq is greater.
+	SubscribeGeneral(after uint64) (<-chan LogEntry, string)
+	SubscribeDeployment(deploymentID string, after uint64) (<-chan LogEntry, string)
+	Unsubscribe(subscriberID string)
 
-	SubscribeDeployment(deploymentID string) <-chan LogEntry
-	UnsubscribeDeployment(deploymentID string)
+	// RecordDeployments passes every deployment entry published from now on
+	// to record, e.g. to keep it after the buffer drops it. record must not
+	// log through the publisher.
+	RecordDeployments(record func(LogEntry))
 
 	Close()
 }
 
-// LogBroker manages log streams for different deployment IDs
+const (
+	historySize           = 1000 // General entries kept for streams resuming
+	deploymentHistorySize = 500  // Entries kept per deployment
+	deploymentHistories   = 64   // Deployments whose entries are kept, the most recently active
+	replaySize            = 100  // Kept entries a new stream starts with
+	subscriberBufferSize  = 1000 // Entries queued for a stream before the oldest are dropped
+)
+
+// LogBroker fans log entries out to streams and keeps the latest ones, in
+// general and per deployment, for streams that start or resume later.
 type LogBroker struct {
-	streams map[string]chan LogEntry // subscriberID -> channel
-	buffer  []LogEntry               // Buffer for historical logs
+	subscribers map[string]*subscriber
+	history     []LogEntry
+
+	deploymentHistory map[string][]LogEntry
 
-	deploymentStreams map[string]chan LogEntry // One channel per deployment ID
-	deploymentBuffer  map[string][]LogEntry
+	record func(LogEntry) // Receives deployment entries, see RecordDeployments
 
-	maxBuffer        int // Maximum buffered logs
+	seq              uint64
 	subscriberIDSeed int
-	mutex            sync.RWMutex
+	mutex            sync.Mutex
 	closed           bool
 }
 
 // NewLogBroker creates a new log broker
 func NewLogBroker() StreamPublisher {
 	return &LogBroker{
-		streams:           make(map[string]chan LogEntry),
-		buffer:            make([]LogEntry, 0),
-		deploymentStreams: make(map[string]chan LogEntry),
-		deploymentBuffer:  make(map[string][]LogEntry),
-		maxBuffer:         100,
-		subscriberIDSeed:  1,
+		subscribers:       make(map[string]*subscriber),
+		deploymentHistory: make(map[string][]LogEntry),
+		// Starting from the clock keeps Seq increasing across restarts, so a
+		// client resuming with a cursor from before one misses nothing new.
+		seq:              uint64(time.Now().UnixMicro()),
+		subscriberIDSeed: 1,
 	}
 }
 
-// Publish publishes a log entry to the general stream and deployment-specific streams
+// Publish numbers a log entry and queues it for the general streams and the
+// streams of its deployment.
 func (lb *LogBroker) Publish(entry LogEntry) {
+	// Recording happens outside the lock, so a slow store doesn't hold up
+	// subscribers.
+	if record, numbered := lb.publish(entry); record != nil {
+		record(numbered)
+	}
+}
+
+// publish routes an entry to the streams and returns the recorder to pass it
+// to, if any, with the entry as numbered.
+func (lb *LogBroker) publish(entry LogEntry) (func(LogEntry), LogEntry) {
 	lb.mutex.Lock()
 	defer lb.mutex.Unlock()
 
 	if lb.closed {
-		return
+		return nil, entry
 	}
 
-	lb.buffer = append(lb.buffer, entry)
-	if len(lb.buffer) > lb.maxBuffer {
-		lb.buffer = lb.buffer[len(lb.buffer)-lb.maxBuffer:]
+	lb.seq++
+	entry.Seq = lb.seq
+
+	lb.history = appendBounded(lb.history, entry, historySize)
+	if entry.DeploymentID != "" {
+		lb.keepDeploymentEntry(entry)
 	}
 
-	// Send to all general subscribers
-	for subscriberID, ch := range lb.streams {
-		select {
-		case ch <- entry:
-		default:
-			// Channel full, close slow subscriber
-			close(ch)
-			delete(lb.streams, subscriberID)
+	for _, sub := range lb.subscribers {
+		if sub.deploymentID == "" || sub.deploymentID == entry.DeploymentID {
+			sub.push(entry)
 		}
 	}
 
-	if entry.DeploymentID != "" {
-		lb.publishToDeployment(entry.DeploymentID, entry)
+	if entry.DeploymentID == "" {
+		return nil, entry
 	}
+	return lb.record, entry
 }
 
-// publishToDeployment is a private helper for deployment-specific publishing
-func (lb *LogBroker) publishToDeployment(deploymentID string, entry LogEntry) {
-	buffer := lb.deploymentBuffer[deploymentID]
-	buffer = append(buffer, entry)
-	if len(buffer) > lb.maxBuffer {
-		buffer = buffer[len(buffer)-lb.maxBuffer:]
+// keepDeploymentEntry adds an entry to its deployment's history, forgetting
+// the deployment that was active least recently when too many are kept.
+func (lb *LogBroker) keepDeploymentEntry(entry LogEntry) {
+	entries, exists := lb.deploymentHistory[entry.DeploymentID]
+	if !exists && len(lb.deploymentHistory) >= deploymentHistories {
+		var oldestID string
+		var oldestSeq uint64
+		for id, kept := range lb.deploymentHistory {
+			if last := kept[len(kept)-1].Seq; oldestID == "" || last < oldestSeq {
+				oldestID, oldestSeq = id, last
+			}
+		}
+		delete(lb.deploymentHistory, oldestID)
 	}
-	lb.deploymentBuffer[deploymentID] = buffer
+	lb.deploymentHistory[entry.DeploymentID] = appendBounded(entries, entry, deploymentHistorySize)
+}
 
-	// Send to deployment subscriber if exists
-	if ch, exists := lb.deploymentStreams[deploymentID]; exists {
-		select {
-		case ch <- entry:
-		default:
-			// Channel full, close slow subscriber
-			close(ch)
-			delete(lb.deploymentStreams, deploymentID)
-			delete(lb.deploymentBuffer, deploymentID)
-		}
+// SubscribeGeneral creates a subscription for all logs.
+func (lb *LogBroker) SubscribeGeneral(after uint64) (<-chan LogEntry, string) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	// General entries are numbered without gaps, so a cursor older than the
+	// history tells how many entries the stream missed.
+	replay := replayAfter(lb.history, after)
+	if after > 0 && len(replay) > 0 && replay[0].Seq > after+1 {
+		replay[0].Dropped = replay[0].Seq - after - 1
 	}
+	return lb.subscribe("general", "", replay)
 }
 
-// SubscribeGeneral creates a subscription for all logs and returns the channel and subscriber ID
-func (lb *LogBroker) SubscribeGeneral() (<-chan LogEntry, string) {
+// SubscribeDeployment creates a subscription for the logs of a deployment.
+func (lb *LogBroker) SubscribeDeployment(deploymentID string, after uint64) (<-chan LogEntry, string) {
 	lb.mutex.Lock()
 	defer lb.mutex.Unlock()
 
+	return lb.subscribe("deployment", deploymentID, replayAfter(lb.deploymentHistory[deploymentID], after))
+}
+
+// subscribe registers a stream that starts with replay. The caller holds
+// the lock, so no entry is published between the replay and the stream.
+func (lb *LogBroker) subscribe(kind, deploymentID string, replay []LogEntry) (<-chan LogEntry, string) {
 	if lb.closed {
 		ch := make(chan LogEntry)
 		close(ch)
 		return ch, ""
 	}
 
-	// Generate unique subscriber ID
-	subscriberID := lb.generateSubscriberID()
-
-	// Create new general subscription
-	ch := make(chan LogEntry, 100)
-
-	// Copy buffered general logs
-	var bufferCopy []LogEntry
-	if len(lb.buffer) > 0 {
-		bufferCopy = make([]LogEntry, len(lb.buffer))
-		copy(bufferCopy, lb.buffer)
-	}
-
-	// Store the channel
-	lb.streams[subscriberID] = ch
-
-	// Send buffered logs in a separate goroutine
-	if len(bufferCopy) > 0 {
-		go func() {
-			for _, entry := range bufferCopy {
-				select {
-				case ch <- entry:
-				case <-time.After(2 * time.Second):
-					// Timeout, subscriber is too slow
-					lb.mutex.Lock()
-					if existingCh, exists := lb.streams[subscriberID]; exists && existingCh == ch {
-						close(ch)
-						delete(lb.streams, subscriberID)
-					}
-					lb.mutex.Unlock()
-					return
-				}
-			}
-		}()
+	subscriberID := lb.generateSubscriberID(kind)
+	sub := newSubscriber(deploymentID, subscriberBufferSize)
+	for _, entry := range replay {
+		sub.push(entry)
 	}
-
-	return ch, subscriberID
+	lb.subscribers[subscriberID] = sub
+	go sub.run()
+	return sub.out, subscriberID
 }
 
-// UnsubscribeGeneral removes a specific general subscriber
-func (lb *LogBroker) UnsubscribeGeneral(subscriberID string) {
+// Unsubscribe ends a stream.
+func (lb *LogBroker) Unsubscribe(subscriberID string) {
 	lb.mutex.Lock()
 	defer lb.mutex.Unlock()
 
-	if ch, exists := lb.streams[subscriberID]; exists {
-		close(ch)
-		delete(lb.streams, subscriberID)
+	if sub, exists := lb.subscribers[subscriberID]; exists {
+		sub.stop()
+		delete(lb.subscribers, subscriberID)
 	}
 }
 
-// SubscribeDeployment creates a new subscription for a deployment ID
-func (lb *LogBroker) SubscribeDeployment(deploymentID string) <-chan LogEntry {
+// RecordDeployments sets the function every deployment entry is passed to.
+func (lb *LogBroker) RecordDeployments(record func(LogEntry)) {
 	lb.mutex.Lock()
 	defer lb.mutex.Unlock()
+	lb.record = record
+}
 
-	if lb.closed {
-		ch := make(chan LogEntry)
-		close(ch)
-		return ch
-	}
+// Close shuts down the log broker and ends all streams
+func (lb *LogBroker) Close() {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
 
-	// Check if already subscribed
-	if existingCh, exists := lb.deploymentStreams[deploymentID]; exists {
-		return existingCh
+	if lb.closed {
+		return
 	}
 
-	// Create new subscription
-	ch := make(chan LogEntry, 100)
+	lb.closed = true
 
-	// Copy buffered logs
-	var bufferCopy []LogEntry
-	if buffer, exists := lb.deploymentBuffer[deploymentID]; exists && len(buffer) > 0 {
-		bufferCopy = make([]LogEntry, len(buffer))
-		copy(bufferCopy, buffer)
+	for subscriberID, sub := range lb.subscribers {
+		sub.stop()
+		delete(lb.subscribers, subscriberID)
 	}
 
-	// Store the channel
-	lb.deploymentStreams[deploymentID] = ch
-
-	// Send buffered logs in a separate goroutine
-	if len(bufferCopy) > 0 {
-		go func() {
-			for _, entry := range bufferCopy {
-				select {
-				case ch <- entry:
-				case <-time.After(2 * time.Second):
-					// Timeout, close the channel
-					lb.mutex.Lock()
-					if existingCh, exists := lb.deploymentStreams[deploymentID]; exists && existingCh == ch {
-						close(ch)
-						delete(lb.deploymentStreams, deploymentID)
-						delete(lb.deploymentBuffer, deploymentID)
-					}
-					lb.mutex.Unlock()
-					return
-				}
-			}
-		}()
-	}
+	lb.history = nil
+	lb.deploymentHistory = nil
+}
 
-	return ch
+// generateSubscriberID creates a unique subscriber ID
+func (lb *LogBroker) generateSubscriberID(kind string) string {
+	id := lb.subscriberIDSeed
+	lb.subscriberIDSeed++
+	return fmt.Sprintf("%s_%d", kind, id)
 }
 
-// UnsubscribeDeployment removes a deployment subscriber
-func (lb *LogBroker) UnsubscribeDeployment(deploymentID string) {
-	lb.mutex.Lock()
-	defer lb.mutex.Unlock()
+// replayAfter returns a copy of the entries with a Seq greater than after,
+// or of the latest replaySize ones when after is zero.
+func replayAfter(entries []LogEntry, after uint64) []LogEntry {
+	start := max(len(entries)-replaySize, 0)
+	if after > 0 {
+		start = len(entries)
+		for start > 0 && entries[start-1].Seq > after {
+			start--
+		}
+	}
+	replay := make([]LogEntry, len(entries)-start)
+	copy(replay, entries[start:])
+	return replay
+}
 
-	if ch, exists := lb.deploymentStreams[deploymentID]; exists {
-		close(ch)
-		delete(lb.deploymentStreams, deploymentID)
+// appendBounded appends entry, dropping the oldest entries past size.
+func appendBounded(entries []LogEntry, entry LogEntry, size int) []LogEntry {
+	entries = append(entries, entry)
+	if len(entries) > size {
+		entries = entries[len(entries)-size:]
 	}
-	delete(lb.deploymentBuffer, deploymentID)
+	return entries
 }
 
-// Close shuts down the log broker and closes all channels
-func (lb *LogBroker) Close() {
-	lb.mutex.Lock()
-	defer lb.mutex.Unlock()
+// subscriber queues the entries of one stream. Publishing never waits for
+// the stream's client: when the queue is full the oldest entry is dropped
+// and counted, so a slow client still gets the latest entries, like the one
+// that ends a deployment.
+type subscriber struct {
+	deploymentID string // Empty for a general stream
+	out          chan LogEntry
+	size         int
+
+	mu      sync.Mutex
+	queue   []LogEntry
+	dropped uint64 // Dropped since the last entry was sent
+
+	wake     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
 
-	if lb.closed {
-		return
+func newSubscriber(deploymentID string, size int) *subscriber {
+	return &subscriber{
+		deploymentID: deploymentID,
+		out:          make(chan LogEntry),
+		size:         size,
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
 	}
+}
 
-	lb.closed = true
-
-	// Close all general streams
-	for subscriberID, ch := range lb.streams {
-		close(ch)
-		delete(lb.streams, subscriberID)
+// push queues an entry without blocking.
+func (s *subscriber) push(entry LogEntry) {
+	s.mu.Lock()
+	s.queue = append(s.queue, entry)
+	if len(s.queue) > s.size {
+		s.queue = s.queue[1:]
+		s.dropped++
 	}
+	s.mu.Unlock()
 
-	// Close all deployment streams
-	for deploymentID, ch := range lb.deploymentStreams {
-		close(ch)
-		delete(lb.deploymentStreams, deploymentID)
+	select {
+	case s.wake <- struct{}{}:
+	default:
 	}
+}
+
+// run sends queued entries to out until the subscriber is stopped, then
+// closes out.
+func (s *subscriber) run() {
+	defer close(s.out)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		entry := s.queue[0]
+		s.queue = s.queue[1:]
+		entry.Dropped += s.dropped
+		s.dropped = 0
+		s.mu.Unlock()
 
-	// Clear buffers
-	lb.buffer = nil
-	lb.deploymentBuffer = nil
+		select {
+		case s.out <- entry:
+		case <-s.done:
+			return
+		}
+	}
 }
 
-// generateSubscriberID creates a unique subscriber ID
-func (lb *LogBroker) generateSubscriberID() string {
-	id := lb.subscriberIDSeed
-	lb.subscriberIDSeed++
-	return fmt.Sprintf("general_%d", id)
+func (s *subscriber) stop() {
+	s.stopOnce.Do(func() { close(s.done) })
 }
 
 // StreamHandler wraps another slog.Handler and publishes logs to streams
@@ -294,7 +350,7 @@ func NewStreamHandler(publisher StreamPublisher, next slog.Handler) slog.Handler
 // Handle processes log records and publishes them to streams
 func (sh *StreamHandler) Handle(ctx context.Context, rec slog.Record) error {
 	// Extract deployment ID and other fields
-	var deploymentID, appName string
+	var deploymentID, appName, phase, errorClass string
 	var isDeploymentComplete, isDeploymentFailed, isDeploymentSuccess, isHaloydInitComplete bool
 	var domains []string
 	fields := make(map[string]any)
@@ -314,6 +370,10 @@ func (sh *StreamHandler) Handle(ctx context.Context, rec slog.Record) error {
 			isHaloydInitComplete = attr.Value.Bool()
 		case AttrAppName, AttrApp: // Handle both "appName" and "app"
 			appName = attr.Value.String()
+		case AttrPhase:
+			phase = attr.Value.String()
+		case AttrErrorClass:
+			errorClass = attr.Value.String()
 		case AttrDomains:
 			if arr, ok := attr.Value.Any().([]string); ok {
 				domains = arr
@@ -344,6 +404,10 @@ func (sh *StreamHandler) Handle(ctx context.Context, rec slog.Record) error {
 			isHaloydInitComplete = a.Value.Bool()
 		case AttrAppName, AttrApp: // Handle both "appName" and "app"
 			appName = a.Value.String()
+		case AttrPhase:
+			phase = a.Value.String()
+		case AttrErrorClass:
+			errorClass = a.Value.String()
 		case AttrDomains:
 			if arr, ok := a.Value.Any().([]string); ok {
 				domains = arr
@@ -375,6 +439,16 @@ func (sh *StreamHandler) Handle(ctx context.Context, rec slog.Record) error {
 		IsHaloydInitComplete: isHaloydInitComplete,
 	}
 
+	if phase != "" {
+		entry.Event = &DeploymentEvent{
+			Phase:        phase,
+			App:          appName,
+			DeploymentID: deploymentID,
+			Percent:      phasePercent[phase],
+			ErrorClass:   errorClass,
+		}
+	}
+
 	// Single publish call handles all routing
 	if sh.publisher != nil {
 		sh.publisher.Publish(entry)
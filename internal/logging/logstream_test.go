@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, ch <-chan LogEntry) LogEntry {
+	t.Helper()
+	select {
+	case entry, ok := <-ch:
+		if !ok {
+			t.Fatal("stream closed")
+		}
+		return entry
+	case <-time.After(time.Second):
+		t.Fatal("no entry received")
+	}
+	return LogEntry{}
+}
+
+func TestLogBroker_Resume(t *testing.T) {
+	lb := NewLogBroker()
+	defer lb.Close()
+
+	for i := range 3 {
+		lb.Publish(LogEntry{Message: fmt.Sprint(i)})
+	}
+	ch, id := lb.SubscribeGeneral(0)
+	first := receive(t, ch)
+	second := receive(t, ch)
+	if first.Message != "0" || second.Seq != first.Seq+1 {
+		t.Fatalf("entries = %+v, %+v, want numbered in order", first, second)
+	}
+	lb.Unsubscribe(id)
+
+	// Resuming after the second entry picks up with the third, then new ones.
+	ch, id = lb.SubscribeGeneral(second.Seq)
+	defer lb.Unsubscribe(id)
+	lb.Publish(LogEntry{Message: "3"})
+	if got := receive(t, ch); got.Message != "2" || got.Dropped != 0 {
+		t.Errorf("first resumed entry = %+v, want 2", got)
+	}
+	if got := receive(t, ch); got.Message != "3" {
+		t.Errorf("second resumed entry = %+v, want 3", got)
+	}
+}
+
+func TestLogBroker_ResumeAfterHistory(t *testing.T) {
+	lb := NewLogBroker()
+	defer lb.Close()
+
+	lb.Publish(LogEntry{Message: "first"})
+	ch, id := lb.SubscribeGeneral(0)
+	cursor := receive(t, ch).Seq
+	lb.Unsubscribe(id)
+
+	for i := range historySize + 5 {
+		lb.Publish(LogEntry{Message: fmt.Sprint(i)})
+	}
+	ch, id = lb.SubscribeGeneral(cursor)
+	defer lb.Unsubscribe(id)
+	if got := receive(t, ch); got.Message != "5" || got.Dropped != 5 {
+		t.Errorf("first resumed entry = %+v, want 5 with 5 dropped", got)
+	}
+}
+
+func TestLogBroker_SlowSubscriberDropsOldest(t *testing.T) {
+	lb := NewLogBroker()
+	defer lb.Close()
+
+	ch, id := lb.SubscribeDeployment("d1", 0)
+	defer lb.Unsubscribe(id)
+	other, otherID := lb.SubscribeDeployment("d2", 0)
+	defer lb.Unsubscribe(otherID)
+
+	// Publishing doesn't wait for the reader; the deployment's last entry
+	// still arrives.
+	for i := range subscriberBufferSize + 10 {
+		lb.Publish(LogEntry{Message: fmt.Sprint(i), DeploymentID: "d1"})
+	}
+	lb.Publish(LogEntry{Message: "done", DeploymentID: "d1", IsDeploymentComplete: true})
+	lb.Publish(LogEntry{Message: "other", DeploymentID: "d2"})
+
+	// Every entry is either received or counted as dropped.
+	var received, dropped uint64
+	var last LogEntry
+	for !last.IsDeploymentComplete {
+		last = receive(t, ch)
+		received++
+		dropped += last.Dropped
+	}
+	if dropped == 0 || received+dropped != subscriberBufferSize+11 {
+		t.Errorf("received %d and dropped %d entries, want %d in all with some dropped", received, dropped, subscriberBufferSize+11)
+	}
+	if last.Message != "done" {
+		t.Errorf("last entry = %+v, want done", last)
+	}
+	if got := receive(t, other); got.Message != "other" {
+		t.Errorf("d2 entry = %+v, want only d2's entries", got)
+	}
+}
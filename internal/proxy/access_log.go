@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// accessLogQueueSize is how many lines wait for the writer before new ones
+// are dropped, so a slow log sink never holds up requests.
+const accessLogQueueSize = 4096
+
+// AccessLogSettings control which requests the proxy logs.
+type AccessLogSettings struct {
+	Disabled bool
+	// Sample logs one in every Sample requests with a status below 400;
+	// 0 or 1 logs them all. Errors are always logged.
+	Sample int
+}
+
+// accessLog writes a line per request in the format of slog's text handler,
+// which the rest of the proxy logs with. Lines are encoded into pooled
+// buffers without going through slog and written by a single goroutine, so
+// logging a request allocates nothing and never waits on the output.
+type accessLog struct {
+	out     io.Writer
+	now     func() time.Time
+	lines   chan *[]byte
+	pool    sync.Pool
+	seen    atomic.Uint64
+	dropped atomic.Uint64
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newAccessLog(out io.Writer) *accessLog {
+	a := &accessLog{
+		out:   out,
+		now:   time.Now,
+		lines: make(chan *[]byte, accessLogQueueSize),
+		pool: sync.Pool{New: func() any {
+			buf := make([]byte, 0, 512)
+			return &buf
+		}},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// log queues the line for a request unless settings leave it out.
+func (a *accessLog) log(settings AccessLogSettings, r *http.Request, status int, duration time.Duration) {
+	if settings.Disabled {
+		return
+	}
+	if status < 400 && settings.Sample > 1 && a.seen.Add(1)%uint64(settings.Sample) != 0 {
+		return
+	}
+
+	bp := a.pool.Get().(*[]byte)
+	*bp = appendAccessLine((*bp)[:0], a.now(), r, status, duration)
+	select {
+	case a.lines <- bp:
+	default:
+		a.dropped.Add(1)
+		a.pool.Put(bp)
+	}
+}
+
+// run writes queued lines, flushing whenever the queue runs empty, until
+// close is called.
+func (a *accessLog) run() {
+	defer close(a.done)
+	w := bufio.NewWriterSize(a.out, 64<<10)
+	write := func(bp *[]byte) {
+		w.Write(*bp)
+		a.pool.Put(bp)
+	}
+	flush := func() {
+		if dropped := a.dropped.Swap(0); dropped > 0 {
+			w.Write(appendDroppedLine(nil, a.now(), dropped))
+		}
+		w.Flush()
+	}
+
+	for {
+		select {
+		case bp := <-a.lines:
+			write(bp)
+			if len(a.lines) == 0 {
+				flush()
+			}
+		case <-a.stop:
+			for {
+				select {
+				case bp := <-a.lines:
+					write(bp)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// close writes the lines still queued and stops the writer.
+func (a *accessLog) close() {
+	a.stopOnce.Do(func() { close(a.stop) })
+	<-a.done
+}
+
+// appendAccessLine appends the line slog's text handler would write for
+//
+//	logger.Info("request", "method", ..., "user_agent", ...)
+func appendAccessLine(buf []byte, now time.Time, r *http.Request, status int, duration time.Duration) []byte {
+	buf = appendLinePrefix(buf, now, "INFO", "request")
+	buf = appendTextAttr(buf, "method", r.Method)
+	buf = appendTextAttr(buf, "host", r.Host)
+	buf = appendTextAttr(buf, "path", r.URL.Path)
+	buf = append(buf, " status="...)
+	buf = strconv.AppendInt(buf, int64(status), 10)
+	buf = append(buf, " duration_ms="...)
+	buf = strconv.AppendInt(buf, duration.Milliseconds(), 10)
+	buf = appendTextAttr(buf, "remote_addr", r.RemoteAddr)
+	buf = appendTextAttr(buf, "user_agent", r.UserAgent())
+	return append(buf, '\n')
+}
+
+func appendDroppedLine(buf []byte, now time.Time, dropped uint64) []byte {
+	buf = appendLinePrefix(buf, now, "WARN", "Access log lines dropped, the log output is too slow")
+	buf = append(buf, " count="...)
+	buf = strconv.AppendUint(buf, dropped, 10)
+	return append(buf, '\n')
+}
+
+func appendLinePrefix(buf []byte, now time.Time, level, msg string) []byte {
+	buf = append(buf, "time="...)
+	buf = now.AppendFormat(buf, "2006-01-02T15:04:05.000Z07:00")
+	buf = append(buf, " level="...)
+	buf = append(buf, level...)
+	return appendTextAttr(buf, "msg", msg)
+}
+
+func appendTextAttr(buf []byte, key, value string) []byte {
+	buf = append(buf, ' ')
+	buf = append(buf, key...)
+	buf = append(buf, '=')
+	if needsQuoting(value) {
+		return strconv.AppendQuote(buf, value)
+	}
+	return append(buf, value...)
+}
+
+// needsQuoting follows slog's text handler, which quotes empty values and
+// values with spaces, quotes or '='. Non-ASCII values are always quoted, which
+// slog only does for unprintable ones.
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= utf8.RuneSelf || c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+			return true
+		}
+	}
+	return false
+}
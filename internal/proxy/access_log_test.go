@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendAccessLine_MatchesSlog(t *testing.T) {
+	r := httptest.NewRequest("GET", "https://example.com/a%20b?q=1", nil)
+	r.RemoteAddr = "203.0.113.7:51234"
+	r.Header.Set("User-Agent", `curl/8.5 "test"`)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 6_000_000, time.UTC)
+
+	var want bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&want, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				a.Value = slog.TimeValue(now)
+			}
+			return a
+		},
+	}))
+	logger.Info("request",
+		"method", r.Method,
+		"host", r.Host,
+		"path", r.URL.Path,
+		"status", 502,
+		"duration_ms", int64(1234),
+		"remote_addr", r.RemoteAddr,
+		"user_agent", r.UserAgent(),
+	)
+
+	got := appendAccessLine(nil, now, r, 502, 1234*time.Millisecond)
+	if string(got) != want.String() {
+		t.Errorf("appendAccessLine() =\n%s\nwant\n%s", got, want.String())
+	}
+}
+
+func TestAccessLog_Sampling(t *testing.T) {
+	var out bytes.Buffer
+	a := newAccessLog(&out)
+	settings := AccessLogSettings{Sample: 10}
+
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	for range 100 {
+		a.log(settings, r, 200, time.Millisecond)
+	}
+	for range 3 {
+		a.log(settings, r, 503, time.Millisecond)
+	}
+	a.log(AccessLogSettings{Disabled: true}, r, 500, time.Millisecond)
+	a.close()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var ok, failed int
+	for _, line := range lines {
+		switch {
+		case strings.Contains(line, " status=200 "):
+			ok++
+		case strings.Contains(line, " status=503 "):
+			failed++
+		}
+	}
+	if ok != 10 {
+		t.Errorf("logged %d of 100 successful requests, want 10", ok)
+	}
+	if failed != 3 {
+		t.Errorf("logged %d of 3 failed requests, want all", failed)
+	}
+	if len(lines) != ok+failed {
+		t.Errorf("logged %d lines, want %d:\n%s", len(lines), ok+failed, out.String())
+	}
+}
+
+func TestAppendAccessLine_DoesNotAllocate(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://example.com/path", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64)")
+	buf := make([]byte, 0, 512)
+	now := time.Now()
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		buf = appendAccessLine(buf[:0], now, r, 200, time.Millisecond)
+	})
+	if allocs > 0 {
+		t.Errorf("encoding a request allocated %v times, want 0", allocs)
+	}
+}
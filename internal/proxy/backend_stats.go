@@ -0,0 +1,308 @@
+package proxy
+
+import (
+	"log/slog"
+	"maps"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+const (
+	// statsWindow is how many of a backend's most recent requests its error
+	// rate and p95 latency are computed from.
+	statsWindow = 100
+	// minStatsSamples is how many requests a backend needs in its window
+	// before it can be flagged, so a single slow request doesn't.
+	minStatsSamples = 20
+	// ejectDuration is how long an ejected backend is out of rotation before
+	// it gets traffic again.
+	ejectDuration = 30 * time.Second
+
+	// failureBurst is how many failed requests in a row get a backend
+	// reported to the failure handler; a failed dial is reported right away.
+	failureBurst = 3
+	// failureReportInterval limits the reports for one backend.
+	failureReportInterval = time.Second
+	// recentFailuresKept is how many failed requests of a backend are kept
+	// as samples for alerts.
+	recentFailuresKept = 5
+
+	defaultMaxLatency   = 5 * time.Second
+	defaultMaxErrorRate = 0.5
+)
+
+// PassiveHealthSettings are the thresholds for flagging a backend from the
+// requests it serves. Zero fields use the defaults.
+type PassiveHealthSettings struct {
+	// MaxLatency is the highest acceptable p95 time to response headers.
+	MaxLatency time.Duration
+	// MaxErrorRate is the highest acceptable share of failed requests:
+	// transport errors and 5xx responses.
+	MaxErrorRate float64
+	// Eject takes flagged backends out of rotation instead of only logging.
+	Eject bool
+}
+
+func (s PassiveHealthSettings) maxLatency() time.Duration {
+	if s.MaxLatency > 0 {
+		return s.MaxLatency
+	}
+	return defaultMaxLatency
+}
+
+func (s PassiveHealthSettings) maxErrorRate() float64 {
+	if s.MaxErrorRate > 0 {
+		return s.MaxErrorRate
+	}
+	return defaultMaxErrorRate
+}
+
+// BackendStat is a snapshot of the traffic sent to one backend.
+type BackendStat struct {
+	Address    string
+	InFlight   int64
+	Requests   uint64
+	Errors     uint64
+	ErrorRate  float64
+	P95Latency time.Duration
+	Flagged    bool
+	Ejected    bool
+	// LatencyBuckets counts requests by latency, bounded by
+	// proxywire.LatencyBucketBounds.
+	LatencyBuckets []uint64
+	RecentFailures []proxywire.FailedRequest
+}
+
+// backendStats is the traffic record of one backend. The window is a ring
+// of the most recent requests.
+type backendStats struct {
+	inFlight atomic.Int64
+
+	mu           sync.Mutex
+	requests     uint64
+	errors       uint64
+	latencies    [statsWindow]time.Duration
+	failed       [statsWindow]bool
+	samples      int // requests in the window, up to statsWindow
+	next         int // ring index of the next request
+	flagged      bool
+	ejectedUntil time.Time
+	// latencyBuckets counts every request, unlike the window.
+	latencyBuckets []uint64
+	// recentFailures is a ring of the last failed requests.
+	recentFailures []proxywire.FailedRequest
+	nextFailure    int
+
+	consecutiveFailures int
+	lastReport          time.Time
+}
+
+// windowLocked returns the error rate and p95 latency of the window.
+// Callers hold s.mu.
+func (s *backendStats) windowLocked() (errorRate float64, p95 time.Duration) {
+	if s.samples == 0 {
+		return 0, 0
+	}
+	latencies := slices.Clone(s.latencies[:s.samples])
+	slices.Sort(latencies)
+	failures := 0
+	for _, failed := range s.failed[:s.samples] {
+		if failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(s.samples), latencies[(s.samples*95-1)/100]
+}
+
+// backendTracker records the traffic of every backend, keyed by address, and
+// flags backends whose latency or error rate exceed the thresholds.
+type backendTracker struct {
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu       sync.Mutex
+	backends map[string]*backendStats
+}
+
+func newBackendTracker(logger *slog.Logger) *backendTracker {
+	return &backendTracker{
+		logger:   logger,
+		now:      time.Now,
+		backends: make(map[string]*backendStats),
+	}
+}
+
+func (t *backendTracker) get(addr string) *backendStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.backends[addr]
+	if !ok {
+		s = &backendStats{latencyBuckets: make([]uint64, len(proxywire.LatencyBucketBounds)+1)}
+		t.backends[addr] = s
+	}
+	return s
+}
+
+// start counts a request to addr as in flight until the returned func is
+// called.
+func (t *backendTracker) start(addr string) func() {
+	s := t.get(addr)
+	s.inFlight.Add(1)
+	return func() { s.inFlight.Add(-1) }
+}
+
+// record adds the outcome of a request to addr and re-evaluates the backend
+// against settings. canEject is false when addr is the last backend of its
+// route still in rotation.
+func (t *backendTracker) record(addr string, latency time.Duration, failed bool, settings PassiveHealthSettings, canEject bool) {
+	s := t.get(addr)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if failed {
+		s.errors++
+		s.consecutiveFailures++
+	} else {
+		s.consecutiveFailures = 0
+	}
+	bucket, _ := slices.BinarySearch(proxywire.LatencyBucketBounds, latency)
+	s.latencyBuckets[bucket]++
+	s.latencies[s.next] = latency
+	s.failed[s.next] = failed
+	s.next = (s.next + 1) % statsWindow
+	s.samples = min(s.samples+1, statsWindow)
+	if s.samples < minStatsSamples {
+		return
+	}
+
+	errorRate, p95 := s.windowLocked()
+	overLatency := p95 > settings.maxLatency()
+	overErrors := errorRate > settings.maxErrorRate()
+	switch {
+	case (overLatency || overErrors) && !s.flagged:
+		s.flagged = true
+		t.logger.Warn("Backend exceeds passive health thresholds",
+			"backend", addr,
+			"p95_latency_ms", p95.Milliseconds(),
+			"error_rate", errorRate,
+			"slow", overLatency,
+			"failing", overErrors)
+	case !overLatency && !overErrors && s.flagged:
+		s.flagged = false
+		t.logger.Info("Backend is back within passive health thresholds", "backend", addr)
+	}
+
+	if s.flagged && settings.Eject && canEject {
+		s.ejectedUntil = t.now().Add(ejectDuration)
+		// Judge the backend afresh once it's back in rotation.
+		s.samples, s.next, s.flagged = 0, 0, false
+		t.logger.Warn("Backend ejected from rotation", "backend", addr, "duration", ejectDuration.String())
+	}
+}
+
+// noteFailure keeps f as one of the recent failures of addr.
+func (t *backendTracker) noteFailure(addr string, f proxywire.FailedRequest) {
+	s := t.get(addr)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.recentFailures) < recentFailuresKept {
+		s.recentFailures = append(s.recentFailures, f)
+		return
+	}
+	s.recentFailures[s.nextFailure] = f
+	s.nextFailure = (s.nextFailure + 1) % recentFailuresKept
+}
+
+// shouldReport reports whether addr's latest failure should be passed to the
+// failure handler: the backend couldn't be dialed, or it failed
+// failureBurst requests in a row, and it wasn't reported within the last
+// failureReportInterval.
+func (t *backendTracker) shouldReport(addr string, dialFailed bool) bool {
+	s := t.get(addr)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !dialFailed && s.consecutiveFailures < failureBurst {
+		return false
+	}
+	now := t.now()
+	if now.Sub(s.lastReport) < failureReportInterval {
+		return false
+	}
+	s.lastReport = now
+	return true
+}
+
+// ejected reports whether addr is out of rotation.
+func (t *backendTracker) ejected(addr string) bool {
+	t.mu.Lock()
+	s, ok := t.backends[addr]
+	t.mu.Unlock()
+	if !ok {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return t.now().Before(s.ejectedUntil)
+}
+
+// retain drops the records of backends config no longer routes to.
+func (t *backendTracker) retain(config *Config) {
+	routed := make(map[string]struct{})
+	for _, route := range config.routes {
+		for _, b := range route.Backends {
+			routed[b.addr()] = struct{}{}
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for addr := range t.backends {
+		if _, ok := routed[addr]; !ok {
+			delete(t.backends, addr)
+		}
+	}
+}
+
+// stats returns the stats of every tracked backend, ordered by address.
+func (t *backendTracker) stats() []BackendStat {
+	t.mu.Lock()
+	addrs := make([]string, 0, len(t.backends))
+	backends := make([]*backendStats, 0, len(t.backends))
+	for _, addr := range slices.Sorted(maps.Keys(t.backends)) {
+		addrs = append(addrs, addr)
+		backends = append(backends, t.backends[addr])
+	}
+	t.mu.Unlock()
+
+	now := t.now()
+	stats := make([]BackendStat, len(backends))
+	for i, s := range backends {
+		s.mu.Lock()
+		errorRate, p95 := s.windowLocked()
+		stats[i] = BackendStat{
+			Address:    addrs[i],
+			InFlight:   s.inFlight.Load(),
+			Requests:   s.requests,
+			Errors:     s.errors,
+			ErrorRate:  errorRate,
+			P95Latency: p95,
+			Flagged:    s.flagged,
+			Ejected:    now.Before(s.ejectedUntil),
+
+			LatencyBuckets: slices.Clone(s.latencyBuckets),
+			RecentFailures: append(slices.Clone(s.recentFailures[s.nextFailure:]), s.recentFailures[:s.nextFailure]...),
+		}
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+// addr returns the backend's host:port address.
+func (b Backend) addr() string {
+	return net.JoinHostPort(b.IP, b.Port)
+}
@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+func TestBackendTrackerFlagsAndRecovers(t *testing.T) {
+	tracker := newBackendTracker(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	settings := PassiveHealthSettings{MaxLatency: time.Second, MaxErrorRate: 0.2}
+	const addr = "10.0.0.2:8080"
+
+	for range minStatsSamples - 1 {
+		tracker.record(addr, 2*time.Second, false, settings, true)
+	}
+	if stats := tracker.stats(); stats[0].Flagged {
+		t.Fatal("backend flagged before it had enough requests")
+	}
+	tracker.record(addr, 2*time.Second, false, settings, true)
+	stats := tracker.stats()
+	if !stats[0].Flagged || stats[0].P95Latency != 2*time.Second {
+		t.Fatalf("stats = %+v, want a flagged backend with 2s p95 latency", stats[0])
+	}
+	if stats[0].Ejected {
+		t.Fatal("backend ejected without Eject set")
+	}
+
+	for range statsWindow {
+		tracker.record(addr, 10*time.Millisecond, false, settings, true)
+	}
+	if stats := tracker.stats(); stats[0].Flagged || stats[0].Requests != statsWindow+minStatsSamples {
+		t.Fatalf("stats = %+v, want a recovered backend", stats[0])
+	}
+}
+
+func TestBackendTrackerEjects(t *testing.T) {
+	tracker := newBackendTracker(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+	settings := PassiveHealthSettings{MaxErrorRate: 0.5, Eject: true}
+
+	for range minStatsSamples {
+		tracker.record("10.0.0.2:8080", time.Millisecond, true, settings, false)
+		tracker.record("10.0.0.3:8080", time.Millisecond, true, settings, true)
+	}
+	if tracker.ejected("10.0.0.2:8080") {
+		t.Error("the last backend in rotation was ejected")
+	}
+	if !tracker.ejected("10.0.0.3:8080") {
+		t.Fatal("failing backend was not ejected")
+	}
+
+	now = now.Add(ejectDuration + time.Second)
+	if tracker.ejected("10.0.0.3:8080") {
+		t.Error("backend still ejected after the ejection period")
+	}
+}
+
+func TestProxyToBackend_EjectsFailingBackend(t *testing.T) {
+	var goodRequests, badRequests atomic.Int32
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		goodRequests.Add(1)
+	}))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		badRequests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+
+	backendFor := func(server *httptest.Server) Backend {
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		host, port, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Backend{IP: host, Port: port}
+	}
+
+	p := newTestProxy()
+	rb := NewRouteBuilder()
+	rb.AddRoute("example.com", nil, []Backend{backendFor(good), backendFor(bad)})
+	rb.SetPassiveHealth(PassiveHealthSettings{Eject: true})
+	config, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.UpdateConfig(config)
+	route := config.FindRoute("example.com")
+
+	serve := func() {
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		p.proxyToBackend(httptest.NewRecorder(), r, route, time.Now())
+	}
+	for range 2 * minStatsSamples {
+		serve()
+	}
+	if got := badRequests.Load(); got != minStatsSamples {
+		t.Fatalf("failing backend got %d requests, want %d before it is ejected", got, minStatsSamples)
+	}
+
+	for range 10 {
+		serve()
+	}
+	if good, bad := goodRequests.Load(), badRequests.Load(); bad != minStatsSamples || good != minStatsSamples+10 {
+		t.Errorf("requests after ejection: good %d, bad %d; want all on the good backend", good, bad)
+	}
+}
+
+func TestProxyToBackend_ReportsFailingBackend(t *testing.T) {
+	deadListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadHost, deadPort, err := net.SplitHostPort(deadListener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadListener.Close()
+
+	p := newTestProxy()
+	var reported []string
+	p.SetBackendFailureHandler(func(addr string) { reported = append(reported, addr) })
+	route := &Route{Canonical: "example.com", Backends: []Backend{{IP: deadHost, Port: deadPort}}}
+
+	for range 3 {
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		p.proxyToBackend(httptest.NewRecorder(), r, route, time.Now())
+	}
+
+	// A failed dial is reported right away, then at most once a second.
+	if want := net.JoinHostPort(deadHost, deadPort); len(reported) != 1 || reported[0] != want {
+		t.Errorf("reported = %v, want [%s]", reported, want)
+	}
+}
+
+func TestBackendTrackerReportsFailureBursts(t *testing.T) {
+	tracker := newBackendTracker(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	const addr = "10.0.0.2:8080"
+
+	for range failureBurst - 1 {
+		tracker.record(addr, time.Millisecond, true, PassiveHealthSettings{}, true)
+		if tracker.shouldReport(addr, false) {
+			t.Fatal("backend reported before a full burst of failures")
+		}
+	}
+	tracker.record(addr, time.Millisecond, true, PassiveHealthSettings{}, true)
+	if !tracker.shouldReport(addr, false) {
+		t.Fatal("backend not reported after a burst of failures")
+	}
+}
+
+func TestBackendTrackerLatencyBucketsAndFailures(t *testing.T) {
+	tracker := newBackendTracker(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	const addr = "10.0.0.2:8080"
+
+	tracker.record(addr, 5*time.Millisecond, false, PassiveHealthSettings{}, true)
+	tracker.record(addr, 10*time.Millisecond, false, PassiveHealthSettings{}, true)
+	tracker.record(addr, time.Minute, true, PassiveHealthSettings{}, true)
+	for i := range recentFailuresKept + 2 {
+		tracker.noteFailure(addr, proxywire.FailedRequest{RequestID: fmt.Sprint(i), Status: http.StatusBadGateway})
+	}
+
+	stats := tracker.stats()[0]
+	if len(stats.LatencyBuckets) != len(proxywire.LatencyBucketBounds)+1 {
+		t.Fatalf("latency buckets = %v, want one per bound and one above", stats.LatencyBuckets)
+	}
+	if stats.LatencyBuckets[0] != 2 || stats.LatencyBuckets[len(stats.LatencyBuckets)-1] != 1 {
+		t.Errorf("latency buckets = %v, want 2 up to 10ms and 1 above all bounds", stats.LatencyBuckets)
+	}
+	if len(stats.RecentFailures) != recentFailuresKept || stats.RecentFailures[0].RequestID != "2" || stats.RecentFailures[recentFailuresKept-1].RequestID != "6" {
+		t.Errorf("recent failures = %+v, want the last %d, oldest first", stats.RecentFailures, recentFailuresKept)
+	}
+}
+
+func TestProxyToBackend_RecordsFailedRequests(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(requestIDHeader, "backend-id")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+	u, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := newTestProxy()
+	route := &Route{Canonical: "example.com", Backends: []Backend{{IP: host, Port: port}}}
+	r := httptest.NewRequest(http.MethodPost, "https://example.com/checkout", nil)
+	p.proxyToBackend(httptest.NewRecorder(), r, route, time.Now())
+
+	stats := p.BackendStats()
+	if len(stats) != 1 || len(stats[0].RecentFailures) != 1 {
+		t.Fatalf("stats = %+v, want one failed request", stats)
+	}
+	failure := stats[0].RecentFailures[0]
+	if failure.RequestID != "backend-id" || failure.Status != http.StatusServiceUnavailable || failure.Method != http.MethodPost || failure.Path != "/checkout" {
+		t.Errorf("failed request = %+v, want the backend's request ID, status and the request", failure)
+	}
+}
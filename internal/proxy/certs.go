@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"log/slog"
 	"math/big"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,7 +26,12 @@ type CertManager struct {
 	logger  *slog.Logger
 
 	mu    sync.RWMutex
-	certs map[string]*tls.Certificate // domain -> certificate
+	certs map[string]*certEntry // domain -> certificate
+
+	// ocspClient fetches OCSP responses; ocspWake starts a stapling round
+	// after certificates were reloaded.
+	ocspClient *http.Client
+	ocspWake   chan struct{}
 
 	// defaultCert is a self-signed certificate returned for connections without SNI.
 	// This prevents TLS handshake errors from being logged for scanner/bot traffic.
@@ -34,14 +40,43 @@ type CertManager struct {
 	// routes is the current routing snapshot, used to resolve aliases to
 	// canonical domains and to restrict disk lookups to known domains.
 	routes atomic.Pointer[Config]
+
+	// onDemand is told about unknown server names when the route table has
+	// on-demand TLS enabled. onDemandSeen rate-limits it per name.
+	onDemand     func(domain string)
+	onDemandMu   sync.Mutex
+	onDemandSeen map[string]time.Time
+}
+
+// certEntry is a parsed certificate together with the size and modification
+// time of its file, so reloads only parse the files that changed.
+type certEntry struct {
+	cert    *tls.Certificate
+	modTime time.Time
+	size    int64
+
+	// ocspRefresh is when the stapled OCSP response should be replaced and
+	// ocspExpiry when it stops being valid. Both are zero without a staple.
+	ocspRefresh time.Time
+	ocspExpiry  time.Time
 }
 
+const (
+	// onDemandCooldown is how long an unknown name isn't reported again.
+	onDemandCooldown = time.Minute
+	// onDemandMaxSeen bounds the names remembered for the cooldown, as SNI
+	// values are attacker-controlled.
+	onDemandMaxSeen = 10000
+)
+
 // NewCertManager creates a new certificate manager.
 func NewCertManager(certDir string, logger *slog.Logger) (*CertManager, error) {
 	cm := &CertManager{
-		certDir: certDir,
-		logger:  logger,
-		certs:   make(map[string]*tls.Certificate),
+		certDir:    certDir,
+		logger:     logger,
+		certs:      make(map[string]*certEntry),
+		ocspClient: &http.Client{Timeout: 10 * time.Second},
+		ocspWake:   make(chan struct{}, 1),
 	}
 
 	// Generate default self-signed certificate for connections without SNI
@@ -96,6 +131,39 @@ func generateSelfSignedCert() (*tls.Certificate, error) {
 	return cert, nil
 }
 
+// SetOnDemandHandler sets the function told about TLS handshakes for unknown
+// domains while on-demand TLS is enabled. It is called from the handshake and
+// must not block.
+func (cm *CertManager) SetOnDemandHandler(fn func(domain string)) {
+	cm.onDemandMu.Lock()
+	defer cm.onDemandMu.Unlock()
+	cm.onDemand = fn
+	cm.onDemandSeen = make(map[string]time.Time)
+}
+
+// reportUnknown passes an unknown server name to the on-demand handler, at
+// most once per onDemandCooldown.
+func (cm *CertManager) reportUnknown(domain string) {
+	cm.onDemandMu.Lock()
+	if cm.onDemand == nil {
+		cm.onDemandMu.Unlock()
+		return
+	}
+	now := time.Now()
+	if last, ok := cm.onDemandSeen[domain]; ok && now.Sub(last) < onDemandCooldown {
+		cm.onDemandMu.Unlock()
+		return
+	}
+	if len(cm.onDemandSeen) >= onDemandMaxSeen {
+		clear(cm.onDemandSeen)
+	}
+	cm.onDemandSeen[domain] = now
+	fn := cm.onDemand
+	cm.onDemandMu.Unlock()
+
+	fn(domain)
+}
+
 // SetRouteTable updates the routing snapshot used for alias resolution and
 // known-host checks. Proxy.UpdateConfig calls this automatically.
 func (cm *CertManager) SetRouteTable(config *Config) {
@@ -104,22 +172,24 @@ func (cm *CertManager) SetRouteTable(config *Config) {
 
 func (cm *CertManager) getCachedCertificate(domain string) (*tls.Certificate, bool) {
 	cm.mu.RLock()
-	cert, ok := cm.certs[domain]
-	cm.mu.RUnlock()
-	return cert, ok
+	defer cm.mu.RUnlock()
+	entry, ok := cm.certs[domain]
+	if !ok {
+		return nil, false
+	}
+	return entry.cert, true
 }
 
 func (cm *CertManager) loadAndCacheCertificate(domain string) (*tls.Certificate, error) {
-	cert, err := cm.loadCertificate(domain)
+	entry, err := cm.loadCertificate(domain)
 	if err != nil {
 		return nil, err
 	}
 
 	cm.mu.Lock()
-	cm.certs[domain] = cert
-	cm.mu.Unlock()
-
-	return cert, nil
+	defer cm.mu.Unlock()
+	cm.certs[domain] = entry
+	return entry.cert, nil
 }
 
 // wildcardDomain returns a one-level wildcard domain for the provided hostname.
@@ -165,6 +235,9 @@ func (cm *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certific
 	routes := cm.routes.Load()
 	known := routes == nil || routes.IsKnownHost(serverName)
 	if !known {
+		if routes.OnDemandTLS() {
+			cm.reportUnknown(serverName)
+		}
 		return cm.defaultCert, nil
 	}
 
@@ -207,7 +280,14 @@ func (cm *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certific
 // ReloadCertificates reloads all certificates from disk.
 func (cm *CertManager) ReloadCertificates() error {
 	cm.logger.Info("Reloading certificates")
-	return cm.loadAllCertificates()
+	if err := cm.loadAllCertificates(); err != nil {
+		return err
+	}
+	select {
+	case cm.ocspWake <- struct{}{}:
+	default:
+	}
+	return nil
 }
 
 // CertCount returns the number of certificates currently cached.
@@ -228,7 +308,7 @@ func (cm *CertManager) loadAllCertificates() error {
 		return fmt.Errorf("failed to read certificate directory: %w", err)
 	}
 
-	newCerts := make(map[string]*tls.Certificate)
+	newCerts := make(map[string]*certEntry)
 
 	for _, entry := range entries {
 		if entry.IsDir() {
@@ -244,7 +324,7 @@ func (cm *CertManager) loadAllCertificates() error {
 		domain := strings.TrimSuffix(name, ".pem")
 		domain = strings.ToLower(domain)
 
-		cert, err := cm.loadCertificate(domain)
+		entry, err := cm.loadCertificate(domain)
 		if err != nil {
 			cm.logger.Warn("Failed to load certificate",
 				"domain", domain,
@@ -252,7 +332,7 @@ func (cm *CertManager) loadAllCertificates() error {
 			continue
 		}
 
-		newCerts[domain] = cert
+		newCerts[domain] = entry
 		cm.logger.Debug("Loaded certificate", "domain", domain)
 	}
 
@@ -264,10 +344,22 @@ func (cm *CertManager) loadAllCertificates() error {
 	return nil
 }
 
-// loadCertificate loads a certificate for a specific domain.
-func (cm *CertManager) loadCertificate(domain string) (*tls.Certificate, error) {
+// loadCertificate loads a certificate for a specific domain. The cached
+// entry, including its OCSP staple, is reused while the file is unchanged.
+func (cm *CertManager) loadCertificate(domain string) (*certEntry, error) {
 	certPath := filepath.Join(cm.certDir, domain+".pem")
 
+	info, err := os.Stat(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+	cm.mu.RLock()
+	cached, ok := cm.certs[domain]
+	cm.mu.RUnlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached, nil
+	}
+
 	// The .pem file contains both the private key and certificate (combined format)
 	certData, err := os.ReadFile(certPath)
 	if err != nil {
@@ -279,5 +371,5 @@ func (cm *CertManager) loadCertificate(domain string) (*tls.Certificate, error)
 		return nil, fmt.Errorf("failed to parse certificate: %w", err)
 	}
 
-	return &cert, nil
+	return &certEntry{cert: &cert, modTime: info.ModTime(), size: info.Size()}, nil
 }
@@ -252,3 +252,83 @@ func writeTestCert(t *testing.T, dir, domain string) {
 func writeFile(path string, contents []byte) error {
 	return os.WriteFile(path, contents, 0o600)
 }
+
+func TestCertManagerReportsUnknownForOnDemandTLS(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cm, err := NewCertManager(t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("NewCertManager() error = %v", err)
+	}
+	var reported []string
+	cm.SetOnDemandHandler(func(domain string) { reported = append(reported, domain) })
+
+	rb := NewRouteBuilder()
+	rb.AddRoute("example.com", nil, nil)
+	config, err := rb.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	cm.SetRouteTable(config)
+
+	if _, err := cm.GetCertificate(&tls.ClientHelloInfo{ServerName: "customer.example.org"}); err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	if len(reported) != 0 {
+		t.Fatalf("reported %v with on-demand TLS disabled", reported)
+	}
+
+	rb.SetOnDemandTLS(true)
+	if config, err = rb.Build(); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	cm.SetRouteTable(config)
+
+	for range 2 {
+		cert, err := cm.GetCertificate(&tls.ClientHelloInfo{ServerName: "customer.example.org"})
+		if err != nil {
+			t.Fatalf("GetCertificate() error = %v", err)
+		}
+		if cert != cm.defaultCert {
+			t.Error("unknown domain should get the default cert until its certificate exists")
+		}
+	}
+	cm.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+
+	if len(reported) != 1 || reported[0] != "customer.example.org" {
+		t.Errorf("reported = %v, want the unknown domain once", reported)
+	}
+}
+
+func TestCertManagerReloadReusesUnchangedCertificates(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCert(t, dir, "example.com")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cm, err := NewCertManager(dir, logger)
+	if err != nil {
+		t.Fatalf("NewCertManager() error = %v", err)
+	}
+	first, ok := cm.getCachedCertificate("example.com")
+	if !ok {
+		t.Fatal("certificate was not loaded")
+	}
+
+	if err := cm.ReloadCertificates(); err != nil {
+		t.Fatalf("ReloadCertificates() error = %v", err)
+	}
+	if cert, _ := cm.getCachedCertificate("example.com"); cert != first {
+		t.Error("ReloadCertificates() parsed an unchanged certificate again")
+	}
+
+	writeTestCert(t, dir, "example.com")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, "example.com.pem"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.ReloadCertificates(); err != nil {
+		t.Fatalf("ReloadCertificates() error = %v", err)
+	}
+	if cert, _ := cm.getCachedCertificate("example.com"); cert == first {
+		t.Error("ReloadCertificates() kept a certificate whose file changed")
+	}
+}
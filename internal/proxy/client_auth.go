@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+// Headers carrying the verified client certificate to the backend. Values
+// sent by clients are always dropped.
+const (
+	headerClientCertSubject = "X-Client-Cert-Subject"
+	headerClientCertIssuer  = "X-Client-Cert-Issuer"
+)
+
+// ClientAuth is the client certificate authentication of a route.
+type ClientAuth struct {
+	// Mode is constants.ClientAuthRequire or constants.ClientAuthVerifyIfGiven.
+	Mode string
+	// CA is the PEM encoded CA certificates client certificates must chain to.
+	CA string
+
+	pool *x509.CertPool
+}
+
+// NewClientAuth parses the PEM encoded caPEM into the client auth of a route.
+func NewClientAuth(caPEM, mode string) (*ClientAuth, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(caPEM)) {
+		return nil, errors.New("no PEM encoded certificate in client auth CA")
+	}
+	return &ClientAuth{Mode: mode, CA: caPEM, pool: pool}, nil
+}
+
+func clientAuthFromSnapshot(ca *proxywire.ClientAuth) (*ClientAuth, error) {
+	if ca == nil {
+		return nil, nil
+	}
+	return NewClientAuth(ca.CA, ca.Mode)
+}
+
+// wire returns the wire form of ca, which may be nil.
+func (ca *ClientAuth) wire() *proxywire.ClientAuth {
+	if ca == nil {
+		return nil
+	}
+	return &proxywire.ClientAuth{CA: ca.CA, Mode: ca.Mode}
+}
+
+// required reports whether clients without a certificate are rejected. Modes
+// this proxy doesn't know fail closed.
+func (ca *ClientAuth) required() bool {
+	return ca.Mode != constants.ClientAuthVerifyIfGiven
+}
+
+// verify returns the client certificate of the connection if it chains to
+// the route's CA. The handshake already verified it, but against the CAs of
+// every route on the host, so it is checked again for this route.
+func (ca *ClientAuth) verify(state *tls.ConnectionState) *x509.Certificate {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	leaf := state.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         ca.pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil
+	}
+	return leaf
+}
+
+// authorizeClient replaces the client certificate headers of r with those of
+// the certificate the client authenticated with for route. It returns 0 when
+// the request may go on, or the status to reject it with.
+func (p *Proxy) authorizeClient(r *http.Request, route *Route, config *Config) int {
+	r.Header.Del(headerClientCertSubject)
+	r.Header.Del(headerClientCertIssuer)
+
+	ca := route.Options.ClientAuth
+	if ca == nil {
+		return 0
+	}
+	if cert := ca.verify(r.TLS); cert != nil {
+		r.Header.Set(headerClientCertSubject, cert.Subject.String())
+		r.Header.Set(headerClientCertIssuer, cert.Issuer.String())
+		return 0
+	}
+	if !ca.required() {
+		return 0
+	}
+	// Clients reuse connections for other hosts the server certificate
+	// covers. If the handshake was for another host, the client wasn't asked
+	// for this route's certificate; 421 makes it connect again.
+	if r.TLS != nil {
+		if canonical, _ := config.ResolveCanonical(r.TLS.ServerName); canonical != route.Canonical {
+			return http.StatusMisdirectedRequest
+		}
+	}
+	return http.StatusForbidden
+}
+
+// clientAuthTLSConfigs returns the handshake TLS configs of the hosts with
+// client auth, by canonical domain. Routes on a host share the handshake, so
+// it asks for a certificate from any of their CAs, and requires one only if
+// every route does.
+func (p *Proxy) clientAuthTLSConfigs(config *Config) map[string]*tls.Config {
+	byCanonical := make(map[string][]*Route)
+	for _, route := range config.routes {
+		if route.Options.ClientAuth != nil {
+			byCanonical[route.Canonical] = nil
+		}
+	}
+	if len(byCanonical) == 0 {
+		return nil
+	}
+	for _, route := range config.routes {
+		if routes, ok := byCanonical[route.Canonical]; ok {
+			byCanonical[route.Canonical] = append(routes, route)
+		}
+	}
+
+	configs := make(map[string]*tls.Config, len(byCanonical))
+	for canonical, routes := range byCanonical {
+		pool := x509.NewCertPool()
+		clientAuth := tls.RequireAndVerifyClientCert
+		for _, route := range routes {
+			ca := route.Options.ClientAuth
+			if ca == nil || !ca.required() {
+				clientAuth = tls.VerifyClientCertIfGiven
+			}
+			if ca != nil {
+				pool.AppendCertsFromPEM([]byte(ca.CA))
+			}
+		}
+		tlsConfig := p.tlsConfigFor(config.TLS())
+		if tlsConfig == nil {
+			tlsConfig = p.baseTLSConfig()
+		}
+		tlsConfig.ClientAuth = clientAuth
+		tlsConfig.ClientCAs = pool
+		configs[canonical] = tlsConfig
+	}
+	return configs
+}
+
+// handshakeTLS returns the TLS config for a handshake for serverName, or nil
+// for the listener's own.
+func (p *Proxy) handshakeTLS(serverName string) *tls.Config {
+	if configs := p.clientAuthTLS.Load(); configs != nil && serverName != "" {
+		if canonical, ok := p.config.Load().ResolveCanonical(serverName); ok {
+			if tlsConfig, ok := (*configs)[canonical]; ok {
+				return tlsConfig
+			}
+		}
+	}
+	return p.clientTLS.Load()
+}
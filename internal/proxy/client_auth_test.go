@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// testClientCert returns a client auth for the cluster test CA, and a
+// certificate it issued.
+func testClientCert(t *testing.T, mode string) (*ClientAuth, *x509.Certificate) {
+	t.Helper()
+	caFile, certFile, _ := writeClusterCerts(t, t.TempDir())
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := NewClientAuth(string(caPEM), mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, cert
+}
+
+func TestHTTPSHandler_ClientAuth(t *testing.T) {
+	received := make(chan http.Header, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+	}))
+	defer backend.Close()
+	host, port := backendAddr(t, backend)
+	backends := []Backend{{IP: host, Port: port}}
+
+	required, cert := testClientCert(t, constants.ClientAuthRequire)
+	optional, _ := testClientCert(t, constants.ClientAuthVerifyIfGiven)
+	_, otherCert := testClientCert(t, constants.ClientAuthRequire)
+
+	rb := NewRouteBuilder()
+	rb.AddRouteWithOptions("internal.example.com", nil, backends, RouteOptions{ClientAuth: required})
+	rb.AddRouteWithOptions("partners.example.com", nil, backends, RouteOptions{ClientAuth: optional})
+	rb.AddRouteWithOptions("www.example.com", nil, backends, RouteOptions{})
+	cfg, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(slog.New(slog.NewTextHandler(io.Discard, nil)), stubCertLoader{})
+	p.UpdateConfig(cfg)
+	handler := p.httpsHandler()
+
+	tests := []struct {
+		name        string
+		host        string
+		serverName  string
+		cert        *x509.Certificate
+		wantStatus  int
+		wantSubject string
+	}{
+		{"verified certificate", "internal.example.com", "internal.example.com", cert, http.StatusOK, "CN=node"},
+		{"no certificate", "internal.example.com", "internal.example.com", nil, http.StatusForbidden, ""},
+		{"certificate from another CA", "internal.example.com", "internal.example.com", otherCert, http.StatusForbidden, ""},
+		{"connection for another host", "internal.example.com", "www.example.com", nil, http.StatusMisdirectedRequest, ""},
+		{"optional without certificate", "partners.example.com", "partners.example.com", nil, http.StatusOK, ""},
+		{"no client auth drops the client's header", "www.example.com", "www.example.com", nil, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://"+tt.host+"/", nil)
+			r.Header.Set(headerClientCertSubject, "CN=spoofed")
+			r.TLS.ServerName = tt.serverName
+			if tt.cert != nil {
+				r.TLS.PeerCertificates = []*x509.Certificate{tt.cert}
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := (<-received).Get(headerClientCertSubject); got != tt.wantSubject {
+				t.Errorf("%s = %q, want %q", headerClientCertSubject, got, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestHandshakeTLS_ClientAuth(t *testing.T) {
+	required, _ := testClientCert(t, constants.ClientAuthRequire)
+	optional, _ := testClientCert(t, constants.ClientAuthVerifyIfGiven)
+
+	rb := NewRouteBuilder()
+	rb.AddRouteWithOptions("internal.example.com", []string{"intranet.example.com"}, nil, RouteOptions{ClientAuth: required})
+	rb.AddRouteWithOptions("app.example.com", nil, nil, RouteOptions{PathPrefix: "/admin", ClientAuth: required})
+	rb.AddRouteWithOptions("app.example.com", nil, nil, RouteOptions{PathPrefix: "/partners", ClientAuth: optional})
+	rb.AddRouteWithOptions("www.example.com", nil, nil, RouteOptions{})
+	cfg, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(slog.New(slog.NewTextHandler(io.Discard, nil)), stubCertLoader{})
+	p.UpdateConfig(cfg)
+
+	tests := []struct {
+		serverName string
+		want       tls.ClientAuthType
+	}{
+		{"internal.example.com", tls.RequireAndVerifyClientCert},
+		{"Intranet.example.com", tls.RequireAndVerifyClientCert},
+		{"app.example.com", tls.VerifyClientCertIfGiven},
+		{"www.example.com", tls.NoClientCert},
+		{"", tls.NoClientCert},
+	}
+	for _, tt := range tests {
+		got := tls.NoClientCert
+		if tlsConfig := p.handshakeTLS(tt.serverName); tlsConfig != nil {
+			got = tlsConfig.ClientAuth
+		}
+		if got != tt.want {
+			t.Errorf("handshake for %q asks %v, want %v", tt.serverName, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// cloudflarePrefixes are Cloudflare's published edge ranges
+// (https://www.cloudflare.com/ips/).
+var cloudflarePrefixes = mustParsePrefixes(
+	"173.245.48.0/20",
+	"103.21.244.0/22",
+	"103.22.200.0/22",
+	"103.31.4.0/22",
+	"141.101.64.0/18",
+	"108.162.192.0/18",
+	"190.93.240.0/20",
+	"188.114.96.0/20",
+	"197.234.240.0/22",
+	"198.41.128.0/17",
+	"162.158.0.0/15",
+	"104.16.0.0/13",
+	"104.24.0.0/14",
+	"172.64.0.0/13",
+	"131.0.72.0/22",
+	"2400:cb00::/32",
+	"2606:4700::/32",
+	"2803:f800::/32",
+	"2405:b500::/32",
+	"2405:8100::/32",
+	"2a06:98c0::/29",
+	"2c0f:f248::/32",
+)
+
+func mustParsePrefixes(prefixes ...string) []netip.Prefix {
+	parsed := make([]netip.Prefix, len(prefixes))
+	for i, p := range prefixes {
+		parsed[i] = netip.MustParsePrefix(p)
+	}
+	return parsed
+}
+
+func isCloudflareAddr(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range cloudflarePrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client behind r. For routes behind
+// Cloudflare, CF-Connecting-IP is used when the connection comes from one of
+// Cloudflare's ranges; from anywhere else the header is spoofed and ignored.
+func clientIP(r *http.Request, cdn string) (string, bool) {
+	peer, ok := forwardedFor(r.RemoteAddr)
+	if !ok || cdn != constants.CDNCloudflare || !isCloudflareAddr(peer) {
+		return peer, ok
+	}
+	header := strings.TrimSpace(r.Header.Get("CF-Connecting-IP"))
+	if addr, err := netip.ParseAddr(header); err == nil {
+		return addr.WithZone("").String(), true
+	}
+	return peer, ok
+}
+
+// isTrustedCDNRequest reports whether r reached the proxy through the CDN in
+// front of its route, so the CDN's own headers can be passed on.
+func isTrustedCDNRequest(r *http.Request, cdn string) bool {
+	if cdn != constants.CDNCloudflare {
+		return false
+	}
+	peer, ok := forwardedFor(r.RemoteAddr)
+	return ok && isCloudflareAddr(peer)
+}
@@ -0,0 +1,318 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+// ClusterSettings configure the proxy's part in a cluster of haloy servers.
+// Build them with LoadClusterSettings.
+type ClusterSettings struct {
+	// Listen is the address of the peer listener, which serves this server's
+	// apps to an edge; empty opens none.
+	Listen string
+
+	// files identifies the TLS files the settings were loaded from, so an
+	// unchanged snapshot doesn't restart the peer listener.
+	files string
+	cert  tls.Certificate
+	// ca verifies peers; nil means peers talk plain HTTP.
+	ca *x509.CertPool
+}
+
+// LoadClusterSettings loads the cluster's mutual TLS files. With all three
+// empty, peer traffic is plain HTTP.
+func LoadClusterSettings(listen, caFile, certFile, keyFile string) (*ClusterSettings, error) {
+	settings := &ClusterSettings{Listen: listen, files: caFile + "\x00" + certFile + "\x00" + keyFile}
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return settings, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load cluster certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read cluster CA: %w", err)
+	}
+	ca := x509.NewCertPool()
+	if !ca.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("cluster CA %s contains no certificates", caFile)
+	}
+	settings.cert = cert
+	settings.ca = ca
+	return settings, nil
+}
+
+func (c *ClusterSettings) equal(other *ClusterSettings) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	return c.Listen == other.Listen && c.files == other.files
+}
+
+// usesTLS reports whether peer traffic uses mutual TLS.
+func (c *ClusterSettings) usesTLS() bool {
+	return c != nil && c.ca != nil
+}
+
+// serverTLS is the peer listener's TLS config: only peers with a certificate
+// signed by the cluster CA get in.
+func (c *ClusterSettings) serverTLS() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{c.cert},
+		ClientCAs:    c.ca,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS13,
+	}
+}
+
+// ClientTLS returns the TLS config for connections to peers, or nil when
+// peers talk plain HTTP. Peers are addressed by IP, so their certificate is
+// verified against the cluster CA without matching a name.
+func (c *ClusterSettings) ClientTLS() *tls.Config {
+	if !c.usesTLS() {
+		return nil
+	}
+	return &tls.Config{
+		Certificates:       []tls.Certificate{c.cert},
+		MinVersion:         tls.VersionTLS13,
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("peer sent no certificate")
+			}
+			intermediates := x509.NewCertPool()
+			for _, cert := range cs.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+				Roots:         c.ca,
+				Intermediates: intermediates,
+			})
+			return err
+		},
+	}
+}
+
+// newPeerTransport returns the transport for requests to peers.
+func newPeerTransport(settings *ClusterSettings) *http.Transport {
+	return &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   20,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       settings.ClientTLS(),
+	}
+}
+
+// applyCluster opens, moves or closes the peer listener and replaces the
+// transport to peers when the cluster settings change.
+func (p *Proxy) applyCluster(settings *ClusterSettings) {
+	p.clusterMu.Lock()
+	defer p.clusterMu.Unlock()
+
+	if p.cluster.equal(settings) {
+		return
+	}
+	p.cluster = settings
+
+	var transport *http.Transport
+	if settings != nil {
+		transport = newPeerTransport(settings)
+	}
+	if old := p.peerTransport.Swap(transport); old != nil {
+		old.CloseIdleConnections()
+	}
+
+	if p.peerServer != nil {
+		p.peerServer.Close()
+		p.peerServer = nil
+	}
+	if settings == nil || settings.Listen == "" {
+		return
+	}
+
+	p.shutdownMu.Lock()
+	isShutdown := p.isShutdown
+	p.shutdownMu.Unlock()
+	if isShutdown {
+		return
+	}
+
+	listener, err := net.Listen("tcp", settings.Listen)
+	if err != nil {
+		// Apps on this server stay reachable directly; only the edge loses
+		// them, and it leaves this server out once it stops answering.
+		p.logger.Error("Failed to open cluster peer listener", "addr", settings.Listen, "error", err)
+		return
+	}
+	server := &http.Server{
+		Handler:           p.peerHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       90 * time.Second,
+	}
+	if settings.usesTLS() {
+		server.TLSConfig = settings.serverTLS()
+		listener = tls.NewListener(listener, server.TLSConfig)
+	}
+	p.peerServer = server
+	go func() {
+		p.logger.Info("Cluster peer listener listening", "addr", listener.Addr().String(), "mtls", settings.usesTLS())
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			p.logger.Error("Cluster peer listener error", "error", err)
+		}
+	}()
+}
+
+// shutdownCluster stops the peer listener.
+func (p *Proxy) shutdownCluster(ctx context.Context) error {
+	p.clusterMu.Lock()
+	server := p.peerServer
+	p.peerServer = nil
+	p.clusterMu.Unlock()
+
+	if t := p.peerTransport.Load(); t != nil {
+		t.CloseIdleConnections()
+	}
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// edgeContextKey marks requests that came in through the peer listener.
+type edgeContextKey struct{}
+
+// peerHandler serves traffic an edge forwards to this server. Only this
+// server's backends are used, so a request never travels on to another peer.
+func (p *Proxy) peerHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+
+		if r.URL.Path == proxywire.PeerRoutesPath {
+			p.servePeerRoutes(w)
+			return
+		}
+
+		r = fromEdge(r)
+		route := p.config.Load().FindRouteForPath(extractHost(r.Host), r.URL.Path)
+		if route == nil {
+			p.serveErrorPage(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		route = route.localRoute()
+
+		if isWebSocketUpgrade(r) {
+			p.handleWebSocket(w, r, route, startTime)
+			return
+		}
+		if len(route.Backends) == 0 {
+			p.logRequest(r, http.StatusBadGateway, time.Since(startTime))
+			p.serveRouteErrorPage(w, r, route, http.StatusBadGateway, "No healthy backends available for this application")
+			return
+		}
+		p.proxyToBackend(w, r, route, startTime)
+	})
+}
+
+// fromEdge takes the client address and scheme of a request from the
+// headers the edge set. The peer listener only accepts edge traffic, so they
+// are trusted.
+func fromEdge(r *http.Request) *http.Request {
+	forwardedFor := r.Header.Get("X-Forwarded-For")
+	if i := strings.LastIndex(forwardedFor, ","); i >= 0 {
+		forwardedFor = forwardedFor[i+1:]
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(forwardedFor)); err == nil {
+		r.RemoteAddr = netip.AddrPortFrom(addr.WithZone(""), 0).String()
+	}
+	proto := r.Header.Get("X-Forwarded-Proto")
+	if proto != "http" {
+		proto = "https"
+	}
+	return r.WithContext(context.WithValue(r.Context(), edgeContextKey{}, proto))
+}
+
+// edgeProto returns the scheme the client used to reach the edge, for
+// requests that came in through the peer listener.
+func edgeProto(r *http.Request) (string, bool) {
+	proto, ok := r.Context().Value(edgeContextKey{}).(string)
+	return proto, ok
+}
+
+// servePeerRoutes lists the routes this server serves with its own backends.
+// Routes with SSO or client certificates are only listed over mutual TLS:
+// the edge gets the SSO client secret, which also signs session cookies,
+// and a route it can't get stays off the edge rather than unprotected.
+func (p *Proxy) servePeerRoutes(w http.ResponseWriter) {
+	p.clusterMu.Lock()
+	mtls := p.cluster.usesTLS()
+	p.clusterMu.Unlock()
+
+	config := p.config.Load()
+	routes := make([]proxywire.Route, 0, len(config.routes))
+	for _, route := range config.routes {
+		if len(route.localRoute().Backends) == 0 {
+			continue
+		}
+		if !mtls && (route.Options.SSO != nil || route.Options.ClientAuth != nil) {
+			continue
+		}
+		routes = append(routes, proxywire.Route{
+			Canonical:     route.Canonical,
+			Aliases:       route.Aliases,
+			App:           route.Options.App,
+			PathPrefix:    route.Options.PathPrefix,
+			StripPrefix:   route.Options.StripPrefix,
+			CDN:           route.Options.CDN,
+			MaxBodyBytes:  route.Options.MaxBodyBytes,
+			ReadTimeoutMS: route.Options.ReadTimeout.Milliseconds(),
+			SendTimeoutMS: route.Options.SendTimeout.Milliseconds(),
+			Redirects:     wireRedirects(route.Options.Redirects),
+
+			URLNormalization: route.Options.URLNormalization.wire(),
+			ClientAuth:       route.Options.ClientAuth.wire(),
+			SSO:              route.Options.SSO.wire(),
+		})
+	}
+	proxywire.SortRoutes(routes)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(proxywire.PeerRoutes{Routes: routes}); err != nil {
+		p.logger.Debug("Failed to write peer routes", "error", err)
+	}
+}
+
+// dialBackend connects to backend for a WebSocket tunnel.
+func (p *Proxy) dialBackend(backend Backend) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if backend.Peer != "" {
+		p.clusterMu.Lock()
+		settings := p.cluster
+		p.clusterMu.Unlock()
+		if tlsConfig := settings.ClientTLS(); tlsConfig != nil {
+			return tls.DialWithDialer(dialer, "tcp", backend.addr(), tlsConfig)
+		}
+	}
+	return dialer.Dial("tcp", backend.addr())
+}
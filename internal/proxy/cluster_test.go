@@ -0,0 +1,288 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+// backendAddr returns the host and port of a test server.
+func backendAddr(t *testing.T, server *httptest.Server) (string, string) {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host, port
+}
+
+// writeClusterCerts writes a cluster CA and a node certificate signed by it
+// to dir and returns the CA, certificate and key paths.
+func writeClusterCerts(t *testing.T, dir string) (caFile, certFile, keyFile string) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Cluster CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	nodeDER, err := x509.CreateCertificate(rand.Reader, nodeTemplate, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile = filepath.Join(dir, "ca.pem")
+	certFile = filepath.Join(dir, "node.pem")
+	keyFile = filepath.Join(dir, "node-key.pem")
+	files := map[string][]byte{
+		caFile:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		certFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: nodeDER}),
+		keyFile:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}),
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return caFile, certFile, keyFile
+}
+
+func TestPeerHandlerServesLocalBackends(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer backend.Close()
+	host, port := backendAddr(t, backend)
+
+	rb := NewRouteBuilder()
+	rb.AddRouteWithOptions("app.example.com", nil, []Backend{
+		{IP: host, Port: port},
+		{IP: "192.0.2.1", Port: "8443", Peer: "b"},
+	}, RouteOptions{PathPrefix: "/api", StripPrefix: true})
+	cfg, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newTestProxy()
+	p.UpdateConfig(cfg)
+
+	// Round-robin would pick the peer every other request; the peer listener
+	// must never forward to another peer.
+	for range 2 {
+		r := httptest.NewRequest(http.MethodGet, "http://app.example.com/api/users", nil)
+		r.RemoteAddr = "192.0.2.10:50000"
+		r.Header.Set("X-Forwarded-For", "198.51.100.7")
+		r.Header.Set("X-Forwarded-Proto", "https")
+		w := httptest.NewRecorder()
+		p.peerHandler().ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+
+		got := <-received
+		if got.URL.Path != "/users" {
+			t.Errorf("path = %q, want the prefix stripped by the peer", got.URL.Path)
+		}
+		if forwardedFor := got.Header.Get("X-Forwarded-For"); forwardedFor != "198.51.100.7" {
+			t.Errorf("X-Forwarded-For = %q, want the client IP from the edge", forwardedFor)
+		}
+		if proto := got.Header.Get("X-Forwarded-Proto"); proto != "https" {
+			t.Errorf("X-Forwarded-Proto = %q, want the scheme the client used at the edge", proto)
+		}
+	}
+}
+
+func TestPeerRoutesListsLocalRoutes(t *testing.T) {
+	rb := NewRouteBuilder()
+	rb.AddRouteWithOptions("app.example.com", []string{"www.app.example.com"}, []Backend{{IP: "10.0.0.5", Port: "8080"}},
+		RouteOptions{App: "app", MaxBodyBytes: 1024})
+	rb.AddRoute("remote.example.com", nil, []Backend{{IP: "192.0.2.1", Port: "8443", Peer: "b"}})
+	rb.AddRoute("down.example.com", nil, nil)
+	// The peer listener talks plain HTTP, which must not carry the client
+	// secret.
+	rb.AddRouteWithOptions("admin.example.com", nil, []Backend{{IP: "10.0.0.6", Port: "8080"}},
+		RouteOptions{App: "admin", SSO: &SSO{ClientID: "admin", ClientSecret: "s3cret"}})
+	cfg, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newTestProxy()
+	p.UpdateConfig(cfg)
+
+	w := httptest.NewRecorder()
+	p.peerHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, proxywire.PeerRoutesPath, nil))
+
+	var got proxywire.PeerRoutes
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decode peer routes: %v", err)
+	}
+	if len(got.Routes) != 1 {
+		t.Fatalf("routes = %+v, want only app.example.com", got.Routes)
+	}
+	if strings.Contains(w.Body.String(), "s3cret") {
+		t.Errorf("peer routes = %s, want no SSO client secret", w.Body.String())
+	}
+	route := got.Routes[0]
+	if route.Canonical != "app.example.com" || route.App != "app" || route.MaxBodyBytes != 1024 ||
+		len(route.Aliases) != 1 || len(route.Backends) != 0 {
+		t.Errorf("route = %+v, want app.example.com with its alias and limits and no backends", route)
+	}
+}
+
+func TestEdgeForwardsToPeerOverMutualTLS(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer backend.Close()
+	backendHost, backendPort := backendAddr(t, backend)
+
+	caFile, certFile, keyFile := writeClusterCerts(t, t.TempDir())
+
+	// Reserve a port for the peer listener.
+	reserved, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerAddr := reserved.Addr().String()
+	reserved.Close()
+	peerHost, peerPort, err := net.SplitHostPort(peerAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerSettings, err := LoadClusterSettings(peerAddr, caFile, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerRB := NewRouteBuilder()
+	peerRB.SetCluster(peerSettings)
+	peerRB.AddRouteWithOptions("app.example.com", nil, []Backend{{IP: backendHost, Port: backendPort}},
+		RouteOptions{PathPrefix: "/api", StripPrefix: true})
+	peerCfg, err := peerRB.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer := newTestProxy()
+	peer.UpdateConfig(peerCfg)
+	defer peer.shutdownCluster(t.Context())
+
+	edgeSettings, err := LoadClusterSettings("", caFile, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edgeRB := NewRouteBuilder()
+	edgeRB.SetCluster(edgeSettings)
+	edgeRB.AddRouteWithOptions("app.example.com", nil, []Backend{{IP: peerHost, Port: peerPort, Peer: "b"}},
+		RouteOptions{PathPrefix: "/api", StripPrefix: true})
+	edgeCfg, err := edgeRB.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	edge := newTestProxy()
+	edge.UpdateConfig(edgeCfg)
+	defer edge.shutdownCluster(t.Context())
+
+	r := httptest.NewRequest(http.MethodGet, "https://app.example.com/api/users", nil)
+	r.RemoteAddr = "198.51.100.7:40000"
+	w := httptest.NewRecorder()
+	edge.httpsHandler().ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %q, want the peer's response", w.Code, w.Body.String())
+	}
+
+	got := <-received
+	if got.Host != "app.example.com" || got.URL.Path != "/users" {
+		t.Errorf("backend got host %q path %q, want app.example.com /users", got.Host, got.URL.Path)
+	}
+	if forwardedFor := got.Header.Get("X-Forwarded-For"); forwardedFor != "198.51.100.7" {
+		t.Errorf("X-Forwarded-For = %q, want the client IP", forwardedFor)
+	}
+}
+
+func TestPeerListenerRejectsClientsWithoutCertificate(t *testing.T) {
+	caFile, certFile, keyFile := writeClusterCerts(t, t.TempDir())
+	reserved, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerAddr := reserved.Addr().String()
+	reserved.Close()
+
+	settings, err := LoadClusterSettings(peerAddr, caFile, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb := NewRouteBuilder()
+	rb.SetCluster(settings)
+	cfg, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newTestProxy()
+	p.UpdateConfig(cfg)
+	defer p.shutdownCluster(t.Context())
+
+	tlsConfig := settings.ClientTLS()
+	tlsConfig.Certificates = nil
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}, Timeout: 5 * time.Second}
+	resp, err := client.Get("https://" + peerAddr + proxywire.PeerRoutesPath)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("request without a client certificate got status %d, want the handshake to fail", resp.StatusCode)
+	}
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/haloydev/haloy/internal/errorpages"
+)
+
+// requestIDHeader carries the ID shown on error pages, so a user's report
+// can be matched to the proxy and app logs.
+const requestIDHeader = "X-Request-ID"
+
+// SetErrorPages sets the store custom app error pages are loaded from.
+// Without one, every error uses the built-in page.
+func (p *Proxy) SetErrorPages(store *errorpages.Store) {
+	p.errorPages = store
+}
+
+// serveRouteErrorPage serves the route's custom error page for statusCode,
+// falling back to the built-in page when the app has none or it fails to render.
+func (p *Proxy) serveRouteErrorPage(w http.ResponseWriter, r *http.Request, route *Route, statusCode int, message string) {
+	p.serveRouteErrorPageWithID(w, r, route, statusCode, message, requestIDFor(r))
+}
+
+// serveRouteErrorPageWithID is serveRouteErrorPage for a request ID the
+// caller already recorded.
+func (p *Proxy) serveRouteErrorPageWithID(w http.ResponseWriter, r *http.Request, route *Route, statusCode int, message, requestID string) {
+	w.Header().Set(requestIDHeader, requestID)
+
+	body, ok, err := p.errorPages.Render(route.Options.App, errorpages.PageData{
+		Status:     statusCode,
+		StatusText: http.StatusText(statusCode),
+		Message:    message,
+		RequestID:  requestID,
+		Host:       r.Host,
+		Path:       r.URL.Path,
+	})
+	if err != nil {
+		p.logger.Warn("Custom error page failed, using default",
+			"app", route.Options.App,
+			"status", statusCode,
+			"error", err)
+	}
+	if !ok {
+		p.serveErrorPage(w, statusCode, message)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// requestIDFor returns the request's X-Request-ID when it is a sane token,
+// or a new random ID.
+func requestIDFor(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); validRequestID(id) {
+		return id
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether id is a sane token to pass on as a request
+// ID.
+func validRequestID(id string) bool {
+	return id != "" && len(id) <= 128 && isPrintableASCII(id)
+}
+
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x21 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
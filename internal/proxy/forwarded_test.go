@@ -11,6 +11,8 @@ import (
 	"net/url"
 	"testing"
 	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
 )
 
 func newTestProxy() *Proxy {
@@ -95,7 +97,7 @@ func TestSetForwardedHeaders(t *testing.T) {
 			r.Header.Set("X-Real-IP", "5.6.7.8")
 			r.Header.Set("Forwarded", "for=1.2.3.4")
 
-			setForwardedHeaders(r)
+			setForwardedHeaders(r, "")
 
 			if got := r.Header.Get("X-Forwarded-For"); got != "203.0.113.7" {
 				t.Errorf("X-Forwarded-For = %q, want %q", got, "203.0.113.7")
@@ -202,3 +204,109 @@ func TestHandleWebSocket_ForwardedHeaders(t *testing.T) {
 		t.Errorf("Forwarded = %q, want it stripped", got)
 	}
 }
+
+func TestForwardedFor(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+		wantOK     bool
+	}{
+		{"203.0.113.7:5000", "203.0.113.7", true},
+		{"[2001:db8::7]:5000", "2001:db8::7", true},
+		{"[fe80::1%eth0]:5000", "fe80::1", true},
+		{"203.0.113.7", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := forwardedFor(tt.remoteAddr)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("forwardedFor(%q) = %q, %v, want %q, %v", tt.remoteAddr, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestSetForwardedHeaders_IPv6(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	r.RemoteAddr = "[2001:db8::7]:5000"
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+
+	setForwardedHeaders(r, "")
+
+	if got := r.Header.Get("X-Forwarded-For"); got != "2001:db8::7" {
+		t.Errorf("X-Forwarded-For = %q, want %q", got, "2001:db8::7")
+	}
+}
+
+func TestListenNetwork(t *testing.T) {
+	tests := map[string]string{
+		":80":          "tcp",
+		"0.0.0.0:80":   "tcp4",
+		"[::]:80":      "tcp6",
+		"[::1]:8088":   "tcp6",
+		"localhost:80": "tcp",
+	}
+	for addr, want := range tests {
+		if got := listenNetwork(addr); got != want {
+			t.Errorf("listenNetwork(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestListenAll(t *testing.T) {
+	listeners, err := listenAll("127.0.0.1:0, 127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listenAll() error = %v", err)
+	}
+	defer closeListeners(listeners)
+	if len(listeners) != 2 {
+		t.Fatalf("listenAll() returned %d listeners, want 2", len(listeners))
+	}
+
+	if _, err := listenAll(" , "); err == nil {
+		t.Error("listenAll() without addresses should fail")
+	}
+}
+
+func TestClientIP_Cloudflare(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		cdn        string
+		want       string
+	}{
+		{"cloudflare edge", "173.245.48.10:443", "203.0.113.7", constants.CDNCloudflare, "203.0.113.7"},
+		{"cloudflare edge ipv6", "[2606:4700::1]:443", "2001:db8::7", constants.CDNCloudflare, "2001:db8::7"},
+		{"spoofed header", "198.51.100.9:443", "203.0.113.7", constants.CDNCloudflare, "198.51.100.9"},
+		{"route without cdn", "173.245.48.10:443", "203.0.113.7", "", "173.245.48.10"},
+		{"invalid header", "173.245.48.10:443", "not-an-ip", constants.CDNCloudflare, "173.245.48.10"},
+		{"missing header", "173.245.48.10:443", "", constants.CDNCloudflare, "173.245.48.10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				r.Header.Set("CF-Connecting-IP", tt.header)
+			}
+			got, ok := clientIP(r, tt.cdn)
+			if !ok || got != tt.want {
+				t.Errorf("clientIP() = %q, %v, want %q, true", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetForwardedHeaders_CloudflareSpoofed(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	r.RemoteAddr = "198.51.100.9:5000"
+	r.Header.Set("CF-Connecting-IP", "203.0.113.7")
+
+	setForwardedHeaders(r, constants.CDNCloudflare)
+
+	if got := r.Header.Get("X-Forwarded-For"); got != "198.51.100.9" {
+		t.Errorf("X-Forwarded-For = %q, want %q", got, "198.51.100.9")
+	}
+	if got := r.Header.Get("CF-Connecting-IP"); got != "" {
+		t.Errorf("CF-Connecting-IP = %q, want it stripped for non-Cloudflare peers", got)
+	}
+}
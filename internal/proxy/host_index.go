@@ -0,0 +1,55 @@
+package proxy
+
+import "strings"
+
+// hostIndex maps hosts to their routes, longest path prefix first. Exact
+// hosts are looked up directly. A wildcard host like "*.example.com" is
+// indexed by its parent domain and matches a single label below it, as a
+// wildcard certificate does, so a lookup costs at most two map reads however
+// many domains are routed.
+type hostIndex struct {
+	exact    map[string][]*Route
+	wildcard map[string][]*Route
+}
+
+func newHostIndex(size int) hostIndex {
+	return hostIndex{exact: make(map[string][]*Route, size)}
+}
+
+// add appends route to the routes of host, which is lowercase.
+func (h *hostIndex) add(host string, route *Route) {
+	if parent, ok := strings.CutPrefix(host, "*."); ok {
+		if h.wildcard == nil {
+			h.wildcard = make(map[string][]*Route)
+		}
+		h.wildcard[parent] = append(h.wildcard[parent], route)
+		return
+	}
+	h.exact[host] = append(h.exact[host], route)
+}
+
+// each calls fn with the routes of every host.
+func (h *hostIndex) each(fn func(routes []*Route)) {
+	for _, routes := range h.exact {
+		fn(routes)
+	}
+	for _, routes := range h.wildcard {
+		fn(routes)
+	}
+}
+
+// lookup returns the routes for host. An exact host wins over a wildcard
+// covering it. Case and a trailing dot are ignored.
+func (h hostIndex) lookup(host string) []*Route {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if routes, ok := h.exact[host]; ok {
+		return routes
+	}
+	if len(h.wildcard) == 0 {
+		return nil
+	}
+	if _, parent, ok := strings.Cut(host, "."); ok {
+		return h.wildcard[parent]
+	}
+	return nil
+}
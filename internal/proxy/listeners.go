@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// A listener that stops accepting connections after Start is rebound with
+// backoff. Only when that keeps failing for listenerRetryTimeout is the error
+// delivered on Err(), so the service manager restarts the process.
+var (
+	listenerRetryMin     = time.Second
+	listenerRetryMax     = 30 * time.Second
+	listenerRetryTimeout = 5 * time.Minute
+)
+
+// ListenerState is the state of one HTTP or HTTPS listener.
+type ListenerState struct {
+	Addr     string
+	Protocol string // "http" or "https"
+	Serving  bool
+	// Restarts counts how often the listener was rebound after it died.
+	Restarts  int
+	LastError string
+	// DownSince is when the listener died, zero while it serves.
+	DownSince time.Time
+}
+
+// supervisedListener is a listener and the address it was opened on, which
+// is what it is rebound to.
+type supervisedListener struct {
+	addr     string
+	listener net.Listener
+	state    ListenerState
+}
+
+// ListenerStates returns the state of every listener opened by Start.
+func (p *Proxy) ListenerStates() []ListenerState {
+	p.listenersMu.Lock()
+	defer p.listenersMu.Unlock()
+	states := make([]ListenerState, len(p.listeners))
+	for i, l := range p.listeners {
+		states[i] = l.state
+	}
+	return states
+}
+
+// superviseListeners registers the listeners and serves each of them in its
+// own goroutine.
+func (p *Proxy) superviseListeners(protocol, addrs string, listeners []net.Listener, serve func(net.Listener) error) {
+	p.listenersMu.Lock()
+	supervised := make([]*supervisedListener, len(listeners))
+	for i, listener := range listeners {
+		supervised[i] = &supervisedListener{
+			addr:     rebindAddr(addrs, listener.Addr()),
+			listener: listener,
+			state:    ListenerState{Addr: listener.Addr().String(), Protocol: protocol, Serving: true},
+		}
+	}
+	p.listeners = append(p.listeners, supervised...)
+	p.listenersMu.Unlock()
+
+	for _, l := range supervised {
+		go p.serveListener(l, serve)
+	}
+}
+
+// serveListener serves l until the proxy shuts down, rebinding it whenever it
+// dies.
+func (p *Proxy) serveListener(l *supervisedListener, serve func(net.Listener) error) {
+	for {
+		p.listenersMu.Lock()
+		listener, protocol := l.listener, l.state.Protocol
+		p.listenersMu.Unlock()
+
+		p.logger.Info("Proxy listening", "protocol", protocol, "addr", listener.Addr().String())
+		err := serve(listener)
+		if err == nil || errors.Is(err, http.ErrServerClosed) || p.shuttingDown() {
+			return
+		}
+
+		p.logger.Error("Proxy listener stopped, rebinding", "protocol", protocol, "addr", l.addr, "error", err)
+		p.listenersMu.Lock()
+		l.state.Serving = false
+		l.state.LastError = err.Error()
+		l.state.DownSince = time.Now()
+		p.listenersMu.Unlock()
+
+		listener, err = p.rebind(l.addr)
+		if err != nil {
+			if !p.shuttingDown() {
+				p.logger.Error("Giving up on proxy listener", "protocol", protocol, "addr", l.addr, "error", err)
+				p.fatal(fmt.Errorf("%s listener %s: %w", protocol, l.addr, err))
+			}
+			return
+		}
+
+		p.listenersMu.Lock()
+		p.logger.Info("Proxy listener rebound", "protocol", protocol, "addr", l.addr, "down_for", time.Since(l.state.DownSince).Round(time.Millisecond))
+		l.listener = listener
+		l.state.Serving = true
+		l.state.Restarts++
+		l.state.DownSince = time.Time{}
+		p.listenersMu.Unlock()
+	}
+}
+
+// rebind opens addr again, backing off between attempts. It gives up after
+// listenerRetryTimeout or when the proxy shuts down.
+func (p *Proxy) rebind(addr string) (net.Listener, error) {
+	deadline := time.Now().Add(listenerRetryTimeout)
+	delay := listenerRetryMin
+	for {
+		select {
+		case <-p.stopCh:
+			return nil, http.ErrServerClosed
+		case <-time.After(delay):
+		}
+
+		listener, err := net.Listen(listenNetwork(addr), addr)
+		if err == nil {
+			return listener, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		p.logger.Warn("Failed to rebind proxy listener", "addr", addr, "error", err, "retry_in", delay)
+		delay = min(delay*2, listenerRetryMax)
+	}
+}
+
+// rebindAddr returns the address in addrs that bound was opened on. The
+// configured address is kept, so a dual-stack ":443" doesn't come back
+// IPv6-only as "[::]:443", unless it asked for any free port.
+func rebindAddr(addrs string, bound net.Addr) string {
+	boundHost, boundPort, _ := net.SplitHostPort(bound.String())
+	for addr := range strings.SplitSeq(addrs, ",") {
+		addr = strings.TrimSpace(addr)
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			continue
+		}
+		if port == "0" {
+			if ip := net.ParseIP(host); ip != nil && ip.Equal(net.ParseIP(boundHost)) {
+				return net.JoinHostPort(host, boundPort)
+			}
+			continue
+		}
+		if port == boundPort && (host == "" || host == boundHost || net.ParseIP(host).Equal(net.ParseIP(boundHost))) {
+			return addr
+		}
+	}
+	return bound.String()
+}
+
+func (p *Proxy) shuttingDown() bool {
+	p.shutdownMu.Lock()
+	defer p.shutdownMu.Unlock()
+	return p.isShutdown
+}
+
+// fatal delivers err on Err() without blocking when earlier errors haven't
+// been read.
+func (p *Proxy) fatal(err error) {
+	select {
+	case p.fatalCh <- err:
+	default:
+	}
+}
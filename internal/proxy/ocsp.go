@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// ocspCheckInterval is how often certificates are checked for missing or
+	// stale OCSP staples.
+	ocspCheckInterval = time.Hour
+	// ocspMaxResponseSize bounds the OCSP responder's answer.
+	ocspMaxResponseSize = 1 << 20
+)
+
+var errCertRevoked = errors.New("certificate is revoked")
+
+// StartOCSPStapling staples OCSP responses to the loaded certificates in the
+// background until ctx is done. Responses are refreshed halfway through their
+// validity, and again right after certificates are reloaded. Certificates
+// without an OCSP responder URL or without their issuer in the chain are
+// served without a staple.
+func (cm *CertManager) StartOCSPStapling(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(ocspCheckInterval)
+		defer ticker.Stop()
+		for {
+			cm.refreshOCSP(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-cm.ocspWake:
+			}
+		}
+	}()
+}
+
+// refreshOCSP fetches new OCSP responses for the certificates whose staple is
+// missing or due for a refresh.
+func (cm *CertManager) refreshOCSP(ctx context.Context) {
+	type dueCert struct {
+		domain string
+		entry  *certEntry
+		cert   *tls.Certificate
+	}
+
+	now := time.Now()
+	var due []dueCert
+	cm.mu.RLock()
+	for domain, entry := range cm.certs {
+		if canStaple(entry.cert) && !now.Before(entry.ocspRefresh) {
+			due = append(due, dueCert{domain: domain, entry: entry, cert: entry.cert})
+		}
+	}
+	cm.mu.RUnlock()
+
+	for _, d := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		resp, raw, err := cm.fetchOCSP(ctx, d.cert)
+
+		cm.mu.Lock()
+		// A reload replaces changed certificates with new entries, so
+		// updating a stale entry here is harmless.
+		if err != nil {
+			// Keep a still valid staple through responder outages, but
+			// never one vouching for a revoked certificate.
+			if len(d.entry.cert.OCSPStaple) > 0 && (errors.Is(err, errCertRevoked) || now.After(d.entry.ocspExpiry)) {
+				d.entry.cert = withOCSPStaple(d.entry.cert, nil)
+				d.entry.ocspRefresh, d.entry.ocspExpiry = time.Time{}, time.Time{}
+			}
+		} else {
+			d.entry.cert = withOCSPStaple(d.entry.cert, raw)
+			d.entry.ocspRefresh = ocspRefreshTime(resp, now)
+			d.entry.ocspExpiry = resp.NextUpdate
+		}
+		cm.mu.Unlock()
+
+		if err != nil {
+			cm.logger.Warn("Failed to refresh OCSP staple", "domain", d.domain, "error", err)
+		} else {
+			cm.logger.Debug("Stapled OCSP response", "domain", d.domain, "next_update", resp.NextUpdate)
+		}
+	}
+}
+
+// canStaple reports whether cert names an OCSP responder and carries the
+// issuer needed to build the request.
+func canStaple(cert *tls.Certificate) bool {
+	return cert.Leaf != nil && len(cert.Leaf.OCSPServer) > 0 && len(cert.Certificate) > 1
+}
+
+// withOCSPStaple returns a copy of cert with staple, leaving cert untouched
+// for handshakes still using it.
+func withOCSPStaple(cert *tls.Certificate, staple []byte) *tls.Certificate {
+	stapled := *cert
+	stapled.OCSPStaple = staple
+	return &stapled
+}
+
+// ocspRefreshTime returns the middle of resp's validity window, or an hour
+// from now for responses without a next update.
+func ocspRefreshTime(resp *ocsp.Response, now time.Time) time.Time {
+	if resp.NextUpdate.IsZero() {
+		return now.Add(ocspCheckInterval)
+	}
+	return resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2)
+}
+
+// fetchOCSP asks the certificate's OCSP responder for its status and returns
+// the parsed and raw response. Only a good status is returned without error.
+func (cm *CertManager) fetchOCSP(ctx context.Context, cert *tls.Certificate) (*ocsp.Response, []byte, error) {
+	leaf := cert.Leaf
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse issuer certificate: %w", err)
+	}
+	reqBody, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, leaf.OCSPServer[0], bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	resp, err := cm.ocspClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("OCSP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("OCSP responder returned %s", resp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, ocspMaxResponseSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read OCSP response: %w", err)
+	}
+
+	parsed, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid OCSP response: %w", err)
+	}
+	switch parsed.Status {
+	case ocsp.Good:
+		return parsed, raw, nil
+	case ocsp.Revoked:
+		return nil, nil, errCertRevoked
+	default:
+		return nil, nil, errors.New("OCSP responder doesn't know the certificate")
+	}
+}
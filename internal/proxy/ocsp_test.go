@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspResponder answers OCSP requests for certificates issued by its CA with
+// status.
+type ocspResponder struct {
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	status atomic.Int32
+	calls  atomic.Int32
+}
+
+func (o *ocspResponder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.calls.Add(1)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := ocsp.ParseRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	resp, err := ocsp.CreateResponse(o.ca, o.ca, ocsp.Response{
+		Status:       int(o.status.Load()),
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   now.Add(-time.Hour),
+		NextUpdate:   now.Add(47 * time.Hour),
+		RevokedAt:    now.Add(-time.Hour),
+	}, o.caKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resp)
+}
+
+// writeTestCertWithOCSP writes a certificate for domain, issued by a test CA
+// whose responder is returned, to dir.
+func writeTestCertWithOCSP(t *testing.T, dir, domain string) *ocspResponder {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responder := &ocspResponder{ca: ca, caKey: caKey}
+	server := httptest.NewServer(responder)
+	t.Cleanup(server.Close)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		OCSPServer:   []string{server.URL},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes})
+	pemData = append(pemData, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})...)
+	pemData = append(pemData, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})...)
+	if err := writeFile(filepath.Join(dir, domain+".pem"), pemData); err != nil {
+		t.Fatal(err)
+	}
+	return responder
+}
+
+func TestCertManagerStaplesOCSP(t *testing.T) {
+	dir := t.TempDir()
+	responder := writeTestCertWithOCSP(t, dir, "example.com")
+	writeTestCert(t, dir, "no-ocsp.example.com")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cm, err := NewCertManager(dir, logger)
+	if err != nil {
+		t.Fatalf("NewCertManager() error = %v", err)
+	}
+
+	cm.refreshOCSP(t.Context())
+	cert, err := cm.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	if len(cert.OCSPStaple) == 0 {
+		t.Fatal("certificate has no OCSP staple")
+	}
+	if plain, _ := cm.getCachedCertificate("no-ocsp.example.com"); len(plain.OCSPStaple) != 0 {
+		t.Error("certificate without an OCSP responder was stapled")
+	}
+
+	// A fresh staple isn't fetched again, and survives a reload.
+	cm.refreshOCSP(t.Context())
+	if got := responder.calls.Load(); got != 1 {
+		t.Errorf("responder called %d times, want 1", got)
+	}
+	if err := cm.ReloadCertificates(); err != nil {
+		t.Fatalf("ReloadCertificates() error = %v", err)
+	}
+	if cert, _ := cm.getCachedCertificate("example.com"); len(cert.OCSPStaple) == 0 {
+		t.Error("ReloadCertificates() dropped the OCSP staple of an unchanged certificate")
+	}
+}
+
+func TestCertManagerDropsStapleForRevokedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	responder := writeTestCertWithOCSP(t, dir, "example.com")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cm, err := NewCertManager(dir, logger)
+	if err != nil {
+		t.Fatalf("NewCertManager() error = %v", err)
+	}
+	cm.refreshOCSP(t.Context())
+	if cert, _ := cm.getCachedCertificate("example.com"); len(cert.OCSPStaple) == 0 {
+		t.Fatal("certificate has no OCSP staple")
+	}
+
+	responder.status.Store(ocsp.Revoked)
+	cm.mu.Lock()
+	cm.certs["example.com"].ocspRefresh = time.Time{}
+	cm.mu.Unlock()
+	cm.refreshOCSP(t.Context())
+
+	if cert, _ := cm.getCachedCertificate("example.com"); len(cert.OCSPStaple) != 0 {
+		t.Error("staple kept after the certificate was revoked")
+	}
+}
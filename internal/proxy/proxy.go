@@ -11,20 +11,27 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/netip"
 	"net/url"
+	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/errorpages"
 	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/proxywire"
 )
 
 // Backend represents a backend server that can receive traffic.
 type Backend struct {
 	IP   string
 	Port string
+	// Peer names the cluster peer whose listener is at the address; empty
+	// for a container on this server.
+	Peer string
 }
 
 // Route represents a domain route configuration.
@@ -32,9 +39,21 @@ type Route struct {
 	Canonical string
 	Aliases   []string
 	Backends  []Backend
+	Options   RouteOptions
 
 	// next holds the round-robin backend index for this route.
 	next atomic.Uint32
+	// local is the route with only this server's backends, set when some
+	// backends are cluster peers.
+	local *Route
+}
+
+// localRoute returns the route without its cluster peer backends.
+func (r *Route) localRoute() *Route {
+	if r.local != nil {
+		return r.local
+	}
+	return r
 }
 
 // nextBackend picks the next backend using round-robin selection.
@@ -49,21 +68,55 @@ func (r *Route) nextBackend() Backend {
 // Config is an immutable, validated routing snapshot. Build one with
 // RouteBuilder; the zero value routes nothing.
 type Config struct {
-	// routes maps canonical domains (lowercase) to their route configurations.
+	// routes maps route keys (canonical domain plus path prefix, lowercase
+	// domain) to their route configurations.
 	routes map[string]*Route
-	// hosts is a flat lookup index mapping every canonical domain and alias
-	// (lowercase) to its route.
-	hosts map[string]*Route
+	// hosts indexes every canonical domain and alias (lowercase, possibly a
+	// wildcard) to its routes, longest path prefix first.
+	hosts hostIndex
 	// apiDomain is the domain for the haloy API (lowercase).
 	apiDomain string
 	// apiBackend is the control plane's API listener; the zero value means no
 	// control plane is reachable and API traffic is answered with 503.
 	apiBackend Backend
+	// apiNetworks limits the API domain to clients in these networks; empty
+	// allows everyone.
+	apiNetworks []netip.Prefix
+	// onDemandTLS is set when haloyd issues certificates for unknown domains.
+	onDemandTLS bool
+	// tls overrides the HTTPS handshake settings; nil keeps the defaults.
+	tls *TLSSettings
+	// passiveHealth holds the thresholds for flagging backends.
+	passiveHealth PassiveHealthSettings
+	// accessLog selects the requests that are logged.
+	accessLog AccessLogSettings
+	// cluster is nil unless the server is part of a cluster.
+	cluster *ClusterSettings
+}
+
+// TLSSettings override the handshake settings of the HTTPS listener. Empty
+// fields keep the defaults.
+type TLSSettings struct {
+	MinVersion       uint16
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
 }
 
-// FindRoute returns the route for the given host (canonical or alias), or nil.
+// FindRoute returns the route serving the root path of the given host
+// (canonical or alias), or nil.
 func (c *Config) FindRoute(host string) *Route {
-	return c.hosts[strings.ToLower(host)]
+	return c.FindRouteForPath(host, "/")
+}
+
+// FindRouteForPath returns the route for host whose path prefix is the
+// longest match for path, or nil.
+func (c *Config) FindRouteForPath(host, path string) *Route {
+	for _, route := range c.hosts.lookup(host) {
+		if matchesPathPrefix(path, route.Options.PathPrefix) {
+			return route
+		}
+	}
+	return nil
 }
 
 // APIDomain returns the domain for the haloy API (lowercase).
@@ -77,6 +130,51 @@ func (c *Config) APIBackend() (Backend, bool) {
 	return c.apiBackend, c.apiBackend != Backend{}
 }
 
+// APIAllows reports whether the client at remoteAddr may reach the API
+// domain. Loopback clients always may.
+func (c *Config) APIAllows(remoteAddr string) bool {
+	if len(c.apiNetworks) == 0 || isLoopbackAddr(remoteAddr) {
+		return true
+	}
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	for _, network := range c.apiNetworks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnDemandTLS reports whether unknown TLS server names should be reported for
+// on-demand certificate issuance.
+func (c *Config) OnDemandTLS() bool {
+	return c.onDemandTLS
+}
+
+// TLS returns the HTTPS handshake settings, or nil for the defaults.
+func (c *Config) TLS() *TLSSettings {
+	return c.tls
+}
+
+// PassiveHealth returns the thresholds for flagging backends.
+func (c *Config) PassiveHealth() PassiveHealthSettings {
+	return c.passiveHealth
+}
+
+// AccessLog returns the access log settings.
+func (c *Config) AccessLog() AccessLogSettings {
+	return c.accessLog
+}
+
+// Cluster returns the cluster settings, or nil outside a cluster.
+func (c *Config) Cluster() *ClusterSettings {
+	return c.cluster
+}
+
 // RouteCount returns the number of routes (canonical domains).
 func (c *Config) RouteCount() int {
 	return len(c.routes)
@@ -92,14 +190,14 @@ func (c *Config) IsKnownHost(host string) bool {
 	if c.apiDomain != "" && host == c.apiDomain {
 		return true
 	}
-	_, ok := c.hosts[host]
-	return ok
+	return len(c.hosts.lookup(host)) > 0
 }
 
-// ResolveCanonical resolves a domain (canonical or alias) to its canonical domain.
+// ResolveCanonical resolves a domain (canonical or alias) to its canonical
+// domain. All routes on a host share the same canonical domain.
 func (c *Config) ResolveCanonical(domain string) (string, bool) {
-	if route := c.FindRoute(domain); route != nil {
-		return route.Canonical, true
+	if routes := c.hosts.lookup(domain); len(routes) > 0 {
+		return routes[0].Canonical, true
 	}
 	return "", false
 }
@@ -109,19 +207,54 @@ type Proxy struct {
 	config     atomic.Pointer[Config]
 	certLoader CertLoader
 	logger     *slog.Logger
+	// accessLog writes the line logged for every request.
+	accessLog *accessLog
+
+	// clientTLS is the TLS config for handshakes under the current Config's
+	// TLS settings, or nil for the listener's own.
+	clientTLS atomic.Pointer[tls.Config]
+	// clientAuthTLS holds the TLS configs for handshakes with hosts whose
+	// routes ask for client certificates, by canonical domain.
+	clientAuthTLS atomic.Pointer[map[string]*tls.Config]
 
 	httpServer  *http.Server
 	httpsServer *http.Server
 
+	// listeners are the HTTP and HTTPS listeners, rebound when they die.
+	listenersMu sync.Mutex
+	listeners   []*supervisedListener
 	// fatalCh receives listener errors that occur after Start returned.
 	fatalCh chan error
 
 	// Transport for backend connections with connection pooling
 	transport *http.Transport
+	// sso signs users of routes with SSO in with their identity provider.
+	sso *ssoClient
+	// Custom per-app error pages; nil serves the built-in page for every error.
+	errorPages *errorpages.Store
+	// Transports for routes with custom timeouts, keyed by transportKey.
+	routeTransports sync.Map
+	// backends records the traffic of every backend for passive health
+	// checks.
+	backends *backendTracker
+	// backendFailure is told about backends that can't be dialed or fail
+	// several requests in a row; nil disables the reports.
+	backendFailure atomic.Pointer[func(addr string)]
+
+	// clusterMu guards the cluster settings and the peer listener, which
+	// follow the routing config.
+	clusterMu  sync.Mutex
+	cluster    *ClusterSettings
+	peerServer *http.Server
+	// peerTransport carries requests to cluster peers; nil outside a
+	// cluster.
+	peerTransport atomic.Pointer[http.Transport]
 
 	// For graceful shutdown
 	shutdownMu sync.Mutex
 	isShutdown bool
+	// stopCh is closed by Shutdown, ending listener rebinds.
+	stopCh chan struct{}
 
 	// Active hijacked WebSocket tunnels, tracked so Shutdown can drain them.
 	wsMu    sync.Mutex
@@ -139,7 +272,9 @@ func New(logger *slog.Logger, certLoader CertLoader) *Proxy {
 	p := &Proxy{
 		logger:     logger,
 		certLoader: certLoader,
+		accessLog:  newAccessLog(os.Stdout),
 		fatalCh:    make(chan error, 2),
+		stopCh:     make(chan struct{}),
 		transport: &http.Transport{
 			DialContext: (&net.Dialer{
 				Timeout:   10 * time.Second,
@@ -152,13 +287,15 @@ func New(logger *slog.Logger, certLoader CertLoader) *Proxy {
 			ResponseHeaderTimeout: 60 * time.Second,
 			ExpectContinueTimeout: 1 * time.Second,
 		},
-		wsConns: make(map[net.Conn]struct{}),
+		wsConns:  make(map[net.Conn]struct{}),
+		backends: newBackendTracker(logger),
+		sso:      newSSOClient(),
 	}
 
 	// Initialize with empty config
 	p.config.Store(&Config{
 		routes: make(map[string]*Route),
-		hosts:  make(map[string]*Route),
+		hosts:  newHostIndex(0),
 	})
 
 	return p
@@ -172,6 +309,11 @@ func (p *Proxy) UpdateConfig(config *Config) {
 		return
 	}
 	p.config.Store(config)
+	p.clientTLS.Store(p.tlsConfigFor(config.TLS()))
+	clientAuthTLS := p.clientAuthTLSConfigs(config)
+	p.clientAuthTLS.Store(&clientAuthTLS)
+	p.backends.retain(config)
+	p.applyCluster(config.Cluster())
 	if ra, ok := p.certLoader.(interface{ SetRouteTable(*Config) }); ok {
 		ra.SetRouteTable(config)
 	}
@@ -180,44 +322,107 @@ func (p *Proxy) UpdateConfig(config *Config) {
 		"api_domain", config.APIDomain())
 }
 
+// SetBackendFailureHandler sets the function told about backends that can't
+// be dialed or fail several requests in a row, so an active health
+// check can confirm the failure without waiting for its next round. fn is
+// called synchronously and must not block.
+func (p *Proxy) SetBackendFailureHandler(fn func(addr string)) {
+	p.backendFailure.Store(&fn)
+}
+
+// BackendStats returns the traffic of every routed backend, ordered by
+// address.
+func (p *Proxy) BackendStats() []BackendStat {
+	return p.backends.stats()
+}
+
+// pickBackend returns the route's next backend, skipping ejected backends
+// unless all of them are.
+func (p *Proxy) pickBackend(route *Route) Backend {
+	for range len(route.Backends) {
+		backend := route.nextBackend()
+		if !p.backends.ejected(backend.addr()) {
+			return backend
+		}
+	}
+	return route.nextBackend()
+}
+
+// canEject reports whether the route has a backend other than addr in
+// rotation, so ejecting addr leaves the route with a backend.
+func (p *Proxy) canEject(route *Route, addr string) bool {
+	for _, b := range route.Backends {
+		if other := b.addr(); other != addr && !p.backends.ejected(other) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetConfig returns the current proxy configuration.
 func (p *Proxy) GetConfig() *Config {
 	return p.config.Load()
 }
 
+func (p *Proxy) baseTLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: p.certLoader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+		MinVersion:     tls.VersionTLS12,
+	}
+}
+
+// tlsConfigFor returns the listener's TLS config with settings applied, or
+// nil when settings is nil. It is built once per routing update so
+// handshakes don't clone it.
+func (p *Proxy) tlsConfigFor(settings *TLSSettings) *tls.Config {
+	if settings == nil {
+		return nil
+	}
+	tlsConfig := p.baseTLSConfig()
+	if settings.MinVersion != 0 {
+		tlsConfig.MinVersion = settings.MinVersion
+	}
+	tlsConfig.CipherSuites = settings.CipherSuites
+	tlsConfig.CurvePreferences = settings.CurvePreferences
+	return tlsConfig
+}
+
 // Start binds the HTTP and HTTPS listeners and starts serving. A bind failure
 // is returned immediately; errors after that are delivered on Err().
+//
+// httpAddr and httpsAddr are comma-separated lists of addresses. A wildcard
+// address like ":80" is dual-stack, accepting IPv4 and IPv6 clients on one
+// socket; "0.0.0.0:80,[::]:80" binds each family separately, for hosts where
+// IPv6 sockets don't accept IPv4 traffic.
 func (p *Proxy) Start(httpAddr, httpsAddr string) error {
 	p.logger.Info("Starting proxy", "http_addr", httpAddr, "https_addr", httpsAddr)
 
-	httpListener, err := net.Listen("tcp", httpAddr)
+	httpListeners, err := listenAll(httpAddr)
 	if err != nil {
 		return fmt.Errorf("HTTP listener: %w", err)
 	}
 
-	httpsListener, err := net.Listen("tcp", httpsAddr)
+	httpsListeners, err := listenAll(httpsAddr)
 	if err != nil {
-		httpListener.Close()
+		closeListeners(httpListeners)
 		return fmt.Errorf("HTTPS listener: %w", err)
 	}
 
 	// Create HTTP server (redirects to HTTPS, handles ACME challenges)
 	p.httpServer = &http.Server{
-		Addr:              httpAddr,
 		Handler:           p.httpHandler(),
 		ReadHeaderTimeout: 10 * time.Second,
 		IdleTimeout:       60 * time.Second,
 	}
 
 	// Create HTTPS server with TLS
-	tlsConfig := &tls.Config{
-		GetCertificate: p.certLoader.GetCertificate,
-		NextProtos:     []string{"h2", "http/1.1"},
-		MinVersion:     tls.VersionTLS12,
+	tlsConfig := p.baseTLSConfig()
+	tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		return p.handshakeTLS(hello.ServerName), nil
 	}
 
 	p.httpsServer = &http.Server{
-		Addr:              httpsAddr,
 		Handler:           p.httpsHandler(),
 		TLSConfig:         tlsConfig,
 		ReadHeaderTimeout: 10 * time.Second,
@@ -225,28 +430,65 @@ func (p *Proxy) Start(httpAddr, httpsAddr string) error {
 		ErrorLog:          log.New(io.Discard, "", 0),
 	}
 
-	go func() {
-		p.logger.Info("HTTP server listening", "addr", httpAddr)
-		if err := p.httpServer.Serve(httpListener); err != nil && err != http.ErrServerClosed {
-			p.logger.Error("HTTP server error", "error", err)
-			p.fatalCh <- fmt.Errorf("HTTP server: %w", err)
-		}
-	}()
+	// An http.Server serves any number of listeners, and Shutdown closes
+	// them all.
+	p.superviseListeners("http", httpAddr, httpListeners, p.httpServer.Serve)
+	p.superviseListeners("https", httpsAddr, httpsListeners, func(listener net.Listener) error {
+		return p.httpsServer.ServeTLS(listener, "", "")
+	})
 
-	go func() {
-		p.logger.Info("HTTPS server listening", "addr", httpsAddr)
-		if err := p.httpsServer.ServeTLS(httpsListener, "", ""); err != nil && err != http.ErrServerClosed {
-			p.logger.Error("HTTPS server error", "error", err)
-			p.fatalCh <- fmt.Errorf("HTTPS server: %w", err)
+	return nil
+}
+
+// listenAll opens a TCP listener on every address in the comma-separated
+// addrs. An IP literal picks its family, so "[::]:443" is IPv6-only and can
+// sit next to "0.0.0.0:443"; other addresses listen on both.
+func listenAll(addrs string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for addr := range strings.SplitSeq(addrs, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
 		}
-	}()
+		listener, err := net.Listen(listenNetwork(addr), addr)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no listen address in %q", addrs)
+	}
+	return listeners, nil
+}
 
-	return nil
+func listenNetwork(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "tcp"
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "tcp"
+	case ip.To4() != nil:
+		return "tcp4"
+	default:
+		return "tcp6"
+	}
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, listener := range listeners {
+		listener.Close()
+	}
 }
 
 // Err returns a channel that receives fatal listener errors occurring after
-// Start returned. A value on this channel means the proxy is no longer
-// serving traffic and the process should exit.
+// Start returned, once a dead listener couldn't be rebound. A value on this
+// channel means the proxy is no longer serving traffic and the process should
+// exit.
 func (p *Proxy) Err() <-chan error {
 	return p.fatalCh
 }
@@ -261,6 +503,7 @@ func (p *Proxy) Shutdown(ctx context.Context) error {
 		return nil
 	}
 	p.isShutdown = true
+	close(p.stopCh)
 	p.shutdownMu.Unlock()
 
 	p.logger.Info("Shutting down proxy...")
@@ -279,6 +522,10 @@ func (p *Proxy) Shutdown(ctx context.Context) error {
 		}
 	}
 
+	if err := p.shutdownCluster(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("cluster peer listener shutdown: %w", err))
+	}
+
 	// Hijacked WebSocket connections are not tracked by http.Server.Shutdown,
 	// so drain them separately.
 	wsDone := make(chan struct{})
@@ -301,6 +548,8 @@ func (p *Proxy) Shutdown(ctx context.Context) error {
 	}
 
 	p.transport.CloseIdleConnections()
+	p.closeIdleRouteTransports()
+	p.accessLog.close()
 
 	if len(errs) > 0 {
 		return fmt.Errorf("shutdown errors: %v", errs)
@@ -338,7 +587,8 @@ func (p *Proxy) untrackWebSocket(conns ...net.Conn) {
 
 // httpHandler handles HTTP requests (port 80).
 // It redirects to HTTPS except for ACME challenges and localhost API access.
-// For known routes, it redirects directly to the canonical domain.
+// For known routes, it redirects directly to the canonical URL, applying the
+// route's redirects and URL normalization.
 func (p *Proxy) httpHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Allow ACME challenges through
@@ -358,17 +608,29 @@ func (p *Proxy) httpHandler() http.Handler {
 			return
 		}
 
+		config := p.config.Load()
+
+		// A routed request goes straight to its canonical URL, so it isn't
+		// redirected a second time over HTTPS.
+		if route := config.FindRouteForPath(host, r.URL.Path); route != nil && host != config.APIDomain() {
+			location, status, ok := route.canonicalRedirect(host, r)
+			if !ok {
+				location, status = route.canonicalURL(host, r.URL.EscapedPath(), r.URL.RawQuery), http.StatusMovedPermanently
+			}
+			http.Redirect(w, r, location, status)
+			return
+		}
+
 		// Determine redirect target (default: same host for unknown domains)
 		targetHost := host
 
-		config := p.config.Load()
-
 		// Check if this is the API domain
 		if config.APIDomain() != "" && host == config.APIDomain() {
 			targetHost = config.APIDomain()
-		} else if route := config.FindRoute(host); route != nil {
-			// Redirect to canonical domain
-			targetHost = route.Canonical
+		} else if canonical, ok := config.ResolveCanonical(host); ok && !strings.HasPrefix(canonical, "*.") {
+			// Redirect to canonical domain. A host covered by a wildcard
+			// canonical domain keeps its name.
+			targetHost = canonical
 		}
 
 		httpsURL := &url.URL{
@@ -400,27 +662,42 @@ func (p *Proxy) httpsHandler() http.Handler {
 
 		// Check if this is the API domain - forward to the control plane
 		if config.APIDomain() != "" && host == config.APIDomain() {
+			if !config.APIAllows(r.RemoteAddr) {
+				// Answer like an unknown host so the API isn't advertised.
+				p.logRequest(r, http.StatusNotFound, time.Since(startTime))
+				p.serveErrorPage(w, http.StatusNotFound, "Not Found")
+				return
+			}
 			p.proxyToAPIBackend(w, r, startTime)
 			return
 		}
 
 		// Find matching route
-		route := config.FindRoute(host)
+		route := config.FindRouteForPath(host, r.URL.Path)
 		if route == nil {
 			p.serveErrorPage(w, http.StatusNotFound, "Not Found")
 			return
 		}
 
-		// Check if this is an alias that should redirect to canonical
-		if host != route.Canonical {
-			canonicalURL := &url.URL{
-				Scheme:   "https",
-				Host:     route.Canonical,
-				Path:     r.URL.Path,
-				RawQuery: r.URL.RawQuery,
-			}
-			p.logRequest(r, http.StatusMovedPermanently, time.Since(startTime))
-			http.Redirect(w, r, canonicalURL.String(), http.StatusMovedPermanently)
+		if status := p.authorizeClient(r, route, config); status != 0 {
+			p.logRequest(r, status, time.Since(startTime))
+			p.serveRouteErrorPage(w, r, route, status, "Client certificate required")
+			return
+		}
+
+		if p.serveSSOPath(w, r, route, startTime) {
+			return
+		}
+
+		// Configured redirects, aliases and paths that aren't normalized
+		// are redirected to the canonical URL.
+		if location, status, ok := route.canonicalRedirect(host, r); ok {
+			p.logRequest(r, status, time.Since(startTime))
+			http.Redirect(w, r, location, status)
+			return
+		}
+
+		if !p.authorizeSSO(w, r, route, startTime) {
 			return
 		}
 
@@ -432,7 +709,7 @@ func (p *Proxy) httpsHandler() http.Handler {
 
 		if len(route.Backends) == 0 {
 			p.logRequest(r, http.StatusBadGateway, time.Since(startTime))
-			p.serveErrorPage(w, http.StatusBadGateway, "No healthy backends available for this application")
+			p.serveRouteErrorPage(w, r, route, http.StatusBadGateway, "No healthy backends available for this application")
 			return
 		}
 
@@ -445,32 +722,85 @@ func (p *Proxy) httpsHandler() http.Handler {
 // the next backend; a dial error means no bytes were sent, so the request is
 // safe to replay.
 func (p *Proxy) proxyToBackend(w http.ResponseWriter, r *http.Request, route *Route, startTime time.Time) {
+	if limit := route.Options.MaxBodyBytes; limit > 0 {
+		if r.ContentLength > limit {
+			p.logRequest(r, http.StatusRequestEntityTooLarge, time.Since(startTime))
+			p.serveRouteErrorPage(w, r, route, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		// Chunked bodies have no declared length; cap them while streaming.
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
 	maxAttempts := 1
 	if len(route.Backends) > 1 {
 		maxAttempts = 2
 	}
 
+	settings := p.config.Load().PassiveHealth()
+
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		backend := route.nextBackend()
-		backendAddr := net.JoinHostPort(backend.IP, backend.Port)
+		backend := p.pickBackend(route)
+		backendAddr := backend.addr()
 
 		targetURL := &url.URL{
 			Scheme: "http",
 			Host:   backendAddr,
 		}
+		// A peer applies the route's limits and path handling itself.
+		var transport http.RoundTripper = p.transportFor(route.Options)
+		stripPrefix := route.Options.StripPrefix
+		if backend.Peer != "" {
+			peerTransport := p.peerTransport.Load()
+			if peerTransport == nil {
+				peerTransport = p.transport
+			} else if peerTransport.TLSClientConfig != nil {
+				targetURL.Scheme = "https"
+			}
+			transport = peerTransport
+			stripPrefix = false
+		}
 
 		var retryErr error
+		attemptStart := time.Now()
+		// record adds the attempt to the backend's passive health stats.
+		// Requests the client gave up on say nothing about the backend.
+		record := func(failed, dialFailed bool, status int, requestID string) {
+			if r.Context().Err() != nil {
+				return
+			}
+			p.backends.record(backendAddr, time.Since(attemptStart), failed, settings, p.canEject(route, backendAddr))
+			if failed {
+				p.backends.noteFailure(backendAddr, proxywire.FailedRequest{
+					Time:      time.Now(),
+					RequestID: requestID,
+					Status:    status,
+					Method:    r.Method,
+					Path:      r.URL.Path,
+				})
+			}
+			if handler := p.backendFailure.Load(); handler != nil && failed && p.backends.shouldReport(backendAddr, dialFailed) {
+				(*handler)(backendAddr)
+			}
+		}
 
 		proxy := &httputil.ReverseProxy{
 			Rewrite: func(pr *httputil.ProxyRequest) {
 				pr.SetURL(targetURL)
-				pr.SetXForwarded()
+				setXForwarded(pr, route.Options.CDN)
 				pr.Out.Header.Del("X-Real-IP")
 				pr.Out.Host = r.Host
+				if stripPrefix {
+					stripPathPrefix(pr.Out.URL, route.Options.PathPrefix)
+				}
 			},
-			Transport:     p.transport,
+			Transport:     transport,
 			FlushInterval: -1, // Flush immediately for streaming
 			ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+				var maxBytesErr *http.MaxBytesError
+				status, message := proxyErrorStatus(err)
+				requestID := requestIDFor(r)
+				record(!errors.As(err, &maxBytesErr), isDialError(err), status, requestID)
 				if attempt < maxAttempts && isDialError(err) && r.Context().Err() == nil {
 					retryErr = err
 					return
@@ -479,17 +809,28 @@ func (p *Proxy) proxyToBackend(w http.ResponseWriter, r *http.Request, route *Ro
 					"host", r.Host,
 					"path", r.URL.Path,
 					"backend", backendAddr,
+					"status", status,
 					"error", err)
-				p.logRequest(r, http.StatusBadGateway, time.Since(startTime))
-				p.serveErrorPage(w, http.StatusBadGateway, "Backend unavailable")
+				p.logRequest(r, status, time.Since(startTime))
+				p.serveRouteErrorPageWithID(w, r, route, status, message, requestID)
 			},
 			ModifyResponse: func(resp *http.Response) error {
+				requestID := resp.Header.Get(requestIDHeader)
+				if !validRequestID(requestID) {
+					requestID = r.Header.Get(requestIDHeader)
+				}
+				if !validRequestID(requestID) {
+					requestID = ""
+				}
+				record(resp.StatusCode >= http.StatusInternalServerError, false, resp.StatusCode, requestID)
 				p.logRequest(r, resp.StatusCode, time.Since(startTime))
 				return nil
 			},
 		}
 
+		done := p.backends.start(backendAddr)
 		proxy.ServeHTTP(w, r)
+		done()
 		if retryErr == nil {
 			return
 		}
@@ -519,7 +860,7 @@ func (p *Proxy) proxyToAPIBackend(w http.ResponseWriter, r *http.Request, startT
 	proxy := &httputil.ReverseProxy{
 		Rewrite: func(pr *httputil.ProxyRequest) {
 			pr.SetURL(targetURL)
-			pr.SetXForwarded()
+			setXForwarded(pr, "")
 			pr.Out.Header.Del("X-Real-IP")
 			pr.Out.Host = r.Host
 		},
@@ -599,18 +940,13 @@ func (p *Proxy) serveErrorPage(w http.ResponseWriter, statusCode int, message st
 </html>`, statusCode, message, statusCode, message)
 }
 
-// logRequest logs an HTTP request in structured JSON format.
+// logRequest writes the access log line for a request, unless the routing
+// config samples it out or the logger is above the info level.
 func (p *Proxy) logRequest(r *http.Request, statusCode int, duration time.Duration) {
-	p.logger.Info(
-		"request",
-		"method", r.Method,
-		"host", r.Host,
-		"path", r.URL.Path,
-		"status", statusCode,
-		"duration_ms", duration.Milliseconds(),
-		"remote_addr", r.RemoteAddr,
-		"user_agent", r.UserAgent(),
-	)
+	if !p.logger.Enabled(r.Context(), slog.LevelInfo) {
+		return
+	}
+	p.accessLog.log(p.config.Load().AccessLog(), r, statusCode, duration)
 }
 
 // extractHost extracts the hostname from a host:port string and lowercases it.
@@ -7,10 +7,14 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"net/url"
+	"slices"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/haloydev/haloy/internal/proxywire"
 )
 
 func TestConfigFindRoute(t *testing.T) {
@@ -318,6 +322,62 @@ func TestUpdateConfig(t *testing.T) {
 	}
 }
 
+func TestUpdateConfigAppliesTLSSettings(t *testing.T) {
+	p := New(slog.New(slog.NewTextHandler(io.Discard, nil)), stubCertLoader{})
+
+	cfg, err := ConfigFromSnapshot(&proxywire.Snapshot{
+		SchemaVersion: proxywire.SchemaVersion,
+		TLS: &proxywire.TLSSettings{
+			MinVersion:       "1.3",
+			CurvePreferences: []string{"X25519", "P-256"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ConfigFromSnapshot() error = %v", err)
+	}
+	p.UpdateConfig(cfg)
+
+	got := p.clientTLS.Load()
+	if got == nil {
+		t.Fatal("UpdateConfig() with TLS settings left the handshake config unset")
+	}
+	if got.MinVersion != tls.VersionTLS13 {
+		t.Errorf("MinVersion = %#x, want %#x", got.MinVersion, tls.VersionTLS13)
+	}
+	if want := []tls.CurveID{tls.X25519, tls.CurveP256}; !slices.Equal(got.CurvePreferences, want) {
+		t.Errorf("CurvePreferences = %v, want %v", got.CurvePreferences, want)
+	}
+	if got.CipherSuites != nil {
+		t.Errorf("CipherSuites = %v, want nil (defaults)", got.CipherSuites)
+	}
+	if got.GetCertificate == nil {
+		t.Error("handshake config has no GetCertificate")
+	}
+
+	empty, err := ConfigFromSnapshot(&proxywire.Snapshot{SchemaVersion: proxywire.SchemaVersion})
+	if err != nil {
+		t.Fatalf("ConfigFromSnapshot() error = %v", err)
+	}
+	p.UpdateConfig(empty)
+	if p.clientTLS.Load() != nil {
+		t.Error("UpdateConfig() without TLS settings should use the listener's config")
+	}
+}
+
+func TestConfigFromSnapshotRejectsInvalidTLSSettings(t *testing.T) {
+	tests := []proxywire.TLSSettings{
+		{MinVersion: "1.0"},
+		{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+		{CurvePreferences: []string{"brainpool"}},
+	}
+	for _, settings := range tests {
+		_, err := ConfigFromSnapshot(&proxywire.Snapshot{SchemaVersion: proxywire.SchemaVersion, TLS: &settings})
+		if err == nil {
+			t.Errorf("ConfigFromSnapshot() with %+v should fail", settings)
+		}
+	}
+}
+
 func TestHTTPHandler_LocalhostAPIRequiresLoopback(t *testing.T) {
 	apiBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -417,6 +477,44 @@ func TestHTTPSHandler_APIDomainForwardsToBackend(t *testing.T) {
 	}
 }
 
+func TestHTTPSHandler_APIDomainAllowedNetworks(t *testing.T) {
+	apiBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "api")
+	}))
+	defer apiBackend.Close()
+	backendHost, backendPort := backendAddr(t, apiBackend)
+
+	p := newTestProxy()
+	rb := NewRouteBuilder()
+	rb.SetAPIDomain("api.example.com")
+	rb.SetAPIBackend(backendHost, backendPort)
+	rb.SetAPIAllowedNetworks([]netip.Prefix{netip.MustParsePrefix("10.99.0.0/24")})
+	cfg, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.UpdateConfig(cfg)
+
+	tests := []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"10.99.0.2:51000", http.StatusOK},
+		{"[::ffff:10.99.0.3]:51000", http.StatusOK},
+		{"127.0.0.1:51000", http.StatusOK},
+		{"203.0.113.9:44321", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "https://api.example.com/v1/version", nil)
+		r.RemoteAddr = tt.remoteAddr
+		w := httptest.NewRecorder()
+		p.httpsHandler().ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("client %s: status = %d, want %d", tt.remoteAddr, w.Code, tt.want)
+		}
+	}
+}
+
 func TestHTTPSHandler_APIDomainWithoutBackendIs503(t *testing.T) {
 	p := newTestProxy()
 	rb := NewRouteBuilder()
@@ -516,3 +614,69 @@ func TestStartAndShutdown(t *testing.T) {
 		t.Fatalf("Shutdown() error = %v", err)
 	}
 }
+
+func TestStart_RebindsDeadListener(t *testing.T) {
+	defer func(d time.Duration) { listenerRetryMin = d }(listenerRetryMin)
+	listenerRetryMin = 10 * time.Millisecond
+
+	p := New(slog.New(slog.NewTextHandler(io.Discard, nil)), stubCertLoader{})
+	if err := p.Start("127.0.0.1:0", "127.0.0.1:0"); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer p.Shutdown(t.Context())
+
+	p.listenersMu.Lock()
+	https := p.listeners[1]
+	addr := https.listener.Addr().String()
+	https.listener.Close()
+	p.listenersMu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		state := p.ListenerStates()[1]
+		if state.Serving && state.Restarts == 1 {
+			if state.Protocol != "https" || state.Addr != addr || state.LastError == "" {
+				t.Errorf("ListenerStates()[1] = %+v, want https on %s with the last error", state, addr)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("listener not rebound, state = %+v", state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial rebound listener: %v", err)
+	}
+	conn.Close()
+
+	select {
+	case err := <-p.Err():
+		t.Fatalf("unexpected fatal listener error: %v", err)
+	default:
+	}
+}
+
+func TestRebindAddr(t *testing.T) {
+	tests := []struct {
+		addrs string
+		bound string
+		want  string
+	}{
+		{addrs: ":443", bound: "[::]:443", want: ":443"},
+		{addrs: "0.0.0.0:443, [::]:443", bound: "[::]:443", want: "[::]:443"},
+		{addrs: "0.0.0.0:443,[::]:443", bound: "0.0.0.0:443", want: "0.0.0.0:443"},
+		{addrs: "127.0.0.1:0", bound: "127.0.0.1:40123", want: "127.0.0.1:40123"},
+	}
+	for _, tt := range tests {
+		bound, err := net.ResolveTCPAddr("tcp", tt.bound)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rebindAddr(tt.addrs, bound); got != tt.want {
+			t.Errorf("rebindAddr(%q, %s) = %q, want %q", tt.addrs, tt.bound, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+// Redirect sends matching requests of a route elsewhere instead of to a
+// backend. See proxywire.Redirect for how From and To are matched and
+// expanded.
+type Redirect struct {
+	// Host limits the redirect to requests for this host (lowercase).
+	Host   string
+	From   string
+	To     string
+	Status int
+}
+
+// match returns the part of path that the "*" in From matched, or the whole
+// path without its leading slash when From is empty, and whether path
+// matches at all.
+func (rd *Redirect) match(path string) (rest string, ok bool) {
+	if rd.From == "" {
+		return strings.TrimPrefix(path, "/"), true
+	}
+	if base, ok := strings.CutSuffix(rd.From, "/*"); ok {
+		if !matchesPathPrefix(path, base) {
+			return "", false
+		}
+		return strings.TrimPrefix(strings.TrimPrefix(path, base), "/"), true
+	}
+	return "", path == rd.From || path == rd.From+"/"
+}
+
+// findRedirect returns where the first of the route's redirects matching a
+// request for host and the escaped path sends it, and with which status.
+func (r *Route) findRedirect(host, path, rawQuery string) (location string, status int, ok bool) {
+	for i := range r.Options.Redirects {
+		rd := &r.Options.Redirects[i]
+		if rd.Host != "" && rd.Host != host {
+			continue
+		}
+		rest, ok := rd.match(path)
+		if !ok {
+			continue
+		}
+
+		location = rd.To
+		if prefix, ok := strings.CutSuffix(location, "*"); ok {
+			location = prefix + rest
+		}
+		if strings.HasPrefix(location, "/") {
+			location = "https://" + r.canonicalHost(host) + location
+		}
+		if rawQuery != "" && !strings.Contains(location, "?") {
+			location += "?" + rawQuery
+		}
+		status = rd.Status
+		if status == 0 {
+			status = http.StatusMovedPermanently
+		}
+		return location, status, true
+	}
+	return "", 0, false
+}
+
+// wireRedirects converts redirects to their wire form.
+func wireRedirects(redirects []Redirect) []proxywire.Redirect {
+	var wire []proxywire.Redirect
+	for _, rd := range redirects {
+		wire = append(wire, proxywire.Redirect{Host: rd.Host, From: rd.From, To: rd.To, Status: rd.Status})
+	}
+	return wire
+}
@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSHandler_Redirects(t *testing.T) {
+	p := newTestProxy()
+	rb := NewRouteBuilder()
+	// No backends: a redirect must be answered before one is needed.
+	rb.AddRouteWithOptions("www.example.com", []string{"example.com", "old-brand.com"}, nil, RouteOptions{
+		Redirects: []Redirect{
+			{From: "/old-path", To: "/new-path", Status: http.StatusPermanentRedirect},
+			{From: "/docs/*", To: "https://docs.example.com/*"},
+			{Host: "old-brand.com", To: "https://www.example.com/welcome"},
+			{From: "/search", To: "/find?source=redirect", Status: http.StatusFound},
+		},
+	})
+	cfg, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.UpdateConfig(cfg)
+	handler := p.httpsHandler()
+
+	tests := []struct {
+		name         string
+		url          string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"exact path", "https://www.example.com/old-path", http.StatusPermanentRedirect, "https://www.example.com/new-path"},
+		{"exact path with trailing slash", "https://www.example.com/old-path/", http.StatusPermanentRedirect, "https://www.example.com/new-path"},
+		{"path keeps the query", "https://www.example.com/old-path?page=2", http.StatusPermanentRedirect, "https://www.example.com/new-path?page=2"},
+		{"path on an alias goes to the canonical domain", "https://example.com/old-path", http.StatusPermanentRedirect, "https://www.example.com/new-path"},
+		{"wildcard", "https://www.example.com/docs/guide/intro", http.StatusMovedPermanently, "https://docs.example.com/guide/intro"},
+		{"wildcard base", "https://www.example.com/docs", http.StatusMovedPermanently, "https://docs.example.com/"},
+		{"host", "https://old-brand.com/anything", http.StatusMovedPermanently, "https://www.example.com/welcome"},
+		{"to with its own query", "https://www.example.com/search?q=x", http.StatusFound, "https://www.example.com/find?source=redirect"},
+		{"alias without a redirect still goes to canonical", "https://example.com/pricing", http.StatusMovedPermanently, "https://www.example.com/pricing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.wantStatus || w.Header().Get("Location") != tt.wantLocation {
+				t.Errorf("status = %d, Location = %q, want %d, %q", w.Code, w.Header().Get("Location"), tt.wantStatus, tt.wantLocation)
+			}
+		})
+	}
+
+	// Paths no redirect matches go on to the backends.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://www.example.com/docsearch", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d for a path no redirect matches", w.Code, http.StatusBadGateway)
+	}
+}
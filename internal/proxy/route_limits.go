@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RouteOptions holds optional per-route settings. Zero values use the proxy
+// defaults and route the whole domain.
+type RouteOptions struct {
+	// App names the app serving the route; its custom error pages are used.
+	App string
+
+	// PathPrefix limits the route to requests under this path (no trailing
+	// slash). Empty matches every path.
+	PathPrefix string
+	// StripPrefix removes PathPrefix from the path sent to the backend.
+	StripPrefix bool
+	// CDN trusts the CDN's client IP header from its published addresses;
+	// only constants.CDNCloudflare is known.
+	CDN string
+
+	// MaxBodyBytes rejects request bodies larger than this with 413.
+	MaxBodyBytes int64
+	// ReadTimeout bounds how long the backend may take to send response headers.
+	ReadTimeout time.Duration
+	// SendTimeout bounds each write of the request to the backend.
+	SendTimeout time.Duration
+
+	// Redirects are answered before a backend is picked.
+	Redirects []Redirect
+	// URLNormalization is nil unless requests are redirected to the
+	// canonical form of their path.
+	URLNormalization *URLNormalization
+	// ClientAuth is nil unless clients are asked for a TLS certificate.
+	ClientAuth *ClientAuth
+	// SSO is nil unless users sign in with an identity provider first.
+	SSO *SSO
+}
+
+type transportKey struct {
+	read, send time.Duration
+}
+
+// transportFor returns the backend transport for a route's timeouts. Routes
+// without custom timeouts share the default transport; others share one
+// transport per distinct timeout pair so connection pooling still applies.
+func (p *Proxy) transportFor(opts RouteOptions) *http.Transport {
+	if opts.ReadTimeout <= 0 && opts.SendTimeout <= 0 {
+		return p.transport
+	}
+
+	key := transportKey{read: opts.ReadTimeout, send: opts.SendTimeout}
+	if t, ok := p.routeTransports.Load(key); ok {
+		return t.(*http.Transport)
+	}
+
+	t := p.transport.Clone()
+	if opts.ReadTimeout > 0 {
+		t.ResponseHeaderTimeout = opts.ReadTimeout
+	}
+	if opts.SendTimeout > 0 {
+		dial := t.DialContext
+		timeout := opts.SendTimeout
+		t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &sendDeadlineConn{Conn: conn, timeout: timeout}, nil
+		}
+	}
+
+	actual, _ := p.routeTransports.LoadOrStore(key, t)
+	return actual.(*http.Transport)
+}
+
+// closeIdleRouteTransports closes idle connections of all per-route transports.
+func (p *Proxy) closeIdleRouteTransports() {
+	p.routeTransports.Range(func(_, t any) bool {
+		t.(*http.Transport).CloseIdleConnections()
+		return true
+	})
+}
+
+// sendDeadlineConn arms a write deadline before every write, so a backend
+// that stops reading the request fails the request instead of stalling it.
+type sendDeadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *sendDeadlineConn) Write(b []byte) (int, error) {
+	if err := c.Conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+// proxyErrorStatus maps a backend round-trip error to the status served to
+// the client.
+func proxyErrorStatus(err error) (int, string) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge, "Request body too large"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return http.StatusGatewayTimeout, "Backend timed out"
+	}
+	return http.StatusBadGateway, "Backend unavailable"
+}
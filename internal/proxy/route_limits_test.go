@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/errorpages"
+)
+
+func testBackend(t *testing.T, handler http.HandlerFunc) Backend {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Backend{IP: host, Port: port}
+}
+
+func TestProxyToBackend_MaxBodyBytes(t *testing.T) {
+	backend := testBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.Copy(io.Discard, r.Body); err != nil {
+			return
+		}
+		io.WriteString(w, "ok")
+	})
+
+	p := newTestProxy()
+	route := &Route{
+		Canonical: "example.com",
+		Backends:  []Backend{backend},
+		Options:   RouteOptions{MaxBodyBytes: 8},
+	}
+
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		wantStatus    int
+	}{
+		{"within limit", "12345678", 8, http.StatusOK},
+		{"declared length too large", "123456789", 9, http.StatusRequestEntityTooLarge},
+		{"chunked body too large", "123456789", -1, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "https://example.com/upload", io.NopCloser(strings.NewReader(tt.body)))
+			r.ContentLength = tt.contentLength
+			w := httptest.NewRecorder()
+			p.proxyToBackend(w, r, route, time.Now())
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestProxyToBackend_ReadTimeout(t *testing.T) {
+	backend := testBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+
+	p := newTestProxy()
+	route := &Route{
+		Canonical: "example.com",
+		Backends:  []Backend{backend},
+		Options:   RouteOptions{ReadTimeout: 50 * time.Millisecond},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "https://example.com/slow", nil)
+	w := httptest.NewRecorder()
+	p.proxyToBackend(w, r, route, time.Now())
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+}
+
+func TestTransportFor_SharesByTimeouts(t *testing.T) {
+	p := newTestProxy()
+
+	if got := p.transportFor(RouteOptions{MaxBodyBytes: 1024}); got != p.transport {
+		t.Fatal("routes without timeouts should use the default transport")
+	}
+	a := p.transportFor(RouteOptions{ReadTimeout: time.Second})
+	b := p.transportFor(RouteOptions{ReadTimeout: time.Second, MaxBodyBytes: 1})
+	if a != b {
+		t.Fatal("routes with equal timeouts should share a transport")
+	}
+	if a.ResponseHeaderTimeout != time.Second {
+		t.Fatalf("ResponseHeaderTimeout = %v, want 1s", a.ResponseHeaderTimeout)
+	}
+	if c := p.transportFor(RouteOptions{ReadTimeout: 2 * time.Second}); c == a {
+		t.Fatal("routes with different timeouts should not share a transport")
+	}
+}
+
+func TestHTTPSHandler_PathPrefixStrip(t *testing.T) {
+	var gotPath string
+	backend := testBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+	})
+
+	p := newTestProxy()
+	rb := NewRouteBuilder()
+	rb.AddRouteWithOptions("example.com", nil, []Backend{backend}, RouteOptions{PathPrefix: "/api", StripPrefix: true})
+	cfg, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.UpdateConfig(cfg)
+
+	r := httptest.NewRequest(http.MethodGet, "https://example.com/api/users?page=2", nil)
+	w := httptest.NewRecorder()
+	p.httpsHandler().ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if gotPath != "/users" {
+		t.Errorf("backend path = %q, want %q", gotPath, "/users")
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "https://example.com/other", nil)
+	w = httptest.NewRecorder()
+	p.httpsHandler().ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d for a path outside every prefix, want 404", w.Code)
+	}
+}
+
+func TestServeRouteErrorPage_CustomPage(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := errorpages.WriteBundle(baseDir, "web", map[string]string{"502.html": "custom {{.Status}} {{.RequestID}}"}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newTestProxy()
+	p.SetErrorPages(errorpages.NewStore(baseDir))
+	route := &Route{Canonical: "example.com", Options: RouteOptions{App: "web"}}
+
+	r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	r.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	p.serveRouteErrorPage(w, r, route, http.StatusBadGateway, "Backend unavailable")
+
+	if w.Code != http.StatusBadGateway || w.Body.String() != "custom 502 req-1" {
+		t.Fatalf("status = %d body = %q, want custom 502 page", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("X-Request-ID"); got != "req-1" {
+		t.Errorf("X-Request-ID = %q, want req-1", got)
+	}
+
+	w = httptest.NewRecorder()
+	p.serveRouteErrorPage(w, r, route, http.StatusRequestEntityTooLarge, "Request body too large")
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "Request body too large") {
+		t.Errorf("status = %d, want the default page when the app has no page for the status", w.Code)
+	}
+}
@@ -1,15 +1,25 @@
 package proxy
 
 import (
+	"cmp"
 	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
 	"strings"
 )
 
 // RouteBuilder helps build proxy routes from deployment information.
 type RouteBuilder struct {
-	routes     map[string]*Route
-	apiDomain  string
-	apiBackend Backend
+	routes      map[string]*Route
+	apiDomain   string
+	apiBackend  Backend
+	apiNetworks []netip.Prefix
+	onDemandTLS bool
+	tls         *TLSSettings
+	passive     PassiveHealthSettings
+	accessLog   AccessLogSettings
+	cluster     *ClusterSettings
 }
 
 // NewRouteBuilder creates a new route builder.
@@ -24,58 +34,170 @@ func (rb *RouteBuilder) SetAPIDomain(domain string) {
 	rb.apiDomain = strings.ToLower(domain)
 }
 
+// SetAPIAllowedNetworks limits the API domain to clients in networks; none
+// allows everyone.
+func (rb *RouteBuilder) SetAPIAllowedNetworks(networks []netip.Prefix) {
+	rb.apiNetworks = networks
+}
+
 // SetAPIBackend sets the control plane's API listener address, which the
 // proxy forwards API-domain and localhost API traffic to.
 func (rb *RouteBuilder) SetAPIBackend(ip, port string) {
 	rb.apiBackend = Backend{IP: ip, Port: port}
 }
 
+// SetOnDemandTLS enables reporting unknown TLS server names for on-demand
+// certificate issuance.
+func (rb *RouteBuilder) SetOnDemandTLS(enabled bool) {
+	rb.onDemandTLS = enabled
+}
+
+// SetTLS sets the HTTPS handshake settings; nil keeps the defaults.
+func (rb *RouteBuilder) SetTLS(settings *TLSSettings) {
+	rb.tls = settings
+}
+
+// SetPassiveHealth sets the thresholds for flagging backends from the
+// traffic they serve.
+func (rb *RouteBuilder) SetPassiveHealth(settings PassiveHealthSettings) {
+	rb.passive = settings
+}
+
+// SetAccessLog sets which requests are logged.
+func (rb *RouteBuilder) SetAccessLog(settings AccessLogSettings) {
+	rb.accessLog = settings
+}
+
+// SetCluster sets the cluster settings; nil means no cluster.
+func (rb *RouteBuilder) SetCluster(settings *ClusterSettings) {
+	rb.cluster = settings
+}
+
 // AddRoute adds a route for an application.
 func (rb *RouteBuilder) AddRoute(canonical string, aliases []string, backends []Backend) {
+	rb.AddRouteWithOptions(canonical, aliases, backends, RouteOptions{})
+}
+
+// AddRouteWithOptions adds a route with per-route proxy limits.
+func (rb *RouteBuilder) AddRouteWithOptions(canonical string, aliases []string, backends []Backend, opts RouteOptions) {
 	canonical = strings.ToLower(canonical)
 
+	opts.PathPrefix = strings.TrimRight(opts.PathPrefix, "/")
+
 	route := &Route{
 		Canonical: canonical,
 		Aliases:   make([]string, len(aliases)),
 		Backends:  backends,
+		Options:   opts,
 	}
 
 	for i, alias := range aliases {
 		route.Aliases[i] = strings.ToLower(alias)
 	}
 
-	rb.routes[canonical] = route
+	rb.routes[canonical+opts.PathPrefix] = route
 }
 
 // Build validates the routes and creates the final proxy configuration with a
-// flat host lookup index. It returns an error if a domain is used as both a
-// canonical domain and an alias, or as an alias of multiple routes.
+// host lookup index. It returns an error if a domain is used as both a
+// canonical domain and an alias, or as an alias of multiple canonical domains.
+// Routes that share a canonical domain with different path prefixes may also
+// share aliases.
 func (rb *RouteBuilder) Build() (*Config, error) {
-	hosts := make(map[string]*Route, len(rb.routes))
+	hosts := newHostIndex(len(rb.routes))
 	owner := make(map[string]string, len(rb.routes)) // host -> canonical that owns it
 
-	for canonical, route := range rb.routes {
-		owner[canonical] = canonical
-		hosts[canonical] = route
+	for _, route := range rb.routes {
+		owner[route.Canonical] = route.Canonical
+		hosts.add(route.Canonical, route)
 	}
 
-	for canonical, route := range rb.routes {
+	for _, route := range rb.routes {
+		canonical := route.Canonical
 		for _, alias := range route.Aliases {
 			if prev, exists := owner[alias]; exists {
 				if prev == alias {
 					return nil, fmt.Errorf("domain %q is both a canonical domain and an alias of %q", alias, canonical)
 				}
-				return nil, fmt.Errorf("alias %q is used by both %q and %q", alias, prev, canonical)
+				if prev != canonical {
+					return nil, fmt.Errorf("alias %q is used by both %q and %q", alias, prev, canonical)
+				}
 			}
 			owner[alias] = canonical
-			hosts[alias] = route
+			hosts.add(alias, route)
+		}
+	}
+
+	hosts.each(sortByPrefixLength)
+
+	for _, route := range rb.routes {
+		var local []Backend
+		for _, b := range route.Backends {
+			if b.Peer == "" {
+				local = append(local, b)
+			}
+		}
+		if len(local) < len(route.Backends) {
+			route.local = &Route{Canonical: route.Canonical, Aliases: route.Aliases, Backends: local, Options: route.Options}
 		}
 	}
 
 	return &Config{
-		routes:     rb.routes,
-		hosts:      hosts,
-		apiDomain:  rb.apiDomain,
-		apiBackend: rb.apiBackend,
+		routes:        rb.routes,
+		hosts:         hosts,
+		apiDomain:     rb.apiDomain,
+		apiBackend:    rb.apiBackend,
+		apiNetworks:   rb.apiNetworks,
+		onDemandTLS:   rb.onDemandTLS,
+		tls:           rb.tls,
+		passiveHealth: rb.passive,
+		accessLog:     rb.accessLog,
+		cluster:       rb.cluster,
 	}, nil
 }
+
+// sortByPrefixLength orders routes longest path prefix first, so the first
+// match is the most specific one.
+func sortByPrefixLength(routes []*Route) {
+	slices.SortFunc(routes, func(a, b *Route) int {
+		if c := cmp.Compare(len(b.Options.PathPrefix), len(a.Options.PathPrefix)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Options.PathPrefix, b.Options.PathPrefix)
+	})
+}
+
+// matchesPathPrefix reports whether path is prefix itself or below it.
+// "/api" matches "/api" and "/api/users" but not "/apiary".
+func matchesPathPrefix(path, prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
+// stripPathPrefix removes prefix from u's path, keeping it rooted at "/".
+func stripPathPrefix(u *url.URL, prefix string) {
+	if prefix == "" {
+		return
+	}
+	u.Path = ensureLeadingSlash(strings.TrimPrefix(u.Path, prefix))
+	if u.RawPath != "" {
+		// The escaped form of the prefix only matches when it needed no escaping.
+		if trimmed, ok := strings.CutPrefix(u.RawPath, prefix); ok {
+			u.RawPath = ensureLeadingSlash(trimmed)
+		} else {
+			u.RawPath = ""
+		}
+	}
+}
+
+func ensureLeadingSlash(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return "/" + path
+	}
+	return path
+}
@@ -1,6 +1,8 @@
 package proxy
 
 import (
+	"fmt"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -163,3 +165,121 @@ func TestConfig_ResolveCanonical(t *testing.T) {
 		t.Error("ResolveCanonical(unknown) = true, want false")
 	}
 }
+
+func TestConfig_FindRouteForPath(t *testing.T) {
+	rb := NewRouteBuilder()
+	rb.AddRoute("example.com", []string{"www.example.com"}, []Backend{{IP: "10.0.0.1", Port: "80"}})
+	rb.AddRouteWithOptions("example.com", []string{"www.example.com"}, []Backend{{IP: "10.0.0.2", Port: "80"}}, RouteOptions{PathPrefix: "/api/"})
+	rb.AddRouteWithOptions("example.com", nil, []Backend{{IP: "10.0.0.3", Port: "80"}}, RouteOptions{PathPrefix: "/api/admin"})
+	rb.AddRouteWithOptions("docs.example.com", nil, []Backend{{IP: "10.0.0.4", Port: "80"}}, RouteOptions{PathPrefix: "/v2"})
+	config, err := rb.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	tests := []struct {
+		host, path string
+		wantIP     string
+	}{
+		{"example.com", "/", "10.0.0.1"},
+		{"example.com", "/apiary", "10.0.0.1"},
+		{"example.com", "/api", "10.0.0.2"},
+		{"example.com", "/api/users", "10.0.0.2"},
+		{"example.com", "/api/admin/settings", "10.0.0.3"},
+		{"www.example.com", "/api/users", "10.0.0.2"},
+		{"docs.example.com", "/v2/intro", "10.0.0.4"},
+		{"docs.example.com", "/v1/intro", ""},
+	}
+	for _, tt := range tests {
+		route := config.FindRouteForPath(tt.host, tt.path)
+		if tt.wantIP == "" {
+			if route != nil {
+				t.Errorf("FindRouteForPath(%q, %q) = %v, want nil", tt.host, tt.path, route.Backends)
+			}
+			continue
+		}
+		if route == nil || route.Backends[0].IP != tt.wantIP {
+			t.Errorf("FindRouteForPath(%q, %q) did not resolve to backend %s", tt.host, tt.path, tt.wantIP)
+		}
+	}
+
+	if !config.IsKnownHost("docs.example.com") {
+		t.Error("IsKnownHost() = false for a host served only under a path prefix")
+	}
+	if canonical, ok := config.ResolveCanonical("www.example.com"); !ok || canonical != "example.com" {
+		t.Errorf("ResolveCanonical(alias) = %q, %v; want example.com, true", canonical, ok)
+	}
+	if config.RouteCount() != 4 {
+		t.Errorf("RouteCount() = %d, want 4", config.RouteCount())
+	}
+}
+
+func TestStripPathPrefix(t *testing.T) {
+	tests := []struct {
+		path, prefix, want string
+	}{
+		{"/api/users", "/api", "/users"},
+		{"/api", "/api", "/"},
+		{"/users", "", "/users"},
+	}
+	for _, tt := range tests {
+		u := &url.URL{Path: tt.path}
+		stripPathPrefix(u, tt.prefix)
+		if u.Path != tt.want {
+			t.Errorf("stripPathPrefix(%q, %q) = %q, want %q", tt.path, tt.prefix, u.Path, tt.want)
+		}
+	}
+}
+
+func TestConfig_WildcardHosts(t *testing.T) {
+	rb := NewRouteBuilder()
+	rb.AddRoute("*.example.com", nil, []Backend{{IP: "10.0.0.1", Port: "80"}})
+	rb.AddRoute("app.example.com", nil, []Backend{{IP: "10.0.0.2", Port: "80"}})
+
+	config, err := rb.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	tests := []struct {
+		host string
+		want string // canonical of the matching route, "" for none
+	}{
+		{"app.example.com", "app.example.com"},
+		{"shop.example.com", "*.example.com"},
+		{"SHOP.Example.COM", "*.example.com"},
+		{"shop.example.com.", "*.example.com"},
+		{"example.com", ""},
+		{"a.b.example.com", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if route := config.FindRoute(tt.host); route != nil {
+			got = route.Canonical
+		}
+		if got != tt.want {
+			t.Errorf("FindRoute(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+		if known := config.IsKnownHost(tt.host); known != (tt.want != "") {
+			t.Errorf("IsKnownHost(%q) = %v, want %v", tt.host, known, tt.want != "")
+		}
+	}
+}
+
+func BenchmarkConfig_FindRouteForPath(b *testing.B) {
+	rb := NewRouteBuilder()
+	for i := range 1000 {
+		canonical := fmt.Sprintf("app%d.example.com", i)
+		rb.AddRoute(canonical, []string{"www." + canonical}, []Backend{{IP: "10.0.0.1", Port: "80"}})
+	}
+	config, err := rb.Build()
+	if err != nil {
+		b.Fatalf("Build() error = %v", err)
+	}
+
+	for b.Loop() {
+		if config.FindRouteForPath("www.app999.example.com", "/") == nil {
+			b.Fatal("route not found")
+		}
+	}
+}
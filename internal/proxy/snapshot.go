@@ -2,7 +2,11 @@ package proxy
 
 import (
 	"fmt"
+	"net/netip"
+	"strings"
+	"time"
 
+	"github.com/haloydev/haloy/internal/helpers"
 	"github.com/haloydev/haloy/internal/proxywire"
 )
 
@@ -19,6 +23,42 @@ func ConfigFromSnapshot(snap *proxywire.Snapshot) (*Config, error) {
 
 	rb := NewRouteBuilder()
 	rb.SetAPIDomain(snap.APIDomain)
+	if len(snap.APIAllowedNetworks) > 0 {
+		networks := make([]netip.Prefix, 0, len(snap.APIAllowedNetworks))
+		for _, network := range snap.APIAllowedNetworks {
+			prefix, err := netip.ParsePrefix(network)
+			if err != nil {
+				return nil, fmt.Errorf("invalid API allowed network %q: %w", network, err)
+			}
+			networks = append(networks, prefix.Masked())
+		}
+		rb.SetAPIAllowedNetworks(networks)
+	}
+	rb.SetOnDemandTLS(snap.OnDemandTLS)
+	if snap.TLS != nil {
+		settings, err := tlsSettingsFromSnapshot(snap.TLS)
+		if err != nil {
+			return nil, err
+		}
+		rb.SetTLS(settings)
+	}
+	if ph := snap.PassiveHealth; ph != nil {
+		rb.SetPassiveHealth(PassiveHealthSettings{
+			MaxLatency:   time.Duration(ph.MaxLatencyMS) * time.Millisecond,
+			MaxErrorRate: ph.MaxErrorRate,
+			Eject:        ph.Eject,
+		})
+	}
+	if al := snap.AccessLog; al != nil {
+		rb.SetAccessLog(AccessLogSettings{Disabled: al.Disabled, Sample: al.Sample})
+	}
+	if c := snap.Cluster; c != nil {
+		settings, err := LoadClusterSettings(c.Listen, c.CAFile, c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		rb.SetCluster(settings)
+	}
 	if snap.APIBackend != nil {
 		rb.SetAPIBackend(snap.APIBackend.IP, snap.APIBackend.Port)
 	}
@@ -29,10 +69,54 @@ func ConfigFromSnapshot(snap *proxywire.Snapshot) (*Config, error) {
 		}
 		var backends []Backend
 		for _, b := range route.Backends {
-			backends = append(backends, Backend{IP: b.IP, Port: b.Port})
+			backends = append(backends, Backend{IP: b.IP, Port: b.Port, Peer: b.Peer})
+		}
+		var redirects []Redirect
+		for _, r := range route.Redirects {
+			redirects = append(redirects, Redirect{Host: strings.ToLower(r.Host), From: r.From, To: r.To, Status: r.Status})
+		}
+		clientAuth, err := clientAuthFromSnapshot(route.ClientAuth)
+		if err != nil {
+			return nil, fmt.Errorf("route %s%s: %w", route.Canonical, route.PathPrefix, err)
 		}
-		rb.AddRoute(route.Canonical, route.Aliases, backends)
+		rb.AddRouteWithOptions(route.Canonical, route.Aliases, backends, RouteOptions{
+			App:          route.App,
+			PathPrefix:   route.PathPrefix,
+			StripPrefix:  route.StripPrefix,
+			CDN:          route.CDN,
+			MaxBodyBytes: route.MaxBodyBytes,
+			ReadTimeout:  time.Duration(route.ReadTimeoutMS) * time.Millisecond,
+			SendTimeout:  time.Duration(route.SendTimeoutMS) * time.Millisecond,
+			Redirects:    redirects,
+
+			URLNormalization: urlNormalizationFromSnapshot(route.URLNormalization),
+			ClientAuth:       clientAuth,
+			SSO:              ssoFromSnapshot(route.SSO),
+		})
 	}
 
 	return rb.Build()
 }
+
+func tlsSettingsFromSnapshot(snap *proxywire.TLSSettings) (*TLSSettings, error) {
+	var settings TLSSettings
+	var err error
+	if snap.MinVersion != "" {
+		if settings.MinVersion, err = helpers.ParseTLSVersion(snap.MinVersion); err != nil {
+			return nil, fmt.Errorf("tls min version: %w", err)
+		}
+	}
+	// An empty, non-nil list would disable every suite or curve, so only
+	// configured lists are set.
+	if len(snap.CipherSuites) > 0 {
+		if settings.CipherSuites, err = helpers.ParseCipherSuites(snap.CipherSuites); err != nil {
+			return nil, fmt.Errorf("tls cipher suites: %w", err)
+		}
+	}
+	if len(snap.CurvePreferences) > 0 {
+		if settings.CurvePreferences, err = helpers.ParseCurvePreferences(snap.CurvePreferences); err != nil {
+			return nil, fmt.Errorf("tls curve preferences: %w", err)
+		}
+	}
+	return &settings, nil
+}
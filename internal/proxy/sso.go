@@ -0,0 +1,689 @@
+package proxy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+// Paths the proxy answers itself on routes with SSO, below the route's path
+// prefix. The callback URL is registered with the identity provider.
+const (
+	ssoCallbackPath = "/.haloy/sso/callback"
+	ssoLogoutPath   = "/.haloy/sso/logout"
+)
+
+const (
+	ssoSessionCookie = "_haloy_sso"
+	ssoLoginCookie   = "_haloy_sso_login"
+
+	// ssoSessionDuration is how long a sign-in lasts. The provider isn't
+	// asked again until it ends.
+	ssoSessionDuration = 12 * time.Hour
+	// ssoLoginTimeout bounds the time a user has to sign in with the
+	// provider.
+	ssoLoginTimeout = 10 * time.Minute
+	// ssoDiscoveryTTL is how long an OIDC provider's endpoints are cached.
+	ssoDiscoveryTTL = time.Hour
+)
+
+// Headers telling the backend who signed in. Values sent by clients are
+// always dropped.
+const (
+	headerAuthUser  = "X-Auth-Request-User"
+	headerAuthEmail = "X-Auth-Request-Email"
+)
+
+// googleIssuer is the OIDC issuer of the google provider.
+const googleIssuer = "https://accounts.google.com"
+
+// githubEndpoints are the OAuth endpoints of the github provider. GitHub
+// doesn't speak OIDC, so users are looked up with the API; the user's email
+// addresses are at userinfo+"/emails".
+var githubEndpoints = ssoEndpoints{
+	authorize: "https://github.com/login/oauth/authorize",
+	token:     "https://github.com/login/oauth/access_token",
+	userinfo:  "https://api.github.com/user",
+}
+
+// SSO is how users of a route sign in with an identity provider. Provider is
+// one of the constants.SSOProvider* values; Issuer is only set for OIDC.
+type SSO struct {
+	Provider       string
+	Issuer         string
+	ClientID       string
+	ClientSecret   string
+	AllowedEmails  []string
+	AllowedDomains []string
+}
+
+func ssoFromSnapshot(s *proxywire.SSO) *SSO {
+	if s == nil {
+		return nil
+	}
+	return &SSO{
+		Provider:       s.Provider,
+		Issuer:         s.Issuer,
+		ClientID:       s.ClientID,
+		ClientSecret:   s.ClientSecret,
+		AllowedEmails:  s.AllowedEmails,
+		AllowedDomains: s.AllowedDomains,
+	}
+}
+
+// wire returns the wire form of s, which may be nil.
+func (s *SSO) wire() *proxywire.SSO {
+	if s == nil {
+		return nil
+	}
+	return &proxywire.SSO{
+		Provider:       s.Provider,
+		Issuer:         s.Issuer,
+		ClientID:       s.ClientID,
+		ClientSecret:   s.ClientSecret,
+		AllowedEmails:  s.AllowedEmails,
+		AllowedDomains: s.AllowedDomains,
+	}
+}
+
+// issuer returns the OIDC issuer of the provider, or "" for github.
+func (s *SSO) issuer() string {
+	switch s.Provider {
+	case constants.SSOProviderGoogle:
+		return googleIssuer
+	case constants.SSOProviderGitHub:
+		return ""
+	default:
+		return s.Issuer
+	}
+}
+
+func (s *SSO) scope() string {
+	if s.Provider == constants.SSOProviderGitHub {
+		return "read:user user:email"
+	}
+	return "openid email profile"
+}
+
+// allows reports whether the user may use the route.
+func (s *SSO) allows(id ssoIdentity) bool {
+	if len(s.AllowedEmails) == 0 && len(s.AllowedDomains) == 0 {
+		return true
+	}
+	if id.Email == "" || !id.EmailVerified {
+		return false
+	}
+	for _, email := range s.AllowedEmails {
+		if strings.EqualFold(email, id.Email) {
+			return true
+		}
+	}
+	domain := id.Email[strings.LastIndex(id.Email, "@")+1:]
+	for _, allowed := range s.AllowedDomains {
+		if strings.EqualFold(allowed, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// seal signs v for a cookie. Cookies are signed with a key derived from the
+// client secret, so every proxy serving the route accepts them and rotating
+// the secret signs everyone out. purpose keeps one kind of cookie from
+// passing for another.
+func (s *SSO) seal(purpose string, v any) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(purpose, encoded)), nil
+}
+
+// open verifies a value from seal and decodes it into v.
+func (s *SSO) open(purpose, value string, v any) bool {
+	encoded, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.sign(purpose, encoded)) {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(payload, v) == nil
+}
+
+func (s *SSO) sign(purpose, encoded string) []byte {
+	key := hmac.New(sha256.New, []byte(s.ClientSecret))
+	key.Write([]byte("haloy sso " + s.ClientID))
+	mac := hmac.New(sha256.New, key.Sum(nil))
+	mac.Write([]byte(purpose + "\x00" + encoded))
+	return mac.Sum(nil)
+}
+
+// ssoSession is the signed-in user, kept in the session cookie.
+type ssoSession struct {
+	User          string `json:"u"`
+	Email         string `json:"e,omitempty"`
+	EmailVerified bool   `json:"v,omitempty"`
+	// Route is the ssoRoute the user signed in to.
+	Route   string `json:"r"`
+	Expires int64  `json:"x"`
+}
+
+// identity is the user the session was signed in as.
+func (s ssoSession) identity() ssoIdentity {
+	return ssoIdentity{User: s.User, Email: s.Email, EmailVerified: s.EmailVerified}
+}
+
+// ssoLogin is a sign-in in progress, kept in the login cookie until the
+// provider sends the user back.
+type ssoLogin struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	// Return is the request URI the user asked for.
+	Return  string `json:"r"`
+	Expires int64  `json:"x"`
+}
+
+// ssoIdentity is a user as the identity provider knows them.
+type ssoIdentity struct {
+	User          string
+	Email         string
+	EmailVerified bool
+}
+
+// ssoEndpoints are where a provider signs users in. basicAuth sends the
+// client credentials to the token endpoint with HTTP basic auth instead of
+// in the form.
+type ssoEndpoints struct {
+	issuer    string
+	authorize string
+	token     string
+	userinfo  string
+	basicAuth bool
+}
+
+// ssoClient talks to identity providers and caches their OIDC discovery
+// documents.
+type ssoClient struct {
+	http *http.Client
+
+	mu         sync.Mutex
+	discovered map[string]discoveredEndpoints
+}
+
+type discoveredEndpoints struct {
+	endpoints ssoEndpoints
+	fetched   time.Time
+}
+
+func newSSOClient() *ssoClient {
+	return &ssoClient{
+		http:       &http.Client{Timeout: 10 * time.Second},
+		discovered: make(map[string]discoveredEndpoints),
+	}
+}
+
+// endpoints returns the provider's endpoints, discovering those of an OIDC
+// provider from its issuer.
+func (c *ssoClient) endpoints(ctx context.Context, s *SSO) (ssoEndpoints, error) {
+	issuer := s.issuer()
+	if issuer == "" {
+		return githubEndpoints, nil
+	}
+
+	c.mu.Lock()
+	cached, ok := c.discovered[issuer]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetched) < ssoDiscoveryTTL {
+		return cached.endpoints, nil
+	}
+
+	var doc struct {
+		Issuer                string   `json:"issuer"`
+		AuthorizationEndpoint string   `json:"authorization_endpoint"`
+		TokenEndpoint         string   `json:"token_endpoint"`
+		UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+		TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+	}
+	if err := c.getJSON(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", "", &doc); err != nil {
+		return ssoEndpoints{}, fmt.Errorf("OIDC discovery: %w", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return ssoEndpoints{}, fmt.Errorf("OIDC discovery: issuer %q doesn't match %q", doc.Issuer, issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" {
+		return ssoEndpoints{}, errors.New("OIDC discovery: missing authorization or token endpoint")
+	}
+	endpoints := ssoEndpoints{
+		issuer:    doc.Issuer,
+		authorize: doc.AuthorizationEndpoint,
+		token:     doc.TokenEndpoint,
+		userinfo:  doc.UserinfoEndpoint,
+		basicAuth: len(doc.TokenAuthMethods) == 0 || slices.Contains(doc.TokenAuthMethods, "client_secret_basic"),
+	}
+
+	c.mu.Lock()
+	c.discovered[issuer] = discoveredEndpoints{endpoints: endpoints, fetched: time.Now()}
+	c.mu.Unlock()
+	return endpoints, nil
+}
+
+// identify exchanges the code the provider sent the user back with for who
+// the user is.
+func (c *ssoClient) identify(ctx context.Context, s *SSO, endpoints ssoEndpoints, code, redirectURI string, login ssoLogin) (ssoIdentity, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {login.Verifier},
+	}
+	if !endpoints.basicAuth {
+		form.Set("client_id", s.ClientID)
+		form.Set("client_secret", s.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoints.token, strings.NewReader(form.Encode()))
+	if err != nil {
+		return ssoIdentity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if endpoints.basicAuth {
+		req.SetBasicAuth(url.QueryEscape(s.ClientID), url.QueryEscape(s.ClientSecret))
+	}
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := c.doJSON(req, &token); err != nil && token.Error == "" {
+		return ssoIdentity{}, fmt.Errorf("token exchange: %w", err)
+	}
+	// GitHub reports errors with status 200.
+	if token.Error != "" {
+		return ssoIdentity{}, fmt.Errorf("token exchange: %s: %s", token.Error, token.ErrorDescription)
+	}
+
+	if s.Provider == constants.SSOProviderGitHub {
+		return c.githubIdentity(ctx, endpoints, token.AccessToken)
+	}
+	return c.oidcIdentity(ctx, s, endpoints, token.IDToken, token.AccessToken, login.Nonce)
+}
+
+// oidcIdentity reads the user from an ID token. The token came straight from
+// the token endpoint over TLS, which OIDC accepts in place of checking its
+// signature; its claims are still checked.
+func (c *ssoClient) oidcIdentity(ctx context.Context, s *SSO, endpoints ssoEndpoints, idToken, accessToken, nonce string) (ssoIdentity, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return ssoIdentity{}, errors.New("no ID token in the token response")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ssoIdentity{}, fmt.Errorf("ID token: %w", err)
+	}
+	var claims struct {
+		oidcUser
+		Issuer    string          `json:"iss"`
+		Audience  json.RawMessage `json:"aud"`
+		ExpiresAt int64           `json:"exp"`
+		Nonce     string          `json:"nonce"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ssoIdentity{}, fmt.Errorf("ID token: %w", err)
+	}
+
+	var audience []string
+	if err := json.Unmarshal(claims.Audience, &audience); err != nil {
+		audience = make([]string, 1)
+		if err := json.Unmarshal(claims.Audience, &audience[0]); err != nil {
+			return ssoIdentity{}, errors.New("ID token: invalid audience")
+		}
+	}
+	switch {
+	case claims.Issuer != endpoints.issuer:
+		return ssoIdentity{}, fmt.Errorf("ID token: issuer %q, want %q", claims.Issuer, endpoints.issuer)
+	case !slices.Contains(audience, s.ClientID):
+		return ssoIdentity{}, errors.New("ID token: issued for another client")
+	case time.Now().Unix() >= claims.ExpiresAt:
+		return ssoIdentity{}, errors.New("ID token: expired")
+	case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return ssoIdentity{}, errors.New("ID token: nonce doesn't match")
+	case claims.Subject == "":
+		return ssoIdentity{}, errors.New("ID token: no subject")
+	}
+
+	user := claims.oidcUser
+	if user.Email == "" && endpoints.userinfo != "" && accessToken != "" {
+		var info oidcUser
+		if err := c.getJSON(ctx, endpoints.userinfo, accessToken, &info); err != nil {
+			return ssoIdentity{}, fmt.Errorf("userinfo: %w", err)
+		}
+		if info.Subject != user.Subject {
+			return ssoIdentity{}, errors.New("userinfo: subject doesn't match the ID token")
+		}
+		user = info
+	}
+	return user.identity(), nil
+}
+
+// oidcUser holds the standard claims about the user.
+type oidcUser struct {
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+	EmailVerified     *bool  `json:"email_verified"`
+}
+
+// identity returns the user; an email without email_verified counts as
+// verified, as the provider didn't say otherwise.
+func (u oidcUser) identity() ssoIdentity {
+	id := ssoIdentity{
+		User:          u.Subject,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified == nil || *u.EmailVerified,
+	}
+	if u.PreferredUsername != "" {
+		id.User = u.PreferredUsername
+	}
+	return id
+}
+
+// githubIdentity looks up the GitHub user and their primary verified email
+// address.
+func (c *ssoClient) githubIdentity(ctx context.Context, endpoints ssoEndpoints, accessToken string) (ssoIdentity, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := c.getJSON(ctx, endpoints.userinfo, accessToken, &user); err != nil {
+		return ssoIdentity{}, fmt.Errorf("GitHub user: %w", err)
+	}
+	if user.Login == "" {
+		return ssoIdentity{}, errors.New("GitHub user: no login")
+	}
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := c.getJSON(ctx, endpoints.userinfo+"/emails", accessToken, &emails); err != nil {
+		return ssoIdentity{}, fmt.Errorf("GitHub emails: %w", err)
+	}
+	id := ssoIdentity{User: user.Login}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			id.Email = e.Email
+			id.EmailVerified = true
+		}
+	}
+	return id, nil
+}
+
+// getJSON fetches rawURL, with accessToken as bearer token if set, into v.
+func (c *ssoClient) getJSON(ctx context.Context, rawURL, accessToken string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return c.doJSON(req, v)
+}
+
+// doJSON sends req and decodes the response into v. The body of an error
+// response is decoded as well, for the provider's error fields.
+func (c *ssoClient) doJSON(req *http.Request, v any) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	decodeErr := json.Unmarshal(body, v)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status %d", req.URL.Redacted(), resp.StatusCode)
+	}
+	return decodeErr
+}
+
+// serveSSOPath answers the sign-in callback and sign-out paths of a route
+// with SSO, and reports whether the request was one of them. It runs before
+// the route's redirects, which could otherwise rewrite the callback URL.
+func (p *Proxy) serveSSOPath(w http.ResponseWriter, r *http.Request, route *Route, startTime time.Time) bool {
+	if route.Options.SSO == nil {
+		return false
+	}
+	switch strings.TrimPrefix(r.URL.Path, route.Options.PathPrefix) {
+	case ssoCallbackPath:
+		p.ssoCallback(w, r, route, startTime)
+		return true
+	case ssoLogoutPath:
+		http.SetCookie(w, ssoCookie(route, ssoSessionCookie, "", -1))
+		p.logRequest(r, http.StatusFound, time.Since(startTime))
+		http.Redirect(w, r, route.Options.PathPrefix+"/", http.StatusFound)
+		return true
+	}
+	return false
+}
+
+// authorizeSSO passes the signed-in user of a route with SSO to the backend,
+// and sends users that aren't signed in to the identity provider. It reports
+// whether the request may go on; if not, it has been answered.
+func (p *Proxy) authorizeSSO(w http.ResponseWriter, r *http.Request, route *Route, startTime time.Time) bool {
+	r.Header.Del(headerAuthUser)
+	r.Header.Del(headerAuthEmail)
+
+	sso := route.Options.SSO
+	if sso == nil {
+		return true
+	}
+	if cookie, err := r.Cookie(ssoSessionCookie); err == nil {
+		var session ssoSession
+		if sso.open("session "+ssoRoute(route), cookie.Value, &session) &&
+			session.Route == ssoRoute(route) && time.Now().Unix() < session.Expires {
+			// The allowed users may have changed since the user signed in.
+			if !sso.allows(session.identity()) {
+				p.logger.Info("SSO: user no longer allowed", "app", route.Options.App, "user", session.User, "email", session.Email)
+				http.SetCookie(w, ssoCookie(route, ssoSessionCookie, "", -1))
+				p.logRequest(r, http.StatusForbidden, time.Since(startTime))
+				p.serveRouteErrorPage(w, r, route, http.StatusForbidden, "Your account doesn't have access to this app")
+				return false
+			}
+			r.Header.Set(headerAuthUser, session.User)
+			if session.Email != "" {
+				r.Header.Set(headerAuthEmail, session.Email)
+			}
+			// The backend has no use for the proxy's cookies.
+			removeCookies(r, ssoSessionCookie, ssoLoginCookie)
+			return true
+		}
+	}
+
+	// Only page loads can follow the redirect to the provider.
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		p.logRequest(r, http.StatusUnauthorized, time.Since(startTime))
+		p.serveRouteErrorPage(w, r, route, http.StatusUnauthorized, "Sign-in required")
+		return false
+	}
+
+	endpoints, err := p.sso.endpoints(r.Context(), sso)
+	if err != nil {
+		p.logger.Error("SSO: identity provider unavailable", "app", route.Options.App, "error", err)
+		p.logRequest(r, http.StatusBadGateway, time.Since(startTime))
+		p.serveRouteErrorPage(w, r, route, http.StatusBadGateway, "Sign-in provider unavailable")
+		return false
+	}
+	login := ssoLogin{
+		State:    rand.Text(),
+		Nonce:    rand.Text(),
+		Verifier: rand.Text() + rand.Text(),
+		Return:   r.URL.RequestURI(),
+		Expires:  time.Now().Add(ssoLoginTimeout).Unix(),
+	}
+	value, err := sso.seal("login "+ssoRoute(route), login)
+	if err != nil {
+		p.logRequest(r, http.StatusInternalServerError, time.Since(startTime))
+		p.serveRouteErrorPage(w, r, route, http.StatusInternalServerError, "Internal Server Error")
+		return false
+	}
+	http.SetCookie(w, ssoCookie(route, ssoLoginCookie, value, ssoLoginTimeout))
+
+	challenge := sha256.Sum256([]byte(login.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {sso.ClientID},
+		"redirect_uri":          {ssoRedirectURI(route)},
+		"scope":                 {sso.scope()},
+		"state":                 {login.State},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	if sso.issuer() != "" {
+		query.Set("nonce", login.Nonce)
+	}
+	separator := "?"
+	if strings.Contains(endpoints.authorize, "?") {
+		separator = "&"
+	}
+	p.logRequest(r, http.StatusFound, time.Since(startTime))
+	http.Redirect(w, r, endpoints.authorize+separator+query.Encode(), http.StatusFound)
+	return false
+}
+
+// ssoCallback finishes a sign-in when the identity provider sends the user
+// back, and returns them to the page they asked for.
+func (p *Proxy) ssoCallback(w http.ResponseWriter, r *http.Request, route *Route, startTime time.Time) {
+	sso := route.Options.SSO
+	fail := func(status int, message string) {
+		p.logRequest(r, status, time.Since(startTime))
+		p.serveRouteErrorPage(w, r, route, status, message)
+	}
+
+	var login ssoLogin
+	cookie, err := r.Cookie(ssoLoginCookie)
+	if err != nil || !sso.open("login "+ssoRoute(route), cookie.Value, &login) || time.Now().Unix() >= login.Expires {
+		fail(http.StatusBadRequest, "Sign-in expired, please try again")
+		return
+	}
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(login.State)) != 1 {
+		fail(http.StatusBadRequest, "Sign-in expired, please try again")
+		return
+	}
+	if e := query.Get("error"); e != "" {
+		p.logger.Info("SSO: sign-in denied by the identity provider", "app", route.Options.App, "error", e)
+		fail(http.StatusForbidden, "Sign-in was denied")
+		return
+	}
+
+	endpoints, err := p.sso.endpoints(r.Context(), sso)
+	if err != nil {
+		p.logger.Error("SSO: identity provider unavailable", "app", route.Options.App, "error", err)
+		fail(http.StatusBadGateway, "Sign-in provider unavailable")
+		return
+	}
+	id, err := p.sso.identify(r.Context(), sso, endpoints, query.Get("code"), ssoRedirectURI(route), login)
+	if err != nil {
+		p.logger.Warn("SSO: sign-in failed", "app", route.Options.App, "error", err)
+		fail(http.StatusForbidden, "Sign-in failed")
+		return
+	}
+	if !sso.allows(id) {
+		p.logger.Info("SSO: user not allowed", "app", route.Options.App, "user", id.User, "email", id.Email)
+		fail(http.StatusForbidden, "Your account doesn't have access to this app")
+		return
+	}
+
+	value, err := sso.seal("session "+ssoRoute(route), ssoSession{
+		User:          id.User,
+		Email:         id.Email,
+		EmailVerified: id.EmailVerified,
+		Route:         ssoRoute(route),
+		Expires:       time.Now().Add(ssoSessionDuration).Unix(),
+	})
+	if err != nil {
+		fail(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	http.SetCookie(w, ssoCookie(route, ssoSessionCookie, value, ssoSessionDuration))
+	http.SetCookie(w, ssoCookie(route, ssoLoginCookie, "", -1))
+
+	// Return is a path; on its own, "//host/..." would leave the site.
+	location := "https://" + route.Canonical + login.Return
+	p.logRequest(r, http.StatusFound, time.Since(startTime))
+	http.Redirect(w, r, location, http.StatusFound)
+}
+
+// ssoRoute identifies a route for its SSO cookies. It goes into their seal
+// purpose and the session, so a cookie from one route doesn't pass on another
+// route sharing the client credentials.
+func ssoRoute(route *Route) string {
+	return route.Canonical + route.Options.PathPrefix
+}
+
+// ssoRedirectURI is the callback URL of a route, on its canonical domain.
+func ssoRedirectURI(route *Route) string {
+	return "https://" + route.Canonical + route.Options.PathPrefix + ssoCallbackPath
+}
+
+// ssoCookie returns a cookie of the route's SSO, scoped to its path prefix.
+// A negative maxAge deletes it.
+func ssoCookie(route *Route, name, value string, maxAge time.Duration) *http.Cookie {
+	path := route.Options.PathPrefix
+	if path == "" {
+		path = "/"
+	}
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(maxAge.Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+// removeCookies drops the named cookies from the request.
+func removeCookies(r *http.Request, names ...string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if !slices.Contains(names, cookie.Name) {
+			r.AddCookie(cookie)
+		}
+	}
+}
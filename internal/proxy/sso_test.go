@@ -0,0 +1,325 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+// fakeOIDCProvider is an OIDC provider that signs in whoever email is set
+// to.
+type fakeOIDCProvider struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	email     string
+	nonce     string
+	challenge string
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	t.Helper()
+	idp := &fakeOIDCProvider{email: "alice@example.com"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"token_endpoint_auth_methods_supported": []string{"client_secret_basic"},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		id, secret, _ := r.BasicAuth()
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if id != "dashboard" || secret != "s3cret" || r.PostFormValue("code") != "good-code" ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims, _ := json.Marshal(map[string]any{
+			"iss":            idp.URL,
+			"aud":            "dashboard",
+			"sub":            "1234",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"nonce":          idp.nonce,
+			"email":          idp.email,
+			"email_verified": true,
+		})
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "token",
+			"id_token":     "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(claims) + ".sig",
+		})
+	})
+	idp.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// authorize plays the user's visit to the authorization endpoint and returns
+// the state to send back.
+func (idp *fakeOIDCProvider) authorize(t *testing.T, location string) string {
+	t.Helper()
+	u, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(location, idp.URL+"/authorize?") {
+		t.Fatalf("Location = %q, want the provider's authorization endpoint", location)
+	}
+	q := u.Query()
+	if q.Get("redirect_uri") != "https://app.example.com"+ssoCallbackPath || q.Get("client_id") != "dashboard" {
+		t.Fatalf("authorization request = %v", q)
+	}
+	idp.mu.Lock()
+	idp.nonce = q.Get("nonce")
+	idp.challenge = q.Get("code_challenge")
+	idp.mu.Unlock()
+	return q.Get("state")
+}
+
+func responseCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestHTTPSHandler_SSO(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer backend.Close()
+	host, port := backendAddr(t, backend)
+
+	idp := newFakeOIDCProvider(t)
+	rb := NewRouteBuilder()
+	rb.AddRouteWithOptions("app.example.com", nil, []Backend{{IP: host, Port: port}}, RouteOptions{
+		SSO: &SSO{
+			Provider:       constants.SSOProviderOIDC,
+			Issuer:         idp.URL,
+			ClientID:       "dashboard",
+			ClientSecret:   "s3cret",
+			AllowedDomains: []string{"example.com"},
+		},
+	})
+	cfg, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newTestProxy()
+	p.sso.http = idp.Client()
+	p.UpdateConfig(cfg)
+	handler := p.httpsHandler()
+
+	// signIn goes through the sign-in and returns the response to the
+	// callback.
+	signIn := func(t *testing.T) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://app.example.com/reports?year=2026", nil))
+		if w.Code != http.StatusFound {
+			t.Fatalf("status = %d, want a redirect to the provider", w.Code)
+		}
+		state := idp.authorize(t, w.Header().Get("Location"))
+		login := responseCookie(w, ssoLoginCookie)
+		if login == nil {
+			t.Fatal("no login cookie set")
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "https://app.example.com"+ssoCallbackPath+"?code=good-code&state="+state, nil)
+		r.AddCookie(login)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := signIn(t)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://app.example.com/reports?year=2026" {
+		t.Fatalf("callback status = %d, Location = %q, want a redirect to the page asked for", w.Code, w.Header().Get("Location"))
+	}
+	session := responseCookie(w, ssoSessionCookie)
+	if session == nil || !session.Secure || !session.HttpOnly {
+		t.Fatalf("session cookie = %+v, want a secure, HTTP-only cookie", session)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "https://app.example.com/reports", nil)
+	r.AddCookie(session)
+	r.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	r.Header.Set(headerAuthUser, "admin")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("signed-in status = %d, want %d", w.Code, http.StatusOK)
+	}
+	got := <-received
+	if got.Header.Get(headerAuthUser) != "1234" || got.Header.Get(headerAuthEmail) != "alice@example.com" {
+		t.Errorf("identity headers = %q, %q, want the signed-in user", got.Header.Get(headerAuthUser), got.Header.Get(headerAuthEmail))
+	}
+	if cookie := got.Header.Get("Cookie"); cookie != "theme=dark" {
+		t.Errorf("backend Cookie = %q, want only the app's cookies", cookie)
+	}
+
+	// A session cookie signed with another secret is ignored.
+	forged, err := (&SSO{ClientID: "dashboard", ClientSecret: "guess"}).seal("session app.example.com", ssoSession{User: "admin", Route: "app.example.com", Expires: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = httptest.NewRequest(http.MethodGet, "https://app.example.com/reports", nil)
+	r.AddCookie(&http.Cookie{Name: ssoSessionCookie, Value: forged})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusFound {
+		t.Errorf("forged session status = %d, want a redirect to the provider", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "https://app.example.com/reports", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("POST without a session status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://app.example.com"+ssoCallbackPath+"?code=good-code&state=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("callback without a login status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	idp.mu.Lock()
+	idp.email = "mallory@other.example"
+	idp.mu.Unlock()
+	if w := signIn(t); w.Code != http.StatusForbidden || responseCookie(w, ssoSessionCookie) != nil {
+		t.Errorf("callback for a user not allowed status = %d, want %d without a session", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestSSO_Allows(t *testing.T) {
+	sso := &SSO{AllowedEmails: []string{"Bob@partner.example"}, AllowedDomains: []string{"example.com"}}
+	tests := []struct {
+		id   ssoIdentity
+		want bool
+	}{
+		{ssoIdentity{Email: "alice@example.com", EmailVerified: true}, true},
+		{ssoIdentity{Email: "bob@partner.example", EmailVerified: true}, true},
+		{ssoIdentity{Email: "alice@example.com"}, false},
+		{ssoIdentity{Email: "eve@partner.example", EmailVerified: true}, false},
+		{ssoIdentity{Email: "eve@example.com.evil", EmailVerified: true}, false},
+		{ssoIdentity{User: "octocat"}, false},
+	}
+	for _, tt := range tests {
+		if got := sso.allows(tt.id); got != tt.want {
+			t.Errorf("allows(%+v) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+	if !(&SSO{}).allows(ssoIdentity{User: "anyone"}) {
+		t.Error("allows() without allow lists = false, want every user allowed")
+	}
+}
+
+func TestSSO_SealPurpose(t *testing.T) {
+	sso := &SSO{ClientID: "app", ClientSecret: "secret"}
+	value, err := sso.seal("login app.example.com", ssoLogin{State: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var login ssoLogin
+	if !sso.open("login app.example.com", value, &login) || login.State != "abc" {
+		t.Fatalf("open() = %+v, want the sealed login", login)
+	}
+	var session ssoSession
+	if sso.open("session app.example.com", value, &session) {
+		t.Error("open() accepted a login cookie as a session")
+	}
+	if sso.open("login admin.example.com", value, &login) {
+		t.Error("open() accepted a login cookie of another route")
+	}
+}
+
+func TestHTTPSHandler_SSOSessionScope(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	host, port := backendAddr(t, backend)
+
+	idp := newFakeOIDCProvider(t)
+	p := newTestProxy()
+	p.sso.http = idp.Client()
+	// configure routes app.example.com and admin.example.com, sharing the
+	// client credentials, with the users allowed on app.example.com.
+	configure := func(allowed ...string) {
+		t.Helper()
+		rb := NewRouteBuilder()
+		for _, domain := range []string{"app.example.com", "admin.example.com"} {
+			sso := &SSO{
+				Provider:     constants.SSOProviderOIDC,
+				Issuer:       idp.URL,
+				ClientID:     "dashboard",
+				ClientSecret: "s3cret",
+			}
+			if domain == "app.example.com" {
+				sso.AllowedEmails = allowed
+			} else {
+				sso.AllowedEmails = []string{"root@example.com"}
+			}
+			rb.AddRouteWithOptions(domain, nil, []Backend{{IP: host, Port: port}}, RouteOptions{SSO: sso})
+		}
+		cfg, err := rb.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.UpdateConfig(cfg)
+	}
+	configure("alice@example.com")
+	handler := p.httpsHandler()
+
+	sso := &SSO{ClientID: "dashboard", ClientSecret: "s3cret"}
+	appRoute := &Route{Canonical: "app.example.com"}
+	value, err := sso.seal("session "+ssoRoute(appRoute), ssoSession{
+		User:          "1234",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Route:         ssoRoute(appRoute),
+		Expires:       time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		r.AddCookie(&http.Cookie{Name: ssoSessionCookie, Value: value})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := get("https://app.example.com/"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d for the route signed in to", w.Code, http.StatusOK)
+	}
+	if w := get("https://admin.example.com/"); w.Code != http.StatusFound {
+		t.Errorf("status on another route = %d, want a redirect to the provider", w.Code)
+	}
+
+	// Taking the user off the allow list signs them out right away.
+	configure("bob@example.com")
+	w := get("https://app.example.com/")
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status after the user was removed = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if cookie := responseCookie(w, ssoSessionCookie); cookie == nil || cookie.MaxAge >= 0 {
+		t.Errorf("session cookie = %+v, want it deleted", cookie)
+	}
+}
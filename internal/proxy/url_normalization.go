@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/proxywire"
+)
+
+// URLNormalization is how a route's request paths are brought into canonical
+// form. TrailingSlash is one of the constants.TrailingSlash* values or empty.
+type URLNormalization struct {
+	TrailingSlash string
+	Lowercase     bool
+	MergeSlashes  bool
+}
+
+func urlNormalizationFromSnapshot(n *proxywire.URLNormalization) *URLNormalization {
+	if n == nil {
+		return nil
+	}
+	return &URLNormalization{TrailingSlash: n.TrailingSlash, Lowercase: n.Lowercase, MergeSlashes: n.MergeSlashes}
+}
+
+// wire returns the wire form of n, which may be nil.
+func (n *URLNormalization) wire() *proxywire.URLNormalization {
+	if n == nil {
+		return nil
+	}
+	return &proxywire.URLNormalization{TrailingSlash: n.TrailingSlash, Lowercase: n.Lowercase, MergeSlashes: n.MergeSlashes}
+}
+
+// normalize returns the canonical form of an escaped request path. A nil n
+// leaves it as it is.
+func (n *URLNormalization) normalize(p string) string {
+	if n == nil {
+		return p
+	}
+	if n.MergeSlashes {
+		for strings.Contains(p, "//") {
+			p = strings.ReplaceAll(p, "//", "/")
+		}
+	}
+	if n.Lowercase {
+		p = strings.ToLower(p)
+	}
+	switch n.TrailingSlash {
+	case constants.TrailingSlashAdd:
+		// Paths of files keep their form; /logo.png/ would be a different URL.
+		if !strings.HasSuffix(p, "/") && !strings.Contains(path.Base(p), ".") {
+			p += "/"
+		}
+	case constants.TrailingSlashRemove:
+		if trimmed := strings.TrimRight(p, "/"); trimmed != "" {
+			p = trimmed
+		} else {
+			p = "/"
+		}
+	}
+	return p
+}
+
+// canonicalRedirect returns where a request for host must be redirected
+// before it reaches the route's backends: a matching configured redirect, or
+// the route's canonical domain with the normalized path when the host is an
+// alias or the path isn't normalized. All of it is one redirect, so clients
+// and crawlers never follow a chain. Only GET and HEAD paths are normalized,
+// as other methods may not be replayed on the new URL.
+func (r *Route) canonicalRedirect(host string, req *http.Request) (location string, status int, ok bool) {
+	original := req.URL.EscapedPath()
+	p := original
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		p = r.Options.URLNormalization.normalize(p)
+	}
+	if location, status, ok := r.findRedirect(host, p, req.URL.RawQuery); ok {
+		return location, status, true
+	}
+	if host == r.canonicalHost(host) && p == original {
+		return "", 0, false
+	}
+	return r.canonicalURL(host, p, req.URL.RawQuery), http.StatusMovedPermanently, true
+}
+
+// canonicalHost returns the canonical domain for a request for host. A
+// wildcard canonical domain like "*.example.com" names no single host to
+// redirect to, so every host of the route is its own canonical domain.
+func (r *Route) canonicalHost(host string) string {
+	if !strings.HasPrefix(r.Canonical, "*.") {
+		return r.Canonical
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// canonicalURL returns the HTTPS URL of an escaped path and query on the
+// canonical domain for a request for host.
+func (r *Route) canonicalURL(host, escapedPath, rawQuery string) string {
+	location := "https://" + r.canonicalHost(host) + escapedPath
+	if rawQuery != "" {
+		location += "?" + rawQuery
+	}
+	return location
+}
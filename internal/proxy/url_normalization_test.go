@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+func TestURLNormalization_Normalize(t *testing.T) {
+	tests := []struct {
+		name string
+		n    *URLNormalization
+		path string
+		want string
+	}{
+		{"nil", nil, "/A//b/", "/A//b/"},
+		{"merge slashes", &URLNormalization{MergeSlashes: true}, "//a///b//", "/a/b/"},
+		{"lowercase", &URLNormalization{Lowercase: true}, "/Shop/Item%2F", "/shop/item%2f"},
+		{"add slash", &URLNormalization{TrailingSlash: constants.TrailingSlashAdd}, "/about", "/about/"},
+		{"add slash keeps files", &URLNormalization{TrailingSlash: constants.TrailingSlashAdd}, "/assets/logo.png", "/assets/logo.png"},
+		{"add slash to root", &URLNormalization{TrailingSlash: constants.TrailingSlashAdd}, "/", "/"},
+		{"remove slash", &URLNormalization{TrailingSlash: constants.TrailingSlashRemove}, "/about/", "/about"},
+		{"remove slash keeps root", &URLNormalization{TrailingSlash: constants.TrailingSlashRemove}, "//", "/"},
+		{"everything", &URLNormalization{TrailingSlash: constants.TrailingSlashRemove, Lowercase: true, MergeSlashes: true}, "/Blog//Post/", "/blog/post"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.n.normalize(tt.path); got != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlers_URLNormalization(t *testing.T) {
+	p := newTestProxy()
+	rb := NewRouteBuilder()
+	rb.AddRouteWithOptions("www.example.com", []string{"example.com"}, nil, RouteOptions{
+		Redirects: []Redirect{{From: "/old-path", To: "/new-path", Status: http.StatusPermanentRedirect}},
+		URLNormalization: &URLNormalization{
+			TrailingSlash: constants.TrailingSlashRemove,
+			Lowercase:     true,
+			MergeSlashes:  true,
+		},
+	})
+	cfg, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.UpdateConfig(cfg)
+
+	tests := []struct {
+		name         string
+		handler      http.Handler
+		method       string
+		url          string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"https path", p.httpsHandler(), http.MethodGet, "https://www.example.com/About//Us/?ref=x", http.StatusMovedPermanently, "https://www.example.com/about/us?ref=x"},
+		{"https alias and path in one redirect", p.httpsHandler(), http.MethodGet, "https://example.com/About/", http.StatusMovedPermanently, "https://www.example.com/about"},
+		{"http, alias and path in one redirect", p.httpHandler(), http.MethodGet, "http://example.com/About/", http.StatusMovedPermanently, "https://www.example.com/about"},
+		{"http canonical path", p.httpHandler(), http.MethodGet, "http://www.example.com/about", http.StatusMovedPermanently, "https://www.example.com/about"},
+		{"redirect matches the normalized path", p.httpHandler(), http.MethodGet, "http://example.com/Old-Path/", http.StatusPermanentRedirect, "https://www.example.com/new-path"},
+		{"post keeps its path", p.httpsHandler(), http.MethodPost, "https://example.com/About/", http.StatusMovedPermanently, "https://www.example.com/About/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.url, nil))
+			if w.Code != tt.wantStatus || w.Header().Get("Location") != tt.wantLocation {
+				t.Errorf("status = %d, Location = %q, want %d, %q", w.Code, w.Header().Get("Location"), tt.wantStatus, tt.wantLocation)
+			}
+		})
+	}
+
+	// A normalized path on the canonical domain is served.
+	w := httptest.NewRecorder()
+	p.httpsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://www.example.com/about/us", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d for a normalized URL", w.Code, http.StatusBadGateway)
+	}
+}
+
+func TestHandlers_WildcardCanonicalKeepsHost(t *testing.T) {
+	p := newTestProxy()
+	rb := NewRouteBuilder()
+	rb.AddRouteWithOptions("*.example.com", nil, nil, RouteOptions{
+		Redirects:        []Redirect{{From: "/old-path", To: "/new-path", Status: http.StatusPermanentRedirect}},
+		URLNormalization: &URLNormalization{Lowercase: true},
+	})
+	cfg, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.UpdateConfig(cfg)
+
+	tests := []struct {
+		name         string
+		handler      http.Handler
+		url          string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"https path", p.httpsHandler(), "https://shop.example.com/About", http.StatusMovedPermanently, "https://shop.example.com/about"},
+		{"https redirect", p.httpsHandler(), "https://shop.example.com/old-path", http.StatusPermanentRedirect, "https://shop.example.com/new-path"},
+		{"http", p.httpHandler(), "http://shop.example.com/about", http.StatusMovedPermanently, "https://shop.example.com/about"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.wantStatus || w.Header().Get("Location") != tt.wantLocation {
+				t.Errorf("status = %d, Location = %q, want %d, %q", w.Code, w.Header().Get("Location"), tt.wantStatus, tt.wantLocation)
+			}
+		})
+	}
+
+	// A host the wildcard covers is canonical and is served.
+	w := httptest.NewRecorder()
+	p.httpsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://shop.example.com/about", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d for a host the wildcard covers", w.Code, http.StatusBadGateway)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httputil"
 	"strings"
 	"sync"
 	"time"
@@ -11,25 +12,57 @@ import (
 
 // setForwardedHeaders replaces any client-supplied forwarding headers with
 // trusted values, mirroring httputil.ReverseProxy's Rewrite + SetXForwarded
-// behavior for requests that bypass the reverse proxy.
-func setForwardedHeaders(r *http.Request) {
+// behavior for requests that bypass the reverse proxy. cdn is the route's CDN.
+func setForwardedHeaders(r *http.Request, cdn string) {
 	r.Header.Del("Forwarded")
 	r.Header.Del("X-Forwarded-For")
 	r.Header.Del("X-Forwarded-Host")
 	r.Header.Del("X-Forwarded-Proto")
 	r.Header.Del("X-Real-IP")
 
-	if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+	if clientIP, ok := clientIP(r, cdn); ok {
 		r.Header.Set("X-Forwarded-For", clientIP)
 	}
+	if cdn != "" && !isTrustedCDNRequest(r, cdn) {
+		r.Header.Del("CF-Connecting-IP")
+	}
 	r.Header.Set("X-Forwarded-Host", r.Host)
-	if r.TLS != nil {
+	if proto, ok := edgeProto(r); ok {
+		r.Header.Set("X-Forwarded-Proto", proto)
+	} else if r.TLS != nil {
 		r.Header.Set("X-Forwarded-Proto", "https")
 	} else {
 		r.Header.Set("X-Forwarded-Proto", "http")
 	}
 }
 
+// setXForwarded is httputil.ProxyRequest.SetXForwarded with the client IP
+// from clientIP. cdn is the route's CDN, empty for direct traffic.
+func setXForwarded(pr *httputil.ProxyRequest, cdn string) {
+	pr.SetXForwarded()
+	if clientIP, ok := clientIP(pr.In, cdn); ok {
+		pr.Out.Header.Set("X-Forwarded-For", clientIP)
+	}
+	if cdn != "" && !isTrustedCDNRequest(pr.In, cdn) {
+		pr.Out.Header.Del("CF-Connecting-IP")
+	}
+	if proto, ok := edgeProto(pr.In); ok {
+		pr.Out.Header.Set("X-Forwarded-Proto", proto)
+	}
+}
+
+// forwardedFor returns the client IP to send in X-Forwarded-For for a remote
+// address. An IPv6 zone ("fe80::1%eth0") is dropped: it only means something
+// on this host, and backends parsing the header as an IP reject it.
+func forwardedFor(remoteAddr string) (string, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return "", false
+	}
+	host, _, _ = strings.Cut(host, "%")
+	return host, true
+}
+
 // isWebSocketUpgrade checks if the request is a WebSocket upgrade request.
 func isWebSocketUpgrade(r *http.Request) bool {
 	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
@@ -41,20 +74,20 @@ func isWebSocketUpgrade(r *http.Request) bool {
 func (p *Proxy) handleWebSocket(w http.ResponseWriter, r *http.Request, route *Route, startTime time.Time) {
 	if len(route.Backends) == 0 {
 		p.logRequest(r, http.StatusBadGateway, time.Since(startTime))
-		p.serveErrorPage(w, http.StatusBadGateway, "No backends available")
+		p.serveRouteErrorPage(w, r, route, http.StatusBadGateway, "No backends available")
 		return
 	}
 
 	backend := route.nextBackend()
-	backendAddr := net.JoinHostPort(backend.IP, backend.Port)
+	backendAddr := backend.addr()
 
-	backendConn, err := net.DialTimeout("tcp", backendAddr, 10*time.Second)
+	backendConn, err := p.dialBackend(backend)
 	if err != nil {
 		p.logger.Error("WebSocket: failed to connect to backend",
 			"backend", backendAddr,
 			"error", err)
 		p.logRequest(r, http.StatusBadGateway, time.Since(startTime))
-		p.serveErrorPage(w, http.StatusBadGateway, "Backend unavailable")
+		p.serveRouteErrorPage(w, r, route, http.StatusBadGateway, "Backend unavailable")
 		return
 	}
 	defer backendConn.Close()
@@ -83,7 +116,10 @@ func (p *Proxy) handleWebSocket(w http.ResponseWriter, r *http.Request, route *R
 	}
 	defer p.untrackWebSocket(clientConn, backendConn)
 
-	setForwardedHeaders(r)
+	setForwardedHeaders(r, route.Options.CDN)
+	if route.Options.StripPrefix && backend.Peer == "" {
+		stripPathPrefix(r.URL, route.Options.PathPrefix)
+	}
 
 	// Forward the original HTTP request to the backend to initiate the WebSocket handshake
 	if err := r.Write(backendConn); err != nil {
@@ -106,8 +142,8 @@ func (p *Proxy) handleWebSocket(w http.ResponseWriter, r *http.Request, route *R
 		}
 		io.Copy(backendConn, clientConn)
 		// Signal EOF to backend
-		if tcpConn, ok := backendConn.(*net.TCPConn); ok {
-			tcpConn.CloseWrite()
+		if conn, ok := backendConn.(interface{ CloseWrite() error }); ok {
+			conn.CloseWrite()
 		}
 	}()
 
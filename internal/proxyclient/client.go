@@ -18,6 +18,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
@@ -52,6 +53,9 @@ type Client struct {
 	// once per outage instead of every tick.
 	reachableMu sync.Mutex
 	unreachable bool
+	// listenersDown are the proxy listeners last reported down, so each
+	// outage is logged once.
+	listenersDown []string
 }
 
 // New creates a client for the control socket under dataDir.
@@ -176,6 +180,35 @@ func (c *Client) Status(ctx context.Context) (*proxywire.Status, error) {
 	return &status, nil
 }
 
+// Config fetches the snapshot the proxy is currently routing by.
+func (c *Client) Config(ctx context.Context) (*proxywire.Snapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://haloy-proxy/v1/config", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		c.setUnreachable(err)
+		return nil, fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	defer resp.Body.Close()
+	c.setReachable()
+
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		// Proxies predating the endpoint only accept PUT on this path.
+		return nil, errors.New("haloy-proxy doesn't report its routes, upgrade it to inspect them")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy config lookup failed: %s: %s", resp.Status, readErrorBody(resp.Body))
+	}
+
+	var snap proxywire.Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("decode proxy config: %w", err)
+	}
+	return &snap, nil
+}
+
 // WaitReady polls the proxy until it answers status requests, so ACME
 // challenges have a live route to the challenge server before certificate
 // issuance starts.
@@ -231,6 +264,7 @@ func (c *Client) reconcile(ctx context.Context) {
 		// Logged once by setUnreachable; keep ticking until the proxy is back.
 		return
 	}
+	c.recordListeners(status)
 	if status.ConfigHash == snap.Hash() {
 		return
 	}
@@ -270,6 +304,26 @@ func (c *Client) setReachable() {
 	}
 }
 
+// recordListeners logs proxy listeners going down and coming back. The proxy
+// rebinds them itself; haloyd only makes the outage visible in its own log.
+func (c *Client) recordListeners(status *proxywire.Status) {
+	down := status.DownListeners()
+
+	c.reachableMu.Lock()
+	defer c.reachableMu.Unlock()
+	for _, listener := range down {
+		if !slices.Contains(c.listenersDown, listener) {
+			c.logger.Error("haloy-proxy listener is down; the proxy is rebinding it", "listener", listener)
+		}
+	}
+	for _, listener := range c.listenersDown {
+		if !slices.Contains(down, listener) {
+			c.logger.Info("haloy-proxy listener is serving again", "listener", listener)
+		}
+	}
+	c.listenersDown = down
+}
+
 func readErrorBody(r io.Reader) string {
 	data, err := io.ReadAll(io.LimitReader(r, 4096))
 	if err != nil || len(data) == 0 {
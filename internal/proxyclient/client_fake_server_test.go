@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/http"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -141,3 +142,15 @@ func TestWaitReadyAndReloadCerts(t *testing.T) {
 		t.Fatalf("ReloadCerts() error = %v", err)
 	}
 }
+
+func TestConfigFromProxyWithoutConfigEndpoint(t *testing.T) {
+	dataDir := tempDataDir(t)
+	startFakeControl(t, dataDir)
+	client := New(dataDir, testLogger())
+
+	// The fake, like proxies predating GET /v1/config, only accepts PUT.
+	_, err := client.Config(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "upgrade") {
+		t.Fatalf("Config() error = %v, want an upgrade hint", err)
+	}
+}
@@ -0,0 +1,116 @@
+package proxywire
+
+import (
+	"net"
+	"slices"
+)
+
+// RouteDiff lists how the routes of one snapshot differ from another's.
+// Routes are matched by canonical domain and path prefix.
+type RouteDiff struct {
+	Added   []Route       `json:"added,omitempty"`
+	Removed []Route       `json:"removed,omitempty"`
+	Changed []RouteChange `json:"changed,omitempty"`
+}
+
+// RouteChange is a route present in both snapshots with different settings.
+type RouteChange struct {
+	From Route `json:"from"`
+	To   Route `json:"to"`
+	// Fields names the settings that differ, using their JSON names.
+	Fields []string `json:"fields"`
+}
+
+// IsEmpty reports whether both snapshots route identically.
+func (d RouteDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffRoutes returns the changes that turn the routes from into the routes
+// to. The order of aliases and backends is ignored.
+func DiffRoutes(from, to []Route) RouteDiff {
+	key := func(r Route) string { return r.Canonical + "\x00" + r.PathPrefix }
+	old := make(map[string]Route, len(from))
+	for _, r := range from {
+		old[key(r)] = r
+	}
+
+	var diff RouteDiff
+	for _, r := range to {
+		prev, ok := old[key(r)]
+		if !ok {
+			diff.Added = append(diff.Added, r)
+			continue
+		}
+		delete(old, key(r))
+		if fields := changedRouteFields(prev, r); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, RouteChange{From: prev, To: r, Fields: fields})
+		}
+	}
+	for _, r := range old {
+		diff.Removed = append(diff.Removed, r)
+	}
+
+	SortRoutes(diff.Added)
+	SortRoutes(diff.Removed)
+	slices.SortFunc(diff.Changed, func(a, b RouteChange) int { return compareRoutes(a.To, b.To) })
+	return diff
+}
+
+func changedRouteFields(a, b Route) []string {
+	var fields []string
+	if !slices.Equal(slices.Sorted(slices.Values(a.Aliases)), slices.Sorted(slices.Values(b.Aliases))) {
+		fields = append(fields, "aliases")
+	}
+	if a.App != b.App {
+		fields = append(fields, "app")
+	}
+	if a.StripPrefix != b.StripPrefix {
+		fields = append(fields, "strip_prefix")
+	}
+	if !slices.Equal(backendAddrs(a.Backends), backendAddrs(b.Backends)) {
+		fields = append(fields, "backends")
+	}
+	if a.CDN != b.CDN {
+		fields = append(fields, "cdn")
+	}
+	if a.MaxBodyBytes != b.MaxBodyBytes {
+		fields = append(fields, "max_body_bytes")
+	}
+	if a.ReadTimeoutMS != b.ReadTimeoutMS {
+		fields = append(fields, "read_timeout_ms")
+	}
+	if a.SendTimeoutMS != b.SendTimeoutMS {
+		fields = append(fields, "send_timeout_ms")
+	}
+	if !slices.Equal(a.Redirects, b.Redirects) {
+		fields = append(fields, "redirects")
+	}
+	if (a.URLNormalization == nil) != (b.URLNormalization == nil) ||
+		(a.URLNormalization != nil && *a.URLNormalization != *b.URLNormalization) {
+		fields = append(fields, "url_normalization")
+	}
+	if (a.ClientAuth == nil) != (b.ClientAuth == nil) ||
+		(a.ClientAuth != nil && *a.ClientAuth != *b.ClientAuth) {
+		fields = append(fields, "client_auth")
+	}
+	if !a.SSO.Equal(b.SSO) {
+		fields = append(fields, "sso")
+	}
+	return fields
+}
+
+// backendAddrs returns the sorted ip:port addresses of backends.
+func backendAddrs(backends []Backend) []string {
+	addrs := make([]string, len(backends))
+	for i, b := range backends {
+		addrs[i] = b.Addr()
+	}
+	slices.Sort(addrs)
+	return addrs
+}
+
+// Addr returns the backend's host:port address.
+func (b Backend) Addr() string {
+	return net.JoinHostPort(b.IP, b.Port)
+}
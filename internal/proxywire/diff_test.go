@@ -0,0 +1,53 @@
+package proxywire
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffRoutes(t *testing.T) {
+	live := []Route{
+		{
+			Canonical: "app.example.com", App: "app", Aliases: []string{"b.example.com", "a.example.com"},
+			Backends: []Backend{{IP: "10.0.0.3", Port: "8080"}, {IP: "10.0.0.2", Port: "8080"}},
+		},
+		{Canonical: "api.example.com", App: "api", Backends: []Backend{{IP: "10.0.0.4", Port: "8080"}}},
+		{Canonical: "api.example.com", PathPrefix: "/v2", App: "api-v2"},
+		{Canonical: "old.example.com", App: "old"},
+	}
+	planned := []Route{
+		// Same routing in a different order is unchanged.
+		{
+			Canonical: "app.example.com", App: "app", Aliases: []string{"a.example.com", "b.example.com"},
+			Backends: []Backend{{IP: "10.0.0.2", Port: "8080"}, {IP: "10.0.0.3", Port: "8080"}},
+		},
+		{Canonical: "api.example.com", App: "api", Backends: []Backend{{IP: "10.0.0.5", Port: "8080"}}, MaxBodyBytes: 1024},
+		{Canonical: "api.example.com", PathPrefix: "/v2", App: "api-v2"},
+		{Canonical: "new.example.com", App: "new"},
+	}
+
+	diff := DiffRoutes(live, planned)
+	if diff.IsEmpty() {
+		t.Fatal("IsEmpty() = true, want changes")
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Canonical != "new.example.com" {
+		t.Errorf("Added = %+v, want new.example.com", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Canonical != "old.example.com" {
+		t.Errorf("Removed = %+v, want old.example.com", diff.Removed)
+	}
+	if len(diff.Changed) != 1 {
+		t.Fatalf("Changed = %+v, want one change", diff.Changed)
+	}
+	change := diff.Changed[0]
+	if change.To.Canonical != "api.example.com" || change.To.PathPrefix != "" {
+		t.Errorf("changed route = %s%s, want api.example.com", change.To.Canonical, change.To.PathPrefix)
+	}
+	if want := []string{"backends", "max_body_bytes"}; !slices.Equal(change.Fields, want) {
+		t.Errorf("Fields = %v, want %v", change.Fields, want)
+	}
+
+	if diff := DiffRoutes(live, live); !diff.IsEmpty() {
+		t.Errorf("DiffRoutes() of identical routes = %+v, want empty", diff)
+	}
+}
@@ -23,7 +23,10 @@ import (
 
 const (
 	// SchemaVersion is the highest snapshot schema version this build understands.
-	SchemaVersion = 1
+	//
+	// 2: several routes may share a canonical domain, split by PathPrefix.
+	// Older proxies would ignore the prefix and route the whole domain to one app.
+	SchemaVersion = 2
 
 	// ProxyGeneration is the minimum proxy rollout generation required by this
 	// build of haloyd. Bump it when a proxy change must be deployed even though
@@ -64,21 +67,174 @@ type Snapshot struct {
 	// APIBackend is haloyd's loopback API listener; the proxy forwards
 	// API-domain and localhost API traffic to it.
 	APIBackend *Backend `json:"api_backend,omitempty"`
-	Routes     []Route  `json:"routes"`
+	// APIAllowedNetworks are the CIDRs whose clients may reach the API
+	// domain; empty allows everyone.
+	APIAllowedNetworks []string `json:"api_allowed_networks,omitempty"`
+	Routes             []Route  `json:"routes"`
+	// OnDemandTLS makes the proxy report TLS handshakes for unknown domains
+	// to haloyd, which may issue a certificate and add a route for them.
+	OnDemandTLS bool `json:"on_demand_tls,omitempty"`
+	// TLS overrides the proxy's TLS handshake settings; nil keeps its defaults.
+	TLS *TLSSettings `json:"tls,omitempty"`
+	// PassiveHealth overrides when the proxy flags a backend from the traffic
+	// it serves; nil keeps its defaults.
+	PassiveHealth *PassiveHealthSettings `json:"passive_health,omitempty"`
+	// AccessLog overrides how the proxy logs requests; nil logs every one.
+	AccessLog *AccessLogSettings `json:"access_log,omitempty"`
+	// Cluster configures traffic between the servers of a cluster; nil when
+	// the server isn't part of one.
+	Cluster *ClusterSettings `json:"cluster,omitempty"`
+}
+
+// PeerRoutesPath is where a peer listener lists the routes its server
+// serves, as a PeerRoutes document.
+const PeerRoutesPath = "/.haloy/cluster/routes"
+
+// ClusterSettings configure the proxy's part in a cluster: the peer listener
+// serving this server's apps to an edge, and how the edge reaches its peers.
+// With the TLS files set, both sides use mutual TLS; otherwise plain HTTP.
+type ClusterSettings struct {
+	// Listen is the address of the peer listener; empty opens none.
+	Listen   string `json:"listen,omitempty"`
+	CAFile   string `json:"ca_file,omitempty"`
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+}
+
+// PeerRoutes are the routes a server serves with its own backends, without
+// the backends. An edge routes them to the server.
+type PeerRoutes struct {
+	Routes []Route `json:"routes"`
+}
+
+// PassiveHealthSettings are the thresholds of the proxy's passive backend
+// checks. Zero fields keep the proxy's defaults.
+type PassiveHealthSettings struct {
+	MaxLatencyMS int64   `json:"max_latency_ms,omitempty"`
+	MaxErrorRate float64 `json:"max_error_rate,omitempty"`
+	// Eject takes flagged backends out of rotation instead of only logging.
+	Eject bool `json:"eject,omitempty"`
+}
+
+// AccessLogSettings control the proxy's access log.
+type AccessLogSettings struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// Sample logs one in every Sample requests with a status below 400;
+	// 0 or 1 logs them all.
+	Sample int `json:"sample,omitempty"`
+}
+
+// TLSSettings are HTTPS handshake settings, using the names accepted by the
+// helpers.Parse* functions.
+type TLSSettings struct {
+	MinVersion       string   `json:"min_version,omitempty"`
+	CipherSuites     []string `json:"cipher_suites,omitempty"`
+	CurvePreferences []string `json:"curve_preferences,omitempty"`
 }
 
 // Route maps a canonical domain (plus aliases) to its backends. A route with
-// no backends is valid: the proxy serves 502 instead of 404 for it.
+// no backends is valid: the proxy serves 502 instead of 404 for it. Routes
+// with a PathPrefix only receive requests under that path; the longest
+// matching prefix on a domain wins.
 type Route struct {
-	Canonical string    `json:"canonical"`
-	Aliases   []string  `json:"aliases,omitempty"`
-	Backends  []Backend `json:"backends,omitempty"`
+	Canonical   string    `json:"canonical"`
+	Aliases     []string  `json:"aliases,omitempty"`
+	App         string    `json:"app,omitempty"`
+	PathPrefix  string    `json:"path_prefix,omitempty"`
+	StripPrefix bool      `json:"strip_prefix,omitempty"`
+	Backends    []Backend `json:"backends,omitempty"`
+
+	// CDN is set for domains behind a CDN; the proxy trusts the CDN's client
+	// IP header on requests from the CDN's addresses.
+	CDN string `json:"cdn,omitempty"`
+
+	// Optional per-route limits; zero means the proxy default.
+	MaxBodyBytes  int64 `json:"max_body_bytes,omitempty"`
+	ReadTimeoutMS int64 `json:"read_timeout_ms,omitempty"`
+	SendTimeoutMS int64 `json:"send_timeout_ms,omitempty"`
+
+	// Redirects are answered before a backend is picked; the first match
+	// wins.
+	Redirects []Redirect `json:"redirects,omitempty"`
+	// URLNormalization is nil unless the route's URLs are normalized.
+	URLNormalization *URLNormalization `json:"url_normalization,omitempty"`
+	// ClientAuth is nil unless clients must present a TLS certificate.
+	ClientAuth *ClientAuth `json:"client_auth,omitempty"`
+	// SSO is nil unless users sign in before reaching the route.
+	SSO *SSO `json:"sso,omitempty"`
+}
+
+// Redirect sends requests for From on the route, or only for those to Host
+// when set, to To. From matches every path below it when it ends in "/*"
+// and every path when empty; a trailing "*" in To is replaced with the rest
+// of the path. To is a path on the route's canonical domain or an absolute
+// URL.
+type Redirect struct {
+	Host   string `json:"host,omitempty"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to"`
+	Status int    `json:"status"`
+}
+
+// URLNormalization is how a route redirects requests to the canonical form of
+// their path. TrailingSlash is one of the constants.TrailingSlash* values or
+// empty.
+type URLNormalization struct {
+	TrailingSlash string `json:"trailing_slash,omitempty"`
+	Lowercase     bool   `json:"lowercase,omitempty"`
+	MergeSlashes  bool   `json:"merge_slashes,omitempty"`
+}
+
+// ClientAuth is the client certificate authentication of a route. CA holds
+// the PEM encoded CA certificates; Mode is one of the constants.ClientAuth*
+// values.
+type ClientAuth struct {
+	CA   string `json:"ca"`
+	Mode string `json:"mode"`
+}
+
+// SSO is how users of a route sign in. Provider is one of the
+// constants.SSOProvider* values; Issuer is only set for OIDC.
+type SSO struct {
+	Provider       string   `json:"provider"`
+	Issuer         string   `json:"issuer,omitempty"`
+	ClientID       string   `json:"client_id"`
+	ClientSecret   string   `json:"client_secret"`
+	AllowedEmails  []string `json:"allowed_emails,omitempty"`
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+}
+
+// Equal reports whether s and o, either of which may be nil, are the same.
+func (s *SSO) Equal(o *SSO) bool {
+	if s == nil || o == nil {
+		return s == o
+	}
+	return s.Provider == o.Provider && s.Issuer == o.Issuer &&
+		s.ClientID == o.ClientID && s.ClientSecret == o.ClientSecret &&
+		slices.Equal(s.AllowedEmails, o.AllowedEmails) &&
+		slices.Equal(s.AllowedDomains, o.AllowedDomains)
+}
+
+// compareRoutes orders routes by canonical domain, then path prefix.
+func compareRoutes(a, b Route) int {
+	if c := strings.Compare(a.Canonical, b.Canonical); c != 0 {
+		return c
+	}
+	return strings.Compare(a.PathPrefix, b.PathPrefix)
+}
+
+// SortRoutes sorts routes into the deterministic order used for hashing.
+func SortRoutes(routes []Route) {
+	slices.SortFunc(routes, compareRoutes)
 }
 
 // Backend is a single upstream address.
 type Backend struct {
 	IP   string `json:"ip"`
 	Port string `json:"port"`
+	// Peer names the cluster peer whose listener is at the address; empty for
+	// a container on this server.
+	Peer string `json:"peer,omitempty"`
 }
 
 // CheckSchemaVersion returns ErrSchemaTooNew if the snapshot was produced by a
@@ -98,23 +254,40 @@ func (s *Snapshot) Hash() string {
 	routes := make([]Route, len(s.Routes))
 	for i, r := range s.Routes {
 		routes[i] = Route{
-			Canonical: r.Canonical,
-			Aliases:   slices.Sorted(slices.Values(r.Aliases)),
-			Backends:  slices.Clone(r.Backends),
+			Canonical:   r.Canonical,
+			Aliases:     slices.Sorted(slices.Values(r.Aliases)),
+			App:         r.App,
+			PathPrefix:  r.PathPrefix,
+			StripPrefix: r.StripPrefix,
+			CDN:         r.CDN,
+			Backends:    slices.Clone(r.Backends),
+
+			MaxBodyBytes:  r.MaxBodyBytes,
+			ReadTimeoutMS: r.ReadTimeoutMS,
+			SendTimeoutMS: r.SendTimeoutMS,
+			Redirects:     r.Redirects,
+
+			URLNormalization: r.URLNormalization,
+			ClientAuth:       r.ClientAuth,
+			SSO:              r.SSO,
 		}
 		slices.SortFunc(routes[i].Backends, func(a, b Backend) int {
-			return strings.Compare(a.IP+":"+a.Port, b.IP+":"+b.Port)
+			return strings.Compare(a.Peer+"/"+a.IP+":"+a.Port, b.Peer+"/"+b.IP+":"+b.Port)
 		})
 	}
-	slices.SortFunc(routes, func(a, b Route) int {
-		return strings.Compare(a.Canonical, b.Canonical)
-	})
+	slices.SortFunc(routes, compareRoutes)
 
 	content := Snapshot{
-		SchemaVersion: s.SchemaVersion,
-		APIDomain:     s.APIDomain,
-		APIBackend:    s.APIBackend,
-		Routes:        routes,
+		SchemaVersion:      s.SchemaVersion,
+		APIDomain:          s.APIDomain,
+		APIBackend:         s.APIBackend,
+		APIAllowedNetworks: s.APIAllowedNetworks,
+		Routes:             routes,
+		OnDemandTLS:        s.OnDemandTLS,
+		TLS:                s.TLS,
+		PassiveHealth:      s.PassiveHealth,
+		AccessLog:          s.AccessLog,
+		Cluster:            s.Cluster,
 	}
 	data, err := json.Marshal(content)
 	if err != nil {
@@ -144,4 +317,80 @@ type Status struct {
 	LastUpdateAt time.Time `json:"last_update_at,omitzero"`
 	// CertsLoaded is the number of TLS certificates in the proxy's cache.
 	CertsLoaded int `json:"certs_loaded"`
+	// Backends reports the traffic of every routed backend.
+	Backends []BackendStatus `json:"backends,omitempty"`
+	// Listeners reports the HTTP and HTTPS listeners. Proxies before
+	// listener supervision leave it empty.
+	Listeners []ListenerStatus `json:"listeners,omitempty"`
+}
+
+// ListenerStatus is the state of one proxy listener. A listener that stops
+// accepting connections is rebound with backoff; while that fails, Serving is
+// false and DownSince is set.
+type ListenerStatus struct {
+	Address   string    `json:"address"`
+	Protocol  string    `json:"protocol"`
+	Serving   bool      `json:"serving"`
+	Restarts  int       `json:"restarts,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+	DownSince time.Time `json:"down_since,omitzero"`
+}
+
+// DownListeners returns the addresses of the listeners that aren't serving,
+// as "protocol address".
+func (s *Status) DownListeners() []string {
+	var down []string
+	for _, l := range s.Listeners {
+		if !l.Serving {
+			down = append(down, l.Protocol+" "+l.Address)
+		}
+	}
+	return down
+}
+
+// BackendStatus is the traffic the proxy sent to one backend. ErrorRate and
+// P95LatencyMS cover the most recent requests only.
+type BackendStatus struct {
+	Address      string  `json:"address"`
+	InFlight     int64   `json:"in_flight"`
+	Requests     uint64  `json:"requests"`
+	Errors       uint64  `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	P95LatencyMS int64   `json:"p95_latency_ms"`
+	// Flagged is set while the backend exceeds a passive health threshold.
+	Flagged bool `json:"flagged,omitempty"`
+	// Ejected is set while the backend is taken out of rotation.
+	Ejected bool `json:"ejected,omitempty"`
+	// LatencyBuckets counts every request by its time to response headers:
+	// entry i those up to LatencyBucketBounds[i], the last those above all
+	// bounds. Proxies before latency histograms leave it empty.
+	LatencyBuckets []uint64 `json:"latency_buckets,omitempty"`
+	// RecentFailures are the last requests that failed, oldest first.
+	RecentFailures []FailedRequest `json:"recent_failures,omitempty"`
+}
+
+// LatencyBucketBounds are the upper bounds of BackendStatus.LatencyBuckets.
+var LatencyBucketBounds = []time.Duration{
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// FailedRequest is a request a backend failed: a transport error or a 5xx
+// response. RequestID is the X-Request-ID the client or the backend set, or
+// the one on the error page the proxy served; it is empty when there was
+// none.
+type FailedRequest struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	Status    int       `json:"status"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
 }
@@ -0,0 +1,62 @@
+// Package sdnotify implements the systemd notify protocol, which services
+// started with Type=notify use to tell systemd they are ready and, with
+// WatchdogSec, that they are still alive. Outside systemd it does nothing.
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends state to systemd. It returns false, and no error, when the
+// process wasn't started by systemd with a notify socket.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the WatchdogSec of the service, or zero when the
+// watchdog isn't enabled for this process.
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// WatchdogTicks returns a channel that ticks at half the watchdog interval,
+// as systemd recommends, for a service loop to send Watchdog from. Sending
+// it from the loop itself means a wedged loop stops the pings and systemd
+// restarts the service. The channel is nil, and never ticks, when the
+// watchdog isn't enabled. Call stop when the loop ends.
+func WatchdogTicks() (ticks <-chan time.Time, stop func()) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(interval / 2)
+	return ticker.C, ticker.Stop
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+// launchdPath is the PATH services get from launchd, which otherwise only
+// has the system directories. Docker Desktop installs its CLI and credential
+// helpers in /usr/local/bin, Homebrew uses /opt/homebrew/bin.
+const launchdPath = "/usr/local/bin:/opt/homebrew/bin:/usr/bin:/bin:/usr/sbin:/sbin"
+
+type launchd struct {
+	plistDir string
+	logDir   string
+	// domain is the launchctl domain target: system for daemons, gui/<uid>
+	// for agents of a logged in user.
+	domain string
+}
+
+// newLaunchd returns a launchd manager for system daemons, started at boot
+// as root.
+func newLaunchd() *launchd {
+	return &launchd{
+		plistDir: "/Library/LaunchDaemons",
+		logDir:   "/Library/Logs/Haloy",
+		domain:   "system",
+	}
+}
+
+// newLaunchdAgent returns a launchd manager for agents of the user with uid,
+// started when they log in.
+func newLaunchdAgent(home string, uid int) *launchd {
+	return &launchd{
+		plistDir: filepath.Join(home, "Library", "LaunchAgents"),
+		logDir:   filepath.Join(home, "Library", "Logs", "Haloy"),
+		domain:   "gui/" + strconv.Itoa(uid),
+	}
+}
+
+func (m *launchd) Name() string { return "launchd" }
+
+func (m *launchd) Install(ctx context.Context, services []Service) error {
+	if err := os.MkdirAll(m.plistDir, constants.ModeFileExec); err != nil {
+		return fmt.Errorf("failed to create %s: %w", m.plistDir, err)
+	}
+	if err := os.MkdirAll(m.logDir, constants.ModeFileExec); err != nil {
+		return fmt.Errorf("failed to create %s: %w", m.logDir, err)
+	}
+	for _, s := range services {
+		plist := launchdPlist(s, filepath.Join(m.logDir, s.Name+".log"))
+		if err := writeFile(m.plistPath(s), plist, constants.ModeFileDefault); err != nil {
+			return err
+		}
+		if err := runCommand(ctx, "launchctl", "enable", m.target(s)); err != nil {
+			return fmt.Errorf("failed to enable %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// Start reloads each service: launchd only reads a plist when it is
+// bootstrapped, and RunAtLoad starts the service right away.
+func (m *launchd) Start(ctx context.Context, services []Service) error {
+	for _, s := range services {
+		// Fails when the service isn't loaded yet, which is fine.
+		_ = runCommand(ctx, "launchctl", "bootout", m.target(s))
+		if err := runCommand(ctx, "launchctl", "bootstrap", m.domain, m.plistPath(s)); err != nil {
+			return fmt.Errorf("failed to start %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *launchd) State(s Service) State {
+	return fileState(m.plistPath(s), launchdPlist(s, filepath.Join(m.logDir, s.Name+".log")))
+}
+
+func (m *launchd) plistPath(s Service) string {
+	return filepath.Join(m.plistDir, helpers.LaunchdLabel(s.Name)+".plist")
+}
+
+func (m *launchd) target(s Service) string {
+	return m.domain + "/" + helpers.LaunchdLabel(s.Name)
+}
+
+// launchdPlist renders the property list for a service, logging to logFile.
+// launchd has no dependencies between jobs; KeepAlive restarts haloyd until
+// Docker is up.
+func launchdPlist(s Service, logFile string) string {
+	env := maps.Clone(s.Env)
+	if env == nil {
+		env = map[string]string{}
+	}
+	if _, ok := env["PATH"]; !ok {
+		env["PATH"] = launchdPath
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	plistString(&b, "\t", "Label", helpers.LaunchdLabel(s.Name))
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{s.Command}, s.Args...) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", xmlEscape(arg))
+	}
+	b.WriteString("\t</array>\n")
+	if s.User != "" {
+		plistString(&b, "\t", "UserName", s.User)
+		plistString(&b, "\t", "GroupName", s.User)
+	}
+	b.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+	for _, key := range slices.Sorted(maps.Keys(env)) {
+		plistString(&b, "\t\t", key, env[key])
+	}
+	b.WriteString("\t</dict>\n")
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	b.WriteString("\t<key>KeepAlive</key>\n\t<true/>\n")
+	b.WriteString("\t<key>ThrottleInterval</key>\n\t<integer>5</integer>\n")
+	plistString(&b, "\t", "StandardOutPath", logFile)
+	plistString(&b, "\t", "StandardErrorPath", logFile)
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+func plistString(b *strings.Builder, indent, key, value string) {
+	fmt.Fprintf(b, "%s<key>%s</key>\n%s<string>%s</string>\n", indent, xmlEscape(key), indent, xmlEscape(value))
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+type openRC struct {
+	initDir string
+	logDir  string
+}
+
+func newOpenRC() *openRC {
+	return &openRC{initDir: "/etc/init.d", logDir: "/var/log"}
+}
+
+func (m *openRC) Name() string { return "OpenRC" }
+
+func (m *openRC) Install(ctx context.Context, services []Service) error {
+	for _, s := range services {
+		script := openRCScript(s, filepath.Join(m.logDir, s.Name+".log"))
+		if err := writeFile(filepath.Join(m.initDir, s.Name), script, constants.ModeFileExec); err != nil {
+			return err
+		}
+		if err := runCommand(ctx, "rc-update", "add", s.Name, "default"); err != nil {
+			return fmt.Errorf("failed to enable %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *openRC) State(s Service) State {
+	return fileState(filepath.Join(m.initDir, s.Name), openRCScript(s, filepath.Join(m.logDir, s.Name+".log")))
+}
+
+func (m *openRC) Start(ctx context.Context, services []Service) error {
+	for _, s := range services {
+		if err := runCommand(ctx, "rc-service", s.Name, "restart"); err != nil {
+			return fmt.Errorf("failed to start %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// openRCScript renders the openrc-run script for a service, logging to
+// logFile.
+func openRCScript(s Service, logFile string) string {
+	runDir := "/run/" + s.Name
+
+	var b strings.Builder
+	b.WriteString("#!/sbin/openrc-run\n\n")
+	fmt.Fprintf(&b, "name=%s\n", strconv.Quote(s.Name))
+	fmt.Fprintf(&b, "description=%s\n", strconv.Quote(s.Description))
+	fmt.Fprintf(&b, "command=%s\n", strconv.Quote(s.Command))
+	fmt.Fprintf(&b, "command_args=%s\n", strconv.Quote(strings.Join(s.Args, " ")))
+	b.WriteString("command_background=\"yes\"\n")
+	owner := "root:root"
+	if s.User != "" {
+		owner = s.User + ":" + s.User
+		fmt.Fprintf(&b, "command_user=%s\n", strconv.Quote(owner))
+		if s.BindsPrivilegedPorts {
+			b.WriteString("capabilities=\"^cap_net_bind_service\"\n")
+		}
+	}
+	fmt.Fprintf(&b, "pidfile=%s\n", strconv.Quote(runDir+"/"+s.Name+".pid"))
+	fmt.Fprintf(&b, "output_log=%s\n", strconv.Quote(logFile))
+	fmt.Fprintf(&b, "error_log=%s\n", strconv.Quote(logFile))
+
+	b.WriteString("\n")
+	for _, key := range slices.Sorted(maps.Keys(s.Env)) {
+		fmt.Fprintf(&b, "export %s=%s\n", key, strconv.Quote(s.Env[key]))
+	}
+
+	need := []string{"net"}
+	if s.NeedsDocker {
+		need = append(need, "docker")
+	}
+	b.WriteString("\ndepend() {\n")
+	fmt.Fprintf(&b, "    need %s\n", strings.Join(need, " "))
+	if len(s.After) > 0 {
+		fmt.Fprintf(&b, "    use %s\n", strings.Join(s.After, " "))
+	}
+	fmt.Fprintf(&b, "    after %s\n", strings.Join(append([]string{"firewall"}, s.After...), " "))
+	b.WriteString("}\n")
+
+	b.WriteString("\nstart_pre() {\n")
+	fmt.Fprintf(&b, "    checkpath --directory --owner %s --mode 0755 %s\n", owner, runDir)
+	fmt.Fprintf(&b, "    checkpath --file --owner %s --mode 0644 %s\n", owner, logFile)
+	b.WriteString("}\n")
+	return b.String()
+}
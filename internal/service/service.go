@@ -0,0 +1,199 @@
+// Package service installs haloy-proxy and haloyd as services of the host's
+// service manager: systemd or OpenRC on Linux, launchd on macOS.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/cmdexec"
+	"github.com/haloydev/haloy/internal/constants"
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+// ErrUnsupported is returned by Detect when the host has no service manager
+// haloy can install services for.
+var ErrUnsupported = errors.New("no supported service manager found")
+
+// Service is a daemon run by the service manager.
+type Service struct {
+	Name        string
+	Description string
+	Command     string
+	Args        []string
+	// User the daemon runs as, and whose group it runs with. Empty runs it
+	// as the user owning the service manager: root, or the logged in user
+	// for launchd agents.
+	User string
+	Env  map[string]string
+	// NeedsDocker makes the service start after, and stop with, Docker.
+	NeedsDocker bool
+	// After lists services that are started first when present, without
+	// depending on them.
+	After []string
+	// BindsPrivilegedPorts grants the capability to listen on ports below
+	// 1024 when the service doesn't run as root.
+	BindsPrivilegedPorts bool
+	ReadWritePaths       []string
+	ReadOnlyPaths        []string
+	// Watchdog is how long the daemon may go without a watchdog ping before
+	// the service manager restarts it. Zero disables the watchdog. Services
+	// with one notify readiness too, and only systemd supports either.
+	Watchdog time.Duration
+	// StopTimeout is how long the service manager waits for the daemon to
+	// exit before killing it. Zero keeps the manager's default.
+	StopTimeout time.Duration
+}
+
+// Manager installs services into a service manager.
+type Manager interface {
+	// Name is the service manager's name, as shown to users.
+	Name() string
+	// Install writes the service definitions and enables them to start at
+	// boot, or at login for user-level managers.
+	Install(ctx context.Context, services []Service) error
+	// Start (re)starts the services in order, so updated definitions are
+	// picked up.
+	Start(ctx context.Context, services []Service) error
+	// State compares the installed definition of a service with the one
+	// Install writes.
+	State(s Service) State
+}
+
+// State is how the installed definition of a service compares to the one
+// Install writes.
+type State int
+
+const (
+	StateMissing  State = iota // not installed
+	StateOutdated              // installed, but differs, e.g. after moving the binaries
+	StateCurrent
+)
+
+// fileState returns the state of the definition at path, which Install
+// writes as want.
+func fileState(path, want string) State {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return StateMissing
+	}
+	if string(data) != want {
+		return StateOutdated
+	}
+	return StateCurrent
+}
+
+// Options describe a haloy installation to create services for.
+type Options struct {
+	// BinDir holds the haloyd and haloy-proxy binaries.
+	BinDir    string
+	DataDir   string
+	ConfigDir string
+	User      string
+}
+
+// HaloyServices returns the haloy-proxy and haloyd services for an
+// installation, in the order they must start: the proxy owns ports 80/443
+// before haloyd begins pushing routes to it.
+func HaloyServices(opts Options) []Service {
+	env := map[string]string{
+		constants.EnvVarDataDir:   opts.DataDir,
+		constants.EnvVarConfigDir: opts.ConfigDir,
+	}
+	return []Service{
+		{
+			Name:                 "haloy-proxy",
+			Description:          "Haloy Proxy",
+			Command:              filepath.Join(opts.BinDir, "haloy-proxy"),
+			Args:                 []string{"serve"},
+			User:                 opts.User,
+			Env:                  env,
+			BindsPrivilegedPorts: true,
+			ReadWritePaths:       []string{opts.DataDir},
+			ReadOnlyPaths:        []string{opts.ConfigDir},
+			Watchdog:             time.Minute,
+		},
+		{
+			Name:           "haloyd",
+			Description:    "Haloy Daemon",
+			Command:        filepath.Join(opts.BinDir, "haloyd"),
+			Args:           []string{"serve"},
+			User:           opts.User,
+			Env:            env,
+			NeedsDocker:    true,
+			After:          []string{"haloy-proxy"},
+			ReadWritePaths: []string{opts.DataDir},
+			ReadOnlyPaths:  []string{opts.ConfigDir},
+			// The main loop runs maintenance, image pruning included, between
+			// pings.
+			Watchdog: 10 * time.Minute,
+			// Above the longest shutdown.grace_period haloyd accepts.
+			StopTimeout: 6 * time.Minute,
+		},
+	}
+}
+
+// Detect returns the service manager of the current host. With user set,
+// services are installed for the current user instead of system-wide, which
+// only launchd supports.
+func Detect(user bool) (Manager, error) {
+	initSystem := helpers.DetectInitSystem()
+	if user && initSystem != helpers.InitLaunchd {
+		return nil, fmt.Errorf("%w: user services are only supported with launchd on macOS", ErrUnsupported)
+	}
+
+	switch initSystem {
+	case helpers.InitSystemd:
+		return newSystemd(), nil
+	case helpers.InitOpenRC:
+		return newOpenRC(), nil
+	case helpers.InitLaunchd:
+		if !user {
+			return newLaunchd(), nil
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine home directory: %w", err)
+		}
+		return newLaunchdAgent(home, os.Getuid()), nil
+	default:
+		return nil, fmt.Errorf("%w (systemd, OpenRC or launchd on %s)", ErrUnsupported, runtime.GOOS)
+	}
+}
+
+// runCommand runs a service manager command. Replaced in tests.
+var runCommand = func(ctx context.Context, name string, args ...string) error {
+	_, err := cmdexec.RunCLICommand(ctx, name, args...)
+	return err
+}
+
+func writeFile(path, content string, mode os.FileMode) error {
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	// WriteFile keeps the mode of an existing file.
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	}
+	return nil
+}
+
+// commandLine joins a command and its arguments, quoting the ones that need
+// it for both systemd's ExecStart and sh.
+func commandLine(command string, args []string) string {
+	parts := make([]string, 0, len(args)+1)
+	for _, part := range append([]string{command}, args...) {
+		if part == "" || strings.ContainsAny(part, " \t\"'\\$`") {
+			part = strconv.Quote(part)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testServices() []Service {
+	return HaloyServices(Options{
+		BinDir:    "/usr/local/bin",
+		DataDir:   "/var/lib/haloy",
+		ConfigDir: "/etc/haloy",
+		User:      "haloy",
+	})
+}
+
+func TestSystemdUnit(t *testing.T) {
+	services := testServices()
+	proxy := systemdUnit(services[0])
+	haloyd := systemdUnit(services[1])
+
+	for _, want := range []string{
+		"Description=Haloy Proxy\n",
+		"User=haloy\nGroup=haloy\n",
+		"ExecStart=/usr/local/bin/haloy-proxy serve\n",
+		"Environment=\"HALOY_DATA_DIR=/var/lib/haloy\"\n",
+		"ReadWritePaths=/var/lib/haloy\n",
+		"AmbientCapabilities=CAP_NET_BIND_SERVICE\n",
+		"Type=notify\nNotifyAccess=main\n",
+		"WatchdogSec=60\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(proxy, want) {
+			t.Errorf("haloy-proxy unit missing %q:\n%s", want, proxy)
+		}
+	}
+	if strings.Contains(proxy, "docker.service") {
+		t.Errorf("haloy-proxy unit must not depend on docker:\n%s", proxy)
+	}
+
+	for _, want := range []string{
+		"After=network-online.target docker.service haloy-proxy.service\n",
+		"Requires=docker.service\n",
+		"Wants=network-online.target haloy-proxy.service\n",
+		"ExecStart=/usr/local/bin/haloyd serve\n",
+		"Environment=\"HALOY_CONFIG_DIR=/etc/haloy\"\n",
+		"ReadOnlyPaths=/etc/haloy\n",
+		"Type=notify\n",
+		"WatchdogSec=600\n",
+		"TimeoutStopSec=360\n",
+	} {
+		if !strings.Contains(haloyd, want) {
+			t.Errorf("haloyd unit missing %q:\n%s", want, haloyd)
+		}
+	}
+	if strings.Contains(haloyd, "CAP_NET_BIND_SERVICE") {
+		t.Errorf("haloyd unit must not get the bind capability:\n%s", haloyd)
+	}
+}
+
+func TestOpenRCScript(t *testing.T) {
+	services := testServices()
+	proxy := openRCScript(services[0], "/var/log/haloy-proxy.log")
+	haloyd := openRCScript(services[1], "/var/log/haloyd.log")
+
+	if !strings.HasPrefix(proxy, "#!/sbin/openrc-run\n") {
+		t.Errorf("script must start with the openrc-run shebang:\n%s", proxy)
+	}
+	for _, want := range []string{
+		`command="/usr/local/bin/haloy-proxy"`,
+		`command_args="serve"`,
+		`command_user="haloy:haloy"`,
+		`capabilities="^cap_net_bind_service"`,
+		`output_log="/var/log/haloy-proxy.log"`,
+		`export HALOY_DATA_DIR="/var/lib/haloy"`,
+		"    need net\n",
+		"checkpath --directory --owner haloy:haloy --mode 0755 /run/haloy-proxy",
+	} {
+		if !strings.Contains(proxy, want) {
+			t.Errorf("haloy-proxy script missing %q:\n%s", want, proxy)
+		}
+	}
+	for _, want := range []string{
+		"    need net docker\n",
+		"    use haloy-proxy\n",
+		"    after firewall haloy-proxy\n",
+		`pidfile="/run/haloyd/haloyd.pid"`,
+	} {
+		if !strings.Contains(haloyd, want) {
+			t.Errorf("haloyd script missing %q:\n%s", want, haloyd)
+		}
+	}
+
+	root := services[0]
+	root.User = ""
+	script := openRCScript(root, "/var/log/haloy-proxy.log")
+	if strings.Contains(script, "command_user") || strings.Contains(script, "capabilities") {
+		t.Errorf("root service must not set a user or capabilities:\n%s", script)
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	s := Service{
+		Name:    "haloyd",
+		Command: "/Users/me/bin/haloyd",
+		Args:    []string{"serve"},
+		Env:     map[string]string{"HALOY_CONFIG_DIR": "/Users/me/.haloy-dev/config & more"},
+	}
+	plist := launchdPlist(s, "/Users/me/Library/Logs/Haloy/haloyd.log")
+
+	for _, want := range []string{
+		"<key>Label</key>\n\t<string>dev.haloy.haloyd</string>",
+		"<array>\n\t\t<string>/Users/me/bin/haloyd</string>\n\t\t<string>serve</string>\n\t</array>",
+		"<key>HALOY_CONFIG_DIR</key>\n\t\t<string>/Users/me/.haloy-dev/config &amp; more</string>",
+		"<key>PATH</key>\n\t\t<string>" + launchdPath + "</string>",
+		"<key>RunAtLoad</key>\n\t<true/>",
+		"<key>KeepAlive</key>\n\t<true/>",
+		"<key>StandardErrorPath</key>\n\t<string>/Users/me/Library/Logs/Haloy/haloyd.log</string>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("plist missing %q:\n%s", want, plist)
+		}
+	}
+	if strings.Contains(plist, "UserName") {
+		t.Errorf("plist without a user must not set UserName:\n%s", plist)
+	}
+
+	s.User = "haloy"
+	if plist := launchdPlist(s, "/tmp/haloyd.log"); !strings.Contains(plist, "<key>UserName</key>\n\t<string>haloy</string>") {
+		t.Errorf("plist missing UserName:\n%s", plist)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		want    string
+	}{
+		{"/usr/local/bin/haloyd", []string{"serve"}, "/usr/local/bin/haloyd serve"},
+		{"/opt/my apps/haloyd", []string{"serve"}, `"/opt/my apps/haloyd" serve`},
+		{"/usr/local/bin/haloyd", []string{""}, `/usr/local/bin/haloyd ""`},
+	}
+	for _, tt := range tests {
+		if got := commandLine(tt.command, tt.args); got != tt.want {
+			t.Errorf("commandLine(%q, %q) = %q, want %q", tt.command, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestLaunchdInstallAndStart(t *testing.T) {
+	var commands []string
+	orig := runCommand
+	runCommand = func(_ context.Context, name string, args ...string) error {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		return nil
+	}
+	t.Cleanup(func() { runCommand = orig })
+
+	home := t.TempDir()
+	m := newLaunchdAgent(home, 501)
+	services := HaloyServices(Options{BinDir: "/usr/local/bin", DataDir: "/data", ConfigDir: "/config"})
+	if state := m.State(services[0]); state != StateMissing {
+		t.Errorf("State() before Install = %d, want StateMissing", state)
+	}
+	if err := m.Install(context.Background(), services); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if state := m.State(services[0]); state != StateCurrent {
+		t.Errorf("State() after Install = %d, want StateCurrent", state)
+	}
+	moved := HaloyServices(Options{BinDir: "/opt/haloy/bin", DataDir: "/data", ConfigDir: "/config"})
+	if state := m.State(moved[0]); state != StateOutdated {
+		t.Errorf("State() with moved binaries = %d, want StateOutdated", state)
+	}
+	if err := m.Start(context.Background(), services); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	plistDir := filepath.Join(home, "Library", "LaunchAgents")
+	for _, name := range []string{"dev.haloy.haloy-proxy.plist", "dev.haloy.haloyd.plist"} {
+		if _, err := os.Stat(filepath.Join(plistDir, name)); err != nil {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+
+	want := []string{
+		"launchctl enable gui/501/dev.haloy.haloy-proxy",
+		"launchctl enable gui/501/dev.haloy.haloyd",
+		"launchctl bootout gui/501/dev.haloy.haloy-proxy",
+		"launchctl bootstrap gui/501 " + filepath.Join(plistDir, "dev.haloy.haloy-proxy.plist"),
+		"launchctl bootout gui/501/dev.haloy.haloyd",
+		"launchctl bootstrap gui/501 " + filepath.Join(plistDir, "dev.haloy.haloyd.plist"),
+	}
+	if !slices.Equal(commands, want) {
+		t.Errorf("commands = %q, want %q", commands, want)
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/haloydev/haloy/internal/constants"
+)
+
+type systemd struct {
+	unitDir string
+}
+
+func newSystemd() *systemd {
+	return &systemd{unitDir: "/etc/systemd/system"}
+}
+
+func (m *systemd) Name() string { return "systemd" }
+
+func (m *systemd) Install(ctx context.Context, services []Service) error {
+	for _, s := range services {
+		path := filepath.Join(m.unitDir, s.Name+".service")
+		if err := writeFile(path, systemdUnit(s), constants.ModeFileDefault); err != nil {
+			return err
+		}
+	}
+	if err := runCommand(ctx, "systemctl", "daemon-reload"); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	for _, s := range services {
+		if err := runCommand(ctx, "systemctl", "enable", s.Name); err != nil {
+			return fmt.Errorf("failed to enable %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *systemd) State(s Service) State {
+	return fileState(filepath.Join(m.unitDir, s.Name+".service"), systemdUnit(s))
+}
+
+func (m *systemd) Start(ctx context.Context, services []Service) error {
+	for _, s := range services {
+		if err := runCommand(ctx, "systemctl", "restart", s.Name); err != nil {
+			return fmt.Errorf("failed to start %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// systemdUnit renders the unit file for a service. Wants/After rather than
+// Requires between haloy services: restarting one must never restart the
+// other.
+func systemdUnit(s Service) string {
+	after := []string{"network-online.target"}
+	wants := []string{"network-online.target"}
+	if s.NeedsDocker {
+		after = append(after, "docker.service")
+	}
+	for _, name := range s.After {
+		after = append(after, name+".service")
+		wants = append(wants, name+".service")
+	}
+
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", s.Description)
+	fmt.Fprintf(&b, "After=%s\n", strings.Join(after, " "))
+	if s.NeedsDocker {
+		b.WriteString("Requires=docker.service\n")
+	}
+	fmt.Fprintf(&b, "Wants=%s\n", strings.Join(wants, " "))
+
+	b.WriteString("\n[Service]\n")
+	if s.Watchdog > 0 {
+		// Startup may take long, like haloyd's initial update, and a daemon
+		// that fails to start exits rather than hangs, so it isn't timed.
+		b.WriteString("Type=notify\nNotifyAccess=main\nTimeoutStartSec=infinity\n")
+		fmt.Fprintf(&b, "WatchdogSec=%d\n", int(s.Watchdog.Seconds()))
+	} else {
+		b.WriteString("Type=simple\n")
+	}
+	if s.User != "" {
+		fmt.Fprintf(&b, "User=%s\nGroup=%s\n", s.User, s.User)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", commandLine(s.Command, s.Args))
+	b.WriteString("Restart=always\nRestartSec=5\n")
+	if s.StopTimeout > 0 {
+		fmt.Fprintf(&b, "TimeoutStopSec=%d\n", int(s.StopTimeout.Seconds()))
+	}
+	for _, key := range slices.Sorted(maps.Keys(s.Env)) {
+		fmt.Fprintf(&b, "Environment=%s\n", strconv.Quote(key+"="+s.Env[key]))
+	}
+
+	b.WriteString("\n# Security hardening\n")
+	b.WriteString("NoNewPrivileges=true\nPrivateTmp=true\nProtectHome=true\nProtectSystem=strict\n")
+	if len(s.ReadWritePaths) > 0 {
+		fmt.Fprintf(&b, "ReadWritePaths=%s\n", strings.Join(s.ReadWritePaths, " "))
+	}
+	if len(s.ReadOnlyPaths) > 0 {
+		fmt.Fprintf(&b, "ReadOnlyPaths=%s\n", strings.Join(s.ReadOnlyPaths, " "))
+	}
+	if s.BindsPrivilegedPorts {
+		b.WriteString("CapabilityBoundingSet=CAP_NET_BIND_SERVICE\nAmbientCapabilities=CAP_NET_BIND_SERVICE\n")
+	}
+	b.WriteString("ProtectKernelTunables=true\nProtectKernelModules=true\nProtectControlGroups=true\nRestrictSUIDSGID=true\nLimitNOFILE=65536\n")
+
+	b.WriteString("\n[Install]\nWantedBy=multi-user.target\n")
+	return b.String()
+}
@@ -9,5 +9,65 @@ func (db *DB) Migrate() error {
 		return err
 	}
 
+	if err := createImageCacheTable(db); err != nil {
+		return err
+	}
+
+	if err := createCertificatesTable(db); err != nil {
+		return err
+	}
+
+	if err := createCertificateIssuanceTable(db); err != nil {
+		return err
+	}
+
+	if err := createContainerRestartsTable(db); err != nil {
+		return err
+	}
+
+	if err := createPausedAppsTable(db); err != nil {
+		return err
+	}
+
+	if err := createDatabaseCredentialsTable(db); err != nil {
+		return err
+	}
+
+	if err := createGitOpsTables(db); err != nil {
+		return err
+	}
+
+	if err := createAppSpecsTable(db); err != nil {
+		return err
+	}
+
+	if err := createSecretsTable(db); err != nil {
+		return err
+	}
+
+	if err := createDeploymentEventsTable(db); err != nil {
+		return err
+	}
+
+	if err := createServerUpgradesTable(db); err != nil {
+		return err
+	}
+
+	if err := createAppUsageTable(db); err != nil {
+		return err
+	}
+
+	if err := createAppUptimeTable(db); err != nil {
+		return err
+	}
+
+	if err := createDeploymentSpecsTable(db); err != nil {
+		return err
+	}
+
+	if err := createDeploymentLogsTable(db); err != nil {
+		return err
+	}
+
 	return nil
 }
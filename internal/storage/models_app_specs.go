@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AppSpec is the desired state of an app managed through the apps API, as
+// last applied with PUT /v1/apps/{name}.
+type AppSpec struct {
+	AppName string          `db:"app_name" json:"appName"`
+	Spec    json.RawMessage `db:"spec" json:"spec"` // Normalized config.TargetConfig
+	// SpecHash identifies the spec and error pages, so applying the same
+	// spec again is a no-op.
+	SpecHash     string    `db:"spec_hash" json:"specHash"`
+	DeploymentID string    `db:"deployment_id" json:"deploymentId"`
+	Error        string    `db:"error" json:"error"` // Why the deployment failed, if it did
+	UpdatedAt    time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+func createAppSpecsTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS app_specs (
+    app_name TEXT PRIMARY KEY,
+    spec JSON NOT NULL,
+    spec_hash TEXT NOT NULL,
+    deployment_id TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL
+);
+`
+	return db.createTable("app_specs", schema)
+}
+
+// SaveAppSpec records the spec an app is being deployed with.
+func (db *DB) SaveAppSpec(spec AppSpec) error {
+	query := `INSERT OR REPLACE INTO app_specs (app_name, spec, spec_hash, deployment_id, error, updated_at)
+              VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := db.Exec(query, spec.AppName, spec.Spec, spec.SpecHash, spec.DeploymentID, spec.Error, spec.UpdatedAt)
+	return err
+}
+
+// SetAppSpecError records why the deployment of an app's spec failed. It's
+// ignored when a later spec has been applied meanwhile.
+func (db *DB) SetAppSpecError(appName, deploymentID, message string) error {
+	_, err := db.Exec(`UPDATE app_specs SET error = ? WHERE app_name = ? AND deployment_id = ?`, message, appName, deploymentID)
+	return err
+}
+
+// GetAppSpec returns the spec of an app, or nil if it isn't managed through
+// the apps API.
+func (db *DB) GetAppSpec(appName string) (*AppSpec, error) {
+	var spec AppSpec
+	err := db.QueryRow(`SELECT app_name, spec, spec_hash, deployment_id, error, updated_at FROM app_specs WHERE app_name = ?`, appName).
+		Scan(&spec.AppName, &spec.Spec, &spec.SpecHash, &spec.DeploymentID, &spec.Error, &spec.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app spec: %w", err)
+	}
+	return &spec, nil
+}
+
+// DeleteAppSpec forgets the spec of a destroyed app.
+func (db *DB) DeleteAppSpec(appName string) error {
+	_, err := db.Exec(`DELETE FROM app_specs WHERE app_name = ?`, appName)
+	return err
+}
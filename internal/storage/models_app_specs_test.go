@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAppSpecs(t *testing.T) {
+	db := newInMemoryDB(t)
+
+	if spec, err := db.GetAppSpec("shop"); err != nil || spec != nil {
+		t.Fatalf("GetAppSpec() = %+v, %v, want nil", spec, err)
+	}
+
+	spec := AppSpec{AppName: "shop", Spec: json.RawMessage(`{"name":"shop"}`), SpecHash: "h1", DeploymentID: "d1", UpdatedAt: time.Now()}
+	if err := db.SaveAppSpec(spec); err != nil {
+		t.Fatalf("SaveAppSpec() error = %v", err)
+	}
+	spec.SpecHash, spec.DeploymentID = "h2", "d2"
+	if err := db.SaveAppSpec(spec); err != nil {
+		t.Fatalf("SaveAppSpec() second call error = %v", err)
+	}
+
+	// The failure of a deployment that was replaced must not stick to the
+	// newer spec.
+	if err := db.SetAppSpecError("shop", "d1", "image not found"); err != nil {
+		t.Fatalf("SetAppSpecError() error = %v", err)
+	}
+	got, err := db.GetAppSpec("shop")
+	if err != nil || got == nil {
+		t.Fatalf("GetAppSpec() = %+v, %v", got, err)
+	}
+	if got.SpecHash != "h2" || got.Error != "" {
+		t.Errorf("GetAppSpec() = %+v, want h2 without error", got)
+	}
+
+	if err := db.SetAppSpecError("shop", "d2", "image not found"); err != nil {
+		t.Fatalf("SetAppSpecError() error = %v", err)
+	}
+	if got, _ := db.GetAppSpec("shop"); got.Error != "image not found" {
+		t.Errorf("Error = %q, want image not found", got.Error)
+	}
+
+	if err := db.DeleteAppSpec("shop"); err != nil {
+		t.Fatalf("DeleteAppSpec() error = %v", err)
+	}
+	if got, _ := db.GetAppSpec("shop"); got != nil {
+		t.Errorf("GetAppSpec() after delete = %+v, want nil", got)
+	}
+}
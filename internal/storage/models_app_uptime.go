@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// AppUptime is the availability of an app over one UTC day: the minutes it
+// was monitored and had no healthy container, and the requests the proxy
+// sent it and how many of those failed.
+type AppUptime struct {
+	AppName     string    `db:"app_name" json:"appName"`
+	Day         time.Time `db:"day" json:"day"`
+	Minutes     int64     `db:"minutes" json:"minutes"`
+	DownMinutes int64     `db:"down_minutes" json:"downMinutes"`
+	Requests    int64     `db:"requests" json:"requests"`
+	Errors      int64     `db:"errors" json:"errors"`
+}
+
+func createAppUptimeTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS app_uptime (
+    app_name TEXT NOT NULL,
+    day DATETIME NOT NULL,
+    minutes INTEGER NOT NULL DEFAULT 0,
+    down_minutes INTEGER NOT NULL DEFAULT 0,
+    requests INTEGER NOT NULL DEFAULT 0,
+    errors INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (app_name, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_uptime_day ON app_uptime(day);
+`
+	return db.createTable("app_uptime", schema)
+}
+
+// AddAppUptime adds the counts of u to the day u.Day falls on.
+func (db *DB) AddAppUptime(u AppUptime) error {
+	day := u.Day.UTC().Truncate(24 * time.Hour)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO app_uptime (app_name, day) VALUES (?, ?)`, u.AppName, day); err != nil {
+		return fmt.Errorf("failed to record uptime of %s: %w", u.AppName, err)
+	}
+	if _, err := tx.Exec(`UPDATE app_uptime
+              SET minutes = minutes + ?, down_minutes = down_minutes + ?, requests = requests + ?, errors = errors + ?
+              WHERE app_name = ? AND day = ?`,
+		u.Minutes, u.DownMinutes, u.Requests, u.Errors, u.AppName, day); err != nil {
+		return fmt.Errorf("failed to record uptime of %s: %w", u.AppName, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record uptime of %s: %w", u.AppName, err)
+	}
+	return nil
+}
+
+// ListAppUptime returns the uptime of an app for the days since the given
+// time, oldest first.
+func (db *DB) ListAppUptime(appName string, since time.Time) ([]AppUptime, error) {
+	rows, err := db.Query(`SELECT app_name, day, minutes, down_minutes, requests, errors
+              FROM app_uptime WHERE app_name = ? AND day >= ? ORDER BY day`,
+		appName, since.UTC().Truncate(24*time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query app uptime: %w", err)
+	}
+	defer rows.Close()
+
+	var uptime []AppUptime
+	for rows.Next() {
+		var u AppUptime
+		if err := rows.Scan(&u.AppName, &u.Day, &u.Minutes, &u.DownMinutes, &u.Requests, &u.Errors); err != nil {
+			return nil, fmt.Errorf("failed to scan app uptime: %w", err)
+		}
+		uptime = append(uptime, u)
+	}
+	return uptime, rows.Err()
+}
+
+// PruneAppUptime removes the uptime of days before the given time.
+func (db *DB) PruneAppUptime(before time.Time) error {
+	if _, err := db.Exec(`DELETE FROM app_uptime WHERE day < ?`, before.UTC().Truncate(24*time.Hour)); err != nil {
+		return fmt.Errorf("failed to prune app uptime: %w", err)
+	}
+	return nil
+}
+
+// DeleteAppUptime removes the uptime recorded for an app.
+func (db *DB) DeleteAppUptime(appName string) error {
+	if _, err := db.Exec(`DELETE FROM app_uptime WHERE app_name = ?`, appName); err != nil {
+		return fmt.Errorf("failed to delete app uptime: %w", err)
+	}
+	return nil
+}
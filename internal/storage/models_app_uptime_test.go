@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAppUptime(t *testing.T) {
+	db := newInMemoryDB(t)
+
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	for _, u := range []AppUptime{
+		{AppName: "shop", Day: day.Add(time.Hour), Minutes: 1, Requests: 100, Errors: 1},
+		{AppName: "shop", Day: day.Add(2 * time.Hour), Minutes: 1, DownMinutes: 1, Requests: 10, Errors: 10},
+		{AppName: "shop", Day: day.Add(25 * time.Hour), Minutes: 1},
+		{AppName: "blog", Day: day, Minutes: 1},
+	} {
+		if err := db.AddAppUptime(u); err != nil {
+			t.Fatalf("AddAppUptime() error = %v", err)
+		}
+	}
+
+	uptime, err := db.ListAppUptime("shop", day.Add(12*time.Hour))
+	if err != nil {
+		t.Fatalf("ListAppUptime() error = %v", err)
+	}
+	if len(uptime) != 2 {
+		t.Fatalf("ListAppUptime() = %+v, want two days of shop", uptime)
+	}
+	want := AppUptime{AppName: "shop", Day: day, Minutes: 2, DownMinutes: 1, Requests: 110, Errors: 11}
+	got := uptime[0]
+	got.Day = got.Day.UTC()
+	if got != want {
+		t.Errorf("first day = %+v, want %+v", got, want)
+	}
+
+	if err := db.PruneAppUptime(day.Add(25 * time.Hour)); err != nil {
+		t.Fatalf("PruneAppUptime() error = %v", err)
+	}
+	if uptime, _ := db.ListAppUptime("shop", time.Time{}); len(uptime) != 1 {
+		t.Errorf("ListAppUptime() after prune = %+v, want the second day", uptime)
+	}
+	if err := db.DeleteAppUptime("shop"); err != nil {
+		t.Fatalf("DeleteAppUptime() error = %v", err)
+	}
+	if uptime, _ := db.ListAppUptime("shop", time.Time{}); len(uptime) != 0 {
+		t.Errorf("ListAppUptime() after delete = %+v, want none", uptime)
+	}
+}
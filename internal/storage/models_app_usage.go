@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// AppUsage is the CPU and memory usage of an app's running containers over
+// the period starting at Time: a single sample, or the samples downsampled
+// into it.
+type AppUsage struct {
+	AppName string    `db:"app_name" json:"appName"`
+	Time    time.Time `db:"time" json:"time"`
+	// Period is the time the usage covers, the sampling interval for a
+	// single sample.
+	Period  time.Duration `db:"period_seconds" json:"period"`
+	Samples int           `db:"samples" json:"samples"`
+	// CPUPercent is the average CPU usage of all containers together, as a
+	// percentage of one CPU, and CPUMax the highest sample.
+	CPUPercent float64 `db:"cpu_percent" json:"cpuPercent"`
+	CPUMax     float64 `db:"cpu_max" json:"cpuMax"`
+	// MemoryBytes is the average memory usage of all containers together,
+	// and MemoryMax the highest sample.
+	MemoryBytes int64 `db:"memory_bytes" json:"memoryBytes"`
+	MemoryMax   int64 `db:"memory_max" json:"memoryMax"`
+	MemoryLimit int64 `db:"memory_limit" json:"memoryLimit"` // Sum of the containers' limits
+	Containers  int   `db:"containers" json:"containers"`
+}
+
+// appUsageUpsert saves a usage row, replacing the one of the same app and time.
+const appUsageUpsert = `INSERT OR REPLACE INTO app_usage (app_name, time, period_seconds, samples, cpu_percent, cpu_max,
+    memory_bytes, memory_max, memory_limit, containers)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+func createAppUsageTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS app_usage (
+    app_name TEXT NOT NULL,
+    time DATETIME NOT NULL,
+    period_seconds INTEGER NOT NULL,
+    samples INTEGER NOT NULL,
+    cpu_percent REAL NOT NULL,
+    cpu_max REAL NOT NULL,
+    memory_bytes INTEGER NOT NULL,
+    memory_max INTEGER NOT NULL,
+    memory_limit INTEGER NOT NULL,
+    containers INTEGER NOT NULL,
+    PRIMARY KEY (app_name, time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_usage_time ON app_usage(time);
+`
+	return db.createTable("app_usage", schema)
+}
+
+// RecordAppUsage saves usage, replacing what was saved for the same app and
+// time.
+func (db *DB) RecordAppUsage(u AppUsage) error {
+	if _, err := db.Exec(appUsageUpsert, appUsageArgs(u)...); err != nil {
+		return fmt.Errorf("failed to record usage of %s: %w", u.AppName, err)
+	}
+	return nil
+}
+
+func appUsageArgs(u AppUsage) []any {
+	return []any{
+		u.AppName, u.Time.UTC(), int64(u.Period / time.Second), u.Samples, u.CPUPercent, u.CPUMax,
+		u.MemoryBytes, u.MemoryMax, u.MemoryLimit, u.Containers,
+	}
+}
+
+// ListAppUsage returns the usage of an app since the given time, oldest
+// first.
+func (db *DB) ListAppUsage(appName string, since time.Time) ([]AppUsage, error) {
+	return db.queryAppUsage(`WHERE app_name = ? AND time >= ? ORDER BY time`, appName, since.UTC())
+}
+
+func (db *DB) queryAppUsage(where string, args ...any) ([]AppUsage, error) {
+	rows, err := db.Query(`SELECT app_name, time, period_seconds, samples, cpu_percent, cpu_max, memory_bytes, memory_max, memory_limit, containers
+              FROM app_usage `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query app usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []AppUsage
+	for rows.Next() {
+		var u AppUsage
+		var periodSeconds int64
+		if err := rows.Scan(&u.AppName, &u.Time, &periodSeconds, &u.Samples, &u.CPUPercent, &u.CPUMax,
+			&u.MemoryBytes, &u.MemoryMax, &u.MemoryLimit, &u.Containers); err != nil {
+			return nil, fmt.Errorf("failed to scan app usage: %w", err)
+		}
+		u.Period = time.Duration(periodSeconds) * time.Second
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// DownsampleAppUsage merges the usage recorded before the given time at a
+// finer resolution than period into one row per app and period, and returns
+// how many rows it merged.
+func (db *DB) DownsampleAppUsage(before time.Time, period time.Duration) (int, error) {
+	before = before.UTC().Truncate(period)
+	usage, err := db.queryAppUsage(`WHERE time < ? AND period_seconds < ? ORDER BY time`, before, int64(period/time.Second))
+	if err != nil || len(usage) == 0 {
+		return 0, err
+	}
+
+	type bucketKey struct {
+		app  string
+		time time.Time
+	}
+	var keys []bucketKey
+	buckets := make(map[bucketKey]*AppUsage)
+	for _, u := range usage {
+		key := bucketKey{u.AppName, u.Time.UTC().Truncate(period)}
+		b, ok := buckets[key]
+		if !ok {
+			keys = append(keys, key)
+			buckets[key] = &AppUsage{AppName: u.AppName, Time: key.time, Period: period}
+			b = buckets[key]
+		}
+		mergeAppUsage(b, u)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM app_usage WHERE time < ? AND period_seconds < ?`, before, int64(period/time.Second)); err != nil {
+		return 0, fmt.Errorf("failed to downsample app usage: %w", err)
+	}
+	for _, key := range keys {
+		if _, err := tx.Exec(appUsageUpsert, appUsageArgs(*buckets[key])...); err != nil {
+			return 0, fmt.Errorf("failed to downsample app usage: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to downsample app usage: %w", err)
+	}
+	return len(usage), nil
+}
+
+// mergeAppUsage adds u to the downsampled usage b, weighing averages by the
+// samples each covers.
+func mergeAppUsage(b *AppUsage, u AppUsage) {
+	samples := max(u.Samples, 1)
+	total := b.Samples + samples
+	b.CPUPercent = (b.CPUPercent*float64(b.Samples) + u.CPUPercent*float64(samples)) / float64(total)
+	b.MemoryBytes = (b.MemoryBytes*int64(b.Samples) + u.MemoryBytes*int64(samples)) / int64(total)
+	b.Samples = total
+	b.CPUMax = max(b.CPUMax, u.CPUMax, u.CPUPercent)
+	b.MemoryMax = max(b.MemoryMax, u.MemoryMax, u.MemoryBytes)
+	b.MemoryLimit = max(b.MemoryLimit, u.MemoryLimit)
+	b.Containers = max(b.Containers, u.Containers)
+}
+
+// PruneAppUsage removes usage recorded before the given time.
+func (db *DB) PruneAppUsage(before time.Time) (int64, error) {
+	result, err := db.Exec(`DELETE FROM app_usage WHERE time < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune app usage: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteAppUsage removes the usage recorded for an app.
+func (db *DB) DeleteAppUsage(appName string) error {
+	if _, err := db.Exec(`DELETE FROM app_usage WHERE app_name = ?`, appName); err != nil {
+		return fmt.Errorf("failed to delete app usage: %w", err)
+	}
+	return nil
+}
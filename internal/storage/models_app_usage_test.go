@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAppUsage_DownsampleAndPrune(t *testing.T) {
+	db := newInMemoryDB(t)
+
+	hour := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	samples := []AppUsage{
+		{AppName: "shop", Time: hour, CPUPercent: 10, MemoryBytes: 100, MemoryLimit: 1000, Containers: 1},
+		{AppName: "shop", Time: hour.Add(20 * time.Minute), CPUPercent: 30, MemoryBytes: 300, MemoryLimit: 1000, Containers: 2},
+		{AppName: "shop", Time: hour.Add(time.Hour), CPUPercent: 50, MemoryBytes: 500, MemoryLimit: 1000, Containers: 1},
+		{AppName: "blog", Time: hour.Add(5 * time.Minute), CPUPercent: 1, MemoryBytes: 10, Containers: 1},
+	}
+	for _, u := range samples {
+		u.Period, u.Samples, u.CPUMax, u.MemoryMax = time.Minute, 1, u.CPUPercent, u.MemoryBytes
+		if err := db.RecordAppUsage(u); err != nil {
+			t.Fatalf("RecordAppUsage() error = %v", err)
+		}
+	}
+
+	merged, err := db.DownsampleAppUsage(hour.Add(time.Hour+30*time.Minute), time.Hour)
+	if err != nil {
+		t.Fatalf("DownsampleAppUsage() error = %v", err)
+	}
+	if merged != 3 {
+		t.Errorf("DownsampleAppUsage() merged %d rows, want 3", merged)
+	}
+
+	usage, err := db.ListAppUsage("shop", hour.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("ListAppUsage() error = %v", err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("ListAppUsage() = %+v, want the downsampled hour and the newer sample", usage)
+	}
+	want := AppUsage{
+		AppName: "shop", Time: hour, Period: time.Hour, Samples: 2,
+		CPUPercent: 20, CPUMax: 30, MemoryBytes: 200, MemoryMax: 300, MemoryLimit: 1000, Containers: 2,
+	}
+	got := usage[0]
+	got.Time = got.Time.UTC()
+	if got != want {
+		t.Errorf("downsampled usage = %+v, want %+v", got, want)
+	}
+	if usage[1].Period != time.Minute || usage[1].CPUPercent != 50 {
+		t.Errorf("newer usage = %+v, want the sample left as is", usage[1])
+	}
+
+	pruned, err := db.PruneAppUsage(hour.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("PruneAppUsage() error = %v", err)
+	}
+	if pruned != 2 {
+		t.Errorf("PruneAppUsage() removed %d rows, want 2", pruned)
+	}
+
+	if err := db.DeleteAppUsage("shop"); err != nil {
+		t.Fatalf("DeleteAppUsage() error = %v", err)
+	}
+	if usage, _ := db.ListAppUsage("shop", time.Time{}); len(usage) != 0 {
+		t.Errorf("ListAppUsage() after delete = %+v, want none", usage)
+	}
+}
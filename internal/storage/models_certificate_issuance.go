@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// CertificateIssuance is the failure record of a domain's certificate
+// issuance. haloyd waits until NextAttemptAt before asking the CA again, so
+// failing domains don't use up the CA's rate limits. The record is removed
+// once a certificate is issued.
+type CertificateIssuance struct {
+	Domain        string    `db:"domain" json:"domain"`
+	Failures      int       `db:"failures" json:"failures"`
+	LastAttemptAt time.Time `db:"last_attempt_at" json:"lastAttemptAt"`
+	LastError     string    `db:"last_error" json:"lastError"`
+	RateLimited   bool      `db:"rate_limited" json:"rateLimited"`
+	NextAttemptAt time.Time `db:"next_attempt_at" json:"nextAttemptAt"`
+}
+
+func createCertificateIssuanceTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS certificate_issuance (
+    domain TEXT PRIMARY KEY,                -- Canonical domain
+    failures INTEGER NOT NULL DEFAULT 0,    -- Consecutive failed attempts
+    last_attempt_at DATETIME NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    rate_limited INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL
+);
+`
+	return db.createTable("certificate_issuance", schema)
+}
+
+// SaveCertificateIssuance saves or replaces the failure record of a domain.
+func (db *DB) SaveCertificateIssuance(issuance CertificateIssuance) error {
+	query := `INSERT OR REPLACE INTO certificate_issuance (domain, failures, last_attempt_at, last_error, rate_limited, next_attempt_at)
+              VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := db.Exec(query, issuance.Domain, issuance.Failures, issuance.LastAttemptAt, issuance.LastError,
+		issuance.RateLimited, issuance.NextAttemptAt)
+	return err
+}
+
+// DeleteCertificateIssuance removes the failure record of a domain.
+func (db *DB) DeleteCertificateIssuance(domain string) error {
+	_, err := db.Exec(`DELETE FROM certificate_issuance WHERE domain = ?`, domain)
+	return err
+}
+
+// GetCertificateIssuance returns the failure record of a domain, or nil if
+// its last issuance succeeded or was never attempted.
+func (db *DB) GetCertificateIssuance(domain string) (*CertificateIssuance, error) {
+	issuances, err := db.queryCertificateIssuance(`WHERE domain = ?`, domain)
+	if err != nil {
+		return nil, err
+	}
+	if len(issuances) == 0 {
+		return nil, nil
+	}
+	return &issuances[0], nil
+}
+
+// ListCertificateIssuance returns the failure records of all domains.
+func (db *DB) ListCertificateIssuance() ([]CertificateIssuance, error) {
+	return db.queryCertificateIssuance(`ORDER BY domain`)
+}
+
+func (db *DB) queryCertificateIssuance(where string, args ...any) ([]CertificateIssuance, error) {
+	query := `SELECT domain, failures, last_attempt_at, last_error, rate_limited, next_attempt_at
+              FROM certificate_issuance ` + where
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certificate issuance: %w", err)
+	}
+	defer rows.Close()
+
+	var issuances []CertificateIssuance
+	for rows.Next() {
+		var i CertificateIssuance
+		if err := rows.Scan(&i.Domain, &i.Failures, &i.LastAttemptAt, &i.LastError, &i.RateLimited, &i.NextAttemptAt); err != nil {
+			return nil, fmt.Errorf("failed to scan certificate issuance: %w", err)
+		}
+		issuances = append(issuances, i)
+	}
+	return issuances, rows.Err()
+}
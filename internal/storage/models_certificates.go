@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CertificateVersions is how many versions of a domain's certificate are
+// kept, the current one included.
+const CertificateVersions = 3
+
+// Certificate is an issued certificate for a canonical domain. The current
+// version of each domain is what haloyd writes to the certificate directory
+// for the proxy; older versions are kept as history.
+type Certificate struct {
+	ID         int64     `db:"id" json:"id"`
+	Domain     string    `db:"domain" json:"domain"`
+	Names      []string  `db:"names" json:"names"`
+	CertPEM    []byte    `db:"cert_pem" json:"-"`
+	KeyPEM     []byte    `db:"key_pem" json:"-"`
+	AccountURL string    `db:"account_url" json:"accountUrl"`
+	Staging    bool      `db:"staging" json:"staging"`
+	IssuedAt   time.Time `db:"issued_at" json:"issuedAt"`
+	NotAfter   time.Time `db:"not_after" json:"notAfter"`
+	Current    bool      `db:"current" json:"current"`
+}
+
+func createCertificatesTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS certificates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain TEXT NOT NULL,                   -- Canonical domain, the certificate file name
+    names TEXT NOT NULL,                    -- Comma-separated DNS names covered
+    cert_pem BLOB NOT NULL,
+    key_pem BLOB NOT NULL,
+    account_url TEXT NOT NULL DEFAULT '',   -- ACME account that issued it
+    staging INTEGER NOT NULL DEFAULT 0,
+    issued_at DATETIME NOT NULL,
+    not_after DATETIME NOT NULL,
+    current INTEGER NOT NULL DEFAULT 0      -- 1 for the version being served
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificates_domain ON certificates(domain);
+`
+	return db.createTable("certificates", schema)
+}
+
+// SaveCertificate stores cert as the current version for its domain in one
+// transaction, keeping the CertificateVersions most recent versions.
+func (db *DB) SaveCertificate(cert Certificate) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE certificates SET current = 0 WHERE domain = ?`, cert.Domain); err != nil {
+		return 0, fmt.Errorf("failed to retire previous certificate: %w", err)
+	}
+	result, err := tx.Exec(`INSERT INTO certificates (domain, names, cert_pem, key_pem, account_url, staging, issued_at, not_after, current)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1)`,
+		cert.Domain, strings.Join(cert.Names, ","), cert.CertPEM, cert.KeyPEM, cert.AccountURL, cert.Staging, cert.IssuedAt, cert.NotAfter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert certificate: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(`DELETE FROM certificates WHERE domain = ? AND id NOT IN (
+              SELECT id FROM certificates WHERE domain = ? ORDER BY id DESC LIMIT ?)`,
+		cert.Domain, cert.Domain, CertificateVersions)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune old certificates: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit certificate: %w", err)
+	}
+	return id, nil
+}
+
+// RetireCertificate marks the current certificate of domain as no longer
+// served. Its versions stay in the history.
+func (db *DB) RetireCertificate(domain string) error {
+	_, err := db.Exec(`UPDATE certificates SET current = 0 WHERE domain = ?`, domain)
+	return err
+}
+
+// GetCurrentCertificate returns the current certificate for domain, or nil
+// if there is none.
+func (db *DB) GetCurrentCertificate(domain string) (*Certificate, error) {
+	certs, err := db.queryCertificates(`WHERE domain = ? AND current = 1`, domain)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, nil
+	}
+	return &certs[0], nil
+}
+
+// ListCurrentCertificates returns the current certificate of every domain.
+func (db *DB) ListCurrentCertificates() ([]Certificate, error) {
+	return db.queryCertificates(`WHERE current = 1 ORDER BY domain`)
+}
+
+// ListCertificateVersions returns the stored versions of domain's
+// certificate, newest first.
+func (db *DB) ListCertificateVersions(domain string) ([]Certificate, error) {
+	return db.queryCertificates(`WHERE domain = ? ORDER BY id DESC`, domain)
+}
+
+func (db *DB) queryCertificates(where string, args ...any) ([]Certificate, error) {
+	query := `SELECT id, domain, names, cert_pem, key_pem, account_url, staging, issued_at, not_after, current
+              FROM certificates ` + where
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certificates: %w", err)
+	}
+	defer rows.Close()
+
+	var certs []Certificate
+	for rows.Next() {
+		var cert Certificate
+		var names string
+		if err := rows.Scan(&cert.ID, &cert.Domain, &names, &cert.CertPEM, &cert.KeyPEM, &cert.AccountURL,
+			&cert.Staging, &cert.IssuedAt, &cert.NotAfter, &cert.Current); err != nil {
+			return nil, fmt.Errorf("failed to scan certificate: %w", err)
+		}
+		if names != "" {
+			cert.Names = strings.Split(names, ",")
+		}
+		certs = append(certs, cert)
+	}
+	return certs, rows.Err()
+}
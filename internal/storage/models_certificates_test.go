@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSaveCertificateKeepsRecentVersions(t *testing.T) {
+	db := newInMemoryDB(t)
+
+	issued := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var ids []int64
+	for i := range CertificateVersions + 2 {
+		id, err := db.SaveCertificate(Certificate{
+			Domain:     "example.com",
+			Names:      []string{"example.com", "www.example.com"},
+			CertPEM:    fmt.Appendf(nil, "cert-%d", i),
+			KeyPEM:     fmt.Appendf(nil, "key-%d", i),
+			AccountURL: "https://acme.example/acct/1",
+			IssuedAt:   issued.AddDate(0, i, 0),
+			NotAfter:   issued.AddDate(0, i+3, 0),
+		})
+		if err != nil {
+			t.Fatalf("SaveCertificate() error = %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := db.SaveCertificate(Certificate{Domain: "other.com", Names: []string{"other.com"}, CertPEM: []byte("c"), KeyPEM: []byte("k")}); err != nil {
+		t.Fatalf("SaveCertificate() error = %v", err)
+	}
+
+	versions, err := db.ListCertificateVersions("example.com")
+	if err != nil {
+		t.Fatalf("ListCertificateVersions() error = %v", err)
+	}
+	if len(versions) != CertificateVersions {
+		t.Fatalf("kept %d versions, want %d", len(versions), CertificateVersions)
+	}
+	if versions[0].ID != ids[len(ids)-1] || !versions[0].Current {
+		t.Errorf("newest version = %+v, want id %d marked current", versions[0], ids[len(ids)-1])
+	}
+	for _, v := range versions[1:] {
+		if v.Current {
+			t.Errorf("version %d is still marked current", v.ID)
+		}
+	}
+
+	current, err := db.GetCurrentCertificate("example.com")
+	if err != nil {
+		t.Fatalf("GetCurrentCertificate() error = %v", err)
+	}
+	if current == nil || string(current.CertPEM) != fmt.Sprintf("cert-%d", CertificateVersions+1) {
+		t.Fatalf("GetCurrentCertificate() = %+v, want the last saved version", current)
+	}
+	if !slices.Equal(current.Names, []string{"example.com", "www.example.com"}) {
+		t.Errorf("Names = %v", current.Names)
+	}
+	if !current.NotAfter.Equal(issued.AddDate(0, CertificateVersions+4, 0)) {
+		t.Errorf("NotAfter = %v", current.NotAfter)
+	}
+}
+
+func TestRetireCertificate(t *testing.T) {
+	db := newInMemoryDB(t)
+
+	if _, err := db.SaveCertificate(Certificate{Domain: "example.com", CertPEM: []byte("c"), KeyPEM: []byte("k")}); err != nil {
+		t.Fatalf("SaveCertificate() error = %v", err)
+	}
+	if err := db.RetireCertificate("example.com"); err != nil {
+		t.Fatalf("RetireCertificate() error = %v", err)
+	}
+
+	current, err := db.ListCurrentCertificates()
+	if err != nil {
+		t.Fatalf("ListCurrentCertificates() error = %v", err)
+	}
+	if len(current) != 0 {
+		t.Errorf("ListCurrentCertificates() = %d certificates, want none", len(current))
+	}
+	versions, err := db.ListCertificateVersions("example.com")
+	if err != nil {
+		t.Fatalf("ListCertificateVersions() error = %v", err)
+	}
+	if len(versions) != 1 {
+		t.Errorf("retired certificate should stay in the history, got %d versions", len(versions))
+	}
+}
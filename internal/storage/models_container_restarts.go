@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// ContainerRestart is a restart of an app container by Docker's restart
+// policy, recorded to tell crash looping containers from ones that restarted
+// once.
+type ContainerRestart struct {
+	ContainerID  string    `db:"container_id" json:"containerId"`
+	RestartCount int       `db:"restart_count" json:"restartCount"` // Docker's restart count after this restart
+	AppName      string    `db:"app_name" json:"appName"`
+	DeploymentID string    `db:"deployment_id" json:"deploymentId"`
+	ExitCode     int       `db:"exit_code" json:"exitCode"` // Exit code of the run that ended
+	RestartedAt  time.Time `db:"restarted_at" json:"restartedAt"`
+}
+
+func createContainerRestartsTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS container_restarts (
+    container_id TEXT NOT NULL,
+    restart_count INTEGER NOT NULL,
+    app_name TEXT NOT NULL,
+    deployment_id TEXT NOT NULL,
+    exit_code INTEGER NOT NULL DEFAULT 0,
+    restarted_at DATETIME NOT NULL,
+    PRIMARY KEY (container_id, restart_count)
+);
+
+CREATE INDEX IF NOT EXISTS idx_container_restarts_restarted_at ON container_restarts(restarted_at);
+`
+	return db.createTable("container_restarts", schema)
+}
+
+// RecordContainerRestart saves a restart. It reports false when the restart
+// was already recorded, e.g. from a repeated Docker event.
+func (db *DB) RecordContainerRestart(restart ContainerRestart) (bool, error) {
+	query := `INSERT OR IGNORE INTO container_restarts (container_id, restart_count, app_name, deployment_id, exit_code, restarted_at)
+              VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := db.Exec(query, restart.ContainerID, restart.RestartCount, restart.AppName, restart.DeploymentID,
+		restart.ExitCode, restart.RestartedAt)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	return inserted > 0, err
+}
+
+// CountContainerRestarts returns how often a container was restarted since
+// the given time.
+func (db *DB) CountContainerRestarts(containerID string, since time.Time) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM container_restarts WHERE container_id = ? AND restarted_at >= ?`,
+		containerID, since).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count container restarts: %w", err)
+	}
+	return count, nil
+}
+
+// PruneContainerRestarts removes restarts recorded before the given time.
+func (db *DB) PruneContainerRestarts(before time.Time) (int64, error) {
+	result, err := db.Exec(`DELETE FROM container_restarts WHERE restarted_at < ?`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune container restarts: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteAppContainerRestarts removes the restarts recorded for an app.
+func (db *DB) DeleteAppContainerRestarts(appName string) error {
+	if _, err := db.Exec(`DELETE FROM container_restarts WHERE app_name = ?`, appName); err != nil {
+		return fmt.Errorf("failed to delete container restarts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DatabaseCredentials are the generated credentials of a managed database.
+// They're created on the database's first deploy and kept afterwards, as the
+// database's data directory is initialized with them.
+type DatabaseCredentials struct {
+	AppName   string    `db:"app_name" json:"appName"`
+	Engine    string    `db:"engine" json:"engine"`
+	Database  string    `db:"db_name" json:"database"`
+	Username  string    `db:"username" json:"username"`
+	Password  string    `db:"password" json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+func createDatabaseCredentialsTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS database_credentials (
+    app_name TEXT PRIMARY KEY,
+    engine TEXT NOT NULL,
+    db_name TEXT NOT NULL,
+    username TEXT NOT NULL,
+    password TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+`
+	return db.createTable("database_credentials", schema)
+}
+
+// EnsureDatabaseCredentials stores creds unless the app already has
+// credentials, and returns the stored ones.
+func (db *DB) EnsureDatabaseCredentials(creds DatabaseCredentials) (*DatabaseCredentials, error) {
+	query := `INSERT OR IGNORE INTO database_credentials (app_name, engine, db_name, username, password, created_at)
+              VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := db.Exec(query, creds.AppName, creds.Engine, creds.Database, creds.Username, creds.Password, creds.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to save database credentials: %w", err)
+	}
+	stored, err := db.GetDatabaseCredentials(creds.AppName)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, fmt.Errorf("database credentials for '%s' missing after save", creds.AppName)
+	}
+	return stored, nil
+}
+
+// GetDatabaseCredentials returns the credentials of a managed database, or
+// nil if it has none.
+func (db *DB) GetDatabaseCredentials(appName string) (*DatabaseCredentials, error) {
+	var creds DatabaseCredentials
+	err := db.QueryRow(`SELECT app_name, engine, db_name, username, password, created_at FROM database_credentials WHERE app_name = ?`, appName).
+		Scan(&creds.AppName, &creds.Engine, &creds.Database, &creds.Username, &creds.Password, &creds.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database credentials: %w", err)
+	}
+	return &creds, nil
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnsureDatabaseCredentials(t *testing.T) {
+	db := newInMemoryDB(t)
+
+	if creds, err := db.GetDatabaseCredentials("shop-db"); err != nil || creds != nil {
+		t.Fatalf("GetDatabaseCredentials() = %+v, %v, want nil", creds, err)
+	}
+
+	first := DatabaseCredentials{AppName: "shop-db", Engine: "postgres", Database: "shop_db", Username: "shop_db", Password: "first", CreatedAt: time.Now()}
+	got, err := db.EnsureDatabaseCredentials(first)
+	if err != nil {
+		t.Fatalf("EnsureDatabaseCredentials() error = %v", err)
+	}
+	if got.Password != "first" {
+		t.Fatalf("EnsureDatabaseCredentials() password = %q, want first", got.Password)
+	}
+
+	// A later deploy must not replace the password the database was
+	// initialized with.
+	second := first
+	second.Password = "second"
+	got, err = db.EnsureDatabaseCredentials(second)
+	if err != nil {
+		t.Fatalf("EnsureDatabaseCredentials() second call error = %v", err)
+	}
+	if got.Password != "first" {
+		t.Errorf("EnsureDatabaseCredentials() password = %q after second call, want first", got.Password)
+	}
+}
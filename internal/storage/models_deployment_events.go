@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+// DeploymentEvent records a deployment entering a state. The events of a
+// deployment are its history, and the latest one its current state.
+type DeploymentEvent struct {
+	ID           int64     `db:"id" json:"id"`
+	DeploymentID string    `db:"deployment_id" json:"deploymentId"`
+	AppName      string    `db:"app_name" json:"appName"`
+	State        string    `db:"state" json:"state"`
+	Message      string    `db:"message" json:"message"`
+	CreatedAt    time.Time `db:"created_at" json:"createdAt"`
+}
+
+func createDeploymentEventsTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS deployment_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    deployment_id TEXT NOT NULL,
+    app_name TEXT NOT NULL,
+    state TEXT NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_deployment_events_deployment_id ON deployment_events(deployment_id);
+CREATE INDEX IF NOT EXISTS idx_deployment_events_app_name ON deployment_events(app_name);
+`
+	return db.createTable("deployment_events", schema)
+}
+
+// AppendDeploymentEvent records an event. Events are never updated.
+func (db *DB) AppendDeploymentEvent(event DeploymentEvent) error {
+	_, err := db.Exec(`INSERT INTO deployment_events (deployment_id, app_name, state, message, created_at) VALUES (?, ?, ?, ?, ?)`,
+		event.DeploymentID, event.AppName, event.State, event.Message, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record deployment event: %w", err)
+	}
+	return nil
+}
+
+// GetDeploymentEvents returns the events of a deployment, oldest first.
+func (db *DB) GetDeploymentEvents(deploymentID string) ([]DeploymentEvent, error) {
+	rows, err := db.Query(`SELECT id, deployment_id, app_name, state, message, created_at
+              FROM deployment_events WHERE deployment_id = ? ORDER BY id`, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deployment events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []DeploymentEvent
+	for rows.Next() {
+		var e DeploymentEvent
+		if err := rows.Scan(&e.ID, &e.DeploymentID, &e.AppName, &e.State, &e.Message, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan deployment event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// ListDeploymentsInState returns the deployments of an app whose latest
+// event is state, oldest first.
+func (db *DB) ListDeploymentsInState(appName, state string) ([]string, error) {
+	rows, err := db.Query(`SELECT e.deployment_id FROM deployment_events e
+              WHERE e.app_name = ? AND e.state = ?
+              AND e.id = (SELECT MAX(id) FROM deployment_events WHERE deployment_id = e.deployment_id)`, appName, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deployments in state %s: %w", state, err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan deployment ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(ids, helpers.CompareDeploymentIDs)
+	return ids, nil
+}
+
+// PruneDeploymentEvents removes the history of deployments that ended in
+// one of the given states before the cutoff, and returns how many events it
+// removed.
+func (db *DB) PruneDeploymentEvents(before time.Time, endStates ...string) (int64, error) {
+	if len(endStates) == 0 {
+		return 0, nil
+	}
+	args := []any{before}
+	placeholders := ""
+	for i, state := range endStates {
+		if i > 0 {
+			placeholders += ", "
+		}
+		placeholders += "?"
+		args = append(args, state)
+	}
+	result, err := db.Exec(`DELETE FROM deployment_events WHERE deployment_id IN (
+              SELECT e.deployment_id FROM deployment_events e
+              WHERE e.created_at < ? AND e.state IN (`+placeholders+`)
+              AND e.id = (SELECT MAX(id) FROM deployment_events WHERE deployment_id = e.deployment_id))`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune deployment events: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteAppDeploymentEvents removes the history of every deployment of an
+// app.
+func (db *DB) DeleteAppDeploymentEvents(appName string) error {
+	_, err := db.Exec(`DELETE FROM deployment_events WHERE app_name = ?`, appName)
+	return err
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DeploymentLog is one entry of the log a deployment streamed, kept so it
+// can be read after the deployment ended. Operations that stream like a
+// deployment, e.g. database restores, are kept under their operation ID.
+type DeploymentLog struct {
+	ID           int64           `db:"id" json:"id"`
+	DeploymentID string          `db:"deployment_id" json:"deploymentId"`
+	AppName      string          `db:"app_name" json:"appName"` // Empty when the entry didn't name the app
+	Entry        json.RawMessage `db:"entry" json:"entry"`      // logging.LogEntry
+	CreatedAt    time.Time       `db:"created_at" json:"createdAt"`
+}
+
+func createDeploymentLogsTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS deployment_logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    deployment_id TEXT NOT NULL,
+    app_name TEXT NOT NULL DEFAULT '',
+    entry JSON NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_deployment_logs_deployment_id ON deployment_logs(deployment_id, id);
+CREATE INDEX IF NOT EXISTS idx_deployment_logs_created_at ON deployment_logs(created_at);
+`
+	return db.createTable("deployment_logs", schema)
+}
+
+// AppendDeploymentLog records a log entry of a deployment.
+func (db *DB) AppendDeploymentLog(log DeploymentLog) error {
+	_, err := db.Exec(`INSERT INTO deployment_logs (deployment_id, app_name, entry, created_at) VALUES (?, ?, ?, ?)`,
+		log.DeploymentID, log.AppName, log.Entry, log.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record deployment log: %w", err)
+	}
+	return nil
+}
+
+// ListDeploymentLogs returns up to limit log entries of a deployment
+// recorded after the entry with ID afterID, oldest first. Pass the ID of the
+// last entry returned to read the next page.
+func (db *DB) ListDeploymentLogs(deploymentID string, afterID int64, limit int) ([]DeploymentLog, error) {
+	rows, err := db.Query(`SELECT id, deployment_id, app_name, entry, created_at
+              FROM deployment_logs WHERE deployment_id = ? AND id > ?
+              ORDER BY id LIMIT ?`, deploymentID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deployment logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []DeploymentLog
+	for rows.Next() {
+		var l DeploymentLog
+		if err := rows.Scan(&l.ID, &l.DeploymentID, &l.AppName, &l.Entry, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan deployment log: %w", err)
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+// PruneDeploymentLogs removes the logs recorded before the cutoff of
+// deployments that have no state history left, and returns how many entries
+// it removed. The logs of a deployment are kept as long as its history.
+func (db *DB) PruneDeploymentLogs(before time.Time) (int64, error) {
+	result, err := db.Exec(`DELETE FROM deployment_logs WHERE created_at < ?
+              AND deployment_id NOT IN (SELECT deployment_id FROM deployment_events)`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune deployment logs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteAppDeploymentLogs removes the logs of every deployment of an app.
+// Run it before the app's deployment events are deleted, which tie entries
+// that didn't name the app to it.
+func (db *DB) DeleteAppDeploymentLogs(appName string) error {
+	_, err := db.Exec(`DELETE FROM deployment_logs WHERE app_name = ?
+              OR deployment_id IN (SELECT deployment_id FROM deployment_events WHERE app_name = ?)`, appName, appName)
+	return err
+}
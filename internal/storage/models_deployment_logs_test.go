@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDeploymentLogs(t *testing.T) {
+	db := newInMemoryDB(t)
+
+	appendLog := func(deploymentID, appName, message string, at time.Time) {
+		t.Helper()
+		entry := DeploymentLog{DeploymentID: deploymentID, AppName: appName, Entry: json.RawMessage(`{"message":"` + message + `"}`), CreatedAt: at}
+		if err := db.AppendDeploymentLog(entry); err != nil {
+			t.Fatalf("AppendDeploymentLog() error = %v", err)
+		}
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	appendLog("d1", "web", "one", old)
+	appendLog("d1", "", "two", old)
+	appendLog("d2", "api", "other", old)
+	appendLog("d1", "web", "three", old)
+	appendLog("restore-1", "", "restoring", old)
+
+	// Pages follow each other by ID.
+	page, err := db.ListDeploymentLogs("d1", 0, 2)
+	if err != nil || len(page) != 2 || string(page[0].Entry) != `{"message":"one"}` {
+		t.Fatalf("ListDeploymentLogs() = %+v, %v, want the first two entries of d1", page, err)
+	}
+	page, err = db.ListDeploymentLogs("d1", page[1].ID, 2)
+	if err != nil || len(page) != 1 || string(page[0].Entry) != `{"message":"three"}` {
+		t.Fatalf("ListDeploymentLogs() = %+v, %v, want the last entry of d1", page, err)
+	}
+
+	if err := db.AppendDeploymentEvent(DeploymentEvent{DeploymentID: "d1", AppName: "web", State: "live", CreatedAt: old}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AppendDeploymentEvent(DeploymentEvent{DeploymentID: "d2", AppName: "api", State: "live", CreatedAt: old}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Logs of deployments with history are kept, others go past the cutoff.
+	pruned, err := db.PruneDeploymentLogs(time.Now().Add(-time.Hour))
+	if err != nil || pruned != 1 {
+		t.Fatalf("PruneDeploymentLogs() = %d, %v, want the restore log removed", pruned, err)
+	}
+
+	// Entries that didn't name the app go with it too.
+	if err := db.DeleteAppDeploymentLogs("web"); err != nil {
+		t.Fatalf("DeleteAppDeploymentLogs() error = %v", err)
+	}
+	if logs, _ := db.ListDeploymentLogs("d1", 0, 10); len(logs) != 0 {
+		t.Errorf("logs of d1 = %+v, want none after deleting web", logs)
+	}
+	if logs, _ := db.ListDeploymentLogs("d2", 0, 10); len(logs) != 1 {
+		t.Errorf("logs of d2 = %+v, want them kept", logs)
+	}
+}
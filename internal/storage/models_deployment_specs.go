@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/haloydev/haloy/internal/helpers"
+)
+
+// DeploymentSpec is what a deployment was started with. The spec of an
+// app's live deployment is the app's desired state, which haloyd recreates
+// the app from when its containers are lost.
+type DeploymentSpec struct {
+	DeploymentID string   `db:"deployment_id" json:"deploymentId"`
+	AppName      string   `db:"app_name" json:"appName"`
+	ImageRef     string   `db:"image_ref" json:"imageRef"`
+	Replicas     int      `db:"replicas" json:"replicas"`
+	Domains      []string `db:"domains" json:"domains"`    // Canonical domains
+	EnvNames     []string `db:"env_names" json:"envNames"` // Names of the env vars set, values are in TargetConfig
+	// TargetConfig is the config.TargetConfig the deployment was requested
+	// with, before haloyd added database env vars and resolved server
+	// secrets.
+	TargetConfig         json.RawMessage `db:"target_config" json:"targetConfig"`
+	RollbackDeployConfig json.RawMessage `db:"rollback_deploy_config" json:"rollbackDeployConfig"` // config.DeployConfig
+	ErrorPages           json.RawMessage `db:"error_pages" json:"errorPages"`
+	CreatedAt            time.Time       `db:"created_at" json:"createdAt"`
+}
+
+func createDeploymentSpecsTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS deployment_specs (
+    deployment_id TEXT PRIMARY KEY,
+    app_name TEXT NOT NULL,
+    image_ref TEXT NOT NULL,
+    replicas INTEGER NOT NULL,
+    domains JSON NOT NULL,
+    env_names JSON NOT NULL,
+    target_config JSON NOT NULL,
+    rollback_deploy_config JSON NOT NULL,
+    error_pages JSON NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_deployment_specs_app_name ON deployment_specs(app_name);
+`
+	return db.createTable("deployment_specs", schema)
+}
+
+// SaveDeploymentSpec records the spec of a deployment.
+func (db *DB) SaveDeploymentSpec(spec DeploymentSpec) error {
+	domains, err := json.Marshal(spec.Domains)
+	if err != nil {
+		return err
+	}
+	envNames, err := json.Marshal(spec.EnvNames)
+	if err != nil {
+		return err
+	}
+	query := `INSERT OR REPLACE INTO deployment_specs (deployment_id, app_name, image_ref, replicas, domains, env_names,
+              target_config, rollback_deploy_config, error_pages, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = db.Exec(query, spec.DeploymentID, spec.AppName, spec.ImageRef, spec.Replicas, domains, envNames,
+		spec.TargetConfig, spec.RollbackDeployConfig, spec.ErrorPages, spec.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save deployment spec: %w", err)
+	}
+	return nil
+}
+
+// ListDeploymentSpecsInState returns, for every app, the spec of its latest
+// deployment whose latest event is state, ordered by app.
+func (db *DB) ListDeploymentSpecsInState(state string) ([]DeploymentSpec, error) {
+	rows, err := db.Query(`SELECT s.deployment_id, s.app_name, s.image_ref, s.replicas, s.domains, s.env_names,
+              s.target_config, s.rollback_deploy_config, s.error_pages, s.created_at
+              FROM deployment_specs s JOIN deployment_events e ON e.deployment_id = s.deployment_id
+              WHERE e.state = ?
+              AND e.id = (SELECT MAX(id) FROM deployment_events WHERE deployment_id = e.deployment_id)`, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deployment specs: %w", err)
+	}
+	defer rows.Close()
+
+	latest := make(map[string]DeploymentSpec)
+	for rows.Next() {
+		var spec DeploymentSpec
+		var domains, envNames []byte
+		if err := rows.Scan(&spec.DeploymentID, &spec.AppName, &spec.ImageRef, &spec.Replicas, &domains, &envNames,
+			&spec.TargetConfig, &spec.RollbackDeployConfig, &spec.ErrorPages, &spec.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan deployment spec: %w", err)
+		}
+		if err := json.Unmarshal(domains, &spec.Domains); err != nil {
+			return nil, fmt.Errorf("failed to decode domains of deployment %s: %w", spec.DeploymentID, err)
+		}
+		if err := json.Unmarshal(envNames, &spec.EnvNames); err != nil {
+			return nil, fmt.Errorf("failed to decode env names of deployment %s: %w", spec.DeploymentID, err)
+		}
+		if current, ok := latest[spec.AppName]; !ok || helpers.CompareDeploymentIDs(current.DeploymentID, spec.DeploymentID) < 0 {
+			latest[spec.AppName] = spec
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	specs := make([]DeploymentSpec, 0, len(latest))
+	for _, spec := range latest {
+		specs = append(specs, spec)
+	}
+	slices.SortFunc(specs, func(a, b DeploymentSpec) int { return strings.Compare(a.AppName, b.AppName) })
+	return specs, nil
+}
+
+// PruneDeploymentSpecs removes the specs of deployments whose latest event
+// is one of the given states, and returns how many it removed.
+func (db *DB) PruneDeploymentSpecs(endStates ...string) (int64, error) {
+	if len(endStates) == 0 {
+		return 0, nil
+	}
+	args := make([]any, 0, len(endStates))
+	for _, state := range endStates {
+		args = append(args, state)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(endStates)), ", ")
+	result, err := db.Exec(`DELETE FROM deployment_specs WHERE deployment_id IN (
+              SELECT e.deployment_id FROM deployment_events e
+              WHERE e.state IN (`+placeholders+`)
+              AND e.id = (SELECT MAX(id) FROM deployment_events WHERE deployment_id = e.deployment_id))`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune deployment specs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteAppDeploymentSpecs removes the specs of every deployment of an app.
+func (db *DB) DeleteAppDeploymentSpecs(appName string) error {
+	_, err := db.Exec(`DELETE FROM deployment_specs WHERE app_name = ?`, appName)
+	return err
+}
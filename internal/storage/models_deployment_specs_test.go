@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDeploymentSpecs(t *testing.T) {
+	db := newInMemoryDB(t)
+
+	event := func(deploymentID, appName, state string) {
+		t.Helper()
+		if err := db.AppendDeploymentEvent(DeploymentEvent{DeploymentID: deploymentID, AppName: appName, State: state, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save := func(deploymentID, appName string) {
+		t.Helper()
+		spec := DeploymentSpec{
+			DeploymentID:         deploymentID,
+			AppName:              appName,
+			ImageRef:             appName + ":latest",
+			Replicas:             2,
+			Domains:              []string{appName + ".example.com"},
+			EnvNames:             []string{"SECRET_KEY"},
+			TargetConfig:         json.RawMessage(`{"name":"` + appName + `"}`),
+			RollbackDeployConfig: json.RawMessage(`{}`),
+			ErrorPages:           json.RawMessage(`null`),
+			CreatedAt:            time.Now(),
+		}
+		if err := db.SaveDeploymentSpec(spec); err != nil {
+			t.Fatalf("SaveDeploymentSpec() error = %v", err)
+		}
+	}
+
+	save("20240101000000", "web")
+	event("20240101000000", "web", "superseded")
+	save("20240102000000", "web")
+	event("20240102000000", "web", "live")
+	save("20240103000000", "web")
+	event("20240103000000", "web", "failed")
+	save("20240101000000-api", "api")
+	event("20240101000000-api", "api", "starting")
+
+	specs, err := db.ListDeploymentSpecsInState("live")
+	if err != nil {
+		t.Fatalf("ListDeploymentSpecsInState() error = %v", err)
+	}
+	if len(specs) != 1 || specs[0].DeploymentID != "20240102000000" {
+		t.Fatalf("ListDeploymentSpecsInState() = %+v, want the live deployment of web", specs)
+	}
+	if got := specs[0]; got.Replicas != 2 || len(got.Domains) != 1 || got.EnvNames[0] != "SECRET_KEY" || string(got.TargetConfig) != `{"name":"web"}` {
+		t.Errorf("spec = %+v, want it as saved", got)
+	}
+
+	pruned, err := db.PruneDeploymentSpecs("superseded", "failed")
+	if err != nil {
+		t.Fatalf("PruneDeploymentSpecs() error = %v", err)
+	}
+	if pruned != 2 {
+		t.Errorf("PruneDeploymentSpecs() = %d, want 2", pruned)
+	}
+
+	if err := db.DeleteAppDeploymentSpecs("web"); err != nil {
+		t.Fatalf("DeleteAppDeploymentSpecs() error = %v", err)
+	}
+	if specs, _ := db.ListDeploymentSpecsInState("live"); len(specs) != 0 {
+		t.Errorf("ListDeploymentSpecsInState() after delete = %+v, want none", specs)
+	}
+}
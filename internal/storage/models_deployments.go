@@ -33,11 +33,7 @@ CREATE TABLE IF NOT EXISTS deployments (
 CREATE INDEX IF NOT EXISTS idx_deployments_app_name ON deployments(app_name);
 `
 
-	_, err := db.Exec(schema)
-	if err != nil {
-		return fmt.Errorf("failed to create deployments table: %w", err)
-	}
-	return nil
+	return db.createTable("deployments", schema)
 }
 
 func (db *DB) SaveDeployment(deployment Deployment) error {
@@ -119,6 +115,16 @@ func (db *DB) PruneOldDeployments(appName string, deploymentsToKeep int) error {
 	return nil
 }
 
+// DeleteAppDeployments removes the whole deployment history of an app and
+// returns how many deployments it held.
+func (db *DB) DeleteAppDeployments(appName string) (int64, error) {
+	result, err := db.Exec(`DELETE FROM deployments WHERE app_name = ?`, appName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete deployments: %w", err)
+	}
+	return result.RowsAffected()
+}
+
 func (db *DB) ListDistinctAppNames() ([]string, error) {
 	query := `SELECT DISTINCT app_name FROM deployments`
 	rows, err := db.Query(query)
@@ -20,7 +20,7 @@ func newInMemoryDB(t *testing.T) *DB {
 		_ = rawDB.Close()
 	})
 
-	db := &DB{rawDB}
+	db := &DB{DB: rawDB}
 	if err := db.Migrate(); err != nil {
 		t.Fatalf("Migrate() error = %v", err)
 	}
@@ -132,6 +132,48 @@ func TestDeployment_PruneOldDeployments(t *testing.T) {
 	}
 }
 
+func TestDeployment_DeleteAppDeployments(t *testing.T) {
+	db := newInMemoryDB(t)
+
+	rolledBackFrom := "20260222010101"
+	deployments := []Deployment{
+		{ID: "20260222010101", AppName: "app"},
+		{ID: "20260222010102", AppName: "app", RolledBackFrom: &rolledBackFrom},
+		{ID: "20260222010199", AppName: "other-app"},
+	}
+	for _, d := range deployments {
+		d.RawDeployConfig = mustJSON(t, config.DeployConfig{TargetConfig: config.TargetConfig{Name: d.AppName}})
+		d.DeployedImage = mustJSON(t, config.Image{Repository: "nginx", Tag: d.ID})
+		if err := db.SaveDeployment(d); err != nil {
+			t.Fatalf("SaveDeployment(%s) error = %v", d.ID, err)
+		}
+	}
+
+	deleted, err := db.DeleteAppDeployments("app")
+	if err != nil {
+		t.Fatalf("DeleteAppDeployments() error = %v", err)
+	}
+	if deleted != 2 {
+		t.Fatalf("DeleteAppDeployments() = %d, want 2", deleted)
+	}
+
+	history, err := db.GetDeploymentHistory("app", 10)
+	if err != nil {
+		t.Fatalf("GetDeploymentHistory() error = %v", err)
+	}
+	if len(history) != 0 {
+		t.Fatalf("GetDeploymentHistory() length = %d, want 0", len(history))
+	}
+
+	otherHistory, err := db.GetDeploymentHistory("other-app", 10)
+	if err != nil {
+		t.Fatalf("GetDeploymentHistory(other-app) error = %v", err)
+	}
+	if len(otherHistory) != 1 {
+		t.Fatalf("GetDeploymentHistory(other-app) length = %d, want 1", len(otherHistory))
+	}
+}
+
 func TestDeployment_GetImageRef_InvalidJSON(t *testing.T) {
 	d := &Deployment{DeployedImage: []byte("not-json")}
 
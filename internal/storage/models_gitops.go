@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GitOpsApp is an app haloyd deployed from the GitOps repository. Apps it
+// has no record of are never destroyed by GitOps.
+type GitOpsApp struct {
+	AppName      string    `db:"app_name" json:"appName"`
+	TargetName   string    `db:"target_name" json:"targetName"`
+	ConfigHash   string    `db:"config_hash" json:"configHash"` // Hash of the applied target config
+	Commit       string    `db:"commit_sha" json:"commit"`
+	DeploymentID string    `db:"deployment_id" json:"deploymentId"`
+	AppliedAt    time.Time `db:"applied_at" json:"appliedAt"`
+}
+
+// GitOpsPause is set while GitOps is paused with haloy gitops pause.
+type GitOpsPause struct {
+	Reason   string    `db:"reason" json:"reason"`
+	PausedAt time.Time `db:"paused_at" json:"pausedAt"`
+}
+
+// gitOpsPauseID is the id of the only row of gitops_pause.
+const gitOpsPauseID = 1
+
+func createGitOpsTables(db *DB) error {
+	apps := `
+CREATE TABLE IF NOT EXISTS gitops_apps (
+    app_name TEXT PRIMARY KEY,
+    target_name TEXT NOT NULL,
+    config_hash TEXT NOT NULL,
+    commit_sha TEXT NOT NULL,
+    deployment_id TEXT NOT NULL,
+    applied_at DATETIME NOT NULL
+);
+`
+	if err := db.createTable("gitops_apps", apps); err != nil {
+		return err
+	}
+
+	pause := `
+CREATE TABLE IF NOT EXISTS gitops_pause (
+    id INTEGER PRIMARY KEY,
+    reason TEXT NOT NULL,
+    paused_at DATETIME NOT NULL
+);
+`
+	return db.createTable("gitops_pause", pause)
+}
+
+// SaveGitOpsApp records that an app was deployed from the repository.
+func (db *DB) SaveGitOpsApp(app GitOpsApp) error {
+	query := `INSERT OR REPLACE INTO gitops_apps (app_name, target_name, config_hash, commit_sha, deployment_id, applied_at)
+              VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := db.Exec(query, app.AppName, app.TargetName, app.ConfigHash, app.Commit, app.DeploymentID, app.AppliedAt)
+	return err
+}
+
+// DeleteGitOpsApp forgets an app removed from the repository.
+func (db *DB) DeleteGitOpsApp(appName string) error {
+	_, err := db.Exec(`DELETE FROM gitops_apps WHERE app_name = ?`, appName)
+	return err
+}
+
+// ListGitOpsApps returns the apps deployed from the repository, sorted by
+// name.
+func (db *DB) ListGitOpsApps() ([]GitOpsApp, error) {
+	rows, err := db.Query(`SELECT app_name, target_name, config_hash, commit_sha, deployment_id, applied_at FROM gitops_apps ORDER BY app_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list gitops apps: %w", err)
+	}
+	defer rows.Close()
+
+	var apps []GitOpsApp
+	for rows.Next() {
+		var app GitOpsApp
+		if err := rows.Scan(&app.AppName, &app.TargetName, &app.ConfigHash, &app.Commit, &app.DeploymentID, &app.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan gitops app: %w", err)
+		}
+		apps = append(apps, app)
+	}
+	return apps, rows.Err()
+}
+
+// PauseGitOps stops GitOps from applying changes until ResumeGitOps.
+func (db *DB) PauseGitOps(pause GitOpsPause) error {
+	query := `INSERT OR REPLACE INTO gitops_pause (id, reason, paused_at)
+              VALUES (?, ?, ?)`
+	_, err := db.Exec(query, gitOpsPauseID, pause.Reason, pause.PausedAt)
+	return err
+}
+
+// ResumeGitOps removes the pause, if any.
+func (db *DB) ResumeGitOps() error {
+	_, err := db.Exec(`DELETE FROM gitops_pause WHERE id = ?`, gitOpsPauseID)
+	return err
+}
+
+// GetGitOpsPause returns the pause, or nil if GitOps isn't paused.
+func (db *DB) GetGitOpsPause() (*GitOpsPause, error) {
+	var pause GitOpsPause
+	err := db.QueryRow(`SELECT reason, paused_at FROM gitops_pause WHERE id = ?`, gitOpsPauseID).
+		Scan(&pause.Reason, &pause.PausedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gitops pause: %w", err)
+	}
+	return &pause, nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGitOpsApps(t *testing.T) {
+	db := newInMemoryDB(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	app := GitOpsApp{AppName: "shop", TargetName: "production", ConfigHash: "h1", Commit: "abc", DeploymentID: "d1", AppliedAt: now}
+	if err := db.SaveGitOpsApp(app); err != nil {
+		t.Fatalf("SaveGitOpsApp() error = %v", err)
+	}
+	app.ConfigHash, app.Commit, app.DeploymentID = "h2", "def", "d2"
+	if err := db.SaveGitOpsApp(app); err != nil {
+		t.Fatalf("SaveGitOpsApp() second call error = %v", err)
+	}
+	if err := db.SaveGitOpsApp(GitOpsApp{AppName: "blog", TargetName: "blog", ConfigHash: "h3", Commit: "def", DeploymentID: "d3", AppliedAt: now}); err != nil {
+		t.Fatalf("SaveGitOpsApp() error = %v", err)
+	}
+
+	apps, err := db.ListGitOpsApps()
+	if err != nil {
+		t.Fatalf("ListGitOpsApps() error = %v", err)
+	}
+	if len(apps) != 2 || apps[0].AppName != "blog" || apps[1].AppName != "shop" {
+		t.Fatalf("ListGitOpsApps() = %+v, want blog and shop", apps)
+	}
+	if apps[1].ConfigHash != "h2" || apps[1].Commit != "def" {
+		t.Errorf("shop = %+v, want the second save", apps[1])
+	}
+
+	if err := db.DeleteGitOpsApp("blog"); err != nil {
+		t.Fatalf("DeleteGitOpsApp() error = %v", err)
+	}
+	if apps, _ := db.ListGitOpsApps(); len(apps) != 1 {
+		t.Errorf("ListGitOpsApps() after delete = %+v, want only shop", apps)
+	}
+}
+
+func TestGitOpsPause(t *testing.T) {
+	db := newInMemoryDB(t)
+
+	if pause, err := db.GetGitOpsPause(); err != nil || pause != nil {
+		t.Fatalf("GetGitOpsPause() = %+v, %v, want nil", pause, err)
+	}
+	if err := db.PauseGitOps(GitOpsPause{Reason: "incident", PausedAt: time.Now()}); err != nil {
+		t.Fatalf("PauseGitOps() error = %v", err)
+	}
+	if err := db.PauseGitOps(GitOpsPause{Reason: "still investigating", PausedAt: time.Now()}); err != nil {
+		t.Fatalf("PauseGitOps() second call error = %v", err)
+	}
+	pause, err := db.GetGitOpsPause()
+	if err != nil || pause == nil || pause.Reason != "still investigating" {
+		t.Fatalf("GetGitOpsPause() = %+v, %v, want the latest pause", pause, err)
+	}
+	if err := db.ResumeGitOps(); err != nil {
+		t.Fatalf("ResumeGitOps() error = %v", err)
+	}
+	if pause, err := db.GetGitOpsPause(); err != nil || pause != nil {
+		t.Errorf("GetGitOpsPause() after resume = %+v, %v, want nil", pause, err)
+	}
+}
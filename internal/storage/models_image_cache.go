@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CachedImage is a registry image kept in the layer store by the pull-through cache.
+// Manifest and Config hold the docker save metadata needed to reassemble the
+// image from stored layers; RemoteDigest is the registry manifest digest the
+// image was pulled at, used to decide whether the cached copy is still current.
+type CachedImage struct {
+	ImageRef     string    `db:"image_ref" json:"imageRef"`
+	RemoteDigest string    `db:"remote_digest" json:"remoteDigest"`
+	ImageID      string    `db:"image_id" json:"imageId"`
+	Manifest     []byte    `db:"manifest" json:"manifest"`
+	Config       []byte    `db:"config" json:"config"`
+	Size         int64     `db:"size" json:"size"`
+	CreatedAt    time.Time `db:"created_at" json:"createdAt"`
+	LastUsedAt   time.Time `db:"last_used_at" json:"lastUsedAt"`
+}
+
+func createImageCacheTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS image_cache (
+    image_ref TEXT PRIMARY KEY,
+    remote_digest TEXT NOT NULL DEFAULT '',
+    image_id TEXT NOT NULL DEFAULT '',
+    manifest BLOB NOT NULL,
+    config BLOB NOT NULL,
+    size INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+`
+	return db.createTable("image_cache", schema)
+}
+
+// SaveCachedImage saves or replaces the cache entry for an image reference.
+func (db *DB) SaveCachedImage(img CachedImage) error {
+	query := `INSERT OR REPLACE INTO image_cache (image_ref, remote_digest, image_id, manifest, config, size, created_at, last_used_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.Exec(query, img.ImageRef, img.RemoteDigest, img.ImageID, img.Manifest, img.Config, img.Size, img.CreatedAt, img.LastUsedAt)
+	return err
+}
+
+// GetCachedImage returns the cache entry for an image reference, or nil if none exists.
+func (db *DB) GetCachedImage(imageRef string) (*CachedImage, error) {
+	query := `SELECT image_ref, remote_digest, image_id, manifest, config, size, created_at, last_used_at
+              FROM image_cache WHERE image_ref = ?`
+	var img CachedImage
+	err := db.QueryRow(query, imageRef).Scan(&img.ImageRef, &img.RemoteDigest, &img.ImageID, &img.Manifest, &img.Config, &img.Size, &img.CreatedAt, &img.LastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached image: %w", err)
+	}
+	return &img, nil
+}
+
+// ListCachedImages returns all cache entries, least recently used first.
+func (db *DB) ListCachedImages() ([]CachedImage, error) {
+	query := `SELECT image_ref, remote_digest, image_id, manifest, config, size, created_at, last_used_at
+              FROM image_cache ORDER BY last_used_at ASC`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cached images: %w", err)
+	}
+	defer rows.Close()
+
+	var images []CachedImage
+	for rows.Next() {
+		var img CachedImage
+		if err := rows.Scan(&img.ImageRef, &img.RemoteDigest, &img.ImageID, &img.Manifest, &img.Config, &img.Size, &img.CreatedAt, &img.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cached image: %w", err)
+		}
+		images = append(images, img)
+	}
+	return images, rows.Err()
+}
+
+// TouchCachedImage updates the last_used_at timestamp of a cache entry.
+func (db *DB) TouchCachedImage(imageRef string) error {
+	_, err := db.Exec(`UPDATE image_cache SET last_used_at = ? WHERE image_ref = ?`, time.Now(), imageRef)
+	return err
+}
+
+// DeleteCachedImage removes a cache entry. Its layers are left to layer pruning.
+func (db *DB) DeleteCachedImage(imageRef string) error {
+	_, err := db.Exec(`DELETE FROM image_cache WHERE image_ref = ?`, imageRef)
+	return err
+}
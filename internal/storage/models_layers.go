@@ -30,9 +30,8 @@ CREATE TABLE IF NOT EXISTS layers (
 
 CREATE INDEX IF NOT EXISTS idx_layers_last_used ON layers(last_used_at);
 `
-	_, err := db.Exec(schema)
-	if err != nil {
-		return fmt.Errorf("failed to create layers table: %w", err)
+	if err := db.createTable("layers", schema); err != nil {
+		return err
 	}
 
 	return addLayerDiffIDColumn(db)
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PausedApp marks an app stopped with haloy stop. haloyd keeps no routes for
+// a paused app, and haloy start brings the deployment back. A new deploy
+// unpauses the app.
+type PausedApp struct {
+	AppName      string    `db:"app_name" json:"appName"`
+	DeploymentID string    `db:"deployment_id" json:"deploymentId"` // Deployment that was stopped
+	PausedAt     time.Time `db:"paused_at" json:"pausedAt"`
+}
+
+func createPausedAppsTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS paused_apps (
+    app_name TEXT PRIMARY KEY,
+    deployment_id TEXT NOT NULL,
+    paused_at DATETIME NOT NULL
+);
+`
+	return db.createTable("paused_apps", schema)
+}
+
+// PauseApp marks an app as paused.
+func (db *DB) PauseApp(app PausedApp) error {
+	query := `INSERT OR REPLACE INTO paused_apps (app_name, deployment_id, paused_at)
+              VALUES (?, ?, ?)`
+	_, err := db.Exec(query, app.AppName, app.DeploymentID, app.PausedAt)
+	return err
+}
+
+// ResumeApp removes the paused mark of an app, if any.
+func (db *DB) ResumeApp(appName string) error {
+	_, err := db.Exec(`DELETE FROM paused_apps WHERE app_name = ?`, appName)
+	return err
+}
+
+// GetPausedApp returns the paused mark of an app, or nil if it isn't paused.
+func (db *DB) GetPausedApp(appName string) (*PausedApp, error) {
+	var app PausedApp
+	err := db.QueryRow(`SELECT app_name, deployment_id, paused_at FROM paused_apps WHERE app_name = ?`, appName).
+		Scan(&app.AppName, &app.DeploymentID, &app.PausedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paused app: %w", err)
+	}
+	return &app, nil
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Secret is a value stored with 'haloy secret set' that env vars reference
+// as server://name. haloyd resolves the references when it deploys.
+type Secret struct {
+	Name      string    `db:"name" json:"name"`
+	Value     string    `db:"value" json:"-"`
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+func createSecretsTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS secrets (
+    name TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+`
+	return db.createTable("secrets", schema)
+}
+
+// SaveSecret creates or replaces a secret.
+func (db *DB) SaveSecret(secret Secret) error {
+	query := `INSERT OR REPLACE INTO secrets (name, value, updated_at)
+              VALUES (?, ?, ?)`
+	if _, err := db.Exec(query, secret.Name, secret.Value, secret.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save secret: %w", err)
+	}
+	return nil
+}
+
+// GetSecret returns a secret, or nil if there's none of that name.
+func (db *DB) GetSecret(name string) (*Secret, error) {
+	var secret Secret
+	err := db.QueryRow(`SELECT name, value, updated_at FROM secrets WHERE name = ?`, name).
+		Scan(&secret.Name, &secret.Value, &secret.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+	return &secret, nil
+}
+
+// ListSecrets returns all secrets, ordered by name.
+func (db *DB) ListSecrets() ([]Secret, error) {
+	rows, err := db.Query(`SELECT name, value, updated_at FROM secrets ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	defer rows.Close()
+
+	var secrets []Secret
+	for rows.Next() {
+		var secret Secret
+		if err := rows.Scan(&secret.Name, &secret.Value, &secret.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan secret: %w", err)
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, rows.Err()
+}
+
+// DeleteSecret removes a secret and reports whether it existed.
+func (db *DB) DeleteSecret(name string) (bool, error) {
+	result, err := db.Exec(`DELETE FROM secrets WHERE name = ?`, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete secret: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSecrets(t *testing.T) {
+	db := newInMemoryDB(t)
+
+	if secret, err := db.GetSecret("stripe-key"); err != nil || secret != nil {
+		t.Fatalf("GetSecret() = %+v, %v, want nil", secret, err)
+	}
+
+	first := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	if err := db.SaveSecret(Secret{Name: "stripe-key", Value: "sk_old", UpdatedAt: first}); err != nil {
+		t.Fatalf("SaveSecret() error = %v", err)
+	}
+	if err := db.SaveSecret(Secret{Name: "stripe-key", Value: "sk_new", UpdatedAt: first.Add(time.Hour)}); err != nil {
+		t.Fatalf("SaveSecret() rotation error = %v", err)
+	}
+	if err := db.SaveSecret(Secret{Name: "db-password", Value: "hunter2", UpdatedAt: first}); err != nil {
+		t.Fatalf("SaveSecret() error = %v", err)
+	}
+
+	secret, err := db.GetSecret("stripe-key")
+	if err != nil || secret == nil {
+		t.Fatalf("GetSecret() = %+v, %v", secret, err)
+	}
+	if secret.Value != "sk_new" || !secret.UpdatedAt.Equal(first.Add(time.Hour)) {
+		t.Errorf("GetSecret() = %+v, want the rotated value", secret)
+	}
+
+	secrets, err := db.ListSecrets()
+	if err != nil {
+		t.Fatalf("ListSecrets() error = %v", err)
+	}
+	if len(secrets) != 2 || secrets[0].Name != "db-password" || secrets[1].Name != "stripe-key" {
+		t.Errorf("ListSecrets() = %+v, want db-password and stripe-key", secrets)
+	}
+
+	if deleted, err := db.DeleteSecret("stripe-key"); err != nil || !deleted {
+		t.Fatalf("DeleteSecret() = %v, %v, want true", deleted, err)
+	}
+	if deleted, err := db.DeleteSecret("stripe-key"); err != nil || deleted {
+		t.Errorf("DeleteSecret() of a missing secret = %v, %v, want false", deleted, err)
+	}
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Server upgrade states. An upgrade is scheduled until its window starts,
+// running while the upgrade script runs, and ends in one of the others.
+const (
+	ServerUpgradeScheduled = "scheduled"
+	ServerUpgradeRunning   = "running"
+	ServerUpgradeCompleted = "completed"
+	ServerUpgradeFailed    = "failed"
+	ServerUpgradeMissed    = "missed" // haloyd wasn't running during the window
+	ServerUpgradeCanceled  = "canceled"
+)
+
+// ServerUpgrade is an upgrade of haloyd and haloy-proxy scheduled with
+// haloy server upgrade --schedule. Only the latest one is kept.
+type ServerUpgrade struct {
+	Schedule    string    `db:"schedule" json:"schedule"` // Window start, like "Sat 03:00"
+	Timezone    string    `db:"timezone" json:"timezone"`
+	StartAt     time.Time `db:"start_at" json:"startAt"`
+	Deadline    time.Time `db:"deadline" json:"deadline"` // Latest time the upgrade may start at
+	NotifyURL   string    `db:"notify_url" json:"notifyUrl"`
+	State       string    `db:"state" json:"state"`
+	FromVersion string    `db:"from_version" json:"fromVersion"` // Version running when the upgrade started
+	ToVersion   string    `db:"to_version" json:"toVersion"`
+	Message     string    `db:"message" json:"message"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// Finished reports whether the upgrade ended, one way or another.
+func (u ServerUpgrade) Finished() bool {
+	return u.State != ServerUpgradeScheduled && u.State != ServerUpgradeRunning
+}
+
+// serverUpgradeID is the id of the only row of server_upgrades.
+const serverUpgradeID = 1
+
+func createServerUpgradesTable(db *DB) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS server_upgrades (
+    id INTEGER PRIMARY KEY,
+    schedule TEXT NOT NULL,
+    timezone TEXT NOT NULL,
+    start_at DATETIME NOT NULL,
+    deadline DATETIME NOT NULL,
+    notify_url TEXT NOT NULL,
+    state TEXT NOT NULL,
+    from_version TEXT NOT NULL,
+    to_version TEXT NOT NULL,
+    message TEXT NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+`
+	return db.createTable("server_upgrades", schema)
+}
+
+// SaveServerUpgrade stores upgrade, replacing the previous one.
+func (db *DB) SaveServerUpgrade(u ServerUpgrade) error {
+	query := `INSERT OR REPLACE INTO server_upgrades (id, schedule, timezone, start_at, deadline, notify_url, state,
+              from_version, to_version, message, updated_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.Exec(query, serverUpgradeID, u.Schedule, u.Timezone, u.StartAt, u.Deadline, u.NotifyURL,
+		u.State, u.FromVersion, u.ToVersion, u.Message, u.UpdatedAt)
+	return err
+}
+
+// GetServerUpgrade returns the latest upgrade, or nil if none was scheduled.
+func (db *DB) GetServerUpgrade() (*ServerUpgrade, error) {
+	var u ServerUpgrade
+	err := db.QueryRow(`SELECT schedule, timezone, start_at, deadline, notify_url, state, from_version, to_version, message, updated_at
+              FROM server_upgrades WHERE id = ?`, serverUpgradeID).
+		Scan(&u.Schedule, &u.Timezone, &u.StartAt, &u.Deadline, &u.NotifyURL, &u.State, &u.FromVersion, &u.ToVersion, &u.Message, &u.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server upgrade: %w", err)
+	}
+	return &u, nil
+}
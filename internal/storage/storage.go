@@ -17,6 +17,7 @@ type DB struct {
 	*sql.DB
 }
 
+// New opens the SQLite file in the data directory.
 func New() (*DB, error) {
 	dataDir, err := config.DataDir()
 	if err != nil {
@@ -51,3 +52,11 @@ func New() (*DB, error) {
 
 	return &DB{database}, nil
 }
+
+// createTable runs the table definition schema of the named table.
+func (db *DB) createTable(name, schema string) error {
+	if _, err := db.Exec(schema); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package ui
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrInputRequired is returned instead of prompting in non-interactive mode.
+var ErrInputRequired = errors.New("input required but running non-interactively")
+
+var nonInteractive atomic.Bool
+
+// SetNonInteractive turns off prompts and live-updating output such as
+// progress bars.
+func SetNonInteractive(enabled bool) {
+	nonInteractive.Store(enabled)
+}
+
+func NonInteractive() bool {
+	return nonInteractive.Load()
+}
+
+// ciEnvVars are set by CI systems; CI covers GitHub Actions, GitLab, CircleCI,
+// Travis, Buildkite and most others.
+var ciEnvVars = []string{"CI", "JENKINS_URL", "TF_BUILD", "TEAMCITY_VERSION"}
+
+// DetectCI reports whether the process runs under a CI system.
+func DetectCI() bool {
+	for _, name := range ciEnvVars {
+		value := strings.ToLower(strings.TrimSpace(os.Getenv(name)))
+		if value != "" && value != "false" && value != "0" {
+			return true
+		}
+	}
+	return false
+}
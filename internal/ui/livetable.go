@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// LiveTable redraws a table in place as its rows change. In non-interactive
+// mode, where redraws would pile up in the log, only the final table is
+// printed.
+type LiveTable struct {
+	headers []string
+	mu      sync.Mutex
+	lines   int // lines drawn by the last update
+}
+
+func NewLiveTable(headers []string) *LiveTable {
+	return &LiveTable{headers: headers}
+}
+
+// Update replaces the drawn table with one of rows (thread-safe).
+func (t *LiveTable) Update(rows [][]string) {
+	if NonInteractive() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.draw(rows)
+}
+
+// Finish draws the final rows, after which the table is left alone.
+func (t *LiveTable) Finish(rows [][]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.draw(rows)
+	t.lines = 0
+}
+
+func (t *LiveTable) draw(rows [][]string) {
+	out := progressOutput()
+	if t.lines > 0 {
+		fmt.Fprint(out, ansi.CursorUp(t.lines), "\r", ansi.EraseScreenBelow)
+	}
+	rendered := renderTable(t.headers, rows)
+	fmt.Fprintln(out, rendered)
+	t.lines = strings.Count(rendered, "\n") + 1
+}
@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestLiveTableRedrawsInPlace(t *testing.T) {
+	var output bytes.Buffer
+	configureProgressTestDoubles(t, &output, 80)
+
+	table := NewLiveTable([]string{"SERVER", "PHASE"})
+	table.Update([][]string{{"a.example.com", "upgrading"}})
+	first := output.String()
+	if strings.Contains(first, ansi.EraseScreenBelow) {
+		t.Fatalf("first draw erased the screen: %q", first)
+	}
+
+	lines := strings.Count(first, "\n")
+	table.Finish([][]string{{"a.example.com", "done"}})
+	redraw := strings.TrimPrefix(output.String(), first)
+	if !strings.HasPrefix(redraw, ansi.CursorUp(lines)+"\r"+ansi.EraseScreenBelow) {
+		t.Fatalf("redraw = %q, want it to start by moving up %d lines", redraw, lines)
+	}
+	if !strings.Contains(ansi.Strip(redraw), "done") {
+		t.Errorf("redraw = %q, want the updated row", ansi.Strip(redraw))
+	}
+}
+
+func TestLiveTableNonInteractivePrintsFinalTableOnce(t *testing.T) {
+	var output bytes.Buffer
+	configureProgressTestDoubles(t, &output, 80)
+	SetNonInteractive(true)
+	t.Cleanup(func() { SetNonInteractive(false) })
+
+	table := NewLiveTable([]string{"SERVER", "PHASE"})
+	table.Update([][]string{{"a.example.com", "upgrading"}})
+	if output.Len() != 0 {
+		t.Fatalf("update printed %q in non-interactive mode", output.String())
+	}
+	table.Finish([][]string{{"a.example.com", "done"}})
+
+	got := ansi.Strip(output.String())
+	if strings.Contains(got, "upgrading") || !strings.Contains(got, "done") {
+		t.Errorf("output = %q, want only the final table", got)
+	}
+}
@@ -8,6 +8,14 @@ import (
 )
 
 func DisplayLogEntry(logEntry logging.LogEntry, prefix string) {
+	if logEntry.Dropped > 0 {
+		gap := fmt.Sprintf("%d log entries skipped, the connection fell behind", logEntry.Dropped)
+		if prefix != "" {
+			gap = fmt.Sprintf("%s %s", stylePrefix(prefix), gap)
+		}
+		Warn("%s", gap)
+	}
+
 	message := logEntry.Message
 	isSuccess := logEntry.IsDeploymentSuccess
 	domains := logEntry.Domains
@@ -39,6 +47,11 @@ func DisplayLogEntry(logEntry logging.LogEntry, prefix string) {
 		}
 	}
 
+	// Phases before the end show how far the deployment got.
+	if event := logEntry.Event; event != nil && event.Phase != logging.PhaseLive && event.Phase != logging.PhaseFailed {
+		message = fmt.Sprintf("%s %s", progressBar(event.Percent), message)
+	}
+
 	if prefix != "" {
 		message = fmt.Sprintf("%s %s", stylePrefix(prefix), message)
 	}
@@ -70,3 +83,9 @@ func DisplayLogEntry(logEntry logging.LogEntry, prefix string) {
 		fmt.Printf("%s\n", message)
 	}
 }
+
+// progressBar renders percent as a bar of ten steps.
+func progressBar(percent int) string {
+	filled := min(max(percent, 0), 100) / 10
+	return fmt.Sprintf("[%s%s] %3d%%", strings.Repeat("=", filled), strings.Repeat(" ", 10-filled), percent)
+}
@@ -74,14 +74,26 @@ func (p *ProgressBar) CompleteItem() {
 	p.render()
 }
 
-// Finish completes the progress bar and moves to next line
+// Finish completes the progress bar and moves to next line. In
+// non-interactive mode, where no updates were drawn, it prints the final
+// state once instead.
 func (p *ProgressBar) Finish() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if NonInteractive() {
+		fmt.Fprintln(progressOutput(), p.formatLine(infoPrefix(), p.status(p.current.Load(), p.completed.Load()), 0, 0))
+		return
+	}
 	fmt.Fprint(progressOutput(), "\r", ansi.EraseLineRight)
 }
 
 func (p *ProgressBar) render() {
+	// Carriage-return redraws only make sense on a terminal; CI logs would
+	// get a line per update.
+	if NonInteractive() {
+		return
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -165,13 +177,13 @@ func (p *ProgressBar) status(current int64, completed int32) string {
 	if p.showBytes && p.total > 0 {
 		return fmt.Sprintf("%d/%d (%s / %s)",
 			completed, p.totalItems,
-			formatBytes(current), formatBytes(p.total))
+			FormatBytes(current), FormatBytes(p.total))
 	}
 	return fmt.Sprintf("%d/%d", completed, p.totalItems)
 }
 
-// formatBytes formats bytes into human-readable format
-func formatBytes(b int64) string {
+// FormatBytes formats bytes into human-readable format
+func FormatBytes(b int64) string {
 	const unit = 1024
 	if b < unit {
 		return fmt.Sprintf("%d B", b)
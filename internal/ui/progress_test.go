@@ -118,3 +118,30 @@ func renderedProgressLines(output string) []string {
 	}
 	return lines
 }
+
+func TestProgressBarNonInteractivePrintsFinalStateOnce(t *testing.T) {
+	var output bytes.Buffer
+	configureProgressTestDoubles(t, &output, 80)
+	SetNonInteractive(true)
+	t.Cleanup(func() { SetNonInteractive(false) })
+
+	progress := NewProgressBar(ProgressBarConfig{
+		Description: "Uploading layers",
+		TotalBytes:  2048,
+		TotalItems:  2,
+		ShowBytes:   true,
+	})
+	progress.Add(1024)
+	progress.CompleteItem()
+	progress.Add(1024)
+	progress.CompleteItem()
+	progress.Finish()
+
+	got := ansi.Strip(output.String())
+	if strings.Contains(got, "\r") {
+		t.Fatalf("expected no carriage-return redraws, got %q", got)
+	}
+	if strings.Count(got, "\n") != 1 || !strings.Contains(got, "Uploading layers 2/2 (2.0 KB / 2.0 KB)") {
+		t.Fatalf("expected a single final progress line, got %q", got)
+	}
+}
@@ -89,6 +89,10 @@ func Section(title string, textLines []string) {
 }
 
 func Table(headers []string, rows [][]string) {
+	fmt.Println(renderTable(headers, rows))
+}
+
+func renderTable(headers []string, rows [][]string) string {
 	cellStyle := lipgloss.NewStyle().Padding(0, 1)
 
 	t := table.New().
@@ -106,7 +110,7 @@ func Table(headers []string, rows [][]string) {
 		}).
 		Headers(headers...).
 		Rows(rows...)
-	fmt.Println(t)
+	return t.String()
 }
 
 func printStyledLines(output *os.File, prefix string, style lipgloss.Style, format string, a ...any) {
@@ -156,8 +160,12 @@ func (p *PrefixedUI) Success(format string, a ...any) {
 	p.call(Success, format, a...)
 }
 
-// Prompt asks the user for input and returns the response
+// Prompt asks the user for input and returns the response. It fails with
+// ErrInputRequired in non-interactive mode.
 func Prompt(message string) (string, error) {
+	if NonInteractive() {
+		return "", fmt.Errorf("%w: %s", ErrInputRequired, message)
+	}
 	fmt.Fprint(os.Stdout, infoPrefix()+" "+message+" ")
 	reader := bufio.NewReader(os.Stdin)
 	response, err := reader.ReadString('\n')
@@ -0,0 +1,203 @@
+package volumesnapshots
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/config"
+)
+
+// restoreDir is where the new volumes are mounted in the helper container
+// the snapshot is extracted through.
+const restoreDir = "/haloy-restore"
+
+// RestoreOptions describes a snapshot of one app restored into the volumes
+// of another.
+type RestoreOptions struct {
+	// SourceApp is the app the snapshot was taken of.
+	SourceApp string
+	Snapshot  string
+	// Remote is the source's snapshots.remote, read when the snapshot isn't
+	// kept on this server.
+	Remote string
+	// Volumes maps the snapshot's volume names to the volumes to create.
+	// Volumes of the snapshot missing from it are skipped.
+	Volumes map[string]string
+	// Image is a local image to create the helper container from. It's never
+	// started, so any image will do.
+	Image string
+}
+
+// Restore creates new volumes for appName and fills them from a snapshot of
+// another app. The snapshot is checked against its checksum before anything
+// is created, and the volumes are removed again if the restore fails.
+func (s *Store) Restore(ctx context.Context, cli *client.Client, appName string, opts RestoreOptions, logger *slog.Logger) error {
+	if len(opts.Volumes) == 0 {
+		return errors.New("no volumes to restore")
+	}
+	for source, target := range opts.Volumes {
+		if target == source {
+			return fmt.Errorf("volume %s would be restored into itself", source)
+		}
+		if _, err := cli.VolumeInspect(ctx, target); err == nil {
+			return fmt.Errorf("volume %s already exists; restores only create new volumes", target)
+		} else if !client.IsErrNotFound(err) {
+			return fmt.Errorf("failed to inspect volume %s: %w", target, err)
+		}
+	}
+
+	archive, err := s.fetchVerified(ctx, opts.SourceApp, opts.Snapshot, opts.Remote)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	logger.Info("Snapshot checksum verified", "snapshot", opts.Snapshot)
+
+	var created []string
+	restored := false
+	defer func() {
+		if restored {
+			return
+		}
+		for _, name := range created {
+			if err := cli.VolumeRemove(context.Background(), name, true); err != nil {
+				logger.Warn("Failed to remove volume of failed restore", "volume", name, "error", err)
+			}
+		}
+	}()
+
+	mounts := make([]mount.Mount, 0, len(opts.Volumes))
+	for _, target := range opts.Volumes {
+		if _, err := cli.VolumeCreate(ctx, volume.CreateOptions{
+			Name:   target,
+			Labels: map[string]string{config.LabelAppName: appName},
+		}); err != nil {
+			return fmt.Errorf("failed to create volume %s: %w", target, err)
+		}
+		created = append(created, target)
+		mounts = append(mounts, mount.Mount{Type: mount.TypeVolume, Source: target, Target: path.Join(restoreDir, target)})
+		logger.Info(fmt.Sprintf("Created volume for %s", appName), "volume", target)
+	}
+
+	helper, err := cli.ContainerCreate(ctx, &container.Config{Image: opts.Image, Cmd: []string{"true"}}, &container.HostConfig{Mounts: mounts}, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create restore container: %w", err)
+	}
+	defer cli.ContainerRemove(context.Background(), helper.ID, container.RemoveOptions{Force: true})
+
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(renameVolumes(gz, pw, opts.Volumes))
+	}()
+	defer pr.Close()
+	if err := cli.CopyToContainer(ctx, helper.ID, restoreDir, pr, container.CopyToContainerOptions{CopyUIDGID: true}); err != nil {
+		return fmt.Errorf("failed to extract snapshot: %w", err)
+	}
+
+	restored = true
+	return nil
+}
+
+// fetchVerified copies a snapshot to a temporary file and checks it against
+// its checksum. The returned file is positioned at its start.
+func (s *Store) fetchVerified(ctx context.Context, appName, name, remote string) (*os.File, error) {
+	body, _, checksum, err := s.Open(ctx, appName, name, remote)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	want, err := ParseChecksum(checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	f, err := os.CreateTemp(s.dir, ".restore-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create restore file: %w", err)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, hash), body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("snapshot %s doesn't match its checksum: expected %s, got %s", name, want, got)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// renameVolumes copies a snapshot's tar from r to w, renaming the volume
+// directories at its root as volumes maps them and dropping the ones it
+// doesn't.
+func renameVolumes(r io.Reader, w io.Writer, volumes map[string]string) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		name, ok := renameRoot(hdr.Name, volumes)
+		if !ok {
+			continue
+		}
+		hdr.Name = name
+		if hdr.Typeflag == tar.TypeLink {
+			if hdr.Linkname, ok = renameRoot(hdr.Linkname, volumes); !ok {
+				return fmt.Errorf("hard link %s points outside its volume", hdr.Name)
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func renameRoot(name string, volumes map[string]string) (string, bool) {
+	root, rest, hasRest := strings.Cut(strings.TrimPrefix(name, "./"), "/")
+	target, ok := volumes[root]
+	if !ok {
+		return "", false
+	}
+	if !hasRest {
+		return target, true
+	}
+	return target + "/" + rest, true
+}
@@ -0,0 +1,46 @@
+package volumesnapshots
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestRenameVolumes(t *testing.T) {
+	var in bytes.Buffer
+	tw := tar.NewWriter(&in)
+	for _, hdr := range []*tar.Header{
+		{Name: "shop-uploads/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "shop-uploads/a.png", Typeflag: tar.TypeReg, Mode: 0o644},
+		{Name: "shop-uploads/b.png", Typeflag: tar.TypeLink, Linkname: "shop-uploads/a.png"},
+		{Name: "shop-cache/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "shop-cache/index", Typeflag: tar.TypeReg, Mode: 0o644},
+	} {
+		tw.WriteHeader(hdr)
+	}
+	tw.Close()
+
+	var out bytes.Buffer
+	if err := renameVolumes(&in, &out, map[string]string{"shop-uploads": "staging-uploads"}); err != nil {
+		t.Fatalf("renameVolumes() error = %v", err)
+	}
+
+	tr := tar.NewReader(&out)
+	var entries []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar Next() error = %v", err)
+		}
+		entries = append(entries, hdr.Name+">"+hdr.Linkname)
+	}
+	want := []string{"staging-uploads/>", "staging-uploads/a.png>", "staging-uploads/b.png>staging-uploads/a.png"}
+	if !slices.Equal(entries, want) {
+		t.Errorf("entries = %v, want %v", entries, want)
+	}
+}
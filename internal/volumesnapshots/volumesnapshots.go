@@ -0,0 +1,392 @@
+// Package volumesnapshots takes scheduled snapshots of an app's named volumes.
+// A snapshot is a gzipped tar with a directory per volume, kept in haloyd's
+// data directory next to a sha256sum-style checksum file, and uploaded to the
+// target's S3 remote when it has one.
+package volumesnapshots
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/s3"
+)
+
+// ErrNotFound is returned when opening a snapshot that doesn't exist.
+var ErrNotFound = errors.New("snapshot not found")
+
+const (
+	timestampFormat = "20060102T150405Z"
+	// ChecksumSuffix names the file holding a snapshot's SHA-256, in the
+	// format sha256sum -c reads.
+	ChecksumSuffix = ".sha256"
+)
+
+type Store struct {
+	dir string
+	s3  config.S3Config
+	now func() time.Time
+}
+
+// New returns a store keeping snapshots under dir, using s3Config's endpoint
+// and credentials for remotes.
+func New(dir string, s3Config config.S3Config) *Store {
+	return &Store{dir: dir, s3: s3Config, now: time.Now}
+}
+
+// volumeSource is a volume to archive; open returns a tar of its mount point
+// with the mount point itself as the root entry, as docker cp does.
+type volumeSource struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// Create snapshots the named volumes mounted in containerID, uploads the
+// snapshot to the remote of cfg if set, and drops the snapshots beyond the
+// retention.
+func (s *Store) Create(ctx context.Context, cli *client.Client, appName, containerID string, cfg config.SnapshotsConfig) (apitypes.VolumeSnapshot, error) {
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return apitypes.VolumeSnapshot{}, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	var volumes []volumeSource
+	for _, m := range info.Mounts {
+		if m.Type != mount.TypeVolume || m.Name == "" {
+			continue
+		}
+		volumes = append(volumes, volumeSource{name: m.Name, open: func() (io.ReadCloser, error) {
+			rc, _, err := docker.CopyFromContainer(ctx, cli, containerID, m.Destination)
+			return rc, err
+		}})
+	}
+	if len(volumes) == 0 {
+		return apitypes.VolumeSnapshot{}, fmt.Errorf("app %s has no named volumes to snapshot", appName)
+	}
+
+	appDir := filepath.Join(s.dir, appName)
+	if err := os.MkdirAll(appDir, 0o700); err != nil {
+		return apitypes.VolumeSnapshot{}, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	createdAt := s.now().UTC().Truncate(time.Second)
+	snapshot := apitypes.VolumeSnapshot{
+		Name:      fmt.Sprintf("%s-%s.tar.gz", appName, createdAt.Format(timestampFormat)),
+		CreatedAt: createdAt,
+		Local:     true,
+	}
+
+	tmp, err := os.CreateTemp(appDir, ".snapshot-*")
+	if err != nil {
+		return apitypes.VolumeSnapshot{}, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	if err := writeArchive(io.MultiWriter(tmp, hash), volumes); err != nil {
+		return apitypes.VolumeSnapshot{}, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return apitypes.VolumeSnapshot{}, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if snapshot.Size, err = tmp.Seek(0, io.SeekCurrent); err != nil {
+		return apitypes.VolumeSnapshot{}, err
+	}
+	checksum := checksumLine(hex.EncodeToString(hash.Sum(nil)), snapshot.Name)
+	if err := os.WriteFile(filepath.Join(appDir, snapshot.Name+ChecksumSuffix), []byte(checksum), 0o600); err != nil {
+		return apitypes.VolumeSnapshot{}, fmt.Errorf("failed to write checksum: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(appDir, snapshot.Name)); err != nil {
+		return apitypes.VolumeSnapshot{}, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	if cfg.Remote != "" {
+		if err := s.upload(ctx, appName, snapshot, checksum, cfg.Remote); err != nil {
+			return snapshot, fmt.Errorf("snapshot %s saved on the server, but failed to upload it: %w", snapshot.Name, err)
+		}
+		snapshot.Remote = true
+	}
+
+	if err := s.prune(ctx, appName, cfg); err != nil {
+		return snapshot, fmt.Errorf("snapshot %s saved, but failed to remove old snapshots: %w", snapshot.Name, err)
+	}
+	return snapshot, nil
+}
+
+// writeArchive writes a gzipped tar with the content of each volume under a
+// directory named after it.
+func writeArchive(w io.Writer, volumes []volumeSource) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, volume := range volumes {
+		if err := addVolume(tw, volume); err != nil {
+			return fmt.Errorf("failed to archive volume %s: %w", volume.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addVolume(tw *tar.Writer, volume volumeSource) error {
+	rc, err := volume.open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Replace the mount point's name, the root of docker cp's archive,
+		// with the volume's.
+		_, rest, _ := strings.Cut(strings.TrimPrefix(hdr.Name, "./"), "/")
+		hdr.Name = path.Join(volume.name, rest)
+		if hdr.Typeflag == tar.TypeDir {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// List returns the snapshots of an app on the server and in remote, newest
+// first.
+func (s *Store) List(ctx context.Context, appName, remote string) ([]apitypes.VolumeSnapshot, error) {
+	byName := make(map[string]*apitypes.VolumeSnapshot)
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, appName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+	for _, entry := range entries {
+		createdAt, ok := parseSnapshotName(appName, entry.Name())
+		if !ok {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		byName[entry.Name()] = &apitypes.VolumeSnapshot{Name: entry.Name(), Size: fi.Size(), CreatedAt: createdAt, Local: true}
+	}
+
+	if remote != "" {
+		store, prefix, err := s.remote(remote)
+		if err != nil {
+			return nil, err
+		}
+		objects, err := store.List(ctx, prefix+appName+"/")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list remote snapshots: %w", err)
+		}
+		for _, object := range objects {
+			name := path.Base(object.Key)
+			createdAt, ok := parseSnapshotName(appName, name)
+			if !ok {
+				continue
+			}
+			if snapshot, exists := byName[name]; exists {
+				snapshot.Remote = true
+				continue
+			}
+			byName[name] = &apitypes.VolumeSnapshot{Name: name, Size: object.Size, CreatedAt: createdAt, Remote: true}
+		}
+	}
+
+	snapshots := make([]apitypes.VolumeSnapshot, 0, len(byName))
+	for _, snapshot := range byName {
+		snapshots = append(snapshots, *snapshot)
+	}
+	slices.SortFunc(snapshots, func(a, b apitypes.VolumeSnapshot) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Open returns the content of a snapshot, read from the server if it's kept
+// there and from remote otherwise, with its checksum line. size is -1 when
+// unknown.
+func (s *Store) Open(ctx context.Context, appName, name, remote string) (body io.ReadCloser, size int64, checksum string, err error) {
+	if _, ok := parseSnapshotName(appName, name); !ok {
+		return nil, 0, "", fmt.Errorf("'%s' is not a snapshot of %s", name, appName)
+	}
+
+	localPath := filepath.Join(s.dir, appName, name)
+	if f, err := os.Open(localPath); err == nil {
+		sum, err := os.ReadFile(localPath + ChecksumSuffix)
+		if err != nil {
+			f.Close()
+			return nil, 0, "", fmt.Errorf("failed to read checksum: %w", err)
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, "", err
+		}
+		return f, fi.Size(), string(sum), nil
+	}
+
+	if remote == "" {
+		return nil, 0, "", ErrNotFound
+	}
+	store, prefix, err := s.remote(remote)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	key := prefix + appName + "/" + name
+	sumBody, err := store.Get(ctx, key+ChecksumSuffix)
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, 0, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, "", err
+	}
+	sum, err := io.ReadAll(io.LimitReader(sumBody, 1024))
+	sumBody.Close()
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to read checksum: %w", err)
+	}
+	body, err = store.Get(ctx, key)
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, 0, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return body, -1, string(sum), nil
+}
+
+// ParseChecksum returns the hex SHA-256 in a checksum line.
+func ParseChecksum(line string) (string, error) {
+	sum, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid checksum '%s'", line)
+	}
+	return sum, nil
+}
+
+func checksumLine(sum, name string) string {
+	return sum + "  " + name + "\n"
+}
+
+func (s *Store) upload(ctx context.Context, appName string, snapshot apitypes.VolumeSnapshot, checksum, remote string) error {
+	store, prefix, err := s.remote(remote)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(filepath.Join(s.dir, appName, snapshot.Name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	key := prefix + appName + "/" + snapshot.Name
+	if err := store.Put(ctx, key, f, snapshot.Size); err != nil {
+		return err
+	}
+	return store.Put(ctx, key+ChecksumSuffix, strings.NewReader(checksum), int64(len(checksum)))
+}
+
+func (s *Store) prune(ctx context.Context, appName string, cfg config.SnapshotsConfig) error {
+	snapshots, err := s.List(ctx, appName, cfg.Remote)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) <= cfg.GetRetain() {
+		return nil
+	}
+	var store *s3.Client
+	var prefix string
+	if cfg.Remote != "" {
+		if store, prefix, err = s.remote(cfg.Remote); err != nil {
+			return err
+		}
+	}
+	for _, snapshot := range snapshots[cfg.GetRetain():] {
+		if snapshot.Local {
+			localPath := filepath.Join(s.dir, appName, snapshot.Name)
+			if err := os.Remove(localPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			os.Remove(localPath + ChecksumSuffix)
+		}
+		if snapshot.Remote && store != nil {
+			key := prefix + appName + "/" + snapshot.Name
+			if err := store.Delete(ctx, key); err != nil {
+				return err
+			}
+			if err := store.Delete(ctx, key+ChecksumSuffix); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// remote returns a client for the bucket of an s3:// URL and the key prefix,
+// ending in a slash unless empty.
+func (s *Store) remote(remote string) (*s3.Client, string, error) {
+	bucket, prefix, err := config.ParseS3URL(remote)
+	if err != nil {
+		return nil, "", err
+	}
+	accessKeyID, secretAccessKey := s.s3.GetCredentials()
+	store, err := s3.New(s3.Config{
+		Endpoint:        s.s3.Endpoint,
+		Region:          s.s3.GetRegion(),
+		Bucket:          bucket,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	if prefix != "" {
+		prefix += "/"
+	}
+	return store, prefix, nil
+}
+
+var snapshotNamePattern = regexp.MustCompile(`^(.+)-(\d{8}T\d{6}Z)\.tar\.gz$`)
+
+// parseSnapshotName returns the time a snapshot of appName named name was
+// taken, and false if name isn't one.
+func parseSnapshotName(appName, name string) (time.Time, bool) {
+	m := snapshotNamePattern.FindStringSubmatch(name)
+	if m == nil || m[1] != appName {
+		return time.Time{}, false
+	}
+	createdAt, err := time.Parse(timestampFormat, m[2])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
@@ -0,0 +1,122 @@
+package volumesnapshots
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/config"
+)
+
+// dockerCpTar builds the archive docker cp returns for a mount point named
+// root holding files.
+func dockerCpTar(t *testing.T, root string, files map[string]string) func() (io.ReadCloser, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: root + "/", Typeflag: tar.TypeDir, Mode: 0o755})
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: root + "/" + name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	return func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(buf.Bytes())), nil }
+}
+
+func TestWriteArchive(t *testing.T) {
+	var out bytes.Buffer
+	err := writeArchive(&out, []volumeSource{
+		{name: "shop-uploads", open: dockerCpTar(t, "uploads", map[string]string{"a.png": "png"})},
+		{name: "shop-cache", open: dockerCpTar(t, "cache", map[string]string{"index": "idx"})},
+	})
+	if err != nil {
+		t.Fatalf("writeArchive() error = %v", err)
+	}
+
+	gz, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar Next() error = %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	want := []string{"shop-uploads/", "shop-uploads/a.png", "shop-cache/", "shop-cache/index"}
+	if !slices.Equal(names, want) {
+		t.Errorf("archive entries = %v, want %v", names, want)
+	}
+}
+
+func TestStore_ListOpenPrune(t *testing.T) {
+	dir := t.TempDir()
+	store := New(dir, config.S3Config{})
+	appDir := filepath.Join(dir, "shop")
+	os.MkdirAll(appDir, 0o700)
+
+	base := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		name := "shop-" + base.AddDate(0, 0, i).Format(timestampFormat) + ".tar.gz"
+		os.WriteFile(filepath.Join(appDir, name), []byte(name), 0o600)
+		os.WriteFile(filepath.Join(appDir, name+ChecksumSuffix), []byte(checksumLine("00", name)), 0o600)
+	}
+	os.WriteFile(filepath.Join(appDir, ".snapshot-123"), nil, 0o600)
+
+	ctx := context.Background()
+	snapshots, err := store.List(ctx, "shop", "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(snapshots) != 3 || snapshots[0].Name != "shop-20260303T030000Z.tar.gz" || !snapshots[0].Local {
+		t.Fatalf("List() = %+v, want 3 local snapshots, newest first", snapshots)
+	}
+
+	body, size, checksum, err := store.Open(ctx, "shop", snapshots[0].Name, "")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	body.Close()
+	if size != int64(len(snapshots[0].Name)) || checksum != checksumLine("00", snapshots[0].Name) {
+		t.Errorf("Open() size = %d, checksum = %q", size, checksum)
+	}
+	if _, _, _, err := store.Open(ctx, "shop", "../other/shop-20260303T030000Z.tar.gz", ""); err == nil {
+		t.Error("Open() accepted a path outside the app's snapshots")
+	}
+
+	if err := store.prune(ctx, "shop", config.SnapshotsConfig{Schedule: "@daily", Retain: 1}); err != nil {
+		t.Fatalf("prune() error = %v", err)
+	}
+	entries, _ := os.ReadDir(appDir)
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	want := []string{".snapshot-123", "shop-20260303T030000Z.tar.gz", "shop-20260303T030000Z.tar.gz.sha256"}
+	if !slices.Equal(left, want) {
+		t.Errorf("after prune = %v, want %v", left, want)
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	sum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got, err := ParseChecksum(checksumLine(sum, "a.tar.gz")); err != nil || got != sum {
+		t.Errorf("ParseChecksum() = %q, %v", got, err)
+	}
+	if _, err := ParseChecksum("nope  a.tar.gz"); err == nil {
+		t.Error("ParseChecksum() accepted an invalid line")
+	}
+}
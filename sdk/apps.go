@@ -0,0 +1,107 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/docker"
+)
+
+// Status returns the state of an app's containers. It returns ErrNotFound
+// for an app that isn't deployed.
+func (c *Client) Status(ctx context.Context, appName string) (*AppStatus, error) {
+	var response apitypes.AppStatusResponse
+	if err := c.api.Get(ctx, "status/"+url.PathEscape(appName), &response); err != nil {
+		return nil, fmt.Errorf("failed to get status of %s: %w", appName, wrapError(err))
+	}
+	return &response, nil
+}
+
+// LogsOptions selects the container logs Logs streams.
+type LogsOptions struct {
+	// Tail is the number of past lines to start with. The server's default
+	// is used when it's 0.
+	Tail int
+	// ContainerID picks a container of the app instead of the first one.
+	ContainerID string
+	// AllContainers streams the logs of all the app's containers.
+	AllContainers bool
+	// Follow keeps streaming new lines until ctx is done.
+	Follow bool
+}
+
+// Logs streams the logs of an app's containers to onLine. It returns when
+// the past lines are sent, or once ctx is done with opts.Follow set.
+func (c *Client) Logs(ctx context.Context, appName string, opts LogsOptions, onLine func(LogLine)) error {
+	params := url.Values{}
+	if opts.Tail > 0 {
+		params.Set("tail", strconv.Itoa(opts.Tail))
+	}
+	if opts.ContainerID != "" {
+		params.Set("containerId", opts.ContainerID)
+	}
+	if opts.AllContainers {
+		params.Set("allContainers", "true")
+	}
+	if !opts.Follow {
+		params.Set("follow", "false")
+	}
+
+	handler := func(data string) bool {
+		var line docker.LogLine
+		if err := json.Unmarshal([]byte(data), &line); err == nil {
+			onLine(line)
+		}
+		return false
+	}
+	path := fmt.Sprintf("logs/%s?%s", url.PathEscape(appName), params.Encode())
+	if err := c.api.Stream(ctx, path, handler); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("failed to stream logs of %s: %w", appName, wrapError(err))
+	}
+	return nil
+}
+
+// Stop stops an app's containers, which Start brings back.
+func (c *Client) Stop(ctx context.Context, appName string) error {
+	if err := c.api.Post(ctx, "stop/"+url.PathEscape(appName), nil, nil); err != nil {
+		return fmt.Errorf("failed to stop %s: %w", appName, wrapError(err))
+	}
+	return nil
+}
+
+// Start starts the containers of an app stopped with Stop and returns their
+// IDs. None are returned for an app that's already running.
+func (c *Client) Start(ctx context.Context, appName string) ([]string, error) {
+	var response apitypes.StartAppResponse
+	if err := c.api.Post(ctx, "start/"+url.PathEscape(appName), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", appName, wrapError(err))
+	}
+	return response.ContainerIDs, nil
+}
+
+// DestroyOptions controls what Destroy removes besides the app's
+// containers, images and deployment history.
+type DestroyOptions struct {
+	KeepVolumes        bool
+	RemoveCertificates bool
+}
+
+// Destroy removes an app from the server like haloy destroy.
+func (c *Client) Destroy(ctx context.Context, appName string, opts DestroyOptions) (*DestroyResult, error) {
+	request := apitypes.DestroyAppRequest{
+		KeepVolumes:        opts.KeepVolumes,
+		RemoveCertificates: opts.RemoveCertificates,
+	}
+	var response apitypes.DestroyAppResponse
+	if err := c.api.Post(ctx, "destroy/"+url.PathEscape(appName), request, &response); err != nil {
+		return nil, fmt.Errorf("failed to destroy %s: %w", appName, wrapError(err))
+	}
+	return &response, nil
+}
@@ -0,0 +1,281 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/configloader"
+	"github.com/haloydev/haloy/internal/errorpages"
+	"github.com/haloydev/haloy/internal/helpers"
+	"github.com/haloydev/haloy/internal/logging"
+)
+
+// defaultHolder is shown as the holder of an app's deploy lock when
+// DeployOptions.Holder is empty.
+const defaultHolder = "haloy sdk"
+
+// Target is a deployable target of a haloy config.
+type Target struct {
+	// Name is the target's name in the config. For a config without
+	// targets, it's the app name.
+	Name string
+	// Config is the target with defaults filled in and secrets resolved.
+	// Config.Server is the server to deploy to, and Config.APIToken holds
+	// its token when the config sets one.
+	Config TargetConfig
+	// ErrorPages are the custom error pages read from the target's
+	// error_pages directory.
+	ErrorPages map[string]string
+
+	// rollback is the target before secrets were resolved, which the server
+	// keeps to roll back to this deployment.
+	rollback DeployConfig
+}
+
+// LoadOptions selects the targets of a config, like the --targets and --all
+// flags of haloy.
+type LoadOptions struct {
+	Targets []string
+	All     bool
+}
+
+// LoadTargets loads the haloy config at configPath, a file or a directory
+// holding one, and returns the selected targets sorted by name. Secrets are
+// resolved from the config's secret providers.
+func LoadTargets(ctx context.Context, configPath string, opts LoadOptions) ([]Target, error) {
+	rawDeployConfig, _, err := configloader.Load(ctx, configPath, opts.Targets, opts.All)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+	configFile, err := configloader.FindConfigFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+	return resolveTargets(ctx, rawDeployConfig, filepath.Dir(configFile))
+}
+
+// NewTarget returns the target for a config built in code, filling in
+// defaults and resolving secrets like LoadTargets. Relative paths in the
+// config, like error_pages, are resolved against the working directory.
+func NewTarget(ctx context.Context, targetConfig TargetConfig) (Target, error) {
+	if targetConfig.Format == "" {
+		targetConfig.Format = "yaml"
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return Target{}, err
+	}
+	targets, err := resolveTargets(ctx, DeployConfig{TargetConfig: targetConfig}, dir)
+	if err != nil {
+		return Target{}, err
+	}
+	if len(targets) != 1 {
+		return Target{}, fmt.Errorf("config resolved to %d targets, use LoadTargets for configs with several targets", len(targets))
+	}
+	return targets[0], nil
+}
+
+// resolveTargets returns the targets of a config whose relative paths are
+// relative to configDir.
+func resolveTargets(ctx context.Context, rawDeployConfig DeployConfig, configDir string) ([]Target, error) {
+	format := rawDeployConfig.Format
+	resolvedDeployConfig, err := configloader.ResolveSecretsInDir(ctx, rawDeployConfig, configDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+	rawTargets, err := configloader.ExtractTargets(rawDeployConfig, format)
+	if err != nil {
+		return nil, err
+	}
+	resolvedTargets, err := configloader.ExtractTargets(resolvedDeployConfig, format)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]Target, 0, len(resolvedTargets))
+	for _, name := range slices.Sorted(maps.Keys(resolvedTargets)) {
+		resolved := resolvedTargets[name]
+		raw, ok := rawTargets[name]
+		if !ok {
+			return nil, fmt.Errorf("could not find raw target for %s", name)
+		}
+		if err := configloader.InterpolateEnvVars(resolved.Env); err != nil {
+			return nil, fmt.Errorf("target '%s': %w", name, err)
+		}
+		pages, err := readErrorPages(resolved, configDir)
+		if err != nil {
+			return nil, fmt.Errorf("target '%s': %w", name, err)
+		}
+		targets = append(targets, Target{
+			Name:       name,
+			Config:     resolved,
+			ErrorPages: pages,
+			rollback: DeployConfig{
+				TargetConfig:    raw,
+				SecretProviders: rawDeployConfig.SecretProviders,
+			},
+		})
+	}
+	return targets, nil
+}
+
+// readErrorPages reads the target's error pages, resolving a relative
+// directory against configDir.
+func readErrorPages(targetConfig TargetConfig, configDir string) (map[string]string, error) {
+	if targetConfig.ErrorPages == "" {
+		return nil, nil
+	}
+	dir := targetConfig.ErrorPages
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(configDir, dir)
+	}
+	pages, err := errorpages.ReadBundle(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", config.GetFieldNameForFormat(config.TargetConfig{}, "ErrorPages", targetConfig.Format), err)
+	}
+	return pages, nil
+}
+
+// DeployOptions controls a deployment.
+type DeployOptions struct {
+	// Holder names who deploys, shown to others while the app is locked.
+	// The default is "haloy sdk".
+	Holder string
+	// ForceUnlock cancels a deployment of the app that's still running
+	// instead of failing with a *DeployLockedError.
+	ForceUnlock bool
+}
+
+// Deploy starts deploying target and returns the deployment's ID once the
+// server accepted it. Follow the deployment with WaitForDeployment.
+func (c *Client) Deploy(ctx context.Context, target Target, opts DeployOptions) (string, error) {
+	if target.Config.Image != nil && target.Config.Image.ShouldBuild() {
+		return "", fmt.Errorf("target '%s': %w", target.Name, ErrBuildRequired)
+	}
+	holder := opts.Holder
+	if holder == "" {
+		holder = defaultHolder
+	}
+	rollback := target.rollback
+	if rollback.Name == "" {
+		rollback = DeployConfig{TargetConfig: target.Config}
+	}
+
+	deploymentID := helpers.NewDeploymentID()
+	request := apitypes.DeployRequest{
+		TargetConfig:         target.Config,
+		RollbackDeployConfig: rollback,
+		DeploymentID:         deploymentID,
+		Holder:               holder,
+		ForceUnlock:          opts.ForceUnlock,
+		ErrorPages:           target.ErrorPages,
+	}
+	if err := c.api.PostIdempotent(ctx, "deploy", deploymentID, request, nil); err != nil {
+		return "", fmt.Errorf("failed to deploy %s: %w", target.Config.Name, wrapError(err))
+	}
+	return deploymentID, nil
+}
+
+// WaitForDeployment follows the log of a deployment until it completes,
+// passing each entry to onLog if it isn't nil. It returns a
+// *DeploymentFailedError when the deployment failed.
+func (c *Client) WaitForDeployment(ctx context.Context, deploymentID string, onLog func(LogEntry)) error {
+	var failure *logging.LogEntry
+	var complete bool
+	handler := func(data string) bool {
+		var entry logging.LogEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return false
+		}
+		if onLog != nil {
+			onLog(entry)
+		}
+		if entry.IsDeploymentFailed {
+			failure = &entry
+		}
+		complete = entry.IsDeploymentComplete
+		return complete
+	}
+
+	if err := c.api.StreamResumable(ctx, fmt.Sprintf("deploy/%s/logs", deploymentID), handler); err != nil {
+		return fmt.Errorf("failed to follow deployment %s: %w", deploymentID, wrapError(err))
+	}
+	if failure != nil {
+		message := failure.Message
+		if cause, ok := failure.Fields[logging.AttrError].(string); ok && cause != "" {
+			message = cause
+		}
+		failed := &DeploymentFailedError{
+			DeploymentID: deploymentID,
+			App:          failure.AppName,
+			Message:      message,
+			HealthCheck:  failure.FailureKind() == logging.FailureKindHealthCheck,
+		}
+		if failure.Event != nil {
+			failed.ErrorClass = failure.Event.ErrorClass
+		}
+		return failed
+	}
+	if !complete {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("log of deployment %s ended before it completed", deploymentID)
+	}
+	return nil
+}
+
+// DeploymentStatus returns the lifecycle of a deployment: the state it is in
+// and the transitions that led there. It returns ErrNotFound for a
+// deployment the server has no record of.
+func (c *Client) DeploymentStatus(ctx context.Context, deploymentID string) (*DeploymentStatus, error) {
+	var response apitypes.DeploymentStatusResponse
+	if err := c.api.Get(ctx, "deploy/"+url.PathEscape(deploymentID), &response); err != nil {
+		return nil, fmt.Errorf("failed to get status of deployment %s: %w", deploymentID, wrapError(err))
+	}
+	return &response, nil
+}
+
+// DeploymentLogs returns the log haloyd recorded for a deployment, the one
+// WaitForDeployment follows, also after the deployment ended. It returns
+// ErrNotFound when the server has no log of the deployment.
+func (c *Client) DeploymentLogs(ctx context.Context, deploymentID string) ([]LogEntry, error) {
+	var entries []LogEntry
+	path := "deployments/" + url.PathEscape(deploymentID) + "/logs"
+	for after := int64(0); ; {
+		pagePath := path
+		if after > 0 {
+			pagePath += "?after=" + strconv.FormatInt(after, 10)
+		}
+		var response apitypes.DeploymentLogsResponse
+		if err := c.api.Get(ctx, pagePath, &response); err != nil {
+			return nil, fmt.Errorf("failed to get logs of deployment %s: %w", deploymentID, wrapError(err))
+		}
+		entries = append(entries, response.Entries...)
+		if response.Next == 0 {
+			return entries, nil
+		}
+		after = response.Next
+	}
+}
+
+// CancelDeployment aborts a deployment that hasn't gone live and removes the
+// containers it started; the app keeps serving the deployment it had. It
+// returns ErrNotFound for a deployment the server has no record of, and an
+// *APIError with status 409 for one that already went live or failed.
+func (c *Client) CancelDeployment(ctx context.Context, deploymentID string) error {
+	path := "deployments/" + url.PathEscape(deploymentID) + "/cancel"
+	if err := c.api.Post(ctx, path, nil, nil); err != nil {
+		return fmt.Errorf("failed to cancel deployment %s: %w", deploymentID, wrapError(err))
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+	"github.com/haloydev/haloy/internal/apitypes"
+)
+
+var (
+	// ErrNotFound is returned when the server doesn't know the app or
+	// resource asked for.
+	ErrNotFound = apiclient.ErrNotFound
+	// ErrUnauthorized is returned when the server rejects the API token.
+	ErrUnauthorized = apiclient.ErrUnauthorized
+	// ErrUnreachable is returned when the server can't be connected to.
+	ErrUnreachable = apiclient.ErrUnreachable
+	// ErrBuildRequired is returned for targets whose image haloy builds,
+	// which the SDK can't deploy.
+	ErrBuildRequired = errors.New("the image must be built and pushed to a registry with haloy first")
+)
+
+// APIError is returned when the server answers with an error status.
+type APIError struct {
+	StatusCode int
+	// Message is the error the server sent, if any.
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("server returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("server returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// DeployLockedError is returned when an app is already being deployed.
+// Deploying with DeployOptions.ForceUnlock cancels that deployment instead.
+type DeployLockedError struct {
+	App          string
+	DeploymentID string
+	Holder       string
+	AcquiredAt   time.Time
+	ExpiresAt    time.Time
+}
+
+func (e *DeployLockedError) Error() string {
+	holder := e.Holder
+	if holder == "" {
+		holder = "another client"
+	}
+	return fmt.Sprintf("app '%s' is locked by %s (deployment %s)", e.App, holder, e.DeploymentID)
+}
+
+// DeploymentFailedError is returned by WaitForDeployment for a deployment
+// the server reported as failed.
+type DeploymentFailedError struct {
+	DeploymentID string
+	App          string
+	Message      string
+	// HealthCheck is set when the new containers failed their health check.
+	HealthCheck bool
+	// ErrorClass is the failure kind, like "health_check" or "canceled", or
+	// else the phase the deployment failed in, like "pull" or "start".
+	ErrorClass string
+}
+
+func (e *DeploymentFailedError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("deployment %s failed", e.DeploymentID)
+	}
+	return fmt.Sprintf("deployment %s failed: %s", e.DeploymentID, e.Message)
+}
+
+// wrapError turns the HTTP errors of apiclient into the errors of this
+// package.
+func wrapError(err error) error {
+	var httpErr *apiclient.HTTPError
+	if err == nil || !errors.As(err, &httpErr) {
+		return err
+	}
+
+	if httpErr.StatusCode == http.StatusConflict {
+		var locked apitypes.DeployLockedResponse
+		if json.Unmarshal([]byte(httpErr.Body), &locked) == nil && locked.Lock.DeploymentID != "" {
+			return &DeployLockedError{
+				App:          locked.Lock.App,
+				DeploymentID: locked.Lock.DeploymentID,
+				Holder:       locked.Lock.Holder,
+				AcquiredAt:   locked.Lock.AcquiredAt,
+				ExpiresAt:    locked.Lock.ExpiresAt,
+			}
+		}
+	}
+	if httpErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotFound, httpErr.Body)
+	}
+	return &APIError{StatusCode: httpErr.StatusCode, Message: httpErr.Body}
+}
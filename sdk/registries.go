@@ -0,0 +1,36 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+)
+
+// Registries lists the container registries the server stores credentials
+// for. Passwords never leave the server.
+func (c *Client) Registries(ctx context.Context) ([]Registry, error) {
+	var response apitypes.RegistriesResponse
+	if err := c.api.Get(ctx, "registries", &response); err != nil {
+		return nil, fmt.Errorf("failed to list registries: %w", wrapError(err))
+	}
+	return response.Registries, nil
+}
+
+// RegistryLogin stores credentials for a container registry on the server,
+// which uses them to pull images of apps whose config has none.
+func (c *Client) RegistryLogin(ctx context.Context, registry, username, password string) error {
+	request := apitypes.RegistryLoginRequest{Server: registry, Username: username, Password: password}
+	if err := c.api.Post(ctx, "registries/login", request, nil); err != nil {
+		return fmt.Errorf("failed to store credentials for %s: %w", registry, wrapError(err))
+	}
+	return nil
+}
+
+// RegistryLogout removes the server's credentials for a container registry.
+func (c *Client) RegistryLogout(ctx context.Context, registry string) error {
+	if err := c.api.Post(ctx, "registries/logout", apitypes.RegistryLogoutRequest{Server: registry}, nil); err != nil {
+		return fmt.Errorf("failed to remove credentials for %s: %w", registry, wrapError(err))
+	}
+	return nil
+}
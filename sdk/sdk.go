@@ -0,0 +1,85 @@
+// Package sdk deploys and manages haloy apps from Go programs, such as
+// internal platforms and bots, without running the haloy binary.
+//
+// Load targets from a haloy config with LoadTargets, or build one with
+// NewTarget, and deploy it with a Client for its server:
+//
+//	targets, err := sdk.LoadTargets(ctx, "haloy.yaml", sdk.LoadOptions{})
+//	...
+//	client, err := sdk.NewClient(targets[0].Config.Server, token)
+//	...
+//	id, err := client.Deploy(ctx, targets[0], sdk.DeployOptions{})
+//	...
+//	err = client.WaitForDeployment(ctx, id, func(e sdk.LogEntry) { log.Println(e.Message) })
+//
+// Secrets referenced by a config are resolved from its secret providers when
+// the target is loaded, like haloy deploy does. The SDK deploys images from a
+// registry: images that haloy builds must be built and pushed with haloy
+// first. Hooks like pre_deploy are the caller's to run.
+//
+// Errors from the server can be matched with errors.Is against ErrNotFound,
+// ErrUnauthorized and ErrUnreachable, and with errors.As against *APIError,
+// *DeployLockedError and *DeploymentFailedError.
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apiclient"
+)
+
+// Client talks to the API of one haloy server. It's safe for concurrent use.
+type Client struct {
+	api    *apiclient.APIClient
+	server string
+}
+
+// Option configures a Client.
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	timeout time.Duration
+}
+
+// WithTimeout sets how long the client waits for the server to start
+// answering a request. Streams of logs aren't bounded by it. The default is
+// 30 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// NewClient returns a client for the server at url, e.g.
+// "haloy.example.com", authenticating with token.
+func NewClient(url, token string, opts ...Option) (*Client, error) {
+	options := clientOptions{timeout: 30 * time.Second}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if token == "" {
+		return nil, errors.New("an API token is required")
+	}
+	api, err := apiclient.NewWithTimeout(url, token, options.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{api: api, server: url}, nil
+}
+
+// Server returns the server the client was created for.
+func (c *Client) Server() string {
+	return c.server
+}
+
+// Version returns the version of haloyd on the server.
+func (c *Client) Version(ctx context.Context) (string, error) {
+	var response VersionResponse
+	if err := c.api.Get(ctx, "version", &response); err != nil {
+		return "", fmt.Errorf("failed to get version: %w", wrapError(err))
+	}
+	return response.Version, nil
+}
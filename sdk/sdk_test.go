@@ -0,0 +1,205 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/logging"
+)
+
+// newTestClient returns a client for a server answering with handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := NewClient(srv.URL, "secret")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+func writeLogStream(w http.ResponseWriter, entries ...logging.LogEntry) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, entry := range entries {
+		data, _ := json.Marshal(entry)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+}
+
+func TestDeploy(t *testing.T) {
+	target, err := NewTarget(context.Background(), TargetConfig{
+		Name:  "shop",
+		Image: &Image{Repository: "ghcr.io/acme/shop", Tag: "1.0"},
+		Env:   []EnvVar{{Name: "MODE", ValueSource: ValueSource{Value: "production"}}},
+	})
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+
+	var request apitypes.DeployRequest
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/deploy":
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Errorf("decoding deploy request: %v", err)
+			}
+			w.WriteHeader(http.StatusAccepted)
+		case "/v1/deploy/" + request.DeploymentID + "/logs":
+			writeLogStream(w,
+				logging.LogEntry{Message: "Pulling image"},
+				logging.LogEntry{Message: "Deployment complete", IsDeploymentComplete: true, IsDeploymentSuccess: true},
+			)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	id, err := client.Deploy(context.Background(), target, DeployOptions{})
+	if err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+	if request.DeploymentID != id || request.TargetConfig.Name != "shop" || request.Holder != defaultHolder {
+		t.Errorf("Deploy() sent %+v, want deployment %s of shop by %q", request, id, defaultHolder)
+	}
+	if request.RollbackDeployConfig.Name != "shop" {
+		t.Errorf("Deploy() rollback config name = %q, want shop", request.RollbackDeployConfig.Name)
+	}
+
+	var messages []string
+	if err := client.WaitForDeployment(context.Background(), id, func(e LogEntry) { messages = append(messages, e.Message) }); err != nil {
+		t.Fatalf("WaitForDeployment() error = %v", err)
+	}
+	if strings.Join(messages, ", ") != "Pulling image, Deployment complete" {
+		t.Errorf("WaitForDeployment() logged %v", messages)
+	}
+}
+
+func TestDeployRequiresPrebuiltImage(t *testing.T) {
+	target, err := NewTarget(context.Background(), TargetConfig{
+		Name:  "shop",
+		Image: &Image{Repository: "shop", BuildConfig: &BuildConfig{}},
+	})
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+	client, err := NewClient("haloy.example.com", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Deploy(context.Background(), target, DeployOptions{}); !errors.Is(err, ErrBuildRequired) {
+		t.Errorf("Deploy() error = %v, want ErrBuildRequired", err)
+	}
+}
+
+func TestWaitForDeploymentFailed(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeLogStream(w, logging.LogEntry{
+			Message:              "Deployment failed",
+			AppName:              "shop",
+			Fields:               map[string]any{logging.AttrError: "container exited", logging.AttrFailureKind: logging.FailureKindHealthCheck},
+			Event:                &logging.DeploymentEvent{Phase: logging.PhaseFailed, ErrorClass: logging.FailureKindHealthCheck},
+			IsDeploymentComplete: true,
+			IsDeploymentFailed:   true,
+		})
+	})
+
+	err := client.WaitForDeployment(context.Background(), "01abc", nil)
+	var failed *DeploymentFailedError
+	if !errors.As(err, &failed) {
+		t.Fatalf("WaitForDeployment() error = %v, want *DeploymentFailedError", err)
+	}
+	if failed.App != "shop" || failed.Message != "container exited" || !failed.HealthCheck || failed.ErrorClass != logging.FailureKindHealthCheck {
+		t.Errorf("WaitForDeployment() error = %+v", failed)
+	}
+}
+
+func TestDeploymentLogs(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/deployments/01abc/logs" {
+			http.NotFound(w, r)
+			return
+		}
+		response := apitypes.DeploymentLogsResponse{DeploymentID: "01abc"}
+		if r.URL.Query().Get("after") == "" {
+			response.Entries = []logging.LogEntry{{Message: "Pulling image"}}
+			response.Next = 7
+		} else {
+			response.Entries = []logging.LogEntry{{Message: "Deployment complete", IsDeploymentComplete: true}}
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+
+	entries, err := client.DeploymentLogs(context.Background(), "01abc")
+	if err != nil {
+		t.Fatalf("DeploymentLogs() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Message != "Pulling image" || !entries[1].IsDeploymentComplete {
+		t.Errorf("DeploymentLogs() = %+v, want both pages", entries)
+	}
+	if _, err := client.DeploymentLogs(context.Background(), "01gone"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeploymentLogs() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	acquiredAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/stop/shop":
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(apitypes.DeployLockedResponse{
+				Error: "locked",
+				Lock:  apitypes.DeployLockInfo{App: "shop", DeploymentID: "01abc", Holder: "ci", AcquiredAt: acquiredAt},
+			})
+		case "/v1/start/shop":
+			http.Error(w, "docker is down", http.StatusInternalServerError)
+		case "/v1/deployments/01live/cancel":
+			http.Error(w, "Deployment 01live is live and can no longer be canceled", http.StatusConflict)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	var locked *DeployLockedError
+	if err := client.Stop(context.Background(), "shop"); !errors.As(err, &locked) || locked.Holder != "ci" || !locked.AcquiredAt.Equal(acquiredAt) {
+		t.Errorf("Stop() error = %v, want *DeployLockedError held by ci", err)
+	}
+	var apiErr *APIError
+	if _, err := client.Start(context.Background(), "shop"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError || apiErr.Message != "docker is down" {
+		t.Errorf("Start() error = %v, want *APIError with the server's message", err)
+	}
+	if _, err := client.Status(context.Background(), "blog"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Status() error = %v, want ErrNotFound", err)
+	}
+	if err := client.CancelDeployment(context.Background(), "01live"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("CancelDeployment() error = %v, want *APIError with status 409", err)
+	}
+
+	unauthorized, err := NewClient(client.Server(), "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unauthorized.Registries(context.Background()); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Registries() error = %v, want ErrUnauthorized", err)
+	}
+}
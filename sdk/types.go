@@ -0,0 +1,49 @@
+package sdk
+
+import (
+	"github.com/haloydev/haloy/internal/apitypes"
+	"github.com/haloydev/haloy/internal/config"
+	"github.com/haloydev/haloy/internal/docker"
+	"github.com/haloydev/haloy/internal/logging"
+)
+
+// Config types, for building targets in code. Their fields match the keys of
+// a haloy config file.
+type (
+	DeployConfig    = config.DeployConfig
+	TargetConfig    = config.TargetConfig
+	Image           = config.Image
+	BuildConfig     = config.BuildConfig
+	Domain          = config.Domain
+	EnvVar          = config.EnvVar
+	ValueSource     = config.ValueSource
+	SourceReference = config.SourceReference
+	HealthCheck     = config.HealthCheck
+	SecurityOptions = config.SecurityOptions
+	SecretProviders = config.SecretProviders
+)
+
+// LogEntry is a line of a deployment's log.
+type LogEntry = logging.LogEntry
+
+// DeploymentEvent is the phase and progress of a deployment a LogEntry
+// records, in its Event field.
+type DeploymentEvent = logging.DeploymentEvent
+
+// LogLine is a line an app's container wrote.
+type LogLine = docker.LogLine
+
+// AppStatus is the state of an app's containers.
+type AppStatus = apitypes.AppStatusResponse
+
+// DeploymentStatus is where a deployment is in its lifecycle.
+type DeploymentStatus = apitypes.DeploymentStatusResponse
+
+// DestroyResult lists what destroying an app removed.
+type DestroyResult = apitypes.DestroyAppResponse
+
+// VersionResponse holds the versions of haloyd and haloy-proxy on a server.
+type VersionResponse = apitypes.VersionResponse
+
+// Registry is a container registry the server has credentials for.
+type Registry = apitypes.RegistryEntry